@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Error-handling checks, by name.
+const (
+	checkIgnored   = "ignored"   // A call's error result is dropped by an expression statement (deferred calls are not reported)
+	checkDiscarded = "discarded" // An error result is assigned to _
+	checkShadow    = "shadow"    // err is redeclared while the outer err is still used later
+	checkCompare   = "compare"   // err == ErrX or a type assertion where wrapped errors need errors.Is/As
+	checkErrorsAs  = "errors-as" // errors.As with a target that can never match
+	checkErrorsIs  = "errors-is" // errors.Is with a target that can never match
+)
+
+// errorChecks lists every check, in report order.
+var errorChecks = []string{checkIgnored, checkDiscarded, checkShadow, checkCompare, checkErrorsAs, checkErrorsIs}
+
+// neverFailing are calls whose error result is nil by contract or by
+// convention left unchecked, as in errcheck's default exclusions.
+var neverFailing = []string{
+	"fmt.Print", "fmt.Fprint", "(*strings.Builder).", "(*bytes.Buffer).", "(hash.Hash).",
+}
+
+// finding is a reported error-handling problem.
+type finding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+	Func    string `json:"func,omitempty"`
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// calleeName is the full name of a called function or method, e.g.
+// "os.Open" or "(*os.File).Close", or "" for calls of function values.
+func calleeName(info *types.Info, call *ast.CallExpr) string {
+	var ident *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	}
+	if ident == nil {
+		return ""
+	}
+	if fn, ok := info.Uses[ident].(*types.Func); ok {
+		return fn.FullName()
+	}
+	return ""
+}
+
+// errorResult is the index of a call's error result, or -1 without one.
+func errorResult(info *types.Info, call *ast.CallExpr) int {
+	switch t := info.TypeOf(call).(type) {
+	case *types.Tuple:
+		for i := t.Len() - 1; i >= 0; i-- {
+			if types.Identical(t.At(i).Type(), errorType) {
+				return i
+			}
+		}
+	case nil:
+	default:
+		if types.Identical(t, errorType) {
+			return 0
+		}
+	}
+	return -1
+}
+
+// isErrorValue reports whether an expression is an error-typed value.
+func isErrorValue(info *types.Info, expr ast.Expr) bool {
+	t := info.TypeOf(expr)
+	return t != nil && types.Identical(t, errorType)
+}
+
+// isSentinel reports whether an expression names a package-level error
+// variable, such as io.EOF.
+func isSentinel(info *types.Info, expr ast.Expr) (string, bool) {
+	var ident *ast.Ident
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		ident = expr
+	case *ast.SelectorExpr:
+		ident = expr.Sel
+	}
+	if ident == nil {
+		return "", false
+	}
+	v, ok := info.Uses[ident].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() || !types.Implements(v.Type(), errorType.Underlying().(*types.Interface)) {
+		return "", false
+	}
+	return types.ExprString(expr), true
+}
+
+// errorFindings runs the error-handling checks over one package.
+func (w *workspace) errorFindings(p *pkg, enabled map[string]bool) []finding {
+	var findings []finding
+	for _, file := range p.files {
+		funcOf := enclosingFuncs(file)
+		report := func(node ast.Node, check, message string) {
+			if !enabled[check] {
+				return
+			}
+			position := w.fset.Position(node.Pos())
+			rel, _ := filepath.Rel(w.root, position.Filename)
+			findings = append(findings, finding{
+				File: rel, Line: position.Line, Column: position.Column, Check: check, Message: message, Func: funcOf(node.Pos()),
+			})
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.ExprStmt:
+				if call, ok := ast.Unparen(node.X).(*ast.CallExpr); ok && errorResult(p.info, call) >= 0 {
+					name := calleeName(p.info, call)
+					for _, prefix := range neverFailing {
+						if strings.HasPrefix(name, prefix) {
+							return true
+						}
+					}
+					report(call, checkIgnored, fmt.Sprintf("error returned by %s is not checked", types.ExprString(call.Fun)))
+				}
+			case *ast.AssignStmt:
+				if len(node.Rhs) != 1 {
+					return true
+				}
+				call, ok := ast.Unparen(node.Rhs[0]).(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if index := errorResult(p.info, call); index >= 0 && index < len(node.Lhs) {
+					if blank, ok := node.Lhs[index].(*ast.Ident); ok && blank.Name == "_" {
+						report(blank, checkDiscarded, fmt.Sprintf("error returned by %s is assigned to _", types.ExprString(call.Fun)))
+					}
+				}
+			case *ast.BinaryExpr:
+				if node.Op != token.EQL && node.Op != token.NEQ {
+					return true
+				}
+				for _, pair := range [][2]ast.Expr{{node.X, node.Y}, {node.Y, node.X}} {
+					if sentinel, ok := isSentinel(p.info, pair[1]); ok && isErrorValue(p.info, pair[0]) {
+						report(node, checkCompare, fmt.Sprintf("comparing with %s misses wrapped errors; use errors.Is(%s, %s)", sentinel, types.ExprString(pair[0]), sentinel))
+						return true
+					}
+					// err.Error() == "..."
+					if call, ok := ast.Unparen(pair[0]).(*ast.CallExpr); ok && len(call.Args) == 0 {
+						if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Error" && isErrorValue(p.info, sel.X) {
+							if lit, ok := ast.Unparen(pair[1]).(*ast.BasicLit); ok && lit.Kind == token.STRING {
+								report(node, checkCompare, fmt.Sprintf("comparing %s text is brittle; compare with a sentinel error using errors.Is", types.ExprString(sel.X)))
+								return true
+							}
+						}
+					}
+				}
+			case *ast.TypeAssertExpr:
+				if node.Type != nil && isErrorValue(p.info, node.X) {
+					report(node, checkCompare, fmt.Sprintf("type assertion on %s misses wrapped errors; use errors.As", types.ExprString(node.X)))
+				}
+			case *ast.TypeSwitchStmt:
+				var x ast.Expr
+				switch assign := node.Assign.(type) {
+				case *ast.AssignStmt:
+					x = assign.Rhs[0].(*ast.TypeAssertExpr).X
+				case *ast.ExprStmt:
+					x = assign.X.(*ast.TypeAssertExpr).X
+				}
+				if x != nil && isErrorValue(p.info, x) {
+					report(node, checkCompare, fmt.Sprintf("type switch on %s misses wrapped errors; use errors.As", types.ExprString(x)))
+				}
+			case *ast.CallExpr:
+				w.checkErrorsCall(p, node, report)
+			}
+			return true
+		})
+		w.shadowFindings(p, file, report)
+	}
+	return findings
+}
+
+// checkErrorsCall checks the target of errors.Is and errors.As.
+func (w *workspace) checkErrorsCall(p *pkg, call *ast.CallExpr, report func(ast.Node, string, string)) {
+	name := calleeName(p.info, call)
+	if (name != "errors.As" && name != "errors.Is") || len(call.Args) != 2 {
+		return
+	}
+	target := call.Args[1]
+	targetType := p.info.TypeOf(target)
+	if targetType == nil {
+		return
+	}
+	if name == "errors.Is" {
+		switch {
+		case p.info.Types[target].IsNil():
+			report(target, checkErrorsIs, "errors.Is with a nil target is err == nil")
+		case isAddressOfLiteral(target):
+			report(target, checkErrorsIs, fmt.Sprintf("errors.Is(%s, %s) compares with a new pointer, which never matches; use errors.As", types.ExprString(call.Args[0]), types.ExprString(target)))
+		}
+		return
+	}
+	pointer, ok := targetType.Underlying().(*types.Pointer)
+	switch {
+	case !ok:
+		report(target, checkErrorsAs, fmt.Sprintf("second argument to errors.As must be a pointer, not %s", types.TypeString(targetType, types.RelativeTo(p.types))))
+	case types.Identical(pointer.Elem(), errorType):
+		report(target, checkErrorsAs, "second argument to errors.As is *error, which any error matches")
+	case !types.IsInterface(pointer.Elem()) && !types.Implements(pointer.Elem(), errorType.Underlying().(*types.Interface)):
+		report(target, checkErrorsAs, fmt.Sprintf("second argument to errors.As points to %s, which does not implement error", types.TypeString(pointer.Elem(), types.RelativeTo(p.types))))
+	}
+}
+
+// isAddressOfLiteral reports whether an expression is &T{...}.
+func isAddressOfLiteral(expr ast.Expr) bool {
+	unary, ok := ast.Unparen(expr).(*ast.UnaryExpr)
+	if !ok || unary.Op != token.AND {
+		return false
+	}
+	_, ok = ast.Unparen(unary.X).(*ast.CompositeLit)
+	return ok
+}
+
+// shadowFindings reports err variables declared in an inner scope of a
+// function while an outer err is used after that scope ends, so an error
+// assigned inside is lost to the code that checks the outer one.
+func (w *workspace) shadowFindings(p *pkg, file *ast.File, report func(ast.Node, string, string)) {
+	uses := map[types.Object][]token.Pos{}
+	for ident, obj := range p.info.Uses {
+		if obj.Name() == "err" && w.fset.File(ident.Pos()) == w.fset.File(file.Pos()) {
+			uses[obj] = append(uses[obj], ident.Pos())
+		}
+	}
+	for ident, obj := range p.info.Defs {
+		v, ok := obj.(*types.Var)
+		if !ok || v.Name() != "err" || v.IsField() || w.fset.File(ident.Pos()) != w.fset.File(file.Pos()) {
+			continue
+		}
+		scope := v.Parent()
+		if scope == nil || scope.Parent() == nil {
+			continue
+		}
+		_, outer := scope.Parent().LookupParent("err", ident.Pos())
+		outerVar, ok := outer.(*types.Var)
+		if !ok || outerVar.Parent() == nil || outerVar.Parent() == p.types.Scope() || outerVar.Parent() == types.Universe {
+			continue
+		}
+		for _, use := range uses[outerVar] {
+			if use > scope.End() {
+				position := w.fset.Position(outerVar.Pos())
+				report(ident, checkShadow, fmt.Sprintf("err shadows err declared at line %d, which is used after this scope", position.Line))
+				break
+			}
+		}
+	}
+}
+
+// runErrors is the errors subcommand: it prints the findings of the
+// error-handling checks under a directory as JSON, for the go_error_checks tool.
+func runErrors(args []string) int {
+	flags := flag.NewFlagSet("errors", flag.ContinueOnError)
+	root := flags.String("root", ".", "workspace root")
+	dir := flags.String("path", "", "directory to check (default: the root)")
+	checks := flags.String("checks", strings.Join(errorChecks, ","), "comma-separated checks to run")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: goanalysis errors [-root DIR] [-path DIR] [-checks LIST]")
+		fmt.Fprintln(flags.Output(), "checks:", strings.Join(errorChecks, ", "))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	enabled := map[string]bool{}
+	for _, check := range strings.Split(*checks, ",") {
+		check = strings.TrimSpace(check)
+		known := false
+		for _, name := range errorChecks {
+			known = known || name == check
+		}
+		if !known {
+			fmt.Fprintf(os.Stderr, "unknown check %q; checks: %s\n", check, strings.Join(errorChecks, ", "))
+			return 2
+		}
+		enabled[check] = true
+	}
+	absRoot, err := filepath.Abs(*root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	searchDir := absRoot
+	if *dir != "" {
+		if searchDir, err = filepath.Abs(*dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	w := newWorkspace(absRoot)
+	findings := []finding{}
+	packages := w.loadUnder(searchDir)
+	for _, p := range packages {
+		findings = append(findings, w.errorFindings(p, enabled)...)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	if err := json.NewEncoder(os.Stdout).Encode(map[string]any{"packages": len(packages), "findings": findings}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestErrorFindings(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/errs\n\ngo 1.21\n",
+		"load.go": `package errs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+type NotFound struct{}
+
+func (*NotFound) Error() string { return "not found" }
+
+func Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	f.Sync()
+	var b strings.Builder
+	b.WriteString("never fails")
+	if true {
+		_, err := f.Read(nil)
+		_ = err
+	}
+	if err == io.EOF {
+		return nil
+	}
+	var nf NotFound
+	errors.As(err, nf)
+	errors.Is(err, &NotFound{})
+	return err
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := newWorkspace(root)
+	enabled := map[string]bool{}
+	for _, check := range errorChecks {
+		enabled[check] = true
+	}
+	var findings []finding
+	for _, p := range w.loadUnder(root) {
+		findings = append(findings, w.errorFindings(p, enabled)...)
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	var got []string
+	for _, f := range findings {
+		got = append(got, fmt.Sprintf("%s@%d in %s", f.Check, f.Line, f.Func))
+	}
+	want := "ignored@20 in Load, shadow@24 in Load, compare@27 in Load, errors-as@31 in Load, errors-is@32 in Load"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("findings = %s", strings.Join(got, ", "))
+	}
+
+	// Only enabled checks are reported
+	for _, p := range w.loadUnder(root) {
+		if findings := w.errorFindings(p, map[string]bool{checkShadow: true}); len(findings) != 1 || findings[0].Check != checkShadow {
+			t.Errorf("shadow findings = %+v", findings)
+		}
+	}
+}
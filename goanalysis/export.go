@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// exportImporter imports packages outside the workspace from the compiler's
+// export data, which "go list -export" finds in the build cache or builds, so
+// a package importing net/http does not type-check the standard library from
+// source. Without the go command, or for a package it cannot build (missing
+// module downloads, cgo without a compiler), the package is type-checked
+// from source instead.
+type exportImporter struct {
+	gc      types.ImporterFrom
+	source  types.ImporterFrom
+	exports map[string]string // import path to export data file; "" when go list has none
+	listed  map[string]bool   // directories go list has run in
+}
+
+func newExportImporter(fset *token.FileSet) *exportImporter {
+	e := &exportImporter{exports: map[string]string{}, listed: map[string]bool{}}
+	e.gc = importer.ForCompiler(fset, "gc", e.lookup).(types.ImporterFrom)
+	e.source = importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)
+	return e
+}
+
+func (e *exportImporter) Import(path string) (*types.Package, error) {
+	return e.ImportFrom(path, "", 0)
+}
+
+func (e *exportImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if _, ok := e.exports[path]; !ok && !e.listed[dir] {
+		e.list(path, dir)
+	}
+	if e.exports[path] != "" {
+		if p, err := e.gc.ImportFrom(path, dir, mode); err == nil {
+			return p, nil
+		}
+	}
+	return e.source.ImportFrom(path, dir, mode)
+}
+
+// lookup opens the export data of a package and of the packages it imports.
+func (e *exportImporter) lookup(path string) (io.ReadCloser, error) {
+	file := e.exports[path]
+	if file == "" {
+		return nil, fmt.Errorf("no export data for %s", path)
+	}
+	return os.Open(file)
+}
+
+// list records the export data of a package and its dependencies, as seen
+// from the module of dir. Downloads are turned off so a query never waits
+// on the network.
+func (e *exportImporter) list(path, dir string) {
+	cmd := exec.Command("go", "list", "-e", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}", path)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPROXY=off")
+	out, err := cmd.Output()
+	if err != nil {
+		// Every import from this module would fail the same way
+		e.listed[dir] = true
+	}
+	e.exports[path] = ""
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if importPath, file, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			e.exports[importPath] = file
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/Gwihwan-Go/grep-for-code/goanalysis
+
+go 1.22
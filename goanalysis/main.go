@@ -0,0 +1,259 @@
+// Command goanalysis is a minimal Go language server built on go/parser and
+// go/types from the standard library. It answers definition, references,
+// implementation, highlight, hover, and symbol requests for the workspace without gopls,
+// for containers where gopls is not installed. It starts instantly; packages
+// are type-checked when a request first needs them, against the export data
+// of their imports from "go list -export" rather than the imports' source.
+//
+// Usage: grep-for-code --workspace DIR --lsp goanalysis
+//
+// "goanalysis typed TYPE" prints the values of a type instead (see runTyped),
+// and "goanalysis errors" error-handling problems (see runErrors).
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// server dispatches LSP messages to a workspace.
+type server struct {
+	out       io.Writer
+	workspace *workspace
+	shutdown  bool
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("goanalysis: ")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "typed":
+			os.Exit(runTyped(os.Args[2:]))
+		case "errors":
+			os.Exit(runErrors(os.Args[2:]))
+		}
+	}
+	s := &server{out: os.Stdout}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Print(err)
+			}
+			return
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				os.Exit(0)
+			}
+			os.Exit(1)
+		}
+		s.handle(msg)
+	}
+}
+
+// handle answers a request or applies a notification.
+func (s *server) handle(msg *message) {
+	result, err := s.dispatch(msg)
+	if len(msg.ID) == 0 {
+		if err != nil {
+			log.Printf("%s: %v", msg.Method, err)
+		}
+		return
+	}
+	var reply any = response{JSONRPC: "2.0", ID: msg.ID, Result: result}
+	if err != nil {
+		var rpcErr *responseError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+		}
+		reply = errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: *rpcErr}
+	}
+	if err := writeMessage(s.out, reply); err != nil {
+		log.Print(err)
+	}
+}
+
+func (e *responseError) Error() string { return e.Message }
+
+// decode reads a request's parameters.
+func decode[T any](msg *message) (T, error) {
+	var params T
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return params, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return params, nil
+}
+
+func (s *server) dispatch(msg *message) (any, error) {
+	if msg.Method == "initialize" {
+		return s.initialize(msg)
+	}
+	if s.workspace == nil {
+		if len(msg.ID) == 0 {
+			return nil, nil
+		}
+		return nil, &responseError{Code: -32002, Message: "server not initialized"}
+	}
+	w := s.workspace
+	switch msg.Method {
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration", "textDocument/didSave":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params, err := decode[struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}](msg)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.setOverlay(params.TextDocument.URI, &params.TextDocument.Text)
+	case "textDocument/didChange":
+		params, err := decode[struct {
+			TextDocument   textDocumentIdentifier `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}](msg)
+		if err != nil || len(params.ContentChanges) == 0 {
+			return nil, err
+		}
+		// Changes are full texts, as advertised; the last one wins
+		return nil, s.setOverlay(params.TextDocument.URI, &params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		params, err := decode[struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}](msg)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.setOverlay(params.TextDocument.URI, nil)
+	case "workspace/didChangeWatchedFiles":
+		w.reset()
+		return nil, nil
+	case "textDocument/definition":
+		params, err := decode[textDocumentPositionParams](msg)
+		if err != nil {
+			return nil, err
+		}
+		return w.definition(params)
+	case "textDocument/references":
+		params, err := decode[referenceParams](msg)
+		if err != nil {
+			return nil, err
+		}
+		return w.references(params)
+	case "textDocument/implementation":
+		params, err := decode[textDocumentPositionParams](msg)
+		if err != nil {
+			return nil, err
+		}
+		return w.implementation(params)
+	case "textDocument/documentHighlight":
+		params, err := decode[textDocumentPositionParams](msg)
+		if err != nil {
+			return nil, err
+		}
+		return w.documentHighlights(params)
+	case "textDocument/hover":
+		params, err := decode[textDocumentPositionParams](msg)
+		if err != nil {
+			return nil, err
+		}
+		return w.hover(params)
+	case "textDocument/documentSymbol":
+		params, err := decode[struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}](msg)
+		if err != nil {
+			return nil, err
+		}
+		return w.documentSymbols(params)
+	case "workspace/symbol":
+		params, err := decode[struct {
+			Query string `json:"query"`
+		}](msg)
+		if err != nil {
+			return nil, err
+		}
+		return w.workspaceSymbols(params)
+	}
+	if len(msg.ID) == 0 {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+}
+
+// initialize opens the workspace and negotiates UTF-8 positions when the
+// client supports them, as they are Go's own columns.
+func (s *server) initialize(msg *message) (any, error) {
+	params, err := decode[struct {
+		RootURI          string `json:"rootUri"`
+		WorkspaceFolders []struct {
+			URI string `json:"uri"`
+		} `json:"workspaceFolders"`
+		Capabilities struct {
+			General struct {
+				PositionEncodings []string `json:"positionEncodings"`
+			} `json:"general"`
+		} `json:"capabilities"`
+	}](msg)
+	if err != nil {
+		return nil, err
+	}
+	rootURI := params.RootURI
+	if len(params.WorkspaceFolders) > 0 {
+		rootURI = params.WorkspaceFolders[0].URI
+	}
+	root, err := uriToPath(rootURI)
+	if err != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	s.workspace = newWorkspace(root)
+	encoding := "utf-16"
+	for _, offered := range params.Capabilities.General.PositionEncodings {
+		if offered == "utf-8" {
+			encoding = "utf-8"
+			s.workspace.utf8 = true
+		}
+	}
+	return map[string]any{
+		"capabilities": map[string]any{
+			"positionEncoding":          encoding,
+			"textDocumentSync":          map[string]any{"openClose": true, "change": 1},
+			"definitionProvider":        true,
+			"referencesProvider":        true,
+			"implementationProvider":    true,
+			"hoverProvider":             true,
+			"documentHighlightProvider": true,
+			"documentSymbolProvider":    true,
+			"workspaceSymbolProvider":   true,
+		},
+		"serverInfo": map[string]any{"name": "goanalysis", "version": "0.1.0"},
+	}, nil
+}
+
+// setOverlay records an open file's buffer, or drops it when text is nil.
+func (s *server) setOverlay(uri string, text *string) error {
+	path, err := uriToPath(uri)
+	if err != nil {
+		return err
+	}
+	if text == nil {
+		s.workspace.setOverlay(path, nil)
+	} else {
+		s.workspace.setOverlay(path, []byte(*text))
+	}
+	return nil
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxWorkspaceSymbols caps workspace/symbol results.
+const maxWorkspaceSymbols = 500
+
+// objectAt returns the object an identifier at a position declares or uses.
+func (w *workspace) objectAt(params textDocumentPositionParams) (types.Object, *pkg, error) {
+	path, err := uriToPath(params.TextDocument.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, file, err := w.fileOf(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := w.readFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	pos := w.fset.File(file.Pos()).Pos(w.offset(content, params.Position))
+	var found *ast.Ident
+	ast.Inspect(file, func(node ast.Node) bool {
+		if node == nil || found != nil || node.Pos() > pos || node.End() < pos {
+			return false
+		}
+		if ident, ok := node.(*ast.Ident); ok {
+			found = ident
+		}
+		return true
+	})
+	if found == nil {
+		return nil, p, nil
+	}
+	if obj := p.info.Defs[found]; obj != nil {
+		return obj, p, nil
+	}
+	return p.info.Uses[found], p, nil
+}
+
+// sameObject reports whether two objects are one declaration. Objects of
+// generic instantiations are distinct values with their origin's position.
+func sameObject(a, b types.Object) bool {
+	return a == b || (a != nil && b != nil && a.Pos().IsValid() && a.Pos() == b.Pos() && a.Name() == b.Name())
+}
+
+// nameLocation is the location of an object's name.
+func (w *workspace) nameLocation(obj types.Object) (Location, bool) {
+	if !obj.Pos().IsValid() || w.fset.File(obj.Pos()) == nil {
+		return Location{}, false
+	}
+	return w.location(obj.Pos(), obj.Pos()+token.Pos(len(obj.Name()))), true
+}
+
+// definition answers textDocument/definition.
+func (w *workspace) definition(params textDocumentPositionParams) ([]Location, error) {
+	obj, _, err := w.objectAt(params)
+	if err != nil || obj == nil {
+		return []Location{}, err
+	}
+	if location, ok := w.nameLocation(obj); ok {
+		return []Location{location}, nil
+	}
+	return []Location{}, nil
+}
+
+// references answers textDocument/references over every workspace package.
+func (w *workspace) references(params referenceParams) ([]Location, error) {
+	target, _, err := w.objectAt(params.textDocumentPositionParams)
+	if err != nil || target == nil {
+		return []Location{}, err
+	}
+	type ref struct {
+		pos      token.Pos
+		location Location
+	}
+	seen := map[token.Pos]bool{}
+	var refs []ref
+	add := func(ident *ast.Ident) {
+		if !seen[ident.Pos()] {
+			seen[ident.Pos()] = true
+			refs = append(refs, ref{ident.Pos(), w.location(ident.Pos(), ident.End())})
+		}
+	}
+	for _, p := range w.loadAll() {
+		for ident, obj := range p.info.Uses {
+			if sameObject(obj, target) {
+				add(ident)
+			}
+		}
+		if params.Context.IncludeDeclaration {
+			for ident, obj := range p.info.Defs {
+				if sameObject(obj, target) {
+					add(ident)
+				}
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].pos < refs[j].pos })
+	locations := make([]Location, len(refs))
+	for i, r := range refs {
+		locations[i] = r.location
+	}
+	return locations, nil
+}
+
+type documentHighlight struct {
+	Range Range `json:"range"`
+	Kind  int   `json:"kind"`
+}
+
+// Document highlight kinds.
+const (
+	highlightRead  = 2
+	highlightWrite = 3
+)
+
+// documentHighlights answers textDocument/documentHighlight: the uses of
+// an identifier's object in its file, as writes where they declare or
+// assign it and reads elsewhere.
+func (w *workspace) documentHighlights(params textDocumentPositionParams) ([]documentHighlight, error) {
+	target, p, err := w.objectAt(params)
+	if err != nil || target == nil {
+		return []documentHighlight{}, err
+	}
+	path, _ := uriToPath(params.TextDocument.URI)
+	_, file, err := w.fileOf(path)
+	if err != nil {
+		return nil, err
+	}
+	assigned := map[*ast.Ident]bool{}
+	mark := func(expr ast.Expr) {
+		switch expr := expr.(type) {
+		case *ast.Ident:
+			assigned[expr] = true
+		case *ast.SelectorExpr:
+			assigned[expr.Sel] = true
+		}
+	}
+	highlights := []documentHighlight{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range node.Lhs {
+				mark(lhs)
+			}
+		case *ast.IncDecStmt:
+			mark(node.X)
+		case *ast.Ident:
+			kind := highlightRead
+			if obj := p.info.Defs[node]; obj != nil && sameObject(obj, target) {
+				kind = highlightWrite
+			} else if obj := p.info.Uses[node]; obj == nil || !sameObject(obj, target) {
+				return true
+			} else if assigned[node] {
+				kind = highlightWrite
+			}
+			highlights = append(highlights, documentHighlight{Range: w.location(node.Pos(), node.End()).Range, Kind: kind})
+		}
+		return true
+	})
+	return highlights, nil
+}
+
+// namedTypes lists the package-level named types of the workspace.
+// Generic types are left out: only their instantiations have method sets.
+func namedTypes(packages []*pkg) []*types.TypeName {
+	var names []*types.TypeName
+	for _, p := range packages {
+		scope := p.types.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() {
+				if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() == 0 {
+					names = append(names, tn)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// implements reports whether T or *T implements an interface.
+func implements(t types.Type, iface *types.Interface) bool {
+	return types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)
+}
+
+// implementation answers textDocument/implementation: the concrete types of
+// an interface, or the interfaces of a concrete type, and likewise for methods.
+func (w *workspace) implementation(params textDocumentPositionParams) ([]Location, error) {
+	obj, _, err := w.objectAt(params)
+	if err != nil || obj == nil {
+		return []Location{}, err
+	}
+	var found []types.Object
+	candidates := namedTypes(w.loadAll())
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		iface, isInterface := obj.Type().Underlying().(*types.Interface)
+		for _, candidate := range candidates {
+			other, otherInterface := candidate.Type().Underlying().(*types.Interface)
+			switch {
+			case sameObject(candidate, obj):
+			case isInterface && !otherInterface && iface.NumMethods() > 0 && implements(candidate.Type(), iface):
+				found = append(found, candidate)
+			case !isInterface && otherInterface && other.NumMethods() > 0 && implements(obj.Type(), other):
+				found = append(found, candidate)
+			}
+		}
+	case *types.Func:
+		recv := obj.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return []Location{}, nil
+		}
+		recvType := recv.Type()
+		if pointer, ok := recvType.(*types.Pointer); ok {
+			recvType = pointer.Elem()
+		}
+		iface, isInterface := recvType.Underlying().(*types.Interface)
+		for _, candidate := range candidates {
+			other, otherInterface := candidate.Type().Underlying().(*types.Interface)
+			var method types.Object
+			switch {
+			case isInterface && !otherInterface && implements(candidate.Type(), iface):
+				method, _, _ = types.LookupFieldOrMethod(types.NewPointer(candidate.Type()), false, obj.Pkg(), obj.Name())
+			case !isInterface && otherInterface && other.NumMethods() > 0 && implements(recvType, other):
+				method, _, _ = types.LookupFieldOrMethod(candidate.Type(), false, obj.Pkg(), obj.Name())
+			}
+			if method != nil {
+				found = append(found, method)
+			}
+		}
+	}
+	var locations []Location
+	for _, obj := range found {
+		if location, ok := w.nameLocation(obj); ok {
+			locations = append(locations, location)
+		}
+	}
+	if locations == nil {
+		locations = []Location{}
+	}
+	return locations, nil
+}
+
+// docComment finds the doc comment of a workspace declaration.
+func (w *workspace) docComment(obj types.Object) string {
+	p, ok := w.packages[filepath.Dir(w.fset.Position(obj.Pos()).Filename)]
+	if !ok {
+		return ""
+	}
+	doc := ""
+	for _, file := range p.files {
+		if file.Pos() > obj.Pos() || file.End() < obj.Pos() {
+			continue
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			if node == nil || doc != "" || node.Pos() > obj.Pos() || node.End() < obj.Pos() {
+				return false
+			}
+			switch decl := node.(type) {
+			case *ast.FuncDecl:
+				if decl.Name.Pos() == obj.Pos() {
+					doc = decl.Doc.Text()
+				}
+			case *ast.GenDecl:
+				if len(decl.Specs) == 1 && decl.Doc != nil {
+					doc = decl.Doc.Text()
+				}
+			case *ast.TypeSpec:
+				if decl.Name.Pos() == obj.Pos() && decl.Doc != nil {
+					doc = decl.Doc.Text()
+				}
+			case *ast.ValueSpec:
+				if decl.Doc != nil {
+					doc = decl.Doc.Text()
+				}
+			case *ast.Field:
+				if decl.Doc != nil {
+					doc = decl.Doc.Text()
+				}
+			}
+			return true
+		})
+	}
+	return doc
+}
+
+// hover answers textDocument/hover with the declaration and its doc comment.
+func (w *workspace) hover(params textDocumentPositionParams) (*hover, error) {
+	obj, p, err := w.objectAt(params)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	qualifier := types.RelativeTo(p.types)
+	text := types.ObjectString(obj, qualifier)
+	if tn, ok := obj.(*types.TypeName); ok {
+		text = fmt.Sprintf("type %s %s", tn.Name(), types.TypeString(tn.Type().Underlying(), qualifier))
+	}
+	value := "```go\n" + text + "\n```"
+	if doc := w.docComment(obj); doc != "" {
+		value += "\n\n" + strings.TrimSpace(doc)
+	}
+	return &hover{Contents: markupContent{Kind: "markdown", Value: value}}, nil
+}
+
+// fileSymbols lists the declarations of a file as a symbol tree: methods
+// are named like "(*T).Name", as gopls names them.
+func (w *workspace) fileSymbols(fset *token.FileSet, file *ast.File) []DocumentSymbol {
+	span := func(node ast.Node) Range {
+		return Range{Start: w.position(fset.Position(node.Pos())), End: w.position(fset.Position(node.End()))}
+	}
+	fields := func(list *ast.FieldList, kind int) []DocumentSymbol {
+		var children []DocumentSymbol
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				children = append(children, DocumentSymbol{
+					Name: name.Name, Detail: types.ExprString(field.Type), Kind: kind, Range: span(field), SelectionRange: span(name),
+				})
+			}
+		}
+		return children
+	}
+	var symbols []DocumentSymbol
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			symbol := DocumentSymbol{Name: decl.Name.Name, Detail: types.ExprString(decl.Type), Kind: kindFunction, Range: span(decl), SelectionRange: span(decl.Name)}
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				recv := types.ExprString(decl.Recv.List[0].Type)
+				if index := strings.IndexByte(recv, '['); index != -1 {
+					recv = recv[:index]
+				}
+				if strings.HasPrefix(recv, "*") {
+					recv = "(" + recv + ")"
+				}
+				symbol.Name = recv + "." + decl.Name.Name
+				symbol.Kind = kindMethod
+			}
+			symbols = append(symbols, symbol)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					symbol := DocumentSymbol{Name: spec.Name.Name, Kind: kindClass, Range: span(spec), SelectionRange: span(spec.Name)}
+					if len(decl.Specs) == 1 {
+						symbol.Range = span(decl)
+					}
+					switch t := spec.Type.(type) {
+					case *ast.StructType:
+						symbol.Kind, symbol.Detail, symbol.Children = kindStruct, "struct{...}", fields(t.Fields, kindField)
+					case *ast.InterfaceType:
+						symbol.Kind, symbol.Detail, symbol.Children = kindInterface, "interface{...}", fields(t.Methods, kindMethod)
+					case *ast.FuncType:
+						symbol.Kind, symbol.Detail = kindFunction, types.ExprString(t)
+					default:
+						symbol.Detail = types.ExprString(t)
+					}
+					symbols = append(symbols, symbol)
+				case *ast.ValueSpec:
+					kind := kindVariable
+					if decl.Tok == token.CONST {
+						kind = kindConstant
+					}
+					detail := ""
+					if spec.Type != nil {
+						detail = types.ExprString(spec.Type)
+					}
+					for _, name := range spec.Names {
+						if name.Name != "_" {
+							symbols = append(symbols, DocumentSymbol{Name: name.Name, Detail: detail, Kind: kind, Range: span(spec), SelectionRange: span(name)})
+						}
+					}
+				}
+			}
+		}
+	}
+	if symbols == nil {
+		symbols = []DocumentSymbol{}
+	}
+	return symbols
+}
+
+// documentSymbols answers textDocument/documentSymbol from the file's syntax alone.
+func (w *workspace) documentSymbols(params struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}) ([]DocumentSymbol, error) {
+	path, err := uriToPath(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	content, err := w.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, path, content, parser.SkipObjectResolution)
+	if file == nil {
+		return []DocumentSymbol{}, nil
+	}
+	return w.fileSymbols(fset, file), nil
+}
+
+// workspaceSymbols answers workspace/symbol: declarations whose name
+// contains the query, ignoring case. Methods and fields are named
+// "Type.Name"; exact and "Type.query" matches come first.
+func (w *workspace) workspaceSymbols(params struct {
+	Query string `json:"query"`
+}) ([]SymbolInformation, error) {
+	var results []SymbolInformation
+	filepath.WalkDir(w.root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != w.root && skipDir(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		content, err := w.readFile(path)
+		if err != nil {
+			return nil
+		}
+		fset := token.NewFileSet()
+		file, _ := parser.ParseFile(fset, path, content, parser.SkipObjectResolution)
+		if file == nil {
+			return nil
+		}
+		uri := pathToURI(path)
+		container := w.importPath(filepath.Dir(path))
+		var visit func(symbols []DocumentSymbol, parent string)
+		visit = func(symbols []DocumentSymbol, parent string) {
+			for _, symbol := range symbols {
+				name := strings.TrimPrefix(strings.NewReplacer("(*", "", ")", "").Replace(symbol.Name), "*")
+				if parent != "" {
+					name = parent + "." + name
+				}
+				if containsFold(name, params.Query) {
+					// From the name, so the start is a position of the symbol, to the end of the declaration
+					span := Range{Start: symbol.SelectionRange.Start, End: symbol.Range.End}
+					results = append(results, SymbolInformation{Name: name, Kind: symbol.Kind, Location: Location{URI: uri, Range: span}, ContainerName: container})
+				}
+				visit(symbol.Children, name)
+			}
+		}
+		visit(w.fileSymbols(fset, file), "")
+		return nil
+	})
+	rank := func(name string) int {
+		switch {
+		case strings.EqualFold(name, params.Query):
+			return 0
+		case strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(params.Query)):
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(results, func(i, j int) bool { return rank(results[i].Name) < rank(results[j].Name) })
+	if len(results) > maxWorkspaceSymbols {
+		results = results[:maxWorkspaceSymbols]
+	}
+	if results == nil {
+		results = []SymbolInformation{}
+	}
+	return results, nil
+}
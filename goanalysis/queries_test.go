@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testWorkspace writes a module with a store interface, an implementation
+// in another package, and a caller.
+func testWorkspace(t *testing.T) (*workspace, string) {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shop\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+// Store keeps items.
+type Store interface {
+	Get(key string) (string, error)
+}
+`,
+		"mem/mem.go": `package mem
+
+// Memory is an in-memory store.
+type Memory struct{ items map[string]string }
+
+func (m *Memory) Get(key string) (string, error) { return m.items[key], nil }
+`,
+		"main.go": `package main
+
+import (
+	"example.com/shop/mem"
+	"example.com/shop/store"
+)
+
+func main() {
+	var s store.Store = &mem.Memory{}
+	s.Get("a")
+	s.Get("b")
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newWorkspace(root), root
+}
+
+func at(root, file string, line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: pathToURI(filepath.Join(root, filepath.FromSlash(file)))},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func describe(locations []Location) string {
+	var parts []string
+	for _, location := range locations {
+		path, _ := uriToPath(location.URI)
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", filepath.Base(path), location.Range.Start.Line, location.Range.Start.Character))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestDefinitionAcrossPackages(t *testing.T) {
+	w, root := testWorkspace(t)
+	// s.Get in main.go resolves to the interface method
+	locations, err := w.definition(at(root, "main.go", 9, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := describe(locations); got != "store.go:4:1" {
+		t.Errorf("definition = %s", got)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	w, root := testWorkspace(t)
+	params := referenceParams{textDocumentPositionParams: at(root, "store/store.go", 4, 1)}
+	locations, err := w.references(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := describe(locations); got != "main.go:9:3 main.go:10:3" {
+		t.Errorf("references = %s", got)
+	}
+	params.Context.IncludeDeclaration = true
+	locations, _ = w.references(params)
+	if len(locations) != 3 {
+		t.Errorf("references with declaration = %s", describe(locations))
+	}
+}
+
+func TestImplementation(t *testing.T) {
+	w, root := testWorkspace(t)
+	locations, err := w.implementation(at(root, "store/store.go", 3, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := describe(locations); got != "mem.go:3:5" {
+		t.Errorf("implementations of Store = %s", got)
+	}
+	locations, _ = w.implementation(at(root, "mem/mem.go", 5, 17))
+	if got := describe(locations); got != "store.go:4:1" {
+		t.Errorf("interfaces of Memory.Get = %s", got)
+	}
+}
+
+func TestHoverAndUTF16Positions(t *testing.T) {
+	w, root := testWorkspace(t)
+	result, err := w.hover(at(root, "main.go", 8, 30))
+	if err != nil || result == nil {
+		t.Fatalf("hover: %v", err)
+	}
+	if !strings.Contains(result.Contents.Value, "type Memory struct") || !strings.Contains(result.Contents.Value, "in-memory store") {
+		t.Errorf("hover = %q", result.Contents.Value)
+	}
+
+	// "é" is one UTF-16 unit but two bytes
+	content := []byte("// é x\n")
+	if got := w.offset(content, Position{Line: 0, Character: 5}); got != 6 {
+		t.Errorf("utf-16 offset = %d", got)
+	}
+	w.utf8 = true
+	if got := w.offset(content, Position{Line: 0, Character: 5}); got != 5 {
+		t.Errorf("utf-8 offset = %d", got)
+	}
+}
+
+func TestImportsFromExportData(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+	w, root := testWorkspace(t)
+	content := "package main\n\nimport \"net/http\"\n\nvar client = http.DefaultClient\n"
+	if err := os.WriteFile(filepath.Join(root, "client.go"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := w.hover(at(root, "client.go", 4, 5))
+	if err != nil || result == nil {
+		t.Fatalf("hover: %v", err)
+	}
+	if !strings.Contains(result.Contents.Value, "*net/http.Client") {
+		t.Errorf("hover = %q", result.Contents.Value)
+	}
+	if w.external.(*exportImporter).exports["net/http"] == "" {
+		t.Error("net/http was not imported from export data")
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	w, root := testWorkspace(t)
+	symbols, err := w.workspaceSymbols(struct {
+		Query string `json:"query"`
+	}{Query: "get"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name+"@"+symbol.ContainerName)
+	}
+	if got := strings.Join(names, " "); got != "Memory.Get@example.com/shop/mem Store.Get@example.com/shop/store" {
+		t.Errorf("workspace symbols = %s", got)
+	}
+
+	document, err := w.documentSymbols(struct {
+		TextDocument textDocumentIdentifier `json:"textDocument"`
+	}{TextDocument: textDocumentIdentifier{URI: pathToURI(filepath.Join(root, "mem", "mem.go"))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(document) != 2 || document[0].Kind != kindStruct || document[0].Children[0].Name != "items" || document[1].Name != "(*Memory).Get" {
+		t.Errorf("document symbols = %+v", document)
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	w, root := testWorkspace(t)
+	describeValues := func(values []typedValue) string {
+		var parts []string
+		for _, v := range values {
+			parts = append(parts, fmt.Sprintf("%s:%d %s %s in %q", filepath.ToSlash(v.File), v.Line, v.Kind, v.Name, v.Func))
+		}
+		return strings.Join(parts, "; ")
+	}
+	if got := describeValues(w.typedValues("store.Store", root, false)); got != `main.go:9 var s in "main"` {
+		t.Errorf("store.Store values = %s", got)
+	}
+	if got := describeValues(w.typedValues("*example.com/shop/mem.Memory", root, true)); got != `main.go:9 expr &mem.Memory{} in "main"; mem/mem.go:6 receiver m in "Memory.Get"` {
+		t.Errorf("*mem.Memory values = %s", got)
+	}
+	if got := describeValues(w.typedValues("string", filepath.Join(root, "store"), false)); got != `store/store.go:5 param key in ""` {
+		t.Errorf("string values under store = %s", got)
+	}
+}
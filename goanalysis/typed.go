@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxExpressionLength caps the expression text of a typed value.
+const maxExpressionLength = 80
+
+// typedValue is a declaration or expression of a searched type.
+type typedValue struct {
+	File   string `json:"file"` // Relative to the workspace root
+	Line   int    `json:"line"`
+	Column int    `json:"column"` // 1-indexed, in bytes
+	Kind   string `json:"kind"`   // receiver, param, result, var, field, or expr
+	Name   string `json:"name"`   // The declared name, or the expression
+	Func   string `json:"func,omitempty"`
+	Type   string `json:"type"`
+}
+
+// typeMatches compares a type with a written type: qualified by package
+// name ("*sql.DB") or by import path ("*database/sql.DB"). A type written
+// without any qualifier ("*Server") matches a named type of any package.
+func typeMatches(t types.Type, want string) bool {
+	if !strings.Contains(want, ".") {
+		return types.TypeString(t, func(*types.Package) string { return "" }) == want
+	}
+	byName := types.TypeString(t, func(p *types.Package) string { return p.Name() })
+	return byName == want || types.TypeString(t, nil) == want
+}
+
+// enclosingFuncs maps each function declaration of a file to its name,
+// "Recv.Name" for methods.
+func enclosingFuncs(file *ast.File) func(token.Pos) string {
+	type span struct {
+		pos, end token.Pos
+		name     string
+	}
+	var spans []span
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			name := fn.Name.Name
+			if fn.Recv != nil && len(fn.Recv.List) > 0 {
+				recv := fn.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				if index, ok := recv.(*ast.IndexExpr); ok {
+					recv = index.X
+				}
+				name = types.ExprString(recv) + "." + name
+			}
+			spans = append(spans, span{fn.Pos(), fn.End(), name})
+		}
+	}
+	return func(pos token.Pos) string {
+		for _, s := range spans {
+			if s.pos <= pos && pos < s.end {
+				return s.name
+			}
+		}
+		return ""
+	}
+}
+
+// typedValues finds the variables, receivers, parameters, named results,
+// and fields of a type in the packages under a directory, and with
+// expressions every other value expression of the type (calls, selectors,
+// literals) too. Plain identifiers that use a variable are not listed; its
+// declaration is.
+func (w *workspace) typedValues(want, dir string, expressions bool) []typedValue {
+	var values []typedValue
+	for _, p := range w.loadUnder(dir) {
+		for _, file := range p.files {
+			kinds := map[*ast.Ident]string{}
+			ast.Inspect(file, func(node ast.Node) bool {
+				if fn, ok := node.(*ast.FuncDecl); ok && fn.Recv != nil {
+					for _, field := range fn.Recv.List {
+						for _, name := range field.Names {
+							kinds[name] = "receiver"
+						}
+					}
+				}
+				if fn, ok := node.(*ast.FuncType); ok {
+					for kind, list := range map[string]*ast.FieldList{"param": fn.Params, "result": fn.Results} {
+						if list == nil {
+							continue
+						}
+						for _, field := range list.List {
+							for _, name := range field.Names {
+								kinds[name] = kind
+							}
+						}
+					}
+				}
+				return true
+			})
+			funcOf := enclosingFuncs(file)
+			add := func(node ast.Node, kind, name string, t types.Type) {
+				position := w.fset.Position(node.Pos())
+				rel, _ := filepath.Rel(w.root, position.Filename)
+				values = append(values, typedValue{
+					File: rel, Line: position.Line, Column: position.Column,
+					Kind: kind, Name: name, Func: funcOf(node.Pos()), Type: types.TypeString(t, types.RelativeTo(p.types)),
+				})
+			}
+			for ident, obj := range p.info.Defs {
+				v, ok := obj.(*types.Var)
+				if !ok || w.fset.File(ident.Pos()) != w.fset.File(file.Pos()) || !typeMatches(v.Type(), want) {
+					continue
+				}
+				kind := kinds[ident]
+				if kind == "" {
+					kind = "var"
+					if v.IsField() {
+						kind = "field"
+					}
+				}
+				add(ident, kind, ident.Name, v.Type())
+			}
+			if !expressions {
+				continue
+			}
+			for expr, tv := range p.info.Types {
+				if !tv.IsValue() || w.fset.File(expr.Pos()) != w.fset.File(file.Pos()) || !typeMatches(tv.Type, want) {
+					continue
+				}
+				if _, ok := expr.(*ast.Ident); ok {
+					continue
+				}
+				if _, ok := expr.(*ast.ParenExpr); ok {
+					continue
+				}
+				text := types.ExprString(expr)
+				if len(text) > maxExpressionLength {
+					text = text[:maxExpressionLength] + "…"
+				}
+				add(expr, "expr", text, tv.Type)
+			}
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return values
+}
+
+// runTyped is the typed subcommand: it prints the typed values under a
+// directory as JSON, for the find_typed tool.
+func runTyped(args []string) int {
+	flags := flag.NewFlagSet("typed", flag.ContinueOnError)
+	root := flags.String("root", ".", "workspace root")
+	dir := flags.String("path", "", "directory to search (default: the root)")
+	expressions := flags.Bool("expressions", false, "also list value expressions other than identifiers")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: goanalysis typed [-root DIR] [-path DIR] [-expressions] TYPE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	absRoot, err := filepath.Abs(*root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	searchDir := absRoot
+	if *dir != "" {
+		if searchDir, err = filepath.Abs(*dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	w := newWorkspace(absRoot)
+	values := w.typedValues(flags.Arg(0), searchDir, *expressions)
+	if values == nil {
+		values = []typedValue{}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(map[string]any{"packages": len(w.loaded(searchDir)), "values": values}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// pkg is a type-checked workspace package. Files of the package's
+// internal tests are checked with it; external test packages (x_test) are not.
+type pkg struct {
+	dir     string
+	path    string
+	files   []*ast.File
+	types   *types.Package
+	info    *types.Info
+	loading bool
+}
+
+// workspace loads and type-checks the Go packages under a root directory
+// on demand. Packages outside the workspace (the standard library and
+// dependencies) come from export data, or from source (see exportImporter).
+type workspace struct {
+	root     string
+	utf8     bool // Positions count bytes rather than UTF-16 code units
+	fset     *token.FileSet
+	overlay  map[string][]byte
+	packages map[string]*pkg   // by directory
+	modules  map[string]string // go.mod directory to module path
+	external types.ImporterFrom
+	scanned  bool
+}
+
+func newWorkspace(root string) *workspace {
+	w := &workspace{root: root, overlay: map[string][]byte{}}
+	w.reset()
+	return w
+}
+
+// reset drops every loaded package, so the next query sees current files.
+// Packages outside the workspace are kept, as they do not change.
+func (w *workspace) reset() {
+	if w.fset == nil {
+		w.fset = token.NewFileSet()
+		w.external = newExportImporter(w.fset)
+	}
+	w.packages = map[string]*pkg{}
+	w.modules = nil
+	w.scanned = false
+}
+
+// setOverlay replaces a file's disk content with an editor buffer; nil drops it.
+// Opening a file whose buffer matches its current content keeps the packages.
+func (w *workspace) setOverlay(path string, content []byte) {
+	before, err := w.readFile(path)
+	if content == nil {
+		delete(w.overlay, path)
+	} else {
+		w.overlay[path] = content
+	}
+	after, afterErr := w.readFile(path)
+	if err != nil || afterErr != nil || !bytes.Equal(before, after) {
+		w.reset()
+	}
+}
+
+// readFile returns a file's overlay content, or its disk content.
+func (w *workspace) readFile(path string) ([]byte, error) {
+	if content, ok := w.overlay[path]; ok {
+		return content, nil
+	}
+	return os.ReadFile(path)
+}
+
+// skipDir reports whether a directory holds no workspace packages.
+func skipDir(name string) bool {
+	return name != "." && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+		name == "vendor" || name == "testdata" || name == "node_modules")
+}
+
+// findModules records the module path of every go.mod under the root.
+func (w *workspace) findModules() map[string]string {
+	if w.modules != nil {
+		return w.modules
+	}
+	w.modules = map[string]string{}
+	filepath.WalkDir(w.root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != w.root && skipDir(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() == "go.mod" {
+			if content, err := os.ReadFile(path); err == nil {
+				if module := modulePath(content); module != "" {
+					w.modules[filepath.Dir(path)] = module
+				}
+			}
+		}
+		return nil
+	})
+	return w.modules
+}
+
+// modulePath reads the module directive of a go.mod file.
+func modulePath(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// importPath is the import path of a workspace directory: its module path
+// and its path inside the module. Directories outside any module use their
+// path under the root.
+func (w *workspace) importPath(dir string) string {
+	best, module := "", ""
+	for modDir, path := range w.findModules() {
+		if (dir == modDir || strings.HasPrefix(dir, modDir+string(filepath.Separator))) && len(modDir) > len(best) {
+			best, module = modDir, path
+		}
+	}
+	if best == "" {
+		rel, _ := filepath.Rel(w.root, dir)
+		return filepath.ToSlash(rel)
+	}
+	rel, _ := filepath.Rel(best, dir)
+	if rel == "." {
+		return module
+	}
+	return module + "/" + filepath.ToSlash(rel)
+}
+
+// dirOf is the workspace directory of an import path, if it is one.
+func (w *workspace) dirOf(importPath string) (string, bool) {
+	for modDir, module := range w.findModules() {
+		if importPath == module {
+			return modDir, true
+		}
+		if strings.HasPrefix(importPath, module+"/") {
+			dir := filepath.Join(modDir, filepath.FromSlash(strings.TrimPrefix(importPath, module+"/")))
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				return dir, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Import resolves imports of workspace packages to the workspace and others
+// to export data.
+func (w *workspace) Import(path string) (*types.Package, error) {
+	return w.ImportFrom(path, w.root, 0)
+}
+
+func (w *workspace) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if pkgDir, ok := w.dirOf(path); ok {
+		p, err := w.load(pkgDir)
+		if err != nil {
+			return nil, err
+		}
+		return p.types, nil
+	}
+	return w.external.ImportFrom(path, dir, mode)
+}
+
+// goFiles lists the Go files of a directory that the default build context
+// includes, with overlaid files that are not on disk yet.
+func (w *workspace) goFiles(dir string) []string {
+	names := map[string]bool{}
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+				names[entry.Name()] = true
+			}
+		}
+	}
+	for path := range w.overlay {
+		if filepath.Dir(path) == dir && strings.HasSuffix(path, ".go") {
+			names[filepath.Base(path)] = true
+		}
+	}
+	ctx := build.Default
+	ctx.OpenFile = func(path string) (io.ReadCloser, error) {
+		content, err := w.readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+	var files []string
+	for name := range names {
+		if ok, err := ctx.MatchFile(dir, name); err == nil && ok {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// load parses and type-checks the package in a directory. Type errors are
+// ignored: the information of what does check is still recorded.
+func (w *workspace) load(dir string) (*pkg, error) {
+	if p, ok := w.packages[dir]; ok {
+		if p.loading {
+			return nil, fmt.Errorf("import cycle through %s", p.path)
+		}
+		if p.types == nil {
+			return nil, fmt.Errorf("no Go files in %s", dir)
+		}
+		return p, nil
+	}
+	p := &pkg{dir: dir, path: w.importPath(dir), loading: true}
+	w.packages[dir] = p
+	defer func() { p.loading = false }()
+
+	var files []*ast.File
+	for _, path := range w.goFiles(dir) {
+		content, err := w.readFile(path)
+		if err != nil {
+			continue
+		}
+		file, _ := parser.ParseFile(w.fset, path, content, parser.ParseComments)
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+	// The package is named by its non-test files; x_test files are left out
+	name := ""
+	for _, file := range files {
+		if !strings.HasSuffix(w.fset.File(file.Pos()).Name(), "_test.go") {
+			name = file.Name.Name
+			break
+		}
+	}
+	for _, file := range files {
+		if name == "" {
+			name = file.Name.Name
+		}
+		if file.Name.Name == name {
+			p.files = append(p.files, file)
+		}
+	}
+	if len(p.files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	p.info = &types.Info{
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Types:      map[ast.Expr]types.TypeAndValue{},
+	}
+	conf := types.Config{Importer: w, Error: func(error) {}}
+	p.types, _ = conf.Check(p.path, w.fset, p.files, p.info)
+	return p, nil
+}
+
+// loadAll loads every package of the workspace.
+func (w *workspace) loadAll() []*pkg {
+	if !w.scanned {
+		w.scanned = true
+		return w.loadUnder(w.root)
+	}
+	return w.loaded(w.root)
+}
+
+// loadUnder loads the packages in a directory and its subdirectories.
+func (w *workspace) loadUnder(dir string) []*pkg {
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			if path != dir && skipDir(entry.Name()) {
+				return filepath.SkipDir
+			}
+			if len(w.goFiles(path)) > 0 {
+				w.load(path)
+			}
+		}
+		return nil
+	})
+	return w.loaded(dir)
+}
+
+// loaded lists the checked packages in a directory and its subdirectories.
+func (w *workspace) loaded(dir string) []*pkg {
+	var all []*pkg
+	for _, p := range w.packages {
+		if p.types != nil && !p.loading && (p.dir == dir || strings.HasPrefix(p.dir, dir+string(filepath.Separator))) {
+			all = append(all, p)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].dir < all[j].dir })
+	return all
+}
+
+// fileOf returns the loaded package and syntax tree of a file.
+func (w *workspace) fileOf(path string) (*pkg, *ast.File, error) {
+	p, err := w.load(filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range p.files {
+		if w.fset.File(file.Pos()).Name() == path {
+			return p, file, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s is not part of the %s package build", filepath.Base(path), p.path)
+}
+
+// offset converts an LSP position in a file to a byte offset.
+func (w *workspace) offset(content []byte, position Position) int {
+	offset := 0
+	for line := 0; line < position.Line; line++ {
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next == -1 {
+			return len(content)
+		}
+		offset += next + 1
+	}
+	if w.utf8 {
+		return min(offset+position.Character, len(content))
+	}
+	for units := 0; units < position.Character && offset < len(content) && content[offset] != '\n'; {
+		r, size := utf8.DecodeRune(content[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+// position converts a token position to an LSP position.
+func (w *workspace) position(pos token.Position) Position {
+	character := pos.Column - 1
+	if !w.utf8 {
+		if content, err := w.readFile(pos.Filename); err == nil && pos.Offset <= len(content) {
+			start := bytes.LastIndexByte(content[:pos.Offset], '\n') + 1
+			character = len(utf16.Encode([]rune(string(content[start:pos.Offset]))))
+		}
+	}
+	return Position{Line: pos.Line - 1, Character: character}
+}
+
+// location converts a span of the file set to an LSP location.
+func (w *workspace) location(start, end token.Pos) Location {
+	from, to := w.fset.Position(start), w.fset.Position(end)
+	return Location{URI: pathToURI(from.Filename), Range: Range{Start: w.position(from), End: w.position(to)}}
+}
@@ -6,6 +6,7 @@ module.exports = {
   collectCoverageFrom: [
     'src/**/*.ts',
     '!src/**/*.test.ts',
+    '!src/**/*.testutil.ts',
     '!src/**/__tests__/**'
   ],
   coverageDirectory: 'coverage',
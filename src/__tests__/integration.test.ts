@@ -125,6 +125,11 @@ export class TestClass {
 
       expect(diagnostics.length).toBeGreaterThan(0);
     }, 10000);
+
+    it('should answer health check pings', async () => {
+      expect(client.isRunning()).toBe(true);
+      expect(await client.ping(5000)).toBe(true);
+    }, 10000);
   });
 });
 
@@ -4,7 +4,14 @@
  */
 
 import { createLogger, Component } from '../logging/logger.js';
-import { WorkspaceSymbol, Location, Hover, Diagnostic } from 'vscode-languageserver-protocol';
+import {
+  WorkspaceSymbol,
+  Location,
+  Hover,
+  Diagnostic,
+  DocumentSymbol,
+  SymbolInformation,
+} from 'vscode-languageserver-protocol';
 
 const cacheLogger = createLogger(Component.TOOLS);
 
@@ -42,6 +49,7 @@ export class LSPCacheManager {
   private referencesCache = new Map<string, LocationCache>();
   private hoverCache = new Map<string, HoverCache>();
   private diagnosticsCache = new Map<string, CacheEntry<Diagnostic[]>>();
+  private documentSymbolsCache = new Map<string, CacheEntry<(DocumentSymbol | SymbolInformation)[]>>();
 
   constructor(config?: Partial<CacheConfig>) {
     this.config = {
@@ -198,6 +206,13 @@ export class LSPCacheManager {
    * Get references from cache
    */
   getReferences(filePath: string, line: number, character: number): Location[] | null {
+    return this.getReferencesEntry(filePath, line, character)?.data ?? null;
+  }
+
+  /**
+   * Get references from cache with the time they were cached
+   */
+  getReferencesEntry(filePath: string, line: number, character: number): { data: Location[]; timestamp: number } | null {
     if (!this.config.enabled) {
       return null;
     }
@@ -215,7 +230,7 @@ export class LSPCacheManager {
     }
 
     cacheLogger.debug('Cache hit: references at %s:%s (%d locations)', filePath, posKey, entry.data.length);
-    return entry.data;
+    return entry;
   }
 
   /**
@@ -322,6 +337,40 @@ export class LSPCacheManager {
     cacheLogger.debug('Cached diagnostics for %s (%d diagnostics)', filePath, diagnostics.length);
   }
 
+  /**
+   * Get document symbols from cache
+   */
+  getDocumentSymbols(filePath: string): (DocumentSymbol | SymbolInformation)[] | null {
+    if (!this.config.enabled) {
+      return null;
+    }
+
+    const entry = this.documentSymbolsCache.get(filePath);
+
+    if (!entry || this.isExpired(entry)) {
+      return null;
+    }
+
+    cacheLogger.debug('Cache hit: document symbols for %s (%d symbols)', filePath, entry.data.length);
+    return entry.data;
+  }
+
+  /**
+   * Set document symbols in cache
+   */
+  setDocumentSymbols(filePath: string, symbols: (DocumentSymbol | SymbolInformation)[]): void {
+    if (!this.config.enabled) {
+      return;
+    }
+
+    this.documentSymbolsCache.set(filePath, {
+      data: symbols,
+      timestamp: Date.now(),
+    });
+
+    cacheLogger.debug('Cached document symbols for %s (%d symbols)', filePath, symbols.length);
+  }
+
   /**
    * Invalidate cache for a specific file
    * Called when a file changes
@@ -334,6 +383,7 @@ export class LSPCacheManager {
     this.referencesCache.delete(filePath);
     this.hoverCache.delete(filePath);
     this.diagnosticsCache.delete(filePath);
+    this.documentSymbolsCache.delete(filePath);
 
     // Note: workspace symbols might still be valid for other files,
     // so we don't clear them. They'll expire via TTL if configured.
@@ -358,6 +408,7 @@ export class LSPCacheManager {
     this.referencesCache.clear();
     this.hoverCache.clear();
     this.diagnosticsCache.clear();
+    this.documentSymbolsCache.clear();
   }
 
   /**
@@ -369,6 +420,7 @@ export class LSPCacheManager {
     references: number;
     hover: number;
     diagnostics: number;
+    documentSymbols: number;
   } {
     return {
       workspaceSymbols: this.workspaceSymbolsCache.size,
@@ -376,6 +428,7 @@ export class LSPCacheManager {
       references: this.referencesCache.size,
       hover: this.hoverCache.size,
       diagnostics: this.diagnosticsCache.size,
+      documentSymbols: this.documentSymbolsCache.size,
     };
   }
 
@@ -0,0 +1,96 @@
+/**
+ * Tests for the interactive tool shell
+ */
+
+import { PassThrough } from 'stream';
+import { evaluateLine, formatHelp, parseToolCall, ReplTools, runRepl, tokenize, ToolSchema } from './repl';
+
+const TOOLS: ToolSchema[] = [
+  {
+    name: 'search_code',
+    description: 'Search the workspace. Returns matches.',
+    inputSchema: {
+      properties: {
+        pattern: { type: 'string', description: 'Text to find' },
+        regex: { type: 'boolean', default: false },
+        glob: { type: 'array' },
+        maxResults: { type: 'number' },
+      },
+      required: ['pattern'],
+    },
+  },
+  {
+    name: 'hover',
+    description: 'Hover information.',
+    inputSchema: {
+      properties: { filePath: { type: 'string' }, line: { type: 'number' }, column: { type: 'number' } },
+      required: ['filePath', 'line', 'column'],
+    },
+  },
+];
+
+function fakeTools(calls: Array<{ name: string; args: Record<string, unknown> }>): ReplTools {
+  return {
+    listTools: () => TOOLS,
+    callTool: async (name, args) => {
+      calls.push({ name, args });
+      if (args.pattern === 'boom') {
+        throw new Error('pattern is required');
+      }
+      if (args.pattern === '(') {
+        return { content: [{ type: 'text', text: '{"error":"pattern-invalid","message":"Invalid regular expression: /(/"}' }], isError: true };
+      }
+      return { content: [{ type: 'text', text: `ran ${name}` }] };
+    },
+  };
+}
+
+describe('REPL', () => {
+  it('should split words with quotes', () => {
+    expect(tokenize(`a "b c" 'd "e"' "x\\"y"`)).toEqual(['a', 'b c', 'd "e"', 'x"y']);
+    expect(() => tokenize('"open')).toThrow('unterminated quote');
+  });
+
+  it('should map positional, key=value, and JSON arguments onto the schema', () => {
+    expect(parseToolCall('search "two words" regex=true maxResults=5 glob=src/**', TOOLS)).toEqual({
+      name: 'search_code',
+      args: { pattern: 'two words', regex: true, maxResults: 5, glob: ['src/**'] },
+    });
+    expect(parseToolCall('hover line=3 a.ts 7', TOOLS).args).toEqual({ line: 3, filePath: 'a.ts', column: 7 });
+    expect(parseToolCall('search_code {"pattern": "x", "glob": ["*.go"]}', TOOLS).args).toEqual({ pattern: 'x', glob: ['*.go'] });
+    expect(() => parseToolCall('hover a.ts 1 2 3', TOOLS)).toThrow('unexpected argument 3');
+    expect(() => parseToolCall('hover a.ts x 2', TOOLS)).toThrow('line must be a number');
+    expect(() => parseToolCall('blame a.ts', TOOLS)).toThrow('unknown tool blame');
+  });
+
+  it('should list tools and describe parameters', () => {
+    expect(formatHelp(TOOLS)).toContain('search_code  Search the workspace.');
+    const help = formatHelp(TOOLS, 'search');
+    expect(help).toContain('pattern (string, required): Text to find');
+    expect(help).toContain('regex (boolean) [default: false]');
+  });
+
+  it('should print results and errors', async () => {
+    const calls: Array<{ name: string; args: Record<string, unknown> }> = [];
+    const tools = fakeTools(calls);
+    expect(await evaluateLine(tools, 'search foo')).toMatch(/^ran search_code\n\n\(search_code \{"pattern":"foo"\}, \d+ ms\)$/);
+    expect(await evaluateLine(tools, 'search boom')).toBe('error: pattern is required');
+    expect(await evaluateLine(tools, 'search (')).toBe('error (pattern-invalid): Invalid regular expression: /(/');
+    expect(await evaluateLine(tools, '  ')).toBe('');
+  });
+
+  it('should run lines until exit', async () => {
+    const calls: Array<{ name: string; args: Record<string, unknown> }> = [];
+    const input = new PassThrough();
+    const output = new PassThrough();
+    let printed = '';
+    output.on('data', (chunk) => (printed += chunk));
+    const done = runRepl(fakeTools(calls), input, output);
+    input.write('search a\nhelp\nexit\nsearch b\n');
+    input.end();
+    await done;
+    expect(calls.map((call) => call.args.pattern)).toEqual(['a']);
+    expect(printed).toContain('ran search_code');
+    expect(printed).toContain('Tools:');
+  });
+});
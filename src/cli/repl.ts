@@ -0,0 +1,309 @@
+/**
+ * Interactive tool shell
+ * `grep-for-code repl` starts the language server like the MCP server does
+ * and reads tool calls from the terminal, so a query that returns nothing
+ * can be tried and refined without an MCP client. Each line names a tool
+ * followed by its arguments, as key=value pairs, as values for the required
+ * parameters in order, or as a JSON object:
+ *
+ *   search handleRequest glob=src/** wholeWord=true
+ *   definition NewServer
+ *   references {"symbolName": "Config"}
+ */
+
+import * as readline from 'readline';
+
+/**
+ * Parameter schema, as listed by the MCP server
+ */
+interface ParameterSchema {
+  type?: string;
+  description?: string;
+  default?: unknown;
+}
+
+/**
+ * Tool schema, as listed by the MCP server
+ */
+export interface ToolSchema {
+  name: string;
+  description?: string;
+  inputSchema: {
+    properties?: Record<string, ParameterSchema>;
+    required?: string[];
+  };
+}
+
+/**
+ * The tools a REPL session runs
+ */
+export interface ReplTools {
+  listTools(): ToolSchema[];
+  callTool(name: string, args: Record<string, unknown>): Promise<ToolCallResult>;
+}
+
+/**
+ * A tool result; a failed call returns {"error": code, "message"} as its text with isError set
+ */
+export interface ToolCallResult {
+  content: Array<{ type: string; text: string }>;
+  isError?: boolean;
+}
+
+/**
+ * The failure a result reports, or undefined when the call succeeded
+ */
+export function resultError(result: ToolCallResult): { code: string; message: string } | undefined {
+  if (!result.isError) {
+    return undefined;
+  }
+  const text = result.content.map((part) => part.text).join('\n');
+  try {
+    const parsed = JSON.parse(text) as { error?: unknown; message?: unknown };
+    if (typeof parsed.error === 'string' && typeof parsed.message === 'string') {
+      return { code: parsed.error, message: parsed.message };
+    }
+  } catch {
+    // Not the JSON form; the text is the message
+  }
+  return { code: 'internal', message: text };
+}
+
+/**
+ * A parsed tool invocation
+ */
+export interface ToolCall {
+  name: string;
+  args: Record<string, unknown>;
+}
+
+/**
+ * Short names for common tools
+ */
+const ALIASES: Record<string, string> = {
+  search: 'search_code',
+  def: 'definition',
+  refs: 'references',
+};
+
+const PROMPT = 'grep-for-code> ';
+
+/**
+ * Split a line into words, honoring single and double quotes
+ */
+export function tokenize(line: string): string[] {
+  const words: string[] = [];
+  let word = '';
+  let inWord = false;
+  let quote: string | null = null;
+  for (let i = 0; i < line.length; i++) {
+    const c = line[i];
+    if (quote) {
+      if (c === quote) {
+        quote = null;
+      } else if (c === '\\' && quote === '"' && i + 1 < line.length) {
+        word += line[++i];
+      } else {
+        word += c;
+      }
+    } else if (c === '"' || c === "'") {
+      quote = c;
+      inWord = true;
+    } else if (/\s/.test(c)) {
+      if (inWord) {
+        words.push(word);
+        word = '';
+        inWord = false;
+      }
+    } else {
+      word += c;
+      inWord = true;
+    }
+  }
+  if (quote) {
+    throw new Error('unterminated quote');
+  }
+  if (inWord) {
+    words.push(word);
+  }
+  return words;
+}
+
+/**
+ * Convert a typed word to the parameter's type
+ */
+function coerce(name: string, value: string, schema: ParameterSchema): unknown {
+  switch (schema.type) {
+    case 'number': {
+      const n = Number(value);
+      if (value === '' || Number.isNaN(n)) {
+        throw new Error(`${name} must be a number`);
+      }
+      return n;
+    }
+    case 'boolean':
+      if (value !== 'true' && value !== 'false') {
+        throw new Error(`${name} must be true or false`);
+      }
+      return value === 'true';
+    case 'array':
+      // A single value stands for a one-element list
+      return value.startsWith('[') ? parseJson(name, value) : [value];
+    case 'object':
+      return parseJson(name, value);
+    default:
+      return value;
+  }
+}
+
+function parseJson(name: string, value: string): unknown {
+  try {
+    return JSON.parse(value);
+  } catch {
+    throw new Error(`${name} must be JSON`);
+  }
+}
+
+/**
+ * Parse a line into a tool call
+ */
+export function parseToolCall(line: string, tools: ToolSchema[]): ToolCall {
+  const trimmed = line.trim();
+  const typed = trimmed.split(/\s/, 1)[0];
+  const name = ALIASES[typed] ?? typed;
+  const tool = tools.find((candidate) => candidate.name === name);
+  if (!tool) {
+    throw new Error(`unknown tool ${typed} (type help to list tools)`);
+  }
+
+  const rest = trimmed.substring(typed.length).trim();
+  if (rest.startsWith('{')) {
+    return { name, args: parseJson('arguments', rest) as Record<string, unknown> };
+  }
+
+  const properties = tool.inputSchema.properties ?? {};
+  const args: Record<string, unknown> = {};
+  const positional: string[] = [];
+  for (const word of tokenize(rest)) {
+    const eq = word.indexOf('=');
+    const key = eq > 0 ? word.substring(0, eq) : '';
+    if (key && properties[key]) {
+      args[key] = coerce(key, word.substring(eq + 1), properties[key]);
+    } else {
+      positional.push(word);
+    }
+  }
+  // Positional values fill the required parameters that are still unset
+  const open = (tool.inputSchema.required ?? []).filter((param) => !(param in args));
+  if (positional.length > open.length) {
+    throw new Error(`unexpected argument ${positional[open.length]} (parameters: ${Object.keys(properties).join(', ')})`);
+  }
+  positional.forEach((value, i) => {
+    args[open[i]] = coerce(open[i], value, properties[open[i]] ?? {});
+  });
+  return { name, args };
+}
+
+/**
+ * First sentence of a description
+ */
+function summary(description = ''): string {
+  const end = description.search(/\.(\s|$)/);
+  return end < 0 ? description : description.substring(0, end + 1);
+}
+
+/**
+ * Help text: the tool list, or one tool's parameters
+ */
+export function formatHelp(tools: ToolSchema[], toolName?: string): string {
+  if (!toolName) {
+    const width = Math.max(...tools.map((tool) => tool.name.length));
+    const aliases = Object.entries(ALIASES).map(([alias, name]) => `${alias} = ${name}`).join(', ');
+    return [
+      'Tools:',
+      ...tools.map((tool) => `  ${tool.name.padEnd(width)}  ${summary(tool.description)}`),
+      '',
+      `Aliases: ${aliases}`,
+      'Type help <tool> for its parameters, exit to quit.',
+    ].join('\n');
+  }
+
+  const name = ALIASES[toolName] ?? toolName;
+  const tool = tools.find((candidate) => candidate.name === name);
+  if (!tool) {
+    return `Unknown tool: ${toolName}`;
+  }
+  const required = new Set(tool.inputSchema.required ?? []);
+  const lines = [`${tool.name}: ${tool.description ?? ''}`, '', 'Parameters:'];
+  for (const [param, schema] of Object.entries(tool.inputSchema.properties ?? {})) {
+    let line = `  ${param} (${schema.type ?? 'any'}${required.has(param) ? ', required' : ''})`;
+    if (schema.default !== undefined) {
+      line += ` [default: ${JSON.stringify(schema.default)}]`;
+    }
+    lines.push(`${line}: ${schema.description ?? ''}`);
+  }
+  return lines.join('\n');
+}
+
+/**
+ * Evaluate one line and return the text to print
+ */
+export async function evaluateLine(tools: ReplTools, line: string): Promise<string> {
+  const trimmed = line.trim();
+  if (!trimmed) {
+    return '';
+  }
+  const schemas = tools.listTools();
+  const [command, arg] = trimmed.split(/\s+/);
+  if (command === 'help' || command === '?') {
+    return formatHelp(schemas, arg);
+  }
+
+  const start = Date.now();
+  try {
+    const call = parseToolCall(trimmed, schemas);
+    const result = await tools.callTool(call.name, call.args);
+    const failed = resultError(result);
+    if (failed) {
+      return `error (${failed.code}): ${failed.message}`;
+    }
+    const text = result.content.map((part) => part.text).join('\n').trimEnd();
+    return `${text || '(no output)'}\n\n(${call.name} ${JSON.stringify(call.args)}, ${Date.now() - start} ms)`;
+  } catch (err) {
+    return `error: ${err instanceof Error ? err.message : String(err)}`;
+  }
+}
+
+/**
+ * Read and run tool calls until exit or end of input
+ */
+export async function runRepl(
+  tools: ReplTools,
+  input: NodeJS.ReadableStream = process.stdin,
+  output: NodeJS.WritableStream = process.stdout
+): Promise<void> {
+  const names = [...Object.keys(ALIASES), ...tools.listTools().map((tool) => tool.name), 'help', 'exit'];
+  const rl = readline.createInterface({
+    input,
+    output,
+    prompt: PROMPT,
+    completer: (line: string) => {
+      const hits = names.filter((name) => name.startsWith(line));
+      return [hits.length > 0 ? hits : names, line];
+    },
+  });
+
+  output.write('Type help to list tools, exit to quit.\n');
+  rl.prompt();
+  for await (const line of rl) {
+    if (line.trim() === 'exit' || line.trim() === 'quit') {
+      break;
+    }
+    const text = await evaluateLine(tools, line);
+    if (text) {
+      output.write(text + '\n');
+    }
+    rl.prompt();
+  }
+  rl.close();
+}
@@ -0,0 +1,80 @@
+/**
+ * Tests for the command line search
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { parseSearchArgs, runSearchCommand } from './search';
+
+describe('Command line search', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'cli-search-')));
+    fs.mkdirSync(path.join(workspace, 'src'));
+    fs.mkdirSync(path.join(workspace, 'docs'));
+    fs.writeFileSync(path.join(workspace, 'src', 'a.ts'), 'const fooBar = 1;\nfoo();\n');
+    fs.writeFileSync(path.join(workspace, 'docs', 'notes.md'), 'foo in the docs\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should parse options, the pattern, and the path', () => {
+    expect(parseSearchArgs(['--regex', 'fo+', 'src', '--glob', '**/*.ts', '--json', '--workspace', '/w'])).toEqual({
+      pattern: 'fo+',
+      path: 'src',
+      json: true,
+      options: { regex: true, glob: ['**/*.ts'] },
+    });
+    expect(parseSearchArgs(['--', '--literal']).pattern).toBe('--literal');
+    expect(() => parseSearchArgs([])).toThrow('a search pattern is required');
+    expect(() => parseSearchArgs(['x', '--max-results', '0'])).toThrow('--max-results must be a number of at least 1');
+    expect(() => parseSearchArgs(['x', '--colour'])).toThrow('unknown option --colour');
+  });
+
+  it('should print grep-style lines and exit 0 on a match', async () => {
+    const output = await runSearchCommand(workspace, parseSearchArgs(['--word', 'foo', path.join(workspace, 'src')]));
+    expect(output.stdout).toBe(`${path.join('src', 'a.ts')}:2:1: foo();\n`);
+    expect(output.stderr).toContain('1 match(es) in 1 file(s)');
+    expect(output.exitCode).toBe(0);
+  });
+
+  it('should print JSON and exit 1 without a match', async () => {
+    const output = await runSearchCommand(workspace, parseSearchArgs(['--json', 'absent']));
+    expect(JSON.parse(output.stdout)).toMatchObject({ pattern: 'absent', filesScanned: 2, matches: [] });
+    expect(output.exitCode).toBe(1);
+  });
+
+  it('should search the sources of a Bazel target', async () => {
+    fs.writeFileSync(path.join(workspace, 'src', 'BUILD.bazel'), 'ts_project(name = "src", srcs = ["a.ts"])\n');
+    expect(parseSearchArgs(['--target', '//src', 'foo']).target).toBe('//src');
+    expect(parseSearchArgs(['--query', 'target://src foo']).target).toBe('//src');
+    const output = await runSearchCommand(workspace, parseSearchArgs(['--target', '//src', '--word', 'foo']));
+    expect(output.stdout).toBe(`${path.join('src', 'a.ts')}:2:1: foo();\n`);
+  });
+
+  it('should take the pattern and filters from a query', async () => {
+    expect(parseSearchArgs(['--query', 'lang:ts -path:docs "foo()"', '--case-sensitive'])).toEqual({
+      pattern: 'foo()',
+      path: undefined,
+      json: false,
+      options: { languages: ['typescript', 'typescriptreact'], excludeGlob: ['docs/**', '**/docs/**'], caseSensitive: true },
+    });
+    expect(() => parseSearchArgs(['--query', 'kind:func foo'])).toThrow('kind: need the server; use search_code');
+    const output = await runSearchCommand(workspace, parseSearchArgs(['--query', '-file:*.ts foo']));
+    expect(output.stdout).toBe(`${path.join('docs', 'notes.md')}:1:1: foo in the docs\n`);
+  });
+
+  it('should apply default globs unless the command gives its own', async () => {
+    const defaults = { glob: ['**/*.md'] };
+    const scoped = await runSearchCommand(workspace, parseSearchArgs(['--json', 'foo']), defaults);
+    expect(JSON.parse(scoped.stdout).matches.map((m: { filePath: string }) => m.filePath))
+      .toEqual([path.join('docs', 'notes.md')]);
+    const explicit = await runSearchCommand(workspace, parseSearchArgs(['--json', '--glob', '**/*.ts', 'foo']), defaults);
+    expect(new Set(JSON.parse(explicit.stdout).matches.map((m: { filePath: string }) => m.filePath)))
+      .toEqual(new Set([path.join('src', 'a.ts')]));
+  });
+});
@@ -0,0 +1,233 @@
+/**
+ * Command line search
+ * `grep-for-code search <pattern> [path]` runs the search_code engine
+ * without an MCP client and prints matches one per line, or as JSON.
+ * Exit codes follow grep: 0 when something matched, 1 when nothing did
+ */
+
+import * as path from 'path';
+import { searchLexical, LexicalSearchOptions, LexicalMatch, LexicalSearchResult, searchDefaultsFromEnv } from '../search/lexical.js';
+import { parseQuery } from '../search/query.js';
+import { bazelTargetFiles } from '../workspace/bazel.js';
+
+/**
+ * A parsed search command
+ */
+export interface SearchCommand {
+  pattern: string;
+  // Directory or file to search, relative to the current directory
+  path?: string;
+  // Bazel label or pattern whose source files are searched
+  target?: string;
+  json: boolean;
+  options: LexicalSearchOptions;
+}
+
+/**
+ * Output of a search command
+ */
+export interface SearchCommandOutput {
+  stdout: string;
+  stderr: string;
+  exitCode: number;
+}
+
+/**
+ * Flags handled by the main argument parser, with the values they take
+ */
+const GLOBAL_FLAGS = new Set(['--workspace', '--config']);
+
+export const SEARCH_USAGE = `Usage: grep-for-code search [options] <pattern> [path]
+       grep-for-code search [options] --query <query> [path]
+
+Options:
+  --query <query>       Pattern and filters in one string, as search_code's
+                        query: 'lang:go -path:vendor "AddUser"'
+  --regex               Treat the pattern as a regular expression
+  --case-sensitive      Match case exactly
+  --word                Only match whole identifiers/words
+  --glob <glob>         Only search files matching the glob (repeatable)
+  --target <label>      Only search the sources of a Bazel target or pattern
+  --max-results <n>     Stop after n matches (default: 100)
+  --timeout-ms <n>      Stop and print partial results after n milliseconds
+  --include-generated   Also search generated files
+  --binary              Also search binary files
+  --follow-symlinks     Follow symbolic links
+  --json                Print the result as JSON
+  --workspace <dir>     Workspace root (default: the current directory)
+  --config <path>       Configuration file
+`;
+
+/**
+ * Parse the arguments after "search"
+ */
+export function parseSearchArgs(args: string[]): SearchCommand {
+  const positional: string[] = [];
+  const options: LexicalSearchOptions = {};
+  const globs: string[] = [];
+  let json = false;
+  let query: string | undefined;
+  let target: string | undefined;
+
+  const value = (i: number): string => {
+    if (i + 1 >= args.length) {
+      throw new Error(`${args[i]} requires a value`);
+    }
+    return args[i + 1];
+  };
+  const count = (i: number, min: number): number => {
+    const n = parseInt(value(i), 10);
+    if (!(n >= min)) {
+      throw new Error(`${args[i]} must be a number of at least ${min}`);
+    }
+    return n;
+  };
+
+  let i = 0;
+  while (i < args.length) {
+    const arg = args[i];
+    if (arg === '--') {
+      positional.push(...args.slice(i + 1));
+      break;
+    } else if (GLOBAL_FLAGS.has(arg)) {
+      value(i);
+      i += 2;
+    } else if (arg === '--query') {
+      query = value(i);
+      i += 2;
+    } else if (arg === '--target') {
+      target = value(i);
+      i += 2;
+    } else if (arg === '--glob') {
+      globs.push(value(i));
+      i += 2;
+    } else if (arg === '--max-results') {
+      options.maxResults = count(i, 1);
+      i += 2;
+    } else if (arg === '--timeout-ms') {
+      options.timeoutMs = count(i, 0);
+      i += 2;
+    } else if (arg === '--regex') {
+      options.regex = true;
+      i++;
+    } else if (arg === '--case-sensitive') {
+      options.caseSensitive = true;
+      i++;
+    } else if (arg === '--word') {
+      options.wholeWord = true;
+      i++;
+    } else if (arg === '--include-generated') {
+      options.includeGenerated = true;
+      i++;
+    } else if (arg === '--binary') {
+      options.binary = true;
+      i++;
+    } else if (arg === '--follow-symlinks') {
+      options.followSymlinks = true;
+      i++;
+    } else if (arg === '--json') {
+      json = true;
+      i++;
+    } else if (arg.startsWith('--')) {
+      throw new Error(`unknown option ${arg}`);
+    } else {
+      positional.push(arg);
+      i++;
+    }
+  }
+
+  if (query !== undefined) {
+    if (positional.length > 1) {
+      throw new Error(`unexpected argument ${positional[1]}`);
+    }
+    const { pattern, path: queryPath, kind, repo, workspace, target: queryTarget, ...parsed } = parseQuery(query);
+    const unsupported = [kind && 'kind:', repo && 'repo:', workspace && 'workspace:'].filter(Boolean);
+    if (unsupported.length > 0) {
+      throw new Error(`${unsupported.join(', ')} need the server; use search_code`);
+    }
+    // Flags given with the query win, as parameters do for search_code
+    const merged: LexicalSearchOptions = { ...parsed, ...options };
+    if (globs.length > 0) {
+      merged.glob = globs;
+    }
+    return { pattern, path: positional[0] ?? queryPath, target: target ?? queryTarget, json, options: merged };
+  }
+  if (positional.length === 0 || positional[0] === '') {
+    throw new Error('a search pattern is required');
+  }
+  if (positional.length > 2) {
+    throw new Error(`unexpected argument ${positional[2]}`);
+  }
+  if (globs.length > 0) {
+    options.glob = globs;
+  }
+  return { pattern: positional[0], path: positional[1], target, json, options };
+}
+
+/**
+ * Format one match as a grep-style line
+ */
+function formatMatchLine(match: LexicalMatch): string {
+  if (match.byteOffset !== undefined) {
+    return `${match.filePath}: binary match at byte offset ${match.byteOffset}`;
+  }
+  let text = match.lineText;
+  if (match.clipped) {
+    const { windowStart, lineLength } = match.clipped;
+    text = `${windowStart > 1 ? '…' : ''}${text}${windowStart - 1 + text.length < lineLength ? '…' : ''}`;
+  }
+  return `${match.filePath}:${match.line}:${match.column}: ${text}`;
+}
+
+/**
+ * Summary line of a search, printed to stderr in human mode
+ */
+function formatSummary(result: LexicalSearchResult): string {
+  const files = new Set(result.matches.map((match) => match.filePath)).size;
+  let summary = `${result.matches.length} match(es) in ${files} file(s), ${result.filesScanned} file(s) scanned`;
+  if (result.truncated) {
+    summary += '; results truncated, raise --max-results to see more';
+  }
+  if (result.truncatedByTimeout) {
+    summary += '; search timed out, results are partial';
+  }
+  if (result.truncatedByMemory) {
+    summary += '; memory budget reached, results are partial';
+  }
+  for (const skipped of result.largeFilesSkipped) {
+    summary += `\nskipped ${skipped.filePath} (${skipped.size} bytes)`;
+  }
+  return summary + '\n';
+}
+
+/**
+ * Run a search command against a workspace
+ * Settings the command leaves unset come from the server's environment
+ * variables, so results match search_code
+ */
+export async function runSearchCommand(
+  workspaceDir: string,
+  command: SearchCommand,
+  defaults: LexicalSearchOptions = {}
+): Promise<SearchCommandOutput> {
+  const options: LexicalSearchOptions = {
+    ...searchDefaultsFromEnv(),
+    ...defaults,
+    ...command.options,
+  };
+  if (command.path) {
+    options.path = path.resolve(command.path);
+  }
+  if (command.target) {
+    options.files = await bazelTargetFiles(workspaceDir, command.target);
+  }
+
+  const result = await searchLexical(workspaceDir, command.pattern, options);
+  const exitCode = result.matches.length > 0 ? 0 : 1;
+  if (command.json) {
+    const { matches, ...stats } = result;
+    return { stdout: JSON.stringify({ pattern: command.pattern, ...stats, matches }, null, 2) + '\n', stderr: '', exitCode };
+  }
+  const lines = result.matches.map((match) => formatMatchLine(match) + '\n');
+  return { stdout: lines.join(''), stderr: formatSummary(result), exitCode };
+}
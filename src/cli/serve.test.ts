@@ -0,0 +1,161 @@
+/**
+ * Tests for the standalone HTTP search
+ */
+
+import * as fs from 'fs';
+import * as http from 'http';
+import * as os from 'os';
+import * as path from 'path';
+import { AddressInfo } from 'net';
+import { parseServeArgs, queryStringParams, searchRequest, startSearchServer } from './serve';
+
+/**
+ * Send a request, returning the status, content type, and body; a body is sent as JSON unless the headers say otherwise
+ */
+function request(
+  server: http.Server,
+  urlPath: string,
+  body?: string,
+  headers: http.OutgoingHttpHeaders = {}
+): Promise<{ status: number; type: string; body: string }> {
+  const { port } = server.address() as AddressInfo;
+  return new Promise((resolve, reject) => {
+    const method = body === undefined ? 'GET' : 'POST';
+    const sent = { ...(body === undefined ? {} : { 'Content-Type': 'application/json' }), ...headers };
+    const req = http.request({ host: '127.0.0.1', port, path: urlPath, method, headers: sent }, (res) => {
+      let text = '';
+      res.setEncoding('utf8');
+      res.on('data', (chunk: string) => {
+        text += chunk;
+      });
+      res.on('end', () => resolve({ status: res.statusCode!, type: String(res.headers['content-type']), body: text }));
+    });
+    req.on('error', reject);
+    req.end(body);
+  });
+}
+
+const lines = (body: string) => body.trim().split('\n').map((line) => JSON.parse(line));
+
+describe('HTTP search', () => {
+  let workspace: string;
+  let server: http.Server;
+
+  beforeAll(async () => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'serve-')));
+    fs.mkdirSync(path.join(workspace, 'src'));
+    fs.writeFileSync(path.join(workspace, 'src', 'a.ts'), 'const fooBar = 1;\nfoo();\n');
+    fs.writeFileSync(path.join(workspace, 'notes.md'), 'foo in the notes\n');
+    server = await startSearchServer(workspace, { port: 0, host: '127.0.0.1' });
+  });
+
+  afterAll(async () => {
+    await new Promise((resolve) => server.close(resolve));
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should parse the serve options', () => {
+    expect(parseServeArgs([])).toEqual({ port: 8765, host: '127.0.0.1' });
+    expect(parseServeArgs(['--port', '0', '--workspace', '/w', '--host', '0.0.0.0'])).toEqual({ port: 0, host: '0.0.0.0' });
+    expect(() => parseServeArgs(['--port', 'http'])).toThrow('--port must be a port number');
+    expect(() => parseServeArgs(['search'])).toThrow('unexpected argument search');
+    expect(parseServeArgs(['--ui', '--lsp', 'gopls', '--', 'serve'])).toEqual({ port: 8765, host: '127.0.0.1', ui: true });
+    expect(parseServeArgs([], { SERVE_TOKEN: 's3cret' }).token).toBe('s3cret');
+    expect(parseServeArgs(['--token', 'other'], { SERVE_TOKEN: 's3cret' }).token).toBe('other');
+  });
+
+  it('should check and type the request parameters', () => {
+    expect(searchRequest(queryStringParams(new URLSearchParams('pattern=foo&wholeWord&glob=src/**&glob=*.md&maxResults=5')))).toEqual({
+      pattern: 'foo',
+      options: { wholeWord: true, glob: ['src/**', '*.md'], maxResults: 5, scope: undefined },
+      target: undefined,
+    });
+    expect(searchRequest({ query: 'file:*.ts foo', caseSensitive: true })).toEqual({
+      pattern: 'foo',
+      options: { glob: ['*.ts'], caseSensitive: true },
+      target: undefined,
+    });
+    expect(() => searchRequest({ pattern: 'foo', regex: 'yes' })).toThrow('regex must be true or false');
+    expect(() => searchRequest({ pattern: 'foo', colour: true })).toThrow('unknown parameter "colour"');
+    expect(() => searchRequest({})).toThrow('pattern or query is required');
+    expect(() => searchRequest({ query: 'kind:func foo' })).toThrow('kind: need the MCP server');
+  });
+
+  it('should stream matches as NDJSON, then a summary', async () => {
+    const response = await request(server, '/search?pattern=foo&wholeWord=true');
+    expect(response.status).toBe(200);
+    expect(response.type).toContain('application/x-ndjson');
+    const [first, second, done] = lines(response.body);
+    expect([first.type, first.filePath, first.line]).toEqual(['match', 'notes.md', 1]);
+    expect([second.type, second.filePath, second.line, second.lineText]).toEqual(['match', path.join('src', 'a.ts'), 2, 'foo();']);
+    expect([done.type, done.pattern, done.count, done.truncated]).toEqual(['done', 'foo', 2, false]);
+
+    const posted = lines((await request(server, '/search', JSON.stringify({ pattern: 'fooBar', glob: ['src/**'] }))).body);
+    expect(posted.map((line) => line.type)).toEqual(['match', 'done']);
+  });
+
+  it('should serve the web UI and its tool calls when enabled', async () => {
+    const calls: unknown[] = [];
+    const ui = await startSearchServer(workspace, { port: 0, host: '127.0.0.1', ui: true }, undefined, async (name, args) => {
+      calls.push([name, args]);
+      return { content: [{ type: 'text', text: `${name} output` }] };
+    });
+    try {
+      const page = await request(ui, '/');
+      expect(page.type).toContain('text/html');
+      expect(page.body).toContain('<title>grep-for-code</title>');
+      expect(JSON.parse((await request(ui, '/api/outline', JSON.stringify({ filePath: 'src/a.ts' }))).body))
+        .toEqual({ text: 'outline output', isError: false });
+      expect(calls).toEqual([['outline', { filePath: 'src/a.ts' }]]);
+      expect((await request(ui, '/api/edit_file', '{}')).status).toBe(404);
+      expect((await request(ui, '/api/outline')).status).toBe(405);
+      expect((await request(ui, '/api/outline', JSON.stringify({ filePath: 'src/a.ts' }), { Host: 'attacker.example' })).status).toBe(403);
+      expect((await request(ui, '/api/outline', 'filePath=src/a.ts', { 'Content-Type': 'text/plain' })).status).toBe(400);
+      expect(calls.length).toBe(1);
+    } finally {
+      await new Promise((resolve) => ui.close(resolve));
+    }
+    const guarded = await startSearchServer(workspace, { port: 0, host: '127.0.0.1', ui: true, token: 's3cret' }, undefined, async (name) => {
+      calls.push(name);
+      return { content: [{ type: 'text', text: `${name} output` }] };
+    });
+    try {
+      expect((await request(guarded, '/')).status).toBe(401);
+      expect((await request(guarded, '/?token=s3cret')).status).toBe(200);
+      expect((await request(guarded, '/api/outline?token=s3cret', '{}')).status).toBe(401);
+      expect((await request(guarded, '/api/outline', '{}', { Authorization: 'Bearer s3cret' })).status).toBe(200);
+      expect(calls.length).toBe(2);
+    } finally {
+      await new Promise((resolve) => guarded.close(resolve));
+    }
+    expect((await request(server, '/')).status).toBe(404);
+  });
+
+  it('should answer bad requests with a JSON error', async () => {
+    const missing = await request(server, '/search?regex');
+    expect(missing.status).toBe(400);
+    expect(JSON.parse(missing.body)).toEqual({ error: 'invalid-argument', message: 'pattern or query is required' });
+    expect(JSON.parse((await request(server, '/search?pattern=(&regex')).body).error).toBe('pattern-invalid');
+    expect((await request(server, '/search', '[1]')).status).toBe(400);
+    expect((await request(server, '/other')).status).toBe(404);
+  });
+
+  it('should refuse requests a page on another site could send', async () => {
+    expect((await request(server, '/search?pattern=foo', undefined, { Host: 'attacker.example:8765' })).status).toBe(403);
+    expect((await request(server, '/search?pattern=foo', undefined, { Host: 'localhost:8765' })).status).toBe(200);
+    const form = await request(server, '/search', 'pattern=foo', { 'Content-Type': 'application/x-www-form-urlencoded' });
+    expect(form.status).toBe(400);
+    expect(JSON.parse(form.body).message).toBe('the request body must be sent as application/json');
+  });
+
+  it('should require the bearer token when one is set', async () => {
+    const guarded = await startSearchServer(workspace, { port: 0, host: '127.0.0.1', token: 's3cret' });
+    try {
+      expect((await request(guarded, '/search?pattern=foo')).status).toBe(401);
+      expect((await request(guarded, '/search?pattern=foo', undefined, { Authorization: 'Bearer wrong' })).status).toBe(401);
+      expect((await request(guarded, '/search?pattern=foo', undefined, { Authorization: 'Bearer s3cret' })).status).toBe(200);
+    } finally {
+      await new Promise((resolve) => guarded.close(resolve));
+    }
+  });
+});
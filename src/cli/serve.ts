@@ -0,0 +1,448 @@
+/**
+ * Standalone HTTP search
+ * `grep-for-code serve` answers searches over plain HTTP without an MCP
+ * client: GET /search?pattern=... (or POST /search with a JSON body) streams
+ * the matches as newline-delimited JSON while the search runs, one object
+ * per line, so editors and web UIs can show results as they arrive
+ *
+ *   {"type":"match","filePath":"src/a.ts","line":3,"column":5,...}
+ *   {"type":"done","count":12,"filesScanned":840,"truncated":false,...}
+ *
+ * A failure before the first match is a 400 or 500 response with a JSON
+ * error; a failure after it is an {"type":"error"} line
+ *
+ * With --ui, / serves a page for browsing search results, outlines, and
+ * definitions, and POST /api/<tool> runs the tools it needs
+ */
+
+import * as crypto from 'crypto';
+import * as http from 'http';
+import { AddressInfo } from 'net';
+import { createLogger, Component } from '../logging/logger.js';
+import { LexicalMatch, LexicalSearchOptions, searchDefaultsFromEnv, searchLexical } from '../search/lexical.js';
+import { parseQuery } from '../search/query.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { bazelTargetFiles } from '../workspace/bazel.js';
+import { ToolError, toToolError } from '../workspace/errors.js';
+import { parseSearchScope } from '../workspace/fixtures.js';
+import { UI_PAGE, UI_TOOLS, UiTools } from './ui.js';
+
+const serveLogger = createLogger(Component.CORE);
+
+/**
+ * A parsed serve command
+ */
+export interface ServeCommand {
+  port: number;
+  host: string;
+  // Requests must send it as "Authorization: Bearer <token>" (default: SERVE_TOKEN)
+  token?: string;
+  // Serve the web UI and the tool calls it makes; needs the language server
+  ui?: boolean;
+}
+
+/**
+ * Port listened on unless --port is given
+ */
+const DEFAULT_PORT = 8765;
+
+/**
+ * Largest POST body accepted
+ */
+const MAX_BODY_SIZE = 1024 * 1024;
+
+/**
+ * Flags handled by the main argument parser, with the values they take
+ */
+const GLOBAL_FLAGS = new Set(['--workspace', '--config', '--lsp']);
+
+export const SERVE_USAGE = `Usage: grep-for-code serve [options]
+
+Answers GET /search?pattern=<pattern>&... and POST /search with a JSON body
+of the same parameters, streaming matches as newline-delimited JSON
+(application/x-ndjson). Parameters: pattern or query, regex, caseSensitive,
+wholeWord, identifierWords, path, glob, excludeGlob, languages, scope,
+target, maxResults, timeoutMs, includeGenerated; glob, excludeGlob, and
+languages repeat in a query string
+
+With --ui, http://<host>:<port>/ is a page for searching and following
+results into outlines, file lines, and definitions, through the same tool
+handlers MCP clients call; it starts the language server given with --lsp
+
+Options:
+  --port <n>            Port to listen on (default: ${DEFAULT_PORT}; 0 picks a free one)
+  --host <host>         Address to listen on (default: 127.0.0.1)
+  --token <token>       Require "Authorization: Bearer <token>" (default: SERVE_TOKEN)
+  --ui                  Serve the web UI
+  --lsp <command>       Language server for the UI, as for the MCP server
+  --workspace <dir>     Workspace root (default: the current directory)
+  --config <path>       Configuration file
+`;
+
+/**
+ * Parse the arguments after "serve"
+ */
+export function parseServeArgs(args: string[], env: NodeJS.ProcessEnv = process.env): ServeCommand {
+  const command: ServeCommand = { port: DEFAULT_PORT, host: '127.0.0.1' };
+  if (env.SERVE_TOKEN) {
+    command.token = env.SERVE_TOKEN;
+  }
+  let i = 0;
+  while (i < args.length) {
+    const arg = args[i];
+    if (GLOBAL_FLAGS.has(arg) || arg === '--port' || arg === '--host' || arg === '--token') {
+      if (i + 1 >= args.length) {
+        throw new Error(`${arg} requires a value`);
+      }
+      if (arg === '--port') {
+        const port = parseInt(args[i + 1], 10);
+        if (!(port >= 0 && port <= 65535)) {
+          throw new Error(`--port must be a port number, got ${args[i + 1]}`);
+        }
+        command.port = port;
+      } else if (arg === '--host') {
+        command.host = args[i + 1];
+      } else if (arg === '--token') {
+        command.token = args[i + 1];
+      }
+      i += 2;
+    } else if (arg === '--ui') {
+      command.ui = true;
+      i++;
+    } else if (arg === '--') {
+      // Language server arguments follow
+      break;
+    } else if (arg.startsWith('--')) {
+      throw new Error(`unknown option ${arg}`);
+    } else {
+      throw new Error(`unexpected argument ${arg}`);
+    }
+  }
+  return command;
+}
+
+/**
+ * Parameters of a search request, by the type they take
+ */
+const STRING_PARAMS = ['pattern', 'query', 'path', 'scope', 'target'];
+const BOOLEAN_PARAMS = ['regex', 'caseSensitive', 'wholeWord', 'identifierWords', 'includeGenerated'];
+const NUMBER_PARAMS = ['maxResults', 'timeoutMs'];
+const LIST_PARAMS = ['glob', 'excludeGlob', 'languages'];
+
+/**
+ * Parameters of a query string, typed as a JSON body would hold them
+ */
+export function queryStringParams(search: URLSearchParams): Record<string, unknown> {
+  const params: Record<string, unknown> = {};
+  for (const key of new Set(search.keys())) {
+    const value = search.get(key)!;
+    if (LIST_PARAMS.includes(key)) {
+      params[key] = search.getAll(key);
+    } else if (BOOLEAN_PARAMS.includes(key)) {
+      // A bare flag (?regex) is true
+      params[key] = value === '' || value === 'true' || value === '1' ? true : value === 'false' || value === '0' ? false : value;
+    } else if (NUMBER_PARAMS.includes(key)) {
+      params[key] = /^\d+$/.test(value) ? parseInt(value, 10) : value;
+    } else {
+      params[key] = value;
+    }
+  }
+  return params;
+}
+
+/**
+ * A validated search request
+ */
+export interface SearchRequest {
+  pattern: string;
+  options: LexicalSearchOptions;
+  target?: string;
+}
+
+/**
+ * Check the parameters of a request and turn them into a search
+ * A query is parsed as search_code parses it; parameters passed with it win
+ */
+export function searchRequest(params: Record<string, unknown>): SearchRequest {
+  for (const [key, value] of Object.entries(params)) {
+    const valid = STRING_PARAMS.includes(key) ? typeof value === 'string'
+      : BOOLEAN_PARAMS.includes(key) ? typeof value === 'boolean'
+        : NUMBER_PARAMS.includes(key) ? typeof value === 'number' && Number.isInteger(value) && value >= 0
+          : LIST_PARAMS.includes(key) ? Array.isArray(value) && value.every((item) => typeof item === 'string')
+            : undefined;
+    if (valid === undefined) {
+      throw new ToolError('invalid-argument', `unknown parameter "${key}"`);
+    }
+    if (!valid) {
+      const type = STRING_PARAMS.includes(key) ? 'a string' : BOOLEAN_PARAMS.includes(key) ? 'true or false'
+        : NUMBER_PARAMS.includes(key) ? 'a non-negative whole number' : 'a list of strings';
+      throw new ToolError('invalid-argument', `${key} must be ${type}`);
+    }
+  }
+  const { pattern, query, scope, target, ...rest } = params as Record<string, any>;
+  const options: LexicalSearchOptions = { ...rest, scope: parseSearchScope(scope) };
+  if (query !== undefined) {
+    const { pattern: queryPattern, kind, repo, workspace, target: queryTarget, ...parsed } = parseQuery(query);
+    const unsupported = [kind && 'kind:', repo && 'repo:', workspace && 'workspace:'].filter(Boolean);
+    if (unsupported.length > 0) {
+      throw new ToolError('unsupported', `${unsupported.join(', ')} need the MCP server; use search_code`);
+    }
+    return {
+      pattern: pattern ?? queryPattern,
+      options: { ...parsed, ...definedOptions(options) },
+      target: target ?? queryTarget,
+    };
+  }
+  if (!pattern) {
+    throw new ToolError('invalid-argument', 'pattern or query is required');
+  }
+  return { pattern, options, target };
+}
+
+/**
+ * Options without the keys left unset, so they do not override a query's
+ */
+function definedOptions(options: LexicalSearchOptions): LexicalSearchOptions {
+  return Object.fromEntries(Object.entries(options).filter(([, value]) => value !== undefined));
+}
+
+/**
+ * Host names a request may be addressed to besides the listening address
+ */
+const LOOPBACK_HOSTS = new Set(['localhost', '127.0.0.1', '[::1]']);
+
+/**
+ * Addresses that listen on every interface, so they name no host
+ */
+const WILDCARD_HOSTS = new Set(['0.0.0.0', '::', '[::]']);
+
+/**
+ * Refuse a request that a web page on another site could have sent
+ * The Host header must name the loopback interface or the address listened
+ * on, so a DNS rebinding page cannot reach the server, and the bearer token
+ * must match when one is set; the UI page, which a browser opens without
+ * headers, may give it as ?token= instead. Returns the HTTP status to answer
+ * with, or 0
+ */
+function refuseRequest(req: http.IncomingMessage, command: ServeCommand, queryToken?: string | null): number {
+  const host = (req.headers.host ?? '').toLowerCase().replace(/:\d+$/, '');
+  const listening = command.host.includes(':') && !command.host.startsWith('[') ? `[${command.host}]` : command.host;
+  if (!LOOPBACK_HOSTS.has(host) && (host !== listening.toLowerCase() || WILDCARD_HOSTS.has(listening))) {
+    return 403;
+  }
+  if (command.token) {
+    const given = Buffer.from(req.headers.authorization ?? (queryToken ? `Bearer ${queryToken}` : ''));
+    const expected = Buffer.from(`Bearer ${command.token}`);
+    if (given.length !== expected.length || !crypto.timingSafeEqual(given, expected)) {
+      return 401;
+    }
+  }
+  return 0;
+}
+
+/**
+ * Read a JSON request body; other content types are refused, so an HTML form cannot post one
+ */
+function readJsonBody(req: http.IncomingMessage): Promise<Record<string, unknown>> {
+  const type = (req.headers['content-type'] ?? '').split(';')[0].trim().toLowerCase();
+  if (type !== 'application/json') {
+    req.resume();
+    return Promise.reject(new ToolError('invalid-argument', 'the request body must be sent as application/json'));
+  }
+  return new Promise((resolve, reject) => {
+    const chunks: Buffer[] = [];
+    let size = 0;
+    req.on('data', (chunk: Buffer) => {
+      size += chunk.length;
+      if (size > MAX_BODY_SIZE) {
+        reject(new ToolError('invalid-argument', `the request body must be at most ${MAX_BODY_SIZE} bytes`));
+        req.destroy();
+        return;
+      }
+      chunks.push(chunk);
+    });
+    req.on('end', () => {
+      const text = Buffer.concat(chunks).toString('utf8');
+      try {
+        const body = text.trim() ? JSON.parse(text) : {};
+        if (typeof body !== 'object' || body === null || Array.isArray(body)) {
+          throw new Error('not an object');
+        }
+        resolve(body);
+      } catch (err) {
+        reject(new ToolError('invalid-argument', `the request body must be a JSON object: ${(err as Error).message}`));
+      }
+    });
+    req.on('error', reject);
+  });
+}
+
+/**
+ * Run a search, writing each file's matches as they are found
+ */
+async function streamSearch(
+  workspaceDir: string,
+  request: SearchRequest,
+  req: http.IncomingMessage,
+  res: http.ServerResponse,
+  index?: TrigramIndex
+): Promise<void> {
+  // The search stops when the client goes away
+  let closed = false;
+  const abort = new AbortController();
+  res.on('close', () => {
+    closed = true;
+    abort.abort();
+  });
+  const begin = () => {
+    if (!res.headersSent) {
+      res.writeHead(200, { 'Content-Type': 'application/x-ndjson; charset=utf-8', 'Cache-Control': 'no-cache' });
+    }
+  };
+  const write = (line: Record<string, unknown>) => {
+    if (!closed) {
+      begin();
+      res.write(JSON.stringify(line) + '\n');
+    }
+  };
+  const options: LexicalSearchOptions = {
+    ...searchDefaultsFromEnv(),
+    ...definedOptions(request.options),
+    signal: abort.signal,
+    onMatches: (batch: LexicalMatch[]) => batch.forEach((match) => write({ type: 'match', ...match })),
+  };
+  try {
+    if (request.target) {
+      options.files = await bazelTargetFiles(workspaceDir, request.target);
+    }
+    const { matches, largeFilesSkipped, archivesSkipped, omittedByFile, ...stats } = await searchLexical(workspaceDir, request.pattern, options, index);
+    write({
+      type: 'done',
+      pattern: request.pattern,
+      count: matches.length,
+      ...stats,
+      largeFilesSkipped: largeFilesSkipped.length,
+      archivesSkipped: archivesSkipped.length,
+    });
+    serveLogger.debug('Streamed %d match(es) for %s %s%s', matches.length, req.method, req.url, closed ? ' until the client went away' : '');
+  } catch (err) {
+    const error = toToolError(err);
+    if (res.headersSent) {
+      write({ type: 'error', ...error.toJSON() });
+    } else {
+      res.writeHead(error.code === 'internal' ? 500 : 400, { 'Content-Type': 'application/json' });
+      res.write(JSON.stringify(error.toJSON()) + '\n');
+    }
+  }
+  res.end();
+}
+
+/**
+ * Answer an error as JSON: 500 for failures of unknown kind, 400 otherwise
+ */
+function sendError(res: http.ServerResponse, err: unknown): void {
+  const error = toToolError(err);
+  res.writeHead(error.code === 'internal' ? 500 : 400, { 'Content-Type': 'application/json' })
+    .end(JSON.stringify(error.toJSON()) + '\n');
+}
+
+/**
+ * Run a tool call of the web UI, answering with its text
+ */
+async function serveToolCall(tools: UiTools, name: string, req: http.IncomingMessage, res: http.ServerResponse): Promise<void> {
+  if (!UI_TOOLS.has(name)) {
+    res.writeHead(404, { 'Content-Type': 'text/plain' }).end(`No UI tool named ${name}\n`);
+    return;
+  }
+  if (req.method !== 'POST') {
+    res.writeHead(405, { 'Content-Type': 'text/plain', Allow: 'POST' }).end('Method not allowed\n');
+    return;
+  }
+  try {
+    const result = await tools(name, await readJsonBody(req));
+    const text = result.content.map((part) => part.text).join('\n');
+    res.writeHead(200, { 'Content-Type': 'application/json' }).end(JSON.stringify({ text, isError: result.isError ?? false }) + '\n');
+  } catch (err) {
+    sendError(res, err);
+  }
+}
+
+/**
+ * Answer a request: /search streams a search, the UI routes serve the page
+ * and its tool calls when enabled, and anything else is not found
+ */
+async function handleRequest(
+  workspaceDir: string,
+  command: ServeCommand,
+  index: TrigramIndex | undefined,
+  tools: UiTools | undefined,
+  req: http.IncomingMessage,
+  res: http.ServerResponse
+) {
+  const url = new URL(req.url ?? '/', 'http://localhost');
+  // Checked before anything is dispatched, UI tool calls included
+  const page = tools !== undefined && url.pathname === '/';
+  const refused = refuseRequest(req, command, page ? url.searchParams.get('token') : undefined);
+  if (refused) {
+    res.writeHead(refused, { 'Content-Type': 'text/plain' }).end(refused === 401 ? 'Unauthorized\n' : 'Forbidden host\n');
+    return;
+  }
+  if (page && (req.method === 'GET' || req.method === 'HEAD')) {
+    res.writeHead(200, { 'Content-Type': 'text/html; charset=utf-8' }).end(req.method === 'HEAD' ? undefined : UI_PAGE);
+    return;
+  }
+  if (tools && url.pathname.startsWith('/api/')) {
+    await serveToolCall(tools, url.pathname.substring('/api/'.length), req, res);
+    return;
+  }
+  if (url.pathname !== '/search') {
+    res.writeHead(404, { 'Content-Type': 'text/plain' }).end('Not found\n');
+    return;
+  }
+  if (req.method !== 'GET' && req.method !== 'POST') {
+    res.writeHead(405, { 'Content-Type': 'text/plain', Allow: 'GET, POST' }).end('Method not allowed\n');
+    return;
+  }
+  let request: SearchRequest;
+  try {
+    request = searchRequest(req.method === 'POST' ? await readJsonBody(req) : queryStringParams(url.searchParams));
+  } catch (err) {
+    sendError(res, err);
+    return;
+  }
+  await streamSearch(workspaceDir, request, req, res, index);
+}
+
+/**
+ * Serve searches of a workspace over HTTP until the server is closed; port 0 picks a free port
+ * The trigram index, when given, is refreshed before each search; the web UI is served when tools are given
+ */
+export function startSearchServer(
+  workspaceDir: string,
+  command: ServeCommand,
+  index?: TrigramIndex,
+  tools?: UiTools
+): Promise<http.Server> {
+  const server = http.createServer((req, res) => {
+    handleRequest(workspaceDir, command, index, tools, req, res).catch((err) => {
+      serveLogger.error('Failed to answer %s %s: %s', req.method, req.url, err);
+      if (!res.headersSent) {
+        res.writeHead(500, { 'Content-Type': 'text/plain' });
+      }
+      res.end();
+    });
+  });
+
+  return new Promise((resolve, reject) => {
+    server.once('error', reject);
+    server.listen(command.port, command.host, () => {
+      server.off('error', reject);
+      const address = server.address() as AddressInfo;
+      serveLogger.info('Serving searches of %s at http://%s:%d/search', workspaceDir, address.address, address.port);
+      if (tools) {
+        serveLogger.info('Web UI at http://%s:%d/', address.address, address.port);
+      }
+      resolve(server);
+    });
+  });
+}
@@ -0,0 +1,97 @@
+/**
+ * Tests for the command line index snapshots
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { execFileSync } from 'child_process';
+import { parseIndexArgs, runIndexCommand } from './snapshot';
+import { readSnapshot } from '../search/snapshot';
+import { parseScip } from '../symbols/codeindex';
+
+describe('Command line index snapshots', () => {
+  let workspace: string;
+  let out: string;
+
+  const git = (...args: string[]) => execFileSync('git', args, { cwd: workspace, stdio: 'pipe' }).toString().trim();
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'cli-snapshot-')));
+    out = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'cli-snapshot-out-')));
+    fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nfunc main() {}\n');
+    git('init', '-q');
+    git('add', '.');
+    git('-c', 'user.name=t', '-c', 'user.email=t@t', 'commit', '-q', '-m', 'init');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+    fs.rmSync(out, { recursive: true, force: true });
+  });
+
+  it('should parse the command and the file', () => {
+    expect(parseIndexArgs(['export', 'index.bin', '--workspace', '/w'])).toEqual({ action: 'export', file: 'index.bin', sameCommit: false });
+    expect(parseIndexArgs(['import', '--same-commit', 'index.bin']).sameCommit).toBe(true);
+    expect(() => parseIndexArgs([])).toThrow('an index command is required (export or import)');
+    expect(() => parseIndexArgs(['save', 'index.bin'])).toThrow('unknown index command save');
+    expect(() => parseIndexArgs(['export'])).toThrow('a snapshot file is required');
+    expect(() => parseIndexArgs(['export', 'a', 'b'])).toThrow('unexpected argument b');
+    expect(parseIndexArgs(['export', '--format=scip', 'index.scip']).format).toBe('scip');
+    expect(parseIndexArgs(['export', '--format', 'snapshot', 'index.bin']).format).toBeUndefined();
+    expect(() => parseIndexArgs(['export', '--format', 'lsif', 'a'])).toThrow('--format must be one of snapshot, scip');
+    expect(() => parseIndexArgs(['import', '--format=scip', 'a'])).toThrow('--format scip only applies to export');
+  });
+
+  it('should export the built-in symbols and their references as SCIP', async () => {
+    fs.writeFileSync(path.join(workspace, 'util.go'), 'package main\n\n// helper is called by main\nfunc helper() int { return 1 }\n');
+    fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nfunc main() {\n\thelper()\n}\n');
+    const file = path.join(out, 'index.scip');
+    fs.writeFileSync(file, 'an older index');
+    const exported = await runIndexCommand(workspace, parseIndexArgs(['export', '--format=scip', file]));
+    expect(exported.stdout).toContain('Exported a SCIP index of 2 symbol(s) and 1 reference(s)');
+    expect(fs.readdirSync(out)).toEqual(['index.scip']);
+
+    const dump = parseScip(fs.readFileSync(file));
+    expect(dump.tool).toBe('grep-for-code');
+    const helper = dump.symbols.find((symbol) => symbol.name === 'helper')!;
+    expect(helper.id).toBe('grep-for-code . . . `util.go`/helper().');
+    expect(helper.definitions.map((location) => [location.relativePath, location.range.start])).toEqual([['util.go', { line: 3, character: 5 }]]);
+    expect(helper.references.map((location) => [location.relativePath, location.range.start])).toEqual([['main.go', { line: 3, character: 1 }]]);
+  });
+
+  it('should export at the commit and import into the cache', async () => {
+    const head = git('rev-parse', 'HEAD');
+    const file = path.join(out, 'index.bin');
+    const exported = await runIndexCommand(workspace, parseIndexArgs(['export', file]));
+    expect(exported.stdout).toContain(`Exported the index of 1 file(s) and 0 symbol(s) at ${head.substring(0, 12)}`);
+    expect(exported.stderr).toBe('');
+    expect((await readSnapshot(file)).commit).toBe(head);
+
+    const installed = path.join(out, 'cache', 'index-snapshot.bin');
+    const imported = await runIndexCommand(workspace, parseIndexArgs(['import', file]), installed);
+    expect(imported).toMatchObject({ stderr: '', exitCode: 0 });
+    expect(fs.readFileSync(installed)).toEqual(fs.readFileSync(file));
+  });
+
+  it('should warn about, or refuse, a snapshot of another commit', async () => {
+    const file = path.join(out, 'index.bin');
+    await runIndexCommand(workspace, parseIndexArgs(['export', file]));
+    fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n');
+    git('-c', 'user.name=t', '-c', 'user.email=t@t', 'commit', '-q', '-am', 'next');
+
+    const installed = path.join(out, 'index-snapshot.bin');
+    const refused = await runIndexCommand(workspace, parseIndexArgs(['import', '--same-commit', file]), installed);
+    expect(refused.exitCode).toBe(1);
+    expect(refused.stderr).toMatch(/^error: the snapshot is of \w+, the workspace is at \w+/);
+    expect(fs.existsSync(installed)).toBe(false);
+
+    const imported = await runIndexCommand(workspace, parseIndexArgs(['import', file]), installed);
+    expect(imported.exitCode).toBe(0);
+    expect(imported.stderr).toContain('files changed since are indexed again');
+
+    const broken = await runIndexCommand(workspace, parseIndexArgs(['import', path.join(workspace, 'main.go')]), installed);
+    expect(broken.exitCode).toBe(1);
+    expect(broken.stderr).toContain('not an index snapshot');
+  });
+});
@@ -0,0 +1,183 @@
+/**
+ * Command line index snapshots
+ * `grep-for-code index export <file>` builds the index of a workspace and
+ * writes it as a snapshot; `grep-for-code index import <file>` installs a
+ * snapshot exported elsewhere, which the server seeds its index with on start.
+ * `index export --format=scip <file>` writes the built-in parsers' symbols
+ * and references as a SCIP index instead, for code-intelligence tools
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { TrigramIndex } from '../search/trigram.js';
+import { buildSnapshot, defaultSnapshotPath, readSnapshot, workspaceCommit, writeSnapshot, IndexSnapshot } from '../search/snapshot.js';
+import { sharedProtoSymbols } from '../symbols/proto.js';
+import { PythonSymbolIndex } from '../symbols/python.js';
+import { encodeScip } from '../symbols/codeindex.js';
+import { buildWorkspaceCodeIndex } from '../tools/codeindex.js';
+import { writeFileAtomic } from '../workspace/atomic.js';
+import { SearchCommandOutput } from './search.js';
+
+/**
+ * A parsed index command
+ */
+export interface IndexCommand {
+  action: 'export' | 'import';
+  // Snapshot file, relative to the current directory
+  file: string;
+  // Refuse a snapshot of another commit than the workspace's
+  sameCommit: boolean;
+  // Export a SCIP index instead of a snapshot
+  format?: 'scip';
+}
+
+/**
+ * Export formats besides the default snapshot
+ */
+const EXPORT_FORMATS = ['snapshot', 'scip'];
+
+/**
+ * Flags handled by the main argument parser, with the values they take
+ */
+const GLOBAL_FLAGS = new Set(['--workspace', '--config']);
+
+export const INDEX_USAGE = `Usage: grep-for-code index export [options] <file>
+       grep-for-code index import [options] <file>
+
+Commands:
+  export                Build the index of the workspace and write it to <file>
+  import                Install the snapshot in <file> for the workspace; the
+                        server takes it on its next start, re-indexing only
+                        the files that differ from the snapshot
+
+Options:
+  --format <format>     On export, snapshot (default) or scip: a SCIP index of
+                        the symbols the built-in Go, Python, TypeScript, and
+                        .proto parsers find and their references, for
+                        code-intelligence tools
+  --same-commit         On import, fail unless the snapshot was exported at
+                        the commit the workspace is at
+  --workspace <dir>     Workspace root (default: the current directory)
+  --config <path>       Configuration file
+`;
+
+/**
+ * Parse the arguments after "index"
+ */
+export function parseIndexArgs(args: string[]): IndexCommand {
+  const positional: string[] = [];
+  let sameCommit = false;
+  let format = 'snapshot';
+  let i = 0;
+  while (i < args.length) {
+    const arg = args[i];
+    if (GLOBAL_FLAGS.has(arg)) {
+      if (i + 1 >= args.length) {
+        throw new Error(`${arg} requires a value`);
+      }
+      i += 2;
+    } else if (arg === '--format' || arg.startsWith('--format=')) {
+      if (arg === '--format' && i + 1 >= args.length) {
+        throw new Error('--format requires a value');
+      }
+      format = arg === '--format' ? args[i + 1] : arg.substring('--format='.length);
+      if (!EXPORT_FORMATS.includes(format)) {
+        throw new Error(`--format must be one of ${EXPORT_FORMATS.join(', ')}`);
+      }
+      i += arg === '--format' ? 2 : 1;
+    } else if (arg === '--same-commit') {
+      sameCommit = true;
+      i++;
+    } else if (arg.startsWith('--')) {
+      throw new Error(`unknown option ${arg}`);
+    } else {
+      positional.push(arg);
+      i++;
+    }
+  }
+
+  const [action, file, extra] = positional;
+  if (action !== 'export' && action !== 'import') {
+    throw new Error(action ? `unknown index command ${action}; expected export or import` : 'an index command is required (export or import)');
+  }
+  if (!file) {
+    throw new Error('a snapshot file is required');
+  }
+  if (extra !== undefined) {
+    throw new Error(`unexpected argument ${extra}`);
+  }
+  if (format === 'scip') {
+    if (action !== 'export') {
+      throw new Error('--format scip only applies to export');
+    }
+    return { action, file, sameCommit, format };
+  }
+  return { action, file, sameCommit };
+}
+
+function shortCommit(commit: string | undefined): string {
+  return commit ? commit.substring(0, 12) : 'no commit';
+}
+
+function formatSize(bytes: number): string {
+  return bytes >= 1024 * 1024 ? `${(bytes / (1024 * 1024)).toFixed(1)} MB` : `${Math.ceil(bytes / 1024)} KB`;
+}
+
+/**
+ * Run an index command against a workspace
+ * The snapshot of an import goes to the cache directory unless snapshotPath says otherwise
+ */
+export async function runIndexCommand(
+  workspaceDir: string,
+  command: IndexCommand,
+  snapshotPath: string = defaultSnapshotPath(workspaceDir)
+): Promise<SearchCommandOutput> {
+  const file = path.resolve(command.file);
+
+  if (command.action === 'export' && command.format === 'scip') {
+    const dump = await buildWorkspaceCodeIndex(workspaceDir, 'grep-for-code');
+    const data = encodeScip(dump, workspaceDir);
+    await writeFileAtomic(file, data);
+    const references = dump.symbols.reduce((sum, symbol) => sum + symbol.references.length, 0);
+    return {
+      stdout: `Exported a SCIP index of ${dump.symbols.length} symbol(s) and ${references} reference(s) to ${file} (${formatSize(data.length)})\n`,
+      stderr: '',
+      exitCode: 0,
+    };
+  }
+
+  if (command.action === 'export') {
+    const index = new TrigramIndex(workspaceDir);
+    const snapshot = await buildSnapshot(workspaceDir, index, [new PythonSymbolIndex(workspaceDir), sharedProtoSymbols(workspaceDir)]);
+    const size = await writeSnapshot(file, snapshot);
+    const symbols = snapshot.symbols.reduce((sum, entry) => sum + entry.symbols.length, 0);
+    const stderr = snapshot.dirty ? 'warning: the workspace has uncommitted changes; the snapshot holds them\n' : '';
+    return {
+      stdout: `Exported the index of ${snapshot.trigrams.length} file(s) and ${symbols} symbol(s) at ${shortCommit(snapshot.commit)} to ${file} (${formatSize(size)})\n`,
+      stderr,
+      exitCode: 0,
+    };
+  }
+
+  let snapshot: IndexSnapshot;
+  try {
+    snapshot = await readSnapshot(file);
+  } catch (err) {
+    return { stdout: '', stderr: `error: cannot import ${file}: ${(err as Error).message}\n`, exitCode: 1 };
+  }
+  const commit = await workspaceCommit(workspaceDir);
+  let stderr = '';
+  if (snapshot.commit !== commit) {
+    const mismatch = `the snapshot is of ${shortCommit(snapshot.commit)}, the workspace is at ${shortCommit(commit)}`;
+    if (command.sameCommit) {
+      return { stdout: '', stderr: `error: ${mismatch}\n`, exitCode: 1 };
+    }
+    stderr = `warning: ${mismatch}; files changed since are indexed again\n`;
+  }
+  await writeFileAtomic(snapshotPath, await fs.promises.readFile(file));
+  return {
+    stdout: `Imported the index of ${snapshot.trigrams.length} file(s) at ${shortCommit(snapshot.commit)} for ${workspaceDir}; the server takes it on its next start\n`,
+    stderr,
+    exitCode: 0,
+  };
+}
@@ -0,0 +1,147 @@
+/**
+ * Web UI of the HTTP search server
+ * One self-contained page, served at / by `grep-for-code serve --ui`, that
+ * calls search_code, outline, definition, and read_range through the same
+ * tool handlers an MCP client gets, and shows their output as the agent
+ * sees it, with paths and line numbers turned into links
+ */
+
+/**
+ * Tools the page may call; none of them writes
+ */
+export const UI_TOOLS = new Set(['search_code', 'outline', 'definition', 'read_range']);
+
+/**
+ * Runs a tool call for the page, as MCPLanguageServer.callTool does
+ */
+export type UiTools = (name: string, args: Record<string, unknown>) => Promise<{
+  content: Array<{ type: 'text'; text: string }>;
+  isError?: boolean;
+}>;
+
+export const UI_PAGE = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>grep-for-code</title>
+<style>
+  body { font: 14px system-ui, sans-serif; margin: 0; display: flex; flex-direction: column; height: 100vh; }
+  header { padding: 8px 12px; border-bottom: 1px solid #ddd; display: flex; gap: 8px; flex-wrap: wrap; align-items: center; }
+  header input[type=text] { padding: 4px 6px; }
+  #pattern { flex: 1; min-width: 240px; }
+  main { flex: 1; display: flex; min-height: 0; }
+  section { flex: 1; overflow: auto; padding: 8px 12px; }
+  section + section { border-left: 1px solid #ddd; }
+  pre { font: 12px ui-monospace, monospace; white-space: pre-wrap; margin: 0; }
+  a { color: #0550ae; cursor: pointer; text-decoration: none; }
+  a:hover { text-decoration: underline; }
+  .error { color: #b00020; }
+  .meta { color: #666; margin-bottom: 6px; }
+</style>
+</head>
+<body>
+<header>
+  <form id="search">
+    <input type="text" id="pattern" placeholder="search_code pattern or query (lang:go kind:func ...)" autofocus>
+    <label><input type="checkbox" id="regex"> regex</label>
+    <label><input type="checkbox" id="wholeWord"> word</label>
+    <label><input type="checkbox" id="context"> context</label>
+    <input type="text" id="glob" placeholder="glob, e.g. **/*.go">
+    <button>Search</button>
+  </form>
+  <form id="definition">
+    <input type="text" id="symbol" placeholder="definition of symbol">
+    <button>Go to definition</button>
+  </form>
+</header>
+<main>
+  <section><div class="meta" id="results-meta"></div><pre id="results"></pre></section>
+  <section><div class="meta" id="file-meta"></div><pre id="file"></pre></section>
+</main>
+<script>
+const $ = (id) => document.getElementById(id);
+const escape = (text) => text.replace(/[&<>"]/g, (c) => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;' })[c]);
+
+// The page is opened as /?token=<token> when the server requires one
+const token = new URLSearchParams(location.search).get('token');
+
+async function call(tool, args) {
+  const started = performance.now();
+  const headers = { 'Content-Type': 'application/json', ...(token ? { Authorization: 'Bearer ' + token } : {}) };
+  const res = await fetch('/api/' + tool, { method: 'POST', headers, body: JSON.stringify(args) });
+  const body = await res.json();
+  const ms = Math.round(performance.now() - started);
+  if (!res.ok) {
+    return { text: body.message || res.statusText, isError: true, ms };
+  }
+  return { ...body, ms };
+}
+
+// Paths on their own line and L<n> locations below them become links to the file at that line
+function linkify(text) {
+  let file;
+  return text.split('\\n').map((line) => {
+    const html = escape(line);
+    if (/^[^\\s:]+\\.[\\w]+$/.test(line) || /^### /.test(line)) {
+      file = line.replace(/^### /, '');
+      return '<a data-file="' + escape(file) + '" data-line="1">' + html + '</a>';
+    }
+    const location = /^(\\s*)(L(\\d+))/.exec(line);
+    if (location && file) {
+      return location[1] + '<a data-file="' + escape(file) + '" data-line="' + location[3] + '">' + location[2] + '</a>' +
+        html.substring(escape(location[0]).length);
+    }
+    // The definition tool names the file on a File: line
+    const named = /^File: (.+)$/.exec(line);
+    if (named) {
+      file = named[1];
+      return 'File: <a data-file="' + escape(file) + '" data-line="1">' + escape(file) + '</a>';
+    }
+    return html;
+  }).join('\\n');
+}
+
+function show(pane, meta, result, label) {
+  $(meta).textContent = label + ' (' + result.ms + ' ms)';
+  $(pane).className = result.isError ? 'error' : '';
+  $(pane).innerHTML = linkify(result.text);
+}
+
+async function openFile(filePath, line) {
+  const start = Math.max(1, line - 20);
+  const [outline, range] = await Promise.all([
+    call('outline', { filePath, includeDocs: false }),
+    call('read_range', { filePath, startLine: start, endLine: start + 80 }),
+  ]);
+  show('file', 'file-meta', { text: range.text + '\\n\\n' + outline.text, isError: range.isError, ms: Math.max(outline.ms, range.ms) },
+    filePath + ':' + line);
+}
+
+$('search').addEventListener('submit', async (event) => {
+  event.preventDefault();
+  const glob = $('glob').value.trim();
+  const result = await call('search_code', {
+    query: $('pattern').value,
+    regex: $('regex').checked,
+    wholeWord: $('wholeWord').checked,
+    context: $('context').checked,
+    ...(glob ? { glob: [glob] } : {}),
+  });
+  show('results', 'results-meta', result, 'search_code');
+});
+
+$('definition').addEventListener('submit', async (event) => {
+  event.preventDefault();
+  show('file', 'file-meta', await call('definition', { symbolName: $('symbol').value }), 'definition of ' + $('symbol').value);
+});
+
+document.addEventListener('click', (event) => {
+  const link = event.target.closest('a[data-file]');
+  if (link) {
+    openFile(link.dataset.file, parseInt(link.dataset.line, 10));
+  }
+});
+</script>
+</body>
+</html>
+`;
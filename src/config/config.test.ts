@@ -0,0 +1,378 @@
+/**
+ * Tests for configuration files
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  applyConfigEnv,
+  configFromEnv,
+  findDefaultConfig,
+  findWorkspaceConfig,
+  loadConfigFile,
+  mergeConfigs,
+  launchSettingsFor,
+  settingEnvName,
+} from './config';
+import { parseToml, parseYaml, stripComment } from './parse';
+
+const YAML = `
+# Project settings
+workspace: ./project
+lsp:
+  command: typescript-language-server
+  args: [--stdio]
+transport: stdio
+exclude:
+  dirs:
+    - generated
+    - "third party"   # quoted, with a space
+  extensions: [log, .tmp]
+followSymlinks: true
+limits:
+  maxFileSize: 1048576
+  searchTimeoutMs: 5000
+cache:
+  queryCache: false
+  semanticIndexPath: ~/index.bin
+logging:
+  level: debug
+  components: { lsp: WARN, tools: DEBUG }
+`;
+
+const TOML = `
+workspace = "./project"
+transport = "stdio"
+followSymlinks = true
+
+[lsp]
+command = "typescript-language-server"
+args = ["--stdio"]
+
+[exclude]
+dirs = [
+  "generated",
+  "third party", # quoted, with a space
+]
+extensions = ["log", ".tmp"]
+
+[limits]
+maxFileSize = 1_048_576
+searchTimeoutMs = 5000
+
+[cache]
+queryCache = false
+semanticIndexPath = "~/index.bin"
+
+[logging]
+level = "debug"
+components = { lsp = "WARN", tools = "DEBUG" }
+`;
+
+describe('Configuration files', () => {
+  let dir: string;
+
+  beforeEach(() => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'config-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  it('should read the same settings from YAML and TOML', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), YAML);
+    fs.writeFileSync(path.join(dir, 'config.toml'), TOML);
+    const yaml = loadConfigFile(path.join(dir, 'config.yaml'));
+    const toml = loadConfigFile(path.join(dir, 'config.toml'));
+
+    expect(yaml.workspace).toBe(path.join(dir, 'project'));
+    expect(yaml.lspCommand).toBe('typescript-language-server');
+    expect(yaml.lspArgs).toEqual(['--stdio']);
+    expect(yaml.env).toEqual({
+      WORKSPACE_EXCLUDE_DIRS: 'generated,third party',
+      WORKSPACE_EXCLUDE_EXTENSIONS: 'log,.tmp',
+      WORKSPACE_FOLLOW_SYMLINKS: 'true',
+      SEARCH_MAX_FILE_SIZE: '1048576',
+      SEARCH_TIMEOUT_MS: '5000',
+      QUERY_CACHE_ENABLED: 'false',
+      SEMANTIC_INDEX_PATH: path.join(os.homedir(), 'index.bin'),
+      LOG_LEVEL: 'debug',
+      LOG_COMPONENT_LEVELS: 'lsp:WARN,tools:DEBUG',
+    });
+    expect({ ...toml, path: '' }).toEqual({ ...yaml, path: '' });
+  });
+
+  it('should only set environment variables that are unset', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'limits:\n  maxWorkers: 2\n  maxLineLength: 80\n');
+    const env: NodeJS.ProcessEnv = { SEARCH_MAX_WORKERS: '8' };
+    const applied = applyConfigEnv(loadConfigFile(path.join(dir, 'config.yaml')), env);
+    expect(applied).toEqual(['SEARCH_MAX_LINE_LENGTH']);
+    expect(env).toEqual({ SEARCH_MAX_WORKERS: '8', SEARCH_MAX_LINE_LENGTH: '80' });
+  });
+
+  it('should find the default file under XDG_CONFIG_HOME', () => {
+    expect(findDefaultConfig({ XDG_CONFIG_HOME: dir })).toBeUndefined();
+    fs.mkdirSync(path.join(dir, 'grep-for-code'));
+    fs.writeFileSync(path.join(dir, 'grep-for-code', 'config.toml'), '');
+    expect(findDefaultConfig({ XDG_CONFIG_HOME: dir })).toBe(path.join(dir, 'grep-for-code', 'config.toml'));
+  });
+
+  it('should layer a workspace file over the global file', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), YAML);
+    fs.writeFileSync(path.join(dir, '.grepforcode.toml'), [
+      '[search]',
+      'glob = ["**/*.go"]',
+      '[exclude]',
+      'dirs = ["testdata"]',
+    ].join('\n'));
+    const workspaceFile = findWorkspaceConfig(dir);
+    expect(workspaceFile).toBe(path.join(dir, '.grepforcode.toml'));
+
+    const merged = mergeConfigs(loadConfigFile(path.join(dir, 'config.yaml')), loadConfigFile(workspaceFile!, 'workspace'));
+    expect(merged.workspace).toBe(path.join(dir, 'project'));
+    expect(merged.lspCommand).toBe('typescript-language-server');
+    expect(merged.lspArgs).toEqual(['--stdio']);
+    expect(merged.globs).toEqual(['**/*.go']);
+    expect(merged.env.WORKSPACE_EXCLUDE_DIRS).toBe('testdata');
+    expect(merged.env.SEARCH_TIMEOUT_MS).toBe('5000');
+  });
+
+  it('should keep workspace files from redirecting code or output', () => {
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'semantic:\n  url: https://example.com\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace'))
+      .toThrow('"semantic.url" cannot be set in a workspace config');
+    expect(loadConfigFile(path.join(dir, '.grepforcode.yaml')).env.SEMANTIC_EMBEDDING_URL).toBe('https://example.com');
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'metrics:\n  port: 9464\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace'))
+      .toThrow('"metrics.port" cannot be set in a workspace config');
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'remotes:\n  - https://example.com/lib.git#v2\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace'))
+      .toThrow('"remotes" cannot be set in a workspace config');
+    expect(loadConfigFile(path.join(dir, '.grepforcode.yaml')).remotes).toEqual(['https://example.com/lib.git#v2']);
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'tools:\n  disabled: [edit_file, rename_symbol]\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace'))
+      .toThrow('"tools.disabled" cannot be set in a workspace config');
+    expect(loadConfigFile(path.join(dir, '.grepforcode.yaml')).env.TOOLS_DISABLED).toBe('edit_file,rename_symbol');
+    // A cloned repository cannot pick the command that is started when it is opened
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'lsp:\n  command: ./tools/server.sh\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace'))
+      .toThrow('"lsp.command" cannot be set in a workspace config');
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'lsp:\n  args: [--exec, evil]\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace'))
+      .toThrow('"lsp.args" cannot be set in a workspace config');
+  });
+
+  it('should read link rules by kind, from files and the environment', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), [
+      'links:',
+      '  proto-go: true',
+      '  cgo: false',
+      '  openapi:',
+      '    files: [api/spec.yaml]',
+      '    handler: Handle{Name}',
+    ].join('\n'));
+    expect(loadConfigFile(path.join(dir, 'config.yaml')).links).toEqual([
+      { kind: 'proto-go' },
+      { kind: 'openapi', files: ['api/spec.yaml'], handler: 'Handle{Name}' },
+    ]);
+    expect(configFromEnv({ GREPFORCODE_LINKS: 'cgo:true' }).config.links).toEqual([{ kind: 'cgo' }]);
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'links:\n  thrift: true\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('unknown link kind "thrift"');
+  });
+
+  it('should map file name globs to languages', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), "languages: { '*.gotmpl': go-template, Jenkinsfile: groovy }\n");
+    expect(loadConfigFile(path.join(dir, 'config.yaml')).env).toEqual({ LANGUAGE_OVERRIDES: '*.gotmpl:go-template,Jenkinsfile:groovy' });
+  });
+
+  it('should read result templates by client profile', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), [
+      'templates:',
+      '  default: markdown',
+      '  cursor:',
+      '    format: plain',
+      '    fields: [count, symbol]',
+      '    context: 2',
+      '    maxLineLength: 200',
+    ].join('\n'));
+    const config = loadConfigFile(path.join(dir, 'config.yaml'));
+    expect(config.templates).toEqual({
+      default: { format: 'markdown', fields: expect.any(Array), context: 0 },
+      cursor: { format: 'plain', fields: ['count', 'symbol'], context: 2, maxLineLength: 200 },
+    });
+    expect(config.templates!.default.fields).toContain('hash');
+    expect(configFromEnv({ GREPFORCODE_TEMPLATES: 'zed:markdown' }).config.templates!.zed.format).toBe('markdown');
+
+    // A workspace file replaces the profiles it names
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'templates:\n  cursor: markdown\n');
+    const merged = mergeConfigs(config, loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace'));
+    expect(Object.keys(merged.templates!)).toEqual(['default', 'cursor']);
+    expect(merged.templates!.cursor).toEqual({ format: 'markdown', fields: config.templates!.default.fields, context: 0 });
+
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'templates:\n  cursor:\n    fields: [path]\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('unknown field "path" in templates.cursor.fields');
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'templates:\n  cursor: html\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('templates.cursor.format must be plain or markdown');
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'templates:\n  cursor:\n    context: -1\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('templates.cursor.context must be a non-negative whole number');
+  });
+
+  it('should resolve matcher modules against the file that names them', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'matchers:\n  sql: matchers/sql.wasm\n  json: /opt/json.wasm\n');
+    const config = loadConfigFile(path.join(dir, 'config.yaml'));
+    expect(config.matchers).toEqual({ sql: path.join(dir, 'matchers', 'sql.wasm'), json: '/opt/json.wasm' });
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'matchers:\n  sql: tools/sql.wasm\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace')).toThrow('"matchers" cannot be set in a workspace config');
+  });
+
+  it('should read plugin tools and keep them out of workspace files', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), [
+      'plugins:',
+      '  service_owner:',
+      '    command: /opt/catalog/lookup',
+      '    args: [--format, text]',
+      '    description: Owning team of a service',
+      '    parameters:',
+      '      service: { type: string, description: Service name, required: true }',
+      '      verbose: { type: boolean }',
+      '      region: Deployment region',
+      '    timeoutMs: 5000',
+      '    readOnly: true',
+      '  ticket: /opt/bin/ticket',
+    ].join('\n'));
+    expect(loadConfigFile(path.join(dir, 'config.yaml')).plugins).toEqual([
+      {
+        name: 'service_owner',
+        command: '/opt/catalog/lookup',
+        args: ['--format', 'text'],
+        description: 'Owning team of a service',
+        parameters: [
+          { name: 'service', type: 'string', description: 'Service name', required: true },
+          { name: 'verbose', type: 'boolean', required: false },
+          { name: 'region', type: 'string', description: 'Deployment region', required: false },
+        ],
+        timeoutMs: 5000,
+        readOnly: true,
+      },
+      { name: 'ticket', command: '/opt/bin/ticket', args: [], parameters: [] },
+    ]);
+    expect(configFromEnv({ GREPFORCODE_PLUGINS: 'ticket:/opt/bin/ticket' }).config.plugins![0].command).toBe('/opt/bin/ticket');
+
+    fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), 'plugins:\n  ticket: ./run.sh\n');
+    expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace')).toThrow('"plugins" cannot be set in a workspace config');
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'plugins:\n  Lookup: /bin/true\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('plugin tool name "Lookup" must be lowercase');
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'plugins:\n  lookup:\n    parameters: { n: { type: list } }\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('plugins.lookup.parameters.n.type must be string, number, boolean');
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'plugins:\n  lookup:\n    args: [x]\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('plugins.lookup.command is required');
+  });
+
+  it('should layer language server launch settings per workspace root', () => {
+    fs.writeFileSync(path.join(dir, 'config.yaml'), [
+      'lsp:',
+      '  command: rust-analyzer',
+      '  path: [~/.cargo/bin]',
+      '  env: { RUST_LOG: error, CARGO_TARGET_DIR: /tmp/ra }',
+      '  roots:',
+      '    project:',
+      '      cwd: crates/core',
+      '      env: { RUSTUP_TOOLCHAIN: nightly }',
+    ].join('\n'));
+    const global = loadConfigFile(path.join(dir, 'config.yaml'));
+    expect(global.lspPath).toEqual([path.join(os.homedir(), '.cargo/bin')]);
+    expect(Object.keys(global.lspRoots!)).toEqual([path.join(dir, 'project')]);
+
+    expect(launchSettingsFor(global, path.join(dir, 'project'))).toEqual({
+      cwd: 'crates/core',
+      path: global.lspPath,
+      env: { RUST_LOG: 'error', CARGO_TARGET_DIR: '/tmp/ra', RUSTUP_TOOLCHAIN: 'nightly' },
+    });
+    expect(launchSettingsFor(global, path.join(dir, 'other'))).toEqual({
+      cwd: undefined,
+      path: global.lspPath,
+      env: { RUST_LOG: 'error', CARGO_TARGET_DIR: '/tmp/ra' },
+    });
+
+    // A cloned repository cannot change where the server starts or what it finds on PATH
+    for (const setting of ['cwd: ..', 'path: [bin]', 'env: { PATH: ./bin }']) {
+      fs.writeFileSync(path.join(dir, '.grepforcode.yaml'), `lsp:\n  ${setting}\n`);
+      expect(() => loadConfigFile(path.join(dir, '.grepforcode.yaml'), 'workspace')).toThrow('cannot be set in a workspace config');
+    }
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'lsp:\n  roots:\n    project:\n      command: jdtls\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('unknown setting "lsp.roots.project.command"');
+
+
+    const { config } = configFromEnv({ GREPFORCODE_LSP_ENV: 'JAVA_HOME:/opt/jdk-21,GRADLE_USER_HOME:/tmp/g', GREPFORCODE_LSP_CWD: 'backend' });
+    expect(config.lspEnv).toEqual({ JAVA_HOME: '/opt/jdk-21', GRADLE_USER_HOME: '/tmp/g' });
+    expect(config.lspCwd).toBe('backend');
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'lsp:\n  env: [A]\n');
+    expect(() => loadConfigFile(path.join(dir, 'config.yaml'))).toThrow('lsp.env must be a mapping');
+  });
+
+  it('should read every setting from GREPFORCODE_* variables', () => {
+    expect(settingEnvName('limits.maxFileSize')).toBe('GREPFORCODE_LIMITS_MAX_FILE_SIZE');
+    expect(settingEnvName('followSymlinks')).toBe('GREPFORCODE_FOLLOW_SYMLINKS');
+
+    const { config, unknown } = configFromEnv({
+      GREPFORCODE_WORKSPACE: dir,
+      GREPFORCODE_LSP_COMMAND: 'gopls',
+      GREPFORCODE_LSP_ARGS: 'serve,-rpc.trace',
+      GREPFORCODE_SEARCH_GLOB: '**/*.{ts,tsx},!**/*.d.ts',
+      GREPFORCODE_LIMITS_MAX_WORKERS: '4',
+      GREPFORCODE_LOGGING_COMPONENTS: 'lsp:DEBUG,tools:WARN',
+      GREPFORCODE_CONFIG: '/etc/grep-for-code.yaml',
+      GREPFORCODE_MAX_WORKERS: '4',
+      SEARCH_MAX_WORKERS: '2',
+    });
+    expect(config.workspace).toBe(dir);
+    expect(config.lspCommand).toBe('gopls');
+    expect(config.lspArgs).toEqual(['serve', '-rpc.trace']);
+    expect(config.globs).toEqual(['**/*.{ts,tsx}', '!**/*.d.ts']);
+    expect(config.env).toEqual({ SEARCH_MAX_WORKERS: '4', LOG_COMPONENT_LEVELS: 'lsp:DEBUG,tools:WARN' });
+    expect(unknown).toEqual(['GREPFORCODE_MAX_WORKERS']);
+  });
+
+  it('should let GREPFORCODE_* variables replace the variables they stand for', () => {
+    const env: NodeJS.ProcessEnv = { SEARCH_TIMEOUT_MS: '1000', GREPFORCODE_LIMITS_SEARCH_TIMEOUT_MS: '2000' };
+    applyConfigEnv(configFromEnv(env).config, env, true);
+    fs.writeFileSync(path.join(dir, 'config.yaml'), 'limits:\n  searchTimeoutMs: 3000\n');
+    applyConfigEnv(loadConfigFile(path.join(dir, 'config.yaml')), env);
+    expect(env.SEARCH_TIMEOUT_MS).toBe('2000');
+  });
+
+  it('should reject unknown settings and transports', () => {
+    fs.writeFileSync(path.join(dir, 'a.yaml'), 'limits:\n  maxFiles: 3\n');
+    fs.writeFileSync(path.join(dir, 'b.yaml'), 'transport: http\n');
+    expect(() => loadConfigFile(path.join(dir, 'a.yaml'))).toThrow('unknown setting "limits.maxFiles"');
+    expect(() => loadConfigFile(path.join(dir, 'b.yaml'))).toThrow('unsupported transport "http"');
+    expect(() => loadConfigFile(path.join(dir, 'missing.yaml'))).toThrow('cannot read config file');
+  });
+});
+
+describe('Configuration parsing', () => {
+  it('should parse nested YAML lists of mappings', () => {
+    expect(parseYaml('servers:\n- name: a\n  args: []\n- name: b\n')).toEqual({
+      servers: [{ name: 'a', args: [] }, { name: 'b' }],
+    });
+  });
+
+  it('should report the line of a YAML error', () => {
+    expect(() => parseYaml('a: 1\n  b: 2\n')).toThrow('line 2: unexpected indentation');
+    expect(() => parseYaml('a: &anchor 1\n')).toThrow('line 1: unsupported YAML feature');
+    expect(() => parseYaml('a: 1\na: 2\n')).toThrow('line 2: duplicate key "a"');
+  });
+
+  it('should parse dotted TOML keys and reject arrays of tables', () => {
+    expect(parseToml('a.b = 1\n[c]\nd.e = "x"\n')).toEqual({ a: { b: 1 }, c: { d: { e: 'x' } } });
+    expect(() => parseToml('[[servers]]\n')).toThrow('line 1: arrays of tables are not supported');
+  });
+
+  it('should strip comments outside quotes, opening quotes only where quoteAfter allows', () => {
+    expect(stripComment('a: "x # y" # note')).toBe('a: "x # y" ');
+    expect(stripComment("a: it's # note")).toBe("a: it's # note");
+    expect(stripComment("a: it's # note", /[\s:]/)).toBe("a: it's ");
+  });
+});
@@ -0,0 +1,812 @@
+/**
+ * Configuration file
+ * Settings are read from ~/.config/grep-for-code/config.yaml (or .yml/.toml)
+ * or from the file given with --config, and a .grepforcode.yaml at the
+ * workspace root overrides them for that workspace. Every setting can also
+ * be given as a GREPFORCODE_* environment variable. Settings map to the
+ * environment variables that control them; file settings are applied only
+ * when those are unset, so the precedence is command line flags, then
+ * environment variables, then the workspace file, then the global file
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { ConfigMap, ConfigValue, parseToml, parseYaml } from './parse.js';
+
+/**
+ * Settings loaded from a configuration file
+ */
+export interface FileConfig {
+  // Path the settings were read from
+  path: string;
+  workspace?: string;
+  lspCommand?: string;
+  lspArgs?: string[];
+  // Working directory of the language server, relative to the workspace
+  lspCwd?: string;
+  // Directories put first on the language server's PATH
+  lspPath?: string[];
+  // Environment variables set for the language server
+  lspEnv?: Record<string, string>;
+  // Launch settings for particular workspaces, by absolute workspace root
+  lspRoots?: Record<string, LspLaunchSettings>;
+  // Default globs for search_code
+  globs?: string[];
+  // Remote repositories to clone, as "url" or "url#ref"
+  remotes?: string[];
+  // Rules linking symbols across language boundaries, for references
+  links?: LinkRule[];
+  // How search results are rendered, by client profile ("default" for the rest)
+  templates?: Record<string, ResultTemplate>;
+  // Tools served by running a command, by tool name
+  plugins?: PluginTool[];
+  // WebAssembly modules search_code can filter matches with, by matcher name
+  matchers?: Record<string, string>;
+  // Environment variables derived from the remaining settings
+  env: Record<string, string>;
+}
+
+/**
+ * Where and how the language server is started, from lsp.cwd, lsp.path, and lsp.env
+ */
+export interface LspLaunchSettings {
+  cwd?: string;
+  path?: string[];
+  env?: Record<string, string>;
+}
+
+/**
+ * Kinds of generated-code seams references can hop across
+ * - proto-go: .proto messages, services, and fields to the Go names protoc-gen-go gives them
+ * - cgo: C.name in Go files to the C declarations in headers and sources
+ * - openapi: operationIds in OpenAPI specs to the handler functions implementing them
+ */
+export const LINK_KINDS = ['proto-go', 'cgo', 'openapi'] as const;
+
+export type LinkKind = typeof LINK_KINDS[number];
+
+/**
+ * A link rule from the links setting
+ */
+export interface LinkRule {
+  kind: LinkKind;
+  // Spec files, for openapi (default: **/openapi*.{yaml,yml,json} and **/swagger*.{yaml,yml,json})
+  files?: string[];
+  // Handler name for an operationId, "{Name}" and "{name}" standing for it capitalized or not, for openapi (default: "{Name}")
+  handler?: string;
+}
+
+/**
+ * Fields of a search result a template can show; the path, line, and text always are
+ * - column: the match column after the line number
+ * - hash: the file's content hash
+ * - copies: files with identical content whose matches were left out
+ * - git: the file's uncommitted changes, when gitStatus is requested
+ * - module: the file's package or module, when context is requested
+ * - directory: the directory summary, when directoryDocs is requested
+ * - count: the number of matches in the file
+ * - section: the heading chain of documentation matches
+ * - symbol: the enclosing symbol, when context is requested
+ * - ast: the syntax node path of each match, when astPath is requested
+ * - change: the last commit touching each match line, when lastChange is requested
+ * - coverage: whether tests ran each match line, when a coverage profile is given
+ */
+export const RESULT_FIELDS = ['column', 'hash', 'copies', 'git', 'module', 'directory', 'count', 'section', 'symbol', 'ast', 'change', 'coverage'] as const;
+
+export type ResultField = typeof RESULT_FIELDS[number];
+
+/**
+ * How search results are rendered for a client profile, from the templates setting
+ */
+export interface ResultTemplate {
+  // plain: sections of "L3:C5: text" lines; markdown: a heading per file and fenced code
+  format: 'plain' | 'markdown';
+  // Fields shown (default: all)
+  fields: ResultField[];
+  // Lines shown before and after each match (default: 0)
+  context: number;
+  // Clip longer lines to this many characters (default: limits.maxLineLength)
+  maxLineLength?: number;
+}
+
+/**
+ * Parameter types a plugin tool can take
+ */
+export const PLUGIN_PARAMETER_TYPES = ['string', 'number', 'boolean'] as const;
+
+/**
+ * A parameter of a plugin tool
+ */
+export interface PluginParameter {
+  name: string;
+  type: typeof PLUGIN_PARAMETER_TYPES[number];
+  description?: string;
+  required: boolean;
+}
+
+/**
+ * A tool served by running a command, from the plugins setting
+ */
+export interface PluginTool {
+  name: string;
+  command: string;
+  args: string[];
+  // Shown to the client as the tool's description (default: names the command)
+  description?: string;
+  parameters: PluginParameter[];
+  // Time the command gets before it is stopped (default: 30000)
+  timeoutMs?: number;
+  // Declared not to write anything, so read-only mode keeps offering it (default: false)
+  readOnly?: boolean;
+}
+
+type Format = 'list' | 'map' | 'path' | 'scalar';
+
+/**
+ * Configuration keys and the environment variables they set
+ */
+const ENV_KEYS: Record<string, { env: string; format: Format }> = {
+  'exclude.dirs': { env: 'WORKSPACE_EXCLUDE_DIRS', format: 'list' },
+  'exclude.extensions': { env: 'WORKSPACE_EXCLUDE_EXTENSIONS', format: 'list' },
+  'exclude.files': { env: 'WORKSPACE_EXCLUDE_FILES', format: 'list' },
+  'fixtures': { env: 'WORKSPACE_FIXTURE_PATTERNS', format: 'list' },
+  'flags': { env: 'FEATURE_FLAG_PATTERNS', format: 'list' },
+  'followSymlinks': { env: 'WORKSPACE_FOLLOW_SYMLINKS', format: 'scalar' },
+  'roots.allowed': { env: 'WORKSPACE_ROOTS_ALLOWED', format: 'list' },
+  'limits.maxResults': { env: 'SEARCH_MAX_RESULTS', format: 'scalar' },
+  'limits.maxFileSize': { env: 'SEARCH_MAX_FILE_SIZE', format: 'scalar' },
+  'limits.maxLineLength': { env: 'SEARCH_MAX_LINE_LENGTH', format: 'scalar' },
+  'limits.searchTimeoutMs': { env: 'SEARCH_TIMEOUT_MS', format: 'scalar' },
+  'limits.maxWorkers': { env: 'SEARCH_MAX_WORKERS', format: 'scalar' },
+  'limits.memoryLimitMb': { env: 'SEARCH_MEMORY_LIMIT_MB', format: 'scalar' },
+  'limits.contextLines': { env: 'LSP_CONTEXT_LINES', format: 'scalar' },
+  'limits.archiveMaxDepth': { env: 'SEARCH_ARCHIVE_MAX_DEPTH', format: 'scalar' },
+  'limits.archiveMaxSizeMb': { env: 'SEARCH_ARCHIVE_MAX_SIZE_MB', format: 'scalar' },
+  'limits.maxConcurrentCalls': { env: 'TOOL_MAX_CONCURRENT_CALLS', format: 'scalar' },
+  'limits.lspMaxInFlight': { env: 'LSP_MAX_IN_FLIGHT', format: 'scalar' },
+  'limits.lspMaxSlowInFlight': { env: 'LSP_MAX_SLOW_IN_FLIGHT', format: 'scalar' },
+  'limits.lspIdleTimeoutMinutes': { env: 'LSP_IDLE_TIMEOUT_MINUTES', format: 'scalar' },
+  'limits.shutdownTimeoutMs': { env: 'SHUTDOWN_TIMEOUT_MS', format: 'scalar' },
+  'limits.callsPerMinute': { env: 'TOOL_CALLS_PER_MINUTE', format: 'scalar' },
+  'limits.queryMaxFiles': { env: 'QUERY_MAX_FILES', format: 'scalar' },
+  'limits.queryMaxReadMb': { env: 'QUERY_MAX_READ_MB', format: 'scalar' },
+  'limits.queryMaxLspCalls': { env: 'QUERY_MAX_LSP_CALLS', format: 'scalar' },
+  'limits.sessionMaxFiles': { env: 'SESSION_MAX_FILES', format: 'scalar' },
+  'limits.sessionMaxReadMb': { env: 'SESSION_MAX_READ_MB', format: 'scalar' },
+  'limits.sessionMaxLspCalls': { env: 'SESSION_MAX_LSP_CALLS', format: 'scalar' },
+  'cache.lsp': { env: 'CACHE_ENABLED', format: 'scalar' },
+  'cache.lspMaxSymbols': { env: 'CACHE_MAX_SYMBOLS', format: 'scalar' },
+  'cache.lspMaxLocations': { env: 'CACHE_MAX_LOCATIONS', format: 'scalar' },
+  'cache.lspTtlSeconds': { env: 'CACHE_TTL_SECONDS', format: 'scalar' },
+  'cache.lspWarmup': { env: 'CACHE_WARMUP', format: 'scalar' },
+  'cache.lspWarmupFiles': { env: 'LSP_WARMUP_FILES', format: 'scalar' },
+  'cache.lspWarmupConcurrency': { env: 'LSP_WARMUP_CONCURRENCY', format: 'scalar' },
+  'cache.queryCache': { env: 'QUERY_CACHE_ENABLED', format: 'scalar' },
+  'cache.queryCacheEntries': { env: 'QUERY_CACHE_MAX_ENTRIES', format: 'scalar' },
+  'cache.persistSemanticIndex': { env: 'SEMANTIC_INDEX_PERSIST', format: 'scalar' },
+  'cache.semanticIndexPath': { env: 'SEMANTIC_INDEX_PATH', format: 'path' },
+  'cache.remoteDir': { env: 'REMOTE_CACHE_DIR', format: 'path' },
+  'semantic.enabled': { env: 'SEMANTIC_SEARCH_ENABLED', format: 'scalar' },
+  'semantic.chunkMaxLines': { env: 'SEMANTIC_CHUNK_MAX_LINES', format: 'scalar' },
+  'semantic.provider': { env: 'SEMANTIC_EMBEDDING_PROVIDER', format: 'scalar' },
+  'semantic.model': { env: 'SEMANTIC_EMBEDDING_MODEL', format: 'scalar' },
+  'semantic.url': { env: 'SEMANTIC_EMBEDDING_URL', format: 'scalar' },
+  'semantic.apiKey': { env: 'SEMANTIC_EMBEDDING_API_KEY', format: 'scalar' },
+  'semantic.onnxModelPath': { env: 'SEMANTIC_ONNX_MODEL_PATH', format: 'path' },
+  'semantic.dimensions': { env: 'SEMANTIC_EMBEDDING_DIMENSIONS', format: 'scalar' },
+  'semantic.timeoutMs': { env: 'SEMANTIC_EMBEDDING_TIMEOUT_MS', format: 'scalar' },
+  'logging.level': { env: 'LOG_LEVEL', format: 'scalar' },
+  'logging.file': { env: 'LOG_FILE', format: 'path' },
+  'logging.components': { env: 'LOG_COMPONENT_LEVELS', format: 'map' },
+  'logging.format': { env: 'LOG_FORMAT', format: 'scalar' },
+  'logging.maxSizeMb': { env: 'LOG_FILE_MAX_SIZE_MB', format: 'scalar' },
+  'logging.maxFiles': { env: 'LOG_FILE_MAX_FILES', format: 'scalar' },
+  'logging.auditFile': { env: 'AUDIT_LOG_FILE', format: 'path' },
+  'metrics.port': { env: 'METRICS_PORT', format: 'scalar' },
+  'metrics.host': { env: 'METRICS_HOST', format: 'scalar' },
+  'security.redactSecrets': { env: 'REDACT_SECRETS', format: 'scalar' },
+  'tools.enabled': { env: 'TOOLS_ENABLED', format: 'list' },
+  'tools.disabled': { env: 'TOOLS_DISABLED', format: 'list' },
+  'languages': { env: 'LANGUAGE_OVERRIDES', format: 'map' },
+};
+
+/**
+ * Keys handled directly rather than through the environment
+ */
+const DIRECT_KEYS = new Set([
+  'workspace', 'lsp.command', 'lsp.args', 'lsp.cwd', 'lsp.path', 'lsp.env', 'lsp.roots', 'search.glob', 'remotes', 'transport', 'links',
+  'templates', 'plugins', 'matchers',
+]);
+
+/**
+ * Settings whose values are mappings, kept whole rather than flattened
+ */
+function isMapSetting(key: string): boolean {
+  return key === 'lsp.env' || key === 'lsp.roots' || key === 'links' || key === 'templates' || key === 'plugins' || key === 'matchers' || ENV_KEYS[key]?.format === 'map';
+}
+
+/**
+ * Keys a workspace file may not set
+ * Workspace files are committed with the code, so they cannot redirect
+ * where code is sent, where files are written, which ports are opened,
+ * what is fetched from the network, whether secrets are redacted, which
+ * tools are offered, which commands and modules they run, or which language server is
+ * started and with what: opening a cloned repository must not run a command
+ * it names, nor put its own directories on the server's PATH
+ */
+const GLOBAL_ONLY_KEYS = new Set([
+  'workspace', 'logging.file', 'logging.auditFile', 'cache.semanticIndexPath', 'cache.remoteDir', 'semantic.url', 'semantic.apiKey',
+  'metrics.port', 'metrics.host', 'remotes', 'security.redactSecrets',
+  'tools.enabled', 'tools.disabled', 'plugins', 'matchers', 'roots.allowed', 'lsp.command', 'lsp.args', 'lsp.cwd', 'lsp.path', 'lsp.env', 'lsp.roots',
+]);
+
+/**
+ * Transports the server can listen on
+ */
+const TRANSPORTS = ['stdio'];
+
+/**
+ * Prefix of the environment variable form of each setting
+ */
+const ENV_PREFIX = 'GREPFORCODE_';
+
+/**
+ * Environment variable naming the configuration file, like --config
+ */
+export const CONFIG_PATH_ENV = 'GREPFORCODE_CONFIG';
+
+/**
+ * File names of the global and workspace configuration files, in lookup order
+ */
+export const CONFIG_NAMES = ['config.yaml', 'config.yml', 'config.toml'];
+export const WORKSPACE_CONFIG_NAMES = ['.grepforcode.yaml', '.grepforcode.yml', '.grepforcode.toml'];
+
+/**
+ * Where a configuration file applies
+ */
+export type ConfigScope = 'global' | 'workspace';
+
+/**
+ * Directory holding the default configuration file
+ */
+export function configDir(env: NodeJS.ProcessEnv = process.env): string {
+  return path.join(env.XDG_CONFIG_HOME || path.join(os.homedir(), '.config'), 'grep-for-code');
+}
+
+/**
+ * Path of the default configuration file, or undefined when there is none
+ */
+export function findDefaultConfig(env: NodeJS.ProcessEnv = process.env): string | undefined {
+  const dir = configDir(env);
+  return CONFIG_NAMES.map((name) => path.join(dir, name)).find((file) => fs.existsSync(file));
+}
+
+/**
+ * Path of the workspace configuration file, or undefined when there is none
+ */
+export function findWorkspaceConfig(workspaceDir: string): string | undefined {
+  return WORKSPACE_CONFIG_NAMES.map((name) => path.join(workspaceDir, name)).find((file) => fs.existsSync(file));
+}
+
+/**
+ * Expand a leading ~ to the home directory
+ */
+function expandHome(value: string): string {
+  return value === '~' || value.startsWith('~/') ? path.join(os.homedir(), value.substring(1)) : value;
+}
+
+function isMap(value: ConfigValue): value is ConfigMap {
+  return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
+
+/**
+ * Flatten nested mappings into dotted keys
+ * Lists and the values of map-valued settings are kept whole
+ */
+function flatten(map: ConfigMap, prefix = '', out: Map<string, ConfigValue> = new Map()): Map<string, ConfigValue> {
+  for (const [key, value] of Object.entries(map)) {
+    const name = prefix ? `${prefix}.${key}` : key;
+    if (isMap(value) && !isMapSetting(name)) {
+      flatten(value, name, out);
+    } else {
+      out.set(name, value);
+    }
+  }
+  return out;
+}
+
+/**
+ * Render a scalar setting as an environment variable value
+ */
+function scalarText(key: string, value: ConfigValue): string {
+  if (typeof value === 'string' || typeof value === 'number' || typeof value === 'boolean') {
+    return String(value);
+  }
+  throw new Error(`${key} must be a string, number, or boolean`);
+}
+
+/**
+ * Render a setting in the format its environment variable expects
+ */
+function envText(key: string, value: ConfigValue, format: Format): string {
+  switch (format) {
+    case 'list': {
+      const items = Array.isArray(value) ? value : [value];
+      return items.map((item) => scalarText(key, item)).join(',');
+    }
+    case 'map':
+      if (!isMap(value)) {
+        throw new Error(`${key} must be a mapping`);
+      }
+      return Object.entries(value).map(([name, item]) => `${name}:${scalarText(key, item)}`).join(',');
+    case 'path':
+      return expandHome(scalarText(key, value));
+    default:
+      return scalarText(key, value);
+  }
+}
+
+/**
+ * Read the links setting: a mapping from link kind to true, false, or the rule's settings
+ */
+function linkRules(value: ConfigValue): LinkRule[] {
+  if (!isMap(value)) {
+    throw new Error('links must be a mapping');
+  }
+  const rules: LinkRule[] = [];
+  for (const [kind, settings] of Object.entries(value)) {
+    if (!(LINK_KINDS as readonly string[]).includes(kind)) {
+      throw new Error(`unknown link kind "${kind}" (supported: ${LINK_KINDS.join(', ')})`);
+    }
+    if (settings === false || settings === 'false' || settings === null) {
+      continue;
+    }
+    const rule: LinkRule = { kind: kind as LinkKind };
+    if (isMap(settings)) {
+      for (const [key, item] of Object.entries(settings)) {
+        if (key === 'files') {
+          rule.files = (Array.isArray(item) ? item : [item]).map((glob) => scalarText('links.files', glob));
+        } else if (key === 'handler') {
+          rule.handler = scalarText('links.handler', item);
+        } else {
+          throw new Error(`unknown setting "links.${kind}.${key}"`);
+        }
+      }
+    } else if (settings !== true && settings !== 'true') {
+      throw new Error(`links.${kind} must be true, false, or a mapping`);
+    }
+    rules.push(rule);
+  }
+  return rules;
+}
+
+/**
+ * Read the cwd, path, and env launch settings under a prefix
+ */
+function launchSettings(prefix: string, get: (key: string) => ConfigValue | undefined): LspLaunchSettings {
+  const settings: LspLaunchSettings = {};
+  const cwd = get('cwd');
+  if (cwd != null) {
+    settings.cwd = expandHome(scalarText(`${prefix}.cwd`, cwd));
+  }
+  const searchPath = get('path');
+  if (searchPath != null) {
+    settings.path = (Array.isArray(searchPath) ? searchPath : [searchPath]).map((dir) => expandHome(scalarText(`${prefix}.path`, dir)));
+  }
+  const env = get('env');
+  if (env != null) {
+    if (!isMap(env)) {
+      throw new Error(`${prefix}.env must be a mapping`);
+    }
+    settings.env = Object.fromEntries(Object.entries(env).map(([name, value]) => [name, scalarText(`${prefix}.env`, value)]));
+  }
+  return settings;
+}
+
+/**
+ * Read the lsp.roots setting: a mapping from workspace root to its launch settings
+ */
+function lspRootSettings(value: ConfigValue): Record<string, LspLaunchSettings> {
+  if (!isMap(value)) {
+    throw new Error('lsp.roots must be a mapping');
+  }
+  const roots: Record<string, LspLaunchSettings> = {};
+  for (const [root, settings] of Object.entries(value)) {
+    const prefix = `lsp.roots.${root}`;
+    if (!isMap(settings)) {
+      throw new Error(`${prefix} must be a mapping`);
+    }
+    const unknown = Object.keys(settings).find((key) => key !== 'cwd' && key !== 'path' && key !== 'env');
+    if (unknown) {
+      throw new Error(`unknown setting "${prefix}.${unknown}"`);
+    }
+    roots[expandHome(root)] = launchSettings(prefix, (key) => settings[key]);
+  }
+  return roots;
+}
+
+/**
+ * Launch settings for a workspace: its lsp.roots entry replaces each global setting it gives, and adds to lsp.env
+ */
+export function launchSettingsFor(config: FileConfig | undefined, workspaceDir: string): LspLaunchSettings {
+  const root = Object.entries(config?.lspRoots ?? {}).find(([dir]) => path.resolve(dir) === path.resolve(workspaceDir))?.[1];
+  const env = config?.lspEnv || root?.env ? { ...config?.lspEnv, ...root?.env } : undefined;
+  return { cwd: root?.cwd ?? config?.lspCwd, path: root?.path ?? config?.lspPath, env };
+}
+
+/**
+ * Read a non-negative whole number setting
+ */
+function countSetting(key: string, value: ConfigValue): number {
+  const count = Number(scalarText(key, value));
+  if (!Number.isInteger(count) || count < 0) {
+    throw new Error(`${key} must be a non-negative whole number`);
+  }
+  return count;
+}
+
+/**
+ * Read the templates setting: a mapping from client profile to a format, or to the template's settings
+ */
+function resultTemplates(value: ConfigValue): Record<string, ResultTemplate> {
+  if (!isMap(value)) {
+    throw new Error('templates must be a mapping');
+  }
+  const templates: Record<string, ResultTemplate> = {};
+  for (const [profile, settings] of Object.entries(value)) {
+    const template: ResultTemplate = { format: 'plain', fields: [...RESULT_FIELDS], context: 0 };
+    let entries: Array<[string, ConfigValue]>;
+    if (isMap(settings)) {
+      entries = Object.entries(settings);
+    } else if (typeof settings === 'string') {
+      entries = [['format', settings]];
+    } else {
+      throw new Error(`templates.${profile} must be a format or a mapping`);
+    }
+    for (const [key, item] of entries) {
+      const name = `templates.${profile}.${key}`;
+      if (key === 'format') {
+        const format = scalarText(name, item);
+        if (format !== 'plain' && format !== 'markdown') {
+          throw new Error(`${name} must be plain or markdown`);
+        }
+        template.format = format;
+      } else if (key === 'fields') {
+        template.fields = (Array.isArray(item) ? item : [item]).map((field) => {
+          const text = scalarText(name, field);
+          if (!(RESULT_FIELDS as readonly string[]).includes(text)) {
+            throw new Error(`unknown field "${text}" in ${name} (supported: ${RESULT_FIELDS.join(', ')})`);
+          }
+          return text as ResultField;
+        });
+      } else if (key === 'context') {
+        template.context = countSetting(name, item);
+      } else if (key === 'maxLineLength') {
+        template.maxLineLength = countSetting(name, item);
+      } else {
+        throw new Error(`unknown setting "${name}"`);
+      }
+    }
+    templates[profile] = template;
+  }
+  return templates;
+}
+
+/**
+ * Read a plugin tool's parameters: a mapping from name to a description, or to the parameter's settings
+ */
+function pluginParameters(tool: string, value: ConfigValue): PluginParameter[] {
+  if (!isMap(value)) {
+    throw new Error(`plugins.${tool}.parameters must be a mapping`);
+  }
+  return Object.entries(value).map(([name, settings]) => {
+    const parameter: PluginParameter = { name, type: 'string', required: false };
+    if (!isMap(settings)) {
+      parameter.description = scalarText(`plugins.${tool}.parameters.${name}`, settings);
+      return parameter;
+    }
+    for (const [key, item] of Object.entries(settings)) {
+      const setting = `plugins.${tool}.parameters.${name}.${key}`;
+      if (key === 'type') {
+        const type = scalarText(setting, item);
+        if (!(PLUGIN_PARAMETER_TYPES as readonly string[]).includes(type)) {
+          throw new Error(`${setting} must be ${PLUGIN_PARAMETER_TYPES.join(', ')}`);
+        }
+        parameter.type = type as PluginParameter['type'];
+      } else if (key === 'description') {
+        parameter.description = scalarText(setting, item);
+      } else if (key === 'required') {
+        parameter.required = item === true || item === 'true';
+      } else {
+        throw new Error(`unknown setting "${setting}"`);
+      }
+    }
+    return parameter;
+  });
+}
+
+/**
+ * Read the plugins setting: a mapping from tool name to a command, or to the tool's settings
+ */
+function pluginTools(value: ConfigValue): PluginTool[] {
+  if (!isMap(value)) {
+    throw new Error('plugins must be a mapping');
+  }
+  const tools: PluginTool[] = [];
+  for (const [name, settings] of Object.entries(value)) {
+    if (!/^[a-z][a-z0-9_]*$/.test(name)) {
+      throw new Error(`plugin tool name "${name}" must be lowercase letters, digits, and underscores`);
+    }
+    const tool: PluginTool = { name, command: '', args: [], parameters: [] };
+    if (typeof settings === 'string') {
+      tool.command = expandHome(settings);
+    } else if (isMap(settings)) {
+      for (const [key, item] of Object.entries(settings)) {
+        const setting = `plugins.${name}.${key}`;
+        if (key === 'command') {
+          tool.command = expandHome(scalarText(setting, item));
+        } else if (key === 'args') {
+          tool.args = (Array.isArray(item) ? item : [item]).map((arg) => scalarText(setting, arg));
+        } else if (key === 'description') {
+          tool.description = scalarText(setting, item);
+        } else if (key === 'parameters') {
+          tool.parameters = pluginParameters(name, item);
+        } else if (key === 'timeoutMs') {
+          tool.timeoutMs = countSetting(setting, item);
+        } else if (key === 'readOnly') {
+          tool.readOnly = item === true || item === 'true';
+        } else {
+          throw new Error(`unknown setting "${setting}"`);
+        }
+      }
+    } else {
+      throw new Error(`plugins.${name} must be a command or a mapping`);
+    }
+    if (!tool.command) {
+      throw new Error(`plugins.${name}.command is required`);
+    }
+    tools.push(tool);
+  }
+  return tools;
+}
+
+/**
+ * Interpret parsed configuration content
+ */
+export function interpretConfig(map: ConfigMap, filePath: string, scope: ConfigScope = 'global'): FileConfig {
+  const config: FileConfig = { path: filePath, env: {} };
+  const settings = flatten(map);
+  for (const [key, value] of settings) {
+    if (value === null) {
+      continue; // An empty key leaves the default in place
+    }
+    if (scope === 'workspace' && GLOBAL_ONLY_KEYS.has(key)) {
+      throw new Error(`"${key}" cannot be set in a workspace config`);
+    }
+    const mapping = ENV_KEYS[key];
+    if (mapping) {
+      config.env[mapping.env] = envText(key, value, mapping.format);
+    } else if (!DIRECT_KEYS.has(key)) {
+      throw new Error(`unknown setting "${key}"`);
+    }
+  }
+
+  const workspace = settings.get('workspace');
+  if (workspace != null) {
+    config.workspace = expandHome(scalarText('workspace', workspace));
+  }
+  const command = settings.get('lsp.command');
+  if (command != null) {
+    config.lspCommand = scalarText('lsp.command', command);
+  }
+  const args = settings.get('lsp.args');
+  if (args != null) {
+    if (!Array.isArray(args)) {
+      throw new Error('lsp.args must be a list');
+    }
+    config.lspArgs = args.map((arg) => scalarText('lsp.args', arg));
+  }
+  const { cwd, path: lspPath, env: lspEnv } = launchSettings('lsp', (key) => settings.get(`lsp.${key}`));
+  if (cwd != null) {
+    config.lspCwd = cwd;
+  }
+  if (lspPath != null) {
+    config.lspPath = lspPath;
+  }
+  if (lspEnv != null) {
+    config.lspEnv = lspEnv;
+  }
+  const roots = settings.get('lsp.roots');
+  if (roots != null) {
+    config.lspRoots = lspRootSettings(roots);
+  }
+  const globs = settings.get('search.glob');
+  if (globs != null) {
+    config.globs = (Array.isArray(globs) ? globs : [globs]).map((glob) => scalarText('search.glob', glob));
+  }
+  const remotes = settings.get('remotes');
+  if (remotes != null) {
+    config.remotes = (Array.isArray(remotes) ? remotes : [remotes]).map((remote) => scalarText('remotes', remote));
+  }
+  const links = settings.get('links');
+  if (links != null) {
+    config.links = linkRules(links);
+  }
+  const templates = settings.get('templates');
+  if (templates != null) {
+    config.templates = resultTemplates(templates);
+  }
+  const plugins = settings.get('plugins');
+  if (plugins != null) {
+    config.plugins = pluginTools(plugins);
+  }
+  const matchers = settings.get('matchers');
+  if (matchers != null) {
+    if (!isMap(matchers)) {
+      throw new Error('matchers must be a mapping');
+    }
+    config.matchers = Object.fromEntries(Object.entries(matchers)
+      .filter(([, modulePath]) => modulePath !== null)
+      .map(([name, modulePath]) => [name, expandHome(scalarText(`matchers.${name}`, modulePath))]));
+  }
+  const transport = settings.get('transport');
+  if (transport != null && !TRANSPORTS.includes(scalarText('transport', transport))) {
+    throw new Error(`unsupported transport "${transport}" (supported: ${TRANSPORTS.join(', ')})`);
+  }
+  return config;
+}
+
+/**
+ * Load a configuration file, choosing the syntax by extension
+ */
+export function loadConfigFile(filePath: string, scope: ConfigScope = 'global'): FileConfig {
+  const resolved = path.resolve(expandHome(filePath));
+  let content: string;
+  try {
+    content = fs.readFileSync(resolved, 'utf8');
+  } catch (err) {
+    throw new Error(`cannot read config file ${resolved}: ${(err as Error).message}`);
+  }
+  try {
+    const map = path.extname(resolved) === '.toml' ? parseToml(content) : parseYaml(content);
+    const config = interpretConfig(map, resolved, scope);
+    // Relative workspace, workspace root, and matcher paths are relative to the file
+    if (config.workspace) {
+      config.workspace = path.resolve(path.dirname(resolved), config.workspace);
+    }
+    if (config.lspRoots) {
+      config.lspRoots = Object.fromEntries(Object.entries(config.lspRoots)
+        .map(([root, settings]) => [path.resolve(path.dirname(resolved), root), settings]));
+    }
+    for (const [name, modulePath] of Object.entries(config.matchers ?? {})) {
+      config.matchers![name] = path.resolve(path.dirname(resolved), modulePath);
+    }
+    return config;
+  } catch (err) {
+    throw new Error(`invalid config file ${resolved}: ${(err as Error).message}`);
+  }
+}
+
+/**
+ * Layer workspace settings over global ones
+ */
+export function mergeConfigs(base: FileConfig | undefined, override: FileConfig): FileConfig {
+  if (!base) {
+    return override;
+  }
+  return {
+    path: override.path,
+    workspace: base.workspace,
+    lspCommand: base.lspCommand,
+    lspArgs: base.lspArgs,
+    lspCwd: base.lspCwd,
+    lspPath: base.lspPath,
+    lspEnv: base.lspEnv,
+    lspRoots: base.lspRoots,
+    globs: override.globs ?? base.globs,
+    remotes: base.remotes,
+    links: override.links ?? base.links,
+    // Profiles are replaced one by one
+    templates: base.templates || override.templates ? { ...base.templates, ...override.templates } : undefined,
+    plugins: base.plugins,
+    matchers: base.matchers,
+    env: { ...base.env, ...override.env },
+  };
+}
+
+/**
+ * Environment variable form of a setting
+ * For example, limits.maxFileSize is GREPFORCODE_LIMITS_MAX_FILE_SIZE
+ */
+export function settingEnvName(key: string): string {
+  return ENV_PREFIX + key.replace(/([a-z0-9])([A-Z])/g, '$1_$2').replace(/\./g, '_').toUpperCase();
+}
+
+/**
+ * Setting key that an environment variable stands for, such as limits.maxFileSize for SEARCH_MAX_FILE_SIZE
+ */
+export function settingForEnv(name: string): string | undefined {
+  return Object.keys(ENV_KEYS).find((key) => ENV_KEYS[key].env === name);
+}
+
+/**
+ * Split a comma-separated list, keeping commas inside braces such as "{ts,tsx}"
+ */
+function splitList(value: string): string[] {
+  const items: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let i = 0; i <= value.length; i++) {
+    const c = value[i];
+    if (c === '{') {
+      depth++;
+    } else if (c === '}') {
+      depth = Math.max(0, depth - 1);
+    } else if ((c === ',' && depth === 0) || i === value.length) {
+      items.push(value.substring(start, i).trim());
+      start = i + 1;
+    }
+  }
+  return items.filter(Boolean);
+}
+
+/**
+ * Read settings from GREPFORCODE_* environment variables
+ * Lists are comma-separated and mappings are written as "key:value,key:value".
+ * Variables with the prefix that name no setting are returned as unknown
+ */
+export function configFromEnv(env: NodeJS.ProcessEnv = process.env): { config: FileConfig; unknown: string[] } {
+  // Per-root launch settings are nested mappings, so they have no environment form
+  const direct = Array.from(DIRECT_KEYS).filter((key) => key !== 'lsp.roots');
+  const keys = new Map([...Object.keys(ENV_KEYS), ...direct].map((key) => [settingEnvName(key), key]));
+  const map: ConfigMap = {};
+  const unknown: string[] = [];
+  for (const [name, value] of Object.entries(env)) {
+    if (!name.startsWith(ENV_PREFIX) || name === CONFIG_PATH_ENV || value === undefined || value === '') {
+      continue;
+    }
+    const key = keys.get(name);
+    if (!key) {
+      unknown.push(name);
+      continue;
+    }
+    let setting: ConfigValue = value;
+    if (key === 'lsp.args' || key === 'lsp.path' || key === 'search.glob' || key === 'remotes' || ENV_KEYS[key]?.format === 'list') {
+      setting = splitList(value);
+    } else if (isMapSetting(key)) {
+      setting = Object.fromEntries(splitList(value).map((part) => {
+        const sep = part.indexOf(':');
+        return sep < 0 ? [part, null] : [part.substring(0, sep).trim(), part.substring(sep + 1).trim()];
+      }));
+    }
+    // Rebuild the nesting so the value is read like a file setting
+    const parts = key.split('.');
+    let table = map;
+    for (const part of parts.slice(0, -1)) {
+      table = (table[part] ??= {}) as ConfigMap;
+    }
+    table[parts[parts.length - 1]] = setting;
+  }
+  const config = interpretConfig(map, 'environment');
+  if (config.workspace) {
+    config.workspace = path.resolve(config.workspace);
+  }
+  return { config, unknown: unknown.sort() };
+}
+
+/**
+ * Set the environment variables of configuration settings
+ * Variables that are already set are kept unless overwrite is set.
+ * Returns the names of the variables that were set
+ */
+export function applyConfigEnv(config: FileConfig, env: NodeJS.ProcessEnv = process.env, overwrite = false): string[] {
+  const applied: string[] = [];
+  for (const [name, value] of Object.entries(config.env)) {
+    if (overwrite || env[name] === undefined) {
+      env[name] = value;
+      applied.push(name);
+    }
+  }
+  return applied;
+}
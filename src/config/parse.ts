@@ -0,0 +1,390 @@
+/**
+ * Configuration file parsing
+ * Supports the subset of YAML and TOML that configuration files need:
+ * nested mappings/tables, lists, inline lists and maps, quoted and plain
+ * scalars, and comments. Anchors, multi-line strings, and arrays of tables
+ * are rejected with an error rather than misread
+ */
+
+export type ConfigValue = string | number | boolean | null | ConfigValue[] | { [key: string]: ConfigValue };
+export type ConfigMap = { [key: string]: ConfigValue };
+
+/**
+ * Parse error with the 1-indexed line it occurred on
+ */
+export class ConfigParseError extends Error {
+  constructor(message: string, readonly line: number) {
+    super(`line ${line}: ${message}`);
+  }
+}
+
+/**
+ * Remove a trailing comment, ignoring # inside quotes
+ * With quoteAfter, a quote only opens a string at the start or after a
+ * character it matches, so plain YAML scalars like it's keep their quotes
+ */
+export function stripComment(text: string, quoteAfter?: RegExp): string {
+  let quote: string | null = null;
+  for (let i = 0; i < text.length; i++) {
+    const c = text[i];
+    if (quote) {
+      if (c === '\\' && quote === '"') {
+        i++;
+      } else if (c === quote) {
+        quote = null;
+      }
+    } else if ((c === '"' || c === "'") && (!quoteAfter || i === 0 || quoteAfter.test(text[i - 1]))) {
+      quote = c;
+    } else if (c === '#' && (i === 0 || /\s/.test(text[i - 1]))) {
+      return text.substring(0, i);
+    }
+  }
+  return text;
+}
+
+/**
+ * Reader for inline values: quoted strings, lists, maps, and plain scalars
+ */
+class InlineReader {
+  private pos = 0;
+
+  constructor(private text: string, private line: number, private separator: ':' | '=') {}
+
+  read(): ConfigValue {
+    const value = this.value(false);
+    this.skipSpace();
+    if (this.pos < this.text.length) {
+      throw new ConfigParseError(`unexpected "${this.text.substring(this.pos)}"`, this.line);
+    }
+    return value;
+  }
+
+  private value(nested: boolean): ConfigValue {
+    this.skipSpace();
+    const c = this.text[this.pos];
+    if (c === '[') {
+      return this.list();
+    }
+    if (c === '{') {
+      return this.map();
+    }
+    if (c === '"' || c === "'") {
+      return this.quoted();
+    }
+    // Plain scalars end at the end of the text, or at a delimiter inside lists and maps
+    const start = this.pos;
+    while (this.pos < this.text.length && !(nested && /[,\]}]/.test(this.text[this.pos]))) {
+      this.pos++;
+    }
+    return plainScalar(this.text.substring(start, this.pos).trim(), this.separator);
+  }
+
+  private list(): ConfigValue[] {
+    this.pos++; // [
+    const items: ConfigValue[] = [];
+    for (;;) {
+      this.skipSpace();
+      if (this.text[this.pos] === ']') {
+        this.pos++;
+        return items;
+      }
+      items.push(this.value(true));
+      this.skipSpace();
+      if (this.text[this.pos] === ',') {
+        this.pos++;
+      } else if (this.text[this.pos] !== ']') {
+        throw new ConfigParseError('unterminated list', this.line);
+      }
+    }
+  }
+
+  private map(): ConfigMap {
+    this.pos++; // {
+    const map: ConfigMap = {};
+    for (;;) {
+      this.skipSpace();
+      if (this.text[this.pos] === '}') {
+        this.pos++;
+        return map;
+      }
+      const keyStart = this.pos;
+      let key: string;
+      if (this.text[this.pos] === '"' || this.text[this.pos] === "'") {
+        key = this.quoted();
+      } else {
+        while (this.pos < this.text.length && this.text[this.pos] !== this.separator && this.text[this.pos] !== '}') {
+          this.pos++;
+        }
+        key = this.text.substring(keyStart, this.pos).trim();
+      }
+      this.skipSpace();
+      if (this.text[this.pos] !== this.separator || !key) {
+        throw new ConfigParseError('expected key and value in inline map', this.line);
+      }
+      this.pos++;
+      map[key] = this.value(true);
+      this.skipSpace();
+      if (this.text[this.pos] === ',') {
+        this.pos++;
+      } else if (this.text[this.pos] !== '}') {
+        throw new ConfigParseError('unterminated inline map', this.line);
+      }
+    }
+  }
+
+  private quoted(): string {
+    const quote = this.text[this.pos++];
+    let out = '';
+    while (this.pos < this.text.length) {
+      const c = this.text[this.pos++];
+      if (c === quote) {
+        // YAML escapes a single quote by doubling it
+        if (quote === "'" && this.separator === ':' && this.text[this.pos] === "'") {
+          out += "'";
+          this.pos++;
+          continue;
+        }
+        return out;
+      }
+      if (c === '\\' && quote === '"') {
+        const e = this.text[this.pos++];
+        out += ({ n: '\n', t: '\t', r: '\r', '"': '"', '\\': '\\' } as Record<string, string>)[e] ?? `\\${e}`;
+      } else {
+        out += c;
+      }
+    }
+    throw new ConfigParseError('unterminated string', this.line);
+  }
+
+  private skipSpace(): void {
+    while (this.pos < this.text.length && /\s/.test(this.text[this.pos])) {
+      this.pos++;
+    }
+  }
+}
+
+/**
+ * Interpret an unquoted scalar
+ * TOML has no bare strings, but they are accepted the same way as in YAML
+ */
+function plainScalar(text: string, separator: ':' | '='): ConfigValue {
+  if (text === 'true' || (separator === ':' && /^(True|TRUE|yes|on)$/.test(text))) {
+    return true;
+  }
+  if (text === 'false' || (separator === ':' && /^(False|FALSE|no|off)$/.test(text))) {
+    return false;
+  }
+  if (separator === ':' && /^(null|Null|NULL|~)?$/.test(text)) {
+    return null;
+  }
+  if (/^[-+]?(\d[\d_]*)(\.\d+)?([eE][-+]?\d+)?$/.test(text)) {
+    return Number(text.replace(/_/g, ''));
+  }
+  return text;
+}
+
+/**
+ * Parse an inline value (the right-hand side of a key)
+ */
+function parseInline(text: string, line: number, separator: ':' | '='): ConfigValue {
+  if (separator === ':' && /^[&*!|>]/.test(text)) {
+    throw new ConfigParseError(`unsupported YAML feature "${text[0]}"`, line);
+  }
+  return new InlineReader(text, line, separator).read();
+}
+
+interface YamlLine {
+  indent: number;
+  text: string;
+  line: number;
+}
+
+const YAML_KEY = /^("(?:[^"\\]|\\.)*"|'[^']*'|[^\s"'#][^:]*?)\s*:(?:\s+(.*))?$/;
+
+function unquoteKey(key: string): string {
+  return /^["']/.test(key) ? key.substring(1, key.length - 1) : key;
+}
+
+function isSequenceItem(text: string): boolean {
+  return text === '-' || text.startsWith('- ');
+}
+
+/**
+ * Parse YAML configuration content into a mapping
+ */
+export function parseYaml(content: string): ConfigMap {
+  const lines: YamlLine[] = [];
+  content.split(/\r?\n/).forEach((raw, i) => {
+    if (/^(---|\.\.\.)\s*$/.test(raw)) {
+      if (lines.length > 0) {
+        throw new ConfigParseError('multiple documents are not supported', i + 1);
+      }
+      return;
+    }
+    if (/^\s*\t/.test(raw)) {
+      throw new ConfigParseError('tabs are not allowed for indentation', i + 1);
+    }
+    const text = stripComment(raw).trimEnd();
+    if (text.trim()) {
+      lines.push({ indent: text.length - text.trimStart().length, text: text.trim(), line: i + 1 });
+    }
+  });
+  if (lines.length === 0) {
+    return {};
+  }
+
+  const parseBlock = (start: number, indent: number): [ConfigValue, number] => {
+    return isSequenceItem(lines[start].text) ? parseSequence(start, indent) : parseMapping(start, indent);
+  };
+
+  const parseSequence = (start: number, indent: number): [ConfigValue[], number] => {
+    const items: ConfigValue[] = [];
+    let i = start;
+    while (i < lines.length && lines[i].indent === indent && isSequenceItem(lines[i].text)) {
+      const rest = lines[i].text.substring(1).trim();
+      if (!rest) {
+        if (i + 1 < lines.length && lines[i + 1].indent > indent) {
+          const [value, next] = parseBlock(i + 1, lines[i + 1].indent);
+          items.push(value);
+          i = next;
+        } else {
+          items.push(null);
+          i++;
+        }
+      } else if (YAML_KEY.test(rest) && !/^[[{"']/.test(rest)) {
+        // "- key: value" starts a mapping indented to the key
+        lines[i] = { indent: indent + (lines[i].text.length - rest.length), text: rest, line: lines[i].line };
+        const [value, next] = parseMapping(i, lines[i].indent);
+        items.push(value);
+        i = next;
+      } else {
+        items.push(parseInline(rest, lines[i].line, ':'));
+        i++;
+      }
+    }
+    return [items, i];
+  };
+
+  const parseMapping = (start: number, indent: number): [ConfigMap, number] => {
+    const map: ConfigMap = {};
+    let i = start;
+    while (i < lines.length && lines[i].indent === indent) {
+      const { text, line } = lines[i];
+      const match = YAML_KEY.exec(text);
+      if (!match || isSequenceItem(text)) {
+        throw new ConfigParseError(`expected "key: value", got "${text}"`, line);
+      }
+      const key = unquoteKey(match[1]);
+      if (key in map) {
+        throw new ConfigParseError(`duplicate key "${key}"`, line);
+      }
+      i++;
+      if (match[2] !== undefined && match[2] !== '') {
+        map[key] = parseInline(match[2], line, ':');
+      } else if (i < lines.length && lines[i].indent > indent) {
+        [map[key], i] = parseBlock(i, lines[i].indent);
+      } else if (i < lines.length && lines[i].indent === indent && isSequenceItem(lines[i].text)) {
+        // A list may sit at the same indentation as its key
+        [map[key], i] = parseSequence(i, indent);
+      } else {
+        map[key] = null;
+      }
+    }
+    if (i < lines.length && lines[i].indent > indent) {
+      throw new ConfigParseError('unexpected indentation', lines[i].line);
+    }
+    return [map, i];
+  };
+
+  const [root, next] = parseBlock(0, lines[0].indent);
+  if (next < lines.length) {
+    throw new ConfigParseError('unexpected indentation', lines[next].line);
+  }
+  if (Array.isArray(root)) {
+    throw new ConfigParseError('the top level must be a mapping', lines[0].line);
+  }
+  return root as ConfigMap;
+}
+
+/**
+ * Parse TOML configuration content into a mapping
+ */
+export function parseToml(content: string): ConfigMap {
+  const root: ConfigMap = {};
+  let table = root;
+  const rawLines = content.split(/\r?\n/);
+
+  const descend = (base: ConfigMap, keys: string[], line: number): ConfigMap => {
+    let current = base;
+    for (const key of keys) {
+      const next = current[key];
+      if (next === undefined) {
+        current[key] = {};
+      } else if (typeof next !== 'object' || next === null || Array.isArray(next)) {
+        throw new ConfigParseError(`"${key}" is not a table`, line);
+      }
+      current = current[key] as ConfigMap;
+    }
+    return current;
+  };
+  const splitKey = (key: string): string[] => key.split('.').map((part) => unquoteKey(part.trim()));
+
+  for (let i = 0; i < rawLines.length; i++) {
+    const line = i + 1;
+    const text = stripComment(rawLines[i]).trim();
+    if (!text) {
+      continue;
+    }
+    if (text.startsWith('[[')) {
+      throw new ConfigParseError('arrays of tables are not supported', line);
+    }
+    const header = /^\[([^\]]+)\]$/.exec(text);
+    if (header) {
+      table = descend(root, splitKey(header[1]), line);
+      continue;
+    }
+
+    const eq = text.indexOf('=');
+    if (eq <= 0) {
+      throw new ConfigParseError(`expected "key = value", got "${text}"`, line);
+    }
+    const keys = splitKey(text.substring(0, eq));
+    let valueText = text.substring(eq + 1).trim();
+    // Lists may continue over several lines until the brackets balance
+    while (valueText.startsWith('[') && !bracketsBalanced(valueText) && i + 1 < rawLines.length) {
+      valueText += ' ' + stripComment(rawLines[++i]).trim();
+    }
+    if (valueText.startsWith('"""') || valueText.startsWith("'''")) {
+      throw new ConfigParseError('multi-line strings are not supported', line);
+    }
+    const parent = descend(table, keys.slice(0, -1), line);
+    const key = keys[keys.length - 1];
+    if (key in parent) {
+      throw new ConfigParseError(`duplicate key "${key}"`, line);
+    }
+    parent[key] = parseInline(valueText, line, '=');
+  }
+  return root;
+}
+
+/**
+ * Check if the brackets of a list are balanced, ignoring quoted text
+ */
+function bracketsBalanced(text: string): boolean {
+  let depth = 0;
+  let quote: string | null = null;
+  for (const c of text) {
+    if (quote) {
+      if (c === quote) {
+        quote = null;
+      }
+    } else if (c === '"' || c === "'") {
+      quote = c;
+    } else if (c === '[') {
+      depth++;
+    } else if (c === ']') {
+      depth--;
+    }
+  }
+  return depth === 0;
+}
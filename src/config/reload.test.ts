@@ -0,0 +1,121 @@
+/**
+ * Tests for configuration hot reload
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { applyConfigEnv, loadConfigFile } from './config';
+import { ConfigReload, ConfigWatcher, applyMode, describeChanges } from './reload';
+
+describe('Configuration reload', () => {
+  let dir: string;
+  let workspace: string;
+  let configPath: string;
+
+  beforeEach(() => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'config-reload-'));
+    workspace = path.join(dir, 'project');
+    fs.mkdirSync(workspace);
+    configPath = path.join(dir, 'config.yaml');
+  });
+
+  afterEach(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  /**
+   * Load the global file and apply it to env, as at startup
+   */
+  function start(env: NodeJS.ProcessEnv, fixed: string[] = []): ConfigWatcher {
+    const config = loadConfigFile(configPath);
+    const owned = applyConfigEnv(config, env);
+    return new ConfigWatcher({ configPath, workspaceDir: workspace }, config, owned, fixed, env);
+  }
+
+  it('should update settings that came from the files and leave the environment alone', () => {
+    fs.writeFileSync(configPath, 'limits:\n  maxFileSize: 1000\n  searchTimeoutMs: 5000\nlogging:\n  level: INFO\n');
+    const env: NodeJS.ProcessEnv = { SEARCH_TIMEOUT_MS: '100' };
+    const watcher = start(env);
+
+    fs.writeFileSync(configPath, 'limits:\n  maxFileSize: 2000\n  searchTimeoutMs: 9000\nexclude:\n  dirs: [gen]\n');
+    const { changes } = watcher.reload();
+    expect(changes).toEqual([
+      { key: 'limits.maxFileSize', before: '1000', after: '2000' },
+      { key: 'logging.level', before: 'INFO', after: undefined },
+      { key: 'exclude.dirs', before: undefined, after: 'gen' },
+    ]);
+    expect(env).toEqual({ SEARCH_TIMEOUT_MS: '100', SEARCH_MAX_FILE_SIZE: '2000', WORKSPACE_EXCLUDE_DIRS: 'gen' });
+    expect(watcher.reload().changes).toEqual([]);
+  });
+
+  it('should pick up a new language server, but not from a workspace file', () => {
+    fs.writeFileSync(configPath, 'lsp:\n  command: gopls\n');
+    const watcher = start({});
+    fs.writeFileSync(path.join(workspace, '.grepforcode.yaml'), 'lsp:\n  command: pyright-langserver\n');
+    expect(() => watcher.reload()).toThrow('"lsp.command" cannot be set in a workspace config');
+    fs.rmSync(path.join(workspace, '.grepforcode.yaml'));
+    fs.writeFileSync(configPath, 'lsp:\n  command: pyright-langserver\n  args: [--stdio]\n');
+    expect(watcher.reload().changes).toEqual([
+      { key: 'lsp.command', before: 'gopls', after: 'pyright-langserver --stdio' },
+    ]);
+
+    const pinned = start({}, ['lsp.command']);
+    expect(pinned.reload().changes).toEqual([]);
+  });
+
+  it('should report changed language server launch settings', () => {
+    fs.writeFileSync(configPath, 'lsp:\n  command: jdtls\n  env: { JAVA_HOME: /opt/jdk-17 }\n');
+    const watcher = start({});
+    fs.writeFileSync(configPath, 'lsp:\n  command: jdtls\n  cwd: backend\n  env: { JAVA_HOME: /opt/jdk-21 }\n');
+    const { changes } = watcher.reload();
+    expect(changes).toEqual([
+      { key: 'lsp.cwd', before: undefined, after: 'backend' },
+      { key: 'lsp.env', before: 'JAVA_HOME=/opt/jdk-17', after: 'JAVA_HOME=/opt/jdk-21' },
+    ]);
+    expect(describeChanges(changes)).toBe('lsp.cwd (unset) -> "backend", lsp.env (hidden) -> (hidden)');
+
+    // Only the settings for this workspace's root count
+    fs.writeFileSync(configPath, 'lsp:\n  command: jdtls\n  cwd: backend\n  env: { JAVA_HOME: /opt/jdk-21 }\n  roots:\n    elsewhere: { cwd: web }\n');
+    expect(watcher.reload().changes).toEqual([]);
+    fs.writeFileSync(configPath, 'lsp:\n  command: jdtls\n  cwd: backend\n  env: { JAVA_HOME: /opt/jdk-21 }\n  roots:\n    project: { cwd: api }\n');
+    expect(watcher.reload().changes).toEqual([{ key: 'lsp.cwd', before: 'backend', after: 'api' }]);
+  });
+
+  it('should keep the previous settings when a file does not parse', () => {
+    fs.writeFileSync(configPath, 'limits:\n  maxFileSize: 1000\n');
+    const env: NodeJS.ProcessEnv = {};
+    const watcher = start(env);
+    fs.writeFileSync(configPath, 'limits:\n  maxFileSize: [\n');
+    expect(() => watcher.reload()).toThrow();
+    expect(env.SEARCH_MAX_FILE_SIZE).toBe('1000');
+  });
+
+  it('should report changes when a file is written', async () => {
+    fs.writeFileSync(configPath, 'limits:\n  maxFileSize: 1000\n');
+    const watcher = start({});
+    const reloaded = new Promise<ConfigReload>((resolve) => watcher.watch(resolve));
+    try {
+      fs.writeFileSync(configPath, 'limits:\n  maxFileSize: 3000\n');
+      expect((await reloaded).changes).toEqual([{ key: 'limits.maxFileSize', before: '1000', after: '3000' }]);
+    } finally {
+      watcher.close();
+    }
+  });
+
+  it('should say how each change takes effect', () => {
+    expect(applyMode('limits.maxFileSize')).toBe('live');
+    expect(applyMode('exclude.dirs')).toBe('live');
+    expect(applyMode('logging.level')).toBe('live');
+    expect(applyMode('flags')).toBe('live');
+    expect(applyMode('limits.memoryLimitMb')).toBe('restart');
+    expect(applyMode('lsp.command')).toBe('lsp');
+    expect(applyMode('lsp.env')).toBe('lsp');
+    expect(applyMode('cache.lspTtlSeconds')).toBe('lsp');
+    expect(applyMode('semantic.provider')).toBe('restart');
+    expect(describeChanges([
+      { key: 'limits.maxFileSize', before: '1000', after: '2000' },
+      { key: 'semantic.apiKey', before: undefined, after: 'secret' },
+    ])).toBe('limits.maxFileSize "1000" -> "2000", semantic.apiKey (unset) -> (hidden)');
+  });
+});
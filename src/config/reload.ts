@@ -0,0 +1,282 @@
+/**
+ * Configuration hot reload
+ * Watches the global and workspace configuration files and re-applies them
+ * when they change, so exclusions, limits, and log levels can be adjusted
+ * without dropping warm caches and language server state. Settings given on
+ * the command line or in the environment still take precedence: only the
+ * environment variables that came from the files are updated
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import {
+  FileConfig,
+  CONFIG_NAMES,
+  WORKSPACE_CONFIG_NAMES,
+  configDir,
+  findDefaultConfig,
+  findWorkspaceConfig,
+  launchSettingsFor,
+  loadConfigFile,
+  mergeConfigs,
+  settingForEnv,
+} from './config.js';
+
+const configLogger = createLogger(Component.CORE);
+
+/**
+ * Time to wait for more file events before reloading
+ */
+const RELOAD_DEBOUNCE_MS = 200;
+
+/**
+ * Settings whose values are not logged
+ */
+const SECRET_KEYS = new Set(['semantic.apiKey', 'lsp.env']);
+
+/**
+ * How a changed setting takes effect
+ * - live: read on each use, or re-applied in place
+ * - lsp: the language server is restarted
+ * - restart: the process has to be restarted
+ */
+export type ApplyMode = 'live' | 'lsp' | 'restart';
+
+/**
+ * One changed setting
+ */
+export interface SettingChange {
+  key: string;
+  before?: string;
+  after?: string;
+}
+
+/**
+ * Result of reloading the configuration files
+ */
+export interface ConfigReload {
+  config?: FileConfig;
+  changes: SettingChange[];
+}
+
+/**
+ * Where the configuration files are looked up
+ */
+export interface ConfigFiles {
+  // Global file given with --config or GREPFORCODE_CONFIG (default: found in the config directory)
+  configPath?: string;
+  workspaceDir: string;
+}
+
+/**
+ * How a change to a setting takes effect
+ */
+export function applyMode(key: string): ApplyMode {
+  if (key.startsWith('lsp.') || key.startsWith('cache.lsp')) {
+    return 'lsp';
+  }
+  if (key.startsWith('logging.') || key.startsWith('exclude.') || key.startsWith('limits.') ||
+    key === 'followSymlinks' || key === 'flags' || key === 'languages' || key === 'search.glob' || key === 'remotes' || key === 'links' ||
+    key === 'templates' || key === 'plugins' || key === 'matchers') {
+    // The memory budget is sized once at startup
+    return key === 'limits.memoryLimitMb' ? 'restart' : 'live';
+  }
+  return 'restart';
+}
+
+/**
+ * Describe changes for a log entry, such as "limits.maxFileSize 1000 -> 2000"
+ */
+export function describeChanges(changes: SettingChange[]): string {
+  const show = (key: string, value?: string) =>
+    value === undefined ? '(unset)' : SECRET_KEYS.has(key) ? '(hidden)' : JSON.stringify(value);
+  return changes.map((change) => `${change.key} ${show(change.key, change.before)} -> ${show(change.key, change.after)}`)
+    .join(', ');
+}
+
+function linksText(config?: FileConfig): string | undefined {
+  return config?.links ? JSON.stringify(config.links) : undefined;
+}
+
+function templatesText(config?: FileConfig): string | undefined {
+  return config?.templates ? JSON.stringify(config.templates) : undefined;
+}
+
+function pluginsText(config?: FileConfig): string | undefined {
+  return config?.plugins ? JSON.stringify(config.plugins) : undefined;
+}
+
+function matchersText(config?: FileConfig): string | undefined {
+  return config?.matchers ? JSON.stringify(config.matchers) : undefined;
+}
+
+function lspText(config?: FileConfig): string | undefined {
+  return config?.lspCommand ? [config.lspCommand, ...(config.lspArgs ?? [])].join(' ') : undefined;
+}
+
+/**
+ * Language server launch settings of a workspace as comparable text
+ */
+function launchTexts(config: FileConfig | undefined, workspaceDir: string): Record<string, string | undefined> {
+  const launch = launchSettingsFor(config, workspaceDir);
+  const env = launch.env ? Object.entries(launch.env).map(([name, value]) => `${name}=${value}`).sort().join(',') : undefined;
+  return { 'lsp.cwd': launch.cwd, 'lsp.path': launch.path?.join(','), 'lsp.env': env };
+}
+
+/**
+ * Watches the configuration files and applies their settings to the environment
+ */
+export class ConfigWatcher {
+  private owned: Set<string>;
+  private fixed: Set<string>;
+  private watchers: fs.FSWatcher[] = [];
+  private timer?: NodeJS.Timeout;
+
+  /**
+   * owned: environment variables that were set from the files
+   * fixed: lsp.command, lsp.cwd, lsp.path, lsp.env, search.glob, remotes, links, templates, plugins, and matchers when the command line or environment sets them
+   */
+  constructor(
+    private files: ConfigFiles,
+    private current: FileConfig | undefined,
+    owned: Iterable<string>,
+    fixed: Iterable<string> = [],
+    private env: NodeJS.ProcessEnv = process.env
+  ) {
+    this.owned = new Set(owned);
+    this.fixed = new Set(fixed);
+  }
+
+  /**
+   * Read the global and workspace files, merged
+   */
+  load(): FileConfig | undefined {
+    const globalFile = this.files.configPath || findDefaultConfig(this.env);
+    let config = globalFile && fs.existsSync(globalFile) ? loadConfigFile(globalFile) : undefined;
+    const workspaceFile = findWorkspaceConfig(this.files.workspaceDir);
+    if (workspaceFile) {
+      config = mergeConfigs(config, loadConfigFile(workspaceFile, 'workspace'));
+    }
+    return config;
+  }
+
+  /**
+   * Re-read the files, update the environment, and return what changed
+   * Throws when a file cannot be parsed; the previous settings stay in effect
+   */
+  reload(): ConfigReload {
+    const next = this.load();
+    const changes: SettingChange[] = [];
+    const before = this.current?.env ?? {};
+    const after = next?.env ?? {};
+
+    for (const name of new Set([...Object.keys(before), ...Object.keys(after)])) {
+      if (before[name] === after[name]) {
+        continue;
+      }
+      // Set outside the files, so the environment wins
+      if (!this.owned.has(name) && this.env[name] !== undefined) {
+        continue;
+      }
+      if (after[name] === undefined) {
+        delete this.env[name];
+        this.owned.delete(name);
+      } else {
+        this.env[name] = after[name];
+        this.owned.add(name);
+      }
+      changes.push({ key: settingForEnv(name) ?? name, before: before[name], after: after[name] });
+    }
+
+    if (!this.fixed.has('lsp.command') && lspText(this.current) !== lspText(next)) {
+      changes.push({ key: 'lsp.command', before: lspText(this.current), after: lspText(next) });
+    }
+    const launchBefore = launchTexts(this.current, this.files.workspaceDir);
+    const launchAfter = launchTexts(next, this.files.workspaceDir);
+    for (const key of Object.keys(launchAfter)) {
+      if (!this.fixed.has(key) && launchBefore[key] !== launchAfter[key]) {
+        changes.push({ key, before: launchBefore[key], after: launchAfter[key] });
+      }
+    }
+    if (!this.fixed.has('search.glob') && this.current?.globs?.join(',') !== next?.globs?.join(',')) {
+      changes.push({ key: 'search.glob', before: this.current?.globs?.join(','), after: next?.globs?.join(',') });
+    }
+    if (!this.fixed.has('remotes') && this.current?.remotes?.join(',') !== next?.remotes?.join(',')) {
+      changes.push({ key: 'remotes', before: this.current?.remotes?.join(','), after: next?.remotes?.join(',') });
+    }
+    if (!this.fixed.has('links') && linksText(this.current) !== linksText(next)) {
+      changes.push({ key: 'links', before: linksText(this.current), after: linksText(next) });
+    }
+    if (!this.fixed.has('templates') && templatesText(this.current) !== templatesText(next)) {
+      changes.push({ key: 'templates', before: templatesText(this.current), after: templatesText(next) });
+    }
+    if (!this.fixed.has('plugins') && pluginsText(this.current) !== pluginsText(next)) {
+      changes.push({ key: 'plugins', before: pluginsText(this.current), after: pluginsText(next) });
+    }
+    if (!this.fixed.has('matchers') && matchersText(this.current) !== matchersText(next)) {
+      changes.push({ key: 'matchers', before: matchersText(this.current), after: matchersText(next) });
+    }
+    if (this.current?.workspace !== next?.workspace) {
+      changes.push({ key: 'workspace', before: this.current?.workspace, after: next?.workspace });
+    }
+
+    this.current = next;
+    return { config: next, changes };
+  }
+
+  /**
+   * Reload whenever a configuration file is written, created, or removed
+   */
+  watch(onReload: (reload: ConfigReload) => void): void {
+    const globalDir = this.files.configPath ? path.dirname(this.files.configPath) : configDir(this.env);
+    const globalNames = this.files.configPath ? [path.basename(this.files.configPath)] : CONFIG_NAMES;
+    this.watchDir(globalDir, globalNames, onReload);
+    this.watchDir(this.files.workspaceDir, WORKSPACE_CONFIG_NAMES, onReload);
+  }
+
+  /**
+   * Stop watching
+   */
+  close(): void {
+    clearTimeout(this.timer);
+    for (const watcher of this.watchers) {
+      watcher.close();
+    }
+    this.watchers = [];
+  }
+
+  /**
+   * Watch a directory rather than the file, since editors often replace files on save
+   */
+  private watchDir(dir: string, names: string[], onReload: (reload: ConfigReload) => void): void {
+    try {
+      const watcher = fs.watch(dir, (_event, filename) => {
+        if (filename && names.includes(filename.toString())) {
+          this.schedule(onReload);
+        }
+      });
+      watcher.on('error', (err) => configLogger.warn('Stopped watching %s: %s', dir, err.message));
+      this.watchers.push(watcher);
+      configLogger.debug('Watching %s for configuration changes', dir);
+    } catch (err) {
+      configLogger.debug('Not watching %s: %s', dir, (err as Error).message);
+    }
+  }
+
+  private schedule(onReload: (reload: ConfigReload) => void): void {
+    clearTimeout(this.timer);
+    this.timer = setTimeout(() => {
+      let result: ConfigReload;
+      try {
+        result = this.reload();
+      } catch (err) {
+        configLogger.error('Keeping the previous configuration: %s', (err as Error).message);
+        return;
+      }
+      if (result.changes.length > 0) {
+        onReload(result);
+      }
+    }, RELOAD_DEBOUNCE_MS);
+  }
+}
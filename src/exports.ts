@@ -10,17 +10,77 @@ export {
   setGlobalLevel,
   setWriter,
   setupFileLogging,
+  reloadLoggingFromEnv,
+  setFormat,
+  withLogContext,
   LogLevel,
+  LogFormat,
+  LogFields,
   Component,
   Logger,
 } from './logging/logger.js';
+export { RotatingFileStream, RotationOptions, rotationFromEnv } from './logging/rotate.js';
+export { AuditLog, AuditEntry, readAuditLog, sharedAuditLog } from './logging/audit.js';
+
+// Configuration
+export {
+  FileConfig,
+  ConfigScope,
+  LinkRule,
+  LinkKind,
+  LINK_KINDS,
+  ResultTemplate,
+  ResultField,
+  RESULT_FIELDS,
+  PluginTool,
+  PluginParameter,
+  PLUGIN_PARAMETER_TYPES,
+  LspLaunchSettings,
+  loadConfigFile,
+  interpretConfig,
+  mergeConfigs,
+  launchSettingsFor,
+  applyConfigEnv,
+  findDefaultConfig,
+  findWorkspaceConfig,
+  configDir,
+  configFromEnv,
+  settingEnvName,
+  settingForEnv,
+  CONFIG_PATH_ENV,
+  CONFIG_NAMES,
+  WORKSPACE_CONFIG_NAMES,
+} from './config/config.js';
+export { parseYaml, parseToml, ConfigParseError, ConfigValue, ConfigMap } from './config/parse.js';
+export { ConfigWatcher, ConfigReload, ConfigFiles, SettingChange, ApplyMode, applyMode, describeChanges } from './config/reload.js';
+
+// Command line
+export { parseSearchArgs, runSearchCommand, SearchCommand, SearchCommandOutput, SEARCH_USAGE } from './cli/search.js';
+export { parseIndexArgs, runIndexCommand, IndexCommand, INDEX_USAGE } from './cli/snapshot.js';
+export { parseServeArgs, queryStringParams, searchRequest, startSearchServer, SearchRequest, ServeCommand, SERVE_USAGE } from './cli/serve.js';
+export { UI_PAGE, UI_TOOLS, UiTools } from './cli/ui.js';
+export { runRepl, evaluateLine, parseToolCall, formatHelp, ReplTools, ToolSchema, ToolCall } from './cli/repl.js';
+
+// Metrics
+export { MetricsRegistry, Counter, Gauge, Histogram, Labels, Collector, DEFAULT_BUCKETS } from './metrics/metrics.js';
+export {
+  startMetricsServer,
+  metricsAddressFromEnv,
+  healthReport,
+  HealthCheck,
+  HealthReport,
+  HealthProbe,
+} from './metrics/http.js';
 
 // Protocol types
 export * from './protocol/types.js';
 export * from './protocol/uri.js';
+export * from './protocol/position.js';
 
 // LSP Client
-export { LSPClient, registerFileWatchHandler } from './lsp/client.js';
+export { LSPClient, LaunchOptions, launchEnvironment, registerFileWatchHandler } from './lsp/client.js';
+export * from './lsp/queue.js';
+export * from './lsp/idle.js';
 export * from './lsp/methods.js';
 export {
   createRequest,
@@ -38,12 +98,255 @@ export {
 export { WorkspaceWatcher, WatcherConfig, defaultWatcherConfig } from './watcher/watcher.js';
 export { GitignoreMatcher } from './watcher/gitignore.js';
 
+// Workspace
+export { walkWorkspaceFiles, resolveWorkspacePath, WalkOptions, WorkspaceFile, ExclusionRule } from './workspace/walker.js';
+
+export { LanguageOverride, detectLanguageId, isSourceFile, isTestFile, languageGlobs, languageOverrides } from './workspace/language.js';
+export { globToRegExp, matchesGlob } from './workspace/glob.js';
+export { runPool, createLimiter, workerCount, PoolOptions } from './workspace/pool.js';
+export { isBinaryFile, isBinaryContent, hasBinaryExtension } from './workspace/binary.js';
+export {
+  readArchive,
+  isArchive,
+  archiveLimitsFromEnv,
+  ARCHIVE_SEPARATOR,
+  ArchiveLimits,
+  ArchiveEntry,
+  ArchiveContents,
+} from './workspace/archive.js';
+export {
+  Overlay,
+  OverlayEntry,
+  sharedOverlay,
+  readFileBytes,
+  readFileText,
+  assertNoOverlay,
+} from './workspace/overlay.js';
+export {
+  SnapshotStore, WorkspaceSnapshot, currentSnapshot, takeSnapshot, unpinned, withSnapshot,
+} from './workspace/treesnapshot.js';
+export { detectProjects, findProject, Project, ProjectSource } from './workspace/projects.js';
+export { WorkspaceRoots, WorkspaceRoot, formatRoot, rootName } from './workspace/roots.js';
+export { SearchScope, fixturePatterns, isFixturePath, inSearchScope } from './workspace/fixtures.js';
+export * from './workspace/targets.js';
+export * from './workspace/bazel.js';
+export * from './workspace/docker.js';
+export {
+  buildContext,
+  matchesBuildContext,
+  matchesFileName,
+  evalBuildExpr,
+  buildConstraints,
+  formatBuildContext,
+  BuildContext,
+} from './workspace/buildtags.js';
+export { TextEncoding, detectEncoding, decodeText, normalizeLineEndings } from './workspace/encoding.js';
+export { GeneratedFileDetector, parseGeneratedAttributes, hasGeneratedHeader } from './workspace/generated.js';
+export { MemoryBudget, sharedMemoryBudget } from './workspace/memory.js';
+export { CallLimiter, CallLimits, ThrottledError, callLimitsFromEnv } from './workspace/throttle.js';
+export { BudgetExceededError, BudgetLimits, BudgetTracker, Cost, CostLimits, QueryBudget, budgetLimitsFromEnv, currentBudget, withBudget } from './workspace/budget.js';
+export { CancelledError, TaskGroup, currentSignal, runGroup, throwIfCancelled, uncancellable, unlessCancelled, withCancellation } from './workspace/cancellation.js';
+export * from './workspace/errors.js';
+export * from './workspace/drain.js';
+export { writeFileAtomic } from './workspace/atomic.js';
+export { canonicalizePath, isCaseInsensitiveDir, pathKey, assertInsideRoots, PathOutsideWorkspaceError } from './workspace/paths.js';
+
+// Git
+export {
+  runGit, isGitRepository, blameFile, parseBlamePorcelain, BlameInfo, describeStatusCode, fileGitStatus, FileGitStatus,
+  describeWorkingChange, workingChanges, WorkingChange,
+  parseNameOnlyLog, recentlyCommittedFiles, FileCommit, parseLineRangeLog, lineRangeHistory, LineRangeCommit,
+  parseNumstatLog, commitChurn, CommitChurn, FileChurn,
+  parseNameStatus, changedFiles, ChangedFile, resolveRevision, mergeBase, fileAtRevision,
+} from './git/git.js';
+
 // Tools
 export { readDefinition } from './tools/definition.js';
-export { findReferences } from './tools/references.js';
+export {
+  findReferences, classifyAccess, inReferenceScope, ReferenceAccess, ReferenceOptions, ReferenceScope,
+} from './tools/references.js';
+export { Answer, Confidence, LocationConfidence, answerNote, leastConfidence, locationConfidence } from './tools/confidence.js';
 export { getHoverInfo } from './tools/hover.js';
 export { getDiagnosticsForFile } from './tools/diagnostics.js';
 export { applyTextEdits, TextEdit } from './tools/edit.js';
-export { renameSymbol } from './tools/rename.js';
+export { renameSymbol, RenameOptions } from './tools/rename.js';
+export { findTodos, parseTodoComment, todoParser, DEFAULT_TODO_TAGS, TodoOptions, TodoComment } from './tools/todos.js';
+export { getApiSurface, isExportedSymbol, extractSignature, ApiSurfaceOptions } from './tools/api.js';
+export { symbolUsage, formatSymbolUsage, SymbolUsage, SymbolUsageOptions } from './tools/usage.js';
+export { workspaceVocabulary, formatVocabulary, countVocabulary, clearVocabulary, Vocabulary, VocabularyEntry, VocabularyKind, VocabularyOptions } from './tools/vocabulary.js';
+export { pluginToolSchema, runPluginTool } from './tools/plugins.js';
+export { exploreSymbol, formatExploredSymbol, ExploreOptions, ExploredSymbol, ExploredDefinition, ExploredReference } from './tools/explore.js';
+export { replaceText, formatReplacements, caseVariants, atWordParts, ReplaceOptions, CaseVariant, FileReplacement } from './tools/replace.js';
+export { planRefactor, formatRefactorPlan, RefactorPlan, RefactorChunk, RefactorPlanOptions, RefactorRisk } from './tools/refactorplan.js';
+export {
+  findDuplicates,
+  findDuplicatePairs,
+  tokenize,
+  winnow,
+  DuplicateOptions,
+  DuplicatePair,
+  Token,
+  Fingerprint,
+} from './tools/duplicates.js';
+export { findSimilar, formatSimilar, similarRegions, SimilarOptions, SimilarRegion } from './tools/similar.js';
+export {
+  getImpactReport,
+  collectEditRanges,
+  groupByPackage,
+  findRenameCollisions,
+  formatCollisions,
+  RenameCollision,
+  ChangeKind,
+  ImpactOptions,
+  FileImpact,
+} from './tools/impact.js';
+export { semanticSearch, SemanticSearchOptions, SearchMode } from './tools/semantic.js';
+export {
+  searchCode, searchCodeResult, SearchCodeResult, groupMatchesByFile, formatMatchSections, SearchProgressCallback, SearchCodeOptions,
+  extractedValue, formatExtraction, sortMatchesByFile, SearchSort, collapseDuplicateFiles, resultTemplate,
+} from './tools/search.js';
+export { planSearch, formatSearchPlan, detectIntent, keywordRegex, SearchPlan, PlanStep, SearchIntent, PlanOptions } from './tools/plan.js';
+export {
+  explainQuery,
+  formatExplanation,
+  ExplainTool,
+  QueryStrategy,
+  PruneCount,
+  QueryExplanation,
+  ExplainOptions,
+  ExplainContext,
+} from './tools/explain.js';
+export {
+  getCapabilities,
+  formatCapabilities,
+  detectLanguages,
+  supportedFeatures,
+  Capabilities,
+  CapabilitiesContext,
+  LanguageServerInfo,
+} from './tools/capabilities.js';
+export {
+  RemoteRepositories,
+  RemoteRepository,
+  defaultRemoteDir,
+  parseRemoteSpec,
+  remoteName,
+  formatRemote,
+} from './git/remote.js';
+export * from './tools/symbols.js';
+export {
+  parsePythonSymbols,
+  PythonSymbolIndex,
+  sharedPythonSymbols,
+  isPythonFile,
+  isPythonServer,
+  isPythonWorkspace,
+} from './symbols/python.js';
+export { FileSymbolIndex, SymbolSnapshotFile } from './symbols/fileIndex.js';
+export * from './symbols/proto.js';
+export {
+  CodeIndex,
+  CodeIndexDump,
+  CodeIndexOptions,
+  encodeScip,
+  IndexedSymbol,
+  IndexLocation,
+  loadCodeIndex,
+  parseLsif,
+  parseScip,
+  scipDisplayName,
+} from './symbols/codeindex.js';
+export { buildWorkspaceCodeIndex, indexedDefinition, indexedReferences, workspaceSymbolId } from './tools/codeindex.js';
+export { parseTypeScriptDeclarations, blankJsLiterals, isTypeScriptFile } from './symbols/typescript.js';
+export { protoReferences, ProtoReferencesOptions } from './tools/proto.js';
+export { getFileOutline, docSummary, OutlineOptions } from './tools/outline.js';
+export { readRange, readChunk, chunkBoundaries, fileContentHash, currentFileHash, SymbolSpan, FileChunk } from './tools/read.js';
+export { directoryTree, TreeOptions } from './tools/tree.js';
+export { getFileInfo, formatBytes } from './tools/info.js';
+export { batchSearch, BatchQuery, MAX_BATCH_QUERIES } from './tools/batch.js';
+export { listEntryPoints, EntryPointOptions } from './tools/entrypoints.js';
+export { findEntryPoints, scanEntryPoints, packageEntryPoints, EntryPoint, EntryPointKind } from './workspace/entrypoints.js';
+export { listRoutes, RouteOptions } from './tools/routes.js';
+export { listEnvVars, findEnvReads, scanEnvReads, EnvVarOptions, EnvRead } from './tools/envvars.js';
+export {
+  findMessages,
+  indexMessages,
+  parseMessageResource,
+  parsePoFile,
+  scanMessageUsages,
+  usageReaches,
+  localeOf,
+  MessageOptions,
+  MessageDefinition,
+  MessageUsage,
+  MessageIndex,
+} from './tools/i18n.js';
+export { listFeatureFlags, flagPatterns, flagCallPattern, scanFlagCalls, DEFAULT_FLAG_PATTERNS, FlagOptions, FlagReference } from './tools/flags.js';
+export { findRoutes, scanRoutes, scanDeclarations, routeMatchScore, parseRouteQuery, Route, HandlerDeclaration } from './workspace/routes.js';
+export { recentFiles, RecentFilesOptions } from './tools/recent.js';
+export { pairTests, formatTestPairing, subjectStem, TestPair, TestPairing, TestPairOptions } from './tools/testpairs.js';
+export { enrichMatches, moduleOf, signatureOf } from './tools/enrich.js';
+export { attachDirectoryDocs, directoryDoc, readmeSummary, goPackageSummary, pythonDocstringSummary, rustModuleSummary, clearDirectoryDocs } from './tools/dirdocs.js';
+export { attachLastChanges, formatLastChange, clearLineHistory } from './tools/linehistory.js';
+export { attachCoverage, loadCoverageProfile, parseGoCoverProfile, parseLcov, formatLineCoverage, formatCoverageSummary, clearCoverage, CoverageProfile, CoverageSummary } from './tools/coverage.js';
+export { workspaceStats, countLines, LineCounts, StatsOptions } from './tools/stats.js';
+export { findImporters, formatImporters, Importer, ImportersOptions, ImportersReport } from './tools/importers.js';
+export { warmupWorkspace, formatWarmup, importSpecifiers, importTargets, rankByFanIn, FanIn, WarmupOptions, WarmupReport } from './tools/warmup.js';
+export { goInterfaceReport, InterfaceReportOptions } from './tools/gointerfaces.js';
+export { findPackageUsages, formatPackageUsages, PackageUsage, PackageUsageOptions, PackageUsages } from './tools/gopackages.js';
+export { findTypedValues, formatTypedValues, TypedValue, TypedSearchOptions } from './tools/typed.js';
+export { goErrorChecks, formatGoErrorFindings, GO_ERROR_CHECKS, GoErrorCheck, GoErrorFinding, GoErrorCheckOptions } from './tools/goerrors.js';
+export { runGoAnalysis } from './tools/goanalysis.js';
+export { findMessageOrigins, formatMessageOrigins, extractStringLiterals, parseTemplate, scoreOrigin, StringLiteral, MessageTemplate, MessageOrigin, MessageOriginOptions } from './tools/origins.js';
+export { resolveStackTrace, parseStackTrace, matchFramePath, isLibraryFrame, findDeclarations, StackFrame, StackTraceOptions } from './tools/stacktrace.js';
+export { symbolHistory, findFileSymbol, SymbolHistoryOptions } from './tools/history.js';
+export { churnReport, churnGroup, ChurnOptions } from './tools/churn.js';
+export { symbolDiff, fileDeclarations, diffDeclarations, Declaration, SymbolChange, SymbolDiffOptions } from './tools/symboldiff.js';
+export { searchDiff, diffMatches, queryScope, MatchDiff, SearchDiffOptions } from './tools/searchdiff.js';
+export { QueryWatches, QueryWatch, WatchOptions, WatchNotifier, describeWatch, formatWatches, formatWatchDiff, MAX_WATCHES } from './tools/watch.js';
+export { PinSet, Pin, resolvePins, formatPins, describePin, MAX_PINS } from './tools/pins.js';
+export {
+  BookmarkSet,
+  Bookmark,
+  ResolvedBookmark,
+  createBookmark,
+  resolveBookmark,
+  describeBookmark,
+  formatResolvedBookmarks,
+  MAX_BOOKMARKS,
+} from './tools/bookmarks.js';
+export { describeSnapshot, formatPinnedSnapshot, formatSnapshots } from './tools/snapshot.js';
+export { findLinkedReferences, formatLinkedReferences, linkedNames, goProtoName, protoFieldName, LinkedName, LinkedMatches } from './tools/links.js';
+export { parseGoDeclarations, parseGoImports, goPackageName, parameterTypes, GoDeclarations, GoImport, GoInterface, GoMethod, GoMethodDecl, GoFunctionDecl, GoType } from './symbols/golang.js';
+export { listBuildTargets, BuildTargetsOptions } from './tools/targets.js';
+export { listBazelTargets, BazelTargetsOptions } from './tools/bazel.js';
+export { sqlSearch } from './tools/sql.js';
+export { analyzeDockerFiles, DockerAnalysisOptions } from './tools/docker.js';
 export * from './tools/utilities.js';
 
+// Lexical search
+export * from './search/trigram.js';
+export * from './search/snapshot.js';
+export * from './search/lexical.js';
+export * from './search/headings.js';
+export * from './search/comments.js';
+export * from './search/notebook.js';
+export * from './search/queryCache.js';
+export * from './search/jsx.js';
+export * from './search/astpath.js';
+export * from './search/structured.js';
+export * from './search/keypath.js';
+export * from './search/sql.js';
+export * from './search/matchers.js';
+export * from './search/secrets.js';
+export * from './search/bench.js';
+
+// Semantic search
+export * from './semantic/chunker.js';
+export * from './semantic/embeddings.js';
+export * from './semantic/providers.js';
+export * from './semantic/onnx.js';
+export * from './semantic/vectorIndex.js';
+export * from './semantic/store.js';
+export * from './semantic/engine.js';
+export * from './semantic/hybrid.js';
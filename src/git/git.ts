@@ -0,0 +1,490 @@
+/**
+ * Git utilities - thin wrappers around the git CLI
+ */
+
+import { execFile } from 'child_process';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+
+const gitLogger = createLogger(Component.TOOLS);
+
+/**
+ * Blame information for a single line
+ */
+export interface BlameInfo {
+  commit: string;
+  author: string;
+  authorMail: string;
+  authorTime: number; // Unix timestamp in seconds
+  summary: string;
+}
+
+/**
+ * Run a git command and return stdout
+ */
+export function runGit(cwd: string, args: string[], env?: NodeJS.ProcessEnv): Promise<string> {
+  return new Promise((resolve, reject) => {
+    execFile('git', args, { cwd, env, maxBuffer: 64 * 1024 * 1024 }, (err, stdout, stderr) => {
+      if (err) {
+        gitLogger.debug('git %s failed: %s', args.join(' '), stderr || err.message);
+        reject(new Error(`git ${args[0]} failed: ${(stderr || err.message).trim()}`));
+        return;
+      }
+      resolve(stdout);
+    });
+  });
+}
+
+/**
+ * Check whether a directory is inside a git work tree
+ */
+export async function isGitRepository(dir: string): Promise<boolean> {
+  try {
+    const out = await runGit(dir, ['rev-parse', '--is-inside-work-tree']);
+    return out.trim() === 'true';
+  } catch (err) {
+    return false;
+  }
+}
+
+/**
+ * Parse `git blame --porcelain` output into per-line blame info (1-indexed)
+ */
+export function parseBlamePorcelain(output: string): Map<number, BlameInfo> {
+  const result = new Map<number, BlameInfo>();
+  const commits = new Map<string, BlameInfo>();
+  const lines = output.split('\n');
+
+  let current: BlameInfo | null = null;
+  let currentLine = 0;
+
+  for (const line of lines) {
+    const header = line.match(/^([0-9a-f]{40}) \d+ (\d+)/);
+    if (header) {
+      const commit = header[1];
+      currentLine = parseInt(header[2], 10);
+      current = commits.get(commit) ?? {
+        commit,
+        author: '',
+        authorMail: '',
+        authorTime: 0,
+        summary: '',
+      };
+      commits.set(commit, current);
+      continue;
+    }
+
+    if (!current) {
+      continue;
+    }
+
+    if (line.startsWith('author ')) {
+      current.author = line.substring(7);
+    } else if (line.startsWith('author-mail ')) {
+      current.authorMail = line.substring(12).replace(/^<|>$/g, '');
+    } else if (line.startsWith('author-time ')) {
+      current.authorTime = parseInt(line.substring(12), 10);
+    } else if (line.startsWith('summary ')) {
+      current.summary = line.substring(8);
+    } else if (line.startsWith('\t')) {
+      // Content line terminates the entry
+      result.set(currentLine, current);
+    }
+  }
+
+  return result;
+}
+
+/**
+ * Blame a file, returning info for each line (1-indexed)
+ * With a revision, the file is blamed as it was at that commit
+ */
+export async function blameFile(filePath: string, revision?: string): Promise<Map<number, BlameInfo>> {
+  const output = await runGit(path.dirname(filePath), ['blame', '--porcelain', ...(revision ? [revision] : []), '--', path.basename(filePath)]);
+  return parseBlamePorcelain(output);
+}
+
+/**
+ * Git status of a single file
+ */
+export type FileGitStatus =
+  | 'unmodified' | 'modified' | 'added' | 'deleted' | 'renamed' | 'conflicted'
+  | 'untracked' | 'ignored' | 'not in a repository';
+
+/**
+ * Describe a `git status --porcelain` XY code; the work tree state wins over the index
+ */
+export function describeStatusCode(code: string): FileGitStatus {
+  if (code === '??') {
+    return 'untracked';
+  }
+  if (code === '!!') {
+    return 'ignored';
+  }
+  if (code.includes('U') || code === 'AA' || code === 'DD') {
+    return 'conflicted';
+  }
+  for (const c of [code[1], code[0]]) {
+    switch (c) {
+      case 'M':
+      case 'T':
+        return 'modified';
+      case 'A':
+        return 'added';
+      case 'D':
+        return 'deleted';
+      case 'R':
+      case 'C':
+        return 'renamed';
+    }
+  }
+  return 'unmodified';
+}
+
+/**
+ * Git status of a file: whether it is tracked, and whether it has uncommitted changes
+ */
+export async function fileGitStatus(filePath: string): Promise<FileGitStatus> {
+  const dir = path.dirname(filePath);
+  const name = path.basename(filePath);
+  let output: string;
+  try {
+    output = await runGit(dir, ['status', '--porcelain=v1', '--ignored', '-z', '--', name]);
+  } catch (err) {
+    return 'not in a repository';
+  }
+  if (output.length >= 2) {
+    return describeStatusCode(output.substring(0, 2));
+  }
+  return 'unmodified';
+}
+
+/**
+ * Uncommitted state of a file: new to git, changes in the index, in the work tree, or both
+ */
+export type WorkingChange = 'untracked' | 'staged' | 'modified' | 'staged, modified' | 'conflicted';
+
+/**
+ * Describe a `git status --porcelain` XY code by where its changes are, or
+ * undefined when it has none (ignored files)
+ */
+export function describeWorkingChange(code: string): WorkingChange | undefined {
+  if (code === '??') {
+    return 'untracked';
+  }
+  if (code === '!!') {
+    return undefined;
+  }
+  if (describeStatusCode(code) === 'conflicted') {
+    return 'conflicted';
+  }
+  const staged = code[0] !== ' ';
+  const modified = code[1] !== ' ';
+  return staged && modified ? 'staged, modified' : staged ? 'staged' : modified ? 'modified' : undefined;
+}
+
+/**
+ * Files with uncommitted changes under a directory, by path relative to it;
+ * undefined when the directory is not in a repository
+ */
+export async function workingChanges(dir: string): Promise<Map<string, WorkingChange> | undefined> {
+  let prefix: string;
+  let output: string;
+  try {
+    prefix = (await runGit(dir, ['rev-parse', '--show-prefix'])).trim();
+    output = await runGit(dir, ['status', '--porcelain=v1', '-z', '--untracked-files=all', '--', '.']);
+  } catch (err) {
+    return undefined;
+  }
+  const changes = new Map<string, WorkingChange>();
+  const entries = output.split('\0');
+  for (let i = 0; i < entries.length; i++) {
+    const entry = entries[i];
+    if (entry.length < 4) {
+      continue;
+    }
+    const code = entry.substring(0, 2);
+    // A rename or copy is followed by an entry holding its source
+    if (code[0] === 'R' || code[0] === 'C') {
+      i++;
+    }
+    const change = describeWorkingChange(code);
+    // Paths are relative to the repository root
+    const repoPath = entry.substring(3);
+    if (change && repoPath.startsWith(prefix)) {
+      changes.set(repoPath.substring(prefix.length).split('/').join(path.sep), change);
+    }
+  }
+  return changes;
+}
+
+/**
+ * The latest commit that touched a file
+ */
+export interface FileCommit {
+  commit: string;
+  author: string;
+  authorTime: number; // Unix timestamp in seconds
+  summary: string;
+}
+
+/**
+ * Parse `git log --name-only` output in the format recentlyCommittedFiles uses
+ * into the latest commit per path; the log lists newest commits first
+ */
+export function parseNameOnlyLog(output: string): Map<string, FileCommit> {
+  const result = new Map<string, FileCommit>();
+  for (const record of output.split('\x1e')) {
+    const [header, ...files] = record.split('\n');
+    const fields = header.split('\x1f');
+    if (fields.length < 4) {
+      continue;
+    }
+    const info: FileCommit = {
+      commit: fields[0],
+      author: fields[1],
+      authorTime: parseInt(fields[2], 10),
+      summary: fields.slice(3).join('\x1f'),
+    };
+    for (const file of files) {
+      if (file && !result.has(file)) {
+        result.set(file, info);
+      }
+    }
+  }
+  return result;
+}
+
+/**
+ * Files changed by the last maxCommits commits, relative to dir, with the latest commit of each
+ */
+export async function recentlyCommittedFiles(dir: string, maxCommits = 500): Promise<Map<string, FileCommit>> {
+  const output = await runGit(dir, [
+    '-c', 'core.quotePath=false', 'log', `-n${maxCommits}`, '--relative', '--name-only', '--no-renames',
+    '--format=%x1e%H%x1f%an%x1f%at%x1f%s', '--', '.',
+  ]);
+  return parseNameOnlyLog(output);
+}
+
+/**
+ * A commit that changed a range of lines, from `git log -L`
+ */
+export interface LineRangeCommit extends FileCommit {
+  path: string; // The file's path relative to the repository root, at that commit
+  added: number;
+  removed: number;
+  patch: string; // The diff of the range
+}
+
+/**
+ * Parse `git log -L` output in the format lineRangeHistory uses, newest commit first
+ */
+export function parseLineRangeLog(output: string): LineRangeCommit[] {
+  const result: LineRangeCommit[] = [];
+  for (const record of output.split('\x1e')) {
+    const newline = record.indexOf('\n');
+    const fields = (newline === -1 ? record : record.substring(0, newline)).split('\x1f');
+    if (fields.length < 4) {
+      continue;
+    }
+    const patch = newline === -1 ? '' : record.substring(newline + 1).trim();
+    let filePath = '';
+    let added = 0;
+    let removed = 0;
+    let inHeader = false;
+    for (const line of patch.split('\n')) {
+      if (line.startsWith('diff --git ')) {
+        inHeader = true;
+      } else if (line.startsWith('@@')) {
+        inHeader = false;
+      } else if (inHeader) {
+        if (line.startsWith('+++ ')) {
+          filePath = line.substring(4).replace(/^b\//, '');
+        }
+      } else if (line.startsWith('+')) {
+        added++;
+      } else if (line.startsWith('-')) {
+        removed++;
+      }
+    }
+    result.push({
+      commit: fields[0],
+      author: fields[1],
+      authorTime: parseInt(fields[2], 10),
+      summary: fields.slice(3).join('\x1f'),
+      path: filePath,
+      added,
+      removed,
+      patch,
+    });
+  }
+  return result;
+}
+
+/**
+ * Commits that changed lines start-end (1-indexed) of a file as of HEAD, newest first
+ * git follows the lines through edits above them and through file renames
+ */
+export async function lineRangeHistory(filePath: string, start: number, end: number, maxCommits = 20): Promise<LineRangeCommit[]> {
+  const output = await runGit(path.dirname(filePath), [
+    '-c', 'core.quotePath=false', 'log', `-n${maxCommits}`, `-L${start},${end}:${path.basename(filePath)}`,
+    '--format=%x1e%H%x1f%an%x1f%at%x1f%s',
+  ]);
+  return parseLineRangeLog(output);
+}
+
+/**
+ * Lines a commit changed in one file
+ */
+export interface FileChurn {
+  path: string;
+  added: number; // 0 for binary files
+  removed: number;
+}
+
+/**
+ * A commit with the lines it changed per file
+ */
+export interface CommitChurn extends FileCommit {
+  files: FileChurn[];
+}
+
+/**
+ * Parse `git log --numstat` output in the format commitChurn uses
+ */
+export function parseNumstatLog(output: string): CommitChurn[] {
+  const result: CommitChurn[] = [];
+  for (const record of output.split('\x1e')) {
+    const [header, ...lines] = record.split('\n');
+    const fields = header.split('\x1f');
+    if (fields.length < 4) {
+      continue;
+    }
+    const files: FileChurn[] = [];
+    for (const line of lines) {
+      const match = /^(\d+|-)\t(\d+|-)\t(.+)$/.exec(line);
+      if (match) {
+        files.push({
+          path: match[3],
+          added: match[1] === '-' ? 0 : parseInt(match[1], 10),
+          removed: match[2] === '-' ? 0 : parseInt(match[2], 10),
+        });
+      }
+    }
+    result.push({
+      commit: fields[0],
+      author: fields[1],
+      authorTime: parseInt(fields[2], 10),
+      summary: fields.slice(3).join('\x1f'),
+      files,
+    });
+  }
+  return result;
+}
+
+/**
+ * Non-merge commits of the last sinceDays days under a pathspec, with paths relative to dir
+ * Authors are read through the mailmap, so one person's addresses count once
+ */
+export async function commitChurn(dir: string, sinceDays: number, pathspec = '.'): Promise<CommitChurn[]> {
+  const output = await runGit(dir, [
+    '-c', 'core.quotePath=false', 'log', `--since=${sinceDays} days ago`, '--no-merges', '--relative', '--numstat',
+    '--no-renames', '--format=%x1e%H%x1f%aN%x1f%at%x1f%s', '--', pathspec,
+  ]);
+  return parseNumstatLog(output);
+}
+
+/**
+ * A file changed between two revisions
+ */
+export interface ChangedFile {
+  status: 'added' | 'deleted' | 'modified' | 'renamed';
+  path: string; // Relative to dir, in the newer revision (the older one for deleted files)
+  oldPath?: string; // For renamed files
+}
+
+/**
+ * Parse `git diff --name-status` output
+ */
+export function parseNameStatus(output: string): ChangedFile[] {
+  const result: ChangedFile[] = [];
+  for (const line of output.split('\n')) {
+    const [code, first, second] = line.split('\t');
+    if (!code || !first) {
+      continue;
+    }
+    switch (code[0]) {
+      case 'A':
+        result.push({ status: 'added', path: first });
+        break;
+      case 'D':
+        result.push({ status: 'deleted', path: first });
+        break;
+      case 'R':
+        result.push({ status: 'renamed', path: second ?? first, oldPath: first });
+        break;
+      case 'C':
+        result.push({ status: 'added', path: second ?? first });
+        break;
+      default:
+        result.push({ status: 'modified', path: first });
+    }
+  }
+  return result;
+}
+
+/**
+ * Files changed from one revision to another, or to the work tree when to is
+ * undefined, with renames detected; paths are relative to dir
+ */
+export async function changedFiles(dir: string, from: string, to?: string): Promise<ChangedFile[]> {
+  const output = await runGit(dir, [
+    '-c', 'core.quotePath=false', 'diff', '--name-status', '--relative', '-M', from, ...(to ? [to] : []), '--', '.',
+  ]);
+  return parseNameStatus(output);
+}
+
+/**
+ * Commit a revision names, as a full hash
+ */
+export async function resolveRevision(dir: string, revision: string): Promise<string> {
+  return (await runGit(dir, ['rev-parse', '--verify', '--quiet', `${revision}^{commit}`])).trim();
+}
+
+/**
+ * Best common ancestor of two revisions
+ */
+export async function mergeBase(dir: string, a: string, b: string): Promise<string> {
+  return (await runGit(dir, ['merge-base', a, b])).trim();
+}
+
+/**
+ * Content of a file, relative to dir, at a revision
+ */
+export function fileAtRevision(dir: string, revision: string, relativePath: string): Promise<string> {
+  return runGit(dir, ['show', `${revision}:./${relativePath.split(path.sep).join('/')}`]);
+}
+
+/**
+ * Bytes of a file, relative to dir, at a revision, for content that may not be text
+ */
+export function fileBytesAtRevision(dir: string, revision: string, relativePath: string): Promise<Buffer> {
+  const args = ['show', `${revision}:./${relativePath.split(path.sep).join('/')}`];
+  return new Promise((resolve, reject) => {
+    execFile('git', args, { cwd: dir, encoding: 'buffer', maxBuffer: 64 * 1024 * 1024 }, (err, stdout, stderr) => {
+      if (err) {
+        reject(new Error(`git show failed: ${(stderr.toString() || err.message).trim()}`));
+        return;
+      }
+      resolve(stdout);
+    });
+  });
+}
+
+/**
+ * Files under dir at a revision, relative to dir
+ */
+export async function filesAtRevision(dir: string, revision: string): Promise<string[]> {
+  const output = await runGit(dir, ['-c', 'core.quotePath=false', 'ls-tree', '-r', '-z', '--name-only', revision, '--', '.']);
+  return output.split('\0').filter(Boolean).map((file) => file.split('/').join(path.sep));
+}
@@ -0,0 +1,72 @@
+/**
+ * Tests for remote repositories
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { RemoteRepositories, parseRemoteSpec, remoteName } from './remote';
+import { searchCode } from '../tools/search';
+
+describe('Remote repositories', () => {
+  let dir: string;
+  let upstream: string;
+
+  const git = (...args: string[]) => execFileSync('git', args, { cwd: upstream, encoding: 'utf8' }).trim();
+  const commit = (file: string, content: string, message: string) => {
+    fs.writeFileSync(path.join(upstream, file), content);
+    git('add', file);
+    git('-c', 'user.name=test', '-c', 'user.email=test@example.com', 'commit', '-q', '-m', message);
+  };
+
+  beforeEach(() => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'remote-'));
+    upstream = path.join(dir, 'upstream.git');
+    fs.mkdirSync(upstream);
+    git('init', '-q', '-b', 'main');
+    commit('lib.go', 'func Retry(attempts int) error { return nil }\n', 'first');
+    git('tag', 'v1');
+    commit('lib.go', 'func Retry(ctx context.Context, attempts int) error { return nil }\n', 'second');
+  });
+
+  afterEach(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  it('should read names and refs from URLs', () => {
+    expect(remoteName('https://github.com/owner/repo.git')).toBe('repo');
+    expect(remoteName('git@github.com:owner/my lib/')).toBe('my-lib');
+    expect(parseRemoteSpec('https://github.com/owner/repo.git#v1.2.0')).toEqual({ url: 'https://github.com/owner/repo.git', ref: 'v1.2.0' });
+    expect(parseRemoteSpec('https://github.com/owner/repo.git')).toEqual({ url: 'https://github.com/owner/repo.git' });
+  });
+
+  it('should shallow-clone a ref and make it searchable', async () => {
+    const remotes = new RemoteRepositories(path.join(dir, 'cache'));
+    const remote = await remotes.add(upstream, { ref: 'v1' });
+    expect(remote.name).toBe('upstream');
+    expect(remote.commit).toBe(git('rev-parse', 'v1'));
+    expect(execFileSync('git', ['rev-list', '--count', 'HEAD'], { cwd: remote.dir, encoding: 'utf8' }).trim()).toBe('1');
+
+    const result = await searchCode(remote.dir, 'func Retry');
+    expect(result).toContain('lib.go');
+    expect(result).toContain('Retry(attempts int)');
+
+    // Registering again moves the clone to the ref the remote now points at
+    const latest = await remotes.add(upstream);
+    expect(latest.commit).toBe(git('rev-parse', 'HEAD'));
+    expect(latest.dir).toBe(remote.dir);
+    expect(await searchCode(latest.dir, 'context.Context')).toContain('lib.go');
+    expect(remotes.names()).toEqual(['upstream']);
+  });
+
+  it('should reject unsafe URLs and conflicting names', async () => {
+    const remotes = new RemoteRepositories(path.join(dir, 'cache'));
+    expect(() => remotes.add('--upload-pack=touch /tmp/x')).toThrow('invalid repository URL');
+    expect(() => remotes.add('ext::sh -c touch% /tmp/x')).toThrow('ext:: repository URLs are not allowed');
+    expect(() => remotes.add(upstream, { ref: '--output=x' })).toThrow('invalid ref');
+    await remotes.add(upstream);
+    expect(() => remotes.add(path.join(dir, 'other', 'upstream.git'))).toThrow('already registered');
+    await expect(remotes.get('missing')).rejects.toThrow('unknown remote "missing" (registered: upstream)');
+  });
+});
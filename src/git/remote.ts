@@ -0,0 +1,163 @@
+/**
+ * Remote repositories - shallow clones searched alongside the workspace
+ * A remote is fetched at a single ref with --depth 1 into the cache
+ * directory, so upstream code can be searched without a full clone.
+ * Fetching again moves the clone to the ref's current commit
+ */
+
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { runGit } from './git.js';
+import { ToolError } from '../workspace/errors.js';
+
+const gitLogger = createLogger(Component.TOOLS);
+
+/**
+ * A registered remote repository
+ */
+export interface RemoteRepository {
+  name: string;
+  url: string;
+  // Branch, tag, or commit that was fetched (default: the remote's HEAD)
+  ref?: string;
+  dir: string;
+  commit: string;
+  fetchedAt: number;
+}
+
+/**
+ * Directory holding the clones, under the user cache directory unless REMOTE_CACHE_DIR is set
+ */
+export function defaultRemoteDir(env: NodeJS.ProcessEnv = process.env): string {
+  if (env.REMOTE_CACHE_DIR) {
+    return path.resolve(env.REMOTE_CACHE_DIR);
+  }
+  return path.join(env.XDG_CACHE_HOME || path.join(os.homedir(), '.cache'), 'grep-for-code', 'remotes');
+}
+
+/**
+ * Read a configured remote written as "url" or "url#ref"
+ */
+export function parseRemoteSpec(spec: string): { url: string; ref?: string } {
+  const sep = spec.lastIndexOf('#');
+  return sep < 0 ? { url: spec } : { url: spec.substring(0, sep), ref: spec.substring(sep + 1) || undefined };
+}
+
+/**
+ * Default name of a remote: the last path component of its URL, without .git
+ */
+export function remoteName(url: string): string {
+  const base = url.replace(/[/\\]+$/, '').split(/[/\\:]/).pop() ?? '';
+  return base.replace(/\.git$/, '').replace(/[^A-Za-z0-9._-]/g, '-') || 'remote';
+}
+
+/**
+ * Reject URLs and refs that git would read as options or run as commands
+ */
+function validate(url: string, ref?: string): void {
+  if (!url || url.startsWith('-')) {
+    throw new ToolError('invalid-argument', `invalid repository URL: ${url}`);
+  }
+  if (/^ext::/i.test(url)) {
+    throw new ToolError('invalid-argument', 'ext:: repository URLs are not allowed');
+  }
+  if (ref !== undefined && (!ref || ref.startsWith('-') || /\s/.test(ref))) {
+    throw new ToolError('invalid-argument', `invalid ref: ${ref}`);
+  }
+}
+
+/**
+ * Remote repositories registered with the server
+ */
+export class RemoteRepositories {
+  private remotes = new Map<string, Promise<RemoteRepository>>();
+  private urls = new Map<string, string>();
+
+  constructor(private cacheDir: string = defaultRemoteDir()) {}
+
+  /**
+   * Clone a remote, or fetch it again when it is already registered
+   * Resolves once the ref is checked out
+   */
+  add(url: string, options: { ref?: string; name?: string } = {}): Promise<RemoteRepository> {
+    validate(url, options.ref);
+    const name = options.name ?? remoteName(url);
+    if (!/^[A-Za-z0-9._-]+$/.test(name)) {
+      throw new ToolError('invalid-argument', `invalid remote name: ${name}`);
+    }
+    const registered = this.urls.get(name);
+    if (registered !== undefined && registered !== url) {
+      throw new ToolError('invalid-argument', `remote "${name}" is already registered for ${registered}; pass a different name`);
+    }
+    this.urls.set(name, url);
+
+    // Clones of the same URL share a directory, so a re-fetch reuses the objects
+    const key = crypto.createHash('sha256').update(url).digest('hex').substring(0, 16);
+    const dir = path.join(this.cacheDir, `${name}-${key}`);
+    const previous = this.remotes.get(name)?.catch(() => undefined);
+    const fetching = (async () => {
+      await previous;
+      return this.fetch(name, url, dir, options.ref);
+    })();
+    this.remotes.set(name, fetching);
+    fetching.catch((err) => gitLogger.error('Failed to fetch remote %s: %s', name, (err as Error).message));
+    return fetching;
+  }
+
+  /**
+   * A registered remote, waiting for its fetch to finish
+   */
+  async get(name: string): Promise<RemoteRepository> {
+    const remote = this.remotes.get(name);
+    if (!remote) {
+      const names = this.names();
+      throw new ToolError('not-found', `unknown remote "${name}"` + (names.length > 0 ? ` (registered: ${names.join(', ')})` : ''));
+    }
+    return remote;
+  }
+
+  /**
+   * Names of the registered remotes
+   */
+  names(): string[] {
+    return Array.from(this.remotes.keys()).sort();
+  }
+
+  /**
+   * Remotes that were fetched successfully
+   */
+  async list(): Promise<RemoteRepository[]> {
+    const remotes = await Promise.all(this.names().map((name) => this.get(name).catch(() => undefined)));
+    return remotes.filter((remote): remote is RemoteRepository => remote !== undefined);
+  }
+
+  private async fetch(name: string, url: string, dir: string, ref?: string): Promise<RemoteRepository> {
+    const start = Date.now();
+    // Never prompt for credentials on the server's terminal
+    const env = { ...process.env, GIT_TERMINAL_PROMPT: '0' };
+    const git = (args: string[]) => runGit(dir, ['-c', 'protocol.ext.allow=never', ...args], env);
+
+    await fs.promises.mkdir(dir, { recursive: true });
+    if (!fs.existsSync(path.join(dir, '.git'))) {
+      await git(['init', '-q']);
+      await git(['remote', 'add', 'origin', url]);
+    }
+    // Fetching the ref by name works for branches, tags, and (on most hosts) commits
+    await git(['fetch', '-q', '--depth', '1', '--no-tags', 'origin', ref ?? 'HEAD']);
+    await git(['checkout', '-q', '--force', '--detach', 'FETCH_HEAD']);
+    const commit = (await git(['rev-parse', 'HEAD'])).trim();
+
+    gitLogger.info('Fetched remote %s (%s) at %s in %dms', name, ref ?? 'HEAD', commit.substring(0, 12), Date.now() - start);
+    return { name, url, ref, dir, commit, fetchedAt: Date.now() };
+  }
+}
+
+/**
+ * Format a remote as one line
+ */
+export function formatRemote(remote: RemoteRepository): string {
+  return `${remote.name}: ${remote.url} at ${remote.ref ?? 'HEAD'} (${remote.commit.substring(0, 12)})`;
+}
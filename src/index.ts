@@ -13,18 +13,143 @@ import {
 } from '@modelcontextprotocol/sdk/types.js';
 import * as path from 'path';
 import * as fs from 'fs';
-import { createLogger, Component } from './logging/logger.js';
-import { LSPClient } from './lsp/client.js';
+import * as crypto from 'crypto';
+import * as http from 'http';
+import { createLogger, Component, LogLevel, reloadLoggingFromEnv, withLogContext } from './logging/logger.js';
+import { LSPClient, LaunchOptions } from './lsp/client.js';
 import { WorkspaceWatcher } from './watcher/watcher.js';
 import { readDefinition } from './tools/definition.js';
-import { findReferences } from './tools/references.js';
+import { findReferences, ReferenceAccess, ReferenceScope } from './tools/references.js';
+import { indexedDefinition, indexedReferences } from './tools/codeindex.js';
+import { CodeIndex, loadCodeIndex } from './symbols/codeindex.js';
 import { getHoverInfo } from './tools/hover.js';
 import { getDiagnosticsForFile } from './tools/diagnostics.js';
 import { applyTextEdits, TextEdit } from './tools/edit.js';
 import { renameSymbol } from './tools/rename.js';
+import { WRITE_TOOLS, toolDisabledReason } from './tools/availability.js';
+import { findTodos } from './tools/todos.js';
+import { getApiSurface } from './tools/api.js';
+import { formatSymbolUsage, symbolUsage } from './tools/usage.js';
+import { exploreSymbol, formatExploredSymbol } from './tools/explore.js';
+import { VocabularyKind, formatVocabulary, workspaceVocabulary } from './tools/vocabulary.js';
+import { pluginDisabledReason, pluginToolSchema, runPluginTool } from './tools/plugins.js';
+import { clearMatchers, matcherFilter } from './search/matchers.js';
+import { formatTestPairing, pairTests } from './tools/testpairs.js';
+import { formatReplacements, replaceText } from './tools/replace.js';
+import { formatRefactorPlan, planRefactor } from './tools/refactorplan.js';
+import { findDuplicates } from './tools/duplicates.js';
+import { findSimilar, formatSimilar } from './tools/similar.js';
+import { getImpactReport, ChangeKind } from './tools/impact.js';
+import { semanticSearch, SearchMode } from './tools/semantic.js';
+import { QueryCache, TreeState, queryCacheOptionsFromEnv, queryKey } from './search/queryCache.js';
+import { searchCode, searchCodeResult, resultTemplate, SearchProgressCallback, SearchCodeOptions, SearchSort } from './tools/search.js';
+import { findJsx } from './tools/jsx.js';
+import { queryConfig } from './tools/keypath.js';
+import { protoReferences } from './tools/proto.js';
+import { listBuildTargets } from './tools/targets.js';
+import { listBazelTargets } from './tools/bazel.js';
+import { bazelTargetFiles } from './workspace/bazel.js';
+import { sqlSearch } from './tools/sql.js';
+import { analyzeDockerFiles } from './tools/docker.js';
+import { getFileOutline } from './tools/outline.js';
+import { readChunk, readRange } from './tools/read.js';
+import { directoryTree } from './tools/tree.js';
+import { getFileInfo } from './tools/info.js';
+import { BatchQuery, MAX_BATCH_QUERIES, batchSearch } from './tools/batch.js';
+import { listEntryPoints } from './tools/entrypoints.js';
+import { listRoutes } from './tools/routes.js';
+import { listEnvVars } from './tools/envvars.js';
+import { findMessages } from './tools/i18n.js';
+import { listFeatureFlags } from './tools/flags.js';
+import { QueryWatches, describeWatch, formatWatchDiff, formatWatches } from './tools/watch.js';
+import { EntryPointKind } from './workspace/entrypoints.js';
+import { recentFiles } from './tools/recent.js';
+import { workspaceStats } from './tools/stats.js';
+import { warmupWorkspace, formatWarmup, openCentralFiles, warmupSettingsFromEnv } from './tools/warmup.js';
+import { goInterfaceReport } from './tools/gointerfaces.js';
+import { findTypedValues } from './tools/typed.js';
+import { findPackageUsages, formatPackageUsages } from './tools/gopackages.js';
+import { findImporters, formatImporters } from './tools/importers.js';
+import { goErrorChecks } from './tools/goerrors.js';
+import { findMessageOrigins, formatMessageOrigins } from './tools/origins.js';
+import { resolveStackTrace } from './tools/stacktrace.js';
+import { symbolHistory } from './tools/history.js';
+import { churnReport } from './tools/churn.js';
+import { symbolDiff } from './tools/symboldiff.js';
+import { searchDiff } from './tools/searchdiff.js';
+import { describePin, formatPins, resolvePins, PinSet } from './tools/pins.js';
+import { createBookmark, describeBookmark, formatResolvedBookmarks, resolveBookmark, BookmarkSet, ResolvedBookmark } from './tools/bookmarks.js';
+import { formatPinnedSnapshot, formatSnapshots } from './tools/snapshot.js';
+import { SnapshotStore, takeSnapshot, unpinned, withSnapshot } from './workspace/treesnapshot.js';
+import { findLinkedReferences, formatLinkedReferences } from './tools/links.js';
+import { planSearch, formatSearchPlan } from './tools/plan.js';
+import { explainQuery, formatExplanation, ExplainTool } from './tools/explain.js';
+import { getCapabilities, formatCapabilities } from './tools/capabilities.js';
+import { RemoteRepositories, formatRemote, parseRemoteSpec } from './git/remote.js';
+import { TrigramIndex } from './search/trigram.js';
+import { LexicalSearchOptions, searchDefaultsFromEnv } from './search/lexical.js';
+import { getFileSymbols, usesPythonFallback, FlatSymbol } from './tools/symbols.js';
+import { SemanticSearchEngine } from './semantic/engine.js';
+import { createEmbeddingProvider, embeddingConfigFromEnv } from './semantic/providers.js';
+import { defaultStorePath } from './semantic/store.js';
+import { resolveWorkspacePath } from './workspace/walker.js';
+import { redactSecrets, redactionEnabled } from './search/secrets.js';
+import { CallLimiter, ThrottledError } from './workspace/throttle.js';
+import { BudgetExceededError, BudgetTracker, unbudgeted, withBudget } from './workspace/budget.js';
+import { runGroup, uncancellable, withCancellation } from './workspace/cancellation.js';
+import { AuditEntry, sharedAuditLog } from './logging/audit.js';
+import { buildContext } from './workspace/buildtags.js';
+import { isPythonWorkspace, sharedPythonSymbols } from './symbols/python.js';
+import { sharedProtoSymbols } from './symbols/proto.js';
+import { JsxSearchOptions, jsxSearchAvailable, validateJsxQuery } from './search/jsx.js';
+import { parseKeyPath } from './search/keypath.js';
+import { sharedOverlay } from './workspace/overlay.js';
+import { PROJECT_SCOPED_TOOLS, Project, detectProjects, findProject } from './workspace/projects.js';
+import { WorkspaceRoots, formatRoot } from './workspace/roots.js';
+import { parseSearchScope } from './workspace/fixtures.js';
+import { parseQuery, resolveKinds, resolveLanguage } from './search/query.js';
+import { canonicalizePath } from './workspace/paths.js';
+import { runBenchmark, formatBenchReport } from './search/bench.js';
+import { parseSearchArgs, runSearchCommand, SearchCommand, SEARCH_USAGE } from './cli/search.js';
+import { resultError, runRepl } from './cli/repl.js';
+import { parseServeArgs, startSearchServer, ServeCommand, SERVE_USAGE } from './cli/serve.js';
+import { parseIndexArgs, runIndexCommand, IndexCommand, INDEX_USAGE } from './cli/snapshot.js';
+import { loadImportedSnapshot } from './search/snapshot.js';
+import { registry, toolCalls, toolDuration, lspRestarts } from './metrics/metrics.js';
+import { metricsAddressFromEnv, startMetricsServer, healthReport, HealthCheck, HealthReport } from './metrics/http.js';
+import {
+  FileConfig,
+  LinkRule,
+  ResultTemplate,
+  PluginTool,
+  CONFIG_PATH_ENV,
+  applyConfigEnv,
+  configFromEnv,
+  findDefaultConfig,
+  findWorkspaceConfig,
+  launchSettingsFor,
+  loadConfigFile,
+  mergeConfigs,
+} from './config/config.js';
+import { ConfigWatcher, ConfigReload, applyMode, describeChanges } from './config/reload.js';
+import { IdleTracker, usesLanguageServer } from './lsp/idle.js';
+import { ToolError, toToolError } from './workspace/errors.js';
+import { CallDrain, shutdownTimeoutFromEnv } from './workspace/drain.js';
 
 const coreLogger = createLogger(Component.CORE);
 
+/**
+ * Tools that need a running language server; the rest also work without one
+ * (definition and api_surface then cover Python files only)
+ */
+const LSP_TOOLS = new Set(['references', 'diagnostics', 'hover', 'rename_symbol', 'edit_file', 'impact_report']);
+
+/**
+ * Manifests that declare monorepo projects
+ */
+const PROJECT_MANIFESTS = new Set(['go.work', 'go.mod', 'package.json', 'pnpm-workspace.yaml', 'Cargo.toml',
+  'CMakeLists.txt', 'settings.gradle', 'settings.gradle.kts', 'pom.xml']);
+
 /**
  * Configuration
  */
@@ -32,21 +157,99 @@ interface Config {
   workspaceDir: string;
   lspCommand: string;
   lspArgs: string[];
+  // Working directory, PATH additions, and environment of the language server (cwd and path relative to the workspace)
+  lspLaunch: LaunchOptions;
+  // Default globs for search_code, from the configuration files
+  globs?: string[];
+  // Remote repositories to clone at startup, as "url" or "url#ref"
+  remotes?: string[];
+  // Rules linking symbols across language boundaries, for references
+  links?: LinkRule[];
+  // How search results are rendered, by client profile
+  templates?: Record<string, ResultTemplate>;
+  // Tools served by running a command, from the configuration files
+  plugins?: PluginTool[];
+  // WebAssembly modules that filter search_code matches, by name
+  matchers?: Record<string, string>;
+  // Run the search benchmark instead of the server
+  bench?: { iterations: number; json: boolean };
+  // Run a single search from the command line instead of the server
+  search?: SearchCommand;
+  // Export or import an index snapshot instead of running the server
+  index?: IndexCommand;
+  // Serve searches over HTTP, streamed as NDJSON, instead of serving MCP
+  serve?: ServeCommand;
+  // Read tool calls from the terminal instead of serving MCP
+  repl?: boolean;
+  // Run the warmup tool once and exit
+  warmup?: boolean;
+  // Leave out every tool that writes to disk
+  readOnly?: boolean;
+  // What the configuration watcher needs to re-apply the files
+  reload?: { configPath?: string; fileConfig?: FileConfig; owned: string[]; fixed: string[] };
+}
+
+/**
+ * Language server launch settings for the workspace; each GREPFORCODE_LSP_* variable replaces its file setting
+ */
+function launchSettings(fileConfig: FileConfig | undefined, envConfig: FileConfig, workspaceDir: string): LaunchOptions {
+  const file = launchSettingsFor(fileConfig, workspaceDir);
+  return {
+    cwd: envConfig.lspCwd ?? file.cwd,
+    path: envConfig.lspPath ?? file.path,
+    env: envConfig.lspEnv ?? file.env,
+  };
 }
 
 /**
  * Parse command line arguments
+ * Settings missing from the command line come from GREPFORCODE_* environment
+ * variables, then the workspace configuration file, then the global one
  */
 function parseConfig(): Config {
   const args = process.argv.slice(2);
 
   let workspaceDir = '';
   let lspCommand = '';
-  const lspArgs: string[] = [];
+  let lspArgs: string[] = [];
+  let configPath = '';
+  let bench: Config['bench'];
+  let search: SearchCommand | undefined;
+  let index: IndexCommand | undefined;
+  let serve: ServeCommand | undefined;
+  let readOnly = false;
+  const repl = args[0] === 'repl';
+  const warmup = args[0] === 'warmup';
 
   let i = 0;
   let foundDash = false;
 
+  // The search subcommand has its own options; the loop below only picks out the shared ones
+  if (args[0] === 'search') {
+    if (args.includes('--help') || args.includes('-h')) {
+      process.stdout.write(SEARCH_USAGE);
+      process.exit(0);
+    }
+    search = parseSearchArgs(args.slice(1));
+    i = 1;
+  } else if (args[0] === 'index') {
+    if (args.includes('--help') || args.includes('-h')) {
+      process.stdout.write(INDEX_USAGE);
+      process.exit(0);
+    }
+    index = parseIndexArgs(args.slice(1));
+    i = 1;
+  } else if (args[0] === 'serve') {
+    if (args.includes('--help') || args.includes('-h')) {
+      process.stdout.write(SERVE_USAGE);
+      process.exit(0);
+    }
+    serve = parseServeArgs(args.slice(1));
+    i = 1;
+  } else if (repl || warmup) {
+    i = 1;
+  }
+
   while (i < args.length) {
     if (args[i] === '--workspace') {
       workspaceDir = args[i + 1];
@@ -54,6 +257,21 @@ function parseConfig(): Config {
     } else if (args[i] === '--lsp') {
       lspCommand = args[i + 1];
       i += 2;
+    } else if (args[i] === '--config') {
+      configPath = args[i + 1];
+      i += 2;
+    } else if (args[i] === '--bench') {
+      bench = { iterations: 5, json: false, ...bench };
+      i++;
+    } else if (args[i] === '--bench-iterations') {
+      bench = { json: false, ...bench, iterations: parseInt(args[i + 1], 10) };
+      i += 2;
+    } else if (args[i] === '--bench-json') {
+      bench = { iterations: 5, ...bench, json: true };
+      i++;
+    } else if (args[i] === '--read-only') {
+      readOnly = true;
+      i++;
     } else if (args[i] === '--') {
       foundDash = true;
       i++;
@@ -63,305 +281,3804 @@ function parseConfig(): Config {
     }
   }
 
-  // Remaining args after -- are LSP arguments
-  if (foundDash) {
-    lspArgs.push(...args.slice(i));
-  }
+  // Remaining args after -- are LSP arguments
+  if (foundDash) {
+    lspArgs.push(...args.slice(i));
+  }
+
+  if (configPath === undefined) {
+    throw new Error('--config requires a file path');
+  }
+  const { config: envConfig, unknown } = configFromEnv();
+  const globalFile = configPath || process.env[CONFIG_PATH_ENV] || findDefaultConfig();
+  let fileConfig: FileConfig | undefined = globalFile ? loadConfigFile(globalFile) : undefined;
+  workspaceDir = workspaceDir || envConfig.workspace || fileConfig?.workspace || (search || index || serve ? process.cwd() : '');
+
+  // Validate
+  if (!workspaceDir) {
+    throw new Error('workspace directory is required (--workspace <dir>)');
+  }
+
+  if (bench && !(bench.iterations > 0)) {
+    throw new Error('--bench-iterations must be a positive number');
+  }
+
+  // Get absolute path, spelled as on disk
+  workspaceDir = canonicalizePath(path.resolve(workspaceDir));
+
+  if (!fs.existsSync(workspaceDir)) {
+    throw new Error(`workspace directory does not exist: ${workspaceDir}`);
+  }
+
+  const workspaceFile = findWorkspaceConfig(workspaceDir);
+  if (workspaceFile) {
+    fileConfig = mergeConfigs(fileConfig, loadConfigFile(workspaceFile, 'workspace'));
+  }
+  // GREPFORCODE_* variables replace the variables they stand for
+  const applied = applyConfigEnv(envConfig, process.env, true);
+  const fileApplied = fileConfig ? applyConfigEnv(fileConfig) : [];
+  if ([...applied, ...fileApplied].some((name) => name.startsWith('LOG_'))) {
+    reloadLoggingFromEnv();
+  }
+  for (const file of [globalFile, workspaceFile].filter(Boolean)) {
+    coreLogger.info('Loaded configuration from %s', file);
+  }
+  for (const name of unknown) {
+    coreLogger.warn('Ignoring unknown setting %s', name);
+  }
+  // Settings that override the files are not changed by a reload
+  const fixed = [
+    ...(lspCommand || envConfig.lspCommand ? ['lsp.command'] : []),
+    ...(envConfig.lspCwd ? ['lsp.cwd'] : []),
+    ...(envConfig.lspPath ? ['lsp.path'] : []),
+    ...(envConfig.lspEnv ? ['lsp.env'] : []),
+    ...(envConfig.globs ? ['search.glob'] : []),
+    ...(envConfig.remotes ? ['remotes'] : []),
+    ...(envConfig.links ? ['links'] : []),
+    ...(envConfig.templates ? ['templates'] : []),
+    ...(envConfig.plugins ? ['plugins'] : []),
+    ...(envConfig.matchers ? ['matchers'] : []),
+  ];
+  // LSP arguments only apply to the configured command
+  const lsp = envConfig.lspCommand ? envConfig : fileConfig;
+  if (!lspCommand && lsp?.lspCommand) {
+    lspCommand = lsp.lspCommand;
+    lspArgs = lsp.lspArgs ?? [];
+  }
+
+  // The benchmark, command line search, snapshots, and HTTP search without the UI do not use the language server
+  if (!lspCommand && !bench && !search && !index && !(serve && !serve.ui)) {
+    throw new Error('LSP command is required (--lsp <command>)');
+  }
+
+  return {
+    workspaceDir,
+    lspCommand,
+    lspArgs,
+    lspLaunch: launchSettings(fileConfig, envConfig, workspaceDir),
+    globs: envConfig.globs ?? fileConfig?.globs,
+    remotes: envConfig.remotes ?? fileConfig?.remotes,
+    links: envConfig.links ?? fileConfig?.links,
+    templates: envConfig.templates ?? fileConfig?.templates,
+    plugins: envConfig.plugins ?? fileConfig?.plugins,
+    matchers: envConfig.matchers ?? fileConfig?.matchers,
+    bench,
+    search,
+    index,
+    serve,
+    repl,
+    warmup,
+    readOnly,
+    reload: { configPath: configPath || process.env[CONFIG_PATH_ENV], fileConfig, owned: fileApplied, fixed },
+  };
+}
+
+/**
+ * Result of a tool call
+ */
+interface ToolResult {
+  content: Array<{ type: 'text'; text: string }>;
+  isError?: boolean;
+}
+
+/**
+ * Session of calls made in the process rather than by an MCP client: the web UI, the REPL, and warmup
+ */
+const LOCAL_SESSION = 'local';
+
+/**
+ * ID for the session of a new MCP connection
+ */
+function newSessionId(): string {
+  return `mcp-${crypto.randomBytes(4).toString('hex')}`;
+}
+
+/**
+ * Time the language server has to answer a health check ping
+ */
+const HEALTH_PING_TIMEOUT_MS = 5000;
+
+/**
+ * How often to check whether the language server went idle
+ */
+const IDLE_CHECK_INTERVAL_MS = 60_000;
+
+/**
+ * Time add_workspace waits for indexing before answering; indexing goes on in the background
+ */
+const WORKSPACE_INDEX_WAIT_MS = 2000;
+
+/**
+ * Longest argument value or result line kept in a request log entry
+ */
+const SUMMARY_LENGTH = 200;
+
+/**
+ * Shorten a value for a request log entry
+ */
+function clip(text: string): string {
+  return text.length > SUMMARY_LENGTH ? text.substring(0, SUMMARY_LENGTH) + '…' : text;
+}
+
+/**
+ * Summarize tool arguments for a request log entry, clipping long strings
+ */
+function summarizeArgs(args: Record<string, unknown> = {}): Record<string, unknown> {
+  return Object.fromEntries(Object.entries(args).map(([key, value]) =>
+    [key, typeof value === 'string' ? clip(value) : value]));
+}
+
+/**
+ * Main MCP server class
+ */
+class MCPLanguageServer {
+  private server: Server;
+  private lspClient?: LSPClient;
+  // Why the language server is not running, when startup went on without it
+  private lspError?: string;
+  // Set while the language server is stopped for being idle; the next call that needs it starts it
+  private lspSuspended = false;
+  private idleTracker = new IdleTracker();
+  private idleTimer?: NodeJS.Timeout;
+  // Stops the background warm-up of the running language server
+  private backgroundWarmup?: AbortController;
+  private workspaceWatcher?: WorkspaceWatcher;
+  private semanticEngine?: SemanticSearchEngine;
+  private trigramIndex: TrigramIndex;
+  private queryCache: QueryCache;
+  private metricsServer?: http.Server;
+  // Pending while the language server starts; tool calls wait for it
+  private initializing?: Promise<void>;
+  private initialized = false;
+  private configWatcher?: ConfigWatcher;
+  private remotes = new RemoteRepositories();
+  private roots: WorkspaceRoots;
+  private callLimiter = new CallLimiter();
+  // Session of the MCP client connected over stdio; a client initializing again starts a new one
+  private session = newSessionId();
+  // Files, bytes, and language server requests spent, by session
+  private budgets = new BudgetTracker();
+  // Workspace snapshots sessions are pinned to
+  private snapshots = new SnapshotStore();
+  // Running tool calls, waited for on shutdown
+  private drain = new CallDrain();
+  // Working sets pinned with pin_file, by session
+  private pins = new Map<string, PinSet>();
+  // Symbol-anchored bookmarks from bookmark_symbol, by session
+  private bookmarks = new Map<string, BookmarkSet>();
+  // Queries watched with watch_query, by session
+  private watches = new Map<string, QueryWatches>();
+  // Detected on first use, and again after a manifest changes
+  private projects?: Project[];
+  // LSIF or SCIP dump answering definitions and references for unchanged files
+  private codeIndex?: CodeIndex;
+
+  constructor(private config: Config) {
+    this.server = new Server(
+      {
+        name: 'MCP Language Server',
+        version: '0.0.2',
+      },
+      {
+        capabilities: {
+          tools: { listChanged: true },
+          logging: {},
+        },
+      }
+    );
+
+    this.trigramIndex = new TrigramIndex(config.workspaceDir);
+    this.roots = new WorkspaceRoots(config.workspaceDir);
+    this.queryCache = new QueryCache(new TreeState(config.workspaceDir), queryCacheOptionsFromEnv());
+
+    this.setupHandlers();
+    this.registerMetrics();
+  }
+
+  /**
+   * Expose index and cache sizes, read when metrics are scraped
+   */
+  private registerMetrics(): void {
+    registry.gauge('grepforcode_trigram_index_files', 'Files in the trigram index',
+      () => this.trigramIndex.getStats().files);
+    registry.gauge('grepforcode_trigram_index_trigrams', 'Distinct trigrams in the trigram index',
+      () => this.trigramIndex.getStats().trigrams);
+    registry.gauge('grepforcode_semantic_index_chunks', 'Chunks in the semantic index',
+      () => this.semanticEngine?.getStats().chunks ?? 0);
+    registry.counter('grepforcode_query_cache_hits_total', 'Tool results served from the query cache',
+      () => this.queryCache.getStats().hits);
+    registry.counter('grepforcode_query_cache_misses_total', 'Tool results computed because the query cache had none',
+      () => this.queryCache.getStats().misses);
+    registry.gauge('grepforcode_query_cache_hit_ratio', 'Share of query cache lookups that hit (0 before the first lookup)', () => {
+      const { hits, misses } = this.queryCache.getStats();
+      return hits + misses > 0 ? hits / (hits + misses) : 0;
+    });
+    registry.gauge('grepforcode_query_cache_entries', 'Results held by the query cache',
+      () => this.queryCache.getStats().entries);
+    registry.gauge('grepforcode_lsp_cache_entries', 'Entries in the language server response cache by kind', () =>
+      Object.entries(this.lspClient?.getCacheManager().getStats() ?? {}).map(([kind, value]) => ({ labels: { kind }, value })));
+    registry.gauge('grepforcode_lsp_requests', 'Language server requests in flight and queued, by lane (fast or slow)', () => {
+      const stats = this.lspClient?.getRequestStats();
+      if (!stats) {
+        return [];
+      }
+      return (['fast', 'slow'] as const).flatMap((lane) => [
+        { labels: { lane, state: 'in_flight' }, value: stats.inFlight[lane] },
+        { labels: { lane, state: 'queued' }, value: stats.queued[lane] },
+      ]);
+    });
+  }
+
+  /**
+   * Setup MCP request handlers
+   */
+  private setupHandlers(): void {
+    // List available tools
+    this.server.setRequestHandler(ListToolsRequestSchema, async () => {
+      return { tools: this.listTools() };
+    });
+
+    // Handle tool calls
+    // The SDK aborts the signal when the client sends notifications/cancelled
+    this.server.setRequestHandler(CallToolRequestSchema, async (request, extra?: { signal?: AbortSignal }) => {
+      const { name, arguments: args } = request.params;
+      return this.callTool(name, args, request.params._meta?.progressToken, this.session, extra?.signal);
+    });
+
+    // What a session pinned, watched, and spent goes with it
+    this.server.oninitialized = () => {
+      this.endSession(this.session);
+      this.session = newSessionId();
+    };
+    this.server.onclose = () => this.endSession(this.session);
+  }
+
+  /**
+   * Forget a session's limits, budget, snapshot pin, working set, bookmarks, and watches
+   */
+  private endSession(session: string): void {
+    this.callLimiter.forget(session);
+    this.budgets.forget(session);
+    this.snapshots.release(session);
+    this.pins.delete(session);
+    this.bookmarks.delete(session);
+    this.watches.get(session)?.dispose();
+    this.watches.delete(session);
+  }
+
+  /**
+   * Schemas of the available tools
+   */
+  listTools(): any[] {
+    const builtins: any[] = [
+      {
+        name: 'definition',
+        description: 'Read the source code definition of a symbol (function, type, constant, etc.) from the codebase. Returns the complete implementation code where the symbol is defined.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            symbolName: {
+              type: 'string',
+              description: 'The name of the symbol whose definition you want to find (e.g. \'mypackage.MyFunction\', \'MyType.MyMethod\')',
+            },
+            buildTags: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Go build target: GOOS, GOARCH, and custom tags (e.g. ["windows", "arm64", "integration"]); Go definitions from files outside this build are omitted when one inside it exists (default: GOOS/GOARCH from the environment, then the host)',
+            },
+          },
+          required: ['symbolName'],
+        },
+      },
+      {
+        name: 'references',
+        description: 'Find all usages and references of a symbol throughout the codebase. Returns a list of all files and locations where the symbol appears.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            symbolName: {
+              type: 'string',
+              description: 'The name of the symbol to search for (e.g. \'mypackage.MyFunction\', \'MyType\')',
+            },
+            classify: {
+              type: 'boolean',
+              description: 'If true, label each reference read, write, or declaration (from the language server\'s document highlights, or the surrounding text when it does not tell them apart)',
+              default: false,
+            },
+            access: {
+              type: 'string',
+              enum: ['read', 'write', 'declaration'],
+              description: 'Only references with this access, e.g. write to find every place a field is assigned; implies classify',
+            },
+            scope: {
+              type: 'string',
+              enum: ['same_file', 'same_package', 'tests_only', 'non_tests'],
+              description: 'Only references in the file declaring the symbol, in its directory (package), in test files, or outside test files',
+            },
+            links: {
+              type: 'boolean',
+              description: 'If true, also list the uses of the names the symbol has across language boundaries, by the links rules of the configuration (.proto to generated Go, cgo to C, OpenAPI operationIds to handlers); found by text search (default: true when rules are configured)',
+              default: true,
+            },
+          },
+          required: ['symbolName'],
+        },
+      },
+      {
+        name: 'diagnostics',
+        description: 'Get diagnostic information for a specific file from the language server.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The path to the file to get diagnostics for',
+            },
+            contextLines: {
+              type: 'number',
+              description: 'Lines to include around each diagnostic.',
+              default: 5,
+            },
+            showLineNumbers: {
+              type: 'boolean',
+              description: 'If true, adds line numbers to the output',
+              default: true,
+            },
+          },
+          required: ['filePath'],
+        },
+      },
+      {
+        name: 'hover',
+        description: 'Get hover information (type, documentation) for a symbol at the specified position.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The path to the file to get hover information for',
+            },
+            line: {
+              type: 'number',
+              description: 'The line number where the hover is requested (1-indexed)',
+            },
+            column: {
+              type: 'number',
+              description: 'The column number where the hover is requested (1-indexed)',
+            },
+          },
+          required: ['filePath', 'line', 'column'],
+        },
+      },
+      {
+        name: 'rename_symbol',
+        description: 'Rename a symbol (variable, function, class, etc.) at the specified position and update all references throughout the codebase.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The path to the file containing the symbol to rename',
+            },
+            line: {
+              type: 'number',
+              description: 'The line number where the symbol is located (1-indexed)',
+            },
+            column: {
+              type: 'number',
+              description: 'The column number where the symbol is located (1-indexed)',
+            },
+            newName: {
+              type: 'string',
+              description: 'The new name for the symbol',
+            },
+            force: {
+              type: 'boolean',
+              description: 'Rename even if the new name collides with a declaration in the same scope or package, or a name already used where the symbol is referenced. Without it such renames are reported and nothing is edited',
+              default: false,
+            },
+          },
+          required: ['filePath', 'line', 'column', 'newName'],
+        },
+      },
+      {
+        name: 'edit_file',
+        description: 'Apply multiple text edits to a file.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'Path to the file to edit',
+            },
+            edits: {
+              type: 'array',
+              description: 'List of edits to apply',
+              items: {
+                type: 'object',
+                properties: {
+                  startLine: {
+                    type: 'number',
+                    description: 'Start line to replace, inclusive, one-indexed',
+                  },
+                  endLine: {
+                    type: 'number',
+                    description: 'End line to replace, inclusive, one-indexed',
+                  },
+                  newText: {
+                    type: 'string',
+                    description: 'Replacement text. Replace with the new text. Leave blank to remove lines.',
+                  },
+                },
+                required: ['startLine', 'endLine'],
+              },
+            },
+            expectedHash: {
+              type: 'string',
+              description: 'Content hash reported by read_range; the edits are refused if the file has changed since',
+            },
+          },
+          required: ['filePath', 'edits'],
+        },
+      },
+      {
+        name: 'replace_text',
+        description: 'Replace text across the workspace, listing each change without writing unless apply is true. With caseAware, one rule covers every spelling: userService -> accountService also replaces UserService, USER_SERVICE, user_service, user-service, and "user service", only where the words are whole parts of an identifier.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            from: {
+              type: 'string',
+              description: 'Text to replace: a literal, words or an identifier for caseAware, or a regular expression with regex',
+            },
+            to: {
+              type: 'string',
+              description: 'Replacement; with regex, $1, $2, ... insert the groups',
+            },
+            caseAware: {
+              type: 'boolean',
+              description: 'If true, replace each camelCase, PascalCase, snake_case, SCREAMING_SNAKE_CASE, kebab-case, and lowercase-words spelling of from with the same spelling of to',
+              default: false,
+            },
+            regex: { type: 'boolean', description: 'If true, from is a regular expression', default: false },
+            caseSensitive: { type: 'boolean', description: 'If true, match case (always, with caseAware)', default: false },
+            wholeWord: { type: 'boolean', description: 'If true, only replace whole words', default: false },
+            path: { type: 'string', description: 'Only replace in files under this path' },
+            glob: { type: 'array', items: { type: 'string' }, description: 'Only replace in files matching one of these globs' },
+            excludeGlob: { type: 'array', items: { type: 'string' }, description: 'Leave files matching one of these globs alone' },
+            apply: {
+              type: 'boolean',
+              description: 'If true, write the replacements; otherwise they are only listed',
+              default: false,
+            },
+          },
+          required: ['from', 'to'],
+        },
+      },
+      {
+        name: 'plan_refactor',
+        description: 'Plan a workspace-wide replace_text rule as ordered chunks without writing anything: one per package (directory), each after the packages it imports, with an estimated risk and the replace_text call applying just its files, so a large refactoring can be applied and checked a chunk at a time.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            from: {
+              type: 'string',
+              description: 'Text to replace, as for replace_text: a literal, words or an identifier for caseAware, or a regular expression with regex',
+            },
+            to: {
+              type: 'string',
+              description: 'Replacement; with regex, $1, $2, ... insert the groups',
+            },
+            caseAware: {
+              type: 'boolean',
+              description: 'If true, replace every case spelling of from with the same spelling of to, as replace_text does',
+              default: false,
+            },
+            regex: { type: 'boolean', description: 'If true, from is a regular expression', default: false },
+            caseSensitive: { type: 'boolean', description: 'If true, match case (always, with caseAware)', default: false },
+            wholeWord: { type: 'boolean', description: 'If true, only replace whole words', default: false },
+            path: { type: 'string', description: 'Only plan for files under this path' },
+            glob: { type: 'array', items: { type: 'string' }, description: 'Only plan for files matching one of these globs' },
+            excludeGlob: { type: 'array', items: { type: 'string' }, description: 'Leave files matching one of these globs alone' },
+            maxChunkFiles: {
+              type: 'number',
+              description: 'Most files in one chunk, a positive whole number; larger packages are split',
+              default: 25,
+            },
+          },
+          required: ['from', 'to'],
+        },
+      },
+      {
+        name: 'todo_comments',
+        description: 'Collect TODO/FIXME/HACK/XXX comments across the workspace, with the enclosing symbol and optionally the author and age from git blame.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            tags: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Tags to collect (default: TODO, FIXME, HACK, XXX)',
+            },
+            path: {
+              type: 'string',
+              description: 'Only scan files under this path (relative to the workspace or absolute)',
+            },
+            includeBlame: {
+              type: 'boolean',
+              description: 'If true, include author and age of each comment from git blame',
+              default: false,
+            },
+            includeSymbols: {
+              type: 'boolean',
+              description: 'If true, include the enclosing symbol of each comment',
+              default: true,
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of comments to return',
+              default: 200,
+            },
+          },
+        },
+      },
+      {
+        name: 'api_surface',
+        description: 'List the exported symbols (functions, methods, types, constants) of a package directory or module file with their signatures and doc comments, similar to `go doc`.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Package directory or module file (relative to the workspace or absolute); required unless project is given',
+            },
+            recursive: {
+              type: 'boolean',
+              description: 'If true, include files in subdirectories',
+              default: false,
+            },
+            includeDocs: {
+              type: 'boolean',
+              description: 'If true, include doc comments',
+              default: true,
+            },
+          },
+        },
+      },
+      {
+        name: 'symbol_usage',
+        description: 'List each symbol a package directory or file defines with how often the rest of the workspace references it, most referenced first; separates the hot public API from barely-used helpers before a refactor. Counts are whole-word name matches in source files.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Package directory or file (relative to the workspace, or to the project when one is given, or absolute)',
+            },
+            recursive: {
+              type: 'boolean',
+              description: 'If true, include files in subdirectories',
+              default: false,
+            },
+            exportedOnly: {
+              type: 'boolean',
+              description: 'If true, only list exported symbols',
+              default: false,
+            },
+            limit: {
+              type: 'number',
+              description: 'Maximum number of symbols to list, a positive whole number',
+              default: 100,
+            },
+          },
+          required: ['path'],
+        },
+      },
+      {
+        name: 'vocabulary',
+        description: 'List the most frequent identifiers or string literals starting with a prefix, with counts, to discover naming conventions and the canonical spelling of a concept (userId or userID, "user_id" or "user-id") before searching for it. Comments are left out.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            prefix: {
+              type: 'string',
+              description: 'Prefix the values start with (e.g. "user"); empty counts every value',
+            },
+            kind: {
+              type: 'string',
+              enum: ['identifiers', 'strings'],
+              description: 'Count identifiers or single-line string literals',
+              default: 'identifiers',
+            },
+            path: {
+              type: 'string',
+              description: 'Only count files under this path',
+            },
+            caseSensitive: {
+              type: 'boolean',
+              description: 'If true, the prefix must match with case',
+              default: false,
+            },
+            limit: {
+              type: 'number',
+              description: 'Maximum number of values listed',
+              default: 50,
+            },
+          },
+          required: ['prefix'],
+        },
+      },
+      {
+        name: 'explore_symbol',
+        description: 'Everything about a symbol in one call: its definition snippet, doc comment, and signature, the top references grouped by package, its implementations, and the tests that touch it. Works without a language server from whole-word name matches.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            symbolName: {
+              type: 'string',
+              description: 'The name of the symbol (e.g. \'MyFunction\', \'MyType.MyMethod\')',
+            },
+            maxReferences: {
+              type: 'number',
+              description: 'Maximum number of references listed, from the packages that use the symbol most',
+              default: 20,
+            },
+          },
+          required: ['symbolName'],
+        },
+      },
+      {
+        name: 'impact_report',
+        description: 'Summarize the files, packages, and tests affected by a proposed rename or signature change of the symbol at a position, without editing anything.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The path to the file containing the symbol',
+            },
+            line: {
+              type: 'number',
+              description: 'The line number where the symbol is located (1-indexed)',
+            },
+            column: {
+              type: 'number',
+              description: 'The column number where the symbol is located (1-indexed)',
+            },
+            changeKind: {
+              type: 'string',
+              enum: ['rename', 'signature'],
+              description: 'Kind of change being assessed',
+              default: 'rename',
+            },
+            newName: {
+              type: 'string',
+              description: 'Proposed new name; lets the language server compute the exact rename edits, and lists declarations and uses it would collide with',
+            },
+            includeSymbols: {
+              type: 'boolean',
+              description: 'If true, show the enclosing symbol of each affected location',
+              default: true,
+            },
+          },
+          required: ['filePath', 'line', 'column'],
+        },
+      },
+      {
+        name: 'search_code',
+        description: 'Search the workspace, or a remote repository registered with add_remote, for a literal string or regular expression. Returns matching lines grouped by file. Literal searches of the workspace are accelerated by a trigram index.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            pattern: {
+              type: 'string',
+              description: 'Text or regular expression to search for (required unless query is given)',
+            },
+            query: {
+              type: 'string',
+              description: 'The pattern and parameters in one string, e.g. lang:go kind:func -path:vendor "AddUser". Filters: lang:, path:, file: (glob), -lang:, -path:, -file: (exclude), kind:, case:yes, word:yes, scope:, count: (maxResults), repo:, workspace:, target: (Bazel label). Other terms are the pattern: words joined by spaces, "quoted text", or /regex/. Parameters passed alongside override the query',
+            },
+            regex: {
+              type: 'boolean',
+              description: 'If true, treat the pattern as a regular expression',
+              default: false,
+            },
+            caseSensitive: {
+              type: 'boolean',
+              description: 'If true, match case exactly',
+              default: false,
+            },
+            wholeWord: {
+              type: 'boolean',
+              description: 'If true, only match whole identifiers/words',
+              default: false,
+            },
+            identifierWords: {
+              type: 'boolean',
+              description: 'If true, match identifiers made of the pattern\'s words in order, whatever their casing: "find user id" matches FindUserByID, findUserId, find_user_id, and FIND_USER_ID, but not finder or userIdentity. Each match is the whole identifier; hyphens join words only outside programming languages (user-id in YAML or CSS). Not combined with regex; case and wholeWord are implied',
+              default: false,
+            },
+            path: {
+              type: 'string',
+              description: 'Only search files under this path (relative to the workspace or absolute)',
+            },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only search files matching one of these globs (e.g. ["**/*.go"], ["src/**/*.ts"]); defaults to search.glob from the configuration files',
+            },
+            scope: {
+              type: 'string',
+              enum: ['code', 'fixtures', 'all', 'comments'],
+              description: 'code leaves out test fixtures and golden files (testdata/, __snapshots__/, *.golden, *.snap, fixtures/ under test directories; WORKSPACE_FIXTURE_PATTERNS), fixtures searches only them, all searches both. comments searches the code files but matches only in comments and docstrings, for design rationale, TODO context, and documented invariants',
+              default: 'code',
+            },
+            excludeGlob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Skip files matching one of these globs (e.g. ["vendor/**", "*_test.go"])',
+            },
+            languages: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only search files in these languages, by LSP language identifier or short name (e.g. ["go"], ["ts", "py"])',
+            },
+            pinned: {
+              type: 'string',
+              enum: ['only', 'first'],
+              description: 'Use the files pinned with pin_file: only searches just them, first searches them before the rest so their matches lead the results and are kept when the output is truncated',
+            },
+            target: {
+              type: 'string',
+              description: 'Only search the source files (srcs, hdrs) of this Bazel target, package, or pattern, from the BUILD files (e.g. "//services/auth:server", "//services/auth:all", "//services/...")',
+            },
+            kind: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only report matches on the declaration line of a symbol of these kinds: func, method, class, struct, interface, type, enum, var, const, field, module. The first 2000 matches are checked',
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of matches to return (default: SEARCH_MAX_RESULTS or 100)',
+            },
+            countOmitted: {
+              type: 'boolean',
+              description: 'Past maxResults, keep scanning to give the exact number of matches omitted, in all and per file. Reads every candidate file, so it is slower on large workspaces (default: false)',
+            },
+            maxFileSize: {
+              type: 'number',
+              description: 'Skip files larger than this many bytes; skipped files are listed (default: SEARCH_MAX_FILE_SIZE or 10MB)',
+            },
+            maxLineLength: {
+              type: 'number',
+              description: 'Clip longer lines (e.g. minified code) to a window around the match (default: SEARCH_MAX_LINE_LENGTH or 500)',
+            },
+            includeGenerated: {
+              type: 'boolean',
+              description: 'If true, also search generated files (linguist-generated in .gitattributes, "Code generated ... DO NOT EDIT" headers, *.pb.go, lock files)',
+              default: false,
+            },
+            binary: {
+              type: 'boolean',
+              description: 'If true, also search binary files (skipped by default), reporting byte offsets instead of lines',
+              default: false,
+            },
+            followSymlinks: {
+              type: 'boolean',
+              description: 'If true, follow symbolic links to files and directories inside the workspace; links leading out and cycles are cut off, and each physical file is reported once (default: WORKSPACE_FOLLOW_SYMLINKS or false)',
+            },
+            timeoutMs: {
+              type: 'number',
+              description: 'Stop after this many milliseconds and return the matches found so far, flagged as truncated by timeout (0 disables; default: SEARCH_TIMEOUT_MS or 30000)',
+            },
+            extract: {
+              type: 'boolean',
+              description: 'If true, return the capture groups of each regex match (tab-separated; the whole match when there are none) instead of the matching lines, e.g. every error message passed to errors.New',
+              default: false,
+            },
+            distinct: {
+              type: 'boolean',
+              description: 'With extract, list each distinct value once with its number of matches, most frequent first (scans up to 10000 matches unless maxResults is set)',
+              default: false,
+            },
+            sort: {
+              type: 'string',
+              enum: ['path', 'mtime', 'match_count', 'size'],
+              description: 'Order files by path, last modification time, number of matches, or file size (default: scan order), e.g. mtime to see the most recently touched files containing the pattern. Files are sorted before maxResults truncates, so the kept matches are those of the first files in this order; match_count ranks the first 10000 matches',
+            },
+            order: {
+              type: 'string',
+              enum: ['asc', 'desc'],
+              description: 'Sort direction (default: asc for path, desc for the others)',
+            },
+            dedupe: {
+              type: 'boolean',
+              description: 'If true, files with identical content (vendored copies, mirrors) are reported once, with the copies listed under the canonical file; false lists every copy\'s matches',
+              default: true,
+            },
+            highlight: {
+              type: 'boolean',
+              description: 'If true, list each matching line once with the column range of every match on it, e.g. L3:C5-11,C20-26 (1-indexed, end exclusive: the line from column 5 up to 11 is a match)',
+              default: false,
+            },
+            gitStatus: {
+              type: 'boolean',
+              description: 'If true, label each file with its uncommitted changes (Git: staged, modified, "staged, modified", untracked, conflicted) or Git: committed, to tell in-progress work from committed code',
+              default: false,
+            },
+            matcher: {
+              type: 'string',
+              description: 'Keep only the matches a WebAssembly matcher from the matchers setting accepts, by name (e.g. "sql" for SQL inside string literals); the matcher sees each match\'s line and its range',
+            },
+            lastChange: {
+              type: 'boolean',
+              description: 'If true, add the hash, date, and subject of the last commit touching each match line (Last change: 3f2a1bc9 2026-10-12 Add retries), from a cached git blame, to weigh matches by recency and intent',
+              default: false,
+            },
+            coverage: {
+              type: 'string',
+              description: 'Coverage profile to mark each match line covered or not by tests (Coverage: covered (12 hits) or not covered): a Go cover profile (go test -coverprofile) or an lcov tracefile, relative to the workspace. Shows which matched code paths are untested',
+            },
+            context: {
+              type: 'boolean',
+              description: 'If true, show each match\'s enclosing symbol (kind, qualified name, declaration line) and each file\'s package or module, often enough to answer without opening the file. Symbols come from the language server, or the built-in parser for Python and .proto files',
+              default: false,
+            },
+            directoryDocs: {
+              type: 'boolean',
+              description: 'If true, say what the area of the codebase each matched file lives in is for: the first paragraph of the nearest README, Go package comment, Python package docstring, Rust module comment, or package.json description in its directory or one above (the workspace root\'s README is left out). Shown once per summary',
+              default: false,
+            },
+            astPath: {
+              type: 'boolean',
+              description: 'If true, show the syntax node path of each match from tree-sitter, e.g. "AST: function_declaration > statement_block > if_statement > call_expression", to keep matches by syntactic position without reading the files. Needs tree-sitter and the language\'s grammar package (tree-sitter-typescript, tree-sitter-python, tree-sitter-go, ...)',
+              default: false,
+            },
+            template: {
+              type: 'string',
+              description: 'Result template profile from the templates setting to render the matches with (default: the profile named after the client, else "default", else plain output with every field)',
+            },
+            archives: {
+              type: 'boolean',
+              description: 'If true, also search the entries of zip, jar, war, and tar(.gz) archives, reported as "<archive>!/<entry>" (nested archives up to SEARCH_ARCHIVE_MAX_DEPTH)',
+              default: false,
+            },
+            buildTags: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only search Go files that are part of the build for this target: GOOS, GOARCH, and custom tags (e.g. ["linux", "amd64", "integration"]); unnamed GOOS/GOARCH default to the environment, then the host. Other files are searched as usual',
+            },
+            repo: {
+              type: 'string',
+              description: 'Search this remote repository (a name returned by add_remote) instead of the workspace',
+            },
+            workspace: {
+              type: 'string',
+              description: 'Search this directory (a name returned by add_workspace) instead of the server\'s workspace',
+            },
+          },
+        },
+      },
+      {
+        name: 'search_jsx',
+        description: 'Find React components, props, and hooks by syntax rather than text: JSX elements by name (<UserCard>), the props passed to them, and hook calls, parsed with the tree-sitter TSX grammar. Needs the tree-sitter and tree-sitter-typescript packages.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            component: {
+              type: 'string',
+              description: 'Element name to find, e.g. "UserCard" or "Layout.Header" (the last segment also matches)',
+            },
+            prop: {
+              type: 'string',
+              description: 'Prop passed to elements (to the component\'s elements when component is set), e.g. "onSelect"',
+            },
+            hook: {
+              type: 'string',
+              description: 'Hook whose calls to find, e.g. "useState"; "*" finds every use* call (.ts files are searched too)',
+            },
+            within: {
+              type: 'string',
+              description: 'Only report matches inside the definition of this component or function',
+            },
+            path: {
+              type: 'string',
+              description: 'Only search files under this path (relative to the workspace or absolute)',
+            },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only search files matching one of these globs (e.g. ["src/components/**"])',
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of matches to return',
+              default: 100,
+            },
+          },
+        },
+      },
+      {
+        name: 'query_config',
+        description: 'Find values in YAML and JSON files by key path (e.g. spec.template.spec.containers[*].image), with the file and line of each value. Multi-document YAML, block scalars, and JSON with comments are supported.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            keyPath: {
+              type: 'string',
+              description: 'Dotted key path: "*" matches any key or item at one level, "[*]" any list item, "[n]" one item, "**" any number of levels, and ["a.b"] a key containing dots',
+            },
+            value: {
+              type: 'string',
+              description: 'Only report scalar values matching this regular expression (e.g. "^nginx:")',
+            },
+            path: {
+              type: 'string',
+              description: 'Only search files under this path (relative to the workspace or absolute)',
+            },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only search files matching one of these globs (e.g. ["deploy/**/*.yaml"])',
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of values to return',
+              default: 100,
+            },
+          },
+          required: ['keyPath'],
+        },
+      },
+      {
+        name: 'proto_references',
+        description: 'Find a Protocol Buffers message, field, enum, service, or RPC and its usages across the IDL boundary: the generated Go (protoc-gen-go, protoc-gen-go-grpc) and TypeScript (ts-proto, protobuf-ts, grpc-js) code for it, and the hand-written code that uses the generated names. Matching is by generated name, so an RPC is only matched in files that mention its service.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            symbolName: {
+              type: 'string',
+              description: 'The proto symbol, qualified or by a dotted suffix of its full name (e.g. "acme.v1.UserService.GetUser", "UserService.GetUser", "User.email")',
+            },
+            path: {
+              type: 'string',
+              description: 'Only search under this path, for both .proto files and code (relative to the workspace or absolute)',
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of matches per language',
+              default: 100,
+            },
+          },
+          required: ['symbolName'],
+        },
+      },
+      {
+        name: 'build_targets',
+        description: 'List the build targets defined in Makefiles, Taskfiles, and justfiles, with their descriptions, dependencies, and recipes, to find how a project is built, tested, or run. Pass target to see one target\'s whole recipe, and pattern to search recipe lines.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            target: {
+              type: 'string',
+              description: 'Only this target (e.g. "test"), with its whole recipe',
+            },
+            pattern: {
+              type: 'string',
+              description: 'Only recipe lines matching this regular expression, case-insensitive (e.g. "go test|pytest")',
+            },
+            path: {
+              type: 'string',
+              description: 'Only read build files under this path (relative to the workspace or absolute)',
+            },
+          },
+        },
+      },
+      {
+        name: 'bazel_targets',
+        description: 'Map files to Bazel targets from BUILD and BUILD.bazel files: the target(s) owning a file (listing it in srcs or hdrs, directly or by glob), the source files of a target or label pattern, or every target under a path with its rule kind and BUILD file line.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'List the targets owning this file (relative to the workspace or absolute)',
+            },
+            target: {
+              type: 'string',
+              description: 'List the source files of this label or pattern (e.g. "//pkg:lib", "//pkg:all", "//pkg/...")',
+            },
+            path: {
+              type: 'string',
+              description: 'Only list targets of BUILD files under this path (relative to the workspace or absolute)',
+            },
+          },
+        },
+      },
+      {
+        name: 'sql_search',
+        description: 'Search .sql files statement by statement: statements touching a table, statements that define, add, drop, rename, or alter a column, or statements of a kind. Migrations (golang-migrate, Flyway, goose, dbmate, Prisma, numbered files in a migrations directory) are listed in version order with a column history, answering "which migration added column X".',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            table: {
+              type: 'string',
+              description: 'Statements reading or writing this table, with or without its schema (e.g. "users", "public.users")',
+            },
+            column: {
+              type: 'string',
+              description: 'Statements defining, changing, or mentioning this column (e.g. "email")',
+            },
+            statement: {
+              type: 'string',
+              description: 'Statements of this kind (e.g. "ALTER TABLE", "CREATE INDEX", "INSERT")',
+            },
+            pattern: {
+              type: 'string',
+              description: 'Statements whose text matches this regular expression, case-insensitive',
+            },
+            path: {
+              type: 'string',
+              description: 'Only search files under this path (relative to the workspace or absolute)',
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of statements to return',
+              default: 50,
+            },
+          },
+        },
+      },
+      {
+        name: 'docker_files',
+        description: 'Summarize Dockerfiles and compose files: build stages with base images, exposed ports, copied paths, and entrypoints, and compose services with their image or build, ports, volumes, and dependencies. Pass stage to list one build stage\'s instructions, and pattern to search instructions.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            stage: {
+              type: 'string',
+              description: 'Only this build stage, by name (FROM ... AS name) or 0-based index, with all its instructions',
+            },
+            pattern: {
+              type: 'string',
+              description: 'Only instructions matching this regular expression, case-insensitive (e.g. "apt-get|apk add")',
+            },
+            path: {
+              type: 'string',
+              description: 'Only read files under this path (relative to the workspace or absolute)',
+            },
+          },
+        },
+      },
+      {
+        name: 'outline',
+        description: 'Nested outline of a file: each symbol with its kind, signature, line range, and the first sentence of its doc comment. Much cheaper than reading the file to learn its structure.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The path to the file to outline',
+            },
+            maxDepth: {
+              type: 'number',
+              description: 'Deepest nesting level shown; 0 shows top-level symbols only (default: all)',
+            },
+            includeDocs: {
+              type: 'boolean',
+              description: 'Show doc comment summaries',
+              default: true,
+            },
+          },
+          required: ['filePath'],
+        },
+      },
+      {
+        name: 'read_range',
+        description: 'Read exact lines of a file, numbered, with the file\'s content hash. Pass the hash to edit_file as expectedHash so the edit fails if the file changed in between. For large files, read by chunk instead: chunks are cut at function and type boundaries, so each holds whole declarations.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The path to the file to read',
+            },
+            startLine: {
+              type: 'number',
+              description: 'First line to return (1-indexed, default: 1)',
+            },
+            endLine: {
+              type: 'number',
+              description: 'Last line to return, inclusive (default: the end of the file, at most 2000 lines)',
+            },
+            chunk: {
+              type: 'number',
+              description: 'Read this chunk of the file (1-indexed) instead of a line range; the output gives the chunk count and the next chunk',
+            },
+            chunkLines: {
+              type: 'number',
+              description: 'Lines a chunk aims for (default: 200); a symbol is kept whole up to twice this, and longer ones are split between their members',
+              default: 200,
+            },
+          },
+          required: ['filePath'],
+        },
+      },
+      {
+        name: 'tree',
+        description: 'Directory structure of the workspace, with .gitignore and exclusion rules applied and the number of files under each directory. Orients you in a repository in one call.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Directory to start from (default: the workspace root)',
+            },
+            depth: {
+              type: 'number',
+              description: 'Directory levels shown (default: 3); deeper directories show only their file count',
+              default: 3,
+            },
+            maxEntries: {
+              type: 'number',
+              description: 'Most files and directories listed (default: 200)',
+              default: 200,
+            },
+          },
+        },
+      },
+      {
+        name: 'file_info',
+        description: 'Size, language, line count, last modified time, git status, and whether a file is generated, binary, or a test, without reading its content. Use it to decide whether a file is worth reading.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The path to the file to describe',
+            },
+          },
+          required: ['filePath'],
+        },
+      },
+      {
+        name: 'batch_search',
+        description: `Run up to ${MAX_BATCH_QUERIES} independent search_code queries in one call, e.g. to check whether a list of names exists. Results are keyed by each query's key (or pattern); a failing query reports its error without failing the others.`,
+        inputSchema: {
+          type: 'object',
+          properties: {
+            queries: {
+              type: 'array',
+              description: 'The queries; each takes search_code arguments, which override the shared ones',
+              items: {
+                type: 'object',
+                properties: {
+                  key: { type: 'string', description: 'Name of this query\'s result (default: the pattern)' },
+                  pattern: { type: 'string', description: 'Text or regular expression to search for' },
+                  query: { type: 'string', description: 'Instead of pattern: search_code\'s query syntax, e.g. lang:go -path:vendor "AddUser"' },
+                  regex: { type: 'boolean' },
+                  wholeWord: { type: 'boolean' },
+                  caseSensitive: { type: 'boolean' },
+                  path: { type: 'string' },
+                  glob: { type: 'array', items: { type: 'string' } },
+                  maxResults: { type: 'number' },
+                },
+              },
+            },
+            regex: { type: 'boolean', description: 'Shared: treat patterns as regular expressions', default: false },
+            wholeWord: { type: 'boolean', description: 'Shared: match whole words only', default: false },
+            caseSensitive: { type: 'boolean', description: 'Shared: match case', default: false },
+            path: { type: 'string', description: 'Shared: only search under this path' },
+            glob: { type: 'array', items: { type: 'string' }, description: 'Shared: only search files matching these globs' },
+            maxResults: { type: 'number', description: 'Shared: matches listed per query (default: 20)' },
+          },
+          required: ['queries'],
+        },
+      },
+      {
+        name: 'entry_points',
+        description: 'List where programs start and where requests come in: main functions and packages, HTTP route registrations, CLI command definitions, and tests (counted per file unless kind is "test"). A starting point for reading an unfamiliar repository.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Only look under this path',
+            },
+            kind: {
+              type: 'string',
+              enum: ['main', 'route', 'command', 'test'],
+              description: 'Only entry points of this kind',
+            },
+          },
+        },
+      },
+      {
+        name: 'routes',
+        description: 'Find the handler serving an HTTP request: maps method and path to the function registered for it, from route registrations in Go (net/http, gorilla/mux, gin, echo, chi, fiber) and JavaScript/TypeScript (Express, Fastify), with group and router prefixes applied. Without a route, lists every route.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            route: {
+              type: 'string',
+              description: 'Request to match, e.g. "GET /users/42" or "/users/:id"; path parameters match the route\'s parameters',
+            },
+            method: {
+              type: 'string',
+              description: 'Only routes taking this HTTP method',
+            },
+            path: {
+              type: 'string',
+              description: 'Only look under this path',
+            },
+            framework: {
+              type: 'string',
+              enum: ['net/http', 'gorilla/mux', 'gin', 'echo', 'chi', 'fiber', 'express', 'fastify'],
+              description: 'Only routes of this framework',
+            },
+          },
+        },
+      },
+      {
+        name: 'env_vars',
+        description: 'List the environment variables the code reads (os.Getenv, process.env, os.environ, env::var, System.getenv, ENV, env struct tags, getEnv-style helpers), each with its defaults and every place it is read, plus the variables documented in .env.example files. Use it to map the configuration surface of a program.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Only look under this path',
+            },
+            name: {
+              type: 'string',
+              description: 'Only variables whose name contains this, ignoring case',
+            },
+          },
+        },
+      },
+      {
+        name: 'i18n_messages',
+        description: 'Map localization message keys between i18n resource files (JSON/YAML locale files, gettext .po catalogs) and the code looking them up (t, $t, i18n.t, formatMessage, gettext, _ ...). With key, shows its text in each locale and every lookup; with text, the keys whose text contains it, so "where is this UI string defined and used" is one query; with neither, missing translations, undefined keys, and unused keys.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            key: {
+              type: 'string',
+              description: 'A message key, e.g. "auth.login.title" (an i18next namespace prefix such as "auth:" may be left off), or a gettext msgid',
+            },
+            text: {
+              type: 'string',
+              description: 'Text of a UI string; keys whose text or key contains it are shown, ignoring case',
+            },
+            locale: {
+              type: 'string',
+              description: 'Only show and search the text of this locale, e.g. en or pt-BR',
+            },
+            path: {
+              type: 'string',
+              description: 'Only index resource and source files under this path',
+            },
+            limit: {
+              type: 'number',
+              description: 'Most keys listed',
+              default: 20,
+            },
+          },
+        },
+      },
+      {
+        name: 'feature_flags',
+        description: 'List the feature flags the code looks up, each with its call sites: calls of LaunchDarkly, Unleash, OpenFeature, Split, GrowthBook, and Flipper lookups, plus homegrown flag functions added with FEATURE_FLAG_PATTERNS, taking the flag key as the first argument. With a flag, lists its lookups and every other mention of its key, the checklist for removing it.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            flag: {
+              type: 'string',
+              description: 'Only this flag key, with the other mentions of it in code, configuration, and tests',
+            },
+            path: {
+              type: 'string',
+              description: 'Only look under this path',
+            },
+          },
+        },
+      },
+      {
+        name: 'test_pairs',
+        description: 'Find the test files of an implementation file, or the files a test file tests, by naming conventions (foo_test.go, foo.spec.ts, test_foo.py, FooTest.java under src/test), the test\'s imports, and the names of the file the test mentions; works in layouts no convention covers.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'Implementation or test file (relative to the workspace or absolute)',
+            },
+            limit: {
+              type: 'number',
+              description: 'Maximum number of files listed, best match first',
+              default: 10,
+            },
+          },
+          required: ['filePath'],
+        },
+      },
+      {
+        name: 'importers',
+        description: 'List the workspace files that import a file, directory, or Go package, directly and, with depth, through the files importing those, to answer "what depends on this" or "can I safely delete it". Reads TypeScript/JavaScript, Python, Go, Java/Kotlin/Scala, and C/C++ imports; a Go package counts as imported by any import of it.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'File, directory, or Go import path (e.g. "src/store/cache.ts", "internal/store", "example.com/app/internal/store")',
+            },
+            depth: {
+              type: 'number',
+              description: 'Levels of importers followed; 1 lists direct importers only (default: 1, at most 10)',
+              default: 1,
+            },
+            includeTests: {
+              type: 'boolean',
+              description: 'List test files among the importers (default: true)',
+              default: true,
+            },
+            limit: {
+              type: 'number',
+              description: 'Most importers listed (default: 200)',
+              default: 200,
+            },
+          },
+          required: ['path'],
+        },
+      },
+      {
+        name: 'recent_files',
+        description: 'List the most recently modified files, newest first, by file modification time or by the latest git commit touching each file. Use it to find the active area of a codebase.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            by: {
+              type: 'string',
+              enum: ['mtime', 'git'],
+              description: 'Order by file modification time (mtime) or by the git log (git); default: mtime',
+              default: 'mtime',
+            },
+            path: {
+              type: 'string',
+              description: 'Only list files under this path',
+            },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only list files matching one of these globs (e.g. ["**/*.go"])',
+            },
+            language: {
+              type: 'string',
+              description: 'Only list files of this language (e.g. "go", "typescript", "python")',
+            },
+            limit: {
+              type: 'number',
+              description: 'Most files listed (default: 20)',
+              default: 20,
+            },
+          },
+        },
+      },
+      {
+        name: 'stats',
+        description: 'Count files, code lines, comment lines, and blank lines per language and per top-level directory, like cloc. Counts the files searches see, so it also shows what the index covers and what it skips (binary and oversized files).',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Directory to count (default: the workspace root); its subdirectories are the directory rows',
+            },
+          },
+        },
+      },
+      {
+        name: 'warmup',
+        description: 'Prepare the workspace for fast queries: build the search index, wait for the language server (starting it again if it was stopped for being idle), and open the files most of the workspace imports, so the language server has loaded the packages queries are likely to touch. Also brings the semantic index up to date when semantic search is enabled. Call it once at the start of a session on a large repository; it reports what it did and how long each step took.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            maxFiles: {
+              type: 'number',
+              description: 'Files to open in the language server, most imported first (default: 20)',
+            },
+            concurrency: {
+              type: 'number',
+              description: 'Files the language server analyzes at once (default: LSP_WARMUP_CONCURRENCY, or 4)',
+            },
+          },
+        },
+      },
+      {
+        name: 'go_interfaces',
+        description: 'List the interfaces a Go type implements: those declared in the workspace and common standard library ones (error, fmt.Stringer, io.Reader, sort.Interface, http.Handler, ...). Says whether the value type or only the pointer type implements each, and lists interfaces the type nearly implements with the missing or mismatched methods. Reads Go source directly; test files are left out.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            typeName: {
+              type: 'string',
+              description: 'Type name, optionally qualified by its package (e.g. "Memory" or "store.Memory")',
+            },
+            maxMissing: {
+              type: 'number',
+              description: 'Most missing or mismatched methods for an interface to be listed as nearly implemented (default: 2)',
+              default: 2,
+            },
+          },
+          required: ['typeName'],
+        },
+      },
+      {
+        name: 'package_usages',
+        description: 'Find where a Go package is used: the selectors (pkg.Name) of the name each file imports it by, so aliased imports such as us "example.com/user" are followed to us.NewUserService. Also lists blank and dot imports of the package. Works without a language server.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            package: {
+              type: 'string',
+              description: 'Import path, a trailing part of one, or package name (e.g. "example.com/app/user", "internal/user", "user")',
+            },
+            symbol: {
+              type: 'string',
+              description: 'Only list usages of this exported name (e.g. "NewUserService")',
+            },
+            path: {
+              type: 'string',
+              description: 'Directory to search for usages (default: the workspace)',
+            },
+            limit: {
+              type: 'number',
+              description: 'Most usages listed (default: 200)',
+              default: 200,
+            },
+          },
+          required: ['package'],
+        },
+      },
+      {
+        name: 'find_typed',
+        description: 'Find Go variables, receivers, parameters, named results, and struct fields of a given type, such as every context.Context parameter or *sql.DB value, using type information rather than name matching. With expressions, calls, selectors, and literals of the type are listed too. Needs the goanalysis command built from goanalysis/ (on PATH or at GOANALYSIS_PATH).',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            type: {
+              type: 'string',
+              description: 'Go type qualified by package name or import path (e.g. "context.Context", "*sql.DB", "[]byte"); an unqualified name matches a named type of any package',
+            },
+            path: {
+              type: 'string',
+              description: 'Package directory or subtree to search (default: the workspace)',
+            },
+            expressions: {
+              type: 'boolean',
+              description: 'Also list value expressions of the type other than plain identifiers (default: false)',
+              default: false,
+            },
+            limit: {
+              type: 'number',
+              description: 'Most values listed (default: 100)',
+              default: 100,
+            },
+          },
+          required: ['type'],
+        },
+      },
+      {
+        name: 'go_error_checks',
+        description: 'Find Go error-handling problems with type information: error results that are not checked or assigned to _, err variables shadowing an outer err that is used later, comparisons and type assertions that miss wrapped errors (use errors.Is/As), and errors.Is/As targets that can never match. Needs the goanalysis command built from goanalysis/ (on PATH or at GOANALYSIS_PATH).',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Package directory or subtree to check (default: the workspace)',
+            },
+            checks: {
+              type: 'array',
+              items: { type: 'string', enum: ['ignored', 'discarded', 'shadow', 'compare', 'errors-as', 'errors-is'] },
+              description: 'Checks to run (default: all)',
+            },
+            limit: {
+              type: 'number',
+              description: 'Most findings listed (default: 200)',
+              default: 200,
+            },
+          },
+        },
+      },
+      {
+        name: 'find_message_origin',
+        description: 'Find the string literals a runtime log or error message came from. Format strings (printf verbs, ${} and {} interpolation) are matched with their placeholders standing for the formatted values, which are listed; other literals are ranked by shared words. Paste the message as printed, values included.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            message: {
+              type: 'string',
+              description: 'The log or error message, e.g. "load config app.yaml: file not found"',
+            },
+            path: {
+              type: 'string',
+              description: 'Directory to search (default: the workspace)',
+            },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only search files matching one of these globs (e.g. ["**/*.go"])',
+            },
+            limit: {
+              type: 'number',
+              description: 'Most candidates listed (default: 10)',
+              default: 10,
+            },
+          },
+          required: ['message'],
+        },
+      },
+      {
+        name: 'resolve_stack_trace',
+        description: 'Resolve a pasted stack trace - a Go panic, Python traceback, or JavaScript (V8 or Firefox) stack - to workspace files, with the code around each frame. Paths are matched by their longest common suffix, so traces from other machines, containers, or build directories resolve. Frames whose line no longer fits their function are flagged as stale.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            trace: {
+              type: 'string',
+              description: 'The stack trace as printed',
+            },
+            contextLines: {
+              type: 'number',
+              description: 'Lines shown around each frame (default: 2)',
+              default: 2,
+            },
+            includeLibraries: {
+              type: 'boolean',
+              description: 'Also resolve frames in node_modules, site-packages, the Go module cache, and standard libraries (default: false)',
+              default: false,
+            },
+          },
+          required: ['trace'],
+        },
+      },
+      {
+        name: 'symbol_history',
+        description: 'Change history of one function, method, or type: the commits that changed its lines, newest first, followed through edits around it and file renames (git log -L over the symbol\'s range). Much more focused than the file\'s history.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            filePath: {
+              type: 'string',
+              description: 'The file declaring the symbol',
+            },
+            symbolName: {
+              type: 'string',
+              description: 'Name or qualified name of the symbol (e.g. "handle", "Server.handle")',
+            },
+            maxCommits: {
+              type: 'number',
+              description: 'Most commits listed (default: 20)',
+              default: 20,
+            },
+            includePatches: {
+              type: 'boolean',
+              description: 'Show each commit\'s diff of the symbol (default: false)',
+              default: false,
+            },
+          },
+          required: ['filePath', 'symbolName'],
+        },
+      },
+      {
+        name: 'churn',
+        description: 'Recent churn per directory or file from the git log: commits and lines added and removed in the last N days, with the authors who changed each most and their share of its lines. Finds hotspots and likely reviewers for an area.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Directory to report on (default: the workspace)',
+            },
+            days: {
+              type: 'number',
+              description: 'Length of the window in days (default: 90)',
+              default: 90,
+            },
+            by: {
+              type: 'string',
+              enum: ['directory', 'file'],
+              description: 'Group changes by directory or list files (default: directory)',
+              default: 'directory',
+            },
+            depth: {
+              type: 'number',
+              description: 'Directory levels below path that directories are grouped at (default: 1)',
+              default: 1,
+            },
+            limit: {
+              type: 'number',
+              description: 'Most rows listed (default: 20)',
+              default: 20,
+            },
+          },
+        },
+      },
+      {
+        name: 'symbol_diff',
+        description: 'Semantic summary of the changes between two git revisions: the functions, methods, classes, types, and fields each changed file added, removed, moved to another file, or changed the signature of, with the old and new signatures. Much shorter than a unified diff for large branches. Reads Go, Python, TypeScript/JavaScript, and .proto files; other changed files are counted.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            base: {
+              type: 'string',
+              description: 'The older revision, e.g. "main" or "v1.2.0"',
+            },
+            head: {
+              type: 'string',
+              description: 'The newer revision (default: the work tree, uncommitted changes included)',
+            },
+            mergeBase: {
+              type: 'boolean',
+              description: 'Compare from the merge base of base and head, as a pull request does, so changes made on base since do not show (default: true)',
+              default: true,
+            },
+            path: {
+              type: 'string',
+              description: 'Only report files under this directory',
+            },
+            limit: {
+              type: 'number',
+              description: 'Most changes listed (default: 200)',
+              default: 200,
+            },
+          },
+          required: ['base'],
+        },
+      },
+      {
+        name: 'search_diff',
+        description: 'Report only the matches of a search that are new or removed, compared with a git revision or with a snapshot saved by an earlier call, e.g. "did this branch add uses of a deprecated API". Matches are paired by file and line text, then by matched text, so lines that moved or were edited but still match are not reported. Against a revision only the changed files are searched; untracked files count as added. Pass save to keep the current matches under a name and since to compare with them later.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            pattern: {
+              type: 'string',
+              description: 'Text or regular expression to search for',
+            },
+            base: {
+              type: 'string',
+              description: 'The older revision to compare with, e.g. "main"',
+            },
+            head: {
+              type: 'string',
+              description: 'The newer revision (default: the work tree, uncommitted and untracked files included)',
+            },
+            mergeBase: {
+              type: 'boolean',
+              description: 'Compare from the merge base of base and head, as a pull request does (default: true)',
+              default: true,
+            },
+            since: {
+              type: 'string',
+              description: 'Compare with the snapshot saved under this name instead of a revision',
+            },
+            save: {
+              type: 'string',
+              description: 'Save the current matches under this name, for a later call with since; snapshots last until the server stops',
+            },
+            regex: {
+              type: 'boolean',
+              description: 'If true, treat the pattern as a regular expression',
+              default: false,
+            },
+            caseSensitive: {
+              type: 'boolean',
+              description: 'If true, match case exactly',
+              default: false,
+            },
+            wholeWord: {
+              type: 'boolean',
+              description: 'If true, only match whole identifiers/words',
+              default: false,
+            },
+            path: {
+              type: 'string',
+              description: 'Only search files under this path (relative to the workspace or absolute)',
+            },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only search files matching one of these globs (e.g. ["**/*.go"])',
+            },
+            limit: {
+              type: 'number',
+              description: 'Most new and most removed matches listed (default: 100 each)',
+              default: 100,
+            },
+          },
+          required: ['pattern'],
+        },
+      },
+      {
+        name: 'watch_query',
+        description: 'Watch a search for changes: the server keeps the query\'s matches, searches the files the file watcher sees change, and sends each change as a notifications/message from logger "watch_query" listing the new and removed matches (paired as search_diff pairs them), e.g. to hear when someone adds another use of a deprecated API. action unwatch stops one watch (or all without id); list shows them. Watches last for the session, 20 at most.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            action: {
+              type: 'string',
+              enum: ['watch', 'unwatch', 'list'],
+              description: 'watch starts watching pattern, unwatch stops the watch named id, list shows the watches',
+              default: 'watch',
+            },
+            pattern: {
+              type: 'string',
+              description: 'Text or regular expression to watch, for watch',
+            },
+            id: {
+              type: 'string',
+              description: 'The watch to stop, for unwatch, e.g. "w1" (default: every watch)',
+            },
+            regex: {
+              type: 'boolean',
+              description: 'If true, treat the pattern as a regular expression',
+              default: false,
+            },
+            caseSensitive: {
+              type: 'boolean',
+              description: 'If true, match case exactly',
+              default: false,
+            },
+            wholeWord: {
+              type: 'boolean',
+              description: 'If true, only match whole identifiers/words',
+              default: false,
+            },
+            path: {
+              type: 'string',
+              description: 'Only watch files under this path (relative to the workspace or absolute)',
+            },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Only watch files matching one of these globs (e.g. ["**/*.go"])',
+            },
+            excludeGlob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'Leave out files matching one of these globs',
+            },
+          },
+        },
+      },
+      {
+        name: 'overlay',
+        description: 'Replace files with in-memory content (unsaved editor buffers or proposed edits), so search and language server tools see that content instead of the file on disk. The file need not exist. edit_file and rename_symbol refuse overlaid files until the overlay is cleared.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            action: {
+              type: 'string',
+              enum: ['set', 'clear', 'list'],
+              description: 'set: overlay filePath with content; clear: drop the overlay of filePath, or of every file when filePath is omitted; list: show overlaid files',
+            },
+            filePath: {
+              type: 'string',
+              description: 'The file to overlay or clear (relative to the workspace or absolute)',
+            },
+            content: {
+              type: 'string',
+              description: 'The file content to use (for set)',
+            },
+          },
+          required: ['action'],
+        },
+      },
+      {
+        name: 'pin_file',
+        description: 'Pin a file, or a symbol in it, to the working set of this session, so search_code with pinned: only or first can stay within or lead with the files being worked on. A symbolName without filePath pins the files defining it. Pins last for the session.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            action: {
+              type: 'string',
+              enum: ['pin', 'unpin', 'clear'],
+              description: 'pin: add to the working set; unpin: remove filePath (with its symbols) or symbolName; clear: remove every pin',
+              default: 'pin',
+            },
+            filePath: {
+              type: 'string',
+              description: 'The file to pin or unpin (relative to the workspace or absolute)',
+            },
+            symbolName: {
+              type: 'string',
+              description: 'A symbol to pin or unpin, by name or qualified name (e.g. "Store" or "Store.get"); with filePath only symbols of that file',
+            },
+          },
+        },
+      },
+      {
+        name: 'list_pins',
+        description: 'List the files and symbols pinned to the working set of this session with pin_file.',
+        inputSchema: {
+          type: 'object',
+          properties: {},
+        },
+      },
+      {
+        name: 'bookmark_symbol',
+        description: 'Bookmark a location by the symbol it is in rather than its line number, so a later step can find "that function" or "that line of it" again with resolve_bookmark after edits move code around. A line inside a symbol is kept by its text and place within the symbol. Bookmarks last for the session.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            action: {
+              type: 'string',
+              enum: ['add', 'remove', 'clear'],
+              description: 'add: bookmark a symbol; remove: drop the bookmark called name; clear: drop every bookmark',
+              default: 'add',
+            },
+            filePath: {
+              type: 'string',
+              description: 'The file the symbol is in (relative to the workspace or absolute)',
+            },
+            symbolName: {
+              type: 'string',
+              description: 'The symbol to bookmark, by name or qualified name (e.g. "send" or "Client.send")',
+            },
+            line: {
+              type: 'number',
+              description: 'A line (1-indexed) to bookmark: without symbolName, the innermost symbol around it is the anchor; with it, picks among symbols of that name',
+            },
+            name: {
+              type: 'string',
+              description: 'Name to refer to the bookmark by (default: b1, b2, ...); adding under a used name replaces that bookmark',
+            },
+            note: {
+              type: 'string',
+              description: 'A note kept with the bookmark, such as what is to be done there',
+            },
+          },
+        },
+      },
+      {
+        name: 'resolve_bookmark',
+        description: 'Find where bookmarks made with bookmark_symbol are now: the current file and line of each symbol, and of the bookmarked line inside it, with what moved since. A symbol moved to another file is found by name; one renamed or deleted is reported lost.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            name: {
+              type: 'string',
+              description: 'The bookmark to resolve (default: every bookmark of the session)',
+            },
+          },
+        },
+      },
+      {
+        name: 'snapshot',
+        description: 'Record the workspace as it is now (the git commit plus the content of changed files) and pin this session to it, so later searches and reads see that one consistent view while the files keep changing. Use it at the start of a long run; release it when done. The language server and git history still see the current files.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            action: {
+              type: 'string',
+              enum: ['create', 'pin', 'release', 'list'],
+              description: 'create: take a snapshot and pin to it; pin: pin to a snapshot another session holds, by id; release: unpin; list: the snapshots kept',
+              default: 'create',
+            },
+            id: {
+              type: 'string',
+              description: 'Snapshot ID, for pin',
+            },
+          },
+        },
+      },
+      {
+        name: 'add_remote',
+        description: 'Register a remote git repository so it can be searched with search_code\'s repo argument: it is shallow-cloned at one ref into the cache directory. Registering it again fetches the ref\'s latest commit. Useful for checking how an upstream library does something.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            url: {
+              type: 'string',
+              description: 'Repository URL (e.g. https://github.com/owner/repo.git)',
+            },
+            ref: {
+              type: 'string',
+              description: 'Branch, tag, or commit to fetch (default: the remote\'s default branch)',
+            },
+            name: {
+              type: 'string',
+              description: 'Name to search it by (default: the last component of the URL, without .git)',
+            },
+          },
+          required: ['url'],
+        },
+      },
+      {
+        name: 'add_workspace',
+        description: 'Add a local directory (e.g. a dependency just cloned) as a workspace that search_code can search with its workspace argument, without restarting the server. Its trigram index is built in the background; adding it again refreshes the index.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Directory to add (absolute, or relative to the server\'s workspace)',
+            },
+            name: {
+              type: 'string',
+              description: 'Name to search it by (default: the directory name)',
+            },
+          },
+          required: ['path'],
+        },
+      },
+      {
+        name: 'remove_workspace',
+        description: 'Remove a directory added with add_workspace and drop its index',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            name: {
+              type: 'string',
+              description: 'Name the workspace was added under',
+            },
+          },
+          required: ['name'],
+        },
+      },
+      {
+        name: 'load_code_index',
+        description: 'Load a precomputed LSIF or SCIP index (e.g. built in CI by lsif-go or scip-typescript) to answer definition and references from it, which is much faster than the language server on very large repositories. Files changed since the index was built are still asked of the language server. Loading another index replaces it.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Index file (absolute, or relative to the workspace), e.g. dump.lsif or index.scip',
+            },
+            commit: {
+              type: 'string',
+              description: 'Commit the index was built at; files changed from it are treated as stale (default: files modified after the index file was written)',
+            },
+          },
+          required: ['path'],
+        },
+      },
+      {
+        name: 'capabilities',
+        description: 'Describe what this deployment supports: languages found in the workspace, the attached language server and the LSP features it advertises, index status, enabled optional subsystems, and which tools are usable. Call it first to choose a search strategy.',
+        inputSchema: {
+          type: 'object',
+          properties: {},
+        },
+      },
+      {
+        name: 'explain',
+        description: 'Explain how a query would be executed, without running it: the index or scan used (trigram, scan, symbol, vector), the files in scope, what ignore rules, the size limit, and globs pruned, and the files left to read. Use it when results look incomplete.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            query: {
+              type: 'string',
+              description: 'The pattern, symbol name, or natural-language query to explain',
+            },
+            tool: {
+              type: 'string',
+              enum: ['search_code', 'semantic_search', 'definition', 'references'],
+              description: 'Tool the query is for',
+              default: 'search_code',
+            },
+            regex: { type: 'boolean', description: 'As for search_code', default: false },
+            caseSensitive: { type: 'boolean', description: 'As for search_code', default: false },
+            wholeWord: { type: 'boolean', description: 'As for search_code', default: false },
+            path: { type: 'string', description: 'Only consider files under this path' },
+            glob: {
+              type: 'array',
+              items: { type: 'string' },
+              description: 'As for search_code; defaults to search.glob from the configuration files',
+            },
+            scope: { type: 'string', enum: ['code', 'fixtures', 'all', 'comments'], description: 'As for search_code', default: 'code' },
+            maxResults: { type: 'number', description: 'As for search_code', default: 100 },
+            maxFileSize: { type: 'number', description: 'As for search_code' },
+            includeGenerated: { type: 'boolean', description: 'As for search_code', default: false },
+            binary: { type: 'boolean', description: 'As for search_code', default: false },
+            followSymlinks: { type: 'boolean', description: 'As for search_code' },
+            archives: { type: 'boolean', description: 'As for search_code', default: false },
+            buildTags: { type: 'array', items: { type: 'string' }, description: 'As for search_code' },
+            mode: {
+              type: 'string',
+              enum: ['semantic', 'hybrid'],
+              description: 'As for semantic_search',
+            },
+          },
+          required: ['query'],
+        },
+      },
+      {
+        name: 'plan_search',
+        description: 'Translate a natural-language search request into a structured query plan: intent, identifiers, literals, keywords, path and glob scopes, symbol kinds, and the concrete tool calls to run. Nothing is executed; the client runs or refines the plan.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            request: {
+              type: 'string',
+              description: 'What to look for, in plain language (e.g. "where do we validate email addresses in go code")',
+            },
+          },
+          required: ['request'],
+        },
+      },
+      {
+        name: 'find_duplicates',
+        description: 'Detect near-duplicate code blocks across the workspace using token fingerprinting (winnowing). Returns pairs of locations with the size of the shared block.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            path: {
+              type: 'string',
+              description: 'Only scan files under this path (relative to the workspace or absolute)',
+            },
+            minTokens: {
+              type: 'number',
+              description: 'Minimum length of a duplicated block in tokens',
+              default: 50,
+            },
+            normalizeIdentifiers: {
+              type: 'boolean',
+              description: 'If true, treat blocks that differ only in identifier names as duplicates',
+              default: true,
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of pairs to return',
+              default: 50,
+            },
+            includeGenerated: {
+              type: 'boolean',
+              description: 'If true, also scan generated files',
+              default: false,
+            },
+          },
+        },
+      },
+      {
+        name: 'find_similar',
+        description: 'Find the code most similar to a snippet: regions of the workspace sharing the most token shingles with it, so renamed variables and changed literals still match. Use it to find every place needing the same fix, or where a pasted snippet came from.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            snippet: {
+              type: 'string',
+              description: 'The code to look for (at least 8 tokens)',
+            },
+            path: {
+              type: 'string',
+              description: 'Only look under this path (relative to the workspace or absolute)',
+            },
+            minSimilarity: {
+              type: 'number',
+              description: 'Lowest share of the snippet\'s shingles a region must contain, from 0 to 1',
+              default: 0.5,
+            },
+            maxResults: {
+              type: 'number',
+              description: 'Maximum number of regions to return, most similar first',
+              default: 10,
+            },
+            normalizeIdentifiers: {
+              type: 'boolean',
+              description: 'If true, match code that differs only in identifier names',
+              default: true,
+            },
+            includeGenerated: {
+              type: 'boolean',
+              description: 'If true, also look in generated files',
+              default: false,
+            },
+          },
+          required: ['snippet'],
+        },
+      },
+      ...this.optionalTools(),
+    ];
+    // A plugin cannot take the place of a built-in tool
+    const names = new Set(builtins.map((tool) => tool.name));
+    const plugins = (this.config.plugins ?? [])
+      .filter((plugin) => !names.has(plugin.name) && !pluginDisabledReason(plugin, this.config.readOnly))
+      .map(pluginToolSchema);
+    const tools = [...builtins, ...plugins].filter((tool) => !toolDisabledReason(tool.name, this.config.readOnly));
+    for (const tool of tools) {
+      if (PROJECT_SCOPED_TOOLS[tool.name]) {
+        tool.inputSchema.properties.project = {
+          type: 'string',
+          description: 'Limit the tool to a project of the monorepo (go.work, npm/pnpm, or Cargo workspace member, CMake subdirectory, Gradle project, or Maven module), by name or directory; capabilities lists them',
+        };
+      }
+    }
+    return tools;
+  }
+
+  /**
+   * Projects of the workspace
+   */
+  private getProjects(): Project[] {
+    if (!this.projects) {
+      this.projects = detectProjects(this.config.workspaceDir);
+    }
+    return this.projects;
+  }
+
+  /**
+   * Apply a tool's project argument: resolve paths under the project directory
+   * Returns the arguments and the project directory, if any
+   */
+  private scopeToProject(name: string, args?: Record<string, unknown>): { args?: Record<string, unknown>; scope?: string } {
+    const mode = PROJECT_SCOPED_TOOLS[name];
+    if (typeof args?.project !== 'string' || !args.project || !mode) {
+      return { args };
+    }
+    const project = findProject(this.getProjects(), args.project);
+    const scope = path.join(this.config.workspaceDir, project.path);
+    coreLogger.debug('Scoping %s to project %s (%s)', name, project.name, project.path);
+    const scoped = { ...args };
+    const key = mode === 'path' ? 'path' : 'filePath';
+    const given = args[key];
+    if (typeof given === 'string' && given) {
+      if (!path.isAbsolute(given)) {
+        scoped[key] = path.join(scope, given);
+      }
+    } else if (mode === 'path') {
+      scoped.path = scope;
+    }
+    return { args: scoped, scope };
+  }
+
+  /**
+   * Run a tool, logging one summary entry per call
+   * Every entry logged while the tool runs carries the request ID. Calls over
+   * the session's limits return a throttling error instead of running, calls
+   * that run out of budget what they found so far, and calls arriving during
+   * shutdown an error saying so. The tool runs in a task group cancelled by
+   * the client's signal, at the shutdown deadline, and when the call returns,
+   * so no work it started outlives it
+   */
+  async callTool(
+    name: string,
+    args?: Record<string, unknown>,
+    progressToken?: string | number,
+    session = LOCAL_SESSION,
+    signal?: AbortSignal,
+  ): Promise<ToolResult> {
+    if (this.drain.isClosed()) {
+      toolCalls.inc({ tool: name, status: 'error' });
+      const error = new ToolError('shutting-down', 'The server is shutting down; retry against a new instance');
+      return { content: [{ type: 'text', text: JSON.stringify(error) }], isError: true };
+    }
+    const requestId = crypto.randomBytes(4).toString('hex');
+    return this.drain.track((callSignal) => withLogContext({ requestId }, async () => {
+      const start = Date.now();
+      const fields = { tool: name, args: summarizeArgs(args) };
+      let release: () => void;
+      try {
+        release = this.callLimiter.acquire(session);
+      } catch (err) {
+        if (!(err instanceof ThrottledError)) {
+          throw err;
+        }
+        toolCalls.inc({ tool: name, status: 'throttled' });
+        coreLogger.event(LogLevel.WARN, 'Tool call throttled', { ...fields, session, reason: err.reason, limit: err.limit });
+        const text = JSON.stringify(err);
+        this.audit({ requestId, session, tool: name, args, start, status: 'throttled', resultChars: text.length, error: err.message });
+        return { content: [{ type: 'text', text }], isError: true };
+      }
+      const budget = this.budgets.start(session);
+      try {
+        const snapshot = this.snapshots.pinned(session);
+        if (snapshot && WRITE_TOOLS.has(name) && name !== 'add_remote') {
+          // Edits made from snapshot content would undo the changes made since
+          throw new ToolError('invalid-argument', `${name} is refused while pinned to snapshot ${snapshot.id}; release it with the snapshot tool first`);
+        }
+        let result: ToolResult;
+        try {
+          result = this.redact(await runGroup(callSignal, () =>
+            withBudget(budget, () => withSnapshot(snapshot, () => this.runTool(name, args, progressToken, session)))));
+        } catch (err) {
+          // A tool stopped by its budget, such as at a language server request, ends with no results rather than failing
+          if (!(err instanceof BudgetExceededError) || !budget.exceeded()) {
+            throw err;
+          }
+          result = { content: [] };
+        }
+        const exceeded = budget.exceeded();
+        if (exceeded && !budget.isReported()) {
+          result = { ...result, content: [...result.content, { type: 'text', text: `(${exceeded.message}; results are partial)` }] };
+        }
+        if (exceeded) {
+          coreLogger.event(LogLevel.WARN, 'Tool call over budget', { ...fields, session, scope: exceeded.scope, resource: exceeded.resource, limit: exceeded.limit });
+        }
+        const text = result.content.map((part) => part.text).join('\n');
+        this.audit({ requestId, session, tool: name, args, start, status: 'ok', resultChars: text.length });
+        toolCalls.inc({ tool: name, status: 'ok' });
+        toolDuration.observe({ tool: name }, (Date.now() - start) / 1000);
+        coreLogger.event(LogLevel.INFO, 'Tool call completed', {
+          ...fields,
+          durationMs: Date.now() - start,
+          outputChars: text.length,
+          summary: clip(text.split('\n', 1)[0]),
+        });
+        return result;
+      } catch (err) {
+        // Failures are results with a code to branch on, like throttling
+        const error = toToolError(err);
+        this.audit({ requestId, session, tool: name, args, start, status: 'error', error: error.message });
+        toolCalls.inc({ tool: name, status: 'error' });
+        toolDuration.observe({ tool: name }, (Date.now() - start) / 1000);
+        coreLogger.event(LogLevel.INFO, 'Tool call failed', { ...fields, durationMs: Date.now() - start, code: error.code, error: err });
+        return { content: [{ type: 'text', text: JSON.stringify(error) }], isError: true };
+      } finally {
+        budget.close();
+        release();
+      }
+    }), signal);
+  }
+
+  /**
+   * Append a tool call to the audit log, when AUDIT_LOG_FILE is set
+   */
+  private audit(call: Omit<AuditEntry, 'time' | 'durationMs' | 'args'> & { args?: Record<string, unknown>; start: number }): void {
+    const { start, args, ...entry } = call;
+    try {
+      sharedAuditLog()?.record({
+        time: new Date(start).toISOString(),
+        ...entry,
+        args: args ?? {},
+        durationMs: Date.now() - start,
+      });
+    } catch (err) {
+      coreLogger.error('Failed to write the audit log: %s', err);
+    }
+  }
+
+  /**
+   * Replace likely secrets in a tool result, unless REDACT_SECRETS is false
+   */
+  private redact(result: ToolResult): ToolResult {
+    if (!redactionEnabled()) {
+      return result;
+    }
+    let total = 0;
+    const content = result.content.map((part) => {
+      const { text, count } = redactSecrets(part.text);
+      total += count;
+      return { ...part, text };
+    });
+    if (total === 0) {
+      return result;
+    }
+    coreLogger.debug('Redacted %d likely secret(s)', total);
+    content.push({ type: 'text', text: `(${total} likely secret(s) redacted)` });
+    return { ...result, content };
+  }
+
+  /**
+   * search_code options from tool arguments, with defaults from the configuration
+   */
+  private searchOptions(args?: Record<string, unknown>): LexicalSearchOptions {
+    const defaults = searchDefaultsFromEnv();
+    return {
+      regex: args?.regex as boolean | undefined,
+      caseSensitive: args?.caseSensitive as boolean | undefined,
+      wholeWord: args?.wholeWord as boolean | undefined,
+      identifierWords: args?.identifierWords as boolean | undefined,
+      path: args?.path as string | undefined,
+      glob: (args?.glob as string[] | undefined) ?? this.config.globs,
+      excludeGlob: args?.excludeGlob as string[] | undefined,
+      languages: (args?.languages as string[] | undefined)?.flatMap(resolveLanguage),
+      scope: parseSearchScope(args?.scope) ?? 'code',
+      maxResults: (args?.maxResults as number | undefined) ?? defaults.maxResults,
+      countOmitted: args?.countOmitted as boolean | undefined,
+      maxFileSize: (args?.maxFileSize as number | undefined) ?? defaults.maxFileSize,
+      maxLineLength: (args?.maxLineLength as number | undefined) ?? defaults.maxLineLength,
+      includeGenerated: args?.includeGenerated as boolean | undefined,
+      binary: args?.binary as boolean | undefined,
+      followSymlinks: args?.followSymlinks as boolean | undefined,
+      archives: args?.archives as boolean | undefined,
+      archiveLimits: defaults.archiveLimits,
+      goBuild: args?.buildTags ? buildContext(args.buildTags as string[]) : undefined,
+      timeoutMs: (args?.timeoutMs as number | undefined) ?? defaults.timeoutMs,
+    };
+  }
+
+  /**
+   * search_code options from tool arguments: the search options and how matches are reported
+   */
+  private searchCodeOptions(args?: Record<string, unknown>, pins?: PinSet): SearchCodeOptions {
+    const template = this.resultTemplate(args);
+    const search = this.searchOptions(args);
+    // The template's line length replaces the configured limit, not the argument
+    if (template?.maxLineLength && args?.maxLineLength === undefined) {
+      search.maxLineLength = template.maxLineLength;
+    }
+    return {
+      ...search,
+      ...this.pinnedOptions(args?.pinned, pins),
+      extract: args?.extract as boolean | undefined,
+      distinct: args?.distinct as boolean | undefined,
+      sort: args?.sort as SearchSort | undefined,
+      order: args?.order as 'asc' | 'desc' | undefined,
+      dedupe: args?.dedupe as boolean | undefined,
+      highlight: args?.highlight as boolean | undefined,
+      gitStatus: args?.gitStatus as boolean | undefined,
+      directoryDocs: args?.directoryDocs as boolean | undefined,
+      lastChange: args?.lastChange as boolean | undefined,
+      coverage: args?.coverage ? resolveWorkspacePath(this.config.workspaceDir, args.coverage as string) : undefined,
+      astPath: args?.astPath as boolean | undefined,
+      symbols: args?.context || args?.kind ? (filePath) => getFileSymbols(this.lspClient, this.config.workspaceDir, filePath) : undefined,
+      kind: args?.kind ? resolveKinds(args.kind as string[]) : undefined,
+      matchFilter: args?.matcher ? this.matchFilter(args.matcher as string) : undefined,
+      template,
+    };
+  }
+
+  /**
+   * Filter keeping the matches a matcher from the matchers setting accepts
+   */
+  private matchFilter(name: string): SearchCodeOptions['matchFilter'] {
+    const modulePath = this.config.matchers?.[name];
+    if (!modulePath) {
+      const known = Object.keys(this.config.matchers ?? {});
+      throw new ToolError('invalid-argument', `no matcher "${name}" in the matchers setting` + (known.length > 0 ? ` (configured: ${known.join(', ')})` : ''));
+    }
+    return matcherFilter(name, modulePath);
+  }
+
+  /**
+   * Result template of a call: the profile the arguments name, else the client's, else the default
+   */
+  private resultTemplate(args?: Record<string, unknown>): ResultTemplate | undefined {
+    const requested = args?.template as string | undefined;
+    return resultTemplate(this.config.templates, requested ?? this.server.getClientVersion()?.name, requested !== undefined);
+  }
+
+  /**
+   * Search options narrowed to the source files of a Bazel target
+   * With pinned: only, the files searched are the pinned files of the target
+   */
+  private async targetOptions(target: unknown, options: SearchCodeOptions): Promise<SearchCodeOptions> {
+    if (target === undefined) {
+      return options;
+    }
+    const files = await bazelTargetFiles(this.config.workspaceDir, target as string);
+    const only = options.files ? files.filter((file) => options.files!.includes(file)) : files;
+    if (only.length === 0) {
+      throw new ToolError('invalid-argument', `None of the pinned files are sources of ${target}`);
+    }
+    return { ...options, files: only };
+  }
+
+  /**
+   * Lexical options searching the pinned files only or first
+   */
+  private pinnedOptions(pinned: unknown, pins?: PinSet): Pick<LexicalSearchOptions, 'files' | 'firstFiles'> {
+    if (pinned === undefined) {
+      return {};
+    }
+    if (pinned !== 'only' && pinned !== 'first') {
+      throw new ToolError('invalid-argument', `Unknown pinned "${pinned}"; use only or first`);
+    }
+    const files = pins?.files() ?? [];
+    if (files.length === 0) {
+      throw new ToolError('invalid-argument', 'Nothing is pinned; pin files with pin_file first');
+    }
+    return pinned === 'only' ? { files } : { firstFiles: files };
+  }
+
+  /**
+   * The query watches of a session, created on first use
+   * Changes are sent as logging notifications, which every client can receive
+   */
+  private sessionWatches(session: string): QueryWatches {
+    let watches = this.watches.get(session);
+    if (!watches) {
+      watches = new QueryWatches(this.config.workspaceDir, (watch, diff) => {
+        const data = {
+          watch: watch.id,
+          pattern: watch.pattern,
+          added: diff.added.map(({ filePath, line, column, lineText }) => ({ filePath, line, column, lineText })),
+          removed: diff.removed.map(({ filePath, line, column, lineText }) => ({ filePath, line, column, lineText })),
+          total: watch.matches.length,
+          message: formatWatchDiff(watch, diff),
+        };
+        this.server.sendLoggingMessage({ level: 'info', logger: 'watch_query', data })
+          .catch((err) => coreLogger.debug('Could not send the watch notification: %s', (err as Error).message));
+      });
+      this.watches.set(session, watches);
+    }
+    return watches;
+  }
+
+  /**
+   * The bookmarks of a session, created on first use
+   */
+  private sessionBookmarks(session: string): BookmarkSet {
+    let bookmarks = this.bookmarks.get(session);
+    if (!bookmarks) {
+      bookmarks = new BookmarkSet();
+      this.bookmarks.set(session, bookmarks);
+    }
+    return bookmarks;
+  }
+
+  /**
+   * The pins of a session, created on first use
+   */
+  private sessionPins(session: string): PinSet {
+    let pins = this.pins.get(session);
+    if (!pins) {
+      pins = new PinSet();
+      this.pins.set(session, pins);
+    }
+    return pins;
+  }
+
+  /**
+   * Run a tool call, starting the language server again first when it was stopped for being idle
+   */
+  private async runTool(
+    name: string,
+    args?: Record<string, unknown>,
+    progressToken?: string | number,
+    session = LOCAL_SESSION,
+  ): Promise<ToolResult> {
+    await this.initializing;
+    if (!usesLanguageServer(name, args)) {
+      return this.dispatchTool(name, args, progressToken, session);
+    }
+    const end = this.idleTracker.begin();
+    try {
+      if (this.lspSuspended) {
+        // Calls waiting on the same restart share it, so no one of them may cancel it
+        await uncancellable(() => this.resumeLsp());
+      }
+      return await this.dispatchTool(name, args, progressToken, session);
+    } finally {
+      end();
+    }
+  }
+
+  /**
+   * Dispatch a tool call to its handler
+   */
+  private async dispatchTool(
+    name: string,
+    args?: Record<string, unknown>,
+    progressToken?: string | number,
+    session = LOCAL_SESSION,
+  ): Promise<ToolResult> {
+    await this.initializing;
+    const disabled = toolDisabledReason(name, this.config.readOnly);
+    if (disabled) {
+      throw new ToolError('disabled', `${name} is disabled: ${disabled}`);
+    }
+    // A code index answers references for files it has unchanged
+    if (!this.lspClient && LSP_TOOLS.has(name) && !(name === 'references' && this.codeIndex)) {
+      throw new ToolError('lsp-unavailable', 'LSP client not initialized' + (this.lspError ? `: ${this.lspError}` : ''));
+    }
+    // A search_code query stands for the parameters it spells out; ones passed with it win
+    if (name === 'search_code' && typeof args?.query === 'string') {
+      const { query, ...explicit } = args;
+      args = { ...parseQuery(query as string), ...explicit };
+    }
+    const { args: scopedArgs, scope } = this.scopeToProject(name, args);
+    args = scopedArgs;
+
+    try {
+    switch (name) {
+      case 'definition': {
+        const symbolName = args?.symbolName as string;
+        if (!symbolName) {
+          throw new ToolError('invalid-argument', 'symbolName is required');
+        }
+        coreLogger.debug('Executing definition for symbol: %s', symbolName);
+        const indexed = this.codeIndex && await indexedDefinition(this.codeIndex, symbolName, scope);
+        const result = indexed || await readDefinition(this.lspClient, this.config.workspaceDir, symbolName, scope,
+          buildContext((args?.buildTags as string[] | undefined) ?? []));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'references': {
+        const symbolName = args?.symbolName as string;
+        if (!symbolName) {
+          throw new ToolError('invalid-argument', 'symbolName is required');
+        }
+        coreLogger.debug('Executing references for symbol: %s', symbolName);
+        // The index does not tell reads from writes
+        const indexed = this.codeIndex && !args?.classify && !args?.access
+          ? await indexedReferences(this.codeIndex, this.lspClient, symbolName, scope, args?.scope as ReferenceScope | undefined)
+          : undefined;
+        if (!indexed && !this.lspClient) {
+          throw new ToolError('lsp-unavailable', 'LSP client not initialized' + (this.lspError ? `: ${this.lspError}` : ''));
+        }
+        const result = indexed || await findReferences(this.lspClient!, symbolName, scope, {
+          classify: args?.classify as boolean | undefined,
+          access: args?.access as ReferenceAccess | undefined,
+          within: args?.scope as ReferenceScope | undefined,
+        });
+        const rules = this.config.links ?? [];
+        if (rules.length === 0 || args?.links === false) {
+          return { content: [{ type: 'text', text: result }] };
+        }
+        const linked = await findLinkedReferences(this.config.workspaceDir, symbolName, rules, this.trigramIndex, scope);
+        return { content: [{ type: 'text', text: [result, formatLinkedReferences(symbolName, linked)].filter(Boolean).join('\n') }] };
+      }
+
+      case 'diagnostics': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        if (!filePath) {
+          throw new ToolError('invalid-argument', 'filePath is required');
+        }
+        const contextLines = (args?.contextLines as number) ?? 5;
+        const showLineNumbers = (args?.showLineNumbers as boolean) ?? true;
+        coreLogger.debug('Executing diagnostics for file: %s', filePath);
+        const result = await getDiagnosticsForFile(
+          this.lspClient!,
+          filePath,
+          contextLines,
+          showLineNumbers
+        );
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'hover': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        const line = args?.line as number;
+        const column = args?.column as number;
+        if (!filePath || !line || !column) {
+          throw new ToolError('invalid-argument', 'filePath, line, and column are required');
+        }
+        coreLogger.debug('Executing hover for file: %s line: %d column: %d', filePath, line, column);
+        const result = await getHoverInfo(this.lspClient!, filePath, line, column);
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'rename_symbol': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        const line = args?.line as number;
+        const column = args?.column as number;
+        const newName = args?.newName as string;
+        if (!filePath || !line || !column || !newName) {
+          throw new ToolError('invalid-argument', 'filePath, line, column, and newName are required');
+        }
+        coreLogger.debug('Executing rename_symbol for file: %s line: %d column: %d newName: %s',
+          filePath, line, column, newName);
+        const result = await renameSymbol(this.lspClient!, filePath, line, column, newName, {
+          workspaceDir: this.config.workspaceDir,
+          force: args?.force as boolean | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'edit_file': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        const edits = args?.edits as TextEdit[];
+        if (!filePath || !edits) {
+          throw new ToolError('invalid-argument', 'filePath and edits are required');
+        }
+        coreLogger.debug('Executing edit_file for file: %s', filePath);
+        const result = await applyTextEdits(this.lspClient!, filePath, edits, args?.expectedHash as string | undefined);
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'replace_text': {
+        const from = args?.from as string;
+        const to = args?.to as string | undefined;
+        if (!from || to === undefined) {
+          throw new ToolError('invalid-argument', 'from and to are required');
+        }
+        coreLogger.debug('Executing replace_text from %s to %s (apply: %s)', from, to, args?.apply ?? false);
+        const options = {
+          caseAware: args?.caseAware as boolean | undefined,
+          regex: args?.regex as boolean | undefined,
+          caseSensitive: args?.caseSensitive as boolean | undefined,
+          wholeWord: args?.wholeWord as boolean | undefined,
+          path: args?.path as string | undefined,
+          glob: args?.glob as string[] | undefined,
+          excludeGlob: args?.excludeGlob as string[] | undefined,
+          apply: args?.apply as boolean | undefined,
+        };
+        const skipped: Array<{ relativePath: string; reason: string }> = [];
+        const replacements = await replaceText(this.config.workspaceDir, from, to, {
+          ...options,
+          onSkip: (relativePath, reason) => skipped.push({ relativePath, reason }),
+        }, this.trigramIndex);
+        if (options.apply) {
+          // Results computed before the files were written are stale
+          this.queryCache.invalidate();
+        }
+        return { content: [{ type: 'text', text: formatReplacements(from, to, replacements, options, skipped) }] };
+      }
+
+      case 'plan_refactor': {
+        const from = args?.from as string;
+        const to = args?.to as string | undefined;
+        if (!from || to === undefined) {
+          throw new ToolError('invalid-argument', 'from and to are required');
+        }
+        coreLogger.debug('Executing plan_refactor from %s to %s', from, to);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), async () =>
+          formatRefactorPlan(await planRefactor(this.config.workspaceDir, from, to, {
+            caseAware: args?.caseAware as boolean | undefined,
+            regex: args?.regex as boolean | undefined,
+            caseSensitive: args?.caseSensitive as boolean | undefined,
+            wholeWord: args?.wholeWord as boolean | undefined,
+            path: args?.path as string | undefined,
+            glob: args?.glob as string[] | undefined,
+            excludeGlob: args?.excludeGlob as string[] | undefined,
+            maxChunkFiles: args?.maxChunkFiles as number | undefined,
+          }, this.trigramIndex))
+        );
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'todo_comments': {
+        coreLogger.debug('Executing todo_comments');
+        const lspClient = this.lspClient;
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          findTodos(lspClient, this.config.workspaceDir, {
+            tags: args?.tags as string[] | undefined,
+            path: args?.path as string | undefined,
+            includeBlame: args?.includeBlame as boolean | undefined,
+            includeSymbols: args?.includeSymbols as boolean | undefined,
+            maxResults: args?.maxResults as number | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'api_surface': {
+        const targetPath = args?.path as string;
+        if (!targetPath) {
+          throw new ToolError('invalid-argument', 'path is required');
+        }
+        coreLogger.debug('Executing api_surface for path: %s', targetPath);
+        const result = await getApiSurface(this.lspClient, this.config.workspaceDir, targetPath, {
+          recursive: args?.recursive as boolean | undefined,
+          includeDocs: args?.includeDocs as boolean | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'symbol_usage': {
+        const targetPath = args?.path as string;
+        if (!targetPath) {
+          throw new ToolError('invalid-argument', 'path is required');
+        }
+        coreLogger.debug('Executing symbol_usage for path: %s', targetPath);
+        const limit = args?.limit as number | undefined;
+        const usages = await symbolUsage(this.lspClient, this.config.workspaceDir, targetPath, {
+          recursive: args?.recursive as boolean | undefined,
+          exportedOnly: args?.exportedOnly as boolean | undefined,
+          limit,
+        }, this.trigramIndex);
+        return { content: [{ type: 'text', text: formatSymbolUsage(targetPath, usages, limit) }] };
+      }
+
+      case 'vocabulary': {
+        const prefix = args?.prefix as string;
+        if (typeof prefix !== 'string') {
+          throw new ToolError('invalid-argument', 'prefix is required');
+        }
+        coreLogger.debug('Executing vocabulary for prefix: %s', prefix);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), async () =>
+          formatVocabulary(await workspaceVocabulary(this.config.workspaceDir, prefix, {
+            kind: args?.kind as VocabularyKind | undefined,
+            path: args?.path as string | undefined,
+            caseSensitive: args?.caseSensitive as boolean | undefined,
+          }, this.trigramIndex), args?.limit as number | undefined));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'explore_symbol': {
+        const symbolName = args?.symbolName as string;
+        if (!symbolName) {
+          throw new ToolError('invalid-argument', 'symbolName is required');
+        }
+        coreLogger.debug('Executing explore_symbol for symbol: %s', symbolName);
+        const maxReferences = args?.maxReferences as number | undefined;
+        const explored = await exploreSymbol(this.lspClient, this.config.workspaceDir, symbolName, { scope }, this.trigramIndex);
+        return { content: [{ type: 'text', text: formatExploredSymbol(this.config.workspaceDir, explored, maxReferences) }] };
+      }
+
+      case 'impact_report': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        const line = args?.line as number;
+        const column = args?.column as number;
+        if (!filePath || !line || !column) {
+          throw new ToolError('invalid-argument', 'filePath, line, and column are required');
+        }
+        coreLogger.debug('Executing impact_report for file: %s line: %d column: %d', filePath, line, column);
+        const result = await getImpactReport(this.lspClient!, this.config.workspaceDir, filePath, line, column, {
+          changeKind: args?.changeKind as ChangeKind | undefined,
+          newName: args?.newName as string | undefined,
+          includeSymbols: args?.includeSymbols as boolean | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'search_code': {
+        const pattern = args?.pattern as string;
+        if (!pattern) {
+          throw new ToolError('invalid-argument', 'pattern or query is required');
+        }
+        const repo = args?.repo as string | undefined;
+        if (args?.pinned !== undefined && (repo || args?.workspace)) {
+          throw new ToolError('invalid-argument', 'pinned applies to the server\'s workspace only, not to repo or workspace');
+        }
+        if (args?.target !== undefined && (repo || args?.workspace)) {
+          throw new ToolError('invalid-argument', 'target applies to the server\'s workspace only, not to repo or workspace');
+        }
+        if (repo) {
+          const remote = await this.remotes.get(repo);
+          coreLogger.debug('Executing search_code for pattern: %s in remote %s', pattern, repo);
+          // Remotes are not indexed, and only change when they are fetched again
+          const result = await searchCode(remote.dir, pattern, this.searchCodeOptions(args), undefined,
+            this.progressReporter(progressToken));
+          return { content: [{ type: 'text', text: `Remote ${formatRemote(remote)}\n\n${result}` }] };
+        }
+        const workspace = args?.workspace as string | undefined;
+        if (workspace) {
+          const root = this.roots.get(workspace);
+          coreLogger.debug('Executing search_code for pattern: %s in workspace %s', pattern, workspace);
+          // The query cache tracks the server's workspace only
+          const result = await searchCode(root.dir, pattern, this.searchCodeOptions(args), root.index,
+            this.progressReporter(progressToken));
+          return { content: [{ type: 'text', text: `Workspace ${root.name} (${root.dir})\n\n${result}` }] };
+        }
+        coreLogger.debug('Executing search_code for pattern: %s', pattern);
+        const pins = this.sessionPins(session);
+        // The pinned files change between calls with the same arguments
+        const key = queryKey(name, args?.pinned !== undefined ? { ...args, pinnedFiles: pins.files() } : args);
+        let partial = false;
+        const result = await this.queryCache.getOrCompute(key, async () => {
+          const found = await searchCodeResult(this.config.workspaceDir, pattern,
+            await this.targetOptions(args?.target, this.searchCodeOptions(args, pins)), this.trigramIndex, this.progressReporter(progressToken));
+          partial = found.partial;
+          return found.text;
+        }, () => !partial);
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'pin_file': {
+        const action = (args?.action as string | undefined) ?? 'pin';
+        const pins = this.sessionPins(session);
+        const filePath = this.resolveFilePath(args?.filePath);
+        const symbolName = args?.symbolName as string | undefined;
+        coreLogger.debug('Executing pin_file %s for file: %s, symbol: %s', action, filePath, symbolName);
+        let text: string;
+        if (action === 'pin') {
+          const resolved = await resolvePins(this.config.workspaceDir, this.lspClient, filePath || undefined, symbolName);
+          const added = resolved.filter((pin) => pins.pin(pin));
+          text = added.length > 0
+            ? `Pinned ${added.map(describePin).join(', ')}`
+            : `Already pinned: ${resolved.map(describePin).join(', ')}`;
+        } else if (action === 'unpin') {
+          if (!filePath && !symbolName) {
+            throw new ToolError('invalid-argument', 'filePath or symbolName is required');
+          }
+          const relativePath = filePath ? path.relative(this.config.workspaceDir, filePath) : undefined;
+          const removed = pins.unpin(relativePath, symbolName);
+          text = removed.length > 0 ? `Unpinned ${removed.map(describePin).join(', ')}` : 'Nothing matching was pinned';
+        } else if (action === 'clear') {
+          const count = pins.clear();
+          text = `Unpinned ${count} pin(s)`;
+        } else {
+          throw new ToolError('invalid-argument', `Unknown pin action "${action}"; use pin, unpin, or clear`);
+        }
+        return { content: [{ type: 'text', text: `${text}\n\n${formatPins(pins.list())}` }] };
+      }
+
+      case 'watch_query': {
+        const action = (args?.action as string | undefined) ?? 'watch';
+        const watches = this.sessionWatches(session);
+        coreLogger.debug('Executing watch_query %s', action);
+        if (action === 'list') {
+          return { content: [{ type: 'text', text: formatWatches(watches.list()) }] };
+        }
+        if (action === 'unwatch') {
+          const removed = watches.unwatch(args?.id as string | undefined);
+          const text = removed.length > 0 ? `Stopped watching ${removed.map((watch) => watch.id).join(', ')}` : 'No queries were watched';
+          return { content: [{ type: 'text', text }] };
+        }
+        if (action !== 'watch') {
+          throw new ToolError('invalid-argument', `Unknown watch action "${action}"; use watch, unwatch, or list`);
+        }
+        const pattern = args?.pattern as string;
+        if (!pattern) {
+          throw new ToolError('invalid-argument', 'pattern is required');
+        }
+        const watch = await watches.watch(pattern, {
+          regex: args?.regex as boolean | undefined,
+          caseSensitive: args?.caseSensitive as boolean | undefined,
+          wholeWord: args?.wholeWord as boolean | undefined,
+          path: args?.path as string | undefined,
+          glob: args?.glob as string[] | undefined,
+          excludeGlob: args?.excludeGlob as string[] | undefined,
+        }, this.trigramIndex);
+        let text = `Watching ${describeWatch(watch)}\nChanges are sent as notifications/message from logger "watch_query"; stop with action unwatch and id ${watch.id}`;
+        if (!this.workspaceWatcher) {
+          text += '\nThe file watcher starts with the language server; changes are reported once it runs';
+        }
+        return { content: [{ type: 'text', text }] };
+      }
+
+      case 'list_pins': {
+        coreLogger.debug('Executing list_pins');
+        return { content: [{ type: 'text', text: formatPins(this.sessionPins(session).list()) }] };
+      }
+
+      case 'bookmark_symbol': {
+        const action = (args?.action as string | undefined) ?? 'add';
+        const bookmarks = this.sessionBookmarks(session);
+        coreLogger.debug('Executing bookmark_symbol %s', action);
+        if (action === 'remove') {
+          const bookmarkName = args?.name as string | undefined;
+          if (!bookmarkName) {
+            throw new ToolError('invalid-argument', 'name is required');
+          }
+          const text = bookmarks.remove(bookmarkName) ? `Removed bookmark ${bookmarkName}` : `No bookmark named ${bookmarkName}`;
+          return { content: [{ type: 'text', text }] };
+        }
+        if (action === 'clear') {
+          const count = bookmarks.clear();
+          return { content: [{ type: 'text', text: `Removed ${count} bookmark(s)` }] };
+        }
+        if (action !== 'add') {
+          throw new ToolError('invalid-argument', `Unknown bookmark action "${action}"; use add, remove, or clear`);
+        }
+        const filePath = this.resolveFilePath(args?.filePath);
+        if (!filePath) {
+          throw new ToolError('invalid-argument', 'filePath is required');
+        }
+        const bookmark = bookmarks.add(await createBookmark(this.config.workspaceDir, this.lspClient, filePath, {
+          symbolName: args?.symbolName as string | undefined,
+          line: args?.line as number | undefined,
+          name: args?.name as string | undefined,
+          note: args?.note as string | undefined,
+        }));
+        return { content: [{ type: 'text', text: `Bookmarked ${describeBookmark(bookmark)}\nFind it again with resolve_bookmark and name ${bookmark.name}` }] };
+      }
+
+      case 'resolve_bookmark': {
+        const bookmarks = this.sessionBookmarks(session);
+        const bookmarkName = args?.name as string | undefined;
+        coreLogger.debug('Executing resolve_bookmark %s', bookmarkName ?? '(all)');
+        let selected = bookmarks.list();
+        if (bookmarkName) {
+          const bookmark = bookmarks.get(bookmarkName);
+          if (!bookmark) {
+            throw new ToolError('not-found', `No bookmark named ${bookmarkName}; add one with bookmark_symbol`);
+          }
+          selected = [bookmark];
+        }
+        const resolved: ResolvedBookmark[] = [];
+        for (const bookmark of selected) {
+          resolved.push(await resolveBookmark(this.config.workspaceDir, this.lspClient, bookmark));
+        }
+        return { content: [{ type: 'text', text: formatResolvedBookmarks(resolved) }] };
+      }
+
+      case 'snapshot': {
+        const action = (args?.action as string | undefined) ?? 'create';
+        coreLogger.debug('Executing snapshot %s', action);
+        if (action === 'create') {
+          const snapshot = await unpinned(() => takeSnapshot(this.config.workspaceDir));
+          this.snapshots.pin(session, snapshot);
+          return { content: [{ type: 'text', text: formatPinnedSnapshot(this.snapshots.pinned(session)!) }] };
+        }
+        if (action === 'pin') {
+          const id = args?.id as string | undefined;
+          if (!id) {
+            throw new ToolError('invalid-argument', 'id is required');
+          }
+          const snapshot = this.snapshots.get(id);
+          if (!snapshot) {
+            throw new ToolError('not-found', `No snapshot named ${id}; snapshots are kept while a session is pinned to them`);
+          }
+          this.snapshots.pin(session, snapshot);
+          return { content: [{ type: 'text', text: formatPinnedSnapshot(snapshot) }] };
+        }
+        if (action === 'release') {
+          const released = this.snapshots.release(session);
+          return { content: [{ type: 'text', text: released ? `Released snapshot ${released.id}; reads see the files as they are now` : 'This session was not pinned to a snapshot' }] };
+        }
+        if (action === 'list') {
+          return { content: [{ type: 'text', text: formatSnapshots(this.snapshots.list(), this.snapshots.pinned(session)) }] };
+        }
+        throw new ToolError('invalid-argument', `Unknown snapshot action "${action}"; use create, pin, release, or list`);
+      }
+
+      case 'overlay': {
+        const action = args?.action as string;
+        const overlay = sharedOverlay();
+        const filePath = this.resolveFilePath(args?.filePath);
+        coreLogger.debug('Executing overlay %s for file: %s', action, filePath);
+        let changed: string[];
+        let text: string;
+        if (action === 'set') {
+          const content = args?.content;
+          if (!filePath || typeof content !== 'string') {
+            throw new ToolError('invalid-argument', 'filePath and content are required');
+          }
+          const entry = overlay.set(filePath, content);
+          changed = [filePath];
+          text = `Overlaid ${path.relative(this.config.workspaceDir, filePath)} (version ${entry.version}, ${content.split('\n').length} line(s))`;
+        } else if (action === 'clear') {
+          changed = filePath ? (overlay.delete(filePath) ? [filePath] : []) : overlay.clear();
+          text = changed.length > 0
+            ? `Cleared the overlay of ${changed.map((file) => path.relative(this.config.workspaceDir, file)).join(', ')}`
+            : 'No overlay to clear';
+        } else if (action === 'list') {
+          changed = [];
+          const entries = overlay.entries();
+          text = entries.length > 0
+            ? entries.map(([file, entry]) => `- ${path.relative(this.config.workspaceDir, file)} (version ${entry.version}, ` +
+              `${entry.content.length} character(s)${fs.existsSync(file) ? '' : ', not on disk'})`).join('\n')
+            : 'No overlaid files';
+        } else {
+          throw new ToolError('invalid-argument', `unknown overlay action: ${action}`);
+        }
+        for (const file of changed) {
+          await this.lspClient?.syncFile(file);
+          this.semanticEngine?.invalidateFile(file);
+        }
+        if (changed.length > 0) {
+          this.queryCache.invalidate();
+        }
+        return { content: [{ type: 'text', text }] };
+      }
+
+      case 'add_remote': {
+        const url = args?.url as string;
+        if (!url) {
+          throw new ToolError('invalid-argument', 'url is required');
+        }
+        coreLogger.debug('Executing add_remote for %s', url);
+        const remote = await this.remotes.add(url, { ref: args?.ref as string | undefined, name: args?.name as string | undefined });
+        const registered = (await this.remotes.list()).map((entry) => `- ${formatRemote(entry)}`).join('\n');
+        return { content: [{ type: 'text', text: `Fetched ${formatRemote(remote)} into ${remote.dir}\n\nRemote repositories:\n${registered}` }] };
+      }
+
+      case 'load_code_index': {
+        const file = args?.path as string;
+        if (!file) {
+          throw new ToolError('invalid-argument', 'path is required');
+        }
+        coreLogger.debug('Executing load_code_index for %s', file);
+        this.codeIndex = await loadCodeIndex(this.config.workspaceDir, file, { commit: args?.commit as string | undefined });
+        return { content: [{ type: 'text', text: `Loaded ${this.codeIndex.describe()}` }] };
+      }
+
+      case 'add_workspace': {
+        const dir = args?.path as string;
+        if (!dir) {
+          throw new ToolError('invalid-argument', 'path is required');
+        }
+        coreLogger.debug('Executing add_workspace for %s', dir);
+        const root = this.roots.add(dir, args?.name as string | undefined);
+        await Promise.race([root.indexing, new Promise((resolve) => setTimeout(resolve, WORKSPACE_INDEX_WAIT_MS))]);
+        const added = this.roots.list().map((entry) => `- ${formatRoot(entry)}`).join('\n');
+        return { content: [{ type: 'text', text: `Added workspace ${formatRoot(root)}\n\nAdded workspaces:\n${added}` }] };
+      }
+
+      case 'remove_workspace': {
+        const rootName = args?.name as string;
+        if (!rootName) {
+          throw new ToolError('invalid-argument', 'name is required');
+        }
+        coreLogger.debug('Executing remove_workspace for %s', rootName);
+        const root = this.roots.remove(rootName);
+        const remaining = this.roots.list().map((entry) => `- ${formatRoot(entry)}`);
+        return { content: [{ type: 'text', text: `Removed workspace ${root.name} (${root.dir})\n\nAdded workspaces:\n${remaining.length > 0 ? remaining.join('\n') : '(none)'}` }] };
+      }
+
+      case 'capabilities': {
+        coreLogger.debug('Executing capabilities');
+        const lspClient = this.lspClient;
+        const semantic = this.semanticEngine;
+        const metricsAddress = this.metricsServer?.address();
+        const capabilities = await getCapabilities(this.config.workspaceDir, {
+          languageServer: lspClient && {
+            command: lspClient.command,
+            args: lspClient.args,
+            name: lspClient.serverInfo?.name,
+            version: lspClient.serverInfo?.version,
+            running: lspClient.isRunning(),
+            positionEncoding: lspClient.positionEncoding,
+            capabilities: lspClient.serverCapabilities,
+          },
+          pythonFallback: usesPythonFallback(lspClient),
+          index: { loaded: this.trigramIndex.isLoaded(), ...this.trigramIndex.getStats() },
+          semantic: semantic ? { provider: semantic.providerId, ...semantic.getStats() } : undefined,
+          subsystems: {
+            'query cache': process.env.QUERY_CACHE_ENABLED !== 'false',
+            'language server cache': process.env.CACHE_ENABLED !== 'false',
+            'semantic search': !!semantic,
+            'metrics and health endpoint': metricsAddress && typeof metricsAddress === 'object'
+              ? `http://${metricsAddress.address}:${metricsAddress.port}`
+              : false,
+            'configuration reload': !!this.configWatcher,
+            'read-only mode': !!this.config.readOnly,
+            'remote repositories': this.remotes.names().length > 0 ? this.remotes.names().join(', ') : false,
+            'added workspaces': this.roots.names().length > 0 ? this.roots.names().join(', ') : false,
+            'built-in Python symbols': usesPythonFallback(lspClient),
+            'JSX search (tree-sitter)': jsxSearchAvailable(),
+          },
+          tools: this.listTools().map((tool) => tool.name),
+          projects: this.getProjects(),
+        });
+        return { content: [{ type: 'text', text: formatCapabilities(capabilities) }] };
+      }
+
+      case 'explain': {
+        const query = args?.query as string;
+        if (!query) {
+          throw new ToolError('invalid-argument', 'query is required');
+        }
+        const tool = (args?.tool as ExplainTool | undefined) ?? 'search_code';
+        coreLogger.debug('Executing explain for %s query: %s', tool, query);
+        const options = { ...this.searchOptions(args), mode: args?.mode as SearchMode | undefined };
+        const lspClient = this.lspClient;
+        const explanation = await explainQuery(this.config.workspaceDir, tool, query, options, {
+          index: this.trigramIndex,
+          semantic: this.semanticEngine,
+          symbolCached: (symbolName) => !!lspClient && lspClient.getCacheManager().getWorkspaceSymbols(symbolName) !== null,
+        });
+        return { content: [{ type: 'text', text: formatExplanation(explanation, options) }] };
+      }
+
+      case 'plan_search': {
+        const request = args?.request as string;
+        if (!request) {
+          throw new ToolError('invalid-argument', 'request is required');
+        }
+        coreLogger.debug('Executing plan_search for: %s', request);
+        const plan = planSearch(this.config.workspaceDir, request, { semanticEnabled: !!this.semanticEngine });
+        return { content: [{ type: 'text', text: formatSearchPlan(plan) }] };
+      }
+
+      case 'find_duplicates': {
+        coreLogger.debug('Executing find_duplicates');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          findDuplicates(this.config.workspaceDir, {
+            path: args?.path as string | undefined,
+            minTokens: args?.minTokens as number | undefined,
+            normalizeIdentifiers: args?.normalizeIdentifiers as boolean | undefined,
+            maxResults: args?.maxResults as number | undefined,
+            includeGenerated: args?.includeGenerated as boolean | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'find_similar': {
+        const snippet = args?.snippet as string;
+        if (!snippet) {
+          throw new ToolError('invalid-argument', 'snippet is required');
+        }
+        coreLogger.debug('Executing find_similar for a snippet of %d characters', snippet.length);
+        const minSimilarity = args?.minSimilarity as number | undefined;
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), async () =>
+          formatSimilar(this.config.workspaceDir, await findSimilar(this.config.workspaceDir, snippet, {
+            path: args?.path as string | undefined,
+            minSimilarity,
+            maxResults: args?.maxResults as number | undefined,
+            normalizeIdentifiers: args?.normalizeIdentifiers as boolean | undefined,
+            includeGenerated: args?.includeGenerated as boolean | undefined,
+          }), minSimilarity));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'search_jsx': {
+        coreLogger.debug('Executing search_jsx');
+        const options: JsxSearchOptions = {
+          component: args?.component as string | undefined,
+          prop: args?.prop as string | undefined,
+          hook: args?.hook as string | undefined,
+          within: args?.within as string | undefined,
+          path: args?.path as string | undefined,
+          glob: args?.glob as string[] | undefined,
+          maxResults: args?.maxResults as number | undefined,
+        };
+        validateJsxQuery(options);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          findJsx(this.config.workspaceDir, options));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'query_config': {
+        const keyPath = args?.keyPath as string;
+        if (!keyPath) {
+          throw new ToolError('invalid-argument', 'keyPath is required');
+        }
+        coreLogger.debug('Executing query_config for %s', keyPath);
+        parseKeyPath(keyPath);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          queryConfig(this.config.workspaceDir, keyPath, {
+            value: args?.value as string | undefined,
+            path: args?.path as string | undefined,
+            glob: args?.glob as string[] | undefined,
+            maxResults: args?.maxResults as number | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'proto_references': {
+        const symbolName = args?.symbolName as string;
+        if (!symbolName) {
+          throw new ToolError('invalid-argument', 'symbolName is required');
+        }
+        coreLogger.debug('Executing proto_references for %s', symbolName);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          protoReferences(this.config.workspaceDir, symbolName, {
+            path: args?.path as string | undefined,
+            maxResults: args?.maxResults as number | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'bazel_targets': {
+        coreLogger.debug('Executing bazel_targets for file: %s, target: %s', args?.filePath, args?.target);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          listBazelTargets(this.config.workspaceDir, {
+            filePath: args?.filePath as string | undefined,
+            target: args?.target as string | undefined,
+            path: args?.path as string | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'build_targets': {
+        coreLogger.debug('Executing build_targets');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          listBuildTargets(this.config.workspaceDir, {
+            target: args?.target as string | undefined,
+            pattern: args?.pattern as string | undefined,
+            path: args?.path as string | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'sql_search': {
+        coreLogger.debug('Executing sql_search');
+        const options = {
+          table: args?.table as string | undefined,
+          column: args?.column as string | undefined,
+          statement: args?.statement as string | undefined,
+          pattern: args?.pattern as string | undefined,
+          path: args?.path as string | undefined,
+          maxResults: args?.maxResults as number | undefined,
+        };
+        if (!options.table && !options.column && !options.statement && !options.pattern) {
+          throw new ToolError('invalid-argument', 'at least one of table, column, statement, and pattern is required');
+        }
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () => sqlSearch(this.config.workspaceDir, options));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'docker_files': {
+        coreLogger.debug('Executing docker_files');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          analyzeDockerFiles(this.config.workspaceDir, {
+            stage: args?.stage as string | undefined,
+            pattern: args?.pattern as string | undefined,
+            path: args?.path as string | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'outline': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        if (!filePath) {
+          throw new ToolError('invalid-argument', 'filePath is required');
+        }
+        coreLogger.debug('Executing outline for file: %s', filePath);
+        const result = await getFileOutline(this.lspClient, this.config.workspaceDir, filePath, {
+          maxDepth: args?.maxDepth as number | undefined,
+          includeDocs: args?.includeDocs as boolean | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'read_range': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        if (!filePath) {
+          throw new ToolError('invalid-argument', 'filePath is required');
+        }
+        const chunk = args?.chunk as number | undefined;
+        if (chunk !== undefined) {
+          if (args?.startLine !== undefined || args?.endLine !== undefined) {
+            throw new ToolError('invalid-argument', 'Pass either chunk or startLine and endLine, not both');
+          }
+          coreLogger.debug('Executing read_range chunk %d for file: %s', chunk, filePath);
+          let symbols: FlatSymbol[] = [];
+          try {
+            symbols = await getFileSymbols(this.lspClient, this.config.workspaceDir, filePath);
+          } catch (err) {
+            // Chunks are cut at blank lines instead
+            coreLogger.debug('No symbols for %s: %s', filePath, (err as Error).message);
+          }
+          const spans = symbols.map((s) => ({
+            name: s.qualifiedName,
+            startLine: s.range.start.line + 1,
+            endLine: s.range.end.line + 1,
+            depth: s.depth,
+          }));
+          const result = await readChunk(this.config.workspaceDir, filePath, chunk, spans, args?.chunkLines as number | undefined);
+          return { content: [{ type: 'text', text: result }] };
+        }
+        coreLogger.debug('Executing read_range for file: %s', filePath);
+        const result = await readRange(this.config.workspaceDir, filePath,
+          args?.startLine as number | undefined, args?.endLine as number | undefined);
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'tree': {
+        coreLogger.debug('Executing tree');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          directoryTree(this.config.workspaceDir, {
+            path: args?.path as string | undefined,
+            depth: args?.depth as number | undefined,
+            maxEntries: args?.maxEntries as number | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'file_info': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        if (!filePath) {
+          throw new ToolError('invalid-argument', 'filePath is required');
+        }
+        coreLogger.debug('Executing file_info for file: %s', filePath);
+        const result = await getFileInfo(this.config.workspaceDir, filePath);
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'batch_search': {
+        const { queries, ...shared } = args ?? {};
+        coreLogger.debug('Executing batch_search');
+        const result = await batchSearch(queries as BatchQuery[], shared, async (queryArgs) =>
+          (await this.runTool('search_code', queryArgs, undefined, session)).content.map((part) => part.text).join('\n'));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'entry_points': {
+        coreLogger.debug('Executing entry_points');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          listEntryPoints(this.config.workspaceDir, {
+            path: args?.path as string | undefined,
+            kind: args?.kind as EntryPointKind | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'routes': {
+        coreLogger.debug('Executing routes');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          listRoutes(this.config.workspaceDir, {
+            route: args?.route as string | undefined,
+            method: args?.method as string | undefined,
+            path: args?.path as string | undefined,
+            framework: args?.framework as string | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'env_vars': {
+        coreLogger.debug('Executing env_vars');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          listEnvVars(this.config.workspaceDir, {
+            path: args?.path as string | undefined,
+            name: args?.name as string | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'i18n_messages': {
+        coreLogger.debug('Executing i18n_messages for key: %s, text: %s', args?.key, args?.text);
+        // Anything but a positive whole number takes the default
+        const limit = Number.isInteger(args?.limit) && (args!.limit as number) > 0 ? args!.limit as number : undefined;
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          findMessages(this.config.workspaceDir, {
+            key: args?.key as string | undefined,
+            text: args?.text as string | undefined,
+          }, {
+            path: args?.path as string | undefined,
+            locale: args?.locale as string | undefined,
+          }, limit));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'feature_flags': {
+        coreLogger.debug('Executing feature_flags');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          listFeatureFlags(this.config.workspaceDir, {
+            flag: args?.flag as string | undefined,
+            path: args?.path as string | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'test_pairs': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        if (!filePath) {
+          throw new ToolError('invalid-argument', 'filePath is required');
+        }
+        coreLogger.debug('Executing test_pairs for file: %s', filePath);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), async () =>
+          formatTestPairing(await pairTests(this.config.workspaceDir, filePath, { limit: args?.limit as number | undefined })));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'importers': {
+        const target = args?.path as string;
+        if (!target) {
+          throw new ToolError('invalid-argument', 'path is required');
+        }
+        coreLogger.debug('Executing importers for %s', target);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), async () =>
+          formatImporters(await findImporters(this.config.workspaceDir, target, {
+            depth: args?.depth as number | undefined,
+            includeTests: args?.includeTests as boolean | undefined,
+          }), args?.limit as number | undefined));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'recent_files': {
+        coreLogger.debug('Executing recent_files');
+        const result = await recentFiles(this.config.workspaceDir, {
+          by: args?.by as 'mtime' | 'git' | undefined,
+          path: args?.path as string | undefined,
+          glob: args?.glob as string[] | undefined,
+          language: args?.language as string | undefined,
+          limit: args?.limit as number | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'stats': {
+        coreLogger.debug('Executing stats');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          workspaceStats(this.config.workspaceDir, { path: args?.path as string | undefined }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'warmup': {
+        coreLogger.debug('Executing warmup');
+        // Warming shared indexes and the language server uses the files as they are now
+        const report = await unpinned(() => warmupWorkspace(this.config.workspaceDir, this.trigramIndex, this.lspClient, {
+          maxFiles: args?.maxFiles as number | undefined,
+          concurrency: args?.concurrency as number | undefined,
+          languageServerError: this.lspError,
+          semanticEngine: this.semanticEngine,
+        }));
+        return { content: [{ type: 'text', text: formatWarmup(report) }] };
+      }
+
+      case 'go_interfaces': {
+        const typeName = args?.typeName as string;
+        if (!typeName) {
+          throw new ToolError('invalid-argument', 'typeName is required');
+        }
+        coreLogger.debug('Executing go_interfaces for %s', typeName);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          goInterfaceReport(this.config.workspaceDir, typeName, { maxMissing: args?.maxMissing as number | undefined }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'package_usages': {
+        const pkg = args?.package as string;
+        if (!pkg) {
+          throw new ToolError('invalid-argument', 'package is required');
+        }
+        coreLogger.debug('Executing package_usages for %s', pkg);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), async () =>
+          formatPackageUsages(await findPackageUsages(this.config.workspaceDir, pkg, {
+            symbol: args?.symbol as string | undefined,
+            path: args?.path as string | undefined,
+          }), args?.limit as number | undefined));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'find_typed': {
+        const typeName = args?.type as string;
+        if (!typeName) {
+          throw new ToolError('invalid-argument', 'type is required');
+        }
+        coreLogger.debug('Executing find_typed for %s', typeName);
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          findTypedValues(this.config.workspaceDir, typeName, {
+            path: args?.path as string | undefined,
+            expressions: args?.expressions as boolean | undefined,
+            limit: args?.limit as number | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'go_error_checks': {
+        coreLogger.debug('Executing go_error_checks');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          goErrorChecks(this.config.workspaceDir, {
+            path: args?.path as string | undefined,
+            checks: args?.checks as string[] | undefined,
+            limit: args?.limit as number | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'find_message_origin': {
+        const message = args?.message as string;
+        if (!message) {
+          throw new ToolError('invalid-argument', 'message is required');
+        }
+        coreLogger.debug('Executing find_message_origin');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), async () =>
+          formatMessageOrigins(message, await findMessageOrigins(this.config.workspaceDir, message, {
+            path: args?.path as string | undefined,
+            glob: args?.glob as string[] | undefined,
+            limit: args?.limit as number | undefined,
+          })));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'resolve_stack_trace': {
+        const trace = args?.trace as string;
+        if (!trace) {
+          throw new ToolError('invalid-argument', 'trace is required');
+        }
+        coreLogger.debug('Executing resolve_stack_trace');
+        const result = await this.queryCache.getOrCompute(queryKey(name, args), () =>
+          resolveStackTrace(this.config.workspaceDir, trace, {
+            contextLines: args?.contextLines as number | undefined,
+            includeLibraries: args?.includeLibraries as boolean | undefined,
+          }));
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'symbol_history': {
+        const filePath = this.resolveFilePath(args?.filePath);
+        const symbolName = args?.symbolName as string;
+        if (!filePath || !symbolName) {
+          throw new ToolError('invalid-argument', 'filePath and symbolName are required');
+        }
+        coreLogger.debug('Executing symbol_history for %s in %s', symbolName, filePath);
+        const result = await symbolHistory(this.lspClient, this.config.workspaceDir, filePath, symbolName, {
+          maxCommits: args?.maxCommits as number | undefined,
+          includePatches: args?.includePatches as boolean | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'churn': {
+        coreLogger.debug('Executing churn');
+        const result = await churnReport(this.config.workspaceDir, {
+          path: args?.path as string | undefined,
+          days: args?.days as number | undefined,
+          by: args?.by as 'directory' | 'file' | undefined,
+          depth: args?.depth as number | undefined,
+          limit: args?.limit as number | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'symbol_diff': {
+        const base = args?.base as string;
+        if (!base) {
+          throw new ToolError('invalid-argument', 'base is required');
+        }
+        coreLogger.debug('Executing symbol_diff from %s to %s', base, args?.head ?? 'the work tree');
+        const result = await symbolDiff(this.config.workspaceDir, base, args?.head as string | undefined, {
+          mergeBase: args?.mergeBase as boolean | undefined,
+          path: args?.path as string | undefined,
+          limit: args?.limit as number | undefined,
+        });
+        return { content: [{ type: 'text', text: result }] };
+      }
+
+      case 'search_diff': {
+        const pattern = args?.pattern as string;
+        if (!pattern) {
+          throw new ToolError('invalid-argument', 'pattern is required');
+        }
+        coreLogger.debug('Executing search_diff for pattern: %s', pattern);
+        const result = await searchDiff(this.config.workspaceDir, pattern, {
+          base: args?.base as string | undefined,
+          head: args?.head as string | undefined,
+          mergeBase: args?.mergeBase as boolean | undefined,
+          since: args?.since as string | undefined,
+          save: args?.save as string | undefined,
+          regex: args?.regex as boolean | undefined,
+          caseSensitive: args?.caseSensitive as boolean | undefined,
+          wholeWord: args?.wholeWord as boolean | undefined,
+          path: args?.path as string | undefined,
+          glob: args?.glob as string[] | undefined,
+          limit: args?.limit as number | undefined,
+        }, this.trigramIndex);
+        return { content: [{ type: 'text', text: result }] };
+      }
 
-  // Validate
-  if (!workspaceDir) {
-    throw new Error('workspace directory is required (--workspace <dir>)');
-  }
+      case 'semantic_search': {
+        const query = args?.query as string;
+        if (!query) {
+          throw new ToolError('invalid-argument', 'query is required');
+        }
+        if (!this.semanticEngine) {
+          throw new ToolError('workspace-not-indexed', 'semantic search is disabled (set SEMANTIC_SEARCH_ENABLED=true)');
+        }
+        coreLogger.debug('Executing semantic_search for query: %s', query);
+        const result = await semanticSearch(this.semanticEngine, query, {
+          topK: args?.topK as number | undefined,
+          path: args?.path as string | undefined,
+          mode: args?.mode as SearchMode | undefined,
+        }, this.trigramIndex);
+        return { content: [{ type: 'text', text: result }] };
+      }
 
-  if (!lspCommand) {
-    throw new Error('LSP command is required (--lsp <command>)');
+      default: {
+        const plugin = this.config.plugins?.find((tool) => tool.name === name);
+        if (!plugin) {
+          throw new ToolError('not-found', `Unknown tool: ${name}`);
+        }
+        const pluginDisabled = pluginDisabledReason(plugin, this.config.readOnly);
+        if (pluginDisabled) {
+          throw new ToolError('disabled', `${name} is disabled: ${pluginDisabled}`);
+        }
+        coreLogger.debug('Executing plugin tool %s', name);
+        const text = await runPluginTool(plugin, args ?? {}, this.config.workspaceDir);
+        return { content: [{ type: 'text', text }] };
+      }
+    }
+    } catch (err) {
+      coreLogger.error('Failed to execute tool %s: %s', name, err);
+      throw err;
+    }
   }
 
-  // Get absolute path
-  workspaceDir = path.resolve(workspaceDir);
-
-  if (!fs.existsSync(workspaceDir)) {
-    throw new Error(`workspace directory does not exist: ${workspaceDir}`);
+  /**
+   * Resolve a filePath argument against the workspace, in its on-disk spelling
+   * Returns an empty string when the argument is missing
+   */
+  private resolveFilePath(filePath: unknown): string {
+    return typeof filePath === 'string' && filePath ? resolveWorkspacePath(this.config.workspaceDir, filePath) : '';
   }
 
-  return { workspaceDir, lspCommand, lspArgs };
-}
+  /**
+   * Create a callback that sends partial results as progress notifications,
+   * or undefined when the client did not ask for progress
+   */
+  private progressReporter(progressToken?: string | number): SearchProgressCallback | undefined {
+    if (progressToken === undefined) {
+      return undefined;
+    }
+    return (message, progress, total) => {
+      this.server.notification({
+        method: 'notifications/progress',
+        params: { progressToken, progress, total, message },
+      }).catch((err) => coreLogger.debug('Failed to send progress notification: %s', err));
+    };
+  }
 
-/**
- * Main MCP server class
- */
-class MCPLanguageServer {
-  private server: Server;
-  private lspClient?: LSPClient;
-  private workspaceWatcher?: WorkspaceWatcher;
+  /**
+   * Schemas of tools that are only available when their subsystem is enabled
+   */
+  private optionalTools(): any[] {
+    const tools: any[] = [];
 
-  constructor(private config: Config) {
-    this.server = new Server(
-      {
-        name: 'MCP Language Server',
-        version: '0.0.2',
-      },
-      {
-        capabilities: {
-          tools: {},
+    if (this.semanticEngine) {
+      tools.push({
+        name: 'semantic_search',
+        description: 'Search the codebase with a natural-language query (e.g. "where do we validate email addresses"). Returns the code chunks most similar in meaning, chunked at symbol boundaries.',
+        inputSchema: {
+          type: 'object',
+          properties: {
+            query: {
+              type: 'string',
+              description: 'Natural-language description of the code to find',
+            },
+            topK: {
+              type: 'number',
+              description: 'Maximum number of chunks to return',
+              default: 10,
+            },
+            path: {
+              type: 'string',
+              description: 'Only search files under this path (relative to the workspace or absolute)',
+            },
+            mode: {
+              type: 'string',
+              enum: ['semantic', 'hybrid'],
+              description: 'semantic ranks chunks by meaning alone; hybrid fuses that ranking with a keyword search, so chunks naming the identifiers of the query rank higher',
+              default: 'semantic',
+            },
+          },
+          required: ['query'],
         },
-      }
-    );
+      });
+    }
 
-    this.setupHandlers();
+    return tools;
   }
 
   /**
-   * Setup MCP request handlers
+   * Initialize LSP client and start server
    */
-  private setupHandlers(): void {
-    // List available tools
-    this.server.setRequestHandler(ListToolsRequestSchema, async () => {
-      return {
-        tools: [
-          {
-            name: 'definition',
-            description: 'Read the source code definition of a symbol (function, type, constant, etc.) from the codebase. Returns the complete implementation code where the symbol is defined.',
-            inputSchema: {
-              type: 'object',
-              properties: {
-                symbolName: {
-                  type: 'string',
-                  description: 'The name of the symbol whose definition you want to find (e.g. \'mypackage.MyFunction\', \'MyType.MyMethod\')',
-                },
-              },
-              required: ['symbolName'],
-            },
-          },
-          {
-            name: 'references',
-            description: 'Find all usages and references of a symbol throughout the codebase. Returns a list of all files and locations where the symbol appears.',
-            inputSchema: {
-              type: 'object',
-              properties: {
-                symbolName: {
-                  type: 'string',
-                  description: 'The name of the symbol to search for (e.g. \'mypackage.MyFunction\', \'MyType\')',
-                },
-              },
-              required: ['symbolName'],
-            },
-          },
-          {
-            name: 'diagnostics',
-            description: 'Get diagnostic information for a specific file from the language server.',
-            inputSchema: {
-              type: 'object',
-              properties: {
-                filePath: {
-                  type: 'string',
-                  description: 'The path to the file to get diagnostics for',
-                },
-                contextLines: {
-                  type: 'number',
-                  description: 'Lines to include around each diagnostic.',
-                  default: 5,
-                },
-                showLineNumbers: {
-                  type: 'boolean',
-                  description: 'If true, adds line numbers to the output',
-                  default: true,
-                },
-              },
-              required: ['filePath'],
-            },
-          },
-          {
-            name: 'hover',
-            description: 'Get hover information (type, documentation) for a symbol at the specified position.',
-            inputSchema: {
-              type: 'object',
-              properties: {
-                filePath: {
-                  type: 'string',
-                  description: 'The path to the file to get hover information for',
-                },
-                line: {
-                  type: 'number',
-                  description: 'The line number where the hover is requested (1-indexed)',
-                },
-                column: {
-                  type: 'number',
-                  description: 'The column number where the hover is requested (1-indexed)',
-                },
-              },
-              required: ['filePath', 'line', 'column'],
-            },
-          },
-          {
-            name: 'rename_symbol',
-            description: 'Rename a symbol (variable, function, class, etc.) at the specified position and update all references throughout the codebase.',
-            inputSchema: {
-              type: 'object',
-              properties: {
-                filePath: {
-                  type: 'string',
-                  description: 'The path to the file containing the symbol to rename',
-                },
-                line: {
-                  type: 'number',
-                  description: 'The line number where the symbol is located (1-indexed)',
-                },
-                column: {
-                  type: 'number',
-                  description: 'The column number where the symbol is located (1-indexed)',
-                },
-                newName: {
-                  type: 'string',
-                  description: 'The new name for the symbol',
-                },
-              },
-              required: ['filePath', 'line', 'column', 'newName'],
-            },
-          },
-          {
-            name: 'edit_file',
-            description: 'Apply multiple text edits to a file.',
-            inputSchema: {
-              type: 'object',
-              properties: {
-                filePath: {
-                  type: 'string',
-                  description: 'Path to the file to edit',
-                },
-                edits: {
-                  type: 'array',
-                  description: 'List of edits to apply',
-                  items: {
-                    type: 'object',
-                    properties: {
-                      startLine: {
-                        type: 'number',
-                        description: 'Start line to replace, inclusive, one-indexed',
-                      },
-                      endLine: {
-                        type: 'number',
-                        description: 'End line to replace, inclusive, one-indexed',
-                      },
-                      newText: {
-                        type: 'string',
-                        description: 'Replacement text. Replace with the new text. Leave blank to remove lines.',
-                      },
-                    },
-                    required: ['startLine', 'endLine'],
-                  },
-                },
-              },
-              required: ['filePath', 'edits'],
-            },
-          },
-        ],
-      };
-    });
+  async start(): Promise<void> {
+    coreLogger.info('MCP Language Server starting');
 
-    // Handle tool calls
-    this.server.setRequestHandler(CallToolRequestSchema, async (request) => {
-      if (!this.lspClient) {
-        throw new Error('LSP client not initialized');
-      }
+    // Setup signal handlers
+    this.setupSignalHandlers();
 
-      const { name, arguments: args } = request.params;
+    // Serve metrics and health checks (only when METRICS_PORT is set)
+    const metricsAddress = metricsAddressFromEnv();
+    if (metricsAddress) {
+      this.metricsServer = await startMetricsServer(registry, metricsAddress.port, metricsAddress.host, () => this.health());
+    }
 
-      try {
-        switch (name) {
-          case 'definition': {
-            const symbolName = args?.symbolName as string;
-            if (!symbolName) {
-              throw new Error('symbolName is required');
-            }
-            coreLogger.debug('Executing definition for symbol: %s', symbolName);
-            const result = await readDefinition(this.lspClient, symbolName);
-            return { content: [{ type: 'text', text: result }] };
-          }
+    // Start MCP server before the language server, so ping is answered
+    // while it starts; tool calls wait for initialization
+    const transport = new StdioServerTransport();
+    await this.server.connect(transport);
 
-          case 'references': {
-            const symbolName = args?.symbolName as string;
-            if (!symbolName) {
-              throw new Error('symbolName is required');
-            }
-            coreLogger.debug('Executing references for symbol: %s', symbolName);
-            const result = await findReferences(this.lspClient, symbolName);
-            return { content: [{ type: 'text', text: result }] };
-          }
+    this.initializing = this.initialize();
+    await this.initializing;
+    if (this.semanticEngine) {
+      await this.server.sendToolListChanged();
+    }
 
-          case 'diagnostics': {
-            const filePath = args?.filePath as string;
-            if (!filePath) {
-              throw new Error('filePath is required');
-            }
-            const contextLines = (args?.contextLines as number) ?? 5;
-            const showLineNumbers = (args?.showLineNumbers as boolean) ?? true;
-            coreLogger.debug('Executing diagnostics for file: %s', filePath);
-            const result = await getDiagnosticsForFile(
-              this.lspClient,
-              filePath,
-              contextLines,
-              showLineNumbers
-            );
-            return { content: [{ type: 'text', text: result }] };
-          }
+    // Re-apply the configuration files when they change
+    if (this.config.reload && process.env.CONFIG_WATCH !== 'false') {
+      const { configPath, fileConfig, owned, fixed } = this.config.reload;
+      this.configWatcher = new ConfigWatcher({ configPath, workspaceDir: this.config.workspaceDir }, fileConfig, owned, fixed);
+      this.configWatcher.watch((reload) => void this.applyConfigReload(reload));
+    }
 
-          case 'hover': {
-            const filePath = args?.filePath as string;
-            const line = args?.line as number;
-            const column = args?.column as number;
-            if (!filePath || !line || !column) {
-              throw new Error('filePath, line, and column are required');
-            }
-            coreLogger.debug('Executing hover for file: %s line: %d column: %d', filePath, line, column);
-            const result = await getHoverInfo(this.lspClient, filePath, line, column);
-            return { content: [{ type: 'text', text: result }] };
-          }
+    coreLogger.info('MCP Language Server running');
+  }
 
-          case 'rename_symbol': {
-            const filePath = args?.filePath as string;
-            const line = args?.line as number;
-            const column = args?.column as number;
-            const newName = args?.newName as string;
-            if (!filePath || !line || !column || !newName) {
-              throw new Error('filePath, line, column, and newName are required');
-            }
-            coreLogger.debug('Executing rename_symbol for file: %s line: %d column: %d newName: %s',
-              filePath, line, column, newName);
-            const result = await renameSymbol(this.lspClient, filePath, line, column, newName);
-            return { content: [{ type: 'text', text: result }] };
-          }
+  /**
+   * Report whether startup finished, the language server responds, and the indexes are loaded
+   */
+  async health(): Promise<HealthReport> {
+    const checks: Record<string, HealthCheck> = {};
+    checks.server = this.initialized
+      ? { ok: true, message: 'running' }
+      : { ok: false, message: 'starting' };
 
-          case 'edit_file': {
-            const filePath = args?.filePath as string;
-            const edits = args?.edits as TextEdit[];
-            if (!filePath || !edits) {
-              throw new Error('filePath and edits are required');
-            }
-            coreLogger.debug('Executing edit_file for file: %s', filePath);
-            const result = await applyTextEdits(this.lspClient, filePath, edits);
-            return { content: [{ type: 'text', text: result }] };
-          }
+    if (this.lspSuspended) {
+      const minutes = Math.floor(this.idleTracker.idleFor() / 60_000);
+      checks.lsp = { ok: true, message: `stopped after ${minutes} minute(s) idle; restarts on the next call` };
+    } else if (!this.lspClient) {
+      checks.lsp = { ok: false, message: 'not started' };
+    } else if (!this.lspClient.isRunning()) {
+      checks.lsp = { ok: false, message: 'process exited' };
+    } else {
+      const start = Date.now();
+      checks.lsp = await this.lspClient.ping(HEALTH_PING_TIMEOUT_MS)
+        ? { ok: true, message: `responded in ${Date.now() - start}ms` }
+        : { ok: false, message: `no response within ${HEALTH_PING_TIMEOUT_MS}ms` };
+    }
 
-          default:
-            throw new Error(`Unknown tool: ${name}`);
-        }
-      } catch (err) {
-        coreLogger.error('Failed to execute tool %s: %s', name, err);
-        throw err;
-      }
-    });
+    // The trigram index is built on the first search, so an unbuilt index is not a failure
+    const index = this.trigramIndex.getStats();
+    checks.index = {
+      ok: true,
+      message: this.trigramIndex.isLoaded() ? `${index.files} file(s) indexed` : 'not built yet (built on the first search)',
+    };
+    if (this.semanticEngine) {
+      checks.semantic = { ok: true, message: `${this.semanticEngine.getStats().chunks} chunk(s) indexed` };
+    }
+    return healthReport(checks);
   }
 
   /**
-   * Initialize LSP client and start server
+   * Start the LSP server, watcher, and optional subsystems
    */
-  async start(): Promise<void> {
-    coreLogger.info('MCP Language Server starting');
-
+  async initialize(): Promise<void> {
     // Change to workspace directory
     process.chdir(this.config.workspaceDir);
+    // An imported snapshot spares indexing the files it has unchanged
+    await loadImportedSnapshot(this.config.workspaceDir, this.trigramIndex,
+      [sharedPythonSymbols(this.config.workspaceDir), sharedProtoSymbols(this.config.workspaceDir)]);
+
+    try {
+      await this.startLsp();
+    } catch (err) {
+      // Python workspaces stay usable through the built-in symbol parser
+      if (!isPythonWorkspace(this.config.workspaceDir)) {
+        throw err;
+      }
+      this.lspError = (err as Error).message;
+      coreLogger.warn('Language server %s failed to start (%s); continuing with built-in Python symbols',
+        this.config.lspCommand, this.lspError);
+      await this.lspClient?.close().catch(() => undefined);
+      this.lspClient = undefined;
+    }
+    this.addRemotes(this.config.remotes ?? []);
+    if (process.env.CODE_INDEX_PATH) {
+      try {
+        this.codeIndex = await loadCodeIndex(this.config.workspaceDir, process.env.CODE_INDEX_PATH,
+          { commit: process.env.CODE_INDEX_COMMIT || undefined });
+      } catch (err) {
+        coreLogger.warn('Ignoring code index %s: %s', process.env.CODE_INDEX_PATH, (err as Error).message);
+      }
+    }
+
+    // Stop the language server once it goes unused for LSP_IDLE_TIMEOUT_MINUTES
+    this.idleTimer = setInterval(() => {
+      if (this.lspClient && !this.lspSuspended && this.idleTracker.expired()) {
+        void this.suspendLsp();
+      }
+    }, IDLE_CHECK_INTERVAL_MS);
+    this.idleTimer.unref();
+
+    // Set up semantic search (disabled by default)
+    if (process.env.SEMANTIC_SEARCH_ENABLED === 'true') {
+      const provider = createEmbeddingProvider(embeddingConfigFromEnv());
+      this.semanticEngine = new SemanticSearchEngine(
+        this.config.workspaceDir,
+        provider,
+        {
+          // Looked up on each call, since a configuration change can restart the language server
+          symbolProvider: (filePath) => getFileSymbols(this.lspClient, this.config.workspaceDir, filePath),
+          chunk: { maxLines: parseInt(process.env.SEMANTIC_CHUNK_MAX_LINES || '60', 10) },
+          storePath: process.env.SEMANTIC_INDEX_PERSIST === 'false'
+            ? undefined
+            : process.env.SEMANTIC_INDEX_PATH || defaultStorePath(this.config.workspaceDir),
+        }
+      );
+      coreLogger.info('Semantic search enabled (embeddings: %s)', provider.id);
+    }
+    this.initialized = true;
+  }
+
+  /**
+   * Clone configured remotes in the background; searches of a remote wait for its clone
+   */
+  private addRemotes(specs: string[]): void {
+    for (const spec of specs) {
+      const { url, ref } = parseRemoteSpec(spec);
+      try {
+        // Failures are logged by the registry once the fetch settles
+        this.remotes.add(url, { ref }).catch(() => undefined);
+      } catch (err) {
+        coreLogger.error('Ignoring remote %s: %s', spec, (err as Error).message);
+      }
+    }
+  }
 
+  /**
+   * Start the LSP server and the workspace watcher, and warm up the cache
+   */
+  private async startLsp(): Promise<void> {
     // Parse cache configuration from environment
     const cacheConfig = {
       enabled: process.env.CACHE_ENABLED !== 'false', // Enabled by default
@@ -372,33 +4089,223 @@ class MCPLanguageServer {
         : undefined,
     };
 
+    // Servers such as jdtls and rust-analyzer depend on where they start and what is on PATH
+    const launch = this.config.lspLaunch;
+    const cwd = launch.cwd ? path.resolve(this.config.workspaceDir, launch.cwd) : undefined;
+    if (cwd && !fs.existsSync(cwd)) {
+      throw new Error(`lsp.cwd does not exist: ${cwd}`);
+    }
+    const searchPath = launch.path?.map((dir) => path.resolve(this.config.workspaceDir, dir));
+
     // Create LSP client with cache config
-    this.lspClient = new LSPClient(this.config.lspCommand, this.config.lspArgs, cacheConfig);
+    this.lspClient = new LSPClient(this.config.lspCommand, this.config.lspArgs, cacheConfig, { cwd, path: searchPath, env: launch.env });
 
     // Initialize LSP
     const initResult = await this.lspClient.initialize(this.config.workspaceDir);
     coreLogger.debug('Server capabilities: %j', initResult.capabilities);
 
-    // Create workspace watcher
-    this.workspaceWatcher = new WorkspaceWatcher(this.lspClient);
+    if (this.workspaceWatcher) {
+      // The watcher kept running while the server was stopped for being idle
+      this.workspaceWatcher.attach(this.lspClient);
+    } else {
+      // Create workspace watcher
+      this.workspaceWatcher = new WorkspaceWatcher(this.lspClient);
 
-    // Start watching workspace
-    await this.workspaceWatcher.watchWorkspace(this.config.workspaceDir);
+      // Start watching workspace
+      await this.workspaceWatcher.watchWorkspace(this.config.workspaceDir);
+      this.workspaceWatcher.onFileEvent((filePath) => {
+        this.queryCache.invalidate();
+        sharedPythonSymbols(this.config.workspaceDir).filesChanged();
+        sharedProtoSymbols(this.config.workspaceDir).filesChanged();
+        for (const watches of this.watches.values()) {
+          watches.fileChanged(filePath);
+        }
+        if (PROJECT_MANIFESTS.has(path.basename(filePath))) {
+          this.projects = undefined;
+        }
+      });
+    }
 
     // Wait for server to be ready
     await this.lspClient.waitForServerReady();
 
+    // A restarted server sees the overlay again
+    for (const filePath of sharedOverlay().paths()) {
+      await this.lspClient.openFile(filePath);
+    }
+
+    this.lspError = undefined;
+
     // Warm up cache with workspace symbols (if enabled)
     await this.warmupCache();
+    this.startBackgroundWarmup(this.lspClient);
+  }
 
-    // Setup signal handlers
-    this.setupSignalHandlers();
+  /**
+   * Analyze the most imported files in the background, LSP_WARMUP_CONCURRENCY at a time
+   * Calls are served meanwhile; stopping the language server ends the warm-up
+   */
+  private startBackgroundWarmup(client: LSPClient): void {
+    const { files, concurrency } = warmupSettingsFromEnv();
+    this.backgroundWarmup?.abort();
+    this.backgroundWarmup = undefined;
+    if (files === 0) {
+      return;
+    }
+    const controller = new AbortController();
+    this.backgroundWarmup = controller;
+    const start = Date.now();
+    coreLogger.info('Background warm-up: analyzing up to %d file(s), %d at a time', files, concurrency);
+    // A start from within a call, after an idle stop, must not charge the warm-up to it
+    unbudgeted(() => unpinned(() => withCancellation(controller.signal, () =>
+      openCentralFiles(this.config.workspaceDir, client, files, concurrency))))
+      .then((opened) => coreLogger.info('Background warm-up: analyzed %d file(s) in %dms', opened.length, Date.now() - start))
+      .catch((err) => coreLogger.debug('Background warm-up stopped: %s', (err as Error).message))
+      .finally(() => {
+        if (this.backgroundWarmup === controller) {
+          this.backgroundWarmup = undefined;
+        }
+      });
+  }
 
-    // Start MCP server
-    const transport = new StdioServerTransport();
-    await this.server.connect(transport);
+  /**
+   * Apply reloaded configuration files
+   * Settings read on each use already changed with the environment; the rest
+   * are re-applied here, restarting the language server when its command or
+   * cache settings changed
+   */
+  private async applyConfigReload(reload: ConfigReload): Promise<void> {
+    const summary = describeChanges(reload.changes);
+    coreLogger.info('Configuration reloaded: %s', summary);
+    this.server.sendLoggingMessage({ level: 'info', logger: 'grep-for-code', data: `Configuration reloaded: ${summary}` })
+      .catch((err) => coreLogger.debug('Could not send the reload notification: %s', (err as Error).message));
 
-    coreLogger.info('MCP Language Server running');
+    const keys = reload.changes.map((change) => change.key);
+    if (keys.some((key) => key.startsWith('logging.'))) {
+      reloadLoggingFromEnv();
+    }
+    if (keys.some((key) => key.startsWith('exclude.'))) {
+      this.workspaceWatcher?.reloadExclusions();
+      sharedPythonSymbols(this.config.workspaceDir).filesChanged();
+      sharedProtoSymbols(this.config.workspaceDir).filesChanged();
+    }
+    if (keys.includes('search.glob')) {
+      this.config.globs = reload.config?.globs;
+    }
+    if (keys.includes('remotes')) {
+      this.addRemotes(reload.config?.remotes ?? []);
+    }
+    if (keys.includes('links')) {
+      this.config.links = reload.config?.links;
+    }
+    if (keys.includes('templates')) {
+      this.config.templates = reload.config?.templates;
+    }
+    if (keys.includes('matchers')) {
+      this.config.matchers = reload.config?.matchers;
+    }
+    if (keys.includes('plugins')) {
+      this.config.plugins = reload.config?.plugins;
+      this.server.sendToolListChanged()
+        .catch((err) => coreLogger.debug('Could not send the tool list change: %s', (err as Error).message));
+    }
+    // Cached results may depend on the old exclusions and limits
+    this.queryCache.invalidate();
+
+    const restart = keys.filter((key) => applyMode(key) === 'restart');
+    if (restart.length > 0) {
+      coreLogger.warn('Restart the server to apply: %s', restart.join(', '));
+    }
+    if (!keys.some((key) => applyMode(key) === 'lsp')) {
+      return;
+    }
+    if (keys.includes('lsp.command')) {
+      if (!reload.config?.lspCommand) {
+        coreLogger.warn('lsp.command was removed; keeping %s', this.config.lspCommand);
+        return;
+      }
+      this.config.lspCommand = reload.config.lspCommand;
+      this.config.lspArgs = reload.config.lspArgs ?? [];
+    }
+    const launch = launchSettingsFor(reload.config, this.config.workspaceDir);
+    if (keys.includes('lsp.cwd')) {
+      this.config.lspLaunch.cwd = launch.cwd;
+    }
+    if (keys.includes('lsp.path')) {
+      this.config.lspLaunch.path = launch.path;
+    }
+    if (keys.includes('lsp.env')) {
+      this.config.lspLaunch.env = launch.env;
+    }
+    if (this.lspSuspended) {
+      // The next call that needs the server starts it with the new settings
+      return;
+    }
+    coreLogger.info('Restarting the language server: %s %s', this.config.lspCommand, this.config.lspArgs.join(' '));
+    // Tool calls wait for the new language server
+    this.initializing = (this.initializing ?? Promise.resolve())
+      .catch(() => undefined)
+      .then(() => this.stopLsp())
+      .then(() => this.startLsp());
+    try {
+      await this.initializing;
+      lspRestarts.inc();
+    } catch (err) {
+      coreLogger.error('Language server restart failed: %s', (err as Error).message);
+    }
+  }
+
+  /**
+   * Stop the language server for being idle, keeping the workspace watcher
+   * running so cached queries still see file changes
+   */
+  private async suspendLsp(): Promise<void> {
+    const idleMinutes = Math.floor(this.idleTracker.idleFor() / 60_000);
+    // Tool calls arriving meanwhile wait, then start the server again
+    this.initializing = (this.initializing ?? Promise.resolve())
+      .catch(() => undefined)
+      .then(async () => {
+        // A call may have started while an earlier restart was pending
+        if (!this.lspClient || !this.idleTracker.expired()) {
+          return;
+        }
+        this.workspaceWatcher?.detach();
+        await this.stopLspClient();
+        this.lspClient = undefined;
+        this.lspSuspended = true;
+        coreLogger.info('Stopped the language server after %d minute(s) idle', idleMinutes);
+      });
+    try {
+      await this.initializing;
+    } catch (err) {
+      coreLogger.error('Language server idle shutdown failed: %s', (err as Error).message);
+    }
+  }
+
+  /**
+   * Start the language server stopped for being idle, and warm it up again
+   */
+  private async resumeLsp(): Promise<void> {
+    this.initializing = (this.initializing ?? Promise.resolve())
+      .catch(() => undefined)
+      .then(async () => {
+        if (!this.lspSuspended) {
+          return;
+        }
+        coreLogger.info('Starting the language server again: %s %s', this.config.lspCommand, this.config.lspArgs.join(' '));
+        this.lspSuspended = false;
+        try {
+          await this.startLsp();
+          lspRestarts.inc();
+        } catch (err) {
+          // Calls get the reason, as after a failed startup
+          this.lspError = (err as Error).message;
+          coreLogger.error('Language server restart failed: %s', this.lspError);
+          await this.lspClient?.close().catch(() => undefined);
+          this.lspClient = undefined;
+        }
+      });
+    await this.initializing;
   }
 
   /**
@@ -518,36 +4425,87 @@ class MCPLanguageServer {
    * Setup signal handlers for graceful shutdown
    */
   private setupSignalHandlers(): void {
+    let stopping = false;
     const cleanup = async () => {
-      coreLogger.info('Cleanup initiated');
+      // A second signal skips the drain
+      if (stopping) {
+        process.exit(1);
+      }
+      stopping = true;
+      await this.shutdown();
+      process.exit(0);
+    };
 
-      // Close all files
-      if (this.lspClient) {
-        coreLogger.info('Closing open files');
-        await this.lspClient.closeAllFiles();
+    process.on('SIGINT', cleanup);
+    process.on('SIGTERM', cleanup);
+  }
 
-        // Send shutdown and exit
-        coreLogger.info('Sending shutdown request');
-        await this.lspClient.shutdown();
+  /**
+   * Refuse new tool calls and wait for running ones, then save the semantic
+   * index, stop the LSP server, and stop watching
+   */
+  async shutdown(): Promise<void> {
+    coreLogger.info('Cleanup initiated');
 
-        coreLogger.info('Sending exit notification');
-        await this.lspClient.exit();
+    const timeoutMs = shutdownTimeoutFromEnv();
+    if (this.drain.size() > 0) {
+      coreLogger.info('Waiting up to %dms for %d running tool call(s)', timeoutMs, this.drain.size());
+    }
+    const running = await this.drain.close(timeoutMs);
+    if (running > 0) {
+      coreLogger.warn('%d tool call(s) still running after %dms; cancelled them', running, timeoutMs);
+    }
 
-        coreLogger.info('Closing LSP client');
-        await this.lspClient.close();
-      }
+    this.configWatcher?.close();
+    clearInterval(this.idleTimer);
+    for (const watches of this.watches.values()) {
+      watches.dispose();
+    }
+    clearMatchers();
+    await this.semanticEngine?.close();
+    await this.stopLsp();
 
-      // Stop watcher
-      if (this.workspaceWatcher) {
-        await this.workspaceWatcher.stop();
-      }
+    // Stop serving metrics
+    if (this.metricsServer) {
+      await new Promise((resolve) => this.metricsServer!.close(resolve));
+    }
 
-      coreLogger.info('Cleanup completed');
-      process.exit(0);
-    };
+    coreLogger.info('Cleanup completed');
+  }
 
-    process.on('SIGINT', cleanup);
-    process.on('SIGTERM', cleanup);
+  /**
+   * Close files, stop the LSP server, and stop watching
+   */
+  private async stopLsp(): Promise<void> {
+    await this.stopLspClient();
+
+    // Stop watcher
+    if (this.workspaceWatcher) {
+      await this.workspaceWatcher.stop();
+      this.workspaceWatcher = undefined;
+    }
+  }
+
+  /**
+   * Close files and stop the LSP server
+   */
+  private async stopLspClient(): Promise<void> {
+    this.backgroundWarmup?.abort();
+    // Close all files
+    if (this.lspClient) {
+      coreLogger.info('Closing open files');
+      await this.lspClient.closeAllFiles();
+
+      // Send shutdown and exit
+      coreLogger.info('Sending shutdown request');
+      await this.lspClient.shutdown();
+
+      coreLogger.info('Sending exit notification');
+      await this.lspClient.exit();
+
+      coreLogger.info('Closing LSP client');
+      await this.lspClient.close();
+    }
   }
 }
 
@@ -557,7 +4515,60 @@ class MCPLanguageServer {
 async function main() {
   try {
     const config = parseConfig();
+    if (config.bench) {
+      const report = await runBenchmark(config.workspaceDir, { iterations: config.bench.iterations });
+      process.stdout.write(config.bench.json ? JSON.stringify(report, null, 2) + '\n' : formatBenchReport(report));
+      return;
+    }
+    if (config.search) {
+      const output = await runSearchCommand(config.workspaceDir, config.search, { glob: config.globs });
+      process.stdout.write(output.stdout);
+      process.stderr.write(output.stderr);
+      process.exitCode = output.exitCode;
+      return;
+    }
+    if (config.index) {
+      const output = await runIndexCommand(config.workspaceDir, config.index);
+      process.stdout.write(output.stdout);
+      process.stderr.write(output.stderr);
+      process.exitCode = output.exitCode;
+      return;
+    }
+    if (config.serve && !config.serve.ui) {
+      // Runs until the process is stopped
+      await startSearchServer(config.workspaceDir, config.serve, new TrigramIndex(config.workspaceDir));
+      return;
+    }
     const server = new MCPLanguageServer(config);
+    if (config.serve) {
+      // The UI calls the tools as an MCP client would
+      await server.initialize();
+      await startSearchServer(config.workspaceDir, config.serve, new TrigramIndex(config.workspaceDir),
+        (name, args) => server.callTool(name, args));
+      // Stop the language server with the process
+      for (const signal of ['SIGINT', 'SIGTERM'] as const) {
+        process.once(signal, () => void server.shutdown().finally(() => process.exit(0)));
+      }
+      return;
+    }
+    if (config.warmup) {
+      await server.initialize();
+      const result = await server.callTool('warmup', {});
+      const failed = resultError(result);
+      if (failed) {
+        process.stderr.write(`warmup failed (${failed.code}): ${failed.message}\n`);
+      } else {
+        process.stdout.write(result.content.map((part) => part.text).join('\n'));
+      }
+      await server.shutdown();
+      process.exit(failed ? 1 : 0);
+    }
+    if (config.repl) {
+      await server.initialize();
+      await runRepl(server);
+      await server.shutdown();
+      process.exit(0);
+    }
     await server.start();
   } catch (err) {
     coreLogger.fatal('%s', err);
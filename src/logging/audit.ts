@@ -0,0 +1,120 @@
+/**
+ * Audit log of tool calls
+ * One JSON object per line for every tool call: when, which session, the
+ * arguments, how it ended, and how long it took. The file is only appended
+ * to, never rotated or truncated, so it can serve as a compliance record and
+ * as input for replaying an agent session
+ */
+
+import * as fs from 'fs';
+import { redactSecrets, redactionEnabled } from '../search/secrets.js';
+
+/**
+ * A recorded tool call
+ */
+export interface AuditEntry {
+  time: string; // ISO 8601, when the call started
+  requestId: string;
+  session: string;
+  tool: string;
+  args: Record<string, unknown>;
+  status: 'ok' | 'error' | 'throttled';
+  durationMs: number;
+  // Characters of result text, for calls that returned one
+  resultChars?: number;
+  error?: string;
+}
+
+/**
+ * Replace likely secrets in every string of an argument value
+ */
+function redactValue(value: unknown): unknown {
+  if (typeof value === 'string') {
+    return redactSecrets(value).text;
+  }
+  if (Array.isArray(value)) {
+    return value.map(redactValue);
+  }
+  if (value && typeof value === 'object') {
+    return Object.fromEntries(Object.entries(value).map(([key, item]) => [key, redactValue(item)]));
+  }
+  return value;
+}
+
+/**
+ * Append-only JSONL file of tool calls
+ * Writes are synchronous so entries stay in order and survive a crash
+ */
+export class AuditLog {
+  private fd: number;
+
+  constructor(readonly filePath: string) {
+    this.fd = fs.openSync(filePath, 'a', 0o600);
+    // A write cut short by a crash must not swallow the next entry
+    const { size } = fs.fstatSync(this.fd);
+    if (size > 0) {
+      const last = Buffer.alloc(1);
+      const reader = fs.openSync(filePath, 'r');
+      try {
+        fs.readSync(reader, last, 0, 1, size - 1);
+      } finally {
+        fs.closeSync(reader);
+      }
+      if (last[0] !== 0x0a) {
+        fs.writeSync(this.fd, '\n');
+      }
+    }
+  }
+
+  /**
+   * Append an entry; arguments are redacted like tool results unless REDACT_SECRETS is false
+   */
+  record(entry: AuditEntry): void {
+    const args = redactionEnabled() ? redactValue(entry.args) : entry.args;
+    fs.writeSync(this.fd, JSON.stringify({ ...entry, args }) + '\n');
+  }
+
+  close(): void {
+    fs.closeSync(this.fd);
+  }
+}
+
+/**
+ * Read the entries of an audit log, for replaying a session
+ * Lines that are not complete JSON objects (a write cut short) are skipped
+ */
+export function readAuditLog(filePath: string, session?: string): AuditEntry[] {
+  const entries: AuditEntry[] = [];
+  for (const line of fs.readFileSync(filePath, 'utf-8').split('\n')) {
+    if (!line.trim()) {
+      continue;
+    }
+    try {
+      const entry = JSON.parse(line) as AuditEntry;
+      if (!session || entry.session === session) {
+        entries.push(entry);
+      }
+    } catch (err) {
+      // Incomplete last line
+    }
+  }
+  return entries;
+}
+
+let shared: AuditLog | undefined;
+
+/**
+ * Audit log at AUDIT_LOG_FILE, or undefined when it is unset
+ * The file is reopened when the setting changes, e.g. on a configuration reload
+ */
+export function sharedAuditLog(env: NodeJS.ProcessEnv = process.env): AuditLog | undefined {
+  const filePath = env.AUDIT_LOG_FILE;
+  if (shared && shared.filePath !== filePath) {
+    shared.close();
+    shared = undefined;
+  }
+  if (filePath && !shared) {
+    shared = new AuditLog(filePath);
+  }
+  return shared;
+}
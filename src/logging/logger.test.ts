@@ -2,6 +2,9 @@
  * Tests for logging module
  */
 
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
 import { Writable } from 'stream';
 import {
   createLogger,
@@ -9,9 +12,12 @@ import {
   LogLevel,
   setLevel,
   setGlobalLevel,
+  setFormat,
   setupTestLogging,
   resetTestLogging,
+  withLogContext,
 } from './logger';
+import { RotatingFileStream } from './rotate';
 
 describe('Logger', () => {
   let capturedOutput: string[] = [];
@@ -107,5 +113,73 @@ describe('Logger', () => {
       expect(logger.isLevelEnabled(LogLevel.FATAL)).toBe(true);
     });
   });
+
+  describe('Structured logging', () => {
+    afterEach(() => {
+      setFormat('text');
+      setGlobalLevel(LogLevel.INFO);
+    });
+
+    it('should append fields and request context to text entries', async () => {
+      setGlobalLevel(LogLevel.INFO);
+      const logger = createLogger(Component.CORE);
+
+      await withLogContext({ requestId: 'r1' }, async () => {
+        await Promise.resolve();
+        logger.info('Inside');
+        logger.event(LogLevel.INFO, 'Tool call completed', { tool: 'search_code', summary: 'Found 2 match(es)' });
+      });
+      logger.info('Outside');
+
+      expect(capturedOutput[0]).toContain('[INFO][core] Inside requestId=r1');
+      expect(capturedOutput[1]).toContain('Tool call completed requestId=r1 tool=search_code summary="Found 2 match(es)"');
+      expect(capturedOutput[2]).not.toContain('requestId');
+    });
+
+    it('should write one JSON object per entry', () => {
+      setGlobalLevel(LogLevel.INFO);
+      setFormat('json');
+      const logger = createLogger(Component.TOOLS);
+
+      withLogContext({ requestId: 'r2' }, () => {
+        logger.event(LogLevel.WARN, 'Slow query', { durationMs: 1500, error: new Error('timed out') });
+      });
+
+      expect(JSON.parse(capturedOutput[0])).toMatchObject({
+        level: 'WARN',
+        component: 'tools',
+        msg: 'Slow query',
+        requestId: 'r2',
+        durationMs: 1500,
+        error: 'timed out',
+      });
+    });
+  });
+
+  describe('File rotation', () => {
+    let dir: string;
+
+    beforeEach(() => {
+      dir = fs.mkdtempSync(path.join(os.tmpdir(), 'logs-'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(dir, { recursive: true, force: true });
+    });
+
+    it('should rotate by size and keep a bounded number of files', async () => {
+      const file = path.join(dir, 'server.log');
+      const stream = new RotatingFileStream(file, { maxBytes: 10, maxFiles: 2 });
+      for (const entry of ['aaaaaa\n', 'bbbbbb\n', 'cccccc\n', 'dddddd\n']) {
+        stream.write(entry);
+      }
+      await new Promise<void>((resolve) => stream.end(resolve));
+
+      expect(fs.readFileSync(file, 'utf8')).toBe('dddddd\n');
+      expect(fs.readFileSync(`${file}.1`, 'utf8')).toBe('cccccc\n');
+      expect(fs.readFileSync(`${file}.2`, 'utf8')).toBe('bbbbbb\n');
+      expect(fs.existsSync(`${file}.3`)).toBe(false);
+    });
+  });
 });
 
@@ -1,9 +1,11 @@
 /**
  * Logging module for MCP Language Server
- * Provides component-based logging with configurable log levels
+ * Provides component-based logging with configurable log levels, plain text
+ * or JSON lines output, log file rotation, and per-request context fields
  */
 
-import * as fs from 'fs';
+import { AsyncLocalStorage } from 'async_hooks';
+import { RotatingFileStream, rotationFromEnv } from './rotate.js';
 
 /**
  * Log levels in order of severity
@@ -26,8 +28,19 @@ export enum Component {
   LSP_PROCESS = 'lsp-process',
   WATCHER = 'watcher',
   TOOLS = 'tools',
+  SEMANTIC = 'semantic',
 }
 
+/**
+ * Output format: plain text lines or one JSON object per line
+ */
+export type LogFormat = 'text' | 'json';
+
+/**
+ * Structured fields attached to a log entry
+ */
+export type LogFields = Record<string, unknown>;
+
 /**
  * Logger interface
  */
@@ -37,6 +50,8 @@ export interface Logger {
   warn(format: string, ...args: any[]): void;
   error(format: string, ...args: any[]): void;
   fatal(format: string, ...args: any[]): void;
+  // Log a message with structured fields
+  event(level: LogLevel, message: string, fields: LogFields): void;
   isLevelEnabled(level: LogLevel): boolean;
 }
 
@@ -48,13 +63,21 @@ class LoggingConfig {
   componentLevels: Map<Component, LogLevel> = new Map();
   writer: NodeJS.WritableStream = process.stderr;
   testOutput?: NodeJS.WritableStream;
+  format: LogFormat = 'text';
+  private logFile?: string;
 
   constructor() {
     // Initialize component levels
     Object.values(Component).forEach((comp) => {
       this.componentLevels.set(comp, this.defaultMinLevel);
     });
+    this.loadEnv();
+  }
 
+  /**
+   * Apply LOG_LEVEL, LOG_COMPONENT_LEVELS, LOG_FORMAT, and LOG_FILE
+   */
+  loadEnv(): void {
     // Parse log level from environment
     const logLevel = process.env.LOG_LEVEL?.toUpperCase();
     if (logLevel) {
@@ -83,12 +106,17 @@ class LoggingConfig {
       });
     }
 
-    // Use custom log file if specified
+    if (process.env.LOG_FORMAT) {
+      this.format = process.env.LOG_FORMAT.toLowerCase() === 'json' ? 'json' : 'text';
+    }
+
+    // Use custom log file if specified, rotated by LOG_FILE_MAX_SIZE_MB and LOG_FILE_MAX_FILES
     const logFile = process.env.LOG_FILE;
-    if (logFile) {
+    if (logFile && logFile !== this.logFile) {
       try {
-        const fileStream = fs.createWriteStream(logFile, { flags: 'a' });
+        const fileStream = new RotatingFileStream(logFile, rotationFromEnv());
         this.writer = fileStream;
+        this.logFile = logFile;
       } catch (err) {
         console.error(`Failed to open log file ${logFile}:`, err);
       }
@@ -115,6 +143,45 @@ class LoggingConfig {
 
 const config = new LoggingConfig();
 
+/**
+ * Fields of the request being handled, attached to every entry logged while handling it
+ */
+const logContext = new AsyncLocalStorage<LogFields>();
+
+/**
+ * Run a function with fields added to every entry it logs, including from async work it starts
+ */
+export function withLogContext<T>(fields: LogFields, fn: () => T): T {
+  return logContext.run({ ...logContext.getStore(), ...fields }, fn);
+}
+
+/**
+ * Render a field value for text output, quoting values with spaces
+ */
+function textValue(value: unknown): string {
+  const text = value instanceof Error ? value.message : typeof value === 'string' ? value : JSON.stringify(value);
+  return /[\s"=]/.test(text) ? JSON.stringify(text) : text;
+}
+
+/**
+ * Format a log entry as a line of text or JSON
+ */
+function formatEntry(level: LogLevel, component: Component, message: string, fields: LogFields): string {
+  const time = new Date().toISOString();
+  if (config.format === 'json') {
+    const entry: LogFields = { time, level: getLevelName(level), component, msg: message };
+    for (const [key, value] of Object.entries(fields)) {
+      entry[key] = value instanceof Error ? value.message : value;
+    }
+    return JSON.stringify(entry) + '\n';
+  }
+  const suffix = Object.entries(fields)
+    .filter(([, value]) => value !== undefined)
+    .map(([key, value]) => ` ${key}=${textValue(value)}`)
+    .join('');
+  return `${time} [${getLevelName(level)}][${component}] ${message}${suffix}\n`;
+}
+
 /**
  * Format a log message
  */
@@ -176,10 +243,11 @@ class ComponentLogger implements Logger {
     if (!this.isLevelEnabled(level)) {
       return;
     }
+    this.write(level, formatMessage(format, ...args), {});
+  }
 
-    const message = formatMessage(format, ...args);
-    const timestamp = new Date().toISOString();
-    const logMessage = `${timestamp} [${getLevelName(level)}][${this.component}] ${message}\n`;
+  private write(level: LogLevel, message: string, fields: LogFields): void {
+    const logMessage = formatEntry(level, this.component, message, { ...logContext.getStore(), ...fields });
 
     try {
       config.writer.write(logMessage);
@@ -213,6 +281,12 @@ class ComponentLogger implements Logger {
     this.log(LogLevel.FATAL, format, ...args);
     process.exit(1);
   }
+
+  event(level: LogLevel, message: string, fields: LogFields): void {
+    if (this.isLevelEnabled(level)) {
+      this.write(level, message, fields);
+    }
+  }
 }
 
 /**
@@ -239,6 +313,21 @@ export function setGlobalLevel(level: LogLevel): void {
   });
 }
 
+/**
+ * Re-read the logging environment variables
+ * Used after settings from a configuration file are applied
+ */
+export function reloadLoggingFromEnv(): void {
+  config.loadEnv();
+}
+
+/**
+ * Set the output format
+ */
+export function setFormat(format: LogFormat): void {
+  config.format = format;
+}
+
 /**
  * Set the writer for log output
  */
@@ -251,7 +340,7 @@ export function setWriter(writer: NodeJS.WritableStream): void {
  */
 export function setupFileLogging(filePath: string): void {
   try {
-    const fileStream = fs.createWriteStream(filePath, { flags: 'a' });
+    const fileStream = new RotatingFileStream(filePath, rotationFromEnv());
     config.writer = fileStream;
   } catch (err) {
     throw new Error(`Failed to open log file: ${err}`);
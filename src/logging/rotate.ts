@@ -0,0 +1,90 @@
+/**
+ * Size-based log file rotation
+ * When the file would grow past its size limit it is renamed to file.1,
+ * older files shift up (file.1 to file.2, ...), and the oldest is removed.
+ * Writes are synchronous so entries stay in order and survive a crash
+ */
+
+import * as fs from 'fs';
+import { Writable } from 'stream';
+
+/**
+ * Rotation options
+ */
+export interface RotationOptions {
+  // Rotate before the file exceeds this many bytes (0: never rotate)
+  maxBytes: number;
+  // Rotated files kept beside the current one
+  maxFiles: number;
+}
+
+/**
+ * Rotation settings from LOG_FILE_MAX_SIZE_MB (default: 10) and LOG_FILE_MAX_FILES (default: 5)
+ */
+export function rotationFromEnv(env: NodeJS.ProcessEnv = process.env): RotationOptions {
+  const sizeMb = env.LOG_FILE_MAX_SIZE_MB ? parseFloat(env.LOG_FILE_MAX_SIZE_MB) : 10;
+  const maxFiles = env.LOG_FILE_MAX_FILES ? parseInt(env.LOG_FILE_MAX_FILES, 10) : 5;
+  return {
+    maxBytes: sizeMb > 0 ? Math.floor(sizeMb * 1024 * 1024) : 0,
+    maxFiles: maxFiles >= 0 ? maxFiles : 5,
+  };
+}
+
+/**
+ * Append-only log file that rotates by size
+ */
+export class RotatingFileStream extends Writable {
+  private fd: number;
+  private size: number;
+
+  constructor(private filePath: string, private options: RotationOptions) {
+    super();
+    this.fd = fs.openSync(filePath, 'a');
+    this.size = fs.fstatSync(this.fd).size;
+  }
+
+  _write(chunk: Buffer | string, encoding: BufferEncoding, callback: (err?: Error | null) => void): void {
+    try {
+      const data = typeof chunk === 'string' ? Buffer.from(chunk, encoding) : chunk;
+      // A single entry larger than the limit still gets a file of its own
+      if (this.options.maxBytes > 0 && this.size > 0 && this.size + data.length > this.options.maxBytes) {
+        this.rotate();
+      }
+      fs.writeSync(this.fd, data);
+      this.size += data.length;
+      callback();
+    } catch (err) {
+      callback(err as Error);
+    }
+  }
+
+  _final(callback: (err?: Error | null) => void): void {
+    try {
+      fs.closeSync(this.fd);
+      callback();
+    } catch (err) {
+      callback(err as Error);
+    }
+  }
+
+  /**
+   * Shift rotated files up by one and start a new file
+   */
+  private rotate(): void {
+    fs.closeSync(this.fd);
+    const { maxFiles } = this.options;
+    if (maxFiles === 0) {
+      fs.rmSync(this.filePath, { force: true });
+    } else {
+      fs.rmSync(`${this.filePath}.${maxFiles}`, { force: true });
+      for (let i = maxFiles - 1; i >= 1; i--) {
+        if (fs.existsSync(`${this.filePath}.${i}`)) {
+          fs.renameSync(`${this.filePath}.${i}`, `${this.filePath}.${i + 1}`);
+        }
+      }
+      fs.renameSync(this.filePath, `${this.filePath}.1`);
+    }
+    this.fd = fs.openSync(this.filePath, 'a');
+    this.size = 0;
+  }
+}
@@ -0,0 +1,154 @@
+/**
+ * Tests for position encoding negotiation against a scripted language server
+ * The server counts columns in the encoding it negotiated and rejects
+ * positions that are not in it, so no real language server is needed
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { LSPClient, launchEnvironment } from './client';
+import { definition } from './methods';
+import { pathToUri } from '../protocol/uri';
+
+/**
+ * A language server speaking one position encoding (argv[2]); argv[3] is
+ * "offsetEncoding" to answer the way clangd did before LSP 3.17
+ */
+const SERVER = `
+const encoding = process.argv[2];
+const legacy = process.argv[3] === 'offsetEncoding';
+const units = (text) => encoding === 'utf-8' ? Buffer.byteLength(text) : encoding === 'utf-32' ? Array.from(text).length : text.length;
+const columnOf = (line, token) => units(line.substring(0, line.indexOf(token)));
+const documents = new Map();
+const send = (message) => {
+  const body = JSON.stringify({ jsonrpc: '2.0', ...message });
+  process.stdout.write('Content-Length: ' + Buffer.byteLength(body) + '\\r\\n\\r\\n' + body);
+};
+const handle = (message) => {
+  const { id, method, params } = message;
+  if (method === 'initialize') {
+    const offered = legacy ? params.capabilities.offsetEncoding : params.capabilities.general.positionEncodings;
+    if (encoding !== 'utf-16' && !offered.includes(legacy ? encoding.replace('-', '') : encoding)) {
+      send({ id, error: { code: -32602, message: 'client does not offer ' + encoding } });
+      return;
+    }
+    const capabilities = { definitionProvider: true };
+    if (!legacy && encoding !== 'utf-16') {
+      capabilities.positionEncoding = encoding;
+    }
+    send({ id, result: { capabilities, ...(legacy ? { offsetEncoding: encoding.replace('-', '') } : {}) } });
+  } else if (method === 'textDocument/didOpen') {
+    const { uri, text } = params.textDocument;
+    documents.set(uri, text.split('\\n'));
+    const start = columnOf(documents.get(uri)[1], 'target');
+    send({ method: 'textDocument/publishDiagnostics', params: { uri, diagnostics: [{
+      range: { start: { line: 1, character: start }, end: { line: 1, character: start + units('target') } },
+      message: 'unused',
+    }] } });
+  } else if (method === 'textDocument/definition') {
+    const { textDocument: { uri }, position } = params;
+    const lines = documents.get(uri);
+    const expected = columnOf(lines[position.line], 'f(');
+    if (position.character !== expected) {
+      send({ id, error: { code: -32602, message: 'character ' + position.character + ' is not at f( (' + expected + ')' } });
+      return;
+    }
+    const start = columnOf(lines[1], 'target');
+    send({ id, result: { uri, range: { start: { line: 1, character: start }, end: { line: 1, character: start + units('target') } } } });
+  } else if (method === 'exit') {
+    process.exit(0);
+  } else if (id !== undefined) {
+    send({ id, result: null });
+  }
+};
+let buffer = Buffer.alloc(0);
+process.stdin.on('data', (chunk) => {
+  buffer = Buffer.concat([buffer, chunk]);
+  for (;;) {
+    const header = buffer.indexOf('\\r\\n\\r\\n');
+    if (header < 0) {
+      return;
+    }
+    const length = parseInt(/Content-Length: (\\d+)/i.exec(buffer.subarray(0, header).toString())[1], 10);
+    if (buffer.length < header + 4 + length) {
+      return;
+    }
+    const message = JSON.parse(buffer.subarray(header + 4, header + 4 + length).toString());
+    buffer = buffer.subarray(header + 4 + length);
+    handle(message);
+  }
+});
+`;
+
+describe('Position encoding negotiation', () => {
+  let dir: string;
+  let serverPath: string;
+  let filePath: string;
+  // Characters outside the BMP and outside ASCII put UTF-8, UTF-16, and UTF-32 columns apart
+  const lines = ['let a😀世 = f(x);', 'const s = "é😀"; target();', ''];
+
+  beforeAll(() => {
+    dir = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'encoding-')));
+    serverPath = path.join(dir, 'server.js');
+    fs.writeFileSync(serverPath, SERVER);
+    filePath = path.join(dir, 'main.ts');
+    fs.writeFileSync(filePath, lines.join('\n'));
+  });
+
+  afterAll(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  const cases: Array<[string, string, string[]]> = [
+    ['utf-8', 'positionEncoding', ['utf-8']],
+    ['utf-32', 'positionEncoding', ['utf-32']],
+    ['utf-16', 'no encoding', ['utf-16']],
+    ['utf-8', 'offsetEncoding', ['utf-8', 'offsetEncoding']],
+  ];
+
+  for (const [encoding, answer, args] of cases) {
+    it(`should convert positions for a server answering ${encoding} by ${answer}`, async () => {
+      const client = new LSPClient(process.execPath, [serverPath, ...args]);
+      try {
+        await client.initialize(dir);
+        expect(client.positionEncoding).toBe(encoding);
+        await client.openFile(filePath);
+        const uri = pathToUri(filePath);
+        const target = {
+          start: { line: 1, character: lines[1].indexOf('target') },
+          end: { line: 1, character: lines[1].indexOf('target') + 'target'.length },
+        };
+
+        const location = await definition(client, { textDocument: { uri }, position: { line: 0, character: lines[0].indexOf('f(') } });
+        expect(location).toEqual({ uri, range: target });
+
+        for (let i = 0; i < 50 && client.getFileDiagnostics(uri).length === 0; i++) {
+          await new Promise((resolve) => setTimeout(resolve, 20));
+        }
+        expect(client.getFileDiagnostics(uri).map((diagnostic) => diagnostic.range)).toEqual([target]);
+      } finally {
+        await client.shutdown();
+        await client.exit();
+        await client.close();
+      }
+    });
+  }
+});
+
+describe('Language server launch', () => {
+  it('should put PATH additions first and set the configured variables', () => {
+    const env = launchEnvironment(
+      { path: ['/opt/toolchain/bin', '/home/dev/.cargo/bin'], env: { RUSTUP_TOOLCHAIN: 'nightly', HOME: '/tmp/home' } },
+      { PATH: '/usr/bin', HOME: '/home/dev' }
+    );
+    expect(env).toEqual({
+      PATH: ['/opt/toolchain/bin', '/home/dev/.cargo/bin', '/usr/bin'].join(path.delimiter),
+      HOME: '/tmp/home',
+      RUSTUP_TOOLCHAIN: 'nightly',
+    });
+    // Windows spells it Path
+    expect(launchEnvironment({ path: ['C:\\tools'] }, { Path: 'C:\\Windows' })).toEqual({ Path: ['C:\\tools', 'C:\\Windows'].join(path.delimiter) });
+    expect(launchEnvironment({}, { PATH: '/usr/bin' })).toEqual({ PATH: '/usr/bin' });
+  });
+});
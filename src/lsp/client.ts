@@ -5,6 +5,7 @@
 import { spawn, ChildProcess } from 'child_process';
 import { Readable, Writable } from 'stream';
 import { createLogger, Component } from '../logging/logger.js';
+import { lspExits } from '../metrics/metrics.js';
 import {
   MessageReader,
   writeMessage,
@@ -29,11 +30,28 @@ import {
   Diagnostic,
   WorkspaceFolder,
   ClientCapabilities,
+  Position,
+  Range,
 } from '../protocol/types.js';
 import { pathToUri, uriToPath } from '../protocol/uri.js';
+import {
+  PositionEncoding,
+  SUPPORTED_POSITION_ENCODINGS,
+  parsePositionEncoding,
+  convertPosition,
+  convertRange,
+} from '../protocol/position.js';
 import { LSPCacheManager, CacheConfig } from '../cache/manager.js';
+import { QueueStats, RequestQueue } from './queue.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { pathKey } from '../workspace/paths.js';
+import { readFileText, sharedOverlay } from '../workspace/overlay.js';
+import { unpinned } from '../workspace/treesnapshot.js';
+import { currentBudget } from '../workspace/budget.js';
+import { currentSignal, throwIfCancelled, unlessCancelled } from '../workspace/cancellation.js';
 import * as fs from 'fs';
 import * as path from 'path';
+import { ToolError } from '../workspace/errors.js';
 
 const lspLogger = createLogger(Component.LSP);
 const processLogger = createLogger(Component.LSP_PROCESS);
@@ -53,6 +71,36 @@ export type NotificationHandler = (params: any) => void;
  */
 export type FileWatchHandler = (id: string, watchers: any[]) => void;
 
+/**
+ * How the language server process is started
+ */
+export interface LaunchOptions {
+  // Working directory of the server (default: this process's)
+  cwd?: string;
+  // Directories searched for the command and its tools before PATH
+  path?: string[];
+  // Environment variables set for the server, over this process's
+  env?: Record<string, string>;
+}
+
+/**
+ * Environment of the language server process
+ */
+export function launchEnvironment(options: LaunchOptions, base: NodeJS.ProcessEnv = process.env): NodeJS.ProcessEnv {
+  const env = { ...base, ...options.env };
+  if (options.path && options.path.length > 0) {
+    // Windows spells it Path
+    const key = Object.keys(env).find((name) => name.toUpperCase() === 'PATH') ?? 'PATH';
+    env[key] = [...options.path, ...(env[key] ? [env[key]] : [])].join(path.delimiter);
+  }
+  return env;
+}
+
+/**
+ * Ended server work kept for telling which answers it overlapped
+ */
+const MAX_ENDED_WORK = 50;
+
 /**
  * Open file information
  */
@@ -87,18 +135,47 @@ export class LSPClient {
   private pendingRequests = new Map<string, (msg: LSPMessage) => void>();
   private serverRequestHandlers = new Map<string, ServerRequestHandler>();
   private notificationHandlers = new Map<string, NotificationHandler>();
-  private diagnostics = new Map<string, Diagnostic[]>();
+  private diagnostics = new Map<string, Diagnostic[]>(); // Keyed by pathKey
   private openFiles = new Map<string, OpenFileInfo>();
   private cacheManager: LSPCacheManager;
+  private requests = new RequestQueue();
+  // Line contents used for position conversion, keyed by path
+  private lineCache = new Map<string, { mtimeMs: number; size: number; lines: string[] }>();
+  // Work the server reports in progress, e.g. loading packages, by progress token
+  private progress = new Map<string, { title: string; begunAt: number }>();
+  // Recently ended work, oldest first
+  private endedWork: Array<{ title: string; begunAt: number; endedAt: number }> = [];
 
-  constructor(command: string, args: string[] = [], cacheConfig?: Partial<CacheConfig>) {
+  /**
+   * Position encoding negotiated with the server
+   */
+  positionEncoding: PositionEncoding = 'utf-16';
+
+  /**
+   * Server name, version, and capabilities from the initialize response
+   */
+  serverInfo?: { name: string; version?: string };
+  serverCapabilities: Record<string, unknown> = {};
+
+  constructor(
+    readonly command: string,
+    readonly args: string[] = [],
+    cacheConfig?: Partial<CacheConfig>,
+    launch: LaunchOptions = {}
+  ) {
     // Initialize cache manager
     this.cacheManager = new LSPCacheManager(cacheConfig);
-    lspLogger.info('Starting LSP server: %s %s', command, args.join(' '));
+    lspLogger.info('Starting LSP server: %s %s%s', command, args.join(' '), launch.cwd ? ` in ${launch.cwd}` : '');
+    if (launch.env && Object.keys(launch.env).length > 0) {
+      // Values may be credentials
+      lspLogger.debug('LSP server environment sets %s', Object.keys(launch.env).join(', '));
+    }
 
+    // The command is looked up on the PATH of the environment given
     this.process = spawn(command, args, {
       stdio: ['pipe', 'pipe', 'pipe'],
-      env: process.env,
+      cwd: launch.cwd,
+      env: launchEnvironment(launch),
     });
 
     if (!this.process.stdin || !this.process.stdout || !this.process.stderr) {
@@ -123,6 +200,12 @@ export class LSPClient {
     // Handle process exit
     this.process.on('exit', (code, signal) => {
       lspLogger.info('LSP server exited with code %d signal %s', code, signal);
+      lspExits.inc({ signal: signal ?? 'none' });
+      // Nothing will answer the requests still waiting
+      for (const [id, resolve] of this.pendingRequests) {
+        this.pendingRequests.delete(id);
+        resolve({ jsonrpc: '2.0', id, error: { code: -32099, message: 'language server exited' } });
+      }
     });
 
     // Start message handling loop
@@ -158,6 +241,14 @@ export class LSPClient {
         } as WorkspaceFolder,
       ],
       capabilities: {
+        general: {
+          positionEncodings: SUPPORTED_POSITION_ENCODINGS,
+        },
+        // clangd's pre-3.17 extension for the same negotiation
+        offsetEncoding: SUPPORTED_POSITION_ENCODINGS.map((encoding) => encoding.replace('-', '')),
+        window: {
+          workDoneProgress: true,
+        },
         workspace: {
           configuration: true,
           didChangeConfiguration: {
@@ -179,6 +270,9 @@ export class LSPClient {
           codeLens: {
             dynamicRegistration: true,
           },
+          documentSymbol: {
+            hierarchicalDocumentSymbolSupport: true,
+          },
           publishDiagnostics: {
             versionSupport: true,
           },
@@ -202,6 +296,18 @@ export class LSPClient {
     };
 
     const result = await this.call<InitializeResult>('initialize', initParams);
+    this.positionEncoding = parsePositionEncoding(
+      result.capabilities?.positionEncoding ?? (result as { offsetEncoding?: string }).offsetEncoding
+    );
+    if (this.positionEncoding !== 'utf-16') {
+      lspLogger.info('Server uses %s positions', this.positionEncoding);
+    }
+    this.serverInfo = result.serverInfo;
+    this.serverCapabilities = (result.capabilities ?? {}) as Record<string, unknown>;
+
+    // Servers start reporting progress, such as loading packages, once initialized
+    this.registerServerRequestHandler('window/workDoneProgress/create', async () => null);
+    this.registerNotificationHandler('$/progress', this.handleProgress.bind(this));
 
     // Send initialized notification
     await this.notify('initialized', {} as InitializedParams);
@@ -237,18 +343,18 @@ export class LSPClient {
       return;
     }
 
-    // Read file content
+    // Read file content; the server sees files as they are now, even from calls pinned to a snapshot
     let content: string;
     try {
-      content = await fs.promises.readFile(filePath, 'utf8');
+      content = await unpinned(() => readFileText(filePath));
     } catch (err) {
-      throw new Error(`Error reading file: ${err}`);
+      throw new ToolError('not-found', `Error reading file: ${err}`);
     }
 
     const params: DidOpenTextDocumentParams = {
       textDocument: {
         uri,
-        languageId: this.detectLanguageId(uri),
+        languageId: detectLanguageId(uri),
         version: 1,
         text: content,
       } as TextDocumentItem,
@@ -275,7 +381,7 @@ export class LSPClient {
     this.cacheManager.invalidateFile(filePath);
 
     // Read updated content
-    const content = await fs.promises.readFile(filePath, 'utf8');
+    const content = await unpinned(() => readFileText(filePath));
 
     // Increment version
     fileInfo.version++;
@@ -295,6 +401,27 @@ export class LSPClient {
     await this.notify('textDocument/didChange', params);
   }
 
+  /**
+   * Send a file's current content to the server after its overlay changed
+   * Overlaid files are opened so the server indexes them; a file whose
+   * overlay was dropped goes back to its disk content, or is closed when it
+   * does not exist on disk
+   */
+  async syncFile(filePath: string): Promise<void> {
+    const overlaid = sharedOverlay().has(filePath);
+    if (this.isFileOpen(filePath)) {
+      if (overlaid || fs.existsSync(filePath)) {
+        await this.notifyChange(filePath);
+      } else {
+        await this.closeFile(filePath);
+      }
+    } else if (overlaid) {
+      await this.openFile(filePath);
+    }
+    this.cacheManager.invalidateFile(filePath);
+    this.cacheManager.invalidateWorkspaceSymbols();
+  }
+
   /**
    * Close a file in the LSP server
    */
@@ -344,13 +471,41 @@ export class LSPClient {
    * Get diagnostics for a file
    */
   getFileDiagnostics(uri: string): Diagnostic[] {
-    return this.diagnostics.get(uri) || [];
+    return this.diagnostics.get(pathKey(uriToPath(uri))) || [];
+  }
+
+  /**
+   * Requests in flight to the server and waiting for a slot
+   */
+  getRequestStats(): QueueStats {
+    return this.requests.stats();
   }
 
   /**
    * Send a request and wait for response
+   * Requests wait for an in-flight slot; initialize and shutdown never do.
+   * The others end with the call they run for: a cancelled call's requests
+   * leave the queue, or are cancelled at the server
    */
   async call<T = any>(method: string, params?: any): Promise<T> {
+    if (method === 'initialize' || method === 'shutdown') {
+      return this.send<T>(method, params);
+    }
+    const signal = currentSignal();
+    throwIfCancelled(signal);
+    currentBudget()?.beforeLspCall();
+    const release = await this.requests.acquire(method, signal);
+    try {
+      return await this.send<T>(method, params, signal);
+    } finally {
+      release();
+    }
+  }
+
+  /**
+   * Send a request now and wait for response
+   */
+  private async send<T>(method: string, params?: any, signal?: AbortSignal): Promise<T> {
     const id = this.nextId++;
     const idStr = id.toString();
 
@@ -368,8 +523,14 @@ export class LSPClient {
 
     lspLogger.debug('Waiting for response to request ID: %d', id);
 
-    // Wait for response
-    const response = await responsePromise;
+    // Wait for response, or tell the server to stop working on it
+    const response = await unlessCancelled(responsePromise, signal, () => {
+      if (this.pendingRequests.delete(idStr)) {
+        lspLogger.debug('Cancelling request ID: %d', id);
+        this.notify('$/cancelRequest', { id }).catch((err) =>
+          lspLogger.debug('Failed to cancel request ID %d: %s', id, (err as Error).message));
+      }
+    });
 
     lspLogger.debug('Received response for request ID: %d', id);
 
@@ -381,6 +542,41 @@ export class LSPClient {
     return response.result as T;
   }
 
+  /**
+   * Check whether the server process is still running
+   */
+  isRunning(): boolean {
+    return this.process.exitCode === null && this.process.signalCode === null;
+  }
+
+  /**
+   * Check that the server answers a request within the time limit
+   * Servers must answer unknown `$/` requests with MethodNotFound, so any
+   * response, error or not, shows the server is processing messages
+   */
+  async ping(timeoutMs: number): Promise<boolean> {
+    const id = this.nextId++;
+    const idStr = id.toString();
+    const response = new Promise<boolean>((resolve) => {
+      const timer = setTimeout(() => {
+        this.pendingRequests.delete(idStr);
+        resolve(false);
+      }, timeoutMs);
+      this.pendingRequests.set(idStr, () => {
+        clearTimeout(timer);
+        resolve(true);
+      });
+    });
+    try {
+      await writeMessage(this.stdin, createRequest(id, '$/grepforcode/ping'));
+    } catch (err) {
+      this.pendingRequests.delete(idStr);
+      lspLogger.warn('Ping failed: %s', (err as Error).message);
+      return false;
+    }
+    return response;
+  }
+
   /**
    * Send a notification
    */
@@ -393,13 +589,26 @@ export class LSPClient {
   /**
    * Send shutdown request
    */
-  async shutdown(): Promise<void> {
+  async shutdown(timeoutMs = 5000): Promise<void> {
     lspLogger.info('Sending shutdown request');
+    let timer: NodeJS.Timeout | undefined;
     try {
-      await this.call('shutdown');
-      lspLogger.info('Shutdown request completed');
+      // A hung server must not keep the process from exiting
+      const answered = await Promise.race([
+        this.call('shutdown').then(() => true),
+        new Promise<boolean>((resolve) => {
+          timer = setTimeout(() => resolve(false), timeoutMs);
+        }),
+      ]);
+      if (answered) {
+        lspLogger.info('Shutdown request completed');
+      } else {
+        lspLogger.warn('Shutdown request not answered within %dms', timeoutMs);
+      }
     } catch (err) {
       lspLogger.error('Shutdown request failed: %s', err);
+    } finally {
+      clearTimeout(timer);
     }
   }
 
@@ -439,42 +648,6 @@ export class LSPClient {
     });
   }
 
-  /**
-   * Detect language ID from file URI
-   */
-  private detectLanguageId(uri: string): string {
-    const ext = path.extname(uri).toLowerCase();
-    const languageMap: Record<string, string> = {
-      '.ts': 'typescript',
-      '.tsx': 'typescriptreact',
-      '.js': 'javascript',
-      '.jsx': 'javascriptreact',
-      '.py': 'python',
-      '.go': 'go',
-      '.rs': 'rust',
-      '.c': 'c',
-      '.cpp': 'cpp',
-      '.cc': 'cpp',
-      '.cxx': 'cpp',
-      '.h': 'c',
-      '.hpp': 'cpp',
-      '.java': 'java',
-      '.cs': 'csharp',
-      '.rb': 'ruby',
-      '.php': 'php',
-      '.swift': 'swift',
-      '.kt': 'kotlin',
-      '.scala': 'scala',
-      '.r': 'r',
-      '.R': 'r',
-      '.sh': 'shell',
-      '.bash': 'shell',
-      '.zsh': 'shell',
-      '.fish': 'shell',
-    };
-    return languageMap[ext] || 'plaintext';
-  }
-
   /**
    * Handle incoming messages
    */
@@ -606,6 +779,35 @@ export class LSPClient {
     return null;
   }
 
+  /**
+   * Handle $/progress notification, tracking work begun and ended
+   */
+  private handleProgress(params: any): void {
+    const token = String(params?.token);
+    const kind = params?.value?.kind;
+    if (kind === 'begin') {
+      this.progress.set(token, { title: params.value.title || 'work', begunAt: Date.now() });
+    } else if (kind === 'end') {
+      const work = this.progress.get(token);
+      if (work) {
+        this.progress.delete(token);
+        this.endedWork.push({ ...work, endedAt: Date.now() });
+        this.endedWork.splice(0, this.endedWork.length - MAX_ENDED_WORK);
+      }
+    }
+  }
+
+  /**
+   * Titles of the work the server reported in progress at a time, such as
+   * loading packages; answers given meanwhile may miss what it loaded
+   */
+  workInProgressAt(time: number): string[] {
+    return [
+      ...this.endedWork.filter((work) => work.begunAt <= time && work.endedAt > time),
+      ...Array.from(this.progress.values()).filter((work) => work.begunAt <= time),
+    ].map((work) => work.title);
+  }
+
   /**
    * Handle window/showMessage notification
    */
@@ -621,8 +823,11 @@ export class LSPClient {
   private handleDiagnostics(params: any): void {
     if (!params?.uri) return;
 
-    const diagnostics = params.diagnostics || [];
-    this.diagnostics.set(params.uri, diagnostics);
+    const diagnostics = (params.diagnostics || []).map((diagnostic: Diagnostic) => (
+      { ...diagnostic, range: this.fromServerRange(params.uri, diagnostic.range) }
+    ));
+    // Keyed by path so a server spelling the URI differently (case, encoding) still matches
+    this.diagnostics.set(pathKey(uriToPath(params.uri)), diagnostics);
 
     // Also cache diagnostics
     const filePath = uriToPath(params.uri);
@@ -654,5 +859,52 @@ export class LSPClient {
   getCacheManager(): LSPCacheManager {
     return this.cacheManager;
   }
+
+  /**
+   * Convert a position from UTF-16 columns to the server's encoding
+   */
+  toServerPosition(uri: string, position: Position): Position {
+    if (this.positionEncoding === 'utf-16') {
+      return position;
+    }
+    return convertPosition(this.documentLines(uri), position, this.positionEncoding, 'toServer');
+  }
+
+  /**
+   * Convert a range reported by the server to UTF-16 columns
+   */
+  fromServerRange(uri: string, range: Range): Range {
+    if (this.positionEncoding === 'utf-16' || !range) {
+      return range;
+    }
+    return convertRange(this.documentLines(uri), range, this.positionEncoding, 'fromServer');
+  }
+
+  /**
+   * Lines of a document as the server sees it: the overlay, or the file on disk, re-read when it changes
+   */
+  private documentLines(uri: string): string[] {
+    const filePath = uriToPath(uri);
+    const overlaid = sharedOverlay().get(filePath);
+    if (overlaid !== undefined) {
+      return overlaid.split('\n');
+    }
+    try {
+      const stats = fs.statSync(filePath);
+      const cached = this.lineCache.get(filePath);
+      if (cached && cached.mtimeMs === stats.mtimeMs && cached.size === stats.size) {
+        return cached.lines;
+      }
+      const lines = fs.readFileSync(filePath, 'utf8').split('\n');
+      if (this.lineCache.size >= 256) {
+        this.lineCache.clear();
+      }
+      this.lineCache.set(filePath, { mtimeMs: stats.mtimeMs, size: stats.size, lines });
+      return lines;
+    } catch (err) {
+      lspLogger.debug('Cannot read %s for position conversion: %s', filePath, err);
+      return [];
+    }
+  }
 }
 
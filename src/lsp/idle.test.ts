@@ -0,0 +1,66 @@
+/**
+ * Tests for idle shutdown of the language server
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { IdleTracker, idleTimeoutFromEnv, usesLanguageServer } from './idle';
+
+describe('Idle shutdown', () => {
+  it('should read the timeout in minutes, defaulting to never', () => {
+    expect(idleTimeoutFromEnv({})).toBe(0);
+    expect(idleTimeoutFromEnv({ LSP_IDLE_TIMEOUT_MINUTES: '15' })).toBe(900_000);
+    expect(idleTimeoutFromEnv({ LSP_IDLE_TIMEOUT_MINUTES: 'soon' })).toBe(0);
+  });
+
+  it('should tell calls that need the server from the rest', () => {
+    expect(usesLanguageServer('hover')).toBe(true);
+    expect(usesLanguageServer('search_code', { pattern: 'load' })).toBe(false);
+    expect(usesLanguageServer('search_code', { pattern: 'load', context: 'function' })).toBe(true);
+    expect(usesLanguageServer('search_code', { query: 'load kind:function' })).toBe(true);
+    expect(usesLanguageServer('batch_search', { queries: [{ pattern: 'a' }, { pattern: 'b', kind: 'class' }] })).toBe(true);
+    expect(usesLanguageServer('pin_file', { filePath: 'a.ts' })).toBe(false);
+    expect(usesLanguageServer('pin_file', { symbolName: 'Store' })).toBe(true);
+    expect(usesLanguageServer('read_range', { filePath: 'a.go', chunk: 2 })).toBe(true);
+    expect(usesLanguageServer('find_duplicates')).toBe(false);
+  });
+
+  it('should count every tool whose handler calls the server', () => {
+    // Arguments under which the tools needing the server only sometimes do need it
+    const needing: Record<string, Record<string, unknown>> = {
+      search_code: { context: 'function' },
+      batch_search: { kind: 'class' },
+      pin_file: { symbolName: 'Store' },
+      read_range: { chunk: 1 },
+    };
+    // overlay only syncs a server that is already running
+    const passive = new Set(['overlay']);
+    const source = fs.readFileSync(path.join(__dirname, '..', 'index.ts'), 'utf8');
+    const handlers = source.split(/\n {6}default: /)[0].split(/\n {6}case '/).slice(1).map((body) => [body.slice(0, body.indexOf("'")), body] as const);
+    const calling = handlers.filter(([name, body]) => /lspClient/.test(body) && !passive.has(name)).map(([name]) => name);
+    expect(calling.length).toBeGreaterThan(10);
+    expect(calling.filter((name) => !usesLanguageServer(name, needing[name]))).toEqual([]);
+  });
+
+  it('should expire only after the timeout with no call running', () => {
+    let now = 0;
+    const tracker = new IdleTracker(() => 60_000, () => now);
+    const end = tracker.begin();
+    now = 120_000;
+    expect(tracker.expired()).toBe(false);
+
+    end();
+    now = 150_000;
+    expect(tracker.idleFor()).toBe(30_000);
+    expect(tracker.expired()).toBe(false);
+    now = 180_000;
+    expect(tracker.expired()).toBe(true);
+  });
+
+  it('should never expire without a timeout', () => {
+    let now = 0;
+    const tracker = new IdleTracker(() => 0, () => now);
+    now = Number.MAX_SAFE_INTEGER;
+    expect(tracker.expired()).toBe(false);
+  });
+});
@@ -0,0 +1,98 @@
+/**
+ * Idle shutdown - when to stop an unused language server
+ * Servers such as jdtls and rust-analyzer hold gigabytes while they wait for
+ * requests. After LSP_IDLE_TIMEOUT_MINUTES without a call that needs the
+ * server it is stopped, and the next such call starts it again
+ */
+
+/**
+ * Tools that always send requests to the language server
+ */
+const LSP_BACKED_TOOLS = new Set([
+  'definition', 'references', 'diagnostics', 'hover', 'rename_symbol', 'edit_file', 'impact_report',
+  'api_surface', 'symbol_usage', 'outline', 'todo_comments', 'symbol_history', 'explain', 'explore_symbol', 'capabilities',
+  'warmup', 'bookmark_symbol', 'resolve_bookmark',
+]);
+
+/**
+ * Check if a search_code call asks for symbol information
+ */
+function searchUsesSymbols(args: Record<string, unknown>): boolean {
+  return !!args.context || !!args.kind || (typeof args.query === 'string' && /(^|\s)kind:/.test(args.query));
+}
+
+/**
+ * Check if a tool call needs the language server running
+ */
+export function usesLanguageServer(name: string, args: Record<string, unknown> = {}): boolean {
+  if (name === 'search_code') {
+    return searchUsesSymbols(args);
+  }
+  if (name === 'batch_search') {
+    const queries = Array.isArray(args.queries) ? args.queries as Array<Record<string, unknown>> : [];
+    return searchUsesSymbols(args) || queries.some((query) => searchUsesSymbols({ ...args, ...query }));
+  }
+  if (name === 'pin_file') {
+    return !!args.symbolName;
+  }
+  if (name === 'read_range') {
+    return args.chunk !== undefined;
+  }
+  return LSP_BACKED_TOOLS.has(name);
+}
+
+/**
+ * Idle timeout from LSP_IDLE_TIMEOUT_MINUTES in milliseconds, 0 for never (default: 0)
+ */
+export function idleTimeoutFromEnv(env: NodeJS.ProcessEnv = process.env): number {
+  const minutes = parseFloat(env.LSP_IDLE_TIMEOUT_MINUTES || '0');
+  return Number.isFinite(minutes) && minutes > 0 ? minutes * 60_000 : 0;
+}
+
+/**
+ * Tracks the calls that use the language server
+ * The timeout is read on every check, so a configuration reload applies at once
+ */
+export class IdleTracker {
+  private running = 0;
+  private lastUsed: number;
+
+  constructor(
+    private timeout: () => number = () => idleTimeoutFromEnv(),
+    private now: () => number = Date.now
+  ) {
+    this.lastUsed = now();
+  }
+
+  /**
+   * Record the start of a call
+   * Returns the function that records its end
+   */
+  begin(): () => void {
+    this.running++;
+    this.lastUsed = this.now();
+    let ended = false;
+    return () => {
+      if (!ended) {
+        ended = true;
+        this.running--;
+        this.lastUsed = this.now();
+      }
+    };
+  }
+
+  /**
+   * Milliseconds since the last call ended, 0 while one runs
+   */
+  idleFor(): number {
+    return this.running > 0 ? 0 : this.now() - this.lastUsed;
+  }
+
+  /**
+   * Check if the server has been unused for longer than the timeout
+   */
+  expired(): boolean {
+    const timeout = this.timeout();
+    return timeout > 0 && this.running === 0 && this.idleFor() >= timeout;
+  }
+}
@@ -1,6 +1,8 @@
 /**
  * LSP Methods - wrapper functions for LSP protocol methods
  * All methods include caching to improve performance
+ * Positions are converted to and from the server's position encoding here,
+ * so callers always work in UTF-16 columns
  */
 
 import { LSPClient } from './client.js';
@@ -10,17 +12,86 @@ import {
   HoverParams,
   RenameParams,
   DefinitionParams,
+  DocumentHighlight,
+  DocumentHighlightParams,
+  DocumentSymbolParams,
+  DocumentSymbol,
   Location,
+  LocationLink,
   Hover,
   WorkspaceEdit,
   SymbolInformation,
   WorkspaceSymbol,
+  TextDocumentPositionParams,
 } from '../protocol/types.js';
 import { uriToPath } from '../protocol/uri.js';
 import { createLogger, Component } from '../logging/logger.js';
 
 const methodsLogger = createLogger(Component.LSP);
 
+/**
+ * Copy of request params with the position in the server's encoding
+ */
+function toServerParams<T extends TextDocumentPositionParams>(client: LSPClient, params: T): T {
+  return { ...params, position: client.toServerPosition(params.textDocument.uri, params.position) };
+}
+
+/**
+ * Convert a location reported by the server to UTF-16 columns
+ */
+function fromServerLocation(client: LSPClient, location: Location): Location {
+  return { ...location, range: client.fromServerRange(location.uri, location.range) };
+}
+
+/**
+ * Convert a location or location link reported by the server to UTF-16 columns
+ */
+function fromServerLocationOrLink(client: LSPClient, location: Location | LocationLink): Location | LocationLink {
+  if ('targetUri' in location) {
+    return {
+      ...location,
+      targetRange: client.fromServerRange(location.targetUri, location.targetRange),
+      targetSelectionRange: client.fromServerRange(location.targetUri, location.targetSelectionRange),
+    };
+  }
+  return fromServerLocation(client, location);
+}
+
+/**
+ * Convert a document symbol tree reported by the server to UTF-16 columns
+ */
+function fromServerDocumentSymbol(client: LSPClient, uri: string, sym: DocumentSymbol): DocumentSymbol {
+  return {
+    ...sym,
+    range: client.fromServerRange(uri, sym.range),
+    selectionRange: client.fromServerRange(uri, sym.selectionRange),
+    children: sym.children?.map((child) => fromServerDocumentSymbol(client, uri, child)),
+  };
+}
+
+/**
+ * Convert the text edits of a workspace edit to UTF-16 columns
+ */
+function fromServerWorkspaceEdit(client: LSPClient, edit: WorkspaceEdit): WorkspaceEdit {
+  const converted: WorkspaceEdit = { ...edit };
+  if (edit.changes) {
+    converted.changes = Object.fromEntries(Object.entries(edit.changes).map(([uri, edits]) => [
+      uri,
+      edits.map((textEdit) => ({ ...textEdit, range: client.fromServerRange(uri, textEdit.range) })),
+    ]));
+  }
+  if (edit.documentChanges) {
+    converted.documentChanges = edit.documentChanges.map((change) => {
+      if (!('textDocument' in change)) {
+        return change; // File create, rename, or delete
+      }
+      const uri = change.textDocument.uri;
+      return { ...change, edits: change.edits.map((textEdit) => ({ ...textEdit, range: client.fromServerRange(uri, textEdit.range) })) };
+    });
+  }
+  return converted;
+}
+
 /**
  * Symbol result wrapper
  */
@@ -49,7 +120,9 @@ export async function symbol(client: LSPClient, params: WorkspaceSymbolParams):
   // Cache miss - query LSP
   methodsLogger.debug('Cache miss for workspace symbols: %s', query);
   const result = await client.call<(SymbolInformation | WorkspaceSymbol)[] | null>('workspace/symbol', params);
-  const symbols = result || [];
+  const symbols = (result || []).map((sym) => (
+    sym.location && 'range' in sym.location ? { ...sym, location: fromServerLocation(client, sym.location) } : sym
+  ));
 
   // Cache the result
   cacheManager.setWorkspaceSymbols(query, symbols);
@@ -61,27 +134,38 @@ export async function symbol(client: LSPClient, params: WorkspaceSymbolParams):
  * Request references with caching
  */
 export async function references(client: LSPClient, params: ReferenceParams): Promise<Location[]> {
+  return (await answeredReferences(client, params)).locations;
+}
+
+/**
+ * References with the time the server answered, earlier than now when cached
+ */
+export async function answeredReferences(
+  client: LSPClient,
+  params: ReferenceParams
+): Promise<{ locations: Location[]; answeredAt: number; cached: boolean }> {
   const cacheManager = client.getCacheManager();
   const filePath = uriToPath(params.textDocument.uri);
   const line = params.position.line;
   const character = params.position.character;
 
   // Check cache first
-  const cachedRefs = cacheManager.getReferences(filePath, line, character);
+  const cachedRefs = cacheManager.getReferencesEntry(filePath, line, character);
   if (cachedRefs !== null) {
     methodsLogger.debug('Cache hit for references: %s:%d:%d', filePath, line, character);
-    return cachedRefs;
+    return { locations: cachedRefs.data, answeredAt: cachedRefs.timestamp, cached: true };
   }
 
   // Cache miss - query LSP
   methodsLogger.debug('Cache miss for references: %s:%d:%d', filePath, line, character);
-  const result = await client.call<Location[] | null>('textDocument/references', params);
-  const locations = result || [];
+  const answeredAt = Date.now();
+  const result = await client.call<Location[] | null>('textDocument/references', toServerParams(client, params));
+  const locations = (result || []).map((location) => fromServerLocation(client, location));
 
   // Cache the result
   cacheManager.setReferences(filePath, line, character, locations);
 
-  return locations;
+  return { locations, answeredAt, cached: false };
 }
 
 /**
@@ -102,7 +186,10 @@ export async function hover(client: LSPClient, params: HoverParams): Promise<Hov
 
   // Cache miss - query LSP
   methodsLogger.debug('Cache miss for hover: %s:%d:%d', filePath, line, character);
-  const result = await client.call<Hover | null>('textDocument/hover', params);
+  const serverResult = await client.call<Hover | null>('textDocument/hover', toServerParams(client, params));
+  const result = serverResult?.range
+    ? { ...serverResult, range: client.fromServerRange(params.textDocument.uri, serverResult.range) }
+    : serverResult;
 
   // Cache the result (even if null)
   cacheManager.setHover(filePath, line, character, result);
@@ -115,8 +202,8 @@ export async function hover(client: LSPClient, params: HoverParams): Promise<Hov
  */
 export async function rename(client: LSPClient, params: RenameParams): Promise<WorkspaceEdit | null> {
   // Rename operations modify state, so they should not be cached
-  const result = await client.call<WorkspaceEdit | null>('textDocument/rename', params);
-  return result;
+  const result = await client.call<WorkspaceEdit | null>('textDocument/rename', toServerParams(client, params));
+  return result && fromServerWorkspaceEdit(client, result);
 }
 
 /**
@@ -144,7 +231,10 @@ export async function definition(client: LSPClient, params: DefinitionParams): P
 
   // Cache miss - query LSP
   methodsLogger.debug('Cache miss for definitions: %s:%d:%d', filePath, line, character);
-  const result = await client.call<Location | Location[] | null>('textDocument/definition', params);
+  const serverResult = await client.call<Location | Location[] | null>('textDocument/definition', toServerParams(client, params));
+  const result = Array.isArray(serverResult)
+    ? serverResult.map((location) => fromServerLocationOrLink(client, location) as Location)
+    : serverResult && fromServerLocationOrLink(client, serverResult) as Location;
 
   // Normalize result to array for caching
   let locations: Location[] = [];
@@ -162,3 +252,59 @@ export async function definition(client: LSPClient, params: DefinitionParams): P
   return result;
 }
 
+/**
+ * Request the implementations of the interface or abstract member at a position
+ * Not cached; links are reduced to the locations of their target names
+ */
+export async function implementations(client: LSPClient, params: TextDocumentPositionParams): Promise<Location[]> {
+  const result = await client.call<Location | Array<Location | LocationLink> | null>('textDocument/implementation', toServerParams(client, params));
+  const locations = Array.isArray(result) ? result : result ? [result] : [];
+  return locations.map((location) => {
+    const converted = fromServerLocationOrLink(client, location);
+    return 'targetUri' in converted ? { uri: converted.targetUri, range: converted.targetSelectionRange } : converted;
+  });
+}
+
+/**
+ * Request the highlights of the symbol at a position within its document
+ * Not cached; servers that tell reads from writes set each highlight's kind
+ */
+export async function documentHighlight(client: LSPClient, params: DocumentHighlightParams): Promise<DocumentHighlight[]> {
+  const result = await client.call<DocumentHighlight[] | null>('textDocument/documentHighlight', toServerParams(client, params));
+  const uri = params.textDocument.uri;
+  return (result || []).map((highlight) => ({ ...highlight, range: client.fromServerRange(uri, highlight.range) }));
+}
+
+/**
+ * Request document symbols with caching
+ */
+export async function documentSymbols(
+  client: LSPClient,
+  params: DocumentSymbolParams
+): Promise<(DocumentSymbol | SymbolInformation)[]> {
+  const cacheManager = client.getCacheManager();
+  const filePath = uriToPath(params.textDocument.uri);
+
+  // Check cache first
+  const cachedSymbols = cacheManager.getDocumentSymbols(filePath);
+  if (cachedSymbols !== null) {
+    methodsLogger.debug('Cache hit for document symbols: %s', filePath);
+    return cachedSymbols;
+  }
+
+  // Cache miss - query LSP
+  methodsLogger.debug('Cache miss for document symbols: %s', filePath);
+  const result = await client.call<(DocumentSymbol | SymbolInformation)[] | null>(
+    'textDocument/documentSymbol',
+    params
+  );
+  const uri = params.textDocument.uri;
+  const symbols = (result || []).map((sym) => (
+    'location' in sym ? { ...sym, location: fromServerLocation(client, sym.location) } : fromServerDocumentSymbol(client, uri, sym)
+  ));
+
+  // Cache the result
+  cacheManager.setDocumentSymbols(filePath, symbols);
+
+  return symbols;
+}
@@ -0,0 +1,68 @@
+/**
+ * Tests for the language server request queue
+ */
+
+import { RequestQueue, inFlightLimitsFromEnv, requestLane } from './queue';
+
+describe('Request queue', () => {
+  it('should read limits from the environment', () => {
+    expect(inFlightLimitsFromEnv({})).toEqual({ total: 16, slow: 4 });
+    expect(inFlightLimitsFromEnv({ LSP_MAX_IN_FLIGHT: '2' })).toEqual({ total: 2, slow: 1 });
+    expect(inFlightLimitsFromEnv({ LSP_MAX_IN_FLIGHT: '0' })).toEqual({ total: 0, slow: 0 });
+    expect(inFlightLimitsFromEnv({ LSP_MAX_IN_FLIGHT: 'x', LSP_MAX_SLOW_IN_FLIGHT: '3' })).toEqual({ total: 16, slow: 3 });
+    expect(requestLane('textDocument/references')).toBe('slow');
+    expect(requestLane('textDocument/hover')).toBe('fast');
+  });
+
+  it('should let fast requests past slow ones holding their share', async () => {
+    const queue = new RequestQueue(() => ({ total: 3, slow: 1 }));
+    const references = await queue.acquire('textDocument/references');
+    const started: string[] = [];
+    const waitFor = (method: string) => queue.acquire(method).then((release) => {
+      started.push(method);
+      return release;
+    });
+
+    const slow = waitFor('workspace/symbol');
+    const hovers = [waitFor('textDocument/hover'), waitFor('textDocument/definition')];
+    const releases = await Promise.all(hovers);
+    expect(started).toEqual(['textDocument/hover', 'textDocument/definition']);
+    expect(queue.stats()).toEqual({ inFlight: { fast: 2, slow: 1 }, queued: { fast: 0, slow: 1 } });
+
+    // The total is reached, so a third fast request waits, and goes first
+    const third = waitFor('textDocument/hover');
+    references();
+    await Promise.resolve();
+    expect(queue.stats().queued).toEqual({ fast: 0, slow: 1 });
+    releases.forEach((release) => release());
+    (await slow)();
+    (await third)();
+    expect(started).toEqual(['textDocument/hover', 'textDocument/definition', 'textDocument/hover', 'workspace/symbol']);
+    expect(queue.stats()).toEqual({ inFlight: { fast: 0, slow: 0 }, queued: { fast: 0, slow: 0 } });
+  });
+
+  it('should keep a slot for fast requests when the slow share is the whole limit', async () => {
+    const queue = new RequestQueue(() => ({ total: 2, slow: 2 }));
+    await queue.acquire('textDocument/references');
+    let second = false;
+    queue.acquire('textDocument/references').then(() => { second = true; });
+    await queue.acquire('textDocument/hover');
+    await Promise.resolve();
+    expect(second).toBe(false);
+  });
+
+  it('should drop a waiting request whose call is cancelled', async () => {
+    const queue = new RequestQueue(() => ({ total: 1, slow: 0 }));
+    const release = await queue.acquire('textDocument/hover');
+    const controller = new AbortController();
+    const cancelled = queue.acquire('textDocument/hover', controller.signal);
+    const next = queue.acquire('textDocument/definition');
+    expect(queue.stats().queued.fast).toBe(2);
+    controller.abort();
+    await expect(cancelled).rejects.toThrow('Cancelled');
+    expect(queue.stats().queued.fast).toBe(1);
+    release();
+    (await next)();
+    expect(queue.stats()).toEqual({ inFlight: { fast: 0, slow: 0 }, queued: { fast: 0, slow: 0 } });
+  });
+});
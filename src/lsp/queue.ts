@@ -0,0 +1,145 @@
+/**
+ * Request queue - caps on the requests in flight to one language server
+ * Requests are multiplexed by ID, so any number can be outstanding, but most
+ * servers work through them a few at a time; the cap keeps a burst from piling
+ * up inside the server. Slow workspace-wide requests (references, workspace
+ * symbols, rename, call hierarchy) get their own smaller share of it, so a
+ * batch of hover and definition calls never waits behind one of them
+ */
+
+import { unlessCancelled } from '../workspace/cancellation.js';
+
+/**
+ * Which share of the in-flight limit a request counts against
+ */
+export type RequestLane = 'fast' | 'slow';
+
+/**
+ * Methods that may take the server seconds on a large workspace
+ */
+const SLOW_METHODS = new Set([
+  'textDocument/references',
+  'textDocument/implementation',
+  'textDocument/rename',
+  'textDocument/prepareCallHierarchy',
+  'callHierarchy/incomingCalls',
+  'callHierarchy/outgoingCalls',
+  'typeHierarchy/supertypes',
+  'typeHierarchy/subtypes',
+  'workspace/symbol',
+  'workspace/diagnostic',
+]);
+
+/**
+ * Lane of a request method
+ */
+export function requestLane(method: string): RequestLane {
+  return SLOW_METHODS.has(method) ? 'slow' : 'fast';
+}
+
+/**
+ * Requests in flight at once; 0 means unlimited
+ */
+export interface InFlightLimits {
+  total: number;
+  slow: number;
+}
+
+/**
+ * Limits from LSP_MAX_IN_FLIGHT (default: 16) and LSP_MAX_SLOW_IN_FLIGHT (default: a quarter of it, at least 1)
+ */
+export function inFlightLimitsFromEnv(env: NodeJS.ProcessEnv = process.env): InFlightLimits {
+  const read = (name: string, fallback: number) => {
+    const value = env[name] !== undefined && env[name] !== '' ? parseInt(env[name]!, 10) : fallback;
+    return Number.isFinite(value) && value >= 0 ? value : fallback;
+  };
+  const total = read('LSP_MAX_IN_FLIGHT', 16);
+  return { total, slow: read('LSP_MAX_SLOW_IN_FLIGHT', total > 0 ? Math.max(1, Math.floor(total / 4)) : 0) };
+}
+
+/**
+ * Requests waiting for a slot, and in flight, per lane
+ */
+export interface QueueStats {
+  inFlight: Record<RequestLane, number>;
+  queued: Record<RequestLane, number>;
+}
+
+/**
+ * Hands out in-flight slots, first come first served within each lane
+ * Limits are read on every request, so a configuration reload applies at once
+ */
+export class RequestQueue {
+  private inFlight: Record<RequestLane, number> = { fast: 0, slow: 0 };
+  private waiting: Record<RequestLane, Array<() => void>> = { fast: [], slow: [] };
+
+  constructor(private limits: () => InFlightLimits = () => inFlightLimitsFromEnv()) {}
+
+  /**
+   * Wait for a slot for a request
+   * Returns the function that frees it, to call once the response arrives.
+   * A request whose call is cancelled while it waits leaves the queue
+   */
+  async acquire(method: string, signal?: AbortSignal): Promise<() => void> {
+    const lane = requestLane(method);
+    if (this.waiting[lane].length > 0 || !this.hasRoom(lane)) {
+      let waiter!: () => void;
+      const slot = new Promise<void>((resolve) => {
+        waiter = resolve;
+        this.waiting[lane].push(resolve);
+      });
+      await unlessCancelled(slot, signal, () => {
+        const index = this.waiting[lane].indexOf(waiter);
+        if (index >= 0) {
+          this.waiting[lane].splice(index, 1);
+        } else {
+          // The slot was handed over as the call was cancelled; pass it on
+          this.inFlight[lane]--;
+          this.dispatch();
+        }
+      });
+    } else {
+      this.inFlight[lane]++;
+    }
+    let released = false;
+    return () => {
+      if (!released) {
+        released = true;
+        this.inFlight[lane]--;
+        this.dispatch();
+      }
+    };
+  }
+
+  /**
+   * Requests waiting and in flight
+   */
+  stats(): QueueStats {
+    return {
+      inFlight: { ...this.inFlight },
+      queued: { fast: this.waiting.fast.length, slow: this.waiting.slow.length },
+    };
+  }
+
+  private hasRoom(lane: RequestLane): boolean {
+    const { total, slow } = this.limits();
+    if (total > 0 && this.inFlight.fast + this.inFlight.slow >= total) {
+      return false;
+    }
+    // Slow requests never take every slot, so a fast one always gets through
+    const slowLimit = total > 1 && (slow === 0 || slow >= total) ? total - 1 : slow;
+    return lane === 'fast' || slowLimit === 0 || this.inFlight.slow < slowLimit;
+  }
+
+  /**
+   * Start waiting requests while there is room, fast ones first
+   */
+  private dispatch(): void {
+    for (const lane of ['fast', 'slow'] as RequestLane[]) {
+      while (this.waiting[lane].length > 0 && this.hasRoom(lane)) {
+        this.inFlight[lane]++;
+        this.waiting[lane].shift()!();
+      }
+    }
+  }
+}
@@ -4,6 +4,7 @@
 
 import { Readable, Writable } from 'stream';
 import { createLogger, Component } from '../logging/logger.js';
+import { ToolError } from '../workspace/errors.js';
 
 const lspLogger = createLogger(Component.LSP);
 const wireLogger = createLogger(Component.WIRE);
@@ -128,7 +129,7 @@ export class MessageReader {
       };
 
       const onEnd = () => {
-        reject(new Error('LSP connection closed (EOF)'));
+        reject(new ToolError('lsp-unavailable', 'LSP connection closed (EOF)'));
       };
 
       const onError = (err: Error) => {
@@ -0,0 +1,129 @@
+/**
+ * Metrics and health endpoints
+ * MCP runs over stdio, so metrics and health checks are served by a
+ * separate HTTP listener that is only started when METRICS_PORT is set
+ */
+
+import * as http from 'http';
+import { AddressInfo } from 'net';
+import { createLogger, Component } from '../logging/logger.js';
+import { MetricsRegistry } from './metrics.js';
+
+const metricsLogger = createLogger(Component.CORE);
+
+/**
+ * Content type of the text exposition format
+ */
+const CONTENT_TYPE = 'text/plain; version=0.0.4; charset=utf-8';
+
+/**
+ * Result of one health check
+ */
+export interface HealthCheck {
+  ok: boolean;
+  message: string;
+}
+
+/**
+ * Health of the server: healthy when every check passes
+ */
+export interface HealthReport {
+  status: 'ok' | 'unhealthy';
+  checks: Record<string, HealthCheck>;
+}
+
+/**
+ * Runs the health checks
+ */
+export type HealthProbe = () => Promise<HealthReport>;
+
+/**
+ * Build a report from named checks
+ */
+export function healthReport(checks: Record<string, HealthCheck>): HealthReport {
+  return { status: Object.values(checks).every((check) => check.ok) ? 'ok' : 'unhealthy', checks };
+}
+
+/**
+ * Listener address from METRICS_PORT and METRICS_HOST (default: 127.0.0.1)
+ * Returns undefined when METRICS_PORT is unset
+ */
+export function metricsAddressFromEnv(env: NodeJS.ProcessEnv = process.env): { port: number; host: string } | undefined {
+  if (!env.METRICS_PORT) {
+    return undefined;
+  }
+  const port = parseInt(env.METRICS_PORT, 10);
+  if (Number.isNaN(port) || port < 0 || port > 65535) {
+    throw new Error(`METRICS_PORT must be a port number, got ${env.METRICS_PORT}`);
+  }
+  return { port, host: env.METRICS_HOST || '127.0.0.1' };
+}
+
+/**
+ * Answer GET /healthz: 200 when healthy, 503 otherwise, with the report as JSON
+ */
+async function serveHealth(health: HealthProbe, req: http.IncomingMessage, res: http.ServerResponse): Promise<void> {
+  let report: HealthReport;
+  try {
+    report = await health();
+  } catch (err) {
+    report = healthReport({ probe: { ok: false, message: (err as Error).message } });
+  }
+  if (report.status !== 'ok') {
+    metricsLogger.warn('Health check failed: %j', report.checks);
+  }
+  res.writeHead(report.status === 'ok' ? 200 : 503, { 'Content-Type': 'application/json' });
+  res.end(req.method === 'HEAD' ? undefined : JSON.stringify(report) + '\n');
+}
+
+/**
+ * Answer GET /metrics in the text exposition format
+ */
+function serveMetrics(registry: MetricsRegistry, req: http.IncomingMessage, res: http.ServerResponse): void {
+  try {
+    const body = registry.render();
+    res.writeHead(200, { 'Content-Type': CONTENT_TYPE });
+    res.end(req.method === 'HEAD' ? undefined : body);
+  } catch (err) {
+    metricsLogger.error('Failed to render metrics: %s', (err as Error).message);
+    res.writeHead(500, { 'Content-Type': 'text/plain' }).end('Failed to render metrics\n');
+  }
+}
+
+/**
+ * Serve GET /metrics, and GET /healthz when a health probe is given; port 0 picks a free port
+ */
+export function startMetricsServer(
+  registry: MetricsRegistry,
+  port: number,
+  host = '127.0.0.1',
+  health?: HealthProbe
+): Promise<http.Server> {
+  const server = http.createServer((req, res) => {
+    const url = new URL(req.url ?? '/', 'http://localhost');
+    const route = url.pathname === '/metrics' || (url.pathname === '/healthz' && health) ? url.pathname : undefined;
+    if (!route) {
+      res.writeHead(404, { 'Content-Type': 'text/plain' }).end('Not found\n');
+      return;
+    }
+    if (req.method !== 'GET' && req.method !== 'HEAD') {
+      res.writeHead(405, { 'Content-Type': 'text/plain', Allow: 'GET, HEAD' }).end('Method not allowed\n');
+      return;
+    }
+    if (route === '/healthz') {
+      void serveHealth(health!, req, res);
+    } else {
+      serveMetrics(registry, req, res);
+    }
+  });
+
+  return new Promise((resolve, reject) => {
+    server.once('error', reject);
+    server.listen(port, host, () => {
+      server.off('error', reject);
+      const address = server.address() as AddressInfo;
+      metricsLogger.info('Metrics available at http://%s:%d/metrics', address.address, address.port);
+      resolve(server);
+    });
+  });
+}
@@ -0,0 +1,105 @@
+/**
+ * Tests for the Prometheus metrics registry and the metrics and health endpoints
+ */
+
+import * as http from 'http';
+import { AddressInfo } from 'net';
+import { MetricsRegistry } from './metrics';
+import { healthReport, metricsAddressFromEnv, startMetricsServer } from './http';
+
+function get(port: number, urlPath: string): Promise<{ status: number; type?: string; body: string }> {
+  return new Promise((resolve, reject) => {
+    http.get({ host: '127.0.0.1', port, path: urlPath }, (res) => {
+      let body = '';
+      res.on('data', (chunk) => (body += chunk));
+      res.on('end', () => resolve({ status: res.statusCode ?? 0, type: res.headers['content-type'], body }));
+    }).on('error', reject);
+  });
+}
+
+describe('Metrics', () => {
+  it('should render counters and gauges with labels', () => {
+    const registry = new MetricsRegistry();
+    const calls = registry.counter('calls_total', 'Calls');
+    calls.inc({ tool: 'search_code', status: 'ok' });
+    calls.inc({ status: 'ok', tool: 'search_code' }, 2);
+    calls.inc({ tool: 'hover', status: 'error' });
+    registry.gauge('sizes', 'Sizes', () => [{ labels: { kind: 'a"b' }, value: 3 }]);
+    registry.gauge('ratio', 'Ratio', () => 0.5);
+
+    expect(calls.get({ tool: 'search_code', status: 'ok' })).toBe(3);
+    expect(registry.render()).toBe([
+      '# HELP calls_total Calls',
+      '# TYPE calls_total counter',
+      'calls_total{tool="search_code",status="ok"} 3',
+      'calls_total{tool="hover",status="error"} 1',
+      '# HELP sizes Sizes',
+      '# TYPE sizes gauge',
+      'sizes{kind="a\\"b"} 3',
+      '# HELP ratio Ratio',
+      '# TYPE ratio gauge',
+      'ratio 0.5',
+      '',
+    ].join('\n'));
+  });
+
+  it('should count histogram observations into cumulative buckets', () => {
+    const registry = new MetricsRegistry();
+    const latency = registry.histogram('latency_seconds', 'Latency', [0.1, 1]);
+    latency.observe({ kind: 'literal' }, 0.05);
+    latency.observe({ kind: 'literal' }, 0.5);
+    latency.observe({ kind: 'literal' }, 3);
+
+    expect(latency.count({ kind: 'literal' })).toBe(3);
+    const text = registry.render();
+    expect(text).toContain('latency_seconds_bucket{kind="literal",le="0.1"} 1');
+    expect(text).toContain('latency_seconds_bucket{kind="literal",le="1"} 2');
+    expect(text).toContain('latency_seconds_bucket{kind="literal",le="+Inf"} 3');
+    expect(text).toContain('latency_seconds_sum{kind="literal"} 3.55');
+    expect(text).toContain('latency_seconds_count{kind="literal"} 3');
+  });
+
+  it('should read the listener address from the environment', () => {
+    expect(metricsAddressFromEnv({})).toBeUndefined();
+    expect(metricsAddressFromEnv({ METRICS_PORT: '9464' })).toEqual({ port: 9464, host: '127.0.0.1' });
+    expect(metricsAddressFromEnv({ METRICS_PORT: '9464', METRICS_HOST: '0.0.0.0' })).toEqual({ port: 9464, host: '0.0.0.0' });
+    expect(() => metricsAddressFromEnv({ METRICS_PORT: 'high' })).toThrow('METRICS_PORT must be a port number');
+  });
+
+  it('should serve /metrics over HTTP', async () => {
+    const registry = new MetricsRegistry();
+    registry.counter('calls_total', 'Calls').inc();
+    const server = await startMetricsServer(registry, 0);
+    try {
+      const { port } = server.address() as AddressInfo;
+      const metrics = await get(port, '/metrics');
+      expect(metrics.status).toBe(200);
+      expect(metrics.type).toContain('text/plain; version=0.0.4');
+      expect(metrics.body).toContain('calls_total 1');
+      expect((await get(port, '/other')).status).toBe(404);
+    } finally {
+      await new Promise((resolve) => server.close(resolve));
+    }
+  });
+
+  it('should serve /healthz with 503 while a check fails', async () => {
+    let lsp = { ok: false, message: 'process exited' };
+    const server = await startMetricsServer(new MetricsRegistry(), 0, '127.0.0.1',
+      async () => healthReport({ server: { ok: true, message: 'running' }, lsp }));
+    try {
+      const { port } = server.address() as AddressInfo;
+      const failing = await get(port, '/healthz');
+      expect(failing.status).toBe(503);
+      expect(JSON.parse(failing.body)).toEqual({
+        status: 'unhealthy',
+        checks: { server: { ok: true, message: 'running' }, lsp: { ok: false, message: 'process exited' } },
+      });
+      lsp = { ok: true, message: 'responded in 3ms' };
+      const passing = await get(port, '/healthz');
+      expect(passing.status).toBe(200);
+      expect(JSON.parse(passing.body).status).toBe('ok');
+    } finally {
+      await new Promise((resolve) => server.close(resolve));
+    }
+  });
+});
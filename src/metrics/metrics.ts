@@ -0,0 +1,228 @@
+/**
+ * Prometheus metrics
+ * A small registry of counters, gauges, and histograms rendered in the
+ * Prometheus text exposition format. Values that already live elsewhere
+ * (index sizes, cache statistics) are read through collect callbacks at
+ * scrape time instead of being copied on every change
+ */
+
+/**
+ * Label names and values of one series
+ */
+export type Labels = Record<string, string>;
+
+/**
+ * Reads the current value of every series of a metric
+ */
+export type Collector = () => number | Array<{ labels: Labels; value: number }>;
+
+type MetricType = 'counter' | 'gauge' | 'histogram';
+
+/**
+ * Default histogram buckets in seconds, from 5ms to 30s
+ */
+export const DEFAULT_BUCKETS = [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30];
+
+function escapeLabel(value: string): string {
+  return value.replace(/\\/g, '\\\\').replace(/"/g, '\\"').replace(/\n/g, '\\n');
+}
+
+/**
+ * Render labels as {a="1",b="2"}, or nothing when there are none
+ */
+function formatLabels(labels: Labels): string {
+  const entries = Object.entries(labels);
+  return entries.length === 0 ? '' : `{${entries.map(([key, value]) => `${key}="${escapeLabel(value)}"`).join(',')}}`;
+}
+
+function formatValue(value: number): string {
+  if (value === Infinity) {
+    return '+Inf';
+  }
+  return Number.isNaN(value) ? 'NaN' : String(value);
+}
+
+/**
+ * Series key: labels in name order, so the same labels always find the same series
+ */
+function seriesKey(labels: Labels): string {
+  return JSON.stringify(Object.keys(labels).sort().map((key) => [key, labels[key]]));
+}
+
+abstract class Metric {
+  constructor(readonly name: string, readonly help: string, readonly type: MetricType) {}
+
+  render(): string {
+    return [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} ${this.type}`, ...this.samples()].join('\n');
+  }
+
+  protected abstract samples(): string[];
+}
+
+/**
+ * Value per label set, set directly or read from a collector
+ */
+class ValueMetric extends Metric {
+  private values = new Map<string, { labels: Labels; value: number }>();
+
+  constructor(name: string, help: string, type: 'counter' | 'gauge', private collector?: Collector) {
+    super(name, help, type);
+  }
+
+  protected add(labels: Labels, value: number): void {
+    const key = seriesKey(labels);
+    const series = this.values.get(key);
+    if (series) {
+      series.value += value;
+    } else {
+      this.values.set(key, { labels, value });
+    }
+  }
+
+  protected assign(labels: Labels, value: number): void {
+    this.values.set(seriesKey(labels), { labels, value });
+  }
+
+  /**
+   * Current value of a series (0 when it was never set)
+   */
+  get(labels: Labels = {}): number {
+    return this.values.get(seriesKey(labels))?.value ?? 0;
+  }
+
+  protected samples(): string[] {
+    let series = Array.from(this.values.values());
+    if (this.collector) {
+      const collected = this.collector();
+      series = typeof collected === 'number' ? [{ labels: {}, value: collected }] : collected;
+    }
+    return series.map(({ labels, value }) => `${this.name}${formatLabels(labels)} ${formatValue(value)}`);
+  }
+}
+
+/**
+ * Monotonic count
+ */
+export class Counter extends ValueMetric {
+  constructor(name: string, help: string, collector?: Collector) {
+    super(name, help, 'counter', collector);
+  }
+
+  inc(labels: Labels = {}, value = 1): void {
+    this.add(labels, value);
+  }
+}
+
+/**
+ * Value that can go up and down
+ */
+export class Gauge extends ValueMetric {
+  constructor(name: string, help: string, collector?: Collector) {
+    super(name, help, 'gauge', collector);
+  }
+
+  set(labels: Labels, value: number): void {
+    this.assign(labels, value);
+  }
+}
+
+interface HistogramSeries {
+  labels: Labels;
+  counts: number[];
+  sum: number;
+  count: number;
+}
+
+/**
+ * Distribution of observed values over fixed buckets
+ */
+export class Histogram extends Metric {
+  private series = new Map<string, HistogramSeries>();
+
+  constructor(name: string, help: string, readonly buckets: number[] = DEFAULT_BUCKETS) {
+    super(name, help, 'histogram');
+  }
+
+  observe(labels: Labels, value: number): void {
+    const key = seriesKey(labels);
+    let series = this.series.get(key);
+    if (!series) {
+      series = { labels, counts: this.buckets.map(() => 0), sum: 0, count: 0 };
+      this.series.set(key, series);
+    }
+    this.buckets.forEach((bound, i) => {
+      if (value <= bound) {
+        series!.counts[i]++;
+      }
+    });
+    series.sum += value;
+    series.count++;
+  }
+
+  /**
+   * Number of observations of a series
+   */
+  count(labels: Labels = {}): number {
+    return this.series.get(seriesKey(labels))?.count ?? 0;
+  }
+
+  protected samples(): string[] {
+    const lines: string[] = [];
+    for (const { labels, counts, sum, count } of this.series.values()) {
+      this.buckets.forEach((bound, i) => {
+        lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: formatValue(bound) })} ${counts[i]}`);
+      });
+      lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: '+Inf' })} ${count}`);
+      lines.push(`${this.name}_sum${formatLabels(labels)} ${sum}`);
+      lines.push(`${this.name}_count${formatLabels(labels)} ${count}`);
+    }
+    return lines;
+  }
+}
+
+/**
+ * A set of metrics rendered together
+ */
+export class MetricsRegistry {
+  private metrics = new Map<string, Metric>();
+
+  counter(name: string, help: string, collector?: Collector): Counter {
+    return this.register(new Counter(name, help, collector));
+  }
+
+  gauge(name: string, help: string, collector?: Collector): Gauge {
+    return this.register(new Gauge(name, help, collector));
+  }
+
+  histogram(name: string, help: string, buckets?: number[]): Histogram {
+    return this.register(new Histogram(name, help, buckets));
+  }
+
+  /**
+   * Add a metric, replacing any earlier one with the same name
+   */
+  register<T extends Metric>(metric: T): T {
+    this.metrics.set(metric.name, metric);
+    return metric;
+  }
+
+  /**
+   * Render every metric in the text exposition format
+   */
+  render(): string {
+    return Array.from(this.metrics.values()).map((metric) => metric.render()).join('\n') + '\n';
+  }
+}
+
+/**
+ * Registry served by the metrics endpoint
+ */
+export const registry = new MetricsRegistry();
+
+export const toolCalls = registry.counter('grepforcode_tool_calls_total', 'Tool invocations by tool and status');
+export const toolDuration = registry.histogram('grepforcode_tool_duration_seconds', 'Tool call latency by tool');
+export const searchDuration = registry.histogram(
+  'grepforcode_search_duration_seconds', 'search_code engine latency by kind (literal or regex), excluding cached results');
+export const searchFilesScanned = registry.counter('grepforcode_search_files_scanned_total', 'Files read by search_code');
+export const lspExits = registry.counter('grepforcode_lsp_exits_total', 'Language server process exits');
+export const lspRestarts = registry.counter('grepforcode_lsp_restarts_total', 'Language server restarts');
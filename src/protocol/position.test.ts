@@ -0,0 +1,55 @@
+/**
+ * Tests for position encoding conversion
+ */
+
+import {
+  parsePositionEncoding,
+  toEncodedCharacter,
+  fromEncodedCharacter,
+  convertRange,
+} from './position';
+
+describe('Position encoding', () => {
+  // "😀" is one code point, two UTF-16 units, and four UTF-8 bytes; "世" is one unit and three bytes
+  const line = 'a😀世 = f(x)';
+  const fUtf16 = line.indexOf('f');
+
+  it('should parse negotiated encodings', () => {
+    expect(parsePositionEncoding('utf-8')).toBe('utf-8');
+    expect(parsePositionEncoding('utf8')).toBe('utf-8');
+    expect(parsePositionEncoding('utf-32')).toBe('utf-32');
+    expect(parsePositionEncoding(undefined)).toBe('utf-16');
+  });
+
+  it('should convert UTF-16 offsets to server units', () => {
+    expect(fUtf16).toBe(7);
+    expect(toEncodedCharacter(line, fUtf16, 'utf-8')).toBe(11);
+    expect(toEncodedCharacter(line, fUtf16, 'utf-32')).toBe(6);
+    expect(toEncodedCharacter(line, fUtf16, 'utf-16')).toBe(7);
+  });
+
+  it('should convert server units back to UTF-16 offsets', () => {
+    expect(fromEncodedCharacter(line, 11, 'utf-8')).toBe(fUtf16);
+    expect(fromEncodedCharacter(line, 6, 'utf-32')).toBe(fUtf16);
+    // Offsets inside a character snap to its start; offsets past the end clamp
+    expect(fromEncodedCharacter(line, 3, 'utf-8')).toBe(1);
+    expect(fromEncodedCharacter(line, 100, 'utf-8')).toBe(line.length);
+  });
+
+  it('should round-trip every character boundary', () => {
+    for (let i = 0; i <= line.length; i++) {
+      if (i === 2) {
+        continue; // Inside the surrogate pair
+      }
+      for (const encoding of ['utf-8', 'utf-32'] as const) {
+        expect(fromEncodedCharacter(line, toEncodedCharacter(line, i, encoding), encoding)).toBe(i);
+      }
+    }
+  });
+
+  it('should convert ranges line by line, ignoring carriage returns', () => {
+    const lines = ['plain\r', 'é = 1'];
+    const range = convertRange(lines, { start: { line: 0, character: 5 }, end: { line: 1, character: 3 } }, 'utf-8', 'toServer');
+    expect(range).toEqual({ start: { line: 0, character: 5 }, end: { line: 1, character: 4 } });
+  });
+});
@@ -0,0 +1,126 @@
+/**
+ * Position encoding conversion
+ * Tools count columns in UTF-16 code units, like JavaScript strings; language
+ * servers may count UTF-8 bytes or code points instead, as negotiated at
+ * initialization
+ */
+
+import { Position, Range } from './types.js';
+
+/**
+ * Position encodings defined by LSP 3.17
+ */
+export type PositionEncoding = 'utf-8' | 'utf-16' | 'utf-32';
+
+/**
+ * Encodings offered to the server, in order of preference
+ * UTF-16 needs no conversion, so it comes first
+ */
+export const SUPPORTED_POSITION_ENCODINGS: PositionEncoding[] = ['utf-16', 'utf-8', 'utf-32'];
+
+/**
+ * Normalize an encoding name reported by a server, defaulting to UTF-16
+ * Accepts the clangd offsetEncoding spelling (e.g. "utf8") as well
+ */
+export function parsePositionEncoding(value: unknown): PositionEncoding {
+  if (typeof value !== 'string') {
+    return 'utf-16';
+  }
+  switch (value.toLowerCase().replace(/-/g, '')) {
+    case 'utf8':
+      return 'utf-8';
+    case 'utf32':
+      return 'utf-32';
+    default:
+      return 'utf-16';
+  }
+}
+
+/**
+ * Units a code point takes in an encoding
+ */
+function codePointUnits(codePoint: number, encoding: PositionEncoding): number {
+  switch (encoding) {
+    case 'utf-8':
+      return codePoint < 0x80 ? 1 : codePoint < 0x800 ? 2 : codePoint < 0x10000 ? 3 : 4;
+    case 'utf-16':
+      return codePoint < 0x10000 ? 1 : 2;
+    case 'utf-32':
+      return 1;
+  }
+}
+
+/**
+ * Convert a UTF-16 offset within a line to the given encoding
+ * Offsets past the end of the line are clamped to it
+ */
+export function toEncodedCharacter(lineText: string, character: number, encoding: PositionEncoding): number {
+  if (encoding === 'utf-16') {
+    return character;
+  }
+  let units = 0;
+  let i = 0;
+  while (i < Math.min(character, lineText.length)) {
+    const codePoint = lineText.codePointAt(i)!;
+    units += codePointUnits(codePoint, encoding);
+    i += codePoint >= 0x10000 ? 2 : 1;
+  }
+  return units;
+}
+
+/**
+ * Convert an offset in the given encoding within a line to UTF-16
+ * An offset inside a multi-unit character resolves to the character's start
+ */
+export function fromEncodedCharacter(lineText: string, character: number, encoding: PositionEncoding): number {
+  if (encoding === 'utf-16') {
+    return character;
+  }
+  let units = 0;
+  let i = 0;
+  while (i < lineText.length) {
+    const codePoint = lineText.codePointAt(i)!;
+    const width = codePointUnits(codePoint, encoding);
+    if (units + width > character) {
+      break;
+    }
+    units += width;
+    i += codePoint >= 0x10000 ? 2 : 1;
+  }
+  return i;
+}
+
+/**
+ * Convert a position between UTF-16 and a server encoding
+ */
+export function convertPosition(
+  lines: string[],
+  position: Position,
+  encoding: PositionEncoding,
+  direction: 'toServer' | 'fromServer'
+): Position {
+  if (encoding === 'utf-16') {
+    return position;
+  }
+  // Line endings are not part of the line
+  const lineText = (lines[position.line] ?? '').replace(/\r$/, '');
+  const character = direction === 'toServer'
+    ? toEncodedCharacter(lineText, position.character, encoding)
+    : fromEncodedCharacter(lineText, position.character, encoding);
+  return { line: position.line, character };
+}
+
+/**
+ * Convert a range between UTF-16 and a server encoding
+ */
+export function convertRange(
+  lines: string[],
+  range: Range,
+  encoding: PositionEncoding,
+  direction: 'toServer' | 'fromServer'
+): Range {
+  return {
+    start: convertPosition(lines, range.start, encoding, direction),
+    end: convertPosition(lines, range.end, encoding, direction),
+  };
+}
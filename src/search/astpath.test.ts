@@ -0,0 +1,117 @@
+/**
+ * Tests for match syntax paths
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { astPathAt, attachAstPaths, grammarPackages, nodePath, PathNode, PathRoot } from './astpath';
+import { LexicalMatch } from './lexical';
+import { formatMatchSections } from '../tools/search';
+
+/**
+ * Build a chain of nodes the way tree-sitter links them, root first
+ */
+function chain(...types: Array<[string, boolean]>): PathNode {
+  let parent: PathNode | null = null;
+  for (const [type, isNamed] of types) {
+    parent = { type, isNamed, parent };
+  }
+  return parent!;
+}
+
+describe('AST paths', () => {
+  // function load() { if (ready) { fetch(url); } }
+  const leaf = chain(['program', true], ['function_declaration', true], ['statement_block', true], ['{', false],
+    ['if_statement', true], ['statement_block', true], ['expression_statement', true], ['call_expression', true]);
+
+  it('should list the named nodes below the root, outermost first', () => {
+    expect(nodePath(leaf)).toEqual(['function_declaration', 'statement_block', 'if_statement', 'statement_block',
+      'expression_statement', 'call_expression']);
+    expect(nodePath(chain(['program', true]))).toEqual([]);
+  });
+
+  it('should look up the node covering a match', () => {
+    const positions: unknown[] = [];
+    const root: PathRoot = {
+      type: 'program',
+      isNamed: true,
+      parent: null,
+      namedDescendantForPosition: (start, end) => {
+        positions.push([start, end]);
+        return leaf;
+      },
+    };
+    expect(astPathAt(root, 3, 5, 5)).toHaveLength(6);
+    expect(positions).toEqual([[{ row: 2, column: 4 }, { row: 2, column: 9 }]]);
+  });
+
+  it('should convert UTF-16 columns to bytes for a parser counting bytes', () => {
+    const positions: unknown[] = [];
+    const root: PathRoot = {
+      type: 'program',
+      isNamed: true,
+      parent: null,
+      namedDescendantForPosition: (start, end) => {
+        positions.push([start.column, end.column]);
+        return leaf;
+      },
+    };
+    // "é" is one UTF-16 code unit and two bytes, "😀" two code units and four bytes
+    const lineText = 's = "é😀"; fetch(url);';
+    const column = lineText.indexOf('fetch') + 1;
+    astPathAt(root, 1, column, 5, lineText, 'utf16');
+    astPathAt(root, 1, column, 5, lineText, 'utf8');
+    expect(positions).toEqual([[column - 1, column + 4], [column + 2, column + 7]]);
+  });
+
+  // Runs where the optional tree-sitter packages are installed
+  const typescriptGrammar = (() => {
+    try {
+      require.resolve('tree-sitter');
+      require.resolve('tree-sitter-typescript');
+      return true;
+    } catch {
+      return false;
+    }
+  })();
+  (typescriptGrammar ? it : it.skip)('should find the path of a match after non-ASCII text in a real parse', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'astpath-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'load.ts'), 'function load() {\n  const s = "éééééééééé😀😀😀"; if (s) { fetch(s); }\n}\n');
+      const lineText = '  const s = "éééééééééé😀😀😀"; if (s) { fetch(s); }';
+      const match: LexicalMatch = { filePath: 'load.ts', line: 2, column: lineText.indexOf('fetch') + 1, length: 5, lineText };
+      expect(await attachAstPaths(workspace, [match])).toEqual([]);
+      expect(match.astPath).toEqual(['function_declaration', 'statement_block', 'if_statement', 'statement_block',
+        'expression_statement', 'call_expression', 'identifier']);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should name the packages a language needs', () => {
+    expect(grammarPackages('go')).toBe('tree-sitter and tree-sitter-go');
+    expect(grammarPackages('plaintext')).toBeUndefined();
+  });
+
+  it('should show the path under each match', () => {
+    const match: LexicalMatch = {
+      filePath: 'src/load.ts', line: 3, column: 5, length: 5, lineText: '    fetch(url);',
+      astPath: ['function_declaration', 'if_statement', 'call_expression'],
+    };
+    expect(formatMatchSections([match])).toContain('L3:C5: fetch(url);\n  AST: function_declaration > if_statement > call_expression\n');
+    expect(formatMatchSections([match], undefined, { format: 'plain', fields: ['column'], context: 0 })).not.toContain('AST:');
+  });
+
+  it('should leave files without a grammar alone', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'astpath-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'notes.txt'), 'fetch the data\n');
+      const match: LexicalMatch = { filePath: 'notes.txt', line: 1, column: 1, length: 5, lineText: 'fetch the data' };
+      expect(await attachAstPaths(workspace, [match])).toEqual([]);
+      expect(match.astPath).toBeUndefined();
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
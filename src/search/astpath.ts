@@ -0,0 +1,191 @@
+/**
+ * Syntax paths of matches - the chain of tree-sitter nodes a match sits in,
+ * e.g. function_declaration > statement_block > if_statement > call_expression
+ * Lets callers keep only the matches in a call, a condition, or a class body
+ * without parsing the files again
+ *
+ * tree-sitter and the grammar packages are loaded lazily, as for JSX search,
+ * so each language's path is only reported when its grammar is installed.
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { LexicalMatch } from './lexical.js';
+import { MAX_PARSE_SIZE, TREE_SITTER_MODULE, TYPESCRIPT_GRAMMAR_MODULE } from './jsx.js';
+
+const astLogger = createLogger(Component.TOOLS);
+
+/**
+ * Grammar packages per language, the first installed one is used; the
+ * second name picks a grammar out of a package that exports several
+ */
+const GRAMMARS: Record<string, Array<[string, string?]>> = {
+  typescript: [[TYPESCRIPT_GRAMMAR_MODULE, 'typescript']],
+  typescriptreact: [[TYPESCRIPT_GRAMMAR_MODULE, 'tsx']],
+  // The TSX grammar parses plain JavaScript too
+  javascript: [['tree-sitter-javascript'], [TYPESCRIPT_GRAMMAR_MODULE, 'tsx']],
+  javascriptreact: [['tree-sitter-javascript'], [TYPESCRIPT_GRAMMAR_MODULE, 'tsx']],
+  python: [['tree-sitter-python']],
+  go: [['tree-sitter-go']],
+  rust: [['tree-sitter-rust']],
+  java: [['tree-sitter-java']],
+  c: [['tree-sitter-c']],
+  cpp: [['tree-sitter-cpp']],
+  csharp: [['tree-sitter-c-sharp']],
+  ruby: [['tree-sitter-ruby']],
+  shell: [['tree-sitter-bash']],
+};
+
+/**
+ * The parts of a tree-sitter node the path is read from
+ */
+export interface PathNode {
+  type: string;
+  isNamed: boolean;
+  parent: PathNode | null;
+}
+
+/**
+ * Root of a parsed file
+ */
+export interface PathRoot extends PathNode {
+  namedDescendantForPosition(start: { row: number; column: number }, end: { row: number; column: number }): PathNode;
+}
+
+/**
+ * What a parser's point columns count: UTF-8 bytes, as the tree-sitter
+ * library does, or UTF-16 code units, as bindings parsing JavaScript strings do
+ */
+export type ColumnUnit = 'utf8' | 'utf16';
+
+/**
+ * Column unit of a parser, from a one-character input two UTF-8 bytes long
+ */
+function columnUnit(parser: any): ColumnUnit {
+  try {
+    return parser.parse('\u00e9').rootNode.endPosition.column === 2 ? 'utf8' : 'utf16';
+  } catch (err) {
+    astLogger.debug('Could not tell the column unit of the parser, taking UTF-16: %s', err);
+    return 'utf16';
+  }
+}
+
+/**
+ * Named node types from below the root down to the node, outermost first
+ */
+export function nodePath(node: PathNode): string[] {
+  const types: string[] = [];
+  for (let current: PathNode | null = node; current?.parent; current = current.parent) {
+    if (current.isNamed) {
+      types.unshift(current.type);
+    }
+  }
+  return types;
+}
+
+/**
+ * Path of the smallest named node covering a match
+ * The match's 1-indexed column and length count UTF-16 code units, as LSP
+ * positions do; for a parser counting UTF-8 bytes they are converted with
+ * the full text of the match's line
+ */
+export function astPathAt(
+  root: PathRoot,
+  line: number,
+  column: number,
+  length: number,
+  lineText = '',
+  unit: ColumnUnit = 'utf16'
+): string[] {
+  let startColumn = column - 1;
+  let endColumn = startColumn + Math.max(length, 1);
+  if (unit === 'utf8') {
+    startColumn = Buffer.byteLength(lineText.substring(0, column - 1));
+    endColumn = startColumn + Math.max(Buffer.byteLength(lineText.substr(column - 1, length)), 1);
+  }
+  return nodePath(root.namedDescendantForPosition({ row: line - 1, column: startColumn }, { row: line - 1, column: endColumn }));
+}
+
+/**
+ * Parsers per language with the unit of their columns, created on first use; null when no grammar is installed
+ */
+const parsers = new Map<string, { parser: any; unit: ColumnUnit } | null>();
+
+function loadParser(languageId: string): { parser: any; unit: ColumnUnit } | null {
+  if (parsers.has(languageId)) {
+    return parsers.get(languageId);
+  }
+  let parser: any | null = null;
+  for (const [moduleName, property] of GRAMMARS[languageId] ?? []) {
+    try {
+      const Parser = require(TREE_SITTER_MODULE);
+      const grammar = require(moduleName);
+      parser = new Parser();
+      parser.setLanguage(property ? grammar[property] : grammar);
+      astLogger.info('Loaded the tree-sitter grammar for %s from %s', languageId, moduleName);
+      break;
+    } catch (err) {
+      astLogger.debug('No tree-sitter grammar for %s in %s: %s', languageId, moduleName, err);
+      parser = null;
+    }
+  }
+  const loaded = parser && { parser, unit: columnUnit(parser) };
+  parsers.set(languageId, loaded);
+  return loaded;
+}
+
+/**
+ * Packages to install for a language's paths, e.g. "tree-sitter and tree-sitter-go"
+ */
+export function grammarPackages(languageId: string): string | undefined {
+  const grammar = GRAMMARS[languageId]?.[0]?.[0];
+  return grammar && `${TREE_SITTER_MODULE} and ${grammar}`;
+}
+
+/**
+ * Set the syntax path of each match whose language has an installed grammar
+ * Returns the languages matched in that have none, so the caller can say why paths are missing
+ */
+export async function attachAstPaths(workspaceDir: string, matches: LexicalMatch[]): Promise<string[]> {
+  const missing = new Set<string>();
+  const byFile = new Map<string, LexicalMatch[]>();
+  for (const match of matches) {
+    // Archive entries, notebook cells, and binary matches have no parseable file of their own
+    if (match.filePath.includes('!/') || match.cell || match.byteOffset !== undefined) {
+      continue;
+    }
+    byFile.set(match.filePath, [...(byFile.get(match.filePath) ?? []), match]);
+  }
+  for (const [filePath, fileMatches] of byFile) {
+    const languageId = detectLanguageId(filePath);
+    if (!GRAMMARS[languageId]) {
+      continue;
+    }
+    const loaded = loadParser(languageId);
+    if (!loaded) {
+      missing.add(languageId);
+      continue;
+    }
+    let root: PathRoot;
+    let lines: string[];
+    try {
+      const content = await readFileText(path.join(workspaceDir, filePath));
+      if (content.length > MAX_PARSE_SIZE) {
+        continue;
+      }
+      // The default input buffer is too small for large files
+      root = loaded.parser.parse(content, undefined, { bufferSize: content.length * 2 + 1 }).rootNode;
+      lines = content.split('\n');
+    } catch (err) {
+      astLogger.debug('Could not parse %s for syntax paths: %s', filePath, err);
+      continue;
+    }
+    for (const match of fileMatches) {
+      // Clipped lines keep the column in the full line, which is converted rather than lineText
+      match.astPath = astPathAt(root, match.line, match.column, match.length, lines[match.line - 1] ?? '', loaded.unit);
+    }
+  }
+  return [...missing].sort();
+}
@@ -0,0 +1,58 @@
+/**
+ * Tests for the search benchmark
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { BENCH_QUERIES, formatBenchReport, percentile, runBenchmark } from './bench';
+
+describe('Search benchmark', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'bench-'));
+    fs.mkdirSync(path.join(workspace, 'src'));
+    fs.writeFileSync(path.join(workspace, 'src', 'a.ts'), 'export function run(options) {\n  return options; // TODO\n}\n');
+    fs.writeFileSync(path.join(workspace, 'src', 'b.py'), 'def main():\n    return "a string long enough to match"\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should take percentiles by nearest rank', () => {
+    const samples = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10];
+    expect(percentile(samples, 50)).toBe(5);
+    expect(percentile(samples, 90)).toBe(9);
+    expect(percentile(samples, 99)).toBe(10);
+    expect(percentile([7], 50)).toBe(7);
+    expect(percentile([], 50)).toBe(0);
+  });
+
+  it('should run the standard suite in both modes with the same matches', async () => {
+    const report = await runBenchmark(workspace, { iterations: 2 });
+    expect(report).toMatchObject({ files: 2, iterations: 2, indexedFiles: 2 });
+    expect(report.modes.map((mode) => mode.mode)).toEqual(['scan', 'index']);
+    const [scan, indexed] = report.modes;
+    expect(scan.queries.map((query) => query.name)).toEqual(BENCH_QUERIES.map((query) => query.name));
+    expect(indexed.queries.map((query) => query.matches)).toEqual(scan.queries.map((query) => query.matches));
+    expect(scan.queries.find((query) => query.name === 'common literal')!.matches).toBe(2);
+    expect(scan.queries.find((query) => query.name === 'rare literal')!.matches).toBe(0);
+    for (const mode of report.modes) {
+      expect(mode.p50Ms).toBeLessThanOrEqual(mode.p90Ms);
+      expect(mode.p90Ms).toBeLessThanOrEqual(mode.p99Ms);
+    }
+    expect(report.peakHeapMb).toBeGreaterThan(0);
+  });
+
+  it('should format a table per mode', async () => {
+    const report = await runBenchmark(workspace, { iterations: 1, queries: [{ name: 'todo', pattern: 'TODO', options: {} }] });
+    const text = formatBenchReport(report);
+    expect(text).toContain('Files: 2, iterations per query: 1');
+    expect(text).toContain('Scan only');
+    expect(text).toContain('Trigram index\n');
+    expect(text).toMatch(/\ntodo {14}.*\s1\n/);
+    expect(text).toContain('Memory: peak heap');
+  });
+});
@@ -0,0 +1,206 @@
+/**
+ * Search benchmark
+ * Runs a fixed suite of queries against a workspace, once scanning every
+ * file and once through the trigram index, and reports latency percentiles,
+ * scan throughput, and memory use
+ */
+
+import { searchLexical, LexicalSearchOptions } from './lexical.js';
+import { TrigramIndex } from './trigram.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+
+/**
+ * A benchmark query
+ */
+export interface BenchQuery {
+  name: string;
+  pattern: string;
+  options: LexicalSearchOptions;
+}
+
+/**
+ * Standard query suite: common and rare literals, case and word variants,
+ * and regular expressions, which the trigram index cannot narrow
+ */
+export const BENCH_QUERIES: BenchQuery[] = [
+  { name: 'common literal', pattern: 'return', options: {} },
+  { name: 'identifier', pattern: 'options', options: { caseSensitive: true } },
+  { name: 'whole word', pattern: 'error', options: { wholeWord: true } },
+  { name: 'comment marker', pattern: 'TODO', options: { caseSensitive: true } },
+  { name: 'rare literal', pattern: 'zq_bench_absent_literal', options: {} },
+  { name: 'regex function', pattern: '(function|func|def|fn)\\s+\\w+', options: { regex: true } },
+  { name: 'regex string', pattern: '"[^"]{16,}"', options: { regex: true } },
+];
+
+/**
+ * Benchmark options
+ */
+export interface BenchOptions {
+  // Runs of each query per mode (default: 5)
+  iterations?: number;
+  queries?: BenchQuery[];
+}
+
+/**
+ * Latency and throughput of one query in one mode
+ */
+export interface BenchQueryResult {
+  name: string;
+  p50Ms: number;
+  p90Ms: number;
+  p99Ms: number;
+  filesPerSec: number;
+  matches: number;
+}
+
+/**
+ * Results of one mode (scan-only or indexed)
+ */
+export interface BenchModeResult {
+  mode: 'scan' | 'index';
+  queries: BenchQueryResult[];
+  p50Ms: number;
+  p90Ms: number;
+  p99Ms: number;
+  filesPerSec: number;
+}
+
+/**
+ * Full benchmark report
+ */
+export interface BenchReport {
+  workspaceDir: string;
+  files: number;
+  iterations: number;
+  indexBuildMs: number;
+  indexedFiles: number;
+  trigrams: number;
+  modes: BenchModeResult[];
+  peakHeapMb: number;
+  rssMb: number;
+}
+
+/**
+ * Value at a percentile of sorted samples (nearest rank)
+ */
+export function percentile(sorted: number[], p: number): number {
+  if (sorted.length === 0) {
+    return 0;
+  }
+  const rank = Math.ceil((p / 100) * sorted.length);
+  return sorted[Math.min(sorted.length, Math.max(1, rank)) - 1];
+}
+
+/**
+ * Run the query suite in one mode
+ */
+async function runMode(
+  workspaceDir: string,
+  mode: 'scan' | 'index',
+  queries: BenchQuery[],
+  iterations: number,
+  index: TrigramIndex | undefined,
+  sampleMemory: () => void
+): Promise<BenchModeResult> {
+  const all: number[] = [];
+  let totalFiles = 0;
+  let totalMs = 0;
+  const results: BenchQueryResult[] = [];
+
+  for (const query of queries) {
+    const samples: number[] = [];
+    let filesScanned = 0;
+    let matches = 0;
+    for (let i = 0; i < iterations; i++) {
+      const start = process.hrtime.bigint();
+      const result = await searchLexical(workspaceDir, query.pattern, { ...query.options, maxResults: 1000 }, index);
+      const ms = Number(process.hrtime.bigint() - start) / 1e6;
+      sampleMemory();
+      samples.push(ms);
+      filesScanned += result.filesScanned;
+      matches = result.matches.length;
+    }
+    const elapsed = samples.reduce((sum, ms) => sum + ms, 0);
+    totalMs += elapsed;
+    totalFiles += filesScanned;
+    all.push(...samples);
+    samples.sort((a, b) => a - b);
+    results.push({
+      name: query.name,
+      p50Ms: percentile(samples, 50),
+      p90Ms: percentile(samples, 90),
+      p99Ms: percentile(samples, 99),
+      filesPerSec: elapsed > 0 ? (filesScanned / elapsed) * 1000 : 0,
+      matches,
+    });
+  }
+
+  all.sort((a, b) => a - b);
+  return {
+    mode,
+    queries: results,
+    p50Ms: percentile(all, 50),
+    p90Ms: percentile(all, 90),
+    p99Ms: percentile(all, 99),
+    filesPerSec: totalMs > 0 ? (totalFiles / totalMs) * 1000 : 0,
+  };
+}
+
+/**
+ * Benchmark scan-only and indexed search over a workspace
+ */
+export async function runBenchmark(workspaceDir: string, options: BenchOptions = {}): Promise<BenchReport> {
+  const iterations = Math.max(1, options.iterations ?? 5);
+  const queries = options.queries ?? BENCH_QUERIES;
+  let peakHeap = process.memoryUsage().heapUsed;
+  const sampleMemory = (): void => {
+    peakHeap = Math.max(peakHeap, process.memoryUsage().heapUsed);
+  };
+
+  const files = (await walkWorkspaceFiles(workspaceDir)).length;
+  const scan = await runMode(workspaceDir, 'scan', queries, iterations, undefined, sampleMemory);
+
+  const index = new TrigramIndex(workspaceDir);
+  const buildStart = process.hrtime.bigint();
+  const indexStats = await index.refresh();
+  const indexBuildMs = Number(process.hrtime.bigint() - buildStart) / 1e6;
+  sampleMemory();
+  const indexed = await runMode(workspaceDir, 'index', queries, iterations, index, sampleMemory);
+
+  const toMb = (bytes: number): number => Math.round((bytes / 1048576) * 10) / 10;
+  return {
+    workspaceDir,
+    files,
+    iterations,
+    indexBuildMs,
+    indexedFiles: indexStats.files,
+    trigrams: indexStats.trigrams,
+    modes: [scan, indexed],
+    peakHeapMb: toMb(peakHeap),
+    rssMb: toMb(process.memoryUsage().rss),
+  };
+}
+
+/**
+ * Format a benchmark report as a plain-text table
+ */
+export function formatBenchReport(report: BenchReport): string {
+  const ms = (value: number): string => value.toFixed(1).padStart(8);
+  let output = `Benchmark: ${report.workspaceDir}\n`;
+  output += `Files: ${report.files}, iterations per query: ${report.iterations}\n`;
+  output += `Trigram index: ${report.indexedFiles} file(s), ${report.trigrams} trigram(s), built in ${report.indexBuildMs.toFixed(1)}ms\n`;
+
+  for (const mode of report.modes) {
+    output += `\n---\n\n${mode.mode === 'scan' ? 'Scan only' : 'Trigram index'}\n\n`;
+    output += `${'Query'.padEnd(18)}${'p50 ms'.padStart(8)}${'p90 ms'.padStart(8)}${'p99 ms'.padStart(8)}${'files/s'.padStart(10)}${'matches'.padStart(9)}\n`;
+    for (const query of mode.queries) {
+      output += `${query.name.padEnd(18)}${ms(query.p50Ms)}${ms(query.p90Ms)}${ms(query.p99Ms)}` +
+        `${Math.round(query.filesPerSec).toString().padStart(10)}${query.matches.toString().padStart(9)}\n`;
+    }
+    output += `${'all queries'.padEnd(18)}${ms(mode.p50Ms)}${ms(mode.p90Ms)}${ms(mode.p99Ms)}` +
+      `${Math.round(mode.filesPerSec).toString().padStart(10)}\n`;
+  }
+
+  output += `\n---\n\nMemory: peak heap ${report.peakHeapMb} MB, rss ${report.rssMb} MB\n`;
+  return output;
+}
@@ -0,0 +1,84 @@
+/**
+ * Tests for comment classification
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { commentSpans, hasCommentSyntax, inComment } from './comments';
+import { searchLexical } from './lexical';
+
+describe('Comment classification', () => {
+  describe('commentSpans', () => {
+    it('should find line and block comments but not markers in strings', () => {
+      const content = [
+        'const url = "http://example.com"; // the endpoint',
+        '/* retries',
+        '   back off */ call();',
+        "const hash = '/* not a comment */';",
+      ].join('\n');
+      expect(commentSpans(content, 'typescript')).toEqual([
+        { line: 1, start: 35, end: 50 },
+        { line: 2, start: 1, end: 11 },
+        { line: 3, start: 1, end: 15 },
+      ]);
+    });
+
+    it('should treat statement-level triple-quoted strings as Python docstrings', () => {
+      const content = [
+        'def load(path):',
+        '    """Read the cache.',
+        '    Callers hold the lock."""',
+        '    query = """SELECT 1"""  # one row',
+        '    return "#" + path',
+      ].join('\n');
+      const spans = commentSpans(content, 'python');
+      expect(spans.map((span) => span.line)).toEqual([2, 3, 4]);
+      expect(inComment(spans, 3, 12)).toBe(true);
+      expect(inComment(spans, 4, 14)).toBe(false);
+      expect(inComment(spans, 4, 30)).toBe(true);
+      expect(inComment(spans, 5, 13)).toBe(false);
+    });
+
+    it('should only classify languages with a known comment syntax', () => {
+      expect(hasCommentSyntax('go')).toBe(true);
+      expect(hasCommentSyntax('shell')).toBe(true);
+      expect(hasCommentSyntax('plaintext')).toBe(false);
+      expect(commentSpans('# heading', 'plaintext')).toEqual([]);
+    });
+  });
+
+  describe('scope: comments', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'comments-'));
+      fs.writeFileSync(path.join(workspace, 'cache.go'), [
+        'package cache',
+        '',
+        '// evict drops the oldest entry; the cache lock must be held',
+        'func evict(lock *sync.Mutex) {',
+        '\tlock.Lock() // lock again',
+        '}',
+      ].join('\n'));
+      fs.writeFileSync(path.join(workspace, 'NOTES.md'), 'the lock order\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should match only inside comments of source files', async () => {
+      const all = await searchLexical(workspace, 'lock');
+      expect(all.matches).toHaveLength(6);
+
+      const result = await searchLexical(workspace, 'lock', { scope: 'comments', maxResults: 2 });
+      expect(result.matches.map((m) => [m.filePath, m.line, m.column])).toEqual([
+        ['cache.go', 3, 44],
+        ['cache.go', 5, 17],
+      ]);
+      expect(result.truncated).toBe(false);
+      expect(result.filesScanned).toBe(1);
+    });
+  });
+});
@@ -0,0 +1,143 @@
+/**
+ * Comment classification - where the comments and docstrings of a source file are
+ * A lexer per comment syntax skips string literals, so "//" in a URL or "#" in
+ * a format string is not taken for a comment. Python docstrings are the
+ * triple-quoted strings that stand as statements of their own
+ */
+
+/**
+ * Part of a line inside a comment or docstring
+ */
+export interface CommentSpan {
+  line: number; // 1-indexed
+  start: number; // 1-indexed column of the first character
+  end: number; // 1-indexed column after the last character
+}
+
+/**
+ * Languages that use '#' for line comments
+ */
+const HASH_LANGUAGES = new Set(['python', 'shell', 'ruby', 'r']);
+
+/**
+ * Languages that use // and /* *\/ comments
+ */
+const C_LANGUAGES = new Set([
+  'typescript', 'typescriptreact', 'javascript', 'javascriptreact', 'go', 'rust', 'c', 'cpp',
+  'java', 'csharp', 'php', 'swift', 'kotlin', 'scala',
+]);
+
+/**
+ * Check if comments of a language can be told apart from its code
+ */
+export function hasCommentSyntax(languageId: string): boolean {
+  return HASH_LANGUAGES.has(languageId) || C_LANGUAGES.has(languageId);
+}
+
+/**
+ * Tokens that matter for finding comments: comments, docstrings, and the
+ * string literals that may contain comment markers
+ */
+function lexer(languageId: string): RegExp | undefined {
+  if (languageId === 'python') {
+    return /#[^\n]*|[rRuUbBfF]{0,2}(?:"""[\s\S]*?"""|'''[\s\S]*?'''|"(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*')/g;
+  }
+  if (languageId === 'php') {
+    return /\/\/[^\n]*|#[^\n]*|\/\*[\s\S]*?\*\/|"(?:\\.|[^"\\])*"|'(?:\\.|[^'\\])*'/g;
+  }
+  if (HASH_LANGUAGES.has(languageId)) {
+    return /#[^\n]*|"(?:\\.|[^"\\])*"|'(?:\\.|[^'\\])*'/g;
+  }
+  if (C_LANGUAGES.has(languageId)) {
+    return /\/\/[^\n]*|\/\*[\s\S]*?\*\/|"(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*'|`(?:\\.|[^`\\])*`/g;
+  }
+  return undefined;
+}
+
+/**
+ * Find the comments and docstrings of a file, one span per line they cover
+ * Returns no spans for languages without a known comment syntax
+ */
+export function commentSpans(content: string, languageId: string): CommentSpan[] {
+  return tokenSpans(content, languageId, false);
+}
+
+/**
+ * Find the comments, docstrings, and string literals of a file: everything
+ * that is not code, one span per line they cover
+ * Returns no spans for languages without a known comment syntax
+ */
+export function nonCodeSpans(content: string, languageId: string): CommentSpan[] {
+  return tokenSpans(content, languageId, true);
+}
+
+/**
+ * Spans of the comments of a file, and of its string literals with strings set
+ */
+function tokenSpans(content: string, languageId: string, strings: boolean): CommentSpan[] {
+  const regex = lexer(languageId);
+  if (!regex) {
+    return [];
+  }
+
+  // Offsets where each line starts, to turn token offsets into lines and columns
+  const lineStarts = [0];
+  for (let i = 0; i < content.length; i++) {
+    if (content.charCodeAt(i) === 10) {
+      lineStarts.push(i + 1);
+    }
+  }
+  let line = 0;
+
+  const spans: CommentSpan[] = [];
+  let match: RegExpExecArray | null;
+  while ((match = regex.exec(content)) !== null) {
+    const text = match[0];
+    const start = match.index;
+    while (line + 1 < lineStarts.length && lineStarts[line + 1] <= start) {
+      line++;
+    }
+    const comment = text.startsWith('#') || text.startsWith('//') || text.startsWith('/*') ||
+      (languageId === 'python' && /^[rRuU]?("""|''')/.test(text) && !content.substring(lineStarts[line], start).trim());
+    if (!comment && !strings) {
+      continue;
+    }
+
+    // Split the token at line breaks
+    let spanStart = start;
+    let spanLine = line;
+    const end = start + text.length;
+    while (spanStart < end) {
+      const lineEnd = spanLine + 1 < lineStarts.length ? lineStarts[spanLine + 1] - 1 : content.length;
+      const spanEnd = Math.min(end, lineEnd);
+      spans.push({ line: spanLine + 1, start: spanStart - lineStarts[spanLine] + 1, end: spanEnd - lineStarts[spanLine] + 1 });
+      spanLine++;
+      spanStart = spanLine < lineStarts.length ? lineStarts[spanLine] : end;
+    }
+  }
+  return spans;
+}
+
+/**
+ * Check if a match at a line and column starts inside a comment
+ * Spans are in file order, as commentSpans returns them
+ */
+export function inComment(spans: CommentSpan[], line: number, column: number): boolean {
+  // First span on the line or after it
+  let low = 0;
+  let high = spans.length;
+  while (low < high) {
+    const mid = (low + high) >> 1;
+    if (spans[mid].line < line) {
+      low = mid + 1;
+    } else {
+      high = mid;
+    }
+  }
+  for (let i = low; i < spans.length && spans[i].line === line; i++) {
+    if (column >= spans[i].start && column < spans[i].end) {
+      return true;
+    }
+  }
+  return false;
+}
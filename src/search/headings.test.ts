@@ -0,0 +1,50 @@
+/**
+ * Tests for Markdown and AsciiDoc heading chains
+ */
+
+import { documentationFormat, headingChain, readHeadings } from './headings';
+
+const MARKDOWN = `---
+title: Design
+---
+# Architecture
+
+Overview.
+
+## Storage
+
+\`\`\`sh
+# not a heading
+\`\`\`
+
+### Compaction ###
+
+Segments are merged.
+
+Replication
+-----------
+
+Followers copy segments.
+`;
+
+describe('Documentation headings', () => {
+  it('should recognise documentation files', () => {
+    expect(documentationFormat('docs/README.md')).toBe('markdown');
+    expect(documentationFormat('docs/guide.ADOC')).toBe('asciidoc');
+    expect(documentationFormat('src/index.ts')).toBeUndefined();
+  });
+
+  it('should build Markdown heading chains, skipping code and front matter', () => {
+    const headings = readHeadings(MARKDOWN, 'markdown');
+    expect(headings.map((heading) => heading.title)).toEqual(['Architecture', 'Storage', 'Compaction', 'Replication']);
+    expect(headingChain(headings, 17)).toEqual(['Architecture', 'Storage', 'Compaction']);
+    expect(headingChain(headings, 22)).toEqual(['Architecture', 'Replication']);
+    expect(headingChain(headings, 2)).toEqual([]);
+  });
+
+  it('should read AsciiDoc section titles outside listing blocks', () => {
+    const content = '= Guide\n\n== Install\n\n----\n== not a title\n----\n\n=== Linux\n\nRun it.\n';
+    const headings = readHeadings(content, 'asciidoc');
+    expect(headingChain(headings, 11)).toEqual(['Guide', 'Install', 'Linux']);
+  });
+});
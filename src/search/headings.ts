@@ -0,0 +1,112 @@
+/**
+ * Document headings - the section a line of Markdown or AsciiDoc is in
+ * Matches in documentation are reported with their heading chain
+ * (Architecture > Storage > Compaction), which says more than the line alone
+ */
+
+import * as path from 'path';
+
+/**
+ * Markdown extensions
+ */
+const MARKDOWN_EXTENSIONS = new Set(['.md', '.markdown', '.mdx', '.mkd']);
+
+/**
+ * AsciiDoc extensions
+ */
+const ASCIIDOC_EXTENSIONS = new Set(['.adoc', '.asciidoc', '.asc']);
+
+/**
+ * A section heading
+ */
+export interface Heading {
+  level: number;
+  title: string;
+  line: number; // 1-indexed
+}
+
+/**
+ * Documentation format of a file, from its extension
+ */
+export function documentationFormat(filePath: string): 'markdown' | 'asciidoc' | undefined {
+  const ext = path.extname(filePath).toLowerCase();
+  if (MARKDOWN_EXTENSIONS.has(ext)) {
+    return 'markdown';
+  }
+  return ASCIIDOC_EXTENSIONS.has(ext) ? 'asciidoc' : undefined;
+}
+
+/**
+ * Read the headings of a document in order, skipping code blocks
+ * Markdown: "# Title" and underlined (=== or ---) headings, outside ``` and ~~~ fences.
+ * AsciiDoc: "= Title" (and "# Title"), outside ----, ...., and ```` blocks
+ */
+export function readHeadings(content: string, format: 'markdown' | 'asciidoc'): Heading[] {
+  const lines = content.split('\n').map((line) => line.replace(/\r$/, ''));
+  const headings: Heading[] = [];
+  let fence: string | undefined;
+  let start = 0;
+
+  // Front matter is not part of the document
+  if (format === 'markdown' && lines[0] === '---') {
+    const end = lines.indexOf('---', 1);
+    start = end > 0 ? end + 1 : 0;
+  }
+
+  for (let i = start; i < lines.length; i++) {
+    const line = lines[i];
+    const fenceMatch = format === 'markdown'
+      ? line.match(/^ {0,3}(`{3,}|~{3,})/)
+      : line.match(/^(-{4,}|\.{4,}|`{3,}|={4,}|\+{4,})\s*$/);
+    if (fenceMatch) {
+      const marker = fenceMatch[1];
+      if (!fence) {
+        fence = marker;
+        continue;
+      }
+      // A fence closes with the same character, at least as long
+      if (marker[0] === fence[0] && marker.length >= fence.length) {
+        fence = undefined;
+      }
+      continue;
+    }
+    if (fence) {
+      continue;
+    }
+
+    const atx = format === 'markdown'
+      ? line.match(/^ {0,3}(#{1,6})\s+(.*?)(?:\s+#+)?\s*$/)
+      : line.match(/^(={1,6}|#{1,6})\s+(.*?)\s*$/);
+    if (atx && atx[2] !== '') {
+      headings.push({ level: atx[1].length, title: atx[2], line: i + 1 });
+      continue;
+    }
+    if (format === 'markdown' && i + 1 < lines.length && line.trim() !== '' && !/^\s{4,}/.test(line)) {
+      const underline = lines[i + 1].match(/^ {0,3}(=+|-+)\s*$/);
+      // "---" under a list item or another rule is a rule, not a heading
+      if (underline && !/^\s*([-*+]|\d+\.)\s/.test(line) && !/^ {0,3}(=+|-+)\s*$/.test(line)) {
+        headings.push({ level: underline[1][0] === '=' ? 1 : 2, title: line.trim(), line: i + 1 });
+        i++;
+      }
+    }
+  }
+  return headings;
+}
+
+/**
+ * Titles of the headings enclosing a 1-indexed line, outermost first
+ * A heading's own line is inside its section
+ */
+export function headingChain(headings: Heading[], line: number): string[] {
+  const chain: Heading[] = [];
+  for (const heading of headings) {
+    if (heading.line > line) {
+      break;
+    }
+    while (chain.length > 0 && chain[chain.length - 1].level >= heading.level) {
+      chain.pop();
+    }
+    chain.push(heading);
+  }
+  return chain.map((heading) => heading.title);
+}
@@ -0,0 +1,53 @@
+/**
+ * Tests for identifier word search
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { buildIdentifierMatcher, identifierWords } from './identifiers';
+import { searchLexical } from './lexical';
+
+const matched = (words: string[], text: string, languageId = 'typescript') =>
+  text.match(buildIdentifierMatcher(words, languageId)) ?? [];
+
+describe('identifier word search', () => {
+  it('should match each casing of the words, with parts in between', () => {
+    const words = identifierWords('find user id');
+    expect(words).toEqual(['find', 'user', 'id']);
+    expect(identifierWords('findUserID')).toEqual(words);
+    expect(matched(words, 'FindUserByID(x); find_user_id = FIND_USER_ID; findUserId()'))
+      .toEqual(['FindUserByID', 'find_user_id', 'FIND_USER_ID', 'findUserId']);
+    expect(matched(words, 'cacheFindUserIdValue')).toEqual(['cacheFindUserIdValue']);
+    expect(() => identifierWords('--')).toThrow('needs a pattern with letters or digits');
+  });
+
+  it('should only match words that are whole parts', () => {
+    const words = identifierWords('user id');
+    expect(matched(words, 'username_id userIdentity USERID user2Id userIDX')).toEqual([]);
+    expect(matched(words, 'HTTPUserIDValue userIDs')).toEqual(['HTTPUserIDValue']);
+  });
+
+  it('should join words with hyphens outside programming languages', () => {
+    const words = identifierWords('user id');
+    expect(matched(words, 'user-id: 3', 'plaintext')).toEqual(['user-id']);
+    expect(matched(words, 'user-id', 'typescript')).toEqual([]);
+    expect(matched(words, '$userId', 'typescript')).toEqual(['$userId']);
+  });
+
+  it('should search the workspace by identifier words', async () => {
+    const workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'identifiers-')));
+    try {
+      fs.writeFileSync(path.join(workspace, 'users.go'), 'func FindUserByID(id int) {}\nfunc finder() {}\n');
+      fs.writeFileSync(path.join(workspace, 'users.py'), 'def find_user_id(name):\n    pass\n');
+      const result = await searchLexical(workspace, 'find user id', { identifierWords: true });
+      expect(result.matches.map((match) => [match.filePath, match.line, match.column, match.length])).toEqual([
+        ['users.go', 1, 6, 12],
+        ['users.py', 1, 5, 12],
+      ]);
+      await expect(searchLexical(workspace, 'find.*', { identifierWords: true, regex: true })).rejects.toThrow('cannot be combined with regex');
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
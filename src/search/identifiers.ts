@@ -0,0 +1,88 @@
+/**
+ * Identifier word search - match identifiers by the words they are made of
+ * "find user id" matches FindUserByID, findUserId, find_user_id, and
+ * FIND_USER_ID: each word must be a whole part of one identifier, in order,
+ * split the way splitIdentifier splits names, with other parts allowed in
+ * between. What joins the parts depends on the language: hyphens only join
+ * words outside programming languages (CSS classes, YAML keys, CLI flags),
+ * where in code they are a minus sign
+ */
+
+import { ToolError } from '../workspace/errors.js';
+import { splitIdentifier } from '../semantic/embeddings.js';
+
+/**
+ * Characters, besides letters, digits, and underscores, that identifiers of a language may contain
+ */
+const EXTRA_IDENTIFIER_CHARACTERS: Record<string, string> = {
+  javascript: '$',
+  javascriptreact: '$',
+  typescript: '$',
+  typescriptreact: '$',
+  // Config keys, CSS classes, and command-line flags are kebab-case
+  plaintext: '-',
+};
+
+/**
+ * Longest run of other identifier characters between two words, or around them
+ * Bounds the backtracking on long runs such as base64 blobs
+ */
+const MAX_GAP = 48;
+
+/**
+ * Words of an identifier search, e.g. "find user id" or "findUserId" -> find, user, id
+ */
+export function identifierWords(pattern: string): string[] {
+  const words = splitIdentifier(pattern);
+  if (words.length === 0) {
+    throw new ToolError('invalid-argument', 'identifierWords needs a pattern with letters or digits');
+  }
+  return words;
+}
+
+/**
+ * A word as one part of an identifier: lowercase, Capitalized, or UPPERCASE,
+ * starting and ending where splitIdentifier would split
+ */
+function wordPart(word: string): string {
+  const lower = word;
+  const capitalized = word.charAt(0).toUpperCase() + word.substring(1);
+  const upper = word.toUpperCase();
+  // find in find_user or findUser
+  const forms = [`(?<![A-Za-z0-9])${lower}(?![a-z0-9])`];
+  if (capitalized !== lower) {
+    // User in findUser, HTTPServer, or User_ID
+    forms.push(`${capitalized}(?![a-z0-9])`);
+  }
+  if (upper !== capitalized) {
+    // ID in userID or IDValue, but not in IDX
+    forms.push(`(?<![A-Z])${upper}(?![a-z0-9])(?![A-Z](?![a-z]))`);
+  }
+  return `(?:${forms.join('|')})`;
+}
+
+/**
+ * Build the matcher of identifier words for files of a language; a match spans the whole identifier
+ */
+export function buildIdentifierMatcher(words: string[], languageId: string): RegExp {
+  const extra = (EXTRA_IDENTIFIER_CHARACTERS[languageId] ?? '').replace(/[-$]/g, '\\$&');
+  const identifier = `[A-Za-z0-9_${extra}]`;
+  const gap = `${identifier}{0,${MAX_GAP}}?`;
+  const source = `(?<!${identifier})${gap}${words.map(wordPart).join(gap)}${identifier}{0,${MAX_GAP}}(?!${identifier})`;
+  // Case is part of the word boundaries, so the expression itself is case-sensitive
+  return new RegExp(source, 'g');
+}
+
+/**
+ * Matchers per language, built when a file of that language is first searched
+ */
+export function identifierMatchers(words: string[]): (languageId: string) => RegExp {
+  const byCharacters = new Map<string, RegExp>();
+  return (languageId) => {
+    const key = EXTRA_IDENTIFIER_CHARACTERS[languageId] ?? '';
+    if (!byCharacters.has(key)) {
+      byCharacters.set(key, buildIdentifierMatcher(words, languageId));
+    }
+    return byCharacters.get(key)!;
+  };
+}
@@ -0,0 +1,97 @@
+/**
+ * Tests for JSX structural search
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { findJsxMatches, jsxSearchAvailable, searchJsx, SyntaxNode, validateJsxQuery } from './jsx';
+
+/**
+ * Build a syntax node the way tree-sitter reports it
+ */
+function node(type: string, text: string, row: number, children: SyntaxNode[] = [], fields: Record<string, SyntaxNode> = {}): SyntaxNode {
+  return {
+    type,
+    text,
+    startPosition: { row, column: 2 },
+    namedChildren: [...Object.values(fields), ...children],
+    childForFieldName: (name) => fields[name] ?? null,
+  };
+}
+
+// function ProfilePage() { const [user] = useState(); return <Layout.Header><UserCard onSelect={pick} /></Layout.Header>; }
+// const label = "<UserCard>";
+const tree = node('program', '', 0, [
+  node('function_declaration', '', 0, [
+    node('call_expression', 'useState()', 1, [], { function: node('identifier', 'useState', 1) }),
+    node('call_expression', 'React.useMemo()', 2, [], { function: node('member_expression', 'React.useMemo', 2) }),
+    node('call_expression', 'userId()', 3, [], { function: node('identifier', 'userId', 3) }),
+    node('jsx_element', '', 4, [
+      node('jsx_opening_element', '<Layout.Header>', 4, [], { name: node('member_expression', 'Layout.Header', 4) }),
+      node('jsx_self_closing_element', '<UserCard onSelect={pick} />', 5, [
+        node('jsx_attribute', 'onSelect={pick}', 5, [node('property_identifier', 'onSelect', 5)]),
+      ], { name: node('identifier', 'UserCard', 5) }),
+    ]),
+  ], { name: node('identifier', 'ProfilePage', 0) }),
+  node('lexical_declaration', '', 7, [
+    node('variable_declarator', '', 7, [], { name: node('identifier', 'label', 7), value: node('string', '"<UserCard>"', 7) }),
+  ]),
+]);
+const lines = Array.from({ length: 8 }, (_, i) => `line ${i + 1}`);
+
+describe('JSX search', () => {
+  it('should find elements by name and the props passed to them', () => {
+    const elements = findJsxMatches(tree, { component: 'UserCard' }, 'Profile.tsx', lines);
+    expect(elements).toEqual([{
+      filePath: 'Profile.tsx', line: 6, column: 3, kind: 'element', name: 'UserCard', lineText: 'line 6', enclosing: 'ProfilePage',
+    }]);
+    expect(findJsxMatches(tree, { component: 'Header' }, 'Profile.tsx', lines).map((m) => m.name)).toEqual(['Layout.Header']);
+
+    const props = findJsxMatches(tree, { prop: 'onSelect' }, 'Profile.tsx', lines);
+    expect(props.map((m) => [m.kind, m.name])).toEqual([['prop', 'UserCard.onSelect']]);
+    expect(findJsxMatches(tree, { component: 'Layout.Header', prop: 'onSelect' }, 'Profile.tsx', lines)).toEqual([]);
+  });
+
+  it('should find hook calls, optionally within a component', () => {
+    const hooks = findJsxMatches(tree, { hook: '*' }, 'Profile.tsx', lines);
+    expect(hooks.map((m) => [m.name, m.line])).toEqual([['useState', 2], ['useMemo', 3]]);
+    expect(findJsxMatches(tree, { hook: 'useMemo', within: 'ProfilePage' }, 'Profile.tsx', lines)).toHaveLength(1);
+    expect(findJsxMatches(tree, { hook: 'useMemo', within: 'OtherPage' }, 'Profile.tsx', lines)).toEqual([]);
+  });
+
+  it('should require something to look for', async () => {
+    expect(() => validateJsxQuery({ within: 'ProfilePage' })).toThrow('at least one of component, prop, and hook');
+    if (!jsxSearchAvailable()) {
+      await expect(searchJsx(process.cwd(), { component: 'UserCard' })).rejects.toThrow("requires the 'tree-sitter'");
+    }
+  });
+
+  // Runs where the optional tree-sitter packages are installed
+  (jsxSearchAvailable() ? it : it.skip)('should find components, props, and hooks in a real parse', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'jsx-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'Profile.tsx'), [
+        'export function ProfilePage() {',
+        '  const [user] = useState<User>();',
+        '  return <Layout.Header><UserCard onSelect={pick} user={user} /></Layout.Header>;',
+        '}',
+        'const label = "<UserCard>";',
+      ].join('\n'));
+      fs.writeFileSync(path.join(workspace, 'hooks.ts'), 'export const useUser = () => useState<User>();\n');
+
+      const elements = await searchJsx(workspace, { component: 'UserCard' });
+      expect(elements.matches.map((m) => [m.filePath, m.line, m.column, m.name, m.enclosing])).toEqual([
+        ['Profile.tsx', 3, 25, 'UserCard', 'ProfilePage'],
+      ]);
+      expect(elements.failedFiles).toBe(0);
+      const props = await searchJsx(workspace, { component: 'UserCard', prop: 'user' });
+      expect(props.matches.map((m) => m.name)).toEqual(['UserCard.user']);
+      // .ts files are parsed with the TypeScript grammar when looking for hooks
+      const hooks = await searchJsx(workspace, { hook: 'useState' });
+      expect(hooks.matches.map((m) => [m.filePath, m.line])).toEqual([['hooks.ts', 1], ['Profile.tsx', 2]]);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,292 @@
+/**
+ * JSX structural search - components, props, and hooks in React code
+ * Files are parsed with the tree-sitter TSX grammar (the TypeScript grammar
+ * for .ts files), so matches follow the syntax tree rather than text:
+ * "<UserCard" in a string or comment is not an element, and a prop is only
+ * reported on the element it is passed to
+ *
+ * tree-sitter and tree-sitter-typescript are loaded lazily so they stay
+ * optional installs.
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { matchesGlob } from '../workspace/glob.js';
+import { readFileText } from '../workspace/overlay.js';
+import { ToolError } from '../workspace/errors.js';
+
+const jsxLogger = createLogger(Component.TOOLS);
+
+/**
+ * Names of the optional parser packages
+ */
+export const TREE_SITTER_MODULE = 'tree-sitter';
+export const TYPESCRIPT_GRAMMAR_MODULE = 'tree-sitter-typescript';
+
+/**
+ * Extensions parsed with the TSX grammar; .ts files use the TypeScript grammar
+ */
+const JSX_EXTENSIONS = new Set(['.tsx', '.jsx', '.js', '.mjs', '.cjs']);
+
+/**
+ * Files larger than this are not parsed
+ */
+export const MAX_PARSE_SIZE = 2 * 1024 * 1024;
+
+/**
+ * The parts of a tree-sitter node the search reads
+ */
+export interface SyntaxNode {
+  type: string;
+  text: string;
+  startPosition: { row: number; column: number };
+  namedChildren: SyntaxNode[];
+  childForFieldName(name: string): SyntaxNode | null;
+}
+
+/**
+ * What to look for; at least one of component, prop, and hook
+ */
+export interface JsxQuery {
+  // Element name, as in <UserCard> or <Layout.Header> (the last segment also matches)
+  component?: string;
+  // Attribute passed to elements (to the component's elements when component is set)
+  prop?: string;
+  // Hook called, e.g. "useState"; "*" matches every use* call
+  hook?: string;
+  // Only report matches inside the definition of this component or hook
+  within?: string;
+}
+
+/**
+ * Options for a JSX search
+ */
+export interface JsxSearchOptions extends JsxQuery {
+  path?: string;
+  glob?: string | string[];
+  maxResults?: number;
+}
+
+/**
+ * A structural match
+ */
+export interface JsxMatch {
+  filePath: string; // Relative to the workspace
+  line: number; // 1-indexed
+  column: number; // 1-indexed
+  kind: 'element' | 'prop' | 'hook';
+  // Element name, element.prop, or hook name
+  name: string;
+  lineText: string;
+  // Component or function the match is in
+  enclosing?: string;
+}
+
+/**
+ * Result of a JSX search
+ */
+export interface JsxSearchResult {
+  matches: JsxMatch[];
+  filesScanned: number;
+  truncated: boolean;
+  // Files that could not be read or parsed
+  failedFiles: number;
+}
+
+/**
+ * Check that a query has something to look for
+ */
+export function validateJsxQuery(query: JsxQuery): void {
+  if (!query.component && !query.prop && !query.hook) {
+    throw new ToolError('invalid-argument', 'at least one of component, prop, and hook is required');
+  }
+}
+
+/**
+ * Check if an element name matches the wanted component
+ */
+function matchesName(name: string, wanted: string): boolean {
+  return name === wanted || name.split('.').pop() === wanted;
+}
+
+/**
+ * Check if a called function is the wanted hook
+ */
+function matchesHook(callee: string, wanted: string): boolean {
+  const name = callee.split('.').pop() ?? callee;
+  return wanted === '*' ? /^use[A-Z0-9]/.test(name) : name === wanted;
+}
+
+/**
+ * Name a function-like node defines, if any
+ */
+function definedName(node: SyntaxNode): string | undefined {
+  switch (node.type) {
+    case 'function_declaration':
+    case 'class_declaration':
+    case 'method_definition':
+      return node.childForFieldName('name')?.text;
+    case 'variable_declarator': {
+      const value = node.childForFieldName('value')?.type;
+      // const Card = () => ..., const Card = function () {...}, const Card = memo(...)
+      return value === 'arrow_function' || value === 'function_expression' || value === 'call_expression'
+        ? node.childForFieldName('name')?.text
+        : undefined;
+    }
+    default:
+      return undefined;
+  }
+}
+
+/**
+ * Find the matches of a query in a parsed file
+ */
+export function findJsxMatches(root: SyntaxNode, query: JsxQuery, filePath: string, lines: string[]): JsxMatch[] {
+  const matches: JsxMatch[] = [];
+  const report = (node: SyntaxNode, kind: JsxMatch['kind'], name: string, enclosing: string | undefined) => {
+    const { row, column } = node.startPosition;
+    matches.push({ filePath, line: row + 1, column: column + 1, kind, name, lineText: lines[row] ?? '', enclosing });
+  };
+
+  const visit = (node: SyntaxNode, enclosing: string | undefined, inside: boolean) => {
+    const name = definedName(node);
+    if (name) {
+      enclosing = name;
+      inside = inside || name === query.within;
+    }
+    const inScope = !query.within || inside;
+
+    if (inScope && (node.type === 'jsx_opening_element' || node.type === 'jsx_self_closing_element')) {
+      const element = node.childForFieldName('name')?.text;
+      // Fragments (<>) have no name
+      if (element !== undefined && (!query.component || matchesName(element, query.component))) {
+        if (query.prop) {
+          for (const attribute of node.namedChildren.filter((child) => child.type === 'jsx_attribute')) {
+            const prop = attribute.namedChildren[0]?.text;
+            if (prop === query.prop) {
+              report(attribute, 'prop', `${element}.${prop}`, enclosing);
+            }
+          }
+        } else if (query.component) {
+          report(node, 'element', element, enclosing);
+        }
+      }
+    }
+
+    if (inScope && query.hook && node.type === 'call_expression') {
+      const callee = node.childForFieldName('function');
+      if (callee && (callee.type === 'identifier' || callee.type === 'member_expression') && matchesHook(callee.text, query.hook)) {
+        report(node, 'hook', callee.text.split('.').pop()!, enclosing);
+      }
+    }
+
+    for (const child of node.namedChildren) {
+      visit(child, enclosing, inside);
+    }
+  };
+
+  visit(root, undefined, false);
+  return matches;
+}
+
+/**
+ * Check if the parser packages are installed
+ */
+export function jsxSearchAvailable(): boolean {
+  try {
+    require.resolve(TREE_SITTER_MODULE);
+    require.resolve(TYPESCRIPT_GRAMMAR_MODULE);
+    return true;
+  } catch (err) {
+    return false;
+  }
+}
+
+/**
+ * Parsers for the TSX and TypeScript grammars, created on first use
+ */
+let parsers: { tsx: any; typescript: any } | undefined;
+
+function loadParsers(): { tsx: any; typescript: any } {
+  if (parsers) {
+    return parsers;
+  }
+  let Parser: any;
+  let grammars: any;
+  try {
+    Parser = require(TREE_SITTER_MODULE);
+    grammars = require(TYPESCRIPT_GRAMMAR_MODULE);
+  } catch (err) {
+    throw new ToolError('unsupported', `JSX search requires the '${TREE_SITTER_MODULE}' and '${TYPESCRIPT_GRAMMAR_MODULE}' packages: ` +
+      (err as Error).message);
+  }
+  const tsx = new Parser();
+  tsx.setLanguage(grammars.tsx);
+  const typescript = new Parser();
+  typescript.setLanguage(grammars.typescript);
+  parsers = { tsx, typescript };
+  jsxLogger.info('Loaded the tree-sitter TSX and TypeScript grammars');
+  return parsers;
+}
+
+/**
+ * Check if a file can be searched: a JSX-capable file, or a .ts file when looking for hooks
+ */
+function isSearchable(filePath: string, query: JsxQuery): boolean {
+  const ext = path.extname(filePath).toLowerCase();
+  return JSX_EXTENSIONS.has(ext) || (!!query.hook && ext === '.ts');
+}
+
+/**
+ * Search the workspace for components, props, and hooks
+ */
+export async function searchJsx(workspaceDir: string, options: JsxSearchOptions): Promise<JsxSearchResult> {
+  validateJsxQuery(options);
+  const { tsx, typescript } = loadParsers();
+  const maxResults = options.maxResults ?? 100;
+
+  const pathPrefix = options.path ? resolveWorkspacePath(workspaceDir, options.path) : undefined;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix }))
+    .filter((file) => isSearchable(file.relativePath, options))
+    .filter((file) => !options.glob || matchesGlob(file.relativePath, options.glob));
+
+  const matches: JsxMatch[] = [];
+  let filesScanned = 0;
+  let failedFiles = 0;
+  for (const file of files) {
+    if (matches.length > maxResults) {
+      break;
+    }
+    let content: string;
+    try {
+      content = await readFileText(file.absolutePath);
+    } catch (err) {
+      jsxLogger.debug('Error reading file %s: %s', file.relativePath, err);
+      failedFiles++;
+      continue;
+    }
+    if (content.length > MAX_PARSE_SIZE) {
+      continue;
+    }
+    const parser = path.extname(file.relativePath).toLowerCase() === '.ts' ? typescript : tsx;
+    let root: SyntaxNode;
+    try {
+      // The default input buffer is too small for large files
+      root = parser.parse(content, undefined, { bufferSize: content.length * 2 + 1 }).rootNode;
+    } catch (err) {
+      jsxLogger.debug('Could not parse %s: %s', file.relativePath, err);
+      failedFiles++;
+      continue;
+    }
+    filesScanned++;
+    matches.push(...findJsxMatches(root, options, file.relativePath, content.split('\n')));
+  }
+
+  return {
+    matches: matches.slice(0, maxResults),
+    filesScanned,
+    truncated: matches.length > maxResults,
+    failedFiles,
+  };
+}
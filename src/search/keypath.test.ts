@@ -0,0 +1,106 @@
+/**
+ * Tests for key path search over YAML and JSON
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { matchKeyPath, parseKeyPath, searchKeyPath } from './keypath';
+import { parseJsonNodes, parseYamlNodes } from './structured';
+
+const DEPLOYMENT = `# Deployment and service
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  labels:
+    app.kubernetes.io/name: api   # selector label
+spec:
+  template:
+    spec:
+      containers:
+        - name: api
+          image: "registry.local/api:1.4"
+          args: [--port, "8080"]
+          command:
+          - /bin/api
+        - name: sidecar
+          image: envoy:1.29
+          env:
+            - name: CONFIG
+              value: |
+                static_resources:
+                  listeners: []
+---
+apiVersion: v1
+kind: Service
+metadata: {name: api, namespace: prod}
+`;
+
+describe('Key path search', () => {
+  it('should parse key paths', () => {
+    expect(parseKeyPath('spec.containers[*].image')).toEqual([
+      { type: 'key', name: 'spec' }, { type: 'key', name: 'containers' }, { type: 'item' }, { type: 'key', name: 'image' },
+    ]);
+    expect(parseKeyPath('**.labels["app.kubernetes.io/name"]')).toEqual([
+      { type: 'deep' }, { type: 'key', name: 'labels' }, { type: 'key', name: 'app.kubernetes.io/name' },
+    ]);
+    expect(parseKeyPath('items[2].*')).toEqual([{ type: 'key', name: 'items' }, { type: 'index', index: 2 }, { type: 'any' }]);
+    expect(() => parseKeyPath('spec..image')).toThrow('empty key');
+    expect(() => parseKeyPath('spec[name]')).toThrow('expected [*], [n], or ["key"]');
+  });
+
+  it('should find values with positions in multi-document YAML', () => {
+    const [deployment, service] = parseYamlNodes(DEPLOYMENT);
+    const images = matchKeyPath(deployment, parseKeyPath('spec.template.spec.containers[*].image'));
+    expect(images.map((m) => [m.path, m.node.kind === 'scalar' && m.node.value, m.line, m.column])).toEqual([
+      ['spec.template.spec.containers[0].image', 'registry.local/api:1.4', 13, 18],
+      ['spec.template.spec.containers[1].image', 'envoy:1.29', 18, 18],
+    ]);
+
+    const args = matchKeyPath(deployment, parseKeyPath('spec.template.spec.containers[0].args[1]'));
+    expect(args[0].node).toMatchObject({ kind: 'scalar', value: '8080', quoted: true, line: 14 });
+    const command = matchKeyPath(deployment, parseKeyPath('**.command[0]'));
+    expect(command[0].node).toMatchObject({ value: '/bin/api', line: 16 });
+    const block = matchKeyPath(deployment, parseKeyPath('**.env[0].value'));
+    expect(block[0].node).toMatchObject({ value: 'static_resources:\n  listeners: []' });
+    const label = matchKeyPath(deployment, parseKeyPath('metadata.labels["app.kubernetes.io/name"]'));
+    expect(label[0].node).toMatchObject({ value: 'api', line: 7 });
+
+    expect(matchKeyPath(service, parseKeyPath('metadata.namespace'))[0].node).toMatchObject({ value: 'prod', line: 27 });
+  });
+
+  it('should read JSON with comments and trailing commas', () => {
+    const root = parseJsonNodes('{\n  // compiler settings\n  "compilerOptions": {\n    "paths": {"@app/*": ["src/*",]},\n  },\n}\n');
+    const found = matchKeyPath(root, parseKeyPath('compilerOptions.paths.*[0]'));
+    expect(found.map((m) => [m.path, m.line, m.column])).toEqual([['compilerOptions.paths["@app/*"][0]', 4, 26]]);
+    expect(() => parseJsonNodes('{"a": 1 "b": 2}')).toThrow('line 1');
+  });
+
+  it('should search workspace files with value filters', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'keypath-'));
+    try {
+      fs.mkdirSync(path.join(workspace, 'deploy'));
+      fs.writeFileSync(path.join(workspace, 'deploy', 'api.yaml'), DEPLOYMENT);
+      fs.writeFileSync(path.join(workspace, 'package.json'), '{"name": "app", "spec": 1}');
+      fs.writeFileSync(path.join(workspace, 'broken.json'), '{"name": ');
+
+      const result = await searchKeyPath(workspace, '**.image', { value: '^envoy' });
+      expect(result.matches).toEqual([{
+        filePath: path.join('deploy', 'api.yaml'),
+        document: 1,
+        path: 'spec.template.spec.containers[1].image',
+        line: 18,
+        column: 18,
+        value: 'envoy:1.29',
+        kind: 'scalar',
+      }]);
+      expect(result.parseErrors.map((entry) => entry.filePath)).toEqual(['broken.json']);
+
+      const kinds = await searchKeyPath(workspace, 'kind');
+      expect(kinds.matches.map((m) => [m.value, m.document])).toEqual([['Deployment', 1], ['Service', 2]]);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,231 @@
+/**
+ * Key path search - query YAML and JSON files by the path to a value
+ * Paths are dotted keys with list access, e.g.
+ * spec.template.spec.containers[*].image: "*" matches any key or item at one
+ * level, "[*]" any list item, "[n]" one item, and "**" any number of levels.
+ * Keys containing dots are written in brackets: metadata.labels["app.kubernetes.io/name"]
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { matchesGlob } from '../workspace/glob.js';
+import { readFileText } from '../workspace/overlay.js';
+import { StructuredNode, parseStructured, renderNode, structuredFormat } from './structured.js';
+import { ToolError } from '../workspace/errors.js';
+
+const keyPathLogger = createLogger(Component.TOOLS);
+
+/**
+ * One step of a key path
+ */
+export type KeyPathSegment =
+  | { type: 'key'; name: string }
+  | { type: 'index'; index: number }
+  | { type: 'item' }
+  | { type: 'any' }
+  | { type: 'deep' };
+
+/**
+ * Options for a key path search
+ */
+export interface KeyPathSearchOptions {
+  path?: string;
+  glob?: string | string[];
+  // Only report scalar values matching this regular expression
+  value?: string;
+  maxResults?: number;
+}
+
+/**
+ * A value found at a key path
+ */
+export interface KeyPathMatch {
+  filePath: string; // Relative to the workspace
+  // Document index within the file, 1-indexed (YAML files may hold several)
+  document: number;
+  // Concrete path of the value, e.g. spec.containers[0].image
+  path: string;
+  line: number; // 1-indexed
+  column: number; // 1-indexed
+  // Scalar value, or a compact rendering of a list or mapping
+  value: string;
+  kind: StructuredNode['kind'];
+}
+
+/**
+ * Result of a key path search
+ */
+export interface KeyPathSearchResult {
+  matches: KeyPathMatch[];
+  filesScanned: number;
+  truncated: boolean;
+  // Files that could not be parsed, with the error
+  parseErrors: Array<{ filePath: string; error: string }>;
+}
+
+/**
+ * Parse a key path expression
+ */
+export function parseKeyPath(expr: string): KeyPathSegment[] {
+  const segments: KeyPathSegment[] = [];
+  let pos = 0;
+  const fail = (message: string): never => {
+    throw new ToolError('pattern-invalid', `invalid key path "${expr}": ${message}`);
+  };
+
+  while (pos < expr.length) {
+    if (expr[pos] === '.') {
+      if (pos === 0 || expr[pos + 1] === '.' || pos === expr.length - 1) {
+        fail(`empty key at ${pos + 1}`);
+      }
+      pos++;
+      continue;
+    }
+    if (expr[pos] === '[') {
+      const end = expr.indexOf(']', pos);
+      if (end < 0) {
+        fail('missing ]');
+      }
+      const inner = expr.substring(pos + 1, end).trim();
+      if (inner === '*') {
+        segments.push({ type: 'item' });
+      } else if (/^\d+$/.test(inner)) {
+        segments.push({ type: 'index', index: parseInt(inner, 10) });
+      } else if (/^(".*"|'.*')$/.test(inner)) {
+        segments.push({ type: 'key', name: inner.slice(1, -1) });
+      } else {
+        fail(`expected [*], [n], or ["key"], got [${inner}]`);
+      }
+      pos = end + 1;
+      continue;
+    }
+    const match = expr.substring(pos).match(/^[^.[\]]+/)!;
+    const name = match[0];
+    segments.push(name === '**' ? { type: 'deep' } : name === '*' ? { type: 'any' } : { type: 'key', name });
+    pos += name.length;
+  }
+  if (segments.length === 0) {
+    fail('empty path');
+  }
+  return segments;
+}
+
+/**
+ * Append a key to a concrete path, bracketing keys that are not plain names
+ */
+function joinKey(path: string, key: string): string {
+  if (/^[A-Za-z_$][\w$-]*$/.test(key)) {
+    return path ? `${path}.${key}` : key;
+  }
+  return `${path}[${JSON.stringify(key)}]`;
+}
+
+/**
+ * Find the values at a key path in a document
+ */
+export function matchKeyPath(root: StructuredNode, segments: KeyPathSegment[]): Array<{ path: string; node: StructuredNode; line: number; column: number }> {
+  const results: Array<{ path: string; node: StructuredNode; line: number; column: number }> = [];
+  const seen = new Set<StructuredNode>();
+
+  // Children of a node with their concrete paths and the position to report
+  const children = (node: StructuredNode, path: string) => {
+    if (node.kind === 'map') {
+      return node.entries.map((entry) => ({ key: entry.key, path: joinKey(path, entry.key), node: entry.value, at: entry.value.kind === 'scalar' ? entry.value : entry.keyPosition }));
+    }
+    if (node.kind === 'seq') {
+      return node.items.map((item, index) => ({ index, path: `${path}[${index}]`, node: item, at: item as { line: number; column: number } }));
+    }
+    return [];
+  };
+
+  const visit = (node: StructuredNode, i: number, path: string, at: { line: number; column: number }) => {
+    if (i === segments.length) {
+      if (!seen.has(node)) {
+        seen.add(node);
+        results.push({ path, node, line: at.line, column: at.column });
+      }
+      return;
+    }
+    const segment = segments[i];
+    if (segment.type === 'deep') {
+      visit(node, i + 1, path, at);
+      for (const child of children(node, path)) {
+        visit(child.node, i, child.path, child.at);
+      }
+      return;
+    }
+    for (const child of children(node, path)) {
+      const matches =
+        segment.type === 'any' ||
+        (segment.type === 'key' && 'key' in child && child.key === segment.name) ||
+        (segment.type === 'item' && 'index' in child) ||
+        (segment.type === 'index' && 'index' in child && child.index === segment.index);
+      if (matches) {
+        visit(child.node, i + 1, child.path, child.at);
+      }
+    }
+  };
+
+  visit(root, 0, '', root);
+  return results;
+}
+
+/**
+ * Search the workspace's YAML and JSON files for the values at a key path
+ */
+export async function searchKeyPath(
+  workspaceDir: string,
+  keyPath: string,
+  options: KeyPathSearchOptions = {}
+): Promise<KeyPathSearchResult> {
+  const segments = parseKeyPath(keyPath);
+  const valueFilter = options.value !== undefined ? new RegExp(options.value) : undefined;
+  const maxResults = options.maxResults ?? 100;
+
+  const pathPrefix = options.path ? resolveWorkspacePath(workspaceDir, options.path) : undefined;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix }))
+    .filter((file) => structuredFormat(file.relativePath) !== undefined)
+    .filter((file) => !options.glob || matchesGlob(file.relativePath, options.glob));
+
+  const matches: KeyPathMatch[] = [];
+  const parseErrors: KeyPathSearchResult['parseErrors'] = [];
+  let filesScanned = 0;
+  for (const file of files) {
+    if (matches.length > maxResults) {
+      break;
+    }
+    let documents: StructuredNode[];
+    try {
+      documents = parseStructured(await readFileText(file.absolutePath), structuredFormat(file.relativePath)!);
+    } catch (err) {
+      keyPathLogger.debug('Could not parse %s: %s', file.relativePath, (err as Error).message);
+      parseErrors.push({ filePath: file.relativePath, error: (err as Error).message });
+      continue;
+    }
+    filesScanned++;
+    documents.forEach((document, index) => {
+      for (const found of matchKeyPath(document, segments)) {
+        const value = found.node.kind === 'scalar' ? found.node.value : renderNode(found.node);
+        if (valueFilter && (found.node.kind !== 'scalar' || !valueFilter.test(value))) {
+          continue;
+        }
+        matches.push({
+          filePath: file.relativePath,
+          document: index + 1,
+          path: found.path,
+          line: found.line,
+          column: found.column,
+          value,
+          kind: found.node.kind,
+        });
+      }
+    });
+  }
+
+  return {
+    matches: matches.slice(0, maxResults),
+    filesScanned,
+    truncated: matches.length > maxResults,
+    parseErrors,
+  };
+}
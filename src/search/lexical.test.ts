@@ -0,0 +1,466 @@
+/**
+ * Tests for lexical search and the trigram index
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { buildMatcher, matchContent, maxFileSizeFromEnv, maxLineLengthFromEnv, maxResultsFromEnv, mergeLineMatches, searchDefaultsFromEnv, searchLexical, searchTimeoutFromEnv, snapshotHash } from './lexical';
+import { TrigramIndex, extractTrigrams } from './trigram';
+import { createLimiter, runPool, workerCount } from '../workspace/pool';
+import { MemoryBudget } from '../workspace/memory';
+
+describe('Lexical search', () => {
+  describe('buildMatcher', () => {
+    it('should escape literal patterns', () => {
+      const matcher = buildMatcher('a.b(');
+      expect(matcher.test('xa.b(y')).toBe(true);
+      matcher.lastIndex = 0;
+      expect(matcher.test('axb(')).toBe(false);
+    });
+
+    it('should respect whole word matching', () => {
+      const matches = matchContent('a.go', 'user users _user user', buildMatcher('user', { wholeWord: true }), 10);
+      expect(matches.map((m) => m.column)).toEqual([1, 18]);
+    });
+
+    it('should reject invalid regular expressions', () => {
+      expect(() => buildMatcher('(', { regex: true })).toThrow('Invalid regular expression');
+    });
+  });
+
+  describe('mergeLineMatches', () => {
+    it('should list every match of a line as column ranges', () => {
+      const matches = matchContent('a.ts', 'foo = foo(x)\nbar\nfoo', buildMatcher('fo+', { regex: true }), 10);
+      const merged = mergeLineMatches(matches);
+      expect(merged.map((m) => [m.line, m.ranges])).toEqual([
+        [1, [{ start: 1, end: 4 }, { start: 7, end: 10 }]],
+        [3, [{ start: 1, end: 4 }]],
+      ]);
+      const [first] = merged;
+      expect(first.ranges!.map((r) => first.lineText.substring(r.start - 1, r.end - 1))).toEqual(['foo', 'foo']);
+      expect(matches[0].ranges).toBeUndefined();
+    });
+  });
+
+  describe('matchContent', () => {
+    it('should stop at the deadline', () => {
+      const content = Array.from({ length: 5000 }, () => 'foo').join('\n');
+      expect(matchContent('a.ts', content, buildMatcher('foo'), 10000, 0).length).toBe(999);
+    });
+
+
+    it('should report 1-indexed lines and columns', () => {
+      const matches = matchContent('a.ts', 'foo\n  bar foo\r\n', buildMatcher('foo'), 10);
+      expect(matches.map((m) => [m.line, m.column])).toEqual([[1, 1], [2, 7]]);
+      expect(matches[1].lineText).toBe('  bar foo');
+    });
+
+    it('should keep capture groups of regex matches', () => {
+      const matcher = buildMatcher('(GET|POST) (/\\w+)(\\?)?', { regex: true, caseSensitive: true });
+      const matches = matchContent('a.go', 'GET /users and POST /login?\n', matcher, 10);
+      expect(matches.map((m) => m.groups)).toEqual([['GET', '/users', ''], ['POST', '/login', '?']]);
+      expect(matchContent('a.go', 'GET\n', buildMatcher('GET'), 10)[0].groups).toBeUndefined();
+    });
+  });
+
+  describe('trigram index', () => {
+    let workspace: string;
+
+    beforeAll(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'trigram-'));
+      fs.writeFileSync(path.join(workspace, 'a.go'), 'func ValidateEmail(addr string) bool {}\n');
+      fs.writeFileSync(path.join(workspace, 'b.go'), 'func OpenDatabase(dsn string) {}\n');
+    });
+
+    afterAll(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should extract lowercase trigrams', () => {
+      expect(Array.from(extractTrigrams('AbCd'))).toEqual(['abc', 'bcd']);
+    });
+
+    it('should narrow candidates to files containing the literal', async () => {
+      const index = new TrigramIndex(workspace);
+      await index.refresh();
+      expect(index.candidates('validateemail')).toEqual(['a.go']);
+      expect(index.candidates('string')).toEqual(['a.go', 'b.go']);
+      expect(index.candidates('xy')).toBeNull();
+    });
+
+    it('should pick up changed files on refresh', async () => {
+      const index = new TrigramIndex(workspace);
+      await index.refresh();
+      fs.writeFileSync(path.join(workspace, 'b.go'), 'func OpenDatabase(dsn string) { ValidateEmail(dsn) }\n');
+      // The new content has a different size, so the change is detected regardless of mtime resolution
+      const result = await searchLexical(workspace, 'ValidateEmail', { caseSensitive: true }, index);
+      expect(result.matches.map((m) => m.filePath)).toEqual(['a.go', 'b.go']);
+    });
+  });
+
+  describe('worker pool', () => {
+    it('should keep input order and stop dispatching on request', async () => {
+      let done = 0;
+      const results = await runPool([30, 10, 20, 5, 5], async (delay) => {
+        await new Promise((resolve) => setTimeout(resolve, delay));
+        done++;
+        return delay;
+      }, { concurrency: 2, shouldStop: () => done >= 3 });
+      expect(results.slice(0, 3)).toEqual([30, 10, 20]);
+      expect(results[4]).toBeUndefined();
+    });
+
+    it('should bound concurrent tasks', async () => {
+      const limit = createLimiter(2);
+      let active = 0;
+      let peak = 0;
+      await Promise.all(Array.from({ length: 6 }, () => limit(async () => {
+        peak = Math.max(peak, ++active);
+        await new Promise((resolve) => setTimeout(resolve, 5));
+        active--;
+      })));
+      expect(peak).toBe(2);
+    });
+
+    it('should run one worker at a time near the memory budget', async () => {
+      let active = 0;
+      let peak = 0;
+      await runPool([1, 2, 3, 4], async () => {
+        peak = Math.max(peak, ++active);
+        await new Promise((resolve) => setTimeout(resolve, 5));
+        active--;
+      }, { concurrency: 4, memory: new MemoryBudget(1) });
+      expect(peak).toBe(1);
+    });
+
+    it('should cap the worker count from the environment', () => {
+      expect(workerCount({ SEARCH_MAX_WORKERS: '1' })).toBe(1);
+      expect(workerCount({})).toBeGreaterThanOrEqual(1);
+    });
+  });
+
+  it('should skip binary files unless asked to search them', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'a.ts'), 'const needle = 1;\n');
+      fs.writeFileSync(path.join(workspace, 'blob.dat'), Buffer.concat([Buffer.from([0, 1, 2]), Buffer.from('needle')]));
+      fs.writeFileSync(path.join(workspace, 'image.png'), 'needle');
+
+      const text = await searchLexical(workspace, 'needle');
+      expect(text.matches.map((m) => m.filePath)).toEqual(['a.ts']);
+      expect(text.binarySkipped).toBe(1);
+
+      const binary = await searchLexical(workspace, 'needle', { binary: true });
+      expect(binary.matches.map((m) => [m.filePath, m.byteOffset])).toEqual([
+        ['a.ts', undefined],
+        ['blob.dat', 3],
+        ['image.png', 0],
+      ]);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should count refreshing the index toward the time limit', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'a.ts'), 'needle\n');
+      // A refresh that never finishes, as a first index build of a huge workspace would seem
+      const index = new TrigramIndex(workspace);
+      index.refresh = () => new Promise(() => {});
+      const started = Date.now();
+      const result = await searchLexical(workspace, 'needle', { timeoutMs: 50 }, index);
+      expect(Date.now() - started).toBeLessThan(5000);
+      expect(result).toMatchObject({ truncatedByTimeout: true, filesScanned: 0, matches: [] });
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should stop scanning once its signal is aborted', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      for (let i = 0; i < 20; i++) {
+        fs.writeFileSync(path.join(workspace, `f${i}.ts`), 'needle\n');
+      }
+      const abort = new AbortController();
+      const result = await searchLexical(workspace, 'needle', {
+        concurrency: 1,
+        signal: abort.signal,
+        onMatches: (_batch, filesScanned) => filesScanned >= 3 && abort.abort(),
+      });
+      expect(result.filesScanned).toBeLessThan(20);
+      expect(result.matches.length).toBe(result.filesScanned);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should decode UTF-16 and Latin-1 files and normalize line endings', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      const utf16 = Buffer.concat([Buffer.from([0xff, 0xfe]), Buffer.from('first\r\nconst café = needle;\r\n', 'utf16le')]);
+      fs.writeFileSync(path.join(workspace, 'utf16.cs'), utf16);
+      const utf16be = Buffer.from(Buffer.concat([Buffer.from([0xff, 0xfe]), Buffer.from('needle\n', 'utf16le')])).swap16();
+      fs.writeFileSync(path.join(workspace, 'utf16be.txt'), utf16be);
+      fs.writeFileSync(path.join(workspace, 'latin1.txt'), Buffer.from('résumé\n\xe9t\xe9 needle\n', 'latin1'));
+      fs.writeFileSync(path.join(workspace, 'mac.txt'), 'one\rtwo\rneedle\r');
+
+      const result = await searchLexical(workspace, 'needle');
+      expect(result.binarySkipped).toBe(0);
+      expect(result.matches.map((m) => [m.filePath, m.line, m.column])).toEqual([
+        ['latin1.txt', 2, 5],
+        ['mac.txt', 3, 1],
+        ['utf16.cs', 2, 14],
+        ['utf16be.txt', 1, 1],
+      ]);
+      expect(result.matches[0].lineText).toBe('été needle');
+
+      // The index sees the same decoded text
+      const index = new TrigramIndex(workspace);
+      await index.refresh();
+      expect(index.candidates('café')).toEqual(['utf16.cs']);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should report the content hash of the snapshot each match came from', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'a.ts'), 'needle\nneedle\n');
+      const before = await searchLexical(workspace, 'needle');
+      const hash = snapshotHash(fs.readFileSync(path.join(workspace, 'a.ts')));
+      expect(before.matches.map((m) => m.contentHash)).toEqual([hash, hash]);
+
+      fs.writeFileSync(path.join(workspace, 'a.ts'), 'needle\n');
+      const after = await searchLexical(workspace, 'needle');
+      expect(after.matches[0].contentHash).not.toBe(hash);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should keep context lines from the content it searched', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'a.ts'), 'one\ntwo\nneedle\nfour\n');
+      const result = await searchLexical(workspace, 'needle', { contextLines: 1 });
+      expect(result.matches[0].context).toEqual({ before: ['two'], after: ['four'] });
+      expect((await searchLexical(workspace, 'needle')).matches[0].context).toBeUndefined();
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should skip generated files unless included', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.writeFileSync(path.join(workspace, '.gitattributes'), 'gen/** linguist-generated\ngen/keep.ts -linguist-generated\n');
+      fs.mkdirSync(path.join(workspace, 'gen'));
+      fs.writeFileSync(path.join(workspace, 'gen', 'types.ts'), 'needle\n');
+      fs.writeFileSync(path.join(workspace, 'gen', 'keep.ts'), 'needle\n');
+      fs.writeFileSync(path.join(workspace, 'api.pb.go'), 'needle\n');
+      fs.writeFileSync(path.join(workspace, 'mock.go'), '// Code generated by MockGen. DO NOT EDIT.\nneedle\n');
+      fs.writeFileSync(path.join(workspace, 'main.go'), 'needle\n');
+
+      const result = await searchLexical(workspace, 'needle');
+      expect(result.matches.map((m) => m.filePath)).toEqual([path.join('gen', 'keep.ts'), 'main.go']);
+      expect(result.generatedSkipped).toBe(3);
+
+      const all = await searchLexical(workspace, 'needle', { includeGenerated: true });
+      expect(all.matches).toHaveLength(5);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should search code, fixtures, or both by scope', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    process.env.WORKSPACE_FIXTURE_PATTERNS = '**/golden/**, !*.snap';
+    try {
+      fs.mkdirSync(path.join(workspace, 'parser', 'testdata'), { recursive: true });
+      fs.mkdirSync(path.join(workspace, 'ui', '__snapshots__'), { recursive: true });
+      fs.mkdirSync(path.join(workspace, 'golden'));
+      fs.writeFileSync(path.join(workspace, 'parser', 'parse.go'), 'func needle() {}\n');
+      fs.writeFileSync(path.join(workspace, 'parser', 'testdata', 'input.go'), 'func needle() {}\n');
+      fs.writeFileSync(path.join(workspace, 'parser', 'parse.golden'), 'needle\n');
+      fs.writeFileSync(path.join(workspace, 'ui', '__snapshots__', 'view.test.ts.snap'), 'needle\n');
+      fs.writeFileSync(path.join(workspace, 'ui', 'view.snap'), 'needle\n');
+      fs.writeFileSync(path.join(workspace, 'golden', 'out.txt'), 'needle\n');
+
+      const code = await searchLexical(workspace, 'needle', { scope: 'code' });
+      // *.snap is no longer a fixture pattern, but __snapshots__/ still is
+      expect(code.matches.map((m) => m.filePath)).toEqual([path.join('parser', 'parse.go'), path.join('ui', 'view.snap')]);
+      expect(code.fixturesSkipped).toBe(4);
+
+      const fixtures = await searchLexical(workspace, 'needle', { scope: 'fixtures' });
+      expect(fixtures.matches.map((m) => m.filePath).sort()).toEqual([
+        path.join('golden', 'out.txt'),
+        path.join('parser', 'parse.golden'),
+        path.join('parser', 'testdata', 'input.go'),
+        path.join('ui', '__snapshots__', 'view.test.ts.snap'),
+      ]);
+      expect(fixtures.fixturesSkipped).toBe(0);
+
+      const all = await searchLexical(workspace, 'needle');
+      expect(all.matches).toHaveLength(6);
+    } finally {
+      delete process.env.WORKSPACE_FIXTURE_PATTERNS;
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should report oversized files and clip long lines', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'big.ts'), 'needle\n' + 'x'.repeat(4096));
+      fs.writeFileSync(path.join(workspace, 'min.js'), 'é'.repeat(1000) + 'needle' + 'y'.repeat(1000));
+
+      const result = await searchLexical(workspace, 'needle', { maxFileSize: 3500, maxLineLength: 100 });
+      expect(result.largeFilesSkipped).toEqual([{ filePath: 'big.ts', size: 4103 }]);
+      const [match] = result.matches;
+      expect(match.lineText.length).toBe(100);
+      expect(match.lineText).toContain('needle');
+      expect(match.clipped).toEqual({ lineLength: 2006, matchByteOffset: 2000, windowStart: 954 });
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should return the same truncated results regardless of concurrency', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      for (let i = 0; i < 20; i++) {
+        fs.writeFileSync(path.join(workspace, `f${String(i).padStart(2, '0')}.ts`), 'needle\nneedle\n');
+      }
+      const sequential = await searchLexical(workspace, 'needle', { maxResults: 7, concurrency: 1 });
+      const parallel = await searchLexical(workspace, 'needle', { maxResults: 7, concurrency: 8 });
+      expect(parallel.matches).toEqual(sequential.matches);
+      expect(parallel.truncated).toBe(true);
+      expect(parallel.matches[6]).toMatchObject({ filePath: 'f03.ts', line: 1 });
+
+      // Partial results arrive in file order and never exceed maxResults
+      const batches: string[] = [];
+      const streamed = await searchLexical(workspace, 'needle', {
+        maxResults: 7,
+        concurrency: 8,
+        onMatches: (batch) => batches.push(...batch.map((m) => `${m.filePath}:${m.line}`)),
+      });
+      expect(batches).toEqual(streamed.matches.map((m) => `${m.filePath}:${m.line}`));
+
+      expect(parallel.truncatedByTimeout).toBe(false);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should count the matches past maxResults per file with countOmitted', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      for (let i = 0; i < 20; i++) {
+        fs.writeFileSync(path.join(workspace, `f${String(i).padStart(2, '0')}.ts`), 'needle\nneedle\n');
+      }
+      fs.writeFileSync(path.join(workspace, 'f00.ts'), 'needle\n'.repeat(5));
+      for (const concurrency of [1, 8]) {
+        const result = await searchLexical(workspace, 'needle', { maxResults: 7, countOmitted: true, concurrency });
+        expect(result.matches.length).toBe(7);
+        expect(result.matches[6]).toMatchObject({ filePath: 'f01.ts', line: 2 });
+        expect(result.truncated).toBe(true);
+        expect(result.omittedMatches).toBe(5 + 19 * 2 - 7);
+        expect(result.omittedByFile?.slice(0, 2)).toEqual([{ filePath: 'f02.ts', count: 2 }, { filePath: 'f03.ts', count: 2 }]);
+        expect(result.omittedByFile?.length).toBe(18);
+      }
+      const exact = await searchLexical(workspace, 'needle', { maxResults: 43, countOmitted: true });
+      expect(exact.truncated).toBe(false);
+      expect(exact.omittedByFile).toEqual([]);
+      const clipped = await searchLexical(workspace, 'needle', { maxResults: 3, countOmitted: true });
+      expect(clipped.omittedByFile?.[0]).toEqual({ filePath: 'f00.ts', count: 2 });
+      expect((await searchLexical(workspace, 'needle', { maxResults: 7 })).omittedMatches).toBeUndefined();
+      for (const maxResults of [NaN, -1, 2.5]) {
+        expect((await searchLexical(workspace, 'needle', { maxResults }).catch((e) => e)).code).toBe('invalid-argument');
+      }
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should take the default match limit from SEARCH_MAX_RESULTS only when it is a positive integer', () => {
+    expect(maxResultsFromEnv({ SEARCH_MAX_RESULTS: '250' })).toBe(250);
+    for (const value of [undefined, '', 'many', '0', '-5', '1.5']) {
+      expect(maxResultsFromEnv({ SEARCH_MAX_RESULTS: value })).toBeUndefined();
+    }
+  });
+
+  it('should take the file size limit from SEARCH_MAX_FILE_SIZE only when it is a positive integer', () => {
+    expect(maxFileSizeFromEnv({ SEARCH_MAX_FILE_SIZE: '2048' })).toBe(2048);
+    for (const value of [undefined, '', '10MB', '0', '-1', '2.5']) {
+      expect(maxFileSizeFromEnv({ SEARCH_MAX_FILE_SIZE: value })).toBeUndefined();
+    }
+  });
+
+  it('should take the line length limit from SEARCH_MAX_LINE_LENGTH only when it is a positive integer', () => {
+    expect(maxLineLengthFromEnv({ SEARCH_MAX_LINE_LENGTH: '80' })).toBe(80);
+    for (const value of [undefined, '', 'wide', '0', '-1', '2.5']) {
+      expect(maxLineLengthFromEnv({ SEARCH_MAX_LINE_LENGTH: value })).toBeUndefined();
+    }
+  });
+
+  it('should take the search timeout from SEARCH_TIMEOUT_MS only when it is a whole number', () => {
+    expect(searchTimeoutFromEnv({ SEARCH_TIMEOUT_MS: '5000' })).toBe(5000);
+    expect(searchTimeoutFromEnv({ SEARCH_TIMEOUT_MS: '0' })).toBe(0);
+    for (const value of [undefined, '', '5s', '-1', '2.5']) {
+      expect(searchTimeoutFromEnv({ SEARCH_TIMEOUT_MS: value })).toBe(30000);
+    }
+  });
+
+  it('should build the shared search defaults from the environment', () => {
+    const defaults = searchDefaultsFromEnv({ SEARCH_MAX_RESULTS: '20', SEARCH_MAX_LINE_LENGTH: 'wide', SEARCH_TIMEOUT_MS: '100' });
+    expect(defaults.maxResults).toBe(20);
+    expect(defaults.maxFileSize).toBeUndefined();
+    expect(defaults.maxLineLength).toBeUndefined();
+    expect(defaults.timeoutMs).toBe(100);
+  });
+
+  it('should return partial results once the memory budget is reached', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'a.ts'), 'const needle = 1;\n');
+      fs.writeFileSync(path.join(workspace, 'b.ts'), 'const needle = 2;\n');
+
+      const exhausted = new MemoryBudget(1);
+      const partial = await searchLexical(workspace, 'needle', { memory: exhausted });
+      expect(partial.truncatedByMemory).toBe(true);
+      expect(partial.filesScanned).toBe(0);
+
+      // Files left out of the index are still searched
+      const index = new TrigramIndex(workspace, exhausted);
+      expect((await index.refresh()).unindexed).toBe(2);
+      expect(index.candidates('needle')).toEqual(['a.ts', 'b.ts']);
+      const result = await searchLexical(workspace, 'needle', { memory: new MemoryBudget(Infinity) }, index);
+      expect(result.truncatedByMemory).toBe(false);
+      expect(result.matches.map((m) => m.filePath)).toEqual(['a.ts', 'b.ts']);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should search only the given files or those files first', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'lexical-'));
+    try {
+      fs.mkdirSync(path.join(workspace, 'src'));
+      for (const name of ['a.ts', 'b.ts', 'src/c.ts']) {
+        fs.writeFileSync(path.join(workspace, name), 'needle\n');
+      }
+      const only = await searchLexical(workspace, 'needle', { files: [path.join('src', 'c.ts'), 'missing.ts'] });
+      expect(only.matches.map((m) => m.filePath)).toEqual([path.join('src', 'c.ts')]);
+
+      const first = await searchLexical(workspace, 'needle', { firstFiles: ['src/c.ts'], maxResults: 2 });
+      expect(first.matches.map((m) => m.filePath)).toEqual([path.join('src', 'c.ts'), 'a.ts']);
+      expect(first.truncated).toBe(true);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,809 @@
+/**
+ * Lexical search - literal and regular expression matching over workspace files
+ */
+
+import * as crypto from 'crypto';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { excludedFromWalk, walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { WatcherConfig, defaultWatcherConfig } from '../watcher/watcher.js';
+import { runPool } from '../workspace/pool.js';
+import { MemoryBudget, sharedMemoryBudget } from '../workspace/memory.js';
+import { isBinaryFile } from '../workspace/binary.js';
+import { ArchiveLimits, archiveExtensions, archiveLimitsFromEnv, archiveSizeLimit, isArchive, readArchive } from '../workspace/archive.js';
+import { decodeText } from '../workspace/encoding.js';
+import { GeneratedFileDetector } from '../workspace/generated.js';
+import { BuildContext, matchesBuildContext } from '../workspace/buildtags.js';
+import { documentationFormat, headingChain, readHeadings } from './headings.js';
+import { NotebookCell, isNotebook, readNotebookCells } from './notebook.js';
+import { matchesGlob } from '../workspace/glob.js';
+import { SearchScope, fixturePatterns, inSearchScope } from '../workspace/fixtures.js';
+import { commentSpans, hasCommentSyntax, inComment } from './comments.js';
+import { currentBudget, unbudgeted } from '../workspace/budget.js';
+import { currentSnapshot } from '../workspace/treesnapshot.js';
+import { ToolError } from '../workspace/errors.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileBytes, sharedOverlay } from '../workspace/overlay.js';
+import { TrigramIndex } from './trigram.js';
+import { identifierMatchers, identifierWords } from './identifiers.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for lexical search
+ */
+export interface LexicalSearchOptions {
+  regex?: boolean;
+  caseSensitive?: boolean;
+  wholeWord?: boolean;
+  // Match identifiers made of the pattern's words, e.g. "find user id" matches FindUserByID and find_user_id
+  identifierWords?: boolean;
+  path?: string;
+  // Only search files matching one of these globs (e.g. "**/*.go")
+  glob?: string | string[];
+  // Skip files matching one of these globs
+  excludeGlob?: string | string[];
+  // Only search files of these LSP language identifiers (e.g. "go", "typescriptreact")
+  languages?: string[];
+  // Only search these files, relative to the workspace (e.g. the pinned working set)
+  files?: string[];
+  // Search these files first, so their matches lead the results and are kept when the rest are truncated
+  firstFiles?: string[];
+  // Reorder the files to search, e.g. by modification time; matches are kept in this order, so maxResults keeps those of the first files
+  orderFiles?: (relativePaths: string[]) => Promise<string[]>;
+  // Code, test fixtures and golden files (fixturePatterns()), or both; comments matches code files only in comments and docstrings (default: 'all')
+  scope?: SearchScope;
+  maxResults?: number;
+  // Keep scanning past maxResults to count the matches left out, per file and in all (default: false)
+  countOmitted?: boolean;
+  // Stop and return partial results after this long (0 or unset: no limit)
+  timeoutMs?: number;
+  // Stop scanning when aborted, e.g. by a client that went away; the result is partial
+  signal?: AbortSignal;
+  // Skip files larger than this many bytes (default: 10MB)
+  maxFileSize?: number;
+  // Clip longer lines to a window around the match (default: 500 characters)
+  maxLineLength?: number;
+  // Keep this many lines before and after each match from the content searched; notebook cells get none (default: 0)
+  contextLines?: number;
+  // Also search generated files (linguist-generated, "Code generated ... DO NOT EDIT"), lock files, and minified bundles
+  includeGenerated?: boolean;
+  // Also search binary files, reporting byte offsets instead of lines
+  binary?: boolean;
+  // Follow symbolic links, reporting each physical file once (default: WORKSPACE_FOLLOW_SYMLINKS)
+  followSymlinks?: boolean;
+  // Also search the entries of zip, jar, and tar archives, as "<archive>!/<entry>"
+  archives?: boolean;
+  // Nesting and size limits for archives (entries default to maxFileSize)
+  archiveLimits?: ArchiveLimits;
+  // Only search Go files that are part of the build for this target
+  goBuild?: BuildContext;
+  // Concurrent file reads (default: workerCount())
+  concurrency?: number;
+  // Scanning slows near this budget and stops at it (default: sharedMemoryBudget())
+  memory?: MemoryBudget;
+  // Asked about each file's matches before they count; keeps the ones it returns (e.g. a WASM matcher's)
+  matchFilter?: (filePath: string, matches: LexicalMatch[]) => Promise<LexicalMatch[]>;
+  // Called with each file's matches, in file order, as soon as they are known
+  onMatches?: (matches: LexicalMatch[], filesScanned: number, totalFiles: number) => void;
+}
+
+/**
+ * A single match
+ */
+export interface LexicalMatch {
+  filePath: string; // Relative to the workspace
+  line: number; // 1-indexed
+  column: number; // 1-indexed
+  length: number;
+  lineText: string; // Empty for binary matches
+  byteOffset?: number; // Set for matches in binary files, which have no lines
+  clipped?: ClippedLine; // Set when lineText is a window of a longer line
+  contentHash?: string; // snapshotHash of the file content the match was found in
+  headings?: string[]; // Enclosing section titles in Markdown and AsciiDoc, outermost first
+  cell?: { index: number; kind: string }; // Notebook cell the match is in; line counts from the cell's first line
+  groups?: string[]; // Capture groups of a regex match; '' for groups that took no part
+  duplicates?: string[]; // Files with identical content whose matches were left out, set on a file's first match
+  module?: string; // Package, module, or namespace of the file, when context is requested
+  enclosing?: EnclosingSymbol; // Innermost symbol around the match, when context is requested
+  ranges?: MatchRange[]; // Every match on the line, in order, when merged by mergeLineMatches
+  gitStatus?: string; // The file's uncommitted changes ("staged, modified", "untracked") or "committed", when requested
+  directoryDoc?: DirectoryDoc; // What the file's directory is for, from its nearest README or package comment, when requested
+  context?: { before: string[]; after: string[] }; // Lines around the match, when a result template asks for them
+  astPath?: string[]; // Named syntax nodes the match is in, outermost first, when requested and a grammar is installed
+  lastChange?: LineChange; // Last commit touching the line, when requested
+  coverage?: LineCoverage; // Whether tests ran the line, when a coverage profile is given and instruments it
+}
+
+/**
+ * Columns of a match within its line: 1-indexed, end exclusive, counted in
+ * the full line (subtract clipped.windowStart to slice a clipped lineText)
+ */
+export interface MatchRange {
+  start: number;
+  end: number;
+}
+
+/**
+ * The symbol a match is inside
+ */
+export interface EnclosingSymbol {
+  name: string; // Qualified name, e.g. Server.handle
+  kind: string; // Symbol kind name, e.g. Method
+  signature?: string; // Declaration line
+  line: number; // 1-indexed line of the declaration
+}
+
+/**
+ * The last commit touching a line, from git blame
+ */
+export interface LineChange {
+  commit: string; // Full hash, all zeros for lines not committed yet
+  time: number; // Author time, Unix seconds
+  subject: string;
+}
+
+/**
+ * How often the tests ran a line, from a coverage profile
+ */
+export interface LineCoverage {
+  hits: number;
+  counted: boolean; // False when the profile only records whether lines ran (go test -covermode=set)
+}
+
+/**
+ * Summary of a directory from its documentation
+ */
+export interface DirectoryDoc {
+  directory: string; // Relative to the workspace
+  source: string; // File the summary is taken from, e.g. api/README.md
+  summary: string;
+}
+
+/**
+ * Where a clipped line's window sits in the original line
+ */
+export interface ClippedLine {
+  lineLength: number; // Characters in the full line
+  matchByteOffset: number; // UTF-8 byte offset of the match within the line
+  windowStart: number; // 1-indexed column where lineText starts
+}
+
+/**
+ * Result of a lexical search
+ */
+export interface LexicalSearchResult {
+  matches: LexicalMatch[];
+  filesScanned: number;
+  truncated: boolean;
+  // The time limit was reached; matches cover only the files scanned so far
+  truncatedByTimeout: boolean;
+  // The memory budget was reached; matches cover only the files scanned so far
+  truncatedByMemory: boolean;
+  // The cost budget spent, as BudgetExceededError says it; matches cover only the files scanned so far
+  truncatedByBudget?: string;
+  // Files skipped because they look binary
+  binarySkipped: number;
+  // Files skipped because they are generated
+  generatedSkipped: number;
+  // Go files left out by goBuild
+  buildExcluded: number;
+  // Test fixtures left out by scope 'code'
+  fixturesSkipped: number;
+  // Files skipped for exceeding maxFileSize
+  largeFilesSkipped: Array<{ filePath: string; size: number }>;
+  // With archives, the archives not searched or searched only in part, and why
+  archivesSkipped: Array<{ filePath: string; reason: string }>;
+  // With countOmitted, the matches past maxResults, in all and per file in scan
+  // order; counts cover only the files scanned before a time or memory limit
+  omittedMatches?: number;
+  omittedByFile?: Array<{ filePath: string; count: number }>;
+}
+
+/**
+ * Hash of file content as read for a query: the first 16 hex digits of its SHA-256
+ * Consumers compare it with the current file to detect stale results
+ */
+export function snapshotHash(data: Buffer): string {
+  return crypto.createHash('sha256').update(data).digest('hex').substring(0, 16);
+}
+
+/**
+ * Default match limit from SEARCH_MAX_RESULTS, or undefined for 100 when unset or not a positive integer
+ */
+export function maxResultsFromEnv(env: NodeJS.ProcessEnv = process.env): number | undefined {
+  const value = Number(env.SEARCH_MAX_RESULTS);
+  return env.SEARCH_MAX_RESULTS && Number.isInteger(value) && value > 0 ? value : undefined;
+}
+
+/**
+ * File size limit from SEARCH_MAX_FILE_SIZE, or undefined for the walker's 10MB when unset or not a positive integer
+ */
+export function maxFileSizeFromEnv(env: NodeJS.ProcessEnv = process.env): number | undefined {
+  const value = Number(env.SEARCH_MAX_FILE_SIZE);
+  return env.SEARCH_MAX_FILE_SIZE && Number.isInteger(value) && value > 0 ? value : undefined;
+}
+
+/**
+ * Line length limit from SEARCH_MAX_LINE_LENGTH, or undefined for 500 when unset or not a positive integer
+ */
+export function maxLineLengthFromEnv(env: NodeJS.ProcessEnv = process.env): number | undefined {
+  const value = Number(env.SEARCH_MAX_LINE_LENGTH);
+  return env.SEARCH_MAX_LINE_LENGTH && Number.isInteger(value) && value > 0 ? value : undefined;
+}
+
+/**
+ * Search time limit from SEARCH_TIMEOUT_MS (0 disables), or 30000 when unset or not a whole number
+ */
+export function searchTimeoutFromEnv(env: NodeJS.ProcessEnv = process.env): number {
+  const value = Number(env.SEARCH_TIMEOUT_MS);
+  return env.SEARCH_TIMEOUT_MS && Number.isInteger(value) && value >= 0 ? value : 30000;
+}
+
+/**
+ * Search options a caller leaves unset, from the environment
+ * search_code, the search command and the HTTP server all start from these
+ */
+export function searchDefaultsFromEnv(env: NodeJS.ProcessEnv = process.env): LexicalSearchOptions {
+  return {
+    maxResults: maxResultsFromEnv(env),
+    maxFileSize: maxFileSizeFromEnv(env),
+    maxLineLength: maxLineLengthFromEnv(env),
+    archiveLimits: archiveLimitsFromEnv(env),
+    timeoutMs: searchTimeoutFromEnv(env),
+  };
+}
+
+/**
+ * Escape a string for use in a regular expression
+ */
+export function escapeRegExp(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+/**
+ * Wait for a promise until a deadline; false when the deadline came first
+ * The promise keeps running, and a later failure of it is ignored
+ */
+async function settledBefore(promise: Promise<unknown>, deadline: number): Promise<boolean> {
+  if (deadline === Infinity) {
+    await promise;
+    return true;
+  }
+  let timer: NodeJS.Timeout | undefined;
+  const expired = new Promise<boolean>((resolve) => {
+    timer = setTimeout(() => resolve(false), Math.max(0, deadline - Date.now()));
+  });
+  try {
+    return await Promise.race([promise.then(() => true), expired]);
+  } finally {
+    clearTimeout(timer);
+  }
+}
+
+/**
+ * Build the matcher for a search pattern
+ */
+export function buildMatcher(pattern: string, options: LexicalSearchOptions = {}): RegExp {
+  let source = options.regex ? pattern : escapeRegExp(pattern);
+  if (options.wholeWord) {
+    source = `(?<![\\w$])(?:${source})(?![\\w$])`;
+  }
+  try {
+    return new RegExp(source, options.caseSensitive ? 'g' : 'gi');
+  } catch (err) {
+    throw new ToolError('pattern-invalid', `Invalid regular expression: ${(err as Error).message}`);
+  }
+}
+
+/**
+ * Find all matches of a matcher in file content
+ */
+export function matchContent(
+  filePath: string,
+  content: string,
+  matcher: RegExp,
+  limit: number,
+  deadline = Infinity
+): LexicalMatch[] {
+  const matches: LexicalMatch[] = [];
+  const lines = content.split('\n');
+
+  for (let i = 0; i < lines.length && matches.length < limit; i++) {
+    // Checking the clock every line is measurable on large files
+    if (i % 1000 === 999 && Date.now() >= deadline) {
+      break;
+    }
+    const lineText = lines[i].replace(/\r$/, '');
+    matcher.lastIndex = 0;
+    let match: RegExpExecArray | null;
+    while ((match = matcher.exec(lineText)) !== null) {
+      matches.push({
+        filePath,
+        line: i + 1,
+        column: match.index + 1,
+        length: match[0].length,
+        lineText,
+        ...(match.length > 1 ? { groups: match.slice(1).map((group) => group ?? '') } : {}),
+      });
+      if (matches.length >= limit) {
+        break;
+      }
+      // Avoid looping forever on empty matches
+      if (match[0].length === 0) {
+        matcher.lastIndex++;
+      }
+    }
+  }
+
+  return matches;
+}
+
+/**
+ * Find all matches of a matcher in the cells of a notebook
+ * Returns undefined when the content is not a readable notebook
+ */
+export function matchNotebook(
+  filePath: string,
+  content: string,
+  matcher: RegExp,
+  limit: number,
+  deadline = Infinity
+): LexicalMatch[] | undefined {
+  let cells: NotebookCell[];
+  try {
+    cells = readNotebookCells(content);
+  } catch (err) {
+    toolsLogger.debug('Could not read notebook %s, searching it as text: %s', filePath, (err as Error).message);
+    return undefined;
+  }
+  const matches: LexicalMatch[] = [];
+  for (const cell of cells) {
+    if (matches.length >= limit) {
+      break;
+    }
+    for (const match of matchContent(filePath, cell.source, matcher, limit - matches.length, deadline)) {
+      matches.push({ ...match, cell: { index: cell.index, kind: cell.kind } });
+    }
+  }
+  return matches;
+}
+
+/**
+ * Clip a long line to a window around the match
+ * Matches in minified files can sit on lines of megabytes; only the
+ * surrounding context is kept
+ */
+export function clipMatchLine(match: LexicalMatch, maxLength: number): LexicalMatch {
+  const { lineText } = match;
+  if (lineText.length <= maxLength) {
+    return match;
+  }
+  const matchStart = match.column - 1;
+  const context = Math.max(0, Math.floor((maxLength - Math.min(match.length, maxLength)) / 2));
+  const start = Math.max(0, Math.min(matchStart - context, lineText.length - maxLength));
+  return {
+    ...match,
+    lineText: lineText.substring(start, start + maxLength),
+    clipped: {
+      lineLength: lineText.length,
+      matchByteOffset: Buffer.byteLength(lineText.substring(0, matchStart), 'utf8'),
+      windowStart: start + 1,
+    },
+  };
+}
+
+/**
+ * Merge the matches on one line into its first match, which lists them all in
+ * ranges; binary matches are kept as they are
+ */
+export function mergeLineMatches(matches: LexicalMatch[]): LexicalMatch[] {
+  const merged: LexicalMatch[] = [];
+  let previous: LexicalMatch | undefined;
+  for (const match of matches) {
+    const range = { start: match.column, end: match.column + match.length };
+    const sameLine = previous && match.byteOffset === undefined && previous.filePath === match.filePath &&
+      previous.line === match.line && previous.cell?.index === match.cell?.index;
+    if (sameLine) {
+      previous!.ranges!.push(range);
+      continue;
+    }
+    previous = match.byteOffset === undefined ? { ...match, ranges: [range] } : undefined;
+    merged.push(previous ?? match);
+  }
+  return merged;
+}
+
+/**
+ * Find matches in binary content, reported by byte offset
+ */
+export function matchBinary(filePath: string, content: Buffer, matcher: RegExp, limit: number): LexicalMatch[] {
+  // latin1 maps every byte to one character, so string indices are byte offsets
+  const text = content.toString('latin1');
+  const matches: LexicalMatch[] = [];
+  matcher.lastIndex = 0;
+  let match: RegExpExecArray | null;
+  while (matches.length < limit && (match = matcher.exec(text)) !== null) {
+    matches.push({ filePath, line: 0, column: 0, length: match[0].length, lineText: '', byteOffset: match.index });
+    if (match[0].length === 0) {
+      matcher.lastIndex++;
+    }
+  }
+  return matches;
+}
+
+/**
+ * Extension and file name exclusions for a scan, or undefined for the defaults
+ * Binary searches also include files the walker excludes by extension,
+ * archive searches include archives, and searches with includeGenerated
+ * include lock files and minified bundles
+ */
+export function scanExclusions(
+  options: LexicalSearchOptions
+): Partial<Pick<WatcherConfig, 'excludedFileExtensions' | 'largeBinaryExtensions' | 'excludedFileNames'>> | undefined {
+  const names = options.includeGenerated ? { excludedFileNames: [] } : {};
+  if (options.binary) {
+    return { ...names, excludedFileExtensions: new Set<string>(), largeBinaryExtensions: new Set<string>() };
+  }
+  if (!options.archives) {
+    return options.includeGenerated ? names : undefined;
+  }
+  const defaults = defaultWatcherConfig();
+  const lifted = new Set(archiveExtensions());
+  return {
+    ...names,
+    excludedFileExtensions: new Set([...defaults.excludedFileExtensions].filter((ext) => !lifted.has(ext))),
+    largeBinaryExtensions: new Set([...defaults.largeBinaryExtensions].filter((ext) => !lifted.has(ext))),
+  };
+}
+
+/**
+ * Files among every non-null candidate list; null when no list filters
+ */
+function intersectCandidates(lists: Array<string[] | null>): string[] | null {
+  const filtering = lists.filter((list): list is string[] => list !== null);
+  if (filtering.length === 0) {
+    return null;
+  }
+  return filtering.reduce((kept, list) => {
+    const listed = new Set(list);
+    return kept.filter((file) => listed.has(file));
+  });
+}
+
+/**
+ * Search the workspace for a literal or regular expression
+ * Literal searches use the trigram index, when given, to skip files that cannot match
+ */
+export async function searchLexical(
+  workspaceDir: string,
+  pattern: string,
+  options: LexicalSearchOptions = {},
+  index?: TrigramIndex
+): Promise<LexicalSearchResult> {
+  const maxResults = options.maxResults ?? 100;
+  const maxLineLength = options.maxLineLength ?? 500;
+  if (!Number.isInteger(maxResults) || maxResults < 0) {
+    throw new ToolError('invalid-argument', `maxResults must be a whole number of matches, got ${maxResults}`);
+  }
+  if (options.identifierWords && options.regex) {
+    throw new ToolError('invalid-argument', 'identifierWords matches the words of identifiers; it cannot be combined with regex');
+  }
+  const words = options.identifierWords ? identifierWords(pattern) : undefined;
+  const wordMatchers = words && identifierMatchers(words);
+  const matcher = wordMatchers ? wordMatchers('plaintext') : buildMatcher(pattern, options);
+  // Identifier characters differ by language, so word searches pick a matcher per file
+  const matcherFor = (filePath: string): RegExp => wordMatchers ? wordMatchers(detectLanguageId(filePath)) : matcher;
+  // The time limit covers refreshing the index and walking the workspace, not only the scan
+  const deadline = options.timeoutMs && options.timeoutMs > 0 ? Date.now() + options.timeoutMs : Infinity;
+  let timedOut = false;
+  // Stops further dispatch; marks the result as partial once the deadline passes
+  const pastDeadline = (): boolean => {
+    if (!timedOut && Date.now() >= deadline) {
+      timedOut = true;
+    }
+    return timedOut;
+  };
+  const memory = options.memory ?? sharedMemoryBudget();
+  let outOfMemory = false;
+  const overBudget = (): boolean => {
+    if (!outOfMemory && memory.isExceeded()) {
+      outOfMemory = true;
+      toolsLogger.warn('Memory budget of %d MB reached, returning partial results', Math.round(memory.limitBytes / 1048576));
+    }
+    return outOfMemory;
+  };
+  const budget = currentBudget();
+  const overCostBudget = (): boolean => budget?.exceeded() !== undefined;
+
+  const largeFilesSkipped: Array<{ filePath: string; size: number }> = [];
+  const archivesSkipped: Array<{ filePath: string; reason: string }> = [];
+  // Archives are limited by the data they expand to, not by maxFileSize
+  const archiveLimit = archiveSizeLimit(options.archiveLimits);
+  const skipLargeFile = (filePath: string, size: number) => {
+    if (options.archives && isArchive(filePath)) {
+      archivesSkipped.push({ filePath, reason: `${Math.round(size / 1024)} KB, over the ${Math.round(archiveLimit / 1024)} KB archive size limit` });
+    } else {
+      largeFilesSkipped.push({ filePath, size });
+    }
+  };
+  let files: string[] | null = null;
+  // Binary files, archives, and lock files are not in the trigram index, and it is built with the default symlink policy;
+  // it indexes the files as they are now, not as a pinned snapshot has them
+  if (index && !currentSnapshot() && !options.regex && !options.binary && !options.archives && !options.includeGenerated && options.followSymlinks === undefined) {
+    // Keeping the shared index current is not the query's cost; a refresh
+    // outlasting the time limit goes on for later queries while this one returns
+    if (await settledBefore(unbudgeted(() => index.refresh()), deadline)) {
+      // A file with every word holds the trigrams of each
+      files = words ? intersectCandidates(words.map((word) => index.candidates(word))) : index.candidates(pattern);
+    } else {
+      timedOut = true;
+      files = [];
+    }
+    if (files !== null && options.path) {
+      const prefix = path.relative(workspaceDir, resolveWorkspacePath(workspaceDir, options.path));
+      if (prefix) {
+        files = files.filter((f) => f === prefix || f.startsWith(prefix + path.sep));
+      }
+    }
+  }
+  if (files === null) {
+    const config = { ...scanExclusions(options), ...(options.maxFileSize ? { maxFileSize: options.maxFileSize } : {}) };
+    const sizeLimit = config.maxFileSize ?? defaultWatcherConfig().maxFileSize;
+    files = (await walkWorkspaceFiles(workspaceDir, {
+      pathPrefix: options.path,
+      followSymlinks: options.followSymlinks,
+      config,
+      deadline,
+      ...(options.archives ? { maxFileSizeOf: (filePath: string) => isArchive(filePath) ? archiveLimit : sizeLimit } : {}),
+      onLargeFile: skipLargeFile,
+    })).map((f) => f.relativePath);
+    // A walk cut short by the deadline listed only some of the files
+    pastDeadline();
+    // A pinned snapshot lacks the files created since and has the ones deleted since
+    const snapshot = currentSnapshot();
+    if (snapshot && path.resolve(workspaceDir) === snapshot.workspaceDir) {
+      const prefix = options.path ? path.relative(workspaceDir, resolveWorkspacePath(workspaceDir, options.path)) : '';
+      files = snapshot.files(files, prefix, excludedFromWalk(workspaceDir, { config }));
+    }
+  }
+  // Overlaid files are read whatever the index holds for them, and may not exist on disk
+  const scope = options.path ? resolveWorkspacePath(workspaceDir, options.path) : workspaceDir;
+  const listed = new Set(files);
+  for (const overlaid of sharedOverlay().paths()) {
+    const relativePath = path.relative(workspaceDir, overlaid);
+    const inScope = overlaid === scope || overlaid.startsWith(scope + path.sep);
+    if (inScope && !relativePath.startsWith('..') && !listed.has(relativePath)) {
+      files.push(relativePath);
+    }
+  }
+  if (options.glob && options.glob.length > 0) {
+    const globs = options.glob;
+    files = files.filter((f) => matchesGlob(f, globs));
+  }
+  if (options.excludeGlob && options.excludeGlob.length > 0) {
+    const excluded = options.excludeGlob;
+    files = files.filter((f) => !matchesGlob(f, excluded));
+  }
+  if (options.languages && options.languages.length > 0) {
+    const languages = new Set(options.languages);
+    files = files.filter((f) => languages.has(detectLanguageId(f)));
+  }
+  if (options.files) {
+    const only = new Set(options.files.map((f) => path.normalize(f)));
+    files = files.filter((f) => only.has(f));
+  }
+  if (options.firstFiles && options.firstFiles.length > 0) {
+    const first = new Set(options.firstFiles.map((f) => path.normalize(f)));
+    files = [...files.filter((f) => first.has(f)), ...files.filter((f) => !first.has(f))];
+  }
+  const searchScope = options.scope ?? 'all';
+  let fixturesSkipped = 0;
+  if (searchScope !== 'all') {
+    const patterns = fixturePatterns();
+    const kept = files.filter((f) => inSearchScope(f, searchScope, patterns));
+    fixturesSkipped = searchScope === 'code' || searchScope === 'comments' ? files.length - kept.length : 0;
+    files = kept;
+  }
+  const comments = searchScope === 'comments';
+  if (comments) {
+    files = files.filter((f) => hasCommentSyntax(detectLanguageId(f)));
+  }
+  if (options.orderFiles) {
+    files = await options.orderFiles(files);
+  }
+
+  // Collect one extra match to tell whether the results were truncated
+  const limit = maxResults + 1;
+  // Counting reads every match of a file; only the first ones are kept
+  const fileLimit = options.countOmitted ? Infinity : limit;
+  const fileTotals: number[] = [];
+  let found = 0;
+  let filesScanned = 0;
+
+  // Partial results are reported in file order: a finished file waits until
+  // every file before it has been reported
+  const totalFiles = files.length;
+  const completed: Array<LexicalMatch[] | undefined> = [];
+  let reported = 0;
+  let streamed = 0;
+  const flushCompleted = (): void => {
+    while (completed[reported] !== undefined) {
+      const batch = completed[reported]!.slice(0, maxResults - streamed);
+      completed[reported++] = undefined;
+      if (batch.length > 0) {
+        streamed += batch.length;
+        options.onMatches!(batch, filesScanned, totalFiles);
+      }
+    }
+  };
+
+  // Files are scanned concurrently; dispatch stops once enough matches are found
+  let binarySkipped = 0;
+  let generatedSkipped = 0;
+  let buildExcluded = 0;
+  const generated = options.includeGenerated ? undefined : new GeneratedFileDetector(workspaceDir);
+  const matchData = (filePath: string, data: Buffer): LexicalMatch[] => {
+    let fileMatches: LexicalMatch[];
+    if (!isBinaryFile(filePath, data)) {
+      const content = decodeText(data);
+      if (generated && generated.isGenerated(filePath, content)) {
+        generatedSkipped++;
+        fileMatches = [];
+      } else if (options.goBuild && filePath.endsWith('.go') && !matchesBuildContext(filePath, content, options.goBuild)) {
+        buildExcluded++;
+        fileMatches = [];
+      } else {
+        const fileMatcher = matcherFor(filePath);
+        const notebookMatches = isNotebook(filePath) ? matchNotebook(filePath, content, fileMatcher, fileLimit, deadline) : undefined;
+        fileMatches = notebookMatches ?? matchContent(filePath, content, fileMatcher, comments ? Infinity : fileLimit, deadline);
+        if (comments) {
+          // Matches in code do not count toward the limit
+          const spans = commentSpans(content, detectLanguageId(filePath));
+          fileMatches = fileMatches.filter((match) => inComment(spans, match.line, match.column)).slice(0, fileLimit);
+        }
+        const contextLines = options.contextLines ?? 0;
+        if (contextLines > 0 && !notebookMatches && fileMatches.length > 0) {
+          const lines = content.split('\n');
+          fileMatches.forEach((match) => {
+            match.context = {
+              before: lines.slice(Math.max(0, match.line - 1 - contextLines), match.line - 1),
+              after: lines.slice(match.line, match.line + contextLines),
+            };
+          });
+        }
+        fileMatches = fileMatches.map((match) => clipMatchLine(match, maxLineLength));
+        const format = fileMatches.length > 0 ? documentationFormat(filePath) : undefined;
+        if (format) {
+          const headings = readHeadings(content, format);
+          fileMatches.forEach((match) => {
+            const chain = headingChain(headings, match.line);
+            if (chain.length > 0) {
+              match.headings = chain;
+            }
+          });
+        }
+      }
+    } else if (options.binary) {
+      fileMatches = matchBinary(filePath, data, matcher, fileLimit);
+    } else {
+      binarySkipped++;
+      fileMatches = [];
+    }
+    if (fileMatches.length > 0) {
+      const hash = snapshotHash(data);
+      fileMatches.forEach((match) => {
+        match.contentHash = hash;
+      });
+    }
+    return fileMatches;
+  };
+  let filterError: unknown;
+  const perFile = await runPool(files, async (relativePath, i) => {
+    // Files dispatched after enough matches were found come after all of
+    // them in file order, so their matches are only counted
+    const countOnly = found >= limit;
+    let fileMatches: LexicalMatch[];
+    try {
+      // Each file is read once into a snapshot; every match and line in the
+      // result comes from it, even if the file changes while the query runs
+      const data = await readFileBytes(path.join(workspaceDir, relativePath));
+      const archive = options.archives && isArchive(relativePath);
+      if (archive ? data.length > archiveLimit : options.maxFileSize && data.length > options.maxFileSize) {
+        // Trigram candidates are not filtered by the walker's size limit
+        skipLargeFile(relativePath, data.length);
+        fileMatches = [];
+      } else if (archive) {
+        const contents = readArchive(relativePath, data, { maxEntrySize: options.maxFileSize, ...options.archiveLimits, memory });
+        largeFilesSkipped.push(...contents.largeEntries.map((entry) => ({ filePath: entry.path, size: entry.size })));
+        if (contents.outOfMemory) {
+          archivesSkipped.push({ filePath: relativePath, reason: 'memory budget reached, later entries not read' });
+          outOfMemory = true;
+        } else if (contents.truncated) {
+          archivesSkipped.push({ filePath: relativePath, reason: `archive size limit of ${Math.round(archiveLimit / 1024)} KB reached, later entries not read` });
+        }
+        fileMatches = [];
+        for (const entry of contents.entries) {
+          if (fileMatches.length >= fileLimit) {
+            break;
+          }
+          fileMatches.push(...matchData(entry.path, entry.data));
+        }
+      } else {
+        fileMatches = matchData(relativePath, data);
+      }
+    } catch (err) {
+      toolsLogger.debug('Error reading file %s: %s', relativePath, err);
+      if (options.archives && isArchive(relativePath)) {
+        archivesSkipped.push({ filePath: relativePath, reason: `unreadable: ${(err as Error).message}` });
+      }
+      if (options.onMatches) {
+        completed[i] = [];
+        flushCompleted();
+      }
+      return [];
+    }
+    if (options.matchFilter && fileMatches.length > 0) {
+      try {
+        fileMatches = await options.matchFilter(relativePath, fileMatches);
+      } catch (err) {
+        // A failing filter fails the search once the files in progress finish
+        filterError ??= err;
+        return [];
+      }
+    }
+    filesScanned++;
+    // A file that ends past the deadline may have been cut short
+    pastDeadline();
+    found += fileMatches.length;
+    fileTotals[i] = fileMatches.length;
+    fileMatches = countOnly ? [] : fileMatches.slice(0, limit);
+    if (options.onMatches) {
+      completed[i] = fileMatches;
+      flushCompleted();
+    }
+    return fileMatches;
+  }, {
+    concurrency: options.concurrency,
+    shouldStop: () => (found >= limit && !options.countOmitted) || pastDeadline() || overBudget() || overCostBudget() ||
+      filterError !== undefined || options.signal?.aborted === true,
+    memory,
+  });
+  if (filterError !== undefined) {
+    throw filterError;
+  }
+
+  // Join in file order so results match a sequential scan
+  const kept = options.countOmitted ? maxResults : limit;
+  const matches: LexicalMatch[] = [];
+  const omittedByFile: Array<{ filePath: string; count: number }> = [];
+  for (let i = 0; i < perFile.length; i++) {
+    const fileMatches = perFile[i];
+    if (!fileMatches || (matches.length >= kept && !options.countOmitted)) {
+      break;
+    }
+    const shown = fileMatches.slice(0, kept - matches.length);
+    matches.push(...shown);
+    const omitted = (fileTotals[i] ?? 0) - shown.length;
+    if (options.countOmitted && omitted > 0) {
+      omittedByFile.push({ filePath: files[i], count: omitted });
+    }
+  }
+  const omittedMatches = omittedByFile.reduce((sum, file) => sum + file.count, 0);
+
+  return {
+    matches: matches.slice(0, maxResults),
+    filesScanned,
+    truncated: options.countOmitted ? omittedMatches > 0 : matches.length > maxResults,
+    truncatedByTimeout: timedOut,
+    truncatedByMemory: outOfMemory,
+    ...(budget?.exceeded() ? { truncatedByBudget: budget.exceeded()!.message } : {}),
+    binarySkipped,
+    generatedSkipped,
+    buildExcluded,
+    fixturesSkipped,
+    largeFilesSkipped: largeFilesSkipped.sort((a, b) => a.filePath.localeCompare(b.filePath)),
+    archivesSkipped: archivesSkipped.sort((a, b) => a.filePath.localeCompare(b.filePath)),
+    ...(options.countOmitted ? { omittedMatches, omittedByFile } : {}),
+  };
+}
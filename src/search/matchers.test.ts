@@ -0,0 +1,139 @@
+/**
+ * Tests for WASM matchers, against modules assembled byte by byte
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { searchLexical } from './lexical';
+import { clearMatchers, loadMatcher, matcherFilter, WasmMatcher } from './matchers';
+
+/**
+ * A section: its id, byte length, and content
+ */
+function section(id: number, content: number[]): number[] {
+  return [id, content.length, ...content];
+}
+
+function name(text: string): number[] {
+  return [text.length, ...Buffer.from(text)];
+}
+
+/**
+ * A module exporting memory, alloc (always 1024), and filter with the given body
+ * Types: 0 is (i32) -> i32, 1 is (i32, i32, i32, i32) -> i32
+ * The memory is one page, growing to at most 16 unless other limits are given
+ */
+function matcherModule(filterBody: number[], imports: number[] = [], limits: number[] = [0x01, 0x01, 0x10]): Buffer {
+  const body = (code: number[]) => [code.length + 1, 0x00, ...code];
+  return Buffer.from([
+    0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+    ...section(1, [0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f]),
+    ...(imports.length > 0 ? section(2, imports) : []),
+    ...section(3, [0x02, 0x00, 0x01]),
+    ...section(5, [0x01, ...limits]),
+    ...section(7, [0x03, ...name('memory'), 0x02, 0x00, ...name('alloc'), 0x00, 0x00, ...name('filter'), 0x00, 0x01]),
+    ...section(10, [0x02, ...body([0x41, 0x80, 0x08, 0x0b]), ...body(filterBody)]),
+  ]);
+}
+
+/**
+ * Keeps a match when the byte before it is a double quote: start > 0 && line[start - 1] == '"'
+ */
+const QUOTED = [
+  0x20, 0x02, 0x45, 0x04, 0x7f, 0x41, 0x00, 0x05,
+  0x20, 0x00, 0x20, 0x02, 0x6a, 0x41, 0x01, 0x6b, 0x2d, 0x00, 0x00, 0x41, 0x22, 0x46,
+  0x0b, 0x0b,
+];
+
+/**
+ * Never returns
+ */
+const LOOP = [0x03, 0x40, 0x0c, 0x00, 0x0b, 0x41, 0x00, 0x0b];
+
+/**
+ * Never returns for a match at the start of the line, keeps every other match
+ */
+const LOOP_AT_START = [0x20, 0x02, 0x45, 0x04, 0x40, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b, 0x41, 0x01, 0x0b];
+
+/**
+ * A matcher running a module with the given filter body, timing out after 200ms
+ */
+async function quickMatcher(matcherName: string, filterBody: number[]): Promise<WasmMatcher> {
+  const module = await (globalThis as any).WebAssembly.compile(matcherModule(filterBody));
+  return new WasmMatcher(matcherName, module, 200);
+}
+
+describe('WASM matchers', () => {
+  let dir: string;
+
+  beforeAll(() => {
+    dir = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'matchers-')));
+    fs.mkdirSync(path.join(dir, 'src'));
+    fs.writeFileSync(path.join(dir, 'src', 'db.go'), [
+      'package db',
+      '',
+      '// SELECT everything',
+      'const q = "SELECT id FROM users"',
+      'func SELECTOR() {}',
+      'var é = "SELECT name"',
+      '',
+    ].join('\n'));
+    fs.writeFileSync(path.join(dir, 'quoted.wasm'), matcherModule(QUOTED));
+    fs.writeFileSync(path.join(dir, 'loop.wasm'), matcherModule(LOOP));
+    fs.writeFileSync(path.join(dir, 'imports.wasm'), matcherModule(QUOTED, [0x01, ...name('env'), ...name('log'), 0x00, 0x00]));
+  });
+
+  afterAll(() => {
+    clearMatchers();
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  it('should keep only the matches the module accepts', async () => {
+    const result = await searchLexical(path.join(dir, 'src'), 'SELECT', {
+      scope: 'all',
+      matchFilter: matcherFilter('quoted', path.join(dir, 'quoted.wasm')),
+    });
+    expect(result.matches.map((match) => match.line)).toEqual([4, 6]);
+  });
+
+  it('should refuse modules that import or lack an export', async () => {
+    await expect(loadMatcher('imports', path.join(dir, 'imports.wasm'))).rejects.toThrow('modules may not import anything, and');
+    fs.writeFileSync(path.join(dir, 'empty.wasm'), Buffer.from([0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00]));
+    await expect(loadMatcher('empty', path.join(dir, 'empty.wasm'))).rejects.toThrow('does not export memory, alloc, filter');
+    await expect(loadMatcher('missing', path.join(dir, 'missing.wasm'))).rejects.toThrow('does not exist');
+  });
+
+  it('should refuse modules whose memory has no maximum or too large a one', async () => {
+    fs.writeFileSync(path.join(dir, 'unbounded.wasm'), matcherModule(QUOTED, [], [0x00, 0x01]));
+    await expect(loadMatcher('unbounded', path.join(dir, 'unbounded.wasm'))).rejects.toThrow('has no maximum; declare one of at most 256 MB');
+    // 4097 pages is one past 256 MB
+    fs.writeFileSync(path.join(dir, 'large.wasm'), matcherModule(QUOTED, [], [0x01, 0x01, 0x81, 0x20]));
+    await expect(loadMatcher('large', path.join(dir, 'large.wasm'))).rejects.toThrow('may grow to 256.0625 MB, past the 256 MB limit');
+    expect((await loadMatcher('loop', path.join(dir, 'loop.wasm'))).name).toBe('loop');
+  });
+
+  it('should stop a module that runs too long and fail the search', async () => {
+    const matcher = await quickMatcher('loop', LOOP);
+    const failed = await matcher.filter([{ text: 'SELECT', start: 0, end: 6 }]).catch((err) => err);
+    expect(failed.code).toBe('timeout');
+    await expect(searchLexical(path.join(dir, 'src'), 'SELECT', {
+      scope: 'all',
+      matchFilter: async (_filePath, matches) => {
+        await matcher.filter([{ text: matches[0].lineText, start: 0, end: 6 }]);
+        return matches;
+      },
+    })).rejects.toThrow('matcher loop timed out after 200ms');
+    matcher.stop();
+  });
+
+  it('should fail only the call that timed out', async () => {
+    const matcher = await quickMatcher('start', LOOP_AT_START);
+    const stuck = matcher.filter([{ text: 'SELECT', start: 0, end: 6 }]).catch((err) => err);
+    const queued = matcher.filter([{ text: 'a SELECT', start: 2, end: 8 }]);
+    expect((await stuck).code).toBe('timeout');
+    expect(await queued).toEqual([true]);
+    expect(await matcher.filter([{ text: 'b SELECT', start: 2, end: 8 }])).toEqual([true]);
+    matcher.stop();
+  });
+});
@@ -0,0 +1,369 @@
+/**
+ * WASM matchers - custom match filters loaded from WebAssembly modules
+ * A matcher decides, match by match, whether search_code keeps it, so
+ * domain logic such as "only SQL inside string literals" ships as a module
+ * instead of a server change. Modules get no imports, so they cannot reach
+ * the file system or network, and their memory must declare a maximum; they
+ * run in a worker thread that is restarted when a file's matches take too
+ * long or the call is cancelled, failing only that call
+ *
+ * A module exports:
+ * - memory: its linear memory
+ * - alloc(size) -> ptr: room for a line of that many bytes
+ * - filter(ptr, len, start, end) -> i32: non-zero keeps the match at UTF-8
+ *   bytes [start, end) of the line at ptr
+ * - dealloc(ptr, size), optionally: called once filter is done with the line
+ */
+
+import * as fs from 'fs';
+import { Worker } from 'worker_threads';
+import { createLogger, Component } from '../logging/logger.js';
+import { currentSignal, unlessCancelled } from '../workspace/cancellation.js';
+import { ToolError } from '../workspace/errors.js';
+import type { LexicalMatch } from './lexical.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Time a matcher gets for one file's matches
+ */
+const MATCHER_TIMEOUT_MS = 5000;
+
+/**
+ * Most linear memory a matcher may declare as its maximum
+ */
+const MAX_MATCHER_MEMORY = 256 * 1024 * 1024;
+
+/**
+ * Bytes in a WebAssembly memory page
+ */
+const WASM_PAGE_SIZE = 64 * 1024;
+
+/**
+ * Matchers kept loaded, by module path
+ */
+const MAX_LOADED_MATCHERS = 16;
+
+/**
+ * The parts of the WebAssembly API used here, which the ES library typings leave out
+ */
+type CompiledModule = object;
+interface WebAssemblyApi {
+  compile(bytes: Uint8Array): Promise<CompiledModule>;
+  Module: {
+    imports(module: CompiledModule): Array<{ module: string; name: string; kind: string }>;
+    exports(module: CompiledModule): Array<{ name: string; kind: string }>;
+  };
+}
+const wasm = (globalThis as unknown as { WebAssembly: WebAssemblyApi }).WebAssembly;
+
+/**
+ * A match as the module sees it: the line and the match's byte range in it
+ */
+export interface MatcherInput {
+  text: string;
+  start: number;
+  end: number;
+}
+
+/**
+ * Runs in the worker: instantiates the module and filters batches of matches
+ */
+const WORKER_SOURCE = `
+const { parentPort, workerData } = require('worker_threads');
+const instance = new WebAssembly.Instance(workerData.module, {});
+const { memory, alloc, filter, dealloc } = instance.exports;
+const encoder = new TextEncoder();
+parentPort.on('message', ({ id, inputs }) => {
+  try {
+    const kept = inputs.map(({ text, start, end }) => {
+      const bytes = encoder.encode(text);
+      const ptr = alloc(bytes.length) >>> 0;
+      if (ptr + bytes.length > memory.buffer.byteLength) {
+        throw new Error('alloc returned room outside the module memory');
+      }
+      new Uint8Array(memory.buffer, ptr, bytes.length).set(bytes);
+      const keep = filter(ptr, bytes.length, start, end) !== 0;
+      if (typeof dealloc === 'function') {
+        dealloc(ptr, bytes.length);
+      }
+      return keep;
+    });
+    parentPort.postMessage({ id, kept });
+  } catch (err) {
+    parentPort.postMessage({ id, error: String(err && err.message || err) });
+  }
+});
+`;
+
+/**
+ * A filter call waiting for the worker
+ */
+interface PendingFilter {
+  inputs: MatcherInput[];
+  resolve: (kept: boolean[]) => void;
+  reject: (err: Error) => void;
+  timer?: NodeJS.Timeout;
+}
+
+/**
+ * A loaded matcher module and the worker running it
+ */
+export class WasmMatcher {
+  private worker?: Worker;
+  private nextId = 0;
+  private waiting = new Map<number, PendingFilter>();
+
+  constructor(
+    readonly name: string,
+    private module: CompiledModule,
+    private timeoutMs = MATCHER_TIMEOUT_MS
+  ) {}
+
+  /**
+   * Whether the module keeps each match, in order
+   * A module that traps or runs out of time fails this call; calls from
+   * other searches waiting on the same worker are run again on a new one
+   */
+  async filter(inputs: MatcherInput[]): Promise<boolean[]> {
+    if (inputs.length === 0) {
+      return [];
+    }
+    const worker = this.start();
+    const id = this.nextId++;
+    const answer = new Promise<boolean[]>((resolve, reject) => {
+      const pending: PendingFilter = { inputs, resolve, reject };
+      this.waiting.set(id, pending);
+      this.arm(id, pending);
+      worker.postMessage({ id, inputs });
+    });
+    try {
+      return await unlessCancelled(answer, currentSignal(), () => this.abandon(id, new Error(`matcher ${this.name} was stopped`)));
+    } finally {
+      clearTimeout(this.waiting.get(id)?.timer);
+      this.waiting.delete(id);
+    }
+  }
+
+  /**
+   * (Re)start the time a call gets
+   */
+  private arm(id: number, pending: PendingFilter): void {
+    clearTimeout(pending.timer);
+    pending.timer = setTimeout(() => {
+      this.abandon(id, new ToolError('timeout', `matcher ${this.name} timed out after ${this.timeoutMs}ms`));
+    }, this.timeoutMs);
+  }
+
+  /**
+   * Fail one call and restart the worker under the others
+   * A module stuck in a loop only stops with its worker, so the calls
+   * queued behind it are sent to a new one, with their time started over
+   */
+  private abandon(id: number, reason: Error): void {
+    const pending = this.waiting.get(id);
+    if (!pending) {
+      return;
+    }
+    this.waiting.delete(id);
+    clearTimeout(pending.timer);
+    pending.reject(reason);
+    const worker = this.worker;
+    this.worker = undefined;
+    worker?.terminate().catch(() => undefined);
+    if (this.waiting.size > 0) {
+      const restarted = this.start();
+      for (const [other, queued] of this.waiting) {
+        this.arm(other, queued);
+        restarted.postMessage({ id: other, inputs: queued.inputs });
+      }
+    }
+  }
+
+  /**
+   * Stop the worker; the next filter starts a new one
+   */
+  stop(reason: Error = new Error(`matcher ${this.name} was stopped`)): void {
+    const worker = this.worker;
+    this.worker = undefined;
+    for (const { reject, timer } of this.waiting.values()) {
+      clearTimeout(timer);
+      reject(reason);
+    }
+    this.waiting.clear();
+    worker?.terminate().catch(() => undefined);
+  }
+
+  private start(): Worker {
+    if (this.worker) {
+      return this.worker;
+    }
+    const worker = new Worker(WORKER_SOURCE, {
+      eval: true,
+      workerData: { module: this.module },
+    });
+    // An idle matcher does not keep the process running
+    worker.unref();
+    worker.on('message', ({ id, kept, error }: { id: number; kept?: boolean[]; error?: string }) => {
+      if (this.worker !== worker) {
+        return;
+      }
+      const waiter = this.waiting.get(id);
+      if (error !== undefined) {
+        waiter?.reject(new Error(`matcher ${this.name} failed: ${error}`));
+      } else {
+        waiter?.resolve(kept!);
+      }
+    });
+    worker.on('error', (err) => {
+      toolsLogger.warn('Matcher %s stopped: %s', this.name, err.message);
+      if (this.worker === worker) {
+        this.stop(new Error(`matcher ${this.name} failed: ${err.message}`));
+      }
+    });
+    worker.on('exit', (code) => {
+      if (this.worker === worker) {
+        this.stop(new Error(`matcher ${this.name} exited with code ${code}`));
+      }
+    });
+    this.worker = worker;
+    return worker;
+  }
+}
+
+/**
+ * Unsigned LEB128 integer at an offset, and the offset after it
+ */
+function readLeb128(bytes: Uint8Array, offset: number): [number, number] {
+  let value = 0;
+  let scale = 1;
+  for (;;) {
+    const byte = bytes[offset++];
+    value += (byte & 0x7f) * scale;
+    scale *= 128;
+    if ((byte & 0x80) === 0) {
+      return [value, offset];
+    }
+  }
+}
+
+/**
+ * Maximum size in bytes of each memory a compiled module defines, undefined for none
+ * Reads the memory section: its limits are a flags byte, whose low bit says
+ * a maximum follows, the minimum, and the maximum, in pages
+ */
+function memoryMaximums(bytes: Uint8Array): Array<number | undefined> {
+  let offset = 8;
+  while (offset < bytes.length) {
+    const id = bytes[offset];
+    const [size, content] = readLeb128(bytes, offset + 1);
+    if (id === 5) {
+      const maximums: Array<number | undefined> = [];
+      let [count, at] = readLeb128(bytes, content);
+      for (; count > 0; count--) {
+        const flags = bytes[at];
+        at = readLeb128(bytes, at + 1)[1];
+        if ((flags & 0x01) === 0) {
+          maximums.push(undefined);
+          continue;
+        }
+        const [pages, next] = readLeb128(bytes, at);
+        maximums.push(pages * WASM_PAGE_SIZE);
+        at = next;
+      }
+      return maximums;
+    }
+    offset = content + size;
+  }
+  return [];
+}
+
+/**
+ * Loaded matchers by module path, with the modification time they were loaded at
+ */
+const loaded = new Map<string, { mtimeMs: number; matcher: WasmMatcher }>();
+
+/**
+ * Compile a matcher module and check it has what a matcher needs
+ */
+export async function loadMatcher(name: string, modulePath: string): Promise<WasmMatcher> {
+  let stat: fs.Stats;
+  try {
+    stat = await fs.promises.stat(modulePath);
+  } catch {
+    throw new ToolError('not-found', `matcher ${name}: module ${modulePath} does not exist`);
+  }
+  const cached = loaded.get(modulePath);
+  if (cached?.mtimeMs === stat.mtimeMs && cached.matcher.name === name) {
+    return cached.matcher;
+  }
+
+  const bytes = await fs.promises.readFile(modulePath);
+  let module: CompiledModule;
+  try {
+    module = await wasm.compile(bytes);
+  } catch (err) {
+    throw new ToolError('invalid-argument', `matcher ${name}: ${modulePath} is not a WebAssembly module: ${(err as Error).message}`);
+  }
+  if (wasm.Module.imports(module).length > 0) {
+    throw new ToolError('invalid-argument', `matcher ${name}: modules may not import anything, and ${modulePath} imports ${wasm.Module.imports(module).map((entry) => `${entry.module}.${entry.name}`).join(', ')}`);
+  }
+  const exports = new Map(wasm.Module.exports(module).map((entry) => [entry.name, entry.kind]));
+  const missing = [['memory', 'memory'], ['alloc', 'function'], ['filter', 'function']]
+    .filter(([exported, kind]) => exports.get(exported) !== kind)
+    .map(([exported]) => exported);
+  if (missing.length > 0) {
+    throw new ToolError('invalid-argument', `matcher ${name}: ${modulePath} does not export ${missing.join(', ')}`);
+  }
+  // The limit is checked before anything runs, so no module can grow past it
+  for (const maximum of memoryMaximums(bytes)) {
+    if (maximum === undefined) {
+      throw new ToolError('invalid-argument', `matcher ${name}: the memory of ${modulePath} has no maximum; declare one of at most ${MAX_MATCHER_MEMORY / 1048576} MB`);
+    }
+    if (maximum > MAX_MATCHER_MEMORY) {
+      throw new ToolError('invalid-argument', `matcher ${name}: the memory of ${modulePath} may grow to ${maximum / 1048576} MB, past the ${MAX_MATCHER_MEMORY / 1048576} MB limit`);
+    }
+  }
+
+  cached?.matcher.stop();
+  const matcher = new WasmMatcher(name, module);
+  loaded.delete(modulePath);
+  loaded.set(modulePath, { mtimeMs: stat.mtimeMs, matcher });
+  while (loaded.size > MAX_LOADED_MATCHERS) {
+    const [oldest, entry] = loaded.entries().next().value!;
+    entry.matcher.stop();
+    loaded.delete(oldest);
+  }
+  toolsLogger.info('Loaded matcher %s from %s', name, modulePath);
+  return matcher;
+}
+
+/**
+ * Filter for a lexical search keeping the matches a matcher accepts
+ * The module is loaded on the first file with matches. Binary matches have
+ * no line and are kept. Clipped lines are passed as the window the search
+ * kept, with the match's range in it
+ */
+export function matcherFilter(name: string, modulePath: string): (filePath: string, matches: LexicalMatch[]) => Promise<LexicalMatch[]> {
+  return async (_filePath, matches) => {
+    const matcher = await loadMatcher(name, modulePath);
+    const lineMatches = matches.filter((match) => match.byteOffset === undefined);
+    const kept = await matcher.filter(lineMatches.map((match) => {
+      const column = match.column - (match.clipped ? match.clipped.windowStart - 1 : 0);
+      const start = Buffer.byteLength(match.lineText.substring(0, column - 1));
+      return { text: match.lineText, start, end: start + Buffer.byteLength(match.lineText.substr(column - 1, match.length)) };
+    }));
+    const rejected = new Set(lineMatches.filter((_, i) => !kept[i]));
+    return matches.filter((match) => !rejected.has(match));
+  };
+}
+
+/**
+ * Stop every matcher's worker and forget the loaded modules
+ */
+export function clearMatchers(): void {
+  for (const { matcher } of loaded.values()) {
+    matcher.stop();
+  }
+  loaded.clear();
+}
@@ -0,0 +1,47 @@
+/**
+ * Tests for Jupyter notebook cell search
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { searchLexical } from './lexical';
+import { TrigramIndex } from './trigram';
+import { readNotebookCells, searchableText } from './notebook';
+
+const NOTEBOOK = JSON.stringify({
+  nbformat: 4,
+  cells: [
+    { cell_type: 'markdown', source: ['# Load the data\n', 'Reads "sales.csv".'] },
+    { cell_type: 'code', source: ['import pandas as pd\n', 'df = pd.read_csv("sales.csv")\n', 'df.head()'], outputs: [{ text: 'read_csv output' }] },
+    { cell_type: 'code', source: 'print("done")' },
+  ],
+}, null, 1);
+
+describe('notebooks', () => {
+  it('reads cell sources', () => {
+    const cells = readNotebookCells(NOTEBOOK);
+    expect(cells.map((cell) => [cell.index, cell.kind])).toEqual([[1, 'markdown'], [2, 'code'], [3, 'code']]);
+    expect(cells[1].source).toBe('import pandas as pd\ndf = pd.read_csv("sales.csv")\ndf.head()');
+    expect(() => readNotebookCells('{"metadata": {}}')).toThrow('no cells');
+    expect(searchableText('a.ipynb', '{ not json')).toBe('{ not json');
+  });
+
+  it('matches cells as written, with cell line numbers', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'notebook-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'analysis.ipynb'), NOTEBOOK);
+      const result = await searchLexical(workspace, 'read_csv("sales.csv")');
+      expect(result.matches).toHaveLength(1);
+      expect(result.matches[0]).toMatchObject({ filePath: 'analysis.ipynb', line: 2, column: 9, cell: { index: 2, kind: 'code' } });
+      expect(result.matches[0].lineText).toBe('df = pd.read_csv("sales.csv")');
+
+      // Outputs are not searched; the trigram index holds cell text, not escaped JSON
+      const index = new TrigramIndex(workspace);
+      expect((await searchLexical(workspace, 'read_csv output', {}, index)).matches).toHaveLength(0);
+      expect((await searchLexical(workspace, 'print("done")', {}, index)).matches[0].cell).toEqual({ index: 3, kind: 'code' });
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
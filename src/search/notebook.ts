@@ -0,0 +1,69 @@
+/**
+ * Jupyter notebooks - search cell sources instead of the raw JSON
+ * In .ipynb files code is stored as escaped JSON strings, so quotes,
+ * backslashes, and line breaks would not match as written. Searches read the
+ * cells and report matches by cell and line within the cell; outputs are not
+ * searched
+ */
+
+/**
+ * A notebook cell
+ */
+export interface NotebookCell {
+  index: number; // 1-indexed position in the notebook
+  kind: string; // code, markdown, or raw
+  source: string;
+}
+
+/**
+ * Check if a file is a Jupyter notebook
+ */
+export function isNotebook(filePath: string): boolean {
+  return filePath.toLowerCase().endsWith('.ipynb');
+}
+
+/**
+ * Join a cell source, stored as a string or a list of lines
+ */
+function cellSource(source: unknown): string {
+  if (Array.isArray(source)) {
+    return source.filter((part) => typeof part === 'string').join('');
+  }
+  return typeof source === 'string' ? source : '';
+}
+
+/**
+ * Read the cells of a notebook (nbformat 4, or the worksheets of nbformat 3)
+ * Throws when the content is not a notebook
+ */
+export function readNotebookCells(content: string): NotebookCell[] {
+  const notebook = JSON.parse(content);
+  let cells: unknown = notebook?.cells;
+  if (!Array.isArray(cells) && Array.isArray(notebook?.worksheets)) {
+    cells = notebook.worksheets.flatMap((worksheet: { cells?: unknown }) => Array.isArray(worksheet?.cells) ? worksheet.cells : []);
+  }
+  if (!Array.isArray(cells)) {
+    throw new Error('not a Jupyter notebook: no cells');
+  }
+  return cells.map((cell: { cell_type?: unknown; source?: unknown; input?: unknown }, i) => ({
+    index: i + 1,
+    kind: typeof cell?.cell_type === 'string' ? cell.cell_type : 'code',
+    // nbformat 3 code cells keep their source in input
+    source: cellSource(cell?.source ?? cell?.input).replace(/\r\n?/g, '\n'),
+  }));
+}
+
+/**
+ * Text to index for a file: a notebook's cell sources, one after another,
+ * or the content itself for other files and unreadable notebooks
+ */
+export function searchableText(filePath: string, content: string): string {
+  if (!isNotebook(filePath)) {
+    return content;
+  }
+  try {
+    return readNotebookCells(content).map((cell) => cell.source).join('\n');
+  } catch (err) {
+    return content;
+  }
+}
@@ -0,0 +1,56 @@
+/**
+ * Tests for the search_code query syntax
+ */
+
+import { parseQuery, resolveKinds, resolveLanguage } from './query';
+
+describe('Query syntax', () => {
+  it('should turn filters into parameters and the rest into the pattern', () => {
+    expect(parseQuery('lang:go kind:func -path:vendor "AddUser"')).toEqual({
+      pattern: 'AddUser',
+      languages: ['go'],
+      kind: ['func'],
+      excludeGlob: ['vendor/**', '**/vendor/**'],
+    });
+    expect(parseQuery('file:*.ts -file:*.test.ts path:src case:yes word:yes scope:all count:5 open file')).toEqual({
+      pattern: 'open file',
+      glob: ['*.ts'],
+      excludeGlob: ['*.test.ts'],
+      path: 'src',
+      caseSensitive: true,
+      wholeWord: true,
+      scope: 'all',
+      maxResults: 5,
+    });
+  });
+
+  it('should read regular expressions with spaces and quoted filter-like text', () => {
+    expect(parseQuery('-lang:py /func \\w+\\(/ repo:lib')).toEqual({
+      pattern: 'func \\w+\\(',
+      regex: true,
+      excludeGlob: ['*.py'],
+      repo: 'lib',
+    });
+    expect(parseQuery('"lang:go" http://example.com -x').pattern).toBe('lang:go http://example.com -x');
+    expect(parseQuery('"say \\"hi\\""').pattern).toBe('say "hi"');
+    expect(parseQuery('/api/users').pattern).toBe('/api/users');
+    expect(parseQuery('target://services/auth/... Token').target).toBe('//services/auth/...');
+  });
+
+  it('should reject malformed queries', () => {
+    expect(() => parseQuery('lang:go')).toThrow('the query has no pattern, only filters');
+    expect(() => parseQuery('"open')).toThrow('unterminated quote in query');
+    expect(() => parseQuery('/a+/ b')).toThrow('a query has one pattern');
+    expect(() => parseQuery('lang:cobol x')).toThrow('unknown language "cobol"');
+    expect(() => parseQuery('kind:macro x')).toThrow('unknown kind "macro"');
+    expect(() => parseQuery('case:maybe x')).toThrow('case: takes yes or no, got "maybe"');
+    expect(() => parseQuery('scope:tests x')).toThrow('Unknown scope "tests"');
+    expect(() => parseQuery('path:a path:b x')).toThrow('path: can be given once');
+  });
+
+  it('should resolve language and kind names', () => {
+    expect(resolveLanguage('ts')).toEqual(['typescript', 'typescriptreact']);
+    expect(resolveLanguage('rust')).toEqual(['rust']);
+    expect(resolveKinds(['func', 'method', 'struct'])).toEqual(['Function', 'Method', 'Struct']);
+  });
+});
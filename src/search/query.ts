@@ -0,0 +1,275 @@
+/**
+ * Query syntax - search_code parameters in one string
+ * `lang:go kind:func -path:vendor "AddUser"` reads as: Go files outside
+ * vendor/, matches on function declaration lines, literal AddUser.
+ * Filters are key:value terms (a leading - negates path, file, and lang);
+ * everything else is the pattern: bare words joined by spaces, "quoted"
+ * text taken as it is, or a /regular expression/
+ */
+
+import { SearchScope, parseSearchScope } from '../workspace/fixtures.js';
+import { languageGlobs } from '../workspace/language.js';
+import { ToolError } from '../workspace/errors.js';
+
+/**
+ * search_code arguments a query stands for
+ */
+export interface ParsedQuery {
+  pattern: string;
+  regex?: boolean;
+  caseSensitive?: boolean;
+  wholeWord?: boolean;
+  path?: string;
+  glob?: string[];
+  excludeGlob?: string[];
+  languages?: string[]; // LSP language identifiers
+  kind?: string[]; // As written, e.g. "func"; resolveKinds gives the symbol kinds
+  scope?: SearchScope;
+  maxResults?: number;
+  repo?: string;
+  workspace?: string;
+  target?: string; // Bazel label or pattern, e.g. //services/auth/...
+}
+
+/**
+ * Language names accepted besides the LSP language identifiers, and the identifiers they cover
+ */
+const LANGUAGE_ALIASES: Record<string, string[]> = {
+  ts: ['typescript', 'typescriptreact'],
+  typescript: ['typescript', 'typescriptreact'],
+  tsx: ['typescriptreact'],
+  js: ['javascript', 'javascriptreact'],
+  javascript: ['javascript', 'javascriptreact'],
+  jsx: ['javascriptreact'],
+  py: ['python'],
+  rs: ['rust'],
+  rb: ['ruby'],
+  'c++': ['cpp'],
+  cs: ['csharp'],
+  'c#': ['csharp'],
+  kt: ['kotlin'],
+  sh: ['shell'],
+  bash: ['shell'],
+};
+
+/**
+ * Symbol kind names accepted by kind:, and the kinds they cover
+ */
+const KIND_ALIASES: Record<string, string[]> = {
+  func: ['Function', 'Method'],
+  function: ['Function', 'Method'],
+  method: ['Method'],
+  constructor: ['Constructor'],
+  class: ['Class'],
+  struct: ['Struct'],
+  interface: ['Interface'],
+  type: ['Class', 'Struct', 'Interface', 'Enum', 'TypeParameter'],
+  enum: ['Enum'],
+  var: ['Variable'],
+  variable: ['Variable'],
+  const: ['Constant'],
+  constant: ['Constant'],
+  field: ['Field', 'Property'],
+  property: ['Field', 'Property'],
+  module: ['Module', 'Namespace', 'Package'],
+  package: ['Module', 'Namespace', 'Package'],
+  namespace: ['Module', 'Namespace', 'Package'],
+};
+
+const FILTER_KEYS = ['lang', 'path', 'file', 'kind', 'case', 'word', 'scope', 'count', 'repo', 'workspace', 'target'];
+
+const NEGATABLE_KEYS = ['lang', 'path', 'file'];
+
+/**
+ * LSP language identifiers for a language name, e.g. ts -> typescript, typescriptreact
+ */
+export function resolveLanguage(name: string): string[] {
+  const ids = LANGUAGE_ALIASES[name.toLowerCase()] ?? [name.toLowerCase()];
+  if (languageGlobs(ids[0]).length === 0) {
+    throw new ToolError('pattern-invalid', `unknown language "${name}"`);
+  }
+  return ids;
+}
+
+/**
+ * Symbol kind names for kind: values, e.g. func -> Function, Method
+ */
+export function resolveKinds(names: string[]): string[] {
+  const kinds = new Set<string>();
+  for (const name of names) {
+    const resolved = KIND_ALIASES[name.toLowerCase()];
+    if (!resolved) {
+      throw new ToolError('pattern-invalid', `unknown kind "${name}"; use ${Object.keys(KIND_ALIASES).join(', ')}`);
+    }
+    resolved.forEach((kind) => kinds.add(kind));
+  }
+  return Array.from(kinds);
+}
+
+/**
+ * Split a query into terms; quoted terms keep their spaces and are marked
+ */
+function tokenize(query: string): Array<{ text: string; quoted: boolean }> {
+  const terms: Array<{ text: string; quoted: boolean }> = [];
+  let i = 0;
+  while (i < query.length) {
+    if (/\s/.test(query[i])) {
+      i++;
+      continue;
+    }
+    if (query[i] === '"') {
+      let text = '';
+      let j = i + 1;
+      while (j < query.length && query[j] !== '"') {
+        if (query[j] === '\\' && (query[j + 1] === '"' || query[j + 1] === '\\')) {
+          j++;
+        }
+        text += query[j++];
+      }
+      if (j >= query.length) {
+        throw new ToolError('pattern-invalid', 'unterminated quote in query');
+      }
+      terms.push({ text, quoted: true });
+      i = j + 1;
+      continue;
+    }
+    // A /regular expression/ may contain spaces; it ends at a / before a space or the end
+    if (query[i] === '/') {
+      const close = /[^\\]\/(?=\s|$)/.exec(query.substring(i + 1));
+      if (close) {
+        const end = i + 1 + close.index + 2;
+        terms.push({ text: query.substring(i, end), quoted: false });
+        i = end;
+        continue;
+      }
+    }
+    let j = i;
+    while (j < query.length && !/\s/.test(query[j])) {
+      j++;
+    }
+    terms.push({ text: query.substring(i, j), quoted: false });
+    i = j;
+  }
+  return terms;
+}
+
+/**
+ * yes/no values of case: and word:
+ */
+function flag(key: string, value: string): boolean {
+  if (value === 'yes' || value === 'true') {
+    return true;
+  }
+  if (value === 'no' || value === 'false') {
+    return false;
+  }
+  throw new ToolError('pattern-invalid', `${key}: takes yes or no, got "${value}"`);
+}
+
+/**
+ * Globs for the files of a -path: directory, wherever it is
+ */
+function pathGlobs(dir: string): string[] {
+  const trimmed = dir.replace(/\\/g, '/').replace(/^\.\/|\/+$/g, '');
+  return trimmed.startsWith('/') || trimmed.includes('*') ? [trimmed] : [`${trimmed}/**`, `**/${trimmed}/**`];
+}
+
+/**
+ * Parse a query into search_code arguments
+ * Terms that look like filters but use an unknown key (http://x) are pattern text
+ */
+export function parseQuery(query: string): ParsedQuery {
+  const parsed: ParsedQuery = { pattern: '' };
+  const words: string[] = [];
+  let regex: string | undefined;
+  const append = <K extends 'glob' | 'excludeGlob' | 'languages' | 'kind'>(key: K, values: string[]) => {
+    parsed[key] = [...(parsed[key] ?? []), ...values];
+  };
+
+  for (const term of tokenize(query)) {
+    const filter = term.quoted ? null : /^(-?)([a-z]+):(.+)$/.exec(term.text);
+    const negated = filter?.[1] === '-';
+    const key = filter?.[2];
+    if (!filter || !key || !FILTER_KEYS.includes(key) || (negated && !NEGATABLE_KEYS.includes(key))) {
+      if (!term.quoted && term.text.length > 2 && term.text.startsWith('/') && term.text.endsWith('/')) {
+        if (regex !== undefined || words.length > 0) {
+          throw new ToolError('pattern-invalid', 'a query has one pattern: a /regular expression/ or text, not both');
+        }
+        regex = term.text.slice(1, -1);
+      } else {
+        if (regex !== undefined) {
+          throw new ToolError('pattern-invalid', 'a query has one pattern: a /regular expression/ or text, not both');
+        }
+        words.push(term.text);
+      }
+      continue;
+    }
+    const value = filter[3];
+    switch (key) {
+      case 'lang': {
+        const ids = resolveLanguage(value);
+        if (negated) {
+          append('excludeGlob', ids.flatMap((id) => languageGlobs(id)));
+        } else {
+          append('languages', ids);
+        }
+        break;
+      }
+      case 'path':
+        if (negated) {
+          append('excludeGlob', pathGlobs(value));
+        } else if (parsed.path !== undefined) {
+          throw new ToolError('pattern-invalid', 'path: can be given once; use file: globs for several places');
+        } else {
+          parsed.path = value;
+        }
+        break;
+      case 'file':
+        append(negated ? 'excludeGlob' : 'glob', [value]);
+        break;
+      case 'kind': {
+        const kinds = value.split(',');
+        resolveKinds(kinds);
+        append('kind', kinds);
+        break;
+      }
+      case 'case':
+        parsed.caseSensitive = flag(key, value);
+        break;
+      case 'word':
+        parsed.wholeWord = flag(key, value);
+        break;
+      case 'scope':
+        parsed.scope = parseSearchScope(value);
+        break;
+      case 'count': {
+        const count = parseInt(value, 10);
+        if (!(count >= 1)) {
+          throw new ToolError('pattern-invalid', `count: takes a number of at least 1, got "${value}"`);
+        }
+        parsed.maxResults = count;
+        break;
+      }
+      case 'repo':
+        parsed.repo = value;
+        break;
+      case 'workspace':
+        parsed.workspace = value;
+        break;
+      case 'target':
+        parsed.target = value;
+        break;
+    }
+  }
+
+  if (regex !== undefined) {
+    parsed.pattern = regex;
+    parsed.regex = true;
+  } else {
+    parsed.pattern = words.join(' ');
+  }
+  if (!parsed.pattern) {
+    throw new ToolError('pattern-invalid', 'the query has no pattern, only filters');
+  }
+  return parsed;
+}
@@ -0,0 +1,74 @@
+/**
+ * Tests for the query result cache
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { execFileSync } from 'child_process';
+import { QueryCache, TreeState, queryCacheOptionsFromEnv, queryKey } from './queryCache';
+
+describe('Query cache', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'query-cache-'));
+    const git = (...args: string[]) => execFileSync('git', args, { cwd: workspace, stdio: 'ignore' });
+    git('init', '-q');
+    fs.writeFileSync(path.join(workspace, 'a.ts'), 'export const a = 1;\n');
+    git('add', '.');
+    git('-c', 'user.name=test', '-c', 'user.email=test@example.com', 'commit', '-qm', 'init');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should normalize argument order and undefined values', () => {
+    expect(queryKey('search_code', { pattern: 'x', regex: true, path: undefined }))
+      .toBe(queryKey('search_code', { regex: true, pattern: 'x' }));
+    expect(queryKey('search_code', { pattern: 'x' })).not.toBe(queryKey('search_code', { pattern: 'y' }));
+  });
+
+  it('should serve repeated queries until the tree changes', async () => {
+    const cache = new QueryCache(new TreeState(workspace));
+    let runs = 0;
+    const compute = async () => `result ${++runs}`;
+
+    expect(await cache.getOrCompute('q', compute)).toBe('result 1');
+    expect(await cache.getOrCompute('q', compute)).toBe('result 1');
+
+    fs.writeFileSync(path.join(workspace, 'a.ts'), 'export const a = 2;\n');
+    cache.invalidate();
+    expect(await cache.getOrCompute('q', compute)).toBe('result 2');
+
+    // Reverting the edit restores the original tree state
+    fs.writeFileSync(path.join(workspace, 'a.ts'), 'export const a = 1;\n');
+    cache.invalidate();
+    expect(await cache.getOrCompute('q', compute)).toBe('result 1');
+    expect(cache.getStats()).toMatchObject({ hits: 2, misses: 2 });
+  });
+
+  it('should not cache results their compute flagged as partial', async () => {
+    const cache = new QueryCache(new TreeState(workspace));
+    let runs = 0;
+    let partial = false;
+    const compute = async () => {
+      partial = ++runs < 3;
+      return `result ${runs}`;
+    };
+    await cache.getOrCompute('q', compute, () => !partial);
+    expect(await cache.getOrCompute('q', compute, () => !partial)).toBe('result 2');
+    // The complete result is cached
+    expect(await cache.getOrCompute('q', compute, () => !partial)).toBe('result 3');
+    expect(await cache.getOrCompute('q', compute, () => !partial)).toBe('result 3');
+  });
+
+  it('should take the cache size from QUERY_CACHE_MAX_ENTRIES only when it is a positive integer', () => {
+    expect(queryCacheOptionsFromEnv({ QUERY_CACHE_MAX_ENTRIES: '50' })).toEqual({ enabled: true, maxEntries: 50 });
+    expect(queryCacheOptionsFromEnv({ QUERY_CACHE_ENABLED: 'false' }).enabled).toBe(false);
+    for (const value of [undefined, '', 'many', '0', '-1', '2.5']) {
+      expect(queryCacheOptionsFromEnv({ QUERY_CACHE_MAX_ENTRIES: value }).maxEntries).toBe(200);
+    }
+  });
+});
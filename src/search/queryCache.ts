@@ -0,0 +1,229 @@
+/**
+ * Query result cache
+ * Results are keyed by the normalized query and the state of the working
+ * tree (git HEAD plus hashes of dirty files), so a repeated query is served
+ * from memory until the code it ran against changes
+ */
+
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { runGit } from '../git/git.js';
+import { currentBudget } from '../workspace/budget.js';
+import { currentSnapshot } from '../workspace/treesnapshot.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * How long a computed tree state is trusted without a watcher event
+ * Guards against missed events without running git on every query
+ */
+const TREE_STATE_MAX_AGE_MS = 5000;
+
+/**
+ * Query cache options
+ */
+export interface QueryCacheOptions {
+  enabled?: boolean;
+  maxEntries?: number;
+}
+
+/**
+ * Options from QUERY_CACHE_ENABLED and QUERY_CACHE_MAX_ENTRIES
+ * A size that is not a positive integer falls back to 200
+ */
+export function queryCacheOptionsFromEnv(env: NodeJS.ProcessEnv = process.env): QueryCacheOptions {
+  const maxEntries = Number(env.QUERY_CACHE_MAX_ENTRIES);
+  return {
+    enabled: env.QUERY_CACHE_ENABLED !== 'false',
+    maxEntries: env.QUERY_CACHE_MAX_ENTRIES && Number.isInteger(maxEntries) && maxEntries > 0 ? maxEntries : 200,
+  };
+}
+
+/**
+ * Query cache statistics
+ */
+export interface QueryCacheStats {
+  entries: number;
+  hits: number;
+  misses: number;
+}
+
+/**
+ * Serialize a value with object keys sorted, so equal queries produce equal keys
+ */
+export function stableStringify(value: unknown): string {
+  if (Array.isArray(value)) {
+    return `[${value.map(stableStringify).join(',')}]`;
+  }
+  if (value && typeof value === 'object') {
+    const entries = Object.entries(value as Record<string, unknown>)
+      .filter(([, v]) => v !== undefined)
+      .sort(([a], [b]) => (a < b ? -1 : a > b ? 1 : 0));
+    return `{${entries.map(([k, v]) => `${JSON.stringify(k)}:${stableStringify(v)}`).join(',')}}`;
+  }
+  return JSON.stringify(value);
+}
+
+/**
+ * Normalized cache key of a tool call
+ */
+export function queryKey(tool: string, args: Record<string, unknown> | undefined): string {
+  return `${tool}:${stableStringify(args || {})}`;
+}
+
+/**
+ * Fingerprint of the working tree contents
+ * Outside a git repository the fingerprint is a counter bumped by watcher events
+ */
+export class TreeState {
+  private fingerprint?: string;
+  private computedAt = 0;
+  private generation = 0;
+  private pending?: Promise<string>;
+  private repoRoot?: string;
+
+  constructor(readonly workspaceDir: string) {}
+
+  /**
+   * Current fingerprint, recomputed after invalidation or when it is stale
+   */
+  current(): Promise<string> {
+    if (this.fingerprint && Date.now() - this.computedAt < TREE_STATE_MAX_AGE_MS) {
+      return Promise.resolve(this.fingerprint);
+    }
+    if (!this.pending) {
+      const generation = this.generation;
+      this.pending = this.compute()
+        .then((fingerprint) => {
+          // An event during computation makes the result stale immediately
+          if (generation === this.generation) {
+            this.fingerprint = fingerprint;
+            this.computedAt = Date.now();
+          }
+          return fingerprint;
+        })
+        .finally(() => {
+          this.pending = undefined;
+        });
+    }
+    return this.pending;
+  }
+
+  /**
+   * Record that a file changed; the next lookup recomputes the fingerprint
+   */
+  invalidate(): void {
+    this.generation++;
+    this.fingerprint = undefined;
+  }
+
+  private async compute(): Promise<string> {
+    const hash = crypto.createHash('sha256');
+    let head: string;
+    let status: string;
+    try {
+      head = (await runGit(this.workspaceDir, ['rev-parse', 'HEAD'])).trim();
+      status = await runGit(this.workspaceDir, ['status', '--porcelain=v1', '-z', '--untracked-files=all', '--', '.']);
+      if (!this.repoRoot) {
+        this.repoRoot = (await runGit(this.workspaceDir, ['rev-parse', '--show-toplevel'])).trim();
+      }
+    } catch (err) {
+      return `generation:${this.generation}`;
+    }
+
+    hash.update(head);
+    // -z entries are "XY path", with an extra entry holding the source of renames
+    const entries = status.split('\0').filter((entry) => entry.length > 3);
+    for (const entry of entries.sort()) {
+      const filePath = entry.substring(3);
+      hash.update('\0' + entry);
+      try {
+        hash.update(await fs.promises.readFile(path.join(this.repoRoot!, filePath)));
+      } catch {
+        hash.update('<missing>');
+      }
+    }
+    return hash.digest('hex');
+  }
+}
+
+/**
+ * LRU cache of tool results keyed by query and tree state
+ */
+export class QueryCache {
+  // Keyed by tree state and query, so results for an earlier state survive
+  // until evicted and are served again if the tree returns to that state
+  private entries = new Map<string, string>();
+  private hits = 0;
+  private misses = 0;
+  private enabled: boolean;
+  private maxEntries: number;
+
+  constructor(private treeState: TreeState, options: QueryCacheOptions = {}) {
+    this.enabled = options.enabled ?? true;
+    this.maxEntries = options.maxEntries ?? 200;
+  }
+
+  /**
+   * Return the cached result of a query, or compute and cache it
+   * Results computed while isCacheable says no (e.g. a compute that flagged
+   * its output partial), or by a call that ran out of budget, are returned
+   * uncached
+   */
+  async getOrCompute(
+    key: string,
+    compute: () => Promise<string>,
+    isCacheable: () => boolean = () => true
+  ): Promise<string> {
+    if (!this.enabled) {
+      return compute();
+    }
+
+    // Calls pinned to a snapshot see its state, whatever the tree is now
+    const snapshot = currentSnapshot();
+    const state = snapshot ? `snapshot:${snapshot.id}` : await this.treeState.current();
+    const entryKey = `${state}\0${key}`;
+    const cached = this.entries.get(entryKey);
+    if (cached !== undefined) {
+      this.hits++;
+      // Re-insert to mark as most recently used
+      this.entries.delete(entryKey);
+      this.entries.set(entryKey, cached);
+      toolsLogger.debug('Query cache hit: %s', key);
+      return cached;
+    }
+
+    this.misses++;
+    const value = await compute();
+    // Only cache when the tree did not change while the query ran
+    if (isCacheable() && !currentBudget()?.exceeded() && (snapshot || state === await this.treeState.current())) {
+      this.entries.set(entryKey, value);
+      while (this.entries.size > this.maxEntries) {
+        this.entries.delete(this.entries.keys().next().value!);
+      }
+    }
+    return value;
+  }
+
+  /**
+   * Handle a file event: the tree state is recomputed on the next lookup
+   * Entries stay until evicted, so a touch that leaves the content as it
+   * was, or a reverted change, still hits
+   */
+  invalidate(): void {
+    this.treeState.invalidate();
+  }
+
+  /**
+   * Drop all cached results
+   */
+  clear(): void {
+    this.entries.clear();
+  }
+
+  getStats(): QueryCacheStats {
+    return { entries: this.entries.size, hits: this.hits, misses: this.misses };
+  }
+}
@@ -0,0 +1,150 @@
+/**
+ * Secrets redaction
+ * Credentials committed to a repository end up in search results, hover
+ * text, and snippets, and from there in whatever the client sends them to.
+ * Known key formats, secret-looking assignments, and high-entropy strings
+ * are replaced with a [REDACTED:<kind>] marker before results leave the server
+ */
+
+/**
+ * A secret found in text
+ */
+export interface SecretMatch {
+  kind: string;
+  start: number; // Offset of the secret itself, not the surrounding context
+  end: number;
+}
+
+/**
+ * Key formats with a recognizable shape
+ */
+const KEY_PATTERNS: Array<{ kind: string; pattern: RegExp }> = [
+  { kind: 'aws-access-key', pattern: /\b(?:AKIA|ASIA)[0-9A-Z]{16}\b/g },
+  { kind: 'github-token', pattern: /\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b/g },
+  { kind: 'slack-token', pattern: /\bxox[abprs]-[A-Za-z0-9-]{10,}/g },
+  { kind: 'google-api-key', pattern: /\bAIza[0-9A-Za-z_-]{35}/g },
+  { kind: 'stripe-key', pattern: /\b[sr]k_(?:live|test)_[0-9A-Za-z]{16,}\b/g },
+  { kind: 'jwt', pattern: /\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}/g },
+];
+
+/**
+ * The password of user:password@host in a URL (group 1)
+ */
+const URL_CREDENTIALS = /\b[a-z][a-z0-9+.-]*:\/\/[^\s:@/]+:([^\s@/]{3,})@/gi;
+
+/**
+ * The quoted value (group 1) assigned to a secret-sounding name
+ */
+const SECRET_ASSIGNMENT =
+  /[\w.-]*(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credentials?)[\w.-]*["']?\s*(?::=|[:=]|=>)\s*["'`]([^"'`\s]{8,})["'`]/gi;
+
+/**
+ * Long runs of base64 and token characters, checked for entropy
+ */
+const TOKEN_CANDIDATE = /[A-Za-z0-9+/_=-]{32,}/g;
+
+/**
+ * Bits per character above which a token is treated as random
+ * Hex digests top out at 4 and identifiers stay well below
+ */
+const HIGH_ENTROPY = 4.5;
+
+/**
+ * Least entropy of an assigned value, to pass over placeholders like "xxxxxxxx"
+ */
+const ASSIGNED_ENTROPY = 3;
+
+/**
+ * Shannon entropy of a string, in bits per character
+ */
+export function shannonEntropy(text: string): number {
+  const counts = new Map<string, number>();
+  for (const c of text) {
+    counts.set(c, (counts.get(c) ?? 0) + 1);
+  }
+  let entropy = 0;
+  for (const count of counts.values()) {
+    const p = count / text.length;
+    entropy -= p * Math.log2(p);
+  }
+  return entropy;
+}
+
+/**
+ * Check if an assigned value is a reference or placeholder rather than a secret
+ */
+function isPlaceholder(value: string): boolean {
+  return /^[$%{<]|^\{\{/.test(value) || /^(?:x+|\*+|changeme|password|secret|example|placeholder|redacted)$/i.test(value);
+}
+
+/**
+ * Check if a token looks randomly generated: letters of both cases, digits, and high entropy
+ */
+function isRandomToken(token: string): boolean {
+  const digits = token.replace(/\D/g, '').length;
+  return digits >= 3 && /[a-z]/.test(token) && /[A-Z]/.test(token) && shannonEntropy(token) >= HIGH_ENTROPY;
+}
+
+/**
+ * Find likely secrets in text, in order, without overlaps
+ */
+export function findSecrets(text: string): SecretMatch[] {
+  const found: SecretMatch[] = [];
+  for (const { kind, pattern } of KEY_PATTERNS) {
+    for (const match of text.matchAll(pattern)) {
+      found.push({ kind, start: match.index!, end: match.index! + match[0].length });
+    }
+  }
+  for (const match of text.matchAll(URL_CREDENTIALS)) {
+    const start = match.index! + match[0].length - 1 - match[1].length;
+    found.push({ kind: 'url-password', start, end: start + match[1].length });
+  }
+  for (const match of text.matchAll(SECRET_ASSIGNMENT)) {
+    const value = match[1];
+    if (!isPlaceholder(value) && shannonEntropy(value) >= ASSIGNED_ENTROPY) {
+      const start = match.index! + match[0].length - 1 - value.length;
+      found.push({ kind: 'assigned-secret', start, end: start + value.length });
+    }
+  }
+  for (const match of text.matchAll(TOKEN_CANDIDATE)) {
+    if (isRandomToken(match[0])) {
+      found.push({ kind: 'high-entropy-string', start: match.index!, end: match.index! + match[0].length });
+    }
+  }
+
+  // Earlier and longer matches win; specific kinds were added first
+  found.sort((a, b) => a.start - b.start || b.end - a.end);
+  const kept: SecretMatch[] = [];
+  for (const match of found) {
+    const last = kept[kept.length - 1];
+    if (!last || match.start >= last.end) {
+      kept.push(match);
+    }
+  }
+  return kept;
+}
+
+/**
+ * Replace likely secrets with [REDACTED:<kind>] markers
+ * Returns the redacted text and how many secrets were replaced
+ */
+export function redactSecrets(text: string): { text: string; count: number } {
+  const secrets = findSecrets(text);
+  if (secrets.length === 0) {
+    return { text, count: 0 };
+  }
+  let output = '';
+  let offset = 0;
+  for (const secret of secrets) {
+    output += text.substring(offset, secret.start) + `[REDACTED:${secret.kind}]`;
+    offset = secret.end;
+  }
+  return { text: output + text.substring(offset), count: secrets.length };
+}
+
+/**
+ * Check if results are redacted (REDACT_SECRETS, on unless "false")
+ */
+export function redactionEnabled(env: NodeJS.ProcessEnv = process.env): boolean {
+  return env.REDACT_SECRETS !== 'false';
+}
@@ -0,0 +1,75 @@
+/**
+ * Tests for index snapshots
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { TrigramIndex } from './trigram';
+import { buildSnapshot, decodeSnapshot, encodeSnapshot, loadImportedSnapshot, writeSnapshot, SNAPSHOT_VERSION } from './snapshot';
+import { PythonSymbolIndex } from '../symbols/python';
+import { contentHash } from '../semantic/store';
+
+describe('Index snapshots', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'snapshot-')));
+    fs.mkdirSync(path.join(workspace, 'pkg'));
+    fs.writeFileSync(path.join(workspace, 'pkg', 'users.py'), 'class UserStore:\n    def get(self, key):\n        pass\n');
+    fs.writeFileSync(path.join(workspace, 'README.md'), 'Users live here\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should round-trip through its encoding and reject other files', async () => {
+    const snapshot = await buildSnapshot(workspace, new TrigramIndex(workspace), [new PythonSymbolIndex(workspace)]);
+    expect(snapshot).toMatchObject({ version: SNAPSHOT_VERSION, commit: undefined, dirty: false });
+    expect(snapshot.trigrams.map((file) => file.relativePath)).toEqual(['README.md', 'pkg/users.py']);
+    expect(snapshot.symbols.map((file) => file.relativePath)).toEqual(['pkg/users.py']);
+    expect(snapshot.symbols[0].symbols[0].name).toBe('UserStore');
+
+    expect(decodeSnapshot(encodeSnapshot(snapshot, 'brotli'))).toEqual(snapshot);
+    expect(decodeSnapshot(encodeSnapshot(snapshot, 'none'))).toEqual(snapshot);
+    expect(() => decodeSnapshot(Buffer.from('not a snapshot'))).toThrow('not an index snapshot');
+  });
+
+  it('should take the entries of unchanged files and index changed ones', async () => {
+    const readme = 'Users live here\n';
+    const snapshotPath = path.join(workspace, '..', `${path.basename(workspace)}.bin`);
+    await writeSnapshot(snapshotPath, {
+      version: SNAPSHOT_VERSION,
+      dirty: false,
+      createdAt: new Date().toISOString(),
+      // Marker trigrams show which entries were taken from the snapshot
+      trigrams: [
+        { relativePath: 'README.md', hash: contentHash(readme), trigrams: ['zzq'] },
+        { relativePath: 'pkg/users.py', hash: contentHash('older text'), trigrams: ['zzq'] },
+      ],
+      symbols: [{ relativePath: 'pkg/users.py', hash: contentHash('older text'), symbols: [] }],
+    });
+
+    try {
+      const index = new TrigramIndex(workspace);
+      const symbols = new PythonSymbolIndex(workspace);
+      const snapshot = await loadImportedSnapshot(workspace, index, [symbols], snapshotPath);
+      expect(snapshot?.trigrams).toHaveLength(2);
+      await index.refresh();
+      expect(index.candidates('zzq')).toEqual(['README.md']);
+      expect(index.candidates('UserStore')).toEqual([path.join('pkg', 'users.py')]);
+      const parsed = await symbols.documentSymbols(path.join(workspace, 'pkg', 'users.py'));
+      expect(parsed.map((symbol) => symbol.name)).toEqual(['UserStore']);
+    } finally {
+      fs.rmSync(snapshotPath, { force: true });
+    }
+  });
+
+  it('should ignore a missing or unreadable snapshot', async () => {
+    const index = new TrigramIndex(workspace);
+    expect(await loadImportedSnapshot(workspace, index, [], path.join(workspace, 'absent.bin'))).toBeUndefined();
+    fs.writeFileSync(path.join(workspace, 'broken.bin'), 'GFCX');
+    expect(await loadImportedSnapshot(workspace, index, [], path.join(workspace, 'broken.bin'))).toBeUndefined();
+  });
+});
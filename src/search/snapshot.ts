@@ -0,0 +1,194 @@
+/**
+ * Index snapshots - a built index carried to another machine
+ * A snapshot holds the trigrams and built-in parser symbols of every indexed
+ * file with the hash of the text they came from, and the commit it was built
+ * at. A server seeded with one takes the entries of files whose text is
+ * unchanged instead of indexing them, so a fleet of agents or CI runners on
+ * the same commit pays the indexing cost once
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { resolveRevision, workingChanges } from '../git/git.js';
+import { compress, decompress, defaultCodec, defaultStorePath, StoreCodec } from '../semantic/store.js';
+import { FileSymbolIndex, SymbolSnapshotFile } from '../symbols/fileIndex.js';
+import { writeFileAtomic } from '../workspace/atomic.js';
+import { TrigramIndex, TrigramSnapshotFile } from './trigram.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * File magic and format version; bump the version when the layout changes
+ */
+const SNAPSHOT_MAGIC = 'GFCX';
+export const SNAPSHOT_VERSION = 1;
+
+/**
+ * A built index, with paths relative to the workspace and / separated
+ */
+export interface IndexSnapshot {
+  version: number;
+  // Commit the workspace was at; undefined outside a repository
+  commit?: string;
+  // The workspace had uncommitted changes, which are in the snapshot
+  dirty: boolean;
+  createdAt: string;
+  trigrams: TrigramSnapshotFile[];
+  symbols: SymbolSnapshotFile[];
+}
+
+/**
+ * Header of the on-disk format; the compressed body follows it
+ */
+interface SnapshotHeader {
+  version: number;
+  commit?: string;
+  dirty: boolean;
+  createdAt: string;
+  codec: StoreCodec;
+  files: number;
+  symbolFiles: number;
+}
+
+/**
+ * Default location of a workspace's imported snapshot, next to its semantic index store
+ */
+export function defaultSnapshotPath(workspaceDir: string, baseDir?: string): string {
+  return path.join(path.dirname(defaultStorePath(workspaceDir, baseDir)), 'index-snapshot.bin');
+}
+
+/**
+ * Serialize a snapshot
+ */
+export function encodeSnapshot(snapshot: IndexSnapshot, codec: StoreCodec = defaultCodec()): Buffer {
+  const header: SnapshotHeader = {
+    version: snapshot.version,
+    commit: snapshot.commit,
+    dirty: snapshot.dirty,
+    createdAt: snapshot.createdAt,
+    codec,
+    files: snapshot.trigrams.length,
+    symbolFiles: snapshot.symbols.length,
+  };
+  const headerBytes = Buffer.from(JSON.stringify(header), 'utf8');
+  const body = compress(codec, Buffer.from(JSON.stringify({ trigrams: snapshot.trigrams, symbols: snapshot.symbols }), 'utf8'));
+
+  const prefix = Buffer.alloc(8);
+  prefix.write(SNAPSHOT_MAGIC, 0, 'ascii');
+  prefix.writeUInt32LE(headerBytes.length, 4);
+  return Buffer.concat([prefix, headerBytes, body]);
+}
+
+/**
+ * Read a serialized snapshot
+ */
+export function decodeSnapshot(data: Buffer): IndexSnapshot {
+  if (data.length < 8 || data.toString('ascii', 0, 4) !== SNAPSHOT_MAGIC) {
+    throw new Error('not an index snapshot');
+  }
+  const headerLength = data.readUInt32LE(4);
+  const header = JSON.parse(data.toString('utf8', 8, 8 + headerLength)) as SnapshotHeader;
+  if (header.version !== SNAPSHOT_VERSION) {
+    throw new Error(`index snapshot version ${header.version} is not supported (expected ${SNAPSHOT_VERSION})`);
+  }
+  const body = JSON.parse(decompress(header.codec, data.subarray(8 + headerLength)).toString('utf8')) as
+    Pick<IndexSnapshot, 'trigrams' | 'symbols'>;
+  return {
+    version: header.version,
+    commit: header.commit,
+    dirty: header.dirty,
+    createdAt: header.createdAt,
+    trigrams: body.trigrams,
+    symbols: body.symbols,
+  };
+}
+
+/**
+ * Write a snapshot to a file atomically
+ */
+export async function writeSnapshot(filePath: string, snapshot: IndexSnapshot): Promise<number> {
+  const data = encodeSnapshot(snapshot);
+  await writeFileAtomic(filePath, data);
+  return data.length;
+}
+
+export async function readSnapshot(filePath: string): Promise<IndexSnapshot> {
+  return decodeSnapshot(await fs.promises.readFile(filePath));
+}
+
+/**
+ * Commit a workspace is at, or undefined outside a repository
+ */
+export async function workspaceCommit(workspaceDir: string): Promise<string | undefined> {
+  try {
+    return await resolveRevision(workspaceDir, 'HEAD');
+  } catch (err) {
+    return undefined;
+  }
+}
+
+function toPortable<T extends { relativePath: string }>(file: T): T {
+  return { ...file, relativePath: file.relativePath.split(path.sep).join('/') };
+}
+
+function fromPortable<T extends { relativePath: string }>(file: T): T {
+  return { ...file, relativePath: file.relativePath.split('/').join(path.sep) };
+}
+
+/**
+ * Build the index of a workspace and take a snapshot of it
+ */
+export async function buildSnapshot(workspaceDir: string, index: TrigramIndex, symbolIndexes: FileSymbolIndex[]): Promise<IndexSnapshot> {
+  await index.refresh();
+  const symbols: SymbolSnapshotFile[] = [];
+  for (const symbolIndex of symbolIndexes) {
+    symbols.push(...await symbolIndex.snapshotFiles());
+  }
+  const changes = await workingChanges(workspaceDir);
+  return {
+    version: SNAPSHOT_VERSION,
+    commit: await workspaceCommit(workspaceDir),
+    dirty: (changes?.size ?? 0) > 0,
+    createdAt: new Date().toISOString(),
+    trigrams: index.snapshotFiles().map(toPortable),
+    symbols: symbols.map(toPortable),
+  };
+}
+
+/**
+ * Seed the indexes with a snapshot; each takes the entries of the files it covers
+ */
+export function seedFromSnapshot(snapshot: IndexSnapshot, index: TrigramIndex, symbolIndexes: FileSymbolIndex[]): void {
+  index.seed(snapshot.trigrams.map(fromPortable));
+  const symbols = snapshot.symbols.map(fromPortable);
+  for (const symbolIndex of symbolIndexes) {
+    symbolIndex.seed(symbols);
+  }
+}
+
+/**
+ * Seed the indexes from the workspace's imported snapshot, if there is one
+ * An unreadable snapshot is ignored; the workspace is indexed as usual
+ */
+export async function loadImportedSnapshot(
+  workspaceDir: string,
+  index: TrigramIndex,
+  symbolIndexes: FileSymbolIndex[],
+  snapshotPath: string = defaultSnapshotPath(workspaceDir)
+): Promise<IndexSnapshot | undefined> {
+  if (!fs.existsSync(snapshotPath)) {
+    return undefined;
+  }
+  try {
+    const snapshot = await readSnapshot(snapshotPath);
+    seedFromSnapshot(snapshot, index, symbolIndexes);
+    const commit = await workspaceCommit(workspaceDir);
+    toolsLogger.info('Seeding the index from the snapshot of %s (%d file(s))%s', snapshot.commit ?? 'an unversioned workspace',
+      snapshot.trigrams.length, commit && snapshot.commit && commit !== snapshot.commit ? `; the workspace is at ${commit}` : '');
+    return snapshot;
+  } catch (err) {
+    toolsLogger.warn('Ignoring unreadable index snapshot %s: %s', snapshotPath, (err as Error).message);
+    return undefined;
+  }
+}
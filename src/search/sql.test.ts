@@ -0,0 +1,101 @@
+/**
+ * Tests for SQL statement splitting, analysis, and migration search
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { sqlSearch } from '../tools/sql';
+import { compareVersions, migrationInfo, parseSqlStatements, searchSql } from './sql';
+
+describe('SQL search', () => {
+  it('splits statements outside strings, comments, dollar quotes, and trigger bodies', () => {
+    const content = [
+      '-- Users; and their settings',
+      'CREATE TABLE IF NOT EXISTS "public"."users" (',
+      '  id serial PRIMARY KEY,',
+      "  note text DEFAULT 'a;b',",
+      '  org_id int REFERENCES orgs(id),',
+      '  CONSTRAINT users_note CHECK (note <> \'\')',
+      ');',
+      '',
+      'CREATE FUNCTION touch() RETURNS trigger AS $$',
+      'BEGIN NEW.updated_at = now(); RETURN NEW; END;',
+      '$$ LANGUAGE plpgsql;',
+      'CREATE TRIGGER users_touch BEFORE UPDATE ON users',
+      'FOR EACH ROW BEGIN',
+      '  UPDATE audit SET n = n + 1;',
+      'END;',
+      'INSERT INTO users (note) VALUES (\'x\')',
+    ].join('\n');
+    const statements = parseSqlStatements(content, 'schema.sql');
+    expect(statements.map((s) => [s.kind, s.startLine, s.endLine])).toEqual([
+      ['CREATE TABLE', 2, 7],
+      ['CREATE FUNCTION', 9, 11],
+      ['CREATE TRIGGER', 12, 15],
+      ['INSERT', 16, 16],
+    ]);
+    expect(statements[0].tables).toEqual(['public.users', 'orgs']);
+    expect(statements[0].columns.map((c) => c.column)).toEqual(['id', 'note', 'org_id']);
+    expect(statements[0].text.startsWith('CREATE TABLE')).toBe(true);
+    expect(statements[2].tables).toEqual(['audit', 'users']);
+  });
+
+  it('reads ALTER TABLE column changes and goose directions', () => {
+    const content = [
+      '-- +goose Up',
+      'ALTER TABLE users ADD COLUMN email text, DROP COLUMN IF EXISTS legacy, RENAME COLUMN nick TO handle,',
+      '  ALTER COLUMN note SET NOT NULL, ADD CONSTRAINT users_email UNIQUE (email);',
+      '-- +goose Down',
+      'ALTER TABLE users DROP COLUMN email;',
+    ].join('\n');
+    const statements = parseSqlStatements(content, 'db/migrations/20240102_add_email.sql');
+    expect(statements[0].columns).toEqual([
+      { table: 'users', column: 'email', change: 'add' },
+      { table: 'users', column: 'legacy', change: 'drop' },
+      { table: 'users', column: 'nick', change: 'rename', newName: 'handle' },
+      { table: 'users', column: 'note', change: 'alter' },
+    ]);
+    expect(statements.map((s) => s.migration)).toEqual([
+      { version: '20240102', name: 'add_email', direction: 'up' },
+      { version: '20240102', name: 'add_email', direction: 'down' },
+    ]);
+  });
+
+  it('recognises migration layouts and orders versions', () => {
+    expect(migrationInfo('migrations/000012_add_index.down.sql')).toEqual({ version: '000012', name: 'add_index', direction: 'down' });
+    expect(migrationInfo('db/V1_2__init.sql')).toEqual({ version: '1.2', name: 'init', direction: 'up' });
+    expect(migrationInfo('prisma/migrations/20240101120000_init/migration.sql')?.version).toBe('20240101120000');
+    expect(migrationInfo('queries/01_report.sql')).toBeUndefined();
+    expect(compareVersions('9', '10')).toBeLessThan(0);
+    expect(compareVersions('1.10', '1.9')).toBeGreaterThan(0);
+  });
+
+  it('finds which migration added a column', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'sql-'));
+    try {
+      const dir = path.join(workspace, 'migrations');
+      fs.mkdirSync(dir);
+      fs.writeFileSync(path.join(dir, '000010_add_email.up.sql'), 'ALTER TABLE users ADD COLUMN email text;\n');
+      fs.writeFileSync(path.join(dir, '000010_add_email.down.sql'), 'ALTER TABLE users DROP COLUMN email;\n');
+      fs.writeFileSync(path.join(dir, '000002_create_users.up.sql'), 'CREATE TABLE users (id int);\nCREATE TABLE orgs (id int);\n');
+      fs.writeFileSync(path.join(workspace, 'report.sql'), 'SELECT email FROM users WHERE id = 1;\n');
+
+      const byTable = await searchSql(workspace, { table: 'users' });
+      expect(byTable.statements.map((s) => s.filePath)).toEqual([
+        'migrations/000002_create_users.up.sql',
+        'migrations/000010_add_email.down.sql',
+        'migrations/000010_add_email.up.sql',
+        'report.sql',
+      ]);
+
+      const output = await sqlSearch(workspace, { column: 'email' });
+      expect(output).toContain('Column history:\nusers.email added in migrations/000010_add_email.up.sql:1 (migration 000010, add_email, up)\n\n');
+      expect(output).toContain('L1 SELECT users');
+      expect(output).toContain('L1 ALTER TABLE users (dropped email)');
+      await expect(searchSql(workspace, {})).rejects.toThrow('at least one of');
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,538 @@
+/**
+ * SQL statement search - tables, column changes, and migrations
+ * .sql files are split into statements (outside strings, comments, dollar
+ * quotes, and trigger or procedure bodies), and each statement records the
+ * tables it touches and the columns it adds, drops, renames, or alters.
+ * Files in common migration layouts (golang-migrate, Flyway, goose, dbmate,
+ * Prisma, numbered files in a migrations directory) carry their version and
+ * direction, so results come in migration order
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { escapeRegExp } from './lexical.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { readFileText } from '../workspace/overlay.js';
+import { ToolError } from '../workspace/errors.js';
+
+const sqlLogger = createLogger(Component.TOOLS);
+
+/**
+ * How a statement changes a column
+ */
+export type ColumnChangeKind = 'define' | 'add' | 'drop' | 'rename' | 'alter';
+
+/**
+ * A column a statement defines or changes
+ */
+export interface ColumnChange {
+  table: string;
+  column: string;
+  change: ColumnChangeKind;
+  // New name, for renames
+  newName?: string;
+}
+
+/**
+ * Version and direction of a migration file
+ */
+export interface MigrationInfo {
+  version: string;
+  name?: string;
+  direction?: 'up' | 'down';
+}
+
+/**
+ * One SQL statement
+ */
+export interface SqlStatement {
+  filePath: string; // Relative to the workspace
+  startLine: number; // 1-indexed
+  endLine: number;
+  text: string;
+  // Leading keywords, e.g. "CREATE TABLE", "ALTER TABLE", "INSERT"
+  kind: string;
+  tables: string[];
+  columns: ColumnChange[];
+  migration?: MigrationInfo;
+}
+
+/**
+ * Options for a SQL statement search
+ */
+export interface SqlSearchOptions {
+  // Statements touching this table (schema-qualified or not)
+  table?: string;
+  // Statements defining, changing, or mentioning this column
+  column?: string;
+  // Statements of this kind, e.g. "ALTER TABLE" or "CREATE INDEX"
+  statement?: string;
+  // Statements whose text matches this regular expression (case-insensitive)
+  pattern?: string;
+  path?: string;
+  maxResults?: number;
+}
+
+/**
+ * Result of a SQL statement search
+ */
+export interface SqlSearchResult {
+  statements: SqlStatement[];
+  filesScanned: number;
+  truncated: boolean;
+}
+
+/**
+ * A SQL identifier, optionally schema-qualified and quoted
+ */
+const PART = '(?:"[^"]+"|`[^`]+`|\\[[^\\]]+\\]|[A-Za-z_][\\w$]*)';
+const IDENT = `(${PART}(?:\\s*\\.\\s*${PART})*)`;
+
+/**
+ * Clauses naming the table a statement reads or writes, the table it defines first
+ */
+const TABLE_PATTERNS = [
+  new RegExp(`\\bTABLE\\s+(?:IF\\s+(?:NOT\\s+)?EXISTS\\s+)?(?:ONLY\\s+)?${IDENT}`, 'gi'),
+  new RegExp(`\\b(?:FROM|JOIN|INTO|UPDATE|REFERENCES)\\s+(?:ONLY\\s+)?${IDENT}`, 'gi'),
+  new RegExp(`\\b(?:INDEX|TRIGGER|POLICY)\\b[^;]*?\\bON\\s+(?:ONLY\\s+)?${IDENT}`, 'gi'),
+];
+
+/**
+ * Words the table patterns can capture that are not tables
+ */
+const NOT_TABLES = new Set(['select', 'lateral', 'only', 'values', 'set', 'if', 'exists', 'not', 'each', 'unnest', 'on', 'of', 'or', 'skip', 'nowait']);
+
+/**
+ * Words starting a table element or ALTER TABLE action that are not columns
+ */
+const NOT_COLUMNS = new Set(['constraint', 'primary', 'unique', 'foreign', 'check', 'index', 'key', 'exclude', 'like', 'fulltext', 'spatial', 'period']);
+
+/**
+ * Statements whose BEGIN ... END body contains semicolons
+ */
+const BLOCK_STATEMENT = /^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:DEFINER\s*=\s*\S+\s+)?(?:TEMP(?:ORARY)?\s+)?(?:TRIGGER|PROCEDURE|FUNCTION|EVENT)\b/i;
+
+/**
+ * Check if a file is SQL
+ */
+export function isSqlFile(filePath: string): boolean {
+  return filePath.toLowerCase().endsWith('.sql');
+}
+
+/**
+ * Strip quotes from an identifier and join its parts with dots
+ */
+export function normalizeIdentifier(identifier: string): string {
+  return identifier.split(/\s*\.\s*/).map((part) => part.replace(/^["`[]|["`\]]$/g, '')).join('.');
+}
+
+/**
+ * Check if a table name matches the wanted table, with or without its schema
+ */
+export function matchesTable(table: string, wanted: string): boolean {
+  const a = table.toLowerCase();
+  const b = normalizeIdentifier(wanted).toLowerCase();
+  return a === b || a.endsWith(`.${b}`) || b.endsWith(`.${a}`);
+}
+
+/**
+ * Version, name, and direction of a migration file, from its path
+ */
+export function migrationInfo(relativePath: string): MigrationInfo | undefined {
+  const normalized = relativePath.replace(/\\/g, '/');
+  const base = path.posix.basename(normalized);
+  const dir = path.posix.basename(path.posix.dirname(normalized));
+
+  // golang-migrate: 000001_create_users.up.sql
+  let match = base.match(/^(\d+)_(.+)\.(up|down)\.sql$/i);
+  if (match) {
+    return { version: match[1], name: match[2], direction: match[3].toLowerCase() as 'up' | 'down' };
+  }
+  // Flyway: V2__add_email.sql, U2__add_email.sql (undo)
+  match = base.match(/^([VU])(\d+(?:[._]\d+)*)__(.+)\.sql$/);
+  if (match) {
+    return { version: match[2].replace(/_/g, '.'), name: match[3], direction: match[1] === 'V' ? 'up' : 'down' };
+  }
+  // Prisma: 20240101120000_add_email/migration.sql
+  match = dir.match(/^(\d{8,})_(.+)$/);
+  if (match && base === 'migration.sql') {
+    return { version: match[1], name: match[2], direction: 'up' };
+  }
+  // goose, dbmate, and numbered files in a migrations directory; direction comes from the file's markers
+  match = base.match(/^(\d+)[_-](.+)\.sql$/i);
+  if (match && /(^|\/)(migrat\w*|changelog|versions|schema)(\/|$)/i.test(path.posix.dirname(normalized))) {
+    return { version: match[1], name: match[2] };
+  }
+  return undefined;
+}
+
+/**
+ * Compare migration versions numerically, part by part
+ */
+export function compareVersions(a: string, b: string): number {
+  const pa = a.split('.');
+  const pb = b.split('.');
+  for (let i = 0; i < Math.max(pa.length, pb.length); i++) {
+    const x = (pa[i] ?? '0').replace(/^0+(?=\d)/, '');
+    const y = (pb[i] ?? '0').replace(/^0+(?=\d)/, '');
+    if (x.length !== y.length) {
+      return x.length - y.length;
+    }
+    if (x !== y) {
+      return x < y ? -1 : 1;
+    }
+  }
+  return 0;
+}
+
+/**
+ * Statement text split out of a file, before analysis
+ */
+interface RawStatement {
+  text: string;
+  // Text with comments removed and string contents blanked
+  code: string;
+  startLine: number;
+  endLine: number;
+  direction?: 'up' | 'down';
+}
+
+/**
+ * Split SQL into statements
+ * Semicolons inside strings, quoted identifiers, comments, dollar quotes,
+ * BEGIN ... END bodies of triggers and procedures, and goose
+ * StatementBegin/StatementEnd blocks do not end a statement; MySQL
+ * DELIMITER lines change the delimiter. goose and dbmate up/down markers set
+ * the direction of the statements after them
+ */
+function splitStatements(content: string): RawStatement[] {
+  const statements: RawStatement[] = [];
+  let delimiter = ';';
+  let text = '';
+  let code = '';
+  let startLine = 0;
+  let endLine = 0;
+  let line = 1;
+  let direction: 'up' | 'down' | undefined;
+  let grouped = false;
+  let i = 0;
+
+  const flush = () => {
+    if (code.trim() !== '') {
+      statements.push({ text: text.trim(), code: code.trim(), startLine, endLine, direction });
+    }
+    text = '';
+    code = '';
+    startLine = 0;
+  };
+  const append = (raw: string, codeText: string) => {
+    const hasCode = codeText.trim() !== '';
+    if (startLine === 0 && hasCode) {
+      startLine = line;
+    }
+    // Comments and blank lines before a statement are not part of it
+    if (startLine !== 0) {
+      text += raw;
+    }
+    code += codeText;
+    line += (raw.match(/\n/g) ?? []).length;
+    if (hasCode) {
+      endLine = line;
+    }
+  };
+  // Depth of BEGIN ... END blocks in a trigger or procedure body
+  const blockDepth = (): number => {
+    if (!BLOCK_STATEMENT.test(code)) {
+      return 0;
+    }
+    const openers = (code.match(/\bBEGIN\b|(?<!\bEND\s+)\bCASE\b/gi) ?? []).length;
+    const closers = (code.match(/\bEND\b(?!\s+(?:IF|LOOP|WHILE|REPEAT|FOR)\b)/gi) ?? []).length;
+    return openers - closers;
+  };
+
+  while (i < content.length) {
+    const rest = content.substring(i, i + 2);
+    const atLineStart = i === 0 || content[i - 1] === '\n';
+
+    if (atLineStart && code.trim() === '') {
+      const delimiterLine = content.substring(i).match(/^DELIMITER[ \t]+(\S+)[^\n]*/i);
+      if (delimiterLine) {
+        delimiter = delimiterLine[1];
+        append(delimiterLine[0], '');
+        i += delimiterLine[0].length;
+        continue;
+      }
+    }
+    if (rest === '--') {
+      const end = content.indexOf('\n', i);
+      const comment = content.substring(i, end < 0 ? content.length : end);
+      const marker = comment.match(/\+goose\s+(Up|Down|StatementBegin|StatementEnd)\b|migrate:(up|down)\b/i);
+      if (marker) {
+        const word = (marker[1] ?? marker[2]).toLowerCase();
+        if (word === 'statementbegin') {
+          grouped = true;
+        } else if (word === 'statementend') {
+          grouped = false;
+          flush();
+        } else {
+          flush();
+          direction = word as 'up' | 'down';
+        }
+      }
+      append(comment, ' ');
+      i += comment.length;
+      continue;
+    }
+    if (rest === '/*') {
+      const end = content.indexOf('*/', i + 2);
+      const comment = content.substring(i, end < 0 ? content.length : end + 2);
+      append(comment, ' ');
+      i += comment.length;
+      continue;
+    }
+    const ch = content[i];
+    if (ch === "'" || ch === '"' || ch === '`') {
+      let j = i + 1;
+      while (j < content.length) {
+        if (content[j] === ch) {
+          // A doubled quote is an escaped quote
+          if (content[j + 1] === ch) {
+            j += 2;
+            continue;
+          }
+          break;
+        }
+        j += content[j] === '\\' && ch === "'" ? 2 : 1;
+      }
+      const quoted = content.substring(i, j + 1);
+      // Identifiers are kept for table names; string contents are blanked
+      append(quoted, ch === "'" ? "''" : quoted);
+      i = j + 1;
+      continue;
+    }
+    if (ch === '$') {
+      const tag = content.substring(i).match(/^\$[A-Za-z_]*\$/);
+      if (tag) {
+        const end = content.indexOf(tag[0], i + tag[0].length);
+        const body = content.substring(i, end < 0 ? content.length : end + tag[0].length);
+        append(body, ' $$ ');
+        i += body.length;
+        continue;
+      }
+    }
+    if (content.startsWith(delimiter, i) && !grouped && (delimiter !== ';' || blockDepth() <= 0)) {
+      append(delimiter, '');
+      flush();
+      i += delimiter.length;
+      continue;
+    }
+    append(ch, ch);
+    i++;
+  }
+  flush();
+  return statements;
+}
+
+/**
+ * Leading keywords of a statement, e.g. "CREATE TABLE" or "INSERT"
+ */
+export function statementKind(code: string): string {
+  const words = code.replace(/\s+/g, ' ').trim().toUpperCase().split(' ');
+  const verb = words[0]?.replace(/[^A-Z]/g, '') ?? '';
+  if (verb === 'CREATE' || verb === 'ALTER' || verb === 'DROP') {
+    const object = words.slice(1).find((word) =>
+      !['OR', 'REPLACE', 'UNIQUE', 'TEMP', 'TEMPORARY', 'GLOBAL', 'LOCAL', 'UNLOGGED', 'IF', 'NOT', 'EXISTS', 'CONCURRENTLY', 'MATERIALIZED'].includes(word) &&
+      !word.startsWith('DEFINER'));
+    const materialized = words.includes('MATERIALIZED') && object === 'VIEW' ? 'MATERIALIZED ' : '';
+    return object ? `${verb} ${materialized}${object.replace(/[^A-Z]/g, '')}` : verb;
+  }
+  return verb;
+}
+
+/**
+ * Split a parenthesized or action list at top-level commas
+ */
+function splitTopLevel(text: string): string[] {
+  const parts: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let i = 0; i < text.length; i++) {
+    if (text[i] === '(') {
+      depth++;
+    } else if (text[i] === ')') {
+      depth--;
+    } else if (text[i] === ',' && depth === 0) {
+      parts.push(text.substring(start, i));
+      start = i + 1;
+    }
+  }
+  parts.push(text.substring(start));
+  return parts.map((part) => part.trim()).filter(Boolean);
+}
+
+/**
+ * Tables a statement touches
+ */
+function statementTables(code: string): string[] {
+  const tables: string[] = [];
+  for (const pattern of TABLE_PATTERNS) {
+    pattern.lastIndex = 0;
+    let match: RegExpExecArray | null;
+    while ((match = pattern.exec(code)) !== null) {
+      const table = normalizeIdentifier(match[1]);
+      if (!NOT_TABLES.has(table.toLowerCase()) && !tables.some((t) => t.toLowerCase() === table.toLowerCase())) {
+        tables.push(table);
+      }
+    }
+  }
+  return tables;
+}
+
+/**
+ * Columns a CREATE TABLE or ALTER TABLE statement defines or changes
+ */
+function statementColumns(code: string, kind: string): ColumnChange[] {
+  const columns: ColumnChange[] = [];
+  const head = code.match(new RegExp(`^\\s*(?:CREATE|ALTER)\\b[^(]*?\\bTABLE\\s+(?:IF\\s+(?:NOT\\s+)?EXISTS\\s+)?(?:ONLY\\s+)?${IDENT}`, 'i'));
+  if (!head) {
+    return columns;
+  }
+  const table = normalizeIdentifier(head[1]);
+  const body = code.substring(head[0].length);
+  // CREATE TABLE ... AS SELECT takes its columns from the query
+  if (/^\s*AS\b/i.test(body)) {
+    return columns;
+  }
+
+  if (kind === 'CREATE TABLE') {
+    const open = body.indexOf('(');
+    const close = body.lastIndexOf(')');
+    if (open < 0 || close < open) {
+      return columns;
+    }
+    for (const element of splitTopLevel(body.substring(open + 1, close))) {
+      const name = element.match(new RegExp(`^${PART}`));
+      if (name && !NOT_COLUMNS.has(name[0].toLowerCase())) {
+        columns.push({ table, column: normalizeIdentifier(name[0]), change: 'define' });
+      }
+    }
+    return columns;
+  }
+
+  for (const action of splitTopLevel(body)) {
+    let match = action.match(new RegExp(`^ADD\\s+(?:COLUMN\\s+)?(?:IF\\s+NOT\\s+EXISTS\\s+)?(${PART})`, 'i'));
+    if (match && !NOT_COLUMNS.has(match[1].toLowerCase())) {
+      columns.push({ table, column: normalizeIdentifier(match[1]), change: 'add' });
+      continue;
+    }
+    match = action.match(new RegExp(`^DROP\\s+(?:COLUMN\\s+)?(?:IF\\s+EXISTS\\s+)?(${PART})`, 'i'));
+    if (match && !NOT_COLUMNS.has(match[1].toLowerCase()) && !/^(default|not)$/i.test(match[1])) {
+      columns.push({ table, column: normalizeIdentifier(match[1]), change: 'drop' });
+      continue;
+    }
+    match = action.match(new RegExp(`^RENAME\\s+(?:COLUMN\\s+)?(${PART})\\s+TO\\s+(${PART})`, 'i'));
+    if (match && !/^to$/i.test(match[1])) {
+      columns.push({ table, column: normalizeIdentifier(match[1]), change: 'rename', newName: normalizeIdentifier(match[2]) });
+      continue;
+    }
+    // MySQL: CHANGE [COLUMN] old new type
+    match = action.match(new RegExp(`^CHANGE\\s+(?:COLUMN\\s+)?(${PART})\\s+(${PART})`, 'i'));
+    if (match) {
+      const [from, to] = [normalizeIdentifier(match[1]), normalizeIdentifier(match[2])];
+      columns.push(from === to ? { table, column: from, change: 'alter' } : { table, column: from, change: 'rename', newName: to });
+      continue;
+    }
+    match = action.match(new RegExp(`^(?:ALTER|MODIFY)\\s+(?:COLUMN\\s+)?(${PART})`, 'i'));
+    if (match && !NOT_COLUMNS.has(match[1].toLowerCase())) {
+      columns.push({ table, column: normalizeIdentifier(match[1]), change: 'alter' });
+    }
+  }
+  return columns;
+}
+
+/**
+ * Split a SQL file into analyzed statements
+ */
+export function parseSqlStatements(content: string, filePath: string): SqlStatement[] {
+  const migration = migrationInfo(filePath);
+  return splitStatements(content).map((raw) => {
+    const kind = statementKind(raw.code);
+    return {
+      filePath,
+      startLine: raw.startLine,
+      endLine: raw.endLine,
+      text: raw.text,
+      kind,
+      tables: statementTables(raw.code),
+      columns: statementColumns(raw.code, kind),
+      migration: migration && { ...migration, direction: migration.direction ?? raw.direction },
+    };
+  });
+}
+
+/**
+ * Check if a statement passes a search's filters
+ */
+function matchesStatement(statement: SqlStatement, options: SqlSearchOptions, patterns: { column?: RegExp; text?: RegExp }): boolean {
+  if (options.table && !statement.tables.some((table) => matchesTable(table, options.table!))) {
+    return false;
+  }
+  if (options.statement && !statement.kind.startsWith(options.statement.trim().toUpperCase().replace(/\s+/g, ' '))) {
+    return false;
+  }
+  if (patterns.text && !patterns.text.test(statement.text)) {
+    return false;
+  }
+  if (options.column) {
+    const wanted = options.column.toLowerCase();
+    const changed = statement.columns.some((change) =>
+      (change.column.toLowerCase() === wanted || change.newName?.toLowerCase() === wanted) &&
+      (!options.table || matchesTable(change.table, options.table)));
+    return changed || patterns.column!.test(statement.text);
+  }
+  return true;
+}
+
+/**
+ * Search the workspace's SQL files statement by statement
+ * Migrations come first, in version order, then other files by path
+ */
+export async function searchSql(workspaceDir: string, options: SqlSearchOptions): Promise<SqlSearchResult> {
+  if (!options.table && !options.column && !options.statement && !options.pattern) {
+    throw new ToolError('invalid-argument', 'at least one of table, column, statement, and pattern is required');
+  }
+  const maxResults = options.maxResults ?? 50;
+  const patterns = {
+    column: options.column ? new RegExp(`(^|[^\\w$])${escapeRegExp(options.column)}(?![\\w$])`, 'i') : undefined,
+    text: options.pattern ? new RegExp(options.pattern, 'i') : undefined,
+  };
+
+  const pathPrefix = options.path ? resolveWorkspacePath(workspaceDir, options.path) : undefined;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix })).filter((file) => isSqlFile(file.relativePath));
+
+  const statements: SqlStatement[] = [];
+  for (const file of files) {
+    try {
+      const content = await readFileText(file.absolutePath);
+      statements.push(...parseSqlStatements(content, file.relativePath).filter((statement) => matchesStatement(statement, options, patterns)));
+    } catch (err) {
+      sqlLogger.debug('Could not read %s: %s', file.relativePath, err);
+    }
+  }
+
+  statements.sort((a, b) => {
+    if (a.migration && b.migration) {
+      return compareVersions(a.migration.version, b.migration.version) || a.filePath.localeCompare(b.filePath) || a.startLine - b.startLine;
+    }
+    if (a.migration || b.migration) {
+      return a.migration ? -1 : 1;
+    }
+    return a.filePath.localeCompare(b.filePath) || a.startLine - b.startLine;
+  });
+
+  return {
+    statements: statements.slice(0, maxResults),
+    filesScanned: files.length,
+    truncated: statements.length > maxResults,
+  };
+}
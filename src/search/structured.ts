@@ -0,0 +1,487 @@
+/**
+ * Structured file reading with positions - YAML and JSON as node trees
+ * Unlike the configuration parser, this reader is tolerant: it accepts the
+ * YAML found in infrastructure repositories (multiple documents, block
+ * scalars, anchors and tags, flow collections) and JSON with comments and
+ * trailing commas, and records where each key and value is so matches can
+ * be reported by line
+ */
+
+import { stripComment } from '../config/parse.js';
+
+/**
+ * Position of a node, 1-indexed
+ */
+export interface NodePosition {
+  line: number;
+  column: number;
+}
+
+/**
+ * A value in a YAML or JSON document
+ */
+export type StructuredNode =
+  | ({ kind: 'map'; entries: Array<{ key: string; keyPosition: NodePosition; value: StructuredNode }> } & NodePosition)
+  | ({ kind: 'seq'; items: StructuredNode[] } & NodePosition)
+  | ({ kind: 'scalar'; value: string; quoted: boolean } & NodePosition);
+
+/**
+ * Extensions read as YAML
+ */
+const YAML_EXTENSIONS = ['.yaml', '.yml'];
+
+/**
+ * Extensions read as JSON (with comments allowed)
+ */
+const JSON_EXTENSIONS = ['.json', '.jsonc', '.json5'];
+
+/**
+ * Format of a structured file, from its extension
+ */
+export function structuredFormat(filePath: string): 'yaml' | 'json' | undefined {
+  const name = filePath.toLowerCase();
+  if (YAML_EXTENSIONS.some((ext) => name.endsWith(ext))) {
+    return 'yaml';
+  }
+  if (JSON_EXTENSIONS.some((ext) => name.endsWith(ext))) {
+    return 'json';
+  }
+  return undefined;
+}
+
+/**
+ * Parse a file into its documents (YAML files may hold several)
+ */
+export function parseStructured(content: string, format: 'yaml' | 'json'): StructuredNode[] {
+  return format === 'json' ? [parseJsonNodes(content)] : parseYamlNodes(content);
+}
+
+/**
+ * Render a node compactly, for reporting collection values
+ */
+export function renderNode(node: StructuredNode, maxLength = 120): string {
+  const render = (n: StructuredNode): string => {
+    switch (n.kind) {
+      case 'scalar':
+        return n.quoted ? JSON.stringify(n.value) : n.value;
+      case 'seq':
+        return `[${n.items.map(render).join(', ')}]`;
+      case 'map':
+        return `{${n.entries.map((entry) => `${entry.key}: ${render(entry.value)}`).join(', ')}}`;
+    }
+  };
+  const text = render(node).replace(/\s+/g, ' ');
+  return text.length > maxLength ? text.substring(0, maxLength - 1) + '…' : text;
+}
+
+// ---------------------------------------------------------------------------
+// JSON
+
+/**
+ * Parse JSON, allowing // and /* comments and trailing commas
+ */
+export function parseJsonNodes(content: string): StructuredNode {
+  let pos = 0;
+  let line = 1;
+  let lineStart = 0;
+
+  const position = (): NodePosition => ({ line, column: pos - lineStart + 1 });
+  const fail = (message: string): never => {
+    throw new Error(`line ${line}: ${message}`);
+  };
+  const advance = (count = 1) => {
+    for (let i = 0; i < count; i++) {
+      if (content[pos] === '\n') {
+        line++;
+        lineStart = pos + 1;
+      }
+      pos++;
+    }
+  };
+  const skipSpace = () => {
+    while (pos < content.length) {
+      if (/\s/.test(content[pos])) {
+        advance();
+      } else if (content.startsWith('//', pos)) {
+        while (pos < content.length && content[pos] !== '\n') {
+          advance();
+        }
+      } else if (content.startsWith('/*', pos)) {
+        const end = content.indexOf('*/', pos + 2);
+        advance((end < 0 ? content.length : end + 2) - pos);
+      } else {
+        break;
+      }
+    }
+  };
+  const readString = (): string => {
+    const start = pos;
+    advance();
+    while (pos < content.length && content[pos] !== '"') {
+      advance(content[pos] === '\\' ? 2 : 1);
+    }
+    if (pos >= content.length) {
+      fail('unterminated string');
+    }
+    advance();
+    try {
+      return JSON.parse(content.substring(start, pos));
+    } catch (err) {
+      return content.substring(start + 1, pos - 1);
+    }
+  };
+
+  const value = (): StructuredNode => {
+    skipSpace();
+    const at = position();
+    const c = content[pos];
+    if (c === '{') {
+      advance();
+      const entries: Array<{ key: string; keyPosition: NodePosition; value: StructuredNode }> = [];
+      for (;;) {
+        skipSpace();
+        if (content[pos] === '}') {
+          advance();
+          break;
+        }
+        const keyPosition = position();
+        if (content[pos] !== '"') {
+          fail('expected a property name');
+        }
+        const key = readString();
+        skipSpace();
+        if (content[pos] !== ':') {
+          fail('expected ":"');
+        }
+        advance();
+        entries.push({ key, keyPosition, value: value() });
+        skipSpace();
+        if (content[pos] === ',') {
+          advance();
+        } else if (content[pos] !== '}') {
+          fail('expected "," or "}"');
+        }
+      }
+      return { kind: 'map', entries, ...at };
+    }
+    if (c === '[') {
+      advance();
+      const items: StructuredNode[] = [];
+      for (;;) {
+        skipSpace();
+        if (content[pos] === ']') {
+          advance();
+          break;
+        }
+        items.push(value());
+        skipSpace();
+        if (content[pos] === ',') {
+          advance();
+        } else if (content[pos] !== ']') {
+          fail('expected "," or "]"');
+        }
+      }
+      return { kind: 'seq', items, ...at };
+    }
+    if (c === '"') {
+      return { kind: 'scalar', value: readString(), quoted: true, ...at };
+    }
+    const literal = content.substring(pos).match(/^[^\s,\]}/]+/);
+    if (!literal) {
+      fail(pos >= content.length ? 'unexpected end of input' : `unexpected "${c}"`);
+    }
+    advance(literal![0].length);
+    return { kind: 'scalar', value: literal![0], quoted: false, ...at };
+  };
+
+  const root = value();
+  skipSpace();
+  if (pos < content.length) {
+    fail(`unexpected "${content[pos]}"`);
+  }
+  return root;
+}
+
+// ---------------------------------------------------------------------------
+// YAML
+
+/**
+ * Key of a block mapping line, quoted or plain, followed by ": " or a final ":"
+ */
+const YAML_KEY = /^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s#'"{[\]}&*!|>%@`-][^#]*?|-[^\s#][^#]*?)\s*:(?:\s+|$)/;
+
+/**
+ * Characters after which a quote starts a YAML string rather than being part of a plain scalar
+ */
+const YAML_QUOTE_AFTER = /[\s:[{,-]/;
+
+/**
+ * Remove a trailing YAML comment
+ */
+function stripYamlComment(text: string): string {
+  return stripComment(text, YAML_QUOTE_AFTER);
+}
+
+/**
+ * Drop an anchor (&name) and tag (!tag, !!str) in front of a value
+ */
+function stripProperties(text: string): string {
+  return text.replace(/^(?:(?:&[^\s]+|![^\s]*)\s*)+/, '');
+}
+
+/**
+ * Unquote a scalar, returning whether it was quoted
+ */
+function yamlScalar(text: string): { value: string; quoted: boolean } {
+  if (text.length >= 2 && text.startsWith('"') && text.endsWith('"')) {
+    try {
+      return { value: JSON.parse(text), quoted: true };
+    } catch (err) {
+      return { value: text.slice(1, -1), quoted: true };
+    }
+  }
+  if (text.length >= 2 && text.startsWith("'") && text.endsWith("'")) {
+    return { value: text.slice(1, -1).replace(/''/g, "'"), quoted: true };
+  }
+  return { value: text, quoted: false };
+}
+
+/**
+ * Parse a flow collection ([a, b], {k: v}) or scalar on one line
+ */
+function parseFlow(text: string, line: number, column: number): StructuredNode {
+  let pos = 0;
+  const at = (): NodePosition => ({ line, column: column + pos });
+  const skipSpace = () => {
+    while (pos < text.length && /\s/.test(text[pos])) {
+      pos++;
+    }
+  };
+  const scalarText = (): string => {
+    skipSpace();
+    if (text[pos] === '"' || text[pos] === "'") {
+      const quote = text[pos];
+      const start = pos++;
+      while (pos < text.length && text[pos] !== quote) {
+        pos += text[pos] === '\\' && quote === '"' ? 2 : 1;
+      }
+      pos++;
+      return text.substring(start, pos);
+    }
+    const start = pos;
+    while (pos < text.length && !',]}'.includes(text[pos]) && !(text[pos] === ':' && /\s|$/.test(text[pos + 1] ?? ''))) {
+      pos++;
+    }
+    return text.substring(start, pos).trim();
+  };
+  const value = (): StructuredNode => {
+    skipSpace();
+    const position = at();
+    if (text[pos] === '[') {
+      pos++;
+      const items: StructuredNode[] = [];
+      for (skipSpace(); pos < text.length && text[pos] !== ']'; skipSpace()) {
+        items.push(value());
+        skipSpace();
+        if (text[pos] === ',') {
+          pos++;
+        }
+      }
+      pos++;
+      return { kind: 'seq', items, ...position };
+    }
+    if (text[pos] === '{') {
+      pos++;
+      const entries: Array<{ key: string; keyPosition: NodePosition; value: StructuredNode }> = [];
+      for (skipSpace(); pos < text.length && text[pos] !== '}'; skipSpace()) {
+        const keyPosition = at();
+        const key = yamlScalar(scalarText()).value;
+        skipSpace();
+        let entryValue: StructuredNode = { kind: 'scalar', value: '', quoted: false, ...at() };
+        if (text[pos] === ':') {
+          pos++;
+          entryValue = value();
+        }
+        entries.push({ key, keyPosition, value: entryValue });
+        skipSpace();
+        if (text[pos] === ',') {
+          pos++;
+        }
+      }
+      pos++;
+      return { kind: 'map', entries, ...position };
+    }
+    return { kind: 'scalar', ...yamlScalar(stripProperties(scalarText())), ...position };
+  };
+  return value();
+}
+
+/**
+ * Parse the documents of a YAML file
+ */
+export function parseYamlNodes(content: string): StructuredNode[] {
+  const lines = content.split('\n').map((line) => line.replace(/\r$/, '').replace(/\t/g, ' '));
+  const documents: StructuredNode[] = [];
+  let i = 0;
+
+  const indentOf = (text: string) => text.length - text.trimStart().length;
+  const isBlank = (text: string) => stripYamlComment(text).trim() === '';
+  const isDocumentMarker = (text: string) => /^(---|\.\.\.)(\s|$)/.test(text);
+  const isSeqItem = (text: string) => /^-(\s|$)/.test(text.trimStart());
+
+  // Index of the next line with content in the current document, or -1
+  const nextLine = (): number => {
+    while (i < lines.length && isBlank(lines[i]) && !isDocumentMarker(lines[i])) {
+      i++;
+    }
+    return i < lines.length && !isDocumentMarker(lines[i]) && !lines[i].startsWith('%') ? i : -1;
+  };
+
+  const blockScalar = (indent: number, folded: boolean): string => {
+    const body: string[] = [];
+    while (i < lines.length && !isDocumentMarker(lines[i]) && (lines[i].trim() === '' || indentOf(lines[i]) > indent)) {
+      body.push(lines[i]);
+      i++;
+    }
+    while (body.length > 0 && body[body.length - 1].trim() === '') {
+      body.pop();
+    }
+    const margin = Math.min(...body.filter((text) => text.trim() !== '').map(indentOf));
+    const text = body.map((text) => text.substring(Number.isFinite(margin) ? margin : 0));
+    return folded ? text.join(' ').replace(/\s+/g, ' ').trim() : text.join('\n');
+  };
+
+  // Value after "key:" or "- ", on the same line or the lines below
+  const inlineValue = (rest: string, lineNo: number, column: number, indent: number, allowSeqAtIndent: boolean): StructuredNode => {
+    const text = stripProperties(stripYamlComment(rest).trim());
+    const offset = rest.length - rest.trimStart().length + (stripYamlComment(rest).trim().length - text.length);
+    const position = { line: lineNo + 1, column: column + offset };
+    if (text === '') {
+      const next = nextLine();
+      if (next >= 0 && (indentOf(lines[next]) > indent || (allowSeqAtIndent && indentOf(lines[next]) === indent && isSeqItem(lines[next])))) {
+        return block(indentOf(lines[next]));
+      }
+      return { kind: 'scalar', value: '', quoted: false, ...position };
+    }
+    if (/^[|>][-+0-9]*$/.test(text)) {
+      return { kind: 'scalar', value: blockScalar(indent, text.startsWith('>')), quoted: false, ...position };
+    }
+    if (text.startsWith('[') || text.startsWith('{')) {
+      // Flow collections may continue on the following lines
+      let flow = text;
+      while (!flowBalanced(flow) && i < lines.length && !isDocumentMarker(lines[i])) {
+        flow += ' ' + stripYamlComment(lines[i]).trim();
+        i++;
+      }
+      return parseFlow(flow, position.line, position.column);
+    }
+    // Plain scalars may continue on more indented lines
+    let scalar = text;
+    while (i < lines.length && !isBlank(lines[i]) && indentOf(lines[i]) > indent && !YAML_KEY.test(lines[i].trimStart()) &&
+        !isSeqItem(lines[i]) && !isDocumentMarker(lines[i])) {
+      scalar += ' ' + stripYamlComment(lines[i]).trim();
+      i++;
+    }
+    return { kind: 'scalar', ...yamlScalar(scalar), ...position };
+  };
+
+  const mapping = (indent: number): StructuredNode => {
+    const first = nextLine();
+    const node: StructuredNode = { kind: 'map', entries: [], line: first + 1, column: indent + 1 };
+    for (let at = first; at >= 0 && indentOf(lines[at]) === indent && !isSeqItem(lines[at]); at = nextLine()) {
+      const text = lines[at].substring(indent);
+      const key = text.match(YAML_KEY);
+      if (!key) {
+        // Not a key line (e.g. a stray scalar); skip it
+        i = at + 1;
+        continue;
+      }
+      i = at + 1;
+      node.entries.push({
+        key: yamlScalar(key[1].trim()).value,
+        keyPosition: { line: at + 1, column: indent + 1 },
+        value: inlineValue(text.substring(key[0].length), at, indent + key[0].length + 1, indent, true),
+      });
+    }
+    return node;
+  };
+
+  const sequence = (indent: number): StructuredNode => {
+    const first = nextLine();
+    const node: StructuredNode = { kind: 'seq', items: [], line: first + 1, column: indent + 1 };
+    for (let at = first; at >= 0 && indentOf(lines[at]) === indent && isSeqItem(lines[at]); at = nextLine()) {
+      const rest = lines[at].substring(indent + 1);
+      const itemIndent = indent + 1 + (rest.length - rest.trimStart().length);
+      if (rest.trim() !== '' && (YAML_KEY.test(rest.trimStart()) || isSeqItem(rest))) {
+        // "- key: value" starts a mapping (and "- - x" a sequence) indented past the dash
+        lines[at] = ' '.repeat(itemIndent) + rest.trimStart();
+        i = at;
+        node.items.push(block(itemIndent));
+      } else {
+        i = at + 1;
+        node.items.push(inlineValue(rest, at, indent + 2, indent, false));
+      }
+    }
+    return node;
+  };
+
+  const block = (indent: number): StructuredNode => {
+    const at = nextLine();
+    const text = lines[at].trimStart();
+    if (isSeqItem(text)) {
+      return sequence(indent);
+    }
+    if (YAML_KEY.test(text)) {
+      return mapping(indent);
+    }
+    i = at + 1;
+    return inlineValue(lines[at].substring(indent), at, indent + 1, indent - 1, false);
+  };
+
+  while (i < lines.length) {
+    if (isDocumentMarker(lines[i]) || lines[i].startsWith('%')) {
+      // "--- value" puts the document's value on the marker line
+      const inline = lines[i].startsWith('---') ? lines[i].substring(3).trim() : '';
+      i++;
+      if (inline !== '' && !inline.startsWith('#')) {
+        documents.push(inlineValue(inline, i - 1, 5, -1, false));
+      }
+      continue;
+    }
+    const at = nextLine();
+    if (at < 0) {
+      if (i < lines.length && !isDocumentMarker(lines[i]) && !lines[i].startsWith('%')) {
+        i++;
+      }
+      continue;
+    }
+    const start = i;
+    documents.push(block(indentOf(lines[at])));
+    if (i === start) {
+      i++;
+    }
+  }
+  return documents;
+}
+
+/**
+ * Check if the brackets of a flow collection are closed, ignoring quoted text
+ */
+function flowBalanced(text: string): boolean {
+  let depth = 0;
+  let quote: string | null = null;
+  for (const c of text) {
+    if (quote) {
+      if (c === quote) {
+        quote = null;
+      }
+    } else if (c === '"' || c === "'") {
+      quote = c;
+    } else if (c === '[' || c === '{') {
+      depth++;
+    } else if (c === ']' || c === '}') {
+      depth--;
+    }
+  }
+  return depth <= 0;
+}
@@ -0,0 +1,289 @@
+/**
+ * Trigram index - narrows literal searches to files that can contain a match
+ */
+
+import * as fs from 'fs';
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { runPool } from '../workspace/pool.js';
+import { uncancellable } from '../workspace/cancellation.js';
+import { isBinaryFile } from '../workspace/binary.js';
+import { decodeText } from '../workspace/encoding.js';
+import { searchableText } from './notebook.js';
+import { MemoryBudget, sharedMemoryBudget } from '../workspace/memory.js';
+import { contentHash } from '../semantic/store.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Extract the set of lowercase trigrams of a string
+ */
+export function extractTrigrams(text: string): Set<string> {
+  const trigrams = new Set<string>();
+  const lower = text.toLowerCase();
+  for (let i = 0; i + 3 <= lower.length; i++) {
+    const trigram = lower.substring(i, i + 3);
+    if (!/\n/.test(trigram)) {
+      trigrams.add(trigram);
+    }
+  }
+  return trigrams;
+}
+
+/**
+ * Index statistics
+ */
+export interface TrigramIndexStats {
+  files: number;
+  trigrams: number;
+  reindexed: number;
+  removed: number;
+  // Files left out because the memory budget was reached
+  unindexed: number;
+  durationMs: number;
+}
+
+/**
+ * File state recorded at indexing time, used to detect changes
+ */
+interface FileState {
+  id: number;
+  mtimeMs: number;
+  size: number;
+  // Hash of the indexed text, which a snapshot entry has to match to be reused
+  hash: string;
+  trigrams: string[];
+}
+
+/**
+ * Trigrams of one file as a snapshot carries them
+ */
+export interface TrigramSnapshotFile {
+  relativePath: string;
+  hash: string;
+  trigrams: string[];
+}
+
+/**
+ * In-memory trigram index over workspace text files
+ */
+export class TrigramIndex {
+  private postings = new Map<string, Set<number>>();
+  private files = new Map<string, FileState>();
+  private paths: string[] = [];
+  // Files not indexed for lack of memory; always candidates, retried on refresh
+  private unindexed = new Set<string>();
+  private refreshing?: Promise<TrigramIndexStats>;
+  // Set once the first refresh completes
+  private loaded = false;
+  // Trigrams from a snapshot, by path, taken instead of extracting them while the text is unchanged
+  private seeds = new Map<string, TrigramSnapshotFile>();
+  // Set by close; refreshes stop reading files
+  private closed = false;
+
+  constructor(private workspaceDir: string, private memory: MemoryBudget = sharedMemoryBudget()) {}
+
+  /**
+   * Bring the index up to date with the workspace
+   * Concurrent callers share a single refresh
+   */
+  refresh(): Promise<TrigramIndexStats> {
+    if (!this.refreshing) {
+      // Shared by every caller, so one call's cancellation does not stop it
+      this.refreshing = uncancellable(() => this.doRefresh()).finally(() => {
+        this.refreshing = undefined;
+      });
+    }
+    return this.refreshing;
+  }
+
+  /**
+   * Stop a refresh in progress and any later one, for an index no longer used
+   */
+  close(): void {
+    this.closed = true;
+  }
+
+  /**
+   * Files that contain every trigram of the literal (case-insensitive)
+   * Returns null when the literal is too short to filter on
+   */
+  candidates(literal: string): string[] | null {
+    const trigrams = extractTrigrams(literal);
+    if (trigrams.size === 0) {
+      return null;
+    }
+
+    // Intersect starting from the rarest trigram
+    const sets = Array.from(trigrams).map((t) => this.postings.get(t) || new Set<number>());
+    sets.sort((a, b) => a.size - b.size);
+
+    let result = Array.from(sets[0]);
+    for (let i = 1; i < sets.length && result.length > 0; i++) {
+      result = result.filter((id) => sets[i].has(id));
+    }
+
+    const paths = result.map((id) => this.paths[id]);
+    paths.push(...this.unindexed);
+    return paths.sort();
+  }
+
+  /**
+   * Indexed file paths, relative to the workspace
+   */
+  indexedFiles(): string[] {
+    return Array.from(this.files.keys()).sort();
+  }
+
+  /**
+   * Check whether the index has been built (it is built on the first search)
+   */
+  isLoaded(): boolean {
+    return this.loaded;
+  }
+
+  /**
+   * Trigrams of every indexed file, to be written to a snapshot
+   */
+  snapshotFiles(): TrigramSnapshotFile[] {
+    return Array.from(this.files.entries())
+      .sort(([a], [b]) => (a < b ? -1 : a > b ? 1 : 0))
+      .map(([relativePath, state]) => ({ relativePath, hash: state.hash, trigrams: state.trigrams }));
+  }
+
+  /**
+   * Reuse the trigrams of a snapshot on the next refresh
+   * Files whose text differs from the snapshot's are indexed as usual
+   */
+  seed(files: TrigramSnapshotFile[]): void {
+    for (const file of files) {
+      this.seeds.set(file.relativePath, file);
+    }
+  }
+
+  /**
+   * Current index size
+   */
+  getStats(): { files: number; trigrams: number } {
+    return { files: this.files.size, trigrams: this.postings.size };
+  }
+
+  private async doRefresh(): Promise<TrigramIndexStats> {
+    const startTime = Date.now();
+    const workspaceFiles = this.closed ? [] : await walkWorkspaceFiles(this.workspaceDir);
+
+    const seen = new Set<string>(workspaceFiles.map((f) => f.relativePath));
+    let reindexed = 0;
+    let seeded = 0;
+    await runPool(workspaceFiles, async (file) => {
+      const stats = await fs.promises.stat(file.absolutePath);
+      const state = this.files.get(file.relativePath);
+      if (state && state.mtimeMs === stats.mtimeMs && state.size === stats.size) {
+        return;
+      }
+      if (this.memory.isExceeded()) {
+        // Searched directly instead of through the index; stale entries are dropped
+        this.removeFile(file.relativePath);
+        this.unindexed.add(file.relativePath);
+        return;
+      }
+
+      try {
+        const data = await fs.promises.readFile(file.absolutePath);
+        const id = this.removeFile(file.relativePath);
+        this.unindexed.delete(file.relativePath);
+        if (isBinaryFile(file.relativePath, data)) {
+          return;
+        }
+        // Notebooks are searched by cell source, so their cells are what is indexed
+        const text = searchableText(file.relativePath, decodeText(data));
+        const hash = contentHash(text);
+        const seed = this.seeds.get(file.relativePath);
+        this.seeds.delete(file.relativePath);
+        if (seed && seed.hash === hash) {
+          this.addTrigrams(file.relativePath, seed.trigrams, hash, stats.mtimeMs, stats.size, id);
+          seeded++;
+        } else {
+          this.addTrigrams(file.relativePath, Array.from(extractTrigrams(text)), hash, stats.mtimeMs, stats.size, id);
+        }
+        reindexed++;
+      } catch (err) {
+        toolsLogger.debug('Failed to index %s: %s', file.relativePath, err);
+      }
+    }, { memory: this.memory, shouldStop: () => this.closed });
+
+    let removed = 0;
+    for (const relativePath of Array.from(this.files.keys())) {
+      if (!seen.has(relativePath)) {
+        this.removeFile(relativePath);
+        removed++;
+      }
+    }
+    for (const relativePath of Array.from(this.unindexed)) {
+      if (!seen.has(relativePath)) {
+        this.unindexed.delete(relativePath);
+      }
+    }
+    // Seeds are only good for the first look at a file
+    this.seeds.clear();
+    if (seeded > 0) {
+      toolsLogger.info('Trigram index took %d of %d file(s) from the snapshot', seeded, reindexed);
+    }
+    if (this.unindexed.size > 0) {
+      toolsLogger.warn('Memory budget reached: %d file(s) left out of the trigram index', this.unindexed.size);
+    }
+
+    this.loaded = true;
+    const stats: TrigramIndexStats = {
+      files: this.files.size,
+      trigrams: this.postings.size,
+      reindexed,
+      removed,
+      unindexed: this.unindexed.size,
+      durationMs: Date.now() - startTime,
+    };
+    if (reindexed > 0 || removed > 0) {
+      toolsLogger.debug('Trigram index refreshed: %d file(s) re-indexed, %d removed (%dms)',
+        reindexed, removed, stats.durationMs);
+    }
+    return stats;
+  }
+
+  private addTrigrams(relativePath: string, trigrams: string[], hash: string, mtimeMs: number, size: number, id?: number): void {
+    // Re-indexed files keep their id so the path table does not grow
+    if (id === undefined) {
+      id = this.paths.length;
+      this.paths.push(relativePath);
+    }
+
+    for (const trigram of trigrams) {
+      let posting = this.postings.get(trigram);
+      if (!posting) {
+        posting = new Set();
+        this.postings.set(trigram, posting);
+      }
+      posting.add(id);
+    }
+
+    this.files.set(relativePath, { id, mtimeMs, size, hash, trigrams });
+  }
+
+  private removeFile(relativePath: string): number | undefined {
+    const state = this.files.get(relativePath);
+    if (!state) {
+      return undefined;
+    }
+
+    for (const trigram of state.trigrams) {
+      const posting = this.postings.get(trigram);
+      if (posting) {
+        posting.delete(state.id);
+        if (posting.size === 0) {
+          this.postings.delete(trigram);
+        }
+      }
+    }
+    this.files.delete(relativePath);
+    return state.id;
+  }
+}
@@ -0,0 +1,53 @@
+/**
+ * Tests for symbol-boundary chunking
+ */
+
+import { chunkBySymbols, chunkByLines } from './chunker';
+import { FlatSymbol } from '../tools/symbols';
+import { SymbolKind } from '../protocol/types';
+
+function sym(name: string, start: number, end: number, depth = 0, containerName?: string): FlatSymbol {
+  const range = { start: { line: start, character: 0 }, end: { line: end, character: 0 } };
+  return {
+    name,
+    qualifiedName: containerName ? `${containerName}.${name}` : name,
+    containerName,
+    kind: depth === 0 ? SymbolKind.Class : SymbolKind.Method,
+    range,
+    selectionRange: range,
+    depth,
+  };
+}
+
+const content = Array.from({ length: 40 }, (_, i) => `line ${i + 1}`).join('\n');
+
+describe('Chunker', () => {
+  it('should split by fixed windows without symbols', () => {
+    const chunks = chunkByLines('a.ts', content, { maxLines: 15 });
+    expect(chunks.map((c) => [c.startLine, c.endLine])).toEqual([[1, 15], [16, 30], [31, 40]]);
+  });
+
+  it('should create one chunk per top-level symbol plus gaps', () => {
+    const chunks = chunkBySymbols('a.ts', content, [sym('A', 5, 14), sym('B', 15, 29)], { maxLines: 60 });
+    expect(chunks.map((c) => [c.startLine, c.endLine, c.symbolName])).toEqual([
+      [1, 5, undefined],
+      [6, 15, 'A'],
+      [16, 30, 'B'],
+      [31, 40, undefined],
+    ]);
+    expect(chunks[1].symbolKind).toBe('Class');
+    expect(chunks[1].text.split('\n')[0]).toBe('line 6');
+  });
+
+  it('should split large symbols at their children', () => {
+    const symbols = [sym('A', 0, 39), sym('m1', 2, 19, 1, 'A'), sym('m2', 20, 37, 1, 'A')];
+    const chunks = chunkBySymbols('a.ts', content, symbols, { maxLines: 20 });
+    expect(chunks.map((c) => c.symbolName)).toEqual(['A.m1', 'A.m2']);
+  });
+
+  it('should keep the parent header when it is long enough', () => {
+    const symbols = [sym('A', 0, 39), sym('m1', 5, 19, 1, 'A'), sym('m2', 20, 39, 1, 'A')];
+    const chunks = chunkBySymbols('a.ts', content, symbols, { maxLines: 20 });
+    expect(chunks.map((c) => [c.startLine, c.symbolName])).toEqual([[1, 'A'], [6, 'A.m1'], [21, 'A.m2']]);
+  });
+});
@@ -0,0 +1,201 @@
+/**
+ * Chunker - split source files into searchable chunks at symbol boundaries
+ */
+
+import { SymbolKindNames } from '../protocol/types.js';
+import { FlatSymbol } from '../tools/symbols.js';
+
+/**
+ * A contiguous region of a file indexed as one unit
+ */
+export interface Chunk {
+  id: string;
+  filePath: string; // Relative to the workspace
+  startLine: number; // 1-indexed, inclusive
+  endLine: number; // 1-indexed, inclusive
+  symbolName?: string;
+  symbolKind?: string;
+  text: string;
+}
+
+/**
+ * Chunking options
+ */
+export interface ChunkOptions {
+  maxLines?: number;
+  minLines?: number;
+}
+
+/**
+ * Build a chunk from a 0-indexed, inclusive line range
+ */
+function makeChunk(
+  filePath: string,
+  lines: string[],
+  start: number,
+  end: number,
+  sym?: FlatSymbol
+): Chunk {
+  return {
+    id: `${filePath}:${start + 1}-${end + 1}`,
+    filePath,
+    startLine: start + 1,
+    endLine: end + 1,
+    symbolName: sym?.qualifiedName,
+    symbolKind: sym ? SymbolKindNames[sym.kind] : undefined,
+    text: lines.slice(start, end + 1).join('\n'),
+  };
+}
+
+/**
+ * Rebuild a chunk from stored 1-indexed boundaries
+ */
+export function chunkAt(
+  filePath: string,
+  lines: string[],
+  startLine: number,
+  endLine: number,
+  symbolName?: string,
+  symbolKind?: string
+): Chunk {
+  return {
+    id: `${filePath}:${startLine}-${endLine}`,
+    filePath,
+    startLine,
+    endLine,
+    symbolName,
+    symbolKind,
+    text: lines.slice(startLine - 1, endLine).join('\n'),
+  };
+}
+
+/**
+ * Check whether a line range has any non-blank content
+ */
+function hasContent(lines: string[], start: number, end: number): boolean {
+  for (let i = start; i <= end; i++) {
+    if (lines[i] && lines[i].trim() !== '') {
+      return true;
+    }
+  }
+  return false;
+}
+
+/**
+ * Split a file into fixed-size line windows
+ */
+export function chunkByLines(
+  filePath: string,
+  content: string,
+  options: ChunkOptions = {}
+): Chunk[] {
+  const maxLines = options.maxLines ?? 60;
+  const lines = content.split('\n');
+  const chunks: Chunk[] = [];
+
+  for (let start = 0; start < lines.length; start += maxLines) {
+    const end = Math.min(lines.length, start + maxLines) - 1;
+    if (hasContent(lines, start, end)) {
+      chunks.push(makeChunk(filePath, lines, start, end));
+    }
+  }
+
+  return chunks;
+}
+
+/**
+ * Split a file at symbol boundaries
+ * Top-level symbols become chunks; symbols longer than maxLines are split at
+ * their children, and code between symbols (imports, globals) is kept in
+ * its own chunks so nothing is left unindexed
+ */
+export function chunkBySymbols(
+  filePath: string,
+  content: string,
+  symbols: FlatSymbol[],
+  options: ChunkOptions = {}
+): Chunk[] {
+  const maxLines = options.maxLines ?? 60;
+  const minLines = options.minLines ?? 3;
+  const lines = content.split('\n');
+
+  if (symbols.length === 0) {
+    return chunkByLines(filePath, content, options);
+  }
+
+  const chunks: Chunk[] = [];
+
+  // Emit [start, end] as one or more windows, attributed to sym
+  const emitRange = (start: number, end: number, sym?: FlatSymbol): void => {
+    for (let s = start; s <= end; s += maxLines) {
+      const e = Math.min(end, s + maxLines - 1);
+      if (hasContent(lines, s, e)) {
+        chunks.push(makeChunk(filePath, lines, s, e, sym));
+      }
+    }
+  };
+
+  // Emit a symbol, descending into children when it is too large
+  const emitSymbol = (sym: FlatSymbol, children: FlatSymbol[]): void => {
+    const start = sym.range.start.line;
+    const end = Math.min(sym.range.end.line, lines.length - 1);
+    if (end - start + 1 <= maxLines || children.length === 0) {
+      emitRange(start, end, sym);
+      return;
+    }
+
+    // Header and gaps between children stay attributed to the parent symbol
+    let cursor = start;
+    for (const child of children) {
+      const childStart = Math.max(cursor, child.range.start.line);
+      const childEnd = Math.min(end, child.range.end.line);
+      if (childStart > childEnd) {
+        continue;
+      }
+      if (childStart - cursor >= minLines) {
+        emitRange(cursor, childStart - 1, sym);
+      }
+      emitRange(childStart, childEnd, child);
+      cursor = childEnd + 1;
+    }
+    if (end - cursor + 1 >= minLines) {
+      emitRange(cursor, end, sym);
+    }
+  };
+
+  const topLevel = symbols.filter((s) => s.depth === 0);
+  let cursor = 0;
+
+  for (const sym of topLevel) {
+    const start = sym.range.start.line;
+    const end = sym.range.end.line;
+    if (end < cursor) {
+      continue;
+    }
+
+    if (start - cursor >= minLines) {
+      emitRange(cursor, start - 1);
+    }
+
+    const children = symbols.filter((s) => s.depth === 1 && s.containerName === sym.qualifiedName);
+    emitSymbol(sym, children);
+    cursor = end + 1;
+  }
+
+  if (lines.length - cursor >= minLines) {
+    emitRange(cursor, lines.length - 1);
+  }
+
+  return chunks;
+}
+
+/**
+ * Text used to embed a chunk: its location and symbol give the model context
+ * that the code alone may not carry
+ */
+export function chunkEmbeddingText(chunk: Chunk): string {
+  const header = chunk.symbolName
+    ? `${chunk.filePath} ${chunk.symbolKind || ''} ${chunk.symbolName}`
+    : chunk.filePath;
+  return `${header}\n${chunk.text}`;
+}
@@ -0,0 +1,38 @@
+/**
+ * Tests for the hashing embedding provider
+ */
+
+import { HashingEmbeddingProvider, splitIdentifier, stemWord } from './embeddings';
+import { cosineSimilarity } from './vectorIndex';
+
+describe('Embeddings', () => {
+  it('should split identifiers into words', () => {
+    expect(splitIdentifier('validateEmailAddress')).toEqual(['validate', 'email', 'address']);
+    expect(splitIdentifier('HTTPServer')).toEqual(['http', 'server']);
+    expect(splitIdentifier('parse_json_v2')).toEqual(['parse', 'json', 'v2']);
+  });
+
+  it('should stem word variants to the same root', () => {
+    expect(stemWord('validation')).toBe(stemWord('validate'));
+    expect(stemWord('validates')).toBe(stemWord('validating'));
+    expect(stemWord('parser')).toBe(stemWord('parse'));
+    expect(stemWord('address')).toBe('address');
+  });
+
+  it('should produce unit vectors of the configured size', async () => {
+    const provider = new HashingEmbeddingProvider(64);
+    const [vector] = await provider.embed(['function validateEmail(address) {}']);
+    expect(vector.length).toBe(64);
+    expect(Math.abs(cosineSimilarity(vector, vector) - 1)).toBeLessThan(1e-6);
+  });
+
+  it('should rank related code above unrelated code', async () => {
+    const provider = new HashingEmbeddingProvider();
+    const [query, related, unrelated] = await provider.embed([
+      'where do we validate email addresses',
+      'func isValidEmail(addr string) bool { return emailRegex.MatchString(addr) }',
+      'func openDatabase(dsn string) (*sql.DB, error) { return sql.Open("postgres", dsn) }',
+    ]);
+    expect(cosineSimilarity(query, related)).toBeGreaterThan(cosineSimilarity(query, unrelated));
+  });
+});
@@ -0,0 +1,155 @@
+/**
+ * Embedding providers for semantic search
+ */
+
+/**
+ * Computes fixed-size vector embeddings for text
+ */
+export interface EmbeddingProvider {
+  /** Identifier of the provider and model, used to tell indexes apart */
+  readonly id: string;
+  readonly dimensions: number;
+  embed(texts: string[]): Promise<Float32Array[]>;
+}
+
+/**
+ * Split an identifier into lowercase words
+ * e.g. "validateEmailAddress" -> ["validate", "email", "address"]
+ */
+export function splitIdentifier(identifier: string): string[] {
+  return identifier
+    .replace(/([a-z0-9])([A-Z])/g, '$1 $2')
+    .replace(/([A-Z]+)([A-Z][a-z])/g, '$1 $2')
+    .split(/[^A-Za-z0-9]+/)
+    .filter((w) => w.length > 0)
+    .map((w) => w.toLowerCase());
+}
+
+/**
+ * Suffixes stripped by stemWord, longest first
+ */
+const SUFFIXES: Array<[string, string]> = [
+  ['ations', ''], ['ation', ''], ['ating', ''], ['ators', ''], ['ator', ''],
+  ['ates', ''], ['ated', ''], ['ate', ''], ['sses', 'ss'], ['ies', 'y'],
+  ['ings', ''], ['ing', ''], ['ers', ''], ['er', ''], ['ed', ''], ['es', ''], ['s', ''],
+];
+
+/**
+ * Reduce a word to a crude stem so that "validate", "validates" and
+ * "validation" share features
+ */
+export function stemWord(word: string): string {
+  let stem = word;
+  for (const [suffix, replacement] of SUFFIXES) {
+    if (stem.endsWith(suffix) && stem.length - suffix.length >= 3) {
+      if (suffix !== 's' || !stem.endsWith('ss')) {
+        stem = stem.slice(0, stem.length - suffix.length) + replacement;
+      }
+      break;
+    }
+  }
+  // "parse" and "parser" should agree
+  if (stem.length > 4 && stem.endsWith('e')) {
+    stem = stem.slice(0, -1);
+  }
+  return stem;
+}
+
+/**
+ * Words too common in code and prose to carry meaning
+ */
+const STOP_WORDS = new Set([
+  'the', 'a', 'an', 'of', 'to', 'in', 'on', 'for', 'and', 'or', 'is', 'it', 'we', 'do',
+  'be', 'by', 'at', 'as', 'if', 'this', 'that', 'with', 'where', 'what', 'how', 'which',
+  'return', 'var', 'let', 'const', 'func', 'function', 'def', 'self', 'nil', 'null',
+]);
+
+/**
+ * Check if a lowercase word is too common to be a useful search term
+ */
+export function isStopWord(word: string): boolean {
+  return STOP_WORDS.has(word);
+}
+
+/**
+ * Extract weighted terms from text (stems and adjacent-stem pairs)
+ */
+export function extractTerms(text: string): string[] {
+  const words: string[] = [];
+  for (const raw of text.match(/[A-Za-z_][A-Za-z0-9_]*/g) || []) {
+    for (const word of splitIdentifier(raw)) {
+      if (word.length > 1 && !STOP_WORDS.has(word)) {
+        words.push(stemWord(word));
+      }
+    }
+  }
+
+  const terms = [...words];
+  for (let i = 0; i + 1 < words.length; i++) {
+    terms.push(`${words[i]}_${words[i + 1]}`);
+  }
+  return terms;
+}
+
+/**
+ * 32-bit FNV-1a string hash
+ */
+function hashString(value: string): number {
+  let hash = 0x811c9dc5;
+  for (let i = 0; i < value.length; i++) {
+    hash ^= value.charCodeAt(i);
+    hash = Math.imul(hash, 0x01000193);
+  }
+  return hash >>> 0;
+}
+
+/**
+ * Normalize a vector to unit length in place
+ */
+export function normalizeVector(vector: Float32Array): Float32Array {
+  let norm = 0;
+  for (let i = 0; i < vector.length; i++) {
+    norm += vector[i] * vector[i];
+  }
+  norm = Math.sqrt(norm);
+  if (norm > 0) {
+    for (let i = 0; i < vector.length; i++) {
+      vector[i] /= norm;
+    }
+  }
+  return vector;
+}
+
+/**
+ * Dependency-free embedding provider using signed feature hashing of
+ * identifier sub-words. It needs no model download and runs offline, at the
+ * cost of only matching on shared vocabulary rather than true meaning
+ */
+export class HashingEmbeddingProvider implements EmbeddingProvider {
+  readonly id: string;
+
+  constructor(readonly dimensions = 512) {
+    this.id = `hashing-${dimensions}`;
+  }
+
+  async embed(texts: string[]): Promise<Float32Array[]> {
+    return texts.map((text) => this.embedOne(text));
+  }
+
+  private embedOne(text: string): Float32Array {
+    const vector = new Float32Array(this.dimensions);
+    const counts = new Map<string, number>();
+    for (const term of extractTerms(text)) {
+      counts.set(term, (counts.get(term) || 0) + 1);
+    }
+
+    for (const [term, count] of counts.entries()) {
+      const hash = hashString(term);
+      const sign = hash & 0x80000000 ? -1 : 1;
+      // Sub-linear term frequency so repeated identifiers do not dominate
+      vector[hash % this.dimensions] += sign * (1 + Math.log(count));
+    }
+
+    return normalizeVector(vector);
+  }
+}
@@ -0,0 +1,304 @@
+/**
+ * Semantic search engine
+ * Keeps the vector index in sync with the workspace and answers queries
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles, resolveWorkspacePath, WorkspaceFile } from '../workspace/walker.js';
+import { readFileText } from '../workspace/overlay.js';
+import { unpinned } from '../workspace/treesnapshot.js';
+import { isSourceFile } from '../workspace/language.js';
+import { runPool } from '../workspace/pool.js';
+import { uncancellable } from '../workspace/cancellation.js';
+import { sharedMemoryBudget } from '../workspace/memory.js';
+import { FlatSymbol } from '../tools/symbols.js';
+import { Chunk, ChunkOptions, chunkAt, chunkBySymbols, chunkByLines, chunkEmbeddingText } from './chunker.js';
+import { EmbeddingProvider } from './embeddings.js';
+import { VectorIndex, VectorHit } from './vectorIndex.js';
+import { IndexStore, contentHash } from './store.js';
+
+const semanticLogger = createLogger(Component.SEMANTIC);
+
+/**
+ * Returns the document symbols of a file, used to chunk at symbol boundaries
+ */
+export type SymbolProvider = (absolutePath: string) => Promise<FlatSymbol[]>;
+
+/**
+ * Semantic engine options
+ */
+export interface SemanticEngineOptions {
+  symbolProvider?: SymbolProvider;
+  chunk?: ChunkOptions;
+  batchSize?: number;
+  // Persist chunks and embeddings here so restarts only re-embed changed chunks
+  storePath?: string;
+}
+
+/**
+ * Options for a semantic query
+ */
+export interface SemanticQueryOptions {
+  topK?: number;
+  pathPrefix?: string;
+}
+
+/**
+ * Index statistics
+ */
+export interface SemanticIndexStats {
+  files: number;
+  chunks: number;
+  reindexed: number;
+  embedded: number;
+  removed: number;
+  durationMs: number;
+}
+
+/**
+ * File state recorded at indexing time, used to detect changes
+ */
+interface FileState {
+  mtimeMs: number;
+  size: number;
+}
+
+/**
+ * Semantic search over workspace chunks
+ */
+export class SemanticSearchEngine {
+  private index: VectorIndex;
+  private fileStates = new Map<string, FileState>();
+  private refreshing?: Promise<SemanticIndexStats>;
+  private store?: IndexStore;
+  private storeLoaded = false;
+  // Set on shutdown; a refresh in progress stops embedding and saves what it has
+  private closing = false;
+
+  constructor(
+    readonly workspaceDir: string,
+    private provider: EmbeddingProvider,
+    private options: SemanticEngineOptions = {}
+  ) {
+    this.index = new VectorIndex(provider.dimensions);
+    if (options.storePath) {
+      // Anything that changes chunk boundaries or vectors invalidates the store
+      const fingerprint = [
+        provider.id,
+        provider.dimensions,
+        options.chunk?.maxLines ?? 60,
+        options.chunk?.minLines ?? 3,
+        options.symbolProvider ? 'symbols' : 'lines',
+      ].join('|');
+      this.store = new IndexStore(options.storePath, fingerprint);
+    }
+  }
+
+  /**
+   * Bring the index up to date with the workspace
+   * Concurrent callers share a single refresh
+   */
+  refresh(): Promise<SemanticIndexStats> {
+    if (!this.refreshing) {
+      // Shared by every caller, so one call's cancellation does not stop it
+      this.refreshing = uncancellable(() => this.doRefresh()).finally(() => {
+        this.refreshing = undefined;
+      });
+    }
+    return this.refreshing;
+  }
+
+  /**
+   * Cut a refresh in progress short and save the store, before the server exits
+   */
+  async close(): Promise<void> {
+    this.closing = true;
+    await this.refreshing?.catch(() => undefined);
+    try {
+      await this.store?.save();
+    } catch (err) {
+      semanticLogger.warn('Failed to save semantic index store %s: %s', this.store!.filePath, err);
+    }
+  }
+
+  /**
+   * Search for chunks matching a natural-language query
+   */
+  async search(query: string, options: SemanticQueryOptions = {}): Promise<VectorHit[]> {
+    // The shared index follows the files as they are now
+    await unpinned(() => this.refresh());
+
+    const [queryVector] = await this.provider.embed([query]);
+    const prefix = options.pathPrefix
+      ? path.relative(this.workspaceDir, resolveWorkspacePath(this.workspaceDir, options.pathPrefix))
+      : '';
+    const filter = prefix
+      ? (chunk: Chunk) => chunk.filePath === prefix || chunk.filePath.startsWith(prefix + path.sep)
+      : undefined;
+
+    return this.index.search(queryVector, options.topK ?? 10, filter);
+  }
+
+  /**
+   * Indexed chunks of a file (relative to the workspace)
+   */
+  chunksForFile(relativePath: string): Chunk[] {
+    return this.index.getFile(relativePath).map((entry) => entry.chunk);
+  }
+
+  /**
+   * Forget a file so that it is re-indexed on the next refresh
+   */
+  invalidateFile(absolutePath: string): void {
+    const relativePath = path.relative(this.workspaceDir, absolutePath);
+    this.fileStates.delete(relativePath);
+    this.index.removeFile(relativePath);
+  }
+
+  /**
+   * Embedding provider in use
+   */
+  get providerId(): string {
+    return this.provider.id;
+  }
+
+  /**
+   * Current index size
+   */
+  getStats(): { files: number; chunks: number } {
+    return { files: this.fileStates.size, chunks: this.index.size() };
+  }
+
+  private async doRefresh(): Promise<SemanticIndexStats> {
+    const startTime = Date.now();
+    if (this.store && !this.storeLoaded) {
+      this.storeLoaded = true;
+      await this.store.load();
+    }
+    const files = (await walkWorkspaceFiles(this.workspaceDir)).filter((f) => isSourceFile(f.absolutePath));
+
+    const seen = new Set<string>(files.map((f) => f.relativePath));
+    const statuses = await runPool(files, async (file) => {
+      const stats = await fs.promises.stat(file.absolutePath);
+      const state = this.fileStates.get(file.relativePath);
+      if (!state || state.mtimeMs !== stats.mtimeMs || state.size !== stats.size) {
+        this.fileStates.set(file.relativePath, { mtimeMs: stats.mtimeMs, size: stats.size });
+        return true;
+      }
+      return false;
+    });
+    const changed = files.filter((_, i) => statuses[i]);
+
+    let removed = 0;
+    for (const relativePath of Array.from(this.fileStates.keys())) {
+      if (!seen.has(relativePath)) {
+        this.fileStates.delete(relativePath);
+        this.index.removeFile(relativePath);
+        removed++;
+      }
+    }
+    // Also forget files deleted while the server was not running
+    for (const relativePath of this.store?.fileKeys() || []) {
+      if (!seen.has(relativePath)) {
+        this.store!.removeFile(relativePath);
+      }
+    }
+
+    let embedded = 0;
+    const memory = sharedMemoryBudget();
+    for (const file of changed) {
+      if (memory.isExceeded() || this.closing) {
+        // Left for a later refresh, once memory has been freed or the server runs again
+        this.fileStates.delete(file.relativePath);
+        continue;
+      }
+      try {
+        embedded += await this.indexFile(file);
+      } catch (err) {
+        semanticLogger.warn('Failed to index %s: %s', file.relativePath, err);
+        this.fileStates.delete(file.relativePath);
+      }
+    }
+
+    const stats: SemanticIndexStats = {
+      files: this.fileStates.size,
+      chunks: this.index.size(),
+      reindexed: changed.length,
+      embedded,
+      removed,
+      durationMs: Date.now() - startTime,
+    };
+    if (this.store) {
+      try {
+        await this.store.save();
+      } catch (err) {
+        semanticLogger.warn('Failed to save semantic index store %s: %s', this.store.filePath, err);
+      }
+    }
+
+    if (changed.length > 0 || removed > 0) {
+      semanticLogger.info('Semantic index refreshed: %d file(s) re-indexed, %d chunk(s) embedded, %d removed, %d chunks total (%dms)',
+        stats.reindexed, stats.embedded, stats.removed, stats.chunks, stats.durationMs);
+    }
+    return stats;
+  }
+
+  /**
+   * Index one file, reusing stored chunk boundaries and vectors where the
+   * content hash is unchanged; returns the number of chunks embedded
+   */
+  private async indexFile(file: WorkspaceFile): Promise<number> {
+    const content = await readFileText(file.absolutePath);
+    const fileHash = contentHash(content);
+    const stored = this.store?.getFile(file.relativePath);
+
+    const lines = content.split('\n');
+
+    // An unchanged file keeps its layout, which also skips the symbol request
+    const chunks = stored && stored.hash === fileHash
+      ? stored.chunks.map((c) => chunkAt(file.relativePath, lines, c.startLine, c.endLine, c.symbolName, c.symbolKind))
+      : await this.chunkFile(file, content);
+
+    const texts = chunks.map(chunkEmbeddingText);
+    const hashes = texts.map(contentHash);
+    const vectors = hashes.map((hash) => this.store?.getVector(hash));
+    const missing = vectors.map((v, i) => (v ? -1 : i)).filter((i) => i >= 0);
+
+    const batchSize = this.options.batchSize ?? 32;
+    for (let i = 0; i < missing.length; i += batchSize) {
+      const batch = missing.slice(i, i + batchSize);
+      const embedded = await this.provider.embed(batch.map((j) => texts[j]));
+      batch.forEach((j, k) => {
+        vectors[j] = embedded[k];
+        this.store?.setVector(hashes[j], embedded[k]);
+      });
+    }
+
+    this.index.setFile(file.relativePath, chunks.map((chunk, i) => ({ chunk, vector: vectors[i]! })));
+    this.store?.setFile(file.relativePath, {
+      hash: fileHash,
+      chunks: chunks.map((chunk, i) => ({
+        startLine: chunk.startLine,
+        endLine: chunk.endLine,
+        symbolName: chunk.symbolName,
+        symbolKind: chunk.symbolKind,
+        hash: hashes[i],
+      })),
+    });
+    return missing.length;
+  }
+
+  private async chunkFile(file: WorkspaceFile, content: string): Promise<Chunk[]> {
+    if (this.options.symbolProvider) {
+      try {
+        const symbols = await this.options.symbolProvider(file.absolutePath);
+        return chunkBySymbols(file.relativePath, content, symbols, this.options.chunk);
+      } catch (err) {
+        semanticLogger.debug('No symbols for %s, chunking by lines: %s', file.relativePath, err);
+      }
+    }
+    return chunkByLines(file.relativePath, content, this.options.chunk);
+  }
+}
@@ -0,0 +1,36 @@
+/**
+ * Tests for reciprocal-rank fusion
+ */
+
+import { reciprocalRankFusion, queryKeywords, RRF_K } from './hybrid';
+
+describe('Hybrid ranking', () => {
+  it('should reward items ranked well in both lists', () => {
+    const fused = reciprocalRankFusion(
+      [
+        { name: 'lexical', items: ['a', 'b', 'c'] },
+        { name: 'semantic', items: ['b', 'd', 'a'] },
+      ],
+      (x) => x
+    );
+    expect(fused[0].item).toBe('b');
+    expect(fused[0].ranks).toEqual({ lexical: 2, semantic: 1 });
+    expect(fused[0].score).toBeCloseTo(1 / (RRF_K + 2) + 1 / (RRF_K + 1));
+    expect(fused.map((f) => f.item).sort()).toEqual(['a', 'b', 'c', 'd']);
+  });
+
+  it('should apply list weights', () => {
+    const fused = reciprocalRankFusion(
+      [
+        { name: 'lexical', items: ['a'], weight: 2 },
+        { name: 'semantic', items: ['b'] },
+      ],
+      (x) => x
+    );
+    expect(fused.map((f) => f.item)).toEqual(['a', 'b']);
+  });
+
+  it('should extract keywords from a natural-language query', () => {
+    expect(queryKeywords('where do we validate the email in parseUser')).toEqual(['validate', 'email', 'parseUser']);
+  });
+});
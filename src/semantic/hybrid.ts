@@ -0,0 +1,184 @@
+/**
+ * Hybrid search - merge keyword and semantic rankings with reciprocal-rank fusion
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { searchLexical } from '../search/lexical.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { Chunk } from './chunker.js';
+import { isStopWord } from './embeddings.js';
+import { SemanticSearchEngine, SemanticQueryOptions } from './engine.js';
+
+const semanticLogger = createLogger(Component.SEMANTIC);
+
+/**
+ * Default RRF constant; dampens the influence of top ranks
+ */
+export const RRF_K = 60;
+
+/**
+ * Number of candidates taken from each ranking before fusion
+ */
+const CANDIDATES_PER_RANKING = 50;
+
+/**
+ * A ranked list to fuse
+ */
+export interface Ranking<T> {
+  name: string;
+  items: T[];
+  weight?: number;
+}
+
+/**
+ * Fused result with the item's rank in each input list (1-indexed)
+ */
+export interface FusedItem<T> {
+  item: T;
+  score: number;
+  ranks: Record<string, number>;
+}
+
+/**
+ * Merge rankings with reciprocal-rank fusion: score = sum(weight / (k + rank))
+ */
+export function reciprocalRankFusion<T>(
+  rankings: Ranking<T>[],
+  key: (item: T) => string,
+  k = RRF_K
+): FusedItem<T>[] {
+  const fused = new Map<string, FusedItem<T>>();
+
+  for (const ranking of rankings) {
+    const weight = ranking.weight ?? 1;
+    ranking.items.forEach((item, i) => {
+      const id = key(item);
+      let entry = fused.get(id);
+      if (!entry) {
+        entry = { item, score: 0, ranks: {} };
+        fused.set(id, entry);
+      }
+      entry.score += weight / (k + i + 1);
+      entry.ranks[ranking.name] = i + 1;
+    });
+  }
+
+  return Array.from(fused.values()).sort((a, b) => b.score - a.score);
+}
+
+/**
+ * Hybrid search hit
+ */
+export interface HybridHit {
+  chunk: Chunk;
+  score: number;
+  lexicalRank?: number;
+  semanticRank?: number;
+  semanticScore?: number;
+  exactMatch: boolean;
+}
+
+/**
+ * Keyword terms of a natural-language query
+ */
+export function queryKeywords(query: string): string[] {
+  const words = query.match(/[A-Za-z_$][\w$]*/g) || [];
+  const keywords = words.filter((w) => w.length >= 3 && !isStopWord(w.toLowerCase()));
+  return Array.from(new Set(keywords));
+}
+
+/**
+ * Check if a query term looks like a code identifier rather than a plain word
+ */
+function looksLikeIdentifier(term: string): boolean {
+  return /[a-z][A-Z]|_|^[A-Z][a-z]+[A-Z]/.test(term);
+}
+
+/**
+ * Rank chunks by keyword hits; chunks with a case-sensitive, whole-word match of an
+ * identifier-like term come first so exact identifier matches keep top rank
+ */
+async function lexicalRanking(
+  engine: SemanticSearchEngine,
+  index: TrigramIndex,
+  query: string,
+  pathPrefix: string | undefined
+): Promise<{ chunks: Chunk[]; exact: Set<string> }> {
+  const keywords = queryKeywords(query);
+  const scores = new Map<string, { chunk: Chunk; score: number; exact: boolean }>();
+  const totalFiles = Math.max(1, index.getStats().files);
+
+  for (const keyword of keywords) {
+    const result = await searchLexical(engine.workspaceDir, keyword, { path: pathPrefix, maxResults: 500 }, index);
+    const filesWithTerm = new Set(result.matches.map((m) => m.filePath)).size;
+    const idf = Math.log(1 + totalFiles / Math.max(1, filesWithTerm));
+    const identifier = looksLikeIdentifier(keyword);
+
+    for (const match of result.matches) {
+      const chunk = engine.chunksForFile(match.filePath)
+        .find((c) => c.startLine <= match.line && match.line <= c.endLine);
+      if (!chunk) {
+        continue;
+      }
+
+      const exact = identifier &&
+        match.lineText.substring(match.column - 1, match.column - 1 + match.length) === keyword &&
+        !/[\w$]/.test(match.lineText[match.column - 2] || '') &&
+        !/[\w$]/.test(match.lineText[match.column - 1 + match.length] || '');
+
+      const entry = scores.get(chunk.id) || { chunk, score: 0, exact: false };
+      entry.score += idf;
+      entry.exact = entry.exact || exact;
+      scores.set(chunk.id, entry);
+    }
+  }
+
+  const ranked = Array.from(scores.values())
+    .sort((a, b) => Number(b.exact) - Number(a.exact) || b.score - a.score)
+    .slice(0, CANDIDATES_PER_RANKING);
+
+  return {
+    chunks: ranked.map((r) => r.chunk),
+    exact: new Set(ranked.filter((r) => r.exact).map((r) => r.chunk.id)),
+  };
+}
+
+/**
+ * Search with both the trigram index and the vector index and fuse the results
+ */
+export async function hybridSearch(
+  engine: SemanticSearchEngine,
+  index: TrigramIndex,
+  query: string,
+  options: SemanticQueryOptions = {}
+): Promise<HybridHit[]> {
+  const topK = options.topK ?? 10;
+
+  // The semantic search refreshes the vector index, which the lexical ranking maps hits onto
+  const semanticHits = await engine.search(query, { ...options, topK: CANDIDATES_PER_RANKING });
+  const lexical = await lexicalRanking(engine, index, query, options.pathPrefix);
+
+  semanticLogger.debug('Hybrid search: %d lexical, %d semantic candidates', lexical.chunks.length, semanticHits.length);
+
+  const semanticScores = new Map(semanticHits.map((h) => [h.chunk.id, h.score]));
+  const fused = reciprocalRankFusion<Chunk>(
+    [
+      { name: 'lexical', items: lexical.chunks },
+      { name: 'semantic', items: semanticHits.map((h) => h.chunk) },
+    ],
+    (chunk) => chunk.id
+  );
+
+  const hits: HybridHit[] = fused.map((f) => ({
+    chunk: f.item,
+    score: f.score,
+    lexicalRank: f.ranks.lexical,
+    semanticRank: f.ranks.semantic,
+    semanticScore: semanticScores.get(f.item.id),
+    exactMatch: lexical.exact.has(f.item.id),
+  }));
+
+  // Exact identifier matches always lead; fusion orders everything else
+  hits.sort((a, b) => Number(b.exactMatch) - Number(a.exactMatch) || b.score - a.score);
+  return hits.slice(0, topK);
+}
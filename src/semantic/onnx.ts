@@ -0,0 +1,222 @@
+/**
+ * Local ONNX embedding provider for BERT-style sentence embedding models
+ * (e.g. all-MiniLM-L6-v2 exported to ONNX). Runs fully offline.
+ *
+ * onnxruntime-node is loaded lazily so it stays an optional install.
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { EmbeddingProvider, normalizeVector } from './embeddings.js';
+import { ToolError } from '../workspace/errors.js';
+
+const semanticLogger = createLogger(Component.SEMANTIC);
+
+/**
+ * Name of the optional runtime package
+ */
+const ONNX_RUNTIME_MODULE = 'onnxruntime-node';
+
+/**
+ * Maximum input length of BERT-style models
+ */
+const MAX_SEQUENCE_LENGTH = 256;
+
+/**
+ * BERT WordPiece tokenizer (lowercasing, uncased vocabularies)
+ */
+export class WordPieceTokenizer {
+  private vocab: Map<string, number>;
+  readonly clsId: number;
+  readonly sepId: number;
+  readonly unkId: number;
+
+  constructor(vocabLines: string[]) {
+    this.vocab = new Map();
+    vocabLines.forEach((token, i) => {
+      if (token.length > 0) {
+        this.vocab.set(token, i);
+      }
+    });
+    this.clsId = this.requireToken('[CLS]');
+    this.sepId = this.requireToken('[SEP]');
+    this.unkId = this.requireToken('[UNK]');
+  }
+
+  /**
+   * Load a tokenizer from a vocab.txt file
+   */
+  static fromFile(vocabPath: string): WordPieceTokenizer {
+    return new WordPieceTokenizer(fs.readFileSync(vocabPath, 'utf8').split(/\r?\n/));
+  }
+
+  /**
+   * Encode text into token ids, including [CLS] and [SEP], truncated to maxLength
+   */
+  encode(text: string, maxLength = MAX_SEQUENCE_LENGTH): number[] {
+    const ids = [this.clsId];
+    for (const word of this.basicTokenize(text)) {
+      for (const id of this.wordPiece(word)) {
+        if (ids.length >= maxLength - 1) {
+          ids.push(this.sepId);
+          return ids;
+        }
+        ids.push(id);
+      }
+    }
+    ids.push(this.sepId);
+    return ids;
+  }
+
+  private requireToken(token: string): number {
+    const id = this.vocab.get(token);
+    if (id === undefined) {
+      throw new Error(`Vocabulary is missing ${token}`);
+    }
+    return id;
+  }
+
+  /**
+   * Lowercase, strip accents, and split on whitespace and punctuation
+   */
+  private basicTokenize(text: string): string[] {
+    const cleaned = text
+      .toLowerCase()
+      .normalize('NFD')
+      .replace(/[\u0300-\u036f]/g, '');
+    return cleaned.match(/[\p{L}\p{N}]+|[^\s\p{L}\p{N}]/gu) || [];
+  }
+
+  /**
+   * Greedy longest-match-first WordPiece segmentation
+   */
+  private wordPiece(word: string): number[] {
+    if (word.length > 100) {
+      return [this.unkId];
+    }
+
+    const ids: number[] = [];
+    let start = 0;
+    while (start < word.length) {
+      let end = word.length;
+      let id: number | undefined;
+      while (start < end) {
+        const piece = (start > 0 ? '##' : '') + word.substring(start, end);
+        id = this.vocab.get(piece);
+        if (id !== undefined) {
+          break;
+        }
+        end--;
+      }
+      if (id === undefined) {
+        return [this.unkId];
+      }
+      ids.push(id);
+      start = end;
+    }
+    return ids;
+  }
+}
+
+/**
+ * Embedding provider running an ONNX model with onnxruntime-node
+ */
+export class OnnxEmbeddingProvider implements EmbeddingProvider {
+  readonly id: string;
+  private session?: any;
+  private ort?: any;
+  private tokenizer?: WordPieceTokenizer;
+  private detectedDimensions = 0;
+
+  constructor(private modelDir: string, modelName?: string) {
+    this.id = `onnx-${modelName || path.basename(modelDir)}`;
+  }
+
+  /** Vector size; known after the model has produced its first output */
+  get dimensions(): number {
+    return this.detectedDimensions;
+  }
+
+  async embed(texts: string[]): Promise<Float32Array[]> {
+    await this.load();
+    const results: Float32Array[] = [];
+    // One sequence per run keeps padding out of the mean pooling
+    for (const text of texts) {
+      results.push(await this.embedOne(text));
+    }
+    return results;
+  }
+
+  /**
+   * Load the runtime, model, and vocabulary on first use
+   */
+  private async load(): Promise<void> {
+    if (this.session) {
+      return;
+    }
+
+    try {
+      this.ort = require(ONNX_RUNTIME_MODULE);
+    } catch (err) {
+      throw new ToolError('unsupported', `onnx embedding provider requires the '${ONNX_RUNTIME_MODULE}' package: ${(err as Error).message}`);
+    }
+
+    const modelPath = path.join(this.modelDir, 'model.onnx');
+    const vocabPath = path.join(this.modelDir, 'vocab.txt');
+    for (const file of [modelPath, vocabPath]) {
+      if (!fs.existsSync(file)) {
+        throw new ToolError('not-found', `ONNX model file not found: ${file}`);
+      }
+    }
+
+    this.tokenizer = WordPieceTokenizer.fromFile(vocabPath);
+    this.session = await this.ort.InferenceSession.create(modelPath);
+    semanticLogger.info('Loaded ONNX embedding model from %s', this.modelDir);
+  }
+
+  private async embedOne(text: string): Promise<Float32Array> {
+    const ids = this.tokenizer!.encode(text);
+    const length = ids.length;
+    const toTensor = (values: number[]) =>
+      new this.ort.Tensor('int64', BigInt64Array.from(values.map((v) => BigInt(v))), [1, length]);
+
+    const feeds: Record<string, any> = {
+      input_ids: toTensor(ids),
+      attention_mask: toTensor(ids.map(() => 1)),
+    };
+    if (this.session.inputNames.includes('token_type_ids')) {
+      feeds.token_type_ids = toTensor(ids.map(() => 0));
+    }
+
+    const output = await this.session.run(feeds);
+
+    // Prefer a pooled sentence embedding when the model exports one
+    const pooled = output.sentence_embedding;
+    if (pooled) {
+      return this.finish(Float32Array.from(pooled.data as Float32Array));
+    }
+
+    // Otherwise mean-pool the token embeddings
+    const hidden = output.last_hidden_state || output[this.session.outputNames[0]];
+    const dims = hidden.dims[2];
+    const data = hidden.data as Float32Array;
+    const vector = new Float32Array(dims);
+    for (let t = 0; t < length; t++) {
+      for (let d = 0; d < dims; d++) {
+        vector[d] += data[t * dims + d];
+      }
+    }
+    for (let d = 0; d < dims; d++) {
+      vector[d] /= length;
+    }
+    return this.finish(vector);
+  }
+
+  private finish(vector: Float32Array): Float32Array {
+    if (this.detectedDimensions === 0) {
+      this.detectedDimensions = vector.length;
+    }
+    return normalizeVector(vector);
+  }
+}
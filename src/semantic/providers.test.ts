@@ -0,0 +1,73 @@
+/**
+ * Tests for embedding providers
+ */
+
+import { createEmbeddingProvider, embeddingConfigFromEnv, OpenAIEmbeddingProvider, OllamaEmbeddingProvider } from './providers';
+import { WordPieceTokenizer } from './onnx';
+import { HashingEmbeddingProvider } from './embeddings';
+
+describe('Embedding providers', () => {
+  const originalFetch = global.fetch;
+
+  afterEach(() => {
+    global.fetch = originalFetch;
+  });
+
+  function mockFetch(body: unknown): jest.Mock {
+    const mock = jest.fn(async () => ({
+      ok: true,
+      status: 200,
+      json: async () => body,
+      text: async () => JSON.stringify(body),
+    }));
+    global.fetch = mock as unknown as typeof fetch;
+    return mock;
+  }
+
+  it('should select providers from environment variables', () => {
+    expect(embeddingConfigFromEnv({}).provider).toBe('hashing');
+    const config = embeddingConfigFromEnv({
+      SEMANTIC_EMBEDDING_PROVIDER: 'openai',
+      OPENAI_API_KEY: 'sk-test',
+      SEMANTIC_EMBEDDING_DIMENSIONS: '3',
+    });
+    expect(config.apiKey).toBe('sk-test');
+    expect(config.dimensions).toBe(3);
+    expect(createEmbeddingProvider(config)).toBeInstanceOf(OpenAIEmbeddingProvider);
+    expect(createEmbeddingProvider({ provider: 'hashing' })).toBeInstanceOf(HashingEmbeddingProvider);
+    expect(() => createEmbeddingProvider({ provider: 'onnx' })).toThrow('model path');
+  });
+
+  it('should call an OpenAI-compatible endpoint and order results by index', async () => {
+    const fetchMock = mockFetch({
+      data: [
+        { index: 1, embedding: [0, 2] },
+        { index: 0, embedding: [3, 4] },
+      ],
+    });
+    const provider = new OpenAIEmbeddingProvider('m', 'http://gateway/v1/', 'key');
+    const vectors = await provider.embed(['a', 'b']);
+
+    const [url, init] = fetchMock.mock.calls[0] as unknown as [string, RequestInit];
+    expect(url).toBe('http://gateway/v1/embeddings');
+    expect((init.headers as Record<string, string>).Authorization).toBe('Bearer key');
+    expect(JSON.parse(init.body as string)).toEqual({ model: 'm', input: ['a', 'b'] });
+    expect(vectors[0][0]).toBeCloseTo(0.6);
+    expect(vectors[0][1]).toBeCloseTo(0.8);
+    expect(Array.from(vectors[1])).toEqual([0, 1]);
+    expect(provider.dimensions).toBe(2);
+  });
+
+  it('should reject vectors of the wrong size', async () => {
+    mockFetch({ embeddings: [[1, 0, 0]] });
+    const provider = new OllamaEmbeddingProvider('nomic-embed-text', 'http://localhost:11434', 2);
+    await expect(provider.embed(['a'])).rejects.toThrow('expected 2 dimensions');
+  });
+
+  it('should tokenize with WordPiece', () => {
+    const tokenizer = new WordPieceTokenizer(['[PAD]', '[UNK]', '[CLS]', '[SEP]', 'valid', '##ate', 'email', '.']);
+    expect(tokenizer.encode('Validate email.')).toEqual([2, 4, 5, 6, 7, 3]);
+    expect(tokenizer.encode('unknown')).toEqual([2, 1, 3]);
+    expect(tokenizer.encode('email email email', 4)).toEqual([2, 6, 6, 3]);
+  });
+});
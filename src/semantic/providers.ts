@@ -0,0 +1,196 @@
+/**
+ * Embedding provider implementations backed by HTTP services, and provider selection
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { EmbeddingProvider, HashingEmbeddingProvider, normalizeVector } from './embeddings.js';
+import { OnnxEmbeddingProvider } from './onnx.js';
+import { ToolError } from '../workspace/errors.js';
+
+const semanticLogger = createLogger(Component.SEMANTIC);
+
+/**
+ * Supported embedding backends
+ */
+export type EmbeddingProviderKind = 'hashing' | 'onnx' | 'ollama' | 'openai';
+
+/**
+ * Embedding provider configuration
+ */
+export interface EmbeddingProviderConfig {
+  provider: EmbeddingProviderKind;
+  model?: string;
+  // Base URL of the HTTP endpoint (ollama, openai)
+  url?: string;
+  apiKey?: string;
+  // Model directory containing model.onnx and vocab.txt (onnx)
+  modelPath?: string;
+  // Expected vector size; detected from the first response when omitted
+  dimensions?: number;
+  timeoutMs?: number;
+}
+
+/**
+ * POST a JSON body and parse the JSON response
+ */
+async function postJson<T>(url: string, body: unknown, headers: Record<string, string>, timeoutMs: number): Promise<T> {
+  const controller = new AbortController();
+  const timer = setTimeout(() => controller.abort(), timeoutMs);
+  try {
+    const response = await fetch(url, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json', ...headers },
+      body: JSON.stringify(body),
+      signal: controller.signal,
+    });
+    if (!response.ok) {
+      const text = await response.text().catch(() => '');
+      throw new Error(`${url} returned HTTP ${response.status}: ${text.slice(0, 200)}`);
+    }
+    return (await response.json()) as T;
+  } catch (err) {
+    if ((err as Error).name === 'AbortError') {
+      throw new ToolError('timeout', `${url} timed out after ${timeoutMs}ms`);
+    }
+    throw err;
+  } finally {
+    clearTimeout(timer);
+  }
+}
+
+/**
+ * Base class for HTTP providers: tracks the vector size and normalizes results
+ */
+abstract class HttpEmbeddingProvider implements EmbeddingProvider {
+  abstract readonly id: string;
+  private detectedDimensions: number;
+
+  constructor(dimensions: number | undefined, protected timeoutMs: number) {
+    this.detectedDimensions = dimensions ?? 0;
+  }
+
+  /** Vector size; 0 until the first response when not configured */
+  get dimensions(): number {
+    return this.detectedDimensions;
+  }
+
+  async embed(texts: string[]): Promise<Float32Array[]> {
+    if (texts.length === 0) {
+      return [];
+    }
+
+    const raw = await this.request(texts);
+    if (raw.length !== texts.length) {
+      throw new Error(`${this.id}: expected ${texts.length} embeddings, got ${raw.length}`);
+    }
+
+    return raw.map((values) => {
+      if (this.detectedDimensions === 0) {
+        this.detectedDimensions = values.length;
+        semanticLogger.info('%s: detected embedding size %d', this.id, values.length);
+      } else if (values.length !== this.detectedDimensions) {
+        throw new Error(`${this.id}: expected ${this.detectedDimensions} dimensions, got ${values.length}`);
+      }
+      return normalizeVector(Float32Array.from(values));
+    });
+  }
+
+  protected abstract request(texts: string[]): Promise<number[][]>;
+}
+
+/**
+ * Ollama embeddings (POST /api/embed)
+ */
+export class OllamaEmbeddingProvider extends HttpEmbeddingProvider {
+  readonly id: string;
+
+  constructor(
+    private model = 'nomic-embed-text',
+    private baseUrl = 'http://localhost:11434',
+    dimensions?: number,
+    timeoutMs = 60000
+  ) {
+    super(dimensions, timeoutMs);
+    this.id = `ollama-${model}`;
+  }
+
+  protected async request(texts: string[]): Promise<number[][]> {
+    const result = await postJson<{ embeddings: number[][] }>(
+      `${this.baseUrl.replace(/\/$/, '')}/api/embed`,
+      { model: this.model, input: texts },
+      {},
+      this.timeoutMs
+    );
+    return result.embeddings || [];
+  }
+}
+
+/**
+ * OpenAI-compatible embeddings (POST /embeddings), also served by many hosted
+ * and self-hosted gateways
+ */
+export class OpenAIEmbeddingProvider extends HttpEmbeddingProvider {
+  readonly id: string;
+
+  constructor(
+    private model = 'text-embedding-3-small',
+    private baseUrl = 'https://api.openai.com/v1',
+    private apiKey?: string,
+    dimensions?: number,
+    timeoutMs = 60000
+  ) {
+    super(dimensions, timeoutMs);
+    this.id = `openai-${model}`;
+  }
+
+  protected async request(texts: string[]): Promise<number[][]> {
+    const headers: Record<string, string> = {};
+    if (this.apiKey) {
+      headers.Authorization = `Bearer ${this.apiKey}`;
+    }
+    const result = await postJson<{ data: Array<{ embedding: number[]; index: number }> }>(
+      `${this.baseUrl.replace(/\/$/, '')}/embeddings`,
+      { model: this.model, input: texts },
+      headers,
+      this.timeoutMs
+    );
+    return [...(result.data || [])].sort((a, b) => a.index - b.index).map((d) => d.embedding);
+  }
+}
+
+/**
+ * Create the configured embedding provider
+ */
+export function createEmbeddingProvider(config: EmbeddingProviderConfig): EmbeddingProvider {
+  switch (config.provider) {
+    case 'hashing':
+      return new HashingEmbeddingProvider(config.dimensions);
+    case 'onnx':
+      if (!config.modelPath) {
+        throw new ToolError('invalid-argument', 'onnx embedding provider requires a model path');
+      }
+      return new OnnxEmbeddingProvider(config.modelPath, config.model);
+    case 'ollama':
+      return new OllamaEmbeddingProvider(config.model, config.url, config.dimensions, config.timeoutMs);
+    case 'openai':
+      return new OpenAIEmbeddingProvider(config.model, config.url, config.apiKey, config.dimensions, config.timeoutMs);
+    default:
+      throw new ToolError('invalid-argument', `Unknown embedding provider: ${config.provider}`);
+  }
+}
+
+/**
+ * Read embedding provider configuration from environment variables
+ */
+export function embeddingConfigFromEnv(env: NodeJS.ProcessEnv = process.env): EmbeddingProviderConfig {
+  const provider = (env.SEMANTIC_EMBEDDING_PROVIDER || 'hashing') as EmbeddingProviderKind;
+  return {
+    provider,
+    model: env.SEMANTIC_EMBEDDING_MODEL || undefined,
+    url: env.SEMANTIC_EMBEDDING_URL || undefined,
+    apiKey: env.SEMANTIC_EMBEDDING_API_KEY || (provider === 'openai' ? env.OPENAI_API_KEY : undefined),
+    modelPath: env.SEMANTIC_ONNX_MODEL_PATH || undefined,
+    dimensions: env.SEMANTIC_EMBEDDING_DIMENSIONS ? parseInt(env.SEMANTIC_EMBEDDING_DIMENSIONS, 10) : undefined,
+    timeoutMs: env.SEMANTIC_EMBEDDING_TIMEOUT_MS ? parseInt(env.SEMANTIC_EMBEDDING_TIMEOUT_MS, 10) : undefined,
+  };
+}
@@ -0,0 +1,149 @@
+/**
+ * Tests for the persistent semantic index store
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { HashingEmbeddingProvider } from './embeddings';
+import { SemanticSearchEngine } from './engine';
+import { IndexStore } from './store';
+
+/**
+ * Hashing provider that counts embedded texts
+ */
+class CountingProvider extends HashingEmbeddingProvider {
+  embedded = 0;
+
+  async embed(texts: string[]): Promise<Float32Array[]> {
+    this.embedded += texts.length;
+    return super.embed(texts);
+  }
+}
+
+describe('Semantic index store', () => {
+  let workspace: string;
+  let storePath: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'store-'));
+    storePath = path.join(workspace, '.cache', 'index.bin');
+    fs.writeFileSync(path.join(workspace, 'a.go'), 'func ValidateEmail(addr string) bool {\n  return true\n}\n');
+    fs.writeFileSync(path.join(workspace, 'b.go'), 'func OpenDatabase(dsn string) {\n  connect(dsn)\n}\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should round-trip files and vectors', async () => {
+    const store = new IndexStore(storePath, 'test');
+    store.setVector('h1', Float32Array.from([0.5, -0.25]));
+    store.setFile('a.go', { hash: 'f1', chunks: [{ startLine: 1, endLine: 3, hash: 'h1' }] });
+    store.setVector('orphan', Float32Array.from([1, 0]));
+    await store.save();
+
+    const loaded = new IndexStore(storePath, 'test');
+    expect(await loaded.load()).toBe(true);
+    expect(loaded.getFile('a.go')?.chunks[0].hash).toBe('h1');
+    expect(Array.from(loaded.getVector('h1')!)).toEqual([0.5, -0.25]);
+    expect(loaded.getVector('orphan')).toBeUndefined();
+  });
+
+  it('should compress shards and keep untouched shards intact across saves', async () => {
+    const write = async (codec: 'brotli' | 'none'): Promise<number> => {
+      const store = new IndexStore(storePath, 'test', codec);
+      const chunks = Array.from({ length: 64 }, (_, i) => {
+        store.setVector(`h${i}`, Float32Array.from({ length: 32 }, (_, d) => (d % 4) / 4));
+        return { startLine: i + 1, endLine: i + 1, hash: `h${i}` };
+      });
+      store.setFile('a.go', { hash: 'f1', chunks });
+      await store.save();
+      return fs.statSync(storePath).size;
+    };
+    const uncompressed = await write('none');
+    expect(await write('brotli')).toBeLessThan(uncompressed / 2);
+
+    // Adding a file decompresses only the shard its vector falls in
+    const store = new IndexStore(storePath, 'test', 'brotli');
+    expect(await store.load()).toBe(true);
+    store.setVector('extra', Float32Array.from({ length: 32 }, () => 1));
+    store.setFile('b.go', { hash: 'f2', chunks: [{ startLine: 1, endLine: 1, hash: 'extra' }] });
+    await store.save();
+
+    // A different codec rewrites every shard
+    const reloaded = new IndexStore(storePath, 'test', 'none');
+    expect(await reloaded.load()).toBe(true);
+    expect(reloaded.getStats()).toEqual({ files: 2, vectors: 65 });
+    expect(reloaded.getVector('h7')![1]).toBe(0.25);
+    reloaded.removeFile('b.go');
+    await reloaded.save();
+    const pruned = new IndexStore(storePath, 'test', 'none');
+    expect(await pruned.load()).toBe(true);
+    expect(pruned.getStats()).toEqual({ files: 1, vectors: 64 });
+    expect(pruned.getVector('h63')![3]).toBe(0.75);
+  });
+
+  it('should decode vectors one at a time and keep the rest of their shard', async () => {
+    const store = new IndexStore(storePath, 'test', 'brotli');
+    const chunks = Array.from({ length: 64 }, (_, i) => {
+      store.setVector(`h${i}`, Float32Array.from([i, -i]));
+      return { startLine: i + 1, endLine: i + 1, hash: `h${i}` };
+    });
+    store.setFile('a.go', { hash: 'f1', chunks });
+    await store.save();
+
+    const loaded = new IndexStore(storePath, 'test', 'brotli');
+    expect(await loaded.load()).toBe(true);
+    expect(Array.from(loaded.getVector('h5')!)).toEqual([5, -5]);
+    expect(loaded.getStats()).toEqual({ files: 1, vectors: 64 });
+    // Changing the store afterwards still keeps every vector, read or not
+    loaded.setVector('extra', Float32Array.from([1, 1]));
+    loaded.setFile('b.go', { hash: 'f2', chunks: [{ startLine: 1, endLine: 1, hash: 'extra' }] });
+    await loaded.save();
+
+    const reloaded = new IndexStore(storePath, 'test', 'brotli');
+    expect(await reloaded.load()).toBe(true);
+    expect(reloaded.getStats()).toEqual({ files: 2, vectors: 65 });
+    for (let i = 0; i < 64; i++) {
+      expect(Array.from(reloaded.getVector(`h${i}`)!)).toEqual([i, -i]);
+    }
+
+    // A vector dropped after it was read is not copied along with its shard
+    reloaded.setFile('a.go', { hash: 'f3', chunks: chunks.slice(1) });
+    await reloaded.save();
+    const pruned = new IndexStore(storePath, 'test', 'brotli');
+    expect(await pruned.load()).toBe(true);
+    expect(pruned.getStats()).toEqual({ files: 2, vectors: 64 });
+    expect(pruned.getVector('h0')).toBeUndefined();
+  });
+
+  it('should discard a store written with different settings', async () => {
+    const store = new IndexStore(storePath, 'model-a');
+    store.setVector('h1', Float32Array.from([1]));
+    store.setFile('a.go', { hash: 'f1', chunks: [{ startLine: 1, endLine: 1, hash: 'h1' }] });
+    await store.save();
+
+    const other = new IndexStore(storePath, 'model-b');
+    expect(await other.load()).toBe(false);
+    expect(other.getStats()).toEqual({ files: 0, vectors: 0 });
+  });
+
+  it('should only re-embed changed chunks across restarts', async () => {
+    const first = new CountingProvider(64);
+    const stats = await new SemanticSearchEngine(workspace, first, { storePath }).refresh();
+    expect(stats.embedded).toBe(2);
+
+    // A new engine with an unchanged workspace embeds nothing
+    const second = new CountingProvider(64);
+    const restarted = new SemanticSearchEngine(workspace, second, { storePath });
+    expect((await restarted.refresh()).embedded).toBe(0);
+    expect((await restarted.search('validate email'))[0].chunk.filePath).toBe('a.go');
+
+    fs.writeFileSync(path.join(workspace, 'b.go'), 'func OpenDatabase(dsn string) {\n  connectWithRetry(dsn)\n}\n');
+    const third = new CountingProvider(64);
+    const changed = await new SemanticSearchEngine(workspace, third, { storePath }).refresh();
+    expect(changed.embedded).toBe(1);
+    expect(third.embedded).toBe(1);
+  });
+});
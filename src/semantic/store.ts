@@ -0,0 +1,439 @@
+/**
+ * Persistent semantic index store
+ * Saves chunk layouts per file and embeddings keyed by content hash, so a
+ * restart only re-embeds chunks whose text actually changed. Embeddings are
+ * split into compressed shards that are only decompressed when first read,
+ * and each vector is decoded from its shard when it is asked for
+ */
+
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { createLogger, Component } from '../logging/logger.js';
+import { writeFileAtomic } from '../workspace/atomic.js';
+import { ToolError } from '../workspace/errors.js';
+
+const semanticLogger = createLogger(Component.SEMANTIC);
+
+/**
+ * File magic and format version; bump the version when the layout changes
+ */
+const STORE_MAGIC = 'GFCI';
+export const STORE_VERSION = 2;
+
+/**
+ * Number of vector shards; each is compressed and decompressed independently
+ */
+const SHARD_COUNT = 16;
+
+/**
+ * Compression applied to the file table and vector shards
+ * zstd needs Node 22.15 or later; brotli is the fallback
+ */
+export type StoreCodec = 'zstd' | 'brotli' | 'none';
+
+/**
+ * zstd bindings, present only on newer Node versions
+ */
+const zstd = zlib as unknown as {
+  zstdCompressSync?: (data: Buffer) => Buffer;
+  zstdDecompressSync?: (data: Buffer) => Buffer;
+};
+
+/**
+ * Best codec supported by the running Node version
+ */
+export function defaultCodec(): StoreCodec {
+  return zstd.zstdCompressSync ? 'zstd' : 'brotli';
+}
+
+/**
+ * Compress data with a codec
+ */
+export function compress(codec: StoreCodec, data: Buffer): Buffer {
+  switch (codec) {
+    case 'zstd':
+      if (!zstd.zstdCompressSync) {
+        throw new ToolError('unsupported', 'zstd is not supported by this Node version');
+      }
+      return zstd.zstdCompressSync(data);
+    case 'brotli':
+      // A middle quality level: most of the size reduction for a fraction of the CPU
+      return zlib.brotliCompressSync(data, { params: { [zlib.constants.BROTLI_PARAM_QUALITY]: 5 } });
+    case 'none':
+      return data;
+  }
+}
+
+/**
+ * Decompress data written with a codec
+ */
+export function decompress(codec: StoreCodec, data: Buffer): Buffer {
+  switch (codec) {
+    case 'zstd':
+      if (!zstd.zstdDecompressSync) {
+        throw new ToolError('unsupported', 'zstd is not supported by this Node version');
+      }
+      return zstd.zstdDecompressSync(data);
+    case 'brotli':
+      return zlib.brotliDecompressSync(data);
+    case 'none':
+      return data;
+    default:
+      throw new Error(`unknown codec ${codec}`);
+  }
+}
+
+/**
+ * Shard a vector hash belongs to (FNV-1a)
+ */
+function shardOf(hash: string): number {
+  let h = 0x811c9dc5;
+  for (let i = 0; i < hash.length; i++) {
+    h ^= hash.charCodeAt(i);
+    h = Math.imul(h, 0x01000193);
+  }
+  return (h >>> 0) % SHARD_COUNT;
+}
+
+/**
+ * Chunk boundaries of a stored file; the text is recovered from the file itself
+ */
+export interface StoredChunk {
+  startLine: number;
+  endLine: number;
+  symbolName?: string;
+  symbolKind?: string;
+  hash: string; // Content hash of the embedded text
+}
+
+/**
+ * Stored state of one workspace file
+ */
+export interface StoredFile {
+  hash: string; // Content hash of the whole file
+  chunks: StoredChunk[];
+}
+
+/**
+ * Header of the on-disk format. The compressed table follows it, then each
+ * shard's compressed little-endian float32 vectors in the order of the
+ * shard's hashes in the table
+ */
+interface StoreHeader {
+  version: number;
+  fingerprint: string;
+  dimensions: number;
+  codec: StoreCodec;
+  tableLength: number;
+  shardLengths: number[];
+}
+
+/**
+ * File table, compressed as one block and read eagerly on load
+ */
+interface StoreTable {
+  files: Record<string, StoredFile>;
+  shardHashes: string[][];
+}
+
+/**
+ * A shard read from disk whose vectors are not all decoded yet
+ */
+interface PendingShard {
+  hashes: string[];
+  data: Buffer;
+  // The decompressed vectors, once one of them was read
+  raw?: Buffer;
+}
+
+/**
+ * Hash text content for change detection
+ */
+export function contentHash(text: string): string {
+  return crypto.createHash('sha256').update(text).digest('hex').substring(0, 32);
+}
+
+/**
+ * Default location of the store for a workspace, under the user cache directory
+ */
+export function defaultStorePath(workspaceDir: string, baseDir?: string): string {
+  const cacheRoot = baseDir || path.join(process.env.XDG_CACHE_HOME || path.join(os.homedir(), '.cache'), 'grep-for-code');
+  const workspaceKey = contentHash(path.resolve(workspaceDir)).substring(0, 16);
+  return path.join(cacheRoot, `${path.basename(workspaceDir)}-${workspaceKey}`, 'semantic-index.bin');
+}
+
+/**
+ * Chunk layouts and embeddings persisted in a single binary file
+ */
+export class IndexStore {
+  private files = new Map<string, StoredFile>();
+  // Vectors decoded from shards and vectors added since loading
+  private vectors = new Map<string, Float32Array>();
+  private pending = new Map<number, PendingShard>();
+  // Shard and position within it of each vector not decoded yet
+  private pendingHashes = new Map<string, { shard: number; position: number }>();
+  private loadedCodec?: StoreCodec;
+  private dimensions = 0;
+  private dirty = false;
+
+  /**
+   * The fingerprint identifies the embedding model and chunking settings;
+   * a store written with a different fingerprint is discarded on load
+   */
+  constructor(readonly filePath: string, readonly fingerprint: string, readonly codec: StoreCodec = defaultCodec()) {}
+
+  /**
+   * Load the store from disk
+   * Returns false when there is no compatible store, leaving it empty
+   */
+  async load(): Promise<boolean> {
+    let data: Buffer;
+    try {
+      data = await fs.promises.readFile(this.filePath);
+    } catch {
+      return false;
+    }
+
+    try {
+      const header = this.decode(data);
+      if (header.fingerprint !== this.fingerprint) {
+        semanticLogger.info('Semantic index store was built with different settings, rebuilding');
+        this.dirty = true;
+        return false;
+      }
+      semanticLogger.info('Loaded semantic index store: %d file(s), %d vector(s)', this.files.size, this.vectorCount());
+      return true;
+    } catch (err) {
+      semanticLogger.warn('Ignoring unreadable semantic index store %s: %s', this.filePath, err);
+      this.files.clear();
+      this.vectors.clear();
+      this.pending.clear();
+      this.pendingHashes.clear();
+      this.dimensions = 0;
+      this.dirty = true;
+      return false;
+    }
+  }
+
+  /**
+   * Write the store to disk if it changed, dropping vectors no file refers to
+   * Shards that were never decompressed and did not change are copied as they are
+   */
+  async save(): Promise<void> {
+    if (!this.dirty) {
+      return;
+    }
+    this.prune();
+    if (this.loadedCodec !== this.codec) {
+      for (const shard of Array.from(this.pending.keys())) {
+        this.decodeShard(shard);
+      }
+    }
+
+    const shardHashes: string[][] = Array.from({ length: SHARD_COUNT }, () => []);
+    for (const hash of this.vectors.keys()) {
+      shardHashes[shardOf(hash)].push(hash);
+    }
+    const shards: Buffer[] = [];
+    for (let shard = 0; shard < SHARD_COUNT; shard++) {
+      const pending = this.pending.get(shard);
+      if (pending) {
+        shardHashes[shard] = pending.hashes;
+        shards.push(pending.data);
+        continue;
+      }
+      const hashes = shardHashes[shard];
+      const vectorBytes = Buffer.alloc(hashes.length * this.dimensions * 4);
+      hashes.forEach((hash, i) => {
+        const vector = this.vectors.get(hash)!;
+        for (let d = 0; d < this.dimensions; d++) {
+          vectorBytes.writeFloatLE(vector[d], (i * this.dimensions + d) * 4);
+        }
+      });
+      shards.push(compress(this.codec, vectorBytes));
+    }
+
+    const table: StoreTable = { files: Object.fromEntries(this.files), shardHashes };
+    const tableBytes = compress(this.codec, Buffer.from(JSON.stringify(table), 'utf8'));
+    const header: StoreHeader = {
+      version: STORE_VERSION,
+      fingerprint: this.fingerprint,
+      dimensions: this.dimensions,
+      codec: this.codec,
+      tableLength: tableBytes.length,
+      shardLengths: shards.map((shard) => shard.length),
+    };
+    const headerBytes = Buffer.from(JSON.stringify(header), 'utf8');
+
+    const prefix = Buffer.alloc(8);
+    prefix.write(STORE_MAGIC, 0, 'ascii');
+    prefix.writeUInt32LE(headerBytes.length, 4);
+
+    await writeFileAtomic(this.filePath, Buffer.concat([prefix, headerBytes, tableBytes, ...shards]));
+    this.dirty = false;
+    semanticLogger.debug('Saved semantic index store: %d file(s), %d vector(s)', this.files.size, this.vectorCount());
+  }
+
+  getFile(relativePath: string): StoredFile | undefined {
+    return this.files.get(relativePath);
+  }
+
+  setFile(relativePath: string, record: StoredFile): void {
+    this.files.set(relativePath, record);
+    this.dirty = true;
+  }
+
+  removeFile(relativePath: string): void {
+    if (this.files.delete(relativePath)) {
+      this.dirty = true;
+    }
+  }
+
+  fileKeys(): string[] {
+    return Array.from(this.files.keys());
+  }
+
+  getVector(hash: string): Float32Array | undefined {
+    const location = this.pendingHashes.get(hash);
+    if (location !== undefined) {
+      this.vectors.set(hash, this.readVector(this.shardBytes(location.shard), location.position));
+      this.pendingHashes.delete(hash);
+    }
+    return this.vectors.get(hash);
+  }
+
+  setVector(hash: string, vector: Float32Array): void {
+    // A shard is rewritten from memory as a whole once it changes
+    const shard = shardOf(hash);
+    if (this.pending.has(shard)) {
+      this.decodeShard(shard);
+    }
+    if (this.dimensions === 0) {
+      this.dimensions = vector.length;
+    } else if (vector.length !== this.dimensions) {
+      throw new Error(`Vector dimension mismatch: expected ${this.dimensions}, got ${vector.length}`);
+    }
+    this.vectors.set(hash, vector);
+    this.dirty = true;
+  }
+
+  /**
+   * Number of stored files and vectors
+   */
+  getStats(): { files: number; vectors: number } {
+    return { files: this.files.size, vectors: this.vectorCount() };
+  }
+
+  private vectorCount(): number {
+    return this.vectors.size + this.pendingHashes.size;
+  }
+
+  /**
+   * Drop vectors that no stored file refers to
+   * Only shards that hold such vectors are decompressed
+   */
+  private prune(): void {
+    const live = new Set<string>();
+    for (const record of this.files.values()) {
+      record.chunks.forEach((chunk) => live.add(chunk.hash));
+    }
+    for (const [hash, { shard }] of Array.from(this.pendingHashes)) {
+      if (!live.has(hash)) {
+        this.decodeShard(shard);
+      }
+    }
+    for (const hash of Array.from(this.vectors.keys())) {
+      if (!live.has(hash)) {
+        // A vector decoded from a shard read from disk is still part of it
+        this.decodeShard(shardOf(hash));
+        this.vectors.delete(hash);
+      }
+    }
+  }
+
+  /**
+   * Decompressed vectors of a pending shard, decompressing it on first use
+   */
+  private shardBytes(shard: number): Buffer {
+    const pending = this.pending.get(shard)!;
+    if (!pending.raw) {
+      const raw = decompress(this.loadedCodec!, pending.data);
+      if (raw.length !== pending.hashes.length * this.dimensions * 4) {
+        throw new Error(`corrupt shard ${shard} in ${this.filePath}`);
+      }
+      pending.raw = raw;
+    }
+    return pending.raw;
+  }
+
+  /**
+   * Decode the vector at a position of a decompressed shard
+   */
+  private readVector(raw: Buffer, position: number): Float32Array {
+    const vector = new Float32Array(this.dimensions);
+    for (let d = 0; d < this.dimensions; d++) {
+      vector[d] = raw.readFloatLE((position * this.dimensions + d) * 4);
+    }
+    return vector;
+  }
+
+  /**
+   * Decode every vector of a pending shard that is not decoded yet, e.g.
+   * because the shard changes and is rewritten from memory
+   */
+  private decodeShard(shard: number): void {
+    const pending = this.pending.get(shard);
+    if (!pending) {
+      return;
+    }
+    const raw = this.shardBytes(shard);
+    this.pending.delete(shard);
+    pending.hashes.forEach((hash, position) => {
+      if (this.pendingHashes.delete(hash)) {
+        this.vectors.set(hash, this.readVector(raw, position));
+      }
+    });
+  }
+
+  private decode(data: Buffer): StoreHeader {
+    if (data.length < 8 || data.toString('ascii', 0, 4) !== STORE_MAGIC) {
+      throw new Error('not a semantic index store');
+    }
+    const headerLength = data.readUInt32LE(4);
+    const header = JSON.parse(data.toString('utf8', 8, 8 + headerLength)) as StoreHeader;
+    if (header.version !== STORE_VERSION) {
+      throw new Error(`unsupported store version ${header.version}`);
+    }
+    if (header.fingerprint !== this.fingerprint) {
+      return header;
+    }
+
+    let offset = 8 + headerLength;
+    const expected = offset + header.tableLength + header.shardLengths.reduce((sum, n) => sum + n, 0);
+    if (data.length !== expected || header.shardLengths.length !== SHARD_COUNT) {
+      throw new Error(`truncated store (${data.length} of ${expected} bytes)`);
+    }
+
+    const table = JSON.parse(
+      decompress(header.codec, data.subarray(offset, offset + header.tableLength)).toString('utf8')
+    ) as StoreTable;
+    offset += header.tableLength;
+
+    this.dimensions = header.dimensions;
+    this.loadedCodec = header.codec;
+    header.shardLengths.forEach((length, shard) => {
+      const hashes = table.shardHashes[shard];
+      if (hashes.length > 0) {
+        // Copied out so the file buffer can be released
+        this.pending.set(shard, { hashes, data: Buffer.from(data.subarray(offset, offset + length)) });
+        hashes.forEach((hash, position) => this.pendingHashes.set(hash, { shard, position }));
+      }
+      offset += length;
+    });
+    this.files = new Map(Object.entries(table.files));
+    return header;
+  }
+}
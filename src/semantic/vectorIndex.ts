@@ -0,0 +1,135 @@
+/**
+ * In-memory vector index over code chunks
+ */
+
+import { Chunk } from './chunker.js';
+
+/**
+ * Chunk with its embedding
+ */
+export interface IndexedChunk {
+  chunk: Chunk;
+  vector: Float32Array;
+}
+
+/**
+ * Search hit with cosine similarity score
+ */
+export interface VectorHit {
+  chunk: Chunk;
+  score: number;
+}
+
+/**
+ * Cosine similarity of two vectors
+ */
+export function cosineSimilarity(a: Float32Array, b: Float32Array): number {
+  let dot = 0;
+  let normA = 0;
+  let normB = 0;
+  for (let i = 0; i < a.length; i++) {
+    dot += a[i] * b[i];
+    normA += a[i] * a[i];
+    normB += b[i] * b[i];
+  }
+  if (normA === 0 || normB === 0) {
+    return 0;
+  }
+  return dot / (Math.sqrt(normA) * Math.sqrt(normB));
+}
+
+/**
+ * Vector index grouped by file so that a changed file can be replaced as a whole
+ */
+export class VectorIndex {
+  private byFile = new Map<string, IndexedChunk[]>();
+
+  /**
+   * A dimension of 0 adopts the size of the first vector added, for providers
+   * that only learn their vector size from the model
+   */
+  constructor(private dimensions: number) {}
+
+  /**
+   * Vector size of the index
+   */
+  getDimensions(): number {
+    return this.dimensions;
+  }
+
+  /**
+   * Replace all chunks of a file
+   */
+  setFile(filePath: string, entries: IndexedChunk[]): void {
+    for (const entry of entries) {
+      if (this.dimensions === 0) {
+        this.dimensions = entry.vector.length;
+      }
+      if (entry.vector.length !== this.dimensions) {
+        throw new Error(`Vector dimension mismatch: expected ${this.dimensions}, got ${entry.vector.length}`);
+      }
+    }
+    this.byFile.set(filePath, entries);
+  }
+
+  /**
+   * Remove all chunks of a file
+   */
+  removeFile(filePath: string): void {
+    this.byFile.delete(filePath);
+  }
+
+  /**
+   * Chunks of a file, in file order
+   */
+  getFile(filePath: string): IndexedChunk[] {
+    return this.byFile.get(filePath) || [];
+  }
+
+  /**
+   * Indexed file paths
+   */
+  files(): string[] {
+    return Array.from(this.byFile.keys());
+  }
+
+  /**
+   * Number of indexed chunks
+   */
+  size(): number {
+    let total = 0;
+    for (const entries of this.byFile.values()) {
+      total += entries.length;
+    }
+    return total;
+  }
+
+  /**
+   * Find the chunks most similar to a query vector
+   */
+  search(query: Float32Array, topK: number, filter?: (chunk: Chunk) => boolean): VectorHit[] {
+    const hits: VectorHit[] = [];
+
+    for (const entries of this.byFile.values()) {
+      for (const entry of entries) {
+        if (filter && !filter(entry.chunk)) {
+          continue;
+        }
+        const score = cosineSimilarity(query, entry.vector);
+        if (score > 0) {
+          hits.push({ chunk: entry.chunk, score });
+        }
+      }
+    }
+
+    hits.sort((a, b) => b.score - a.score);
+    return hits.slice(0, topK);
+  }
+
+  /**
+   * Remove all entries
+   */
+  clear(): void {
+    this.byFile.clear();
+  }
+}
@@ -0,0 +1,134 @@
+/**
+ * Tests for LSIF and SCIP dumps
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { loadCodeIndex, parseLsif, parseScip, scipDisplayName } from './codeindex';
+
+function varint(value: number): Buffer {
+  const bytes: number[] = [];
+  while (value > 127) {
+    bytes.push((value & 127) | 128);
+    value = Math.floor(value / 128);
+  }
+  bytes.push(value);
+  return Buffer.from(bytes);
+}
+
+/**
+ * Encode a protobuf field: numbers as varints, strings and buffers length-delimited
+ */
+function field(number: number, value: number | string | Buffer): Buffer {
+  if (typeof value === 'number') {
+    return Buffer.concat([varint(number * 8), varint(value)]);
+  }
+  const bytes = Buffer.from(value);
+  return Buffer.concat([varint(number * 8 + 2), varint(bytes.length), bytes]);
+}
+
+const packed = (number: number, values: number[]) => field(number, Buffer.concat(values.map(varint)));
+
+const HANDLE = 'scip-go gomod example.com/app v1 `example.com/app/server`/Server#Handle().';
+
+/**
+ * A SCIP index of server/server.go defining Server.Handle and main.go calling it
+ */
+function scipFixture(): Buffer {
+  return Buffer.concat([
+    field(1, Buffer.concat([field(2, Buffer.concat([field(1, 'scip-go'), field(2, '0.1.10')])), field(3, 'file:///app')])),
+    field(2, Buffer.concat([
+      field(1, 'server/server.go'),
+      field(2, Buffer.concat([packed(1, [2, 17, 23]), field(2, HANDLE), field(3, 1), packed(7, [2, 0, 4, 1])])),
+      field(2, Buffer.concat([packed(1, [3, 1, 2]), field(2, 'local 0'), field(3, 1)])),
+    ])),
+    field(2, Buffer.concat([
+      field(2, Buffer.concat([packed(1, [5, 3, 9]), field(2, HANDLE)])),
+      // The path after the occurrences
+      field(1, 'main.go'),
+    ])),
+  ]);
+}
+
+describe('code indexes', () => {
+  it('should name SCIP symbols from their descriptors', () => {
+    expect(scipDisplayName(HANDLE)).toBe('Server.Handle');
+    expect(scipDisplayName('scip-typescript npm pkg 1.0 src/`util.ts`/formatDate().')).toBe('formatDate');
+    expect(scipDisplayName('scip-python python my  pkg 0.1 store/Store#items.')).toBe('Store.items');
+    expect(scipDisplayName('scip-java maven g 1 com/x/Box#[T]get(+1).')).toBe('Box.get');
+    expect(scipDisplayName('local 12')).toBeUndefined();
+  });
+
+  it('should read definitions and references from a SCIP index', () => {
+    const dump = parseScip(scipFixture());
+    expect(dump.tool).toBe('scip-go 0.1.10');
+    expect(dump.symbols).toEqual([{
+      id: HANDLE,
+      name: 'Server.Handle',
+      definitions: [{
+        relativePath: path.join('server', 'server.go'),
+        range: { start: { line: 2, character: 17 }, end: { line: 2, character: 23 } },
+        extent: { start: { line: 2, character: 0 }, end: { line: 4, character: 1 } },
+      }],
+      references: [{ relativePath: 'main.go', range: { start: { line: 5, character: 3 }, end: { line: 5, character: 9 } } }],
+    }]);
+    expect(() => parseScip(scipFixture().subarray(0, 20))).toThrow('SCIP index is truncated');
+  });
+
+  it('should follow LSIF result sets to definitions and references', () => {
+    const elements = [
+      { id: 1, type: 'vertex', label: 'metaData', projectRoot: 'file:///app', toolInfo: { name: 'lsif-node' } },
+      { id: 2, type: 'vertex', label: 'document', uri: 'file:///app/src/a.ts' },
+      { id: 3, type: 'vertex', label: 'range', start: { line: 0, character: 16 }, end: { line: 0, character: 21 } },
+      { id: 4, type: 'vertex', label: 'range', start: { line: 3, character: 0 }, end: { line: 3, character: 5 } },
+      { id: 5, type: 'vertex', label: 'resultSet' },
+      { id: 6, type: 'vertex', label: 'definitionResult' },
+      { id: 7, type: 'vertex', label: 'referenceResult' },
+      { id: 8, type: 'edge', label: 'contains', outV: 2, inVs: [3, 4] },
+      { id: 9, type: 'edge', label: 'next', outV: 3, inV: 5 },
+      { id: 10, type: 'edge', label: 'next', outV: 4, inV: 5 },
+      { id: 11, type: 'edge', label: 'textDocument/definition', outV: 5, inV: 6 },
+      { id: 12, type: 'edge', label: 'textDocument/references', outV: 5, inV: 7 },
+      { id: 13, type: 'edge', label: 'item', outV: 6, inVs: [3], document: 2 },
+      { id: 14, type: 'edge', label: 'item', outV: 7, inVs: [3], document: 2, property: 'definitions' },
+      { id: 15, type: 'edge', label: 'item', outV: 7, inVs: [4], document: 2, property: 'references' },
+    ];
+    const dump = parseLsif(elements.map((element) => JSON.stringify(element)).join('\n'), '/elsewhere');
+    expect(dump.tool).toBe('lsif-node');
+    expect(dump.symbols.map((symbol) => [symbol.id, symbol.definitions.map((location) => location.relativePath), symbol.references.length]))
+      .toEqual([['5', [path.join('src', 'a.ts')], 1]]);
+    // The older format is one JSON array
+    expect(parseLsif(JSON.stringify(elements), '/elsewhere').symbols).toEqual(dump.symbols);
+  });
+
+  it('should load a dump, name its symbols, and tell which files changed since', async () => {
+    const workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'codeindex-')));
+    try {
+      fs.mkdirSync(path.join(workspace, 'src'));
+      fs.writeFileSync(path.join(workspace, 'src', 'a.ts'), 'export function greet() {\n  return 1;\n}\n\ngreet();\n');
+      fs.writeFileSync(path.join(workspace, 'dump.lsif'), [
+        { id: 1, type: 'vertex', label: 'document', uri: `file://${workspace}/src/a.ts` },
+        { id: 2, type: 'vertex', label: 'range', start: { line: 0, character: 16 }, end: { line: 0, character: 21 } },
+        { id: 3, type: 'edge', label: 'contains', outV: 1, inVs: [2] },
+        { id: 4, type: 'vertex', label: 'definitionResult' },
+        { id: 5, type: 'edge', label: 'textDocument/definition', outV: 2, inV: 4 },
+        { id: 6, type: 'edge', label: 'item', outV: 4, inVs: [2] },
+      ].map((element) => JSON.stringify(element)).join('\n'));
+      const index = await loadCodeIndex(workspace, 'dump.lsif');
+      expect(index.lookup('greet').map((symbol) => symbol.name)).toEqual(['greet']);
+      expect(index.lookup('Other.greet')).toEqual([]);
+      expect(index.describe()).toBe('LSIF index dump.lsif, 1 symbols');
+      expect(await index.isStale(path.join('src', 'a.ts'))).toBe(false);
+      const later = new Date(Date.now() + 60_000);
+      fs.utimesSync(path.join(workspace, 'src', 'a.ts'), later, later);
+      expect(await index.isStale(path.join('src', 'a.ts'))).toBe(true);
+      expect(await index.isStale('missing.ts')).toBe(true);
+
+      expect((await loadCodeIndex(workspace, 'missing.lsif').catch((e) => e)).code).toBe('not-found');
+      await expect(loadCodeIndex(workspace, '../dump.lsif')).rejects.toThrow('outside');
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,770 @@
+/**
+ * Precomputed code indexes - definitions and references from LSIF and SCIP dumps
+ * CI builds these for large repositories (lsif-go, scip-typescript,
+ * scip-java, ...), where a live language server takes minutes to index. A
+ * dump answers for the files unchanged since it was built; changed files
+ * are left to the language server
+ */
+
+import * as fs from 'fs/promises';
+import * as path from 'path';
+import { Range } from '../protocol/types.js';
+import { pathToUri, uriToPath } from '../protocol/uri.js';
+import { changedFiles, workingChanges } from '../git/git.js';
+import { ToolError } from '../workspace/errors.js';
+import { readFileText, sharedOverlay } from '../workspace/overlay.js';
+import { resolveWorkspacePath } from '../workspace/walker.js';
+import { createLogger, Component } from '../logging/logger.js';
+
+const indexLogger = createLogger(Component.TOOLS);
+
+/**
+ * Largest dump loaded; the whole of it is held while parsing
+ */
+const MAX_DUMP_SIZE = 256 * 1024 * 1024;
+
+/**
+ * A location in an indexed file
+ */
+export interface IndexLocation {
+  relativePath: string;
+  range: Range; // The name
+  extent?: Range; // The whole definition, when the dump records it
+}
+
+/**
+ * A symbol of a dump with everywhere it is defined and referenced
+ */
+export interface IndexedSymbol {
+  id: string;
+  name: string; // Qualified as the definition tool takes it, e.g. Server.Handle
+  definitions: IndexLocation[];
+  references: IndexLocation[]; // Definitions excluded
+}
+
+/**
+ * The symbols a dump holds
+ */
+export interface CodeIndexDump {
+  format: 'lsif' | 'scip';
+  tool?: string; // The indexer that produced it, e.g. scip-typescript 0.3.14
+  symbols: IndexedSymbol[];
+}
+
+/**
+ * An LSIF vertex or edge; only the fields read here
+ */
+interface LsifElement {
+  id: number | string;
+  type: 'vertex' | 'edge';
+  label: string;
+  uri?: string;
+  projectRoot?: string;
+  toolInfo?: { name?: string; version?: string };
+  start?: { line: number; character: number };
+  end?: { line: number; character: number };
+  outV?: number | string;
+  inV?: number | string;
+  inVs?: Array<number | string>;
+  property?: string;
+}
+
+/**
+ * Parse an LSIF dump, as JSON lines or one JSON array
+ * Symbols are the result sets ranges point to. LSIF does not name them, so
+ * names are left empty for loadCodeIndex to read from the definitions
+ */
+export function parseLsif(text: string, workspaceDir: string): CodeIndexDump {
+  const trimmed = text.trimStart();
+  const elements: LsifElement[] = trimmed.startsWith('[')
+    ? JSON.parse(trimmed)
+    : trimmed.split('\n').filter((line) => line.trim()).map((line) => JSON.parse(line));
+
+  let root = workspaceDir;
+  let tool: string | undefined;
+  const documents = new Map<string, string>();
+  const ranges = new Map<string, Range>();
+  const rangeDocument = new Map<string, string>();
+  const next = new Map<string, string>();
+  const definitionResult = new Map<string, string>();
+  const referenceResult = new Map<string, string>();
+  const items = new Map<string, Array<{ ranges: string[]; property?: string }>>();
+
+  for (const element of elements) {
+    const id = String(element.id);
+    if (element.type === 'vertex') {
+      if (element.label === 'metaData') {
+        root = element.projectRoot ? uriToPath(element.projectRoot) : root;
+        if (element.toolInfo?.name) {
+          tool = [element.toolInfo.name, element.toolInfo.version].filter(Boolean).join(' ');
+        }
+      } else if (element.label === 'document' && element.uri) {
+        documents.set(id, path.relative(root, uriToPath(element.uri)));
+      } else if (element.label === 'range' && element.start && element.end) {
+        ranges.set(id, { start: element.start, end: element.end });
+      }
+      continue;
+    }
+    const inVs = (element.inVs ?? (element.inV !== undefined ? [element.inV] : [])).map(String);
+    const outV = String(element.outV);
+    switch (element.label) {
+      case 'contains':
+        for (const inV of inVs) {
+          rangeDocument.set(inV, outV);
+        }
+        break;
+      case 'next':
+        next.set(outV, inVs[0]);
+        break;
+      case 'textDocument/definition':
+        definitionResult.set(outV, inVs[0]);
+        break;
+      case 'textDocument/references':
+        referenceResult.set(outV, inVs[0]);
+        break;
+      case 'item':
+        if (!items.has(outV)) {
+          items.set(outV, []);
+        }
+        items.get(outV)!.push({ ranges: inVs, property: element.property });
+        break;
+    }
+  }
+
+  const locations = (ids: string[]): IndexLocation[] => ids.flatMap((id) => {
+    const document = documents.get(rangeDocument.get(id) ?? '');
+    const range = ranges.get(id);
+    // Files outside the project root, such as dependencies, are not answered for
+    return document !== undefined && range && !document.startsWith('..') ? [{ relativePath: document, range }] : [];
+  });
+
+  const symbols: IndexedSymbol[] = [];
+  const seen = new Set<string>();
+  for (const start of ranges.keys()) {
+    // A range's symbol is the last result set of its chain
+    let id = start;
+    while (next.has(id)) {
+      id = next.get(id)!;
+    }
+    const definitions = definitionResult.get(id);
+    if (seen.has(id) || definitions === undefined) {
+      continue;
+    }
+    seen.add(id);
+    const defined = locations((items.get(definitions) ?? []).flatMap((item) => item.ranges));
+    const referenced = (items.get(referenceResult.get(id) ?? '') ?? [])
+      .filter((item) => item.property !== 'definitions')
+      .flatMap((item) => item.ranges);
+    if (defined.length > 0) {
+      symbols.push({ id, name: '', definitions: defined, references: dropDefinitions(locations(referenced), defined) });
+    }
+  }
+  return { format: 'lsif', tool, symbols };
+}
+
+/**
+ * Reader of protobuf wire format, enough for the SCIP schema
+ */
+class ProtoReader {
+  private offset = 0;
+
+  constructor(private buffer: Buffer, private end = buffer.length, start = 0) {
+    this.offset = start;
+  }
+
+  done(): boolean {
+    return this.offset >= this.end;
+  }
+
+  varint(): number {
+    let result = 0;
+    let shift = 0;
+    for (;;) {
+      if (this.offset >= this.end) {
+        throw new ToolError('invalid-argument', 'SCIP index is truncated');
+      }
+      const byte = this.buffer[this.offset++];
+      // Past 32 bits, multiply rather than shift to stay exact
+      result += (byte & 0x7f) * 2 ** shift;
+      shift += 7;
+      if ((byte & 0x80) === 0) {
+        return result;
+      }
+    }
+  }
+
+  /**
+   * Next field's number and wire type
+   */
+  tag(): { field: number; wireType: number } {
+    const tag = this.varint();
+    return { field: Math.floor(tag / 8), wireType: tag & 7 };
+  }
+
+  /**
+   * A length-delimited field as a reader of its own
+   */
+  message(): ProtoReader {
+    const length = this.varint();
+    const start = this.offset;
+    this.offset += length;
+    if (this.offset > this.end) {
+      throw new ToolError('invalid-argument', 'SCIP index is truncated');
+    }
+    return new ProtoReader(this.buffer, this.offset, start);
+  }
+
+  string(): string {
+    const field = this.message();
+    return this.buffer.toString('utf8', field.offset, field.end);
+  }
+
+  /**
+   * A repeated int32, packed or as one unpacked element
+   */
+  int32s(wireType: number, into: number[]): void {
+    if (wireType !== 2) {
+      into.push(this.varint());
+      return;
+    }
+    const packed = this.message();
+    while (!packed.done()) {
+      into.push(packed.varint());
+    }
+  }
+
+  skip(wireType: number): void {
+    switch (wireType) {
+      case 0:
+        this.varint();
+        break;
+      case 1:
+        this.offset += 8;
+        break;
+      case 2:
+        this.message();
+        break;
+      case 5:
+        this.offset += 4;
+        break;
+      default:
+        throw new ToolError('invalid-argument', `SCIP index has unsupported wire type ${wireType}`);
+    }
+  }
+}
+
+/**
+ * SymbolRole.Definition in the SCIP schema
+ */
+const SCIP_DEFINITION_ROLE = 1;
+
+/**
+ * A SCIP range: start line, start character, and end character on that line,
+ * or start line, start character, end line, and end character
+ */
+function scipRange(values: number[]): Range | undefined {
+  if (values.length === 3) {
+    return { start: { line: values[0], character: values[1] }, end: { line: values[0], character: values[2] } };
+  }
+  if (values.length === 4) {
+    return { start: { line: values[0], character: values[1] }, end: { line: values[2], character: values[3] } };
+  }
+  return undefined;
+}
+
+/**
+ * Parse a SCIP index
+ * Symbols local to a document are left out: they cannot be looked up by name
+ */
+export function parseScip(buffer: Buffer): CodeIndexDump {
+  const index = new ProtoReader(buffer);
+  let tool: string | undefined;
+  const bySymbol = new Map<string, IndexedSymbol>();
+  const displayNames = new Map<string, string>();
+
+  const occurrence = (reader: ProtoReader, relativePath: string) => {
+    const range: number[] = [];
+    const enclosing: number[] = [];
+    let symbol = '';
+    let roles = 0;
+    while (!reader.done()) {
+      const { field, wireType } = reader.tag();
+      if (field === 1) {
+        reader.int32s(wireType, range);
+      } else if (field === 2 && wireType === 2) {
+        symbol = reader.string();
+      } else if (field === 3 && wireType === 0) {
+        roles = reader.varint();
+      } else if (field === 7) {
+        reader.int32s(wireType, enclosing);
+      } else {
+        reader.skip(wireType);
+      }
+    }
+    const location = scipRange(range);
+    if (!symbol || symbol.startsWith('local ') || !location) {
+      return;
+    }
+    if (!bySymbol.has(symbol)) {
+      bySymbol.set(symbol, { id: symbol, name: '', definitions: [], references: [] });
+    }
+    const entry = bySymbol.get(symbol)!;
+    if (roles & SCIP_DEFINITION_ROLE) {
+      entry.definitions.push({ relativePath, range: location, extent: scipRange(enclosing) });
+    } else {
+      entry.references.push({ relativePath, range: location });
+    }
+  };
+
+  const symbolInformation = (reader: ProtoReader) => {
+    let symbol = '';
+    let displayName = '';
+    while (!reader.done()) {
+      const { field, wireType } = reader.tag();
+      if (field === 1 && wireType === 2) {
+        symbol = reader.string();
+      } else if (field === 6 && wireType === 2) {
+        displayName = reader.string();
+      } else {
+        reader.skip(wireType);
+      }
+    }
+    if (symbol && displayName) {
+      displayNames.set(symbol, displayName);
+    }
+  };
+
+  const document = (reader: ProtoReader) => {
+    let relativePath = '';
+    const occurrences: ProtoReader[] = [];
+    while (!reader.done()) {
+      const { field, wireType } = reader.tag();
+      if (field === 1 && wireType === 2) {
+        relativePath = reader.string();
+      } else if (field === 2 && wireType === 2) {
+        // relative_path may come after the occurrences
+        occurrences.push(reader.message());
+      } else if (field === 3 && wireType === 2) {
+        symbolInformation(reader.message());
+      } else {
+        reader.skip(wireType);
+      }
+    }
+    const filePath = relativePath.split('/').join(path.sep);
+    for (const entry of occurrences) {
+      occurrence(entry, filePath);
+    }
+  };
+
+  const metadata = (reader: ProtoReader) => {
+    while (!reader.done()) {
+      const { field, wireType } = reader.tag();
+      if (field === 2 && wireType === 2) {
+        const info = reader.message();
+        const parts: string[] = [];
+        while (!info.done()) {
+          const entry = info.tag();
+          if ((entry.field === 1 || entry.field === 2) && entry.wireType === 2) {
+            parts.push(info.string());
+          } else {
+            info.skip(entry.wireType);
+          }
+        }
+        tool = parts.filter(Boolean).join(' ') || undefined;
+      } else {
+        reader.skip(wireType);
+      }
+    }
+  };
+
+  while (!index.done()) {
+    const { field, wireType } = index.tag();
+    if (field === 1 && wireType === 2) {
+      metadata(index.message());
+    } else if (field === 2 && wireType === 2) {
+      document(index.message());
+    } else {
+      index.skip(wireType);
+    }
+  }
+
+  const symbols: IndexedSymbol[] = [];
+  for (const entry of bySymbol.values()) {
+    const name = scipDisplayName(entry.id, displayNames.get(entry.id));
+    if (name && entry.definitions.length > 0) {
+      symbols.push({ ...entry, name, references: dropDefinitions(entry.references, entry.definitions) });
+    }
+  }
+  return { format: 'scip', tool, symbols };
+}
+
+/**
+ * Name of a SCIP symbol as the definition tool takes it, from its descriptors
+ * `scip-go gomod example.com/app v1 `example.com/app/server`/Server#Handle().`
+ * is Server.Handle: namespaces, parameters, and type parameters are left out.
+ * A display name the indexer recorded replaces the last part
+ */
+export function scipDisplayName(symbol: string, displayName?: string): string | undefined {
+  if (symbol.startsWith('local ')) {
+    return undefined;
+  }
+  // Scheme, manager, package name, and version come first; two spaces are an escaped space
+  let i = 0;
+  for (let field = 0; field < 4 && i < symbol.length; field++) {
+    while (i < symbol.length && !(symbol[i] === ' ' && symbol[i + 1] !== ' ')) {
+      i += symbol[i] === ' ' ? 2 : 1;
+    }
+    i++;
+  }
+  const descriptors = symbol.substring(i);
+  let j = 0;
+  const readName = (): string => {
+    if (descriptors[j] !== '`') {
+      const name = /^[\w+$-]*/.exec(descriptors.substring(j))![0];
+      j += name.length;
+      return name;
+    }
+    let name = '';
+    for (j++; j < descriptors.length; j++) {
+      if (descriptors[j] === '`') {
+        if (descriptors[j + 1] !== '`') {
+          j++;
+          break;
+        }
+        j++;
+      }
+      name += descriptors[j];
+    }
+    return name;
+  };
+
+  const names: string[] = [];
+  while (j < descriptors.length) {
+    // Type parameters [T] and parameters (x)
+    if (descriptors[j] === '[' || descriptors[j] === '(') {
+      j++;
+      readName();
+      j++;
+      continue;
+    }
+    const name = readName();
+    const suffix = descriptors[j];
+    if (suffix === '(') {
+      // A method: (disambiguator).
+      const close = descriptors.indexOf(').', j);
+      j = close < 0 ? descriptors.length : close + 2;
+      names.push(name);
+      continue;
+    }
+    j++;
+    // Types (#), terms (.), and macros (!); namespaces (/) and meta (:) are left out
+    if ((suffix === '#' || suffix === '.' || suffix === '!') && name) {
+      names.push(name);
+    }
+  }
+  if (displayName && names.length > 0) {
+    names[names.length - 1] = displayName;
+  }
+  return names.length > 0 ? names.join('.') : undefined;
+}
+
+/**
+ * Writer of protobuf wire format, the counterpart of ProtoReader
+ * Zero numbers and empty strings are left out, as protobuf leaves out defaults
+ */
+class ProtoWriter {
+  private chunks: Buffer[] = [];
+
+  private varint(value: number): void {
+    const bytes: number[] = [];
+    while (value > 127) {
+      bytes.push((value % 128) | 128);
+      value = Math.floor(value / 128);
+    }
+    bytes.push(value);
+    this.chunks.push(Buffer.from(bytes));
+  }
+
+  private delimited(field: number, bytes: Buffer): void {
+    this.varint(field * 8 + 2);
+    this.varint(bytes.length);
+    this.chunks.push(bytes);
+  }
+
+  number(field: number, value: number): this {
+    if (value) {
+      this.varint(field * 8);
+      this.varint(value);
+    }
+    return this;
+  }
+
+  string(field: number, value: string | undefined): this {
+    if (value) {
+      this.delimited(field, Buffer.from(value, 'utf8'));
+    }
+    return this;
+  }
+
+  message(field: number, build: (writer: ProtoWriter) => void): this {
+    const writer = new ProtoWriter();
+    build(writer);
+    this.delimited(field, writer.finish());
+    return this;
+  }
+
+  int32s(field: number, values: number[]): this {
+    const writer = new ProtoWriter();
+    for (const value of values) {
+      writer.varint(value);
+    }
+    this.delimited(field, writer.finish());
+    return this;
+  }
+
+  finish(): Buffer {
+    return Buffer.concat(this.chunks);
+  }
+}
+
+/**
+ * A range as SCIP stores it: three numbers when it is on one line
+ */
+function scipRangeValues(range: Range): number[] {
+  return range.start.line === range.end.line
+    ? [range.start.line, range.start.character, range.end.character]
+    : [range.start.line, range.start.character, range.end.line, range.end.character];
+}
+
+/**
+ * PositionEncoding.UTF16CodeUnitOffsetFromLineStart: characters count as JavaScript strings do
+ */
+const SCIP_UTF16_POSITIONS = 2;
+
+/**
+ * TextEncoding.UTF8 in the SCIP schema
+ */
+const SCIP_UTF8_TEXT = 1;
+
+/**
+ * Encode symbols as a SCIP index, one document per file with its
+ * occurrences sorted by position
+ * Symbol ids are written as they are, so they should be SCIP symbols
+ */
+export function encodeScip(dump: CodeIndexDump, projectRoot: string): Buffer {
+  type Occurrence = { range: Range; symbol: string; definition: boolean; extent?: Range };
+  const documents = new Map<string, { occurrences: Occurrence[]; defined: IndexedSymbol[] }>();
+  const documentOf = (relativePath: string) => {
+    if (!documents.has(relativePath)) {
+      documents.set(relativePath, { occurrences: [], defined: [] });
+    }
+    return documents.get(relativePath)!;
+  };
+  for (const symbol of dump.symbols) {
+    for (const location of symbol.definitions) {
+      documentOf(location.relativePath).occurrences.push({ range: location.range, symbol: symbol.id, definition: true, extent: location.extent });
+    }
+    const home = documentOf(symbol.definitions[0].relativePath);
+    home.defined.push(symbol);
+    for (const location of symbol.references) {
+      documentOf(location.relativePath).occurrences.push({ range: location.range, symbol: symbol.id, definition: false });
+    }
+  }
+
+  const [toolName, ...toolVersion] = (dump.tool ?? 'grep-for-code').split(' ');
+  const index = new ProtoWriter().message(1, (metadata) => metadata
+    .message(2, (info) => info.string(1, toolName).string(2, toolVersion.join(' ')))
+    .string(3, pathToUri(projectRoot))
+    .number(4, SCIP_UTF8_TEXT));
+  for (const relativePath of Array.from(documents.keys()).sort()) {
+    const { occurrences, defined } = documents.get(relativePath)!;
+    occurrences.sort((a, b) => a.range.start.line - b.range.start.line || a.range.start.character - b.range.start.character);
+    index.message(2, (document) => {
+      document.string(1, relativePath.split(path.sep).join('/'));
+      for (const occurrence of occurrences) {
+        document.message(2, (entry) => {
+          entry.int32s(1, scipRangeValues(occurrence.range))
+            .string(2, occurrence.symbol)
+            .number(3, occurrence.definition ? SCIP_DEFINITION_ROLE : 0);
+          if (occurrence.extent) {
+            entry.int32s(7, scipRangeValues(occurrence.extent));
+          }
+        });
+      }
+      for (const symbol of defined) {
+        document.message(3, (information) => information
+          .string(1, symbol.id)
+          .string(6, symbol.name.substring(symbol.name.lastIndexOf('.') + 1)));
+      }
+      document.number(6, SCIP_UTF16_POSITIONS);
+    });
+  }
+  return index.finish();
+}
+
+function sameLocation(a: IndexLocation, b: IndexLocation): boolean {
+  return a.relativePath === b.relativePath &&
+    a.range.start.line === b.range.start.line && a.range.start.character === b.range.start.character;
+}
+
+function dropDefinitions(references: IndexLocation[], definitions: IndexLocation[]): IndexLocation[] {
+  return references.filter((reference) => !definitions.some((definition) => sameLocation(reference, definition)));
+}
+
+/**
+ * Read the text at a range, for symbols a dump does not name
+ */
+async function fillNames(workspaceDir: string, symbols: IndexedSymbol[]): Promise<IndexedSymbol[]> {
+  const files = new Map<string, Promise<string[] | undefined>>();
+  const linesOf = (relativePath: string) => {
+    if (!files.has(relativePath)) {
+      files.set(relativePath, readFileText(path.join(workspaceDir, relativePath))
+        .then((text) => text.split('\n'), () => undefined));
+    }
+    return files.get(relativePath)!;
+  };
+  const named: IndexedSymbol[] = [];
+  for (const symbol of symbols) {
+    if (symbol.name) {
+      named.push(symbol);
+      continue;
+    }
+    const { relativePath, range } = symbol.definitions[0];
+    const line = (await linesOf(relativePath))?.[range.start.line];
+    const end = range.end.line === range.start.line ? range.end.character : undefined;
+    const name = line?.substring(range.start.character, end).trim();
+    if (name && /^[\w$]+$/.test(name)) {
+      named.push({ ...symbol, name });
+    }
+  }
+  return named;
+}
+
+/**
+ * Options for loading a dump
+ */
+export interface CodeIndexOptions {
+  // Revision the dump was built at; files changed since are stale. Without
+  // it, files modified after the dump file was written are
+  commit?: string;
+}
+
+/**
+ * A loaded dump that answers lookups for files unchanged since it was built
+ */
+export class CodeIndex {
+  private byName = new Map<string, IndexedSymbol[]>();
+  private byLastPart = new Map<string, IndexedSymbol[]>();
+
+  constructor(
+    readonly workspaceDir: string,
+    readonly source: string,
+    readonly dump: CodeIndexDump,
+    // When the dump was written, from its modification time
+    readonly builtAt: number,
+    private changed = new Set<string>()
+  ) {
+    for (const symbol of dump.symbols) {
+      const lastPart = symbol.name.substring(symbol.name.lastIndexOf('.') + 1);
+      for (const [map, key] of [[this.byName, symbol.name], [this.byLastPart, lastPart]] as const) {
+        if (!map.has(key)) {
+          map.set(key, []);
+        }
+        map.get(key)!.push(symbol);
+      }
+    }
+  }
+
+  /**
+   * Symbols with a name: qualified names match exactly, and unqualified ones
+   * also match members, as the definition tool matches methods
+   */
+  lookup(symbolName: string): IndexedSymbol[] {
+    if (symbolName.includes('.')) {
+      return this.byName.get(symbolName) ?? [];
+    }
+    return this.byLastPart.get(symbolName) ?? [];
+  }
+
+  /**
+   * Check if a file changed since the dump was built, so its locations may be wrong
+   */
+  async isStale(relativePath: string): Promise<boolean> {
+    const filePath = path.join(this.workspaceDir, relativePath);
+    if (this.changed.has(relativePath) || sharedOverlay().has(filePath)) {
+      return true;
+    }
+    try {
+      return (await fs.stat(filePath)).mtimeMs > this.builtAt;
+    } catch (err) {
+      // Deleted since
+      return true;
+    }
+  }
+
+  /**
+   * Files known to have changed since the dump was built: those git reports,
+   * and the given ones that were modified since
+   */
+  async staleFiles(files: Iterable<string>): Promise<Set<string>> {
+    const stale = new Set<string>(this.changed);
+    await Promise.all([...new Set(files)].map(async (file) => {
+      if (await this.isStale(file)) {
+        stale.add(file);
+      }
+    }));
+    return stale;
+  }
+
+  /**
+   * One line naming the dump, e.g. "SCIP index (scip-go 0.1.10) index.scip, 1532 symbols"
+   */
+  describe(): string {
+    const tool = this.dump.tool ? ` (${this.dump.tool})` : '';
+    return `${this.dump.format.toUpperCase()} index${tool} ${this.source}, ${this.dump.symbols.length} symbols`;
+  }
+}
+
+/**
+ * Load an LSIF or SCIP dump, told apart by content: LSIF is JSON
+ * With a commit, files changed from it to the work tree are stale;
+ * otherwise uncommitted files and files modified after the dump was written are.
+ * The dump must be inside the workspace and at most MAX_DUMP_SIZE
+ */
+export async function loadCodeIndex(workspaceDir: string, dumpPath: string, options: CodeIndexOptions = {}): Promise<CodeIndex> {
+  const filePath = resolveWorkspacePath(workspaceDir, dumpPath);
+  let size: number;
+  try {
+    size = (await fs.stat(filePath)).size;
+  } catch {
+    throw new ToolError('not-found', `code index ${dumpPath} does not exist`);
+  }
+  if (size > MAX_DUMP_SIZE) {
+    throw new ToolError('invalid-argument', `code index ${dumpPath} is ${Math.round(size / 1048576)} MB, over the ${MAX_DUMP_SIZE / 1048576} MB limit`);
+  }
+  const buffer = await fs.readFile(filePath);
+  const start = buffer.toString('utf8', 0, Math.min(buffer.length, 64)).trimStart();
+  const dump = start.startsWith('{') || start.startsWith('[')
+    ? parseLsif(buffer.toString('utf8'), workspaceDir)
+    : parseScip(buffer);
+  dump.symbols = await fillNames(workspaceDir, dump.symbols);
+
+  let builtAt = (await fs.stat(filePath)).mtimeMs;
+  const changed = new Set<string>();
+  if (options.commit) {
+    for (const file of await changedFiles(workspaceDir, options.commit)) {
+      changed.add(file.path.split('/').join(path.sep));
+      if (file.oldPath) {
+        changed.add(file.oldPath.split('/').join(path.sep));
+      }
+    }
+    // Git knows what changed up to now; edits after loading count by time
+    builtAt = Date.now();
+  }
+  // Uncommitted files are ones the language server should answer for, such as new ones the dump lacks
+  for (const file of (await workingChanges(workspaceDir))?.keys() ?? []) {
+    changed.add(file);
+  }
+  indexLogger.info('Loaded %s index %s: %d symbols, %d files changed since', dump.format, dumpPath, dump.symbols.length, changed.size);
+  return new CodeIndex(workspaceDir, dumpPath, dump, builtAt, changed);
+}
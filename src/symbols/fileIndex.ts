@@ -0,0 +1,169 @@
+/**
+ * Symbol index over files parsed by a built-in parser
+ * Backs document and workspace symbols for languages no attached language
+ * server covers (Python without a Python server, Protocol Buffers)
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { DocumentSymbol, SymbolInformation } from '../protocol/types.js';
+import { pathToUri } from '../protocol/uri.js';
+import { WorkspaceFile, walkWorkspaceFiles } from '../workspace/walker.js';
+import { readFileText, sharedOverlay } from '../workspace/overlay.js';
+import { currentSnapshot } from '../workspace/treesnapshot.js';
+import { contentHash } from '../semantic/store.js';
+
+const indexLogger = createLogger(Component.TOOLS);
+
+/**
+ * Symbols of one file as a snapshot carries them
+ */
+export interface SymbolSnapshotFile {
+  relativePath: string;
+  hash: string;
+  symbols: DocumentSymbol[];
+}
+
+/**
+ * How long a walk of the workspace is reused when no file event has said it changed
+ */
+const WALK_MAX_AGE_MS = 30_000;
+
+/**
+ * Document and workspace symbols of the files a parser accepts
+ * Parsed files are cached until their modification time or size changes;
+ * overlaid files are parsed on each request. The files the parser accepts
+ * are walked once and reused until filesChanged is called or the walk ages out
+ */
+export class FileSymbolIndex {
+  private files = new Map<string, { stamp: string; hash: string; symbols: DocumentSymbol[] }>();
+  // Symbols from a snapshot, by path, taken instead of parsing while the file is unchanged
+  private seeds = new Map<string, SymbolSnapshotFile>();
+  private walked?: { at: number; files: Promise<WorkspaceFile[]> };
+
+  constructor(
+    private workspaceDir: string,
+    private accepts: (filePath: string) => boolean,
+    private parse: (content: string) => DocumentSymbol[]
+  ) {}
+
+  getWorkspaceDir(): string {
+    return this.workspaceDir;
+  }
+
+  /**
+   * Walk the workspace again on the next request, after files were added, removed, or excluded
+   */
+  filesChanged(): void {
+    this.walked = undefined;
+  }
+
+  /**
+   * The workspace files the parser accepts
+   */
+  private acceptedFiles(): Promise<WorkspaceFile[]> {
+    if (!this.walked || Date.now() - this.walked.at > WALK_MAX_AGE_MS) {
+      const files = walkWorkspaceFiles(this.workspaceDir).then((all) => all.filter((file) => this.accepts(file.relativePath)));
+      // A failed walk is not kept
+      files.catch(() => {
+        if (this.walked?.files === files) {
+          this.walked = undefined;
+        }
+      });
+      this.walked = { at: Date.now(), files };
+    }
+    return this.walked.files;
+  }
+
+  /**
+   * Symbol tree of one file
+   */
+  async documentSymbols(filePath: string): Promise<DocumentSymbol[]> {
+    // Neither overlays nor snapshot content match the file's stamp on disk
+    if (sharedOverlay().has(filePath) || currentSnapshot()?.covers(filePath)) {
+      return this.parse(await readFileText(filePath));
+    }
+    const stat = await fs.promises.stat(filePath);
+    const stamp = `${stat.mtimeMs}:${stat.size}`;
+    const cached = this.files.get(filePath);
+    if (cached && cached.stamp === stamp) {
+      return cached.symbols;
+    }
+    const content = await readFileText(filePath);
+    const hash = contentHash(content);
+    const relativePath = path.relative(this.workspaceDir, filePath);
+    const seed = this.seeds.get(relativePath);
+    this.seeds.delete(relativePath);
+    const symbols = seed && seed.hash === hash ? seed.symbols : this.parse(content);
+    this.files.set(filePath, { stamp, hash, symbols });
+    return symbols;
+  }
+
+  /**
+   * Symbols of every file the parser accepts, to be written to a snapshot
+   */
+  async snapshotFiles(): Promise<SymbolSnapshotFile[]> {
+    const files = await this.acceptedFiles();
+    const snapshot: SymbolSnapshotFile[] = [];
+    for (const file of files) {
+      try {
+        const symbols = await this.documentSymbols(file.absolutePath);
+        const cached = this.files.get(file.absolutePath);
+        // Overlaid files are not cached, and their unsaved text does not belong in a snapshot
+        if (cached) {
+          snapshot.push({ relativePath: file.relativePath, hash: cached.hash, symbols });
+        }
+      } catch (err) {
+        indexLogger.debug('Could not parse %s: %s', file.absolutePath, (err as Error).message);
+      }
+    }
+    return snapshot;
+  }
+
+  /**
+   * Reuse the symbols of a snapshot for files whose content is unchanged
+   */
+  seed(files: SymbolSnapshotFile[]): void {
+    for (const file of files) {
+      this.seeds.set(file.relativePath, file);
+    }
+  }
+
+  /**
+   * Symbols whose name contains the query (case-insensitive), as a language
+   * server returns for workspace/symbol; an empty query matches every symbol
+   */
+  async workspaceSymbols(query: string, maxResults = 1000): Promise<SymbolInformation[]> {
+    const wanted = query.toLowerCase();
+    const results: SymbolInformation[] = [];
+    const files = await this.acceptedFiles();
+
+    for (const file of files) {
+      let symbols: DocumentSymbol[];
+      try {
+        symbols = await this.documentSymbols(file.absolutePath);
+      } catch (err) {
+        indexLogger.debug('Could not parse %s: %s', file.absolutePath, (err as Error).message);
+        continue;
+      }
+      const uri = pathToUri(file.absolutePath);
+      const visit = (symbol: DocumentSymbol, container?: string) => {
+        if (results.length >= maxResults) {
+          return;
+        }
+        if (symbol.name.toLowerCase().includes(wanted)) {
+          results.push({ name: symbol.name, kind: symbol.kind, location: { uri, range: symbol.range }, containerName: container });
+        }
+        for (const child of symbol.children ?? []) {
+          visit(child, container ? `${container}.${symbol.name}` : symbol.name);
+        }
+      };
+      symbols.forEach((symbol) => visit(symbol));
+      if (results.length >= maxResults) {
+        break;
+      }
+    }
+    return results;
+  }
+}
@@ -0,0 +1,103 @@
+/**
+ * Tests for the Go declaration parser
+ */
+
+import { goPackageName, parameterTypes, parseGoDeclarations, parseGoImports } from './golang';
+
+describe('golang', () => {
+  it('should drop parameter names', () => {
+    expect(parameterTypes('a, b int, m map[string][]byte')).toEqual(['int', 'int', 'map[string][]byte']);
+    expect(parameterTypes('chan int, func(x int) error')).toEqual(['chan int', 'func(x int) error']);
+    expect(parameterTypes('')).toEqual([]);
+  });
+
+  it('should read interfaces, types, and methods', () => {
+    const source = [
+      'package store',
+      '',
+      '// Store keeps items: type Fake interface { Nope() }',
+      'type Store interface {',
+      '\tio.Closer',
+      '\tGet(key string) (*Item, error)',
+      '\tPut(key string, item *Item) error',
+      '}',
+      '',
+      'type (',
+      '\tNumber interface { ~int | ~float64 }',
+      '\tItem struct { Name string }',
+      '\tNames []string',
+      ')',
+      '',
+      'type Mem[K comparable] struct{ items map[K]*Item }',
+      '',
+      'func (m *Mem[K]) Get(key string) (*Item, error) { return nil, nil }',
+      'func (m Mem[K]) Len() int { return len(m.items) }',
+      '',
+      'func Open[K comparable](dir string, opts ...Option) (*Mem[K], error) {',
+      '\treturn nil, nil',
+      '}',
+    ].join('\n');
+    const declarations = parseGoDeclarations(source);
+    expect(declarations.package).toBe('store');
+    expect(declarations.interfaces).toEqual([
+      {
+        name: 'Store',
+        line: 4,
+        methods: [
+          { name: 'Get', signature: '(string) (*Item, error)' },
+          { name: 'Put', signature: '(string, *Item) error' },
+        ],
+        embeds: ['io.Closer'],
+        constraint: false,
+      },
+      { name: 'Number', line: 11, methods: [], embeds: [], constraint: true },
+    ]);
+    expect(declarations.types).toEqual([
+      { name: 'Item', line: 12, kind: 'struct' },
+      { name: 'Names', line: 13, kind: 'other' },
+      { name: 'Mem', line: 16, kind: 'struct' },
+    ]);
+    expect(declarations.methods).toEqual([
+      { receiver: 'Mem', pointer: true, name: 'Get', signature: '(string) (*Item, error)', line: 18 },
+      { receiver: 'Mem', pointer: false, name: 'Len', signature: '() int', line: 19 },
+    ]);
+    expect(declarations.functions).toEqual([
+      { name: 'Open', signature: '(string, ...Option) (*Mem[K], error)', line: 21 },
+    ]);
+  });
+
+  it('should read imports with their aliases', () => {
+    const source = [
+      'package api',
+      '',
+      '// import "commented/out"',
+      'import "fmt"',
+      'import us "example.com/user"',
+      'import (',
+      '\t"context"',
+      '\t_ "example.com/drivers/pg" // registers "pg"',
+      '\t. "example.com/testing/helpers"',
+      '\tstore `example.com/app/internal/store`',
+      ')',
+      '',
+      'var s = "import \\"not/an/import\\""',
+    ].join('\n');
+    expect(parseGoImports(source)).toEqual([
+      { path: 'fmt', line: 4 },
+      { path: 'example.com/user', alias: 'us', line: 5 },
+      { path: 'context', line: 7 },
+      { path: 'example.com/drivers/pg', alias: '_', line: 8 },
+      { path: 'example.com/testing/helpers', alias: '.', line: 9 },
+      { path: 'example.com/app/internal/store', alias: 'store', line: 10 },
+    ]);
+  });
+
+  it('should assume package names from import paths', () => {
+    expect(goPackageName('example.com/user')).toBe('user');
+    expect(goPackageName('example.com/lib/v2')).toBe('lib');
+    expect(goPackageName('gopkg.in/yaml.v3')).toBe('yaml');
+    expect(goPackageName('github.com/mattn/go-sqlite3')).toBe('sqlite3');
+    expect(goPackageName('github.com/acme/client-go')).toBe('client');
+    expect(goPackageName('fmt')).toBe('fmt');
+  });
+});
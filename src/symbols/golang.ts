@@ -0,0 +1,411 @@
+/**
+ * Go type and method declarations without a language server
+ * Reads interfaces with their method sets, named types, functions, and
+ * methods with their receivers from Go source, and normalizes signatures to
+ * parameter and result types, so method sets can be compared across files
+ */
+
+/**
+ * A method: its name and signature without parameter names, e.g. "([]byte) (int, error)"
+ */
+export interface GoMethod {
+  name: string;
+  signature: string;
+}
+
+/**
+ * An interface declaration
+ */
+export interface GoInterface {
+  name: string;
+  line: number; // 1-indexed
+  methods: GoMethod[];
+  // Embedded interfaces as written, e.g. "io.Reader" or "Closer"
+  embeds: string[];
+  // Has type-set terms (~int | string), so it can only constrain type parameters
+  constraint: boolean;
+}
+
+/**
+ * A named type declaration other than an interface
+ */
+export interface GoType {
+  name: string;
+  line: number;
+  kind: 'struct' | 'other';
+}
+
+/**
+ * A method declaration
+ */
+export interface GoMethodDecl extends GoMethod {
+  receiver: string; // Type name without * or type arguments
+  pointer: boolean;
+  line: number;
+}
+
+/**
+ * A package-level function declaration
+ */
+export interface GoFunctionDecl extends GoMethod {
+  line: number;
+}
+
+/**
+ * An import spec of a Go file
+ */
+export interface GoImport {
+  path: string;
+  // Name the file gives the package, as written: a name, "." or "_"
+  alias?: string;
+  line: number;
+}
+
+/**
+ * The declarations of a Go file
+ */
+export interface GoDeclarations {
+  package?: string;
+  interfaces: GoInterface[];
+  types: GoType[];
+  methods: GoMethodDecl[];
+  functions: GoFunctionDecl[];
+}
+
+/**
+ * Replace comments and the contents of string and rune literals with spaces,
+ * keeping line breaks, so braces and parentheses can be matched
+ */
+export function blankGoLiterals(content: string): string {
+  let out = '';
+  let i = 0;
+  while (i < content.length) {
+    const ch = content[i];
+    if (content.startsWith('//', i)) {
+      while (i < content.length && content[i] !== '\n') {
+        out += ' ';
+        i++;
+      }
+    } else if (content.startsWith('/*', i)) {
+      const end = content.indexOf('*/', i + 2);
+      const stop = end === -1 ? content.length : end + 2;
+      out += content.substring(i, stop).replace(/[^\n]/g, ' ');
+      i = stop;
+    } else if (ch === '"' || ch === '\'' || ch === '`') {
+      out += ch;
+      i++;
+      while (i < content.length && content[i] !== ch && (ch === '`' || content[i] !== '\n')) {
+        if (content[i] === '\\' && ch !== '`') {
+          out += ' ';
+          i++;
+        }
+        out += content[i] === '\n' ? '\n' : ' ';
+        i++;
+      }
+      if (i < content.length) {
+        out += content[i];
+        i++;
+      }
+    } else {
+      out += ch;
+      i++;
+    }
+  }
+  return out;
+}
+
+/**
+ * Index just past the bracket closing the one at start
+ */
+function matchBracket(text: string, start: number): number {
+  const open = text[start];
+  const close = open === '(' ? ')' : open === '[' ? ']' : '}';
+  let depth = 0;
+  for (let i = start; i < text.length; i++) {
+    if (text[i] === open) {
+      depth++;
+    } else if (text[i] === close && --depth === 0) {
+      return i + 1;
+    }
+  }
+  return text.length;
+}
+
+/**
+ * Split at commas outside brackets
+ */
+function splitTopLevel(text: string): string[] {
+  const parts: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let i = 0; i < text.length; i++) {
+    const ch = text[i];
+    if ('([{'.includes(ch)) {
+      depth++;
+    } else if (')]}'.includes(ch)) {
+      depth--;
+    } else if (ch === ',' && depth === 0) {
+      parts.push(text.substring(start, i));
+      start = i + 1;
+    }
+  }
+  parts.push(text.substring(start));
+  return parts.map((part) => part.trim()).filter(Boolean);
+}
+
+/**
+ * Collapse whitespace in a type, keeping the spaces Go needs (chan int, func() error)
+ */
+function normalizeType(type: string): string {
+  return type.replace(/\s+/g, ' ').replace(/([([\]*.])\s/g, '$1').replace(/\s([)\],.])/g, '$1').replace(/,(?=\S)/g, ', ').trim();
+}
+
+const TYPE_KEYWORDS = new Set(['chan', 'func', 'map', 'struct', 'interface']);
+
+/**
+ * Types of a parameter or result list without its names
+ * Go lists either name every entry or none: "a, b int, c string" is (int, int, string)
+ */
+export function parameterTypes(list: string): string[] {
+  const items = splitTopLevel(list);
+  const named = items.some((item) => {
+    const match = item.match(/^([A-Za-z_]\w*)\s+\S/);
+    return match !== null && !TYPE_KEYWORDS.has(match[1]);
+  });
+  if (!named) {
+    return items.map(normalizeType);
+  }
+  const types: string[] = [];
+  let pendingNames = 0;
+  for (const item of items) {
+    const match = item.match(/^[A-Za-z_]\w*\s+(.+)$/s);
+    if (match) {
+      const type = normalizeType(match[1]);
+      for (let i = 0; i <= pendingNames; i++) {
+        types.push(type);
+      }
+      pendingNames = 0;
+    } else {
+      pendingNames++; // A name sharing the type of the next entry
+    }
+  }
+  return types;
+}
+
+/**
+ * Normalized signature from the text after a method name: "(params) results"
+ */
+function readSignature(text: string, start: number): { signature: string; end: number } {
+  const paramsEnd = matchBracket(text, start);
+  const params = parameterTypes(text.substring(start + 1, paramsEnd - 1));
+  // Results run to the end of the line or the body
+  let i = paramsEnd;
+  while (i < text.length && (text[i] === ' ' || text[i] === '\t')) {
+    i++;
+  }
+  let results: string[] = [];
+  let end = i;
+  if (text[i] === '(') {
+    end = matchBracket(text, i);
+    results = parameterTypes(text.substring(i + 1, end - 1));
+  } else {
+    while (end < text.length && text[end] !== '\n' && text[end] !== ';') {
+      if (text[end] === '(' || text[end] === '[') {
+        end = matchBracket(text, end);
+      } else if (text[end] === '{') {
+        // The body starts, unless the result is a struct or interface type
+        if (!/\b(?:struct|interface)\s*$/.test(text.substring(i, end))) {
+          break;
+        }
+        end = matchBracket(text, end);
+      } else {
+        end++;
+      }
+    }
+    const result = text.substring(i, end).trim();
+    results = result ? [normalizeType(result)] : [];
+  }
+  const resultText = results.length === 0 ? '' : results.length === 1 ? ` ${results[0]}` : ` (${results.join(', ')})`;
+  return { signature: `(${params.join(', ')})${resultText}`, end };
+}
+
+/**
+ * Format a method as Go declares it in an interface
+ */
+export function formatGoMethod(method: GoMethod): string {
+  return `${method.name}${method.signature}`;
+}
+
+/**
+ * Line number (1-indexed) of an offset
+ */
+function lineAt(text: string, offset: number): number {
+  let line = 1;
+  for (let i = 0; i < offset; i++) {
+    if (text.charCodeAt(i) === 10) {
+      line++;
+    }
+  }
+  return line;
+}
+
+/**
+ * Read the elements of an interface body
+ */
+function parseInterfaceBody(body: string): Pick<GoInterface, 'methods' | 'embeds' | 'constraint'> {
+  const methods: GoMethod[] = [];
+  const embeds: string[] = [];
+  let constraint = false;
+  let i = 0;
+  while (i < body.length) {
+    const rest = body.substring(i);
+    const method = rest.match(/^[\s;]*([A-Za-z_]\w*)\s*\(/);
+    if (method) {
+      const open = i + method[0].length - 1;
+      const { signature, end } = readSignature(body, open);
+      methods.push({ name: method[1], signature });
+      i = end;
+      continue;
+    }
+    const line = rest.match(/^[\s;]*([^\n;]*)/);
+    const element = line ? line[1].trim() : '';
+    if (element) {
+      if (/[|~]/.test(element) || !/^[A-Za-z_][\w.]*(\[.*\])?$/.test(element)) {
+        constraint = true;
+      } else {
+        embeds.push(element.replace(/\[.*\]$/, ''));
+      }
+    }
+    i += (line ? line[0].length : 0) + 1;
+  }
+  return { methods, embeds, constraint };
+}
+
+/**
+ * Read the package, types, interfaces, methods, and functions of a Go file
+ */
+export function parseGoDeclarations(content: string): GoDeclarations {
+  const text = blankGoLiterals(content);
+  const declarations: GoDeclarations = { interfaces: [], types: [], methods: [], functions: [] };
+  const pkg = text.match(/^\s*package\s+(\w+)/m);
+  if (pkg) {
+    declarations.package = pkg[1];
+  }
+
+  // One type spec: Name [TypeParams] [=] TypeExpr
+  const readSpec = (offset: number): number => {
+    const spec = text.substring(offset).match(/^\s*([A-Za-z_]\w*)\s*/);
+    if (!spec) {
+      return offset + 1;
+    }
+    let i = offset + spec[0].length;
+    // Type parameters: "[T any]" directly after the name, not an array type "[4]byte"
+    if (text[i] === '[' && /^\[\s*[A-Za-z_]\w*\s+[^\]]/.test(text.substring(i))) {
+      i = matchBracket(text, i);
+    }
+    const rest = text.substring(i).match(/^\s*=?\s*/);
+    i += rest ? rest[0].length : 0;
+    const line = lineAt(text, offset + spec[0].indexOf(spec[1]));
+    const kind = text.substring(i).match(/^(interface|struct)\s*\{/);
+    if (kind) {
+      const open = i + kind[0].length - 1;
+      const close = matchBracket(text, open);
+      if (kind[1] === 'interface') {
+        declarations.interfaces.push({ name: spec[1], line, ...parseInterfaceBody(text.substring(open + 1, close - 1)) });
+      } else {
+        declarations.types.push({ name: spec[1], line, kind: 'struct' });
+      }
+      return close;
+    }
+    declarations.types.push({ name: spec[1], line, kind: 'other' });
+    const end = text.indexOf('\n', i);
+    return end === -1 ? text.length : end;
+  };
+
+  const typeDecl = /^type\b\s*/gm;
+  let match: RegExpExecArray | null;
+  while ((match = typeDecl.exec(text)) !== null) {
+    const start = match.index + match[0].length;
+    if (text[start] === '(') {
+      const close = matchBracket(text, start);
+      let i = start + 1;
+      while (i < close - 1) {
+        const next = text.substring(i, close - 1).search(/\S/);
+        if (next === -1) {
+          break;
+        }
+        i = readSpec(i + next);
+      }
+      typeDecl.lastIndex = close;
+    } else {
+      typeDecl.lastIndex = readSpec(start);
+    }
+  }
+
+  const methodDecl = /^func\s*\(\s*(?:[A-Za-z_]\w*\s+)?(\*?)\s*([A-Za-z_]\w*)\s*(?:\[[^\]]*\])?\s*\)\s*([A-Za-z_]\w*)\s*(?:\[[^\]]*\]\s*)?\(/gm;
+  while ((match = methodDecl.exec(text)) !== null) {
+    const open = match.index + match[0].length - 1;
+    const { signature } = readSignature(text, open);
+    declarations.methods.push({
+      receiver: match[2],
+      pointer: match[1] === '*',
+      name: match[3],
+      signature,
+      line: lineAt(text, match.index),
+    });
+  }
+
+  const functionDecl = /^func\s+([A-Za-z_]\w*)\s*(?:\[[^\]]*\]\s*)?\(/gm;
+  while ((match = functionDecl.exec(text)) !== null) {
+    const { signature } = readSignature(text, match.index + match[0].length - 1);
+    declarations.functions.push({ name: match[1], signature, line: lineAt(text, match.index) });
+  }
+  return declarations;
+}
+
+/**
+ * Read the import specs of a Go file, with the aliases they give packages
+ */
+export function parseGoImports(content: string): GoImport[] {
+  const text = blankGoLiterals(content);
+  const imports: GoImport[] = [];
+  // Literals are blanked in place, so their paths are read from the content at the same offsets
+  const readSpecs = (start: number, end: number) => {
+    const spec = /(?:([A-Za-z_]\w*|\.)[ \t]*)?("[^"\n]*"|`[^`]*`)/g;
+    spec.lastIndex = start;
+    let match: RegExpExecArray | null;
+    while ((match = spec.exec(text)) !== null && match.index < end) {
+      const quoted = content.substr(match.index + match[0].length - match[2].length, match[2].length);
+      const importPath = quoted.substring(1, quoted.length - 1).replace(/\\(.)/g, '$1');
+      imports.push({ path: importPath, ...(match[1] ? { alias: match[1] } : {}), line: lineAt(text, match.index) });
+    }
+  };
+  const importDecl = /^import\b[ \t]*/gm;
+  let match: RegExpExecArray | null;
+  while ((match = importDecl.exec(text)) !== null) {
+    const start = match.index + match[0].length;
+    if (text[start] === '(') {
+      const close = matchBracket(text, start);
+      readSpecs(start + 1, close);
+      importDecl.lastIndex = close;
+    } else {
+      const lineEnd = text.indexOf('\n', start);
+      readSpecs(start, lineEnd === -1 ? text.length : lineEnd);
+    }
+  }
+  return imports;
+}
+
+/**
+ * Name a Go package is assumed to have from its import path, as goimports assumes it
+ * The last element counts, without a major version (example.com/lib/v2 is lib),
+ * a gopkg.in version (yaml.v3 is yaml), a go- prefix, or anything from a - or .
+ */
+export function goPackageName(importPath: string): string {
+  const elements = importPath.split('/');
+  let last = elements.pop() ?? importPath;
+  if (/^v\d+$/.test(last) && elements.length > 0) {
+    last = elements.pop()!;
+  }
+  return last.replace(/^go-/, '').split(/[-.]/)[0] || last;
+}
@@ -0,0 +1,117 @@
+/**
+ * Tests for .proto symbols and generated-name cross-references
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { SymbolKind } from '../protocol/types';
+import { findWorkspaceSymbols, flattenDocumentSymbols } from '../tools/symbols';
+import { protoReferences } from '../tools/proto';
+import { generatedNames, goCamelCase, parseProto } from './proto';
+
+const PROTO = `syntax = "proto3";
+package acme.v1;
+
+import "google/protobuf/timestamp.proto";
+
+/* A user account.
+   message NotAMessage { } */
+message User {
+  string user_id = 1;
+  repeated string email_addresses = 2 [deprecated = true];
+  map<string, int32> quotas = 3;
+  oneof contact {
+    string phone = 4;
+  }
+  enum Role {
+    ROLE_UNSPECIFIED = 0;
+    ROLE_ADMIN = 1;
+  }
+  message Settings {
+    bool dark_mode = 1;
+  }
+}
+
+service UserService {
+  // Looks a user up
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc WatchUsers(WatchRequest) returns (stream User) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+}
+`;
+
+describe('proto symbols', () => {
+  it('parses messages, fields, enums, services, and RPCs', () => {
+    const parsed = parseProto(PROTO);
+    expect(parsed.package).toBe('acme.v1');
+    const symbols = flattenDocumentSymbols(parsed.symbols);
+    expect(symbols.map((symbol) => symbol.qualifiedName)).toEqual([
+      'User', 'User.user_id', 'User.email_addresses', 'User.quotas', 'User.phone', 'User.Role',
+      'User.Role.ROLE_UNSPECIFIED', 'User.Role.ROLE_ADMIN', 'User.Settings', 'User.Settings.dark_mode',
+      'UserService', 'UserService.GetUser', 'UserService.WatchUsers',
+    ]);
+    const byName = new Map(symbols.map((symbol) => [symbol.qualifiedName, symbol]));
+    expect(byName.get('User')!.kind).toBe(SymbolKind.Struct);
+    expect(byName.get('User')!.range.end.line).toBe(21);
+    expect(byName.get('User.email_addresses')!.detail).toBe('repeated string email_addresses = 2');
+    expect(byName.get('User.quotas')!.detail).toBe('map<string, int32> quotas = 3');
+    expect(byName.get('UserService.WatchUsers')!.detail).toBe('rpc WatchUsers(WatchRequest) returns (stream User)');
+    expect(byName.get('UserService.GetUser')!.selectionRange.start).toEqual({ line: 25, character: 6 });
+  });
+
+  it('maps proto symbols to generated Go and TypeScript names', () => {
+    expect(goCamelCase('email_addresses')).toBe('EmailAddresses');
+    const field = generatedNames({ path: ['User', 'Settings', 'dark_mode'], kinds: [SymbolKind.Struct, SymbolKind.Struct, SymbolKind.Field] });
+    expect(field.go).toContain('\\bGetDarkMode\\b');
+    expect(field.typescript).toContain('\\.darkMode\\b');
+    const value = generatedNames({ path: ['User', 'Role', 'ROLE_ADMIN'], kinds: [SymbolKind.Struct, SymbolKind.Enum, SymbolKind.EnumMember] });
+    expect(value.go).toEqual(['\\bUser_ROLE_ADMIN\\b']);
+    const rpc = generatedNames({ package: 'acme.v1', path: ['UserService', 'GetUser'], kinds: [SymbolKind.Interface, SymbolKind.Method] });
+    expect(new RegExp(rpc.go.join('|')).test('"/acme.v1.UserService/GetUser"')).toBe(true);
+    expect(new RegExp(rpc.requires!).test('client := NewUserServiceClient(conn)')).toBe(true);
+  });
+
+  it('finds generated code and usages of an RPC', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'proto-'));
+    try {
+      fs.mkdirSync(path.join(workspace, 'gen'));
+      fs.writeFileSync(path.join(workspace, 'user.proto'), PROTO);
+      fs.writeFileSync(path.join(workspace, 'gen', 'user_grpc.pb.go'),
+        '// Code generated by protoc-gen-go-grpc. DO NOT EDIT.\npackage gen\n\n' +
+        'type UserServiceClient interface {\n\tGetUser(ctx context.Context, in *GetUserRequest) (*User, error)\n}\n');
+      fs.writeFileSync(path.join(workspace, 'main.go'),
+        'package main\n\nfunc run(c gen.UserServiceClient) {\n\tc.GetUser(ctx, req)\n}\n');
+      // Same method name, unrelated to the service
+      fs.writeFileSync(path.join(workspace, 'cache.go'), 'package main\n\nfunc warm(s *Store) {\n\ts.GetUser(id)\n}\n');
+      fs.writeFileSync(path.join(workspace, 'client.ts'),
+        'import { UserServiceClient } from "./gen/user";\nawait client.getUser({ userId });\n');
+
+      const output = await protoReferences(workspace, 'UserService.GetUser');
+      expect(output).toContain('acme.v1.UserService.GetUser (Method: rpc GetUser(GetUserRequest) returns (User))');
+      expect(output).toContain('Defined at user.proto:26:7');
+      expect(output).toContain('Generated code (1 match(es) in 1 file(s))');
+      expect(output).toContain('gen/user_grpc.pb.go:5:2');
+      expect(output).toContain('Usages: 2');
+      expect(output).toContain('L4:C4: c.GetUser(ctx, req)');
+      expect(output).toContain('L2:C14: await client.getUser({ userId });');
+      expect(output).not.toContain('cache.go');
+      expect(await protoReferences(workspace, 'Missing')).toBe('No proto symbol named Missing');
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('serves workspace symbols of the workspace it is given, not the working directory', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'proto-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'user.proto'), PROTO);
+      const found = await findWorkspaceSymbols(undefined, workspace, 'UserService');
+      expect(found.map((sym) => sym.name)).toContain('UserService');
+      expect(process.cwd()).not.toBe(workspace);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
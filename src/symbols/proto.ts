@@ -0,0 +1,339 @@
+/**
+ * Protocol Buffers symbols and their generated names
+ * A tokenizer-based parser reads messages, enums, fields, services, and RPCs
+ * from .proto files. The generated-name rules map a proto symbol to the
+ * identifiers protoc-gen-go, protoc-gen-go-grpc, and the common TypeScript
+ * generators (ts-proto, protobuf-ts, grpc-js) emit for it, so usages can be
+ * found across the IDL boundary
+ */
+
+import { DocumentSymbol, SymbolKind } from '../protocol/types.js';
+import { escapeRegExp } from '../search/lexical.js';
+import { FileSymbolIndex } from './fileIndex.js';
+
+/**
+ * A .proto token with its 0-indexed position
+ */
+interface Token {
+  text: string;
+  line: number;
+  character: number;
+}
+
+/**
+ * A parsed .proto file
+ */
+export interface ProtoFile {
+  package?: string;
+  symbols: DocumentSymbol[];
+}
+
+/**
+ * Check if a file is a Protocol Buffers definition
+ */
+export function isProtoFile(filePath: string): boolean {
+  return filePath.endsWith('.proto');
+}
+
+/**
+ * Split .proto source into identifiers, numbers, strings, and punctuation,
+ * dropping comments
+ */
+function tokenize(content: string): Token[] {
+  const tokens: Token[] = [];
+  let line = 0;
+  let lineStart = 0;
+  let i = 0;
+  while (i < content.length) {
+    const ch = content[i];
+    if (ch === '\n') {
+      line++;
+      lineStart = ++i;
+      continue;
+    }
+    if (/\s/.test(ch)) {
+      i++;
+      continue;
+    }
+    if (content.startsWith('//', i)) {
+      while (i < content.length && content[i] !== '\n') {
+        i++;
+      }
+      continue;
+    }
+    if (content.startsWith('/*', i)) {
+      const end = content.indexOf('*/', i + 2);
+      const stop = end < 0 ? content.length : end + 2;
+      for (; i < stop; i++) {
+        if (content[i] === '\n') {
+          line++;
+          lineStart = i + 1;
+        }
+      }
+      continue;
+    }
+    const start = i;
+    if (ch === '"' || ch === "'") {
+      i++;
+      while (i < content.length && content[i] !== ch && content[i] !== '\n') {
+        i += content[i] === '\\' ? 2 : 1;
+      }
+      i++;
+    } else if (/[\w.]/.test(ch)) {
+      while (i < content.length && /[\w.]/.test(content[i])) {
+        i++;
+      }
+    } else {
+      i++;
+    }
+    tokens.push({ text: content.substring(start, i), line, character: start - lineStart });
+  }
+  return tokens;
+}
+
+/**
+ * Statements that carry no symbols and end at a semicolon
+ */
+const SKIPPED_STATEMENTS = new Set(['syntax', 'edition', 'import', 'option', 'reserved', 'extensions']);
+
+/**
+ * Field labels before the type
+ */
+const FIELD_LABELS = new Set(['optional', 'repeated', 'required']);
+
+/**
+ * Parse a .proto file into its package and symbol tree
+ * Messages are Structs, services Interfaces, RPCs Methods; oneof fields
+ * belong to the enclosing message
+ */
+export function parseProto(content: string): ProtoFile {
+  const tokens = tokenize(content);
+  let pos = 0;
+  let pkg: string | undefined;
+
+  const peek = (offset = 0): string | undefined => tokens[pos + offset]?.text;
+  // Skip to the end of a statement, over any bracketed options
+  const skipStatement = () => {
+    let depth = 0;
+    while (pos < tokens.length) {
+      const text = tokens[pos++].text;
+      if (text === '{' || text === '[' || text === '(') {
+        depth++;
+      } else if (text === '}' || text === ']' || text === ')') {
+        depth--;
+        if (depth === 0 && text === '}') {
+          return;
+        }
+      } else if (text === ';' && depth === 0) {
+        return;
+      }
+    }
+  };
+  const symbolAt = (token: Token, name: string, kind: SymbolKind, detail?: string): DocumentSymbol => ({
+    name,
+    detail,
+    kind,
+    range: { start: { line: token.line, character: token.character }, end: { line: token.line, character: token.character + name.length } },
+    selectionRange: { start: { line: token.line, character: token.character }, end: { line: token.line, character: token.character + name.length } },
+    children: [],
+  });
+  const endAt = (symbol: DocumentSymbol) => {
+    const last = tokens[pos - 1];
+    if (last) {
+      symbol.range.end = { line: last.line, character: last.character + last.text.length };
+    }
+  };
+
+  // Read declarations until the closing brace of the current block
+  const block = (kind: 'file' | 'message' | 'enum' | 'service', into: DocumentSymbol[]) => {
+    while (pos < tokens.length) {
+      const keyword = tokens[pos];
+      const text = keyword.text;
+      if (text === '}') {
+        pos++;
+        return;
+      }
+      if (text === ';') {
+        pos++;
+        continue;
+      }
+      if (text === 'package' && kind === 'file') {
+        pkg = peek(1);
+        skipStatement();
+        continue;
+      }
+      if (SKIPPED_STATEMENTS.has(text)) {
+        skipStatement();
+        continue;
+      }
+      if ((text === 'message' || text === 'enum' || text === 'service') && peek(2) === '{') {
+        const nameToken = tokens[pos + 1];
+        const symbolKind = text === 'message' ? SymbolKind.Struct : text === 'enum' ? SymbolKind.Enum : SymbolKind.Interface;
+        const symbol = symbolAt(nameToken, nameToken.text, symbolKind, `${text} ${nameToken.text}`);
+        symbol.range.start = { line: keyword.line, character: keyword.character };
+        pos += 3;
+        block(text, symbol.children!);
+        endAt(symbol);
+        into.push(symbol);
+        continue;
+      }
+      if (text === 'oneof' && peek(2) === '{' && kind === 'message') {
+        pos += 3;
+        block('message', into);
+        continue;
+      }
+      if (text === 'rpc' && kind === 'service') {
+        const nameToken = tokens[pos + 1];
+        const start = pos;
+        skipStatement();
+        const signature = tokens.slice(start, pos).map((token) => token.text)
+          .filter((part) => part !== ';').join(' ')
+          .replace(/\s*\{.*$/, '').replace(/\( /g, '(').replace(/ \)/g, ')').replace(/^(rpc \w+) \(/, '$1(');
+        const symbol = symbolAt(nameToken, nameToken.text, SymbolKind.Method, signature);
+        symbol.range.start = { line: keyword.line, character: keyword.character };
+        endAt(symbol);
+        into.push(symbol);
+        continue;
+      }
+      if (kind === 'enum' && peek(1) === '=') {
+        into.push(symbolAt(keyword, text, SymbolKind.EnumMember, `${text} = ${peek(2)}`));
+        skipStatement();
+        continue;
+      }
+      if (kind === 'message' && text !== 'extend') {
+        // [label] type name = number, or map<K, V> name = number
+        const start = pos;
+        if (FIELD_LABELS.has(text)) {
+          pos++;
+        }
+        let type = peek() ?? '';
+        if (type === 'map' && peek(1) === '<') {
+          const close = tokens.findIndex((token, index) => index > pos && token.text === '>');
+          type = close > 0 ? tokens.slice(pos, close + 1).map((token) => token.text).join('').replace(/,/g, ', ') : type;
+          pos = close > 0 ? close + 1 : pos + 1;
+        } else {
+          pos++;
+        }
+        const nameToken = tokens[pos];
+        if (nameToken && /^[A-Za-z_]\w*$/.test(nameToken.text) && peek(1) === '=') {
+          const label = FIELD_LABELS.has(text) ? `${text} ` : '';
+          into.push(symbolAt(nameToken, nameToken.text, SymbolKind.Field, `${label}${type} ${nameToken.text} = ${peek(2)}`));
+        }
+        pos = start;
+        skipStatement();
+        continue;
+      }
+      // extend blocks, groups, and anything unrecognized
+      skipStatement();
+    }
+  };
+
+  const symbols: DocumentSymbol[] = [];
+  block('file', symbols);
+  return { package: pkg, symbols };
+}
+
+/**
+ * Symbol tree of a .proto file
+ */
+export function parseProtoSymbols(content: string): DocumentSymbol[] {
+  return parseProto(content).symbols;
+}
+
+/**
+ * A proto symbol located by its path from the file's top level
+ */
+export interface ProtoSymbolRef {
+  package?: string;
+  // Names from the outermost message or service down to the symbol
+  path: string[];
+  // Kinds along the path, outermost first
+  kinds: SymbolKind[];
+}
+
+/**
+ * Identifier patterns the generators emit for a proto symbol, by language
+ */
+export interface GeneratedNames {
+  go: string[];
+  typescript: string[];
+  // Regex that must also match somewhere in the file (RPC names are only
+  // usages in files that mention their service)
+  requires?: string;
+}
+
+/**
+ * foo_bar to FooBar, as protoc-gen-go names fields
+ */
+export function goCamelCase(name: string): string {
+  return name.replace(/(^|_)([a-z0-9])/g, (_match, _sep, ch: string) => ch.toUpperCase()).replace(/_/g, '');
+}
+
+/**
+ * foo_bar to fooBar, as the TypeScript generators name fields
+ */
+export function lowerCamelCase(name: string): string {
+  const camel = goCamelCase(name);
+  return camel[0].toLowerCase() + camel.substring(1);
+}
+
+/**
+ * Generated identifier patterns (regex sources) for a proto symbol
+ */
+export function generatedNames(ref: ProtoSymbolRef): GeneratedNames {
+  const kind = ref.kinds[ref.kinds.length - 1];
+  const name = ref.path[ref.path.length - 1];
+  // Nested messages and enums are joined with underscores by every generator
+  const typeName = (depth: number) => ref.path.slice(0, depth).join('_');
+
+  switch (kind) {
+    case SymbolKind.Struct:
+    case SymbolKind.Enum: {
+      const generated = `\\b${typeName(ref.path.length)}\\b`;
+      return { go: [generated], typescript: [generated] };
+    }
+    case SymbolKind.Field: {
+      const goField = goCamelCase(name);
+      return {
+        go: [`\\bGet${goField}\\b`, `\\.${goField}\\b`],
+        typescript: [`\\.${lowerCamelCase(name)}\\b`, `\\bget${goField}\\b`, `\\bset${goField}\\b`],
+      };
+    }
+    case SymbolKind.EnumMember: {
+      // Go prefixes values with the enclosing message, or the enum at the top level
+      const enumDepth = ref.path.length - 1;
+      const goPrefix = enumDepth > 1 ? typeName(enumDepth - 1) : typeName(enumDepth);
+      return {
+        go: [`\\b${goPrefix}_${name}\\b`],
+        typescript: [`\\b${typeName(enumDepth)}\\.${name}\\b`, `\\b${typeName(enumDepth)}_${name}\\b`],
+      };
+    }
+    case SymbolKind.Interface: {
+      const service = `\\b(New|Register|Unimplemented)?${name}(Client|Server|Service|ClientImpl|_ServiceDesc)?\\b`;
+      return { go: [service], typescript: [service] };
+    }
+    case SymbolKind.Method: {
+      const service = ref.path[ref.path.length - 2];
+      const fullName = `/${ref.package ? `${ref.package}.` : ''}${service}/${name}`;
+      return {
+        go: [`\\b${name}\\(`, `\\b${service}_${name}_FullMethodName\\b`, escapeRegExp(fullName)],
+        typescript: [`\\b${lowerCamelCase(name)}\\(`, `\\b${name}\\(`, escapeRegExp(fullName)],
+        requires: `\\b(New|Register|Unimplemented)?${service}(Client|Server|Service|ClientImpl|_ServiceDesc)?\\b`,
+      };
+    }
+    default:
+      return { go: [], typescript: [] };
+  }
+}
+
+let sharedInstance: FileSymbolIndex | undefined;
+
+/**
+ * Process-wide index of the .proto files in a workspace, replaced when another workspace is asked for
+ */
+export function sharedProtoSymbols(workspaceDir: string): FileSymbolIndex {
+  if (!sharedInstance || sharedInstance.getWorkspaceDir() !== workspaceDir) {
+    sharedInstance = new FileSymbolIndex(workspaceDir, isProtoFile, parseProtoSymbols);
+  }
+  return sharedInstance;
+}
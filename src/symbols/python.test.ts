@@ -0,0 +1,128 @@
+/**
+ * Tests for the built-in Python symbol parser
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { SymbolKind } from '../protocol/types';
+import { PythonSymbolIndex, isPythonServer, parsePythonSymbols, sharedPythonSymbols } from './python';
+
+const SOURCE = [
+  '"""Module docstring',
+  'class NotAClass: mentioned in the docstring',
+  '"""',
+  'import os',
+  '',
+  'MAX_RETRIES = 3',
+  'default_timeout: float = 1.5',
+  '',
+  '',
+  '@dataclass',
+  'class Client(Base):',
+  '    """A client."""',
+  '    retries: int = 0',
+  '',
+  '    def __init__(self, url: str,',
+  '                 timeout: float = 1.0):',
+  '        self.url = url',
+  '        local = 1',
+  '',
+  '    @property',
+  '    def name(self) -> str:',
+  '        return "x"  # def fake(): in a comment',
+  '',
+  '    @name.setter',
+  '    def name(self, value):',
+  '        pass',
+  '',
+  '    async def fetch(self, path):',
+  '        def helper():',
+  '            return path',
+  '        return helper()',
+  '',
+  '',
+  'if TYPE_CHECKING:',
+  '    def typed(x): ...',
+  '',
+  'def main(argv=(',
+  '    "a",',
+  ')):',
+  '    else_value = 1',
+  '    return 0',
+].join('\n');
+
+describe('Python symbols', () => {
+  it('should find classes, functions, methods, and assignments', () => {
+    const symbols = parsePythonSymbols(SOURCE);
+    expect(symbols.map((s) => [s.name, s.kind])).toEqual([
+      ['MAX_RETRIES', SymbolKind.Constant],
+      ['default_timeout', SymbolKind.Variable],
+      ['Client', SymbolKind.Class],
+      ['typed', SymbolKind.Function],
+      ['main', SymbolKind.Function],
+    ]);
+
+    const client = symbols[2];
+    expect(client.range.start.line).toBe(10);
+    expect(client.range.end.line).toBe(30);
+    expect(client.selectionRange.start).toEqual({ line: 10, character: 6 });
+    expect(client.detail).toBe('class Client(Base)');
+    expect(client.children!.map((s) => [s.name, s.kind])).toEqual([
+      ['retries', SymbolKind.Field],
+      ['__init__', SymbolKind.Constructor],
+      ['name', SymbolKind.Property],
+      ['fetch', SymbolKind.Method],
+    ]);
+    expect(client.children![1].detail).toBe('def __init__(self, url: str, timeout: float = 1.0)');
+    expect(client.children![3].children!.map((s) => s.name)).toEqual(['helper']);
+
+    // Bracketed continuation lines stay in the signature, and locals are not symbols
+    const main = symbols[4];
+    expect(main.range).toEqual({ start: { line: 36, character: 0 }, end: { line: 40, character: 12 } });
+    expect(main.children).toEqual([]);
+  });
+
+  it('should serve workspace symbols with their containers', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'python-symbols-'));
+    try {
+      fs.mkdirSync(path.join(workspace, 'pkg'));
+      fs.writeFileSync(path.join(workspace, 'pkg', 'client.py'), SOURCE);
+      fs.writeFileSync(path.join(workspace, 'pkg', 'util.py'), 'def fetch_all():\n    pass\n');
+
+      const index = new PythonSymbolIndex(workspace);
+      const found = await index.workspaceSymbols('fetch');
+      expect(found.map((s) => [s.name, s.containerName])).toEqual([
+        ['fetch', 'Client'],
+        ['fetch_all', undefined],
+      ]);
+      expect(found[0].location.uri).toMatch(/pkg\/client\.py$/);
+      expect((await index.workspaceSymbols('')).length).toBe(11);
+
+      // The walk is reused until the files are said to have changed
+      fs.writeFileSync(path.join(workspace, 'pkg', 'extra.py'), 'def fetch_more():\n    pass\n');
+      expect((await index.workspaceSymbols('fetch_more')).length).toBe(0);
+      index.filesChanged();
+      expect((await index.workspaceSymbols('fetch_more')).length).toBe(1);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should keep the shared index for the workspace it is asked for', () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'python-symbols-'));
+    try {
+      expect(sharedPythonSymbols(workspace).getWorkspaceDir()).toBe(workspace);
+      expect(sharedPythonSymbols(workspace)).toBe(sharedPythonSymbols(workspace));
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+
+  it('should recognize Python language servers', () => {
+    expect(isPythonServer('/usr/local/bin/pyright-langserver')).toBe(true);
+    expect(isPythonServer('pylsp')).toBe(true);
+    expect(isPythonServer('jedi-language-server')).toBe(true);
+    expect(isPythonServer('gopls')).toBe(false);
+  });
+});
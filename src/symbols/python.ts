@@ -0,0 +1,270 @@
+/**
+ * Python symbol extraction without a language server
+ * A line-based parser finds classes, functions, methods, and module and
+ * class level assignments from indentation, skipping strings, comments, and
+ * bracketed continuation lines. It backs document and workspace symbols for
+ * Python files when no Python language server is attached
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { DocumentSymbol, SymbolKind } from '../protocol/types.js';
+import { FileSymbolIndex } from './fileIndex.js';
+
+/**
+ * Files marking a directory as a Python project
+ */
+const PYTHON_MANIFESTS = ['pyproject.toml', 'setup.py', 'setup.cfg', 'requirements.txt', 'Pipfile'];
+
+/**
+ * Language servers that provide Python symbols themselves
+ */
+const PYTHON_SERVER = /pyright|pylsp|pyls|jedi/i;
+
+/**
+ * Keywords that can be followed by a colon, so "else: x = 1" is not an assignment to "else"
+ */
+const KEYWORDS = new Set(['else', 'try', 'finally', 'except', 'lambda', 'match', 'case', 'while', 'for', 'with', 'if', 'elif']);
+
+/**
+ * Check if a file is Python source or a stub
+ */
+export function isPythonFile(filePath: string): boolean {
+  return /\.pyi?$/.test(filePath);
+}
+
+/**
+ * Check if a language server command provides Python symbols
+ */
+export function isPythonServer(command: string): boolean {
+  return PYTHON_SERVER.test(path.basename(command));
+}
+
+/**
+ * Check if a workspace looks like a Python project: a Python manifest or a
+ * .py file at its root
+ */
+export function isPythonWorkspace(workspaceDir: string): boolean {
+  if (PYTHON_MANIFESTS.some((name) => fs.existsSync(path.join(workspaceDir, name)))) {
+    return true;
+  }
+  try {
+    return fs.readdirSync(workspaceDir).some((name) => isPythonFile(name));
+  } catch (err) {
+    return false;
+  }
+}
+
+/**
+ * A class or def whose body is still being read
+ */
+interface OpenScope {
+  indent: number;
+  symbol: DocumentSymbol;
+  isClass: boolean;
+}
+
+/**
+ * Scan state carried across physical lines
+ */
+interface LineState {
+  // Open triple-quoted string delimiter
+  triple?: string;
+  // Open bracket depth
+  depth: number;
+  // Previous line ended with a backslash
+  continued: boolean;
+}
+
+/**
+ * Advance the scan state over one physical line
+ * Returns whether the line has code outside comments and strings
+ */
+function scanLine(line: string, state: LineState): boolean {
+  let hasCode = false;
+  let i = 0;
+  state.continued = false;
+  while (i < line.length) {
+    if (state.triple) {
+      const end = line.indexOf(state.triple, i);
+      if (end < 0) {
+        return true;
+      }
+      i = end + 3;
+      state.triple = undefined;
+      continue;
+    }
+    const ch = line[i];
+    if (ch === '#') {
+      break;
+    }
+    if (ch === '"' || ch === "'") {
+      hasCode = true;
+      if (line.startsWith(ch.repeat(3), i)) {
+        state.triple = ch.repeat(3);
+        i += 3;
+        continue;
+      }
+      // Single-quoted strings end on the same line
+      i++;
+      while (i < line.length && line[i] !== ch) {
+        i += line[i] === '\\' ? 2 : 1;
+      }
+      i++;
+      continue;
+    }
+    if ('([{'.includes(ch)) {
+      state.depth++;
+    } else if (')]}'.includes(ch)) {
+      state.depth = Math.max(0, state.depth - 1);
+    }
+    if (ch === '\\' && i === line.length - 1) {
+      state.continued = true;
+    } else if (ch !== ' ' && ch !== '\t' && ch !== '\r') {
+      hasCode = true;
+    }
+    i++;
+  }
+  return hasCode || !!state.triple;
+}
+
+/**
+ * Extract the symbols of a Python file as a symbol tree
+ */
+export function parsePythonSymbols(content: string): DocumentSymbol[] {
+  const lines = content.split('\n');
+  const roots: DocumentSymbol[] = [];
+  const stack: OpenScope[] = [];
+  const state: LineState = { depth: 0, continued: false };
+  let decorators: string[] = [];
+  let lastCode = { line: 0, character: 0 };
+
+  const close = (indent: number) => {
+    while (stack.length > 0 && stack[stack.length - 1].indent >= indent) {
+      stack.pop()!.symbol.range.end = { ...lastCode };
+    }
+  };
+  const add = (symbol: DocumentSymbol) => {
+    const parent = stack[stack.length - 1];
+    if (parent) {
+      parent.symbol.children!.push(symbol);
+    } else {
+      roots.push(symbol);
+    }
+  };
+  const seen = (name: string) => {
+    const siblings = stack.length > 0 ? stack[stack.length - 1].symbol.children! : roots;
+    return siblings.some((sibling) => sibling.name === name);
+  };
+
+  for (let lineNo = 0; lineNo < lines.length; lineNo++) {
+    const line = lines[lineNo].replace(/\r$/, '');
+    const startsLogicalLine = !state.triple && state.depth === 0 && !state.continued;
+    const hasCode = scanLine(line, state);
+    if (!hasCode) {
+      continue;
+    }
+
+    if (startsLogicalLine) {
+      const indent = line.length - line.trimStart().length;
+      const text = line.trimStart();
+      close(indent);
+      const parent = stack[stack.length - 1];
+      const inFunction = parent !== undefined && !parent.isClass;
+
+      const decorator = text.match(/^@\s*([\w.]+)/);
+      const def = text.match(/^(?:async\s+)?def\s+([A-Za-z_]\w*)/);
+      const cls = text.match(/^class\s+([A-Za-z_]\w*)/);
+      const assignment = text.match(/^([A-Za-z_]\w*)\s*(?::[^=]*)?=(?!=)|^([A-Za-z_]\w*)\s*:\s*[^=]+$/);
+
+      if (decorator) {
+        decorators.push(decorator[1]);
+      } else if (def || cls) {
+        const name = (def ?? cls)![1];
+        const column = indent + text.indexOf(name, text.indexOf(def ? 'def' : 'class') + 3);
+        let kind: SymbolKind;
+        if (cls) {
+          kind = SymbolKind.Class;
+        } else if (parent?.isClass) {
+          kind = name === '__init__'
+            ? SymbolKind.Constructor
+            : decorators.some((d) => d === 'property' || /\.(setter|getter|deleter)$/.test(d))
+              ? SymbolKind.Property
+              : SymbolKind.Method;
+        } else {
+          kind = SymbolKind.Function;
+        }
+        const symbol: DocumentSymbol = {
+          name,
+          detail: signature(lines, lineNo),
+          kind,
+          range: { start: { line: lineNo, character: indent }, end: { line: lineNo, character: line.length } },
+          selectionRange: { start: { line: lineNo, character: column }, end: { line: lineNo, character: column + name.length } },
+          children: [],
+        };
+        // A property's setter repeats its name
+        if (kind !== SymbolKind.Property || !seen(name)) {
+          add(symbol);
+        }
+        stack.push({ indent, symbol, isClass: !!cls });
+        decorators = [];
+      } else {
+        decorators = [];
+        const name = assignment?.[1] ?? assignment?.[2];
+        if (name && !KEYWORDS.has(name) && !inFunction && !seen(name)) {
+          const kind = parent?.isClass
+            ? SymbolKind.Field
+            : /^[A-Z][A-Z0-9_]*$/.test(name) ? SymbolKind.Constant : SymbolKind.Variable;
+          const range = {
+            start: { line: lineNo, character: indent },
+            end: { line: lineNo, character: indent + name.length },
+          };
+          add({ name, kind, range, selectionRange: range, children: [] });
+        }
+      }
+    }
+    lastCode = { line: lineNo, character: line.length };
+  }
+  close(0);
+
+  return roots;
+}
+
+/**
+ * The def or class line, joined across bracketed lines, without the trailing colon
+ */
+function signature(lines: string[], start: number): string {
+  const state: LineState = { depth: 0, continued: false };
+  const parts: string[] = [];
+  for (let i = start; i < Math.min(lines.length, start + 20); i++) {
+    parts.push(lines[i].trim());
+    scanLine(lines[i], state);
+    if (state.depth === 0 && !state.continued) {
+      break;
+    }
+  }
+  const text = parts.join(' ').replace(/\s+/g, ' ').replace(/\(\s+/g, '(').replace(/\s+\)/g, ')');
+  const colon = text.lastIndexOf(':');
+  return (colon > 0 ? text.substring(0, colon) : text).trim();
+}
+
+/**
+ * Document and workspace symbols of the Python files in a workspace
+ */
+export class PythonSymbolIndex extends FileSymbolIndex {
+  constructor(workspaceDir: string) {
+    super(workspaceDir, isPythonFile, parsePythonSymbols);
+  }
+}
+
+let sharedInstance: PythonSymbolIndex | undefined;
+
+/**
+ * Process-wide index for a workspace, replaced when another workspace is asked for
+ */
+export function sharedPythonSymbols(workspaceDir: string): PythonSymbolIndex {
+  if (!sharedInstance || sharedInstance.getWorkspaceDir() !== workspaceDir) {
+    sharedInstance = new PythonSymbolIndex(workspaceDir);
+  }
+  return sharedInstance;
+}
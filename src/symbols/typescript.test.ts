@@ -0,0 +1,77 @@
+/**
+ * Tests for the built-in TypeScript declaration parser
+ */
+
+import { SymbolKind } from '../protocol/types';
+import { blankJsLiterals, parseTypeScriptDeclarations } from './typescript';
+
+const SOURCE = [
+  'import { x } from "y";',
+  '// function commented() {}',
+  'export interface Options {',
+  '  path?: string;',
+  '  run(a: string): Promise<void>;',
+  '}',
+  '',
+  "export type Mode = 'a' | 'b';",
+  '',
+  '@Component({ selector: "x" })',
+  'export class Server<T> extends Base implements Handler {',
+  '  private readonly items: Map<string, T> = new Map();',
+  '  #secret = 1;',
+  '  constructor(private port: number) {',
+  '    super();',
+  '  }',
+  '',
+  '  @Get()',
+  '  async handle(req: Request, res?: Response): Promise<void> {',
+  '    if (x) { y("}"); }',
+  '  }',
+  '}',
+  '',
+  'export function load(path: string,',
+  '  opts: Options = {}): { a: string } {',
+  '  return { a: "}" };',
+  '}',
+  '',
+  'export const run = async (a: number): Promise<string> => {',
+  '  return "x";',
+  '};',
+  'const LIMIT: number = 3;',
+  'if (LIMIT > 1) {',
+  '  function inner() {}',
+  '}',
+].join('\n');
+
+describe('TypeScript declarations', () => {
+  it('should blank comments and literal contents', () => {
+    expect(blankJsLiterals('a("{", `}`) // }')).toBe('a(" ", ` `)     ');
+  });
+
+  it('should read top-level declarations with signatures', () => {
+    const symbols = parseTypeScriptDeclarations(SOURCE);
+    expect(symbols.map((s) => [s.name, s.kind, s.detail, s.range.start.line])).toEqual([
+      ['Options', SymbolKind.Interface, 'Options', 2],
+      ['Mode', SymbolKind.TypeParameter, "Mode = 'a' | 'b'", 7],
+      ['Server', SymbolKind.Class, 'Server<T> extends Base implements Handler', 10],
+      ['load', SymbolKind.Function, 'load(path: string, opts: Options = {}): { a: string }', 23],
+      ['run', SymbolKind.Function, 'run(a: number): Promise<string>', 28],
+      ['LIMIT', SymbolKind.Constant, 'LIMIT: number', 31],
+    ]);
+    expect(symbols[2].range.end.line).toBe(21);
+  });
+
+  it('should read class and interface members', () => {
+    const symbols = parseTypeScriptDeclarations(SOURCE);
+    expect(symbols[0].children!.map((c) => [c.name, c.kind, c.detail])).toEqual([
+      ['path', SymbolKind.Property, 'path?: string'],
+      ['run', SymbolKind.Method, 'run(a: string): Promise<void>'],
+    ]);
+    // Private # members are not listed
+    expect(symbols[2].children!.map((c) => [c.name, c.kind, c.detail])).toEqual([
+      ['items', SymbolKind.Property, 'items: Map<string, T>'],
+      ['constructor', SymbolKind.Constructor, 'constructor(private port: number)'],
+      ['handle', SymbolKind.Method, 'handle(req: Request, res?: Response): Promise<void>'],
+    ]);
+  });
+});
@@ -0,0 +1,299 @@
+/**
+ * TypeScript and JavaScript declarations without a language server
+ * Reads top-level functions, classes, interfaces, type aliases, enums, and
+ * variables, and the members of classes and interfaces, with their
+ * signatures, so two versions of a file's API can be compared
+ */
+
+import { DocumentSymbol, SymbolKind } from '../protocol/types.js';
+
+/**
+ * Check if a file is TypeScript or JavaScript
+ */
+export function isTypeScriptFile(filePath: string): boolean {
+  return /\.(?:[cm]?[jt]s|[jt]sx)$/.test(filePath);
+}
+
+/**
+ * Replace comments and the contents of string and template literals with
+ * spaces, keeping line breaks, so braces and parentheses can be matched
+ */
+export function blankJsLiterals(content: string): string {
+  let out = '';
+  let i = 0;
+  while (i < content.length) {
+    const ch = content[i];
+    if (content.startsWith('//', i)) {
+      const end = content.indexOf('\n', i);
+      const stop = end === -1 ? content.length : end;
+      out += ' '.repeat(stop - i);
+      i = stop;
+    } else if (content.startsWith('/*', i)) {
+      const end = content.indexOf('*/', i + 2);
+      const stop = end === -1 ? content.length : end + 2;
+      out += content.substring(i, stop).replace(/[^\n]/g, ' ');
+      i = stop;
+    } else if (ch === '"' || ch === '\'' || ch === '`') {
+      let j = i + 1;
+      while (j < content.length && content[j] !== ch && (ch === '`' || content[j] !== '\n')) {
+        j += content[j] === '\\' ? 2 : 1;
+      }
+      const closed = j < content.length && content[j] === ch;
+      out += ch + content.substring(i + 1, Math.min(j, content.length)).replace(/[^\n]/g, ' ') + (closed ? ch : '');
+      i = closed ? j + 1 : j;
+    } else {
+      out += ch;
+      i++;
+    }
+  }
+  return out;
+}
+
+/**
+ * Top-level declaration: modifiers, keyword, and name
+ */
+const DECLARATION = /^(?:export\s+(?:default\s+)?)?(?:declare\s+)?(?:abstract\s+)?(?:async\s+)?(function\s*\*?|class|interface|type|const\s+enum|enum|const|let|var|namespace)\s+([A-Za-z_$][\w$]*)/;
+
+/**
+ * Class or interface member: modifiers, then the name and what follows it
+ */
+const MEMBER = /^(?:(?:public|private|protected|static|readonly|abstract|override|declare|async|get|set|accessor)\s+)*(#?[A-Za-z_$][\w$]*)\s*[?!]?\s*(<|\(|:|=|;|\n|$)/;
+
+const KINDS: Record<string, SymbolKind> = {
+  function: SymbolKind.Function,
+  class: SymbolKind.Class,
+  interface: SymbolKind.Interface,
+  type: SymbolKind.TypeParameter,
+  enum: SymbolKind.Enum,
+  const: SymbolKind.Constant,
+  let: SymbolKind.Variable,
+  var: SymbolKind.Variable,
+  namespace: SymbolKind.Namespace,
+};
+
+/**
+ * Offset after the bracket closing the one at start, or the end of the text
+ * Type argument brackets (<>) ignore the > of arrows
+ */
+function matchBracket(text: string, start: number): number {
+  const open = text[start];
+  const close = ({ '(': ')', '[': ']', '{': '}', '<': '>' } as Record<string, string>)[open];
+  let depth = 0;
+  for (let i = start; i < text.length; i++) {
+    if (text[i] === open) {
+      depth++;
+    } else if (text[i] === close && !(close === '>' && text[i - 1] === '=')) {
+      depth--;
+      if (depth === 0) {
+        return i + 1;
+      }
+    } else if (open === '<' && (text[i] === ';' || text[i] === '{' || text[i] === ')')) {
+      return i; // A comparison, not type arguments
+    }
+  }
+  return text.length;
+}
+
+/**
+ * Last non-space character before an offset
+ */
+function lastChar(text: string, offset: number): string {
+  for (let i = offset - 1; i >= 0; i--) {
+    if (!/\s/.test(text[i])) {
+      return text[i];
+    }
+  }
+  return '';
+}
+
+/**
+ * Check if a line break continues the statement: an operator before it, or one after it
+ */
+function continues(text: string, offset: number): boolean {
+  const next = text.substring(offset).match(/^\s*(\S)/)?.[1];
+  return '=|&,:(<'.includes(lastChar(text, offset)) || next === '|' || next === '&' || next === '.' || next === '?';
+}
+
+/**
+ * End of a declaration's header: where its body, initializer (with
+ * stopAtEquals), or statement starts or ends; brackets are skipped
+ */
+function headerEnd(text: string, start: number, stopAtEquals: boolean): number {
+  let i = start;
+  while (i < text.length) {
+    const ch = text[i];
+    if (ch === '(' || ch === '[') {
+      i = matchBracket(text, i);
+    } else if (ch === '<' && /[\w$]/.test(text[i - 1] ?? '')) {
+      i = matchBracket(text, i);
+    } else if (ch === '{') {
+      // An object type after ":", "|", "&", "," or "=>" is part of the header
+      const before = lastChar(text, i);
+      if (!':|&,<'.includes(before) && !text.substring(start, i).trimEnd().endsWith('=>') || before === '') {
+        return i;
+      }
+      i = matchBracket(text, i);
+    } else if (ch === ';' || ch === '}' || (ch === '\n' && !continues(text, i))) {
+      return i;
+    } else if (stopAtEquals && ch === '=' && text[i + 1] !== '>' && text[i + 1] !== '=' && text[i - 1] !== '!' && text[i - 1] !== '=') {
+      return i;
+    } else {
+      i++;
+    }
+  }
+  return i;
+}
+
+/**
+ * End of the statement from an offset, brackets skipped
+ */
+function statementEnd(text: string, start: number): number {
+  let i = start;
+  while (i < text.length) {
+    const ch = text[i];
+    if (ch === '(' || ch === '[' || ch === '{') {
+      i = matchBracket(text, i);
+    } else if (ch === ';') {
+      return i + 1;
+    } else if (ch === '}' || (ch === '\n' && !continues(text, i))) {
+      return i;
+    } else {
+      i++;
+    }
+  }
+  return i;
+}
+
+/**
+ * Collapsed source text of a range; literals keep their contents, as in "'a' | 'b'"
+ */
+function collapse(text: string, start: number, end: number): string {
+  return text.substring(start, end).replace(/\s+/g, ' ').trim();
+}
+
+/**
+ * 0-indexed line and column of an offset
+ */
+function positionAt(text: string, offset: number): { line: number; character: number } {
+  let line = 0;
+  let lineStart = 0;
+  for (let i = 0; i < offset; i++) {
+    if (text[i] === '\n') {
+      line++;
+      lineStart = i + 1;
+    }
+  }
+  return { line, character: offset - lineStart };
+}
+
+function symbol(text: string, name: string, kind: SymbolKind, detail: string, nameAt: number, end: number): DocumentSymbol {
+  const selection = positionAt(text, nameAt);
+  return {
+    name,
+    detail,
+    kind,
+    range: { start: selection, end: positionAt(text, end) },
+    selectionRange: { start: selection, end: { line: selection.line, character: selection.character + name.length } },
+    children: [],
+  };
+}
+
+/**
+ * Members of a class or interface body, from after its { to its }
+ */
+function readMembers(text: string, source: string, start: number, close: number): DocumentSymbol[] {
+  const members: DocumentSymbol[] = [];
+  const seen = new Set<string>();
+  let i = start;
+  while (i < close) {
+    const next = text.substring(i, close).search(/[^\s;,]/);
+    if (next === -1) {
+      break;
+    }
+    const at = i + next;
+    const member = MEMBER.exec(text.substring(at, close));
+    if (!member || text[at] === '@' || text[at] === '[') {
+      // Decorators, index signatures, and anything unrecognized
+      i = Math.max(at + 1, text[at] === '[' ? matchBracket(text, at) : statementEnd(text, at));
+      continue;
+    }
+    const name = member[1];
+    const nameAt = at + member[0].lastIndexOf(name, member[0].length - member[2].length);
+    const isMethod = member[2] === '(' || member[2] === '<';
+    let end = headerEnd(text, nameAt, !isMethod);
+    const detail = collapse(source, nameAt, end);
+    if (text[end] === '{') {
+      end = matchBracket(text, end);
+    } else if (text[end] === '=') {
+      end = statementEnd(text, end + 1);
+    }
+    // Overloads and accessor pairs repeat the name; private # members are not API
+    const key = `${name}:${isMethod}`;
+    if (!name.startsWith('#') && !seen.has(key)) {
+      seen.add(key);
+      const kind = name === 'constructor' ? SymbolKind.Constructor : isMethod ? SymbolKind.Method : SymbolKind.Property;
+      members.push(symbol(text, name, kind, detail, nameAt, end));
+    }
+    i = Math.max(end, at + 1);
+  }
+  return members;
+}
+
+/**
+ * Extract the declarations of a TypeScript or JavaScript file as a symbol tree
+ * Details are signatures with whitespace collapsed: "load(path: string): Config"
+ * for functions and methods, the heritage clause for classes, the aliased type
+ * for type aliases, and the type or arrow signature for variables and properties
+ */
+export function parseTypeScriptDeclarations(content: string): DocumentSymbol[] {
+  const text = blankJsLiterals(content);
+  const symbols: DocumentSymbol[] = [];
+  let i = 0;
+  while (i < text.length) {
+    const next = text.substring(i).search(/[^\s;]/);
+    if (next === -1) {
+      break;
+    }
+    const at = i + next;
+    const match = DECLARATION.exec(text.substring(at, at + 200));
+    if (!match) {
+      i = Math.max(at + 1, statementEnd(text, at));
+      continue;
+    }
+    const keyword = match[1].replace(/\s*\*$/, '').replace(/^const\s+enum$/, 'enum');
+    const name = match[2];
+    const nameAt = at + match[0].length - name.length;
+    const isVariable = keyword === 'const' || keyword === 'let' || keyword === 'var';
+    let end = headerEnd(text, nameAt, isVariable || keyword === 'type');
+    let detail = collapse(content, nameAt, end);
+    let kind = KINDS[keyword];
+    let children: DocumentSymbol[] = [];
+    if (text[end] === '=') {
+      const valueAt = end + 1;
+      end = statementEnd(text, valueAt);
+      const value = collapse(content, valueAt, end).replace(/;$/, '');
+      if (keyword === 'type') {
+        detail = `${detail} = ${value}`;
+      } else {
+        // Arrow functions and function expressions: their parameters and return type
+        const fn = value.match(/^(?:async\s+)?(?:function\b\s*\*?\s*[\w$]*\s*)?(<[^>]*>)?(\([^]*?\)(?:\s*:\s*[^=]+?)?)\s*(?:=>|\{)/) ??
+          value.match(/^(?:async\s+)?()([A-Za-z_$][\w$]*)\s*=>/);
+        if (fn) {
+          kind = SymbolKind.Function;
+          detail = `${detail}${fn[1] ?? ''}${fn[2].startsWith('(') ? fn[2] : `(${fn[2]})`}`;
+        }
+      }
+    } else if (text[end] === '{') {
+      const close = matchBracket(text, end);
+      if (keyword === 'class' || keyword === 'interface') {
+        children = readMembers(text, content, end + 1, close - 1);
+      }
+      end = close;
+    }
+    const sym = symbol(text, name, kind, detail, nameAt, end);
+    sym.children = children;
+    symbols.push(sym);
+    i = Math.max(end, at + 1);
+  }
+  return symbols;
+}
@@ -0,0 +1,249 @@
+/**
+ * API surface tool - list exported symbols of a package or module
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { SymbolKind, SymbolKindNames } from '../protocol/types.js';
+import { WorkspaceFile, walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { detectLanguageId, isSourceFile, isTestFile } from '../workspace/language.js';
+import { getFileSymbols, FlatSymbol } from './symbols.js';
+import { getDocComment } from './utilities.js';
+import { readFileText } from '../workspace/overlay.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Symbol kinds that make up an API surface
+ */
+export const TOP_LEVEL_KINDS = new Set<SymbolKind>([
+  SymbolKind.Module,
+  SymbolKind.Namespace,
+  SymbolKind.Class,
+  SymbolKind.Interface,
+  SymbolKind.Struct,
+  SymbolKind.Enum,
+  SymbolKind.Function,
+  SymbolKind.Method,
+  SymbolKind.Constant,
+  SymbolKind.Variable,
+  SymbolKind.TypeParameter,
+]);
+
+/**
+ * Member kinds listed under an exported container
+ */
+export const MEMBER_KINDS = new Set<SymbolKind>([
+  SymbolKind.Method,
+  SymbolKind.Constructor,
+  SymbolKind.Function,
+  SymbolKind.Constant,
+]);
+
+/**
+ * Options for the API surface tool
+ */
+export interface ApiSurfaceOptions {
+  recursive?: boolean;
+  includeDocs?: boolean;
+}
+
+/**
+ * Get the unqualified name of a symbol (e.g. "(*UserService).AddUser" -> "AddUser")
+ */
+export function baseName(name: string): string {
+  return name.replace(/^.*[.:]/, '').replace(/[()*]/g, '');
+}
+
+/**
+ * Decide whether a symbol is exported, using per-language conventions
+ * applied to the symbol name and its declaration text
+ */
+export function isExportedSymbol(languageId: string, name: string, declaration: string, isMember: boolean): boolean {
+  const base = baseName(name);
+  switch (languageId) {
+    case 'go':
+      return /^[A-Z]/.test(base);
+    case 'python':
+      return !base.startsWith('_') || (base.startsWith('__') && base.endsWith('__'));
+    case 'typescript':
+    case 'typescriptreact':
+    case 'javascript':
+    case 'javascriptreact':
+      if (isMember) {
+        return !/\b(private|protected)\b/.test(declaration) && !base.startsWith('#');
+      }
+      return /\bexport\b/.test(declaration);
+    case 'rust':
+      return /\bpub\b/.test(declaration);
+    case 'java':
+    case 'csharp':
+      return /\bpublic\b/.test(declaration);
+    case 'kotlin':
+    case 'scala':
+    case 'swift':
+      return !/\b(private|internal|fileprivate)\b/.test(declaration);
+    default:
+      return true;
+  }
+}
+
+/**
+ * Extract a one-line signature from the declaration lines
+ */
+export function extractSignature(lines: string[], startLine: number, maxLines = 5): string {
+  const parts: string[] = [];
+  let depth = 0;
+
+  for (let i = startLine; i < Math.min(lines.length, startLine + maxLines); i++) {
+    const line = lines[i].trim();
+    parts.push(line);
+    for (const ch of line) {
+      if (ch === '(') depth++;
+      if (ch === ')') depth--;
+    }
+    if (depth <= 0) {
+      break;
+    }
+  }
+
+  return parts
+    .join(' ')
+    .replace(/\s*\{\s*$/, '')
+    .replace(/\s*:\s*$/, '')
+    .replace(/\s+/g, ' ')
+    .trim();
+}
+
+/**
+ * Declaration text from the start of the symbol range to its name
+ */
+export function declarationText(lines: string[], sym: FlatSymbol): string {
+  const start = sym.range.start.line;
+  const end = Math.max(start, sym.selectionRange.start.line);
+  return lines.slice(start, end + 1).join(' ');
+}
+
+/**
+ * Format the exported symbols of one file
+ */
+function formatFileSurface(
+  relativePath: string,
+  lines: string[],
+  symbols: FlatSymbol[],
+  languageId: string,
+  includeDocs: boolean
+): [string, number] {
+  const exported = new Set<string>();
+  const entries: string[] = [];
+
+  for (const sym of symbols) {
+    const isMember = sym.depth > 0;
+    if (isMember) {
+      if (!sym.containerName || !exported.has(sym.containerName) || !MEMBER_KINDS.has(sym.kind)) {
+        continue;
+      }
+    } else if (!TOP_LEVEL_KINDS.has(sym.kind)) {
+      continue;
+    }
+
+    const declaration = declarationText(lines, sym);
+    if (!isExportedSymbol(languageId, sym.name, declaration, isMember)) {
+      continue;
+    }
+    exported.add(sym.qualifiedName);
+
+    const indent = '  '.repeat(sym.depth);
+    const kindName = SymbolKindNames[sym.kind] || 'Unknown';
+    let entry = `${indent}[${kindName}] ${sym.qualifiedName} (L${sym.range.start.line + 1})\n`;
+    entry += `${indent}    ${extractSignature(lines, sym.selectionRange.start.line)}\n`;
+
+    if (includeDocs) {
+      const doc = getDocComment(lines, sym.range.start.line) || getDocComment(lines, sym.selectionRange.start.line);
+      if (doc) {
+        entry += doc.split('\n').map((line) => `${indent}    ${line}`).join('\n') + '\n';
+      }
+    }
+    entries.push(entry);
+  }
+
+  if (entries.length === 0) {
+    return ['', 0];
+  }
+  return [`---\n\n${relativePath}\n\n${entries.join('')}`, entries.length];
+}
+
+/**
+ * Source files of a package (directory) or module (file); the files of
+ * subdirectories too when recursive
+ */
+export async function packageSourceFiles(workspaceDir: string, absolutePath: string, recursive?: boolean): Promise<WorkspaceFile[]> {
+  let files = await walkWorkspaceFiles(workspaceDir, { pathPrefix: absolutePath });
+  files = files.filter((f) => isSourceFile(f.absolutePath));
+  if (!recursive) {
+    const stats = await fs.promises.stat(absolutePath);
+    const dir = stats.isDirectory() ? absolutePath : path.dirname(absolutePath);
+    files = files.filter((f) => path.dirname(f.absolutePath) === dir);
+  }
+  return files;
+}
+
+/**
+ * List the exported API of a package (directory) or module (file)
+ */
+export async function getApiSurface(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  targetPath: string,
+  options: ApiSurfaceOptions = {}
+): Promise<string> {
+  const includeDocs = options.includeDocs ?? true;
+  const absolutePath = resolveWorkspacePath(workspaceDir, targetPath);
+
+  const files = await packageSourceFiles(workspaceDir, absolutePath, options.recursive);
+  if (files.length === 0) {
+    return `No source files found in ${targetPath}`;
+  }
+
+  const sections: string[] = [];
+  let totalSymbols = 0;
+
+  for (const file of files) {
+    // Skip test files - they are not part of the public API
+    if (isTestFile(file.relativePath)) {
+      continue;
+    }
+
+    let symbols: FlatSymbol[];
+    let content: string;
+    try {
+      symbols = await getFileSymbols(client, workspaceDir, file.absolutePath);
+      content = await readFileText(file.absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Could not get symbols for %s: %s', file.absolutePath, err);
+      continue;
+    }
+
+    const languageId = detectLanguageId(file.absolutePath);
+    const [section, count] = formatFileSurface(
+      file.relativePath,
+      content.split('\n'),
+      symbols,
+      languageId,
+      includeDocs
+    );
+    if (count > 0) {
+      sections.push(section);
+      totalSymbols += count;
+    }
+  }
+
+  if (totalSymbols === 0) {
+    return `No exported symbols found in ${targetPath}`;
+  }
+
+  return `API surface of ${targetPath}: ${totalSymbols} exported symbol(s) in ${sections.length} file(s)\n\n` +
+    sections.join('\n');
+}
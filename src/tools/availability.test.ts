@@ -0,0 +1,34 @@
+/**
+ * Tests for tool availability
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { WRITE_TOOLS, toolDisabledReason } from './availability';
+
+describe('Tool availability', () => {
+  it('should refuse each write tool in read-only mode, and only those', () => {
+    for (const name of ['rename_symbol', 'edit_file', 'replace_text', 'add_remote']) {
+      expect(toolDisabledReason(name, true, {})).toBe('the server runs in read-only mode');
+      expect(toolDisabledReason(name, false, {})).toBeUndefined();
+    }
+    for (const name of ['search_code', 'plan_refactor', 'impact_report', 'overlay', 'definition']) {
+      expect(toolDisabledReason(name, true, {})).toBeUndefined();
+    }
+  });
+
+  it('should count every tool whose handler writes files as a write tool', () => {
+    const source = fs.readFileSync(path.join(__dirname, '..', 'index.ts'), 'utf8');
+    const handlers = source.split(/\n {6}default: /)[0].split(/\n {6}case '/).slice(1).map((body) => [body.slice(0, body.indexOf("'")), body] as const);
+    const writing = handlers
+      .filter(([, body]) => /\b(renameSymbol|applyTextEdits|replaceText)\(|this\.remotes\.add\(/.test(body))
+      .map(([name]) => name);
+    expect(writing.sort()).toEqual([...WRITE_TOOLS].sort());
+  });
+
+  it('should narrow the tools by TOOLS_ENABLED and TOOLS_DISABLED', () => {
+    expect(toolDisabledReason('hover', false, { TOOLS_ENABLED: 'search_code, definition' })).toBe('the configuration turns it off');
+    expect(toolDisabledReason('definition', false, { TOOLS_ENABLED: 'search_code, definition' })).toBeUndefined();
+    expect(toolDisabledReason('hover', false, { TOOLS_DISABLED: 'hover' })).toBe('the configuration turns it off');
+  });
+});
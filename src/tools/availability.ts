@@ -0,0 +1,38 @@
+/**
+ * Tool availability - which tools the server offers
+ * Read-only mode leaves out the tools that write to disk, and TOOLS_ENABLED
+ * and TOOLS_DISABLED narrow the list further
+ */
+
+/**
+ * Tools that write to disk, left out in read-only mode
+ * (add_remote clones into the remote cache directory)
+ */
+export const WRITE_TOOLS = new Set(['rename_symbol', 'edit_file', 'replace_text', 'add_remote']);
+
+/**
+ * Tool names of a comma-separated list setting, or undefined when it is unset
+ */
+function toolList(value: string | undefined): Set<string> | undefined {
+  return value ? new Set(value.split(',').map((item) => item.trim()).filter(Boolean)) : undefined;
+}
+
+/**
+ * Why a tool is turned off, or undefined when it is offered
+ * TOOLS_ENABLED lists the only tools offered and TOOLS_DISABLED removes
+ * tools; both are read on every call, so a configuration reload applies at once
+ */
+export function toolDisabledReason(
+  name: string,
+  readOnly: boolean | undefined,
+  env: NodeJS.ProcessEnv = process.env
+): string | undefined {
+  if (readOnly && WRITE_TOOLS.has(name)) {
+    return 'the server runs in read-only mode';
+  }
+  const enabled = toolList(env.TOOLS_ENABLED);
+  if ((enabled && !enabled.has(name)) || toolList(env.TOOLS_DISABLED)?.has(name)) {
+    return 'the configuration turns it off';
+  }
+  return undefined;
+}
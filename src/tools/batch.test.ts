@@ -0,0 +1,44 @@
+/**
+ * Tests for the batch search tool
+ */
+
+import { MAX_BATCH_QUERIES, batchSearch } from './batch';
+
+describe('batch_search', () => {
+  const run = async (args: Record<string, unknown>) => {
+    if (args.pattern === 'boom') {
+      throw new Error('invalid regular expression');
+    }
+    return args.pattern === 'Missing'
+      ? `No matches found for: ${args.pattern} (3 file(s) scanned)`
+      : `Found 1 match(es) for ${args.pattern} (wholeWord: ${args.wholeWord}, maxResults: ${args.maxResults})`;
+  };
+
+  it('should key results and count queries with matches', async () => {
+    const output = await batchSearch([{ pattern: 'NewServer' }, { key: 'absent', pattern: 'Missing' }, { pattern: 'boom' }], { wholeWord: true }, run);
+    expect(output).toBe([
+      'Batch of 3 queries: 1 with matches, 2 without',
+      '',
+      '=== NewServer ===',
+      'Found 1 match(es) for NewServer (wholeWord: true, maxResults: 20)',
+      '',
+      '=== absent ===',
+      'No matches found for: Missing (3 file(s) scanned)',
+      '',
+      '=== boom ===',
+      'Error: invalid regular expression',
+    ].join('\n'));
+  });
+
+  it('should let a query override the shared options', async () => {
+    const output = await batchSearch([{ pattern: 'x', maxResults: 5, wholeWord: false }], { wholeWord: true, maxResults: 50 }, run);
+    expect(output).toContain('(wholeWord: false, maxResults: 5)');
+  });
+
+  it('should reject empty, oversized, and ambiguous batches', async () => {
+    await expect(batchSearch([], {}, run)).rejects.toThrow('non-empty');
+    const many = Array.from({ length: MAX_BATCH_QUERIES + 1 }, (_, i) => ({ pattern: `p${i}` }));
+    await expect(batchSearch(many, {}, run)).rejects.toThrow(`at most ${MAX_BATCH_QUERIES} queries`);
+    await expect(batchSearch([{ pattern: 'a' }, { pattern: 'a' }], {}, run)).rejects.toThrow('duplicate query key "a"');
+  });
+});
@@ -0,0 +1,92 @@
+/**
+ * Batch search tool - several independent search_code queries in one call
+ * Saves a round trip per query when an agent checks many names at once;
+ * each query runs like its own search_code call and its result is keyed
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { parseQuery } from '../search/query.js';
+import { CancelledError } from '../workspace/cancellation.js';
+import { createLimiter } from '../workspace/pool.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Most queries in one batch
+ */
+export const MAX_BATCH_QUERIES = 20;
+
+/**
+ * Queries run at the same time
+ */
+const BATCH_CONCURRENCY = 4;
+
+/**
+ * Matches listed per query unless the query or the batch sets maxResults
+ */
+const DEFAULT_BATCH_RESULTS = 20;
+
+/**
+ * One query: search_code arguments and an optional key naming its result
+ */
+export interface BatchQuery {
+  key?: string;
+  pattern?: string;
+  // search_code's query syntax, taking the place of pattern and the arguments it spells out
+  query?: string;
+  [option: string]: unknown;
+}
+
+/**
+ * Run a batch of queries, each with the shared options under its own, and format the keyed results
+ * A failing query reports its error without failing the batch
+ */
+export async function batchSearch(
+  queries: BatchQuery[],
+  shared: Record<string, unknown>,
+  run: (args: Record<string, unknown>) => Promise<string>
+): Promise<string> {
+  if (!Array.isArray(queries) || queries.length === 0) {
+    throw new ToolError('invalid-argument', 'queries must be a non-empty array');
+  }
+  if (queries.length > MAX_BATCH_QUERIES) {
+    throw new ToolError('invalid-argument', `at most ${MAX_BATCH_QUERIES} queries per batch, got ${queries.length}`);
+  }
+  const keys = queries.map((query, i) => query.key || query.pattern || query.query || `query ${i + 1}`);
+  const duplicate = keys.find((key, i) => keys.indexOf(key) !== i);
+  if (duplicate !== undefined) {
+    throw new ToolError('invalid-argument', `duplicate query key "${duplicate}"; give each query a distinct key`);
+  }
+  toolsLogger.debug('Running batch of %d queries', queries.length);
+
+  const limit = createLimiter(BATCH_CONCURRENCY);
+  const results = await Promise.all(queries.map((query) => limit(async () => {
+    let args: Record<string, unknown> = { ...query };
+    delete args.key;
+    try {
+      if (typeof args.query === 'string') {
+        const { query: text, ...explicit } = args;
+        args = { ...parseQuery(text as string), ...explicit };
+      }
+      if (typeof args.pattern !== 'string' || !args.pattern) {
+        return { text: 'Error: pattern or query is required', found: false };
+      }
+      const text = await run({ maxResults: DEFAULT_BATCH_RESULTS, ...shared, ...args });
+      return { text, found: !text.startsWith('No matches') };
+    } catch (err) {
+      // A cancelled batch stops instead of listing every query as failed
+      if (err instanceof CancelledError) {
+        throw err;
+      }
+      return { text: `Error: ${(err as Error).message}`, found: false };
+    }
+  })));
+
+  const found = results.filter((result) => result.found).length;
+  let output = `Batch of ${queries.length} queries: ${found} with matches, ${queries.length - found} without\n\n`;
+  results.forEach((result, i) => {
+    output += `=== ${keys[i]} ===\n${result.text}\n\n`;
+  });
+  return output.trimEnd();
+}
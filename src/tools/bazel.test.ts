@@ -0,0 +1,59 @@
+/**
+ * Tests for the Bazel targets tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { listBazelTargets } from './bazel';
+
+describe('bazel_targets', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'bazel-tool-')));
+    const files: Record<string, string> = {
+      'api/BUILD.bazel': [
+        'go_library(',
+        '    name = "api",',
+        '    srcs = ["server.go", "routes.go"],',
+        ')',
+        '',
+        'go_test(name = "api_test", srcs = glob(["*_test.go"]))',
+      ].join('\n'),
+      'api/server.go': 'package api\n',
+      'api/routes.go': 'package api\n',
+      'api/server_test.go': 'package api\n',
+      'api/notes.txt': 'unlisted\n',
+      'scripts/run.sh': 'echo\n',
+    };
+    for (const [file, content] of Object.entries(files)) {
+      fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, file), content);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should list the targets owning a file', async () => {
+    expect(await listBazelTargets(workspace, { filePath: 'api/server.go' })).toBe(
+      'Targets owning api/server.go (1)\n  //api:api (go_library) - api/BUILD.bazel:1');
+    expect(await listBazelTargets(workspace, { filePath: 'api/notes.txt' })).toBe(
+      'api/notes.txt is in package //api, but no target there lists it in srcs or hdrs');
+    expect(await listBazelTargets(workspace, { filePath: 'scripts/run.sh' })).toBe(
+      'scripts/run.sh is not in a Bazel package (no BUILD or BUILD.bazel file above it)');
+  });
+
+  it('should list the files of a target and every target', async () => {
+    expect(await listBazelTargets(workspace, { target: '//api:api_test' })).toBe(
+      '//api:api_test (go_test) - api/BUILD.bazel:6, 1 source file(s)\n  api/server_test.go');
+    expect(await listBazelTargets(workspace)).toBe([
+      'Bazel targets: 2 in 1 package(s)',
+      '  //api:api (go_library) - api/BUILD.bazel:1, 2 source file(s)',
+      '  //api:api_test (go_test) - api/BUILD.bazel:6, 1 source file(s)',
+    ].join('\n'));
+    await expect(listBazelTargets(workspace, { target: '//api:client' })).rejects.toThrow('No Bazel target named //api:client');
+  });
+});
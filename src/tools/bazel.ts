@@ -0,0 +1,80 @@
+/**
+ * Bazel targets tool - the targets owning a file, and the files of a target
+ * In a Bazel monorepo the unit of build, test, and ownership is the target;
+ * this answers "which target do I build to test this file" and "what is in
+ * //services/auth:server" from the BUILD files, without running bazel query
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { BazelTarget, BazelWorkspace, loadBazelWorkspace } from '../workspace/bazel.js';
+import { resolveWorkspacePath } from '../workspace/walker.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the Bazel targets tool
+ */
+export interface BazelTargetsOptions {
+  // File whose owning targets to list
+  filePath?: string;
+  // Label or pattern whose source files to list, e.g. //pkg:lib or //pkg/...
+  target?: string;
+  path?: string;
+}
+
+/**
+ * Source files listed per target before the rest is left out
+ */
+const MAX_LISTED_FILES = 100;
+
+function describeTarget(target: BazelTarget): string {
+  return `${target.label} (${target.kind}) - ${target.filePath}:${target.line}`;
+}
+
+function formatTargetFiles(workspace: BazelWorkspace, target: BazelTarget): string {
+  const files = workspace.filesOf(target);
+  const lines = [`${describeTarget(target)}, ${files.length} source file(s)`];
+  lines.push(...files.slice(0, MAX_LISTED_FILES).map((file) => `  ${file}`));
+  if (files.length > MAX_LISTED_FILES) {
+    lines.push(`  … ${files.length - MAX_LISTED_FILES} more`);
+  }
+  return lines.join('\n');
+}
+
+/**
+ * List the targets owning a file, the source files of a target, or the targets under a path
+ */
+export async function listBazelTargets(workspaceDir: string, options: BazelTargetsOptions = {}): Promise<string> {
+  toolsLogger.debug('Listing Bazel targets (file: %s, target: %s)', options.filePath ?? '-', options.target ?? '-');
+
+  if (options.filePath) {
+    const relativePath = path.relative(workspaceDir, resolveWorkspacePath(workspaceDir, options.filePath)).split(path.sep).join('/');
+    const workspace = await loadBazelWorkspace(workspaceDir);
+    const packageName = workspace.packageOf(relativePath);
+    if (packageName === undefined) {
+      return `${relativePath} is not in a Bazel package (no BUILD or BUILD.bazel file above it)`;
+    }
+    const owners = workspace.owners(relativePath);
+    if (owners.length === 0) {
+      return `${relativePath} is in package //${packageName}, but no target there lists it in srcs or hdrs`;
+    }
+    return [`Targets owning ${relativePath} (${owners.length})`, ...owners.map((target) => `  ${describeTarget(target)}`)].join('\n');
+  }
+
+  if (options.target) {
+    const workspace = await loadBazelWorkspace(workspaceDir);
+    return workspace.resolve(options.target).map((target) => formatTargetFiles(workspace, target)).join('\n\n');
+  }
+
+  const workspace = await loadBazelWorkspace(workspaceDir, options.path);
+  if (workspace.targets.length === 0) {
+    return options.path ? `No Bazel targets under ${options.path}` : 'No Bazel targets found (no BUILD or BUILD.bazel files)';
+  }
+  const packages = new Set(workspace.targets.map((target) => target.package));
+  const lines = [`Bazel targets: ${workspace.targets.length} in ${packages.size} package(s)`];
+  for (const target of workspace.targets) {
+    lines.push(`  ${describeTarget(target)}, ${workspace.filesOf(target).length} source file(s)`);
+  }
+  return lines.join('\n');
+}
@@ -0,0 +1,117 @@
+/**
+ * Tests for the bookmark tools
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { BookmarkSet, createBookmark, formatResolvedBookmarks, resolveBookmark, MAX_BOOKMARKS } from './bookmarks';
+import { ToolError } from '../workspace/errors';
+
+describe('Bookmarks', () => {
+  describe('BookmarkSet', () => {
+    it('should name unnamed bookmarks and replace named ones', () => {
+      const bookmarks = new BookmarkSet();
+      const base = { filePath: 'a.py', symbol: 'run', kind: 12, line: 1 };
+      expect(bookmarks.add(base).name).toBe('b1');
+      expect(bookmarks.add({ ...base, name: 'b2' }).name).toBe('b2');
+      expect(bookmarks.add(base).name).toBe('b3');
+      expect(bookmarks.add({ ...base, name: 'b2', line: 5 }).line).toBe(5);
+      expect(bookmarks.list().map((b) => b.name)).toEqual(['b1', 'b2', 'b3']);
+      expect(bookmarks.remove('b1')).toBe(true);
+      expect(bookmarks.remove('b1')).toBe(false);
+    });
+
+    it('should refuse bookmarks past the limit', () => {
+      const bookmarks = new BookmarkSet();
+      for (let i = 0; i < MAX_BOOKMARKS; i++) {
+        bookmarks.add({ filePath: 'a.py', symbol: 'run', kind: 12, line: 1 });
+      }
+      expect(() => bookmarks.add({ filePath: 'a.py', symbol: 'run', kind: 12, line: 1 })).toThrow(ToolError);
+    });
+  });
+
+  describe('resolveBookmark', () => {
+    let workspace: string;
+    let file: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'bookmarks-'));
+      file = path.join(workspace, 'client.py');
+      fs.writeFileSync(file, [
+        'class Client:',
+        '    def send(self, request):',
+        '        for attempt in range(3):',
+        '            self.post(request)',
+        '',
+        '    def close(self):',
+        '        pass',
+        '',
+      ].join('\n'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should anchor a line to its enclosing symbol and follow it when code moves', async () => {
+      const bookmarks = new BookmarkSet();
+      const bookmark = bookmarks.add(await createBookmark(workspace, undefined, file, { line: 3, name: 'retry' }));
+      expect(bookmark).toMatchObject({
+        name: 'retry',
+        filePath: 'client.py',
+        symbol: 'Client.send',
+        line: 2,
+        anchor: { offset: 1, text: 'for attempt in range(3):' },
+      });
+
+      expect((await resolveBookmark(workspace, undefined, bookmark)).status).toBe('unchanged');
+
+      // close moves above send, and a line is added inside send before the loop
+      fs.writeFileSync(file, [
+        'class Client:',
+        '    def close(self):',
+        '        pass',
+        '',
+        '    def send(self, request):',
+        '        request.validate()',
+        '        for attempt in range(3):',
+        '            self.post(request)',
+        '',
+      ].join('\n'));
+      const resolved = await resolveBookmark(workspace, undefined, bookmark);
+      expect(resolved).toMatchObject({ status: 'moved', line: 7, lineText: 'for attempt in range(3):' });
+      expect(bookmark.line).toBe(5);
+      expect(formatResolvedBookmarks([resolved])).toBe(
+        'retry: client.py:7  Method Client.send (moved from line 2)\n  for attempt in range(3):'
+      );
+    });
+
+    it('should bookmark a symbol by name and report it lost once removed', async () => {
+      const bookmark = new BookmarkSet().add(await createBookmark(workspace, undefined, file, { symbolName: 'close', note: 'tidy up' }));
+      expect(bookmark).toMatchObject({ name: 'b1', symbol: 'Client.close', line: 6 });
+      expect(bookmark.anchor).toBeUndefined();
+
+      fs.writeFileSync(file, 'class Client:\n    def shutdown(self):\n        pass\n');
+      const resolved = await resolveBookmark(workspace, undefined, bookmark);
+      expect(resolved.status).toBe('lost');
+      expect(formatResolvedBookmarks([resolved])).toBe(
+        'b1: Client.close not found; last seen in client.py:6. It was renamed or deleted\n  Note: tidy up'
+      );
+    });
+
+    it('should keep an anchor at its old place when its line is rewritten', async () => {
+      const bookmark = new BookmarkSet().add(await createBookmark(workspace, undefined, file, { line: 4 }));
+      fs.writeFileSync(file, fs.readFileSync(file, 'utf8').replace('self.post(request)', 'self.put(request)'));
+      const resolved = await resolveBookmark(workspace, undefined, bookmark);
+      expect(resolved).toMatchObject({ status: 'unchanged', line: 4, lineText: 'self.put(request)', approximate: true });
+      expect(formatResolvedBookmarks([resolved])).toContain('is no longer in Client.send');
+    });
+
+    it('should refuse a line outside every symbol', async () => {
+      fs.writeFileSync(file, 'import os\n\ndef main():\n    pass\n');
+      const err = await createBookmark(workspace, undefined, file, { line: 1 }).catch((e) => e);
+      expect(err.code).toBe('not-found');
+    });
+  });
+});
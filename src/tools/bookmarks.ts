@@ -0,0 +1,285 @@
+/**
+ * Bookmark tools - locations anchored to a symbol rather than a line number
+ * A bookmark names a symbol, and optionally a line inside it with that line's
+ * text, so "the retry loop in Client.send" is found again after edits move
+ * the function, reorder the file, or move the symbol to another file. Like
+ * pins, bookmarks last as long as the session and are never written to disk
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { SymbolKind, SymbolKindNames } from '../protocol/types.js';
+import { uriToPath } from '../protocol/uri.js';
+import { ToolError } from '../workspace/errors.js';
+import { readFileText, sharedOverlay } from '../workspace/overlay.js';
+import { FlatSymbol, findEnclosingSymbol, findWorkspaceSymbols, getFileSymbols } from './symbols.js';
+
+/**
+ * Most bookmarks a session holds
+ */
+export const MAX_BOOKMARKS = 100;
+
+/**
+ * A location anchored to a symbol
+ */
+export interface Bookmark {
+  name: string;
+  filePath: string; // Relative to the workspace, where the symbol was last found
+  symbol: string; // Qualified name
+  kind: SymbolKind;
+  line: number; // 1-indexed line of the symbol's name when last resolved
+  // A line inside the symbol: its distance from the symbol's first line, and its text
+  anchor?: { offset: number; text: string };
+  note?: string;
+}
+
+/**
+ * Where a bookmark is now
+ */
+export interface ResolvedBookmark {
+  bookmark: Bookmark;
+  status: 'unchanged' | 'moved' | 'lost';
+  // Where it was before this resolution, when it moved
+  previous?: { filePath: string; line: number };
+  // 1-indexed line the bookmark points at: the anchored line, or the symbol's name
+  line?: number;
+  lineText?: string;
+  // Set when the anchored line's text is no longer in the symbol, so the line is its old distance from the start
+  approximate?: boolean;
+}
+
+/**
+ * The bookmarks of one session, by name
+ */
+export class BookmarkSet {
+  private bookmarks = new Map<string, Bookmark>();
+  private nextId = 1;
+
+  /**
+   * Add a bookmark, replacing one of the same name; unnamed ones get b1, b2, ...
+   */
+  add(bookmark: Omit<Bookmark, 'name'> & { name?: string }): Bookmark {
+    let name = bookmark.name;
+    while (!name || (!bookmark.name && this.bookmarks.has(name))) {
+      name = `b${this.nextId++}`;
+    }
+    if (!this.bookmarks.has(name) && this.bookmarks.size >= MAX_BOOKMARKS) {
+      throw new ToolError('invalid-argument', `At most ${MAX_BOOKMARKS} bookmarks are kept; remove some first`);
+    }
+    const added = { ...bookmark, name };
+    this.bookmarks.set(name, added);
+    return added;
+  }
+
+  get(name: string): Bookmark | undefined {
+    return this.bookmarks.get(name);
+  }
+
+  /**
+   * Remove a bookmark, returning whether it existed
+   */
+  remove(name: string): boolean {
+    return this.bookmarks.delete(name);
+  }
+
+  /**
+   * Remove every bookmark, returning how many there were
+   */
+  clear(): number {
+    const count = this.bookmarks.size;
+    this.bookmarks.clear();
+    return count;
+  }
+
+  /**
+   * Bookmarks in the order they were added
+   */
+  list(): Bookmark[] {
+    return Array.from(this.bookmarks.values());
+  }
+}
+
+/**
+ * Lines of a file, empty when it cannot be read
+ */
+async function fileLines(filePath: string): Promise<string[]> {
+  try {
+    return (await readFileText(filePath)).split('\n');
+  } catch {
+    return [];
+  }
+}
+
+/**
+ * Anchor a bookmark to a symbol of a file: one named, or the innermost one around a line
+ * With a line inside the symbol, the bookmark keeps that line's place in it
+ */
+export async function createBookmark(
+  workspaceDir: string,
+  client: LSPClient | undefined,
+  filePath: string,
+  options: { symbolName?: string; line?: number; name?: string; note?: string }
+): Promise<Omit<Bookmark, 'name'> & { name?: string }> {
+  if (!fs.existsSync(filePath) && !sharedOverlay().has(filePath)) {
+    throw new ToolError('not-found', `File ${path.relative(workspaceDir, filePath)} does not exist`);
+  }
+  const relativePath = path.relative(workspaceDir, filePath);
+  const symbols = await getFileSymbols(client, workspaceDir, filePath);
+  let target: FlatSymbol | undefined;
+  if (options.symbolName) {
+    const named = symbols.filter((s) => s.qualifiedName === options.symbolName || s.name === options.symbolName);
+    // A line picks among symbols of the same name, such as overloads or methods of several types
+    target = options.line === undefined
+      ? named[0]
+      : named.find((s) => s.range.start.line < options.line! && options.line! <= s.range.end.line + 1) ?? named[0];
+    if (!target) {
+      throw new ToolError('not-found', `No symbol named ${options.symbolName} in ${relativePath}`);
+    }
+  } else if (options.line !== undefined) {
+    target = findEnclosingSymbol(symbols, options.line - 1);
+    if (!target) {
+      throw new ToolError('not-found', `Line ${options.line} of ${relativePath} is in no symbol; bookmarks are anchored to symbols`);
+    }
+  } else {
+    throw new ToolError('invalid-argument', 'symbolName or line is required');
+  }
+
+  const bookmark: Omit<Bookmark, 'name'> & { name?: string } = {
+    name: options.name,
+    filePath: relativePath,
+    symbol: target.qualifiedName,
+    kind: target.kind,
+    line: target.selectionRange.start.line + 1,
+    note: options.note,
+  };
+  const inside = options.line !== undefined && options.line - 1 > target.range.start.line && options.line - 1 <= target.range.end.line;
+  if (inside) {
+    const text = (await fileLines(filePath))[options.line! - 1];
+    if (text !== undefined && text.trim() !== '') {
+      bookmark.anchor = { offset: options.line! - 1 - target.range.start.line, text: text.trim() };
+    }
+  }
+  return bookmark;
+}
+
+/**
+ * The symbol a bookmark names in a list of symbols, nearest its last line
+ */
+function pickSymbol(symbols: FlatSymbol[], bookmark: Bookmark): FlatSymbol | undefined {
+  const candidates = symbols.filter((s) => s.qualifiedName === bookmark.symbol);
+  const sameKind = candidates.filter((s) => s.kind === bookmark.kind);
+  const pool = sameKind.length > 0 ? sameKind : candidates;
+  return pool.sort((a, b) =>
+    Math.abs(a.selectionRange.start.line + 1 - bookmark.line) - Math.abs(b.selectionRange.start.line + 1 - bookmark.line))[0];
+}
+
+/**
+ * Find a bookmark's symbol again and update the bookmark to where it is
+ * The symbol is looked for in its last file, then by name in the workspace,
+ * in case it moved to another file. An anchored line is found again by its
+ * text within the symbol, nearest its old place
+ */
+export async function resolveBookmark(workspaceDir: string, client: LSPClient | undefined, bookmark: Bookmark): Promise<ResolvedBookmark> {
+  let filePath = path.join(workspaceDir, bookmark.filePath);
+  let found: FlatSymbol | undefined;
+  if (fs.existsSync(filePath) || sharedOverlay().has(filePath)) {
+    found = pickSymbol(await getFileSymbols(client, workspaceDir, filePath), bookmark);
+  }
+  if (!found) {
+    const shortName = bookmark.symbol.split('.').pop()!;
+    const elsewhere = (await findWorkspaceSymbols(client, workspaceDir, shortName).catch(() => []))
+      .filter((sym) => sym.name === shortName && (sym.containerName ? `${sym.containerName}.${sym.name}` : sym.name) === bookmark.symbol)
+      .map((sym) => uriToPath(sym.location.uri))
+      .filter((candidate) => !path.relative(workspaceDir, candidate).startsWith('..'));
+    for (const candidate of Array.from(new Set(elsewhere))) {
+      found = pickSymbol(await getFileSymbols(client, workspaceDir, candidate).catch(() => []), bookmark);
+      if (found) {
+        filePath = candidate;
+        break;
+      }
+    }
+  }
+  if (!found) {
+    return { bookmark, status: 'lost' };
+  }
+
+  const relativePath = path.relative(workspaceDir, filePath);
+  const line = found.selectionRange.start.line + 1;
+  const moved = relativePath !== bookmark.filePath || line !== bookmark.line;
+  const previous = moved ? { filePath: bookmark.filePath, line: bookmark.line } : undefined;
+  bookmark.filePath = relativePath;
+  bookmark.line = line;
+
+  const lines = await fileLines(filePath);
+  if (!bookmark.anchor) {
+    return { bookmark, status: moved ? 'moved' : 'unchanged', previous, line, lineText: lines[line - 1]?.trim() };
+  }
+  const start = found.range.start.line;
+  const end = Math.min(found.range.end.line, lines.length - 1);
+  let anchored: number | undefined;
+  for (let i = start; i <= end; i++) {
+    if (lines[i].trim() === bookmark.anchor.text &&
+      (anchored === undefined || Math.abs(i - start - bookmark.anchor.offset) < Math.abs(anchored - start - bookmark.anchor.offset))) {
+      anchored = i;
+    }
+  }
+  const approximate = anchored === undefined;
+  const index = anchored ?? Math.min(start + bookmark.anchor.offset, end);
+  const anchorMoved = moved || index - start !== bookmark.anchor.offset;
+  bookmark.anchor.offset = index - start;
+  return {
+    bookmark,
+    status: anchorMoved ? 'moved' : 'unchanged',
+    previous: anchorMoved ? previous ?? { filePath: relativePath, line } : undefined,
+    line: index + 1,
+    lineText: lines[index]?.trim(),
+    ...(approximate ? { approximate } : {}),
+  };
+}
+
+/**
+ * "name: Kind symbol in file:line" description of a bookmark
+ */
+export function describeBookmark(bookmark: Bookmark): string {
+  const anchor = bookmark.anchor ? `, line ${bookmark.anchor.offset + 1} of it` : '';
+  return `${bookmark.name}: ${SymbolKindNames[bookmark.kind] ?? 'Symbol'} ${bookmark.symbol}${anchor} in ${bookmark.filePath}:${bookmark.line}`;
+}
+
+/**
+ * Format resolved bookmarks, one block each
+ */
+export function formatResolvedBookmarks(resolved: ResolvedBookmark[]): string {
+  if (resolved.length === 0) {
+    return 'No bookmarks; anchor one to a symbol with bookmark_symbol';
+  }
+  const lines: string[] = [];
+  for (const entry of resolved) {
+    const { bookmark } = entry;
+    if (lines.length > 0) {
+      lines.push('');
+    }
+    if (entry.status === 'lost') {
+      lines.push(`${bookmark.name}: ${bookmark.symbol} not found; last seen in ${bookmark.filePath}:${bookmark.line}. It was renamed or deleted`);
+    } else {
+      const where = `${bookmark.filePath}:${entry.line}`;
+      let state = 'unchanged';
+      if (entry.status === 'moved' && entry.previous) {
+        state = entry.previous.filePath !== bookmark.filePath
+          ? `moved from ${entry.previous.filePath}`
+          : entry.previous.line !== bookmark.line ? `moved from line ${entry.previous.line}` : 'moved within the symbol';
+      }
+      lines.push(`${bookmark.name}: ${where}  ${SymbolKindNames[bookmark.kind] ?? 'Symbol'} ${bookmark.symbol} (${state})`);
+      if (entry.lineText !== undefined) {
+        lines.push(`  ${entry.lineText}`);
+      }
+      if (entry.approximate) {
+        lines.push(`  The bookmarked line "${bookmark.anchor!.text}" is no longer in ${bookmark.symbol}; this line is at its old place`);
+      }
+    }
+    if (bookmark.note) {
+      lines.push(`  Note: ${bookmark.note}`);
+    }
+  }
+  return lines.join('\n');
+}
@@ -0,0 +1,83 @@
+/**
+ * Tests for the capabilities tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { CapabilitiesContext, detectLanguages, formatCapabilities, getCapabilities, supportedFeatures } from './capabilities';
+
+describe('Capabilities tool', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'capabilities-'));
+    fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n');
+    fs.writeFileSync(path.join(workspace, 'util.go'), 'package main\n');
+    fs.writeFileSync(path.join(workspace, 'tool.py'), 'print(1)\n');
+    fs.writeFileSync(path.join(workspace, 'README.md'), '# readme\n');
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  const context = (capabilities: Record<string, unknown>, running = true): CapabilitiesContext => ({
+    languageServer: {
+      command: 'gopls',
+      args: ['serve'],
+      name: 'gopls',
+      version: 'v0.16.0',
+      running,
+      positionEncoding: 'utf-16',
+      capabilities,
+    },
+    index: { loaded: false, files: 0, trigrams: 0 },
+    subsystems: { 'query cache': true, 'semantic search': false, 'metrics and health endpoint': 'http://127.0.0.1:9464' },
+    tools: ['definition', 'rename_symbol', 'search_code'],
+  });
+
+  it('should count languages, most common first', async () => {
+    expect(await detectLanguages(workspace)).toEqual([{ language: 'go', files: 2 }, { language: 'python', files: 1 }]);
+  });
+
+  it('should list advertised features and the tools they enable', async () => {
+    expect(supportedFeatures({ hoverProvider: true, renameProvider: { prepareProvider: true }, referencesProvider: false }))
+      .toEqual(['hover', 'rename']);
+
+    const capabilities = await getCapabilities(workspace, context({ workspaceSymbolProvider: true }));
+    expect(capabilities.tools).toEqual([
+      { name: 'definition', available: true, missing: [] },
+      { name: 'rename_symbol', available: false, missing: ['renameProvider'] },
+      { name: 'search_code', available: true, missing: [] },
+    ]);
+
+    const text = formatCapabilities(capabilities);
+    expect(text).toContain('- gopls v0.16.0 (gopls serve), running');
+    expect(text).toContain('- Features: workspace symbols');
+    expect(text).toContain('- trigram: not built yet');
+    expect(text).toContain('- semantic: disabled');
+    expect(text).toContain('- metrics and health endpoint: http://127.0.0.1:9464');
+    expect(text).toContain('- rename_symbol (unavailable: needs renameProvider)');
+  });
+
+  it('should mark language server tools unavailable when the server is not running', async () => {
+    const capabilities = await getCapabilities(workspace, context({ workspaceSymbolProvider: true }, false));
+    expect(capabilities.tools[0]).toEqual({ name: 'definition', available: false, missing: ['a running language server'] });
+    expect(capabilities.tools[2].available).toBe(true);
+  });
+
+  it('should keep tools available without a feature they only make use of', async () => {
+    const capabilities = await getCapabilities(workspace, {
+      ...context({ workspaceSymbolProvider: true, referencesProvider: true }),
+      tools: ['references'],
+    });
+    expect(capabilities.tools).toEqual([{ name: 'references', available: true, missing: ['documentHighlightProvider'] }]);
+    expect(formatCapabilities(capabilities)).toContain('- references (limited: no documentHighlightProvider)');
+    const full = await getCapabilities(workspace, {
+      ...context({ workspaceSymbolProvider: true, referencesProvider: true, documentHighlightProvider: true }),
+      tools: ['references'],
+    });
+    expect(full.tools).toEqual([{ name: 'references', available: true, missing: [] }]);
+  });
+});
@@ -0,0 +1,219 @@
+/**
+ * Capabilities tool - describe what this deployment supports
+ * Lists the languages found in the workspace, the attached language server
+ * and the LSP features it advertises, index status, the optional subsystems
+ * that are enabled, and which tools are usable as a result, so clients can
+ * pick strategies that work here
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { Project } from '../workspace/projects.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Language server capability flags and the features they stand for
+ */
+const LSP_FEATURES: Array<[string, string]> = [
+  ['definitionProvider', 'definition'],
+  ['referencesProvider', 'references'],
+  ['hoverProvider', 'hover'],
+  ['renameProvider', 'rename'],
+  ['documentSymbolProvider', 'document symbols'],
+  ['workspaceSymbolProvider', 'workspace symbols'],
+  ['implementationProvider', 'implementation'],
+  ['typeDefinitionProvider', 'type definition'],
+  ['callHierarchyProvider', 'call hierarchy'],
+  ['typeHierarchyProvider', 'type hierarchy'],
+  ['codeActionProvider', 'code actions'],
+  ['documentFormattingProvider', 'formatting'],
+  ['completionProvider', 'completion'],
+  ['signatureHelpProvider', 'signature help'],
+  ['semanticTokensProvider', 'semantic tokens'],
+  ['inlayHintProvider', 'inlay hints'],
+  ['diagnosticProvider', 'pull diagnostics'],
+  ['documentHighlightProvider', 'document highlights'],
+];
+
+/**
+ * Language server features each tool needs, or uses if advertised when in
+ * OPTIONAL_FEATURES; tools not listed need none
+ */
+const TOOL_REQUIREMENTS: Record<string, string[]> = {
+  definition: ['workspaceSymbolProvider'],
+  references: ['workspaceSymbolProvider', 'referencesProvider', 'documentHighlightProvider'],
+  hover: ['hoverProvider'],
+  rename_symbol: ['renameProvider'],
+  api_surface: ['documentSymbolProvider'],
+  impact_report: ['referencesProvider'],
+};
+
+/**
+ * Features tools make use of when advertised and work without otherwise,
+ * e.g. references telling reads from writes by the surrounding text
+ */
+const OPTIONAL_FEATURES = new Set(['documentHighlightProvider']);
+
+/**
+ * Tools the built-in Python symbols serve without a language server
+ */
+const PYTHON_FALLBACK_TOOLS = new Set(['definition', 'api_surface']);
+
+/**
+ * The attached language server
+ */
+export interface LanguageServerInfo {
+  command: string;
+  args: string[];
+  name?: string;
+  version?: string;
+  running: boolean;
+  positionEncoding: string;
+  capabilities: Record<string, unknown>;
+}
+
+/**
+ * Inputs gathered by the server
+ */
+export interface CapabilitiesContext {
+  languageServer?: LanguageServerInfo;
+  index: { loaded: boolean; files: number; trigrams: number };
+  semantic?: { provider: string; files: number; chunks: number };
+  // Optional subsystems and whether (or how) they are enabled
+  subsystems: Record<string, boolean | string>;
+  // Names of the tools the server lists
+  tools: string[];
+  // Monorepo projects, for the tools' project argument
+  projects?: Project[];
+  // Python symbols come from the built-in parser
+  pythonFallback?: boolean;
+}
+
+/**
+ * What this deployment supports
+ */
+export interface Capabilities {
+  workspace: string;
+  languages: Array<{ language: string; files: number }>;
+  projects: Project[];
+  languageServer?: Omit<LanguageServerInfo, 'capabilities'> & { features: string[] };
+  index: CapabilitiesContext['index'];
+  semantic?: CapabilitiesContext['semantic'];
+  subsystems: Record<string, boolean | string>;
+  tools: Array<{ name: string; available: boolean; missing: string[] }>;
+}
+
+/**
+ * Features a language server advertises
+ */
+export function supportedFeatures(capabilities: Record<string, unknown>): string[] {
+  return LSP_FEATURES.filter(([flag]) => !!capabilities[flag]).map(([, feature]) => feature);
+}
+
+/**
+ * Count workspace files per language, most common first
+ */
+export async function detectLanguages(workspaceDir: string): Promise<Array<{ language: string; files: number }>> {
+  const counts = new Map<string, number>();
+  for (const file of await walkWorkspaceFiles(workspaceDir)) {
+    const language = detectLanguageId(file.relativePath);
+    if (language !== 'plaintext') {
+      counts.set(language, (counts.get(language) ?? 0) + 1);
+    }
+  }
+  return Array.from(counts.entries())
+    .map(([language, files]) => ({ language, files }))
+    .sort((a, b) => b.files - a.files || a.language.localeCompare(b.language));
+}
+
+/**
+ * Describe what this deployment supports
+ */
+export async function getCapabilities(workspaceDir: string, context: CapabilitiesContext): Promise<Capabilities> {
+  toolsLogger.debug('Collecting capabilities for %s', workspaceDir);
+  const server = context.languageServer;
+  const tools = context.tools.map((name) => {
+    // A missing or stopped language server leaves every LSP tool unusable
+    const flags = context.pythonFallback && PYTHON_FALLBACK_TOOLS.has(name) && !server?.running
+      ? []
+      : TOOL_REQUIREMENTS[name] ?? [];
+    const missing = flags.length > 0 && !server?.running
+      ? ['a running language server']
+      : flags.filter((flag) => !server?.capabilities[flag]);
+    return { name, available: missing.every((flag) => OPTIONAL_FEATURES.has(flag)), missing };
+  });
+
+  let languageServer: Capabilities['languageServer'];
+  if (server) {
+    const { capabilities, ...info } = server;
+    languageServer = { ...info, features: supportedFeatures(capabilities) };
+  }
+  return {
+    workspace: workspaceDir,
+    languages: await detectLanguages(workspaceDir),
+    projects: context.projects ?? [],
+    languageServer,
+    index: context.index,
+    semantic: context.semantic,
+    subsystems: context.subsystems,
+    tools,
+  };
+}
+
+/**
+ * Format capabilities as text
+ */
+export function formatCapabilities(capabilities: Capabilities): string {
+  let output = `Workspace: ${capabilities.workspace}\n\n`;
+
+  output += 'Languages:\n';
+  output += capabilities.languages.length > 0
+    ? capabilities.languages.map((entry) => `- ${entry.language}: ${entry.files} file(s)\n`).join('')
+    : '- none detected\n';
+
+  if (capabilities.projects.length > 0) {
+    output += '\nProjects:\n';
+    for (const project of capabilities.projects) {
+      output += `- ${project.name}: ${project.path} (${project.source})\n`;
+    }
+  }
+
+  const server = capabilities.languageServer;
+  output += '\nLanguage server:\n';
+  if (server) {
+    const name = server.name ? `${server.name}${server.version ? ` ${server.version}` : ''}` : 'unnamed';
+    output += `- ${name} (${[server.command, ...server.args].join(' ')}), ${server.running ? 'running' : 'not running'}\n`;
+    output += `- Position encoding: ${server.positionEncoding}\n`;
+    output += `- Features: ${server.features.length > 0 ? server.features.join(', ') : 'none advertised'}\n`;
+  } else {
+    output += '- none attached\n';
+  }
+
+  const { index, semantic } = capabilities;
+  output += '\nIndexes:\n';
+  output += index.loaded
+    ? `- trigram: ${index.files} file(s), ${index.trigrams} trigram(s)\n`
+    : '- trigram: not built yet (built on the first search)\n';
+  output += semantic
+    ? `- semantic: ${semantic.chunks} chunk(s) from ${semantic.files} file(s), embeddings: ${semantic.provider}\n`
+    : '- semantic: disabled\n';
+
+  output += '\nSubsystems:\n';
+  for (const [name, value] of Object.entries(capabilities.subsystems)) {
+    output += `- ${name}: ${value === true ? 'enabled' : value === false ? 'disabled' : value}\n`;
+  }
+
+  output += '\nTools:\n';
+  for (const tool of capabilities.tools) {
+    if (!tool.available) {
+      output += `- ${tool.name} (unavailable: needs ${tool.missing.join(', ')})\n`;
+    } else if (tool.missing.length > 0) {
+      output += `- ${tool.name} (limited: no ${tool.missing.join(', ')})\n`;
+    } else {
+      output += `- ${tool.name}\n`;
+    }
+  }
+  return output;
+}
@@ -0,0 +1,72 @@
+/**
+ * Tests for the churn tool
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { churnGroup, churnReport } from './churn';
+import { parseNumstatLog } from '../git/git';
+
+describe('churn', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'churn-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should parse numstat logs', () => {
+    const log = '\x1eaaa\x1fAna\x1f200\x1fedit\n\n3\t1\tsrc/a.ts\n-\t-\tlogo.png\n\x1ebbb\x1fBo\x1f100\x1fadd\n\n5\t0\tREADME.md\n';
+    expect(parseNumstatLog(log)).toEqual([
+      { commit: 'aaa', author: 'Ana', authorTime: 200, summary: 'edit', files: [
+        { path: 'src/a.ts', added: 3, removed: 1 },
+        { path: 'logo.png', added: 0, removed: 0 },
+      ] },
+      { commit: 'bbb', author: 'Bo', authorTime: 100, summary: 'add', files: [{ path: 'README.md', added: 5, removed: 0 }] },
+    ]);
+  });
+
+  it('should group paths by directory below the base', () => {
+    expect(churnGroup('src/tools/search.ts', '.', 'directory', 1)).toBe('src');
+    expect(churnGroup('src/tools/search.ts', 'src', 'directory', 1)).toBe('src/tools');
+    expect(churnGroup('README.md', '.', 'directory', 2)).toBe('.');
+    expect(churnGroup('src/tools/search.ts', '.', 'file', 1)).toBe('src/tools/search.ts');
+  });
+
+  it('should rank directories by changed lines with their top authors', async () => {
+    try {
+      const git = (author: string, ...args: string[]) =>
+        execFileSync('git', ['-c', `user.name=${author}`, '-c', 'user.email=t@t', ...args], { cwd: workspace });
+      git('t', 'init', '-q');
+      fs.mkdirSync(path.join(workspace, 'src'));
+      fs.mkdirSync(path.join(workspace, 'docs'));
+      fs.writeFileSync(path.join(workspace, 'src', 'a.ts'), 'a\nb\nc\n');
+      fs.writeFileSync(path.join(workspace, 'docs', 'guide.md'), 'x\n');
+      git('Ana', 'add', '.');
+      git('Ana', 'commit', '-qm', 'first');
+      fs.writeFileSync(path.join(workspace, 'src', 'a.ts'), 'a\n');
+      git('Bo', 'commit', '-qam', 'trim');
+    } catch (err) {
+      return; // git is not available
+    }
+    const result = await churnReport(workspace, { days: 30 });
+    const lines = result.split('\n');
+    expect(lines[0]).toBe('Churn in the last 30 days: 2 commit(s) by 2 author(s), 2 directories');
+    expect(lines[2]).toMatch(/^directory\s+commits\s+added\s+removed\s+top authors$/);
+    expect(lines[3]).toMatch(/^src\s+2\s+\+3\s+-2\s+Ana 60%, Bo 40%$/);
+    expect(lines[4]).toMatch(/^docs\s+1\s+\+1\s+-0\s+Ana 100%$/);
+
+    const files = await churnReport(workspace, { path: 'src', by: 'file', limit: 1 });
+    expect(files).toContain('1 file(s)');
+    expect(files).toMatch(/src\/a\.ts\s+2/);
+  });
+
+  it('should reject workspaces outside git', async () => {
+    await expect(churnReport(workspace)).rejects.toThrow('not a git repository');
+  });
+});
@@ -0,0 +1,124 @@
+/**
+ * Churn tool - recent commits and changed lines per directory or file, with
+ * the authors who changed them most, to find hotspots and likely reviewers
+ */
+
+import * as path from 'path';
+import { commitChurn, isGitRepository } from '../git/git.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { resolveWorkspacePath } from '../workspace/walker.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the churn tool
+ */
+export interface ChurnOptions {
+  path?: string;
+  // Length of the window, in days (default: 90)
+  days?: number;
+  // 'directory' groups files by directory; 'file' lists files (default: 'directory')
+  by?: 'directory' | 'file';
+  // Directory levels below path that groups are formed at (default: 1)
+  depth?: number;
+  // Most rows listed (default: 20)
+  limit?: number;
+}
+
+/**
+ * Authors named per row
+ */
+const TOP_AUTHORS = 3;
+
+/**
+ * Churn of one directory or file
+ */
+interface ChurnRow {
+  commits: Set<string>;
+  added: number;
+  removed: number;
+  authors: Map<string, number>; // Lines changed per author
+}
+
+/**
+ * Group key of a repository-relative path: the file itself, or its directory
+ * cut to depth levels below the base directory
+ */
+export function churnGroup(filePath: string, base: string, by: 'directory' | 'file', depth: number): string {
+  if (by === 'file') {
+    return filePath;
+  }
+  const dir = path.posix.dirname(filePath);
+  const baseSegments = base === '.' ? 0 : base.split('/').length;
+  const segments = dir === '.' ? [] : dir.split('/');
+  return segments.slice(0, baseSegments + depth).join('/') || '.';
+}
+
+/**
+ * Most active authors of a row, with their share of its changed lines
+ */
+function topAuthors(row: ChurnRow): string {
+  const total = row.added + row.removed;
+  const ranked = [...row.authors.entries()].sort((a, b) => b[1] - a[1] || a[0].localeCompare(b[0]));
+  const named = ranked.slice(0, TOP_AUTHORS)
+    .map(([author, lines]) => `${author} ${total > 0 ? Math.round((lines / total) * 100) : 0}%`);
+  if (ranked.length > TOP_AUTHORS) {
+    named.push(`+${ranked.length - TOP_AUTHORS} more`);
+  }
+  return named.join(', ');
+}
+
+/**
+ * Commits, changed lines, and top authors per directory or file over the
+ * last days, the most changed lines first
+ */
+export async function churnReport(workspaceDir: string, options: ChurnOptions = {}): Promise<string> {
+  const days = options.days ?? 90;
+  const by = options.by ?? 'directory';
+  if (by !== 'directory' && by !== 'file') {
+    throw new ToolError('invalid-argument', `Unknown grouping "${by}"; use directory or file`);
+  }
+  const depth = options.depth ?? 1;
+  const limit = options.limit ?? 20;
+  if (!(await isGitRepository(workspaceDir))) {
+    throw new ToolError('unsupported', 'The workspace is not a git repository');
+  }
+  const start = options.path ? resolveWorkspacePath(workspaceDir, options.path) : workspaceDir;
+  const base = path.relative(workspaceDir, start).split(path.sep).join('/') || '.';
+  toolsLogger.debug('Reading churn of %s over %d days by %s', base, days, by);
+
+  const commits = await commitChurn(workspaceDir, days, base);
+  const rows = new Map<string, ChurnRow>();
+  const authors = new Set<string>();
+  for (const commit of commits) {
+    authors.add(commit.author);
+    for (const file of commit.files) {
+      const key = churnGroup(file.path, base, by, depth);
+      const row = rows.get(key) ?? { commits: new Set(), added: 0, removed: 0, authors: new Map() };
+      row.commits.add(commit.commit);
+      row.added += file.added;
+      row.removed += file.removed;
+      row.authors.set(commit.author, (row.authors.get(commit.author) ?? 0) + file.added + file.removed);
+      rows.set(key, row);
+    }
+  }
+  const scope = base === '.' ? '' : ` under ${base}`;
+  if (rows.size === 0) {
+    return `No commits in the last ${days} days${scope}`;
+  }
+
+  const ranked = [...rows.entries()]
+    .sort((a, b) => (b[1].added + b[1].removed) - (a[1].added + a[1].removed) || b[1].commits.size - a[1].commits.size || a[0].localeCompare(b[0]));
+  const shown = ranked.slice(0, limit);
+  const cells = [[by, 'commits', 'added', 'removed', 'top authors'],
+    ...shown.map(([key, row]) => [key, String(row.commits.size), `+${row.added}`, `-${row.removed}`, topAuthors(row)])];
+  const widths = cells[0].map((_, column) => Math.max(...cells.map((row) => row[column].length)));
+  const table = cells
+    .map((row) => row.map((cell, column) => column === 0 || column === 4 ? cell.padEnd(widths[column]) : cell.padStart(widths[column])).join('  ').trimEnd())
+    .join('\n');
+
+  const header = `Churn in the last ${days} days${scope}: ${commits.length} commit(s) by ${authors.size} author(s), ` +
+    `${rows.size} ${by === 'file' ? 'file(s)' : rows.size === 1 ? 'directory' : 'directories'}` + (shown.length < ranked.length ? ` (showing top ${shown.length})` : '');
+  return `${header}\n\n${table}`;
+}
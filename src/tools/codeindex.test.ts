@@ -0,0 +1,93 @@
+/**
+ * Tests for definitions and references from a code index
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { encodeScip, loadCodeIndex, parseScip } from '../symbols/codeindex';
+import { buildWorkspaceCodeIndex, indexedDefinition, indexedReferences } from './codeindex';
+
+describe('code index tools', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'codeindex-tools-')));
+    fs.writeFileSync(path.join(workspace, 'a.ts'), 'export function greet() {\n  return 1;\n}\n');
+    fs.writeFileSync(path.join(workspace, 'b.ts'), 'import { greet } from "./a";\n\ngreet();\n');
+    const range = (id: number, line: number, character: number) =>
+      ({ id, type: 'vertex', label: 'range', start: { line, character }, end: { line, character: character + 5 } });
+    fs.writeFileSync(path.join(workspace, 'dump.lsif'), [
+      { id: 1, type: 'vertex', label: 'document', uri: `file://${workspace}/a.ts` },
+      { id: 2, type: 'vertex', label: 'document', uri: `file://${workspace}/b.ts` },
+      range(3, 0, 16),
+      range(4, 0, 9),
+      range(5, 2, 0),
+      { id: 6, type: 'edge', label: 'contains', outV: 1, inVs: [3] },
+      { id: 7, type: 'edge', label: 'contains', outV: 2, inVs: [4, 5] },
+      { id: 8, type: 'vertex', label: 'resultSet' },
+      ...[3, 4, 5].map((outV, i) => ({ id: 9 + i, type: 'edge', label: 'next', outV, inV: 8 })),
+      { id: 12, type: 'vertex', label: 'definitionResult' },
+      { id: 13, type: 'vertex', label: 'referenceResult' },
+      { id: 14, type: 'edge', label: 'textDocument/definition', outV: 8, inV: 12 },
+      { id: 15, type: 'edge', label: 'textDocument/references', outV: 8, inV: 13 },
+      { id: 16, type: 'edge', label: 'item', outV: 12, inVs: [3] },
+      { id: 17, type: 'edge', label: 'item', outV: 13, inVs: [4, 5], property: 'references' },
+    ].map((element) => JSON.stringify(element)).join('\n'));
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should read definitions from the index, with their bodies', async () => {
+    const index = await loadCodeIndex(workspace, 'dump.lsif');
+    const text = await indexedDefinition(index, 'greet');
+    expect(text).toContain(`Symbol: greet\nFile: ${path.join(workspace, 'a.ts')}\nRange: L1:C1 - L3:C2\n`);
+    expect(text).toContain('     3| }\n');
+    expect(text).toContain('From the LSIF index dump.lsif, 1 symbols');
+    expect(await indexedDefinition(index, 'missing')).toBeUndefined();
+  });
+
+  it('should list references from the index and leave changed definitions to the language server', async () => {
+    const index = await loadCodeIndex(workspace, 'dump.lsif');
+    const text = await indexedReferences(index, undefined, 'greet');
+    expect(text).toContain(`${path.join(workspace, 'b.ts')}\nReferences in File: 2\nConfidence: high\nAt: L1:C10, L3:C1\n`);
+    expect(await indexedReferences(index, undefined, 'greet', undefined, 'same_file')).toContain('No references found for symbol: greet (scope: same_file)');
+
+    // References in files changed after the dump are kept, marked by whether the name is still there
+    const later = new Date(Date.now() + 60_000);
+    fs.writeFileSync(path.join(workspace, 'b.ts'), 'import { greet } from "./a";\n\n  greet();\n');
+    fs.utimesSync(path.join(workspace, 'b.ts'), later, later);
+    const changed = await indexedReferences(index, undefined, 'greet');
+    expect(changed).toContain('References in File: 2\nConfidence: low (file changed after the answer; the name is no longer here)\n' +
+      'At: L1:C10 (medium), L3:C1 (low)\n');
+    expect(changed).toContain('1 file(s) changed since it was built were not checked (no language server), so their references may be out of date');
+
+    fs.utimesSync(path.join(workspace, 'a.ts'), later, later);
+    expect(await indexedReferences(index, undefined, 'greet')).toBeUndefined();
+    expect(await indexedDefinition(index, 'greet')).toBeUndefined();
+  });
+
+  it('should index the workspace with the built-in parsers, leaving ambiguous names without references', async () => {
+    const dir = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'codeindex-build-')));
+    try {
+      fs.writeFileSync(path.join(dir, 'view.ts'), 'export class View {\n  render() {}\n}\nexport function mount(v: View) {\n  // mount a View\n  v.render();\n}\n');
+      fs.writeFileSync(path.join(dir, 'other.ts'), 'export function render() {}\nmount(new View());\n');
+      const dump = await buildWorkspaceCodeIndex(dir);
+      const byName = new Map(dump.symbols.map((symbol) => [symbol.name, symbol]));
+      expect([...byName.keys()].sort()).toEqual(['View', 'View.render', 'mount', 'render']);
+      expect(byName.get('View.render')!.id).toBe('grep-for-code . . . `view.ts`/View#render().');
+      expect(byName.get('View')!.references.map((location) => [location.relativePath, location.range.start.line]))
+        .toEqual([['other.ts', 1], ['view.ts', 3]]);
+      expect(byName.get('mount')!.references.map((location) => location.relativePath)).toEqual(['other.ts']);
+      expect(byName.get('render')!.references).toEqual([]);
+
+      const decoded = parseScip(encodeScip(dump, dir));
+      expect(decoded.symbols.map((symbol) => symbol.name).sort()).toEqual(['View', 'View.render', 'mount', 'render']);
+      expect(decoded.symbols.find((symbol) => symbol.name === 'View')!.definitions[0].extent).toEqual(byName.get('View')!.definitions[0].extent);
+    } finally {
+      fs.rmSync(dir, { recursive: true, force: true });
+    }
+  });
+});
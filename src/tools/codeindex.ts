@@ -0,0 +1,366 @@
+/**
+ * Code index tools - definitions and references answered from a loaded LSIF or SCIP dump
+ * Files changed since the dump was built are not trusted: a definition in
+ * one falls back to the language server, and references in them are asked
+ * of it while the rest come from the dump. Without a server they are kept,
+ * with a confidence from the files' modification times against the dump's. The workspace's own index, from
+ * the built-in parsers, is built here for export
+ */
+
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { answeredReferences } from '../lsp/methods.js';
+import { Location, Range, ReferenceContext, ReferenceParams, SymbolKind, TextDocumentPositionParams } from '../protocol/types.js';
+import { pathToUri, uriToPath } from '../protocol/uri.js';
+import { CodeIndex, CodeIndexDump, IndexedSymbol, IndexLocation } from '../symbols/codeindex.js';
+import { blankGoLiterals, parseGoDeclarations } from '../symbols/golang.js';
+import { isProtoFile, parseProtoSymbols } from '../symbols/proto.js';
+import { isPythonFile, parsePythonSymbols } from '../symbols/python.js';
+import { blankJsLiterals, isTypeScriptFile, parseTypeScriptDeclarations } from '../symbols/typescript.js';
+import { readFileText } from '../workspace/overlay.js';
+import { isUnder } from '../workspace/paths.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { Answer, answerNote, leastConfidence, locationConfidence } from './confidence.js';
+import { ReferenceScope, inReferenceScope } from './references.js';
+import { flattenDocumentSymbols } from './symbols.js';
+import {
+  addLineNumbers,
+  convertLinesToRanges,
+  formatLinesWithRanges,
+  getFullDefinition,
+  getLineRangesToDisplay,
+} from './utilities.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Package fields of the SCIP symbols the workspace index gets: the scheme,
+ * then placeholders for the manager, package name, and version
+ */
+const SCIP_SYMBOL_PREFIX = 'grep-for-code . . . ';
+
+const CALLABLE_KINDS = new Set([SymbolKind.Function, SymbolKind.Method, SymbolKind.Constructor]);
+const TYPE_KINDS = new Set([SymbolKind.Class, SymbolKind.Interface, SymbolKind.Struct, SymbolKind.Enum]);
+
+/**
+ * Identifiers of a line, for references of the workspace index
+ */
+const IDENTIFIER = /[A-Za-z_$][\w$]*/g;
+
+/**
+ * A declaration a built-in parser found
+ */
+export interface BuiltinDeclaration {
+  qualifiedName: string;
+  kind: SymbolKind;
+  line: number; // 0-indexed
+  character: number; // Where the name is looked for on the line
+  extent?: Range;
+}
+
+/**
+ * Languages with a built-in parser, by file; references are only looked for
+ * in files of the declaring language
+ */
+export function parsedLanguage(filePath: string): 'go' | 'python' | 'proto' | 'typescript' | undefined {
+  if (filePath.endsWith('.go')) {
+    return 'go';
+  }
+  if (isPythonFile(filePath)) {
+    return 'python';
+  }
+  if (isProtoFile(filePath)) {
+    return 'proto';
+  }
+  return isTypeScriptFile(filePath) && !filePath.endsWith('.d.ts') ? 'typescript' : undefined;
+}
+
+/**
+ * Declarations the built-in parsers find in a file of a language parsedLanguage knows
+ */
+export function builtinDeclarations(filePath: string, content: string): BuiltinDeclaration[] {
+  if (filePath.endsWith('.go')) {
+    const parsed = parseGoDeclarations(content);
+    const at = (line: number) => ({ line: line - 1, character: 0 });
+    return [
+      ...parsed.functions.map((fn) => ({ qualifiedName: fn.name, kind: SymbolKind.Function, ...at(fn.line) })),
+      // The name follows the receiver
+      ...parsed.methods.map((method) => ({
+        qualifiedName: `${method.receiver}.${method.name}`, kind: SymbolKind.Method, ...at(method.line),
+        character: Math.max(0, content.split('\n')[method.line - 1]?.indexOf(')') ?? 0),
+      })),
+      ...parsed.types.map((type) => ({ qualifiedName: type.name, kind: SymbolKind.Struct, ...at(type.line) })),
+      ...parsed.interfaces.map((iface) => ({ qualifiedName: iface.name, kind: SymbolKind.Interface, ...at(iface.line) })),
+    ];
+  }
+  const symbols = isPythonFile(filePath) ? parsePythonSymbols(content)
+    : isProtoFile(filePath) ? parseProtoSymbols(content)
+      : parseTypeScriptDeclarations(content);
+  return flattenDocumentSymbols(symbols).map((sym) => ({
+    qualifiedName: sym.qualifiedName,
+    kind: sym.kind,
+    line: sym.selectionRange.start.line,
+    character: sym.selectionRange.start.character,
+    extent: sym.range,
+  }));
+}
+
+function scipDescriptorName(name: string): string {
+  return /^[\w+$-]+$/.test(name) ? name : '`' + name.replace(/`/g, '``') + '`';
+}
+
+/**
+ * SCIP symbol of a declaration: its file as namespace and its containers as
+ * types, e.g. grep-for-code . . . `server/server.go`/Server#Handle().
+ */
+export function workspaceSymbolId(relativePath: string, qualifiedName: string, kind: SymbolKind): string {
+  const parts = qualifiedName.split('.');
+  const name = parts.pop()!;
+  const suffix = CALLABLE_KINDS.has(kind) ? '().' : TYPE_KINDS.has(kind) ? '#' : '.';
+  return SCIP_SYMBOL_PREFIX + scipDescriptorName(relativePath.split(path.sep).join('/')) + '/' +
+    parts.map((part) => `${scipDescriptorName(part)}#`).join('') + scipDescriptorName(name) + suffix;
+}
+
+/**
+ * Index the workspace with the built-in Go, Python, TypeScript, and .proto
+ * parsers, for export as SCIP
+ * Definitions are the declarations the parsers find. References are the
+ * other occurrences of a declared name in code (comments and strings left
+ * out) of the same language, for names declared once in it; names declared
+ * more than once get none rather than guessed ones
+ */
+export async function buildWorkspaceCodeIndex(workspaceDir: string, tool?: string): Promise<CodeIndexDump> {
+  const files = (await walkWorkspaceFiles(workspaceDir)).filter((file) => parsedLanguage(file.relativePath));
+  const symbols = new Map<string, IndexedSymbol>();
+  // Symbol ids by language, then by unqualified name
+  const byName = new Map<string, Map<string, Set<string>>>();
+  const definedAt = new Set<string>();
+  const texts = new Map<string, string[]>();
+
+  for (const file of files) {
+    let content: string;
+    try {
+      content = await readFileText(file.absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Cannot read %s: %s', file.relativePath, err);
+      continue;
+    }
+    const language = parsedLanguage(file.relativePath)!;
+    let blanked = language === 'go' ? blankGoLiterals(content) : language === 'typescript' ? blankJsLiterals(content) : content;
+    // A Go package clause names the package, not a declaration named like it
+    if (language === 'go') {
+      blanked = blanked.replace(/^package\s+\w+/m, (clause) => ' '.repeat(clause.length));
+    }
+    const lines = blanked.split('\n');
+    texts.set(file.relativePath, lines);
+    if (!byName.has(language)) {
+      byName.set(language, new Map());
+    }
+    for (const declaration of builtinDeclarations(file.relativePath, content)) {
+      const name = declaration.qualifiedName.substring(declaration.qualifiedName.lastIndexOf('.') + 1);
+      const line = lines[declaration.line] ?? '';
+      const character = line.substring(declaration.character).search(new RegExp(`(?<![\\w$])${name.replace(/\$/g, '\\$')}(?![\\w$])`));
+      if (character < 0) {
+        continue;
+      }
+      const start = { line: declaration.line, character: declaration.character + character };
+      const id = workspaceSymbolId(file.relativePath, declaration.qualifiedName, declaration.kind);
+      if (!symbols.has(id)) {
+        symbols.set(id, { id, name: declaration.qualifiedName, definitions: [], references: [] });
+      }
+      symbols.get(id)!.definitions.push({
+        relativePath: file.relativePath,
+        range: { start, end: { line: start.line, character: start.character + name.length } },
+        extent: declaration.extent,
+      });
+      definedAt.add(`${file.relativePath}:${start.line}:${start.character}`);
+      const names = byName.get(language)!;
+      if (!names.has(name)) {
+        names.set(name, new Set());
+      }
+      names.get(name)!.add(id);
+    }
+  }
+
+  for (const [relativePath, lines] of texts) {
+    const names = byName.get(parsedLanguage(relativePath)!)!;
+    lines.forEach((line, lineNo) => {
+      for (const match of line.matchAll(IDENTIFIER)) {
+        const ids = names.get(match[0]);
+        if (!ids || ids.size !== 1 || definedAt.has(`${relativePath}:${lineNo}:${match.index}`)) {
+          continue;
+        }
+        const start = { line: lineNo, character: match.index! };
+        symbols.get([...ids][0])!.references.push({
+          relativePath,
+          range: { start, end: { line: lineNo, character: start.character + match[0].length } },
+        });
+      }
+    });
+  }
+  return { format: 'scip', tool, symbols: Array.from(symbols.values()) };
+}
+
+/**
+ * Lines past a definition's name searched for its body, when the dump records only the name
+ */
+const MAX_DEFINITION_LINES = 200;
+
+function toLocation(index: CodeIndex, location: IndexLocation): Location {
+  return { uri: pathToUri(path.join(index.workspaceDir, location.relativePath)), range: location.range };
+}
+
+/**
+ * Definitions of a symbol from the dump, formatted as the definition tool formats them
+ * Undefined when the dump does not have the symbol or a definition is in a
+ * changed file, for the caller to ask the language server
+ */
+export async function indexedDefinition(index: CodeIndex, symbolName: string, scope?: string): Promise<string | undefined> {
+  const symbols = index.lookup(symbolName);
+  const definitions = symbols.flatMap((symbol) => symbol.definitions.map((location) => ({ symbol, location })));
+  if (definitions.length === 0) {
+    return undefined;
+  }
+  for (const { location } of definitions) {
+    if (await index.isStale(location.relativePath)) {
+      toolsLogger.debug('Definition of %s is in %s, changed since the index was built', symbolName, location.relativePath);
+      return undefined;
+    }
+  }
+
+  const output: string[] = [];
+  for (const { symbol, location } of definitions) {
+    const filePath = path.join(index.workspaceDir, location.relativePath);
+    if (scope && !isUnder(filePath, scope)) {
+      continue;
+    }
+    const extent = location.extent ?? {
+      start: location.range.start,
+      end: { line: location.range.start.line + MAX_DEFINITION_LINES, character: 0 },
+    };
+    const [definition, updated] = await getFullDefinition(filePath, { uri: pathToUri(filePath), range: extent });
+    output.push(
+      '---\n\n' +
+      `Symbol: ${symbol.name}\n` +
+      `File: ${filePath}\n` +
+      `Range: L${updated.range.start.line + 1}:C${updated.range.start.character + 1} - ` +
+      `L${updated.range.end.line + 1}:C${updated.range.end.character + 1}\n\n` +
+      addLineNumbers(definition, updated.range.start.line + 1) + '\n'
+    );
+  }
+  if (output.length === 0) {
+    return `${symbolName} not found`;
+  }
+  return output.join('') + `---\n\nFrom the ${index.describe()}\n`;
+}
+
+/**
+ * References of a symbol: from the dump in unchanged files, and from the
+ * language server in changed ones
+ * Undefined when the dump does not have the symbol or its definition is in a
+ * changed file, for the caller to ask the language server for all of them
+ */
+export async function indexedReferences(
+  index: CodeIndex,
+  client: LSPClient | undefined,
+  symbolName: string,
+  scope?: string,
+  within?: ReferenceScope
+): Promise<string | undefined> {
+  const symbols = index.lookup(symbolName);
+  if (symbols.length === 0) {
+    return undefined;
+  }
+  const stale = await index.staleFiles(symbols.flatMap((symbol) =>
+    [...symbol.definitions, ...symbol.references].map((location) => location.relativePath)));
+  if (symbols.some((symbol) => symbol.definitions.some((location) => stale.has(location.relativePath)))) {
+    return undefined;
+  }
+  const contextLines = parseInt(process.env.LSP_CONTEXT_LINES || '5', 10);
+  // The dump answered when it was written
+  const fromDump: Answer = { answeredAt: index.builtAt, cached: false, loading: [] };
+  const answers: Answer[] = [];
+
+  const byFile = new Map<string, { answer: Answer; locations: Location[] }>();
+  const add = (filePath: string, declarationPath: string, location: Location, answer: Answer) => {
+    if ((scope && !isUnder(filePath, scope)) || (within && !inReferenceScope(filePath, declarationPath, within))) {
+      return;
+    }
+    if (!byFile.has(filePath)) {
+      byFile.set(filePath, { answer, locations: [] });
+    }
+    byFile.get(filePath)!.locations.push(location);
+  };
+
+  let checkedLive = false;
+  for (const symbol of symbols) {
+    const declaration = toLocation(index, symbol.definitions[0]);
+    const declarationPath = uriToPath(declaration.uri);
+    for (const reference of symbol.references) {
+      if (!client || !stale.has(reference.relativePath)) {
+        add(path.join(index.workspaceDir, reference.relativePath), declarationPath, toLocation(index, reference), fromDump);
+      }
+    }
+    if (!client || stale.size === 0) {
+      continue;
+    }
+    // Changed files may have gained or lost references the dump cannot know about
+    await client.openFile(declarationPath);
+    const { locations: refs, answeredAt, cached } = await answeredReferences(client, {
+      textDocument: { uri: declaration.uri },
+      position: declaration.range.start,
+      context: { includeDeclaration: false } as ReferenceContext,
+    } as ReferenceParams & TextDocumentPositionParams);
+    const answer: Answer = { answeredAt, cached, loading: client.workInProgressAt(answeredAt) };
+    answers.push(answer);
+    checkedLive = true;
+    for (const ref of refs) {
+      const filePath = uriToPath(ref.uri);
+      const relativePath = path.relative(index.workspaceDir, filePath);
+      if (stale.has(relativePath) || (!relativePath.startsWith('..') && await index.isStale(relativePath))) {
+        stale.add(relativePath);
+        add(filePath, declarationPath, ref, answer);
+      }
+    }
+  }
+
+  // The name each reference spells, checked in files changed since the answer
+  const spelled = symbolName.split('.').pop()!;
+  const sections: string[] = [];
+  for (const filePath of Array.from(byFile.keys()).sort()) {
+    const { answer, locations } = byFile.get(filePath)!;
+    const refs = locations.sort((a, b) =>
+      a.range.start.line - b.range.start.line || a.range.start.character - b.range.start.character);
+    try {
+      const lines = (await readFileText(filePath)).split('\n');
+      const confidences = await locationConfidence(filePath, lines, refs, spelled, answer);
+      const least = leastConfidence(confidences);
+      const lineRanges = convertLinesToRanges(getLineRangesToDisplay(refs, lines.length, contextLines), lines.length);
+      sections.push(
+        `---\n\n${filePath}\nReferences in File: ${refs.length}\n` +
+        `Confidence: ${least.confidence}${least.reason ? ` (${least.reason})` : ''}\n` +
+        'At: ' + refs.map((ref, i) => `L${ref.range.start.line + 1}:C${ref.range.start.character + 1}` +
+          (confidences[i].confidence !== 'high' ? ` (${confidences[i].confidence})` : '')).join(', ') + '\n' +
+        '\n' + formatLinesWithRanges(lines, lineRanges)
+      );
+    } catch (err) {
+      sections.push(`---\n\n${filePath}\nReferences in File: ${refs.length}\n\nError reading file: ${err}`);
+    }
+  }
+
+  let note = `From the ${index.describe()}, built ${new Date(index.builtAt).toISOString()}`;
+  if (stale.size > 0) {
+    note += checkedLive
+      ? `; ${stale.size} file(s) changed since it was built were checked with the language server`
+      : `; ${stale.size} file(s) changed since it was built were not checked (no language server), so their references may be out of date`;
+  }
+  if (answers.length > 0) {
+    note += `\n${answerNote(answers)}`;
+  }
+  if (sections.length === 0) {
+    const scoped = within ? ` (scope: ${within})` : '';
+    return `No references found for symbol: ${symbolName}${scoped}\n${note}\n`;
+  }
+  return sections.join('\n') + `\n---\n\n${note}\n`;
+}
@@ -0,0 +1,57 @@
+/**
+ * Tests for confidence in language server answers
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { answerNote, leastConfidence, locationConfidence } from './confidence';
+import { sharedOverlay } from '../workspace/overlay';
+
+const at = (line: number, character: number) => ({
+  uri: 'file:///unused',
+  range: { start: { line, character }, end: { line, character: character + 5 } },
+});
+
+describe('answer confidence', () => {
+  let dir: string;
+  let file: string;
+
+  beforeAll(() => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'confidence-'));
+    file = path.join(dir, 'a.go');
+    fs.writeFileSync(file, 'func main() {\n\tgreet()\n}\n');
+  });
+
+  afterAll(() => {
+    sharedOverlay().delete(file);
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  it('should trust files unchanged since the answer, unless the server was busy', async () => {
+    const lines = fs.readFileSync(file, 'utf8').split('\n');
+    const answeredAt = Date.now() + 1000;
+    expect(await locationConfidence(file, lines, [at(1, 1)], 'greet', { answeredAt, cached: false, loading: [] }))
+      .toEqual([{ confidence: 'high' }]);
+    expect(await locationConfidence(file, lines, [at(1, 1)], 'greet', { answeredAt, cached: false, loading: ['Loading packages'] }))
+      .toEqual([{ confidence: 'medium', reason: 'the language server was still busy (Loading packages)' }]);
+  });
+
+  it('should check locations in files changed since the answer for the name', async () => {
+    const answeredAt = Date.now() - 60_000;
+    sharedOverlay().set(file, 'func main() {\n\n\tgreet()\n}\n');
+    const lines = sharedOverlay().get(file)!.split('\n');
+    const confidences = await locationConfidence(file, lines, [at(1, 1), at(2, 1)], 'greet', { answeredAt, cached: true, loading: [] });
+    expect(confidences.map((c) => c.confidence)).toEqual(['low', 'medium']);
+    expect(leastConfidence(confidences)).toEqual({ confidence: 'low', reason: 'file changed after the answer; the name is no longer here' });
+    expect(leastConfidence([])).toEqual({ confidence: 'high' });
+  });
+
+  it('should say when the oldest answer was given', () => {
+    const answeredAt = Date.parse('2026-01-02T03:04:05.000Z');
+    expect(answerNote([{ answeredAt, cached: false, loading: [] }])).toBe('Answered by the language server at 2026-01-02T03:04:05.000Z');
+    expect(answerNote([{ answeredAt: Date.now(), cached: false, loading: [] }, { answeredAt, cached: true, loading: [] }]))
+      .toMatch(/^Answered by the language server at 2026-01-02T03:04:05.000Z \(cached, \d+s old\)$/);
+    expect(answerNote([])).toBe('');
+  });
+});
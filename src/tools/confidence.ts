@@ -0,0 +1,101 @@
+/**
+ * Confidence in language server answers
+ * An answer can be out of date: cached before a file it points into changed,
+ * or given while the server was still loading packages. Each location gets
+ * a confidence, so agents can tell which results to trust and which to
+ * verify again
+ */
+
+import * as fs from 'fs';
+import { Location } from '../protocol/types.js';
+import { sharedOverlay } from '../workspace/overlay.js';
+
+/**
+ * How far a location can be trusted
+ * - high: the file is unchanged since the answer, given with the server loaded
+ * - medium: the server was still loading, or the file changed but the name is still there
+ * - low: the file changed and the name is no longer at the location
+ */
+export type Confidence = 'high' | 'medium' | 'low';
+
+export interface LocationConfidence {
+  confidence: Confidence;
+  reason?: string;
+}
+
+/**
+ * When and how the server answered
+ */
+export interface Answer {
+  answeredAt: number;
+  // Served from the cache rather than asked
+  cached: boolean;
+  // Work the server reported in progress when it answered
+  loading: string[];
+}
+
+const RANK: Record<Confidence, number> = { high: 0, medium: 1, low: 2 };
+
+/**
+ * When a file last changed, by overlay or on disk; undefined when it is gone
+ */
+async function changedAt(filePath: string): Promise<number | undefined> {
+  const overlay = sharedOverlay().entry(filePath);
+  if (overlay) {
+    return overlay.updatedAt;
+  }
+  try {
+    return (await fs.promises.stat(filePath)).mtimeMs;
+  } catch {
+    return undefined;
+  }
+}
+
+/**
+ * Confidence in each location an answer gave in one file
+ * lines are the file's current content; name is the symbol the locations should spell
+ */
+export async function locationConfidence(
+  filePath: string,
+  lines: string[],
+  locations: Location[],
+  name: string,
+  answer: Answer
+): Promise<LocationConfidence[]> {
+  const changed = await changedAt(filePath);
+  // Modification times are whole milliseconds on some file systems
+  const changedSince = changed === undefined || changed > answer.answeredAt + 1;
+  return locations.map((location) => {
+    if (changedSince) {
+      const { line, character } = location.range.start;
+      if (lines[line]?.substring(character, character + name.length) !== name) {
+        return { confidence: 'low', reason: 'file changed after the answer; the name is no longer here' };
+      }
+      return { confidence: 'medium', reason: 'file changed after the answer' };
+    }
+    if (answer.loading.length > 0) {
+      return { confidence: 'medium', reason: `the language server was still busy (${answer.loading.join(', ')})` };
+    }
+    return { confidence: 'high' };
+  });
+}
+
+/**
+ * The least confidence of some locations, with its reason
+ */
+export function leastConfidence(confidences: LocationConfidence[]): LocationConfidence {
+  return confidences.reduce((least, next) => RANK[next.confidence] > RANK[least.confidence] ? next : least, { confidence: 'high' });
+}
+
+/**
+ * Note saying when the answers were given, the oldest first
+ */
+export function answerNote(answers: Answer[]): string {
+  if (answers.length === 0) {
+    return '';
+  }
+  const oldest = answers.reduce((a, b) => b.answeredAt < a.answeredAt ? b : a);
+  const ageSeconds = Math.max(0, Math.round((Date.now() - oldest.answeredAt) / 1000));
+  return `Answered by the language server at ${new Date(oldest.answeredAt).toISOString()}` +
+    (answers.some((answer) => answer.cached) ? ` (cached, ${ageSeconds}s old)` : '');
+}
@@ -0,0 +1,83 @@
+/**
+ * Tests for test coverage on search matches
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { attachCoverage, clearCoverage, formatLineCoverage, parseGoCoverProfile, parseLcov } from './coverage';
+import { searchCode } from './search';
+import { LexicalMatch } from '../search/lexical';
+
+describe('coverage', () => {
+  let workspace: string;
+  const match = (filePath: string, line: number): LexicalMatch => ({ filePath, line, column: 1, length: 1, lineText: '' });
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'coverage-')));
+    fs.mkdirSync(path.join(workspace, 'store'));
+    fs.writeFileSync(path.join(workspace, 'store', 'retry.go'), [
+      'package store',
+      '',
+      'func retry(n int) error {',
+      '\tif n > 3 {',
+      '\t\treturn errGiveUp // retry limit',
+      '\t}',
+      '\treturn nil',
+      '}',
+    ].join('\n') + '\n');
+    fs.writeFileSync(path.join(workspace, 'util.ts'), 'export function retry() {}\n');
+    fs.writeFileSync(path.join(workspace, 'coverage.out'), [
+      'mode: count',
+      'example.com/app/store/retry.go:3.26,4.11 1 7',
+      'example.com/app/store/retry.go:4.11,6.3 1 0',
+      'example.com/app/store/retry.go:7.2,7.12 1 7',
+    ].join('\n') + '\n');
+    fs.mkdirSync(path.join(workspace, 'coverage'));
+    fs.writeFileSync(path.join(workspace, 'coverage', 'lcov.info'), 'TN:\nSF:../util.ts\nDA:1,0\nend_of_record\n');
+    // The profiles are newer than the sources
+    const later = new Date(Date.now() + 60000);
+    fs.utimesSync(path.join(workspace, 'coverage.out'), later, later);
+    fs.utimesSync(path.join(workspace, 'coverage', 'lcov.info'), later, later);
+  });
+
+  afterAll(() => {
+    clearCoverage();
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should read Go cover profiles and lcov tracefiles', () => {
+    const go = parseGoCoverProfile('mode: set\na/b.go:1.1,2.5 1 1\na/b.go:2.5,3.2 1 0\n');
+    expect(go.counted).toBe(false);
+    expect(Array.from(go.files.get('a/b.go')!)).toEqual([[1, 1], [2, 1], [3, 0]]);
+    const lcov = parseLcov('SF:/src/app/lib/x.js\nDA:2,4\nDA:3,0\nend_of_record\nSF:/elsewhere/y.js\nDA:1,1\n', '/src/app/coverage', '/src/app');
+    expect(Array.from(lcov.files.keys())).toEqual(['lib/x.js', '/elsewhere/y.js']);
+    expect(Array.from(lcov.files.get('lib/x.js')!)).toEqual([[2, 4], [3, 0]]);
+  });
+
+  it('should mark matched lines covered or not', async () => {
+    const matches = [match('store/retry.go', 3), match('store/retry.go', 5), match('store/retry.go', 1)];
+    const summary = await attachCoverage(workspace, matches, path.join(workspace, 'coverage.out'));
+    expect(summary).toEqual({ covered: 1, uncovered: 1, unknown: 1, stale: 0 });
+    expect(formatLineCoverage(matches[0].coverage!)).toBe('covered (7 hits)');
+    expect(formatLineCoverage(matches[1].coverage!)).toBe('not covered');
+    expect(matches[2].coverage).toBeUndefined();
+    expect(formatLineCoverage({ hits: 1, counted: false })).toBe('covered');
+
+    const output = await searchCode(workspace, 'retry', { coverage: path.join(workspace, 'coverage', 'lcov.info'), glob: ['**/*.ts'] });
+    expect(output).toContain('(coverage: 0 line(s) covered, 1 not covered)');
+    expect(output).toContain('  Coverage: not covered');
+  });
+
+  it('should count files changed since the profile and reject other files', async () => {
+    const earlier = new Date(Date.now() - 3600000);
+    fs.utimesSync(path.join(workspace, 'coverage.out'), earlier, earlier);
+    const output = await searchCode(workspace, 'retry', { coverage: path.join(workspace, 'coverage.out'), glob: ['**/*.go'] });
+    expect(output).toContain('(coverage: 1 line(s) covered, 1 not covered) (1 file(s) changed since the coverage profile was written; their lines may have moved)');
+
+    const missing = await attachCoverage(workspace, [], path.join(workspace, 'none.out')).catch((err) => err);
+    expect(missing.code).toBe('not-found');
+    const invalid = await attachCoverage(workspace, [], path.join(workspace, 'util.ts')).catch((err) => err);
+    expect(invalid.code).toBe('invalid-argument');
+  });
+});
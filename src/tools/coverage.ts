@@ -0,0 +1,219 @@
+/**
+ * Test coverage - whether the tests ran each matched line
+ * Reads a Go cover profile (go test -coverprofile) or an lcov tracefile and
+ * marks each search match covered or not, so changes to untested code paths
+ * stand out. Profiles are cached until they change; files edited since the
+ * profile was written are counted, since their lines may have moved
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { LexicalMatch, LineCoverage } from '../search/lexical.js';
+import { sharedOverlay } from '../workspace/overlay.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Profiles kept loaded, by path
+ */
+const MAX_CACHED_PROFILES = 8;
+
+/**
+ * Hit counts of the instrumented lines of each file in a profile
+ */
+export interface CoverageProfile {
+  format: 'go' | 'lcov';
+  // Whether hits count executions; Go's set mode only records whether a line ran
+  counted: boolean;
+  // By the path the profile names: a Go import path, or an lcov path made workspace-relative when it can be
+  files: Map<string, Map<number, number>>;
+}
+
+/**
+ * How the matched lines fared in a profile
+ */
+export interface CoverageSummary {
+  covered: number;
+  uncovered: number;
+  // Matched lines the profile does not instrument: declarations, comments, files it has no entry for
+  unknown: number;
+  // Files with matches changed since the profile was written
+  stale: number;
+}
+
+/**
+ * Read a Go cover profile: "mode: set" then "file.go:12.5,14.2 3 1" blocks
+ * A line is covered when any block on it ran, as go tool cover shows it
+ */
+export function parseGoCoverProfile(content: string): CoverageProfile {
+  const lines = content.split('\n');
+  const mode = lines[0].match(/^mode:\s*(\w+)/)?.[1];
+  const files = new Map<string, Map<number, number>>();
+  for (const line of lines.slice(1)) {
+    const block = line.trim().match(/^(.+):(\d+)\.\d+,(\d+)\.\d+\s+\d+\s+(\d+)$/);
+    if (!block) {
+      continue;
+    }
+    const hits = files.get(block[1]) ?? new Map<number, number>();
+    files.set(block[1], hits);
+    for (let n = Number(block[2]); n <= Number(block[3]); n++) {
+      hits.set(n, Math.max(hits.get(n) ?? 0, Number(block[4])));
+    }
+  }
+  return { format: 'go', counted: mode !== 'set', files };
+}
+
+/**
+ * Read an lcov tracefile: "SF:path", "DA:line,hits" records, "end_of_record"
+ * Relative source paths are taken from the profile's directory
+ */
+export function parseLcov(content: string, profileDir: string, workspaceDir: string): CoverageProfile {
+  const files = new Map<string, Map<number, number>>();
+  let hits: Map<number, number> | undefined;
+  for (const line of content.split('\n')) {
+    const record = line.trim();
+    if (record.startsWith('SF:')) {
+      const source = path.resolve(profileDir, record.substring(3));
+      const relative = path.relative(workspaceDir, source);
+      const key = relative.startsWith('..') || path.isAbsolute(relative) ? source : relative.split(path.sep).join('/');
+      hits = files.get(key) ?? new Map<number, number>();
+      files.set(key, hits);
+    } else if (record.startsWith('DA:') && hits) {
+      const [lineNumber, count] = record.substring(3).split(',').map(Number);
+      if (Number.isInteger(lineNumber) && !Number.isNaN(count)) {
+        hits.set(lineNumber, (hits.get(lineNumber) ?? 0) + count);
+      }
+    } else if (record === 'end_of_record') {
+      hits = undefined;
+    }
+  }
+  return { format: 'lcov', counted: true, files };
+}
+
+/**
+ * Loaded profiles by absolute path, with the modification time they were read at
+ */
+const profiles = new Map<string, { mtimeMs: number; profile: CoverageProfile }>();
+
+/**
+ * Read a coverage profile, from the cache while it is unchanged
+ */
+export async function loadCoverageProfile(workspaceDir: string, profilePath: string): Promise<{ profile: CoverageProfile; mtimeMs: number }> {
+  let stat: fs.Stats;
+  try {
+    stat = await fs.promises.stat(profilePath);
+  } catch {
+    throw new ToolError('not-found', `Coverage profile ${profilePath} does not exist`);
+  }
+  const cached = profiles.get(profilePath);
+  if (cached?.mtimeMs === stat.mtimeMs) {
+    return cached;
+  }
+  const content = await fs.promises.readFile(profilePath, 'utf8');
+  let profile: CoverageProfile;
+  if (/^mode:\s*\w+/.test(content)) {
+    profile = parseGoCoverProfile(content);
+  } else if (/^SF:/m.test(content)) {
+    profile = parseLcov(content, path.dirname(profilePath), workspaceDir);
+  } else {
+    throw new ToolError('invalid-argument', `${profilePath} is not a Go cover profile or an lcov tracefile`);
+  }
+  toolsLogger.debug('Loaded %s coverage of %d file(s) from %s', profile.format, profile.files.size, profilePath);
+  const entry = { mtimeMs: stat.mtimeMs, profile };
+  profiles.delete(profilePath);
+  profiles.set(profilePath, entry);
+  while (profiles.size > MAX_CACHED_PROFILES) {
+    profiles.delete(profiles.keys().next().value!);
+  }
+  return entry;
+}
+
+/**
+ * Hits of a workspace file in a profile
+ * Go profiles name files by import path and lcov ones may name them from
+ * elsewhere, so a profile path ending in the file's relative path is it,
+ * the shortest such path winning
+ */
+function fileHits(profile: CoverageProfile, workspaceDir: string, relativePath: string): Map<number, number> | undefined {
+  const exact = profile.files.get(relativePath) ?? profile.files.get(path.join(workspaceDir, relativePath));
+  if (exact) {
+    return exact;
+  }
+  let best: string | undefined;
+  for (const key of profile.files.keys()) {
+    if (key.endsWith('/' + relativePath) && (!best || key.length < best.length)) {
+      best = key;
+    }
+  }
+  return best ? profile.files.get(best) : undefined;
+}
+
+/**
+ * Mark each match with whether the profile's tests ran its line
+ * Archive entries, notebook cells, binary matches, and files with an overlay
+ * are left out; their lines are not the ones the profile measured
+ */
+export async function attachCoverage(workspaceDir: string, matches: LexicalMatch[], profilePath: string): Promise<CoverageSummary> {
+  const { profile, mtimeMs } = await loadCoverageProfile(workspaceDir, profilePath);
+  const summary: CoverageSummary = { covered: 0, uncovered: 0, unknown: 0, stale: 0 };
+  const byFile = new Map<string, Map<number, number> | undefined>();
+  for (const match of matches) {
+    if (match.filePath.includes('!/') || match.cell || match.byteOffset !== undefined ||
+      sharedOverlay().entry(path.join(workspaceDir, match.filePath))) {
+      summary.unknown++;
+      continue;
+    }
+    if (!byFile.has(match.filePath)) {
+      byFile.set(match.filePath, fileHits(profile, workspaceDir, match.filePath.split(path.sep).join('/')));
+      try {
+        if ((await fs.promises.stat(path.join(workspaceDir, match.filePath))).mtimeMs > mtimeMs) {
+          summary.stale++;
+        }
+      } catch {
+        // A file gone since the search has nothing newer than the profile
+      }
+    }
+    const hits = byFile.get(match.filePath)?.get(match.line);
+    if (hits === undefined) {
+      summary.unknown++;
+      continue;
+    }
+    match.coverage = { hits, counted: profile.counted };
+    summary[hits > 0 ? 'covered' : 'uncovered']++;
+  }
+  return summary;
+}
+
+/**
+ * Coverage of a line, e.g. "covered (12 hits)" or "not covered"
+ */
+export function formatLineCoverage(coverage: LineCoverage): string {
+  if (coverage.hits === 0) {
+    return 'not covered';
+  }
+  return coverage.counted ? `covered (${coverage.hits} hit${coverage.hits === 1 ? '' : 's'})` : 'covered';
+}
+
+/**
+ * Summary of the matched lines' coverage for the search header
+ */
+export function formatCoverageSummary(summary: CoverageSummary): string {
+  let note = ` (coverage: ${summary.covered} line(s) covered, ${summary.uncovered} not covered`;
+  if (summary.unknown > 0) {
+    note += `, ${summary.unknown} not instrumented`;
+  }
+  note += ')';
+  if (summary.stale > 0) {
+    note += ` (${summary.stale} file(s) changed since the coverage profile was written; their lines may have moved)`;
+  }
+  return note;
+}
+
+/**
+ * Forget the loaded profiles
+ */
+export function clearCoverage(): void {
+  profiles.clear();
+}
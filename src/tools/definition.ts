@@ -3,27 +3,62 @@
  */
 
 import { LSPClient } from '../lsp/client.js';
-import { symbol } from '../lsp/methods.js';
 import { createLogger, Component } from '../logging/logger.js';
-import { wrapSymbol, SymbolKind, SymbolKindNames, WorkspaceSymbolParams } from '../protocol/types.js';
+import { wrapSymbol, SymbolKind, SymbolKindNames, Location } from '../protocol/types.js';
 import { uriToPath } from '../protocol/uri.js';
+import { isUnder } from '../workspace/paths.js';
+import { BuildContext, formatBuildContext, matchesBuildContext } from '../workspace/buildtags.js';
+import { readFileText } from '../workspace/overlay.js';
 import { addLineNumbers, getFullDefinition } from './utilities.js';
+import { findWorkspaceSymbols } from './symbols.js';
 
 const toolsLogger = createLogger(Component.TOOLS);
 
+/**
+ * Drop Go definitions from files outside the build, unless that would drop all of them
+ * Platform-specific files (foo_linux.go, foo_windows.go) often define the same function
+ */
+async function preferBuildMatches<T extends { filePath: string }>(
+  candidates: T[],
+  build: BuildContext
+): Promise<{ kept: T[]; omitted: number }> {
+  const matches = await Promise.all(candidates.map(async (candidate) => {
+    if (!candidate.filePath.endsWith('.go')) {
+      return true;
+    }
+    try {
+      return matchesBuildContext(candidate.filePath, await readFileText(candidate.filePath), build);
+    } catch (err) {
+      return true;
+    }
+  }));
+  const kept = candidates.filter((_, i) => matches[i]);
+  if (kept.length === 0) {
+    return { kept: candidates, omitted: 0 };
+  }
+  return { kept, omitted: candidates.length - kept.length };
+}
+
 /**
  * Read definition of a symbol
+ * With a scope directory, only definitions inside it are returned. With a
+ * build context, Go definitions for other platforms are left out when one
+ * for the context exists. Without a language server, only the built-in
+ * Python symbols are searched
  */
 export async function readDefinition(
-  client: LSPClient,
-  symbolName: string
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  symbolName: string,
+  scope?: string,
+  build?: BuildContext
 ): Promise<string> {
   // Query symbols (caching is now handled in methods.ts)
   toolsLogger.debug('Querying for symbols: %s', symbolName);
-  const symbolResult = await symbol(client, { query: symbolName } as WorkspaceSymbolParams);
-  const results = symbolResult.results();
+  const results = await findWorkspaceSymbols(client, workspaceDir, symbolName);
 
   const definitions: string[] = [];
+  let candidates: Array<{ sym: ReturnType<typeof wrapSymbol>; kind: any; containerName: any; loc: Location; filePath: string }> = [];
 
   for (const rawSymbol of results) {
     const sym = wrapSymbol(rawSymbol);
@@ -60,9 +95,20 @@ export async function readDefinition(
 
     const loc = sym.getLocation();
     const filePath = uriToPath(loc.uri);
+    if (scope && !isUnder(filePath, scope)) {
+      continue;
+    }
+    candidates.push({ sym, kind, containerName, loc, filePath });
+  }
 
+  let omitted = 0;
+  if (build) {
+    ({ kept: candidates, omitted } = await preferBuildMatches(candidates, build));
+  }
+
+  for (const { sym, kind, containerName, loc, filePath } of candidates) {
     try {
-      await client.openFile(filePath);
+      await client?.openFile(filePath);
     } catch (err) {
       toolsLogger.error('Error opening file: %s', err);
       continue;
@@ -91,6 +137,10 @@ export async function readDefinition(
     return `${symbolName} not found`;
   }
 
-  return definitions.join('');
+  let output = definitions.join('');
+  if (omitted > 0) {
+    output += `---\n\n${omitted} definition(s) in Go files outside the ${formatBuildContext(build!)} build omitted (pass buildTags to choose another target)\n`;
+  }
+  return output;
 }
 
@@ -2,10 +2,11 @@
  * Diagnostics tool - get diagnostic information for files
  */
 
-import * as fs from 'fs';
 import { LSPClient } from '../lsp/client.js';
 import { pathToUri } from '../protocol/uri.js';
 import { addLineNumbers } from './utilities.js';
+import { readFileText } from '../workspace/overlay.js';
+import { ToolError } from '../workspace/errors.js';
 
 /**
  * Get diagnostics for a file
@@ -21,7 +22,7 @@ export async function getDiagnosticsForFile(
   try {
     await client.openFile(filePath);
   } catch (err) {
-    throw new Error(`Error opening file: ${err}`);
+    throw new ToolError('not-found', `Error opening file: ${err}`);
   }
 
   // Wait a bit for diagnostics to be published
@@ -37,7 +38,7 @@ export async function getDiagnosticsForFile(
   output += `Total: ${diagnostics.length}\n\n`;
 
   // Read file content
-  const content = await fs.promises.readFile(filePath, 'utf8');
+  const content = await readFileText(filePath);
   const lines = content.split('\n');
 
   // Group diagnostics by severity
@@ -0,0 +1,77 @@
+/**
+ * Tests for directory summaries
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { clearDirectoryDocs, directoryDoc, goPackageSummary, pythonDocstringSummary, readmeSummary, rustModuleSummary } from './dirdocs';
+import { searchCode } from './search';
+
+describe('Directory docs', () => {
+  it('should take the first paragraph of a README', () => {
+    const readme = [
+      '# Billing',
+      '',
+      '[![CI](https://ci/badge.svg)](https://ci)',
+      '',
+      'Invoicing and **payment** retries, see [the design](docs/design.md).',
+      'Owned by the payments team.',
+      '',
+      'More details.',
+    ].join('\n');
+    expect(readmeSummary(readme)).toBe('Invoicing and payment retries, see the design. Owned by the payments team.');
+    expect(readmeSummary('Billing\n=======\n\n```sh\nmake\n```\n')).toBe('Billing');
+    expect(readmeSummary('x'.repeat(150) + ' ' + 'y'.repeat(100))).toBe('x'.repeat(150) + '…');
+  });
+
+  it('should take Go, Python, and Rust package comments', () => {
+    expect(goPackageSummary('//go:build linux\n\n// Package billing sends invoices.\n//\n// Details.\npackage billing\n'))
+      .toBe('Package billing sends invoices.');
+    expect(goPackageSummary('/*\nPackage cli parses flags.\n*/\npackage cli\n')).toBe('Package cli parses flags.');
+    expect(goPackageSummary('package bare\n')).toBeUndefined();
+    expect(pythonDocstringSummary('# coding: utf-8\n"""Storage backends.\n\nMore.\n"""\n')).toBe('Storage backends.');
+    expect(rustModuleSummary('//! Wire protocol codecs.\n//!\n//! More.\nuse std::io;\n')).toBe('Wire protocol codecs.');
+  });
+
+  describe('directoryDoc', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      clearDirectoryDocs();
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'dirdocs-'));
+      fs.mkdirSync(path.join(workspace, 'billing', 'retry'), { recursive: true });
+      fs.writeFileSync(path.join(workspace, 'README.md'), 'The whole project.\n');
+      fs.writeFileSync(path.join(workspace, 'billing', 'doc.go'), '// Package billing sends invoices.\npackage billing\n');
+      fs.writeFileSync(path.join(workspace, 'billing', 'retry', 'retry.go'), 'package retry\n\nfunc Charge() {}\n');
+      fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nfunc Charge() {}\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should use the nearest documented directory below the root', async () => {
+      expect(await directoryDoc(workspace, path.join('billing', 'retry', 'retry.go'))).toEqual({
+        directory: 'billing',
+        source: path.join('billing', 'doc.go'),
+        summary: 'Package billing sends invoices.',
+      });
+      expect(await directoryDoc(workspace, 'main.go')).toBeUndefined();
+    });
+
+    it('should see a README added after the summary was cached', async () => {
+      const file = path.join('billing', 'retry', 'retry.go');
+      expect((await directoryDoc(workspace, file))?.directory).toBe('billing');
+      fs.writeFileSync(path.join(workspace, 'billing', 'retry', 'README.md'), 'Retries failed charges with backoff.\n');
+      expect((await directoryDoc(workspace, file))?.summary).toBe('Retries failed charges with backoff.');
+    });
+
+    it('should describe each directory once in search results', async () => {
+      fs.writeFileSync(path.join(workspace, 'billing', 'invoice.go'), '// Package billing sends invoices.\npackage billing\n\nfunc Charge() {}\n');
+      const output = await searchCode(workspace, 'Charge', { directoryDocs: true });
+      expect(output.split('About billing:').length - 1).toBe(1);
+      expect(output).toContain(`About billing: Package billing sends invoices. (${path.join('billing', 'doc.go')})`);
+    });
+  });
+});
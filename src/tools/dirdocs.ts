@@ -0,0 +1,270 @@
+/**
+ * Directory docs - what the area of the codebase a match lives in is for
+ * Summarizes a directory from its README, Go package comment, Python package
+ * docstring, Rust module comment, or package.json description. A file with
+ * none of these takes the summary of the nearest directory above it that has
+ * one; the workspace root is left out, as its README describes the whole project
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { DirectoryDoc, LexicalMatch } from '../search/lexical.js';
+import { readFileText } from '../workspace/overlay.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Longest summary shown; longer ones are cut at a word
+ */
+const MAX_SUMMARY_LENGTH = 200;
+
+/**
+ * Directories whose summaries are kept between searches
+ */
+const MAX_CACHED_DIRECTORIES = 1000;
+
+const README_NAMES = ['README.md', 'README.markdown', 'README.rst', 'README.txt', 'README', 'readme.md', 'Readme.md'];
+
+/**
+ * Own summaries of directories, by absolute path, with the modification
+ * times they were read at; a directory without one caches undefined
+ */
+const summaries = new Map<string, { stamp: string; doc?: { source: string; summary: string } }>();
+
+/**
+ * Shorten text to the summary length, at a word boundary
+ */
+function clip(text: string): string {
+  const flat = text.replace(/\s+/g, ' ').trim();
+  if (flat.length <= MAX_SUMMARY_LENGTH) {
+    return flat;
+  }
+  const cut = flat.substring(0, MAX_SUMMARY_LENGTH);
+  return `${cut.substring(0, cut.lastIndexOf(' ') > 0 ? cut.lastIndexOf(' ') : cut.length)}…`;
+}
+
+/**
+ * First paragraph of a README, past front matter, headings, badges, and images
+ * Markdown links keep their text; a README of headings only gives its first heading
+ */
+export function readmeSummary(content: string): string | undefined {
+  const lines = content.replace(/^---\n[\s\S]*?\n---\n/, '').split('\n');
+  const paragraph: string[] = [];
+  let heading: string | undefined;
+  let fenced = false;
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i].trim();
+    if (line.startsWith('```')) {
+      fenced = !fenced;
+    }
+    if (fenced || line.startsWith('```')) {
+      if (paragraph.length > 0) {
+        break;
+      }
+      continue;
+    }
+    const underlined = /^(=+|-+|~+|\^+)$/.test(lines[i + 1]?.trim() ?? '') && line.length > 0;
+    const skipped = /^#/.test(line) || /^(=+|-+|~+|\^+|\*+)$/.test(line) || /^(\[!\[|!\[|<)/.test(line);
+    if (underlined || /^#/.test(line)) {
+      heading = heading ?? line.replace(/^#+\s*/, '');
+    }
+    if (!line || skipped || underlined) {
+      if (paragraph.length > 0) {
+        break;
+      }
+      continue;
+    }
+    paragraph.push(line);
+  }
+  const text = paragraph.length > 0 ? paragraph.join(' ') : heading;
+  if (!text) {
+    return undefined;
+  }
+  return clip(text.replace(/!?\[([^\]]*)\]\([^)]*\)/g, '$1').replace(/\*\*|__/g, '').replace(/`([^`]*)`/g, '$1'));
+}
+
+/**
+ * First paragraph of the comment right above a Go package clause
+ */
+export function goPackageSummary(content: string): string | undefined {
+  const lines = content.split('\n');
+  const clause = lines.findIndex((line) => /^package\s+\w+/.test(line));
+  if (clause <= 0) {
+    return undefined;
+  }
+  const comment: string[] = [];
+  if (lines[clause - 1].trim().endsWith('*/')) {
+    for (let i = clause - 1; i >= 0; i--) {
+      comment.unshift(lines[i].replace(/^\s*\/\*+|\*+\/\s*$/g, '').replace(/^\s*\* ?/, ''));
+      if (lines[i].includes('/*')) {
+        break;
+      }
+    }
+  } else {
+    for (let i = clause - 1; i >= 0 && /^\s*\/\//.test(lines[i]); i--) {
+      comment.unshift(lines[i].replace(/^\s*\/\/\s?/, ''));
+    }
+  }
+  // Build constraints and generated-code markers are not documentation
+  const text = comment.filter((line) => !/^(go:build|\+build|Code generated)/.test(line.trim()));
+  const end = text.findIndex((line, i) => i > 0 && line.trim() === '');
+  const paragraph = (end > 0 ? text.slice(0, end) : text).join(' ').trim();
+  return paragraph ? clip(paragraph) : undefined;
+}
+
+/**
+ * First paragraph of a Python module docstring
+ */
+export function pythonDocstringSummary(content: string): string | undefined {
+  const match = /^(?:\s*#.*\n|\s*\n)*\s*[rRuU]?("""|''')([\s\S]*?)\1/.exec(content);
+  const paragraph = match?.[2].trim().split(/\n\s*\n/)[0];
+  return paragraph ? clip(paragraph) : undefined;
+}
+
+/**
+ * First paragraph of the //! comments opening a Rust module
+ */
+export function rustModuleSummary(content: string): string | undefined {
+  const lines: string[] = [];
+  for (const line of content.split('\n')) {
+    if (!/^\s*\/\/!/.test(line)) {
+      if (lines.length > 0 || line.trim() !== '') {
+        break;
+      }
+      continue;
+    }
+    const text = line.replace(/^\s*\/\/!\s?/, '');
+    if (text.trim() === '' && lines.length > 0) {
+      break;
+    }
+    if (text.trim() !== '' && !/^#/.test(text.trim())) {
+      lines.push(text);
+    }
+  }
+  return lines.length > 0 ? clip(lines.join(' ')) : undefined;
+}
+
+async function readIfExists(filePath: string): Promise<string | undefined> {
+  try {
+    return await readFileText(filePath);
+  } catch (err) {
+    return undefined;
+  }
+}
+
+/**
+ * Summary of a directory from its own files, and the file it came from
+ * A README wins; then doc.go or another Go file's package comment, an
+ * __init__.py docstring, a mod.rs or lib.rs comment, or package.json's description
+ */
+async function ownSummary(dir: string, entries: string[]): Promise<{ file: string; summary: string } | undefined> {
+  const sources: Array<[string, (content: string) => string | undefined]> = [
+    ...README_NAMES.map((name): [string, (content: string) => string | undefined] => [name, readmeSummary]),
+    ['doc.go', goPackageSummary],
+    ...entries.filter((name) => name.endsWith('.go') && !name.endsWith('_test.go') && name !== 'doc.go').sort()
+      .map((name): [string, (content: string) => string | undefined] => [name, goPackageSummary]),
+    ['__init__.py', pythonDocstringSummary],
+    ['mod.rs', rustModuleSummary],
+    ['lib.rs', rustModuleSummary],
+    ['package.json', (content) => {
+      try {
+        const description = JSON.parse(content).description;
+        return typeof description === 'string' && description.trim() ? clip(description) : undefined;
+      } catch (err) {
+        return undefined;
+      }
+    }],
+  ];
+  for (const [name, summarize] of sources) {
+    if (!entries.includes(name)) {
+      continue;
+    }
+    const content = await readIfExists(path.join(dir, name));
+    const summary = content === undefined ? undefined : summarize(content);
+    if (summary) {
+      return { file: name, summary };
+    }
+  }
+  return undefined;
+}
+
+/**
+ * A directory's own summary, from the cache while the directory and the
+ * file the summary came from are unchanged
+ */
+async function cachedSummary(workspaceDir: string, dir: string): Promise<{ source: string; summary: string } | undefined> {
+  let dirStats: fs.Stats;
+  try {
+    dirStats = await fs.promises.stat(dir);
+  } catch (err) {
+    return undefined;
+  }
+  const cached = summaries.get(dir);
+  if (cached) {
+    const sourceTime = cached.doc
+      ? await fs.promises.stat(path.join(workspaceDir, cached.doc.source)).then((stats) => stats.mtimeMs, () => 0)
+      : 0;
+    if (cached.stamp === `${dirStats.mtimeMs}:${sourceTime}`) {
+      return cached.doc;
+    }
+  }
+
+  let entries: string[] = [];
+  try {
+    entries = await fs.promises.readdir(dir);
+  } catch (err) {
+    toolsLogger.debug('Could not list %s: %s', dir, (err as Error).message);
+  }
+  const found = await ownSummary(dir, entries);
+  const doc = found && { source: path.relative(workspaceDir, path.join(dir, found.file)), summary: found.summary };
+  const sourceTime = found ? (await fs.promises.stat(path.join(dir, found.file)).catch(() => undefined))?.mtimeMs ?? 0 : 0;
+  summaries.delete(dir);
+  summaries.set(dir, { stamp: `${dirStats.mtimeMs}:${sourceTime}`, doc });
+  if (summaries.size > MAX_CACHED_DIRECTORIES) {
+    summaries.delete(summaries.keys().next().value!);
+  }
+  return doc;
+}
+
+/**
+ * Summary of the nearest documented directory holding a file, below the workspace root
+ */
+export async function directoryDoc(workspaceDir: string, relativePath: string): Promise<DirectoryDoc | undefined> {
+  let relativeDir = path.dirname(relativePath);
+  while (relativeDir !== '.' && relativeDir !== '' && !relativeDir.startsWith('..')) {
+    const doc = await cachedSummary(workspaceDir, path.join(workspaceDir, relativeDir));
+    if (doc) {
+      return { directory: relativeDir, ...doc };
+    }
+    relativeDir = path.dirname(relativeDir);
+  }
+  return undefined;
+}
+
+/**
+ * Attach the directory summary of each matched file to its matches
+ * Archive entries are left as they are
+ */
+export async function attachDirectoryDocs(workspaceDir: string, matches: LexicalMatch[]): Promise<void> {
+  const byFile = new Map<string, Promise<DirectoryDoc | undefined>>();
+  for (const match of matches) {
+    if (match.filePath.includes('!/')) {
+      continue;
+    }
+    if (!byFile.has(match.filePath)) {
+      byFile.set(match.filePath, directoryDoc(workspaceDir, match.filePath));
+    }
+    const doc = await byFile.get(match.filePath);
+    if (doc) {
+      match.directoryDoc = doc;
+    }
+  }
+}
+
+/**
+ * Forget the cached summaries
+ */
+export function clearDirectoryDocs(): void {
+  summaries.clear();
+}
@@ -0,0 +1,128 @@
+/**
+ * Docker tool - stages, base images, ports, and copied paths of Dockerfiles and compose files
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { ComposeService, DockerInstruction, DockerStage, findDockerFiles } from '../workspace/docker.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for Docker file analysis
+ */
+export interface DockerAnalysisOptions {
+  path?: string;
+  // Only this build stage, by name or 0-based index, with all its instructions
+  stage?: string;
+  // Only instructions matching this regular expression (case-insensitive)
+  pattern?: string;
+}
+
+/**
+ * Check if a stage is the one asked for, by name or index
+ */
+function isStage(stage: DockerStage, wanted: string): boolean {
+  return stage.name?.toLowerCase() === wanted.toLowerCase() || (/^\d+$/.test(wanted) && stage.index === parseInt(wanted, 10));
+}
+
+/**
+ * Format a stage: its FROM line, then the listed instructions or a summary
+ */
+function formatStage(stage: DockerStage, listed?: DockerInstruction[]): string {
+  const label = stage.name ? `Stage ${stage.index} "${stage.name}"` : `Stage ${stage.index}`;
+  const resolved = stage.resolvedImage !== stage.baseImage ? ` (${stage.resolvedImage})` : '';
+  const base = stage.fromStage ? `stage ${stage.fromStage}` : `${stage.baseImage}${resolved}`;
+  let output = `${label} L${stage.line}: FROM ${base}${stage.platform ? ` [${stage.platform}]` : ''}\n`;
+
+  if (listed) {
+    for (const instruction of listed) {
+      const text = `${instruction.keyword} ${instruction.args}`;
+      output += `  L${instruction.line}: ${text.split('\n')[0]}${text.includes('\n') ? ' …' : ''}\n`;
+    }
+    return output;
+  }
+  if (stage.ports.length > 0) {
+    output += `  Exposes: ${stage.ports.join(', ')}\n`;
+  }
+  for (const copy of stage.copies) {
+    const from = copy.from ? ` (from ${copy.from})` : '';
+    output += `  L${copy.line}: ${copy.keyword} ${copy.sources.join(' ')} → ${copy.destination}${from}\n`;
+  }
+  const entrypoint = [...stage.instructions].reverse().find((instruction) => instruction.keyword === 'ENTRYPOINT' || instruction.keyword === 'CMD');
+  if (entrypoint) {
+    output += `  L${entrypoint.line}: ${entrypoint.keyword} ${entrypoint.args}\n`;
+  }
+  return output;
+}
+
+/**
+ * Format a compose service
+ */
+function formatService(service: ComposeService): string {
+  let source = service.image ? `image ${service.image}` : '';
+  if (service.build) {
+    const details = [service.build.dockerfile, service.build.target && `target ${service.build.target}`].filter(Boolean);
+    source += `${source ? ', ' : ''}build ${service.build.context}${details.length > 0 ? ` (${details.join(', ')})` : ''}`;
+  }
+  let output = `${service.name} L${service.line}: ${source || 'no image or build'}\n`;
+  if (service.ports.length > 0) {
+    output += `  Ports: ${service.ports.join(', ')}\n`;
+  }
+  if (service.volumes.length > 0) {
+    output += `  Volumes: ${service.volumes.join(', ')}\n`;
+  }
+  if (service.dependsOn.length > 0) {
+    output += `  Depends on: ${service.dependsOn.join(', ')}\n`;
+  }
+  return output;
+}
+
+/**
+ * Analyze the workspace's Dockerfiles and compose files, or search within a build stage
+ */
+export async function analyzeDockerFiles(workspaceDir: string, options: DockerAnalysisOptions = {}): Promise<string> {
+  toolsLogger.debug('Analyzing Docker files (stage: %s, pattern: %s)', options.stage, options.pattern);
+  const matcher = options.pattern !== undefined ? new RegExp(options.pattern, 'i') : undefined;
+  const files = await findDockerFiles(workspaceDir, options.path);
+  if (files.length === 0) {
+    return 'No Dockerfiles or compose files found';
+  }
+
+  let output = '';
+  let shown = 0;
+  for (const file of files) {
+    let section = '';
+    if (file.kind === 'dockerfile') {
+      for (const stage of file.stages) {
+        if (options.stage && !isStage(stage, options.stage)) {
+          continue;
+        }
+        // A stage or a search lists instructions; otherwise the stage is summarized
+        const listed = options.stage || matcher
+          ? stage.instructions.slice(1).filter((instruction) => !matcher || matcher.test(`${instruction.keyword} ${instruction.args}`))
+          : undefined;
+        if (matcher && listed!.length === 0) {
+          continue;
+        }
+        section += formatStage(stage, listed);
+      }
+    } else if (!options.stage) {
+      for (const service of file.services) {
+        const text = formatService(service);
+        if (!matcher || matcher.test(text)) {
+          section += text;
+        }
+      }
+    }
+    if (section) {
+      output += `---\n\n${file.filePath} (${file.kind === 'dockerfile' ? 'Dockerfile' : 'compose'})\n\n${section}\n`;
+      shown++;
+    }
+  }
+  if (shown === 0) {
+    return options.stage
+      ? `No build stage ${options.stage}${matcher ? ` with instructions matching ${options.pattern}` : ''} in ${files.length} file(s)`
+      : `No instructions match ${options.pattern}`;
+  }
+  return `Found ${shown} file(s)\n\n${output}`.trimEnd();
+}
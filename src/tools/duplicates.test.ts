@@ -0,0 +1,89 @@
+/**
+ * Tests for duplicate code detection
+ */
+
+import { tokenize, winnow, findDuplicatePairs } from './duplicates';
+
+describe('Duplicate detection', () => {
+  describe('tokenize', () => {
+    it('should drop comments and normalize literals', () => {
+      const tokens = tokenize('// comment\nconst x = "hi"; /* block */ y = 42;', 'typescript', false);
+      expect(tokens.map((t) => t.value)).toEqual(['const', 'x', '=', 'STR', ';', 'y', '=', 'NUM', ';']);
+      expect(tokens[0].line).toBe(2);
+    });
+
+    it('should normalize identifiers but keep keywords', () => {
+      const tokens = tokenize('return userCount', 'go', true);
+      expect(tokens.map((t) => t.value)).toEqual(['return', 'ID']);
+    });
+
+    it('should treat hash as a comment only for hash-comment languages', () => {
+      expect(tokenize('# note\nx', 'python', false).map((t) => t.value)).toEqual(['x']);
+      expect(tokenize('#define X', 'c', false).map((t) => t.value)).toContain('#');
+    });
+  });
+
+  describe('winnow', () => {
+    it('should select at least one fingerprint per window', () => {
+      const tokens = tokenize('a b c d e f g h i j k l m n o p', 'go', false);
+      const fingerprints = winnow(tokens, 3, 4);
+      expect(fingerprints.length).toBeGreaterThan(0);
+
+      // Consecutive selected positions are never more than w apart
+      for (let i = 1; i < fingerprints.length; i++) {
+        expect(fingerprints[i].position - fingerprints[i - 1].position).toBeLessThanOrEqual(4);
+      }
+    });
+
+    it('should return nothing for short inputs', () => {
+      expect(winnow(tokenize('a b', 'go', false), 5, 4)).toEqual([]);
+    });
+  });
+
+  describe('findDuplicatePairs', () => {
+    const body = [
+      'let total = 0;',
+      'for (const value of values) {',
+      '  if (value.skip) { continue; }',
+      '  total += value.amount * rate;',
+      '  log("added", value.id, total);',
+      '}',
+      'const result = { total, count: values.length };',
+      'if (result.count === 0) { throw new Error("empty"); }',
+      'return result;',
+    ].join('\n');
+
+    it('should find renamed copies across files', () => {
+      const a = tokenize(`function sumA(values) {\n${body}\n}`, 'javascript', true);
+      const b = tokenize(`function sumB(items) {\n${body.replace(/values/g, 'items')}\n}`, 'javascript', true);
+      const pairs = findDuplicatePairs([{ path: 'a.js', tokens: a }, { path: 'b.js', tokens: b }], 50);
+
+      expect(pairs.length).toBe(1);
+      expect(pairs[0].fileA).toBe('a.js');
+      expect(pairs[0].fileB).toBe('b.js');
+      expect(pairs[0].startLineA).toBe(1);
+      expect(pairs[0].tokens).toBe(a.length);
+    });
+
+    it('should report a repeated block once', () => {
+      const a = tokenize(`${body}\n${body}`, 'javascript', false);
+      const pairs = findDuplicatePairs([{ path: 'a.js', tokens: a }], 50);
+      expect(pairs.length).toBe(1);
+      expect(pairs[0].fileB).toBe('a.js');
+      expect(pairs[0].startLineB).toBe(10);
+    });
+
+    it('should not report unrelated code', () => {
+      const a = tokenize(body, 'javascript', false);
+      const b = tokenize([
+        'class Queue {',
+        '  constructor() { this.items = []; this.head = 0; }',
+        '  push(item) { this.items.push(item); return this.items.length - this.head; }',
+        '  shift() { const item = this.items[this.head]; this.head++; return item; }',
+        '  get size() { return this.items.length - this.head; }',
+        '}',
+      ].join('\n'), 'javascript', false);
+      expect(findDuplicatePairs([{ path: 'a.js', tokens: a }, { path: 'b.js', tokens: b }], 50)).toEqual([]);
+    });
+  });
+});
@@ -0,0 +1,338 @@
+/**
+ * Duplicates tool - detect near-duplicate code blocks across the workspace
+ * Uses winnowing (Schleimer et al.) over normalized token streams
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { readFileText } from '../workspace/overlay.js';
+import { GeneratedFileDetector } from '../workspace/generated.js';
+import { detectLanguageId, isSourceFile } from '../workspace/language.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Source token with its 1-indexed line
+ */
+export interface Token {
+  value: string;
+  line: number;
+}
+
+/**
+ * Fingerprint selected by winnowing
+ */
+export interface Fingerprint {
+  hash: number;
+  position: number; // Index of the first token of the k-gram
+}
+
+/**
+ * Options for duplicate detection
+ */
+export interface DuplicateOptions {
+  path?: string;
+  minTokens?: number;
+  normalizeIdentifiers?: boolean;
+  maxResults?: number;
+  includeGenerated?: boolean;
+}
+
+/**
+ * A duplicated region between two locations
+ */
+export interface DuplicatePair {
+  fileA: string;
+  startLineA: number;
+  endLineA: number;
+  fileB: string;
+  startLineB: number;
+  endLineB: number;
+  tokens: number;
+}
+
+/**
+ * Languages that use '#' for line comments
+ */
+export const HASH_COMMENT_LANGUAGES = new Set(['python', 'shell', 'ruby', 'r']);
+
+/**
+ * Keywords kept verbatim when identifiers are normalized, so that the
+ * structure of the code still matters
+ */
+const KEYWORDS = new Set([
+  'if', 'else', 'for', 'while', 'do', 'return', 'break', 'continue', 'switch', 'case', 'default',
+  'func', 'function', 'def', 'class', 'struct', 'interface', 'type', 'enum', 'const', 'let', 'var',
+  'new', 'try', 'catch', 'finally', 'throw', 'raise', 'except', 'with', 'import', 'from', 'export',
+  'public', 'private', 'protected', 'static', 'async', 'await', 'yield', 'lambda', 'in', 'of',
+  'range', 'go', 'defer', 'select', 'chan', 'map', 'package', 'self', 'this', 'super', 'nil',
+  'null', 'None', 'true', 'false', 'True', 'False', 'and', 'or', 'not', 'is', 'pass',
+]);
+
+/**
+ * Tokenize source code, dropping whitespace and comments
+ * Literals are always normalized; identifiers optionally
+ */
+export function tokenize(content: string, languageId: string, normalizeIdentifiers: boolean): Token[] {
+  const hashComments = HASH_COMMENT_LANGUAGES.has(languageId);
+  const regex = hashComments
+    ? /#[^\n]*|"""[\s\S]*?"""|'''[\s\S]*?'''|"(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*'|[A-Za-z_$][\w$]*|\d[\w.]*|\S/g
+    : /\/\/[^\n]*|\/\*[\s\S]*?\*\/|"(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*'|`(?:\\.|[^`\\])*`|[A-Za-z_$][\w$]*|\d[\w.]*|\S/g;
+
+  const tokens: Token[] = [];
+  let line = 1;
+  let lastIndex = 0;
+  let match: RegExpExecArray | null;
+
+  while ((match = regex.exec(content)) !== null) {
+    for (let i = lastIndex; i < match.index; i++) {
+      if (content.charCodeAt(i) === 10) line++;
+    }
+
+    const text = match[0];
+    const startLine = line;
+
+    // Advance line count past multi-line tokens
+    for (let i = 0; i < text.length; i++) {
+      if (text.charCodeAt(i) === 10) line++;
+    }
+    lastIndex = match.index + text.length;
+
+    const first = text[0];
+    if ((text.startsWith('//') || text.startsWith('/*')) && !hashComments) {
+      continue;
+    }
+    if (first === '#' && hashComments) {
+      continue;
+    }
+
+    let value = text;
+    if (first === '"' || first === "'" || first === '`') {
+      value = 'STR';
+    } else if (/^\d/.test(text)) {
+      value = 'NUM';
+    } else if (normalizeIdentifiers && /^[A-Za-z_$]/.test(text) && !KEYWORDS.has(text)) {
+      value = 'ID';
+    }
+    tokens.push({ value, line: startLine });
+  }
+
+  return tokens;
+}
+
+/**
+ * 32-bit FNV-1a hash of a token k-gram
+ */
+export function hashKGram(tokens: Token[], start: number, k: number): number {
+  let hash = 0x811c9dc5;
+  for (let i = start; i < start + k; i++) {
+    const value = tokens[i].value;
+    for (let j = 0; j < value.length; j++) {
+      hash ^= value.charCodeAt(j);
+      hash = Math.imul(hash, 0x01000193);
+    }
+    hash ^= 0x20;
+    hash = Math.imul(hash, 0x01000193);
+  }
+  return hash >>> 0;
+}
+
+/**
+ * Select fingerprints with the winnowing algorithm
+ * Any shared run of at least k + w - 1 tokens is guaranteed to share a fingerprint
+ */
+export function winnow(tokens: Token[], k: number, w: number): Fingerprint[] {
+  if (tokens.length < k) {
+    return [];
+  }
+
+  const hashes: number[] = [];
+  for (let i = 0; i + k <= tokens.length; i++) {
+    hashes.push(hashKGram(tokens, i, k));
+  }
+
+  const fingerprints: Fingerprint[] = [];
+  let lastSelected = -1;
+  const windowCount = Math.max(1, hashes.length - w + 1);
+
+  for (let start = 0; start < windowCount; start++) {
+    const end = Math.min(hashes.length, start + w);
+    // Rightmost minimal hash in the window
+    let minIdx = start;
+    for (let i = start; i < end; i++) {
+      if (hashes[i] <= hashes[minIdx]) {
+        minIdx = i;
+      }
+    }
+    if (minIdx !== lastSelected) {
+      fingerprints.push({ hash: hashes[minIdx], position: minIdx });
+      lastSelected = minIdx;
+    }
+  }
+
+  return fingerprints;
+}
+
+/**
+ * Tokenized file
+ */
+interface TokenizedFile {
+  path: string;
+  tokens: Token[];
+}
+
+/**
+ * Find duplicated regions among tokenized files
+ */
+export function findDuplicatePairs(
+  files: TokenizedFile[],
+  minTokens: number
+): DuplicatePair[] {
+  const k = Math.max(5, Math.floor(minTokens / 2));
+  const w = Math.max(1, minTokens - k + 1);
+
+  // Index fingerprints by hash
+  const index = new Map<number, Array<{ file: number; position: number }>>();
+  files.forEach((file, fileIdx) => {
+    for (const fp of winnow(file.tokens, k, w)) {
+      let entries = index.get(fp.hash);
+      if (!entries) {
+        entries = [];
+        index.set(fp.hash, entries);
+      }
+      entries.push({ file: fileIdx, position: fp.position });
+    }
+  });
+
+  const pairs: DuplicatePair[] = [];
+  const seen = new Set<string>();
+
+  for (const entries of index.values()) {
+    // Skip extremely common fingerprints (boilerplate)
+    if (entries.length < 2 || entries.length > 50) {
+      continue;
+    }
+
+    for (let i = 0; i < entries.length; i++) {
+      for (let j = i + 1; j < entries.length; j++) {
+        let a = entries[i];
+        let b = entries[j];
+        if (a.file > b.file || (a.file === b.file && a.position > b.position)) {
+          [a, b] = [b, a];
+        }
+
+        const tokensA = files[a.file].tokens;
+        const tokensB = files[b.file].tokens;
+
+        // Extend the match backwards and forwards
+        let startA = a.position;
+        let startB = b.position;
+        while (startA > 0 && startB > 0 && tokensA[startA - 1].value === tokensB[startB - 1].value) {
+          if (a.file === b.file && startB - 1 <= startA) break;
+          startA--;
+          startB--;
+        }
+        let length = 0;
+        while (
+          startA + length < tokensA.length &&
+          startB + length < tokensB.length &&
+          tokensA[startA + length].value === tokensB[startB + length].value &&
+          !(a.file === b.file && startA + length >= startB)
+        ) {
+          length++;
+        }
+
+        if (length < minTokens) {
+          continue;
+        }
+
+        // Several fingerprints of the same region extend to the same match
+        const key = `${a.file}:${startA}:${b.file}:${startB}`;
+        if (seen.has(key)) {
+          continue;
+        }
+        seen.add(key);
+
+        pairs.push({
+          fileA: files[a.file].path,
+          startLineA: tokensA[startA].line,
+          endLineA: tokensA[startA + length - 1].line,
+          fileB: files[b.file].path,
+          startLineB: tokensB[startB].line,
+          endLineB: tokensB[startB + length - 1].line,
+          tokens: length,
+        });
+      }
+    }
+  }
+
+  // Keep the largest pair of each group of overlapping matches
+  pairs.sort((x, y) => y.tokens - x.tokens);
+  const overlaps = (p: DuplicatePair, q: DuplicatePair): boolean =>
+    p.fileA === q.fileA && p.fileB === q.fileB &&
+    p.startLineA <= q.endLineA && q.startLineA <= p.endLineA &&
+    p.startLineB <= q.endLineB && q.startLineB <= p.endLineB;
+
+  const result: DuplicatePair[] = [];
+  for (const pair of pairs) {
+    if (!result.some((kept) => overlaps(kept, pair))) {
+      result.push(pair);
+    }
+  }
+  return result;
+}
+
+/**
+ * Detect near-duplicate code blocks in the workspace
+ */
+export async function findDuplicates(
+  workspaceDir: string,
+  options: DuplicateOptions = {}
+): Promise<string> {
+  const minTokens = Math.max(10, options.minTokens ?? 50);
+  const normalizeIdentifiers = options.normalizeIdentifiers ?? true;
+  const maxResults = options.maxResults ?? 50;
+
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: options.path }))
+    .filter((f) => isSourceFile(f.absolutePath));
+
+  // Generated code is repetitive by nature and would crowd out real duplication
+  const generated = options.includeGenerated ? undefined : new GeneratedFileDetector(workspaceDir);
+
+  const tokenized: TokenizedFile[] = [];
+  for (const file of files) {
+    try {
+      const content = await readFileText(file.absolutePath);
+      if (generated && generated.isGenerated(file.relativePath, content)) {
+        continue;
+      }
+      const tokens = tokenize(content, detectLanguageId(file.absolutePath), normalizeIdentifiers);
+      if (tokens.length >= minTokens) {
+        tokenized.push({ path: file.relativePath, tokens });
+      }
+    } catch (err) {
+      toolsLogger.debug('Error reading file %s: %s', file.absolutePath, err);
+    }
+  }
+
+  toolsLogger.debug('Fingerprinting %d files with minTokens=%d', tokenized.length, minTokens);
+  const pairs = findDuplicatePairs(tokenized, minTokens);
+
+  if (pairs.length === 0) {
+    return `No duplicate blocks of ${minTokens}+ tokens found in ${tokenized.length} file(s)`;
+  }
+
+  let output = `Found ${pairs.length} duplicate block(s) of ${minTokens}+ tokens`;
+  if (pairs.length > maxResults) {
+    output += `, showing the ${maxResults} largest`;
+  }
+  output += '\n\n';
+
+  for (const pair of pairs.slice(0, maxResults)) {
+    output += `---\n\n${pair.tokens} tokens\n`;
+    output += `  ${pair.fileA}:L${pair.startLineA}-L${pair.endLineA}\n`;
+    output += `  ${pair.fileB}:L${pair.startLineB}-L${pair.endLineB}\n\n`;
+  }
+
+  return output;
+}
@@ -6,6 +6,9 @@ import * as fs from 'fs';
 import { LSPClient } from '../lsp/client.js';
 import { WorkspaceEdit, TextEdit as LSPTextEdit, Range, Position } from '../protocol/types.js';
 import { pathToUri } from '../protocol/uri.js';
+import { assertNoOverlay } from '../workspace/overlay.js';
+import { currentFileHash } from './read.js';
+import { ToolError } from '../workspace/errors.js';
 
 /**
  * Text edit input format
@@ -18,18 +21,27 @@ export interface TextEdit {
 
 /**
  * Apply text edits to a file
+ * With expectedHash (from read_range), the edits are refused when the file changed since it was read
  */
 export async function applyTextEdits(
   client: LSPClient,
   filePath: string,
-  edits: TextEdit[]
+  edits: TextEdit[],
+  expectedHash?: string
 ): Promise<string> {
+  assertNoOverlay(filePath, 'edit');
+  if (expectedHash) {
+    const hash = await currentFileHash(filePath);
+    if (hash !== expectedHash) {
+      throw new ToolError('invalid-argument', `${filePath} changed since it was read (hash ${hash}, expected ${expectedHash}); read it again before editing`);
+    }
+  }
   const uri = pathToUri(filePath);
 
   try {
     await client.openFile(filePath);
   } catch (err) {
-    throw new Error(`Could not open file: ${err}`);
+    throw new ToolError('not-found', `Could not open file: ${err}`);
   }
 
   // Sort edits for reporting (ascending order)
@@ -90,7 +102,7 @@ async function getRange(filePath: string, startLine: number, endLine: number): P
 
   // Validate start line
   if (startLine < 1) {
-    throw new Error(`Start line must be >= 1, got ${startLine}`);
+    throw new ToolError('invalid-argument', `Start line must be >= 1, got ${startLine}`);
   }
 
   // Convert to 0-based line numbers
@@ -0,0 +1,53 @@
+/**
+ * Tests for match context enrichment
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { moduleOf } from './enrich';
+import { searchCode } from './search';
+import { getFileSymbols } from './symbols';
+
+describe('match context', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'enrich-'));
+    fs.mkdirSync(path.join(workspace, 'app'));
+    fs.writeFileSync(path.join(workspace, 'app', 'store.py'), [
+      'class Store:',
+      '    def save(self, item):',
+      '        validate(item)',
+      '        validate(item.key)',
+      '',
+      'def load(path):',
+      '    return validate(path)',
+    ].join('\n') + '\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should name the module and the enclosing symbol of matches', async () => {
+    const result = await searchCode(workspace, 'validate(', { symbols: (filePath) => getFileSymbols(undefined, workspace, filePath) });
+    const section = result.split('---\n\n')[1];
+    expect(section).toContain('Module: module app.store\n');
+    const lines = section.split('\n').filter((line) => line.startsWith('In ') || line.startsWith('L'));
+    expect(lines.map((line) => line.split(': ')[0])).toEqual([
+      'In Method Store.save (L2)', 'L3:C9', 'L4:C9',
+      'In Function load (L6)', 'L7:C12',
+    ]);
+    expect(lines[0]).toBe('In Method Store.save (L2): def save(self, item)');
+    expect(await searchCode(workspace, 'validate(')).not.toContain('Module:');
+  });
+
+  it('should read packages from declarations and paths', () => {
+    expect(moduleOf(path.join('api', 'server.go'), '// Package api\npackage api\n')).toBe('package api');
+    expect(moduleOf(path.join('src', 'main', 'Foo.java'), 'package com.example.app;\n')).toBe('package com.example.app');
+    expect(moduleOf(path.join('pkg', '__init__.py'), '')).toBe('module pkg');
+    expect(moduleOf(path.join('crates', 'net', 'src', 'tcp', 'mod.rs'), '')).toBe('crate::tcp');
+    expect(moduleOf('README.md', '')).toBeUndefined();
+  });
+});
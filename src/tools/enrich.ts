@@ -0,0 +1,126 @@
+/**
+ * Match context enrichment - the enclosing symbol and package of each search match
+ * Often answers what a match is part of without opening the file
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { SymbolKindNames } from '../protocol/types.js';
+import { LexicalMatch } from '../search/lexical.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { createLimiter } from '../workspace/pool.js';
+import { FlatSymbol, findEnclosingSymbol } from './symbols.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Longest signature shown; longer declaration lines are cut
+ */
+const MAX_SIGNATURE_LENGTH = 200;
+
+/**
+ * Package, module, or namespace a file belongs to, from its declaration or its path
+ */
+export function moduleOf(relativePath: string, content: string): string | undefined {
+  const languageId = detectLanguageId(relativePath);
+  const withoutExt = relativePath.slice(0, relativePath.length - path.extname(relativePath).length).split(path.sep);
+  switch (languageId) {
+    case 'go': {
+      const match = content.match(/^package\s+(\w+)/m);
+      return match ? `package ${match[1]}` : undefined;
+    }
+    case 'java':
+    case 'kotlin':
+    case 'scala': {
+      const match = content.match(/^\s*package\s+([\w.]+)/m);
+      return match ? `package ${match[1]}` : undefined;
+    }
+    case 'csharp': {
+      const match = content.match(/^\s*namespace\s+([\w.]+)/m);
+      return match ? `namespace ${match[1]}` : undefined;
+    }
+    case 'python': {
+      const parts = withoutExt[withoutExt.length - 1] === '__init__' ? withoutExt.slice(0, -1) : withoutExt;
+      return parts.length > 0 ? `module ${parts.join('.')}` : undefined;
+    }
+    case 'rust': {
+      const src = withoutExt.lastIndexOf('src');
+      const parts = withoutExt.slice(src + 1);
+      if (['main', 'lib', 'mod'].includes(parts[parts.length - 1])) {
+        parts.pop();
+      }
+      return ['crate', ...parts].join('::');
+    }
+    case 'javascript':
+    case 'javascriptreact':
+    case 'typescript':
+    case 'typescriptreact':
+      return `module ${withoutExt.join('/')}`;
+    default:
+      return undefined;
+  }
+}
+
+/**
+ * Signature of a symbol: its declaration line, without a trailing opening brace
+ */
+export function signatureOf(symbol: FlatSymbol, lines: string[]): string | undefined {
+  const line = lines[symbol.selectionRange.start.line]?.trim().replace(/\s*[{:]\s*$/, '');
+  if (!line) {
+    return symbol.detail;
+  }
+  return line.length > MAX_SIGNATURE_LENGTH ? `${line.substring(0, MAX_SIGNATURE_LENGTH)}…` : line;
+}
+
+/**
+ * Add the enclosing symbol and the module of each match
+ * symbolsOf returns a file's symbols; files it fails on keep only their module.
+ * Archive entries are left as they are.
+ */
+export async function enrichMatches(
+  workspaceDir: string,
+  matches: LexicalMatch[],
+  symbolsOf: (filePath: string) => Promise<FlatSymbol[]>
+): Promise<void> {
+  const byFile = new Map<string, LexicalMatch[]>();
+  for (const match of matches) {
+    byFile.set(match.filePath, [...(byFile.get(match.filePath) ?? []), match]);
+  }
+  const limit = createLimiter(8);
+  await Promise.all([...byFile.entries()].map(([relativePath, fileMatches]) => limit(async () => {
+    if (relativePath.includes('!/') || fileMatches[0].byteOffset !== undefined) {
+      return;
+    }
+    const filePath = path.join(workspaceDir, relativePath);
+    let content: string;
+    try {
+      content = await readFileText(filePath);
+    } catch (err) {
+      return;
+    }
+    const module = moduleOf(relativePath, content);
+    let symbols: FlatSymbol[] = [];
+    try {
+      symbols = await symbolsOf(filePath);
+    } catch (err) {
+      toolsLogger.debug('No symbols for %s: %s', relativePath, (err as Error).message);
+    }
+    const lines = content.split('\n');
+    for (const match of fileMatches) {
+      if (module) {
+        match.module = module;
+      }
+      // Notebook cell lines do not count from the top of the file
+      const symbol = match.cell ? undefined : findEnclosingSymbol(symbols, match.line - 1);
+      if (symbol) {
+        match.enclosing = {
+          name: symbol.qualifiedName,
+          kind: SymbolKindNames[symbol.kind] || 'Unknown',
+          signature: signatureOf(symbol, lines),
+          line: symbol.selectionRange.start.line + 1,
+        };
+      }
+    }
+  })));
+}
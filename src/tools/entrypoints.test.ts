@@ -0,0 +1,50 @@
+/**
+ * Tests for the entry points tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { listEntryPoints } from './entrypoints';
+
+describe('entry_points', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'entrypoints-'));
+    const files: Record<string, string> = {
+      'cmd/server/main.go': 'package main\n\nfunc main() {\n\tmux.HandleFunc("/users", listUsers)\n}\n',
+      'cmd/server/main_test.go': 'package main\n\nfunc TestUsers(t *testing.T) {}\nfunc TestMain(m *testing.M) {}\n',
+      'README.md': 'func main() {}\n',
+    };
+    for (const [file, content] of Object.entries(files)) {
+      fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, file), content);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should group entry points by kind and count tests per file', async () => {
+    const testFile = path.join('cmd', 'server', 'main_test.go');
+    expect(await listEntryPoints(workspace)).toBe([
+      'Main entry points (1)',
+      `  main (package main) - ${path.join('cmd', 'server', 'main.go')}:3`,
+      '',
+      'HTTP routes (1)',
+      `  /users - ${path.join('cmd', 'server', 'main.go')}:4`,
+      '',
+      'Tests (2)',
+      `  ${testFile}: 2`,
+      '  (pass kind: "test" to list them)',
+    ].join('\n'));
+    expect(await listEntryPoints(workspace, { kind: 'test' })).toBe(`Tests (2)\n  TestUsers - ${testFile}:3\n  TestMain - ${testFile}:4`);
+  });
+
+  it('should report when nothing matches and reject unknown kinds', async () => {
+    expect(await listEntryPoints(workspace, { kind: 'command' })).toBe('No entry points of kind "command" found');
+    await expect(listEntryPoints(workspace, { kind: 'job' as never })).rejects.toThrow('Unknown kind');
+  });
+});
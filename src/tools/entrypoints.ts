@@ -0,0 +1,82 @@
+/**
+ * Entry points tool - where programs start and where requests come in
+ * Lists main functions, HTTP routes, CLI commands, and tests, grouped by kind,
+ * as the places to start reading an unfamiliar repository
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { EntryPoint, EntryPointKind, findEntryPoints } from '../workspace/entrypoints.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the entry points tool
+ */
+export interface EntryPointOptions {
+  path?: string;
+  // Only entry points of this kind; tests are listed one by one only when asked for
+  kind?: EntryPointKind;
+}
+
+const KIND_TITLES: Record<EntryPointKind, string> = {
+  main: 'Main entry points',
+  route: 'HTTP routes',
+  command: 'CLI commands',
+  test: 'Tests',
+};
+
+/**
+ * Entry points listed before the rest of a kind is summarized
+ */
+const MAX_ENTRIES_PER_KIND = 200;
+
+function formatEntry(entry: EntryPoint): string {
+  const location = entry.line > 0 ? `${entry.filePath}:${entry.line}` : entry.filePath;
+  const detail = entry.detail ? ` (${entry.detail})` : '';
+  return `  ${entry.name}${detail} - ${location}`;
+}
+
+/**
+ * List the workspace's entry points grouped by kind
+ */
+export async function listEntryPoints(workspaceDir: string, options: EntryPointOptions = {}): Promise<string> {
+  if (options.kind && !(options.kind in KIND_TITLES)) {
+    throw new ToolError('invalid-argument', `Unknown kind "${options.kind}"; use one of ${Object.keys(KIND_TITLES).join(', ')}`);
+  }
+  toolsLogger.debug('Listing entry points (kind: %s)', options.kind ?? 'all');
+  const entries = (await findEntryPoints(workspaceDir, options.path))
+    .filter((entry) => !options.kind || entry.kind === options.kind);
+  if (entries.length === 0) {
+    return options.kind ? `No entry points of kind "${options.kind}" found` : 'No entry points found';
+  }
+
+  const sections: string[] = [];
+  for (const kind of Object.keys(KIND_TITLES) as EntryPointKind[]) {
+    const ofKind = entries.filter((entry) => entry.kind === kind);
+    if (ofKind.length === 0) {
+      continue;
+    }
+    const lines = [`${KIND_TITLES[kind]} (${ofKind.length})`];
+    if (kind === 'test' && options.kind !== 'test') {
+      // Tests are many; count them per file unless they are what was asked for
+      const perFile = new Map<string, number>();
+      for (const entry of ofKind) {
+        perFile.set(entry.filePath, (perFile.get(entry.filePath) ?? 0) + 1);
+      }
+      const files = [...perFile.entries()].sort((a, b) => b[1] - a[1] || a[0].localeCompare(b[0]));
+      lines.push(...files.slice(0, MAX_ENTRIES_PER_KIND).map(([file, count]) => `  ${file}: ${count}`));
+      if (files.length > MAX_ENTRIES_PER_KIND) {
+        lines.push(`  … ${files.length - MAX_ENTRIES_PER_KIND} more files`);
+      }
+      lines.push('  (pass kind: "test" to list them)');
+    } else {
+      lines.push(...ofKind.slice(0, MAX_ENTRIES_PER_KIND).map(formatEntry));
+      if (ofKind.length > MAX_ENTRIES_PER_KIND) {
+        lines.push(`  … ${ofKind.length - MAX_ENTRIES_PER_KIND} more; pass a path to narrow`);
+      }
+    }
+    sections.push(lines.join('\n'));
+  }
+  return sections.join('\n\n');
+}
@@ -0,0 +1,78 @@
+/**
+ * Tests for the environment variables tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { listEnvVars, scanEnvReads } from './envvars';
+
+function reads(relativePath: string, content: string): string[] {
+  return scanEnvReads(relativePath, content).map((read) =>
+    `${read.name} ${read.accessor}${read.defaultValue !== undefined ? ` =${read.defaultValue}` : ''} :${read.line}`);
+}
+
+describe('Environment variables', () => {
+  it('should find Go reads, helpers with defaults, and struct tags', () => {
+    const source = [
+      'dsn := os.Getenv("DATABASE_URL")',
+      'port := cmp.Or(os.Getenv("PORT"), "8080")',
+      'level := getEnv("LOG_LEVEL", "info")',
+      'os.Setenv("TZ", "UTC")',
+      'type Config struct {',
+      '\tTimeout time.Duration `env:"TIMEOUT" envDefault:"5s"`',
+      '}',
+    ].join('\n');
+    expect(reads('main.go', source)).toEqual([
+      'DATABASE_URL os.Getenv :1',
+      'PORT os.Getenv =8080 :2',
+      'LOG_LEVEL getEnv =info :3',
+      'TIMEOUT env tag =5s :6',
+    ]);
+  });
+
+  it('should find JavaScript, Python, Rust, and Ruby reads', () => {
+    expect(reads('app.ts', "const port = process.env.PORT || 3000;\nconst { HOST = 'localhost', DEBUG } = process.env;\nkey = process.env['API_KEY'];\n")).toEqual([
+      'PORT process.env =3000 :1',
+      'HOST process.env =localhost :2',
+      'DEBUG process.env :2',
+      'API_KEY process.env :3',
+    ]);
+    expect(reads('settings.py', "SECRET = os.environ['SECRET_KEY']\nDEBUG = os.environ.get('DEBUG', 'false')\n")).toEqual([
+      'SECRET_KEY os.environ :1',
+      'DEBUG os.environ.get =false :2',
+    ]);
+    expect(reads('main.rs', 'let home = env::var("HOME").unwrap_or("/tmp".into());\n')).toEqual(['HOME env::var =/tmp :1']);
+    expect(reads('config.rb', "ENV.fetch('RAILS_ENV', 'development')\n")).toEqual(['RAILS_ENV ENV =development :1']);
+  });
+
+  describe('listEnvVars', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'envvars-'));
+      fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nvar port = cmp.Or(os.Getenv("PORT"), "8080")\n');
+      fs.writeFileSync(path.join(workspace, 'server.js'), 'const port = process.env.PORT ?? "3000";\n');
+      fs.writeFileSync(path.join(workspace, '.env.example'), '# Copy to .env\nPORT=8080\nSENTRY_DSN=\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should group reads by name with their defaults and documentation', async () => {
+      expect(await listEnvVars(workspace)).toBe([
+        'Environment variables: 2 (2 read(s) in code)',
+        '',
+        'PORT - defaults "8080", "3000", documented',
+        '  main.go:3 os.Getenv (default "8080")',
+        '  server.js:1 process.env (default "3000")',
+        '  .env.example:2 documented (default "8080")',
+        '',
+        'SENTRY_DSN - no default, documented, not read in code',
+        '  .env.example:3 documented',
+      ].join('\n'));
+      expect(await listEnvVars(workspace, { name: 'missing' })).toBe('No environment variables matching "missing" found');
+    });
+  });
+});
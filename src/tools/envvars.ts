@@ -0,0 +1,220 @@
+/**
+ * Environment variables tool - the configuration surface read from the environment
+ * Lists every environment variable the code reads (os.Getenv, process.env.X,
+ * os.environ[...], env::var, System.getenv, ENV[...], struct tags of env
+ * config libraries), with where it is read and the default it falls back to,
+ * together with the variables documented in .env.example files
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the environment variables tool
+ */
+export interface EnvVarOptions {
+  path?: string;
+  // Only variables whose name contains this, ignoring case
+  name?: string;
+}
+
+/**
+ * A read of an environment variable
+ */
+export interface EnvRead {
+  name: string;
+  // How it is read, e.g. os.Getenv or process.env; "documented" for .env.example entries
+  accessor: string;
+  defaultValue?: string;
+  filePath: string;
+  line: number; // 1-indexed
+}
+
+/**
+ * A literal default: a string, a number, or a boolean
+ */
+const LITERAL = String.raw`('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|\x60[^\x60$]*\x60|-?\d+(?:\.\d+)?|true|false|True|False)`;
+
+interface EnvRule {
+  languages: string[];
+  accessor: string;
+  // Group 1 is the name; group 2, when present, the default
+  pattern: RegExp;
+  // Default following the read, matched against the rest of the line
+  after?: RegExp;
+}
+
+const JS = ['javascript', 'javascriptreact', 'typescript', 'typescriptreact'];
+
+const RULES: EnvRule[] = [
+  { languages: ['go'], accessor: 'os.Getenv', pattern: /\bos\.(?:Getenv|LookupEnv)\(\s*"([^"]+)"\s*\)/g, after: new RegExp(String.raw`^\s*,\s*${LITERAL}`) },
+  { languages: ['go'], accessor: 'env tag', pattern: /\benv:"([A-Za-z_]\w*)[^"]*"(?:.*?\benvDefault:"([^"]*)")?/g },
+  { languages: ['go'], accessor: 'envconfig tag', pattern: /\benvconfig:"([A-Za-z_]\w*)"(?:.*?\bdefault:"([^"]*)")?/g },
+  { languages: JS, accessor: 'process.env', pattern: /\bprocess\.env(?:\.([A-Za-z_]\w*)|\[\s*['"`]([^'"`]+)['"`]\s*\])/g, after: new RegExp(String.raw`^\s*(?:\|\||\?\?)\s*${LITERAL}`) },
+  { languages: JS, accessor: 'import.meta.env', pattern: /\bimport\.meta\.env\.([A-Za-z_]\w*)/g, after: new RegExp(String.raw`^\s*(?:\|\||\?\?)\s*${LITERAL}`) },
+  { languages: ['python'], accessor: 'os.environ', pattern: /\bos\.environ\[\s*['"]([^'"]+)['"]\s*\]/g },
+  { languages: ['python'], accessor: 'os.environ.get', pattern: new RegExp(String.raw`\bos\.(?:environ\.get|getenv)\(\s*['"]([^'"]+)['"]\s*(?:,\s*${LITERAL})?`, 'g') },
+  { languages: ['rust'], accessor: 'env::var', pattern: /\b(?:std::)?env::var(?:_os)?\(\s*"([^"]+)"\s*\)/g, after: new RegExp(String.raw`^\s*\.unwrap_or(?:_else)?\(\s*(?:\|_\|\s*)?${LITERAL}`) },
+  { languages: ['rust'], accessor: 'env!', pattern: /\b(?:option_)?env!\(\s*"([^"]+)"\s*\)/g },
+  { languages: ['java', 'kotlin', 'scala'], accessor: 'System.getenv', pattern: /\bSystem\.getenv\(\s*"([^"]+)"\s*\)/g, after: new RegExp(String.raw`^\s*(?:\?:|,)\s*${LITERAL}`) },
+  { languages: ['ruby'], accessor: 'ENV', pattern: new RegExp(String.raw`\bENV(?:\[\s*['"]([^'"]+)['"]\s*\]|\.fetch\(\s*['"]([^'"]+)['"]\s*(?:,\s*${LITERAL})?)`, 'g'), after: new RegExp(String.raw`^\s*\|\|\s*${LITERAL}`) },
+  { languages: ['shell'], accessor: '${:-}', pattern: /\$\{([A-Za-z_]\w*):?[-=]([^}]*)\}/g },
+];
+
+/**
+ * Names of .env files documenting the variables a program takes
+ * The walk skips dotfiles, so these are looked up in each directory it visits
+ */
+const DOCUMENTED_NAMES = ['.env.example', '.env.sample', '.env.template', '.env.dist', '.env.defaults', 'example.env'];
+
+/**
+ * Helpers wrapping a read with a default, e.g. getEnv("PORT", "8080") or cmp.Or(os.Getenv("PORT"), "8080")
+ */
+const HELPER = new RegExp(String.raw`\b(\w*[Ee]nv\w*)\(\s*['"]([A-Z][A-Z0-9_]*)['"]\s*,\s*${LITERAL}`, 'g');
+
+/**
+ * Value of a literal, without its quotes
+ */
+function literalValue(literal: string | undefined): string | undefined {
+  if (literal === undefined) {
+    return undefined;
+  }
+  return /^['"\x60]/.test(literal) ? literal.slice(1, -1) : literal;
+}
+
+/**
+ * Find the environment variable reads of one file
+ */
+export function scanEnvReads(relativePath: string, content: string): EnvRead[] {
+  const lines = content.split('\n');
+  if (DOCUMENTED_NAMES.includes(path.basename(relativePath))) {
+    const reads: EnvRead[] = [];
+    lines.forEach((text, i) => {
+      const entry = /^\s*(?:export\s+)?([A-Za-z_]\w*)\s*=\s*(.*?)\s*$/.exec(text);
+      if (entry) {
+        reads.push({ name: entry[1], accessor: 'documented', defaultValue: literalValue(entry[2]) || undefined, filePath: relativePath, line: i + 1 });
+      }
+    });
+    return reads;
+  }
+
+  const languageId = detectLanguageId(relativePath);
+  const rules = RULES.filter((rule) => rule.languages.includes(languageId));
+  if (rules.length === 0) {
+    return [];
+  }
+  const reads: EnvRead[] = [];
+  lines.forEach((text, i) => {
+    const found = new Set<string>();
+    for (const rule of rules) {
+      for (const match of text.matchAll(rule.pattern)) {
+        const groups = match.slice(1);
+        const name = groups.find((group) => group !== undefined)!;
+        let defaultValue = literalValue(groups[groups.indexOf(name) + 1]);
+        if (defaultValue === undefined && rule.after) {
+          const rest = text.substring(match.index! + match[0].length);
+          // In Go only cmp.Or-style wrappers put a default after the read
+          if (languageId !== 'go' || /\bOr\(\s*$/.test(text.substring(0, match.index))) {
+            defaultValue = literalValue(rule.after.exec(rest)?.[1]);
+          }
+        }
+        found.add(name);
+        reads.push({ name, accessor: rule.accessor, defaultValue, filePath: relativePath, line: i + 1 });
+      }
+    }
+    if (languageId === 'shell') {
+      return;
+    }
+    for (const match of text.matchAll(HELPER)) {
+      // Setenv and putenv write the variable
+      if (!found.has(match[2]) && !/set|put/i.test(match[1])) {
+        reads.push({ name: match[2], accessor: match[1], defaultValue: literalValue(match[3]), filePath: relativePath, line: i + 1 });
+      }
+    }
+    if (JS.includes(languageId)) {
+      // const { PORT = '3000', HOST } = process.env
+      const destructured = /\{([^}]*)\}\s*=\s*process\.env\b/.exec(text);
+      for (const part of destructured?.[1].split(',') ?? []) {
+        const binding = new RegExp(String.raw`^\s*([A-Za-z_]\w*)(?:\s*:\s*\w+)?(?:\s*=\s*${LITERAL})?\s*$`).exec(part);
+        if (binding) {
+          reads.push({ name: binding[1], accessor: 'process.env', defaultValue: literalValue(binding[2]), filePath: relativePath, line: i + 1 });
+        }
+      }
+    }
+  });
+  return reads;
+}
+
+/**
+ * Find the environment variable reads under a path
+ */
+export async function findEnvReads(workspaceDir: string, options: EnvVarOptions = {}): Promise<EnvRead[]> {
+  const walked = await walkWorkspaceFiles(workspaceDir, { pathPrefix: options.path });
+  const files: Array<{ absolutePath: string; relativePath: string }> = walked
+    .filter((file) => detectLanguageId(file.relativePath) !== 'plaintext');
+  const dirs = new Set([resolveWorkspacePath(workspaceDir, options.path ?? '.'), ...walked.map((file) => path.dirname(file.absolutePath))]);
+  for (const dir of dirs) {
+    for (const name of DOCUMENTED_NAMES) {
+      const absolutePath = path.join(dir, name);
+      if (fs.existsSync(absolutePath)) {
+        files.push({ absolutePath, relativePath: path.relative(workspaceDir, absolutePath) });
+      }
+    }
+  }
+
+  const needle = options.name?.toLowerCase();
+  const reads: EnvRead[] = [];
+  for (const file of files) {
+    try {
+      const content = await readFileText(file.absolutePath);
+      reads.push(...scanEnvReads(file.relativePath, content).filter((read) => !needle || read.name.toLowerCase().includes(needle)));
+    } catch (err) {
+      toolsLogger.debug('Could not scan %s: %s', file.relativePath, (err as Error).message);
+    }
+  }
+  return reads;
+}
+
+function quote(value: string): string {
+  return JSON.stringify(value);
+}
+
+/**
+ * List the environment variables read in the workspace, by name
+ * Each variable shows the defaults it is read with, then each place it is read
+ */
+export async function listEnvVars(workspaceDir: string, options: EnvVarOptions = {}): Promise<string> {
+  toolsLogger.debug('Listing environment variables (name: %s)', options.name ?? 'all');
+  const reads = await findEnvReads(workspaceDir, options);
+  if (reads.length === 0) {
+    return options.name ? `No environment variables matching "${options.name}" found` : 'No environment variable reads found';
+  }
+
+  const byName = new Map<string, EnvRead[]>();
+  for (const read of reads) {
+    byName.set(read.name, [...(byName.get(read.name) ?? []), read]);
+  }
+  const names = [...byName.keys()].sort();
+  const inCode = reads.filter((read) => read.accessor !== 'documented').length;
+  const sections = [`Environment variables: ${names.length} (${inCode} read(s) in code)`];
+  for (const name of names) {
+    const ofName = byName.get(name)!;
+    const defaults = [...new Set(ofName.map((read) => read.defaultValue).filter((value): value is string => value !== undefined))];
+    const summary = defaults.length === 0 ? 'no default' : `default${defaults.length > 1 ? 's' : ''} ${defaults.map(quote).join(', ')}`;
+    const documented = ofName.some((read) => read.accessor === 'documented') ? ', documented' : '';
+    const unread = ofName.every((read) => read.accessor === 'documented') ? ', not read in code' : '';
+    const lines = [`${name} - ${summary}${documented}${unread}`];
+    for (const read of ofName) {
+      const value = read.defaultValue !== undefined ? ` (default ${quote(read.defaultValue)})` : '';
+      lines.push(`  ${read.filePath}:${read.line} ${read.accessor}${value}`);
+    }
+    sections.push(lines.join('\n'));
+  }
+  return sections.join('\n\n');
+}
@@ -0,0 +1,73 @@
+/**
+ * Tests for the explain tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { explainQuery, formatExplanation } from './explain';
+import { TrigramIndex } from '../search/trigram';
+
+describe('Explain tool', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'explain-')));
+    fs.mkdirSync(path.join(workspace, 'src'));
+    fs.mkdirSync(path.join(workspace, 'node_modules', 'dep'), { recursive: true });
+    fs.mkdirSync(path.join(workspace, 'logs'));
+    fs.writeFileSync(path.join(workspace, '.gitignore'), 'logs/\n');
+    fs.writeFileSync(path.join(workspace, 'src', 'server.go'), 'func handleRequest() {}\n');
+    fs.writeFileSync(path.join(workspace, 'src', 'client.go'), 'func send() {}\n');
+    fs.writeFileSync(path.join(workspace, 'src', 'notes.md'), 'handleRequest is documented here\n');
+    fs.writeFileSync(path.join(workspace, 'src', 'logo.png'), 'png');
+    fs.writeFileSync(path.join(workspace, 'node_modules', 'dep', 'index.js'), 'handleRequest');
+    fs.writeFileSync(path.join(workspace, 'logs', 'out.txt'), 'handleRequest');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should use the trigram index for literals and count what each rule pruned', async () => {
+    const explanation = await explainQuery(workspace, 'search_code', 'handleRequest', { glob: ['**/*.go'] }, {
+      index: new TrigramIndex(workspace),
+    });
+    expect(explanation.strategy).toBe('trigram');
+    expect(explanation.reason).toBe('2 of 3 indexed file(s) in scope contain every trigram of the pattern');
+    expect(explanation.filesInScope).toBe(3);
+    expect(explanation.candidates).toBe(1);
+    expect(explanation.candidateBytes).toBe('func handleRequest() {}\n'.length);
+
+    const pruned = Object.fromEntries(explanation.pruned.map((count) => [count.rule, count]));
+    expect(pruned.hidden).toMatchObject({ files: 1, examples: ['.gitignore'] });
+    expect(pruned.excludedDir).toMatchObject({ directories: 1, examples: [`node_modules${path.sep}`] });
+    expect(pruned.excludedExtension).toMatchObject({ files: 1 });
+    expect(pruned.gitignore).toMatchObject({ directories: 1, examples: [`logs${path.sep}`] });
+    expect(pruned.glob).toMatchObject({ files: 1, examples: [path.join('src', 'notes.md')] });
+
+    const text = formatExplanation(explanation, { glob: ['**/*.go'] });
+    expect(text).toContain('Strategy: trigram - 2 of 3 indexed file(s)');
+    expect(text).toContain('- not matching the globs ["**/*.go"]: 1 file(s)');
+    expect(text).toContain('Cost: 1 file(s) to read, 24 B');
+  });
+
+  it('should explain why a search scans', async () => {
+    const index = new TrigramIndex(workspace);
+    const regex = await explainQuery(workspace, 'search_code', 'handle\\w+', { regex: true }, { index });
+    expect(regex.strategy).toBe('scan');
+    expect(regex.reason).toContain('regular expressions are matched against every file');
+    expect(regex.candidates).toBe(3);
+
+    const short = await explainQuery(workspace, 'search_code', 'fu', { path: 'src' }, { index });
+    expect(short.reason).toContain('shorter than 3 characters');
+    expect(short.scope).toBe('src');
+  });
+
+  it('should explain symbol lookups', async () => {
+    const explanation = await explainQuery(workspace, 'definition', 'NewServer', {}, { symbolCached: () => true });
+    expect(explanation.strategy).toBe('symbol');
+    expect(formatExplanation(explanation)).toContain('The symbol lookup is cached');
+    await expect(explainQuery(workspace, 'semantic_search', 'retry logic')).rejects.toThrow('semantic search is disabled');
+  });
+});
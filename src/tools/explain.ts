@@ -0,0 +1,362 @@
+/**
+ * Explain tool - report how a query would be executed without running it
+ * Shows the index or scan that would be used, the files in scope, what the
+ * ignore rules, size limit, and globs pruned, and the files left to read,
+ * so incomplete-looking results can be traced to a rule
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { LexicalSearchOptions, scanExclusions } from '../search/lexical.js';
+import { TrigramIndex, extractTrigrams } from '../search/trigram.js';
+import { SemanticSearchEngine } from '../semantic/engine.js';
+import { ExclusionRule, resolveWorkspacePath, walkWorkspaceFiles } from '../workspace/walker.js';
+import { ToolError } from '../workspace/errors.js';
+import { matchesGlob } from '../workspace/glob.js';
+import { fixturePatterns, inSearchScope } from '../workspace/fixtures.js';
+import { hasCommentSyntax } from '../search/comments.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { formatBuildContext } from '../workspace/buildtags.js';
+import { workerCount } from '../workspace/pool.js';
+import { unpinned } from '../workspace/treesnapshot.js';
+import { SearchMode } from './semantic.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Tools whose queries can be explained
+ */
+export type ExplainTool = 'search_code' | 'semantic_search' | 'definition' | 'references';
+
+/**
+ * How the candidates are found
+ * - trigram: the trigram index narrows the files to read
+ * - scan: every file in scope is read
+ * - vector: the query embedding is compared with every chunk
+ * - hybrid: vector ranking fused with a trigram-indexed keyword search
+ * - symbol: the language server's symbol index is queried
+ */
+export type QueryStrategy = 'trigram' | 'scan' | 'vector' | 'hybrid' | 'symbol';
+
+/**
+ * Paths left out by one rule
+ */
+export interface PruneCount {
+  rule: ExclusionRule | 'size' | 'glob' | 'filter' | 'scope';
+  files: number;
+  directories: number;
+  examples: string[];
+}
+
+/**
+ * How a query would be executed
+ */
+export interface QueryExplanation {
+  tool: ExplainTool;
+  query: string;
+  strategy: QueryStrategy;
+  reason: string;
+  scope: string;
+  // Files the walk found in scope, before globs
+  filesInScope: number;
+  pruned: PruneCount[];
+  // Files (or chunks, for vector search) that would be examined
+  candidates: number;
+  candidateBytes: number;
+  workers: number;
+  notes: string[];
+}
+
+/**
+ * Search options plus the semantic search mode
+ */
+export interface ExplainOptions extends LexicalSearchOptions {
+  mode?: SearchMode;
+}
+
+/**
+ * What the server has available to run the query
+ */
+export interface ExplainContext {
+  index?: TrigramIndex;
+  semantic?: SemanticSearchEngine;
+  // Whether the language server cache already holds the symbol lookup
+  symbolCached?: (name: string) => boolean;
+}
+
+/**
+ * Paths listed per rule
+ */
+const MAX_EXAMPLES = 5;
+
+const RULE_LABELS: Record<PruneCount['rule'], string> = {
+  hidden: 'hidden files and directories',
+  excludedDir: 'excluded directories (WORKSPACE_EXCLUDE_DIRS and defaults)',
+  excludedExtension: 'excluded extensions (WORKSPACE_EXCLUDE_EXTENSIONS and defaults)',
+  excludedFile: 'lock files and minified bundles (WORKSPACE_EXCLUDE_FILES and defaults)',
+  gitignore: '.gitignore',
+  symlink: 'symbolic links (followSymlinks is off)',
+  size: 'over maxFileSize',
+  glob: 'not matching the globs',
+  filter: 'matching excludeGlob or outside the languages',
+  scope: 'outside the scope (code leaves out test fixtures and golden files, fixtures everything else, comments also languages without a known comment syntax)',
+};
+
+/**
+ * Why the trigram index would not be used for a search, or undefined when it would
+ */
+function scanReason(pattern: string, options: LexicalSearchOptions, index?: TrigramIndex): string | undefined {
+  if (!index) {
+    return 'no trigram index is available';
+  }
+  if (options.regex) {
+    return 'regular expressions are matched against every file; the trigram index only filters literals';
+  }
+  if (options.binary) {
+    return 'binary files are not in the trigram index';
+  }
+  if (options.archives) {
+    return 'archive entries are not in the trigram index';
+  }
+  if (options.followSymlinks !== undefined) {
+    return 'the trigram index is built with the default symlink policy, and followSymlinks was given';
+  }
+  if (options.includeGenerated) {
+    return 'lock files and minified bundles are not in the trigram index, and includeGenerated was given';
+  }
+  if (extractTrigrams(pattern).size === 0) {
+    return 'the pattern is shorter than 3 characters, so the trigram index cannot filter';
+  }
+  return undefined;
+}
+
+/**
+ * Explain a search_code query: walk the scope as the search would, counting what each rule prunes
+ */
+async function explainSearch(
+  workspaceDir: string,
+  pattern: string,
+  options: LexicalSearchOptions,
+  index?: TrigramIndex
+): Promise<QueryExplanation> {
+  const pruned = new Map<PruneCount['rule'], PruneCount>();
+  const prune = (rule: PruneCount['rule'], relativePath: string, isDirectory: boolean) => {
+    let count = pruned.get(rule);
+    if (!count) {
+      count = { rule, files: 0, directories: 0, examples: [] };
+      pruned.set(rule, count);
+    }
+    if (isDirectory) {
+      count.directories++;
+    } else {
+      count.files++;
+    }
+    if (count.examples.length < MAX_EXAMPLES) {
+      count.examples.push(isDirectory ? relativePath + path.sep : relativePath);
+    }
+  };
+
+  // The same walk searchLexical makes when it scans
+  const config = scanExclusions(options);
+  const walked = await walkWorkspaceFiles(workspaceDir, {
+    pathPrefix: options.path,
+    followSymlinks: options.followSymlinks,
+    config: { ...config, ...(options.maxFileSize ? { maxFileSize: options.maxFileSize } : {}) },
+    onLargeFile: (filePath) => prune('size', filePath, false),
+    onExcluded: (filePath, rule, isDirectory) => prune(rule, filePath, isDirectory),
+  });
+  const sizes = new Map(walked.map((file) => [file.relativePath, file.size]));
+
+  const reason = scanReason(pattern, options, index);
+  let files = walked.map((file) => file.relativePath);
+  let strategyReason: string;
+  if (reason) {
+    strategyReason = reason;
+  } else {
+    await unpinned(() => index!.refresh());
+    const { files: indexed } = index!.getStats();
+    // Candidates outside the walk (excluded, or over the size limit) are not read
+    files = (index!.candidates(pattern) ?? []).filter((file) => sizes.has(file));
+    strategyReason = `${files.length} of ${indexed} indexed file(s) in scope contain every trigram of the pattern`;
+  }
+
+  // Path filters in the order the search applies them, each reported against
+  // what the ones before it left of the whole scope, so counts do not depend on the strategy
+  const globs = options.glob && options.glob.length > 0 ? options.glob : undefined;
+  const excluded = options.excludeGlob && options.excludeGlob.length > 0 ? options.excludeGlob : undefined;
+  const languages = options.languages && options.languages.length > 0 ? new Set(options.languages) : undefined;
+  const searchScope = options.scope ?? 'all';
+  const patterns = searchScope !== 'all' ? fixturePatterns() : [];
+  const filters: Array<[PruneCount['rule'], (file: string) => boolean]> = [];
+  if (globs) {
+    filters.push(['glob', (file) => matchesGlob(file, globs)]);
+  }
+  if (excluded || languages) {
+    filters.push(['filter', (file) => (!excluded || !matchesGlob(file, excluded)) && (!languages || languages.has(detectLanguageId(file)))]);
+  }
+  if (searchScope !== 'all') {
+    filters.push(['scope', (file) => inSearchScope(file, searchScope, patterns) &&
+      (searchScope !== 'comments' || hasCommentSyntax(detectLanguageId(file)))]);
+  }
+  let remaining = walked.map((file) => file.relativePath);
+  for (const [rule, keep] of filters) {
+    remaining = remaining.filter((file) => {
+      if (keep(file)) {
+        return true;
+      }
+      prune(rule, file, false);
+      return false;
+    });
+    files = files.filter(keep);
+  }
+
+  const notes: string[] = [];
+  if (!options.includeGenerated) {
+    notes.push('Generated files are recognized while reading and skipped (set includeGenerated to search them and lock files)');
+  }
+  if (!options.binary) {
+    notes.push('Binary files are recognized while reading and skipped (set binary to search them)');
+  }
+  if (options.caseSensitive && !reason) {
+    notes.push('The trigram index is case-insensitive, so some candidates may only match in another case');
+  }
+  if (options.archives) {
+    notes.push('Archives are read in memory; their entries are not counted in the cost');
+  }
+  if (options.goBuild) {
+    notes.push(`Go files outside the ${formatBuildContext(options.goBuild)} build are read and then left out`);
+  }
+  notes.push(`Reading stops after ${(options.maxResults ?? 100) + 1} matches, so fewer files may be read`);
+  if (options.timeoutMs && options.timeoutMs > 0) {
+    notes.push(`Results are partial if the search runs past ${options.timeoutMs} ms`);
+  }
+
+  const scope = options.path
+    ? path.relative(workspaceDir, resolveWorkspacePath(workspaceDir, options.path)) || '.'
+    : '.';
+  return {
+    tool: 'search_code',
+    query: pattern,
+    strategy: reason ? 'scan' : 'trigram',
+    reason: strategyReason,
+    scope,
+    filesInScope: walked.length,
+    pruned: Array.from(pruned.values()),
+    candidates: files.length,
+    candidateBytes: files.reduce((sum, file) => sum + (sizes.get(file) ?? 0), 0),
+    workers: workerCount(),
+    notes,
+  };
+}
+
+/**
+ * Report how a query would be executed, without running it
+ */
+export async function explainQuery(
+  workspaceDir: string,
+  tool: ExplainTool,
+  query: string,
+  options: ExplainOptions = {},
+  context: ExplainContext = {}
+): Promise<QueryExplanation> {
+  toolsLogger.debug('Explaining %s query: %s', tool, query);
+  const base = { tool, query, scope: options.path ?? '.', filesInScope: 0, pruned: [], candidateBytes: 0, workers: 1 };
+
+  switch (tool) {
+    case 'search_code':
+      return explainSearch(workspaceDir, query, options, context.index);
+
+    case 'semantic_search': {
+      if (!context.semantic) {
+        throw new ToolError('workspace-not-indexed', 'semantic search is disabled (set SEMANTIC_SEARCH_ENABLED=true)');
+      }
+      const stats = context.semantic.getStats();
+      const hybrid = options.mode === 'hybrid';
+      return {
+        ...base,
+        strategy: hybrid ? 'hybrid' : 'vector',
+        reason: hybrid
+          ? 'the query embedding ranks every chunk, a trigram-indexed keyword search ranks them again, and the rankings are fused'
+          : 'the query is embedded once and compared with every chunk vector',
+        filesInScope: stats.files,
+        candidates: stats.chunks,
+        notes: [
+          'Changed files are re-embedded before the query runs',
+          ...(options.path ? ['Only chunks under the path are compared'] : []),
+        ],
+      };
+    }
+
+    case 'definition':
+    case 'references': {
+      const cached = context.symbolCached?.(query) ?? false;
+      return {
+        ...base,
+        scope: 'language server',
+        strategy: 'symbol',
+        reason: `the language server resolves ${query} with workspace/symbol, then asks for the ` +
+          (tool === 'definition' ? 'definition' : 'references') + ' of each match',
+        candidates: 0,
+        notes: [
+          cached ? 'The symbol lookup is cached, so the language server is not asked again' : 'The symbol lookup is not cached yet',
+          'Only files the language server has indexed are covered; ignore rules and globs do not apply',
+        ],
+      };
+    }
+  }
+}
+
+function formatBytes(bytes: number): string {
+  if (bytes < 1024) {
+    return `${bytes} B`;
+  }
+  return bytes < 1024 * 1024 ? `${(bytes / 1024).toFixed(1)} KB` : `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+}
+
+/**
+ * Format an explanation as text
+ */
+export function formatExplanation(explanation: QueryExplanation, options: ExplainOptions = {}): string {
+  const mode = [options.regex ? 'regex' : 'literal', options.caseSensitive ? 'case-sensitive' : 'case-insensitive'];
+  if (options.wholeWord) {
+    mode.push('whole word');
+  }
+  let output = `Query: ${JSON.stringify(explanation.query)} (${explanation.tool}`;
+  output += explanation.tool === 'search_code' ? `, ${mode.join(', ')})\n` : ')\n';
+  output += `Strategy: ${explanation.strategy} - ${explanation.reason}\n`;
+
+  if (explanation.tool === 'search_code') {
+    output += `Scope: ${explanation.filesInScope} file(s) under ${explanation.scope}\n`;
+    if (explanation.pruned.length > 0) {
+      output += '\nPruned:\n';
+      for (const count of explanation.pruned) {
+        const parts = [];
+        if (count.directories > 0) {
+          parts.push(`${count.directories} director${count.directories === 1 ? 'y' : 'ies'} (contents not visited)`);
+        }
+        if (count.files > 0) {
+          parts.push(`${count.files} file(s)`);
+        }
+        let label = RULE_LABELS[count.rule];
+        if (count.rule === 'size' && options.maxFileSize) {
+          label += ` (${options.maxFileSize} bytes)`;
+        } else if (count.rule === 'glob' && options.glob) {
+          label += ` ${JSON.stringify(options.glob)}`;
+        }
+        output += `- ${label}: ${parts.join(', ')}, e.g. ${count.examples.join(', ')}\n`;
+      }
+    }
+    output += `\nCost: ${explanation.candidates} file(s) to read, ${formatBytes(explanation.candidateBytes)}, ` +
+      `with ${explanation.workers} worker(s)\n`;
+  } else if (explanation.strategy !== 'symbol') {
+    output += `\nCost: ${explanation.candidates} chunk(s) from ${explanation.filesInScope} file(s) to compare\n`;
+  }
+
+  if (explanation.notes.length > 0) {
+    output += '\nNotes:\n';
+    for (const note of explanation.notes) {
+      output += `- ${note}\n`;
+    }
+  }
+  return output;
+}
@@ -0,0 +1,86 @@
+/**
+ * Tests for the explore symbol tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { exploreSymbol, formatExploredSymbol } from './explore';
+
+describe('explore symbol', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'explore-')));
+    const write = (relativePath: string, content: string) => {
+      fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, relativePath), content);
+    };
+    write('store/store.go', [
+      'package store',
+      '',
+      '// Lookup finds an item by key',
+      '// and reports whether it was there',
+      'func Lookup(key string) (string, bool) {',
+      '\treturn "", false',
+      '}',
+      '',
+    ].join('\n'));
+    write('api/handler.go', 'package api\n\nfunc Handle() {\n\tstore.Lookup("a")\n\tstore.Lookup("b")\n}\n');
+    write('cli/main.go', 'package main\n\nfunc main() {\n\tstore.Lookup("c")\n}\n');
+    write('store/store_test.go', 'package store\n\nfunc TestLookup(t *testing.T) {\n\tLookup("a")\n}\n');
+    write('web/store.ts', [
+      'export interface Store {',
+      '  get(key: string): string;',
+      '}',
+      '',
+      'export class MemoryStore implements Store {',
+      '  get(key: string): string { return key; }',
+      '}',
+      '',
+    ].join('\n'));
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should gather the definition, references by package, and tests without a language server', async () => {
+    const explored = await exploreSymbol(undefined, workspace, 'Lookup');
+    expect(explored.definition).toMatchObject({ name: 'Lookup', filePath: path.join(workspace, 'store/store.go'), line: 4 });
+    expect(explored.signature).toBe('func Lookup(key string) (string, bool)');
+    expect(explored.docComment).toBe('Lookup finds an item by key\nand reports whether it was there');
+    expect(explored.textMatches).toBe(true);
+    expect(explored.references.map((ref) => `${ref.relativePath}:${ref.line + 1}`))
+      .toEqual(['api/handler.go:4', 'api/handler.go:5', 'cli/main.go:4', 'store/store_test.go:4', 'store/store.go:3']);
+    expect(explored.tests).toEqual([{ relativePath: 'store/store_test.go', lines: [3], functions: ['TestLookup'] }]);
+
+    const text = formatExploredSymbol(workspace, explored, 2);
+    expect(text).toContain('File: store/store.go:L5');
+    expect(text).toContain('     5| func Lookup(key string) (string, bool) {');
+    expect(text).toContain('References: 4 in 3 file(s) across 3 package(s), tests listed below; 2 shown');
+    expect(text).toContain('  api (2)\n    api/handler.go:L4:C8: store.Lookup("a")');
+    expect(text).toContain('  … cli (1)');
+    expect(text).toContain('Implementations: only looked up for interfaces, classes, and methods');
+    expect(text).toContain('  store/store_test.go: L4 (in TestLookup)');
+  });
+
+  it('should find implementations that name the interface', async () => {
+    const explored = await exploreSymbol(undefined, workspace, 'Store');
+    expect(explored.definition).toMatchObject({ name: 'Store', kind: 11 });
+    expect(explored.implementations.map((impl) => impl.text)).toEqual(['export class MemoryStore implements Store {']);
+  });
+
+  it('should list the places a name appears when nothing defines it', async () => {
+    const explored = await exploreSymbol(undefined, workspace, 'Missing');
+    expect(explored.definition).toBeUndefined();
+    expect(formatExploredSymbol(workspace, explored)).toContain('No definition of Missing found');
+  });
+
+  it('should refuse a reference limit that is not a positive whole number', async () => {
+    const explored = await exploreSymbol(undefined, workspace, 'Lookup');
+    for (const maxReferences of [0, -1, 2.5, NaN]) {
+      expect(() => formatExploredSymbol(workspace, explored, maxReferences)).toThrow('maxReferences must be a positive whole number');
+    }
+  });
+});
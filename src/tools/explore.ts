@@ -0,0 +1,473 @@
+/**
+ * Explore symbol tool - everything an agent usually asks about a symbol, in one call
+ * Returns the definition with its doc comment and signature, the references
+ * grouped by package, the implementations, and the tests that touch it.
+ * References come from the language server when one is attached, otherwise
+ * from whole-word matches found through the trigram index
+ */
+
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { answeredReferences, implementations as lspImplementations } from '../lsp/methods.js';
+import { createLogger, Component } from '../logging/logger.js';
+import {
+  Location,
+  ReferenceContext,
+  ReferenceParams,
+  SymbolKind,
+  SymbolKindNames,
+  TextDocumentIdentifier,
+  TextDocumentPositionParams,
+  wrapSymbol,
+} from '../protocol/types.js';
+import { pathToUri, uriToPath } from '../protocol/uri.js';
+import { searchLexical } from '../search/lexical.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { ToolError } from '../workspace/errors.js';
+import { isTestFile } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { isUnder } from '../workspace/paths.js';
+import { baseName, extractSignature } from './api.js';
+import { builtinDeclarations, parsedLanguage } from './codeindex.js';
+import { findEnclosingSymbol, findWorkspaceSymbols, getFileSymbols } from './symbols.js';
+import { addLineNumbers, getDocComment, getFullDefinition } from './utilities.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the explore symbol tool
+ */
+export interface ExploreOptions {
+  // Only definitions and references under this directory
+  scope?: string;
+}
+
+/**
+ * Where a symbol is defined
+ */
+export interface ExploredDefinition {
+  name: string;
+  kind?: SymbolKind;
+  filePath: string;
+  line: number; // 0-indexed line of the name
+  character: number;
+}
+
+/**
+ * A use of the symbol
+ */
+export interface ExploredReference {
+  relativePath: string;
+  line: number; // 0-indexed
+  character: number;
+  text: string; // The trimmed source line
+}
+
+/**
+ * What explore_symbol found
+ */
+export interface ExploredSymbol {
+  symbolName: string;
+  definition?: ExploredDefinition;
+  // Other definitions of the same name
+  others: ExploredDefinition[];
+  signature: string;
+  docComment: string;
+  snippet: string;
+  snippetOmitted: number; // Lines of the definition left out of the snippet
+  references: ExploredReference[];
+  // Whether references are name matches rather than language server answers
+  textMatches: boolean;
+  implementations: ExploredReference[];
+  // Why implementations were not looked for, when they were not
+  implementationsSkipped?: string;
+  // Test files using the symbol, with the test functions the uses are in
+  tests: Array<{ relativePath: string; lines: number[]; functions: string[] }>;
+}
+
+/**
+ * Definition lines shown; longer definitions are clipped
+ */
+const MAX_SNIPPET_LINES = 40;
+
+/**
+ * Name matches kept when no language server answers
+ */
+const MAX_TEXT_MATCHES = 2000;
+
+/**
+ * Kinds whose implementations are asked for
+ */
+const ABSTRACT_KINDS = new Set([SymbolKind.Interface, SymbolKind.Class, SymbolKind.Method]);
+
+/**
+ * Kinds a use in a test file is attributed to
+ */
+const CALLABLE_KINDS = new Set([SymbolKind.Function, SymbolKind.Method]);
+
+/**
+ * Whether a declared name is the symbol asked for
+ * Qualified names must match exactly; a bare name also matches members of that name
+ */
+function matchesSymbolName(declared: string, symbolName: string): boolean {
+  if (symbolName.includes('.')) {
+    return declared === symbolName;
+  }
+  return declared === symbolName || declared.endsWith(`.${symbolName}`) || declared.endsWith(`::${symbolName}`);
+}
+
+/**
+ * Definitions from the language server and the built-in Python and .proto symbols
+ */
+async function serverDefinitions(client: LSPClient | undefined, workspaceDir: string, symbolName: string): Promise<ExploredDefinition[]> {
+  const definitions: ExploredDefinition[] = [];
+  for (const raw of await findWorkspaceSymbols(client, workspaceDir, symbolName)) {
+    const sym = wrapSymbol(raw);
+    const kind = (raw as { kind?: SymbolKind }).kind;
+    const container = (raw as { containerName?: string }).containerName;
+    const qualified = container && !sym.getName().includes('.') ? `${container}.${sym.getName()}` : sym.getName();
+    if (!matchesSymbolName(sym.getName(), symbolName) && !matchesSymbolName(qualified, symbolName)) {
+      continue;
+    }
+    const location = sym.getLocation();
+    definitions.push({
+      name: sym.getName(),
+      kind,
+      filePath: uriToPath(location.uri),
+      line: location.range.start.line,
+      character: location.range.start.character,
+    });
+  }
+  return definitions;
+}
+
+/**
+ * Definitions the built-in parsers find in the files that mention the name
+ */
+async function parsedDefinitions(workspaceDir: string, symbolName: string, mentions: Set<string>): Promise<ExploredDefinition[]> {
+  const definitions: ExploredDefinition[] = [];
+  for (const relativePath of mentions) {
+    const filePath = path.join(workspaceDir, relativePath);
+    if (!parsedLanguage(filePath)) {
+      continue;
+    }
+    let content: string;
+    try {
+      content = await readFileText(filePath);
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', filePath, err);
+      continue;
+    }
+    const lines = content.split('\n');
+    for (const declaration of builtinDeclarations(filePath, content)) {
+      if (!matchesSymbolName(declaration.qualifiedName, symbolName)) {
+        continue;
+      }
+      const name = baseName(declaration.qualifiedName);
+      const column = lines[declaration.line]?.indexOf(name, declaration.character) ?? -1;
+      definitions.push({
+        name: declaration.qualifiedName,
+        kind: declaration.kind,
+        filePath,
+        line: declaration.line,
+        character: column >= 0 ? column : declaration.character,
+      });
+    }
+  }
+  return definitions;
+}
+
+/**
+ * References from the language server, leaving out the declaration
+ */
+async function serverReferences(client: LSPClient, workspaceDir: string, definition: ExploredDefinition): Promise<ExploredReference[]> {
+  await client.openFile(definition.filePath);
+  const params = {
+    textDocument: { uri: pathToUri(definition.filePath) } as TextDocumentIdentifier,
+    position: { line: definition.line, character: definition.character },
+    context: { includeDeclaration: false } as ReferenceContext,
+  } as ReferenceParams & TextDocumentPositionParams;
+  const { locations } = await answeredReferences(client, params);
+  return locationsToReferences(workspaceDir, locations);
+}
+
+/**
+ * References with the text of their lines, in file and line order
+ */
+async function locationsToReferences(workspaceDir: string, locations: Location[]): Promise<ExploredReference[]> {
+  const contents = new Map<string, string[]>();
+  const references: ExploredReference[] = [];
+  for (const location of locations) {
+    const filePath = uriToPath(location.uri);
+    if (!contents.has(filePath)) {
+      contents.set(filePath, (await readFileText(filePath).catch(() => '')).split('\n'));
+    }
+    const { line, character } = location.range.start;
+    references.push({
+      relativePath: path.relative(workspaceDir, filePath),
+      line,
+      character,
+      text: contents.get(filePath)![line]?.trim() ?? '',
+    });
+  }
+  return references.sort(byPosition);
+}
+
+/**
+ * Order of references: by file, then position
+ */
+function byPosition(a: ExploredReference, b: ExploredReference): number {
+  return a.relativePath.localeCompare(b.relativePath) || a.line - b.line || a.character - b.character;
+}
+
+/**
+ * Implementations from the language server, or by text where classes name what they implement
+ */
+async function findImplementations(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  definition: ExploredDefinition,
+  mentions: ExploredReference[]
+): Promise<{ found: ExploredReference[]; skipped?: string }> {
+  if (definition.kind === undefined || !ABSTRACT_KINDS.has(definition.kind)) {
+    return { found: [], skipped: 'only looked up for interfaces, classes, and methods' };
+  }
+  if (client?.serverCapabilities.implementationProvider) {
+    const locations = await lspImplementations(client, {
+      textDocument: { uri: pathToUri(definition.filePath) } as TextDocumentIdentifier,
+      position: { line: definition.line, character: definition.character },
+    });
+    return { found: await locationsToReferences(workspaceDir, locations) };
+  }
+  if (definition.filePath.endsWith('.go')) {
+    return { found: [], skipped: 'Go types implement interfaces implicitly; needs a language server' };
+  }
+  const name = baseName(definition.name);
+  const declares = new RegExp(`\\b(implements|extends)\\b[^{]*\\b${name.replace(/[$]/g, '\\$')}\\b`);
+  return { found: mentions.filter((mention) => declares.test(mention.text)) };
+}
+
+/**
+ * Test files among the references, with the functions the uses are in
+ */
+async function testsTouching(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  references: ExploredReference[]
+): Promise<ExploredSymbol['tests']> {
+  const byFile = new Map<string, number[]>();
+  for (const reference of references.filter((ref) => isTestFile(ref.relativePath))) {
+    byFile.set(reference.relativePath, [...(byFile.get(reference.relativePath) ?? []), reference.line]);
+  }
+  const tests: ExploredSymbol['tests'] = [];
+  for (const [relativePath, lines] of byFile) {
+    const filePath = path.join(workspaceDir, relativePath);
+    const functions = new Set<string>();
+    try {
+      const symbols = await getFileSymbols(client, workspaceDir, filePath);
+      for (const line of lines) {
+        const enclosing = findEnclosingSymbol(symbols, line);
+        if (enclosing) {
+          functions.add(enclosing.qualifiedName);
+        }
+      }
+    } catch (err) {
+      // Without document symbols, the nearest function declared above each use; test functions are top-level
+      toolsLogger.debug('Could not get symbols for %s: %s', relativePath, err);
+      const declarations = parsedLanguage(filePath)
+        ? builtinDeclarations(filePath, await readFileText(filePath).catch(() => ''))
+          .filter((declaration) => CALLABLE_KINDS.has(declaration.kind))
+        : [];
+      for (const line of lines) {
+        const above = declarations.filter((declaration) => declaration.line <= line).pop();
+        if (above) {
+          functions.add(above.qualifiedName);
+        }
+      }
+    }
+    tests.push({ relativePath, lines, functions: Array.from(functions) });
+  }
+  return tests;
+}
+
+/**
+ * Gather what is known about a symbol: definition, docs, references, implementations, and tests
+ */
+export async function exploreSymbol(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  symbolName: string,
+  options: ExploreOptions = {},
+  index?: TrigramIndex
+): Promise<ExploredSymbol> {
+  const name = baseName(symbolName);
+  const inScope = (filePath: string) => !options.scope || isUnder(path.resolve(workspaceDir, filePath), options.scope);
+
+  // Whole-word matches find definitions the built-in parsers know and stand in for references without a server
+  const matches = (await searchLexical(workspaceDir, name, {
+    wholeWord: true,
+    caseSensitive: true,
+    maxResults: MAX_TEXT_MATCHES,
+  }, index)).matches.filter((match) => inScope(match.filePath));
+  const mentions: ExploredReference[] = matches.map((match) => ({
+    relativePath: match.filePath,
+    line: match.line - 1,
+    character: match.column - 1,
+    text: match.lineText.trim(),
+  })).sort(byPosition);
+
+  let definitions = (await serverDefinitions(client, workspaceDir, symbolName)).filter((def) => inScope(def.filePath));
+  if (definitions.length === 0) {
+    definitions = await parsedDefinitions(workspaceDir, symbolName, new Set(mentions.map((mention) => mention.relativePath)));
+  }
+  // Definitions outside tests come first
+  definitions.sort((a, b) => Number(isTestFile(a.filePath)) - Number(isTestFile(b.filePath)));
+
+  const explored: ExploredSymbol = {
+    symbolName,
+    definition: definitions[0],
+    others: definitions.slice(1),
+    signature: '',
+    docComment: '',
+    snippet: '',
+    snippetOmitted: 0,
+    references: [],
+    textMatches: true,
+    implementations: [],
+    tests: [],
+  };
+  const definition = definitions[0];
+  if (!definition) {
+    explored.references = mentions;
+    explored.tests = await testsTouching(client, workspaceDir, mentions);
+    return explored;
+  }
+
+  const lines = (await readFileText(definition.filePath)).split('\n');
+  explored.signature = extractSignature(lines, definition.line);
+  explored.docComment = getDocComment(lines, definition.line);
+  const [text, extent] = await getFullDefinition(definition.filePath, {
+    uri: pathToUri(definition.filePath),
+    range: { start: { line: definition.line, character: 0 }, end: { line: definition.line, character: 0 } },
+  });
+  const snippetLines = text.split('\n');
+  explored.snippet = addLineNumbers(snippetLines.slice(0, MAX_SNIPPET_LINES).join('\n'), extent.range.start.line + 1);
+  explored.snippetOmitted = Math.max(0, snippetLines.length - MAX_SNIPPET_LINES);
+
+  const declared = new Set(definitions.map((def) => `${path.relative(workspaceDir, def.filePath)}:${def.line}`));
+  if (client) {
+    try {
+      explored.references = (await serverReferences(client, workspaceDir, definition)).filter((ref) => inScope(ref.relativePath));
+      explored.textMatches = false;
+    } catch (err) {
+      toolsLogger.debug('References of %s fell back to name matches: %s', symbolName, err);
+    }
+  }
+  if (explored.textMatches) {
+    explored.references = mentions.filter((mention) => !declared.has(`${mention.relativePath}:${mention.line}`));
+  }
+
+  try {
+    const implementations = await findImplementations(client, workspaceDir, definition, mentions);
+    explored.implementations = implementations.found;
+    explored.implementationsSkipped = implementations.skipped;
+  } catch (err) {
+    explored.implementationsSkipped = `the language server failed: ${(err as Error).message}`;
+  }
+  explored.tests = await testsTouching(client, workspaceDir, explored.references);
+  return explored;
+}
+
+/**
+ * Package of a file: its directory relative to the workspace
+ */
+function packageOf(relativePath: string): string {
+  return path.dirname(relativePath).split(path.sep).join('/');
+}
+
+/**
+ * Format the references by package, the packages using the symbol most first, up to a limit
+ */
+function formatReferences(references: ExploredReference[], limit: number): string[] {
+  const packages = new Map<string, ExploredReference[]>();
+  for (const reference of references.filter((ref) => !isTestFile(ref.relativePath))) {
+    const pkg = packageOf(reference.relativePath);
+    packages.set(pkg, [...(packages.get(pkg) ?? []), reference]);
+  }
+  const inPackages = Array.from(packages.values()).reduce((sum, refs) => sum + refs.length, 0);
+  const files = new Set(Array.from(packages.values()).flat().map((ref) => ref.relativePath));
+  const lines = [`References: ${inPackages} in ${files.size} file(s) across ${packages.size} package(s), tests listed below` +
+    (inPackages > limit ? `; ${limit} shown` : '')];
+  let shown = 0;
+  for (const [pkg, refs] of Array.from(packages).sort((a, b) => b[1].length - a[1].length || a[0].localeCompare(b[0]))) {
+    if (shown >= limit) {
+      lines.push(`  … ${pkg} (${refs.length})`);
+      continue;
+    }
+    lines.push(`  ${pkg} (${refs.length})`);
+    for (const ref of refs.slice(0, limit - shown)) {
+      lines.push(`    ${ref.relativePath.split(path.sep).join('/')}:L${ref.line + 1}:C${ref.character + 1}: ${ref.text}`);
+    }
+    shown += Math.min(refs.length, limit - shown);
+  }
+  return lines;
+}
+
+/**
+ * Format what explore_symbol found, one section per question
+ */
+export function formatExploredSymbol(workspaceDir: string, explored: ExploredSymbol, maxReferences = 20): string {
+  if (!Number.isInteger(maxReferences) || maxReferences < 1) {
+    throw new ToolError('invalid-argument', `maxReferences must be a positive whole number of references, got ${maxReferences}`);
+  }
+  const { definition } = explored;
+  const lines: string[] = [];
+  if (definition) {
+    const kind = definition.kind !== undefined ? SymbolKindNames[definition.kind] : undefined;
+    const relative = path.relative(workspaceDir, definition.filePath).split(path.sep).join('/');
+    lines.push(`Symbol: ${definition.name}`);
+    if (kind) {
+      lines.push(`Kind: ${kind}`);
+    }
+    lines.push(`File: ${relative}:L${definition.line + 1}`);
+    for (const other of explored.others) {
+      lines.push(`Also defined: ${other.name} in ${path.relative(workspaceDir, other.filePath).split(path.sep).join('/')}:L${other.line + 1}`);
+    }
+    lines.push(`Signature: ${explored.signature}`, '');
+    lines.push('Doc comment:', explored.docComment ? explored.docComment.split('\n').map((line) => `  ${line}`).join('\n') : '  (none)', '');
+    lines.push('Definition:', explored.snippet);
+    if (explored.snippetOmitted > 0) {
+      lines.push(`  … ${explored.snippetOmitted} more line(s); read the rest with definition`);
+    }
+  } else {
+    lines.push(`No definition of ${explored.symbolName} found; listing the places the name appears`);
+  }
+  lines.push('');
+
+  lines.push(...formatReferences(explored.references, maxReferences));
+  if (explored.textMatches) {
+    lines.push('  (whole-word name matches; names shared with other symbols are included)');
+  }
+  lines.push('');
+
+  if (definition) {
+    if (explored.implementationsSkipped) {
+      lines.push(`Implementations: ${explored.implementationsSkipped}`);
+    } else {
+      lines.push(`Implementations: ${explored.implementations.length}`);
+      for (const impl of explored.implementations) {
+        lines.push(`  ${impl.relativePath.split(path.sep).join('/')}:L${impl.line + 1}: ${impl.text}`);
+      }
+    }
+    lines.push('');
+  }
+
+  const testReferences = explored.tests.reduce((sum, test) => sum + test.lines.length, 0);
+  lines.push(`Tests: ${testReferences} reference(s) in ${explored.tests.length} test file(s)`);
+  for (const test of explored.tests) {
+    const where = test.lines.map((line) => `L${line + 1}`).join(', ');
+    lines.push(`  ${test.relativePath.split(path.sep).join('/')}: ${where}` +
+      (test.functions.length > 0 ? ` (in ${test.functions.join(', ')})` : ''));
+  }
+  return lines.join('\n');
+}
@@ -0,0 +1,70 @@
+/**
+ * Tests for the feature flags tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { DEFAULT_FLAG_PATTERNS, flagCallPattern, flagPatterns, listFeatureFlags, scanFlagCalls } from './flags';
+
+function calls(relativePath: string, content: string, patterns = DEFAULT_FLAG_PATTERNS): string[] {
+  return scanFlagCalls(relativePath, content, flagCallPattern(patterns)).map((reference) =>
+    `${reference.flag} ${reference.call}${reference.constant ? ' (constant)' : ''} :${reference.line}`);
+}
+
+describe('Feature flags', () => {
+  it('should add and drop patterns from FEATURE_FLAG_PATTERNS', () => {
+    const patterns = flagPatterns({ FEATURE_FLAG_PATTERNS: 'flags.Enabled, !isOn' });
+    expect(patterns).toContain('flags.Enabled');
+    expect(patterns).not.toContain('isOn');
+    expect(flagPatterns({})).toEqual(DEFAULT_FLAG_PATTERNS);
+  });
+
+  it('should find lookups by literal key, symbol, and constant', () => {
+    const source = [
+      'on, _ := ld.BoolVariation("new-checkout", ctx, false)',
+      'if unleash.IsEnabled(flags.DarkMode) {',
+      'if isEnabledForUser(user) {',
+    ].join('\n');
+    expect(calls('main.go', source)).toEqual([
+      'new-checkout ld.BoolVariation :1',
+      'flags.DarkMode unleash.IsEnabled (constant) :2',
+    ]);
+    expect(calls('flags.rb', 'if Flipper.enabled?(:search_v2)\n')).toEqual(['search_v2 Flipper.enabled? :1']);
+    expect(calls('app.ts', "if (features.has('beta')) {}\nif (flag('beta')) {}\n", ['features.has'])).toEqual(['beta features.has :1']);
+  });
+
+  describe('listFeatureFlags', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'flags-'));
+      fs.writeFileSync(path.join(workspace, 'cart.ts'), "if (client.variation('new-checkout', user, false)) {}\nclient.variation('new-checkout-v2', user, false);\n");
+      fs.writeFileSync(path.join(workspace, 'flags.yaml'), 'new-checkout: true\n');
+      fs.writeFileSync(path.join(workspace, 'pay.go'), 'package pay\n\nvar on = ld.BoolVariation("pay-later", ctx, false)\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should list every flag with its lookups', async () => {
+      const output = await listFeatureFlags(workspace);
+      expect(output.split('\n\n')[0]).toBe('Feature flags: 3 (3 lookup(s))');
+      expect(output).toContain("pay-later (1)\n  pay.go:3 ld.BoolVariation: var on = ld.BoolVariation(\"pay-later\", ctx, false)");
+    });
+
+    it('should list the lookups and other mentions of one flag', async () => {
+      expect(await listFeatureFlags(workspace, { flag: 'new-checkout' })).toBe([
+        'Flag new-checkout: 1 lookup(s), 1 other mention(s)',
+        '',
+        'Lookups',
+        "  cart.ts:1 client.variation: if (client.variation('new-checkout', user, false)) {}",
+        '',
+        'Other mentions',
+        '  flags.yaml:1: new-checkout: true',
+      ].join('\n'));
+      expect(await listFeatureFlags(workspace, { flag: 'gone' })).toBe('No lookups or mentions of flag "gone" found');
+    });
+  });
+});
@@ -0,0 +1,180 @@
+/**
+ * Feature flags tool - flags referenced in code and where they are checked
+ * Flag lookups are calls of known functions with the flag key as the first
+ * argument: LaunchDarkly's BoolVariation("new-checkout", ...), Unleash's
+ * isEnabled("new-checkout"), OpenFeature's getBooleanValue, or a homegrown
+ * helper added with FEATURE_FLAG_PATTERNS. Listing every call site of a flag,
+ * and every other mention of its key, is the checklist for removing it
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { escapeRegExp } from '../search/lexical.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Flag lookup functions recognized without configuration
+ * A * stands for any identifier characters; a dotted entry names the receiver too
+ */
+export const DEFAULT_FLAG_PATTERNS = [
+  '*Variation', 'variation', '*VariationDetail', 'variationDetail', // LaunchDarkly
+  'isEnabled', 'IsEnabled', // Unleash
+  'isFeatureEnabled', 'IsFeatureEnabled',
+  'getBooleanValue', 'getStringValue', 'getNumberValue', 'getObjectValue', // OpenFeature
+  'GetBooleanValue', 'GetStringValue', 'GetIntValue', 'GetFloatValue', 'GetObjectValue',
+  'getTreatment', 'GetTreatment', // Split
+  'isOn', 'IsOn', // GrowthBook
+  'Flipper.enabled?', // Flipper
+];
+
+/**
+ * Options for the feature flags tool
+ */
+export interface FlagOptions {
+  path?: string;
+  // Only this flag, with the other mentions of its key (default: every flag)
+  flag?: string;
+}
+
+/**
+ * A flag lookup or another mention of a flag key
+ */
+export interface FlagReference {
+  flag: string;
+  // The lookup function, e.g. ldClient.BoolVariation; undefined for other mentions
+  call?: string;
+  // The key is a constant's name rather than a literal
+  constant: boolean;
+  filePath: string;
+  line: number; // 1-indexed
+  lineText: string;
+}
+
+/**
+ * Flag lookup functions: the defaults, plus FEATURE_FLAG_PATTERNS entries, less
+ * the defaults it negates ("!isOn")
+ */
+export function flagPatterns(env: NodeJS.ProcessEnv = process.env): string[] {
+  const entries = (env.FEATURE_FLAG_PATTERNS || '').split(',').map((entry) => entry.trim()).filter(Boolean);
+  const negated = new Set(entries.filter((entry) => entry.startsWith('!')).map((entry) => entry.substring(1)));
+  const added = entries.filter((entry) => !entry.startsWith('!'));
+  return [...new Set([...DEFAULT_FLAG_PATTERNS, ...added])].filter((pattern) => !negated.has(pattern));
+}
+
+/**
+ * Regular expression matching calls of any of the patterns
+ * Group 1 is the call, group 2 a quoted key, group 3 a Ruby symbol, and
+ * group 4 a constant's name (UPPER_CASE, Exported, or pkg.Exported)
+ */
+export function flagCallPattern(patterns: string[]): RegExp {
+  const names = patterns.map((pattern) =>
+    pattern.replace(/[.+?^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '\\w*'));
+  return new RegExp(
+    String.raw`(?<![\w$.])((?:[\w$]+\.)*(?:${names.join('|')}))\s*\(\s*(?:['"\x60]([^'"\x60\n]+)['"\x60]|:(\w+)|((?:\w+\.)?[A-Z]\w*))\s*[,)]`,
+    'g'
+  );
+}
+
+/**
+ * Find the flag lookups of one file
+ */
+export function scanFlagCalls(relativePath: string, content: string, pattern: RegExp): FlagReference[] {
+  const references: FlagReference[] = [];
+  content.split('\n').forEach((lineText, i) => {
+    for (const match of lineText.matchAll(pattern)) {
+      const flag = match[2] ?? match[3] ?? match[4];
+      references.push({ flag, call: match[1], constant: match[4] !== undefined, filePath: relativePath, line: i + 1, lineText });
+    }
+  });
+  return references;
+}
+
+/**
+ * Mentions of a flag key outside its lookups, as a whole word
+ * Hyphens join words here, so new-checkout-v2 is not a mention of new-checkout
+ */
+function scanMentions(relativePath: string, content: string, flag: string, calls: Set<number>): FlagReference[] {
+  const mention = new RegExp(`(?<![\\w.-])${escapeRegExp(flag)}(?![\\w-])`);
+  const references: FlagReference[] = [];
+  content.split('\n').forEach((lineText, i) => {
+    if (!calls.has(i + 1) && mention.test(lineText)) {
+      references.push({ flag, constant: false, filePath: relativePath, line: i + 1, lineText });
+    }
+  });
+  return references;
+}
+
+function formatReference(reference: FlagReference): string {
+  const text = reference.lineText.trim();
+  const where = `${reference.filePath}:${reference.line}`;
+  return `  ${where}${reference.call ? ` ${reference.call}` : ''}: ${text.length > 200 ? text.substring(0, 200) + '...' : text}`;
+}
+
+/**
+ * List the feature flags looked up in the workspace with their call sites
+ * With a flag, also list the other mentions of its key (configuration,
+ * tests, constants), which a cleanup has to touch as well
+ */
+export async function listFeatureFlags(workspaceDir: string, options: FlagOptions = {}): Promise<string> {
+  const patterns = flagPatterns();
+  const pattern = flagCallPattern(patterns);
+  toolsLogger.debug('Listing feature flags (%d pattern(s), flag: %s)', patterns.length, options.flag ?? 'all');
+
+  const files = await walkWorkspaceFiles(workspaceDir, { pathPrefix: options.path });
+  const calls: FlagReference[] = [];
+  const mentions: FlagReference[] = [];
+  for (const file of files) {
+    const source = detectLanguageId(file.relativePath) !== 'plaintext';
+    if (!source && !options.flag) {
+      continue;
+    }
+    let content: string;
+    try {
+      content = await readFileText(file.absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Could not scan %s: %s', file.relativePath, (err as Error).message);
+      continue;
+    }
+    if (content.includes('\0')) {
+      continue;
+    }
+    const found = (source ? scanFlagCalls(file.relativePath, content, pattern) : [])
+      .filter((reference) => !options.flag || reference.flag === options.flag);
+    calls.push(...found);
+    if (options.flag && content.includes(options.flag)) {
+      mentions.push(...scanMentions(file.relativePath, content, options.flag, new Set(found.map((reference) => reference.line))));
+    }
+  }
+
+  if (options.flag) {
+    if (calls.length === 0 && mentions.length === 0) {
+      return `No lookups or mentions of flag "${options.flag}" found`;
+    }
+    const lines = [`Flag ${options.flag}: ${calls.length} lookup(s), ${mentions.length} other mention(s)`];
+    if (calls.length > 0) {
+      lines.push('', 'Lookups', ...calls.map(formatReference));
+    }
+    if (mentions.length > 0) {
+      lines.push('', 'Other mentions', ...mentions.map(formatReference));
+    }
+    return lines.join('\n');
+  }
+
+  if (calls.length === 0) {
+    return `No feature flag lookups found (looking for calls of ${patterns.join(', ')}; add yours with FEATURE_FLAG_PATTERNS)`;
+  }
+  const byFlag = new Map<string, FlagReference[]>();
+  for (const reference of calls) {
+    byFlag.set(reference.flag, [...(byFlag.get(reference.flag) ?? []), reference]);
+  }
+  const sections = [`Feature flags: ${byFlag.size} (${calls.length} lookup(s))`];
+  for (const flag of [...byFlag.keys()].sort()) {
+    const ofFlag = byFlag.get(flag)!;
+    const constant = ofFlag.every((reference) => reference.constant) ? ', by constant' : '';
+    sections.push([`${flag} (${ofFlag.length}${constant})`, ...ofFlag.map(formatReference)].join('\n'));
+  }
+  return sections.join('\n\n');
+}
@@ -0,0 +1,35 @@
+/**
+ * Test stand-in for the goanalysis command
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+
+const originalPath = process.env.GOANALYSIS_PATH;
+
+/**
+ * Point GOANALYSIS_PATH at a script in dir that records its arguments and
+ * prints output as JSON; returns a function reading the recorded arguments
+ */
+export function stubGoanalysis(dir: string, output: unknown): () => string {
+  const script = path.join(dir, 'goanalysis');
+  const argsFile = path.join(dir, 'args.txt');
+  fs.writeFileSync(script, [
+    '#!/bin/sh',
+    `echo "$@" > "${argsFile}"`,
+    `echo '${JSON.stringify(output)}'`,
+  ].join('\n'), { mode: 0o755 });
+  process.env.GOANALYSIS_PATH = script;
+  return () => fs.readFileSync(argsFile, 'utf8').trim();
+}
+
+/**
+ * Put GOANALYSIS_PATH back as it was before the tests changed it
+ */
+export function restoreGoanalysisPath(): void {
+  if (originalPath === undefined) {
+    delete process.env.GOANALYSIS_PATH;
+  } else {
+    process.env.GOANALYSIS_PATH = originalPath;
+  }
+}
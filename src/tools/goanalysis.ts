@@ -0,0 +1,39 @@
+/**
+ * goanalysis runner - the Go analysis commands built from goanalysis/
+ * Used by the tools that need type information whichever language server
+ * is attached
+ */
+
+import { execFile } from 'child_process';
+import { createLogger, Component } from '../logging/logger.js';
+import { ToolError } from '../workspace/errors.js';
+
+const analysisLogger = createLogger(Component.TOOLS);
+
+/**
+ * goanalysis command, from GOANALYSIS_PATH or PATH
+ */
+function goAnalysisCommand(): string {
+  return process.env.GOANALYSIS_PATH || 'goanalysis';
+}
+
+/**
+ * Run goanalysis and return stdout
+ */
+export function runGoAnalysis(cwd: string, args: string[]): Promise<string> {
+  const command = goAnalysisCommand();
+  return new Promise((resolve, reject) => {
+    execFile(command, args, { cwd, maxBuffer: 64 * 1024 * 1024 }, (err, stdout, stderr) => {
+      if (err) {
+        if ((err as NodeJS.ErrnoException).code === 'ENOENT') {
+          reject(new ToolError('unsupported', `${command} not found; build it from goanalysis/ (go build -o goanalysis .) and put it on PATH, or set GOANALYSIS_PATH`));
+          return;
+        }
+        analysisLogger.debug('%s %s failed: %s', command, args.join(' '), stderr || err.message);
+        reject(new Error(`goanalysis failed: ${(stderr || err.message).trim()}`));
+        return;
+      }
+      resolve(stdout);
+    });
+  });
+}
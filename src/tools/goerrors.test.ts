@@ -0,0 +1,46 @@
+/**
+ * Tests for the Go error-handling checks
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { restoreGoanalysisPath, stubGoanalysis } from './goanalysis.testutil';
+import { GoErrorFinding, formatGoErrorFindings, goErrorChecks } from './goerrors';
+
+describe('goerrors', () => {
+  const findings: GoErrorFinding[] = [
+    { file: 'load.go', line: 21, column: 2, check: 'ignored', message: 'error returned by f.Close is not checked', func: 'Load' },
+    { file: 'load.go', line: 26, column: 6, check: 'shadow', message: 'err shadows err declared at line 17, which is used after this scope', func: 'Load' },
+    { file: 'save.go', line: 4, column: 2, check: 'ignored', message: 'error returned by os.Remove is not checked' },
+  ];
+
+  it('should count per check and group by file', () => {
+    expect(formatGoErrorFindings(2, findings, 200)).toBe([
+      'Found 3 error-handling problem(s) in 2 package(s): 2 ignored, 1 shadow',
+      '',
+      'load.go',
+      '  L21:C2  ignored: error returned by f.Close is not checked  (in Load)',
+      '  L26:C6  shadow: err shadows err declared at line 17, which is used after this scope  (in Load)',
+      '',
+      'save.go',
+      '  L4:C2  ignored: error returned by os.Remove is not checked',
+    ].join('\n'));
+    expect(formatGoErrorFindings(2, findings, 2)).toContain('(showing first 2)');
+    expect(formatGoErrorFindings(5, [], 200)).toBe('No error-handling problems found in 5 package(s)');
+  });
+
+  it('should pass the checks to goanalysis and reject unknown ones', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'goerrors-'));
+    try {
+      const args = stubGoanalysis(workspace, { packages: 1, findings: [findings[2]] });
+
+      expect(await goErrorChecks(workspace, { checks: ['ignored'] })).toContain('1 ignored');
+      expect(args()).toBe(`errors -root ${workspace} -path ${workspace} -checks ignored`);
+      await expect(goErrorChecks(workspace, { checks: ['nil-check'] })).rejects.toThrow('Unknown check(s) nil-check');
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+      restoreGoanalysisPath();
+    }
+  });
+});
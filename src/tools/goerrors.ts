@@ -0,0 +1,86 @@
+/**
+ * Go error-handling checks - ignored and discarded errors, err shadowing,
+ * comparisons that miss wrapped errors, and errors.Is/As targets that never match
+ * Runs `goanalysis errors`, which reads types from go/types
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { resolveWorkspacePath } from '../workspace/walker.js';
+import { runGoAnalysis } from './goanalysis.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * The checks goanalysis runs
+ */
+export const GO_ERROR_CHECKS = ['ignored', 'discarded', 'shadow', 'compare', 'errors-as', 'errors-is'] as const;
+
+export type GoErrorCheck = typeof GO_ERROR_CHECKS[number];
+
+/**
+ * An error-handling problem, as goanalysis reports it
+ */
+export interface GoErrorFinding {
+  file: string; // Relative to the workspace
+  line: number;
+  column: number; // 1-indexed, in bytes
+  check: GoErrorCheck;
+  message: string;
+  func?: string;
+}
+
+/**
+ * Options for the error-handling checks
+ */
+export interface GoErrorCheckOptions {
+  // Directory to check (default: the workspace)
+  path?: string;
+  // Checks to run (default: all)
+  checks?: string[];
+  // Most findings listed (default: 200)
+  limit?: number;
+}
+
+/**
+ * Format findings grouped by file, after a count per check
+ */
+export function formatGoErrorFindings(packages: number, findings: GoErrorFinding[], limit: number): string {
+  if (findings.length === 0) {
+    return `No error-handling problems found in ${packages} package(s)`;
+  }
+  const counts = GO_ERROR_CHECKS
+    .map((check) => [check, findings.filter((finding) => finding.check === check).length] as const)
+    .filter(([, count]) => count > 0)
+    .map(([check, count]) => `${count} ${check}`);
+  const shown = findings.slice(0, limit);
+  const lines = [`Found ${findings.length} error-handling problem(s) in ${packages} package(s): ${counts.join(', ')}` +
+    (shown.length < findings.length ? ` (showing first ${shown.length})` : '')];
+  let file: string | undefined;
+  for (const finding of shown) {
+    if (finding.file !== file) {
+      file = finding.file;
+      lines.push('', file);
+    }
+    lines.push(`  L${finding.line}:C${finding.column}  ${finding.check}: ${finding.message}${finding.func ? `  (in ${finding.func})` : ''}`);
+  }
+  return lines.join('\n');
+}
+
+/**
+ * Run the Go error-handling checks over a directory's packages
+ */
+export async function goErrorChecks(workspaceDir: string, options: GoErrorCheckOptions = {}): Promise<string> {
+  const checks = options.checks ?? [...GO_ERROR_CHECKS];
+  const unknown = checks.filter((check) => !(GO_ERROR_CHECKS as readonly string[]).includes(check));
+  if (unknown.length > 0) {
+    throw new ToolError('invalid-argument', `Unknown check(s) ${unknown.join(', ')}; use ${GO_ERROR_CHECKS.join(', ')}`);
+  }
+  const dir = options.path ? resolveWorkspacePath(workspaceDir, options.path) : workspaceDir;
+  toolsLogger.debug('Checking Go error handling under %s: %s', dir, checks.join(','));
+  const output = JSON.parse(await runGoAnalysis(workspaceDir, ['errors', '-root', workspaceDir, '-path', dir, '-checks', checks.join(',')])) as {
+    packages: number;
+    findings: GoErrorFinding[];
+  };
+  return formatGoErrorFindings(output.packages, output.findings, options.limit ?? 200);
+}
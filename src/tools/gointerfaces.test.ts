@@ -0,0 +1,75 @@
+/**
+ * Tests for the Go interface satisfaction tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { goInterfaceReport } from './gointerfaces';
+
+describe('gointerfaces', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'gointerfaces-'));
+    const files: Record<string, string> = {
+      'store/store.go': [
+        'package store',
+        '',
+        'type Item struct{}',
+        '',
+        'type Getter interface {',
+        '\tGet(key string) (*Item, error)',
+        '}',
+        '',
+        'type Store interface {',
+        '\tGetter',
+        '\tio.Closer',
+        '}',
+        '',
+        'type Lister interface {',
+        '\tGet(string) (*Item, error)',
+        '\tList(prefix string) []string',
+        '}',
+      ].join('\n'),
+      'mem/mem.go': [
+        'package mem',
+        '',
+        'type Memory struct{}',
+        '',
+        'func (m *Memory) Get(key string) (*store.Item, error) { return nil, nil }',
+        'func (m Memory) Close() error { return nil }',
+        'func (m Memory) String() string { return "" }',
+        'func (m Memory) List() []string { return nil }',
+      ].join('\n'),
+      'mem/mem_test.go': 'package mem\n\nfunc (m Memory) Write(p []byte) (int, error) { return 0, nil }\n',
+    };
+    for (const [file, content] of Object.entries(files)) {
+      fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, file), content);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should list implemented and nearly implemented interfaces', async () => {
+    const result = await goInterfaceReport(workspace, 'Memory');
+    const storeFile = path.join('store', 'store.go');
+    expect(result).toContain(`Type mem.Memory (${path.join('mem', 'mem.go')}:3), 4 method(s), 1 with pointer receivers`);
+    expect(result).toContain(`store.Getter (${storeFile}:5) - via *Memory only`);
+    expect(result).toContain(`store.Store (${storeFile}:9) - via *Memory only`);
+    expect(result).toContain('io.Closer (standard library) - via Memory and *Memory');
+    expect(result).toContain('fmt.Stringer (standard library) - via Memory and *Memory');
+    expect(result).toContain(`store.Lister (${storeFile}:14) - List is List() []string, want List(string) []string`);
+    expect(result).toContain('io.WriteCloser (standard library) - missing Write([]byte) (int, error)');
+    expect(result).not.toContain('io.Writer ');
+  });
+
+  it('should honor maxMissing and package qualification', async () => {
+    const result = await goInterfaceReport(workspace, 'mem.Memory', { maxMissing: 0 });
+    expect(result).not.toContain('Nearly implements');
+    await expect(goInterfaceReport(workspace, 'store.Memory')).rejects.toThrow('No Go type named store.Memory');
+  });
+});
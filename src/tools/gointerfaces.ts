@@ -0,0 +1,201 @@
+/**
+ * Go interface satisfaction tool - which interfaces a Go type implements
+ * Compares the type's method sets (value and pointer receivers) with every
+ * interface declared in the workspace and common standard library ones, and
+ * lists the interfaces it nearly implements with the methods it lacks
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { GoMethod, GoMethodDecl, formatGoMethod, parseGoDeclarations } from '../symbols/golang.js';
+import { readFileText } from '../workspace/overlay.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the interface report
+ */
+export interface InterfaceReportOptions {
+  // Most missing or mismatched methods for an interface to count as nearly implemented (default: 2)
+  maxMissing?: number;
+}
+
+/**
+ * Standard library interfaces types commonly implement, with package-qualified signatures
+ */
+const STANDARD_INTERFACES: Record<string, string[]> = {
+  'error': ['Error() string'],
+  'fmt.Stringer': ['String() string'],
+  'io.Reader': ['Read([]byte) (int, error)'],
+  'io.Writer': ['Write([]byte) (int, error)'],
+  'io.Closer': ['Close() error'],
+  'io.ReadWriter': ['Read([]byte) (int, error)', 'Write([]byte) (int, error)'],
+  'io.ReadCloser': ['Read([]byte) (int, error)', 'Close() error'],
+  'io.WriteCloser': ['Write([]byte) (int, error)', 'Close() error'],
+  'io.ReadWriteCloser': ['Read([]byte) (int, error)', 'Write([]byte) (int, error)', 'Close() error'],
+  'io.ReaderFrom': ['ReadFrom(io.Reader) (int64, error)'],
+  'io.WriterTo': ['WriteTo(io.Writer) (int64, error)'],
+  'sort.Interface': ['Len() int', 'Less(int, int) bool', 'Swap(int, int)'],
+  'http.Handler': ['ServeHTTP(http.ResponseWriter, *http.Request)'],
+  'json.Marshaler': ['MarshalJSON() ([]byte, error)'],
+  'json.Unmarshaler': ['UnmarshalJSON([]byte) error'],
+  'encoding.TextMarshaler': ['MarshalText() ([]byte, error)'],
+  'encoding.TextUnmarshaler': ['UnmarshalText([]byte) error'],
+};
+
+/**
+ * An interface to check, with its methods' signatures qualified by package
+ */
+interface Candidate {
+  name: string; // pkg.Name, or the standard library name
+  location: string; // file:line, or "standard library"
+  methods: Array<GoMethod & { qualified: string }>;
+  unresolved: string[]; // Embedded interfaces outside the workspace and the standard list
+}
+
+/**
+ * Qualify the exported type names of a signature with their package, so
+ * signatures written in different packages compare equal
+ */
+function qualify(signature: string, pkg: string | undefined): string {
+  return pkg ? signature.replace(/(?<![\w.])([A-Z]\w*)(?![\w.])/g, `${pkg}.$1`) : signature;
+}
+
+/**
+ * A standard library method from its declaration, e.g. "Read([]byte) (int, error)"
+ */
+function standardMethod(declaration: string): GoMethod & { qualified: string } {
+  const open = declaration.indexOf('(');
+  const signature = declaration.substring(open);
+  return { name: declaration.substring(0, open), signature, qualified: signature };
+}
+
+/**
+ * Report the interfaces a Go type implements and nearly implements
+ * typeName may be qualified by its package name, e.g. "store.Memory"
+ */
+export async function goInterfaceReport(workspaceDir: string, typeName: string, options: InterfaceReportOptions = {}): Promise<string> {
+  const maxMissing = options.maxMissing ?? 2;
+  const [wantedPackage, wantedName] = typeName.includes('.') ? typeName.split('.', 2) : [undefined, typeName];
+  toolsLogger.debug('Checking interfaces implemented by %s', typeName);
+
+  // Test files are left out; their methods exist only in test builds
+  const files = (await walkWorkspaceFiles(workspaceDir))
+    .filter((file) => file.relativePath.endsWith('.go') && !file.relativePath.endsWith('_test.go'));
+  const parsed = [];
+  for (const file of files) {
+    try {
+      parsed.push({ relativePath: file.relativePath, ...parseGoDeclarations(await readFileText(file.absolutePath)) });
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', file.relativePath, (err as Error).message);
+    }
+  }
+
+  const targets = parsed.flatMap((file) => file.types
+    .filter((type) => type.name === wantedName)
+    .filter(() => !wantedPackage || file.package === wantedPackage || path.basename(path.dirname(file.relativePath)) === wantedPackage)
+    .map((type) => ({ ...type, file })));
+  if (targets.length === 0) {
+    throw new ToolError('not-found', `No Go type named ${typeName} in the workspace`);
+  }
+
+  // Interfaces by package and name, for resolving embeds
+  const interfaces = parsed.flatMap((file) => file.interfaces.map((iface) => ({ ...iface, file })));
+  const resolve = (
+    embed: string,
+    pkg: string | undefined,
+    dir: string,
+    seen: Set<string>
+  ): { methods: Candidate['methods']; unresolved: string[] } => {
+    const [qualifier, name] = embed.includes('.') ? embed.split('.', 2) : [undefined, embed];
+    const key = `${qualifier ?? pkg}.${name}`;
+    if (seen.has(key)) {
+      return { methods: [], unresolved: [] };
+    }
+    seen.add(key);
+    const local = interfaces.find((iface) => iface.name === name && !iface.constraint &&
+      (qualifier ? iface.file.package === qualifier : path.dirname(iface.file.relativePath) === dir));
+    if (local) {
+      return expand(local, seen);
+    }
+    const standard = STANDARD_INTERFACES[embed];
+    if (standard) {
+      return { methods: standard.map(standardMethod), unresolved: [] };
+    }
+    return { methods: [], unresolved: embed === 'any' ? [] : [embed] };
+  };
+  const expand = (iface: typeof interfaces[number], seen = new Set<string>()): { methods: Candidate['methods']; unresolved: string[] } => {
+    const dir = path.dirname(iface.file.relativePath);
+    const methods = iface.methods.map((method) => ({ ...method, qualified: qualify(method.signature, iface.file.package) }));
+    const unresolved: string[] = [];
+    for (const embed of iface.embeds) {
+      const inner = resolve(embed, iface.file.package, dir, seen);
+      methods.push(...inner.methods);
+      unresolved.push(...inner.unresolved);
+    }
+    return { methods, unresolved };
+  };
+  const candidates: Candidate[] = [
+    ...interfaces.filter((iface) => !iface.constraint).map((iface) => ({
+      name: iface.file.package ? `${iface.file.package}.${iface.name}` : iface.name,
+      location: `${iface.file.relativePath}:${iface.line}`,
+      ...expand(iface, new Set([`${iface.file.package}.${iface.name}`])),
+    })),
+    ...Object.entries(STANDARD_INTERFACES).map(([name, methods]) => ({
+      name,
+      location: 'standard library',
+      methods: methods.map(standardMethod),
+      unresolved: [],
+    })),
+  ].filter((candidate) => candidate.methods.length > 0);
+
+  const sections: string[] = [];
+  for (const target of targets) {
+    const dir = path.dirname(target.file.relativePath);
+    const pkg = target.file.package;
+    const declared = parsed
+      .filter((file) => path.dirname(file.relativePath) === dir)
+      .flatMap((file) => file.methods)
+      .filter((method) => method.receiver === target.name);
+    const pointerSet = new Map<string, GoMethodDecl>(declared.map((method) => [method.name, method]));
+    const valueSet = new Set(declared.filter((method) => !method.pointer).map((method) => method.name));
+
+    const lines = [`Type ${pkg ? `${pkg}.` : ''}${target.name} (${target.file.relativePath}:${target.line}), ` +
+      `${declared.length} method(s), ${declared.filter((method) => method.pointer).length} with pointer receivers`];
+    const satisfied: string[] = [];
+    const nearly: Array<{ text: string; gaps: number }> = [];
+    for (const candidate of candidates) {
+      const gaps: string[] = [];
+      let present = 0;
+      let pointerOnly = false;
+      for (const method of candidate.methods) {
+        const own = pointerSet.get(method.name);
+        if (!own) {
+          gaps.push(`missing ${formatGoMethod(method)}`);
+        } else if (qualify(own.signature, pkg) !== method.qualified) {
+          gaps.push(`${method.name} is ${formatGoMethod(own)}, want ${formatGoMethod(method)}`);
+        } else {
+          present++;
+          pointerOnly = pointerOnly || !valueSet.has(method.name);
+        }
+      }
+      const unchecked = candidate.unresolved.length > 0 ? `; embedded ${candidate.unresolved.join(', ')} not checked` : '';
+      if (gaps.length === 0) {
+        const via = pointerOnly ? `*${target.name} only` : `${target.name} and *${target.name}`;
+        satisfied.push(`  ${candidate.name} (${candidate.location}) - via ${via}${unchecked}`);
+      } else if (present > 0 && gaps.length <= maxMissing) {
+        nearly.push({ text: `  ${candidate.name} (${candidate.location}) - ${gaps.join('; ')}${unchecked}`, gaps: gaps.length });
+      }
+    }
+    lines.push('', satisfied.length > 0 ? `Implements (${satisfied.length}):` : 'Implements no interface with methods');
+    lines.push(...satisfied);
+    if (nearly.length > 0) {
+      lines.push('', `Nearly implements (${nearly.length}, at most ${maxMissing} method(s) off):`);
+      lines.push(...nearly.sort((a, b) => a.gaps - b.gaps).map((entry) => entry.text));
+    }
+    sections.push(lines.join('\n'));
+  }
+  return sections.join('\n\n---\n\n');
+}
@@ -0,0 +1,90 @@
+/**
+ * Tests for the Go package usage search
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { findPackageUsages, formatPackageUsages } from './gopackages';
+
+describe('gopackages', () => {
+  let workspace: string;
+
+  const write = (relativePath: string, lines: string[]) => {
+    fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+    fs.writeFileSync(path.join(workspace, relativePath), lines.join('\n') + '\n');
+  };
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'gopackages-')));
+    write('go.mod', ['module example.com/app', '', 'go 1.22']);
+    write('user/service.go', ['package user', '', 'func NewUserService() *Service { return &Service{} }']);
+    write('user-svc/client.go', ['package usersvc', '', 'func Dial() {}']);
+    write('api/handler.go', [
+      'package api',
+      '',
+      'import (',
+      '\t"fmt"',
+      '\tus "example.com/app/user"',
+      ')',
+      '',
+      '// user.NewUserService is not called here',
+      'func Handle() {',
+      '\tsvc := us.NewUserService()',
+      '\tfmt.Println(svc, "us.NewUserService", us.Default)',
+      '}',
+    ]);
+    write('cmd/main.go', [
+      'package main',
+      '',
+      'import "example.com/app/user"',
+      'import _ "example.com/app/user-svc"',
+      '',
+      'func main() { user.NewUserService() }',
+    ]);
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should follow aliases to the call sites of a package', async () => {
+    const result = await findPackageUsages(workspace, 'user');
+    expect(result.packages).toEqual([{ importPath: 'example.com/app/user', name: 'user' }]);
+    expect(result.usages).toEqual([
+      { filePath: 'api/handler.go', line: 10, column: 9, importPath: 'example.com/app/user', localName: 'us', symbol: 'NewUserService' },
+      { filePath: 'api/handler.go', line: 11, column: 40, importPath: 'example.com/app/user', localName: 'us', symbol: 'Default' },
+      { filePath: 'cmd/main.go', line: 6, column: 15, importPath: 'example.com/app/user', localName: 'user', symbol: 'NewUserService' },
+    ]);
+    expect(formatPackageUsages(result)).toBe([
+      'Found 3 usage(s) of example.com/app/user in 2 file(s), imported as us, user',
+      'Names used: NewUserService (2), Default (1)',
+      '',
+      'api/handler.go  (import us "example.com/app/user")',
+      '  L10:C9  us.NewUserService  (user.NewUserService)',
+      '  L11:C40  us.Default  (user.Default)',
+      '',
+      'cmd/main.go  (import "example.com/app/user")',
+      '  L6:C15  user.NewUserService',
+    ].join('\n'));
+  });
+
+  it('should name workspace packages by their package clause', async () => {
+    const result = await findPackageUsages(workspace, 'usersvc');
+    expect(result.packages).toEqual([{ importPath: 'example.com/app/user-svc', name: 'usersvc' }]);
+    expect(formatPackageUsages(result)).toBe([
+      'Found 1 usage(s) of example.com/app/user-svc (package usersvc) in 1 file(s), imported as _',
+      '',
+      'cmd/main.go  (import _ "example.com/app/user-svc")',
+      '  L4  imported for its side effects',
+    ].join('\n'));
+  });
+
+  it('should narrow by symbol and path', async () => {
+    const bySymbol = await findPackageUsages(workspace, 'example.com/app/user', { symbol: 'Default' });
+    expect(bySymbol.usages.map((usage) => `${usage.filePath}:${usage.line}`)).toEqual(['api/handler.go:11']);
+    const byPath = await findPackageUsages(workspace, 'user', { path: 'cmd' });
+    expect(byPath.usages.map((usage) => usage.filePath)).toEqual(['cmd/main.go']);
+    expect(formatPackageUsages(await findPackageUsages(workspace, 'payments'))).toBe('No Go file imports a package matching "payments" (4 file(s) scanned)');
+  });
+});
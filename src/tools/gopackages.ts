@@ -0,0 +1,263 @@
+/**
+ * Package usages - where a Go package is used, under whatever name each file imports it by
+ * A file importing example.com/user as us calls us.NewUserService, which a
+ * text search for user. never finds. Each Go file's imports are read with
+ * their aliases, and the selectors of the name it gives the package are
+ * its usages. Packages of the workspace are named by their package clause,
+ * others as goimports assumes from the import path
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { escapeRegExp } from '../search/lexical.js';
+import { blankGoLiterals, goPackageName, parseGoImports, GoImport } from '../symbols/golang.js';
+import { readFileText } from '../workspace/overlay.js';
+import { isUnder } from '../workspace/paths.js';
+import { resolveWorkspacePath, walkWorkspaceFiles } from '../workspace/walker.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the package usage search
+ */
+export interface PackageUsageOptions {
+  // Only list usages of this exported name, e.g. NewUserService
+  symbol?: string;
+  // Only search files under this directory (default: the workspace)
+  path?: string;
+}
+
+/**
+ * A use of a package in a file: a selector of its name, or a dot or blank import
+ */
+export interface PackageUsage {
+  filePath: string; // Relative to the workspace
+  line: number;
+  column: number; // 1-indexed
+  importPath: string;
+  // The name the file uses for the package; "." and "_" for dot and blank imports
+  localName: string;
+  // The selected name, e.g. NewUserService; empty for dot and blank imports
+  symbol: string;
+}
+
+/**
+ * Usages of the packages matching a query
+ */
+export interface PackageUsages {
+  query: string;
+  // Import paths of the packages matched, and the name each declares or is assumed to have
+  packages: Array<{ importPath: string; name: string }>;
+  usages: PackageUsage[];
+  importers: number;
+  filesScanned: number;
+}
+
+/**
+ * Package clause name of each directory of Go files, by workspace-relative directory
+ */
+function packageClauses(files: Array<{ relativePath: string; content: string }>): Map<string, string> {
+  const clauses = new Map<string, string>();
+  for (const file of [...files].sort((a, b) => a.relativePath.localeCompare(b.relativePath))) {
+    const dir = path.posix.dirname(file.relativePath.split(path.sep).join('/'));
+    // Test files may be of an external _test package
+    const clause = file.content.match(/^\s*package\s+(\w+)/m)?.[1];
+    if (clause && !file.relativePath.endsWith('_test.go') && !clauses.has(dir)) {
+      clauses.set(dir, clause);
+    }
+  }
+  return clauses;
+}
+
+/**
+ * Whether an import path is the package a query names
+ * The query is an import path, a trailing part of one (user, internal/user),
+ * or a package name
+ */
+function matchesQuery(query: string, importPath: string, name: string): boolean {
+  return importPath === query || importPath.endsWith('/' + query) || (!query.includes('/') && name === query);
+}
+
+/**
+ * Find the usages of a Go package across the workspace
+ * Locals shadowing a package's name are not told apart from it
+ */
+export async function findPackageUsages(workspaceDir: string, query: string, options: PackageUsageOptions = {}): Promise<PackageUsages> {
+  const wanted = query.trim().replace(/^"|"$/g, '');
+  if (!wanted) {
+    throw new ToolError('invalid-argument', 'package is required');
+  }
+  const within = options.path ? resolveWorkspacePath(workspaceDir, options.path) : undefined;
+
+  // Every Go file is read: the package clauses name the workspace's packages wherever they are
+  const files: Array<{ relativePath: string; absolutePath: string; content: string }> = [];
+  const modules: Array<{ modulePath: string; dir: string }> = [];
+  for (const file of await walkWorkspaceFiles(workspaceDir)) {
+    const isModule = path.basename(file.relativePath) === 'go.mod';
+    if (!isModule && !file.relativePath.endsWith('.go')) {
+      continue;
+    }
+    let content: string;
+    try {
+      content = await readFileText(file.absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', file.relativePath, err);
+      continue;
+    }
+    if (!isModule) {
+      files.push({ ...file, content });
+      continue;
+    }
+    const mod = content.match(/^module\s+"?([^"\s]+)"?/m);
+    if (mod) {
+      modules.push({ modulePath: mod[1], dir: path.posix.dirname(file.relativePath.split(path.sep).join('/')) });
+    }
+  }
+  const clauses = packageClauses(files);
+  // Longest first, so a nested module wins over the one around it
+  modules.sort((a, b) => b.modulePath.length - a.modulePath.length);
+  const nameOf = (importPath: string): string => {
+    for (const { modulePath, dir } of modules) {
+      if (importPath === modulePath || importPath.startsWith(modulePath + '/')) {
+        const clause = clauses.get(path.posix.join(dir, importPath.substring(modulePath.length)));
+        if (clause) {
+          return clause;
+        }
+      }
+    }
+    return goPackageName(importPath);
+  };
+
+  const packages = new Map<string, string>();
+  const usages: PackageUsage[] = [];
+  let importers = 0;
+  let filesScanned = 0;
+  for (const file of files) {
+    if (within && !isUnder(file.absolutePath, within)) {
+      continue;
+    }
+    filesScanned++;
+    const specs = parseGoImports(file.content).filter((spec) => {
+      const name = nameOf(spec.path);
+      if (!matchesQuery(wanted, spec.path, name)) {
+        return false;
+      }
+      packages.set(spec.path, name);
+      return true;
+    });
+    if (specs.length === 0) {
+      continue;
+    }
+    importers++;
+    const relativePath = file.relativePath.split(path.sep).join('/');
+    const text = blankGoLiterals(file.content);
+    const lineStarts = [0];
+    for (let i = 0; i < text.length; i++) {
+      if (text.charCodeAt(i) === 10) {
+        lineStarts.push(i + 1);
+      }
+    }
+    const position = (offset: number) => {
+      let line = lineStarts.length - 1;
+      while (lineStarts[line] > offset) {
+        line--;
+      }
+      return { line: line + 1, column: offset - lineStarts[line] + 1 };
+    };
+    for (const spec of specs) {
+      usages.push(...specUsages(spec, nameOf(spec.path), text, position, options.symbol)
+        .map((usage) => ({ filePath: relativePath, importPath: spec.path, ...usage })));
+    }
+  }
+
+  toolsLogger.debug('Found %d usage(s) of package %s in %d importer(s)', usages.length, wanted, importers);
+  return {
+    query: wanted,
+    packages: Array.from(packages, ([importPath, name]) => ({ importPath, name })).sort((a, b) => a.importPath.localeCompare(b.importPath)),
+    usages,
+    importers,
+    filesScanned,
+  };
+}
+
+/**
+ * Usages of one import spec in a file whose literals are blanked
+ */
+function specUsages(
+  spec: GoImport,
+  name: string,
+  text: string,
+  position: (offset: number) => { line: number; column: number },
+  symbol?: string
+): Array<Omit<PackageUsage, 'filePath' | 'importPath'>> {
+  if (spec.alias === '_' || spec.alias === '.') {
+    // Dot imports use the package's names unqualified, so only the import is certain
+    return symbol ? [] : [{ line: spec.line, column: 1, localName: spec.alias, symbol: '' }];
+  }
+  const localName = spec.alias ?? name;
+  const selector = new RegExp(`(?<![\\w.])${escapeRegExp(localName)}[ \\t]*\\.[ \\t]*([A-Za-z_]\\w*)`, 'g');
+  const found: Array<Omit<PackageUsage, 'filePath' | 'importPath'>> = [];
+  let match: RegExpExecArray | null;
+  while ((match = selector.exec(text)) !== null) {
+    if (!symbol || match[1] === symbol) {
+      found.push({ ...position(match.index), localName, symbol: match[1] });
+    }
+  }
+  return found;
+}
+
+/**
+ * Format usages grouped by file, with the names used most often first
+ */
+export function formatPackageUsages(result: PackageUsages, limit = 200): string {
+  const { query, packages, usages } = result;
+  if (packages.length === 0) {
+    return `No Go file imports a package matching "${query}" (${result.filesScanned} file(s) scanned)`;
+  }
+  // A package named other than its path suggests is shown with its name
+  const described = packages.map((pkg) =>
+    pkg.name === goPackageName(pkg.importPath) ? pkg.importPath : `${pkg.importPath} (package ${pkg.name})`);
+  const nameOf = new Map(packages.map((pkg) => [pkg.importPath, pkg.name]));
+  const aliases = Array.from(new Set(usages.map((usage) => usage.localName)));
+  if (usages.length === 0) {
+    return `No usages of ${described.join(', ')} in ${result.importers} importing file(s)`;
+  }
+  const lines = [`Found ${usages.length} usage(s) of ${described.join(', ')} in ${result.importers} file(s), imported as ${aliases.join(', ')}`];
+
+  const counts = new Map<string, number>();
+  for (const usage of usages) {
+    if (usage.symbol) {
+      counts.set(usage.symbol, (counts.get(usage.symbol) ?? 0) + 1);
+    }
+  }
+  if (counts.size > 0) {
+    lines.push(`Names used: ${Array.from(counts).sort((a, b) => b[1] - a[1] || a[0].localeCompare(b[0]))
+      .map(([symbol, count]) => `${symbol} (${count})`).join(', ')}`);
+  }
+
+  const shown = usages.slice(0, limit);
+  if (shown.length < usages.length) {
+    lines[0] += ` (showing first ${shown.length})`;
+  }
+  let current: string | undefined;
+  for (const usage of shown) {
+    const key = `${usage.filePath}\n${usage.importPath}\n${usage.localName}`;
+    if (key !== current) {
+      current = key;
+      const imported = usage.localName === nameOf.get(usage.importPath) ? `"${usage.importPath}"` : `${usage.localName} "${usage.importPath}"`;
+      lines.push('', `${usage.filePath}  (import ${imported})`);
+    }
+    if (usage.localName === '_') {
+      lines.push(`  L${usage.line}  imported for its side effects`);
+    } else if (usage.localName === '.') {
+      lines.push(`  L${usage.line}  dot import: its names are used unqualified`);
+    } else {
+      // Aliased selectors are shown under the package's own name too
+      const name = nameOf.get(usage.importPath);
+      const canonical = usage.localName === name ? '' : `  (${name}.${usage.symbol})`;
+      lines.push(`  L${usage.line}:C${usage.column}  ${usage.localName}.${usage.symbol}${canonical}`);
+    }
+  }
+  return lines.join('\n');
+}
@@ -0,0 +1,66 @@
+/**
+ * Tests for the symbol history tool
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { symbolHistory } from './history';
+import { parseLineRangeLog } from '../git/git';
+
+describe('symbol history', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'history-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should parse line range logs', () => {
+    const log = '\x1eaaa\x1fAna\x1f200\x1fedit\n\ndiff --git a/b.py b/b.py\n--- a/b.py\n+++ b/b.py\n@@ -2,2 +2,3 @@\n def f():\n---    x\n+    y\n+    w\n' +
+      '\x1ebbb\x1fBo\x1f100\x1fadd\n\ndiff --git a/a.py b/a.py\n--- /dev/null\n+++ b/a.py\n@@ -0,0 +2,2 @@\n+def f():\n+    x\n';
+    const commits = parseLineRangeLog(log);
+    expect(commits.map(({ commit, path: file, added, removed }) => ({ commit, file, added, removed }))).toEqual([
+      { commit: 'aaa', file: 'b.py', added: 2, removed: 1 },
+      { commit: 'bbb', file: 'a.py', added: 2, removed: 0 },
+    ]);
+    expect(commits[0].patch).toMatch(/^diff --git/);
+  });
+
+  it('should follow a function through edits and renames', async () => {
+    try {
+      const git = (...args: string[]) => execFileSync('git', ['-c', 'user.name=t', '-c', 'user.email=t@t', ...args], { cwd: workspace });
+      git('init', '-q');
+      fs.writeFileSync(path.join(workspace, 'old.py'), 'def load():\n    return 1\n\n\ndef other():\n    return 2\n');
+      git('add', 'old.py');
+      git('commit', '-qm', 'add load', '--date=2020-01-01T00:00:00Z');
+      git('mv', 'old.py', 'util.py');
+      git('commit', '-qm', 'rename', '--date=2020-06-01T00:00:00Z');
+      fs.writeFileSync(path.join(workspace, 'util.py'), '# utilities\n\ndef load():\n    value = 1\n    return value\n\n\ndef other():\n    return 3\n');
+      git('commit', '-qam', 'split load', '--date=2021-01-01T00:00:00Z');
+      fs.writeFileSync(path.join(workspace, 'util.py'), '# utilities\n\ndef load():\n    value = 1\n    return value\n\n\ndef other():\n    return 4\n');
+      git('commit', '-qam', 'change other', '--date=2022-01-01T00:00:00Z');
+    } catch (err) {
+      return; // git is not available
+    }
+    const result = await symbolHistory(undefined, workspace, path.join(workspace, 'util.py'), 'load');
+    const lines = result.split('\n');
+    expect(lines[0]).toBe('History of load (Function) in util.py, lines 3-5: 2 commit(s)');
+    expect(lines[2]).toMatch(/^[0-9a-f]{7} 2021-01-01 t: split load {2}\(\+2 -1\)$/);
+    expect(lines[3]).toMatch(/^[0-9a-f]{7} 2020-01-01 t: add load {2}\(\+2 -0, in old\.py\)$/);
+
+    const patched = await symbolHistory(undefined, workspace, path.join(workspace, 'util.py'), 'load', { maxCommits: 1, includePatches: true });
+    expect(patched).toContain('1 commit(s)');
+    expect(patched).toContain('    +    value = 1');
+    await expect(symbolHistory(undefined, workspace, path.join(workspace, 'util.py'), 'missing')).rejects.toThrow('No symbol named missing in util.py');
+  });
+
+  it('should report files without history', async () => {
+    fs.writeFileSync(path.join(workspace, 'a.py'), 'def f():\n    pass\n');
+    expect(await symbolHistory(undefined, workspace, path.join(workspace, 'a.py'), 'f')).toMatch(/^a\.py has no history/);
+  });
+});
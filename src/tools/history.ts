@@ -0,0 +1,101 @@
+/**
+ * Symbol history tool - the commits that changed one function or type,
+ * rather than every commit to its file
+ * Reads the symbol's line range from the language server and follows it
+ * back with `git log -L`, through edits around it and file renames
+ */
+
+import * as path from 'path';
+import { fileGitStatus, lineRangeHistory, LineRangeCommit } from '../git/git.js';
+import { LSPClient } from '../lsp/client.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { SymbolKindNames } from '../protocol/types.js';
+import { FlatSymbol, getFileSymbols } from './symbols.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the symbol history tool
+ */
+export interface SymbolHistoryOptions {
+  // Most commits listed (default: 20)
+  maxCommits?: number;
+  // Show each commit's diff of the symbol (default: false)
+  includePatches?: boolean;
+}
+
+/**
+ * Longest patch shown per commit, in lines
+ */
+const MAX_PATCH_LINES = 60;
+
+/**
+ * Find a symbol of a file by name or qualified name ("Server.handle")
+ * Exact qualified names win over a bare name shared by several symbols
+ */
+export function findFileSymbol(symbols: FlatSymbol[], symbolName: string): FlatSymbol[] {
+  const qualified = symbols.filter((sym) => sym.qualifiedName === symbolName);
+  if (qualified.length > 0) {
+    return qualified;
+  }
+  return symbols.filter((sym) => sym.name === symbolName || sym.qualifiedName.endsWith(`.${symbolName}`));
+}
+
+/**
+ * Format one commit line, and its patch when asked
+ */
+function formatCommit(commit: LineRangeCommit, currentPath: string, includePatches: boolean): string {
+  const date = new Date(commit.authorTime * 1000).toISOString().substring(0, 10);
+  const renamed = commit.path && commit.path !== currentPath ? `, in ${commit.path}` : '';
+  let entry = `${commit.commit.substring(0, 7)} ${date} ${commit.author}: ${commit.summary}  (+${commit.added} -${commit.removed}${renamed})`;
+  if (includePatches && commit.patch) {
+    const hunks = commit.patch.split('\n');
+    const start = hunks.findIndex((line) => line.startsWith('@@'));
+    const shown = hunks.slice(Math.max(0, start), Math.max(0, start) + MAX_PATCH_LINES);
+    entry += '\n' + shown.map((line) => `    ${line}`).join('\n');
+    if (hunks.length - Math.max(0, start) > MAX_PATCH_LINES) {
+      entry += '\n    ...';
+    }
+  }
+  return entry;
+}
+
+/**
+ * Change history of a symbol of a file, newest commit first
+ */
+export async function symbolHistory(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  filePath: string,
+  symbolName: string,
+  options: SymbolHistoryOptions = {}
+): Promise<string> {
+  const relativePath = path.relative(workspaceDir, filePath);
+  const matches = findFileSymbol(await getFileSymbols(client, workspaceDir, filePath), symbolName);
+  if (matches.length === 0) {
+    throw new ToolError('not-found', `No symbol named ${symbolName} in ${relativePath}`);
+  }
+  const sym = matches[0];
+  const start = sym.range.start.line + 1;
+  const end = sym.range.end.line + 1;
+  toolsLogger.debug('Reading history of %s at %s:%d-%d', sym.qualifiedName, relativePath, start, end);
+
+  const status = await fileGitStatus(filePath);
+  if (status === 'untracked' || status === 'ignored' || status === 'not in a repository' || status === 'added') {
+    return `${relativePath} has no history (${status})`;
+  }
+  const commits = await lineRangeHistory(filePath, start, end, options.maxCommits ?? 20);
+
+  const kind = SymbolKindNames[sym.kind] || 'Unknown';
+  let output = `History of ${sym.qualifiedName} (${kind}) in ${relativePath}, lines ${start}-${end}: ${commits.length} commit(s)\n`;
+  if (matches.length > 1) {
+    output += `Also named ${symbolName}: ${matches.slice(1).map((m) => `${m.qualifiedName} L${m.range.start.line + 1}`).join(', ')}\n`;
+  }
+  if (status === 'modified' || status === 'renamed') {
+    output += `Note: ${relativePath} has uncommitted changes; git reads lines ${start}-${end} in the committed version\n`;
+  }
+  const currentPath = commits[0]?.path ?? '';
+  const entries = commits.map((commit) => formatCommit(commit, currentPath, options.includePatches ?? false));
+  return (output + '\n' + entries.join(options.includePatches ? '\n\n' : '\n')).trimEnd();
+}
@@ -7,6 +7,7 @@ import { hover as lspHover } from '../lsp/methods.js';
 import { createLogger, Component } from '../logging/logger.js';
 import { HoverParams, TextDocumentIdentifier, Position } from '../protocol/types.js';
 import { pathToUri } from '../protocol/uri.js';
+import { ToolError } from '../workspace/errors.js';
 
 const toolsLogger = createLogger(Component.TOOLS);
 
@@ -24,7 +25,7 @@ export async function getHoverInfo(
   try {
     await client.openFile(filePath);
   } catch (err) {
-    throw new Error(`Error opening file: ${err}`);
+    throw new ToolError('not-found', `Error opening file: ${err}`);
   }
 
   const params: HoverParams = {
@@ -0,0 +1,164 @@
+/**
+ * Tests for the localization tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { findMessages, indexMessages, localeOf, parseMessageResource, parsePoFile, scanMessageUsages, translatedGroups } from './i18n';
+
+describe('i18n_messages', () => {
+  describe('resource files', () => {
+    it('should tell locales from other names', () => {
+      expect(localeOf('en')).toBe('en');
+      expect(localeOf('pt-BR')).toBe('pt-BR');
+      expect(localeOf('zh_Hant')).toBe('zh_Hant');
+      expect(localeOf('ui')).toBeUndefined();
+      expect(localeOf('common')).toBeUndefined();
+    });
+
+    it('should flatten nested keys with the locale from the path or a Rails top-level key', () => {
+      expect(parseMessageResource('locales/fr/auth.json', '{\n  "login": {\n    "title": "Connexion"\n  }\n}\n')).toEqual([
+        { key: 'login.title', value: 'Connexion', line: 3, locale: 'fr', namespace: 'auth', filePath: 'locales/fr/auth.json' },
+      ]);
+      expect(parseMessageResource('config/locales/devise.yml', 'de:\n  greeting: Hallo\n').map((d) => [d.locale, d.key, d.value]))
+        .toEqual([['de', 'greeting', 'Hallo']]);
+      expect(parseMessageResource('config/settings.yml', 'en:\n  greeting: Hi\n')).toEqual([]);
+    });
+
+    it('should take files named by a locale elsewhere only next to another locale of the same file', () => {
+      const groups = translatedGroups(['src/en.json', 'src/fr.json', 'schemas/id.json', 'fixtures/my.json', 'config/no.yaml', 'config/it.json']);
+      expect(parseMessageResource('src/fr.json', '{"hi": "Salut"}', groups).map((d) => [d.locale, d.key])).toEqual([['fr', 'hi']]);
+      for (const lone of ['schemas/id.json', 'fixtures/my.json', 'config/no.yaml', 'config/it.json']) {
+        expect(parseMessageResource(lone, lone.endsWith('.json') ? '{"type": "object"}' : 'type: object\n', groups)).toEqual([]);
+      }
+    });
+
+    it('should read gettext catalogs, joining continued strings', () => {
+      const po = [
+        'msgid ""',
+        'msgstr ""',
+        '"Language: es\\n"',
+        '',
+        '#: app.py:3',
+        'msgid "Hello, "',
+        '"world"',
+        'msgstr "Hola, mundo"',
+        '',
+        'msgid "One file"',
+        'msgid_plural "%d files"',
+        'msgstr[0] "Un archivo"',
+        'msgstr[1] "%d archivos"',
+      ].join('\n');
+      expect(parsePoFile('po/app.po', po)).toEqual([
+        { key: 'Hello, world', locale: 'es', value: 'Hola, mundo', filePath: 'po/app.po', line: 6 },
+        { key: 'One file', locale: 'es', value: 'Un archivo', filePath: 'po/app.po', line: 10 },
+      ]);
+    });
+
+    it('should key entries with a context by it, and decode the header charset', () => {
+      const po = Buffer.concat([
+        Buffer.from('msgid ""\nmsgstr ""\n"Language: fr\\n"\n"Content-Type: text/plain; charset=ISO-8859-1\\n"\n\n'),
+        Buffer.from('msgctxt "menu"\nmsgid "Open"\nmsgstr "Ouvrir"\n\nmsgid "Open"\nmsgstr "Ouvert"\n\nmsgid "Caf', 'latin1'),
+        Buffer.from([0xe9]),
+        Buffer.from('"\nmsgstr "Caf', 'latin1'),
+        Buffer.from([0xe9]),
+        Buffer.from('"\n', 'latin1'),
+      ]);
+      expect(parsePoFile('po/fr.po', po).map((d) => [d.key, d.value])).toEqual([
+        ['menu\x04Open', 'Ouvrir'],
+        ['Open', 'Ouvert'],
+        ['Café', 'Café'],
+      ]);
+      expect(scanMessageUsages('app.py', 'pgettext("menu", "Open")')[0].key).toBe('menu\x04Open');
+    });
+  });
+
+  describe('scanMessageUsages', () => {
+    it('should find lookups with their position, keeping run-time keys as prefixes', () => {
+      const source = [
+        "const title = t('auth.login.title');",
+        'const err = i18n.t(`errors.${code}`);',
+        '<FormattedMessage id="cart.total" />',
+        "print(_(\"Hello, world\"), t('.relative'))",
+      ].join('\n');
+      expect(scanMessageUsages('src/app.tsx', source).map((u) => [u.accessor, u.key, u.dynamic, u.line, u.column])).toEqual([
+        ['t', 'auth.login.title', false, 1, 18],
+        ['i18n.t', 'errors.', true, 2, 21],
+        ['FormattedMessage', 'cart.total', false, 3, 23],
+        ['_', 'Hello, world', false, 4, 10],
+      ]);
+    });
+  });
+
+  describe('findMessages', () => {
+    let workspace: string;
+
+    const write = (relativePath: string, content: string) => {
+      fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, relativePath), content);
+    };
+
+    beforeEach(() => {
+      workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'i18n-')));
+      write('locales/en/auth.json', JSON.stringify({ login: { title: 'Sign in', help: 'Forgot password?' }, legacy: 'Old' }, null, 2));
+      write('locales/fr/auth.json', JSON.stringify({ login: { title: 'Connexion' } }, null, 2));
+      write('locales/en/errors.json', JSON.stringify({ errors: { notFound: 'Not found', item_one: 'One item', item_other: 'Items' } }, null, 2));
+      write('src/Login.tsx', [
+        "export const Title = () => t('auth:login.title');",
+        "export const Help = () => t('login.help');",
+        'export const Err = (code: string) => t(`errors.${code}`);',
+        "export const Missing = () => t('checkout.total');",
+      ].join('\n'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should index resources and lookups, splitting namespaces off keys', async () => {
+      const index = await indexMessages(workspace);
+      expect(index).toMatchObject({ resourceFiles: 3, sourceFiles: 1 });
+      expect(index.usages[0]).toMatchObject({ key: 'login.title', namespace: 'auth' });
+    });
+
+    it('should show where a key is defined and used', async () => {
+      expect(await findMessages(workspace, { key: 'login.title' })).toBe([
+        'auth:login.title',
+        '  Defined in 2 locale(s):',
+        '    en  "Sign in"  locales/en/auth.json:3',
+        '    fr  "Connexion"  locales/fr/auth.json:3',
+        '  Used 1 time(s):',
+        '    src/Login.tsx:1:31  t("auth:login.title")',
+      ].join('\n'));
+      expect(await findMessages(workspace, { key: 'login' })).toBe('No message key login is defined or used; keys containing it: auth:login.help, auth:login.title');
+    });
+
+    it('should split the namespace off a key and keep the same key of two namespaces apart', async () => {
+      write('locales/en/errors.json', JSON.stringify({ errors: { notFound: 'Not found' }, login: { title: 'Login failed' } }, null, 2));
+      expect(await findMessages(workspace, { key: 'auth:login.title' })).toMatch(/^auth:login\.title\n  Defined in 2 locale\(s\):/);
+      const both = await findMessages(workspace, { key: 'login.title' });
+      expect(both).toContain('auth:login.title\n  Defined in 2 locale(s):');
+      expect(both).toContain('errors:login.title\n  Defined in 1 locale(s), missing in fr:');
+      const summary = await findMessages(workspace, {});
+      expect(summary).toContain('  fr (4): auth:legacy, auth:login.help, errors:errors.notFound, errors:login.title');
+      expect(summary).toContain('Defined but not used (2): auth:legacy, errors:login.title');
+    });
+
+    it('should find keys by their text', async () => {
+      const text = await findMessages(workspace, { text: 'password' });
+      expect(text).toContain('1 message key(s) match "password"');
+      expect(text).toContain('auth:login.help\n  Defined in 1 locale(s), missing in fr:');
+      expect(text).toContain('    src/Login.tsx:2:30  t("login.help")');
+    });
+
+    it('should summarize missing translations, undefined keys, and unused keys', async () => {
+      const text = await findMessages(workspace, {});
+      expect(text).toContain('Messages: 6 key(s) in 2 locale(s) (en 6, fr 1) from 3 resource file(s); 4 lookup(s) in 1 file(s)');
+      expect(text).toContain('  fr (5): auth:legacy, auth:login.help, errors:errors.item_one, errors:errors.item_other, errors:errors.notFound');
+      expect(text).toContain('Used but not defined (1):\n  checkout.total  src/Login.tsx:4');
+      // The run-time key reaches every errors. key
+      expect(text).toContain('Defined but not used (1): auth:legacy');
+    });
+  });
+});
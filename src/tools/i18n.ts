@@ -0,0 +1,590 @@
+/**
+ * Localization tool - message keys from resource files to the code using them, and back
+ * Indexes the messages of i18n resource files (JSON and YAML under locale
+ * directories or named by locale, gettext .po catalogs) and the calls that
+ * look them up (t('key'), $t, i18n.T, formatMessage, gettext and _(...)),
+ * so where a UI string is defined, in which locales, and where it is used
+ * is one query. Keys built at run time (t(`errors.${code}`)) are kept as
+ * prefixes, and count as uses of every key they can reach
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { parseStructured, structuredFormat, StructuredNode } from '../search/structured.js';
+import { isSourceFile } from '../workspace/language.js';
+import { readFileBytes, readFileText } from '../workspace/overlay.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the localization tool
+ */
+export interface MessageOptions {
+  // Only index resource and source files under this path
+  path?: string;
+  // Only show definitions of this locale, e.g. en or pt-BR
+  locale?: string;
+}
+
+/**
+ * A message of one locale in a resource file
+ */
+export interface MessageDefinition {
+  key: string; // Dotted path of nested keys, or the msgid of a .po entry, after its msgctxt and \x04 when it has one
+  locale: string;
+  namespace?: string; // i18next-style namespace: the file name under a locale directory
+  value: string;
+  filePath: string; // Relative to the workspace
+  line: number;
+}
+
+/**
+ * A lookup of a message key in code
+ */
+export interface MessageUsage {
+  key: string; // The literal key, or the literal prefix of a key built at run time; pgettext's context and \x04 come first
+  namespace?: string;
+  dynamic: boolean;
+  accessor: string; // e.g. t, i18n.t, gettext, formatMessage
+  filePath: string;
+  line: number;
+  column: number; // 1-indexed
+}
+
+/**
+ * Messages and lookups of a workspace
+ */
+export interface MessageIndex {
+  definitions: MessageDefinition[];
+  usages: MessageUsage[];
+  resourceFiles: number;
+  sourceFiles: number;
+}
+
+/**
+ * Directory names holding resource files, whatever their file names
+ */
+const RESOURCE_DIRS = new Set(['locales', 'locale', 'i18n', 'l10n', 'lang', 'langs', 'languages', 'translations', 'messages', 'intl']);
+
+/**
+ * Language codes a file or directory may be named by (ISO 639-1, the common ones)
+ */
+const LANGUAGE_CODES = new Set([
+  'af', 'am', 'ar', 'az', 'be', 'bg', 'bn', 'bs', 'ca', 'cs', 'cy', 'da', 'de', 'el', 'en', 'eo', 'es', 'et', 'eu',
+  'fa', 'fi', 'fil', 'fr', 'ga', 'gl', 'gu', 'he', 'hi', 'hr', 'hu', 'hy', 'id', 'is', 'it', 'ja', 'ka', 'kk', 'km',
+  'kn', 'ko', 'ky', 'lo', 'lt', 'lv', 'mk', 'ml', 'mn', 'mr', 'ms', 'my', 'nb', 'ne', 'nl', 'nn', 'no', 'pa', 'pl',
+  'pt', 'ro', 'ru', 'si', 'sk', 'sl', 'sq', 'sr', 'sv', 'sw', 'ta', 'te', 'th', 'tl', 'tr', 'uk', 'ur', 'uz', 'vi',
+  'zh', 'zu',
+]);
+
+/**
+ * Template files scanned for lookups besides source files
+ */
+const TEMPLATE_EXTENSIONS = ['.vue', '.svelte', '.html', '.erb', '.haml', '.hbs', '.jinja', '.j2', '.twig'];
+
+/**
+ * i18next plural and context suffixes: t('item', { count }) reads item_one and item_other
+ */
+const PLURAL_SUFFIX = /_(zero|one|two|few|many|other|plural)$/;
+
+/**
+ * The locale a name is, e.g. en, pt-BR, zh_Hant; undefined when it is not one
+ */
+export function localeOf(name: string): string | undefined {
+  const match = name.match(/^([a-z]{2,3})(?:[-_]([A-Za-z]{2}|\d{3}|[A-Z][a-z]{3}))?$/);
+  return match && LANGUAGE_CODES.has(match[1]) ? name : undefined;
+}
+
+/**
+ * The files a file named by a locale is translated with: its directory,
+ * name without the locale, and extension; undefined when it is not named by one
+ */
+function localeGroup(relativePath: string): { group: string; locale: string } | undefined {
+  const slash = relativePath.lastIndexOf('/');
+  const name = relativePath.substring(slash + 1);
+  const ext = path.posix.extname(name);
+  const parts = name.substring(0, name.length - ext.length).split('.');
+  const locale = localeOf(parts[parts.length - 1]);
+  return locale ? { group: `${relativePath.substring(0, slash + 1)}${parts.slice(0, -1).join('.')}\0${ext}`, locale } : undefined;
+}
+
+/**
+ * Groups of files named by locales with files of at least two locales, e.g.
+ * en.json and fr.json in one directory; a file named by a locale outside a
+ * locale directory is a resource only in one of these, so schemas/id.json
+ * or fixtures/my.json alone are not
+ */
+export function translatedGroups(paths: Iterable<string>): Set<string> {
+  const locales = new Map<string, Set<string>>();
+  for (const relativePath of paths) {
+    const named = localeGroup(relativePath);
+    if (named) {
+      locales.set(named.group, (locales.get(named.group) ?? new Set<string>()).add(named.locale));
+    }
+  }
+  return new Set(Array.from(locales).filter(([, found]) => found.size > 1).map(([group]) => group));
+}
+
+/**
+ * Locale and namespace of a JSON or YAML resource file, or undefined when it is not one
+ * A file is a resource when it sits in a locale directory (locales/en/common.json,
+ * i18n/de.json), or is named by a locale (en.json, messages.fr.yml) next to
+ * the same file for another locale
+ */
+function resourceLocale(relativePath: string, groups: ReadonlySet<string>): { locale?: string; namespace?: string } | undefined {
+  const segments = relativePath.split('/');
+  const stem = segments.pop()!.replace(/\.[^.]+$/, '');
+  const parts = stem.split('.');
+  const named = localeOf(parts[parts.length - 1]);
+  const inResourceDir = segments.some((segment) => RESOURCE_DIRS.has(segment.toLowerCase()));
+  if (named && (inResourceDir || groups.has(localeGroup(relativePath)!.group))) {
+    return { locale: named, namespace: parts.length > 1 ? parts.slice(0, -1).join('.') : undefined };
+  }
+  if (!inResourceDir) {
+    return undefined;
+  }
+  // The nearest directory named by a locale; the file is a namespace in it
+  for (let i = segments.length - 1; i >= 0; i--) {
+    const locale = localeOf(segments[i]);
+    if (locale) {
+      return { locale, namespace: stem };
+    }
+  }
+  // The locale may be the document's only top-level key, as Rails writes them
+  return {};
+}
+
+/**
+ * Flatten a resource document into dotted keys and their text
+ */
+function flattenMessages(root: StructuredNode, prefix: string, out: Array<{ key: string; value: string; line: number }>): void {
+  if (root.kind !== 'map') {
+    return;
+  }
+  for (const entry of root.entries) {
+    const key = prefix ? `${prefix}.${entry.key}` : entry.key;
+    if (entry.value.kind === 'scalar') {
+      out.push({ key, value: entry.value.value, line: entry.keyPosition.line });
+    } else {
+      flattenMessages(entry.value, key, out);
+    }
+  }
+}
+
+/**
+ * Messages of a JSON or YAML resource file
+ * Groups are the translatedGroups of the workspace's files
+ */
+export function parseMessageResource(relativePath: string, content: string, groups: ReadonlySet<string> = new Set()): MessageDefinition[] {
+  const format = structuredFormat(relativePath);
+  const resource = format ? resourceLocale(relativePath, groups) : undefined;
+  if (!format || !resource) {
+    return [];
+  }
+  const definitions: MessageDefinition[] = [];
+  for (const doc of parseStructured(content, format)) {
+    let { locale } = resource;
+    let root = doc;
+    // A single top-level locale key, as Rails writes them, is the locale rather than part of each key
+    if (doc.kind === 'map' && doc.entries.length === 1 && localeOf(doc.entries[0].key) &&
+      (!locale || locale.toLowerCase() === doc.entries[0].key.toLowerCase())) {
+      locale = doc.entries[0].key;
+      root = doc.entries[0].value;
+    }
+    if (!locale) {
+      continue;
+    }
+    const messages: Array<{ key: string; value: string; line: number }> = [];
+    flattenMessages(root, '', messages);
+    definitions.push(...messages.map((message) => ({
+      ...message,
+      locale: locale!,
+      ...(resource.namespace ? { namespace: resource.namespace } : {}),
+      filePath: relativePath,
+    })));
+  }
+  return definitions;
+}
+
+/**
+ * Text of a .po string line: "..." with C escapes
+ */
+function poString(text: string): string {
+  const quoted = text.trim().match(/^"(.*)"$/);
+  return quoted ? quoted[1].replace(/\\(.)/g, (_, ch: string) => (ch === 'n' ? '\n' : ch === 't' ? '\t' : ch)) : '';
+}
+
+/**
+ * Text of a catalog in the charset its header names, UTF-8 when it names none or one Node cannot decode
+ * The header is ASCII, so it is found before the charset is known
+ */
+function decodePoFile(bytes: Buffer): string {
+  const charset = bytes.toString('latin1').match(/"Content-Type:[^"]*charset=([\w.:-]+)/i)?.[1];
+  if (charset && !/^utf-?8$/i.test(charset)) {
+    try {
+      return new TextDecoder(charset).decode(bytes);
+    } catch {
+      toolsLogger.debug('Unknown catalog charset %s; reading it as UTF-8', charset);
+    }
+  }
+  return bytes.toString('utf8');
+}
+
+/**
+ * Messages of a gettext catalog, keyed by msgid, or by msgctxt, \x04, and
+ * msgid as gettext keys them when an entry has a context; the locale is the
+ * header's Language, else the file or directory name
+ * (locale/fr/LC_MESSAGES/app.po). Obsolete (#~) entries are left out.
+ * Bytes are decoded in the header's charset
+ */
+export function parsePoFile(relativePath: string, content: string | Buffer): MessageDefinition[] {
+  const entries: Array<{ id: string; context?: string; value: string; line: number }> = [];
+  let current: { id: string; context?: string; value: string; line: number } | undefined;
+  let context: string | undefined;
+  let field: 'context' | 'id' | 'value' | 'other' | undefined;
+  const lines = (typeof content === 'string' ? content : decodePoFile(content)).split('\n');
+  lines.forEach((raw, index) => {
+    const line = raw.trim();
+    const keyword = line.match(/^(msgctxt|msgid_plural|msgid|msgstr(?:\[(\d+)\])?)\s+(".*")$/);
+    if (keyword) {
+      if (keyword[1] === 'msgid') {
+        current = { id: poString(keyword[3]), ...(context !== undefined ? { context } : {}), value: '', line: index + 1 };
+        context = undefined;
+        entries.push(current);
+        field = 'id';
+      } else if (keyword[1] === 'msgctxt') {
+        context = poString(keyword[3]);
+        field = 'context';
+      } else if (keyword[1].startsWith('msgstr') && (keyword[2] === undefined || keyword[2] === '0')) {
+        if (current) {
+          current.value = poString(keyword[3]);
+        }
+        field = 'value';
+      } else {
+        field = 'other';
+      }
+    } else if (line.startsWith('"') && field === 'context') {
+      context += poString(line);
+    } else if (line.startsWith('"') && current && (field === 'id' || field === 'value')) {
+      current[field === 'id' ? 'id' : 'value'] += poString(line);
+    } else if (line === '' || line.startsWith('#')) {
+      field = undefined;
+    }
+  });
+
+  const header = entries.find((entry) => entry.id === '' && entry.context === undefined);
+  const segments = relativePath.split('/');
+  const stem = segments[segments.length - 1].replace(/\.po$/, '');
+  const locale = header?.value.match(/^Language:\s*(\S+)/m)?.[1] ??
+    localeOf(stem) ?? segments.slice(0, -1).reverse().map(localeOf).find((found) => found !== undefined);
+  if (!locale) {
+    return [];
+  }
+  return entries
+    .filter((entry) => entry !== header)
+    .map((entry) => ({
+      key: entry.context !== undefined ? `${entry.context}\x04${entry.id}` : entry.id,
+      locale,
+      value: entry.value,
+      filePath: relativePath,
+      line: entry.line,
+    }));
+}
+
+/**
+ * A string literal argument: groups are the single-quoted, double-quoted, and
+ * template text, then how the template ended (a backtick, or ${ for a key built at run time)
+ */
+const STRING = String.raw`(?:'((?:[^'\\\n]|\\.)*)'|"((?:[^"\\\n]|\\.)*)"|\x60((?:[^\x60\\$]|\\.|\$(?!\{))*)(\x60|\$\{))`;
+
+/**
+ * Lookups of message keys; a rule without an accessor takes it from group 1,
+ * and one with a context takes it, quoted, from group 2
+ */
+const LOOKUP_RULES: Array<{ accessor?: string; context?: boolean; pattern: RegExp }> = [
+  // t, $t, $tc, i18n.t, I18n.t, this.$t, and Go's localizer.T
+  { pattern: new RegExp(String.raw`(?<![\w$])((?:[\w$]+\.)*\$?tc?|(?:[\w$]+\.)+T)\(\s*${STRING}`, 'g') },
+  { accessor: 'translate', pattern: new RegExp(String.raw`(?<![\w$.])translate\(\s*${STRING}`, 'g') },
+  { accessor: 'formatMessage', pattern: new RegExp(String.raw`\bformatMessage\(\s*\{[^}]*?\bid\s*:\s*${STRING}`, 'g') },
+  { accessor: 'FormattedMessage', pattern: new RegExp(String.raw`<FormattedMessage\b[^>]*?\bid=\{?\s*${STRING}`, 'g') },
+  { accessor: 'i18nKey', pattern: new RegExp(String.raw`\bi18nKey=\{?\s*${STRING}`, 'g') },
+  { accessor: 'MessageID', pattern: new RegExp(String.raw`\bMessageID\s*:\s*${STRING}`, 'g') },
+  { pattern: new RegExp(String.raw`(?<![\w$])(gettext|gettext_lazy|gettext_noop|ugettext|ngettext|N_|__|_)\(\s*${STRING}`, 'g') },
+  // The context comes first; the message is the second argument
+  { context: true, pattern: new RegExp(String.raw`(?<![\w$])(pgettext|npgettext)\(\s*('[^'\n]*'|"[^"\n]*")\s*,\s*${STRING}`, 'g') },
+];
+
+/**
+ * Message key lookups in a source or template file
+ * Keys starting with a dot are relative to the template (Rails lazy
+ * lookup) and are left out, as are empty ones
+ */
+export function scanMessageUsages(relativePath: string, content: string): MessageUsage[] {
+  const lineStarts = [0];
+  for (let i = 0; i < content.length; i++) {
+    if (content.charCodeAt(i) === 10) {
+      lineStarts.push(i + 1);
+    }
+  }
+  const usages: MessageUsage[] = [];
+  for (const rule of LOOKUP_RULES) {
+    rule.pattern.lastIndex = 0;
+    let match: RegExpExecArray | null;
+    while ((match = rule.pattern.exec(content)) !== null) {
+      const [single, double, template, end] = match.slice(match.length - 4);
+      const key = (single ?? double ?? template ?? '').replace(/\\(.)/g, '$1');
+      if (!key || key.startsWith('.')) {
+        continue;
+      }
+      // The key starts where the literal does: after the call and its opening quote
+      const offset = match.index + match[0].length - (end === '${' ? 2 : 1) - (template ?? double ?? single)!.length;
+      let line = lineStarts.length - 1;
+      while (lineStarts[line] > offset) {
+        line--;
+      }
+      usages.push({
+        key: rule.context ? `${match[2].slice(1, -1)}\x04${key}` : key,
+        dynamic: end === '${',
+        accessor: rule.accessor ?? match[1],
+        filePath: relativePath,
+        line: line + 1,
+        column: offset - lineStarts[line] + 1,
+      });
+    }
+  }
+  return usages.sort((a, b) => a.line - b.line || a.column - b.column);
+}
+
+/**
+ * Index the messages and lookups of a workspace
+ */
+export async function indexMessages(workspaceDir: string, options: MessageOptions = {}): Promise<MessageIndex> {
+  const definitions: MessageDefinition[] = [];
+  const usages: MessageUsage[] = [];
+  let resourceFiles = 0;
+  let sourceFiles = 0;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: options.path }))
+    .map((file) => ({ absolutePath: file.absolutePath, relativePath: file.relativePath.split(path.sep).join('/') }));
+  const groups = translatedGroups(files.map((file) => file.relativePath));
+  for (const { absolutePath, relativePath } of files) {
+    const isPo = relativePath.endsWith('.po');
+    const isResource = isPo || (structuredFormat(relativePath) !== undefined && resourceLocale(relativePath, groups) !== undefined);
+    const isCode = !isResource && (isSourceFile(relativePath) || TEMPLATE_EXTENSIONS.includes(path.extname(relativePath).toLowerCase()));
+    if (!isResource && !isCode) {
+      continue;
+    }
+    let content: string | Buffer;
+    try {
+      // Catalogs name their charset
+      content = isPo ? await readFileBytes(absolutePath) : await readFileText(absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', relativePath, err);
+      continue;
+    }
+    if (isResource) {
+      let found: MessageDefinition[];
+      try {
+        found = isPo ? parsePoFile(relativePath, content) : parseMessageResource(relativePath, content as string, groups);
+      } catch (err) {
+        toolsLogger.debug('Could not parse %s: %s', relativePath, (err as Error).message);
+        continue;
+      }
+      if (found.length > 0) {
+        resourceFiles++;
+        definitions.push(...found);
+      }
+      continue;
+    }
+    const found = scanMessageUsages(relativePath, content as string);
+    if (found.length > 0) {
+      sourceFiles++;
+      usages.push(...found);
+    }
+  }
+
+  // An i18next "ns:key" names a namespace only when the resources have one by that name
+  const namespaces = new Set(definitions.map((definition) => definition.namespace).filter(Boolean));
+  for (const usage of usages) {
+    const colon = usage.key.indexOf(':');
+    if (colon > 0 && namespaces.has(usage.key.substring(0, colon))) {
+      usage.namespace = usage.key.substring(0, colon);
+      usage.key = usage.key.substring(colon + 1);
+    }
+  }
+  toolsLogger.debug('Indexed %d message(s) in %d resource file(s), %d lookup(s) in %d file(s)',
+    definitions.length, resourceFiles, usages.length, sourceFiles);
+  return { definitions, usages, resourceFiles, sourceFiles };
+}
+
+/**
+ * A definition's key with its namespace, e.g. auth:login.title, so the same key in two namespaces is two messages
+ */
+function qualifiedKey(definition: MessageDefinition): string {
+  return definition.namespace ? `${definition.namespace}:${definition.key}` : definition.key;
+}
+
+/**
+ * Whether a lookup reads a message: the same key, a plural form of it, or a key a run-time prefix reaches
+ */
+export function usageReaches(usage: MessageUsage, definition: MessageDefinition): boolean {
+  if (usage.namespace && definition.namespace && usage.namespace !== definition.namespace) {
+    return false;
+  }
+  if (usage.dynamic) {
+    return definition.key.startsWith(usage.key);
+  }
+  return definition.key === usage.key || definition.key.replace(PLURAL_SUFFIX, '') === usage.key;
+}
+
+/**
+ * The keys each lookup reaches, and the lookups reaching none
+ * Literal keys are looked up directly; only keys built at run time are
+ * compared with every key
+ */
+function resolveUsages(index: MessageIndex): { used: Set<string>; unresolved: MessageUsage[] } {
+  const byKey = new Map<string, MessageDefinition[]>();
+  for (const definition of index.definitions) {
+    for (const key of new Set([definition.key, definition.key.replace(PLURAL_SUFFIX, '')])) {
+      byKey.set(key, [...(byKey.get(key) ?? []), definition]);
+    }
+  }
+  const used = new Set<string>();
+  const unresolved: MessageUsage[] = [];
+  for (const usage of index.usages) {
+    const candidates = usage.dynamic ? index.definitions : byKey.get(usage.key) ?? [];
+    const reached = candidates.filter((definition) => usageReaches(usage, definition));
+    if (reached.length === 0) {
+      unresolved.push(usage);
+    }
+    reached.forEach((definition) => used.add(qualifiedKey(definition)));
+  }
+  return { used, unresolved };
+}
+
+function quote(value: string, maxLength = 80): string {
+  const text = JSON.stringify(value);
+  return text.length > maxLength ? text.substring(0, maxLength - 2) + '…"' : text;
+}
+
+/**
+ * Definitions and usages of each key, for the sections of a key or text query
+ * Keys are qualified by their namespace; one no message has is matched with the lookups of it
+ */
+function describeKeys(index: MessageIndex, keys: string[], options: MessageOptions, limit: number): string[] {
+  const locales = Array.from(new Set(index.definitions.map((definition) => definition.locale))).sort();
+  const sections: string[] = [];
+  for (const key of keys.slice(0, limit)) {
+    const defined = index.definitions.filter((definition) => qualifiedKey(definition) === key);
+    const shown = options.locale ? defined.filter((definition) => definition.locale === options.locale) : defined;
+    const missing = locales.filter((locale) => !defined.some((definition) => definition.locale === locale));
+    const lines = [key];
+    if (defined.length === 0) {
+      lines.push('  Not defined in any resource file');
+    } else {
+      lines.push(`  Defined in ${new Set(defined.map((definition) => definition.locale)).size} locale(s)` +
+        `${missing.length > 0 ? `, missing in ${missing.join(', ')}` : ''}:`);
+      for (const definition of shown) {
+        lines.push(`    ${definition.locale}  ${quote(definition.value)}  ${definition.filePath}:${definition.line}`);
+      }
+    }
+    const used = index.usages.filter((usage) => defined.length > 0
+      ? defined.some((definition) => usageReaches(usage, definition))
+      : (usage.namespace ? `${usage.namespace}:${usage.key}` : usage.key) === key || usage.key === key);
+    if (used.length === 0) {
+      lines.push('  Not used in code');
+    } else {
+      lines.push(`  Used ${used.length} time(s):`);
+      for (const usage of used) {
+        const call = `${usage.accessor}(${usage.dynamic ? `\`${usage.key}\${…}\`` : quote(usage.namespace ? `${usage.namespace}:${usage.key}` : usage.key)})`;
+        lines.push(`    ${usage.filePath}:${usage.line}:${usage.column}  ${call}`);
+      }
+    }
+    sections.push(lines.join('\n'));
+  }
+  if (keys.length > limit) {
+    sections.push(`… ${keys.length - limit} more key(s)`);
+  }
+  return sections;
+}
+
+/**
+ * Look up a message key, the keys whose text contains some text, or, with
+ * neither, summarize the workspace's messages: keys per locale, missing
+ * translations, keys used but never defined, and keys defined but never used
+ */
+export async function findMessages(
+  workspaceDir: string,
+  query: { key?: string; text?: string },
+  options: MessageOptions = {},
+  limit = 20
+): Promise<string> {
+  const index = await indexMessages(workspaceDir, options);
+  if (index.definitions.length === 0 && index.usages.length === 0) {
+    return 'No i18n resource files (JSON or YAML under a locale directory or named by locale, .po catalogs) or message lookups found';
+  }
+
+  if (query.key) {
+    // An i18next "ns:key" names a namespace only when the resources have one by that name
+    const namespaces = new Set(index.definitions.map((definition) => definition.namespace).filter(Boolean));
+    const colon = query.key.indexOf(':');
+    const namespace = colon > 0 && namespaces.has(query.key.substring(0, colon)) ? query.key.substring(0, colon) : undefined;
+    const key = namespace ? query.key.substring(colon + 1) : query.key;
+    // The key in each namespace defining it
+    const keys = Array.from(new Set(index.definitions
+      .filter((definition) => definition.key === key && (!namespace || definition.namespace === namespace))
+      .map(qualifiedKey))).sort();
+    if (keys.length === 0 && index.usages.some((usage) => usage.key === key && (!namespace || usage.namespace === namespace))) {
+      keys.push(query.key);
+    }
+    if (keys.length === 0) {
+      const needle = query.key.toLowerCase();
+      const similar = Array.from(new Set(index.definitions.map(qualifiedKey)))
+        .filter((known) => known.toLowerCase().includes(needle)).sort().slice(0, 10);
+      return `No message key ${query.key} is defined or used` + (similar.length > 0 ? `; keys containing it: ${similar.join(', ')}` : '');
+    }
+    return describeKeys(index, keys, options, limit).join('\n\n');
+  }
+
+  if (query.text) {
+    const needle = query.text.toLowerCase();
+    const keys = Array.from(new Set(index.definitions
+      .filter((definition) => (!options.locale || definition.locale === options.locale) &&
+        (definition.value.toLowerCase().includes(needle) || definition.key.toLowerCase().includes(needle)))
+      .map(qualifiedKey))).sort();
+    if (keys.length === 0) {
+      return `No message text or key contains "${query.text}" (${index.definitions.length} message(s) in ${index.resourceFiles} resource file(s))`;
+    }
+    return [`${keys.length} message key(s) match "${query.text}"`, ...describeKeys(index, keys, options, limit)].join('\n\n');
+  }
+
+  const byLocale = new Map<string, Set<string>>();
+  for (const definition of index.definitions) {
+    byLocale.set(definition.locale, (byLocale.get(definition.locale) ?? new Set<string>()).add(qualifiedKey(definition)));
+  }
+  const allKeys = new Set(index.definitions.map(qualifiedKey));
+  const locales = Array.from(byLocale.keys()).sort();
+  const sections = [`Messages: ${allKeys.size} key(s) in ${locales.length} locale(s)` +
+    `${locales.length > 0 ? ` (${locales.map((locale) => `${locale} ${byLocale.get(locale)!.size}`).join(', ')})` : ''}` +
+    ` from ${index.resourceFiles} resource file(s); ${index.usages.length} lookup(s) in ${index.sourceFiles} file(s)`];
+
+  const missing = locales
+    .map((locale) => [locale, Array.from(allKeys).filter((key) => !byLocale.get(locale)!.has(key)).sort()] as const)
+    .filter(([, keys]) => keys.length > 0);
+  if (missing.length > 0) {
+    sections.push(['Missing translations:', ...missing.map(([locale, keys]) =>
+      `  ${locale} (${keys.length}): ${keys.slice(0, limit).join(', ')}${keys.length > limit ? ', …' : ''}`)].join('\n'));
+  }
+
+  const { used, unresolved } = resolveUsages(index);
+  if (unresolved.length > 0 && index.definitions.length > 0) {
+    sections.push([`Used but not defined (${unresolved.length}):`, ...unresolved.slice(0, limit).map((usage) =>
+      `  ${usage.dynamic ? `${usage.key}…` : usage.key}  ${usage.filePath}:${usage.line}`)].join('\n'));
+  }
+
+  const unused = Array.from(allKeys).filter((key) => !used.has(key)).sort();
+  if (unused.length > 0 && index.usages.length > 0) {
+    sections.push(`Defined but not used (${unused.length}): ${unused.slice(0, limit).join(', ')}${unused.length > limit ? ', …' : ''}`);
+  }
+  return sections.join('\n\n');
+}
@@ -0,0 +1,163 @@
+/**
+ * Tests for the impact report and rename collision detection
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { LSPClient } from '../lsp/client';
+import { Range, WorkspaceEdit } from '../protocol/types';
+import { pathToUri } from '../protocol/uri';
+import { collectEditRanges, findRenameCollisions, formatCollisions, getImpactReport, groupByPackage } from './impact';
+
+const at = (line: number, character: number): Range => ({
+  start: { line, character },
+  end: { line, character: character + 5 },
+});
+
+describe('Rename collisions', () => {
+  let workspace: string;
+  let file: string;
+  // Python symbols come from the built-in parser when the server is not a Python one
+  const client = { command: 'gopls' } as unknown as LSPClient;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'impact-'));
+    file = path.join(workspace, 'store.py');
+    fs.writeFileSync(file, [
+      'def parse(text):',
+      '    return text.split()',
+      '',
+      'def load(path):',
+      '    with open(path) as f:',
+      '        return parse(f.read())',
+      '',
+      'def save(items, path):',
+      '    return parse(path)',
+      '',
+    ].join('\n'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  // The rename of parse touches its declaration and both calls
+  const affected = (): Map<string, Range[]> => new Map([[file, [at(0, 4), at(5, 15), at(8, 11)]]]);
+
+  it('should report a declaration of the new name in the same scope', async () => {
+    const collisions = await findRenameCollisions(client, workspace, file, { line: 0, character: 4 }, 'load', affected());
+    expect(formatCollisions(collisions)).toBe('  store.py:L4 Function load is already declared in the same scope\n');
+  });
+
+  it('should report functions already using the new name where the symbol is referenced', async () => {
+    const collisions = await findRenameCollisions(client, workspace, file, { line: 0, character: 4 }, 'items', affected());
+    expect(collisions).toEqual([
+      { relativePath: 'store.py', line: 8, description: "Function save already uses 'items' where it references the symbol" },
+    ]);
+  });
+
+  it('should not count the new name in comments or strings', async () => {
+    fs.writeFileSync(file, fs.readFileSync(file, 'utf8').replace('def save(items, path):', 'def save(rows, path):  # items to save')
+      .replace('return parse(path)', 'return parse(path or "items.txt")'));
+    expect(await findRenameCollisions(client, workspace, file, { line: 0, character: 4 }, 'items', affected())).toEqual([]);
+  });
+
+  it('should report nothing for a free name', async () => {
+    expect(await findRenameCollisions(client, workspace, file, { line: 0, character: 4 }, 'tokenize', affected())).toEqual([]);
+  });
+});
+
+describe('Impact report', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'impact-')));
+    fs.mkdirSync(path.join(workspace, 'app'));
+    fs.mkdirSync(path.join(workspace, 'tests'));
+    fs.writeFileSync(path.join(workspace, 'app', 'store.py'), 'def parse(text):\n    return text.split()\n');
+    fs.writeFileSync(path.join(workspace, 'app', 'cli.py'), 'def main():\n    parse(input())\n');
+    fs.writeFileSync(path.join(workspace, 'tests', 'test_store.py'), 'def test_parse():\n    assert parse("a b")\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should collect the ranges of text edits, leaving out file operations', () => {
+    const edit: WorkspaceEdit = {
+      changes: { [pathToUri('/w/a.py')]: [{ range: at(0, 4), newText: 'x' }] },
+      documentChanges: [
+        { textDocument: { uri: pathToUri('/w/a.py'), version: 1 }, edits: [{ range: at(3, 0), newText: 'x' }] },
+        { kind: 'rename', oldUri: pathToUri('/w/b.py'), newUri: pathToUri('/w/c.py') },
+      ],
+    };
+    expect(collectEditRanges(edit)).toEqual(new Map([['/w/a.py', [at(0, 4), at(3, 0)]]]));
+    expect(collectEditRanges(null).size).toBe(0);
+  });
+
+  it('should group files by package', () => {
+    const file = (relativePath: string) => ({ filePath: relativePath, relativePath, packageName: path.dirname(relativePath), isTest: false, ranges: [] });
+    const packages = groupByPackage([file('app/a.py'), file('tests/t.py'), file('app/b.py')]);
+    expect([...packages].map(([name, files]) => [name, files.map((f) => f.relativePath)])).toEqual([
+      ['app', ['app/a.py', 'app/b.py']],
+      ['tests', ['tests/t.py']],
+    ]);
+  });
+
+  it('should report the locations a rename touches by package and file, with their symbols', async () => {
+    const calls: string[] = [];
+    const edit: WorkspaceEdit = {
+      changes: {
+        [pathToUri(path.join(workspace, 'app', 'store.py'))]: [{ range: at(0, 4), newText: 'tokenize' }],
+        [pathToUri(path.join(workspace, 'app', 'cli.py'))]: [{ range: at(1, 4), newText: 'tokenize' }],
+        [pathToUri(path.join(workspace, 'tests', 'test_store.py'))]: [{ range: at(1, 11), newText: 'tokenize' }],
+      },
+    };
+    const client = {
+      command: 'gopls',
+      openFile: async () => undefined,
+      call: async (method: string) => {
+        calls.push(method);
+        return edit;
+      },
+      toServerPosition: (_uri: string, position: unknown) => position,
+      fromServerRange: (_uri: string, range: Range) => range,
+    } as unknown as LSPClient;
+
+    const report = await getImpactReport(client, workspace, path.join(workspace, 'app', 'store.py'), 1, 5, { newName: 'tokenize' });
+    expect(calls).toEqual(['textDocument/rename']);
+    expect(report).toContain("Impact of rename of 'parse' to 'tokenize'\n");
+    expect(report).toContain('Locations: 3 in 3 file(s) across 2 package(s)\n');
+    expect(report).toContain('Test files: 1 (1 location(s))\n');
+    expect(report).toContain('Collisions: none\n');
+    expect(report).toContain('Packages:\n  app: 2 location(s) in 2 file(s)\n  tests: 1 location(s) in 1 file(s)\n');
+    expect(report).toContain('app/cli.py\nLocations: 1\nAt: L2:C5 (main)\n');
+    expect(report).toContain('tests/test_store.py [test]\nLocations: 1\nAt: L2:C12 (test_parse)\n');
+  });
+
+  it('should fall back to references for a signature change, adding the declaration', async () => {
+    const calls: string[] = [];
+    const client = {
+      command: 'gopls',
+      openFile: async () => undefined,
+      call: async (method: string) => {
+        calls.push(method);
+        return [{ uri: pathToUri(path.join(workspace, 'app', 'cli.py')), range: at(1, 4) }];
+      },
+      toServerPosition: (_uri: string, position: unknown) => position,
+      fromServerRange: (_uri: string, range: Range) => range,
+      getCacheManager: () => ({ getReferencesEntry: () => null, setReferences: () => undefined }),
+    } as unknown as LSPClient;
+
+    const report = await getImpactReport(client, workspace, path.join(workspace, 'app', 'store.py'), 1, 5, {
+      changeKind: 'signature',
+      includeSymbols: false,
+    });
+    expect(calls).toEqual(['textDocument/references']);
+    expect(report).toContain("Impact of signature change of 'parse'\n");
+    expect(report).toContain('Locations: 2 in 2 file(s) across 1 package(s)\n');
+    expect(report).not.toContain('Collisions:');
+    expect(report).toContain('app/store.py\nLocations: 1\nAt: L1:C5\n');
+  });
+});
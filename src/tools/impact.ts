@@ -0,0 +1,416 @@
+/**
+ * Impact report tool - summarize the blast radius of a rename or signature change
+ * without applying any edits, and the declarations a new name would collide with
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { rename as lspRename, references as lspReferences } from '../lsp/methods.js';
+import { createLogger, Component } from '../logging/logger.js';
+import {
+  RenameParams,
+  ReferenceParams,
+  ReferenceContext,
+  TextDocumentIdentifier,
+  Position,
+  Range,
+  SymbolKind,
+  SymbolKindNames,
+  WorkspaceEdit,
+} from '../protocol/types.js';
+import { pathToUri, uriToPath } from '../protocol/uri.js';
+import { detectLanguageId, isTestFile } from '../workspace/language.js';
+import { FlatSymbol, getFileSymbols, findEnclosingSymbol } from './symbols.js';
+import { readFileText } from '../workspace/overlay.js';
+import { escapeRegExp } from '../search/lexical.js';
+import { inComment, nonCodeSpans } from '../search/comments.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Kind of change being assessed
+ */
+export type ChangeKind = 'rename' | 'signature';
+
+/**
+ * Options for the impact report
+ */
+export interface ImpactOptions {
+  changeKind?: ChangeKind;
+  newName?: string;
+  includeSymbols?: boolean;
+}
+
+/**
+ * Affected locations in one file
+ */
+export interface FileImpact {
+  filePath: string;
+  relativePath: string;
+  packageName: string;
+  isTest: boolean;
+  ranges: Range[];
+}
+
+/**
+ * A declaration or use of the new name that a rename would clash with
+ */
+export interface RenameCollision {
+  relativePath: string;
+  line: number; // 1-indexed
+  description: string;
+}
+
+/**
+ * Languages whose top-level declarations share one namespace across the files of a directory
+ */
+const PACKAGE_SCOPED_LANGUAGES = new Set(['go', 'java', 'kotlin', 'scala']);
+
+const FUNCTION_KINDS = new Set([SymbolKind.Function, SymbolKind.Method, SymbolKind.Constructor]);
+
+/**
+ * Collect edit ranges per file from a workspace edit
+ */
+export function collectEditRanges(edit: WorkspaceEdit | null): Map<string, Range[]> {
+  const result = new Map<string, Range[]>();
+  if (!edit) {
+    return result;
+  }
+
+  const add = (uri: string, ranges: Range[]): void => {
+    const filePath = uriToPath(uri);
+    if (!result.has(filePath)) {
+      result.set(filePath, []);
+    }
+    result.get(filePath)!.push(...ranges);
+  };
+
+  for (const [uri, edits] of Object.entries(edit.changes || {})) {
+    add(uri, edits.map((e) => e.range));
+  }
+  for (const change of edit.documentChanges || []) {
+    // Only TextDocumentEdit entries carry ranges; create/rename/delete file operations are skipped
+    if ('textDocument' in change && 'edits' in change) {
+      add(change.textDocument.uri, change.edits.map((e) => e.range));
+    }
+  }
+
+  return result;
+}
+
+/**
+ * Group affected files by package (directory relative to the workspace)
+ */
+export function groupByPackage(files: FileImpact[]): Map<string, FileImpact[]> {
+  const packages = new Map<string, FileImpact[]>();
+  for (const file of files) {
+    if (!packages.has(file.packageName)) {
+      packages.set(file.packageName, []);
+    }
+    packages.get(file.packageName)!.push(file);
+  }
+  return packages;
+}
+
+/**
+ * Read the identifier at a 0-indexed position
+ */
+function identifierAt(lines: string[], position: Position): string {
+  const line = lines[position.line] || '';
+  let start = position.character;
+  let end = position.character;
+  while (start > 0 && /[\w$]/.test(line[start - 1])) start--;
+  while (end < line.length && /[\w$]/.test(line[end])) end++;
+  return line.substring(start, end);
+}
+
+/**
+ * Compare ranges by start position
+ */
+function compareRanges(a: Range, b: Range): number {
+  return a.start.line - b.start.line || a.start.character - b.start.character;
+}
+
+/**
+ * Find the locations affected by a rename, falling back to references
+ * when the server cannot compute a rename edit
+ */
+async function findAffectedRanges(
+  client: LSPClient,
+  filePath: string,
+  position: Position,
+  changeKind: ChangeKind,
+  newName: string | undefined
+): Promise<Map<string, Range[]>> {
+  const uri = pathToUri(filePath);
+
+  if (changeKind === 'rename' && newName) {
+    try {
+      const edit = await lspRename(client, {
+        textDocument: { uri } as TextDocumentIdentifier,
+        position,
+        newName,
+      } as RenameParams);
+      const ranges = collectEditRanges(edit);
+      if (ranges.size > 0) {
+        return ranges;
+      }
+    } catch (err) {
+      toolsLogger.debug('Rename dry run failed, falling back to references: %s', err);
+    }
+  }
+
+  const refs = await lspReferences(client, {
+    textDocument: { uri } as TextDocumentIdentifier,
+    position,
+    context: { includeDeclaration: false } as ReferenceContext,
+  } as ReferenceParams);
+
+  const result = new Map<string, Range[]>();
+  for (const ref of refs) {
+    const refPath = uriToPath(ref.uri);
+    if (!result.has(refPath)) {
+      result.set(refPath, []);
+    }
+    result.get(refPath)!.push(ref.range);
+  }
+
+  // References exclude the declaration; add it so the report covers the definition site too
+  const declRanges = result.get(filePath) || [];
+  if (!declRanges.some((r) => r.start.line === position.line)) {
+    declRanges.push({ start: position, end: position });
+    result.set(filePath, declRanges);
+  }
+
+  return result;
+}
+
+/**
+ * Check if a 0-indexed position is inside a range
+ */
+function containsPosition(range: Range, position: Position): boolean {
+  const afterStart = position.line > range.start.line ||
+    (position.line === range.start.line && position.character >= range.start.character);
+  const beforeEnd = position.line < range.end.line ||
+    (position.line === range.end.line && position.character <= range.end.character);
+  return afterStart && beforeEnd;
+}
+
+/**
+ * Find what renaming the symbol at a position to newName would collide with:
+ * - declarations of newName in the same scope (the symbol's container)
+ * - for top-level symbols of package-scoped languages, top-level declarations
+ *   of newName in the other files of the directory
+ * - functions referencing the symbol that already use newName, where one
+ *   would shadow the other
+ */
+export async function findRenameCollisions(
+  client: LSPClient,
+  workspaceDir: string,
+  filePath: string,
+  position: Position,
+  newName: string,
+  affected: Map<string, Range[]>
+): Promise<RenameCollision[]> {
+  const relative = (p: string): string => path.relative(workspaceDir, p) || path.basename(p);
+  const symbolsOf = async (p: string): Promise<FlatSymbol[]> => {
+    try {
+      return await getFileSymbols(client, workspaceDir, p);
+    } catch (err) {
+      toolsLogger.debug('Could not get symbols for %s: %s', p, err);
+      return [];
+    }
+  };
+  const collisions: RenameCollision[] = [];
+  const describe = (sym: FlatSymbol): string => `${SymbolKindNames[sym.kind] || 'Symbol'} ${sym.qualifiedName}`;
+
+  const declared = await symbolsOf(filePath);
+  const target = declared.find((sym) => containsPosition(sym.selectionRange, position));
+  for (const sym of declared) {
+    if (sym !== target && sym.name === newName && sym.containerName === target?.containerName) {
+      collisions.push({
+        relativePath: relative(filePath),
+        line: sym.selectionRange.start.line + 1,
+        description: `${describe(sym)} is already declared in the same scope`,
+      });
+    }
+  }
+
+  const languageId = detectLanguageId(filePath);
+  if ((!target || target.depth === 0) && PACKAGE_SCOPED_LANGUAGES.has(languageId)) {
+    const dir = path.dirname(filePath);
+    const packageName = path.relative(workspaceDir, dir) || '.';
+    let siblings: string[] = [];
+    try {
+      siblings = (await fs.promises.readdir(dir))
+        .map((name) => path.join(dir, name))
+        .filter((p) => p !== filePath && detectLanguageId(p) === languageId);
+    } catch (err) {
+      toolsLogger.debug('Could not list %s: %s', dir, err);
+    }
+    for (const sibling of siblings) {
+      for (const sym of await symbolsOf(sibling)) {
+        if (sym.depth === 0 && sym.name === newName) {
+          collisions.push({
+            relativePath: relative(sibling),
+            line: sym.selectionRange.start.line + 1,
+            description: `${describe(sym)} is already declared in package ${packageName}`,
+          });
+        }
+      }
+    }
+  }
+
+  const word = new RegExp(`(?<![\\w$])${escapeRegExp(newName)}(?![\\w$])`, 'g');
+  for (const [affectedPath, ranges] of affected.entries()) {
+    const functions = (affectedPath === filePath ? declared : await symbolsOf(affectedPath))
+      .filter((sym) => FUNCTION_KINDS.has(sym.kind));
+    let content: string;
+    try {
+      content = await readFileText(affectedPath);
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', affectedPath, err);
+      continue;
+    }
+    const lines = content.split('\n');
+    // Only identifiers count; the name in a comment or a string shadows nothing
+    const literals = nonCodeSpans(content, detectLanguageId(affectedPath));
+    const usesName = (line: number) =>
+      Array.from(lines[line].matchAll(word)).some((match) => !inComment(literals, line + 1, match.index! + 1));
+    const checked = new Set<FlatSymbol>();
+    for (const range of ranges) {
+      const enclosing = findEnclosingSymbol(functions, range.start.line);
+      if (!enclosing || enclosing === target || checked.has(enclosing)) {
+        continue;
+      }
+      checked.add(enclosing);
+      let line = enclosing.range.start.line;
+      const last = Math.min(enclosing.range.end.line, lines.length - 1);
+      while (line <= last && !usesName(line)) {
+        line++;
+      }
+      if (line <= last) {
+        collisions.push({
+          relativePath: relative(affectedPath),
+          line: line + 1,
+          description: `${describe(enclosing)} already uses '${newName}' where it references the symbol`,
+        });
+      }
+    }
+  }
+
+  // A declaration in scope is also a use in the function declaring it
+  const seen = new Set<string>();
+  return collisions
+    .sort((a, b) => a.relativePath.localeCompare(b.relativePath) || a.line - b.line)
+    .filter((collision) => {
+      const key = `${collision.relativePath}:${collision.line}`;
+      if (seen.has(key)) {
+        return false;
+      }
+      seen.add(key);
+      return true;
+    });
+}
+
+/**
+ * Format rename collisions, one per line
+ */
+export function formatCollisions(collisions: RenameCollision[]): string {
+  return collisions.map((c) => `  ${c.relativePath}:L${c.line} ${c.description}\n`).join('');
+}
+
+/**
+ * Build an impact report for renaming or changing the signature of the symbol at a position
+ */
+export async function getImpactReport(
+  client: LSPClient,
+  workspaceDir: string,
+  filePath: string,
+  line: number,
+  column: number,
+  options: ImpactOptions = {}
+): Promise<string> {
+  const changeKind = options.changeKind ?? 'rename';
+  const includeSymbols = options.includeSymbols ?? true;
+
+  try {
+    await client.openFile(filePath);
+  } catch (err) {
+    throw new ToolError('not-found', `Error opening file: ${err}`);
+  }
+
+  const position: Position = { line: line - 1, character: column - 1 };
+  const lines = (await readFileText(filePath)).split('\n');
+  const identifier = identifierAt(lines, position);
+  const symbolName = identifier ? `'${identifier}'` : `symbol at L${line}:C${column}`;
+
+  toolsLogger.debug('Computing %s impact for %s at %s:%d:%d', changeKind, symbolName, filePath, line, column);
+  const affected = await findAffectedRanges(client, filePath, position, changeKind, options.newName);
+
+  const files: FileImpact[] = [];
+  for (const [affectedPath, ranges] of affected.entries()) {
+    const relativePath = path.relative(workspaceDir, affectedPath) || path.basename(affectedPath);
+    files.push({
+      filePath: affectedPath,
+      relativePath,
+      packageName: path.dirname(relativePath),
+      isTest: isTestFile(relativePath),
+      ranges: [...ranges].sort(compareRanges),
+    });
+  }
+  files.sort((a, b) => a.relativePath.localeCompare(b.relativePath));
+
+  const action = changeKind === 'rename'
+    ? `rename of ${symbolName}${options.newName ? ` to '${options.newName}'` : ''}`
+    : `signature change of ${symbolName}`;
+
+  if (files.length === 0) {
+    return `No locations affected by ${action}`;
+  }
+
+  const totalLocations = files.reduce((sum, f) => sum + f.ranges.length, 0);
+  const testFiles = files.filter((f) => f.isTest);
+  const packages = groupByPackage(files);
+
+  let output = `Impact of ${action}\n`;
+  output += `Locations: ${totalLocations} in ${files.length} file(s) across ${packages.size} package(s)\n`;
+  output += `Test files: ${testFiles.length} (${testFiles.reduce((sum, f) => sum + f.ranges.length, 0)} location(s))\n`;
+  if (changeKind === 'rename' && options.newName) {
+    const collisions = await findRenameCollisions(client, workspaceDir, filePath, position, options.newName, affected);
+    output += collisions.length > 0
+      ? `Collisions: ${collisions.length}, '${options.newName}' is already in use\n${formatCollisions(collisions)}`
+      : 'Collisions: none\n';
+  }
+  output += '\n';
+
+  output += 'Packages:\n';
+  for (const [pkg, pkgFiles] of [...packages.entries()].sort(([a], [b]) => a.localeCompare(b))) {
+    const count = pkgFiles.reduce((sum, f) => sum + f.ranges.length, 0);
+    output += `  ${pkg}: ${count} location(s) in ${pkgFiles.length} file(s)\n`;
+  }
+  output += '\n';
+
+  for (const file of files) {
+    output += `---\n\n${file.relativePath}${file.isTest ? ' [test]' : ''}\n`;
+    output += `Locations: ${file.ranges.length}\n`;
+
+    let atStrings = file.ranges.map((r) => `L${r.start.line + 1}:C${r.start.character + 1}`);
+    if (includeSymbols) {
+      try {
+        const symbols = await getFileSymbols(client, workspaceDir, file.filePath);
+        atStrings = file.ranges.map((r, i) => {
+          const enclosing = findEnclosingSymbol(symbols, r.start.line);
+          return enclosing ? `${atStrings[i]} (${enclosing.qualifiedName})` : atStrings[i];
+        });
+      } catch (err) {
+        toolsLogger.debug('Could not get symbols for %s: %s', file.filePath, err);
+      }
+    }
+    output += `At: ${atStrings.join(', ')}\n\n`;
+  }
+
+  return output;
+}
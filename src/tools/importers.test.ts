@@ -0,0 +1,88 @@
+/**
+ * Tests for the importers tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { findImporters, formatImporters } from './importers';
+
+describe('importers', () => {
+  let workspace: string;
+
+  const write = (relativePath: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+    fs.writeFileSync(path.join(workspace, relativePath), content);
+  };
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'importers-')));
+    write('go.mod', 'module example.com/app\n');
+    write('internal/store/store.go', 'package store\n');
+    write('internal/store/cache.go', 'package store\n');
+    write('api/handler.go', 'package api\n\nimport st "example.com/app/internal/store"\n');
+    write('api/handler_test.go', 'package api\n\nimport "example.com/app/internal/store"\n');
+    write('cmd/main.go', 'package main\n\nimport "example.com/app/api"\n');
+    write('web/util.ts', 'export const x = 1;\n');
+    write('web/app.ts', "import { x } from './util.js';\n");
+    write('web/index.ts', "import './app';\n");
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should list direct importers of a Go package by any path to it', async () => {
+    const report = await findImporters(workspace, 'example.com/app/internal/store');
+    expect(report.target).toBe('internal/store');
+    expect(report.importers).toEqual([
+      { relativePath: 'api/handler.go', depth: 1, via: 'internal/store', isTest: false },
+      { relativePath: 'api/handler_test.go', depth: 1, via: 'internal/store', isTest: true },
+    ]);
+    expect(formatImporters(report)).toBe([
+      'Found 2 importer(s) of internal/store (2 files) in 1 directory, 1 of them tests, up to depth 1',
+      '',
+      'Depth 1 (direct):',
+      '  api/handler.go  (via internal/store)',
+      '  api/handler_test.go [test]  (via internal/store)',
+      '',
+      'By directory: api (2)',
+    ].join('\n'));
+  });
+
+  it('should follow importers to a depth without following tests', async () => {
+    const report = await findImporters(workspace, 'internal/store/cache.go', { depth: 3, includeTests: false });
+    expect(report.importers.map((importer) => `${importer.depth} ${importer.relativePath} ${importer.via}`)).toEqual([
+      '1 api/handler.go internal/store',
+      '2 cmd/main.go api',
+    ]);
+    const files = await findImporters(workspace, 'web/util.ts', { depth: 2 });
+    expect(formatImporters(files)).toBe([
+      'Found 2 importer(s) of web/util.ts in 1 directory, up to depth 2',
+      '',
+      'Depth 1 (direct):',
+      '  web/app.ts',
+      '',
+      'Depth 2:',
+      '  web/index.ts  (via web/app.ts)',
+      '',
+      'By directory: web (2)',
+    ].join('\n'));
+  });
+
+  it('should say when nothing imports the target', async () => {
+    expect(formatImporters(await findImporters(workspace, path.join(workspace, 'cmd'))))
+      .toMatch(/^Nothing in the workspace imports cmd \(\d+ file\(s\) scanned\); dynamic imports/);
+    const missing = await findImporters(workspace, 'billing').catch((err) => err);
+    expect(missing.code).toBe('not-found');
+  });
+
+  it('should take workspace paths and import paths under a go.mod module, not path suffixes', async () => {
+    expect((await findImporters(workspace, 'example.com/app')).target).toBe('.');
+    const suffix = await findImporters(workspace, 'store').catch((err) => err);
+    expect(suffix.code).toBe('not-found');
+    expect(suffix.message).toBe('No file or directory of the workspace is store; paths ending with it: internal/store');
+    expect((await findImporters(workspace, 'other.org/app/internal/store').catch((err) => err)).message)
+      .toBe('No file or directory of the workspace is other.org/app/internal/store');
+  });
+});
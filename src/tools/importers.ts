@@ -0,0 +1,215 @@
+/**
+ * Importers tool - the workspace files importing a file, directory, or package
+ * Reverses the import graph warm-up ranks files by, and follows it to files
+ * importing the importers, up to a depth, so "can I delete this package"
+ * is answered by an empty list. A Go package is one node: importing any of
+ * its files imports all of them
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { ToolError } from '../workspace/errors.js';
+import { isSourceFile, isTestFile } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { isUnder } from '../workspace/paths.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { importTargets } from './warmup.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Deepest a search for importers goes
+ */
+const MAX_IMPORTER_DEPTH = 10;
+
+/**
+ * Options for the importers search
+ */
+export interface ImportersOptions {
+  // Levels of importers followed: 1 for direct importers only (default: 1)
+  depth?: number;
+  // List test files among the importers (default: true)
+  includeTests?: boolean;
+}
+
+/**
+ * A file importing the target, directly or through other files
+ */
+export interface Importer {
+  relativePath: string;
+  depth: number; // 1 for a direct importer
+  // The file or Go package at the previous depth this one imports, or the target's at depth 1
+  via: string;
+  isTest: boolean;
+}
+
+/**
+ * Importers of a target, nearest first
+ */
+export interface ImportersReport {
+  target: string; // Workspace-relative file or directory
+  targetFiles: number;
+  depth: number;
+  importers: Importer[];
+  filesScanned: number;
+}
+
+/**
+ * Most candidates listed when a target is not a workspace path
+ */
+const MAX_TARGET_CANDIDATES = 10;
+
+/**
+ * The workspace file or directory a target names
+ * A target is a workspace-relative path, or a Go import path under the
+ * module of a go.mod in the workspace, such as example.com/app/internal/store.
+ * Anything else is not found; files and directories ending with the target
+ * are listed for the caller to pick from, never picked for it
+ */
+function resolveTarget(target: string, paths: string[], modules: Map<string, string>): string {
+  const wanted = target.replace(/\\/g, '/').replace(/^\.\/|\/+$/g, '');
+  const exists = (candidate: string) => paths.some((p) => p === candidate || p.startsWith(candidate + '/'));
+  if (wanted === '' || wanted === '.') {
+    return '.';
+  }
+  if (exists(wanted)) {
+    return wanted;
+  }
+  // The longest module path the target is under
+  for (const [modulePath, dir] of Array.from(modules).sort(([a], [b]) => b.length - a.length)) {
+    if (wanted === modulePath || wanted.startsWith(modulePath + '/')) {
+      const candidate = path.posix.join(dir, wanted.substring(modulePath.length + 1));
+      if (candidate === '.' || exists(candidate)) {
+        return candidate;
+      }
+    }
+  }
+  const candidates = new Set<string>();
+  for (const p of paths) {
+    const at = `/${p}/`.indexOf(`/${wanted}/`);
+    if (at >= 0) {
+      candidates.add(p.substring(0, at + wanted.length));
+    }
+  }
+  const listed = Array.from(candidates).sort().slice(0, MAX_TARGET_CANDIDATES);
+  throw new ToolError('not-found', `No file or directory of the workspace is ${target}` +
+    (listed.length > 0 ? `; paths ending with it: ${listed.join(', ')}${candidates.size > listed.length ? ', ...' : ''}` : ''));
+}
+
+/**
+ * Node of the graph a file belongs to: its package directory for Go, the file otherwise
+ */
+function nodeOf(relativePath: string): string {
+  return relativePath.endsWith('.go') ? path.posix.dirname(relativePath) + '/' : relativePath;
+}
+
+/**
+ * Find the files importing a target, following importers up to a depth
+ * Imports resolve as importTargets resolves them; dynamic imports and
+ * imports from outside the workspace are not seen
+ */
+export async function findImporters(workspaceDir: string, target: string, options: ImportersOptions = {}): Promise<ImportersReport> {
+  const depth = Math.min(Math.max(Math.floor(options.depth ?? 1), 1), MAX_IMPORTER_DEPTH);
+  const files: Array<{ relativePath: string; content: string }> = [];
+  // Go module paths by the directory of their go.mod
+  const modules = new Map<string, string>();
+  for (const file of await walkWorkspaceFiles(workspaceDir)) {
+    if (path.basename(file.relativePath) === 'go.mod') {
+      const modulePath = (await readFileText(file.absolutePath).catch(() => '')).match(/^module\s+(\S+)/m)?.[1];
+      if (modulePath) {
+        modules.set(modulePath, path.posix.dirname(file.relativePath.split(path.sep).join('/')));
+      }
+    }
+    if (!isSourceFile(file.absolutePath)) {
+      continue;
+    }
+    try {
+      files.push({ relativePath: file.relativePath.split(path.sep).join('/'), content: await readFileText(file.absolutePath) });
+    } catch (err) {
+      toolsLogger.debug('Error reading file %s: %s', file.absolutePath, err);
+    }
+  }
+  const given = path.isAbsolute(target) && isUnder(target, workspaceDir) ? path.relative(workspaceDir, target) || '.' : target;
+  const relativeTarget = resolveTarget(given, files.map((file) => file.relativePath), modules);
+  const within = (relativePath: string) =>
+    relativeTarget === '.' || isUnder(path.join(workspaceDir, relativePath), path.join(workspaceDir, relativeTarget));
+  const targetFiles = files.filter((file) => within(file.relativePath));
+  if (targetFiles.length === 0) {
+    throw new ToolError('not-found', `${relativeTarget} holds no source files whose imports are read`);
+  }
+
+  // Importing files of each node
+  const importedBy = new Map<string, Map<string, string>>();
+  for (const [importer, targets] of importTargets(files)) {
+    for (const imported of targets) {
+      const node = nodeOf(imported);
+      const from = importedBy.get(node) ?? new Map<string, string>();
+      from.set(importer, imported);
+      importedBy.set(node, from);
+    }
+  }
+
+  const importers: Importer[] = [];
+  const seen = new Set(targetFiles.map((file) => file.relativePath));
+  let frontier = new Set(targetFiles.map((file) => nodeOf(file.relativePath)));
+  for (let level = 1; level <= depth && frontier.size > 0; level++) {
+    const next = new Set<string>();
+    for (const node of Array.from(frontier).sort()) {
+      for (const [importer, imported] of importedBy.get(node) ?? []) {
+        if (seen.has(importer)) {
+          continue;
+        }
+        seen.add(importer);
+        // Go imports name the package, not the file they resolved to
+        const via = imported.endsWith('.go') ? path.posix.dirname(imported) : imported;
+        importers.push({ relativePath: importer, depth: level, via, isTest: isTestFile(importer) });
+        // Test files are imported by nothing worth following
+        if (!isTestFile(importer)) {
+          next.add(nodeOf(importer));
+        }
+      }
+    }
+    frontier = next;
+  }
+
+  const listed = options.includeTests === false ? importers.filter((importer) => !importer.isTest) : importers;
+  listed.sort((a, b) => a.depth - b.depth || (a.relativePath < b.relativePath ? -1 : a.relativePath > b.relativePath ? 1 : 0));
+  toolsLogger.debug('Found %d importer(s) of %s within depth %d', listed.length, relativeTarget, depth);
+  return { target: relativeTarget, targetFiles: targetFiles.length, depth, importers: listed, filesScanned: files.length };
+}
+
+/**
+ * Format importers by depth, then the directories they are in
+ */
+export function formatImporters(report: ImportersReport, limit = 200): string {
+  const { target, importers } = report;
+  const what = `${target}${report.targetFiles > 1 ? ` (${report.targetFiles} files)` : ''}`;
+  if (importers.length === 0) {
+    return `Nothing in the workspace imports ${what} (${report.filesScanned} file(s) scanned); ` +
+      'dynamic imports, code generation, and importers outside the workspace are not seen';
+  }
+  const directories = new Map<string, number>();
+  for (const importer of importers) {
+    const dir = path.posix.dirname(importer.relativePath);
+    directories.set(dir, (directories.get(dir) ?? 0) + 1);
+  }
+  const tests = importers.filter((importer) => importer.isTest).length;
+  const lines = [`Found ${importers.length} importer(s) of ${what} in ${directories.size} director${directories.size === 1 ? 'y' : 'ies'}` +
+    `${tests > 0 ? `, ${tests} of them tests` : ''}, up to depth ${report.depth}`];
+  const shown = importers.slice(0, limit);
+  if (shown.length < importers.length) {
+    lines[0] += ` (showing first ${shown.length})`;
+  }
+  let level = 0;
+  for (const importer of shown) {
+    if (importer.depth !== level) {
+      level = importer.depth;
+      lines.push('', level === 1 ? 'Depth 1 (direct):' : `Depth ${level}:`);
+    }
+    const via = importer.depth === 1 && report.targetFiles === 1 ? '' : `  (via ${importer.via})`;
+    lines.push(`  ${importer.relativePath}${importer.isTest ? ' [test]' : ''}${via}`);
+  }
+  lines.push('', `By directory: ${Array.from(directories).sort((a, b) => b[1] - a[1] || a[0].localeCompare(b[0]))
+    .map(([dir, count]) => `${dir} (${count})`).join(', ')}`);
+  return lines.join('\n');
+}
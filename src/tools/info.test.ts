@@ -0,0 +1,70 @@
+/**
+ * Tests for the file info tool
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { describeStatusCode } from '../git/git';
+import { formatBytes, getFileInfo } from './info';
+
+describe('file_info', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'info-')));
+    fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nfunc main() {}\n');
+    fs.writeFileSync(path.join(workspace, 'api.pb.go'), '// Code generated by protoc-gen-go. DO NOT EDIT.\npackage api\n');
+    fs.writeFileSync(path.join(workspace, 'logo.png'), Buffer.from([0x89, 0x50, 0x4e, 0x47, 0]));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should describe a text file', async () => {
+    const output = await getFileInfo(workspace, path.join(workspace, 'main.go'));
+    expect(output).toMatch(/^main\.go\n\nSize: {7}29 B \(29 bytes\)\nLanguage: {3}go\nLines: {6}3\n/);
+    expect(output).toMatch(/Generated: +no/);
+    expect(output).toMatch(/Git status: +not in a repository/);
+  });
+
+  it('should flag generated and binary files', async () => {
+    expect(await getFileInfo(workspace, path.join(workspace, 'api.pb.go'))).toMatch(/Generated: +yes/);
+    const binary = await getFileInfo(workspace, path.join(workspace, 'logo.png'));
+    expect(binary).toMatch(/Binary: +yes/);
+    expect(binary).not.toContain('Lines:');
+  });
+
+  it('should estimate the lines of a file larger than it reads', async () => {
+    // 3 MB of 64-byte lines
+    fs.writeFileSync(path.join(workspace, 'big.txt'), ('x'.repeat(63) + '\n').repeat(3 * 16384));
+    const output = await getFileInfo(workspace, path.join(workspace, 'big.txt'));
+    expect(output).toContain('Size:       3.0 MB (3145728 bytes)');
+    expect(output).toContain('Lines:      about 49152 (estimated from the first 1.0 MB)');
+  });
+
+  it('should report git status', async () => {
+    try {
+      execFileSync('git', ['init', '-q'], { cwd: workspace });
+      execFileSync('git', ['add', 'main.go'], { cwd: workspace });
+      execFileSync('git', ['-c', 'user.name=t', '-c', 'user.email=t@t', 'commit', '-qm', 'init'], { cwd: workspace });
+    } catch (err) {
+      return; // git is not available
+    }
+    expect(await getFileInfo(workspace, path.join(workspace, 'main.go'))).toMatch(/Git status: +unmodified/);
+    fs.appendFileSync(path.join(workspace, 'main.go'), '// changed\n');
+    expect(await getFileInfo(workspace, path.join(workspace, 'main.go'))).toMatch(/Git status: +modified/);
+    expect(await getFileInfo(workspace, path.join(workspace, 'api.pb.go'))).toMatch(/Git status: +untracked/);
+  });
+
+  it('should describe status codes and sizes', () => {
+    expect(describeStatusCode(' M')).toBe('modified');
+    expect(describeStatusCode('A ')).toBe('added');
+    expect(describeStatusCode('UU')).toBe('conflicted');
+    expect(describeStatusCode('!!')).toBe('ignored');
+    expect(formatBytes(1536)).toBe('1.5 KB');
+    expect(formatBytes(50 * 1024 * 1024)).toBe('50 MB');
+  });
+});
@@ -0,0 +1,110 @@
+/**
+ * File info tool - size, language, line count, modification time, git status,
+ * and whether a file is generated or binary, to decide whether it is worth reading
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { fileGitStatus } from '../git/git.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { maxFileSizeFromEnv } from '../search/lexical.js';
+import { defaultWatcherConfig } from '../watcher/watcher.js';
+import { isBinaryFile } from '../workspace/binary.js';
+import { decodeText, detectEncoding } from '../workspace/encoding.js';
+import { GeneratedFileDetector } from '../workspace/generated.js';
+import { detectLanguageId, isTestFile } from '../workspace/language.js';
+import { readFileHead, sharedOverlay } from '../workspace/overlay.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Size limit search_code applies, as in the search options
+ */
+function maxSearchedFileSize(): number {
+  return maxFileSizeFromEnv() ?? defaultWatcherConfig().maxFileSize;
+}
+
+/**
+ * Bytes read from the start of a file; larger files get estimated line counts
+ */
+const HEAD_LENGTH = 1024 * 1024;
+
+/**
+ * Human-readable byte count
+ */
+export function formatBytes(bytes: number): string {
+  if (bytes < 1024) {
+    return `${bytes} B`;
+  }
+  const units = ['KB', 'MB', 'GB'];
+  let value = bytes / 1024;
+  let unit = 0;
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024;
+    unit++;
+  }
+  return `${value.toFixed(value < 10 ? 1 : 0)} ${units[unit]}`;
+}
+
+/**
+ * Describe a workspace file without returning its content
+ * Only the first HEAD_LENGTH bytes are read; the size comes from the file
+ */
+export async function getFileInfo(workspaceDir: string, filePath: string): Promise<string> {
+  const relativePath = path.relative(workspaceDir, filePath);
+  toolsLogger.debug('Getting file info for %s', relativePath);
+  const overlaid = sharedOverlay().has(filePath);
+  let stats: fs.Stats | undefined;
+  try {
+    stats = await fs.promises.stat(filePath);
+  } catch (err) {
+    if (!overlaid) {
+      throw new ToolError('not-found', `${relativePath} does not exist`);
+    }
+  }
+  if (stats?.isDirectory()) {
+    throw new ToolError('invalid-argument', `${relativePath} is a directory; use tree to list it`);
+  }
+
+  const [{ head, size }, gitStatus] = await Promise.all([readFileHead(filePath, HEAD_LENGTH), fileGitStatus(filePath)]);
+  const complete = head.length === size;
+  const binary = isBinaryFile(filePath, head);
+  const text = binary ? '' : decodeText(head);
+  const language = detectLanguageId(filePath);
+
+  const rows: Array<[string, string]> = [
+    ['Size', `${formatBytes(size)} (${size} bytes)`],
+    ['Language', language === 'plaintext' ? `plaintext (${path.extname(filePath) || 'no extension'})` : language],
+  ];
+  if (binary) {
+    rows.push(['Binary', 'yes; search_code skips it unless binary: true']);
+  } else {
+    const lines = text.split('\n');
+    if (complete) {
+      rows.push(['Lines', String(lines[lines.length - 1] === '' ? lines.length - 1 : lines.length)]);
+    } else {
+      // Lines of the head, the last one likely cut short, scaled to the whole file
+      const estimate = Math.round(((lines.length - 1) * size) / head.length);
+      rows.push(['Lines', `about ${estimate} (estimated from the first ${formatBytes(head.length)})`]);
+    }
+    rows.push(['Encoding', detectEncoding(head).encoding]);
+  }
+  if (stats) {
+    rows.push(['Modified', stats.mtime.toISOString()]);
+  }
+  rows.push(['Git status', gitStatus]);
+  rows.push(['Generated', new GeneratedFileDetector(workspaceDir).isGenerated(relativePath, text) ? 'yes' : 'no']);
+  if (isTestFile(relativePath)) {
+    rows.push(['Test file', 'yes']);
+  }
+  if (size > maxSearchedFileSize()) {
+    rows.push(['Searched', 'no; larger than SEARCH_MAX_FILE_SIZE']);
+  }
+  if (overlaid) {
+    rows.push(['Overlay', 'yes; size and lines are of the overlay content']);
+  }
+
+  const width = Math.max(...rows.map(([label]) => label.length)) + 1;
+  return `${relativePath}\n\n` + rows.map(([label, value]) => `${`${label}:`.padEnd(width)} ${value}`).join('\n');
+}
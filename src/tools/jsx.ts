@@ -0,0 +1,58 @@
+/**
+ * JSX search tool - find React components, props, and hooks by syntax
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { searchJsx, JsxSearchOptions, JsxMatch } from '../search/jsx.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Describe what was searched for, e.g. "<UserCard onSelect>" or "useState"
+ */
+function describeQuery(options: JsxSearchOptions): string {
+  const parts: string[] = [];
+  if (options.component || options.prop) {
+    parts.push(`<${options.component ?? '*'}${options.prop ? ` ${options.prop}` : ''}>`);
+  }
+  if (options.hook) {
+    parts.push(options.hook === '*' ? 'hooks' : options.hook);
+  }
+  return parts.join(' and ') + (options.within ? ` in ${options.within}` : '');
+}
+
+/**
+ * Format one match line
+ */
+function formatMatch(match: JsxMatch): string {
+  const where = match.enclosing ? ` in ${match.enclosing}` : '';
+  return `L${match.line}:C${match.column}: [${match.kind}] ${match.name}${where}: ${match.lineText.trim()}\n`;
+}
+
+/**
+ * Search the workspace for JSX elements, props, and hook calls and format the matches
+ */
+export async function findJsx(workspaceDir: string, options: JsxSearchOptions): Promise<string> {
+  const query = describeQuery(options);
+  toolsLogger.debug('Searching JSX for %s', query);
+  const result = await searchJsx(workspaceDir, options);
+
+  const failed = result.failedFiles > 0 ? `, ${result.failedFiles} file(s) could not be parsed` : '';
+  if (result.matches.length === 0) {
+    return `No matches found for ${query} (${result.filesScanned} file(s) parsed${failed})`;
+  }
+
+  const byFile = new Map<string, JsxMatch[]>();
+  for (const match of result.matches) {
+    byFile.set(match.filePath, [...(byFile.get(match.filePath) ?? []), match]);
+  }
+  let output = `Found ${result.matches.length} match(es) for ${query} in ${byFile.size} file(s)${failed}`;
+  if (result.truncated) {
+    output += ` (results truncated at ${result.matches.length}; narrow the query or path)`;
+  }
+  output += '\n\n';
+  for (const [filePath, matches] of byFile) {
+    output += `---\n\n${filePath}\nMatches: ${matches.length}\n\n${matches.map(formatMatch).join('')}\n`;
+  }
+  return output;
+}
@@ -0,0 +1,45 @@
+/**
+ * Config query tool - find values in YAML and JSON files by key path
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { searchKeyPath, KeyPathSearchOptions, KeyPathMatch } from '../search/keypath.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Query the workspace's YAML and JSON files and format the values found
+ */
+export async function queryConfig(workspaceDir: string, keyPath: string, options: KeyPathSearchOptions = {}): Promise<string> {
+  toolsLogger.debug('Querying key path %s', keyPath);
+  const result = await searchKeyPath(workspaceDir, keyPath, options);
+
+  let notes = '';
+  if (result.parseErrors.length > 0) {
+    notes = `\n\nCould not parse ${result.parseErrors.length} file(s):\n` +
+      result.parseErrors.slice(0, 10).map((entry) => `${entry.filePath}: ${entry.error}\n`).join('');
+  }
+  if (result.matches.length === 0) {
+    return `No values found at ${keyPath} (${result.filesScanned} YAML/JSON file(s) read)${notes}`;
+  }
+
+  const byFile = new Map<string, KeyPathMatch[]>();
+  for (const match of result.matches) {
+    byFile.set(match.filePath, [...(byFile.get(match.filePath) ?? []), match]);
+  }
+  let output = `Found ${result.matches.length} value(s) at ${keyPath} in ${byFile.size} file(s)`;
+  if (result.truncated) {
+    output += ` (results truncated at ${result.matches.length}; narrow the path or glob)`;
+  }
+  output += '\n\n';
+  for (const [filePath, matches] of byFile) {
+    const documents = new Set(matches.map((match) => match.document)).size > 1 || matches[0].document > 1;
+    output += `---\n\n${filePath}\nMatches: ${matches.length}\n\n`;
+    for (const match of matches) {
+      const document = documents ? ` (document ${match.document})` : '';
+      output += `L${match.line}:C${match.column}: ${match.path} = ${match.value}${document}\n`;
+    }
+    output += '\n';
+  }
+  return output + notes.trimStart();
+}
@@ -0,0 +1,55 @@
+/**
+ * Tests for line history on search matches
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { attachLastChanges, clearLineHistory, formatLastChange } from './linehistory';
+import { searchCode } from './search';
+import { LexicalMatch } from '../search/lexical';
+
+describe('line history', () => {
+  let workspace: string;
+  const git = (...args: string[]) => execFileSync('git', ['-c', 'user.name=test', '-c', 'user.email=test@example.com', ...args],
+    { cwd: workspace, stdio: 'ignore', env: { ...process.env, GIT_AUTHOR_DATE: '2026-01-02T03:04:05Z', GIT_COMMITTER_DATE: '2026-01-02T03:04:05Z' } });
+  const match = (filePath: string, line: number): LexicalMatch => ({ filePath, line, column: 1, length: 1, lineText: '' });
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'linehistory-')));
+    git('init', '-q');
+    fs.writeFileSync(path.join(workspace, 'retry.go'), 'func retry() {}\n');
+    git('add', '.');
+    git('commit', '-qm', 'Add retries');
+    fs.appendFileSync(path.join(workspace, 'retry.go'), 'func retryLater() {}\n');
+  });
+
+  afterAll(() => {
+    clearLineHistory();
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should attach the last commit touching each matched line', async () => {
+    const matches = [match('retry.go', 1), match('retry.go', 2)];
+    expect(await attachLastChanges(workspace, matches)).toBe(true);
+    expect(matches[0].lastChange).toMatchObject({ subject: 'Add retries', time: Date.parse('2026-01-02T03:04:05Z') / 1000 });
+    expect(formatLastChange(matches[0].lastChange!)).toMatch(/^[0-9a-f]{8} 2026-01-02 Add retries$/);
+    expect(formatLastChange(matches[1].lastChange!)).toBe('not committed yet');
+
+    const output = await searchCode(workspace, 'retry', { lastChange: true });
+    expect(output).toMatch(/L1:C6: func retry\(\) \{\}\n {2}Last change: [0-9a-f]{8} 2026-01-02 Add retries\n/);
+    expect(output).toContain('  Last change: not committed yet');
+  });
+
+  it('should say when the workspace has no history', async () => {
+    const plain = fs.mkdtempSync(path.join(os.tmpdir(), 'linehistory-plain-'));
+    try {
+      fs.writeFileSync(path.join(plain, 'a.txt'), 'retry\n');
+      expect(await attachLastChanges(plain, [match('a.txt', 1)])).toBe(false);
+      expect(await searchCode(plain, 'retry', { lastChange: true })).toContain('(not a git repository, so no line history)');
+    } finally {
+      fs.rmSync(plain, { recursive: true, force: true });
+    }
+  });
+});
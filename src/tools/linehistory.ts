@@ -0,0 +1,112 @@
+/**
+ * Line history - the last commit touching each matched line
+ * Blames are cached per file until its content or HEAD changes, so agents can
+ * weigh matches by how recent they are and why they were written without a
+ * blame call per file. Files with an overlay are left out; their lines are
+ * not the ones git knows
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { BlameInfo, blameFile, runGit } from '../git/git.js';
+import { LexicalMatch, LineChange } from '../search/lexical.js';
+import { sharedOverlay } from '../workspace/overlay.js';
+import { currentSnapshot } from '../workspace/treesnapshot.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Files whose blames are kept between searches
+ */
+const MAX_CACHED_BLAMES = 500;
+
+/**
+ * Blames by absolute path, with the HEAD and file stamp, or the revision, they were taken at
+ */
+const blames = new Map<string, { stamp: string; lines: Map<number, BlameInfo> }>();
+
+/**
+ * Blame of a file, from the cache while its stamp holds
+ */
+async function cachedBlame(filePath: string, stamp: string, revision?: string): Promise<Map<number, BlameInfo>> {
+  const cached = blames.get(filePath);
+  if (cached && cached.stamp === stamp) {
+    // Most recently used last
+    blames.delete(filePath);
+    blames.set(filePath, cached);
+    return cached.lines;
+  }
+  const lines = await blameFile(filePath, revision);
+  blames.delete(filePath);
+  blames.set(filePath, { stamp, lines });
+  while (blames.size > MAX_CACHED_BLAMES) {
+    blames.delete(blames.keys().next().value!);
+  }
+  return lines;
+}
+
+/**
+ * Attach the last commit touching its line to each match
+ * Returns false outside a git repository. Under a snapshot, files are blamed
+ * at its commit and the files it recorded changes for are left out. Archive
+ * entries, notebook cells, and binary matches have no lines git blames
+ */
+export async function attachLastChanges(workspaceDir: string, matches: LexicalMatch[]): Promise<boolean> {
+  let head: string;
+  try {
+    head = (await runGit(workspaceDir, ['rev-parse', 'HEAD'])).trim();
+  } catch {
+    return false;
+  }
+  const snapshot = currentSnapshot();
+  const recorded = new Set(snapshot?.changedFiles() ?? []);
+  const byFile = new Map<string, Promise<Map<number, BlameInfo> | undefined>>();
+  const blame = async (relativePath: string): Promise<Map<number, BlameInfo> | undefined> => {
+    const filePath = path.join(workspaceDir, relativePath);
+    if (sharedOverlay().entry(filePath) || recorded.has(relativePath)) {
+      return undefined;
+    }
+    try {
+      if (snapshot) {
+        return await cachedBlame(filePath, snapshot.commit, snapshot.commit);
+      }
+      const stat = await fs.promises.stat(filePath);
+      return await cachedBlame(filePath, `${head}:${stat.mtimeMs}:${stat.size}`);
+    } catch (err) {
+      // Untracked and ignored files have no history
+      toolsLogger.debug('No blame for %s: %s', relativePath, (err as Error).message);
+      return undefined;
+    }
+  };
+  for (const match of matches) {
+    if (match.filePath.includes('!/') || match.cell || match.byteOffset !== undefined) {
+      continue;
+    }
+    if (!byFile.has(match.filePath)) {
+      byFile.set(match.filePath, blame(match.filePath));
+    }
+    const info = (await byFile.get(match.filePath))?.get(match.line);
+    if (info) {
+      match.lastChange = { commit: info.commit, time: info.authorTime, subject: info.summary };
+    }
+  }
+  return true;
+}
+
+/**
+ * The last change of a line, e.g. "3f2a1bc9 2026-10-12 Add retries"
+ */
+export function formatLastChange(change: LineChange): string {
+  if (/^0+$/.test(change.commit)) {
+    return 'not committed yet';
+  }
+  return `${change.commit.substring(0, 8)} ${new Date(change.time * 1000).toISOString().substring(0, 10)} ${change.subject}`;
+}
+
+/**
+ * Forget the cached blames
+ */
+export function clearLineHistory(): void {
+  blames.clear();
+}
@@ -0,0 +1,62 @@
+/**
+ * Tests for cross-language links
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { findLinkedReferences, formatLinkedReferences, goProtoName, linkedNames, protoFieldName } from './links';
+
+describe('Cross-language links', () => {
+  it('should convert between .proto and Go names', () => {
+    expect(goProtoName('user_id')).toBe('UserId');
+    expect(goProtoName('display_name_v2')).toBe('DisplayNameV2');
+    expect(protoFieldName('UserId')).toBe('user_id');
+  });
+
+  it('should link Go service and getter names back to the .proto declarations', () => {
+    const [proto] = linkedNames('UsersServer', [{ kind: 'proto-go' }]);
+    expect(proto.names).toEqual(['UsersServer', 'Users', 'users_server']);
+    expect(linkedNames('GetUserId', [{ kind: 'proto-go' }])[0].names).toEqual(['GetUserId', 'user_id', 'get_user_id']);
+    const [, go] = linkedNames('user_id', [{ kind: 'proto-go' }]);
+    expect(go.names).toEqual(['UserId', 'GetUserId']);
+    expect(go.glob).toEqual(['**/*.go']);
+  });
+
+  it('should link cgo names and OpenAPI operations', () => {
+    expect(linkedNames('C.sqlite3_open', [{ kind: 'cgo' }]).map((link) => link.names)).toEqual([['C.sqlite3_open'], ['sqlite3_open']]);
+    const links = linkedNames('HandleGetUser', [{ kind: 'openapi', handler: 'Handle{Name}' }]);
+    expect(links.map((link) => link.names)).toEqual([['HandleGetUser', 'getUser', 'GetUser']]);
+    expect(linkedNames('getUser', [{ kind: 'openapi' }])[1].names).toEqual(['GetUser']);
+    expect(linkedNames('getUser', [{ kind: 'openapi', handler: 'Handle{Name}' }])[1].names).toEqual(['HandleGetUser']);
+  });
+
+  describe('findLinkedReferences', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'links-'));
+      fs.mkdirSync(path.join(workspace, 'api'));
+      fs.writeFileSync(path.join(workspace, 'api', 'users.proto'), 'service Users {\n  rpc GetUser (GetUserRequest) returns (User);\n}\n');
+      fs.writeFileSync(path.join(workspace, 'api', 'openapi.yaml'), 'paths:\n  /users/{id}:\n    get:\n      operationId: getUser\n');
+      fs.writeFileSync(path.join(workspace, 'server.go'), 'type server struct{ api.UnimplementedUsersServer }\n\nfunc GetUser() {}\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should find the linked names on the other side of each rule', async () => {
+      const linked = await findLinkedReferences(workspace, 'Users', [{ kind: 'proto-go' }]);
+      expect(linked.map(({ link, matches }) => [link.glob, matches.map((m) => `${m.filePath}:${m.line}`)])).toEqual([
+        [['**/*.proto'], [path.join('api', 'users.proto') + ':1']],
+        [['**/*.go'], ['server.go:1']],
+      ]);
+
+      const operations = await findLinkedReferences(workspace, 'GetUser', [{ kind: 'openapi' }]);
+      const output = formatLinkedReferences('GetUser', operations);
+      expect(output).toContain('Linked references of GetUser across language boundaries');
+      expect(output).toContain(`${path.join('api', 'openapi.yaml')}:4:7: operationId: getUser`);
+    });
+  });
+});
@@ -0,0 +1,263 @@
+/**
+ * Cross-language links - references across generated-code seams
+ * A language server stops at the language boundary: gopls does not see the
+ * .proto message a Go type was generated from, nor the C function behind a
+ * C.name call. Link rules from the links setting map a symbol name to the
+ * names it has on the other side, which are then found by text search
+ */
+
+import { LinkKind, LinkRule } from '../config/config.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { escapeRegExp, searchLexical, LexicalMatch } from '../search/lexical.js';
+import { TrigramIndex } from '../search/trigram.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Spec files searched for operationIds when a rule names none
+ */
+const DEFAULT_SPEC_FILES = ['**/openapi*.{yaml,yml,json}', '**/swagger*.{yaml,yml,json}'];
+
+/**
+ * Most matches listed for each linked name
+ */
+const MAX_LINKED_MATCHES = 50;
+
+/**
+ * A name a symbol has on the other side of a link, and where to look for it
+ */
+export interface LinkedName {
+  kind: LinkKind;
+  // What the names are, e.g. "Go names generated from the .proto field"
+  description: string;
+  names: string[];
+  // Regular expression matching any of the names in use
+  pattern: string;
+  glob: string[];
+  excludeGlob?: string[];
+}
+
+/**
+ * Matches of one linked name
+ */
+export interface LinkedMatches {
+  link: LinkedName;
+  matches: LexicalMatch[];
+  truncated: boolean;
+}
+
+function upperFirst(name: string): string {
+  return name.charAt(0).toUpperCase() + name.substring(1);
+}
+
+function lowerFirst(name: string): string {
+  return name.charAt(0).toLowerCase() + name.substring(1);
+}
+
+/**
+ * Go name protoc-gen-go gives a .proto name, e.g. user_id to UserId
+ */
+export function goProtoName(name: string): string {
+  return name.split('_').filter(Boolean).map(upperFirst).join('');
+}
+
+/**
+ * .proto field name of a Go field name, e.g. UserId to user_id
+ */
+export function protoFieldName(name: string): string {
+  return name.replace(/([a-z0-9])([A-Z])/g, '$1_$2').toLowerCase();
+}
+
+/**
+ * Whole-word pattern for any of the names
+ */
+function wordsPattern(names: string[]): string {
+  return `\\b(?:${names.map(escapeRegExp).join('|')})\\b`;
+}
+
+/**
+ * Names on the proto side and the Go side of a proto-go link
+ * Services get Client, Server, RegisterServer, and UnimplementedServer types
+ * in Go, and fields get a Go field and a Get getter; message names are kept
+ */
+function protoGoLinks(name: string): LinkedName[] {
+  const links: LinkedName[] = [];
+  const protoNames = new Set<string>([name]);
+  const service = /^(?:Register|Unimplemented)?(\w+?)(?:Client|Server)$/.exec(name)?.[1];
+  if (service) {
+    protoNames.add(service);
+  }
+  const getter = /^Get([A-Z]\w*)$/.exec(name)?.[1];
+  for (const field of [getter, name]) {
+    if (field && /^[A-Z]/.test(field) && /[a-z]/.test(field)) {
+      protoNames.add(protoFieldName(field));
+    }
+  }
+  links.push({
+    kind: 'proto-go',
+    description: 'the .proto declarations the Go name is generated from',
+    names: Array.from(protoNames),
+    pattern: wordsPattern(Array.from(protoNames)),
+    glob: ['**/*.proto'],
+  });
+
+  const goNames = new Set<string>();
+  const pascal = goProtoName(name);
+  if (pascal !== name) {
+    // A field in snake_case
+    goNames.add(pascal).add(`Get${pascal}`);
+  }
+  if (/^[A-Z]/.test(name) && !service) {
+    for (const generated of [`${name}Client`, `${name}Server`, `Register${name}Server`, `Unimplemented${name}Server`]) {
+      goNames.add(generated);
+    }
+  }
+  if (goNames.size > 0) {
+    links.push({
+      kind: 'proto-go',
+      description: 'the Go names generated from the .proto declaration',
+      names: Array.from(goNames),
+      pattern: wordsPattern(Array.from(goNames)),
+      glob: ['**/*.go'],
+    });
+  }
+  return links;
+}
+
+/**
+ * Names on the Go side and the C side of a cgo link
+ */
+function cgoLinks(name: string): LinkedName[] {
+  const base = name.replace(/^C\./, '');
+  if (!/^\w+$/.test(base)) {
+    return [];
+  }
+  return [
+    {
+      kind: 'cgo',
+      description: 'the cgo calls of the C name from Go',
+      names: [`C.${base}`],
+      pattern: `\\bC\\.${escapeRegExp(base)}\\b`,
+      glob: ['**/*.go'],
+    },
+    {
+      kind: 'cgo',
+      description: 'the C declarations behind the cgo name',
+      names: [base],
+      pattern: wordsPattern([base]),
+      glob: ['**/*.{h,hpp,c,cc,cpp}'],
+    },
+  ];
+}
+
+/**
+ * Names in the spec and in the code of an openapi link
+ * The handler template maps an operationId to its handler; a name matching
+ * the template is taken for a handler, and any other for an operationId
+ */
+function openapiLinks(name: string, rule: LinkRule): LinkedName[] {
+  const template = rule.handler ?? '{Name}';
+  const specFiles = rule.files ?? DEFAULT_SPEC_FILES;
+  const links: LinkedName[] = [];
+
+  const templatePattern = new RegExp('^' + escapeRegExp(template).replace(/\\\{Name\\\}|\\\{name\\\}/g, '(\\w+)') + '$');
+  const operation = templatePattern.exec(name)?.[1];
+  const operations = new Set([name]);
+  if (operation) {
+    operations.add(lowerFirst(operation)).add(upperFirst(operation));
+  }
+  links.push({
+    kind: 'openapi',
+    description: 'the OpenAPI operations the handler implements',
+    names: Array.from(operations),
+    pattern: `operationId["']?\\s*:\\s*["']?(?:${Array.from(operations).map(escapeRegExp).join('|')})\\b`,
+    glob: specFiles,
+  });
+
+  // A bare template matches every name, which may be an operationId all the same
+  const bare = /^\{[Nn]ame\}$/.test(template);
+  const handler = template.replace(/\{Name\}/g, upperFirst(name)).replace(/\{name\}/g, lowerFirst(name));
+  if (handler !== name && (bare || !operation)) {
+    links.push({
+      kind: 'openapi',
+      description: 'the handler implementing the OpenAPI operation',
+      names: [handler],
+      pattern: wordsPattern([handler]),
+      glob: ['**/*'],
+      excludeGlob: specFiles,
+    });
+  }
+  return links;
+}
+
+/**
+ * Names a symbol has across the boundaries of the link rules
+ * Only the last segment of a qualified name (Service.Method) is linked. The
+ * name as given is left to the language server, except in files of the
+ * other language
+ */
+export function linkedNames(symbolName: string, rules: LinkRule[]): LinkedName[] {
+  const name = symbolName.startsWith('C.') ? symbolName : symbolName.split('.').pop() ?? symbolName;
+  const links: LinkedName[] = [];
+  for (const rule of rules) {
+    switch (rule.kind) {
+      case 'proto-go':
+        links.push(...protoGoLinks(name));
+        break;
+      case 'cgo':
+        links.push(...cgoLinks(name));
+        break;
+      case 'openapi':
+        links.push(...openapiLinks(name, rule));
+        break;
+    }
+  }
+  return links;
+}
+
+/**
+ * Find the uses of a symbol's linked names, under scope when given
+ * Names with no match are left out
+ */
+export async function findLinkedReferences(
+  workspaceDir: string,
+  symbolName: string,
+  rules: LinkRule[],
+  index?: TrigramIndex,
+  scope?: string
+): Promise<LinkedMatches[]> {
+  const found: LinkedMatches[] = [];
+  for (const link of linkedNames(symbolName, rules)) {
+    toolsLogger.debug('Searching %s link of %s: %s', link.kind, symbolName, link.pattern);
+    const result = await searchLexical(workspaceDir, link.pattern, {
+      regex: true,
+      caseSensitive: true,
+      glob: link.glob,
+      excludeGlob: link.excludeGlob,
+      path: scope,
+      maxResults: MAX_LINKED_MATCHES,
+    }, index);
+    if (result.matches.length > 0) {
+      found.push({ link, matches: result.matches, truncated: result.truncated });
+    }
+  }
+  return found;
+}
+
+/**
+ * Format linked references as a section following the references
+ */
+export function formatLinkedReferences(symbolName: string, linked: LinkedMatches[]): string {
+  if (linked.length === 0) {
+    return '';
+  }
+  let output = `---\n\nLinked references of ${symbolName} across language boundaries\n`;
+  for (const { link, matches, truncated } of linked) {
+    output += `\n${link.kind}: ${link.names.join(', ')}, ${link.description} (${matches.length}${truncated ? '+' : ''} match(es))\n`;
+    for (const match of matches) {
+      const text = match.lineText.trim();
+      output += `  ${match.filePath}:${match.line}:${match.column}: ${text.length > 200 ? text.substring(0, 200) + '...' : text}\n`;
+    }
+  }
+  return output;
+}
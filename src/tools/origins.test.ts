@@ -0,0 +1,115 @@
+/**
+ * Tests for the message origin tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { extractStringLiterals, parseTemplate, scoreOrigin, findMessageOrigins, formatMessageOrigins } from './origins';
+
+describe('Message origins', () => {
+  describe('extractStringLiterals', () => {
+    it('should read literals with escapes and skip comments', () => {
+      const content = '// "not this"\nconst a = "say \\"hi\\"\\n";\n/* \'nor this\' */ f(\'x\', `multi\nline`);';
+      expect(extractStringLiterals(content, 'typescript')).toEqual([
+        { text: 'say "hi"\n', line: 2 },
+        { text: 'x', line: 3 },
+        { text: 'multi\nline', line: 3 },
+      ]);
+    });
+
+    it('should use hash comments and triple quotes for Python', () => {
+      const content = '# "comment"\nraise ValueError("bad %s" % x)\ndoc = """one\ntwo"""\nprint("after")';
+      expect(extractStringLiterals(content, 'python')).toEqual([
+        { text: 'bad %s', line: 2 },
+        { text: 'one\ntwo', line: 3 },
+        { text: 'after', line: 5 },
+      ]);
+    });
+
+    it('should not treat an unclosed quote as a string', () => {
+      expect(extractStringLiterals("it's here\nx = \"ok\"", 'go')).toEqual([{ text: 'ok', line: 2 }]);
+    });
+  });
+
+  describe('parseTemplate', () => {
+    it('should split printf verbs and interpolation', () => {
+      expect(parseTemplate('open %q: %w')).toEqual({ parts: ['open ', ': ', ''], placeholders: ['%q', '%w'] });
+      expect(parseTemplate('user ${id} has {count} items at 100%%')).toEqual({
+        parts: ['user ', ' has ', ' items at 100%'],
+        placeholders: ['${id}', '{count}'],
+      });
+    });
+  });
+
+  describe('scoreOrigin', () => {
+    it('should match format strings and read placeholder values', () => {
+      const scored = scoreOrigin('open "/etc/app.yaml": permission denied', 'open %q: %w');
+      expect(scored?.how).toBe('format');
+      expect(scored?.values).toEqual([
+        { placeholder: '%q', value: '"/etc/app.yaml"' },
+        { placeholder: '%w', value: 'permission denied' },
+      ]);
+    });
+
+    it('should score exact literals highest', () => {
+      expect(scoreOrigin('connection refused', 'connection refused')?.score).toBe(1);
+      const partial = scoreOrigin('dial tcp: connection refused', 'connection refused')!;
+      expect(partial.score).toBeLessThan(1);
+      expect(partial.score).toBeGreaterThan(0.5);
+    });
+
+    it('should match literals made of pattern syntax and many placeholders quickly', () => {
+      expect(scoreOrigin('cost (a+b)*  2 [x]', 'Cost (a+b)* %d [x]')?.values).toEqual([{ placeholder: '%d', value: '2' }]);
+      const literal = Array.from({ length: 30 }, () => '%s a').join('');
+      const started = Date.now();
+      expect(scoreOrigin('a'.repeat(5000), literal)).toBeUndefined();
+      expect(Date.now() - started).toBeLessThan(1000);
+    });
+
+    it('should fall back to word similarity', () => {
+      const scored = scoreOrigin('failed to load the config file', 'could not load config file %s');
+      expect(scored?.how).toBe('similar');
+      expect(scored!.score).toBeLessThanOrEqual(0.5);
+      expect(scoreOrigin('failed to load the config file', 'unrelated words entirely')).toBeUndefined();
+    });
+  });
+
+  describe('findMessageOrigins', () => {
+    let workspaceDir: string;
+
+    beforeEach(() => {
+      workspaceDir = fs.mkdtempSync(path.join(os.tmpdir(), 'origins-'));
+      fs.mkdirSync(path.join(workspaceDir, 'pkg'));
+      fs.writeFileSync(path.join(workspaceDir, 'pkg', 'load.go'),
+        'package pkg\n\nfunc Load(name string) error {\n\treturn fmt.Errorf("load config %s: %w", name, err)\n}\n');
+      fs.writeFileSync(path.join(workspaceDir, 'server.ts'),
+        'log.info(`listening on port ${port}`);\nthrow new Error("config missing");\n');
+      fs.writeFileSync(path.join(workspaceDir, 'notes.md'), 'load config app.yaml: file not found\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspaceDir, { recursive: true, force: true });
+    });
+
+    it('should rank the format string the message came from first', async () => {
+      const origins = await findMessageOrigins(workspaceDir, 'load config app.yaml: file not found');
+      expect(origins[0]).toMatchObject({ filePath: path.join('pkg', 'load.go'), line: 4, how: 'format' });
+      expect(origins.every((origin) => origin.filePath !== 'notes.md')).toBe(true);
+
+      const text = formatMessageOrigins('load config app.yaml: file not found', origins);
+      expect(text).toContain('1. pkg/load.go:4  (score');
+      expect(text).toContain('%s = "app.yaml", %w = "file not found"');
+    });
+
+    it('should restrict the search to a path', async () => {
+      const origins = await findMessageOrigins(workspaceDir, 'listening on port 8080', { path: 'pkg' });
+      expect(origins).toEqual([]);
+      expect(formatMessageOrigins('listening on port 8080', origins)).toBe('No string literals resembling "listening on port 8080"');
+    });
+
+    it('should reject messages without words', async () => {
+      await expect(findMessageOrigins(workspaceDir, '42 !!')).rejects.toThrow('no words');
+    });
+  });
+});
@@ -0,0 +1,315 @@
+/**
+ * Message origin tool - the string literals a runtime log or error message came from
+ * Turns the format strings of the code (printf verbs, {} and ${} interpolation)
+ * into patterns, matches the message against them, and falls back to word
+ * similarity for messages that were reworded or assembled from pieces
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { isBinaryFile } from '../workspace/binary.js';
+import { decodeText } from '../workspace/encoding.js';
+import { matchesGlob } from '../workspace/glob.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileBytes } from '../workspace/overlay.js';
+import { createLimiter } from '../workspace/pool.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { HASH_COMMENT_LANGUAGES } from './duplicates.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * A string literal of a source file
+ */
+export interface StringLiteral {
+  text: string; // Unescaped content without quotes
+  line: number; // 1-indexed
+}
+
+/**
+ * A format string split into literal text and placeholders
+ */
+export interface MessageTemplate {
+  parts: string[]; // Literal text; placeholders sit between consecutive parts
+  placeholders: string[];
+}
+
+/**
+ * A candidate origin of a message
+ */
+export interface MessageOrigin {
+  filePath: string;
+  line: number;
+  literal: string;
+  score: number; // 0-1
+  how: 'format' | 'similar';
+  // Placeholder values read from the message, for format matches
+  values?: Array<{ placeholder: string; value: string }>;
+}
+
+/**
+ * Options for the message origin tool
+ */
+export interface MessageOriginOptions {
+  path?: string;
+  glob?: string | string[];
+  // Most candidates listed (default: 10)
+  limit?: number;
+}
+
+/**
+ * Longest literal considered; longer ones are rarely messages
+ */
+const MAX_LITERAL_LENGTH = 500;
+
+/**
+ * Least word similarity (Dice coefficient) for a similar match
+ */
+const MIN_SIMILARITY = 0.4;
+
+/**
+ * printf verbs (%s, %-8.3f, %(name)s, %w), ${expr} and #{expr} interpolation,
+ * and {} / {0} / {name} / {name:?} placeholders; %% is a literal percent sign
+ */
+const PLACEHOLDER = /%%|%(?:\([^)]*\))?[-+# 0]*(?:\d+|\*)?(?:\.(?:\d+|\*))?(?:hh|h|ll|l|L|q|j|z|t)?[a-zA-Z]|\$\{[^}]*\}|#\{[^}]*\}|\{[^{}\s]*\}/g;
+
+const ESCAPES: Record<string, string> = { n: '\n', t: '\t', r: '\r', '0': '\0' };
+
+/**
+ * String literals of a file: "..." and '...' on one line, `...` across lines
+ * Comments are skipped; Python triple-quoted strings are read as one literal.
+ */
+export function extractStringLiterals(content: string, languageId: string): StringLiteral[] {
+  const literals: StringLiteral[] = [];
+  const hashComments = HASH_COMMENT_LANGUAGES.has(languageId);
+  let line = 1;
+  let i = 0;
+  while (i < content.length) {
+    const ch = content[i];
+    if (ch === '\n') {
+      line++;
+      i++;
+    } else if ((!hashComments && content.startsWith('//', i)) || (hashComments && ch === '#')) {
+      while (i < content.length && content[i] !== '\n') {
+        i++;
+      }
+    } else if (!hashComments && content.startsWith('/*', i)) {
+      const end = content.indexOf('*/', i + 2);
+      const stop = end === -1 ? content.length : end + 2;
+      line += content.substring(i, stop).split('\n').length - 1;
+      i = stop;
+    } else if (ch === '"' || ch === '\'' || ch === '`') {
+      const triple = content.startsWith(ch.repeat(3), i) && hashComments;
+      const quote = triple ? ch.repeat(3) : ch;
+      const multiline = triple || ch === '`';
+      const startLine = line;
+      let text = '';
+      let j = i + quote.length;
+      let closed = false;
+      while (j < content.length) {
+        if (content.startsWith(quote, j)) {
+          closed = true;
+          j += quote.length;
+          break;
+        }
+        const c = content[j];
+        if (c === '\n') {
+          if (!multiline) {
+            break;
+          }
+          line++;
+        }
+        if (c === '\\' && ch !== '`' && j + 1 < content.length) {
+          const next = content[j + 1];
+          text += ESCAPES[next] ?? next;
+          j += 2;
+          continue;
+        }
+        text += c;
+        j++;
+      }
+      // A quote that is not closed on its line is an apostrophe or a rune, not a string
+      if (closed && text.length <= MAX_LITERAL_LENGTH) {
+        literals.push({ text, line: startLine });
+      }
+      i = closed ? j : i + 1;
+    } else {
+      i++;
+    }
+  }
+  return literals;
+}
+
+/**
+ * Split a format string at its placeholders
+ */
+export function parseTemplate(literal: string): MessageTemplate {
+  const parts = [''];
+  const placeholders: string[] = [];
+  let last = 0;
+  for (const match of literal.matchAll(PLACEHOLDER)) {
+    parts[parts.length - 1] += literal.substring(last, match.index);
+    if (match[0] === '%%') {
+      parts[parts.length - 1] += '%';
+    } else {
+      placeholders.push(match[0]);
+      parts.push('');
+    }
+    last = match.index! + match[0].length;
+  }
+  parts[parts.length - 1] += literal.substring(last);
+  return { parts, placeholders };
+}
+
+/**
+ * Lowercase words of two or more letters or digits
+ */
+function words(text: string): string[] {
+  return text.toLowerCase().match(/[a-z0-9][a-z0-9_']+/g) ?? [];
+}
+
+/**
+ * Dice coefficient of two word lists, as multisets
+ */
+function similarity(a: string[], b: string[]): number {
+  if (a.length === 0 || b.length === 0) {
+    return 0;
+  }
+  const counts = new Map<string, number>();
+  for (const word of a) {
+    counts.set(word, (counts.get(word) ?? 0) + 1);
+  }
+  let shared = 0;
+  for (const word of b) {
+    const count = counts.get(word) ?? 0;
+    if (count > 0) {
+      shared++;
+      counts.set(word, count - 1);
+    }
+  }
+  return (2 * shared) / (a.length + b.length);
+}
+
+/**
+ * Where the parts of a template occur in a text in order, case-insensitively,
+ * with the text between them; the first occurrence of each part after the
+ * one before, the last placeholder taking the rest of the text.
+ * Found with indexOf rather than a regular expression, since the parts come
+ * from workspace files and a pattern of many wildcards backtracks badly
+ */
+function matchTemplate(text: string, parts: string[]): { start: number; end: number; values: string[] } | undefined {
+  const lower = text.toLowerCase();
+  // Lowercasing rarely changes the length; then the values are read lowercased
+  const source = lower.length === text.length ? text : lower;
+  const values: string[] = [];
+  let start = 0;
+  let at = 0;
+  for (let i = 0; i < parts.length; i++) {
+    if (i > 0 && i === parts.length - 1 && parts[i] === '') {
+      values.push(source.substring(at));
+      return { start, end: source.length, values };
+    }
+    const found = lower.indexOf(parts[i].toLowerCase(), at);
+    if (found < 0) {
+      return undefined;
+    }
+    if (i === 0) {
+      start = found;
+    } else {
+      values.push(source.substring(at, found));
+    }
+    at = found + parts[i].length;
+  }
+  return { start, end: at, values };
+}
+
+/**
+ * Score a literal as the origin of a message
+ * A format match - the literal text in order, placeholders matching anything,
+ * runs of whitespace matching any others - scores from 0.5 to 1 by how much
+ * of the message it explains with literal text; otherwise word similarity
+ * scores up to 0.5
+ */
+export function scoreOrigin(message: string, literal: string): Omit<MessageOrigin, 'filePath' | 'line' | 'literal'> | undefined {
+  const template = parseTemplate(literal);
+  const fixed = template.parts.join('');
+  if (fixed.replace(/\s/g, '').length >= 4) {
+    const text = message.replace(/\s+/g, ' ');
+    const parts = template.parts.map((part) => part.replace(/\s+/g, ' '));
+    const match = matchTemplate(text, parts);
+    if (match && match.end > match.start) {
+      const length = match.end - match.start;
+      const span = length / text.length;
+      const literalness = Math.min(1, parts.join('').length / length);
+      return {
+        score: 0.5 + 0.25 * span + 0.25 * literalness,
+        how: 'format',
+        values: template.placeholders.map((placeholder, index) => ({ placeholder, value: match.values[index] })),
+      };
+    }
+  }
+  const score = similarity(words(message), words(fixed));
+  return score >= MIN_SIMILARITY ? { score: score / 2, how: 'similar' } : undefined;
+}
+
+/**
+ * Rank the string literals of the workspace as origins of a message
+ */
+export async function findMessageOrigins(workspaceDir: string, message: string, options: MessageOriginOptions = {}): Promise<MessageOrigin[]> {
+  const wanted = new Set(words(message).filter((word) => word.length >= 3 && !/^\d+$/.test(word)));
+  if (wanted.size === 0) {
+    throw new ToolError('invalid-argument', 'The message has no words to match');
+  }
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: options.path }))
+    .filter((file) => detectLanguageId(file.relativePath) !== 'plaintext')
+    .filter((file) => !options.glob || matchesGlob(file.relativePath, options.glob));
+  toolsLogger.debug('Matching message origins in %d files', files.length);
+
+  const origins: MessageOrigin[] = [];
+  const limit = createLimiter(8);
+  await Promise.all(files.map((file) => limit(async () => {
+    let bytes: Buffer;
+    try {
+      bytes = await readFileBytes(file.absolutePath);
+    } catch (err) {
+      return;
+    }
+    if (isBinaryFile(file.absolutePath, bytes)) {
+      return;
+    }
+    const content = decodeText(bytes);
+    for (const literal of extractStringLiterals(content, detectLanguageId(file.relativePath))) {
+      // Literals sharing no word with the message cannot match
+      if (!words(literal.text).some((word) => wanted.has(word))) {
+        continue;
+      }
+      const scored = scoreOrigin(message, literal.text);
+      if (scored) {
+        origins.push({ filePath: file.relativePath, line: literal.line, literal: literal.text, ...scored });
+      }
+    }
+  })));
+  origins.sort((a, b) => b.score - a.score || a.filePath.localeCompare(b.filePath) || a.line - b.line);
+  return origins.slice(0, options.limit ?? 10);
+}
+
+/**
+ * Format ranked origins for the tool result
+ */
+export function formatMessageOrigins(message: string, origins: MessageOrigin[]): string {
+  if (origins.length === 0) {
+    return `No string literals resembling "${message}"`;
+  }
+  const lines = [`Candidate origins of "${message}"`];
+  origins.forEach((origin, index) => {
+    lines.push('', `${index + 1}. ${origin.filePath.split(path.sep).join('/')}:${origin.line}  ` +
+      `(score ${origin.score.toFixed(2)}, ${origin.how === 'format' ? 'format match' : 'similar words'})`);
+    lines.push(`   ${JSON.stringify(origin.literal)}`);
+    const values = (origin.values ?? []).filter((value) => value.value !== '');
+    if (values.length > 0) {
+      lines.push(`   ${values.map((value) => `${value.placeholder} = ${JSON.stringify(value.value)}`).join(', ')}`);
+    }
+  });
+  return lines.join('\n');
+}
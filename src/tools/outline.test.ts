@@ -0,0 +1,60 @@
+/**
+ * Tests for the outline tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { docSummary, getFileOutline } from './outline';
+
+const PYTHON = `"""Account storage."""
+
+
+class Store:
+    """Keeps accounts in memory. Not thread-safe."""
+
+    def get(self, key):
+        """Return the account for a key."""
+        return self.items[key]
+
+    def put(self, key, value):
+        self.items[key] = value
+
+
+# Open the default store.
+def open_store():
+    return Store()
+`;
+
+describe('outline', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'outline-'));
+    fs.writeFileSync(path.join(workspace, 'store.py'), PYTHON);
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should nest symbols with ranges and doc summaries', async () => {
+    const output = await getFileOutline(undefined, workspace, path.join(workspace, 'store.py'));
+    expect(output).toContain('store.py (18 lines, 4 symbol(s))');
+    expect(output).toMatch(/^\[Class\] Store L4-12 - Keeps accounts in memory\.$/m);
+    expect(output).toMatch(/^ {2}\[Method\] get\(self, key\) L7-9 - Return the account for a key\.$/m);
+    expect(output).toMatch(/^\[Function\] open_store\(\) L16-17 - Open the default store\.$/m);
+  });
+
+  it('should stop at the requested depth', async () => {
+    const output = await getFileOutline(undefined, workspace, path.join(workspace, 'store.py'), { maxDepth: 0, includeDocs: false });
+    expect(output).not.toContain('[Method]');
+    expect(output).not.toContain(' - ');
+    expect(output).toContain('2 nested symbol(s) below depth 0 not shown');
+  });
+
+  it('should summarize a doc comment by its first sentence', () => {
+    expect(docSummary('Parses the file. Returns nil on error.\n\nDetails.')).toBe('Parses the file.');
+    expect(docSummary('Version 1.2 parser\nfor configs')).toBe('Version 1.2 parser for configs');
+  });
+});
@@ -0,0 +1,108 @@
+/**
+ * Outline tool - nested symbols of a file with line ranges and doc comment summaries
+ * A compact view of a file's structure, much cheaper than reading the file
+ */
+
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { SymbolKindNames } from '../protocol/types.js';
+import { readFileText } from '../workspace/overlay.js';
+import { FlatSymbol, getFileSymbols } from './symbols.js';
+import { getDocComment } from './utilities.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Longest symbol detail (usually a signature) shown beside the name
+ */
+const MAX_DETAIL_LENGTH = 80;
+
+/**
+ * Longest doc comment summary shown
+ */
+const MAX_SUMMARY_LENGTH = 100;
+
+/**
+ * Options for the outline tool
+ */
+export interface OutlineOptions {
+  // Deepest nesting level shown, 0 for top-level symbols only (default: all)
+  maxDepth?: number;
+  // Show the first sentence of each symbol's doc comment (default: true)
+  includeDocs?: boolean;
+}
+
+/**
+ * First sentence of a doc comment, on one line
+ */
+export function docSummary(doc: string): string {
+  const paragraph = doc.split(/\n\s*\n/)[0].replace(/\s+/g, ' ').trim();
+  const sentence = paragraph.match(/^.*?[.!?](?=\s|$)/)?.[0] ?? paragraph;
+  return sentence.length > MAX_SUMMARY_LENGTH ? sentence.substring(0, MAX_SUMMARY_LENGTH) + '…' : sentence;
+}
+
+/**
+ * Symbol detail to show after the name
+ * Details that repeat the declaration ("def get(self, key)") keep only what follows the name
+ */
+function formatDetail(sym: FlatSymbol): string {
+  const detail = sym.detail?.trim();
+  if (!detail || detail.length > MAX_DETAIL_LENGTH || detail.includes('\n')) {
+    return '';
+  }
+  const at = detail.indexOf(sym.name);
+  if (at >= 0) {
+    return detail.substring(at + sym.name.length).replace(/:$/, '');
+  }
+  return ` ${detail}`;
+}
+
+/**
+ * Format one outline line: kind, name, detail, line range, and doc summary
+ */
+function formatEntry(sym: FlatSymbol, lines: string[], includeDocs: boolean): string {
+  const kind = SymbolKindNames[sym.kind] || 'Unknown';
+  const start = sym.range.start.line + 1;
+  const end = sym.range.end.line + 1;
+  const range = end > start ? `L${start}-${end}` : `L${start}`;
+  let entry = `${'  '.repeat(sym.depth)}[${kind}] ${sym.name}${formatDetail(sym)} ${range}`;
+  if (includeDocs) {
+    const doc = getDocComment(lines, sym.range.start.line) || getDocComment(lines, sym.selectionRange.start.line);
+    if (doc) {
+      entry += ` - ${docSummary(doc)}`;
+    }
+  }
+  return entry + '\n';
+}
+
+/**
+ * Nested outline of a file's symbols
+ */
+export async function getFileOutline(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  filePath: string,
+  options: OutlineOptions = {}
+): Promise<string> {
+  const includeDocs = options.includeDocs ?? true;
+  const relativePath = path.relative(workspaceDir, filePath);
+  toolsLogger.debug('Outlining %s (max depth: %s)', relativePath, options.maxDepth);
+
+  const [symbols, content] = await Promise.all([getFileSymbols(client, workspaceDir, filePath), readFileText(filePath)]);
+  const lines = content.split('\n');
+  const shown = symbols.filter((sym) => options.maxDepth === undefined || sym.depth <= options.maxDepth);
+  if (shown.length === 0) {
+    return `No symbols in ${relativePath} (${lines.length} lines)`;
+  }
+
+  let output = `${relativePath} (${lines.length} lines, ${shown.length} symbol(s))\n\n`;
+  for (const sym of shown) {
+    output += formatEntry(sym, lines, includeDocs);
+  }
+  const hidden = symbols.length - shown.length;
+  if (hidden > 0) {
+    output += `\n${hidden} nested symbol(s) below depth ${options.maxDepth} not shown\n`;
+  }
+  return output.trimEnd();
+}
@@ -0,0 +1,69 @@
+/**
+ * Tests for the pin tools
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { formatPins, PinSet, resolvePins, MAX_PINS } from './pins';
+import { ToolError } from '../workspace/errors';
+
+describe('Pins', () => {
+  describe('PinSet', () => {
+    it('should pin once and list distinct files in pin order', () => {
+      const pins = new PinSet();
+      expect(pins.pin({ filePath: 'b.ts' })).toBe(true);
+      expect(pins.pin({ filePath: 'a.ts', symbol: 'Store', line: 3 })).toBe(true);
+      expect(pins.pin({ filePath: 'b.ts' })).toBe(false);
+      expect(pins.pin({ filePath: 'b.ts', symbol: 'run', line: 9 })).toBe(true);
+      expect(pins.files()).toEqual(['b.ts', 'a.ts']);
+      expect(formatPins(pins.list())).toBe('Pinned: 2 file(s), 3 pin(s)\n- b.ts: run (line 9)\n- a.ts: Store (line 3)\n');
+    });
+
+    it('should unpin a file with its symbols or one symbol', () => {
+      const pins = new PinSet();
+      pins.pin({ filePath: 'a.ts' });
+      pins.pin({ filePath: 'a.ts', symbol: 'Store' });
+      pins.pin({ filePath: 'b.ts', symbol: 'Store' });
+      expect(pins.unpin(undefined, 'Store').map((p) => p.filePath)).toEqual(['a.ts', 'b.ts']);
+      expect(pins.unpin('a.ts')).toEqual([{ filePath: 'a.ts' }]);
+      expect(pins.size()).toBe(0);
+      expect(formatPins(pins.list())).toContain('Nothing is pinned');
+    });
+
+    it('should refuse pins past the limit', () => {
+      const pins = new PinSet();
+      for (let i = 0; i < MAX_PINS; i++) {
+        pins.pin({ filePath: `f${i}.ts` });
+      }
+      expect(() => pins.pin({ filePath: 'one-more.ts' })).toThrow(ToolError);
+    });
+  });
+
+  describe('resolvePins', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'pins-'));
+      fs.writeFileSync(path.join(workspace, 'models.py'), 'class User:\n    def save(self):\n        pass\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should pin a file, or a symbol in it by name', async () => {
+      const file = path.join(workspace, 'models.py');
+      expect(await resolvePins(workspace, undefined, file, undefined)).toEqual([{ filePath: 'models.py' }]);
+      expect(await resolvePins(workspace, undefined, file, 'save'))
+        .toEqual([{ filePath: 'models.py', symbol: 'User.save', line: 2 }]);
+    });
+
+    it('should fail for a missing file or symbol', async () => {
+      await expect(resolvePins(workspace, undefined, path.join(workspace, 'gone.py'), undefined))
+        .rejects.toThrow('File gone.py does not exist');
+      await expect(resolvePins(workspace, undefined, path.join(workspace, 'models.py'), 'missing'))
+        .rejects.toThrow('No symbol named missing in models.py');
+    });
+  });
+});
@@ -0,0 +1,170 @@
+/**
+ * Pin tools - the working set of a session
+ * An agent pins the files, or symbols in them, it is working on; search_code
+ * can then search only the pinned files or list their matches first. Pins
+ * last as long as the session and are never written to disk
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { uriToPath } from '../protocol/uri.js';
+import { ToolError } from '../workspace/errors.js';
+import { sharedOverlay } from '../workspace/overlay.js';
+import { findWorkspaceSymbols, getFileSymbols } from './symbols.js';
+
+/**
+ * Most pins a session holds
+ */
+export const MAX_PINS = 200;
+
+/**
+ * Most definitions pinned for a symbol name given without a file
+ */
+const MAX_SYMBOL_MATCHES = 10;
+
+/**
+ * A pinned file, or a symbol in it
+ */
+export interface Pin {
+  filePath: string; // Relative to the workspace
+  symbol?: string;
+  line?: number; // 1-indexed line of the symbol's name
+}
+
+/**
+ * The pins of one session, in the order they were pinned
+ */
+export class PinSet {
+  private pins: Pin[] = [];
+
+  /**
+   * Pin a file or symbol
+   * Returns false when it was already pinned
+   */
+  pin(pin: Pin): boolean {
+    if (this.pins.some((p) => p.filePath === pin.filePath && p.symbol === pin.symbol)) {
+      return false;
+    }
+    if (this.pins.length >= MAX_PINS) {
+      throw new ToolError('invalid-argument', `At most ${MAX_PINS} pins are kept; unpin some first`);
+    }
+    this.pins.push(pin);
+    return true;
+  }
+
+  /**
+   * Unpin a file with its symbols, one symbol of a file, or a symbol in any file
+   * Returns the pins removed
+   */
+  unpin(filePath?: string, symbol?: string): Pin[] {
+    const removed = this.pins.filter((p) =>
+      (filePath === undefined || p.filePath === filePath) && (symbol === undefined || p.symbol === symbol));
+    this.pins = this.pins.filter((p) => !removed.includes(p));
+    return removed;
+  }
+
+  /**
+   * Unpin everything, returning the number of pins removed
+   */
+  clear(): number {
+    const count = this.pins.length;
+    this.pins = [];
+    return count;
+  }
+
+  list(): Pin[] {
+    return [...this.pins];
+  }
+
+  /**
+   * Distinct pinned files, in pin order
+   */
+  files(): string[] {
+    return Array.from(new Set(this.pins.map((p) => p.filePath)));
+  }
+
+  size(): number {
+    return this.pins.length;
+  }
+}
+
+/**
+ * Resolve what to pin: a file, a symbol in a file, or the definitions of a
+ * symbol anywhere in the workspace
+ */
+export async function resolvePins(
+  workspaceDir: string,
+  client: LSPClient | undefined,
+  filePath: string | undefined,
+  symbolName: string | undefined
+): Promise<Pin[]> {
+  if (filePath) {
+    if (!fs.existsSync(filePath) && !sharedOverlay().has(filePath)) {
+      throw new ToolError('not-found', `File ${path.relative(workspaceDir, filePath)} does not exist`);
+    }
+    const relativePath = path.relative(workspaceDir, filePath);
+    if (!symbolName) {
+      return [{ filePath: relativePath }];
+    }
+    const found = (await getFileSymbols(client, workspaceDir, filePath))
+      .filter((s) => s.name === symbolName || s.qualifiedName === symbolName);
+    if (found.length === 0) {
+      throw new ToolError('not-found', `No symbol named ${symbolName} in ${relativePath}`);
+    }
+    return found.map((s) => ({ filePath: relativePath, symbol: s.qualifiedName, line: s.selectionRange.start.line + 1 }));
+  }
+
+  if (!symbolName) {
+    throw new ToolError('invalid-argument', 'filePath or symbolName is required');
+  }
+  const pins: Pin[] = [];
+  for (const sym of await findWorkspaceSymbols(client, workspaceDir, symbolName)) {
+    const qualified = sym.containerName ? `${sym.containerName}.${sym.name}` : sym.name;
+    if (sym.name !== symbolName && qualified !== symbolName) {
+      continue;
+    }
+    const relativePath = path.relative(workspaceDir, uriToPath(sym.location.uri));
+    if (relativePath.startsWith('..') || path.isAbsolute(relativePath)) {
+      continue;
+    }
+    const line = 'range' in sym.location ? sym.location.range.start.line + 1 : undefined;
+    pins.push({ filePath: relativePath, symbol: qualified, line });
+    if (pins.length >= MAX_SYMBOL_MATCHES) {
+      break;
+    }
+  }
+  if (pins.length === 0) {
+    throw new ToolError('not-found', `No symbol named ${symbolName} in the workspace`);
+  }
+  return pins;
+}
+
+/**
+ * "file: symbol (line N)" description of a pin
+ */
+export function describePin(pin: Pin): string {
+  if (!pin.symbol) {
+    return pin.filePath;
+  }
+  return `${pin.symbol} in ${pin.filePath}` + (pin.line ? `:${pin.line}` : '');
+}
+
+/**
+ * List pins grouped by file
+ */
+export function formatPins(pins: Pin[]): string {
+  if (pins.length === 0) {
+    return 'Nothing is pinned; pin files or symbols with pin_file\n';
+  }
+  const byFile = new Map<string, Pin[]>();
+  for (const pin of pins) {
+    byFile.set(pin.filePath, [...(byFile.get(pin.filePath) ?? []), pin]);
+  }
+  let output = `Pinned: ${byFile.size} file(s), ${pins.length} pin(s)\n`;
+  for (const [filePath, filePins] of byFile) {
+    const symbols = filePins.filter((p) => p.symbol).map((p) => p.line ? `${p.symbol} (line ${p.line})` : p.symbol!);
+    output += symbols.length > 0 ? `- ${filePath}: ${symbols.join(', ')}\n` : `- ${filePath}\n`;
+  }
+  return output;
+}
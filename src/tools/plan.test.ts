@@ -0,0 +1,101 @@
+/**
+ * Tests for the search planner and glob matching
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { planSearch, detectIntent, keywordRegex } from './plan';
+import { globToRegExp, matchesGlob } from '../workspace/glob';
+
+describe('Search planner', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'plan-'));
+    fs.mkdirSync(path.join(workspace, 'src', 'server'), { recursive: true });
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  describe('detectIntent', () => {
+    it('should recognize tool-specific requests', () => {
+      expect(detectIntent('list the TODOs', false, false)).toBe('todos');
+      expect(detectIntent('find copy-pasted code', false, false)).toBe('duplicates');
+      expect(detectIntent('show the public API', false, false)).toBe('api');
+    });
+
+    it('should only pick navigation intents when an identifier is named', () => {
+      expect(detectIntent('who calls parseConfig', true, false)).toBe('references');
+      expect(detectIntent('where is parseConfig defined', true, false)).toBe('definition');
+      expect(detectIntent('where is config defined', false, false)).toBe('concept');
+    });
+  });
+
+  describe('keywordRegex', () => {
+    it('should match keyword variants in any order', () => {
+      const regex = new RegExp(keywordRegex(['validate', 'email']), 'i');
+      expect(regex.test('if (!isValidEmail(addr))')).toBe(true);
+      expect(regex.test('// email validation')).toBe(true);
+      expect(regex.test('sendEmail(addr)')).toBe(false);
+    });
+  });
+
+  describe('planSearch', () => {
+    it('should scope conceptual requests by language and path', () => {
+      const plan = planSearch(workspace, 'where do we validate email addresses in go code under src/server');
+      expect(plan.intent).toBe('concept');
+      expect(plan.keywords).toEqual(['validate', 'email', 'addresses']);
+      expect(plan.paths).toEqual(['src/server']);
+      expect(plan.steps).toHaveLength(1);
+      expect(plan.steps[0].tool).toBe('search_code');
+      expect(plan.steps[0].arguments).toMatchObject({ regex: true, path: 'src/server', glob: ['**/*.go'] });
+    });
+
+    it('should add a hybrid semantic step when enabled', () => {
+      const plan = planSearch(workspace, 'retry failed uploads', { semanticEnabled: true });
+      expect(plan.steps.map((s) => s.tool)).toEqual(['semantic_search', 'search_code']);
+      expect(plan.steps[0].arguments).toMatchObject({ mode: 'hybrid' });
+    });
+
+    it('should plan reference lookups for named identifiers', () => {
+      const plan = planSearch(workspace, 'who calls UserService.addUser');
+      expect(plan.intent).toBe('references');
+      expect(plan.steps[0]).toMatchObject({ tool: 'references', arguments: { symbolName: 'UserService.addUser' } });
+      expect(plan.steps[1].arguments).toMatchObject({ pattern: 'UserService.addUser', wholeWord: true });
+    });
+
+    it('should search quoted text literally and collect symbol kinds', () => {
+      const plan = planSearch(workspace, 'function that logs "connection refused"');
+      expect(plan.literals).toEqual(['connection refused']);
+      expect(plan.symbolKinds).toEqual(['Function']);
+      expect(plan.steps[0].arguments).toEqual({ pattern: 'connection refused' });
+    });
+  });
+});
+
+describe('Glob matching', () => {
+  it('should translate glob syntax', () => {
+    expect(globToRegExp('src/**/*.ts').test('src/a/b/c.ts')).toBe(true);
+    expect(globToRegExp('src/**/*.ts').test('src/c.ts')).toBe(true);
+    expect(globToRegExp('*.{js,ts}').test('a.ts')).toBe(true);
+    expect(globToRegExp('file?.[ch]').test('file1.h')).toBe(true);
+    expect(globToRegExp('*.ts').test('a/b.ts')).toBe(false);
+    expect(globToRegExp('*.{js,{ts,tsx}}').test('a.tsx')).toBe(true);
+  });
+
+  it('should match unbalanced braces literally', () => {
+    expect(globToRegExp('a}.ts').test('a}.ts')).toBe(true);
+    expect(globToRegExp('{a,b.ts').test('{a,b.ts')).toBe(true);
+    expect(globToRegExp('{a,b}}.ts').test('b}.ts')).toBe(true);
+    expect(matchesGlob('src/x}', 'src/*}')).toBe(true);
+  });
+
+  it('should match slash-free globs against the file name', () => {
+    expect(matchesGlob('pkg/server/main.go', '*.go')).toBe(true);
+    expect(matchesGlob('pkg/server/main.go', ['src/**', '**/*.go'])).toBe(true);
+    expect(matchesGlob('pkg/server/main.go', 'src/**')).toBe(false);
+  });
+});
@@ -0,0 +1,331 @@
+/**
+ * Plan search tool - translate a natural-language request into a structured query
+ * The plan is returned for the client to execute or refine; nothing is searched here
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { isStopWord, stemWord } from '../semantic/embeddings.js';
+import { escapeRegExp } from '../search/lexical.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * What the request is asking for
+ */
+export type SearchIntent =
+  | 'definition'
+  | 'references'
+  | 'todos'
+  | 'duplicates'
+  | 'api'
+  | 'text'
+  | 'concept';
+
+/**
+ * A suggested tool call
+ */
+export interface PlanStep {
+  tool: string;
+  arguments: Record<string, unknown>;
+  reason: string;
+}
+
+/**
+ * Structured query derived from the request
+ */
+export interface SearchPlan {
+  request: string;
+  intent: SearchIntent;
+  literals: string[];
+  identifiers: string[];
+  keywords: string[];
+  globs: string[];
+  paths: string[];
+  symbolKinds: string[];
+  steps: PlanStep[];
+}
+
+/**
+ * Planner options
+ */
+export interface PlanOptions {
+  semanticEnabled?: boolean;
+}
+
+/**
+ * Words naming a symbol kind, mapped to LSP SymbolKind names
+ */
+const KIND_WORDS: Record<string, string> = {
+  function: 'Function',
+  functions: 'Function',
+  func: 'Function',
+  method: 'Method',
+  methods: 'Method',
+  class: 'Class',
+  classes: 'Class',
+  interface: 'Interface',
+  interfaces: 'Interface',
+  struct: 'Struct',
+  structs: 'Struct',
+  enum: 'Enum',
+  enums: 'Enum',
+  constant: 'Constant',
+  constants: 'Constant',
+  variable: 'Variable',
+  variables: 'Variable',
+  field: 'Field',
+  fields: 'Field',
+  constructor: 'Constructor',
+  constructors: 'Constructor',
+  type: 'Class',
+  types: 'Class',
+};
+
+/**
+ * Language names mapped to file globs
+ */
+const LANGUAGE_GLOBS: Record<string, string[]> = {
+  go: ['**/*.go'],
+  golang: ['**/*.go'],
+  python: ['**/*.py'],
+  typescript: ['**/*.ts', '**/*.tsx'],
+  javascript: ['**/*.js', '**/*.jsx', '**/*.mjs', '**/*.cjs'],
+  java: ['**/*.java'],
+  rust: ['**/*.rs'],
+  ruby: ['**/*.rb'],
+  kotlin: ['**/*.kt'],
+  csharp: ['**/*.cs'],
+  'c#': ['**/*.cs'],
+  'c++': ['**/*.cpp', '**/*.cc', '**/*.hpp', '**/*.h'],
+  shell: ['**/*.sh'],
+};
+
+/**
+ * Globs matching test files
+ */
+const TEST_GLOBS = ['**/*_test.go', '**/*.test.*', '**/*.spec.*', '**/test_*.py', '**/__tests__/**'];
+
+/**
+ * Filler words of search requests, beyond the generic stop words
+ */
+const REQUEST_WORDS = new Set([
+  'find', 'show', 'search', 'look', 'locate', 'list', 'get', 'all', 'any', 'every', 'code', 'file', 'files',
+  'called', 'named', 'usage', 'usages', 'used', 'uses', 'use', 'defined', 'define', 'definition', 'implemented',
+  'implementation', 'references', 'reference', 'calls', 'call', 'callers', 'who', 'there', 'are', 'our', 'me',
+  'place', 'places', 'handle', 'handled', 'happen', 'happens', 'does', 'can', 'should', 'into', 'from', 'under', 'inside', 'within', 'test', 'tests',
+  ...Object.keys(KIND_WORDS),
+  ...Object.keys(LANGUAGE_GLOBS),
+]);
+
+/**
+ * Check if a word looks like a code identifier rather than an English word
+ */
+function isIdentifierLike(word: string): boolean {
+  return /[a-z][A-Z]|[A-Z]{2,}[a-z]|_|\d/.test(word) || /^[A-Z][a-z]+[A-Z]/.test(word) || /\.\w/.test(word);
+}
+
+/**
+ * Detect the intent of a request from its phrasing
+ */
+export function detectIntent(request: string, hasIdentifiers: boolean, hasLiterals: boolean): SearchIntent {
+  const text = request.toLowerCase();
+  if (/\b(todo|fixme|hack)s?\b/.test(text)) {
+    return 'todos';
+  }
+  if (/\b(duplicat\w*|copy[- ]?past\w*|cloned?)\b/.test(text)) {
+    return 'duplicates';
+  }
+  if (/\b(public|exported)\b.*\b(api|symbols?|functions?|surface)\b|\bapi surface\b/.test(text)) {
+    return 'api';
+  }
+  if (hasIdentifiers && /\b(who|what|where)\b.*\b(calls?|uses?|used|references?)\b|\b(callers|usages?|references)\b/.test(text)) {
+    return 'references';
+  }
+  if (hasIdentifiers && /\b(defined?|definition|declared?|implement\w*)\b/.test(text)) {
+    return 'definition';
+  }
+  if (hasLiterals || hasIdentifiers) {
+    return 'text';
+  }
+  return 'concept';
+}
+
+/**
+ * Build a regex that matches lines mentioning all keywords, in any order
+ * Keywords are stemmed so variants ("validate", "validation") match too
+ */
+export function keywordRegex(keywords: string[]): string {
+  const stems = keywords.slice(0, 3).map((k) => escapeRegExp(stemWord(k.toLowerCase())) + '\\w*');
+  if (stems.length <= 1) {
+    return stems[0] || '';
+  }
+  if (stems.length === 2) {
+    return `${stems[0]}.*${stems[1]}|${stems[1]}.*${stems[0]}`;
+  }
+  // Three keywords: require any two of them on the same line
+  const pairs: string[] = [];
+  for (let i = 0; i < stems.length; i++) {
+    for (let j = 0; j < stems.length; j++) {
+      if (i !== j) {
+        pairs.push(`${stems[i]}.*${stems[j]}`);
+      }
+    }
+  }
+  return pairs.join('|');
+}
+
+/**
+ * Translate a natural-language request into a search plan
+ */
+export function planSearch(workspaceDir: string, request: string, options: PlanOptions = {}): SearchPlan {
+  const literals = Array.from(request.matchAll(/["'`]([^"'`]+)["'`]/g)).map((m) => m[1]);
+  const unquoted = request.replace(/["'`][^"'`]+["'`]/g, ' ');
+
+  const words = unquoted.match(/[\w.-]+\/[\w./-]*|[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)*/g) || [];
+  const identifiers: string[] = [];
+  const keywords: string[] = [];
+  const paths: string[] = [];
+  const globs = new Set<string>();
+  const symbolKinds = new Set<string>();
+
+  for (const word of words) {
+    const lower = word.toLowerCase();
+
+    // Existing workspace paths scope the search
+    if (word.includes('/') || (word.length > 2 && !isIdentifierLike(word))) {
+      const candidate = path.join(workspaceDir, word);
+      if (fs.existsSync(candidate) && fs.statSync(candidate).isDirectory() && word !== '.') {
+        paths.push(word.replace(/\/$/, ''));
+        continue;
+      }
+    }
+    if (word.includes('/')) {
+      continue;
+    }
+
+    if (KIND_WORDS[lower]) {
+      symbolKinds.add(KIND_WORDS[lower]);
+    }
+    if (LANGUAGE_GLOBS[lower]) {
+      LANGUAGE_GLOBS[lower].forEach((g) => globs.add(g));
+    }
+    if (lower === 'test' || lower === 'tests') {
+      TEST_GLOBS.forEach((g) => globs.add(g));
+    }
+
+    if (isIdentifierLike(word)) {
+      identifiers.push(word);
+    } else if (word.length > 2 && !isStopWord(lower) && !REQUEST_WORDS.has(lower)) {
+      keywords.push(lower);
+    }
+  }
+
+  const intent = detectIntent(request, identifiers.length > 0, literals.length > 0);
+  const scope: Record<string, unknown> = {};
+  if (paths.length > 0) {
+    scope.path = paths[0];
+  }
+  const globList = Array.from(globs);
+  if (globList.length > 0) {
+    scope.glob = globList;
+  }
+
+  const steps: PlanStep[] = [];
+  const uniqueIdentifiers = Array.from(new Set(identifiers));
+
+  switch (intent) {
+    case 'todos':
+      steps.push({ tool: 'todo_comments', arguments: { ...(scope.path ? { path: scope.path } : {}) }, reason: 'Request asks for TODO-style comments' });
+      break;
+    case 'duplicates':
+      steps.push({ tool: 'find_duplicates', arguments: { ...(scope.path ? { path: scope.path } : {}) }, reason: 'Request asks for duplicated code' });
+      break;
+    case 'api':
+      steps.push({ tool: 'api_surface', arguments: { path: scope.path || '.', recursive: !scope.path }, reason: 'Request asks for the exported API' });
+      break;
+    case 'definition':
+      for (const id of uniqueIdentifiers) {
+        steps.push({ tool: 'definition', arguments: { symbolName: id }, reason: `Read the definition of ${id}` });
+      }
+      break;
+    case 'references':
+      for (const id of uniqueIdentifiers) {
+        steps.push({ tool: 'references', arguments: { symbolName: id }, reason: `Find usages of ${id}` });
+      }
+      break;
+    default:
+      break;
+  }
+
+  // Text searches back up every intent that names something concrete
+  for (const literal of literals) {
+    steps.push({ tool: 'search_code', arguments: { pattern: literal, ...scope }, reason: `Exact text "${literal}" was quoted` });
+  }
+  if (intent === 'text' || intent === 'definition' || intent === 'references') {
+    for (const id of uniqueIdentifiers) {
+      steps.push({
+        tool: 'search_code',
+        arguments: { pattern: id, wholeWord: true, caseSensitive: true, ...scope },
+        reason: `Identifier ${id} appears in the request`,
+      });
+    }
+  }
+
+  if (keywords.length > 0 && (intent === 'concept' || intent === 'text')) {
+    if (options.semanticEnabled) {
+      steps.push({
+        tool: 'semantic_search',
+        arguments: { query: request, mode: 'hybrid', ...(scope.path ? { path: scope.path } : {}) },
+        reason: 'Conceptual request; ranks code by meaning with exact matches first',
+      });
+    }
+    steps.push({
+      tool: 'search_code',
+      arguments: { pattern: keywordRegex(keywords), regex: true, ...scope },
+      reason: `Lines mentioning ${keywords.slice(0, 3).join(', ')} (word variants included)`,
+    });
+  }
+
+  toolsLogger.debug('Planned %s search with %d step(s)', intent, steps.length);
+
+  return {
+    request,
+    intent,
+    literals,
+    identifiers: uniqueIdentifiers,
+    keywords,
+    globs: globList,
+    paths,
+    symbolKinds: Array.from(symbolKinds),
+    steps,
+  };
+}
+
+/**
+ * Format a search plan for the client
+ */
+export function formatSearchPlan(plan: SearchPlan): string {
+  let output = `Search plan (intent: ${plan.intent})\n\n`;
+
+  if (plan.identifiers.length > 0) output += `Identifiers: ${plan.identifiers.join(', ')}\n`;
+  if (plan.literals.length > 0) output += `Literals: ${plan.literals.map((l) => `"${l}"`).join(', ')}\n`;
+  if (plan.keywords.length > 0) output += `Keywords: ${plan.keywords.join(', ')}\n`;
+  if (plan.paths.length > 0) output += `Paths: ${plan.paths.join(', ')}\n`;
+  if (plan.globs.length > 0) output += `Globs: ${plan.globs.join(', ')}\n`;
+  if (plan.symbolKinds.length > 0) output += `Symbol kinds: ${plan.symbolKinds.join(', ')}\n`;
+
+  if (plan.steps.length === 0) {
+    output += '\nNo concrete query could be derived; rephrase with identifiers, quoted text, or keywords.\n';
+    return output;
+  }
+
+  output += '\n';
+  plan.steps.forEach((step, i) => {
+    output += `---\n\n${i + 1}. ${step.tool}\n   ${step.reason}\n   ${JSON.stringify(step.arguments)}\n\n`;
+  });
+
+  output += '---\n\nPlan JSON:\n' + JSON.stringify(plan, null, 2) + '\n';
+  return output;
+}
@@ -0,0 +1,93 @@
+/**
+ * Tests for plugin tools
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { PluginTool } from '../config/config';
+import { withCancellation } from '../workspace/cancellation';
+import { pluginDisabledReason, pluginToolSchema, runPluginTool } from './plugins';
+
+/**
+ * A plugin that answers with its request, or as its first argument says
+ */
+const PLUGIN = `
+let input = '';
+process.stdin.on('data', (chunk) => { input += chunk; });
+process.stdin.on('end', () => {
+  const request = JSON.parse(input);
+  const mode = process.argv[2];
+  if (mode === 'missing') {
+    console.log(JSON.stringify({ error: 'not-found', message: 'no service named ' + request.arguments.service }));
+  } else if (mode === 'fail') {
+    console.error('catalog unreachable');
+    process.exit(2);
+  } else if (mode === 'hang') {
+    setTimeout(() => undefined, 60000);
+  } else {
+    console.log(request.tool + ' ' + request.arguments.service + ' in ' + process.env.GREPFORCODE_WORKSPACE + ' from ' + process.cwd());
+  }
+});
+`;
+
+describe('plugin tools', () => {
+  let workspace: string;
+  let script: string;
+  const tool = (mode: string, overrides: Partial<PluginTool> = {}): PluginTool => ({
+    name: 'service_owner',
+    command: process.execPath,
+    args: [script, mode],
+    parameters: [{ name: 'service', type: 'string', description: 'Service name', required: true }],
+    ...overrides,
+  });
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'plugins-')));
+    script = path.join(workspace, 'plugin.js');
+    fs.writeFileSync(script, PLUGIN);
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should describe the tool by its parameters', () => {
+    expect(pluginToolSchema(tool('echo'))).toEqual({
+      name: 'service_owner',
+      description: `Organization tool served by ${process.execPath}`,
+      inputSchema: { type: 'object', properties: { service: { type: 'string', description: 'Service name' } }, required: ['service'] },
+    });
+  });
+
+  it('should refuse plugins in read-only mode unless they declare readOnly', () => {
+    expect(pluginDisabledReason(tool('echo'), true)).toContain('does not declare readOnly: true');
+    expect(pluginDisabledReason(tool('echo', { readOnly: true }), true)).toBeUndefined();
+    expect(pluginDisabledReason(tool('echo'), false)).toBeUndefined();
+  });
+
+  it('should pass the request on stdin and return what the command prints', async () => {
+    expect(await runPluginTool(tool('echo'), { service: 'billing' }, workspace))
+      .toBe(`service_owner billing in ${workspace} from ${workspace}`);
+    await expect(runPluginTool(tool('echo'), {}, workspace)).rejects.toThrow('service is required');
+    await expect(runPluginTool(tool('echo'), { service: 3 }, workspace)).rejects.toThrow('service must be a string');
+  });
+
+  it('should fail with the code the command reports, or its last error line', async () => {
+    await expect(runPluginTool(tool('missing'), { service: 'x' }, workspace)).rejects.toThrow('no service named x');
+    const reported = await runPluginTool(tool('missing'), { service: 'x' }, workspace).catch((err) => err);
+    expect(reported.code).toBe('not-found');
+    await expect(runPluginTool(tool('fail'), { service: 'x' }, workspace)).rejects.toThrow('Plugin tool service_owner failed: catalog unreachable');
+  });
+
+  it('should stop the command when it times out or the call is cancelled', async () => {
+    const timedOut = await runPluginTool(tool('hang', { timeoutMs: 200 }), { service: 'x' }, workspace).catch((err) => err);
+    expect(timedOut.code).toBe('timeout');
+
+    const controller = new AbortController();
+    const running = withCancellation(controller.signal, () => runPluginTool(tool('hang'), { service: 'x' }, workspace));
+    setTimeout(() => controller.abort(), 100);
+    const cancelled = await running.catch((err) => err);
+    expect(cancelled.code).toBe('cancelled');
+  });
+});
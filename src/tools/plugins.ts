@@ -0,0 +1,139 @@
+/**
+ * Plugin tools - organization-specific tools served by running a command
+ * Tools declared under plugins in the global configuration are offered next
+ * to the built-in ones. Each call runs the command with a JSON request on
+ * stdin, {"tool", "arguments", "workspace"}, and its stdout is the result,
+ * so a service catalog lookup or an internal search can be added in any
+ * language without forking the server. A command that prints
+ * {"error": code, "message"} fails the call with that code
+ */
+
+import { execFile } from 'child_process';
+import { createLogger, Component } from '../logging/logger.js';
+import { PluginTool } from '../config/config.js';
+import { currentSignal, throwIfCancelled } from '../workspace/cancellation.js';
+import { TOOL_ERROR_CODES, ToolError, ToolErrorCode } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Time a plugin command gets unless its timeoutMs says otherwise
+ */
+const DEFAULT_PLUGIN_TIMEOUT_MS = 30000;
+
+/**
+ * Most output read from a plugin command
+ */
+const MAX_PLUGIN_OUTPUT = 4 * 1024 * 1024;
+
+/**
+ * Why read-only mode leaves a plugin tool out, or undefined when it is offered
+ * A command can do anything, so only tools declaring readOnly: true are kept
+ */
+export function pluginDisabledReason(tool: PluginTool, readOnly: boolean | undefined): string | undefined {
+  return readOnly && !tool.readOnly ? 'the server runs in read-only mode and the plugin does not declare readOnly: true' : undefined;
+}
+
+/**
+ * MCP schema of a plugin tool
+ */
+export function pluginToolSchema(tool: PluginTool): any {
+  const properties: Record<string, unknown> = {};
+  for (const parameter of tool.parameters) {
+    properties[parameter.name] = parameter.description
+      ? { type: parameter.type, description: parameter.description }
+      : { type: parameter.type };
+  }
+  const required = tool.parameters.filter((parameter) => parameter.required).map((parameter) => parameter.name);
+  return {
+    name: tool.name,
+    description: tool.description ?? `Organization tool served by ${tool.command}`,
+    inputSchema: { type: 'object', properties, ...(required.length > 0 ? { required } : {}) },
+  };
+}
+
+/**
+ * Check the arguments of a call against the tool's parameters
+ */
+function checkArguments(tool: PluginTool, args: Record<string, unknown>): void {
+  for (const parameter of tool.parameters) {
+    const value = args[parameter.name];
+    if (value === undefined || value === null || value === '') {
+      if (parameter.required) {
+        throw new ToolError('invalid-argument', `${parameter.name} is required`);
+      }
+      continue;
+    }
+    if (typeof value !== parameter.type) {
+      throw new ToolError('invalid-argument', `${parameter.name} must be a ${parameter.type}`);
+    }
+  }
+}
+
+/**
+ * An error the command reported as {"error": code, "message"}, if its output is one
+ */
+function reportedError(output: string): ToolError | undefined {
+  if (!output.trimStart().startsWith('{')) {
+    return undefined;
+  }
+  try {
+    const parsed = JSON.parse(output);
+    if (typeof parsed?.error === 'string' && typeof parsed.message === 'string') {
+      const code = (TOOL_ERROR_CODES as readonly string[]).includes(parsed.error) ? parsed.error as ToolErrorCode : 'internal';
+      return new ToolError(code, parsed.message);
+    }
+  } catch {
+    // Output that merely starts like JSON is a result like any other
+  }
+  return undefined;
+}
+
+/**
+ * Run a plugin tool's command for one call and return what it printed
+ * The command runs in the workspace with GREPFORCODE_TOOL and
+ * GREPFORCODE_WORKSPACE set, and is stopped when the call is cancelled or
+ * its time runs out
+ */
+export async function runPluginTool(tool: PluginTool, args: Record<string, unknown>, workspaceDir: string): Promise<string> {
+  checkArguments(tool, args);
+  throwIfCancelled();
+  const timeoutMs = tool.timeoutMs ?? DEFAULT_PLUGIN_TIMEOUT_MS;
+  const request = JSON.stringify({ tool: tool.name, arguments: args, workspace: workspaceDir });
+  toolsLogger.debug('Running plugin tool %s: %s %s', tool.name, tool.command, tool.args.join(' '));
+
+  const signal = currentSignal();
+  return new Promise<string>((resolve, reject) => {
+    const child = execFile(tool.command, tool.args, {
+      cwd: workspaceDir,
+      env: { ...process.env, GREPFORCODE_TOOL: tool.name, GREPFORCODE_WORKSPACE: workspaceDir },
+      timeout: timeoutMs,
+      maxBuffer: MAX_PLUGIN_OUTPUT,
+      signal,
+    }, (err, stdout, stderr) => {
+      const reported = reportedError(stdout);
+      if (reported) {
+        reject(reported);
+        return;
+      }
+      if (err) {
+        try {
+          throwIfCancelled(signal);
+        } catch (cancelled) {
+          reject(cancelled);
+          return;
+        }
+        const detail = stderr.trim().split('\n').pop() || err.message;
+        toolsLogger.debug('Plugin tool %s failed: %s', tool.name, stderr || err.message);
+        reject((err as { killed?: boolean }).killed
+          ? new ToolError('timeout', `Plugin tool ${tool.name} timed out after ${timeoutMs}ms`)
+          : new Error(`Plugin tool ${tool.name} failed: ${detail}`));
+        return;
+      }
+      resolve(stdout.trimEnd() || `${tool.name} returned no output`);
+    });
+    // A command that does not read its request must not fail the call
+    child.stdin?.on('error', () => undefined);
+    child.stdin?.end(request);
+  });
+}
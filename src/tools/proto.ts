@@ -0,0 +1,171 @@
+/**
+ * Proto references tool - usages of a proto symbol in generated Go and TypeScript code
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { DocumentSymbol, SymbolKind, SymbolKindNames } from '../protocol/types.js';
+import { searchLexical, LexicalMatch } from '../search/lexical.js';
+import { ProtoFile, ProtoSymbolRef, generatedNames, isProtoFile, parseProto } from '../symbols/proto.js';
+import { GeneratedFileDetector } from '../workspace/generated.js';
+import { readFileText } from '../workspace/overlay.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { formatMatchSections } from './search.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * File globs searched for each generated language
+ */
+const LANGUAGE_GLOBS: Record<'go' | 'typescript', string[]> = {
+  go: ['**/*.go'],
+  typescript: ['**/*.ts', '**/*.tsx', '**/*.js', '**/*.mjs'],
+};
+
+/**
+ * Definitions resolved from at most this many proto symbols are reported
+ */
+const MAX_DEFINITIONS = 5;
+
+/**
+ * Options for a proto references query
+ */
+export interface ProtoReferencesOptions {
+  // Only search under this path (proto files and generated code alike)
+  path?: string;
+  maxResults?: number;
+}
+
+/**
+ * A proto symbol matching the query
+ */
+interface ProtoDefinition extends ProtoSymbolRef {
+  filePath: string;
+  symbol: DocumentSymbol;
+}
+
+/**
+ * Find the proto symbols a name refers to: a qualified name
+ * (acme.v1.UserService.GetUser), or any dotted suffix of one (UserService.GetUser)
+ */
+async function findProtoDefinitions(workspaceDir: string, symbolName: string, pathPrefix?: string): Promise<ProtoDefinition[]> {
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix })).filter((file) => isProtoFile(file.relativePath));
+  const definitions: ProtoDefinition[] = [];
+
+  for (const file of files) {
+    let parsed: ProtoFile;
+    try {
+      parsed = parseProto(await readFileText(file.absolutePath));
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', file.relativePath, err);
+      continue;
+    }
+    const visit = (symbol: DocumentSymbol, names: string[], kinds: SymbolKind[]) => {
+      const qualified = [parsed.package, ...names].filter(Boolean).join('.');
+      if (qualified === symbolName || qualified.endsWith(`.${symbolName}`)) {
+        definitions.push({ filePath: file.relativePath, symbol, package: parsed.package, path: names, kinds });
+      }
+      for (const child of symbol.children ?? []) {
+        visit(child, [...names, child.name], [...kinds, child.kind]);
+      }
+    };
+    parsed.symbols.forEach((symbol) => visit(symbol, [symbol.name], [symbol.kind]));
+  }
+  return definitions;
+}
+
+/**
+ * Matches of a definition's generated identifiers, split into generated code
+ * and hand-written usages
+ */
+async function findGeneratedUsages(
+  workspaceDir: string,
+  definition: ProtoDefinition,
+  options: ProtoReferencesOptions
+): Promise<{ generated: LexicalMatch[]; usages: LexicalMatch[]; truncated: boolean }> {
+  const names = generatedNames(definition);
+  const requires = names.requires ? new RegExp(names.requires) : undefined;
+  const detector = new GeneratedFileDetector(workspaceDir);
+  const generated: LexicalMatch[] = [];
+  const usages: LexicalMatch[] = [];
+  let truncated = false;
+
+  for (const language of ['go', 'typescript'] as const) {
+    const patterns = names[language];
+    if (patterns.length === 0) {
+      continue;
+    }
+    const result = await searchLexical(workspaceDir, patterns.map((pattern) => `(?:${pattern})`).join('|'), {
+      regex: true,
+      caseSensitive: true,
+      glob: LANGUAGE_GLOBS[language],
+      path: options.path,
+      includeGenerated: true,
+      maxResults: options.maxResults,
+    });
+    truncated = truncated || result.truncated;
+
+    // Each file is read once to classify it and check the required context
+    const files = new Map<string, { generated: boolean; relevant: boolean }>();
+    for (const match of result.matches) {
+      let file = files.get(match.filePath);
+      if (!file) {
+        const content = await readFileText(path.join(workspaceDir, match.filePath));
+        file = { generated: detector.isGenerated(match.filePath, content), relevant: !requires || requires.test(content) };
+        files.set(match.filePath, file);
+      }
+      if (file.relevant) {
+        (file.generated ? generated : usages).push(match);
+      }
+    }
+  }
+  return { generated, usages, truncated };
+}
+
+/**
+ * Report where a proto symbol is defined, the generated code for it, and the
+ * code that uses it
+ */
+export async function protoReferences(
+  workspaceDir: string,
+  symbolName: string,
+  options: ProtoReferencesOptions = {}
+): Promise<string> {
+  const pathPrefix = options.path ? resolveWorkspacePath(workspaceDir, options.path) : undefined;
+  const definitions = await findProtoDefinitions(workspaceDir, symbolName, pathPrefix);
+  if (definitions.length === 0) {
+    return `No proto symbol named ${symbolName}`;
+  }
+
+  let output = '';
+  for (const definition of definitions.slice(0, MAX_DEFINITIONS)) {
+    const { symbol } = definition;
+    const qualified = [definition.package, ...definition.path].filter(Boolean).join('.');
+    const start = symbol.selectionRange.start;
+    output += `${qualified} (${SymbolKindNames[symbol.kind]}${symbol.detail ? `: ${symbol.detail}` : ''})\n`;
+    output += `Defined at ${definition.filePath}:${start.line + 1}:${start.character + 1}\n`;
+
+    const { generated, usages, truncated } = await findGeneratedUsages(workspaceDir, definition, options);
+    if (generated.length === 0 && usages.length === 0) {
+      output += '\nNo generated Go or TypeScript code refers to it\n\n';
+      continue;
+    }
+    if (generated.length > 0) {
+      const byFile = new Map<string, LexicalMatch[]>();
+      for (const match of generated) {
+        byFile.set(match.filePath, [...(byFile.get(match.filePath) ?? []), match]);
+      }
+      output += `\nGenerated code (${generated.length} match(es) in ${byFile.size} file(s)):\n`;
+      for (const [filePath, matches] of byFile) {
+        output += `${filePath}:${matches[0].line}:${matches[0].column}: ${matches[0].lineText.trim()}` +
+          (matches.length > 1 ? ` (+${matches.length - 1} more)` : '') + '\n';
+      }
+    }
+    output += `\nUsages: ${usages.length}${truncated ? ' (results truncated; narrow the path)' : ''}\n\n`;
+    output += formatMatchSections(usages);
+  }
+  if (definitions.length > MAX_DEFINITIONS) {
+    output += `${definitions.length - MAX_DEFINITIONS} more proto symbol(s) match ${symbolName}; qualify the name\n`;
+  }
+  return output.trimEnd();
+}
@@ -0,0 +1,98 @@
+/**
+ * Tests for the read range tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { chunkBoundaries, currentFileHash, fileContentHash, readChunk, readRange } from './read';
+
+describe('read_range', () => {
+  let workspace: string;
+  let file: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'read-'));
+    file = path.join(workspace, 'notes.txt');
+    fs.writeFileSync(file, Array.from({ length: 12 }, (_, i) => `line ${i + 1}`).join('\n') + '\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should return numbered lines with the content hash', async () => {
+    const hash = fileContentHash(fs.readFileSync(file, 'utf8'));
+    expect(await readRange(workspace, file, 9, 10)).toBe(`notes.txt L9-10 of 12 (hash ${hash})\n\n 9: line 9\n10: line 10`);
+    expect(await readRange(workspace, file, 11)).toBe(`notes.txt L11-12 of 12 (hash ${hash})\n\n11: line 11\n12: line 12`);
+  });
+
+  it('should report ranges past the end and reject inverted ones', async () => {
+    expect(await readRange(workspace, file, 20)).toContain('notes.txt has 12 line(s); line 20 is past the end');
+    await expect(readRange(workspace, file, 5, 4)).rejects.toThrow('Invalid line range 5-4');
+  });
+
+  it('should reject lines that are not positive whole numbers', async () => {
+    for (const [startLine, endLine] of [[0, 3], [-2, undefined], [1.5, 3], [NaN, undefined], [1, 2.5], [1, 0], [1, Infinity]]) {
+      const err = await readRange(workspace, file, startLine, endLine).catch((e) => e);
+      expect(err.code).toBe('invalid-argument');
+    }
+    await expect(readRange(workspace, file, 2.5)).rejects.toThrow('startLine must be a positive whole number, got 2.5');
+  });
+
+  it('should change the hash when the file changes', async () => {
+    const before = await currentFileHash(file);
+    fs.appendFileSync(file, 'line 13\n');
+    expect(await currentFileHash(file)).not.toBe(before);
+    expect(before).toMatch(/^[0-9a-f]{16}$/);
+  });
+
+  describe('chunks', () => {
+    const lines = (count: number, blank: number[] = []) =>
+      Array.from({ length: count }, (_, i) => blank.includes(i + 1) ? '' : `x${i + 1}`);
+
+    it('should cut before top-level symbols and the comments above them', () => {
+      const symbols = [
+        { name: 'a', startLine: 2, endLine: 9, depth: 0 },
+        { name: 'b', startLine: 12, endLine: 20, depth: 0 },
+        { name: 'c', startLine: 22, endLine: 30, depth: 0 },
+      ];
+      expect(chunkBoundaries(lines(30, [10, 21]), symbols, 12)).toEqual([
+        { startLine: 1, endLine: 10 },
+        { startLine: 11, endLine: 21 },
+        { startLine: 22, endLine: 30 },
+      ]);
+    });
+
+    it('should split a symbol too long for one chunk before its members', () => {
+      const symbols = [
+        { name: 'C', startLine: 1, endLine: 40, depth: 0 },
+        { name: 'C.a', startLine: 2, endLine: 10, depth: 1 },
+        { name: 'C.b', startLine: 11, endLine: 25, depth: 1 },
+        { name: 'C.c', startLine: 26, endLine: 39, depth: 1 },
+      ];
+      expect(chunkBoundaries(lines(40), symbols, 10)).toEqual([
+        { startLine: 1, endLine: 10 },
+        { startLine: 11, endLine: 25 },
+        { startLine: 26, endLine: 39 },
+        { startLine: 40, endLine: 40 },
+      ]);
+    });
+
+    it('should cut after blank lines without symbols', () => {
+      expect(chunkBoundaries(lines(12, [4]), [], 5)).toEqual([
+        { startLine: 1, endLine: 4 },
+        { startLine: 5, endLine: 9 },
+        { startLine: 10, endLine: 12 },
+      ]);
+    });
+
+    it('should read a chunk with its symbols and the next chunk to read', async () => {
+      const hash = fileContentHash(fs.readFileSync(file, 'utf8'));
+      const symbols = [{ name: 'first', startLine: 1, endLine: 6, depth: 0 }, { name: 'second', startLine: 7, endLine: 12, depth: 0 }];
+      expect(await readChunk(workspace, file, 1, symbols, 8)).toBe(
+        `notes.txt L1-6 of 12, chunk 1 of 2 (hash ${hash})\nSymbols: first\n\n1: line 1\n2: line 2\n3: line 3\n4: line 4\n5: line 5\n6: line 6\n\nContinue with chunk: 2`);
+      expect(await readChunk(workspace, file, 3, symbols, 8)).toContain('notes.txt has 2 chunk(s); chunk 3 is past the end');
+    });
+  });
+});
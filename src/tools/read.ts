@@ -0,0 +1,204 @@
+/**
+ * Read range tool - exact lines of a file with line numbers and the file's content hash
+ * The hash lets a later edit_file call check that the file has not changed since it was read.
+ * Large files can be read in chunks that start and end at symbol boundaries,
+ * so each chunk holds whole functions and types
+ */
+
+import * as crypto from 'crypto';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { readFileText } from '../workspace/overlay.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Most lines returned by one call
+ */
+const MAX_RANGE_LINES = 2000;
+
+/**
+ * Lines a chunk aims for when none is given
+ */
+const DEFAULT_CHUNK_LINES = 200;
+
+/**
+ * A symbol's extent, 1-indexed lines inclusive, and its nesting depth (0 for top level)
+ */
+export interface SymbolSpan {
+  name: string;
+  startLine: number;
+  endLine: number;
+  depth: number;
+}
+
+/**
+ * A chunk of a file, 1-indexed lines inclusive
+ */
+export interface FileChunk {
+  startLine: number;
+  endLine: number;
+}
+
+/**
+ * Content hash of file text: the first 16 hex digits of its SHA-256
+ */
+export function fileContentHash(content: string): string {
+  return crypto.createHash('sha256').update(content).digest('hex').substring(0, 16);
+}
+
+/**
+ * Current content hash of a file (overlay content when it has one)
+ */
+export async function currentFileHash(filePath: string): Promise<string> {
+  return fileContentHash(await readFileText(filePath));
+}
+
+/**
+ * Lines of a file; a trailing newline does not start another line
+ */
+function splitLines(content: string): string[] {
+  const lines = content.split('\n');
+  if (lines.length > 1 && lines[lines.length - 1] === '') {
+    lines.pop();
+  }
+  return lines;
+}
+
+/**
+ * Lines first to last, prefixed with their right-aligned numbers
+ */
+function numberLines(lines: string[], first: number, last: number): string[] {
+  const width = String(last).length;
+  const numbered: string[] = [];
+  for (let i = first; i <= last; i++) {
+    numbered.push(`${String(i).padStart(width)}: ${lines[i - 1].replace(/\r$/, '')}`);
+  }
+  return numbered;
+}
+
+/**
+ * Return lines startLine to endLine (1-indexed, inclusive) of a file with
+ * line numbers, and the file's line count and content hash
+ */
+export async function readRange(workspaceDir: string, filePath: string, startLine = 1, endLine?: number): Promise<string> {
+  if (!Number.isInteger(startLine) || startLine < 1) {
+    throw new ToolError('invalid-argument', `startLine must be a positive whole number, got ${startLine}`);
+  }
+  if (endLine !== undefined && (!Number.isInteger(endLine) || endLine < 1)) {
+    throw new ToolError('invalid-argument', `endLine must be a positive whole number, got ${endLine}`);
+  }
+  if (endLine !== undefined && endLine < startLine) {
+    throw new ToolError('invalid-argument', `Invalid line range ${startLine}-${endLine}`);
+  }
+  const content = await readFileText(filePath);
+  const lines = splitLines(content);
+  const relativePath = path.relative(workspaceDir, filePath);
+  const hash = fileContentHash(content);
+  toolsLogger.debug('Reading %s lines %d-%s', relativePath, startLine, endLine);
+
+  if (startLine > lines.length) {
+    return `${relativePath} has ${lines.length} line(s); line ${startLine} is past the end (hash ${hash})`;
+  }
+  const last = Math.min(endLine ?? lines.length, lines.length, startLine + MAX_RANGE_LINES - 1);
+
+  const output = [`${relativePath} L${startLine}-${last} of ${lines.length} (hash ${hash})`, ''];
+  output.push(...numberLines(lines, startLine, last));
+  if (last < (endLine ?? lines.length) && last < lines.length) {
+    output.push('', `Stopped at ${MAX_RANGE_LINES} lines; continue with startLine: ${last + 1}`);
+  }
+  return output.join('\n');
+}
+
+/**
+ * Split a file into chunks of about chunkLines lines that start at symbol boundaries
+ * A chunk ends before the last top-level symbol (with its leading comments)
+ * that starts within chunkLines, or after one that ends within it; a symbol
+ * too long for that is kept whole up to twice chunkLines, and split between
+ * its members otherwise.
+ * Without a symbol to cut at, chunks end after a blank line or, failing
+ * that, at chunkLines
+ */
+export function chunkBoundaries(lines: string[], symbols: SymbolSpan[], chunkLines = DEFAULT_CHUNK_LINES): FileChunk[] {
+  const target = Math.max(1, Math.min(chunkLines, MAX_RANGE_LINES / 2));
+  // A symbol's head takes in the comments, decorators, and attributes right above it
+  const inSymbol = (line: number) => symbols.some((s) => line >= s.startLine && line <= s.endLine);
+  const head = (symbol: SymbolSpan) => {
+    let line = symbol.startLine;
+    while (line > 1 && lines[line - 2].trim() !== '' && !inSymbol(line - 1)) {
+      line--;
+    }
+    return line;
+  };
+  // Lines a chunk may start at, by depth: a symbol's head and the line after its end
+  const maxDepth = Math.max(-1, ...symbols.map((s) => s.depth));
+  const cuts: number[][] = [];
+  for (let depth = 0; depth <= maxDepth; depth++) {
+    cuts.push(symbols.filter((s) => s.depth === depth).flatMap((s) => [head(s), s.endLine + 1]));
+  }
+  const afterBlank = lines.flatMap((text, i) => text.trim() === '' && i + 2 <= lines.length ? [i + 2] : []);
+
+  const chunks: FileChunk[] = [];
+  let start = 1;
+  while (start <= lines.length) {
+    if (start + target > lines.length) {
+      chunks.push({ startLine: start, endLine: lines.length });
+      break;
+    }
+    const within = (candidates: number[], upTo: number) =>
+      Math.max(0, ...candidates.filter((line) => line > start && line <= upTo));
+    let next = 0;
+    for (let depth = 0; depth < cuts.length && !next; depth++) {
+      const candidates = cuts.slice(0, depth + 1).flat();
+      next = within(candidates, start + target);
+      if (!next && !symbols.some((s) => s.depth === depth && start > s.startLine && start <= s.endLine)) {
+        // Keep a long symbol whole when it ends soon enough, unless it is already being split
+        const following = candidates.filter((line) => line > start + target && line <= start + 2 * target);
+        next = following.length > 0 ? Math.min(...following) : 0;
+      }
+    }
+    next = next || within(afterBlank, start + target) || start + target;
+    chunks.push({ startLine: start, endLine: next - 1 });
+    start = next;
+  }
+  return chunks;
+}
+
+/**
+ * Return one chunk of a file, cut at symbol boundaries, with line numbers,
+ * the chunk count, the top-level symbols starting in it, and the file's content hash
+ */
+export async function readChunk(
+  workspaceDir: string,
+  filePath: string,
+  chunk: number,
+  symbols: SymbolSpan[],
+  chunkLines?: number
+): Promise<string> {
+  const content = await readFileText(filePath);
+  const lines = splitLines(content);
+  const relativePath = path.relative(workspaceDir, filePath);
+  const hash = fileContentHash(content);
+  const chunks = chunkBoundaries(lines, symbols, chunkLines);
+  toolsLogger.debug('Reading %s chunk %d of %d', relativePath, chunk, chunks.length);
+
+  if (!Number.isInteger(chunk) || chunk < 1) {
+    throw new ToolError('invalid-argument', `Invalid chunk ${chunk}; chunks are numbered from 1`);
+  }
+  if (chunk > chunks.length) {
+    return `${relativePath} has ${chunks.length} chunk(s); chunk ${chunk} is past the end (hash ${hash})`;
+  }
+
+  const { startLine, endLine } = chunks[chunk - 1];
+  const output = [`${relativePath} L${startLine}-${endLine} of ${lines.length}, chunk ${chunk} of ${chunks.length} (hash ${hash})`];
+  const names = symbols.filter((s) => s.depth === 0 && s.startLine >= startLine && s.startLine <= endLine).map((s) => s.name);
+  if (names.length > 0) {
+    output.push(`Symbols: ${names.join(', ')}`);
+  }
+  output.push('', ...numberLines(lines, startLine, endLine));
+  if (chunk < chunks.length) {
+    output.push('', `Continue with chunk: ${chunk + 1}`);
+  }
+  return output.join('\n');
+}
@@ -0,0 +1,69 @@
+/**
+ * Tests for the recent files tool
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { parseNameOnlyLog } from '../git/git';
+import { recentFiles } from './recent';
+
+describe('recent_files', () => {
+  let workspace: string;
+
+  const write = (file: string, ageSeconds: number) => {
+    const filePath = path.join(workspace, file);
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, `// ${file}\n`);
+    const time = Date.now() / 1000 - ageSeconds;
+    fs.utimesSync(filePath, time, time);
+  };
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'recent-'));
+    write('old.go', 3000);
+    write('src/new.ts', 10);
+    write('src/mid.go', 1000);
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should list files newest first by modification time', async () => {
+    const result = await recentFiles(workspace);
+    expect(result.split('\n')[0]).toBe('3 most recently modified of 3 files');
+    const order = result.split('\n').slice(2).map((line) => line.split('  ')[1]);
+    expect(order).toEqual([path.join('src', 'new.ts'), path.join('src', 'mid.go'), 'old.go']);
+    expect(await recentFiles(workspace, { language: 'go', limit: 1 })).toMatch(/^1 most recently modified of 2 files\n\n.*mid\.go$/);
+    expect(await recentFiles(workspace, { glob: ['*.py'] })).toBe('No matching files');
+  });
+
+  it('should order by the git log', async () => {
+    try {
+      const git = (...args: string[]) => execFileSync('git', ['-c', 'user.name=t', '-c', 'user.email=t@t', ...args], { cwd: workspace });
+      git('init', '-q');
+      git('add', 'src/new.ts', 'old.go');
+      git('commit', '-qm', 'first', '--date=2020-01-01T00:00:00Z');
+      git('add', 'old.go', 'src/mid.go');
+      fs.appendFileSync(path.join(workspace, 'old.go'), '// again\n');
+      git('add', 'old.go');
+      git('commit', '-qm', 'second', '--date=2021-01-01T00:00:00Z');
+    } catch (err) {
+      return; // git is not available
+    }
+    const result = await recentFiles(workspace, { by: 'git' });
+    const lines = result.split('\n');
+    expect(lines[0]).toBe('3 most recently committed of 3 files');
+    expect(lines[2]).toMatch(/ {2}old\.go {2}\([0-9a-f]{7} t: second\)$/);
+    expect(lines[4]).toMatch(/new\.ts {2}\([0-9a-f]{7} t: first\)$/);
+  });
+
+  it('should parse the latest commit per path', () => {
+    const log = '\x1eaaa\x1fAna\x1f200\x1fnewer\n\na.go\n\x1ebbb\x1fBo\x1f100\x1folder\n\na.go\nb.go\n';
+    const commits = parseNameOnlyLog(log);
+    expect(commits.get('a.go')).toEqual({ commit: 'aaa', author: 'Ana', authorTime: 200, summary: 'newer' });
+    expect(commits.get('b.go')?.commit).toBe('bbb');
+  });
+});
@@ -0,0 +1,99 @@
+/**
+ * Recent files tool - the most recently modified files of the workspace,
+ * by modification time or by the git log, to find the active area of a codebase
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { recentlyCommittedFiles } from '../git/git.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { matchesGlob } from '../workspace/glob.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { createLimiter } from '../workspace/pool.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the recent files tool
+ */
+export interface RecentFilesOptions {
+  path?: string;
+  glob?: string | string[];
+  // Language ID as detected for search (e.g. "go", "typescript")
+  language?: string;
+  // 'mtime' orders by file modification time; 'git' by the latest commit touching the file (default: 'mtime')
+  by?: 'mtime' | 'git';
+  // Most files listed (default: 20)
+  limit?: number;
+}
+
+/**
+ * Commits read when ordering by the git log
+ */
+const MAX_LOG_COMMITS = 1000;
+
+/**
+ * Local "YYYY-MM-DD HH:MM" of a time in milliseconds
+ */
+function formatTime(ms: number): string {
+  const date = new Date(ms);
+  const pad = (n: number) => String(n).padStart(2, '0');
+  return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())} ${pad(date.getHours())}:${pad(date.getMinutes())}`;
+}
+
+/**
+ * List the most recently modified files, newest first
+ */
+export async function recentFiles(workspaceDir: string, options: RecentFilesOptions = {}): Promise<string> {
+  const by = options.by ?? 'mtime';
+  if (by !== 'mtime' && by !== 'git') {
+    throw new ToolError('invalid-argument', `Unknown ordering "${by}"; use mtime or git`);
+  }
+  const limit = options.limit ?? 20;
+  const prefix = options.path ? resolveWorkspacePath(workspaceDir, options.path) : undefined;
+  toolsLogger.debug('Listing recent files by %s (limit: %d)', by, limit);
+
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: prefix, config: { maxFileSize: Number.MAX_SAFE_INTEGER } }))
+    .filter((file) => !options.glob || matchesGlob(file.relativePath, options.glob))
+    .filter((file) => !options.language || detectLanguageId(file.relativePath) === options.language);
+
+  let rows: Array<{ relativePath: string; time: number; note?: string }>;
+  if (by === 'git') {
+    let commits;
+    try {
+      commits = await recentlyCommittedFiles(workspaceDir, MAX_LOG_COMMITS);
+    } catch (err) {
+      throw new ToolError('unsupported', `Cannot order by git: ${(err as Error).message}`);
+    }
+    rows = files.flatMap((file) => {
+      const commit = commits.get(file.relativePath.split(path.sep).join('/'));
+      return commit ? [{
+        relativePath: file.relativePath,
+        time: commit.authorTime * 1000,
+        note: `${commit.commit.substring(0, 7)} ${commit.author}: ${commit.summary}`,
+      }] : [];
+    });
+  } else {
+    const limitStats = createLimiter(32);
+    rows = (await Promise.all(files.map((file) => limitStats(async () => {
+      try {
+        return [{ relativePath: file.relativePath, time: (await fs.promises.stat(file.absolutePath)).mtimeMs }];
+      } catch (err) {
+        return [];
+      }
+    })))).flat();
+  }
+
+  if (rows.length === 0) {
+    return by === 'git' ? `No matching files changed in the last ${MAX_LOG_COMMITS} commits` : 'No matching files';
+  }
+  rows.sort((a, b) => b.time - a.time || a.relativePath.localeCompare(b.relativePath));
+  const shown = rows.slice(0, limit);
+  const lines = [`${shown.length} most recently ${by === 'git' ? 'committed' : 'modified'} of ${rows.length} files`, ''];
+  for (const row of shown) {
+    lines.push(`${formatTime(row.time)}  ${row.relativePath}${row.note ? `  (${row.note})` : ''}`);
+  }
+  return lines.join('\n');
+}
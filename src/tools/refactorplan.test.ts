@@ -0,0 +1,79 @@
+/**
+ * Tests for the refactoring plan tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { formatRefactorPlan, planRefactor } from './refactorplan';
+
+describe('plan_refactor', () => {
+  let workspace: string;
+
+  const write = (relativePath: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+    fs.writeFileSync(path.join(workspace, relativePath), content);
+  };
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'refactorplan-')));
+    write('src/model/user.ts', 'export class UserRecord {}\nexport type UserRecordId = string;\n');
+    write('src/store/users.ts', "import { UserRecord } from '../model/user';\nexport const load = (): UserRecord => new UserRecord();\n");
+    write('src/store/users.test.ts', "import { load } from './users';\nload();\n");
+    write('src/api/handler.ts', "import { UserRecord } from '../model/user';\nimport { load } from '../store/users';\nconst r: UserRecord = load();\n");
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should order packages after the ones they import', async () => {
+    const plan = await planRefactor(workspace, 'UserRecord', 'Account', { caseSensitive: true });
+    expect(plan).toMatchObject({ occurrences: 7, files: 3, packages: 3 });
+    expect(plan.chunks.map((chunk) => chunk.packageDir)).toEqual(['src/model', 'src/store', 'src/api']);
+    expect(plan.chunks[0]).toMatchObject({ importers: 2, dependsOn: [], inCycle: false });
+    expect(plan.chunks[1].dependsOn).toEqual(['src/model']);
+    expect(plan.chunks[2].dependsOn).toEqual(['src/model', 'src/store']);
+    expect(plan.chunks[2].apply).toEqual({
+      from: 'UserRecord',
+      to: 'Account',
+      caseSensitive: true,
+      glob: ['src/api/handler.ts'],
+      apply: true,
+    });
+    // Nothing is written while planning
+    expect(fs.readFileSync(path.join(workspace, 'src/model/user.ts'), 'utf8')).toContain('UserRecord');
+  });
+
+  it('should estimate risk from occurrences, importers, and tests', async () => {
+    const plan = await planRefactor(workspace, 'UserRecord', 'Account', { caseSensitive: true });
+    const [model, store, api] = plan.chunks;
+    expect(model.risk).toBe('medium');
+    expect(model.reasons).toEqual(['2 occurrence(s)', 'imported by 2 file(s) outside it', 'no tests in the package']);
+    expect(store.reasons).toEqual(['3 occurrence(s)', 'imported by 1 file(s) outside it']);
+    expect(store.risk).toBe('low');
+    expect(api.risk).toBe('low');
+  });
+
+  it('should split large packages and format the steps', async () => {
+    write('src/model/admin.ts', 'export class AdminUserRecord {}\n');
+    const plan = await planRefactor(workspace, 'UserRecord', 'Account', { caseSensitive: true, maxChunkFiles: 1 });
+    expect(plan.chunks.slice(0, 2).map((chunk) => [chunk.packageDir, chunk.part, chunk.files.map((file) => file.relativePath)])).toEqual([
+      ['src/model', { index: 1, of: 2 }, ['src/model/admin.ts']],
+      ['src/model', { index: 2, of: 2 }, ['src/model/user.ts']],
+    ]);
+    const text = formatRefactorPlan(plan);
+    expect(text).toContain('Plan: replace UserRecord with Account, 8 occurrence(s) in 4 file(s) across 3 package(s), in 4 chunk(s)');
+    expect(text).toContain('1. src/model, part 1 of 2: 1 file(s), 1 occurrence(s), risk medium');
+    expect(text).toContain('   After: src/model, src/store');
+    expect(text).toContain('   Apply: replace_text {"from":"UserRecord","to":"Account","caseSensitive":true,"glob":["src/api/handler.ts"],"apply":true}');
+    expect(formatRefactorPlan(await planRefactor(workspace, 'Nowhere', 'x'))).toBe('No occurrences of Nowhere found; nothing to plan');
+  });
+
+  it('should refuse a chunk size that is not a positive whole number', async () => {
+    for (const maxChunkFiles of [0, -3, 2.5, NaN]) {
+      const err = await planRefactor(workspace, 'UserRecord', 'Account', { maxChunkFiles }).catch((e) => e);
+      expect(err.code).toBe('invalid-argument');
+    }
+  });
+});
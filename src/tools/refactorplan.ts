@@ -0,0 +1,255 @@
+/**
+ * Refactoring plan tool - a workspace-wide replacement as ordered, reviewable chunks
+ * Previews a replace_text rule and splits its changes by package (directory),
+ * ordered so the packages others import are changed before their importers,
+ * each with an estimated risk and the replace_text call applying just it.
+ * Nothing is written here; the client applies the chunks one at a time,
+ * building or testing between them
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { isSourceFile, isTestFile } from '../workspace/language.js';
+import { ToolError } from '../workspace/errors.js';
+import { readFileText } from '../workspace/overlay.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { FileReplacement, ReplaceOptions, replaceText } from './replace.js';
+import { importTargets } from './warmup.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for a refactoring plan: the replace_text rule, and how big a chunk gets
+ */
+export interface RefactorPlanOptions extends Omit<ReplaceOptions, 'apply' | 'previewLines'> {
+  // Most files one chunk changes; larger packages are split (default: 25)
+  maxChunkFiles?: number;
+}
+
+export type RefactorRisk = 'low' | 'medium' | 'high';
+
+/**
+ * Changes to one package, or to part of a large one
+ */
+export interface RefactorChunk {
+  packageDir: string; // Workspace-relative directory, "." for the root
+  part?: { index: number; of: number }; // Set when a large package is split
+  files: Array<{ relativePath: string; count: number }>;
+  occurrences: number;
+  // Packages of the plan this one imports, all changed in earlier chunks unless it is in an import cycle
+  dependsOn: string[];
+  inCycle: boolean;
+  // Files outside the package importing it
+  importers: number;
+  risk: RefactorRisk;
+  reasons: string[];
+  // Arguments for the replace_text call applying this chunk
+  apply: Record<string, unknown>;
+}
+
+/**
+ * An ordered refactoring plan
+ */
+export interface RefactorPlan {
+  from: string;
+  to: string;
+  occurrences: number;
+  files: number;
+  packages: number;
+  chunks: RefactorChunk[];
+}
+
+/**
+ * Files a chunk changes unless maxChunkFiles says otherwise
+ */
+const DEFAULT_MAX_CHUNK_FILES = 25;
+
+/**
+ * A glob matching exactly one path; glob characters in it are made literal as character classes
+ */
+function literalGlob(relativePath: string): string {
+  return relativePath.replace(/[*?[\]{}]/g, (ch) => (ch === ']' ? ch : `[${ch}]`));
+}
+
+/**
+ * How risky changing a package is, and why
+ * Many occurrences, many importers, and no tests to catch mistakes raise
+ * the risk; changes to test files alone are low
+ */
+function estimateRisk(
+  files: FileReplacement[],
+  importers: number,
+  hasTests: boolean
+): { risk: RefactorRisk; reasons: string[] } {
+  const occurrences = files.reduce((sum, file) => sum + file.count, 0);
+  const reasons = [`${occurrences} occurrence(s)`];
+  if (files.every((file) => isTestFile(file.relativePath))) {
+    reasons.push('test files only');
+    return { risk: 'low', reasons };
+  }
+  let score = occurrences >= 20 ? 2 : occurrences >= 5 ? 1 : 0;
+  if (importers > 0) {
+    reasons.push(`imported by ${importers} file(s) outside it`);
+    score += importers >= 10 ? 2 : 1;
+  }
+  if (!hasTests && files.some((file) => isSourceFile(file.relativePath))) {
+    reasons.push('no tests in the package');
+    score++;
+  }
+  return { risk: score >= 4 ? 'high' : score >= 2 ? 'medium' : 'low', reasons };
+}
+
+const RISK_ORDER: Record<RefactorRisk, number> = { low: 0, medium: 1, high: 2 };
+
+const byCodePoint = (a: string, b: string) => (a < b ? -1 : a > b ? 1 : 0);
+
+/**
+ * Plan a replacement across the workspace as chunks, one package at a time
+ * Packages are ordered by their imports within the workspace: a package
+ * comes after every other package of the plan it imports, and lower-risk
+ * packages come first among those ready. Packages in an import cycle are
+ * ordered as best they can be and flagged
+ */
+export async function planRefactor(
+  workspaceDir: string,
+  from: string,
+  to: string,
+  options: RefactorPlanOptions = {},
+  index?: TrigramIndex
+): Promise<RefactorPlan> {
+  const { maxChunkFiles = DEFAULT_MAX_CHUNK_FILES, ...rule } = options;
+  if (!Number.isInteger(maxChunkFiles) || maxChunkFiles < 1) {
+    throw new ToolError('invalid-argument', `maxChunkFiles must be a positive whole number of files, got ${maxChunkFiles}`);
+  }
+  const replacements = await replaceText(workspaceDir, from, to, { ...rule, apply: false, previewLines: 0 }, index);
+  const packageOf = (relativePath: string) => path.posix.dirname(relativePath.split(path.sep).join('/'));
+  const byPackage = new Map<string, FileReplacement[]>();
+  for (const file of replacements) {
+    const dir = packageOf(file.relativePath);
+    byPackage.set(dir, [...(byPackage.get(dir) ?? []), file]);
+  }
+
+  // Imports between the workspace's files, to order the packages and count their importers
+  const sources: Array<{ relativePath: string; content: string }> = [];
+  const testPackages = new Set<string>();
+  if (byPackage.size > 0) {
+    for (const file of await walkWorkspaceFiles(workspaceDir)) {
+      if (!isSourceFile(file.absolutePath)) {
+        continue;
+      }
+      const relativePath = file.relativePath.split(path.sep).join('/');
+      if (isTestFile(relativePath)) {
+        testPackages.add(packageOf(relativePath));
+      }
+      try {
+        sources.push({ relativePath, content: await readFileText(file.absolutePath) });
+      } catch (err) {
+        toolsLogger.debug('Error reading file %s: %s', file.absolutePath, err);
+      }
+    }
+  }
+  const dependsOn = new Map<string, Set<string>>(Array.from(byPackage.keys(), (dir) => [dir, new Set<string>()]));
+  const importers = new Map<string, Set<string>>();
+  for (const [importer, targets] of importTargets(sources)) {
+    const importerDir = packageOf(importer);
+    for (const target of targets) {
+      const targetDir = packageOf(target);
+      if (targetDir === importerDir || !byPackage.has(targetDir)) {
+        continue;
+      }
+      importers.set(targetDir, (importers.get(targetDir) ?? new Set<string>()).add(importer));
+      dependsOn.get(importerDir)?.add(targetDir);
+    }
+  }
+
+  const estimates = new Map(Array.from(byPackage, ([dir, files]) =>
+    [dir, estimateRisk(files, importers.get(dir)?.size ?? 0, testPackages.has(dir))]));
+  const readiness = (a: string, b: string) =>
+    RISK_ORDER[estimates.get(a)!.risk] - RISK_ORDER[estimates.get(b)!.risk] || byCodePoint(a, b);
+  const ordered: Array<{ dir: string; inCycle: boolean }> = [];
+  const done = new Set<string>();
+  while (done.size < byPackage.size) {
+    const remaining = Array.from(byPackage.keys()).filter((dir) => !done.has(dir));
+    const unmet = (dir: string) => Array.from(dependsOn.get(dir)!).filter((dep) => !done.has(dep)).length;
+    const ready = remaining.filter((dir) => unmet(dir) === 0).sort(readiness);
+    if (ready.length > 0) {
+      ready.forEach((dir) => done.add(dir));
+      ordered.push(...ready.map((dir) => ({ dir, inCycle: false })));
+      continue;
+    }
+    // Every remaining package waits on another: break the cycle at the one waiting on fewest
+    const next = remaining.sort((a, b) => unmet(a) - unmet(b) || readiness(a, b))[0];
+    done.add(next);
+    ordered.push({ dir: next, inCycle: true });
+  }
+
+  const chunks: RefactorChunk[] = [];
+  for (const { dir, inCycle } of ordered) {
+    const files = byPackage.get(dir)!.sort((a, b) => byCodePoint(a.relativePath, b.relativePath));
+    const { risk, reasons } = estimates.get(dir)!;
+    const parts = Math.ceil(files.length / maxChunkFiles);
+    for (let part = 0; part < parts; part++) {
+      const slice = files.slice(part * maxChunkFiles, (part + 1) * maxChunkFiles);
+      chunks.push({
+        packageDir: dir,
+        ...(parts > 1 ? { part: { index: part + 1, of: parts } } : {}),
+        files: slice.map((file) => ({ relativePath: file.relativePath, count: file.count })),
+        occurrences: slice.reduce((sum, file) => sum + file.count, 0),
+        dependsOn: Array.from(dependsOn.get(dir)!).sort(byCodePoint),
+        inCycle,
+        importers: importers.get(dir)?.size ?? 0,
+        risk,
+        reasons,
+        apply: {
+          from,
+          to,
+          ...(rule.caseAware ? { caseAware: true } : {}),
+          ...(rule.regex ? { regex: true } : {}),
+          ...(rule.caseSensitive ? { caseSensitive: true } : {}),
+          ...(rule.wholeWord ? { wholeWord: true } : {}),
+          glob: slice.map((file) => literalGlob(file.relativePath)),
+          apply: true,
+        },
+      });
+    }
+  }
+  toolsLogger.debug('Planned %s -> %s: %d file(s) in %d chunk(s)', from, to, replacements.length, chunks.length);
+  return {
+    from,
+    to,
+    occurrences: replacements.reduce((sum, file) => sum + file.count, 0),
+    files: replacements.length,
+    packages: byPackage.size,
+    chunks,
+  };
+}
+
+/**
+ * Format a plan as numbered steps, each with the call applying it
+ */
+export function formatRefactorPlan(plan: RefactorPlan): string {
+  if (plan.chunks.length === 0) {
+    return `No occurrences of ${plan.from} found; nothing to plan`;
+  }
+  const risks = (['high', 'medium', 'low'] as RefactorRisk[])
+    .map((risk) => [risk, plan.chunks.filter((chunk) => chunk.risk === risk).length] as const)
+    .filter(([, count]) => count > 0)
+    .map(([risk, count]) => `${count} ${risk}`);
+  const lines = [
+    `Plan: replace ${plan.from} with ${plan.to}, ${plan.occurrences} occurrence(s) in ${plan.files} file(s) ` +
+      `across ${plan.packages} package(s), in ${plan.chunks.length} chunk(s) (risk: ${risks.join(', ')})`,
+    'Apply the chunks in order with replace_text, building or testing after each; packages come after the ones they import',
+  ];
+  plan.chunks.forEach((chunk, i) => {
+    const part = chunk.part ? `, part ${chunk.part.index} of ${chunk.part.of}` : '';
+    lines.push('', `${i + 1}. ${chunk.packageDir}${part}: ${chunk.files.length} file(s), ${chunk.occurrences} occurrence(s), risk ${chunk.risk}`);
+    lines.push(`   Why: ${chunk.reasons.join('; ')}`);
+    if (chunk.dependsOn.length > 0) {
+      lines.push(`   After: ${chunk.dependsOn.join(', ')}${chunk.inCycle ? ' (import cycle: some of these come later)' : ''}`);
+    }
+    lines.push(`   Files: ${chunk.files.map((file) => `${file.relativePath} (${file.count})`).join(', ')}`);
+    lines.push(`   Apply: replace_text ${JSON.stringify(chunk.apply)}`);
+  });
+  return lines.join('\n');
+}
@@ -0,0 +1,55 @@
+/**
+ * Tests for reference access classification
+ */
+
+import * as path from 'path';
+import { classifyAccess, inReferenceScope } from './references';
+
+describe('classifyAccess', () => {
+  const access = (line: string, name: string, occurrence = 0) => {
+    let character = -1;
+    for (let i = 0; i <= occurrence; i++) {
+      character = line.indexOf(name, character + 1);
+    }
+    return classifyAccess(line, character, name.length);
+  };
+
+  it('should find writes', () => {
+    expect(access('\ts.count = 0', 'count')).toBe('write');
+    expect(access('\ts.count += n', 'count')).toBe('write');
+    expect(access('\tcount++', 'count')).toBe('write');
+    expect(access('\t--count;', 'count')).toBe('write');
+    expect(access('\terr, count := parse()', 'count')).toBe('write');
+    expect(access('\tcount, err = parse()', 'count')).toBe('write');
+    expect(access('\tthis.cache ??= new Map()', 'cache')).toBe('write');
+  });
+
+  it('should find reads', () => {
+    expect(access('\tif s.count == 0 {', 'count')).toBe('read');
+    expect(access('\treturn count >= limit', 'count')).toBe('read');
+    expect(access('\ttotal = count + 1', 'count')).toBe('read');
+    expect(access('\tconst f = (count) => count', 'count', 1)).toBe('read');
+    expect(access('\tif count != 0 {', 'count')).toBe('read');
+  });
+
+  it('should find declarations', () => {
+    expect(access('var count int', 'count')).toBe('declaration');
+    expect(access('func (s *Server) Count() int {', 'Count')).toBe('declaration');
+    expect(access('let total, count = 0, 0', 'count')).toBe('declaration');
+    expect(access('def count(self):', 'count')).toBe('declaration');
+  });
+});
+
+describe('inReferenceScope', () => {
+  const declaration = path.join('/w', 'api', 'user.go');
+
+  it('should keep references in the declaring file, package, or test files', () => {
+    expect(inReferenceScope(declaration, declaration, 'same_file')).toBe(true);
+    expect(inReferenceScope(path.join('/w', 'api', 'handler.go'), declaration, 'same_file')).toBe(false);
+    expect(inReferenceScope(path.join('/w', 'api', 'handler.go'), declaration, 'same_package')).toBe(true);
+    expect(inReferenceScope(path.join('/w', 'api', 'v2', 'handler.go'), declaration, 'same_package')).toBe(false);
+    expect(inReferenceScope(path.join('/w', 'api', 'user_test.go'), declaration, 'tests_only')).toBe(true);
+    expect(inReferenceScope(path.join('/w', 'api', 'user_test.go'), declaration, 'non_tests')).toBe(false);
+    expect(inReferenceScope(path.join('/w', 'web', 'user.ts'), declaration, 'non_tests')).toBe(true);
+  });
+});
@@ -2,9 +2,9 @@
  * References tool - find all usages of symbols
  */
 
-import * as fs from 'fs';
+import * as path from 'path';
 import { LSPClient } from '../lsp/client.js';
-import { symbol, references as lspReferences } from '../lsp/methods.js';
+import { symbol, answeredReferences, documentHighlight } from '../lsp/methods.js';
 import { createLogger, Component } from '../logging/logger.js';
 import {
   wrapSymbol,
@@ -14,6 +14,8 @@ import {
   TextDocumentIdentifier,
   ReferenceContext,
   Location,
+  DocumentHighlightKind,
+  DocumentHighlightParams,
 } from '../protocol/types.js';
 import { uriToPath } from '../protocol/uri.js';
 import {
@@ -21,16 +23,140 @@ import {
   convertLinesToRanges,
   formatLinesWithRanges,
 } from './utilities.js';
+import { Answer, answerNote, leastConfidence, locationConfidence } from './confidence.js';
+import { isTestFile } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { isUnder } from '../workspace/paths.js';
+import { ToolError } from '../workspace/errors.js';
 
 const toolsLogger = createLogger(Component.TOOLS);
 
+/**
+ * How a reference uses its symbol
+ */
+export type ReferenceAccess = 'read' | 'write' | 'declaration';
+
+/**
+ * Where references are looked for, relative to the symbol's declaration
+ */
+export type ReferenceScope = 'same_file' | 'same_package' | 'tests_only' | 'non_tests';
+
+const REFERENCE_SCOPES: ReferenceScope[] = ['same_file', 'same_package', 'tests_only', 'non_tests'];
+
+/**
+ * Options for filtering and classifying references
+ */
+export interface ReferenceOptions {
+  // Label each reference read, write, or declaration
+  classify?: boolean;
+  // Only references with this access; implies classify
+  access?: ReferenceAccess;
+  // Only references in the declaring file, its directory, test files, or other files
+  within?: ReferenceScope;
+}
+
+/**
+ * Check if a reference falls within a scope around the file declaring its symbol
+ * A package is the declaring file's directory, as in Go and Python
+ */
+export function inReferenceScope(refPath: string, declarationPath: string, within: ReferenceScope): boolean {
+  switch (within) {
+    case 'same_file':
+      return refPath === declarationPath;
+    case 'same_package':
+      return path.dirname(refPath) === path.dirname(declarationPath);
+    case 'tests_only':
+      return isTestFile(refPath);
+    case 'non_tests':
+      return !isTestFile(refPath);
+  }
+}
+
+/**
+ * Classify a reference from the text around it, for servers without
+ * read/write document highlights
+ * Assignments, compound assignments, and increments write; a declaration
+ * keyword before the name declares; anything else reads
+ */
+export function classifyAccess(lineText: string, character: number, length: number): ReferenceAccess {
+  const before = lineText.substring(0, character);
+  const after = lineText.substring(character + length);
+  if (/\b(?:var|let|const|val|def|func|fn|class|struct|type|interface|enum|trait)\s+(?:\([^)]*\)\s*)?(?:[\w.]+\s*,\s*)*$/.test(before)) {
+    return 'declaration';
+  }
+  if (/^\s*(?:=(?![=>])|:=|[-+*/%&|^]=|<<=|>>=|&&=|\|\|=|\?\?=|\+\+|--)/.test(after) || /(?:\+\+|--)\s*$/.test(before)) {
+    return 'write';
+  }
+  // The left side of a multiple assignment: a, b = f()
+  if (/^\s*,[\w\s.,]*(?::=|=(?![=>]))/.test(after)) {
+    return 'write';
+  }
+  return 'read';
+}
+
+/**
+ * Access of each of a file's references, from the server's document
+ * highlights when it marks reads and writes, otherwise from the text
+ */
+async function classifyReferences(
+  client: LSPClient,
+  declaration: Location,
+  refs: Location[],
+  lines: string[]
+): Promise<ReferenceAccess[]> {
+  const kinds = new Map<string, DocumentHighlightKind>();
+  try {
+    const highlights = await documentHighlight(client, {
+      textDocument: { uri: refs[0].uri } as TextDocumentIdentifier,
+      position: refs[0].range.start,
+    } as DocumentHighlightParams);
+    for (const highlight of highlights) {
+      kinds.set(`${highlight.range.start.line}:${highlight.range.start.character}`, highlight.kind ?? DocumentHighlightKind.Text);
+    }
+  } catch (err) {
+    toolsLogger.debug('No document highlights for %s: %s', refs[0].uri, err);
+  }
+  return refs.map((ref) => {
+    const { line, character } = ref.range.start;
+    if (ref.uri === declaration.uri && line === declaration.range.start.line) {
+      return 'declaration';
+    }
+    const kind = kinds.get(`${line}:${character}`);
+    if (kind === DocumentHighlightKind.Write) {
+      return 'write';
+    }
+    if (kind === DocumentHighlightKind.Read) {
+      return 'read';
+    }
+    const length = ref.range.end.line === line ? ref.range.end.character - character : 0;
+    return classifyAccess(lines[line] ?? '', character, length);
+  });
+}
+
+/**
+ * Count of each access, e.g. "1 write, 3 read"
+ */
+function summarizeAccesses(accesses: ReferenceAccess[]): string {
+  return (['declaration', 'write', 'read'] as ReferenceAccess[])
+    .map((access) => [access, accesses.filter((a) => a === access).length] as const)
+    .filter(([, count]) => count > 0)
+    .map(([access, count]) => `${count} ${access}`)
+    .join(', ');
+}
+
 /**
  * Find references to a symbol
  */
 export async function findReferences(
   client: LSPClient,
-  symbolName: string
+  symbolName: string,
+  scope?: string,
+  options: ReferenceOptions = {}
 ): Promise<string> {
+  const classify = options.classify || options.access !== undefined;
+  if (options.within && !REFERENCE_SCOPES.includes(options.within)) {
+    throw new ToolError('invalid-argument', `Unknown scope "${options.within}"; use ${REFERENCE_SCOPES.join(', ')}`);
+  }
   // Get context lines from environment variable
   const contextLines = parseInt(process.env.LSP_CONTEXT_LINES || '5', 10);
 
@@ -40,6 +166,9 @@ export async function findReferences(
   const results = symbolResult.results();
 
   const allReferences: string[] = [];
+  const answers: Answer[] = [];
+  // The name each reference spells, checked against files changed since the answer
+  const spelled = symbolName.split('.').pop()!;
 
   for (const rawSymbol of results) {
     const sym = wrapSymbol(rawSymbol);
@@ -73,15 +202,24 @@ export async function findReferences(
     const refsParams: ReferenceParams = {
       textDocument: { uri: loc.uri } as TextDocumentIdentifier,
       position: loc.range.start,
-      context: { includeDeclaration: false } as ReferenceContext,
+      // Classified results list the declaration as such
+      context: { includeDeclaration: classify } as ReferenceContext,
     } as ReferenceParams & TextDocumentPositionParams;
 
-    const refs = await lspReferences(client, refsParams);
+    const { locations: refs, answeredAt, cached } = await answeredReferences(client, refsParams);
+    const answer: Answer = { answeredAt, cached, loading: client.workInProgressAt(answeredAt) };
+    answers.push(answer);
 
     // Group references by file
     const refsByFile = new Map<string, Location[]>();
     for (const ref of refs) {
       const uri = ref.uri;
+      if (scope && !isUnder(uriToPath(uri), scope)) {
+        continue;
+      }
+      if (options.within && !inReferenceScope(uriToPath(uri), filePath, options.within)) {
+        continue;
+      }
       if (!refsByFile.has(uri)) {
         refsByFile.set(uri, []);
       }
@@ -93,21 +231,36 @@ export async function findReferences(
 
     // Process each file's references
     for (const uri of uris) {
-      const fileRefs = refsByFile.get(uri)!;
+      let fileRefs = refsByFile.get(uri)!;
       const refFilePath = uriToPath(uri);
 
-      // Format file header
-      let fileInfo = `---\n\n${refFilePath}\nReferences in File: ${fileRefs.length}\n`;
-
       // Format locations with context
       try {
-        const fileContent = await fs.promises.readFile(refFilePath, 'utf8');
+        const fileContent = await readFileText(refFilePath);
         const lines = fileContent.split('\n');
 
-        // Track reference locations for header display
-        const locStrings = fileRefs.map(
-          (ref) => `L${ref.range.start.line + 1}:C${ref.range.start.character + 1}`
-        );
+        let accesses: ReferenceAccess[] | undefined;
+        if (classify) {
+          accesses = await classifyReferences(client, loc, fileRefs, lines);
+          if (options.access) {
+            fileRefs = fileRefs.filter((_ref, i) => accesses![i] === options.access);
+            accesses = accesses.filter((access) => access === options.access);
+            if (fileRefs.length === 0) {
+              continue;
+            }
+          }
+        }
+
+        const confidences = await locationConfidence(refFilePath, lines, fileRefs, spelled, answer);
+        const least = leastConfidence(confidences);
+
+        // Track reference locations for header display; locations below high confidence say so
+        const locStrings = fileRefs.map((ref, i) => {
+          const notes = [accesses?.[i], confidences[i].confidence !== 'high' ? confidences[i].confidence : undefined].filter(Boolean);
+          return `L${ref.range.start.line + 1}:C${ref.range.start.character + 1}${notes.length > 0 ? ` (${notes.join(', ')})` : ''}`;
+        });
+        const fileInfo = `---\n\n${refFilePath}\nReferences in File: ${fileRefs.length}${accesses ? ` (${summarizeAccesses(accesses)})` : ''}\n` +
+          `Confidence: ${least.confidence}${least.reason ? ` (${least.reason})` : ''}\n`;
 
         // Collect lines to display
         const linesToShow = getLineRangesToDisplay(fileRefs, lines.length, contextLines);
@@ -124,15 +277,18 @@ export async function findReferences(
         formattedOutput += '\n' + formatLinesWithRanges(lines, lineRanges);
         allReferences.push(formattedOutput);
       } catch (err) {
-        allReferences.push(fileInfo + '\nError reading file: ' + err);
+        allReferences.push(`---\n\n${refFilePath}\nReferences in File: ${fileRefs.length}\n\nError reading file: ${err}`);
       }
     }
   }
 
   if (allReferences.length === 0) {
-    return `No references found for symbol: ${symbolName}`;
+    const within = options.within ? ` (scope: ${options.within})` : '';
+    return options.access
+      ? `No ${options.access} references found for symbol: ${symbolName}${within}`
+      : `No references found for symbol: ${symbolName}${within}`;
   }
 
-  return allReferences.join('\n');
+  return allReferences.join('\n') + `\n---\n\n${answerNote(answers)}\n`;
 }
 
@@ -3,6 +3,7 @@
  */
 
 import * as fs from 'fs';
+import * as path from 'path';
 import { LSPClient } from '../lsp/client.js';
 import { rename as lspRename } from '../lsp/methods.js';
 import { createLogger, Component } from '../logging/logger.js';
@@ -13,25 +14,41 @@ import {
   WorkspaceEdit,
 } from '../protocol/types.js';
 import { pathToUri, uriToPath } from '../protocol/uri.js';
+import { assertNoOverlay } from '../workspace/overlay.js';
+import { collectEditRanges, findRenameCollisions, formatCollisions } from './impact.js';
+import { ToolError } from '../workspace/errors.js';
 
 const toolsLogger = createLogger(Component.TOOLS);
 
+/**
+ * Options for renaming a symbol
+ */
+export interface RenameOptions {
+  // Collision paths are reported relative to this directory (default: the file's directory)
+  workspaceDir?: string;
+  // Rename even when the new name collides with a declaration or use in scope (default: false)
+  force?: boolean;
+}
+
 /**
  * Rename a symbol
+ * Nothing is edited when the new name collides with an existing one, unless forced
  */
 export async function renameSymbol(
   client: LSPClient,
   filePath: string,
   line: number,
   column: number,
-  newName: string
+  newName: string,
+  options: RenameOptions = {}
 ): Promise<string> {
+  assertNoOverlay(filePath, 'rename in');
   const uri = pathToUri(filePath);
 
   try {
     await client.openFile(filePath);
   } catch (err) {
-    throw new Error(`Error opening file: ${err}`);
+    throw new ToolError('not-found', `Error opening file: ${err}`);
   }
 
   const params: RenameParams = {
@@ -52,6 +69,15 @@ export async function renameSymbol(
     return `No rename operations available at ${filePath}:${line}:${column}`;
   }
 
+  if (!options.force) {
+    const collisions = await findRenameCollisions(client, options.workspaceDir ?? path.dirname(filePath), filePath,
+      params.position, newName, collectEditRanges(workspaceEdit));
+    if (collisions.length > 0) {
+      return `Rename to '${newName}' not applied: ${collisions.length} collision(s)\n${formatCollisions(collisions)}` +
+        '\nChoose another name, or pass force: true to rename anyway';
+    }
+  }
+
   // Apply the workspace edit
   await applyWorkspaceEdit(workspaceEdit);
 
@@ -86,6 +112,7 @@ async function applyWorkspaceEdit(edit: WorkspaceEdit): Promise<void> {
 
   for (const [uri, edits] of Object.entries(edit.changes)) {
     const filePath = uriToPath(uri);
+    assertNoOverlay(filePath, 'rename in');
     const content = await fs.promises.readFile(filePath, 'utf8');
     const lines = content.split('\n');
 
@@ -0,0 +1,117 @@
+/**
+ * Tests for the replace tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { atWordParts, caseVariants, formatReplacements, replaceText } from './replace';
+
+describe('replace_text', () => {
+  let workspace: string;
+
+  const read = (relativePath: string) => fs.readFileSync(path.join(workspace, relativePath), 'utf8');
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'replace-')));
+    fs.writeFileSync(path.join(workspace, 'service.ts'), [
+      'export class UserService {}',
+      'const userService = new UserService();',
+      'const USER_SERVICE_URL = process.env.USER_SERVICE;',
+      'getUserServiceImpl(userServices, username);',
+    ].join('\n'));
+    fs.writeFileSync(path.join(workspace, 'deploy.yaml'), 'image: user-service\n# the user service\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should derive every spelling from one rule', () => {
+    expect(caseVariants('userService', 'AccountService')).toEqual([
+      { from: 'user_service', to: 'account_service' },
+      { from: 'USER_SERVICE', to: 'ACCOUNT_SERVICE' },
+      { from: 'user-service', to: 'account-service' },
+      { from: 'user service', to: 'account service' },
+      { from: 'userService', to: 'accountService' },
+      { from: 'UserService', to: 'AccountService' },
+    ]);
+    expect(caseVariants('id', 'key').map((variant) => variant.from)).toEqual(['id', 'Id', 'ID']);
+    expect(() => caseVariants('--', 'x')).toThrow('caseAware needs from and to made of letters or digits');
+  });
+
+  it('should only match whole parts of identifiers', () => {
+    const text = 'getUserServiceImpl userServices USER_SERVICES';
+    expect(atWordParts(text, 3, 14)).toBe(true);
+    expect(atWordParts(text, 19, 30)).toBe(false);
+    expect(atWordParts(text, 32, 44)).toBe(false);
+  });
+
+  it('should preview, then apply, case-aware replacements', async () => {
+    const preview = await replaceText(workspace, 'user service', 'account service', { caseAware: true });
+    expect(preview.map((file) => [file.relativePath, file.count])).toEqual([['deploy.yaml', 2], ['service.ts', 6]]);
+    expect(read('service.ts')).toContain('class UserService');
+    expect(formatReplacements('user service', 'account service', preview, { caseAware: true }).split('\n').slice(0, 6)).toEqual([
+      'Would replace 8 occurrence(s) in 2 file(s); pass apply: true to write them',
+      'Spellings: user_service -> account_service, USER_SERVICE -> ACCOUNT_SERVICE, user-service -> account-service, ' +
+        'user service -> account service, userService -> accountService, UserService -> AccountService',
+      '',
+      'deploy.yaml (2)',
+      '  L1- image: user-service',
+      '  L1+ image: account-service',
+    ]);
+
+    await replaceText(workspace, 'user service', 'account service', { caseAware: true, apply: true });
+    expect(read('service.ts')).toBe([
+      'export class AccountService {}',
+      'const accountService = new AccountService();',
+      'const ACCOUNT_SERVICE_URL = process.env.ACCOUNT_SERVICE;',
+      'getAccountServiceImpl(userServices, username);',
+    ].join('\n'));
+    expect(read('deploy.yaml')).toBe('image: account-service\n# the account service\n');
+  });
+
+  it('should replace literals and regular expressions with groups', async () => {
+    const replaced = await replaceText(workspace, 'new (\\w+)\\(\\)', 'create$1()', { regex: true, apply: true });
+    expect(replaced).toHaveLength(1);
+    expect(read('service.ts')).toContain('const userService = createUserService();');
+    expect(await replaceText(workspace, 'username', 'login', { wholeWord: true })).toHaveLength(1);
+    expect(formatReplacements('nothing', 'x', [])).toBe('No occurrences of nothing found');
+    await expect(replaceText(workspace, 'a+', 'b', { regex: true, caseAware: true })).rejects.toThrow('cannot be combined with regex');
+  });
+
+  it('should write files back in their own encoding and line endings', async () => {
+    const utf16 = Buffer.concat([Buffer.from([0xff, 0xfe]), Buffer.from('var userId = 1;\r\nuserId++;\r\n', 'utf16le')]);
+    fs.writeFileSync(path.join(workspace, 'ids.cs'), utf16);
+    fs.writeFileSync(path.join(workspace, 'ids.txt'), Buffer.from('\xe9t\xe9 userId\r\n', 'latin1'));
+    fs.writeFileSync(path.join(workspace, 'bom.ts'), '\ufeffconst userId = 1;\nexport { userId };\n');
+
+    const replaced = await replaceText(workspace, 'userId', 'accountId', { caseSensitive: true, apply: true });
+    expect(replaced.map((file) => [file.relativePath, file.count])).toEqual([['bom.ts', 2], ['ids.cs', 2], ['ids.txt', 1]]);
+    expect(fs.readFileSync(path.join(workspace, 'ids.cs')))
+      .toEqual(Buffer.concat([Buffer.from([0xff, 0xfe]), Buffer.from('var accountId = 1;\r\naccountId++;\r\n', 'utf16le')]));
+    expect(fs.readFileSync(path.join(workspace, 'ids.txt'))).toEqual(Buffer.from('\xe9t\xe9 accountId\r\n', 'latin1'));
+    expect(read('bom.ts')).toBe('\ufeffconst accountId = 1;\nexport { accountId };\n');
+    expect(fs.readdirSync(workspace).filter((name) => name.endsWith('.tmp'))).toEqual([]);
+
+    // Latin-1 cannot hold the new text, so nothing is written
+    const err = await replaceText(workspace, 'accountId', 'compte€', { apply: true }).catch((e) => e);
+    expect(err.code).toBe('invalid-argument');
+    expect(read('bom.ts')).toContain('accountId');
+  });
+
+  it('should skip notebooks instead of rewriting their JSON', async () => {
+    const notebook = JSON.stringify({ cells: [{ cell_type: 'code', source: ['print("user service")\n'] }] });
+    fs.writeFileSync(path.join(workspace, 'explore.ipynb'), notebook);
+    const skipped: string[] = [];
+    const replaced = await replaceText(workspace, 'user service', 'account service', {
+      apply: true,
+      onSkip: (relativePath, reason) => skipped.push(`${relativePath}: ${reason}`),
+    });
+    expect(replaced.map((file) => file.relativePath)).toEqual(['deploy.yaml']);
+    expect(skipped).toEqual(['explore.ipynb: notebook; edit its cells instead']);
+    expect(fs.readFileSync(path.join(workspace, 'explore.ipynb'), 'utf8')).toBe(notebook);
+    expect(formatReplacements('user service', 'account service', replaced, { apply: true }, [{ relativePath: 'explore.ipynb', reason: 'notebook' }]))
+      .toContain('Skipped 1 file(s):\n  explore.ipynb (notebook)');
+  });
+});
@@ -0,0 +1,300 @@
+/**
+ * Replace tool - substitute text across the workspace, previewing by default
+ * With caseAware, one rule covers every spelling of a concept: from
+ * "userService" to "accountService" also turns UserService into
+ * AccountService, USER_SERVICE into ACCOUNT_SERVICE, and user-service into
+ * account-service, and only where the words start and end a part of an
+ * identifier, so "user" does not touch "username"
+ *
+ * Files are written back in the encoding, byte order mark, and line endings
+ * they were read with, through a temporary file so a failed write never
+ * leaves one half changed. Notebooks and archive entries are skipped: their
+ * matches are in cell sources and entries, not in the bytes of the file
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { LexicalSearchOptions, buildMatcher, escapeRegExp, searchLexical } from '../search/lexical.js';
+import { isNotebook } from '../search/notebook.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { splitIdentifier } from '../semantic/embeddings.js';
+import { writeFileAtomic } from '../workspace/atomic.js';
+import { decodeText, detectTextFormat, encodeText } from '../workspace/encoding.js';
+import { ToolError } from '../workspace/errors.js';
+import { assertNoOverlay, readFileBytes } from '../workspace/overlay.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the replace tool
+ */
+export interface ReplaceOptions extends Pick<LexicalSearchOptions, 'path' | 'glob' | 'excludeGlob' | 'languages'> {
+  // Treat from as a regular expression; to may refer to groups as $1
+  regex?: boolean;
+  caseSensitive?: boolean;
+  wholeWord?: boolean;
+  // Replace every case variant of from with the same variant of to
+  caseAware?: boolean;
+  // Write the files; without it the replacements are only listed (default: false)
+  apply?: boolean;
+  // Changed lines listed per file (default: 5)
+  previewLines?: number;
+  // Called with each file that matches but is not rewritten, and why
+  onSkip?: (relativePath: string, reason: string) => void;
+}
+
+/**
+ * One spelling of the rule, e.g. USER_SERVICE -> ACCOUNT_SERVICE
+ */
+export interface CaseVariant {
+  from: string;
+  to: string;
+}
+
+/**
+ * Replacements made in one file
+ */
+export interface FileReplacement {
+  relativePath: string;
+  count: number;
+  changedLines: number;
+  // The first changed lines, 1-indexed in the original file
+  lines: Array<{ line: number; before: string; after: string }>;
+}
+
+/**
+ * Files listed in a preview before the rest are only counted
+ */
+const MAX_LISTED_FILES = 50;
+
+const capitalize = (word: string) => word.charAt(0).toUpperCase() + word.substring(1);
+
+/**
+ * The spellings of a rule: camelCase, PascalCase, snake_case,
+ * SCREAMING_SNAKE_CASE, kebab-case, and lowercase words, each of from
+ * mapped to the same spelling of to; longest first, so the alternation
+ * prefers USER_SERVICE over USER
+ */
+export function caseVariants(from: string, to: string): CaseVariant[] {
+  const source = splitIdentifier(from);
+  const target = splitIdentifier(to);
+  if (source.length === 0 || target.length === 0) {
+    throw new ToolError('invalid-argument', 'caseAware needs from and to made of letters or digits');
+  }
+  const spellings: Array<(words: string[]) => string> = [
+    (words) => words[0] + words.slice(1).map(capitalize).join(''),
+    (words) => words.map(capitalize).join(''),
+    (words) => words.join('_'),
+    (words) => words.join('_').toUpperCase(),
+    (words) => words.join('-'),
+    (words) => words.join(' '),
+  ];
+  const variants = new Map<string, string>();
+  for (const spell of spellings) {
+    const variant = spell(source);
+    if (!variants.has(variant)) {
+      variants.set(variant, spell(target));
+    }
+  }
+  return [...variants].map(([variantFrom, variantTo]) => ({ from: variantFrom, to: variantTo }))
+    .sort((a, b) => b.from.length - a.from.length);
+}
+
+const isLower = (c: string) => c >= 'a' && c <= 'z';
+const isUpper = (c: string) => c >= 'A' && c <= 'Z';
+const isDigit = (c: string) => c >= '0' && c <= '9';
+
+/**
+ * Check that a case-aware match starts and ends a part of an identifier:
+ * next to a non-alphanumeric character, a digit, or a case change
+ */
+export function atWordParts(text: string, start: number, end: number): boolean {
+  const before = text.charAt(start - 1);
+  const first = text.charAt(start);
+  const startsPart = !before || !/[A-Za-z0-9]/.test(before) || isDigit(before) ||
+    (isUpper(first) && isLower(before));
+  const after = text.charAt(end);
+  const last = text.charAt(end - 1);
+  const endsPart = !after || !/[A-Za-z0-9]/.test(after) || isDigit(after) ||
+    (isUpper(after) && !isUpper(last));
+  return startsPart && endsPart;
+}
+
+/**
+ * Replace in one file's content, returning the new content and the changed lines
+ */
+export function replaceContent(
+  content: string,
+  matcher: RegExp,
+  replace: (match: string, offset: number, groups: string[]) => string | undefined
+): { content: string; count: number; changed: number[] } {
+  let count = 0;
+  const offsets: number[] = [];
+  const result = content.replace(matcher, (match: string, ...rest: unknown[]) => {
+    // The arguments after the groups are the offset and the whole string, then named groups when there are any
+    const offsetIndex = rest.findIndex((item) => typeof item === 'number');
+    const offset = rest[offsetIndex] as number;
+    const replacement = replace(match, offset, rest.slice(0, offsetIndex).map((group) => (group as string | undefined) ?? ''));
+    if (replacement === undefined || replacement === match) {
+      return match;
+    }
+    count++;
+    offsets.push(offset);
+    return replacement;
+  });
+  const changed: number[] = [];
+  let line = 1;
+  let position = 0;
+  for (const offset of offsets) {
+    for (; position < offset; position++) {
+      if (content.charCodeAt(position) === 10) {
+        line++;
+      }
+    }
+    if (changed[changed.length - 1] !== line) {
+      changed.push(line);
+    }
+  }
+  return { content: result, count, changed };
+}
+
+/**
+ * Expand $1, $2, ... and $& in a regex replacement
+ */
+function expandGroups(to: string, match: string, groups: string[]): string {
+  return to.replace(/\$(\d+|&|\$)/g, (token, name: string) =>
+    name === '$' ? '$' : name === '&' ? match : groups[parseInt(name, 10) - 1] ?? token);
+}
+
+/**
+ * Find the replacements of a rule across the workspace, writing them when apply is set
+ */
+export async function replaceText(
+  workspaceDir: string,
+  from: string,
+  to: string,
+  options: ReplaceOptions = {},
+  index?: TrigramIndex
+): Promise<FileReplacement[]> {
+  if (!from) {
+    throw new ToolError('invalid-argument', 'from is required');
+  }
+  if (options.caseAware && options.regex) {
+    throw new ToolError('invalid-argument', 'caseAware applies to literal text; it cannot be combined with regex');
+  }
+  const variants = options.caseAware ? caseVariants(from, to) : undefined;
+  const byVariant = new Map(variants?.map((variant) => [variant.from, variant.to]));
+  const pattern = variants ? variants.map((variant) => escapeRegExp(variant.from)).join('|') : from;
+  const regex = options.regex || variants !== undefined;
+  const caseSensitive = variants !== undefined || options.caseSensitive;
+
+  // Only the files with a match are read again to replace
+  const search = await searchLexical(workspaceDir, pattern, {
+    path: options.path,
+    glob: options.glob,
+    excludeGlob: options.excludeGlob,
+    languages: options.languages,
+    regex,
+    caseSensitive,
+    wholeWord: !variants && options.wholeWord,
+    maxResults: 0,
+    countOmitted: true,
+  }, index);
+
+  const matcher = buildMatcher(pattern, { regex, caseSensitive, wholeWord: !variants && options.wholeWord });
+  const previewLines = options.previewLines ?? 5;
+  const replacements: FileReplacement[] = [];
+  const writes: Array<[string, Buffer]> = [];
+  for (const { filePath: relativePath } of search.omittedByFile ?? []) {
+    if (relativePath.includes('!/')) {
+      options.onSkip?.(relativePath, 'archive entry');
+      continue;
+    }
+    if (isNotebook(relativePath)) {
+      options.onSkip?.(relativePath, 'notebook; edit its cells instead');
+      continue;
+    }
+    const absolutePath = path.join(workspaceDir, relativePath);
+    const data = await readFileBytes(absolutePath);
+    const format = detectTextFormat(data);
+    const content = decodeText(data);
+    const result = replaceContent(content, matcher, (match, offset, groups) => {
+      if (byVariant.size > 0) {
+        return atWordParts(content, offset, offset + match.length) ? byVariant.get(match) : undefined;
+      }
+      return options.regex ? expandGroups(to, match, groups) : to;
+    });
+    if (result.count === 0) {
+      continue;
+    }
+    const before = content.split('\n');
+    const after = result.content.split('\n');
+    // Lines after a multi-line replacement shift, so only the first changed line of each is shown
+    const shift = after.length - before.length;
+    replacements.push({
+      relativePath,
+      count: result.count,
+      changedLines: result.changed.length,
+      lines: result.changed.slice(0, previewLines).map((line) => ({
+        line,
+        before: before[line - 1],
+        after: shift === 0 ? after[line - 1] : '(lines added or removed)',
+      })),
+    });
+    try {
+      writes.push([absolutePath, encodeText(result.content, format)]);
+    } catch (err) {
+      throw new ToolError('invalid-argument', `cannot replace in ${relativePath}: ${(err as Error).message}`);
+    }
+  }
+  if (options.apply) {
+    // Every file is checked and encoded before any is written, so a refusal leaves the rule unapplied
+    writes.forEach(([absolutePath]) => assertNoOverlay(absolutePath, 'replace in'));
+    for (const [absolutePath, data] of writes) {
+      // Keeping the file's permissions
+      await writeFileAtomic(absolutePath, data, (await fs.promises.stat(absolutePath)).mode);
+    }
+  }
+  toolsLogger.debug('Replacing %s: %d file(s)%s', from, replacements.length, options.apply ? ' written' : '');
+  return replacements;
+}
+
+/**
+ * Format the replacements, as a preview or as done
+ */
+export function formatReplacements(
+  from: string,
+  to: string,
+  replacements: FileReplacement[],
+  options: ReplaceOptions = {},
+  skipped: Array<{ relativePath: string; reason: string }> = []
+): string {
+  const skippedLines = skipped.length > 0
+    ? ['', `Skipped ${skipped.length} file(s):`, ...skipped.map((file) => `  ${file.relativePath} (${file.reason})`)]
+    : [];
+  if (replacements.length === 0) {
+    return [`No occurrences of ${from} found`, ...skippedLines].join('\n');
+  }
+  const total = replacements.reduce((sum, file) => sum + file.count, 0);
+  const lines = [options.apply
+    ? `Replaced ${total} occurrence(s) in ${replacements.length} file(s)`
+    : `Would replace ${total} occurrence(s) in ${replacements.length} file(s); pass apply: true to write them`];
+  if (options.caseAware) {
+    lines.push(`Spellings: ${caseVariants(from, to).map((variant) => `${variant.from} -> ${variant.to}`).join(', ')}`);
+  }
+  for (const file of replacements.slice(0, MAX_LISTED_FILES)) {
+    lines.push('', `${file.relativePath} (${file.count})`);
+    for (const change of file.lines) {
+      lines.push(`  L${change.line}- ${change.before.trim()}`, `  L${change.line}+ ${change.after.trim()}`);
+    }
+    if (file.changedLines > file.lines.length) {
+      lines.push(`  … ${file.changedLines - file.lines.length} more line(s)`);
+    }
+  }
+  if (replacements.length > MAX_LISTED_FILES) {
+    lines.push('', `… ${replacements.length - MAX_LISTED_FILES} more file(s)`);
+  }
+  lines.push(...skippedLines);
+  return lines.join('\n');
+}
@@ -0,0 +1,53 @@
+/**
+ * Tests for the routes tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { listRoutes } from './routes';
+
+describe('routes', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'routes-'));
+    const files: Record<string, string> = {
+      'server/routes.go': [
+        'import "github.com/gin-gonic/gin"',
+        '',
+        'func routes(r *gin.Engine, h *Handler) {',
+        '\tr.GET("/users/:id", h.GetUser)',
+        '\tr.GET("/users/me", h.Me)',
+        '\tr.Any("/*path", notFound)',
+        '}',
+      ].join('\n'),
+      'server/users.go': 'package server\n\nfunc (h *Handler) GetUser(c *gin.Context) {}\n',
+    };
+    for (const [file, content] of Object.entries(files)) {
+      fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, file), content);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should list the routes serving a request, most specific first', async () => {
+    const routesFile = path.join('server', 'routes.go');
+    expect(await listRoutes(workspace, { route: 'GET /users/42' })).toBe([
+      'Routes matching GET /users/42 (2)',
+      `  GET /users/:id → h.GetUser (gin) - ${routesFile}:4, defined at ${path.join('server', 'users.go')}:3`,
+      `  ANY /*path → notFound (gin) - ${routesFile}:6`,
+    ].join('\n'));
+    expect(await listRoutes(workspace, { route: 'POST /users/42', framework: 'gin' })).toContain('ANY /*path');
+    expect(await listRoutes(workspace, { route: '/users/42', framework: 'express' })).toBe('No route matches /users/42');
+  });
+
+  it('should list every route without a request', async () => {
+    const output = await listRoutes(workspace);
+    expect(output.split('\n')[0]).toBe('HTTP routes (3)');
+    expect(output).toContain('GET /users/me → h.Me (gin)');
+  });
+});
@@ -0,0 +1,91 @@
+/**
+ * Routes tool - which function serves a request
+ * Maps HTTP method and path to the handler registered for it, with the place
+ * of the registration and of the handler's declaration, so "what serves
+ * GET /users/42" is one call instead of a search through router setup code
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { findRoutes, parseRouteQuery, routeMatchScore, HandlerDeclaration, Route } from '../workspace/routes.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the routes tool
+ */
+export interface RouteOptions {
+  // Request to match, e.g. "GET /users/42" or "/users/:id"; all routes when absent
+  route?: string;
+  // Only routes taking this method
+  method?: string;
+  path?: string;
+  // Only routes of this framework, e.g. gin or express
+  framework?: string;
+}
+
+/**
+ * Routes listed before the rest is left out
+ */
+const MAX_ROUTES = 200;
+
+/**
+ * Where a handler is declared, when exactly one function has its name
+ * A qualified handler (h.GetUser) is looked up by its last part, preferring
+ * a declaration in the registering file
+ */
+function handlerDeclaration(route: Route, declarations: Map<string, HandlerDeclaration[]>): HandlerDeclaration | undefined {
+  if (!route.handler || route.handler === '(inline)') {
+    return undefined;
+  }
+  const candidates = declarations.get(route.handler.split('.').pop()!) ?? [];
+  const local = candidates.filter((declaration) => declaration.filePath === route.filePath);
+  if (local.length === 1) {
+    return local[0];
+  }
+  return candidates.length === 1 ? candidates[0] : undefined;
+}
+
+function formatRoute(route: Route, declarations: Map<string, HandlerDeclaration[]>): string {
+  const declaration = handlerDeclaration(route, declarations);
+  const defined = declaration ? `, defined at ${declaration.filePath}:${declaration.line}` : '';
+  return `${route.method} ${route.path} → ${route.handler ?? '(unknown handler)'} (${route.framework}) - ${route.filePath}:${route.line}${defined}`;
+}
+
+/**
+ * List the routes matching a request, most specific first, or every route
+ */
+export async function listRoutes(workspaceDir: string, options: RouteOptions = {}): Promise<string> {
+  const query = options.route ? parseRouteQuery(options.route) : undefined;
+  const method = (options.method ?? query?.method)?.toUpperCase();
+  toolsLogger.debug('Listing routes (route: %s, method: %s)', query?.path ?? 'all', method ?? 'any');
+
+  const { routes, declarations } = await findRoutes(workspaceDir, options.path);
+  const byName = new Map<string, HandlerDeclaration[]>();
+  for (const declaration of declarations) {
+    byName.set(declaration.name, [...(byName.get(declaration.name) ?? []), declaration]);
+  }
+
+  const matched = routes
+    .filter((route) => !options.framework || route.framework === options.framework)
+    .filter((route) => !method || route.method === method || route.method === 'ANY')
+    .map((route) => ({ route, score: query ? routeMatchScore(route, query.path) : 0 }))
+    .filter((entry): entry is { route: Route; score: number } => entry.score !== undefined);
+  if (matched.length === 0) {
+    if (query) {
+      return `No route matches ${method ? `${method} ` : ''}${query.path}`;
+    }
+    return routes.length === 0 ? 'No HTTP routes found' : 'No routes match the filters';
+  }
+  if (query) {
+    // Most specific first; a method of its own before ANY
+    matched.sort((a, b) => a.score - b.score || Number(a.route.method === 'ANY') - Number(b.route.method === 'ANY'));
+  }
+
+  const title = query ? `Routes matching ${method ? `${method} ` : ''}${query.path}` : 'HTTP routes';
+  const lines = [`${title} (${matched.length})`];
+  lines.push(...matched.slice(0, MAX_ROUTES).map(({ route }) => `  ${formatRoute(route, byName)}`));
+  if (matched.length > MAX_ROUTES) {
+    lines.push(`  … ${matched.length - MAX_ROUTES} more; pass a path or route to narrow`);
+  }
+  return lines.join('\n');
+}
@@ -0,0 +1,309 @@
+/**
+ * Tests for the search tool's output modes
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { execFileSync } from 'child_process';
+import { resultTemplate, searchCode, searchCodeResult } from './search';
+import { RESULT_FIELDS, ResultTemplate } from '../config/config';
+import { MemoryBudget } from '../workspace/memory';
+import { flattenDocumentSymbols } from './symbols';
+import { parseTypeScriptDeclarations } from '../symbols/typescript';
+
+describe('search_code extract', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'extract-'));
+    fs.writeFileSync(path.join(workspace, 'a.go'),
+      'return errors.New("not found")\nreturn errors.New("denied")\n');
+    fs.writeFileSync(path.join(workspace, 'b.go'), 'return errors.New("not found")\nfmt.Println("x")\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should return the capture groups of each match', async () => {
+    const result = await searchCode(workspace, 'errors\\.New\\("([^"]*)"\\)', { regex: true, extract: true });
+    expect(result).toBe([
+      'Found 3 match(es) in 2 file(s)',
+      '',
+      'a.go:1: not found',
+      'a.go:2: denied',
+      'b.go:1: not found',
+      '',
+      '',
+    ].join('\n'));
+  });
+
+  it('should count distinct values, or whole matches without groups', async () => {
+    const distinct = await searchCode(workspace, 'errors\\.New\\("([^"]*)"\\)', { regex: true, extract: true, distinct: true });
+    expect(distinct).toBe([
+      'Extracted 2 distinct value(s) from 3 match(es) in 2 file(s)',
+      '',
+      '2  not found  (first: a.go:1)',
+      '1  denied  (first: a.go:2)',
+      '',
+      '',
+    ].join('\n'));
+    const whole = await searchCode(workspace, 'ERRORS.new', { extract: true, distinct: true });
+    expect(whole.split('\n')[2]).toBe('3  errors.New  (first: a.go:1)');
+  });
+});
+
+describe('search_code partial results', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'partial-'));
+    fs.writeFileSync(path.join(workspace, 'a.go'), 'needle\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should flag output cut short by a limit as partial', async () => {
+    const partial = await searchCodeResult(workspace, 'needle', { memory: new MemoryBudget(1) });
+    expect(partial.partial).toBe(true);
+    expect(partial.text).toContain('results are partial');
+    const complete = await searchCodeResult(workspace, 'needle', { memory: new MemoryBudget(Infinity) });
+    expect(complete.partial).toBe(false);
+    expect(complete.text).toContain('Found 1 match(es) in 1 file(s)');
+  });
+
+  it('should not flag a complete result that mentions a limit as partial', async () => {
+    fs.writeFileSync(path.join(workspace, 'a.go'), '// search timed out\n');
+    expect((await searchCodeResult(workspace, 'timed out')).partial).toBe(false);
+  });
+});
+
+describe('search_code sort', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'sort-'));
+    const files: Array<[string, string, number]> = [
+      ['a.go', 'foo\n', 100],
+      ['b.go', 'foo foo foo\nfoo and more text\n', 300],
+      ['c.go', 'foo\nfoo\n', 200],
+    ];
+    for (const [file, content, age] of files) {
+      fs.writeFileSync(path.join(workspace, file), content);
+      const time = Date.now() / 1000 - age;
+      fs.utimesSync(path.join(workspace, file), time, time);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  const files = async (options: Record<string, unknown>) =>
+    (await searchCode(workspace, 'foo', options)).split('\n').filter((line) => line.endsWith('.go'));
+
+  it('should order files by each key, keeping line order within a file', async () => {
+    expect(await files({ sort: 'mtime' })).toEqual(['a.go', 'c.go', 'b.go']);
+    expect(await files({ sort: 'mtime', order: 'asc' })).toEqual(['b.go', 'c.go', 'a.go']);
+    expect(await files({ sort: 'match_count' })).toEqual(['b.go', 'c.go', 'a.go']);
+    expect(await files({ sort: 'size', order: 'asc' })).toEqual(['a.go', 'c.go', 'b.go']);
+    expect(await files({ sort: 'path', order: 'desc' })).toEqual(['c.go', 'b.go', 'a.go']);
+    const lines = (await searchCode(workspace, 'foo', { sort: 'match_count' })).split('\n').filter((line) => line.startsWith('L'));
+    expect(lines.slice(0, 4).map((line) => line.split(':').slice(0, 2).join(':'))).toEqual(['L1:C1', 'L1:C5', 'L1:C9', 'L2:C1']);
+  });
+
+  it('should report exactly how many matches maxResults left out with countOmitted', async () => {
+    const result = await searchCode(workspace, 'foo', { sort: 'path', maxResults: 3, countOmitted: true });
+    expect(result.split('\n')[0]).toBe('Found 3 match(es) in 2 file(s) (showing 3 of 7; 4 match(es) in 2 file(s) omitted, ' +
+      'raise maxResults or narrow the pattern or path)');
+    expect(result).toContain('Matches: 2 (2 more omitted)');
+    expect(result).toContain('Omitted 2 match(es) in 1 file(s) not shown (raise maxResults to see them):\nc.go (2)\n');
+    expect(await searchCode(workspace, 'foo', { maxResults: 7, countOmitted: true })).not.toContain('omitted');
+    expect((await searchCode(workspace, 'foo', { maxResults: 3 })).split('\n')[0]).toBe('Found 3 match(es) in 2 file(s) (results truncated at 3; narrow the pattern or path)');
+  });
+
+  it('should list each line once with its match ranges when highlighting', async () => {
+    const result = await searchCode(workspace, 'foo', { sort: 'path', highlight: true });
+    expect(result.split('\n')[0]).toBe('Found 7 match(es) in 3 file(s)');
+    expect(result).toContain('b.go\nContent hash: ');
+    expect(result).toContain('Matches: 4\n\nL1:C1-4,C5-8,C9-12: foo foo foo\nL2:C1-4: foo and more text\n');
+  });
+
+  it('should reject unknown sort keys', async () => {
+    await expect(searchCode(workspace, 'foo', { sort: 'name' as never })).rejects.toThrow('Unknown sort "name"');
+  });
+
+  it('should sort before maxResults keeps the first matches', async () => {
+    // The newest files, not the first ones scanned
+    expect(await files({ sort: 'mtime', maxResults: 2 })).toEqual(['a.go', 'c.go']);
+    expect(await files({ sort: 'size', maxResults: 2 })).toEqual(['b.go']);
+    expect(await files({ sort: 'path', order: 'desc', maxResults: 2 })).toEqual(['c.go']);
+    const counted = await searchCode(workspace, 'foo', { sort: 'match_count', maxResults: 5 });
+    expect(counted.split('\n')[0]).toBe('Found 5 match(es) in 2 file(s) (results truncated at 5; narrow the pattern or path)');
+    expect(counted.split('\n').filter((line) => line.endsWith('.go'))).toEqual(['b.go', 'c.go']);
+  });
+});
+
+describe('search_code dedupe', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'dedupe-'));
+    for (const [file, content] of [['lib/util.go', 'func Helper() {}\n'], ['third_party/lib/util.go', 'func Helper() {}\n'],
+      ['mirror/deep/util.go', 'func Helper() {}\n'], ['other.go', 'func Helper() { changed }\n']]) {
+      fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, file), content);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should report identical files once under the canonical copy', async () => {
+    const result = await searchCode(workspace, 'Helper', { sort: 'path' });
+    expect(result.split('\n')[0]).toBe('Found 2 match(es) in 2 file(s) (2 match(es) in identical copies not repeated)');
+    const section = result.split('---\n\n')[1];
+    expect(section).toContain(path.join('lib', 'util.go'));
+    expect(section).toContain(`Identical copies (matches not repeated): ${path.join('mirror', 'deep', 'util.go')}, ${path.join('third_party', 'lib', 'util.go')}`);
+    expect((await searchCode(workspace, 'Helper', { dedupe: false })).split('\n')[0]).toBe('Found 4 match(es) in 4 file(s)');
+  });
+});
+
+describe('search_code kind filter', () => {
+  let workspace: string;
+  const symbols = async (filePath: string) => flattenDocumentSymbols(parseTypeScriptDeclarations(fs.readFileSync(filePath, 'utf-8')));
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'kind-'));
+    fs.writeFileSync(path.join(workspace, 'users.ts'),
+      'export function addUser(name: string) {\n  return name;\n}\nexport class UserStore {\n  addUser() {}\n}\n');
+    fs.writeFileSync(path.join(workspace, 'main.ts'), 'addUser("a");\naddUser("b");\nconst addUserLater = 1;\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should keep only matches on declaration lines of the kinds', async () => {
+    const result = await searchCode(workspace, 'addUser', { kind: ['Function', 'Method'], symbols, sort: 'path' });
+    expect(result.split('\n')[0]).toBe('Found 2 match(es) in 1 file(s) (Function/Method declarations only, 3 other match(es) left out)');
+    expect(result.split('\n').filter((line) => line.startsWith('L'))).toEqual([
+      'L1:C17: export function addUser(name: string) {',
+      'L5:C3: addUser() {}',
+    ]);
+    expect(await searchCode(workspace, 'addUser', { kind: ['Struct'], symbols }))
+      .toBe('No matches found for: addUser (2 file(s) scanned, 5 match(es) checked, none on a Struct declaration)');
+    await expect(searchCode(workspace, 'addUser', { kind: ['Function'] })).rejects.toThrow('kind filters need a symbol provider');
+  });
+});
+
+describe('search_code git status', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'git-status-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  // Runs where git is installed
+  const gitInstalled = (() => {
+    try {
+      execFileSync('git', ['--version'], { stdio: 'ignore' });
+      return true;
+    } catch {
+      return false;
+    }
+  })();
+  (gitInstalled ? it : it.skip)('should label each file with its uncommitted changes', async () => {
+    for (const name of ['clean.ts', 'edited.ts', 'staged.ts', 'both.ts']) {
+      fs.writeFileSync(path.join(workspace, name), `needle in ${name}\n`);
+    }
+    expect((await searchCode(workspace, 'needle', { gitStatus: true })).split('\n')[0])
+      .toBe('Found 4 match(es) in 4 file(s) (not a git repository, so no git status)');
+    const git = (...args: string[]) => execFileSync('git', args, { cwd: workspace, stdio: 'ignore' });
+    git('init', '-q');
+    git('add', '.');
+    git('-c', 'user.name=test', '-c', 'user.email=test@example.com', 'commit', '-qm', 'init');
+    fs.appendFileSync(path.join(workspace, 'edited.ts'), 'edited\n');
+    fs.appendFileSync(path.join(workspace, 'staged.ts'), 'staged\n');
+    fs.appendFileSync(path.join(workspace, 'both.ts'), 'staged\n');
+    git('add', 'staged.ts', 'both.ts');
+    fs.appendFileSync(path.join(workspace, 'both.ts'), 'edited\n');
+    fs.writeFileSync(path.join(workspace, 'new.ts'), 'needle\n');
+
+    const result = await searchCode(workspace, 'needle', { gitStatus: true, sort: 'path' });
+    expect(result.split('\n')[0]).toBe('Found 5 match(es) in 5 file(s) (4 of 5 file(s) with uncommitted changes)');
+    const labels = result.split('---\n\n').slice(1).map((section) => section.split('\n')[0] + ': ' + section.match(/^Git: (.*)$/m)![1]);
+    expect(labels).toEqual([
+      'both.ts: staged, modified',
+      'clean.ts: committed',
+      'edited.ts: modified',
+      'new.ts: untracked',
+      'staged.ts: staged',
+    ]);
+  });
+});
+
+describe('search_code result templates', () => {
+  let workspace: string;
+
+  const template = (settings: Partial<ResultTemplate>): ResultTemplate =>
+    ({ format: 'plain', fields: [...RESULT_FIELDS], context: 0, ...settings });
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'templates-'));
+    fs.writeFileSync(path.join(workspace, 'main.go'), [
+      'package main',
+      '',
+      'func main() {',
+      '\tneedle()',
+      '\tneedle()',
+      '}',
+      '',
+      '',
+      'func needle() {}',
+    ].join('\n'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should pick the profile of the client, or the default', () => {
+    const templates = { default: template({}), Cursor: template({ format: 'markdown' }) };
+    expect(resultTemplate(templates, 'cursor')).toBe(templates.Cursor);
+    expect(resultTemplate(templates, 'zed')).toBe(templates.default);
+    expect(resultTemplate(undefined, 'zed')).toBeUndefined();
+    expect(() => resultTemplate(templates, 'zed', true)).toThrow('No result template named zed (configured: default, Cursor)');
+  });
+
+  it('should show only the template fields, with context lines', async () => {
+    const result = await searchCode(workspace, 'needle', { template: template({ fields: ['count'], context: 1 }) });
+    expect(result.split('---\n\n')[1]).toBe([
+      'main.go',
+      'Matches: 3',
+      '',
+      'L3- func main() {',
+      'L4: \tneedle()',
+      'L5: \tneedle()',
+      'L6- }',
+      '--',
+      'L8- ',
+      'L9: func needle() {}',
+      '',
+      '',
+    ].join('\n'));
+  });
+
+  it('should render Markdown with fenced code', async () => {
+    const result = await searchCode(workspace, 'needle', { maxResults: 1, template: template({ format: 'markdown', fields: ['column'] }) });
+    expect(result.split('\n\n').slice(1).join('\n\n')).toBe('### main.go\n\n```go\nL4:C2: needle()\n```\n\n');
+  });
+});
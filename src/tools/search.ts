@@ -0,0 +1,763 @@
+/**
+ * Search tool - find literal strings or regular expressions in the workspace
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { mergeLineMatches, searchLexical, LexicalSearchOptions, LexicalMatch } from '../search/lexical.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { searchDuration, searchFilesScanned } from '../metrics/metrics.js';
+import { enrichMatches } from './enrich.js';
+import { attachDirectoryDocs } from './dirdocs.js';
+import { attachLastChanges, formatLastChange } from './linehistory.js';
+import { attachCoverage, formatCoverageSummary, formatLineCoverage } from './coverage.js';
+import { attachAstPaths, grammarPackages } from '../search/astpath.js';
+import { workingChanges } from '../git/git.js';
+import { currentBudget } from '../workspace/budget.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { RESULT_FIELDS, ResultField, ResultTemplate } from '../config/config.js';
+import { FlatSymbol } from './symbols.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Group matches by file, preserving order
+ */
+export function groupMatchesByFile(matches: LexicalMatch[]): Map<string, LexicalMatch[]> {
+  const byFile = new Map<string, LexicalMatch[]>();
+  for (const match of matches) {
+    if (!byFile.has(match.filePath)) {
+      byFile.set(match.filePath, []);
+    }
+    byFile.get(match.filePath)!.push(match);
+  }
+  return byFile;
+}
+
+/**
+ * Options of searchCode: the search, and how matches are reported
+ */
+export interface SearchCodeOptions extends LexicalSearchOptions {
+  // Report the capture groups of each match (the whole match without groups) instead of its line
+  extract?: boolean;
+  // With extract, list each distinct value once with its number of matches
+  distinct?: boolean;
+  // Order files by path, modification time, number of matches, or size (default: scan order)
+  sort?: SearchSort;
+  // Sort direction (default: ascending for path, descending otherwise)
+  order?: 'asc' | 'desc';
+  // Report files with identical content once, listing the copies (default: true)
+  dedupe?: boolean;
+  // Report each matching line once, with the column range of every match on it
+  highlight?: boolean;
+  // Symbols of a file; when set, each match gets its enclosing symbol and the file its module
+  symbols?: (filePath: string) => Promise<FlatSymbol[]>;
+  // Annotate each file with its uncommitted changes: staged, modified, untracked, or committed
+  gitStatus?: boolean;
+  // Only matches on the declaration line of a symbol of one of these kinds (e.g. "Function"); needs symbols
+  kind?: string[];
+  // Describe the directory of each file from its nearest README or package comment
+  directoryDocs?: boolean;
+  // Attach the hash, date, and subject of the last commit touching each match line
+  lastChange?: boolean;
+  // Coverage profile (Go cover profile or lcov tracefile) to mark each match line covered or not by
+  coverage?: string;
+  // Report the tree-sitter node path of each match, e.g. function_declaration > call_expression
+  astPath?: boolean;
+  // How the matches are rendered (default: plain, every field, no context lines)
+  template?: ResultTemplate;
+}
+
+/**
+ * Keys search results can be sorted by
+ */
+export type SearchSort = 'path' | 'mtime' | 'match_count' | 'size';
+
+const SEARCH_SORTS: SearchSort[] = ['path', 'mtime', 'match_count', 'size'];
+
+/**
+ * Matches scanned for distinct extraction unless maxResults is set
+ */
+const DISTINCT_EXTRACT_MATCHES = 10000;
+
+/**
+ * Matches scanned for a kind filter; declarations are picked from these
+ */
+const KIND_FILTER_MATCHES = 2000;
+
+/**
+ * Matches scanned to sort by match_count, which is only known once files are scanned
+ */
+const MATCH_COUNT_SORT_MATCHES = 10000;
+
+/**
+ * Receives partial results while a search is running
+ */
+export type SearchProgressCallback = (message: string, progress: number, total?: number) => void;
+
+/**
+ * Marks output cut short by the time limit
+ */
+const TIMEOUT_NOTE = 'search timed out';
+
+/**
+ * Marks output cut short by the memory budget
+ */
+const MEMORY_NOTE = 'memory budget reached';
+
+/**
+ * Formatted search output, and whether it is partial
+ */
+export interface SearchCodeResult {
+  text: string;
+  // The search timed out, ran out of memory, or spent its budget; partial output must not be served from the query cache
+  partial: boolean;
+}
+
+/**
+ * Minimum time between partial result batches
+ */
+const PROGRESS_INTERVAL_MS = 100;
+
+/**
+ * Location prefix for matches in notebook cells, e.g. "Cell 3 (code) "
+ */
+function cellLabel(match: LexicalMatch): string {
+  return match.cell ? `Cell ${match.cell.index} (${match.cell.kind}) ` : '';
+}
+
+/**
+ * Location of a match, e.g. "L3:C5", or "L3:C5-11,C20-26" with every range on a merged line
+ */
+function matchLocation(match: LexicalMatch, column = true): string {
+  if (!column) {
+    return `${cellLabel(match)}L${match.line}`;
+  }
+  const columns = match.ranges ? match.ranges.map((range) => `C${range.start}-${range.end}`).join(',') : `C${match.column}`;
+  return `${cellLabel(match)}L${match.line}:${columns}`;
+}
+
+/**
+ * The template of a client profile, or of the default profile; undefined when neither is configured
+ * An explicitly requested profile must exist
+ */
+export function resultTemplate(
+  templates: Record<string, ResultTemplate> | undefined,
+  profile: string | undefined,
+  requested = false
+): ResultTemplate | undefined {
+  const names = Object.keys(templates ?? {});
+  const name = profile && names.find((candidate) => candidate.toLowerCase() === profile.toLowerCase());
+  if (requested && !name) {
+    throw new ToolError('not-found', `No result template named ${profile} (configured: ${names.join(', ') || 'none'})`);
+  }
+  return templates?.[name || 'default'];
+}
+
+/**
+ * Text of a match line: clipped lines with their markers, full lines trimmed,
+ * or only trimmed at the end when context lines keep the indentation
+ */
+function matchText(match: LexicalMatch, column: boolean): string {
+  if (match.byteOffset !== undefined) {
+    return `Binary match at byte offset ${match.byteOffset} (0x${match.byteOffset.toString(16)}), ${match.length} byte(s)`;
+  }
+  if (match.clipped) {
+    const { clipped } = match;
+    const before = clipped.windowStart > 1 ? '…' : '';
+    const after = clipped.windowStart - 1 + match.lineText.length < clipped.lineLength ? '…' : '';
+    return `${matchLocation(match, column)}: ${before}${match.lineText}${after} ` +
+      `(line clipped: ${clipped.lineLength} chars, match at byte ${clipped.matchByteOffset})`;
+  }
+  return `${matchLocation(match, column)}: ${match.context ? match.lineText.trimEnd() : match.lineText.trim()}`;
+}
+
+/**
+ * One file's match section in either format
+ */
+interface FileSection {
+  header: string[];
+  // Section and symbol lines interleaved with runs of match and context lines
+  body: Array<{ note: string } | { lines: string[] }>;
+}
+
+/**
+ * Lay out the sections of matches, keeping only the template's fields
+ */
+function buildSections(matches: LexicalMatch[], omitted: Map<string, number> | undefined, fields: Set<ResultField>): Map<string, FileSection> {
+  const sections = new Map<string, FileSection>();
+  // A directory summary is shown with the first file it applies to
+  const described = new Set<string>();
+  for (const [filePath, fileMatches] of groupMatchesByFile(matches).entries()) {
+    const header: string[] = [];
+    const first = fileMatches[0];
+    if (first.contentHash && fields.has('hash')) {
+      header.push(`Content hash: ${first.contentHash}`);
+    }
+    if (first.duplicates && fields.has('copies')) {
+      header.push(`Identical copies (matches not repeated): ${first.duplicates.join(', ')}`);
+    }
+    if (first.gitStatus && fields.has('git')) {
+      header.push(`Git: ${first.gitStatus}`);
+    }
+    if (first.module && fields.has('module')) {
+      header.push(`Module: ${first.module}`);
+    }
+    const doc = first.directoryDoc;
+    if (doc && !described.has(doc.source) && fields.has('directory')) {
+      described.add(doc.source);
+      header.push(`About ${doc.directory}: ${doc.summary} (${doc.source})`);
+    }
+    if (fields.has('count')) {
+      const more = omitted?.get(filePath);
+      const count = fileMatches.reduce((sum, match) => sum + (match.ranges?.length ?? 1), 0);
+      header.push(`Matches: ${count}${more ? ` (${more} more omitted)` : ''}`);
+    }
+
+    const body: FileSection['body'] = [];
+    const append = (line: string) => {
+      const last = body[body.length - 1];
+      if (last && 'lines' in last) {
+        last.lines.push(line);
+      } else {
+        body.push({ lines: [line] });
+      }
+    };
+    let section: string | undefined;
+    let enclosing: string | undefined;
+    // Last line shown, so overlapping context is not repeated
+    let shown = 0;
+    for (const [i, match] of fileMatches.entries()) {
+      // Documentation matches are grouped under their heading chain
+      const heading = match.headings?.join(' > ');
+      if (heading !== undefined && heading !== section && fields.has('section')) {
+        body.push({ note: `Section: ${heading}` });
+      }
+      section = heading;
+      // Matches in the same symbol share one context line
+      const symbol = match.enclosing;
+      const context = symbol && `In ${symbol.kind} ${symbol.name} (L${symbol.line})${symbol.signature ? `: ${symbol.signature}` : ''}`;
+      if (context && context !== enclosing && fields.has('symbol')) {
+        body.push({ note: context });
+      }
+      enclosing = context;
+      if (match.context) {
+        const start = match.line - match.context.before.length;
+        if (shown > 0 && start > shown + 1) {
+          append('--');
+        }
+        match.context.before.forEach((text, i) => {
+          if (start + i > shown) {
+            append(`L${start + i}- ${text.trimEnd()}`);
+          }
+        });
+      }
+      // Context never reaches a match line, so every match is shown
+      append(matchText(match, fields.has('column')));
+      if (match.astPath && match.astPath.length > 0 && fields.has('ast')) {
+        append(`  AST: ${match.astPath.join(' > ')}`);
+      }
+      if (match.lastChange && fields.has('change')) {
+        append(`  Last change: ${formatLastChange(match.lastChange)}`);
+      }
+      if (match.coverage && fields.has('coverage')) {
+        append(`  Coverage: ${formatLineCoverage(match.coverage)}`);
+      }
+      shown = Math.max(shown, match.line);
+      if (match.context) {
+        // Context stops before the next match, which shows its own line
+        const next = fileMatches[i + 1];
+        match.context.after.forEach((text, i) => {
+          const line = match.line + 1 + i;
+          if (line > shown && (!next || line < next.line)) {
+            append(`L${line}- ${text.trimEnd()}`);
+            shown = line;
+          }
+        });
+      }
+    }
+    sections.set(filePath, { header, body });
+  }
+  return sections;
+}
+
+/**
+ * Format matches as per-file sections
+ * Files in omitted had more matches than were returned; their sections say how many
+ */
+export function formatMatchSections(matches: LexicalMatch[], omitted?: Map<string, number>, template?: ResultTemplate): string {
+  const fields = new Set<ResultField>(template?.fields ?? RESULT_FIELDS);
+  const sections = buildSections(matches, omitted, fields);
+  if (template?.format === 'markdown') {
+    return formatMarkdownSections(sections);
+  }
+  let output = '';
+  for (const [filePath, { header, body }] of sections) {
+    output += `---\n\n${filePath}\n`;
+    output += header.map((line) => `${line}\n`).join('');
+    output += header.length > 0 ? '\n' : '';
+    for (const part of body) {
+      output += 'note' in part ? `${part.note}\n` : part.lines.map((line) => `${line}\n`).join('');
+    }
+    output += '\n';
+  }
+  return output;
+}
+
+/**
+ * Format sections as Markdown: a heading per file, its fields as a list, and lines in fenced code
+ */
+function formatMarkdownSections(sections: Map<string, FileSection>): string {
+  let output = '';
+  for (const [filePath, { header, body }] of sections) {
+    output += `### ${filePath}\n\n`;
+    if (header.length > 0) {
+      output += header.map((line) => `- ${line}\n`).join('') + '\n';
+    }
+    const language = detectLanguageId(filePath);
+    for (const part of body) {
+      if ('note' in part) {
+        output += `*${part.note}*\n\n`;
+        continue;
+      }
+      const fence = part.lines.some((line) => line.includes('```')) ? '````' : '```';
+      output += `${fence}${language === 'plaintext' ? '' : language}\n${part.lines.join('\n')}\n${fence}\n\n`;
+    }
+  }
+  return output;
+}
+
+/**
+ * Directories that hold copies of code from elsewhere
+ */
+const COPY_DIRS = new Set(['vendor', 'node_modules', 'third_party', 'third-party', 'external', 'deps']);
+
+/**
+ * Collapse files with identical content (vendored copies, mirrors, symlinked
+ * trees) into one canonical file whose first match lists the others
+ * The canonical file is outside copy directories if one is, then the
+ * shallowest, then the first found
+ */
+export function collapseDuplicateFiles(matches: LexicalMatch[]): LexicalMatch[] {
+  const byFile = groupMatchesByFile(matches);
+  const byHash = new Map<string, string[]>();
+  for (const [filePath, fileMatches] of byFile) {
+    const hash = fileMatches[0].contentHash;
+    if (hash) {
+      byHash.set(hash, [...(byHash.get(hash) ?? []), filePath]);
+    }
+  }
+  const rank = (filePath: string): [number, number] => {
+    const segments = filePath.split(/[\\/]/);
+    return [segments.some((segment) => COPY_DIRS.has(segment)) ? 1 : 0, segments.length];
+  };
+  const dropped = new Set<string>();
+  const duplicatesOf = new Map<string, string[]>();
+  for (const filePaths of byHash.values()) {
+    if (filePaths.length < 2) {
+      continue;
+    }
+    const canonical = filePaths.reduce((best, filePath) => {
+      const [copyA, depthA] = rank(best);
+      const [copyB, depthB] = rank(filePath);
+      return copyB < copyA || (copyB === copyA && depthB < depthA) ? filePath : best;
+    });
+    const others = filePaths.filter((filePath) => filePath !== canonical);
+    others.forEach((filePath) => dropped.add(filePath));
+    duplicatesOf.set(canonical, others);
+  }
+  if (dropped.size === 0) {
+    return matches;
+  }
+  const result: LexicalMatch[] = [];
+  for (const [filePath, fileMatches] of byFile) {
+    if (dropped.has(filePath)) {
+      continue;
+    }
+    const duplicates = duplicatesOf.get(filePath);
+    result.push(...(duplicates ? [{ ...fileMatches[0], duplicates }, ...fileMatches.slice(1)] : fileMatches));
+  }
+  return result;
+}
+
+/**
+ * Fail unless sort is a known sort key
+ */
+function checkSort(sort: SearchSort): void {
+  if (!SEARCH_SORTS.includes(sort)) {
+    throw new ToolError('invalid-argument', `Unknown sort "${sort}"; use ${SEARCH_SORTS.join(', ')}`);
+  }
+}
+
+/**
+ * Order file paths by a sort key; match_count takes its counts from matchCounts
+ * Archive entries sort by their archive's modification time and size
+ */
+async function orderFilePaths(
+  workspaceDir: string,
+  filePaths: string[],
+  sort: SearchSort,
+  order?: 'asc' | 'desc',
+  matchCounts?: Map<string, number>
+): Promise<string[]> {
+  const keys = new Map<string, number>();
+  if (sort === 'mtime' || sort === 'size') {
+    await Promise.all(filePaths.map(async (filePath) => {
+      try {
+        const stats = await fs.promises.stat(path.join(workspaceDir, filePath.split('!/')[0]));
+        keys.set(filePath, sort === 'mtime' ? stats.mtimeMs : stats.size);
+      } catch (err) {
+        keys.set(filePath, 0); // Overlaid files need not exist on disk
+      }
+    }));
+  } else if (sort === 'match_count') {
+    for (const filePath of filePaths) {
+      keys.set(filePath, matchCounts?.get(filePath) ?? 0);
+    }
+  }
+  const direction = (order ?? (sort === 'path' ? 'asc' : 'desc')) === 'asc' ? 1 : -1;
+  return [...filePaths].sort((a, b) => {
+    const difference = sort === 'path' ? a.localeCompare(b) : keys.get(a)! - keys.get(b)!;
+    // Ties keep path order
+    return direction * difference || a.localeCompare(b);
+  });
+}
+
+/**
+ * Reorder matches file by file; matches within a file keep their line order
+ * Archive entries sort by their archive's modification time and size
+ */
+export async function sortMatchesByFile(
+  workspaceDir: string,
+  matches: LexicalMatch[],
+  sort: SearchSort,
+  order?: 'asc' | 'desc'
+): Promise<LexicalMatch[]> {
+  checkSort(sort);
+  const byFile = groupMatchesByFile(matches);
+  const counts = new Map(Array.from(byFile, ([filePath, fileMatches]) => [filePath, fileMatches.length]));
+  return (await orderFilePaths(workspaceDir, [...byFile.keys()], sort, order, counts)).flatMap((filePath) => byFile.get(filePath)!);
+}
+
+/**
+ * Value extracted from a match: its capture group, its groups joined by tabs,
+ * or the matched text; undefined for binary matches
+ */
+export function extractedValue(match: LexicalMatch): string | undefined {
+  if (match.groups) {
+    return match.groups.join('\t');
+  }
+  if (match.byteOffset !== undefined) {
+    return undefined;
+  }
+  const start = match.column - (match.clipped?.windowStart ?? 1);
+  return match.lineText.substring(start, start + match.length);
+}
+
+/**
+ * Format extracted values, one per match or distinct with counts
+ */
+export function formatExtraction(matches: LexicalMatch[], distinct: boolean): string {
+  if (!distinct) {
+    return matches.flatMap((match) => {
+      const value = extractedValue(match);
+      return value === undefined ? [] : [`${match.filePath}:${match.line}: ${value}\n`];
+    }).join('');
+  }
+  const counts = new Map<string, { count: number; first: LexicalMatch }>();
+  for (const match of matches) {
+    const value = extractedValue(match);
+    if (value === undefined) {
+      continue;
+    }
+    const entry = counts.get(value);
+    if (entry) {
+      entry.count++;
+    } else {
+      counts.set(value, { count: 1, first: match });
+    }
+  }
+  const values = [...counts.entries()].sort((a, b) => b[1].count - a[1].count || a[0].localeCompare(b[0]));
+  const width = String(values[0]?.[1].count ?? 0).length;
+  return values.map(([value, { count, first }]) =>
+    `${String(count).padStart(width)}  ${value}  (first: ${first.filePath}:${first.line})\n`).join('');
+}
+
+/**
+ * List files skipped for size
+ */
+function formatLargeFiles(files: Array<{ filePath: string; size: number }>): string {
+  let output = `---\n\nSkipped ${files.length} file(s) over the size limit (raise maxFileSize to search them):\n`;
+  for (const file of files.slice(0, 20)) {
+    output += `${file.filePath} (${Math.round(file.size / 1024)} KB)\n`;
+  }
+  if (files.length > 20) {
+    output += `... and ${files.length - 20} more\n`;
+  }
+  return output;
+}
+
+/**
+ * List archives that were not searched, or only in part
+ */
+function formatSkippedArchives(archives: Array<{ filePath: string; reason: string }>): string {
+  let output = '---\n\nArchives not fully searched:\n';
+  for (const archive of archives.slice(0, 20)) {
+    output += `${archive.filePath} (${archive.reason})\n`;
+  }
+  if (archives.length > 20) {
+    output += `... and ${archives.length - 20} more\n`;
+  }
+  return output;
+}
+
+/**
+ * List files whose matches were all omitted by maxResults
+ */
+function formatOmittedFiles(files: Array<{ filePath: string; count: number }>): string {
+  const total = files.reduce((sum, file) => sum + file.count, 0);
+  let output = `---\n\nOmitted ${total} match(es) in ${files.length} file(s) not shown (raise maxResults to see them):\n`;
+  for (const file of files.slice(0, 20)) {
+    output += `${file.filePath} (${file.count})\n`;
+  }
+  if (files.length > 20) {
+    output += `... and ${files.length - 20} more\n`;
+  }
+  return output;
+}
+
+/**
+ * Search the workspace and format the matches
+ * When onProgress is given, matches are also sent in batches as they are
+ * found; the returned text still contains the complete result
+ */
+export async function searchCode(
+  workspaceDir: string,
+  pattern: string,
+  options: SearchCodeOptions = {},
+  index?: TrigramIndex,
+  onProgress?: SearchProgressCallback
+): Promise<string> {
+  return (await searchCodeResult(workspaceDir, pattern, options, index, onProgress)).text;
+}
+
+/**
+ * Search the workspace and format the matches, flagging partial output
+ */
+export async function searchCodeResult(
+  workspaceDir: string,
+  pattern: string,
+  options: SearchCodeOptions = {},
+  index?: TrigramIndex,
+  onProgress?: SearchProgressCallback
+): Promise<SearchCodeResult> {
+  toolsLogger.debug('Searching for %s (regex: %s)', pattern, options.regex ?? false);
+
+  let pending: LexicalMatch[] = [];
+  let lastSent = 0;
+  const { extract, distinct, sort, order, dedupe, highlight, symbols, kind, gitStatus, directoryDocs, lastChange, coverage, astPath, template, ...searchOptions } = options;
+  const lines = (matches: LexicalMatch[]) => highlight ? mergeLineMatches(matches) : matches;
+  const kinds = kind && kind.length > 0 ? kind : undefined;
+  if (kinds && !symbols) {
+    throw new ToolError('unsupported', 'kind filters need a symbol provider');
+  }
+  if (template?.maxLineLength && searchOptions.maxLineLength === undefined) {
+    searchOptions.maxLineLength = template.maxLineLength;
+  }
+  if (template && !extract) {
+    searchOptions.contextLines = template.context;
+  }
+  if (extract && distinct && searchOptions.maxResults === undefined) {
+    searchOptions.maxResults = DISTINCT_EXTRACT_MATCHES;
+  }
+  // Most matches are uses, so declarations are looked for among many more than are shown
+  const shown = searchOptions.maxResults ?? 100;
+  if (kinds) {
+    searchOptions.maxResults = Math.max(shown, KIND_FILTER_MATCHES);
+    searchOptions.countOmitted = false;
+  }
+  // Files are sorted before maxResults keeps the first matches; match counts
+  // are only known after the scan, so those matches are sorted and then cut
+  const sortAfterScan = sort === 'match_count';
+  if (sort) {
+    checkSort(sort);
+  }
+  if (sortAfterScan) {
+    searchOptions.maxResults = Math.max(searchOptions.maxResults ?? shown, MATCH_COUNT_SORT_MATCHES);
+    searchOptions.countOmitted = false;
+  } else if (sort) {
+    searchOptions.orderFiles = (filePaths) => orderFilePaths(workspaceDir, filePaths, sort, order);
+  }
+  // Partial results are match lines; extraction and kind filters report once they are done
+  if (onProgress && !extract && !kinds) {
+    searchOptions.onMatches = (batch, filesScanned, totalFiles) => {
+      pending.push(...batch);
+      const now = Date.now();
+      if (now - lastSent >= PROGRESS_INTERVAL_MS) {
+        lastSent = now;
+        onProgress(`Partial results (${filesScanned}/${totalFiles} files scanned):\n\n${formatMatchSections(lines(pending), undefined, template)}`,
+          filesScanned, totalFiles);
+        pending = [];
+      }
+    };
+  }
+
+  const start = Date.now();
+  const result = await searchLexical(workspaceDir, pattern, searchOptions, index);
+  searchDuration.observe({ kind: options.regex ? 'regex' : 'literal' }, (Date.now() - start) / 1000);
+  searchFilesScanned.inc({}, result.filesScanned);
+
+  let notes = '';
+  let kindNote = '';
+  // The scan stopped at its limit, so a match_count sort only ranks the matches found
+  const sortCapped = sortAfterScan && result.truncated ? result.matches.length : 0;
+  if (kinds) {
+    const checked = result.matches.length;
+    await enrichMatches(workspaceDir, result.matches, symbols!);
+    const declarations = result.matches.filter((match) =>
+      match.enclosing && match.enclosing.line === match.line && kinds.includes(match.enclosing.kind));
+    kindNote = ` (${kinds.join('/')} declarations only, ${checked - declarations.length} other match(es) left out` +
+      (result.truncated ? `; only the first ${checked} matches were checked, narrow the pattern or path)` : ')');
+    if (checked > 0) {
+      notes += `, ${checked} match(es) checked, none on a ${kinds.join('/')} declaration`;
+    }
+    result.truncated = declarations.length > shown;
+    result.matches = sortAfterScan ? declarations : declarations.slice(0, shown);
+  }
+  if (sortAfterScan) {
+    result.matches = await sortMatchesByFile(workspaceDir, result.matches, sort!, order);
+    result.truncated = result.matches.length > shown || sortCapped > 0;
+    result.matches = result.matches.slice(0, shown);
+  }
+  if (result.binarySkipped > 0) {
+    notes += `, ${result.binarySkipped} binary file(s) skipped`;
+  }
+  if (result.largeFilesSkipped.length > 0) {
+    notes += `, ${result.largeFilesSkipped.length} large file(s) skipped`;
+  }
+  if (result.archivesSkipped.length > 0) {
+    notes += `, ${result.archivesSkipped.length} archive(s) not fully searched`;
+  }
+  if (result.generatedSkipped > 0) {
+    notes += `, ${result.generatedSkipped} generated file(s) skipped`;
+  }
+  if (result.buildExcluded > 0) {
+    notes += `, ${result.buildExcluded} Go file(s) excluded by build constraints`;
+  }
+  if (result.fixturesSkipped > 0) {
+    notes += `, ${result.fixturesSkipped} test fixture(s) skipped (scope: all to search them)`;
+  }
+  if (options.scope === 'comments') {
+    notes += ', matching only comments and docstrings';
+  }
+  if (result.truncatedByTimeout) {
+    notes += `; ${TIMEOUT_NOTE} after ${options.timeoutMs}ms, results are partial`;
+  }
+  if (result.truncatedByMemory) {
+    notes += `; ${MEMORY_NOTE}, results are partial`;
+  }
+  if (result.truncatedByBudget) {
+    notes += `; ${result.truncatedByBudget}, results are partial`;
+    currentBudget()?.markReported();
+  }
+  const partial = result.truncatedByTimeout || result.truncatedByMemory || result.truncatedByBudget !== undefined;
+
+  if (result.matches.length === 0) {
+    let output = `No matches found for: ${pattern} (${result.filesScanned} file(s) scanned${notes})`;
+    if (result.largeFilesSkipped.length > 0) {
+      output += '\n\n' + formatLargeFiles(result.largeFilesSkipped);
+    }
+    if (result.archivesSkipped.length > 0) {
+      output += '\n\n' + formatSkippedArchives(result.archivesSkipped);
+    }
+    return { text: output, partial };
+  }
+
+  const found = result.matches.length;
+  if (dedupe !== false) {
+    result.matches = collapseDuplicateFiles(result.matches);
+  }
+  if (symbols && !extract && !kinds) {
+    await enrichMatches(workspaceDir, result.matches, symbols);
+  }
+  if (directoryDocs && !extract) {
+    await attachDirectoryDocs(workspaceDir, result.matches);
+  }
+  let astNote = '';
+  if (astPath && !extract) {
+    const missing = await attachAstPaths(workspaceDir, result.matches);
+    if (missing.length > 0) {
+      astNote = ` (no syntax paths for ${missing.join(', ')}: install ${missing.map(grammarPackages).join(', ')})`;
+    }
+  }
+  let gitNote = '';
+  if (gitStatus) {
+    const changes = await workingChanges(workspaceDir);
+    if (changes) {
+      // Archive entries take the status of their archive
+      for (const match of result.matches) {
+        match.gitStatus = changes.get(match.filePath.split('!/')[0]) ?? 'committed';
+      }
+      const files = groupMatchesByFile(result.matches);
+      const changed = Array.from(files.values()).filter((fileMatches) => fileMatches[0].gitStatus !== 'committed').length;
+      gitNote = ` (${changed} of ${files.size} file(s) with uncommitted changes)`;
+    } else {
+      gitNote = ' (not a git repository, so no git status)';
+    }
+  }
+  if (lastChange && !extract && !await attachLastChanges(workspaceDir, result.matches)) {
+    gitNote += ' (not a git repository, so no line history)';
+  }
+  let coverageNote = '';
+  if (coverage && !extract) {
+    coverageNote = formatCoverageSummary(await attachCoverage(workspaceDir, result.matches, coverage));
+  }
+  if (sort) {
+    result.matches = await sortMatchesByFile(workspaceDir, result.matches, sort, order);
+  }
+  const byFile = groupMatchesByFile(result.matches);
+  const commentNote = options.scope === 'comments' ? ' in comments and docstrings' : '';
+  let output = `Found ${result.matches.length} match(es)${commentNote} in ${byFile.size} file(s)${kindNote}${gitNote}${coverageNote}${astNote}`;
+  if (extract && distinct) {
+    const values = new Set(result.matches.map(extractedValue).filter((value) => value !== undefined));
+    output = `Extracted ${values.size} distinct value(s) from ${result.matches.length} match(es) in ${byFile.size} file(s)`;
+  }
+  if (result.matches.length < found) {
+    output += ` (${found - result.matches.length} match(es) in identical copies not repeated)`;
+  }
+  const omitted = result.omittedMatches ?? 0;
+  if (result.truncated && result.omittedByFile) {
+    output += ` (showing ${found} of ${found + omitted}; ${omitted} match(es) in ${result.omittedByFile.length} file(s) omitted, ` +
+      'raise maxResults or narrow the pattern or path)';
+  } else if (result.truncated) {
+    output += ` (results truncated at ${found}; narrow the pattern or path)`;
+  }
+  if (sortCapped > 0) {
+    output += ` (sorted by ${sort} among the first ${sortCapped} matches only)`;
+  }
+  if (result.truncatedByTimeout) {
+    output += ` (${TIMEOUT_NOTE} after ${options.timeoutMs}ms after scanning ${result.filesScanned} file(s); results are partial)`;
+  }
+  if (result.truncatedByMemory) {
+    output += ` (${MEMORY_NOTE} after scanning ${result.filesScanned} file(s); results are partial)`;
+  }
+  if (result.truncatedByBudget) {
+    output += ` (${result.truncatedByBudget} after scanning ${result.filesScanned} file(s); results are partial)`;
+  }
+  const omittedByFile = new Map((result.omittedByFile ?? []).map((file) => [file.filePath, file.count]));
+  output += '\n\n' + (extract ? formatExtraction(result.matches, distinct ?? false) + '\n' : formatMatchSections(lines(result.matches), omittedByFile, template));
+  const unshown = (result.omittedByFile ?? []).filter((file) => !byFile.has(file.filePath));
+  if (unshown.length > 0) {
+    output += formatOmittedFiles(unshown);
+  }
+  if (result.largeFilesSkipped.length > 0) {
+    output += formatLargeFiles(result.largeFilesSkipped);
+  }
+  if (result.archivesSkipped.length > 0) {
+    output += formatSkippedArchives(result.archivesSkipped);
+  }
+
+  return { text: output, partial };
+}
@@ -0,0 +1,89 @@
+/**
+ * Tests for the search diff tool
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { LexicalMatch } from '../search/lexical';
+import { diffMatches, searchDiff } from './searchdiff';
+
+const match = (filePath: string, line: number, lineText: string, column = lineText.indexOf('legacy') + 1): LexicalMatch =>
+  ({ filePath, line, column, length: 6, lineText });
+
+describe('Search diff', () => {
+  describe('diffMatches', () => {
+    it('should not report moved lines or edited lines that still match', () => {
+      const diff = diffMatches([
+        match('a.go', 3, 'legacy.Open(path)'),
+        match('a.go', 9, '\tx := legacy.Read(r)'),
+        match('b.go', 1, 'legacy.Close()'),
+      ], [
+        match('a.go', 5, 'legacy.Open(path)'),
+        match('a.go', 12, '\tx, err := legacy.Read(r)'),
+        match('a.go', 20, 'legacy.Write(w)'),
+      ]);
+      expect(diff.added.map((m) => `${m.filePath}:${m.line}`)).toEqual(['a.go:20']);
+      expect(diff.removed.map((m) => `${m.filePath}:${m.line}`)).toEqual(['b.go:1']);
+    });
+  });
+
+  describe('searchDiff', () => {
+    let workspace: string;
+    let git: (...args: string[]) => Buffer;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'searchdiff-'));
+      git = (...args: string[]) => execFileSync('git', ['-c', 'user.name=t', '-c', 'user.email=t@t', ...args], { cwd: workspace });
+      fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nfunc main() {\n\tlegacy.Open()\n}\n');
+      fs.writeFileSync(path.join(workspace, 'old.go'), 'package main\n\nvar _ = legacy.Close\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should report new and removed matches since a revision, untracked files included', async () => {
+      try {
+        git('init', '-q', '-b', 'main');
+        git('add', '.');
+        git('commit', '-qm', 'base');
+      } catch (err) {
+        return; // git is not available
+      }
+      fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\n// entry point\nfunc main() {\n\tlegacy.Open()\n}\n');
+      fs.rmSync(path.join(workspace, 'old.go'));
+      fs.writeFileSync(path.join(workspace, 'new.go'), 'package main\n\nvar _ = legacy.Write\n');
+
+      const result = await searchDiff(workspace, 'legacy', { base: 'main' });
+      const lines = result.split('\n');
+      expect(lines[0]).toMatch(/^Search diff for "legacy" from main \([0-9a-f]{7}\) to the work tree, 3 changed file\(s\) searched: 1 new, 1 removed match\(es\)$/);
+      expect(result).toContain('New:\n  new.go:3:9: var _ = legacy.Write\n');
+      expect(result).toContain('Removed:\n  old.go:3:9: var _ = legacy.Close\n');
+    });
+
+    it('should compare with a saved snapshot', async () => {
+      expect(await searchDiff(workspace, 'legacy', { save: 'before' })).toBe('Saved 2 match(es) of "legacy" as snapshot before\n');
+      fs.writeFileSync(path.join(workspace, 'new.go'), 'package main\n\nvar _ = legacy.Write\n');
+
+      const result = await searchDiff(workspace, 'legacy', { since: 'before' });
+      expect(result).toMatch(/^Search diff for "legacy" since snapshot before \(.+\): 1 new, 0 removed match\(es\)\n\nNew:\n  new.go:3:9: var _ = legacy.Write\n$/);
+      await expect(searchDiff(workspace, 'Open', { since: 'before' })).rejects.toThrow('Snapshot before was saved for "legacy", not "Open"');
+      await expect(searchDiff(workspace, 'legacy', { since: 'later' })).rejects.toThrow('No snapshot named later; saved: before');
+      await expect(searchDiff(workspace, 'legacy', { since: 'before', wholeWord: true, glob: ['*.go'] })).rejects.toThrow(
+        'Snapshot before was saved with other search options (glob: ["*.go"], not unset; wholeWord: true, not unset)');
+    });
+
+    it('should keep the most recently used snapshots only', async () => {
+      await searchDiff(workspace, 'legacy', { save: 'kept' });
+      await searchDiff(workspace, 'legacy', { save: 'dropped' });
+      for (let i = 0; i < 19; i++) {
+        await searchDiff(workspace, 'legacy', { since: i === 0 ? 'kept' : undefined, save: `s${i}` });
+      }
+      expect(await searchDiff(workspace, 'legacy', { since: 'kept' })).toContain('since snapshot kept');
+      const err = await searchDiff(workspace, 'legacy', { since: 'dropped' }).catch((e) => e);
+      expect(err.code).toBe('not-found');
+    });
+  });
+});
@@ -0,0 +1,344 @@
+/**
+ * Search diff tool - the matches of a query that are new or gone, compared
+ * with a git revision or with a snapshot saved by an earlier call
+ * Matches are paired by file and line text, then by matched text, so a line
+ * that moved, or was edited but still matches, is neither new nor removed.
+ * Against a revision only the changed files are searched, since matches in
+ * the others are the same on both sides
+ */
+
+import * as path from 'path';
+import { changedFiles, fileAtRevision, isGitRepository, mergeBase, resolveRevision, workingChanges, ChangedFile } from '../git/git.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { buildMatcher, matchContent, searchLexical, LexicalMatch, LexicalSearchOptions } from '../search/lexical.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { matchesGlob } from '../workspace/glob.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { resolveWorkspacePath } from '../workspace/walker.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the search diff tool
+ */
+export interface SearchDiffOptions extends Pick<LexicalSearchOptions,
+  'regex' | 'caseSensitive' | 'wholeWord' | 'path' | 'glob' | 'excludeGlob' | 'languages'> {
+  // The older revision to compare with, e.g. "main"
+  base?: string;
+  // The newer revision (default: the work tree, uncommitted and untracked files included)
+  head?: string;
+  // Compare from the merge base of base and head, as a pull request does (default: true)
+  mergeBase?: boolean;
+  // Compare with the snapshot saved under this name
+  since?: string;
+  // Save the current matches under this name, for a later call with since
+  save?: string;
+  // Most new and most removed matches listed (default: 100 each)
+  limit?: number;
+}
+
+/**
+ * Matches new and gone between two sides
+ */
+export interface MatchDiff {
+  added: LexicalMatch[];
+  removed: LexicalMatch[];
+}
+
+/**
+ * Matches of a query at one point in time
+ */
+interface SearchSnapshot {
+  pattern: string;
+  // The search options that decide which matches there are, as saveable JSON
+  query: Record<string, unknown>;
+  matches: LexicalMatch[];
+  takenAt: number;
+  truncated: boolean;
+}
+
+/**
+ * Matches kept in a snapshot; a query with more is saved truncated
+ */
+const SNAPSHOT_MAX_MATCHES = 100_000;
+
+/**
+ * Snapshots kept; saving one more drops the least recently used
+ */
+const MAX_SNAPSHOTS = 20;
+
+/**
+ * Snapshots by name, least recently used first
+ */
+const snapshots = new Map<string, SearchSnapshot>();
+
+/**
+ * The options of a search diff that decide its matches, with unset ones left out
+ */
+function queryOptions(options: SearchDiffOptions): Record<string, unknown> {
+  const { regex, caseSensitive, wholeWord, path: scope, glob, excludeGlob, languages } = options;
+  const query: Record<string, unknown> = { regex, caseSensitive, wholeWord, path: scope, glob, excludeGlob, languages };
+  for (const [key, value] of Object.entries(query)) {
+    if (value === undefined || value === false || (Array.isArray(value) && value.length === 0)) {
+      delete query[key];
+    }
+  }
+  return query;
+}
+
+/**
+ * How the options of a query differ from those a snapshot was saved with, e.g. 'regex: true, not unset'
+ */
+function optionDifferences(saved: Record<string, unknown>, query: Record<string, unknown>): string[] {
+  const show = (value: unknown) => value === undefined ? 'unset' : JSON.stringify(value);
+  return [...new Set([...Object.keys(saved), ...Object.keys(query)])].sort()
+    .filter((key) => JSON.stringify(saved[key]) !== JSON.stringify(query[key]))
+    .map((key) => `${key}: ${show(query[key])}, not ${show(saved[key])}`);
+}
+
+/**
+ * Text a match covers
+ */
+function matchedText(match: LexicalMatch): string {
+  return match.lineText.substring(match.column - 1, match.column - 1 + match.length);
+}
+
+/**
+ * Pair up the matches of two sides, file by file
+ * Pairs by matched text and trimmed line first, then by matched text alone;
+ * what stays unpaired is new or removed. Matches of the before side are
+ * compared under their filePath, so renamed files are mapped to their new
+ * path by the caller
+ */
+export function diffMatches(before: LexicalMatch[], after: LexicalMatch[]): MatchDiff {
+  const byFile = (matches: LexicalMatch[]) => {
+    const files = new Map<string, LexicalMatch[]>();
+    for (const match of matches) {
+      files.set(match.filePath, [...(files.get(match.filePath) ?? []), match]);
+    }
+    return files;
+  };
+  const oldFiles = byFile(before);
+  const newFiles = byFile(after);
+
+  const diff: MatchDiff = { added: [], removed: [] };
+  for (const filePath of new Set([...oldFiles.keys(), ...newFiles.keys()])) {
+    let removed = oldFiles.get(filePath) ?? [];
+    let added = newFiles.get(filePath) ?? [];
+    for (const key of [(m: LexicalMatch) => `${matchedText(m)}\n${m.lineText.trim()}`, matchedText]) {
+      const unpaired = new Map<string, LexicalMatch[]>();
+      for (const match of removed) {
+        unpaired.set(key(match), [...(unpaired.get(key(match)) ?? []), match]);
+      }
+      added = added.filter((match) => {
+        const candidates = unpaired.get(key(match));
+        return !candidates?.shift();
+      });
+      removed = Array.from(unpaired.values()).flat();
+    }
+    diff.added.push(...added);
+    diff.removed.push(...removed);
+  }
+  const order = (a: LexicalMatch, b: LexicalMatch) => a.filePath.localeCompare(b.filePath) || a.line - b.line || a.column - b.column;
+  diff.added.sort(order);
+  diff.removed.sort(order);
+  return diff;
+}
+
+/**
+ * Check if a content looks binary
+ */
+function isBinaryText(content: string): boolean {
+  return content.includes('\0');
+}
+
+/**
+ * Check for workspace-relative paths a query's path, globs, and languages let in
+ */
+export function queryScope(
+  workspaceDir: string,
+  options: Pick<LexicalSearchOptions, 'path' | 'glob' | 'excludeGlob' | 'languages'>
+): (relativePath: string) => boolean {
+  const prefix = options.path ? path.relative(workspaceDir, resolveWorkspacePath(workspaceDir, options.path)) : '';
+  return (p: string) =>
+    (!prefix || p === prefix || p.startsWith(prefix + path.sep) || p.startsWith(prefix + '/')) &&
+    (!options.glob || options.glob.length === 0 || matchesGlob(p, options.glob)) &&
+    (!options.excludeGlob || options.excludeGlob.length === 0 || !matchesGlob(p, options.excludeGlob)) &&
+    (!options.languages || options.languages.length === 0 || options.languages.includes(detectLanguageId(p)));
+}
+
+/**
+ * Diff the matches of a query between a revision and the work tree or another revision
+ */
+async function diffAgainstRevision(
+  workspaceDir: string,
+  pattern: string,
+  base: string,
+  options: SearchDiffOptions
+): Promise<{ diff: MatchDiff; summary: string }> {
+  if (!(await isGitRepository(workspaceDir))) {
+    throw new ToolError('unsupported', 'The workspace is not a git repository');
+  }
+  const resolve = async (revision: string) => {
+    try {
+      return await resolveRevision(workspaceDir, revision);
+    } catch (err) {
+      throw new ToolError('not-found', `Unknown revision ${revision}`);
+    }
+  };
+  const baseCommit = await resolve(base);
+  const headCommit = options.head ? await resolve(options.head) : undefined;
+  const from = (options.mergeBase ?? true) ? await mergeBase(workspaceDir, baseCommit, headCommit ?? 'HEAD') : baseCommit;
+
+  const files = await changedFiles(workspaceDir, from, headCommit);
+  if (!headCommit) {
+    // git diff leaves out files never added, which a new usage may well be in
+    for (const [relativePath, change] of (await workingChanges(workspaceDir)) ?? []) {
+      if (change === 'untracked') {
+        files.push({ status: 'added', path: relativePath.split(path.sep).join('/') });
+      }
+    }
+  }
+
+  const searched = queryScope(workspaceDir, options);
+  const selected = files.filter((file) => [file.path, file.oldPath].some((p) => p && searched(p)));
+  toolsLogger.debug('Diffing matches of %d changed file(s) from %s to %s', selected.length, from, headCommit ?? 'the work tree');
+
+  const read = (file: ChangedFile, side: 'old' | 'new'): Promise<string> => {
+    if ((side === 'old' && file.status === 'added') || (side === 'new' && file.status === 'deleted')) {
+      return Promise.resolve('');
+    }
+    if (side === 'old') {
+      return fileAtRevision(workspaceDir, from, file.oldPath ?? file.path);
+    }
+    return headCommit ? fileAtRevision(workspaceDir, headCommit, file.path) : readFileText(path.join(workspaceDir, file.path));
+  };
+
+  const before: LexicalMatch[] = [];
+  const after: LexicalMatch[] = [];
+  // Old matches are paired under the new path; removed ones of a renamed file are reported under the old one
+  const renamedFrom = new Map<LexicalMatch, string>();
+  for (const file of selected) {
+    try {
+      const [oldText, newText] = [await read(file, 'old'), await read(file, 'new')];
+      if (isBinaryText(oldText) || isBinaryText(newText)) {
+        continue;
+      }
+      for (const match of matchContent(file.path, oldText, buildMatcher(pattern, options), Infinity)) {
+        if (file.oldPath && file.oldPath !== file.path) {
+          renamedFrom.set(match, file.oldPath);
+        }
+        before.push(match);
+      }
+      after.push(...matchContent(file.path, newText, buildMatcher(pattern, options), Infinity));
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', file.path, (err as Error).message);
+    }
+  }
+
+  const diff = diffMatches(before, after);
+  diff.removed = diff.removed.map((match) => renamedFrom.has(match) ? { ...match, filePath: renamedFrom.get(match)! } : match);
+
+  const target = headCommit ? `${options.head} (${headCommit.substring(0, 7)})` : 'the work tree';
+  const origin = from === baseCommit ? `${base} (${from.substring(0, 7)})` : `${base} (merge base ${from.substring(0, 7)})`;
+  return { diff, summary: `from ${origin} to ${target}, ${selected.length} changed file(s) searched` };
+}
+
+/**
+ * "path:line:column: text" lines for matches, at most limit of them
+ */
+function formatMatchList(matches: LexicalMatch[], limit: number): string {
+  let output = '';
+  for (const match of matches.slice(0, limit)) {
+    const text = match.lineText.trim();
+    output += `  ${match.filePath}:${match.line}:${match.column}: ${text.length > 200 ? text.substring(0, 200) + '...' : text}\n`;
+  }
+  if (matches.length > limit) {
+    output += `  ... and ${matches.length - limit} more\n`;
+  }
+  return output;
+}
+
+/**
+ * Report the matches of a query that are new or removed since a revision or a saved snapshot
+ */
+export async function searchDiff(
+  workspaceDir: string,
+  pattern: string,
+  options: SearchDiffOptions = {},
+  index?: TrigramIndex
+): Promise<string> {
+  if (options.base && options.since) {
+    throw new ToolError('invalid-argument', 'Pass either base or since, not both');
+  }
+  if (!options.base && !options.since && !options.save) {
+    throw new ToolError('invalid-argument', 'base, since, or save is required');
+  }
+
+  // Snapshots hold the whole workspace's matches, so they are taken by a full search
+  let current: { matches: LexicalMatch[]; truncated: boolean } | undefined;
+  const search = async () => {
+    if (!current) {
+      const { regex, caseSensitive, wholeWord, glob, excludeGlob, languages } = options;
+      const result = await searchLexical(workspaceDir, pattern, {
+        regex, caseSensitive, wholeWord, glob, excludeGlob, languages, path: options.path, maxResults: SNAPSHOT_MAX_MATCHES,
+      }, index);
+      current = { matches: result.matches, truncated: result.truncated };
+    }
+    return current;
+  };
+
+  let output = '';
+  if (options.base || options.since) {
+    let diff: MatchDiff;
+    let summary: string;
+    if (options.base) {
+      ({ diff, summary } = await diffAgainstRevision(workspaceDir, pattern, options.base, options));
+    } else {
+      const snapshot = snapshots.get(options.since!);
+      if (!snapshot) {
+        const names = Array.from(snapshots.keys());
+        throw new ToolError('not-found', `No snapshot named ${options.since}` + (names.length > 0 ? `; saved: ${names.join(', ')}` : ''));
+      }
+      if (snapshot.pattern !== pattern) {
+        throw new ToolError('invalid-argument', `Snapshot ${options.since} was saved for "${snapshot.pattern}", not "${pattern}"`);
+      }
+      const differences = optionDifferences(snapshot.query, queryOptions(options));
+      if (differences.length > 0) {
+        throw new ToolError('invalid-argument', `Snapshot ${options.since} was saved with other search options (${differences.join('; ')})`);
+      }
+      snapshots.delete(options.since!);
+      snapshots.set(options.since!, snapshot);
+      const now = await search();
+      diff = diffMatches(snapshot.matches, now.matches);
+      summary = `since snapshot ${options.since} (${new Date(snapshot.takenAt).toISOString()})`;
+      if (snapshot.truncated || now.truncated) {
+        summary += `, comparing the first ${SNAPSHOT_MAX_MATCHES} matches only`;
+      }
+    }
+    const limit = options.limit ?? 100;
+    output += `Search diff for "${pattern}" ${summary}: ${diff.added.length} new, ${diff.removed.length} removed match(es)\n`;
+    if (diff.added.length > 0) {
+      output += `\nNew:\n${formatMatchList(diff.added, limit)}`;
+    }
+    if (diff.removed.length > 0) {
+      output += `\nRemoved:\n${formatMatchList(diff.removed, limit)}`;
+    }
+  }
+
+  if (options.save) {
+    const { matches, truncated } = await search();
+    snapshots.delete(options.save);
+    snapshots.set(options.save, { pattern, query: queryOptions(options), matches, takenAt: Date.now(), truncated });
+    if (snapshots.size > MAX_SNAPSHOTS) {
+      const dropped = snapshots.keys().next().value!;
+      snapshots.delete(dropped);
+      toolsLogger.debug('Dropped search snapshot %s, the least recently used', dropped);
+    }
+    output += `${output ? '\n' : ''}Saved ${matches.length} match(es) of "${pattern}" as snapshot ${options.save}`;
+    output += truncated ? ` (the first ${SNAPSHOT_MAX_MATCHES})\n` : '\n';
+  }
+  return output;
+}
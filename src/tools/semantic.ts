@@ -0,0 +1,107 @@
+/**
+ * Semantic search tool - find code by natural-language description
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { SemanticSearchEngine } from '../semantic/engine.js';
+import { hybridSearch } from '../semantic/hybrid.js';
+import { Chunk } from '../semantic/chunker.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { addLineNumbers } from './utilities.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Ranking mode: vectors only, or keyword and vector rankings fused
+ */
+export type SearchMode = 'semantic' | 'hybrid';
+
+/**
+ * Options for the semantic search tool
+ */
+export interface SemanticSearchOptions {
+  topK?: number;
+  path?: string;
+  maxLinesPerHit?: number;
+  mode?: SearchMode;
+}
+
+/**
+ * Ranked chunk with a description of its score
+ */
+interface RankedChunk {
+  chunk: Chunk;
+  scoreText: string;
+}
+
+/**
+ * Run the query in the requested mode
+ */
+async function rankChunks(
+  engine: SemanticSearchEngine,
+  query: string,
+  options: SemanticSearchOptions,
+  index?: TrigramIndex
+): Promise<RankedChunk[]> {
+  const queryOptions = { topK: options.topK ?? 10, pathPrefix: options.path };
+  if (options.mode !== undefined && options.mode !== 'semantic' && options.mode !== 'hybrid') {
+    throw new ToolError('invalid-argument', `Unknown mode "${options.mode}"; use semantic or hybrid`);
+  }
+
+  if (options.mode === 'hybrid') {
+    if (!index) {
+      throw new ToolError('workspace-not-indexed', 'hybrid mode requires the trigram index');
+    }
+    const hits = await hybridSearch(engine, index, query, queryOptions);
+    return hits.map((hit) => {
+      const parts = [`lexical #${hit.lexicalRank ?? '-'}`, `semantic #${hit.semanticRank ?? '-'}`];
+      if (hit.exactMatch) {
+        parts.push('exact identifier match');
+      }
+      return { chunk: hit.chunk, scoreText: `${hit.score.toFixed(4)} (${parts.join(', ')})` };
+    });
+  }
+
+  const hits = await engine.search(query, queryOptions);
+  return hits.map((hit) => ({ chunk: hit.chunk, scoreText: hit.score.toFixed(3) }));
+}
+
+/**
+ * Search the workspace for code matching a natural-language query
+ */
+export async function semanticSearch(
+  engine: SemanticSearchEngine,
+  query: string,
+  options: SemanticSearchOptions = {},
+  index?: TrigramIndex
+): Promise<string> {
+  const maxLines = options.maxLinesPerHit ?? 30;
+
+  toolsLogger.debug('Semantic search (%s): %s', options.mode ?? 'semantic', query);
+  const hits = await rankChunks(engine, query, options, index);
+
+  if (hits.length === 0) {
+    return `No semantic matches found for: ${query}`;
+  }
+
+  const sections = hits.map((hit) => {
+    const { chunk } = hit;
+    let section = `---\n\n${chunk.filePath}:L${chunk.startLine}-L${chunk.endLine}`;
+    if (chunk.symbolName) {
+      section += ` [${chunk.symbolKind}] ${chunk.symbolName}`;
+    }
+    section += `\nScore: ${hit.scoreText}\n\n`;
+
+    const lines = chunk.text.split('\n');
+    section += addLineNumbers(lines.slice(0, maxLines).join('\n'), chunk.startLine) + '\n';
+    if (lines.length > maxLines) {
+      section += `... (${lines.length - maxLines} more line(s))\n`;
+    }
+    return section;
+  });
+
+  const stats = engine.getStats();
+  return `Found ${hits.length} semantic match(es) for "${query}" (${stats.chunks} chunks in ${stats.files} files indexed)\n\n` +
+    sections.join('\n');
+}
@@ -0,0 +1,65 @@
+/**
+ * Tests for the similar code tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { tokenize } from './duplicates';
+import { findSimilar, formatSimilar, similarRegions } from './similar';
+
+const HANDLER = `func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	user, err := s.store.Find(id)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+`;
+
+describe('Similar code', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'similar-'));
+    fs.writeFileSync(path.join(workspace, 'users.go'), `package api\n\n${HANDLER}`);
+    // The same handler with other names and literals
+    fs.writeFileSync(path.join(workspace, 'orders.go'), 'package api\n\n' + HANDLER
+      .replace(/getUser/g, 'getOrder').replace(/user/g, 'order').replace(/"id"/, '"order_id"').replace('500', '404'));
+    fs.writeFileSync(path.join(workspace, 'other.go'), 'package api\n\nfunc add(a, b int) int {\n\treturn a + b\n}\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should find the snippet and its renamed copy, best first', async () => {
+    const snippet = HANDLER.split('\n').slice(2, 7).join('\n');
+    const regions = await findSimilar(workspace, snippet);
+    expect(regions.map((r) => [r.filePath, r.startLine, r.endLine, r.similarity])).toEqual([
+      ['orders.go', 5, 9, 1],
+      ['users.go', 5, 9, 1],
+    ]);
+    const exact = await findSimilar(workspace, snippet, { normalizeIdentifiers: false });
+    // Only the shingle holding the renamed variable differs
+    expect(exact[0]).toMatchObject({ filePath: 'users.go', similarity: 1 });
+    expect(exact[1].filePath).toBe('orders.go');
+    expect(exact[1].similarity).toBeLessThan(1);
+
+    const output = await formatSimilar(workspace, regions);
+    expect(output).toContain('100%  users.go:L5-L9\n    user, err := s.store.Find(id)');
+    expect(await formatSimilar(workspace, [], 0.8)).toBe('No regions at least 80% similar to the snippet');
+  });
+
+  it('should reject snippets too short to compare', async () => {
+    await expect(findSimilar(workspace, 'return x')).rejects.toThrow('the snippet has 2 token(s); give at least 8');
+  });
+
+  it('should report separate copies in one file separately', () => {
+    const snippet = tokenize('if err != nil { return err }', 'go', true);
+    const file = tokenize('a()\nif err != nil { return err }\nb()\nc()\nd()\nif e != nil { return e }\n', 'go', true);
+    expect(similarRegions('x.go', file, snippet, 0.9).map((r) => [r.startLine, r.endLine])).toEqual([[2, 2], [6, 6]]);
+  });
+});
@@ -0,0 +1,214 @@
+/**
+ * Similar code tool - the workspace regions most like a given snippet
+ * Compares token shingles (k-grams of the normalized token stream, as
+ * find_duplicates uses) of the snippet with every window of each source file,
+ * so renamed variables and changed literals still match. Finds where else to
+ * apply a fix, or where a pasted snippet came from
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { readFileText } from '../workspace/overlay.js';
+import { GeneratedFileDetector } from '../workspace/generated.js';
+import { detectLanguageId, isSourceFile } from '../workspace/language.js';
+import { HASH_COMMENT_LANGUAGES, Token, hashKGram, tokenize } from './duplicates.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the similar code tool
+ */
+export interface SimilarOptions {
+  // Only look under this path
+  path?: string;
+  // Lowest share of the snippet's shingles a region must contain (default: 0.5)
+  minSimilarity?: number;
+  // Most regions listed (default: 10)
+  maxResults?: number;
+  // Match code that differs only in identifier names (default: true)
+  normalizeIdentifiers?: boolean;
+  // Also look in generated files
+  includeGenerated?: boolean;
+}
+
+/**
+ * A region similar to the snippet
+ */
+export interface SimilarRegion {
+  filePath: string;
+  startLine: number;
+  endLine: number;
+  similarity: number; // Share of the snippet's shingles found in the region, 0 to 1
+}
+
+/**
+ * Shingle length in tokens; shorter snippets use their whole length
+ */
+const SHINGLE_TOKENS = 5;
+
+/**
+ * Fewest snippet tokens worth comparing
+ */
+const MIN_SNIPPET_TOKENS = 8;
+
+/**
+ * Hashes of every k-gram of a token stream
+ */
+function shingles(tokens: Token[], k: number): number[] {
+  const hashes: number[] = [];
+  for (let i = 0; i + k <= tokens.length; i++) {
+    hashes.push(hashKGram(tokens, i, k));
+  }
+  return hashes;
+}
+
+/**
+ * Find the regions of a file's tokens most similar to a snippet
+ * Each window as long as the snippet is scored by the distinct snippet
+ * shingles it contains; the best window of each run above minSimilarity is
+ * kept, trimmed to its first and last shared shingle
+ */
+export function similarRegions(
+  filePath: string,
+  tokens: Token[],
+  snippetTokens: Token[],
+  minSimilarity: number
+): SimilarRegion[] {
+  const k = Math.min(SHINGLE_TOKENS, snippetTokens.length);
+  const wanted = new Set(shingles(snippetTokens, k));
+  const hashes = shingles(tokens, k);
+  const window = snippetTokens.length - k + 1;
+  if (wanted.size === 0 || hashes.length === 0) {
+    return [];
+  }
+
+  const counts = new Map<number, number>();
+  let distinct = 0;
+  const add = (hash: number, delta: number) => {
+    if (!wanted.has(hash)) {
+      return;
+    }
+    const count = (counts.get(hash) ?? 0) + delta;
+    counts.set(hash, count);
+    if (delta > 0 && count === 1) {
+      distinct++;
+    } else if (delta < 0 && count === 0) {
+      distinct--;
+    }
+  };
+
+  const regions: SimilarRegion[] = [];
+  let best: { score: number; start: number } | undefined;
+  const flush = () => {
+    if (!best) {
+      return;
+    }
+    // Trim the window to the shingles it shares with the snippet
+    const end = Math.min(hashes.length, best.start + window) - 1;
+    let first = best.start;
+    let last = end;
+    while (first < last && !wanted.has(hashes[first])) {
+      first++;
+    }
+    while (last > first && !wanted.has(hashes[last])) {
+      last--;
+    }
+    regions.push({
+      filePath,
+      startLine: tokens[first].line,
+      endLine: tokens[last + k - 1].line,
+      similarity: best.score,
+    });
+    best = undefined;
+  };
+
+  for (let i = 0; i < hashes.length; i++) {
+    add(hashes[i], 1);
+    if (i >= window) {
+      add(hashes[i - window], -1);
+    }
+    const start = Math.max(0, i - window + 1);
+    // Past the best window without finding a better one overlapping it
+    if (best && start >= best.start + window) {
+      flush();
+    }
+    const score = distinct / wanted.size;
+    if (score >= minSimilarity && (!best || score > best.score)) {
+      best = { score, start };
+    }
+  }
+  flush();
+  return regions;
+}
+
+/**
+ * Find the workspace regions most similar to a snippet
+ */
+export async function findSimilar(
+  workspaceDir: string,
+  snippet: string,
+  options: SimilarOptions = {}
+): Promise<SimilarRegion[]> {
+  const minSimilarity = Math.min(1, Math.max(0.05, options.minSimilarity ?? 0.5));
+  const normalizeIdentifiers = options.normalizeIdentifiers ?? true;
+  // The snippet is tokenized by the comment syntax of each file it is compared with
+  const snippetTokens = new Map<boolean, Token[]>();
+  const tokensFor = (languageId: string): Token[] => {
+    const hashComments = HASH_COMMENT_LANGUAGES.has(languageId);
+    if (!snippetTokens.has(hashComments)) {
+      snippetTokens.set(hashComments, tokenize(snippet, languageId, normalizeIdentifiers));
+    }
+    return snippetTokens.get(hashComments)!;
+  };
+  const length = tokenize(snippet, 'plaintext', false).length;
+  if (length < MIN_SNIPPET_TOKENS) {
+    throw new ToolError('invalid-argument', `the snippet has ${length} token(s); give at least ${MIN_SNIPPET_TOKENS}`);
+  }
+
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: options.path }))
+    .filter((f) => isSourceFile(f.absolutePath));
+  const generated = options.includeGenerated ? undefined : new GeneratedFileDetector(workspaceDir);
+  const regions: SimilarRegion[] = [];
+  for (const file of files) {
+    try {
+      const content = await readFileText(file.absolutePath);
+      if (generated && generated.isGenerated(file.relativePath, content)) {
+        continue;
+      }
+      const languageId = detectLanguageId(file.absolutePath);
+      const wanted = tokensFor(languageId);
+      if (wanted.length === 0) {
+        continue;
+      }
+      regions.push(...similarRegions(file.relativePath, tokenize(content, languageId, normalizeIdentifiers), wanted, minSimilarity));
+    } catch (err) {
+      toolsLogger.debug('Error reading file %s: %s', file.absolutePath, err);
+    }
+  }
+  toolsLogger.debug('Compared a snippet of %d tokens with %d files: %d region(s)', length, files.length, regions.length);
+  return regions
+    .sort((a, b) => b.similarity - a.similarity || a.filePath.localeCompare(b.filePath) || a.startLine - b.startLine)
+    .slice(0, options.maxResults ?? 10);
+}
+
+/**
+ * Format similar regions, best first, each with its first line
+ */
+export async function formatSimilar(workspaceDir: string, regions: SimilarRegion[], minSimilarity = 0.5): Promise<string> {
+  if (regions.length === 0) {
+    return `No regions at least ${Math.round(minSimilarity * 100)}% similar to the snippet`;
+  }
+  let output = `Found ${regions.length} similar region(s)\n`;
+  for (const region of regions) {
+    output += `\n${Math.round(region.similarity * 100)}%  ${region.filePath}:L${region.startLine}-L${region.endLine}\n`;
+    try {
+      const lines = (await readFileText(path.join(workspaceDir, region.filePath))).split('\n');
+      output += `    ${lines[region.startLine - 1].trim()}\n`;
+    } catch (err) {
+      // The file changed since it was read; the location is still useful
+    }
+  }
+  return output.trimEnd();
+}
@@ -0,0 +1,37 @@
+/**
+ * Snapshot tool - pin a session to the workspace as it is now
+ * Formats the snapshots the server keeps for the snapshot tool
+ */
+
+import { WorkspaceSnapshot } from '../workspace/treesnapshot.js';
+import { formatBytes } from './info.js';
+
+/**
+ * One line on a snapshot: ID, commit, and what was recorded on top of it
+ */
+export function describeSnapshot(snapshot: WorkspaceSnapshot): string {
+  const changed = snapshot.changedFiles().length;
+  return `${snapshot.id}: commit ${snapshot.commit.substring(0, 12)}` +
+    (changed > 0 ? ` plus ${changed} changed file(s) (${formatBytes(snapshot.size())})` : '') +
+    `, taken ${new Date(snapshot.createdAt).toISOString()}`;
+}
+
+/**
+ * The result of pinning a session to a snapshot
+ */
+export function formatPinnedSnapshot(snapshot: WorkspaceSnapshot): string {
+  return `Pinned to snapshot ${describeSnapshot(snapshot)}\n\n` +
+    'File reads of this session (search_code, read tools, outlines) now see the workspace as it was then, until released with action: release. ' +
+    'The language server, git history, and write tools work on the files as they are now; write tools are refused while pinned.';
+}
+
+/**
+ * The snapshots kept, with the sessions pinned to each
+ */
+export function formatSnapshots(snapshots: Array<{ snapshot: WorkspaceSnapshot; sessions: number }>, pinned?: WorkspaceSnapshot): string {
+  if (snapshots.length === 0) {
+    return 'No snapshots; take one with action: create';
+  }
+  return snapshots.map(({ snapshot, sessions }) =>
+    `${describeSnapshot(snapshot)}; ${sessions} session(s)${snapshot === pinned ? ' (this session)' : ''}`).join('\n');
+}
@@ -0,0 +1,98 @@
+/**
+ * SQL search tool - statements touching a table or column, in migration order
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { ColumnChange, SqlSearchOptions, SqlStatement, matchesTable, searchSql } from '../search/sql.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Statement lines shown before the rest is elided
+ */
+const MAX_STATEMENT_LINES = 12;
+
+/**
+ * Past tense of each column change, for summaries
+ */
+const CHANGE_VERBS: Record<ColumnChange['change'], string> = {
+  define: 'defined',
+  add: 'added',
+  drop: 'dropped',
+  rename: 'renamed',
+  alter: 'altered',
+};
+
+/**
+ * Describe a file's migration version, name, and direction
+ */
+function migrationLabel(statement: SqlStatement): string {
+  const { migration } = statement;
+  if (!migration) {
+    return '';
+  }
+  const parts = [migration.version, migration.name, migration.direction].filter(Boolean);
+  return ` (migration ${parts.join(', ')})`;
+}
+
+/**
+ * Search SQL files by table, column, statement kind, or pattern and format the statements
+ */
+export async function sqlSearch(workspaceDir: string, options: SqlSearchOptions): Promise<string> {
+  toolsLogger.debug('Searching SQL statements (table: %s, column: %s)', options.table, options.column);
+  const result = await searchSql(workspaceDir, options);
+  const filters = [
+    options.table && `table: ${options.table}`,
+    options.column && `column: ${options.column}`,
+    options.statement && `statement: ${options.statement}`,
+    options.pattern && `pattern: ${options.pattern}`,
+  ].filter(Boolean).join(', ');
+  if (result.statements.length === 0) {
+    return `No SQL statements match (${filters}; ${result.filesScanned} SQL file(s) read)`;
+  }
+
+  const files = new Set(result.statements.map((statement) => statement.filePath));
+  let output = `Found ${result.statements.length} statement(s) in ${files.size} file(s) (${filters})`;
+  if (result.truncated) {
+    output += ` (results truncated at ${result.statements.length}; narrow the search)`;
+  }
+  output += '\n\n';
+
+  // "which migration added column X": the changes to the column, oldest first
+  if (options.column) {
+    const wanted = options.column.toLowerCase();
+    const history = result.statements.flatMap((statement) => statement.columns
+      .filter((change) => change.column.toLowerCase() === wanted || change.newName?.toLowerCase() === wanted)
+      .filter((change) => !options.table || matchesTable(change.table, options.table))
+      .filter(() => statement.migration?.direction !== 'down')
+      .map((change) => {
+        const verb = change.change === 'rename' ? `renamed to ${change.newName}` : CHANGE_VERBS[change.change];
+        return `${change.table}.${change.column} ${verb} in ${statement.filePath}:${statement.startLine}${migrationLabel(statement)}\n`;
+      }));
+    if (history.length > 0) {
+      output += `Column history:\n${history.join('')}\n`;
+    }
+  }
+
+  let currentFile: string | undefined;
+  for (const statement of result.statements) {
+    if (statement.filePath !== currentFile) {
+      currentFile = statement.filePath;
+      output += `---\n\n${statement.filePath}${migrationLabel(statement)}\n\n`;
+    }
+    const range = statement.endLine > statement.startLine ? `L${statement.startLine}-${statement.endLine}` : `L${statement.startLine}`;
+    const changes = statement.columns.filter((change) => change.change !== 'define')
+      .map((change) => `${CHANGE_VERBS[change.change]} ${change.column}${change.newName ? ` → ${change.newName}` : ''}`);
+    output += `${range} ${statement.kind}${statement.tables.length > 0 ? ` ${statement.tables.join(', ')}` : ''}` +
+      `${changes.length > 0 ? ` (${changes.join('; ')})` : ''}\n`;
+    const lines = statement.text.split('\n');
+    for (const line of lines.slice(0, MAX_STATEMENT_LINES)) {
+      output += `  ${line}\n`;
+    }
+    if (lines.length > MAX_STATEMENT_LINES) {
+      output += `  … ${lines.length - MAX_STATEMENT_LINES} more line(s)\n`;
+    }
+    output += '\n';
+  }
+  return output.trimEnd();
+}
@@ -0,0 +1,133 @@
+/**
+ * Tests for the stack trace tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { parseStackTrace, isLibraryFrame, matchFramePath, findDeclarations, resolveStackTrace } from './stacktrace';
+import { WorkspaceFile } from '../workspace/walker';
+
+const GO_PANIC = [
+  'panic: runtime error: index out of range [5] with length 3',
+  '',
+  'goroutine 1 [running]:',
+  'main.(*Server).handle(0xc000010000, {0x10a2f40, 0x3})',
+  '\t/home/ci/build/app/server.go:6 +0x1d',
+  'main.main()',
+  '\t/home/ci/build/app/main.go:4 +0x25',
+  'runtime.main()',
+  '\t/usr/local/go/src/runtime/proc.go:271 +0x29d',
+].join('\n');
+
+function file(relativePath: string): WorkspaceFile {
+  return { absolutePath: path.join('/ws', relativePath), relativePath, size: 0 } as WorkspaceFile;
+}
+
+describe('Stack traces', () => {
+  describe('parseStackTrace', () => {
+    it('should parse Go panics', () => {
+      expect(parseStackTrace(GO_PANIC)).toEqual([
+        { file: '/home/ci/build/app/server.go', line: 6, func: 'main.(*Server).handle' },
+        { file: '/home/ci/build/app/main.go', line: 4, func: 'main.main' },
+        { file: '/usr/local/go/src/runtime/proc.go', line: 271, func: 'runtime.main' },
+      ]);
+    });
+
+    it('should parse Python tracebacks innermost first', () => {
+      const trace = [
+        'Traceback (most recent call last):',
+        '  File "/srv/app/main.py", line 10, in <module>',
+        '    main()',
+        '  File "/srv/app/pkg/load.py", line 3, in load',
+        '    raise ValueError("bad")',
+        'ValueError: bad',
+      ].join('\n');
+      expect(parseStackTrace(trace)).toEqual([
+        { file: '/srv/app/pkg/load.py', line: 3, func: 'load' },
+        { file: '/srv/app/main.py', line: 10, func: '<module>' },
+      ]);
+    });
+
+    it('should parse V8 and Firefox stacks', () => {
+      const trace = [
+        'Error: boom',
+        '    at Server.handle (/app/dist/server.js:10:5)',
+        '    at /app/dist/index.js:3:1',
+        'handle@http://localhost:8080/static/app.js:7:12',
+      ].join('\n');
+      expect(parseStackTrace(trace)).toEqual([
+        { file: '/app/dist/server.js', line: 10, column: 5, func: 'Server.handle' },
+        { file: '/app/dist/index.js', line: 3, column: 1, func: undefined },
+        { file: 'http://localhost:8080/static/app.js', line: 7, column: 12, func: 'handle' },
+      ]);
+    });
+  });
+
+  describe('matchFramePath', () => {
+    const files = [file(path.join('app', 'server.go')), file(path.join('other', 'server.go')), file('server.go'), file(path.join('static', 'app.js'))];
+
+    it('should prefer the longest shared suffix', () => {
+      expect(matchFramePath('/home/ci/build/app/server.go', files).map((f) => f.relativePath)).toEqual([path.join('app', 'server.go')]);
+      expect(matchFramePath('C:\\build\\other\\server.go', files).map((f) => f.relativePath)).toEqual([path.join('other', 'server.go')]);
+    });
+
+    it('should accept trace paths shorter than the workspace path', () => {
+      expect(matchFramePath('./static/app.js', files).map((f) => f.relativePath)).toEqual([path.join('static', 'app.js')]);
+      expect(matchFramePath('http://localhost:8080/static/app.js', files)).toHaveLength(1);
+    });
+
+    it('should reject paths that diverge', () => {
+      expect(matchFramePath('/x/lib/app.js', files)).toEqual([]);
+    });
+  });
+
+  it('should recognize library frames', () => {
+    expect(isLibraryFrame('/usr/local/go/src/runtime/proc.go')).toBe(true);
+    expect(isLibraryFrame('node:internal/process/task_queues')).toBe(true);
+    expect(isLibraryFrame('/usr/lib/python3.11/site-packages/requests/api.py')).toBe(true);
+    expect(isLibraryFrame('/home/ci/build/app/server.go')).toBe(false);
+  });
+
+  it('should find function declarations', () => {
+    const content = 'func (s *Server) handle() {\n}\ndef load(x):\nconst run = async () => {\n  handle(x) {\nhandle(y);\n';
+    expect(findDeclarations(content, 'handle')).toEqual([1, 5]);
+    expect(findDeclarations(content, 'load')).toEqual([3]);
+    expect(findDeclarations(content, 'run')).toEqual([4]);
+  });
+
+  describe('resolveStackTrace', () => {
+    let workspaceDir: string;
+
+    beforeEach(() => {
+      workspaceDir = fs.mkdtempSync(path.join(os.tmpdir(), 'stacktrace-'));
+      fs.mkdirSync(path.join(workspaceDir, 'app'));
+      fs.writeFileSync(path.join(workspaceDir, 'app', 'server.go'),
+        'package main\n\ntype Server struct{ items []int }\n\nfunc (s *Server) handle() int {\n\treturn s.items[5]\n}\n');
+      fs.writeFileSync(path.join(workspaceDir, 'app', 'main.go'), 'package main\n\n\n\nfunc main() {\n\t(&Server{}).handle()\n}\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspaceDir, { recursive: true, force: true });
+    });
+
+    it('should resolve frames with snippets and flag stale lines', async () => {
+      const result = await resolveStackTrace(workspaceDir, GO_PANIC, { contextLines: 1 });
+      expect(result).toContain('Resolved 2 of 3 frame(s), 1 in libraries');
+      expect(result).toContain(`  → ${path.join('app', 'server.go')}:6\n      5| func (s *Server) handle() int {\n>     6| \treturn s.items[5]\n      7| }`);
+      // main.go:4 is above func main, which moved to line 5
+      expect(result).toContain('stale: line 4 is above main; main is declared at line 5');
+      expect(result).toContain('#2 runtime.main  /usr/local/go/src/runtime/proc.go:271  (library)');
+    });
+
+    it('should report frames outside the workspace', async () => {
+      const result = await resolveStackTrace(workspaceDir, '    at run (/srv/lib/missing.js:1:1)');
+      expect(result).toContain('Resolved 0 of 1 frame(s)');
+      expect(result).toContain('(not in the workspace)');
+    });
+
+    it('should reject text without frames', async () => {
+      await expect(resolveStackTrace(workspaceDir, 'just a message')).rejects.toThrow('No stack frames found');
+    });
+  });
+});
@@ -0,0 +1,271 @@
+/**
+ * Stack trace tool - resolves the frames of a pasted Go panic, Python traceback,
+ * or JavaScript stack to workspace files, with a snippet per frame
+ * Trace paths come from another machine or build directory, so files are
+ * matched by their longest common path suffix rather than by prefix
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { readFileText } from '../workspace/overlay.js';
+import { walkWorkspaceFiles, WorkspaceFile } from '../workspace/walker.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * A frame of a stack trace
+ */
+export interface StackFrame {
+  file: string; // As written in the trace
+  line: number; // 1-indexed
+  column?: number;
+  func?: string;
+}
+
+/**
+ * Options for stack trace resolution
+ */
+export interface StackTraceOptions {
+  // Lines shown around each frame's line (default: 2)
+  contextLines?: number;
+  // Also resolve frames in dependencies and standard libraries (default: false)
+  includeLibraries?: boolean;
+}
+
+/**
+ * Paths of dependencies and standard libraries, whose frames are not resolved
+ * by default; a workspace file sharing their base name is not the frame's file
+ */
+const LIBRARY_PATHS = [
+  /\/node_modules\//,
+  /\/(?:site|dist)-packages\//,
+  /\/lib\/python\d[\d.]*\//,
+  /\/go\/pkg\/mod\//,
+  /\/(?:go|libexec)\/src\//,
+  /^node:/,
+  /^<.*>$/,
+];
+
+/**
+ * Go: "\t/src/app/server.go:42 +0x1d", under a "pkg.(*T).Method(...)" line
+ */
+const GO_LOCATION = /^\s+(\S+\.go):(\d+)(?: \+0x[0-9a-f]+)?\s*$/;
+
+/**
+ * Python: '  File "/app/load.py", line 12, in load'
+ */
+const PYTHON_FRAME = /^\s*File "([^"]+)", line (\d+)(?:, in (.+))?\s*$/;
+
+/**
+ * V8: "    at Server.handle (/app/server.js:10:5)" or "    at /app/server.js:10:5"
+ */
+const V8_FRAME = /^\s*at (?:(.+?) \()?(.+?):(\d+)(?::(\d+))?\)?\s*$/;
+
+/**
+ * Firefox and Safari: "handle@http://host/app.js:10:5"
+ */
+const GECKO_FRAME = /^([^@\s]*)@(.+?):(\d+)(?::(\d+))?\s*$/;
+
+/**
+ * Parse the frames of a stack trace, innermost first as the trace lists them
+ * Lines that are not frames (messages, goroutine headers) are skipped.
+ */
+export function parseStackTrace(trace: string): StackFrame[] {
+  const frames: StackFrame[] = [];
+  const lines = trace.split(/\r?\n/);
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i];
+    let match = GO_LOCATION.exec(line);
+    if (match) {
+      // The function is on the line above, with its arguments
+      const call = i > 0 ? lines[i - 1].trim().replace(/^created by /, '').replace(/ in goroutine \d+$/, '') : '';
+      frames.push({
+        file: match[1],
+        line: parseInt(match[2], 10),
+        func: call.includes('(') && call.endsWith(')') ? call.substring(0, call.lastIndexOf('(')) : call || undefined,
+      });
+      continue;
+    }
+    match = PYTHON_FRAME.exec(line);
+    if (match) {
+      frames.push({ file: match[1], line: parseInt(match[2], 10), func: match[3] });
+      continue;
+    }
+    match = V8_FRAME.exec(line) ?? GECKO_FRAME.exec(line);
+    if (match) {
+      frames.push({
+        file: match[2],
+        line: parseInt(match[3], 10),
+        column: match[4] ? parseInt(match[4], 10) : undefined,
+        func: match[1] || undefined,
+      });
+    }
+  }
+  // Python lists the innermost frame last
+  if (lines.some((line) => line.startsWith('Traceback (most recent call last)'))) {
+    frames.reverse();
+  }
+  return frames;
+}
+
+/**
+ * Path segments of a trace path, without URL schemes, bundler prefixes, and queries
+ */
+function pathSegments(file: string): string[] {
+  const cleaned = file
+    .replace(/^https?:\/\/[^/]*/, '')
+    .replace(/^(?:file|webpack|webpack-internal):\/+/, '/')
+    .replace(/[?#].*$/, '')
+    .replace(/\\/g, '/')
+    .replace(/^[a-zA-Z]:/, '');
+  return cleaned.split('/').filter((segment) => segment !== '' && segment !== '.');
+}
+
+/**
+ * Check if a frame is in a dependency or standard library
+ */
+export function isLibraryFrame(file: string): boolean {
+  const normalized = file.replace(/\\/g, '/');
+  return LIBRARY_PATHS.some((pattern) => pattern.test(normalized));
+}
+
+/**
+ * Workspace files a trace path may refer to: those sharing the longest path
+ * suffix with it, where one of the two paths is a suffix of the other
+ */
+export function matchFramePath(file: string, files: WorkspaceFile[]): WorkspaceFile[] {
+  const segments = pathSegments(file);
+  const base = segments[segments.length - 1];
+  let best: WorkspaceFile[] = [];
+  let bestLength = 0;
+  for (const candidate of files) {
+    const candidateSegments = candidate.relativePath.split(/[\\/]/);
+    if (candidateSegments[candidateSegments.length - 1] !== base) {
+      continue;
+    }
+    let shared = 0;
+    while (
+      shared < segments.length && shared < candidateSegments.length &&
+      segments[segments.length - 1 - shared] === candidateSegments[candidateSegments.length - 1 - shared]
+    ) {
+      shared++;
+    }
+    if (shared < Math.min(segments.length, candidateSegments.length)) {
+      continue;
+    }
+    if (shared > bestLength) {
+      best = [candidate];
+      bestLength = shared;
+    } else if (shared === bestLength) {
+      best.push(candidate);
+    }
+  }
+  return best.sort((a, b) => a.relativePath.localeCompare(b.relativePath));
+}
+
+/**
+ * Name a frame's function is declared with: "handle" for "main.(*Server).handle",
+ * "Server.handle", or "async Server.handle"
+ */
+function declaredName(func: string | undefined): string | undefined {
+  const names = func?.match(/[A-Za-z_$][\w$]*/g);
+  const name = names?.[names.length - 1];
+  return name && name !== 'anonymous' && name !== 'module' && !/^func\d+$/.test(name) ? name : undefined;
+}
+
+/**
+ * 1-indexed lines declaring a function or method name in Go, Python, or JavaScript
+ */
+export function findDeclarations(content: string, name: string): number[] {
+  const escaped = name.replace(/\$/g, '\\$');
+  const patterns = [
+    new RegExp(`^\\s*func\\s+(?:\\([^)]*\\)\\s*)?${escaped}\\b`),
+    new RegExp(`^\\s*(?:async\\s+)?def\\s+${escaped}\\b`),
+    new RegExp(`\\bfunction\\s*\\*?\\s*${escaped}\\b`),
+    new RegExp(`(?:^|[\\s.])${escaped}\\s*[:=]\\s*(?:async\\s*)?(?:function\\b|\\([^)]*\\)\\s*(?::[^=]*)?=>|[\\w$]+\\s*=>)`),
+    new RegExp(`^\\s*(?:(?:public|private|protected|static|async|get|set|override)\\s+)*${escaped}\\s*\\([^)]*\\)\\s*(?::[^{]*)?\\{\\s*$`),
+  ];
+  const lines: number[] = [];
+  content.split('\n').forEach((text, index) => {
+    if (patterns.some((pattern) => pattern.test(text))) {
+      lines.push(index + 1);
+    }
+  });
+  return lines;
+}
+
+/**
+ * Lines around a 1-indexed line, the line itself marked
+ */
+function snippet(lines: string[], line: number, contextLines: number): string {
+  const output: string[] = [];
+  for (let n = Math.max(1, line - contextLines); n <= Math.min(lines.length, line + contextLines); n++) {
+    output.push(`${n === line ? '>' : ' '}${String(n).padStart(6)}| ${lines[n - 1].replace(/\r$/, '')}`);
+  }
+  return output.join('\n');
+}
+
+/**
+ * Resolve a pasted stack trace to workspace files and show each frame's code
+ * Frames whose recorded line no longer fits their function - past the end of
+ * the file, or above the function's declaration - are flagged as stale, with
+ * the declaration's current line
+ */
+export async function resolveStackTrace(workspaceDir: string, trace: string, options: StackTraceOptions = {}): Promise<string> {
+  const frames = parseStackTrace(trace);
+  if (frames.length === 0) {
+    throw new ToolError('invalid-argument', 'No stack frames found; expected a Go panic, Python traceback, or JavaScript stack');
+  }
+  const contextLines = options.contextLines ?? 2;
+  const files = await walkWorkspaceFiles(workspaceDir);
+  toolsLogger.debug('Resolving %d stack frames against %d files', frames.length, files.length);
+
+  const sections: string[] = [];
+  let resolved = 0;
+  let libraries = 0;
+  for (let i = 0; i < frames.length; i++) {
+    const frame = frames[i];
+    const header = `#${i} ${frame.func ? `${frame.func}  ` : ''}${frame.file}:${frame.line}${frame.column ? `:${frame.column}` : ''}`;
+    if (!options.includeLibraries && isLibraryFrame(frame.file)) {
+      libraries++;
+      sections.push(`${header}  (library)`);
+      continue;
+    }
+    const candidates = matchFramePath(frame.file, files);
+    if (candidates.length === 0) {
+      sections.push(`${header}  (not in the workspace)`);
+      continue;
+    }
+    let content: string;
+    try {
+      content = await readFileText(candidates[0].absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Error reading file %s: %s', candidates[0].absolutePath, err);
+      sections.push(`${header}  (cannot read ${candidates[0].relativePath})`);
+      continue;
+    }
+    resolved++;
+    const lines = content.split('\n');
+    let section = `${header}\n  → ${candidates[0].relativePath}:${frame.line}`;
+    if (candidates.length > 1) {
+      section += `  (also ${candidates.slice(1, 4).map((c) => c.relativePath).join(', ')}${candidates.length > 4 ? ', ...' : ''})`;
+    }
+    const name = declaredName(frame.func);
+    const declarations = name ? findDeclarations(content, name) : [];
+    const enclosing = declarations.filter((line) => line <= frame.line).pop();
+    if (frame.line > lines.length || (declarations.length > 0 && enclosing === undefined)) {
+      section += `\n  stale: line ${frame.line} ${frame.line > lines.length ? `is past the end of the file (${lines.length} lines)` : `is above ${name}`}` +
+        (declarations.length > 0 ? `; ${name} is declared at line ${declarations[0]}` : '');
+    }
+    if (frame.line <= lines.length) {
+      section += `\n${snippet(lines, frame.line, contextLines)}`;
+    }
+    sections.push(section);
+  }
+
+  const counts = [`Resolved ${resolved} of ${frames.length} frame(s)`];
+  if (libraries > 0) {
+    counts.push(`${libraries} in libraries`);
+  }
+  return `${counts.join(', ')}\n\n${sections.join('\n\n')}`;
+}
@@ -0,0 +1,66 @@
+/**
+ * Tests for the stats tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { countLines, workspaceStats } from './stats';
+
+describe('stats', () => {
+  it('should count code, comment, and blank lines', () => {
+    const go = [
+      '// Package x does things',
+      'package x',
+      '',
+      '/*',
+      ' * Block comment',
+      ' */',
+      'func f() {} /* trailing',
+      'still comment */',
+      '/* one line */ var y = 1',
+    ].join('\n') + '\n';
+    expect(countLines(go, 'go')).toEqual({ code: 3, comment: 5, blank: 1 });
+    expect(countLines('# comment\nx = 1\n\n', 'python')).toEqual({ code: 1, comment: 1, blank: 1 });
+    expect(countLines('// not a comment\n', 'plaintext')).toEqual({ code: 1, comment: 0, blank: 0 });
+  });
+
+  describe('workspaceStats', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'stats-'));
+      const files: Record<string, string | Buffer> = {
+        'main.go': 'package main\n\n// main runs\nfunc main() {}\n',
+        'pkg/a.go': 'package pkg\n',
+        'pkg/b.py': '# b\nprint(1)\n',
+        'docs/README.md': '# Title\n',
+        'blob.dat': Buffer.from([0x01, 0, 0, 0, 0x02, 0, 0xff, 0]),
+      };
+      for (const [file, content] of Object.entries(files)) {
+        fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+        fs.writeFileSync(path.join(workspace, file), content);
+      }
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should tabulate per language and per directory', async () => {
+      const result = await workspaceStats(workspace);
+      const sections = result.split('\n\n');
+      expect(sections[0]).toBe('Line counts under .');
+      expect(sections[1].split('\n').map((line) => line.split(/ {2,}/))).toEqual([
+        ['language', 'files', 'code', 'comment', 'blank'],
+        ['go', '2', '3', '1', '1'],
+        ['other (.md)', '1', '1', '0', '0'],
+        ['python', '1', '1', '1', '0'],
+        ['total', '4', '5', '2', '1'],
+      ]);
+      expect(sections[2].split('\n').map((line) => line.split(/ {2,}/)[0])).toEqual(['directory', '(root files)', 'pkg/', 'docs/', 'total']);
+      expect(sections[3]).toBe('Not counted, as searches skip them: 1 binary file(s)');
+      expect((await workspaceStats(workspace, { path: 'pkg' })).split('\n\n')[2]).toContain('(root files)');
+    });
+  });
+});
@@ -0,0 +1,168 @@
+/**
+ * Stats tool - cloc-style line counts per language and per top-level directory
+ * Counts the files searches see, with the same ignore rules and size limit,
+ * so the totals double as a check of what the index covers
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { isBinaryFile } from '../workspace/binary.js';
+import { decodeText } from '../workspace/encoding.js';
+import { detectLanguageId } from '../workspace/language.js';
+import { readFileBytes } from '../workspace/overlay.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+import { HASH_COMMENT_LANGUAGES } from './duplicates.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Line counts of a file or a group of files
+ */
+export interface LineCounts {
+  code: number;
+  comment: number;
+  blank: number;
+}
+
+/**
+ * Count code, comment, and blank lines
+ * Comment lines hold only a comment; a line with code and a comment counts as code.
+ * Files of unknown languages have no comments.
+ */
+export function countLines(content: string, languageId: string): LineCounts {
+  const counts: LineCounts = { code: 0, comment: 0, blank: 0 };
+  const lines = content.split('\n');
+  if (lines[lines.length - 1] === '') {
+    lines.pop();
+  }
+  const hashComments = HASH_COMMENT_LANGUAGES.has(languageId);
+  const slashComments = !hashComments && languageId !== 'plaintext';
+  let inBlock = false;
+
+  for (const raw of lines) {
+    const line = raw.trim();
+    if (inBlock) {
+      const end = line.indexOf('*/');
+      if (end === -1) {
+        counts[line ? 'comment' : 'blank']++;
+        continue;
+      }
+      inBlock = false;
+      counts[line.substring(end + 2).trim() ? 'code' : 'comment']++;
+      continue;
+    }
+    if (!line) {
+      counts.blank++;
+    } else if ((hashComments && line.startsWith('#')) || (slashComments && line.startsWith('//'))) {
+      counts.comment++;
+    } else if (slashComments && line.startsWith('/*')) {
+      const end = line.indexOf('*/', 2);
+      inBlock = end === -1;
+      counts[end !== -1 && line.substring(end + 2).trim() ? 'code' : 'comment']++;
+    } else {
+      counts.code++;
+      // A block comment opened after code continues on the next lines
+      const open = slashComments ? line.lastIndexOf('/*') : -1;
+      inBlock = open !== -1 && line.indexOf('*/', open + 2) === -1;
+    }
+  }
+  return counts;
+}
+
+/**
+ * Options for the stats tool
+ */
+export interface StatsOptions {
+  path?: string;
+}
+
+interface Totals extends LineCounts {
+  files: number;
+}
+
+/**
+ * Add a file's counts to its group
+ */
+function add(totals: Map<string, Totals>, key: string, counts: LineCounts): void {
+  const entry = totals.get(key) ?? { files: 0, code: 0, comment: 0, blank: 0 };
+  entry.files++;
+  entry.code += counts.code;
+  entry.comment += counts.comment;
+  entry.blank += counts.blank;
+  totals.set(key, entry);
+}
+
+/**
+ * Right-aligned table of totals, largest code count first, with a total row
+ */
+function formatTable(title: string, totals: Map<string, Totals>): string {
+  const rows = [...totals.entries()].sort((a, b) => b[1].code - a[1].code || a[0].localeCompare(b[0]));
+  const sum: Totals = { files: 0, code: 0, comment: 0, blank: 0 };
+  for (const [, entry] of rows) {
+    sum.files += entry.files;
+    sum.code += entry.code;
+    sum.comment += entry.comment;
+    sum.blank += entry.blank;
+  }
+  const cells = [[title, 'files', 'code', 'comment', 'blank'],
+    ...[...rows, ['total', sum] as [string, Totals]].map(([name, entry]) =>
+      [name, String(entry.files), String(entry.code), String(entry.comment), String(entry.blank)])];
+  const widths = cells[0].map((_, column) => Math.max(...cells.map((row) => row[column].length)));
+  return cells
+    .map((row) => row.map((cell, column) => column === 0 ? cell.padEnd(widths[0]) : cell.padStart(widths[column])).join('  '))
+    .join('\n');
+}
+
+/**
+ * Files, code lines, comment lines, and blank lines per language and per top-level directory
+ */
+export async function workspaceStats(workspaceDir: string, options: StatsOptions = {}): Promise<string> {
+  const start = options.path ? resolveWorkspacePath(workspaceDir, options.path) : workspaceDir;
+  toolsLogger.debug('Counting lines under %s', start);
+  let largeFiles = 0;
+  const files = await walkWorkspaceFiles(workspaceDir, { pathPrefix: start, onLargeFile: () => largeFiles++ });
+
+  const byLanguage = new Map<string, Totals>();
+  const byDirectory = new Map<string, Totals>();
+  let binaryFiles = 0;
+  for (const file of files) {
+    let content: Buffer;
+    try {
+      content = await readFileBytes(file.absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', file.relativePath, (err as Error).message);
+      continue;
+    }
+    if (isBinaryFile(file.absolutePath, content)) {
+      binaryFiles++;
+      continue;
+    }
+    const languageId = detectLanguageId(file.relativePath);
+    const counts = countLines(decodeText(content), languageId);
+    const extension = path.extname(file.relativePath).toLowerCase();
+    add(byLanguage, languageId !== 'plaintext' ? languageId : extension ? `other (${extension})` : 'other', counts);
+    const relative = path.relative(start, file.absolutePath).split(path.sep);
+    add(byDirectory, relative.length > 1 ? `${relative[0]}/` : '(root files)', counts);
+  }
+
+  const rootName = path.relative(workspaceDir, start) || '.';
+  if (byLanguage.size === 0) {
+    return `No text files under ${rootName}`;
+  }
+  const sections = [
+    `Line counts under ${rootName}`,
+    formatTable('language', byLanguage),
+    formatTable('directory', byDirectory),
+  ];
+  const skipped: string[] = [];
+  if (binaryFiles > 0) {
+    skipped.push(`${binaryFiles} binary`);
+  }
+  if (largeFiles > 0) {
+    skipped.push(`${largeFiles} larger than SEARCH_MAX_FILE_SIZE`);
+  }
+  if (skipped.length > 0) {
+    sections.push(`Not counted, as searches skip them: ${skipped.join(', ')} file(s)`);
+  }
+  return sections.join('\n\n');
+}
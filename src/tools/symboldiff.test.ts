@@ -0,0 +1,120 @@
+/**
+ * Tests for the symbol diff tool
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { SymbolKind } from '../protocol/types';
+import { diffDeclarations, fileDeclarations, symbolDiff } from './symboldiff';
+import { parseNameStatus } from '../git/git';
+
+describe('symbol diff', () => {
+  it('should read Go declarations with signatures', () => {
+    const declarations = fileDeclarations('store.go', [
+      'package store',
+      'type Store interface { Get(key string) (string, error) }',
+      'type Mem struct{}',
+      'func (m *Mem) Get(key string) (string, error) { return "", nil }',
+      'func Open(dir string) *Mem { return nil }',
+    ].join('\n'));
+    expect(declarations).toEqual([
+      { name: 'Open', kind: SymbolKind.Function, signature: 'func Open(string) *Mem', line: 5 },
+      { name: 'Mem.Get', kind: SymbolKind.Method, signature: 'func (*Mem) Get(string) (string, error)', line: 4 },
+      { name: 'Mem', kind: SymbolKind.Struct, signature: 'type Mem struct', line: 3 },
+      { name: 'Store', kind: SymbolKind.Interface, signature: 'type Store interface { Get(string) (string, error) }', line: 2 },
+    ]);
+    expect(fileDeclarations('README.md', '# x')).toBeUndefined();
+  });
+
+  it('should compare declarations by name', () => {
+    const before = fileDeclarations('a.py', 'def load(path):\n    pass\n\ndef old():\n    pass\n')!;
+    const after = fileDeclarations('a.py', 'def load(path, strict=False):\n    pass\n\ndef new():\n    pass\n')!;
+    expect(diffDeclarations(before, after, 'a.py')).toEqual([
+      { change: 'changed', path: 'a.py', name: 'load', kind: SymbolKind.Function, line: 1, before: 'def load(path)', after: 'def load(path, strict=False)' },
+      { change: 'added', path: 'a.py', name: 'new', kind: SymbolKind.Function, line: 4 },
+      { change: 'removed', path: 'a.py', name: 'old', kind: SymbolKind.Function, line: 4 },
+    ]);
+  });
+
+  it('should parse name-status output', () => {
+    expect(parseNameStatus('M\ta.ts\nA\tb.go\nD\tc.py\nR087\told.ts\tnew.ts\n')).toEqual([
+      { status: 'modified', path: 'a.ts' },
+      { status: 'added', path: 'b.go' },
+      { status: 'deleted', path: 'c.py' },
+      { status: 'renamed', path: 'new.ts', oldPath: 'old.ts' },
+    ]);
+  });
+
+  describe('symbolDiff', () => {
+    let workspace: string;
+    let git: (...args: string[]) => Buffer;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'symboldiff-'));
+      git = (...args: string[]) => execFileSync('git', ['-c', 'user.name=t', '-c', 'user.email=t@t', ...args], { cwd: workspace });
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should summarize a branch and the work tree', async () => {
+      try {
+        git('init', '-q', '-b', 'main');
+        fs.writeFileSync(path.join(workspace, 'server.ts'), [
+          'export class Server {',
+          '  handle(req: Request): void {}',
+          '}',
+          'export function helper(): number {',
+          '  return 1;',
+          '}',
+          'export function legacy() {}',
+        ].join('\n'));
+        fs.writeFileSync(path.join(workspace, 'README.md'), 'x\n');
+        git('add', '.');
+        git('commit', '-qm', 'base');
+        git('checkout', '-qb', 'feature');
+        fs.writeFileSync(path.join(workspace, 'server.ts'), [
+          'export class Server {',
+          '  handle(req: Request, res: Response): void {}',
+          '  listen(port: number): void {}',
+          '}',
+        ].join('\n'));
+        fs.writeFileSync(path.join(workspace, 'util.ts'), 'export function helper(): number {\n  return 2;\n}\n');
+        fs.writeFileSync(path.join(workspace, 'README.md'), 'y\n');
+        git('add', '.');
+        git('commit', '-qm', 'feature');
+        // main moves on; its changes are not the branch's
+        git('checkout', '-q', 'main');
+        fs.writeFileSync(path.join(workspace, 'server.ts'), 'export function mainOnly() {}\n');
+        git('commit', '-qam', 'main');
+        git('checkout', '-q', 'feature');
+      } catch (err) {
+        return; // git is not available
+      }
+      const result = await symbolDiff(workspace, 'main', 'feature');
+      const lines = result.split('\n');
+      expect(lines[0]).toMatch(/^Symbol changes from main \(merge base [0-9a-f]{7}\) to feature \([0-9a-f]{7}\): 3 changed file\(s\), 1 added, 1 removed, 1 changed, 1 moved$/);
+      expect(lines[1]).toBe('1 file(s) without a declaration parser: README.md');
+      expect(lines.slice(2)).toEqual([
+        '',
+        'server.ts',
+        '  ~ method Server.handle  L2',
+        '      was: handle(req: Request): void',
+        '      now: handle(req: Request, res: Response): void',
+        '  + method Server.listen  L3',
+        '  - function legacy',
+        '',
+        'util.ts',
+        '  > function helper  L1, moved from server.ts',
+      ]);
+
+      fs.appendFileSync(path.join(workspace, 'util.ts'), 'export const VERSION = 2;\n');
+      const worktree = await symbolDiff(workspace, 'HEAD');
+      expect(worktree).toMatch(/to the work tree: 1 changed file\(s\), 1 added\n\nutil\.ts\n {2}\+ constant VERSION {2}L4$/);
+      await expect(symbolDiff(workspace, 'nope')).rejects.toThrow('Unknown revision nope');
+    });
+  });
+});
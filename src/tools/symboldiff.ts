@@ -0,0 +1,276 @@
+/**
+ * Symbol diff tool - the declarations two revisions added, removed, moved, or
+ * changed the signature of, instead of their line diff
+ * Both sides are parsed from file text (the built-in Go, Python, TypeScript,
+ * and .proto parsers), so no language server has to see the old revision
+ */
+
+import * as path from 'path';
+import { changedFiles, fileAtRevision, isGitRepository, mergeBase, resolveRevision, ChangedFile } from '../git/git.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { SymbolKind, SymbolKindNames } from '../protocol/types.js';
+import { formatGoMethod, parseGoDeclarations } from '../symbols/golang.js';
+import { isProtoFile, parseProtoSymbols } from '../symbols/proto.js';
+import { isPythonFile, parsePythonSymbols } from '../symbols/python.js';
+import { isTypeScriptFile, parseTypeScriptDeclarations } from '../symbols/typescript.js';
+import { readFileText } from '../workspace/overlay.js';
+import { resolveWorkspacePath } from '../workspace/walker.js';
+import { flattenDocumentSymbols } from './symbols.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * A declaration and its signature
+ */
+export interface Declaration {
+  name: string; // Qualified, e.g. "Server.handle"
+  kind: SymbolKind;
+  signature: string;
+  line: number; // 1-indexed
+}
+
+/**
+ * How a declaration differs between the revisions
+ */
+export interface SymbolChange {
+  change: 'added' | 'removed' | 'changed' | 'moved';
+  path: string; // The newer file (the older one for removals)
+  name: string;
+  kind: SymbolKind;
+  line: number; // In the newer file (the older one for removals)
+  before?: string; // Signatures, for changes
+  after?: string;
+  from?: string; // The older file, for moves
+}
+
+/**
+ * Options for the symbol diff tool
+ */
+export interface SymbolDiffOptions {
+  // Compare from the merge base of base and head, as a pull request does (default: true)
+  mergeBase?: boolean;
+  // Only report files under this path
+  path?: string;
+  // Most changes listed (default: 200)
+  limit?: number;
+}
+
+/**
+ * Declarations of a Go file: functions, methods qualified by receiver,
+ * types, and interfaces with their method sets as signature
+ */
+function goDeclarations(content: string): Declaration[] {
+  const parsed = parseGoDeclarations(content);
+  return [
+    ...parsed.functions.map((fn) => ({ name: fn.name, kind: SymbolKind.Function, signature: `func ${formatGoMethod(fn)}`, line: fn.line })),
+    ...parsed.methods.map((method) => ({
+      name: `${method.receiver}.${method.name}`,
+      kind: SymbolKind.Method,
+      signature: `func (${method.pointer ? '*' : ''}${method.receiver}) ${formatGoMethod(method)}`,
+      line: method.line,
+    })),
+    ...parsed.types.map((type) => ({
+      name: type.name,
+      kind: type.kind === 'struct' ? SymbolKind.Struct : SymbolKind.Class,
+      signature: `type ${type.name}${type.kind === 'struct' ? ' struct' : ''}`,
+      line: type.line,
+    })),
+    ...parsed.interfaces.map((iface) => ({
+      name: iface.name,
+      kind: SymbolKind.Interface,
+      signature: `type ${iface.name} interface { ${[...iface.embeds, ...iface.methods.map(formatGoMethod)].sort().join('; ')} }`,
+      line: iface.line,
+    })),
+  ];
+}
+
+/**
+ * Declarations of a file, or undefined when there is no parser for its language
+ */
+export function fileDeclarations(filePath: string, content: string): Declaration[] | undefined {
+  if (filePath.endsWith('.go')) {
+    return goDeclarations(content);
+  }
+  const symbols = isPythonFile(filePath) ? parsePythonSymbols(content)
+    : isProtoFile(filePath) ? parseProtoSymbols(content)
+      : isTypeScriptFile(filePath) && !filePath.endsWith('.d.ts') ? parseTypeScriptDeclarations(content)
+        : undefined;
+  return symbols && flattenDocumentSymbols(symbols).map((sym) => ({
+    name: sym.qualifiedName,
+    kind: sym.kind,
+    signature: sym.detail ?? '',
+    line: sym.range.start.line + 1,
+  }));
+}
+
+/**
+ * Compare a file's declarations by qualified name; the first of repeated names counts
+ */
+export function diffDeclarations(before: Declaration[], after: Declaration[], filePath: string, oldPath = filePath): SymbolChange[] {
+  const index = (declarations: Declaration[]) => {
+    const byName = new Map<string, Declaration>();
+    for (const declaration of declarations) {
+      if (!byName.has(declaration.name)) {
+        byName.set(declaration.name, declaration);
+      }
+    }
+    return byName;
+  };
+  const old = index(before);
+  const current = index(after);
+  const changes: SymbolChange[] = [];
+  for (const [name, declaration] of current) {
+    const previous = old.get(name);
+    if (!previous) {
+      changes.push({ change: 'added', path: filePath, name, kind: declaration.kind, line: declaration.line });
+    } else if (previous.signature !== declaration.signature || previous.kind !== declaration.kind) {
+      changes.push({
+        change: 'changed', path: filePath, name, kind: declaration.kind, line: declaration.line,
+        before: previous.signature, after: declaration.signature,
+      });
+    }
+  }
+  for (const [name, declaration] of old) {
+    if (!current.has(name)) {
+      changes.push({ change: 'removed', path: oldPath, name, kind: declaration.kind, line: declaration.line });
+    }
+  }
+  return changes;
+}
+
+/**
+ * Pair removals with additions of the same name and signature in another file as moves
+ */
+function detectMoves(changes: SymbolChange[], signatures: Map<SymbolChange, string>): SymbolChange[] {
+  const moved = new Set<SymbolChange>();
+  for (const removal of changes.filter((c) => c.change === 'removed')) {
+    const addition = changes.find((c) => c.change === 'added' && !moved.has(c) && c.path !== removal.path &&
+      c.name === removal.name && c.kind === removal.kind && signatures.get(c) === signatures.get(removal));
+    if (addition) {
+      moved.add(removal);
+      moved.add(addition);
+      addition.change = 'moved';
+      addition.from = removal.path;
+    }
+  }
+  return changes.filter((c) => !moved.has(c) || c.change === 'moved');
+}
+
+/**
+ * Lowercase kind name, with type aliases as "type"
+ */
+function kindLabel(kind: SymbolKind): string {
+  return kind === SymbolKind.TypeParameter ? 'type' : (SymbolKindNames[kind] || 'symbol').toLowerCase();
+}
+
+/**
+ * Format changes grouped by file
+ */
+function formatChanges(changes: SymbolChange[]): string {
+  const lines: string[] = [];
+  let file: string | undefined;
+  for (const change of changes) {
+    if (change.path !== file) {
+      file = change.path;
+      lines.push('', file);
+    }
+    const label = `${kindLabel(change.kind)} ${change.name}`;
+    switch (change.change) {
+      case 'added':
+        lines.push(`  + ${label}  L${change.line}`);
+        break;
+      case 'removed':
+        lines.push(`  - ${label}`);
+        break;
+      case 'moved':
+        lines.push(`  > ${label}  L${change.line}, moved from ${change.from}`);
+        break;
+      case 'changed':
+        lines.push(`  ~ ${label}  L${change.line}`, `      was: ${change.before}`, `      now: ${change.after}`);
+        break;
+    }
+  }
+  return lines.join('\n');
+}
+
+/**
+ * Summarize the declarations changed from base to head (a revision, or the
+ * work tree when omitted)
+ */
+export async function symbolDiff(workspaceDir: string, base: string, head?: string, options: SymbolDiffOptions = {}): Promise<string> {
+  if (!(await isGitRepository(workspaceDir))) {
+    throw new ToolError('unsupported', 'The workspace is not a git repository');
+  }
+  const resolve = async (revision: string) => {
+    try {
+      return await resolveRevision(workspaceDir, revision);
+    } catch (err) {
+      throw new ToolError('not-found', `Unknown revision ${revision}`);
+    }
+  };
+  const baseCommit = await resolve(base);
+  const headCommit = head ? await resolve(head) : undefined;
+  const from = (options.mergeBase ?? true) ? await mergeBase(workspaceDir, baseCommit, headCommit ?? 'HEAD') : baseCommit;
+  const prefix = options.path ? path.relative(workspaceDir, resolveWorkspacePath(workspaceDir, options.path)) : '';
+  const files = (await changedFiles(workspaceDir, from, headCommit))
+    .filter((file) => !prefix || [file.path, file.oldPath].some((p) => p && (p === prefix || p.startsWith(prefix + path.sep) || p.startsWith(prefix + '/'))));
+  toolsLogger.debug('Diffing symbols of %d files from %s to %s', files.length, from, headCommit ?? 'the work tree');
+
+  const read = async (file: ChangedFile, side: 'old' | 'new'): Promise<string> => {
+    if ((side === 'old' && file.status === 'added') || (side === 'new' && file.status === 'deleted')) {
+      return '';
+    }
+    if (side === 'old') {
+      return fileAtRevision(workspaceDir, from, file.oldPath ?? file.path);
+    }
+    return headCommit ? fileAtRevision(workspaceDir, headCommit, file.path) : readFileText(path.join(workspaceDir, file.path));
+  };
+
+  let changes: SymbolChange[] = [];
+  const signatures = new Map<SymbolChange, string>();
+  const unparsed: string[] = [];
+  for (const file of files) {
+    if (!fileDeclarations(file.path, '')) {
+      unparsed.push(file.path);
+      continue;
+    }
+    let before: Declaration[];
+    let after: Declaration[];
+    try {
+      before = fileDeclarations(file.oldPath ?? file.path, await read(file, 'old')) ?? [];
+      after = fileDeclarations(file.path, await read(file, 'new')) ?? [];
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', file.path, (err as Error).message);
+      unparsed.push(file.path);
+      continue;
+    }
+    for (const change of diffDeclarations(before, after, file.path, file.oldPath ?? file.path)) {
+      const declaration = (change.change === 'removed' ? before : after).find((d) => d.name === change.name);
+      signatures.set(change, declaration?.signature ?? '');
+      changes.push(change);
+    }
+  }
+  changes = detectMoves(changes, signatures)
+    .sort((a, b) => a.path.localeCompare(b.path) || a.line - b.line);
+
+  const target = headCommit ? `${head} (${headCommit.substring(0, 7)})` : 'the work tree';
+  const origin = from === baseCommit ? `${base} (${from.substring(0, 7)})` : `${base} (merge base ${from.substring(0, 7)})`;
+  const counts = (['added', 'removed', 'changed', 'moved'] as const)
+    .map((kind) => [kind, changes.filter((c) => c.change === kind).length] as const)
+    .filter(([, count]) => count > 0)
+    .map(([kind, count]) => `${count} ${kind}`);
+  let output = `Symbol changes from ${origin} to ${target}: ${files.length} changed file(s)`;
+  output += changes.length > 0 ? `, ${counts.join(', ')}` : ', no declarations changed';
+  if (unparsed.length > 0) {
+    output += `\n${unparsed.length} file(s) without a declaration parser: ${unparsed.slice(0, 5).join(', ')}${unparsed.length > 5 ? ', ...' : ''}`;
+  }
+  const limit = options.limit ?? 200;
+  if (changes.length > 0) {
+    output += `\n${formatChanges(changes.slice(0, limit))}`;
+  }
+  if (changes.length > limit) {
+    output += `\n\n${changes.length - limit} more change(s) not shown`;
+  }
+  return output;
+}
@@ -0,0 +1,149 @@
+/**
+ * Document symbol helpers shared by tools
+ * Python files fall back to the built-in parser when the attached language
+ * server (if any) is not a Python server; .proto files always use the
+ * built-in parser
+ */
+
+import { LSPClient } from '../lsp/client.js';
+import { documentSymbols, symbol } from '../lsp/methods.js';
+import {
+  DocumentSymbol,
+  SymbolInformation,
+  SymbolKind,
+  Range,
+  TextDocumentIdentifier,
+  WorkspaceSymbol,
+} from '../protocol/types.js';
+import { pathToUri } from '../protocol/uri.js';
+import { isPythonFile, isPythonServer, sharedPythonSymbols } from '../symbols/python.js';
+import { isProtoFile, sharedProtoSymbols } from '../symbols/proto.js';
+import { ToolError } from '../workspace/errors.js';
+
+/**
+ * Flattened symbol with its qualified name
+ */
+export interface FlatSymbol {
+  name: string;
+  qualifiedName: string;
+  containerName?: string;
+  kind: SymbolKind;
+  detail?: string;
+  range: Range;
+  selectionRange: Range;
+  depth: number;
+}
+
+/**
+ * Check if a symbol result is hierarchical (DocumentSymbol) rather than flat
+ */
+function isDocumentSymbol(sym: DocumentSymbol | SymbolInformation): sym is DocumentSymbol {
+  return 'range' in sym && 'selectionRange' in sym;
+}
+
+/**
+ * Flatten document symbols into a list ordered by position
+ */
+export function flattenDocumentSymbols(symbols: (DocumentSymbol | SymbolInformation)[]): FlatSymbol[] {
+  const result: FlatSymbol[] = [];
+
+  const visit = (sym: DocumentSymbol, parent: string, depth: number): void => {
+    const qualifiedName = parent ? `${parent}.${sym.name}` : sym.name;
+    result.push({
+      name: sym.name,
+      qualifiedName,
+      containerName: parent || undefined,
+      kind: sym.kind,
+      detail: sym.detail,
+      range: sym.range,
+      selectionRange: sym.selectionRange,
+      depth,
+    });
+    for (const child of sym.children || []) {
+      visit(child, qualifiedName, depth + 1);
+    }
+  };
+
+  for (const sym of symbols) {
+    if (isDocumentSymbol(sym)) {
+      visit(sym, '', 0);
+    } else {
+      const info = sym as SymbolInformation;
+      result.push({
+        name: info.name,
+        qualifiedName: info.containerName ? `${info.containerName}.${info.name}` : info.name,
+        containerName: info.containerName || undefined,
+        kind: info.kind,
+        range: info.location.range,
+        selectionRange: info.location.range,
+        depth: info.containerName ? 1 : 0,
+      });
+    }
+  }
+
+  result.sort((a, b) => a.range.start.line - b.range.start.line || a.depth - b.depth);
+  return result;
+}
+
+/**
+ * Find the innermost symbol enclosing a 0-indexed line
+ */
+export function findEnclosingSymbol(symbols: FlatSymbol[], line: number): FlatSymbol | undefined {
+  let best: FlatSymbol | undefined;
+  for (const sym of symbols) {
+    if (sym.range.start.line <= line && line <= sym.range.end.line) {
+      if (
+        !best ||
+        sym.depth > best.depth ||
+        (sym.range.end.line - sym.range.start.line) < (best.range.end.line - best.range.start.line)
+      ) {
+        best = sym;
+      }
+    }
+  }
+  return best;
+}
+
+/**
+ * Check if Python symbols come from the built-in parser rather than the language server
+ */
+export function usesPythonFallback(client: LSPClient | undefined): boolean {
+  return !client || !isPythonServer(client.command);
+}
+
+/**
+ * Open a file and return its flattened document symbols
+ */
+export async function getFileSymbols(client: LSPClient | undefined, workspaceDir: string, filePath: string): Promise<FlatSymbol[]> {
+  if (isPythonFile(filePath) && usesPythonFallback(client)) {
+    return flattenDocumentSymbols(await sharedPythonSymbols(workspaceDir).documentSymbols(filePath));
+  }
+  if (isProtoFile(filePath)) {
+    return flattenDocumentSymbols(await sharedProtoSymbols(workspaceDir).documentSymbols(filePath));
+  }
+  if (!client) {
+    throw new ToolError('lsp-unavailable', 'LSP client not initialized');
+  }
+  await client.openFile(filePath);
+  const symbols = await documentSymbols(client, {
+    textDocument: { uri: pathToUri(filePath) } as TextDocumentIdentifier,
+  });
+  return flattenDocumentSymbols(symbols);
+}
+
+/**
+ * Workspace symbols matching a query, from the language server, the .proto
+ * parser, and, when the server does not serve Python, the built-in Python parser
+ */
+export async function findWorkspaceSymbols(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  query: string
+): Promise<(SymbolInformation | WorkspaceSymbol)[]> {
+  const results: (SymbolInformation | WorkspaceSymbol)[] = client ? (await symbol(client, { query })).results() : [];
+  if (usesPythonFallback(client)) {
+    results.push(...await sharedPythonSymbols(workspaceDir).workspaceSymbols(query));
+  }
+  results.push(...await sharedProtoSymbols(workspaceDir).workspaceSymbols(query));
+  return results;
+}
@@ -0,0 +1,85 @@
+/**
+ * Build targets tool - list Makefile, Taskfile, and justfile targets and search their recipes
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { BuildTarget, findBuildTargets } from '../workspace/targets.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Recipe lines shown per target when listing
+ */
+const LISTED_RECIPE_LINES = 5;
+
+/**
+ * Options for listing build targets
+ */
+export interface BuildTargetsOptions {
+  path?: string;
+  // Only this target, with its whole recipe
+  target?: string;
+  // Only recipe lines matching this regular expression (case-insensitive)
+  pattern?: string;
+}
+
+/**
+ * Format one target: its rule line, then recipe lines
+ */
+function formatTarget(target: BuildTarget, recipe: BuildTarget['recipe'], limit: number): string {
+  const deps = target.dependencies.length > 0 ? ` (depends on ${target.dependencies.join(', ')})` : '';
+  const description = target.description ? ` - ${target.description}` : '';
+  let output = `L${target.line}: ${target.name}${deps}${description}\n`;
+  for (const line of recipe.slice(0, limit)) {
+    output += `  L${line.line}: ${line.text}\n`;
+  }
+  if (recipe.length > limit) {
+    output += `  … ${recipe.length - limit} more line(s); pass target: "${target.name}" for the whole recipe\n`;
+  }
+  return output;
+}
+
+/**
+ * List the workspace's build targets, or search the recipes of one target
+ */
+export async function listBuildTargets(workspaceDir: string, options: BuildTargetsOptions = {}): Promise<string> {
+  toolsLogger.debug('Listing build targets (target: %s, pattern: %s)', options.target, options.pattern);
+  const matcher = options.pattern !== undefined ? new RegExp(options.pattern, 'i') : undefined;
+  const all = await findBuildTargets(workspaceDir, options.path);
+  if (all.length === 0) {
+    return 'No Makefile, Taskfile, or justfile targets found';
+  }
+
+  const targets = options.target ? all.filter((target) => target.name === options.target) : all;
+  if (targets.length === 0) {
+    const wanted = options.target!.toLowerCase();
+    const similar = Array.from(new Set(all.map((target) => target.name).filter((name) => name.toLowerCase().includes(wanted))));
+    return `No target named ${options.target}` +
+      (similar.length > 0 ? `; similar: ${similar.slice(0, 10).join(', ')}` : `; ${all.length} target(s) exist`);
+  }
+
+  const sections = new Map<string, string>();
+  let shown = 0;
+  for (const target of targets) {
+    // A search matching the name or description shows the whole recipe,
+    // otherwise only the matching recipe lines
+    const named = matcher && (matcher.test(target.name) || matcher.test(target.description ?? ''));
+    const recipe = matcher && !named ? target.recipe.filter((line) => matcher.test(line.text)) : target.recipe;
+    if (matcher && !named && recipe.length === 0) {
+      continue;
+    }
+    const limit = options.target || matcher ? Infinity : LISTED_RECIPE_LINES;
+    const key = `${target.filePath} (${target.tool})`;
+    sections.set(key, (sections.get(key) ?? '') + formatTarget(target, recipe, limit));
+    shown++;
+  }
+  if (shown === 0) {
+    return `No recipe lines match ${options.pattern}${options.target ? ` in ${options.target}` : ''}`;
+  }
+
+  let output = `Found ${shown} target(s) in ${sections.size} file(s)\n\n`;
+  for (const [file, text] of sections) {
+    output += `---\n\n${file}\n\n${text}\n`;
+  }
+  return output.trimEnd();
+}
@@ -0,0 +1,60 @@
+/**
+ * Tests for test pairing
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { formatTestPairing, pairTests, subjectStem } from './testpairs';
+
+describe('test pairing', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'testpairs-')));
+    const write = (relativePath: string, content: string) => {
+      fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, relativePath), content);
+    };
+    write('src/store/cache.ts', 'export class Cache {}\nexport function evict() {}\n');
+    write('src/store/keys.ts', 'export function cacheKey() {}\n');
+    write('test/store/cache.spec.ts', "import { Cache, evict } from '../../src/store/cache';\nnew Cache(); evict();\n");
+    write('test/integration/flows.ts', 'export const unrelated = 1;\n');
+    write('test/e2e/checkout.test.ts', "import { cacheKey } from '../../src/store/keys';\ncacheKey();\n");
+    write('store/store.go', 'package store\n\nfunc Lookup() {}\n\nfunc Remember() {}\n');
+    write('store/lookup_test.go', 'package store\n\nfunc TestLookup(t *testing.T) {\n\tLookup()\n}\n');
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should drop test affixes from file names', () => {
+    expect(subjectStem('store/cache_test.go')).toBe('cache');
+    expect(subjectStem('tests/test_cache.py')).toBe('cache');
+    expect(subjectStem('src/test/java/CacheTests.java')).toBe('cache');
+    expect(subjectStem('src/cache.spec.tsx')).toBe('cache');
+    expect(subjectStem('src/Contest.java')).toBe('contest');
+  });
+
+  it('should find the tests of a file by name, imports, and mentions', async () => {
+    const pairing = await pairTests(workspace, 'src/store/cache.ts');
+    expect(pairing.isTest).toBe(false);
+    expect(pairing.pairs).toEqual([{
+      relativePath: path.join('test', 'store', 'cache.spec.ts'),
+      score: 7,
+      reasons: ['same name, mirrored directory', 'test imports it', 'test mentions 2 of its 2 name(s)'],
+    }]);
+    expect(formatTestPairing(pairing)).toContain('Tests of src/store/cache.ts, best match first:\n  test/store/cache.spec.ts (score 7');
+  });
+
+  it('should find the files a test tests where no name matches', async () => {
+    const pairing = await pairTests(workspace, 'test/e2e/checkout.test.ts');
+    expect(pairing.isTest).toBe(true);
+    expect(pairing.pairs.map((pair) => pair.relativePath)).toEqual([path.join('src', 'store', 'keys.ts')]);
+
+    const goPairing = await pairTests(workspace, path.join(workspace, 'store/lookup_test.go'));
+    expect(goPairing.pairs).toEqual([{ relativePath: path.join('store', 'store.go'), score: 1, reasons: ['test mentions 1 of its 2 name(s)'] }]);
+    expect(formatTestPairing(await pairTests(workspace, 'store/store.go'))).toContain('store/lookup_test.go (score 1');
+  });
+});
@@ -0,0 +1,226 @@
+/**
+ * Test pairing tool - the test files of an implementation file, and the other way round
+ * Pairs come from three signals: naming conventions (foo.go and foo_test.go,
+ * foo.ts and __tests__/foo.spec.ts, Foo.java and FooTest.java under
+ * src/test), the test's imports, and the test mentioning the names the file
+ * declares. The last two find pairs in layouts no convention covers
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { detectLanguageId, isSourceFile, isTestFile } from '../workspace/language.js';
+import { readFileText } from '../workspace/overlay.js';
+import { resolveWorkspacePath, walkWorkspaceFiles } from '../workspace/walker.js';
+import { baseName } from './api.js';
+import { builtinDeclarations, parsedLanguage } from './codeindex.js';
+import { importTargets } from './warmup.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for test pairing
+ */
+export interface TestPairOptions {
+  // Most pairs listed (default: 10)
+  limit?: number;
+}
+
+/**
+ * A file paired with the one asked about
+ */
+export interface TestPair {
+  relativePath: string;
+  score: number;
+  reasons: string[];
+}
+
+/**
+ * The pairs of one file, best first
+ */
+export interface TestPairing {
+  relativePath: string;
+  // Whether the file is a test, so the pairs are the files it tests
+  isTest: boolean;
+  pairs: TestPair[];
+}
+
+/**
+ * Directories that hold tests, or source roots, left out when comparing layouts
+ * src/main/java/x and src/test/java/x, or pkg/ and tests/pkg/, mirror each other
+ */
+const LAYOUT_DIRS = new Set(['test', 'tests', '__tests__', 'spec', 'specs', 'testing', 'unit', 'integration', 'src', 'main', 'lib']);
+
+/**
+ * Names shorter than this are too common to pair files by
+ */
+const MIN_NAME_LENGTH = 3;
+
+/**
+ * Languages whose files may test each other
+ */
+function languageFamily(relativePath: string): string {
+  const languageId = detectLanguageId(relativePath);
+  return /^(type|java)script(react)?$/.test(languageId) ? 'javascript' : languageId;
+}
+
+/**
+ * File name without extension and test affixes, for comparing names
+ * foo_test.go, test_foo.py, foo.spec.ts, and FooTests.java all give foo
+ */
+export function subjectStem(relativePath: string): string {
+  let stem = path.basename(relativePath).replace(/\.[^.]+$/, '');
+  if (isTestFile(relativePath)) {
+    stem = stem.replace(/[._-](test|spec)$/i, '').replace(/^test_/, '').replace(/(?<=.)(Tests?|Spec)$/, '');
+  }
+  return stem.toLowerCase().replace(/[-_]/g, '');
+}
+
+/**
+ * Directory of a file without test and source root directories
+ */
+function subjectDir(relativePath: string): string {
+  return path.dirname(relativePath).split(path.sep).filter((segment) => !LAYOUT_DIRS.has(segment)).join('/');
+}
+
+/**
+ * Names a file declares, for finding the tests that mention them
+ */
+function declaredNames(filePath: string, content: string): string[] {
+  if (!parsedLanguage(filePath)) {
+    return [];
+  }
+  const names = builtinDeclarations(filePath, content).map((declaration) => baseName(declaration.qualifiedName));
+  return Array.from(new Set(names.filter((name) => name.length >= MIN_NAME_LENGTH)));
+}
+
+/**
+ * Names of a list a text mentions as whole words
+ */
+function mentionedNames(content: string, names: string[]): number {
+  return names.filter((name) => new RegExp(`(?<![\\w$])${name.replace(/[$]/g, '\\$')}(?![\\w$])`).test(content)).length;
+}
+
+/**
+ * How well a test and an implementation file pair
+ */
+function scorePair(
+  test: string,
+  implementation: string,
+  imported: Set<string>,
+  mentions: { count: number; of: number }
+): Omit<TestPair, 'relativePath'> {
+  const reasons: string[] = [];
+  let score = 0;
+  if (subjectStem(test) === subjectStem(implementation)) {
+    if (path.dirname(test) === path.dirname(implementation)) {
+      reasons.push('same name, same directory');
+      score += 3;
+    } else if (subjectDir(test) === subjectDir(implementation)) {
+      reasons.push('same name, mirrored directory');
+      score += 3;
+    } else {
+      reasons.push('same name');
+      score += 1;
+    }
+  }
+  if (imported.has(implementation)) {
+    reasons.push(implementation.endsWith('.go') ? 'test imports its package' : 'test imports it');
+    score += 2;
+  }
+  if (mentions.count > 0) {
+    reasons.push(`test mentions ${mentions.count} of its ${mentions.of} name(s)`);
+    score += mentions.count >= 3 || mentions.count === mentions.of ? 2 : 1;
+  }
+  return { score, reasons };
+}
+
+/**
+ * Pair a file with its tests, or a test file with the files it tests
+ */
+export async function pairTests(workspaceDir: string, targetPath: string, options: TestPairOptions = {}): Promise<TestPairing> {
+  const absolutePath = resolveWorkspacePath(workspaceDir, targetPath);
+  const relativePath = path.relative(workspaceDir, absolutePath);
+  const targetContent = await readFileText(absolutePath);
+  const isTest = isTestFile(relativePath);
+  const family = languageFamily(relativePath);
+
+  const files = (await walkWorkspaceFiles(workspaceDir))
+    .filter((file) => isSourceFile(file.relativePath) && languageFamily(file.relativePath) === family);
+  const tests = isTest ? [relativePath] : files.map((file) => file.relativePath).filter(isTestFile);
+  const implementations = isTest
+    ? files.map((file) => file.relativePath).filter((file) => !isTestFile(file))
+    : [relativePath];
+
+  const contents = new Map<string, string>([[relativePath, targetContent]]);
+  for (const test of tests) {
+    if (!contents.has(test)) {
+      contents.set(test, await readFileText(path.join(workspaceDir, test)).catch(() => ''));
+    }
+  }
+  // Only the tests' imports are resolved; the other files are there to resolve them to
+  const imports = importTargets(files.map((file) => ({
+    relativePath: file.relativePath,
+    content: isTestFile(file.relativePath) ? contents.get(file.relativePath) ?? '' : '',
+  })));
+  const importedBy = (test: string): Set<string> => {
+    const imported = new Set<string>();
+    for (const target of imports.get(test) ?? []) {
+      imported.add(target);
+      // Go imports name a package; every file of it is imported
+      if (target.endsWith('.go')) {
+        implementations.filter((file) => path.dirname(file) === path.dirname(target)).forEach((file) => imported.add(file));
+      }
+    }
+    return imported;
+  };
+
+  // Declared names are only read for the implementation files a cheaper signal points to, or the one asked about
+  const names = new Map<string, string[]>();
+  const namesOf = async (implementation: string): Promise<string[]> => {
+    if (!names.has(implementation)) {
+      const content = contents.get(implementation) ??
+        await readFileText(path.join(workspaceDir, implementation)).catch(() => '');
+      names.set(implementation, declaredNames(implementation, content));
+    }
+    return names.get(implementation)!;
+  };
+
+  const pairs: TestPair[] = [];
+  for (const test of tests) {
+    const imported = importedBy(test);
+    for (const implementation of implementations) {
+      const related = !isTest || imported.has(implementation) || subjectStem(test) === subjectStem(implementation) ||
+        path.dirname(test) === path.dirname(implementation) || subjectDir(test) === subjectDir(implementation);
+      let mentions = { count: 0, of: 0 };
+      if (related) {
+        const declared = await namesOf(implementation);
+        mentions = { count: mentionedNames(contents.get(test) ?? '', declared), of: declared.length };
+      }
+      const pair = scorePair(test, implementation, imported, mentions);
+      if (pair.score > 0) {
+        pairs.push({ ...pair, relativePath: isTest ? implementation : test });
+      }
+    }
+  }
+  toolsLogger.debug('Paired %s with %d file(s)', relativePath, pairs.length);
+
+  pairs.sort((a, b) => b.score - a.score || a.relativePath.localeCompare(b.relativePath));
+  return { relativePath, isTest, pairs: pairs.slice(0, options.limit ?? 10) };
+}
+
+/**
+ * Format the pairs of a file, best first, with why each was paired
+ */
+export function formatTestPairing(pairing: TestPairing): string {
+  const target = pairing.relativePath.split(path.sep).join('/');
+  if (pairing.pairs.length === 0) {
+    return pairing.isTest
+      ? `No implementation files found for ${target}`
+      : `No tests found for ${target}`;
+  }
+  const lines = [pairing.isTest ? `Files tested by ${target}, best match first:` : `Tests of ${target}, best match first:`];
+  for (const pair of pairing.pairs) {
+    lines.push(`  ${pair.relativePath.split(path.sep).join('/')} (score ${pair.score}: ${pair.reasons.join('; ')})`);
+  }
+  return lines.join('\n');
+}
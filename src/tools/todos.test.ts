@@ -0,0 +1,77 @@
+/**
+ * Tests for the TODO tool
+ */
+
+import { parseTodoComment, todoParser } from './todos';
+import { parseBlamePorcelain } from '../git/git';
+
+describe('TODO tool', () => {
+  describe('parseTodoComment', () => {
+    it('should parse line comments', () => {
+      expect(parseTodoComment('  // TODO: add validation')).toEqual({
+        tag: 'TODO',
+        owner: undefined,
+        text: 'add validation',
+      });
+      expect(parseTodoComment('# FIXME handle None')).toEqual({
+        tag: 'FIXME',
+        owner: undefined,
+        text: 'handle None',
+      });
+    });
+
+    it('should parse owners in parentheses', () => {
+      const result = parseTodoComment('// HACK(alice): temporary workaround');
+      expect(result?.tag).toBe('HACK');
+      expect(result?.owner).toBe('alice');
+      expect(result?.text).toBe('temporary workaround');
+    });
+
+    it('should strip block comment terminators', () => {
+      const result = parseTodoComment('/* XXX remove before release */');
+      expect(result?.tag).toBe('XXX');
+      expect(result?.text).toBe('remove before release');
+    });
+
+    it('should ignore tags outside comments', () => {
+      expect(parseTodoComment('const TODO = 1;')).toBeNull();
+      expect(parseTodoComment('// TODOS are great')).toBeNull();
+    });
+
+    it('should respect custom tags', () => {
+      expect(parseTodoComment('// NOTE: keep in sync', ['NOTE'])?.tag).toBe('NOTE');
+      expect(parseTodoComment('// TODO: not requested', ['NOTE'])).toBeNull();
+    });
+
+    it('should match tags literally with one parser for many lines', () => {
+      const parse = todoParser(['FIX.ME', 'NOTE']);
+      expect(parse('// FIX.ME: port this')?.text).toBe('port this');
+      expect(parse('# NOTE keep')?.tag).toBe('NOTE');
+      expect(parse('// FIXXME: not a tag')).toBeNull();
+      expect(todoParser([])('// TODO: none')).toBeNull();
+    });
+  });
+
+  describe('parseBlamePorcelain', () => {
+    it('should map lines to commit info', () => {
+      const sha = 'a'.repeat(40);
+      const output = [
+        `${sha} 1 1 2`,
+        'author Alice',
+        'author-mail <alice@example.com>',
+        'author-time 1700000000',
+        'summary Initial commit',
+        '\tline one',
+        `${sha} 2 2`,
+        '\tline two',
+      ].join('\n');
+
+      const blame = parseBlamePorcelain(output);
+
+      expect(blame.size).toBe(2);
+      expect(blame.get(1)?.author).toBe('Alice');
+      expect(blame.get(2)?.authorMail).toBe('alice@example.com');
+      expect(blame.get(2)?.authorTime).toBe(1700000000);
+    });
+  });
+});
@@ -0,0 +1,202 @@
+/**
+ * TODO tool - aggregate TODO/FIXME/HACK/XXX comments across the workspace
+ */
+
+import { LSPClient } from '../lsp/client.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { SymbolKindNames } from '../protocol/types.js';
+import { escapeRegExp } from '../search/lexical.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+import { readFileText } from '../workspace/overlay.js';
+import { blameFile, BlameInfo, isGitRepository } from '../git/git.js';
+import { getFileSymbols, findEnclosingSymbol, FlatSymbol } from './symbols.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Default tags to collect
+ */
+export const DEFAULT_TODO_TAGS = ['TODO', 'FIXME', 'HACK', 'XXX'];
+
+/**
+ * Options for the TODO tool
+ */
+export interface TodoOptions {
+  tags?: string[];
+  path?: string;
+  includeBlame?: boolean;
+  includeSymbols?: boolean;
+  maxResults?: number;
+}
+
+/**
+ * A single TODO comment
+ */
+export interface TodoComment {
+  tag: string;
+  owner?: string;
+  text: string;
+  line: number; // 1-indexed
+}
+
+/**
+ * Parser of TODO-style comments with the given tags, built once for many lines
+ * Recognizes forms like `// TODO: text`, `# FIXME(alice) text`, `/* HACK ... *\/`
+ */
+export function todoParser(tags: string[] = DEFAULT_TODO_TAGS): (line: string) => Omit<TodoComment, 'line'> | null {
+  if (tags.length === 0) {
+    return () => null;
+  }
+
+  const regex = new RegExp(
+    `(?:\\/\\/+|\\/\\*+|#+|--|;+|<!--|^\\s*\\*)\\s*(${tags.map(escapeRegExp).join('|')})\\b(?:\\(([^)]*)\\))?:?\\s*(.*)$`
+  );
+  return (line) => {
+    const match = line.match(regex);
+    if (!match) {
+      return null;
+    }
+
+    const text = match[3].replace(/\s*(\*\/|-->)\s*$/, '').trim();
+    return {
+      tag: match[1],
+      owner: match[2]?.trim() || undefined,
+      text,
+    };
+  };
+}
+
+/**
+ * Parse a TODO-style comment from a line of source
+ */
+export function parseTodoComment(line: string, tags: string[] = DEFAULT_TODO_TAGS): Omit<TodoComment, 'line'> | null {
+  return todoParser(tags)(line);
+}
+
+/**
+ * Format an age in days
+ */
+function formatAge(authorTime: number): string {
+  const days = Math.floor((Date.now() / 1000 - authorTime) / 86400);
+  const date = new Date(authorTime * 1000).toISOString().substring(0, 10);
+  return `${date}, ${days} day(s) ago`;
+}
+
+/**
+ * Collect TODO comments from the workspace
+ */
+export async function findTodos(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  options: TodoOptions = {}
+): Promise<string> {
+  const tags = options.tags && options.tags.length > 0 ? options.tags : DEFAULT_TODO_TAGS;
+  const maxResults = options.maxResults ?? 200;
+  const includeSymbols = options.includeSymbols ?? true;
+  const includeBlame = options.includeBlame ?? false;
+
+  const files = await walkWorkspaceFiles(workspaceDir, { pathPrefix: options.path });
+  toolsLogger.debug('Scanning %d files for tags: %s', files.length, tags.join(','));
+
+  const parseTodo = todoParser(tags);
+  const useBlame = includeBlame && (await isGitRepository(workspaceDir));
+  if (includeBlame && !useBlame) {
+    toolsLogger.debug('Workspace is not a git repository, skipping blame');
+  }
+
+  const tagCounts = new Map<string, number>();
+  const sections: string[] = [];
+  let total = 0;
+  let shown = 0;
+
+  for (const file of files) {
+    let content: string;
+    try {
+      content = await readFileText(file.absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Error reading file %s: %s', file.absolutePath, err);
+      continue;
+    }
+
+    const todos: TodoComment[] = [];
+    const lines = content.split('\n');
+    for (let i = 0; i < lines.length; i++) {
+      const parsed = parseTodo(lines[i]);
+      if (parsed) {
+        todos.push({ ...parsed, line: i + 1 });
+      }
+    }
+
+    if (todos.length === 0) {
+      continue;
+    }
+
+    for (const todo of todos) {
+      tagCounts.set(todo.tag, (tagCounts.get(todo.tag) || 0) + 1);
+    }
+    total += todos.length;
+
+    if (shown >= maxResults) {
+      continue;
+    }
+
+    // Enclosing symbols (best effort - the language server may not handle this file)
+    let symbols: FlatSymbol[] = [];
+    if (includeSymbols) {
+      try {
+        symbols = await getFileSymbols(client, workspaceDir, file.absolutePath);
+      } catch (err) {
+        toolsLogger.debug('Could not get symbols for %s: %s', file.absolutePath, err);
+      }
+    }
+
+    // Blame (best effort - untracked files have no history)
+    let blame: Map<number, BlameInfo> | undefined;
+    if (useBlame) {
+      try {
+        blame = await blameFile(file.absolutePath);
+      } catch (err) {
+        toolsLogger.debug('Could not blame %s: %s', file.absolutePath, err);
+      }
+    }
+
+    let section = `---\n\n${file.relativePath}\n`;
+    for (const todo of todos) {
+      if (shown >= maxResults) {
+        break;
+      }
+      shown++;
+
+      const owner = todo.owner ? `(${todo.owner})` : '';
+      section += `  L${todo.line} [${todo.tag}${owner}] ${todo.text}\n`;
+
+      const enclosing = findEnclosingSymbol(symbols, todo.line - 1);
+      if (enclosing) {
+        section += `    Symbol: ${enclosing.qualifiedName} (${SymbolKindNames[enclosing.kind] || 'Unknown'})\n`;
+      }
+
+      const info = blame?.get(todo.line);
+      if (info && info.authorTime > 0) {
+        const mail = info.authorMail ? ` <${info.authorMail}>` : '';
+        section += `    Author: ${info.author}${mail} (${formatAge(info.authorTime)})\n`;
+      }
+    }
+    sections.push(section);
+  }
+
+  if (total === 0) {
+    return `No ${tags.join('/')} comments found`;
+  }
+
+  const summary = Array.from(tagCounts.entries())
+    .sort((a, b) => b[1] - a[1])
+    .map(([tag, count]) => `${tag}: ${count}`)
+    .join(', ');
+
+  let output = `Found ${total} comment(s) (${summary})`;
+  if (shown < total) {
+    output += `, showing first ${shown}`;
+  }
+  output += '\n\n' + sections.join('\n');
+  return output;
+}
@@ -0,0 +1,44 @@
+/**
+ * Tests for the tree tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { directoryTree } from './tree';
+
+describe('tree', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'tree-'));
+    for (const file of ['README.md', 'src/index.ts', 'src/cli/repl.ts', 'src/cli/search.ts', 'node_modules/x/index.js', 'build/out.js']) {
+      fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+      fs.writeFileSync(path.join(workspace, file), 'x\n');
+    }
+    fs.writeFileSync(path.join(workspace, '.gitignore'), 'build/\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should list directories before files, with counts, honoring ignore rules', async () => {
+    expect(await directoryTree(workspace)).toBe([
+      './ (4 files)',
+      '├── src/ (3 files)',
+      '│   ├── cli/ (2 files)',
+      '│   │   ├── repl.ts',
+      '│   │   └── search.ts',
+      '│   └── index.ts',
+      '└── README.md',
+    ].join('\n'));
+  });
+
+  it('should stop at the depth and the entry limit', async () => {
+    expect(await directoryTree(workspace, { path: 'src', depth: 1 })).toBe('src/ (3 files)\n├── cli/ (2 files)\n└── index.ts');
+    const limited = await directoryTree(workspace, { maxEntries: 2 });
+    expect(limited).toContain('│   └── … 2 more');
+    expect(limited).toContain('Stopped at 2 entries');
+  });
+});
@@ -0,0 +1,106 @@
+/**
+ * Tree tool - directory structure of the workspace with ignore rules applied
+ * Directories show how many files they hold, so a shallow tree still says
+ * where the code is
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from '../workspace/walker.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the tree tool
+ */
+export interface TreeOptions {
+  path?: string;
+  // Directory levels shown below the root (default: 3)
+  depth?: number;
+  // Most entries (files and directories) listed (default: 200)
+  maxEntries?: number;
+}
+
+/**
+ * A directory and the files under it
+ */
+interface TreeNode {
+  dirs: Map<string, TreeNode>;
+  files: string[];
+  fileCount: number; // Files anywhere below
+}
+
+/**
+ * Build the directory tree of relative file paths
+ */
+function buildTree(relativePaths: string[]): TreeNode {
+  const root: TreeNode = { dirs: new Map(), files: [], fileCount: 0 };
+  for (const relativePath of relativePaths) {
+    const parts = relativePath.split(path.sep);
+    let node = root;
+    node.fileCount++;
+    for (const part of parts.slice(0, -1)) {
+      let child = node.dirs.get(part);
+      if (!child) {
+        child = { dirs: new Map(), files: [], fileCount: 0 };
+        node.dirs.set(part, child);
+      }
+      child.fileCount++;
+      node = child;
+    }
+    node.files.push(parts[parts.length - 1]);
+  }
+  return root;
+}
+
+/**
+ * Directory structure under a path, to a depth, with file counts per directory
+ */
+export async function directoryTree(workspaceDir: string, options: TreeOptions = {}): Promise<string> {
+  const depth = options.depth ?? 3;
+  const maxEntries = options.maxEntries ?? 200;
+  const start = options.path ? resolveWorkspacePath(workspaceDir, options.path) : workspaceDir;
+  toolsLogger.debug('Listing tree of %s (depth: %d)', start, depth);
+
+  // Large files belong in the tree even though searches skip them
+  const files = await walkWorkspaceFiles(workspaceDir, { pathPrefix: start, config: { maxFileSize: Number.MAX_SAFE_INTEGER } });
+  const rootName = path.relative(workspaceDir, start) || '.';
+  if (files.length === 0) {
+    return `No files under ${rootName}`;
+  }
+  const root = buildTree(files.map((file) => path.relative(start, file.absolutePath)));
+
+  const count = (n: number) => `${n} file${n === 1 ? '' : 's'}`;
+  const lines = [`${rootName}/ (${count(root.fileCount)})`];
+  let listed = 0;
+  let truncated = false;
+  const visit = (node: TreeNode, prefix: string, level: number): void => {
+    const dirs = [...node.dirs.keys()].sort();
+    const children = [...dirs.map((name) => ({ name, dir: node.dirs.get(name) })), ...node.files.sort().map((name) => ({ name, dir: undefined }))];
+    for (let i = 0; i < children.length; i++) {
+      if (listed >= maxEntries) {
+        lines.push(`${prefix}└── … ${children.length - i} more`);
+        truncated = true;
+        return;
+      }
+      listed++;
+      const { name, dir } = children[i];
+      const last = i === children.length - 1;
+      const branch = last ? '└── ' : '├── ';
+      if (!dir) {
+        lines.push(`${prefix}${branch}${name}`);
+        continue;
+      }
+      lines.push(`${prefix}${branch}${name}/ (${count(dir.fileCount)})`);
+      if (level < depth) {
+        visit(dir, prefix + (last ? '    ' : '│   '), level + 1);
+      }
+    }
+  };
+  visit(root, '', 1);
+
+  if (truncated) {
+    lines.push('', `Stopped at ${maxEntries} entries; pass a path or a smaller depth to see the rest`);
+  }
+  return lines.join('\n');
+}
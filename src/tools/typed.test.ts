@@ -0,0 +1,59 @@
+/**
+ * Tests for the typed value search
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { restoreGoanalysisPath, stubGoanalysis } from './goanalysis.testutil';
+import { TypedValue, findTypedValues, formatTypedValues } from './typed';
+
+describe('typed', () => {
+  const values: TypedValue[] = [
+    { file: 'api/server.go', line: 12, column: 20, kind: 'param', name: 'ctx', func: 'Server.Handle', type: 'context.Context' },
+    { file: 'api/server.go', line: 30, column: 2, kind: 'field', name: 'base', type: 'context.Context' },
+    { file: 'main.go', line: 8, column: 9, kind: 'expr', name: 'context.Background()', func: 'main', type: 'context.Context' },
+  ];
+
+  it('should group values by file', () => {
+    expect(formatTypedValues('context.Context', 2, values, 100)).toBe([
+      'Found 3 value(s) of type context.Context in 2 package(s)',
+      '',
+      'api/server.go',
+      '  L12:C20  param ctx  in Server.Handle',
+      '  L30:C2  field base',
+      '',
+      'main.go',
+      '  L8:C9  expr context.Background()  in main',
+    ].join('\n'));
+    expect(formatTypedValues('context.Context', 2, values, 1)).toContain('(showing first 1)');
+    expect(formatTypedValues('*sql.DB', 4, [], 100)).toBe('No values of type *sql.DB in 4 package(s)');
+  });
+
+  describe('findTypedValues', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'typed-'));
+      fs.mkdirSync(path.join(workspace, 'api'));
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+      restoreGoanalysisPath();
+    });
+
+    it('should pass the scope and type to goanalysis', async () => {
+      const args = stubGoanalysis(workspace, { packages: 1, values: [values[0]] });
+
+      const result = await findTypedValues(workspace, 'context.Context', { path: 'api', expressions: true });
+      expect(result).toContain('Found 1 value(s) of type context.Context in 1 package(s)');
+      expect(args()).toBe(`typed -root ${workspace} -path ${path.join(workspace, 'api')} -expressions context.Context`);
+    });
+
+    it('should explain a missing goanalysis command', async () => {
+      process.env.GOANALYSIS_PATH = path.join(workspace, 'missing');
+      await expect(findTypedValues(workspace, 'error')).rejects.toThrow('not found; build it from goanalysis/');
+    });
+  });
+});
@@ -0,0 +1,74 @@
+/**
+ * Typed value search - Go variables, parameters, fields, and expressions of a given type
+ * Runs `goanalysis typed` (goanalysis/ in this repository), which matches
+ * types from go/types rather than names, so it works whichever language
+ * server is attached
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { resolveWorkspacePath } from '../workspace/walker.js';
+import { runGoAnalysis } from './goanalysis.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * A declaration or expression of the searched type, as goanalysis reports it
+ */
+export interface TypedValue {
+  file: string; // Relative to the workspace
+  line: number;
+  column: number; // 1-indexed, in bytes
+  kind: 'receiver' | 'param' | 'result' | 'var' | 'field' | 'expr';
+  name: string;
+  func?: string;
+  type: string;
+}
+
+/**
+ * Options for the typed value search
+ */
+export interface TypedSearchOptions {
+  // Directory to search (default: the workspace)
+  path?: string;
+  // Also list calls, selectors, and literals of the type, not only declarations
+  expressions?: boolean;
+  // Most values listed (default: 100)
+  limit?: number;
+}
+
+/**
+ * Format typed values grouped by file
+ */
+export function formatTypedValues(typeName: string, packages: number, values: TypedValue[], limit: number): string {
+  if (values.length === 0) {
+    return `No values of type ${typeName} in ${packages} package(s)`;
+  }
+  const shown = values.slice(0, limit);
+  const lines = [`Found ${values.length} value(s) of type ${typeName} in ${packages} package(s)` +
+    (shown.length < values.length ? ` (showing first ${shown.length})` : '')];
+  let file: string | undefined;
+  for (const value of shown) {
+    if (value.file !== file) {
+      file = value.file;
+      lines.push('', file);
+    }
+    lines.push(`  L${value.line}:C${value.column}  ${value.kind} ${value.name}${value.func ? `  in ${value.func}` : ''}`);
+  }
+  return lines.join('\n');
+}
+
+/**
+ * Find the Go values of a type, e.g. "context.Context" or "*sql.DB"
+ * Types are written qualified by package name or import path; an unqualified
+ * name matches a named type of any package
+ */
+export async function findTypedValues(workspaceDir: string, typeName: string, options: TypedSearchOptions = {}): Promise<string> {
+  const dir = options.path ? resolveWorkspacePath(workspaceDir, options.path) : workspaceDir;
+  const args = ['typed', '-root', workspaceDir, '-path', dir];
+  if (options.expressions) {
+    args.push('-expressions');
+  }
+  toolsLogger.debug('Finding values of type %s under %s', typeName, dir);
+  const output = JSON.parse(await runGoAnalysis(workspaceDir, [...args, typeName])) as { packages: number; values: TypedValue[] };
+  return formatTypedValues(typeName, output.packages, output.values, options.limit ?? 100);
+}
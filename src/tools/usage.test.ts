@@ -0,0 +1,74 @@
+/**
+ * Tests for the symbol usage tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { formatSymbolUsage, symbolUsage } from './usage';
+
+describe('symbol_usage', () => {
+  let workspace: string;
+
+  const write = (relativePath: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+    fs.writeFileSync(path.join(workspace, relativePath), content);
+  };
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'usage-')));
+    write('store/store.py', [
+      'class Store:',
+      '    def fetch(self, key):',
+      '        return self.items[key]',
+      '',
+      'def open_store():',
+      '    return Store()',
+      '',
+      'def _compact(store):',
+      '    pass',
+    ].join('\n'));
+    write('app/main.py', 'from store.store import open_store\n\ns = open_store()\nprint(s.fetch("a"), s.fetch("b"))\n');
+    write('app/cli.py', 'from store.store import open_store\n');
+    write('tests/test_store.py', 'from store.store import open_store\n\ndef test_open():\n    assert open_store()\n');
+    write('README.md', 'Call open_store() to get a Store.\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should count references outside the declarations, most referenced first', async () => {
+    const usages = await symbolUsage(undefined, workspace, 'store');
+    expect(usages.map((usage) => [usage.name, usage.references, usage.testReferences, usage.files, usage.exported])).toEqual([
+      ['open_store', 5, 2, 3, true],
+      ['Store.fetch', 2, 0, 1, true],
+      ['Store', 1, 0, 1, true],
+      ['_compact', 0, 0, 0, false],
+    ]);
+    expect((await symbolUsage(undefined, workspace, 'store', { exportedOnly: true })).map((usage) => usage.name))
+      .not.toContain('_compact');
+  });
+
+  it('should format the report', async () => {
+    const report = formatSymbolUsage('store', await symbolUsage(undefined, workspace, 'store/store.py'), 2);
+    expect(report).toBe([
+      'Symbol usage in store: 4 symbol(s) in 1 file(s), most referenced first',
+      '  5 ref(s) in 3 file(s), 2 in tests - [Function] open_store  store/store.py:5',
+      '  2 ref(s) in 1 file(s) - [Method] Store.fetch  store/store.py:2',
+      '  … 2 more',
+      '',
+      '1 symbol(s) are not mentioned outside their declaration',
+      '',
+      'Counts are whole-word name matches in source files, so symbols sharing a name are counted together',
+    ].join('\n'));
+    expect(formatSymbolUsage('empty', [])).toBe('No symbols found in empty');
+  });
+
+  it('should refuse a limit that is not a positive whole number', async () => {
+    for (const limit of [0, -1, 2.5, NaN]) {
+      const err = await symbolUsage(undefined, workspace, 'store', { limit }).catch((e) => e);
+      expect(err.code).toBe('invalid-argument');
+    }
+  });
+});
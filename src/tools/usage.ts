@@ -0,0 +1,220 @@
+/**
+ * Symbol usage tool - each symbol a package or file defines, with how often
+ * the rest of the workspace mentions it, most used first
+ * Separates the hot public API from barely-used helpers before a refactor;
+ * counts are whole-word name matches in source files, found in one pass over
+ * the workspace for every name, so no language server has to resolve every
+ * reference
+ */
+
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { SymbolKind, SymbolKindNames } from '../protocol/types.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { resolveWorkspacePath, walkWorkspaceFiles } from '../workspace/walker.js';
+import { detectLanguageId, isSourceFile, isTestFile } from '../workspace/language.js';
+import { ToolError } from '../workspace/errors.js';
+import { readFileText } from '../workspace/overlay.js';
+import { MEMBER_KINDS, TOP_LEVEL_KINDS, baseName, declarationText, isExportedSymbol, packageSourceFiles } from './api.js';
+import { FlatSymbol, getFileSymbols } from './symbols.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * A defined symbol and the references to it
+ */
+export interface SymbolUsage {
+  name: string; // Qualified, e.g. Store.get
+  kind: SymbolKind;
+  relativePath: string;
+  line: number; // 1-indexed
+  exported: boolean;
+  // Mentions in source files, leaving out the declarations themselves
+  references: number;
+  // Of those, the mentions in test files
+  testReferences: number;
+  files: number;
+}
+
+/**
+ * Options for the symbol usage tool
+ */
+export interface SymbolUsageOptions {
+  // Include files in subdirectories of a package directory
+  recursive?: boolean;
+  // Only report exported symbols
+  exportedOnly?: boolean;
+  // Most symbols listed (default: 100)
+  limit?: number;
+}
+
+/**
+ * Symbols counted per call
+ */
+const MAX_SYMBOLS = 500;
+
+/**
+ * Top-level declarations and the members of classes and types, as api_surface lists them
+ */
+function isCounted(sym: FlatSymbol): boolean {
+  return sym.depth === 0 ? TOP_LEVEL_KINDS.has(sym.kind) : sym.depth === 1 && MEMBER_KINDS.has(sym.kind);
+}
+
+/**
+ * Mentions of each name per source file, by name and then relative path
+ * Every source file is read once and its words looked up among the names,
+ * rather than searching the workspace once per name
+ */
+async function countMentions(
+  workspaceDir: string,
+  names: Set<string>,
+  index?: TrigramIndex
+): Promise<Map<string, Map<string, number>>> {
+  let relativePaths: string[];
+  if (index) {
+    await index.refresh();
+    relativePaths = index.indexedFiles();
+  } else {
+    relativePaths = (await walkWorkspaceFiles(workspaceDir)).map((file) => file.relativePath);
+  }
+  const counts = new Map<string, Map<string, number>>(Array.from(names, (name) => [name, new Map<string, number>()]));
+  for (const relativePath of relativePaths) {
+    const filePath = path.join(workspaceDir, relativePath);
+    if (!isSourceFile(filePath)) {
+      continue;
+    }
+    let content: string;
+    try {
+      content = await readFileText(filePath);
+    } catch (err) {
+      toolsLogger.debug('Could not read %s: %s', filePath, err);
+      continue;
+    }
+    for (const word of content.match(/[A-Za-z_$][\w$]*/g) ?? []) {
+      const perFile = counts.get(word);
+      if (perFile) {
+        perFile.set(relativePath, (perFile.get(relativePath) ?? 0) + 1);
+      }
+    }
+  }
+  return counts;
+}
+
+/**
+ * Count the workspace-wide references to each symbol a package or file defines
+ */
+export async function symbolUsage(
+  client: LSPClient | undefined,
+  workspaceDir: string,
+  targetPath: string,
+  options: SymbolUsageOptions = {},
+  index?: TrigramIndex
+): Promise<SymbolUsage[]> {
+  if (options.limit !== undefined && (!Number.isInteger(options.limit) || options.limit < 1)) {
+    throw new ToolError('invalid-argument', `limit must be a positive whole number of symbols, got ${options.limit}`);
+  }
+  const absolutePath = resolveWorkspacePath(workspaceDir, targetPath);
+  const files = (await packageSourceFiles(workspaceDir, absolutePath, options.recursive))
+    .filter((file) => !isTestFile(file.relativePath));
+
+  const defined: Array<{ usage: SymbolUsage; base: string }> = [];
+  for (const file of files) {
+    let symbols: FlatSymbol[];
+    let content: string;
+    try {
+      symbols = await getFileSymbols(client, workspaceDir, file.absolutePath);
+      content = await readFileText(file.absolutePath);
+    } catch (err) {
+      toolsLogger.debug('Could not get symbols for %s: %s', file.absolutePath, err);
+      continue;
+    }
+    const lines = content.split('\n');
+    const languageId = detectLanguageId(file.absolutePath);
+    for (const sym of symbols.filter(isCounted)) {
+      const exported = isExportedSymbol(languageId, sym.name, declarationText(lines, sym), sym.depth > 0);
+      if (options.exportedOnly && !exported) {
+        continue;
+      }
+      defined.push({
+        base: baseName(sym.name),
+        usage: {
+          name: sym.qualifiedName,
+          kind: sym.kind,
+          relativePath: file.relativePath,
+          line: sym.selectionRange.start.line + 1,
+          exported,
+          references: 0,
+          testReferences: 0,
+          files: 0,
+        },
+      });
+    }
+  }
+  if (defined.length > MAX_SYMBOLS) {
+    toolsLogger.warn('Counting the references of the first %d of %d symbols in %s', MAX_SYMBOLS, defined.length, targetPath);
+    defined.length = MAX_SYMBOLS;
+  }
+
+  // Each declaration mentions its own name once; symbols sharing a name share its count
+  const declarations = new Map<string, Map<string, number>>();
+  for (const { base, usage } of defined) {
+    const perFile = declarations.get(base) ?? new Map<string, number>();
+    perFile.set(usage.relativePath, (perFile.get(usage.relativePath) ?? 0) + 1);
+    declarations.set(base, perFile);
+  }
+  const mentionsByName = await countMentions(workspaceDir, new Set(declarations.keys()), index);
+  for (const [base, declared] of declarations) {
+    const mentions = mentionsByName.get(base)!;
+    let references = 0;
+    let testReferences = 0;
+    let referencingFiles = 0;
+    for (const [relativePath, count] of mentions) {
+      const remaining = Math.max(0, count - (declared.get(relativePath) ?? 0));
+      if (remaining > 0) {
+        references += remaining;
+        referencingFiles++;
+        if (isTestFile(relativePath)) {
+          testReferences += remaining;
+        }
+      }
+    }
+    for (const entry of defined.filter((item) => item.base === base)) {
+      entry.usage.references = references;
+      entry.usage.testReferences = testReferences;
+      entry.usage.files = referencingFiles;
+    }
+  }
+
+  return defined.map((item) => item.usage).sort((a, b) =>
+    b.references - a.references || a.relativePath.localeCompare(b.relativePath) || a.line - b.line);
+}
+
+/**
+ * Format the symbols by reference count, the unreferenced ones summed up at the end
+ */
+export function formatSymbolUsage(targetPath: string, usages: SymbolUsage[], limit = 100): string {
+  if (usages.length === 0) {
+    return `No symbols found in ${targetPath}`;
+  }
+  const files = new Set(usages.map((usage) => usage.relativePath));
+  const lines = [`Symbol usage in ${targetPath}: ${usages.length} symbol(s) in ${files.size} file(s), most referenced first`];
+  const width = String(usages[0].references).length;
+  for (const usage of usages.slice(0, limit)) {
+    const kind = SymbolKindNames[usage.kind] || 'Unknown';
+    const where = usage.references > 0
+      ? ` in ${usage.files} file(s)${usage.testReferences > 0 ? `, ${usage.testReferences} in tests` : ''}`
+      : '';
+    lines.push(`  ${String(usage.references).padStart(width)} ref(s)${where} - [${kind}] ${usage.name}` +
+      `${usage.exported ? '' : ' (unexported)'}  ${usage.relativePath.split(path.sep).join('/')}:${usage.line}`);
+  }
+  if (usages.length > limit) {
+    lines.push(`  … ${usages.length - limit} more`);
+  }
+  const unreferenced = usages.filter((usage) => usage.references === 0).length;
+  if (unreferenced > 0) {
+    lines.push('', `${unreferenced} symbol(s) are not mentioned outside their declaration`);
+  }
+  lines.push('', 'Counts are whole-word name matches in source files, so symbols sharing a name are counted together');
+  return lines.join('\n');
+}
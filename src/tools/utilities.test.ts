@@ -7,6 +7,7 @@ import {
   getLineRangesToDisplay,
   convertLinesToRanges,
   formatLinesWithRanges,
+  getDocComment,
   LineRange,
 } from './utilities';
 import { Location, Range } from '../protocol/types';
@@ -128,5 +129,25 @@ describe('Tool utilities', () => {
       expect(result).toContain('     2| line 1');
     });
   });
-});
 
+  describe('getDocComment', () => {
+    it('should collect line comments above a declaration', () => {
+      const lines = ['// NewUserService creates', '// a new service', 'func NewUserService() {}'];
+      expect(getDocComment(lines, 2)).toBe('NewUserService creates\na new service');
+    });
+
+    it('should collect JSDoc blocks and skip decorators', () => {
+      const lines = ['/**', ' * Greets a user', ' */', '@log', 'greet() {}'];
+      expect(getDocComment(lines, 4)).toBe('Greets a user');
+    });
+
+    it('should read Python docstrings below a declaration', () => {
+      const lines = ['def add_user(self, user):', '    """Add a user to the service"""', '    pass'];
+      expect(getDocComment(lines, 0)).toBe('Add a user to the service');
+    });
+
+    it('should return empty string without docs', () => {
+      expect(getDocComment(['x = 1', 'def f():', '    return x'], 1)).toBe('');
+    });
+  });
+});
@@ -2,8 +2,8 @@
  * Utility functions for tools
  */
 
-import * as fs from 'fs';
 import { Location } from '../protocol/types.js';
+import { readFileText } from '../workspace/overlay.js';
 
 /**
  * Add line numbers to text
@@ -113,6 +113,76 @@ export function formatLinesWithRanges(lines: string[], ranges: LineRange[]): str
   return output.join('\n');
 }
 
+/**
+ * Check if a trimmed line looks like a comment
+ */
+function isCommentLine(trimmed: string): boolean {
+  return (
+    trimmed.startsWith('//') ||
+    trimmed.startsWith('/*') ||
+    trimmed.startsWith('*') ||
+    trimmed.startsWith('#')
+  );
+}
+
+/**
+ * Get the doc comment for a declaration starting at a 0-indexed line
+ * Looks for comment lines directly above the declaration, then for a
+ * Python-style docstring directly below it
+ */
+export function getDocComment(lines: string[], declLine: number): string {
+  // Skip decorators/annotations between the comment and the declaration
+  let i = declLine - 1;
+  while (i >= 0 && lines[i].trim().startsWith('@')) {
+    i--;
+  }
+
+  const commentLines: string[] = [];
+  while (i >= 0 && isCommentLine(lines[i].trim()) && !lines[i].trim().startsWith('#!')) {
+    commentLines.unshift(lines[i].trim());
+    i--;
+  }
+
+  if (commentLines.length > 0) {
+    return commentLines
+      .map((line) => line.replace(/^(\/\/+|\/\*\*?|\*\/|\*|#+)\s?/, '').replace(/\s*\*\/$/, ''))
+      .filter((line, idx, arr) => line !== '' || (idx > 0 && idx < arr.length - 1))
+      .join('\n')
+      .trim();
+  }
+
+  // Python docstring on the line(s) after the declaration
+  for (let j = declLine + 1; j < Math.min(lines.length, declLine + 3); j++) {
+    const trimmed = lines[j].trim();
+    const quote = trimmed.startsWith('"""') ? '"""' : trimmed.startsWith("'''") ? "'''" : null;
+    if (!quote) {
+      if (trimmed !== '') {
+        break;
+      }
+      continue;
+    }
+
+    const rest = trimmed.substring(3);
+    const endIdx = rest.indexOf(quote);
+    if (endIdx >= 0) {
+      return rest.substring(0, endIdx).trim();
+    }
+
+    const docLines = [rest];
+    for (let k = j + 1; k < lines.length; k++) {
+      const idx = lines[k].indexOf(quote);
+      if (idx >= 0) {
+        docLines.push(lines[k].substring(0, idx));
+        break;
+      }
+      docLines.push(lines[k]);
+    }
+    return docLines.map((line) => line.trim()).join('\n').trim();
+  }
+
+  return '';
+}
+
 /**
  * Get full definition by expanding the range
  */
@@ -120,7 +190,7 @@ export async function getFullDefinition(
   filePath: string,
   location: Location
 ): Promise<[string, Location]> {
-  const content = await fs.promises.readFile(filePath, 'utf8');
+  const content = await readFileText(filePath);
   const lines = content.split('\n');
 
   let startLine = location.range.start.line;
@@ -0,0 +1,64 @@
+/**
+ * Tests for the vocabulary tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { clearVocabulary, countVocabulary, formatVocabulary, workspaceVocabulary } from './vocabulary';
+import { TrigramIndex } from '../search/trigram';
+import { PathOutsideWorkspaceError } from '../workspace/paths';
+
+describe('vocabulary', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'vocabulary-')));
+    fs.mkdirSync(path.join(workspace, 'api'));
+    fs.writeFileSync(path.join(workspace, 'api', 'users.ts'),
+      "// userName is not counted in comments\nconst userId = req.params['user_id'];\nlookup(userId, userID, 3px);\n");
+    fs.writeFileSync(path.join(workspace, 'store.py'), '# userId\ndef load(user_id):\n    return db.get("user_id", user_id)\n');
+    fs.writeFileSync(path.join(workspace, 'README.md'), 'userId userId userId\n');
+  });
+
+  afterAll(() => {
+    clearVocabulary();
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should count identifiers and string literals outside comments', () => {
+    const counts = countVocabulary('const a = "x"; // b\n/* c */ a(`y`, \'x\', 12px);\n', 'typescript');
+    expect(Array.from(counts.identifiers)).toEqual([['const', 1], ['a', 2]]);
+    expect(Array.from(counts.strings)).toEqual([['x', 2], ['y', 1]]);
+    expect(Array.from(countVocabulary('# a\nb = "c"\n', 'python').identifiers)).toEqual([['b', 1]]);
+  });
+
+  it('should list the values starting with a prefix, most frequent first', async () => {
+    const index = new TrigramIndex(workspace);
+    const vocabulary = await workspaceVocabulary(workspace, 'user', {}, index);
+    expect(vocabulary.entries).toEqual([
+      { value: 'userId', count: 2, files: 1 },
+      { value: 'user_id', count: 2, files: 1 },
+      { value: 'userID', count: 1, files: 1 },
+    ]);
+    const text = formatVocabulary(vocabulary);
+    expect(text).toContain('Identifiers starting with "user" (any case): 3 distinct in 2 file(s) scanned');
+    expect(text).toContain('Spellings of the same name:\n  userId (2), user_id (2), userID (1)');
+
+    const strings = await workspaceVocabulary(workspace, 'USER', { kind: 'strings', path: 'api' });
+    expect(strings.entries).toEqual([{ value: 'user_id', count: 1, files: 1 }]);
+    expect(formatVocabulary(strings)).toContain('1  "user_id"  (1 file(s))');
+    expect((await workspaceVocabulary(workspace, 'USER', { caseSensitive: true }, index)).entries).toEqual([]);
+  });
+
+  it('should refuse a limit that is not a positive whole number', async () => {
+    const vocabulary = await workspaceVocabulary(workspace, 'user');
+    for (const limit of [0, -1, 1.5, NaN]) {
+      expect(() => formatVocabulary(vocabulary, limit)).toThrow('limit must be a positive whole number');
+    }
+  });
+
+  it('should refuse a path outside the workspace', async () => {
+    await expect(workspaceVocabulary(workspace, 'user', { path: '../elsewhere' })).rejects.toThrow(PathOutsideWorkspaceError);
+  });
+});
@@ -0,0 +1,244 @@
+/**
+ * Vocabulary tool - the most frequent identifiers or string literals starting with a prefix
+ * Shows the naming conventions of a codebase and the canonical spelling of a
+ * concept (userId or userID, "user_id" or "user-id") before searching for
+ * it. Files are narrowed to those holding the prefix through the trigram
+ * index, and each file's counts are cached until it changes
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { detectLanguageId, isSourceFile } from '../workspace/language.js';
+import { readFileText, sharedOverlay } from '../workspace/overlay.js';
+import { isUnder } from '../workspace/paths.js';
+import { currentSnapshot } from '../workspace/treesnapshot.js';
+import { resolveWorkspacePath, walkWorkspaceFiles } from '../workspace/walker.js';
+import { HASH_COMMENT_LANGUAGES } from './duplicates.js';
+import { ToolError } from '../workspace/errors.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * What the vocabulary counts
+ */
+export type VocabularyKind = 'identifiers' | 'strings';
+
+/**
+ * Options for the vocabulary tool
+ */
+export interface VocabularyOptions {
+  // Count identifiers or string literals (default: identifiers)
+  kind?: VocabularyKind;
+  // Only count files under this directory or file
+  path?: string;
+  // Match the prefix with case (default: false, so userId and UserID both count for "user")
+  caseSensitive?: boolean;
+}
+
+/**
+ * A value and how often it occurs
+ */
+export interface VocabularyEntry {
+  value: string;
+  count: number;
+  files: number;
+}
+
+/**
+ * Values starting with a prefix, most frequent first
+ */
+export interface Vocabulary {
+  prefix: string;
+  kind: VocabularyKind;
+  caseSensitive: boolean;
+  entries: VocabularyEntry[];
+  filesScanned: number;
+}
+
+/**
+ * Counts of one file
+ */
+interface FileCounts {
+  identifiers: Map<string, number>;
+  strings: Map<string, number>;
+}
+
+/**
+ * Files whose counts are kept between calls
+ */
+const MAX_CACHED_FILES = 5000;
+
+/**
+ * Longer string literals are left out; they are prose or data, not names
+ */
+const MAX_STRING_LENGTH = 100;
+
+/**
+ * Counts by absolute path, with the modification time and size they were taken at
+ */
+const counted = new Map<string, { stamp: string; counts: FileCounts }>();
+
+/**
+ * Count the identifiers and single-line string literals of a file, leaving out comments and numbers
+ */
+export function countVocabulary(content: string, languageId: string): FileCounts {
+  const regex = HASH_COMMENT_LANGUAGES.has(languageId)
+    ? /#[^\n]*|"""[\s\S]*?"""|'''[\s\S]*?'''|"((?:\\.|[^"\\\n])*)"|'((?:\\.|[^'\\\n])*)'|\d[\w.]*|([A-Za-z_$][\w$]*)/g
+    : /\/\/[^\n]*|\/\*[\s\S]*?\*\/|"((?:\\.|[^"\\\n])*)"|'((?:\\.|[^'\\\n])*)'|`((?:\\.|[^`\\\n$])*)`|\d[\w.]*|([A-Za-z_$][\w$]*)/g;
+  const counts: FileCounts = { identifiers: new Map(), strings: new Map() };
+  const add = (map: Map<string, number>, value: string) => map.set(value, (map.get(value) ?? 0) + 1);
+  let match: RegExpExecArray | null;
+  while ((match = regex.exec(content)) !== null) {
+    const identifier = match[match.length - 1];
+    if (identifier !== undefined) {
+      add(counts.identifiers, identifier);
+      continue;
+    }
+    const literal = match.slice(1, -1).find((group) => group !== undefined);
+    if (literal && literal.length <= MAX_STRING_LENGTH) {
+      add(counts.strings, literal);
+    }
+  }
+  return counts;
+}
+
+/**
+ * Counts of a file, from the cache while it is unchanged
+ * Reads pinned to a snapshot are not cached; the file on disk may differ
+ */
+async function fileCounts(filePath: string): Promise<FileCounts | undefined> {
+  const overlay = sharedOverlay().entry(filePath);
+  let stamp: string | undefined;
+  if (!currentSnapshot()) {
+    try {
+      const stat = overlay ? undefined : await fs.promises.stat(filePath);
+      stamp = overlay ? `overlay:${overlay.updatedAt}` : `${stat!.mtimeMs}:${stat!.size}`;
+    } catch {
+      return undefined;
+    }
+    const cached = counted.get(filePath);
+    if (cached?.stamp === stamp) {
+      return cached.counts;
+    }
+  }
+  let content: string;
+  try {
+    content = await readFileText(filePath);
+  } catch (err) {
+    toolsLogger.debug('Could not read %s: %s', filePath, err);
+    return undefined;
+  }
+  const counts = countVocabulary(content, detectLanguageId(filePath));
+  if (stamp) {
+    counted.delete(filePath);
+    counted.set(filePath, { stamp, counts });
+    while (counted.size > MAX_CACHED_FILES) {
+      counted.delete(counted.keys().next().value!);
+    }
+  }
+  return counts;
+}
+
+/**
+ * Count the identifiers or string literals starting with a prefix across the workspace
+ * An empty prefix counts every value
+ */
+export async function workspaceVocabulary(
+  workspaceDir: string,
+  prefix: string,
+  options: VocabularyOptions = {},
+  index?: TrigramIndex
+): Promise<Vocabulary> {
+  const kind = options.kind ?? 'identifiers';
+  if (kind !== 'identifiers' && kind !== 'strings') {
+    throw new ToolError('invalid-argument', `Unknown kind "${kind}"; use identifiers or strings`);
+  }
+  const caseSensitive = options.caseSensitive ?? false;
+  const within = options.path ? resolveWorkspacePath(workspaceDir, options.path) : undefined;
+
+  // The index is case-insensitive, so its candidates hold the prefix in any case
+  let relativePaths: string[];
+  if (index) {
+    await index.refresh();
+    relativePaths = index.candidates(prefix) ?? index.indexedFiles();
+  } else {
+    relativePaths = (await walkWorkspaceFiles(workspaceDir)).map((file) => file.relativePath);
+  }
+  const files = relativePaths
+    .map((relativePath) => path.join(workspaceDir, relativePath))
+    .filter((filePath) => isSourceFile(filePath) && (!within || isUnder(filePath, within)));
+
+  const wanted = caseSensitive ? prefix : prefix.toLowerCase();
+  const matches = (value: string) => (caseSensitive ? value : value.toLowerCase()).startsWith(wanted);
+  const totals = new Map<string, VocabularyEntry>();
+  let filesScanned = 0;
+  for (const filePath of files) {
+    const counts = await fileCounts(filePath);
+    if (!counts) {
+      continue;
+    }
+    filesScanned++;
+    for (const [value, count] of counts[kind]) {
+      if (!matches(value)) {
+        continue;
+      }
+      const entry = totals.get(value) ?? { value, count: 0, files: 0 };
+      entry.count += count;
+      entry.files++;
+      totals.set(value, entry);
+    }
+  }
+
+  toolsLogger.debug('Counted %d value(s) starting with "%s" in %d file(s)', totals.size, prefix, filesScanned);
+  const entries = Array.from(totals.values()).sort((a, b) =>
+    b.count - a.count || b.files - a.files || (a.value < b.value ? -1 : a.value > b.value ? 1 : 0));
+  return { prefix, kind, caseSensitive, entries, filesScanned };
+}
+
+/**
+ * Format the values by frequency, then the spellings that differ only in case or separators
+ */
+export function formatVocabulary(vocabulary: Vocabulary, limit = 50): string {
+  if (!Number.isInteger(limit) || limit < 1) {
+    throw new ToolError('invalid-argument', `limit must be a positive whole number of values, got ${limit}`);
+  }
+  const { prefix, kind, entries } = vocabulary;
+  const what = kind === 'strings' ? 'String literals' : 'Identifiers';
+  const matching = prefix ? ` starting with "${prefix}"${vocabulary.caseSensitive ? '' : ' (any case)'}` : '';
+  if (entries.length === 0) {
+    return `No ${what.toLowerCase()}${matching} in ${vocabulary.filesScanned} file(s)`;
+  }
+  const lines = [`${what}${matching}: ${entries.length} distinct in ${vocabulary.filesScanned} file(s) scanned, most frequent first`];
+  const width = String(entries[0].count).length;
+  for (const entry of entries.slice(0, limit)) {
+    const value = kind === 'strings' ? JSON.stringify(entry.value) : entry.value;
+    lines.push(`  ${String(entry.count).padStart(width)}  ${value}  (${entry.files} file(s))`);
+  }
+  if (entries.length > limit) {
+    lines.push(`  … ${entries.length - limit} more`);
+  }
+
+  // Spellings of one name, the most frequent first, hint at which is canonical
+  const spellings = new Map<string, VocabularyEntry[]>();
+  for (const entry of entries.slice(0, limit)) {
+    const key = entry.value.toLowerCase().replace(/[-_]/g, '');
+    spellings.set(key, [...(spellings.get(key) ?? []), entry]);
+  }
+  const variants = Array.from(spellings.values()).filter((group) => group.length > 1);
+  if (variants.length > 0) {
+    lines.push('', 'Spellings of the same name:');
+    for (const group of variants) {
+      lines.push(`  ${group.map((entry) => `${kind === 'strings' ? JSON.stringify(entry.value) : entry.value} (${entry.count})`).join(', ')}`);
+    }
+  }
+  return lines.join('\n');
+}
+
+/**
+ * Forget the cached counts
+ */
+export function clearVocabulary(): void {
+  counted.clear();
+}
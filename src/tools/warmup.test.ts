@@ -0,0 +1,115 @@
+/**
+ * Tests for the warm-up tool
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { TrigramIndex } from '../search/trigram';
+import { formatWarmup, importSpecifiers, openCentralFiles, rankByFanIn, warmupSettingsFromEnv, warmupWorkspace } from './warmup';
+import { LSPClient } from '../lsp/client';
+
+describe('Warm-up', () => {
+  describe('importSpecifiers', () => {
+    it('should read imports of each language', () => {
+      expect(importSpecifiers([
+        "import { Store } from './store.js';",
+        "import './polyfill';",
+        "const fs = require('fs');",
+      ].join('\n'), 'typescript')).toEqual(['./store.js', './polyfill', 'fs']);
+      expect(importSpecifiers('from .models import User\nimport os, app.config as cfg\n', 'python'))
+        .toEqual(['.models', 'os', 'app.config']);
+      expect(importSpecifiers('import (\n\t"fmt"\n\tst "example.com/app/internal/store"\n)\n', 'go'))
+        .toEqual(['fmt', 'example.com/app/internal/store']);
+      expect(importSpecifiers('import static com.acme.Util.join;\nimport com.acme.Store;\n', 'java'))
+        .toEqual(['com.acme.Util.join', 'com.acme.Store']);
+    });
+  });
+
+  describe('rankByFanIn', () => {
+    it('should count the files importing each file, most imported first', () => {
+      expect(rankByFanIn([
+        { relativePath: 'src/store.ts', content: "import { log } from './log.js';" },
+        { relativePath: 'src/log.ts', content: '' },
+        { relativePath: 'src/api.ts', content: "import { Store } from './store';\nimport { log } from './log';" },
+        { relativePath: 'src/cli/main.ts', content: "import { log } from '../log.js';\nimport '../log';" },
+      ])).toEqual([
+        { relativePath: 'src/log.ts', importers: 3 },
+        { relativePath: 'src/store.ts', importers: 1 },
+      ]);
+    });
+
+    it('should match Go imports to the first file of the package directory', () => {
+      expect(rankByFanIn([
+        { relativePath: 'internal/store/cache.go', content: 'package store' },
+        { relativePath: 'internal/store/a_test.go', content: 'package store' },
+        { relativePath: 'store/other.go', content: 'package store' },
+        { relativePath: 'cmd/main.go', content: 'import "example.com/app/internal/store"' },
+      ])).toEqual([{ relativePath: 'internal/store/cache.go', importers: 1 }]);
+    });
+
+    it('should match Python modules by path suffix and relative imports from the package', () => {
+      expect(rankByFanIn([
+        { relativePath: 'src/app/models.py', content: '' },
+        { relativePath: 'src/app/views.py', content: 'from .models import User' },
+        { relativePath: 'tests/test_models.py', content: 'import app.models' },
+      ])).toEqual([{ relativePath: 'src/app/models.py', importers: 2 }]);
+    });
+  });
+
+  describe('warmupWorkspace', () => {
+    let workspace: string;
+
+    beforeEach(() => {
+      workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'warmup-'));
+      fs.writeFileSync(path.join(workspace, 'main.py'), 'import util\n');
+      fs.writeFileSync(path.join(workspace, 'util.py'), 'def helper():\n    pass\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    });
+
+    it('should build the index and report a missing language server', async () => {
+      const index = new TrigramIndex(workspace);
+      const report = await warmupWorkspace(workspace, index, undefined, { languageServerError: 'gopls not found' });
+      expect(index.isLoaded()).toBe(true);
+      expect(report.index.files).toBe(2);
+      const output = formatWarmup(report);
+      expect(output).toContain('Index: 2 file(s)');
+      expect(output).toContain('Language server: gopls not found');
+    });
+
+    it('should analyze the most imported files a few at a time', async () => {
+      fs.writeFileSync(path.join(workspace, 'cli.py'), 'import util\nimport main\n');
+      let running = 0;
+      let most = 0;
+      const requested: string[] = [];
+      const client = {
+        command: 'pyright',
+        openFile: async () => undefined,
+        getCacheManager: () => ({ getDocumentSymbols: () => null, setDocumentSymbols: () => undefined }),
+        call: async (_method: string, params: { textDocument: { uri: string } }) => {
+          running++;
+          most = Math.max(most, running);
+          await new Promise((resolve) => setTimeout(resolve, 10));
+          running--;
+          requested.push(path.basename(params.textDocument.uri));
+          return [];
+        },
+      } as unknown as LSPClient;
+      const opened = await openCentralFiles(workspace, client, 5, 1);
+      expect(opened).toEqual([{ relativePath: 'util.py', importers: 2 }, { relativePath: 'main.py', importers: 1 }]);
+      expect(requested).toEqual(['util.py', 'main.py']);
+      expect(most).toBe(1);
+
+      const report = await warmupWorkspace(workspace, new TrigramIndex(workspace), client, { maxFiles: 1, concurrency: 2 });
+      expect(formatWarmup(report)).toMatch(/Language server: pyright, 1 file\(s\) opened in [\d.]+s, 2 at a time, most imported first/);
+    });
+
+    it('should read the background warm-up settings', () => {
+      expect(warmupSettingsFromEnv({})).toEqual({ files: 0, concurrency: 4 });
+      expect(warmupSettingsFromEnv({ LSP_WARMUP_FILES: '50', LSP_WARMUP_CONCURRENCY: '0' })).toEqual({ files: 50, concurrency: 1 });
+    });
+  });
+});
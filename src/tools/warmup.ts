@@ -0,0 +1,325 @@
+/**
+ * Warm-up tool - pay the cold start of a large workspace up front
+ * Builds the trigram index, waits for the language server, and opens the
+ * files most of the workspace imports, so the language server has loaded the
+ * packages the first queries are likely to touch. Semantic search, when
+ * enabled, has its index brought up to date too. The same files can be
+ * analyzed in the background after each language server start, a few at a
+ * time, so queries do not wait behind a burst of package loads
+ */
+
+import * as path from 'path';
+import { LSPClient } from '../lsp/client.js';
+import { documentSymbols } from '../lsp/methods.js';
+import { createLogger, Component } from '../logging/logger.js';
+import { TrigramIndex, TrigramIndexStats } from '../search/trigram.js';
+import { SemanticSearchEngine, SemanticIndexStats } from '../semantic/engine.js';
+import { parseGoImports } from '../symbols/golang.js';
+import { readFileText } from '../workspace/overlay.js';
+import { detectLanguageId, isSourceFile } from '../workspace/language.js';
+import { createLimiter } from '../workspace/pool.js';
+import { pathToUri } from '../protocol/uri.js';
+import { walkWorkspaceFiles } from '../workspace/walker.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Options for the warm-up
+ */
+export interface WarmupOptions {
+  // Files to open in the language server, most imported first (default: 20)
+  maxFiles?: number;
+  // Files analyzed at once (default: LSP_WARMUP_CONCURRENCY, or 4)
+  concurrency?: number;
+  // Why the language server is not running, when it is not
+  languageServerError?: string;
+  semanticEngine?: SemanticSearchEngine;
+}
+
+/**
+ * A workspace file and the number of files importing it
+ */
+export interface FanIn {
+  relativePath: string;
+  importers: number;
+}
+
+/**
+ * What the warm-up did
+ */
+export interface WarmupReport {
+  index: TrigramIndexStats;
+  languageServer?: { command: string; opened: FanIn[]; concurrency: number; durationMs: number };
+  languageServerError?: string;
+  semantic?: SemanticIndexStats;
+  durationMs: number;
+}
+
+/**
+ * What the sources of a file import, as written
+ */
+export function importSpecifiers(content: string, languageId: string): string[] {
+  const specifiers: string[] = [];
+  const collect = (regex: RegExp) => {
+    let match: RegExpExecArray | null;
+    while ((match = regex.exec(content)) !== null) {
+      const specifier = match.slice(1).find((group) => group !== undefined);
+      if (specifier) {
+        specifiers.push(specifier);
+      }
+    }
+  };
+
+  switch (languageId) {
+    case 'typescript':
+    case 'typescriptreact':
+    case 'javascript':
+    case 'javascriptreact':
+      collect(/\bfrom\s*['"]([^'"\n]+)['"]|^\s*import\s*['"]([^'"\n]+)['"]|\b(?:require|import)\(\s*['"]([^'"\n]+)['"]\s*\)/gm);
+      break;
+    case 'python':
+      content.replace(/^[ \t]*(?:from[ \t]+(\.*[\w.]*)[ \t]+import|import[ \t]+([\w., \t]+))/gm, (_, from?: string, names?: string) => {
+        specifiers.push(...(from !== undefined ? [from] : names!.split(',').map((name) => name.trim().split(/\s/)[0])));
+        return '';
+      });
+      break;
+    case 'go':
+      specifiers.push(...parseGoImports(content).map((spec) => spec.path));
+      break;
+    case 'java':
+    case 'kotlin':
+    case 'scala':
+      collect(/^[ \t]*import[ \t]+(?:static[ \t]+)?([\w.]+)/gm);
+      break;
+    case 'c':
+    case 'cpp':
+      collect(/^[ \t]*#[ \t]*include[ \t]*"([^"\n]+)"/gm);
+      break;
+  }
+  return specifiers.filter(Boolean);
+}
+
+/**
+ * Source extensions tried for an import without one
+ */
+const IMPORT_EXTENSIONS: Record<string, string[]> = {
+  typescript: ['.ts', '.tsx', '.js', '.jsx', '.d.ts'],
+  javascript: ['.js', '.jsx', '.mjs', '.cjs', '.ts', '.tsx'],
+  python: ['.py', '/__init__.py'],
+  java: ['.java'],
+  kotlin: ['.kt'],
+  scala: ['.scala'],
+};
+
+/**
+ * The workspace files each file imports
+ * Imports are matched to files by path: relative imports from the importing
+ * file, the rest by path suffix (a Python module or Java class may live
+ * under src/), and Go imports by package directory, resolved to the first
+ * file of the package. Imports of files outside the workspace are ignored
+ */
+export function importTargets(files: Array<{ relativePath: string; content: string }>): Map<string, Set<string>> {
+  const paths = new Set(files.map((file) => file.relativePath));
+  // Files by their last path segment, for suffix matches
+  const byName = new Map<string, string[]>();
+  // First non-test Go file of each package directory
+  const goPackages = new Map<string, string>();
+  for (const relativePath of Array.from(paths).sort()) {
+    const name = path.posix.basename(relativePath);
+    byName.set(name, [...(byName.get(name) ?? []), relativePath]);
+    const dir = path.posix.dirname(relativePath);
+    if (relativePath.endsWith('.go') && !relativePath.endsWith('_test.go') && !goPackages.has(dir)) {
+      goPackages.set(dir, relativePath);
+    }
+  }
+  // Longest first, so internal/store wins over store
+  const goDirs = Array.from(goPackages.keys()).filter((d) => d !== '.').sort((a, b) => b.length - a.length);
+  const bySuffix = (candidate: string): string | undefined =>
+    (byName.get(path.posix.basename(candidate)) ?? []).find((p) => p === candidate || p.endsWith('/' + candidate));
+
+  const resolve = (specifier: string, importer: string, languageId: string): string | undefined => {
+    const family = languageId.replace('react', '');
+    const extensions = IMPORT_EXTENSIONS[family] ?? [];
+    const dir = path.posix.dirname(importer);
+    if (languageId === 'go') {
+      const packageDir = goDirs.find((d) => specifier === d || specifier.endsWith('/' + d));
+      return packageDir ? goPackages.get(packageDir) : undefined;
+    }
+    if (languageId === 'c' || languageId === 'cpp') {
+      const local = path.posix.normalize(path.posix.join(dir, specifier));
+      return paths.has(local) ? local : bySuffix(path.posix.normalize(specifier));
+    }
+    if (family === 'typescript' || family === 'javascript') {
+      if (!specifier.startsWith('.')) {
+        return undefined;
+      }
+      // TypeScript sources import each other by their compiled .js names
+      const base = path.posix.normalize(path.posix.join(dir, specifier)).replace(/\.[cm]?js$/, '');
+      return [base, ...extensions.map((ext) => base + ext), ...extensions.map((ext) => `${base}/index${ext}`)]
+        .find((candidate) => paths.has(candidate) && candidate !== importer);
+    }
+    if (languageId === 'python' && specifier.startsWith('.')) {
+      const dots = specifier.match(/^\.+/)![0].length;
+      let base = dir;
+      for (let i = 1; i < dots; i++) {
+        base = path.posix.dirname(base);
+      }
+      const module = specifier.substring(dots).replace(/\./g, '/');
+      const local = module ? path.posix.join(base, module) : base;
+      return extensions.map((ext) => path.posix.normalize(local + ext)).find((candidate) => paths.has(candidate));
+    }
+    // Dotted module and class names
+    const module = specifier.replace(/\.\*$/, '').replace(/\./g, '/');
+    for (const ext of extensions) {
+      const found = bySuffix(module + ext);
+      if (found) {
+        return found;
+      }
+    }
+    return undefined;
+  };
+
+  const targets = new Map<string, Set<string>>();
+  for (const file of files) {
+    const languageId = detectLanguageId(file.relativePath);
+    for (const specifier of importSpecifiers(file.content, languageId)) {
+      const target = resolve(specifier, file.relativePath, languageId);
+      if (target && target !== file.relativePath) {
+        targets.set(file.relativePath, (targets.get(file.relativePath) ?? new Set()).add(target));
+      }
+    }
+  }
+  return targets;
+}
+
+/**
+ * Rank files by how many other files import them, as importTargets resolves imports
+ * A Go package's imports are counted for its first file
+ */
+export function rankByFanIn(files: Array<{ relativePath: string; content: string }>): FanIn[] {
+  const importers = new Map<string, Set<string>>();
+  for (const [importer, targets] of importTargets(files)) {
+    for (const target of targets) {
+      importers.set(target, (importers.get(target) ?? new Set()).add(importer));
+    }
+  }
+
+  return Array.from(importers, ([relativePath, from]) => ({ relativePath, importers: from.size }))
+    .sort((a, b) => b.importers - a.importers || a.relativePath.localeCompare(b.relativePath));
+}
+
+/**
+ * Background warm-up after a language server start, from LSP_WARMUP_FILES
+ * (default: 0, none) and LSP_WARMUP_CONCURRENCY (default: 4)
+ */
+export function warmupSettingsFromEnv(env: NodeJS.ProcessEnv = process.env): { files: number; concurrency: number } {
+  const read = (name: string, fallback: number) => {
+    const value = parseInt(env[name] ?? '', 10);
+    return Number.isFinite(value) && value >= 0 ? value : fallback;
+  };
+  return { files: read('LSP_WARMUP_FILES', 0), concurrency: Math.max(1, read('LSP_WARMUP_CONCURRENCY', 4)) };
+}
+
+/**
+ * Open the workspace's most imported files in the language server, a few at a time
+ * Each file's symbols are requested once it is open, so the server has
+ * loaded its package before the next file takes the slot. Files that fail
+ * are skipped; the files opened are returned, most imported first
+ */
+export async function openCentralFiles(
+  workspaceDir: string,
+  client: LSPClient,
+  maxFiles: number,
+  concurrency: number
+): Promise<FanIn[]> {
+  const files: Array<{ relativePath: string; content: string }> = [];
+  for (const file of await walkWorkspaceFiles(workspaceDir)) {
+    if (!isSourceFile(file.absolutePath)) {
+      continue;
+    }
+    try {
+      files.push({ relativePath: file.relativePath, content: await readFileText(file.absolutePath) });
+    } catch (err) {
+      toolsLogger.debug('Error reading file %s: %s', file.absolutePath, err);
+    }
+  }
+
+  const limit = createLimiter(Math.max(1, concurrency));
+  const central = rankByFanIn(files).slice(0, maxFiles);
+  const opened = await Promise.all(central.map((file) => limit(async () => {
+    const filePath = path.join(workspaceDir, file.relativePath);
+    try {
+      await client.openFile(filePath);
+      await documentSymbols(client, { textDocument: { uri: pathToUri(filePath) } });
+      return file;
+    } catch (err) {
+      toolsLogger.debug('Warm-up could not open %s: %s', file.relativePath, (err as Error).message);
+      return undefined;
+    }
+  })));
+  return opened.filter((file): file is FanIn => file !== undefined);
+}
+
+/**
+ * Build the index, open the most imported files in the language server, and
+ * refresh the semantic index
+ */
+export async function warmupWorkspace(
+  workspaceDir: string,
+  index: TrigramIndex,
+  client: LSPClient | undefined,
+  options: WarmupOptions = {}
+): Promise<WarmupReport> {
+  const start = Date.now();
+  const report: WarmupReport = { index: await index.refresh(), durationMs: 0 };
+  toolsLogger.info('Warm-up: indexed %d file(s) in %dms', report.index.files, report.index.durationMs);
+
+  if (client) {
+    const lspStart = Date.now();
+    const concurrency = options.concurrency ?? warmupSettingsFromEnv().concurrency;
+    const opened = await openCentralFiles(workspaceDir, client, options.maxFiles ?? 20, concurrency);
+    report.languageServer = { command: client.command, opened, concurrency, durationMs: Date.now() - lspStart };
+    toolsLogger.info('Warm-up: opened %d file(s) in the language server', opened.length);
+  } else {
+    report.languageServerError = options.languageServerError ?? 'not running';
+  }
+
+  if (options.semanticEngine) {
+    report.semantic = await options.semanticEngine.refresh();
+  }
+  report.durationMs = Date.now() - start;
+  return report;
+}
+
+/**
+ * Seconds with one decimal, e.g. "2.1s"
+ */
+function seconds(ms: number): string {
+  return `${(ms / 1000).toFixed(1)}s`;
+}
+
+/**
+ * Format a warm-up report
+ */
+export function formatWarmup(report: WarmupReport): string {
+  const { index } = report;
+  let output = `Warm-up finished in ${seconds(report.durationMs)}\n\n`;
+  output += `Index: ${index.files} file(s), ${index.trigrams} trigram(s), ${index.reindexed} reindexed in ${seconds(index.durationMs)}`;
+  output += index.unindexed > 0 ? ` (${index.unindexed} left out by the memory limit)\n` : '\n';
+
+  const lsp = report.languageServer;
+  if (lsp) {
+    output += `Language server: ${lsp.command}, ${lsp.opened.length} file(s) opened in ${seconds(lsp.durationMs)}, ${lsp.concurrency} at a time`;
+    output += lsp.opened.length > 0 ? ', most imported first\n' : '\n';
+    for (const file of lsp.opened) {
+      output += `  ${file.relativePath} (imported by ${file.importers} file(s))\n`;
+    }
+  } else {
+    output += `Language server: ${report.languageServerError}\n`;
+  }
+
+  if (report.semantic) {
+    output += `Semantic index: ${report.semantic.chunks} chunk(s), ${report.semantic.embedded} embedded in ${seconds(report.semantic.durationMs)}\n`;
+  }
+  return output;
+}
@@ -0,0 +1,73 @@
+/**
+ * Tests for query watches
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { formatWatchDiff, formatWatches, QueryWatch, QueryWatches, MAX_WATCHES } from './watch';
+import { MatchDiff } from './searchdiff';
+import { ToolError } from '../workspace/errors';
+
+describe('QueryWatches', () => {
+  let workspace: string;
+  let notified: Array<{ watch: QueryWatch; diff: MatchDiff }>;
+  let watches: QueryWatches;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'watch-'));
+    fs.writeFileSync(path.join(workspace, 'a.go'), 'package a\n\nvar b, _ = ioutil.ReadAll(r)\n');
+    fs.writeFileSync(path.join(workspace, 'notes.md'), 'ioutil.ReadAll is deprecated\n');
+    notified = [];
+    watches = new QueryWatches(workspace, (watch, diff) => notified.push({ watch, diff }));
+  });
+
+  afterEach(() => {
+    watches.dispose();
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should report new and removed matches of changed files', async () => {
+    const watch = await watches.watch('ioutil.ReadAll', { glob: ['**/*.go'] });
+    expect(watch.matches.length).toBe(1);
+
+    fs.writeFileSync(path.join(workspace, 'b.go'), 'package a\n\nvar c, _ = ioutil.ReadAll(s)\n');
+    fs.writeFileSync(path.join(workspace, 'notes.md'), 'ioutil.ReadAll again\n');
+    watches.fileChanged(path.join(workspace, 'b.go'));
+    watches.fileChanged(path.join(workspace, 'notes.md'));
+    await watches.settle();
+    expect(notified.length).toBe(1);
+    expect(notified[0].diff.added.map((m) => `${m.filePath}:${m.line}`)).toEqual(['b.go:3']);
+    expect(formatWatchDiff(watch, notified[0].diff)).toBe([
+      'Watch w1 "ioutil.ReadAll": 1 new, 0 removed match(es); 2 now',
+      'New:',
+      '  b.go:3:12: var c, _ = ioutil.ReadAll(s)',
+    ].join('\n'));
+
+    fs.rmSync(path.join(workspace, 'a.go'));
+    watches.fileChanged(path.join(workspace, 'a.go'));
+    await watches.settle();
+    expect(notified[1].diff.removed.map((m) => m.filePath)).toEqual(['a.go']);
+  });
+
+  it('should not report edits that keep the matches', async () => {
+    await watches.watch('ReadAll', {});
+    fs.writeFileSync(path.join(workspace, 'a.go'), 'package a\n\n// moved\nvar b, _ = ioutil.ReadAll(r)\n');
+    watches.fileChanged(path.join(workspace, 'a.go'));
+    await watches.settle();
+    expect(notified).toEqual([]);
+    expect(formatWatches(watches.list())).toBe('Watching 1 query\n- w1 "ReadAll": 2 match(es) in 2 file(s), 0 notification(s)');
+  });
+
+  it('should unwatch by id and limit the watches', async () => {
+    await watches.watch('a', {});
+    expect(() => watches.unwatch('w9')).toThrow('No watch named w9; watching: w1');
+    expect(watches.unwatch('w1').map((watch) => watch.id)).toEqual(['w1']);
+    for (let i = 0; i < MAX_WATCHES; i++) {
+      await watches.watch(`p${i}`, {});
+    }
+    await expect(watches.watch('one-more', {})).rejects.toThrow(ToolError);
+    expect(watches.unwatch().length).toBe(MAX_WATCHES);
+    expect(formatWatches(watches.list())).toContain('No queries are watched');
+  });
+});
@@ -0,0 +1,239 @@
+/**
+ * Query watches - standing searches that report what changed in their results
+ * A watch keeps the matches of a query. When the file watcher reports changed
+ * files, only those files are searched again, and the matches that are new or
+ * gone (paired as search_diff pairs them) are sent to the client, e.g. "tell
+ * me when someone adds another use of this deprecated API"
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { buildMatcher, matchContent, searchLexical, LexicalMatch, LexicalSearchOptions } from '../search/lexical.js';
+import { TrigramIndex } from '../search/trigram.js';
+import { ToolError } from '../workspace/errors.js';
+import { readFileText } from '../workspace/overlay.js';
+import { diffMatches, queryScope, MatchDiff } from './searchdiff.js';
+
+const toolsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Watches a session may keep at once
+ */
+export const MAX_WATCHES = 20;
+
+/**
+ * Matches a watch keeps; a query with more is watched on its first matches only
+ */
+const MAX_WATCH_MATCHES = 10_000;
+
+/**
+ * Time file events are collected for before the watches are searched again
+ */
+const WATCH_DEBOUNCE_MS = 300;
+
+/**
+ * How a watched query searches
+ */
+export type WatchOptions = Pick<LexicalSearchOptions,
+  'regex' | 'caseSensitive' | 'wholeWord' | 'path' | 'glob' | 'excludeGlob' | 'languages'>;
+
+/**
+ * A watched query and its current matches
+ */
+export interface QueryWatch {
+  id: string;
+  pattern: string;
+  options: WatchOptions;
+  matches: LexicalMatch[];
+  // More matches than MAX_WATCH_MATCHES were found when the watch started
+  truncated: boolean;
+  createdAt: number;
+  // Notifications sent so far
+  notified: number;
+}
+
+/**
+ * Receives the matches that changed for a watch
+ */
+export type WatchNotifier = (watch: QueryWatch, diff: MatchDiff) => void;
+
+/**
+ * The query watches of one session
+ */
+export class QueryWatches {
+  private watches = new Map<string, QueryWatch>();
+  private nextId = 1;
+  private pending = new Set<string>();
+  private timer?: NodeJS.Timeout;
+  private flushing: Promise<void> = Promise.resolve();
+
+  constructor(private workspaceDir: string, private notify: WatchNotifier) {}
+
+  /**
+   * Start watching a query; the first search sets the matches changes are reported against
+   */
+  async watch(pattern: string, options: WatchOptions, index?: TrigramIndex): Promise<QueryWatch> {
+    if (this.watches.size >= MAX_WATCHES) {
+      throw new ToolError('invalid-argument', `At most ${MAX_WATCHES} queries can be watched; unwatch one first`);
+    }
+    // Fails on an invalid pattern before anything is kept
+    buildMatcher(pattern, options);
+    const result = await searchLexical(this.workspaceDir, pattern, { ...options, maxResults: MAX_WATCH_MATCHES }, index);
+    const watch: QueryWatch = {
+      id: `w${this.nextId++}`,
+      pattern,
+      options,
+      matches: result.matches,
+      truncated: result.truncated,
+      createdAt: Date.now(),
+      notified: 0,
+    };
+    this.watches.set(watch.id, watch);
+    toolsLogger.debug('Watching %s: "%s" (%d match(es))', watch.id, pattern, watch.matches.length);
+    return watch;
+  }
+
+  /**
+   * Stop watching one query, or every query without an id
+   */
+  unwatch(id?: string): QueryWatch[] {
+    if (id === undefined) {
+      const all = this.list();
+      this.watches.clear();
+      return all;
+    }
+    const watch = this.watches.get(id);
+    if (!watch) {
+      throw new ToolError('not-found', `No watch named ${id}` + (this.watches.size > 0 ? `; watching: ${[...this.watches.keys()].join(', ')}` : ''));
+    }
+    this.watches.delete(id);
+    return [watch];
+  }
+
+  list(): QueryWatch[] {
+    return Array.from(this.watches.values());
+  }
+
+  /**
+   * Note a changed, added, or deleted file; the watches are searched again shortly
+   */
+  fileChanged(filePath: string): void {
+    if (this.watches.size === 0) {
+      return;
+    }
+    const relativePath = path.relative(this.workspaceDir, filePath);
+    if (relativePath.startsWith('..') || path.isAbsolute(relativePath)) {
+      return;
+    }
+    this.pending.add(relativePath);
+    if (!this.timer) {
+      this.timer = setTimeout(() => {
+        this.timer = undefined;
+        this.flushing = this.flushing.then(() => this.flush());
+      }, WATCH_DEBOUNCE_MS);
+      this.timer.unref?.();
+    }
+  }
+
+  /**
+   * Wait for the changes noted so far to be searched and reported
+   */
+  async settle(): Promise<void> {
+    if (this.timer) {
+      clearTimeout(this.timer);
+      this.timer = undefined;
+      this.flushing = this.flushing.then(() => this.flush());
+    }
+    await this.flushing;
+  }
+
+  /**
+   * Stop reporting changes
+   */
+  dispose(): void {
+    if (this.timer) {
+      clearTimeout(this.timer);
+      this.timer = undefined;
+    }
+    this.watches.clear();
+    this.pending.clear();
+  }
+
+  /**
+   * Search the changed files again for every watch and report the differences
+   */
+  private async flush(): Promise<void> {
+    const files = Array.from(this.pending);
+    this.pending.clear();
+    const contents = new Map<string, string>();
+    for (const relativePath of files) {
+      try {
+        contents.set(relativePath, await readFileText(path.join(this.workspaceDir, relativePath)));
+      } catch (err) {
+        // Deleted: its matches are gone
+        contents.set(relativePath, '');
+      }
+    }
+
+    for (const watch of this.watches.values()) {
+      const inScope = queryScope(this.workspaceDir, watch.options);
+      const changed = new Set(files.filter(inScope));
+      if (changed.size === 0) {
+        continue;
+      }
+      const matcher = buildMatcher(watch.pattern, watch.options);
+      const after: LexicalMatch[] = [];
+      for (const relativePath of changed) {
+        const content = contents.get(relativePath)!;
+        if (!content.includes('\0')) {
+          after.push(...matchContent(relativePath, content, matcher, Infinity));
+        }
+      }
+      const before = watch.matches.filter((match) => changed.has(match.filePath));
+      const diff = diffMatches(before, after);
+      watch.matches = [...watch.matches.filter((match) => !changed.has(match.filePath)), ...after];
+      if (diff.added.length > 0 || diff.removed.length > 0) {
+        watch.notified++;
+        toolsLogger.debug('Watch %s: %d new, %d removed match(es)', watch.id, diff.added.length, diff.removed.length);
+        this.notify(watch, diff);
+      }
+    }
+  }
+}
+
+/**
+ * Summary of a watch, e.g. `w1 "ioutil.ReadAll": 12 match(es) in 5 file(s), 2 notification(s)`
+ */
+export function describeWatch(watch: QueryWatch): string {
+  const files = new Set(watch.matches.map((match) => match.filePath)).size;
+  const truncated = watch.truncated ? ` (watching the first ${MAX_WATCH_MATCHES} only)` : '';
+  return `${watch.id} "${watch.pattern}": ${watch.matches.length} match(es) in ${files} file(s)${truncated}, ${watch.notified} notification(s)`;
+}
+
+/**
+ * List the watches of a session
+ */
+export function formatWatches(watches: QueryWatch[]): string {
+  if (watches.length === 0) {
+    return 'No queries are watched; start one with watch_query';
+  }
+  return `Watching ${watches.length} quer${watches.length === 1 ? 'y' : 'ies'}\n` + watches.map((watch) => `- ${describeWatch(watch)}`).join('\n');
+}
+
+/**
+ * Text of a change notification: the counts, then the new and removed matches
+ */
+export function formatWatchDiff(watch: QueryWatch, diff: MatchDiff, limit = 50): string {
+  const list = (matches: LexicalMatch[]) => matches.slice(0, limit).map((match) => {
+    const text = match.lineText.trim();
+    return `  ${match.filePath}:${match.line}:${match.column}: ${text.length > 200 ? text.substring(0, 200) + '...' : text}`;
+  }).concat(matches.length > limit ? [`  ... and ${matches.length - limit} more`] : []);
+  const lines = [`Watch ${watch.id} "${watch.pattern}": ${diff.added.length} new, ${diff.removed.length} removed match(es); ${watch.matches.length} now`];
+  if (diff.added.length > 0) {
+    lines.push('New:', ...list(diff.added));
+  }
+  if (diff.removed.length > 0) {
+    lines.push('Removed:', ...list(diff.removed));
+  }
+  return lines.join('\n');
+}
@@ -11,6 +11,7 @@ import { GitignoreMatcher } from './gitignore.js';
 import { LSPClient, registerFileWatchHandler } from '../lsp/client.js';
 import { pathToUri } from '../protocol/uri.js';
 import { FileChangeType, WatchKind, DidChangeWatchedFilesParams, FileEvent } from '../protocol/types.js';
+import { matchesGlob } from '../workspace/glob.js';
 
 const watcherLogger = createLogger(Component.WATCHER);
 
@@ -22,11 +23,54 @@ export interface WatcherConfig {
   excludedDirs: Set<string>;
   excludedFileExtensions: Set<string>;
   largeBinaryExtensions: Set<string>;
+  // File name globs to skip: lock files, minified bundles, source maps
+  excludedFileNames: string[];
   maxFileSize: number;
+  // Follow symbolic links to files and directories
+  followSymlinks: boolean;
+}
+
+/**
+ * Split a comma-separated environment variable
+ */
+function envList(value: string | undefined): string[] {
+  return (value || '').split(',').map((item) => item.trim()).filter(Boolean);
+}
+
+/**
+ * Machine-generated files that dominate results for common tokens
+ */
+const DEFAULT_EXCLUDED_FILE_NAMES = [
+  'package-lock.json',
+  'npm-shrinkwrap.json',
+  'yarn.lock',
+  'pnpm-lock.yaml',
+  'bun.lockb',
+  'go.sum',
+  'Cargo.lock',
+  'poetry.lock',
+  'Pipfile.lock',
+  'composer.lock',
+  'Gemfile.lock',
+  '*.min.js',
+  '*.min.css',
+  '*.map',
+];
+
+/**
+ * Excluded file name globs: the defaults, plus WORKSPACE_EXCLUDE_FILES entries,
+ * less the defaults it negates ("!go.sum")
+ */
+function excludedFileNames(): string[] {
+  const entries = envList(process.env.WORKSPACE_EXCLUDE_FILES);
+  const negated = new Set(entries.filter((entry) => entry.startsWith('!')).map((entry) => entry.substring(1)));
+  const added = entries.filter((entry) => !entry.startsWith('!'));
+  return [...new Set([...DEFAULT_EXCLUDED_FILE_NAMES, ...added])].filter((name) => !negated.has(name));
 }
 
 /**
  * Default watcher configuration
+ * WORKSPACE_EXCLUDE_DIRS, WORKSPACE_EXCLUDE_EXTENSIONS, and WORKSPACE_EXCLUDE_FILES add to the defaults
  */
 export function defaultWatcherConfig(): WatcherConfig {
   return {
@@ -44,6 +88,7 @@ export function defaultWatcherConfig(): WatcherConfig {
       '.pytest_cache',
       '.mypy_cache',
       'vendor',
+      ...envList(process.env.WORKSPACE_EXCLUDE_DIRS),
     ]),
     excludedFileExtensions: new Set([
       '.pyc',
@@ -55,6 +100,7 @@ export function defaultWatcherConfig(): WatcherConfig {
       '.dll',
       '.so',
       '.dylib',
+      ...envList(process.env.WORKSPACE_EXCLUDE_EXTENSIONS).map((ext) => (ext.startsWith('.') ? ext : `.${ext}`)),
     ]),
     largeBinaryExtensions: new Set([
       '.png',
@@ -74,7 +120,9 @@ export function defaultWatcherConfig(): WatcherConfig {
       '.avi',
       '.mov',
     ]),
+    excludedFileNames: excludedFileNames(),
     maxFileSize: 10 * 1024 * 1024, // 10MB
+    followSymlinks: process.env.WORKSPACE_FOLLOW_SYMLINKS === 'true',
   };
 }
 
@@ -96,14 +144,35 @@ export class WorkspaceWatcher {
   private registrations: WatcherPattern[] = [];
   private watcher?: chokidar.FSWatcher;
   private debounceTimers = new Map<string, NodeJS.Timeout>();
+  private listeners: Array<(filePath: string, changeType: FileChangeType) => void> = [];
 
   constructor(
-    private client: LSPClient,
+    private client: LSPClient | undefined,
     config?: Partial<WatcherConfig>
   ) {
     this.config = { ...defaultWatcherConfig(), ...config };
   }
 
+  /**
+   * Stop passing file events to the language server, which is being stopped
+   * Listeners still hear every event
+   */
+  detach(): void {
+    this.client = undefined;
+    this.registrations = [];
+    for (const timer of this.debounceTimers.values()) {
+      clearTimeout(timer);
+    }
+    this.debounceTimers.clear();
+  }
+
+  /**
+   * Pass file events to a restarted language server, which registers its watchers anew
+   */
+  attach(client: LSPClient): void {
+    this.client = client;
+  }
+
   /**
    * Add file watcher registrations
    */
@@ -146,7 +215,7 @@ export class WorkspaceWatcher {
       ignored: (filePath: string) => this.shouldExcludePath(filePath),
       persistent: true,
       ignoreInitial: false,
-      followSymlinks: false,
+      followSymlinks: this.config.followSymlinks,
       awaitWriteFinish: {
         stabilityThreshold: 100,
         pollInterval: 50,
@@ -163,6 +232,20 @@ export class WorkspaceWatcher {
     watcherLogger.info('Started watching workspace: %s', workspacePath);
   }
 
+  /**
+   * Re-read the exclusions from the environment after a configuration change
+   * Newly excluded paths stop producing events; directories that are no longer
+   * excluded are picked up the next time the workspace is watched
+   */
+  reloadExclusions(): void {
+    const defaults = defaultWatcherConfig();
+    this.config.excludedDirs = defaults.excludedDirs;
+    this.config.excludedFileExtensions = defaults.excludedFileExtensions;
+    this.config.excludedFileNames = defaults.excludedFileNames;
+    watcherLogger.info('Reloaded exclusions: %d directory name(s), %d extension(s), %d file name(s)',
+      this.config.excludedDirs.size, this.config.excludedFileExtensions.size, this.config.excludedFileNames.length);
+  }
+
   /**
    * Stop watching
    */
@@ -195,6 +278,9 @@ export class WorkspaceWatcher {
     if (this.config.excludedFileExtensions.has(ext) || this.config.largeBinaryExtensions.has(ext)) {
       return true;
     }
+    if (matchesGlob(path.basename(filePath), this.config.excludedFileNames)) {
+      return true;
+    }
 
     // Check gitignore
     if (this.gitignore) {
@@ -223,6 +309,9 @@ export class WorkspaceWatcher {
     if (this.config.excludedFileExtensions.has(ext) || this.config.largeBinaryExtensions.has(ext)) {
       return true;
     }
+    if (matchesGlob(path.basename(filePath), this.config.excludedFileNames)) {
+      return true;
+    }
 
     // Check file size
     try {
@@ -246,11 +335,24 @@ export class WorkspaceWatcher {
     return false;
   }
 
+  /**
+   * Register a listener for every file event in the workspace, regardless of
+   * the LSP server's watch registrations
+   */
+  onFileEvent(listener: (filePath: string, changeType: FileChangeType) => void): void {
+    this.listeners.push(listener);
+  }
+
   /**
    * Handle file events
    */
   private handleFileEvent(filePath: string, changeType: FileChangeType): void {
-    if (this.shouldExcludeFile(filePath)) {
+    for (const listener of this.listeners) {
+      listener(filePath, changeType);
+    }
+
+    const client = this.client;
+    if (!client || this.shouldExcludeFile(filePath)) {
       return;
     }
 
@@ -272,13 +374,13 @@ export class WorkspaceWatcher {
 
     // Handle file creation - open the file
     if (changeType === FileChangeType.Created) {
-      this.client.openFile(filePath).catch((err) => {
+      client.openFile(filePath).catch((err) => {
         watcherLogger.debug('Error opening file %s: %s', filePath, err);
       });
     }
 
     // Handle change - notify if file is open, otherwise send didChangeWatchedFiles
-    if (changeType === FileChangeType.Changed && this.client.isFileOpen(filePath)) {
+    if (changeType === FileChangeType.Changed && client.isFileOpen(filePath)) {
       this.debounceNotifyChange(filePath);
       return;
     }
@@ -323,7 +425,7 @@ export class WorkspaceWatcher {
     // Create new timer
     const timer = setTimeout(() => {
       this.debounceTimers.delete(key);
-      this.client.notifyChange(filePath).catch((err) => {
+      this.client?.notifyChange(filePath).catch((err) => {
         watcherLogger.error('Error notifying change: %s', err);
       });
     }, this.config.debounceTime);
@@ -346,7 +448,7 @@ export class WorkspaceWatcher {
       ],
     };
 
-    this.client.didChangeWatchedFiles(params).catch((err) => {
+    this.client?.didChangeWatchedFiles(params).catch((err) => {
       watcherLogger.error('Error notifying LSP server about file event: %s', err);
     });
   }
@@ -422,6 +524,10 @@ export class WorkspaceWatcher {
    * Open files that match registered patterns
    */
   private async openMatchingFiles(): Promise<void> {
+    const client = this.client;
+    if (!client) {
+      return;
+    }
     const startTime = Date.now();
     let filesOpened = 0;
 
@@ -440,7 +546,7 @@ export class WorkspaceWatcher {
             const [watched] = this.isPathWatched(fullPath);
             if (watched) {
               try {
-                await this.client.openFile(fullPath);
+                await client.openFile(fullPath);
                 filesOpened++;
 
                 // Add delay every 100 files
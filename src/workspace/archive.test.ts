@@ -0,0 +1,164 @@
+/**
+ * Tests for archive reading and archive search
+ */
+
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { isArchive, readArchive } from './archive';
+import { MemoryBudget } from './memory';
+import { searchLexical } from '../search/lexical';
+
+/**
+ * Build a zip archive, deflating every entry
+ */
+function zip(files: Record<string, string | Buffer>): Buffer {
+  const locals: Buffer[] = [];
+  const central: Buffer[] = [];
+  let offset = 0;
+  for (const [name, content] of Object.entries(files)) {
+    const data = Buffer.from(content);
+    const compressed = zlib.deflateRawSync(data);
+    const nameBytes = Buffer.from(name);
+    const local = Buffer.alloc(30);
+    local.writeUInt32LE(0x04034b50, 0);
+    local.writeUInt16LE(8, 8);
+    local.writeUInt32LE(compressed.length, 18);
+    local.writeUInt32LE(data.length, 22);
+    local.writeUInt16LE(nameBytes.length, 26);
+    const entry = Buffer.alloc(46);
+    entry.writeUInt32LE(0x02014b50, 0);
+    entry.writeUInt16LE(8, 10);
+    entry.writeUInt32LE(compressed.length, 20);
+    entry.writeUInt32LE(data.length, 24);
+    entry.writeUInt16LE(nameBytes.length, 28);
+    entry.writeUInt32LE(offset, 42);
+    locals.push(local, nameBytes, compressed);
+    central.push(entry, nameBytes);
+    offset += local.length + nameBytes.length + compressed.length;
+  }
+  const directory = Buffer.concat(central);
+  const end = Buffer.alloc(22);
+  end.writeUInt32LE(0x06054b50, 0);
+  end.writeUInt16LE(Object.keys(files).length, 10);
+  end.writeUInt32LE(directory.length, 12);
+  end.writeUInt32LE(offset, 16);
+  return Buffer.concat([...locals, directory, end]);
+}
+
+/**
+ * Build a tar archive
+ */
+function tar(files: Record<string, string>): Buffer {
+  const blocks: Buffer[] = [];
+  for (const [name, content] of Object.entries(files)) {
+    const data = Buffer.from(content);
+    const header = Buffer.alloc(512);
+    header.write(name, 0);
+    header.write(data.length.toString(8).padStart(11, '0'), 124);
+    header.write('0', 156);
+    blocks.push(header, data, Buffer.alloc((512 - (data.length % 512)) % 512));
+  }
+  return Buffer.concat([...blocks, Buffer.alloc(1024)]);
+}
+
+describe('Archives', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'archive-'));
+    fs.mkdirSync(path.join(workspace, 'deps'));
+    fs.writeFileSync(path.join(workspace, 'deps', 'lib.jar'), zip({
+      'com/foo/': '',
+      'com/foo/Bar.java': 'package com.foo;\nclass Bar { void retryRequest() {} }\n',
+      'nested.zip': zip({ 'inner/Baz.java': 'class Baz { void retryRequest() {} }\n' }),
+    }));
+    fs.writeFileSync(path.join(workspace, 'deps', 'src.tar.gz'), zlib.gzipSync(tar({
+      './pkg/retry.go': 'package pkg\n\nfunc retryRequest() {}\n',
+    })));
+    fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nfunc main() { retryRequest() }\n');
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should recognize archives by extension', () => {
+    expect(isArchive('deps/lib.jar')).toBe(true);
+    expect(isArchive('dist/src.TAR.GZ')).toBe(true);
+    expect(isArchive('dist/bundle.tgz')).toBe(true);
+    expect(isArchive('notes.gz')).toBe(false);
+    expect(isArchive('main.go')).toBe(false);
+  });
+
+  it('should read entries, expanding nested archives up to the depth limit', () => {
+    const data = fs.readFileSync(path.join(workspace, 'deps', 'lib.jar'));
+    expect(readArchive('deps/lib.jar', data).entries.map((entry) => entry.path)).toEqual([
+      'deps/lib.jar!/com/foo/Bar.java',
+      'deps/lib.jar!/nested.zip!/inner/Baz.java',
+    ]);
+    // At depth 1 the nested archive is an entry like any other
+    expect(readArchive('deps/lib.jar', data, { maxDepth: 1 }).entries.map((entry) => entry.path))
+      .toEqual(['deps/lib.jar!/com/foo/Bar.java', 'deps/lib.jar!/nested.zip']);
+  });
+
+  it('should enforce entry and total size limits', () => {
+    const data = zip({ 'a.txt': 'a'.repeat(100), 'b.txt': 'b'.repeat(100), 'c.txt': 'c'.repeat(10) });
+    const large = readArchive('x.zip', data, { maxEntrySize: 50 });
+    expect(large.entries.map((entry) => entry.path)).toEqual(['x.zip!/c.txt']);
+    expect(large.largeEntries).toEqual([{ path: 'x.zip!/a.txt', size: 100 }, { path: 'x.zip!/b.txt', size: 100 }]);
+
+    const total = readArchive('x.zip', data, { maxTotalSize: 150 });
+    expect(total.entries.map((entry) => entry.path)).toEqual(['x.zip!/a.txt']);
+    expect(total.truncated).toBe(true);
+  });
+
+  it('should stop expanding at the memory budget', () => {
+    const data = fs.readFileSync(path.join(workspace, 'deps', 'src.tar.gz'));
+    expect(readArchive('deps/src.tar.gz', data, { memory: new MemoryBudget(1) })).toMatchObject({
+      entries: [],
+      truncated: true,
+      outOfMemory: true,
+    });
+    expect(readArchive('deps/src.tar.gz', data, { memory: new MemoryBudget(Infinity) }).outOfMemory).toBe(false);
+  });
+
+  it('should report archives it could not search in full', async () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'archive-'));
+    try {
+      fs.writeFileSync(path.join(dir, 'broken.zip'), 'not a zip');
+      // Random bytes do not compress, so the archive is as large as its entry
+      fs.writeFileSync(path.join(dir, 'big.zip'), zip({ 'a.txt': crypto.randomBytes(4096) }));
+      fs.writeFileSync(path.join(dir, 'cut.zip'), zip({ 'a.txt': 'retryRequest\n', 'b.txt': 'x'.repeat(3000) }));
+      const limited = await searchLexical(dir, 'retryRequest', { archives: true, archiveLimits: { maxTotalSize: 2048 } });
+      expect(limited.archivesSkipped).toEqual([
+        { filePath: 'big.zip', reason: '4 KB, over the 2 KB archive size limit' },
+        { filePath: 'broken.zip', reason: 'unreadable: not a zip archive' },
+        { filePath: 'cut.zip', reason: 'archive size limit of 2 KB reached, later entries not read' },
+      ]);
+      expect(limited.matches.map((match) => match.filePath)).toEqual(['cut.zip!/a.txt']);
+
+      // An archive larger than maxFileSize is still searched
+      const result = await searchLexical(dir, 'retryRequest', { archives: true, maxFileSize: 100 });
+      expect(result.largeFilesSkipped.map((file) => file.filePath)).toEqual(['big.zip!/a.txt', 'cut.zip!/b.txt']);
+      expect(result.matches.map((match) => match.filePath)).toEqual(['cut.zip!/a.txt']);
+    } finally {
+      fs.rmSync(dir, { recursive: true, force: true });
+    }
+  });
+
+  it('should search archive entries only when asked', async () => {
+    const plain = await searchLexical(workspace, 'retryRequest');
+    expect(plain.matches.map((match) => match.filePath)).toEqual(['main.go']);
+
+    const result = await searchLexical(workspace, 'retryRequest', { archives: true });
+    expect(result.matches.map((match) => `${match.filePath}:${match.line}`).sort()).toEqual([
+      'deps/lib.jar!/com/foo/Bar.java:2',
+      'deps/lib.jar!/nested.zip!/inner/Baz.java:1',
+      'deps/src.tar.gz!/pkg/retry.go:3',
+      'main.go:3',
+    ]);
+  });
+});
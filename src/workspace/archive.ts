@@ -0,0 +1,303 @@
+/**
+ * Archive reading - zip (jar, war) and tar (optionally gzipped) entries
+ * Archives are read into memory and their entries searched as virtual files
+ * named "<archive>!/<entry>". Nested archives are expanded up to a depth
+ * limit, and decompression stops at a size limit, or at the memory budget,
+ * so a small archive cannot expand into gigabytes
+ */
+
+import * as path from 'path';
+import * as zlib from 'zlib';
+import { createLogger, Component } from '../logging/logger.js';
+import { MemoryBudget } from './memory.js';
+
+const archiveLogger = createLogger(Component.TOOLS);
+
+/**
+ * Separator between an archive path and an entry path
+ */
+export const ARCHIVE_SEPARATOR = '!/';
+
+/**
+ * Extensions read as zip archives
+ */
+const ZIP_EXTENSIONS = new Set(['.zip', '.jar', '.war', '.ear', '.aar']);
+
+/**
+ * Extensions read as tar archives, gzipped or not
+ */
+const TAR_EXTENSIONS = ['.tar', '.tar.gz', '.tgz'];
+
+/**
+ * Uncompressed bytes read from one archive unless maxTotalSize says otherwise
+ */
+const DEFAULT_MAX_TOTAL_SIZE = 100 * 1024 * 1024;
+
+/**
+ * Limits on reading an archive
+ */
+export interface ArchiveLimits {
+  // Levels of nested archives expanded; 1 reads only the archive's own entries (default: 2)
+  maxDepth?: number;
+  // Entries larger than this are listed but not read (default: 10MB)
+  maxEntrySize?: number;
+  // Uncompressed bytes read from one archive, nested archives included (default: 100MB);
+  // also the largest archive file read at all
+  maxTotalSize?: number;
+  // Expanded entries count against it; reading stops once it would be exceeded
+  memory?: MemoryBudget;
+}
+
+/**
+ * A file inside an archive
+ */
+export interface ArchiveEntry {
+  // "<archive>!/<entry>", with one separator per nesting level
+  path: string;
+  data: Buffer;
+}
+
+/**
+ * Entries of an archive and what was left out
+ */
+export interface ArchiveContents {
+  entries: ArchiveEntry[];
+  // Entries over maxEntrySize, with their uncompressed size
+  largeEntries: Array<{ path: string; size: number }>;
+  // Set when maxTotalSize or the memory budget was reached, so later entries were not read
+  truncated: boolean;
+  // Set when it was the memory budget
+  outOfMemory: boolean;
+}
+
+/**
+ * Raw entry as listed by an archive, read on demand
+ */
+interface RawEntry {
+  name: string;
+  size: number;
+  read: () => Buffer;
+}
+
+/**
+ * Limits from SEARCH_ARCHIVE_MAX_DEPTH and SEARCH_ARCHIVE_MAX_SIZE_MB
+ */
+export function archiveLimitsFromEnv(env: NodeJS.ProcessEnv = process.env): ArchiveLimits {
+  return {
+    maxDepth: env.SEARCH_ARCHIVE_MAX_DEPTH ? parseInt(env.SEARCH_ARCHIVE_MAX_DEPTH, 10) : undefined,
+    maxTotalSize: env.SEARCH_ARCHIVE_MAX_SIZE_MB ? parseInt(env.SEARCH_ARCHIVE_MAX_SIZE_MB, 10) * 1024 * 1024 : undefined,
+  };
+}
+
+/**
+ * Largest archive file read under the limits; larger ones are skipped whole
+ */
+export function archiveSizeLimit(limits: ArchiveLimits = {}): number {
+  return limits.maxTotalSize ?? DEFAULT_MAX_TOTAL_SIZE;
+}
+
+/**
+ * Check if a file name is a supported archive
+ */
+export function isArchive(filePath: string): boolean {
+  const name = filePath.toLowerCase();
+  return ZIP_EXTENSIONS.has(path.extname(name)) || TAR_EXTENSIONS.some((ext) => name.endsWith(ext));
+}
+
+/**
+ * Extensions of supported archives, for lifting walker exclusions
+ */
+export function archiveExtensions(): string[] {
+  return [...ZIP_EXTENSIONS, '.tar', '.gz', '.tgz'];
+}
+
+/**
+ * List the entries of a zip archive from its central directory
+ * ZIP64 and encrypted entries are skipped
+ */
+function zipEntries(data: Buffer, maxSize: number): RawEntry[] {
+  // The end of central directory record sits within the last 64KB (its comment) plus 22 bytes
+  let eocd = -1;
+  for (let i = data.length - 22; i >= Math.max(0, data.length - 65557); i--) {
+    if (data.readUInt32LE(i) === 0x06054b50) {
+      eocd = i;
+      break;
+    }
+  }
+  if (eocd < 0) {
+    throw new Error('not a zip archive');
+  }
+  const count = data.readUInt16LE(eocd + 10);
+  let offset = data.readUInt32LE(eocd + 16);
+
+  const entries: RawEntry[] = [];
+  for (let i = 0; i < count; i++) {
+    if (offset + 46 > data.length || data.readUInt32LE(offset) !== 0x02014b50) {
+      throw new Error('corrupt zip central directory');
+    }
+    const flags = data.readUInt16LE(offset + 8);
+    const method = data.readUInt16LE(offset + 10);
+    const compressedSize = data.readUInt32LE(offset + 20);
+    const size = data.readUInt32LE(offset + 24);
+    const nameLength = data.readUInt16LE(offset + 28);
+    const extraLength = data.readUInt16LE(offset + 30);
+    const commentLength = data.readUInt16LE(offset + 32);
+    const localOffset = data.readUInt32LE(offset + 42);
+    const name = data.toString('utf8', offset + 46, offset + 46 + nameLength);
+    offset += 46 + nameLength + extraLength + commentLength;
+
+    if (name.endsWith('/')) {
+      continue;
+    }
+    if (flags & 1 || compressedSize === 0xffffffff || size === 0xffffffff || (method !== 0 && method !== 8)) {
+      archiveLogger.debug('Skipping unsupported zip entry %s (method %d, flags %d)', name, method, flags);
+      continue;
+    }
+    entries.push({
+      name,
+      size,
+      read: () => {
+        if (data.readUInt32LE(localOffset) !== 0x04034b50) {
+          throw new Error(`corrupt zip entry ${name}`);
+        }
+        const start = localOffset + 30 + data.readUInt16LE(localOffset + 26) + data.readUInt16LE(localOffset + 28);
+        const compressed = data.subarray(start, start + compressedSize);
+        // The declared size is not trusted; inflation stops at the limit
+        return method === 0 ? compressed : zlib.inflateRawSync(compressed, { maxOutputLength: Math.max(1, maxSize) });
+      },
+    });
+  }
+  return entries;
+}
+
+/**
+ * Read a NUL-terminated string field of a tar header
+ */
+function tarField(header: Buffer, start: number, length: number): string {
+  const field = header.subarray(start, start + length);
+  const end = field.indexOf(0);
+  return field.toString('utf8', 0, end < 0 ? length : end);
+}
+
+/**
+ * List the regular files of a tar archive, with GNU long names and pax paths
+ */
+function tarEntries(data: Buffer): RawEntry[] {
+  const entries: RawEntry[] = [];
+  let offset = 0;
+  let longName: string | undefined;
+  while (offset + 512 <= data.length) {
+    const header = data.subarray(offset, offset + 512);
+    if (header.every((byte) => byte === 0)) {
+      break;
+    }
+    const size = parseInt(tarField(header, 124, 12).trim() || '0', 8);
+    if (Number.isNaN(size)) {
+      throw new Error('corrupt tar header');
+    }
+    const type = String.fromCharCode(header[156]);
+    const start = offset + 512;
+    const body = data.subarray(start, start + size);
+    offset = start + Math.ceil(size / 512) * 512;
+
+    if (type === 'L') {
+      longName = tarField(body, 0, body.length);
+      continue;
+    }
+    if (type === 'x') {
+      const pax = body.toString('utf8').match(/^\d+ path=(.*)$/m);
+      longName = pax ? pax[1] : longName;
+      continue;
+    }
+    const prefix = tarField(header, 345, 155);
+    const name = longName ?? (prefix ? `${prefix}/${tarField(header, 0, 100)}` : tarField(header, 0, 100));
+    longName = undefined;
+    if (type === '0' || type === '\0' || type === '7') {
+      entries.push({ name: name.replace(/^\.\//, ''), size, read: () => body });
+    }
+  }
+  return entries;
+}
+
+/**
+ * Read the entries of an archive, expanding nested archives
+ */
+export function readArchive(archivePath: string, data: Buffer, limits: ArchiveLimits = {}): ArchiveContents {
+  const maxDepth = limits.maxDepth ?? 2;
+  const maxEntrySize = limits.maxEntrySize ?? 10 * 1024 * 1024;
+  let remaining = archiveSizeLimit(limits);
+  const contents: ArchiveContents = { entries: [], largeEntries: [], truncated: false, outOfMemory: false };
+  const headroom = () => limits.memory?.headroom() ?? Infinity;
+  const stopForMemory = () => {
+    contents.truncated = true;
+    contents.outOfMemory = true;
+  };
+
+  const expand = (name: string, buffer: Buffer, depth: number): void => {
+    const lower = name.toLowerCase();
+    let raw: RawEntry[];
+    if (ZIP_EXTENSIONS.has(path.extname(lower))) {
+      raw = zipEntries(buffer, Math.min(maxEntrySize, remaining));
+    } else {
+      const gzipped = lower.endsWith('.gz') || lower.endsWith('.tgz');
+      let tar = buffer;
+      if (gzipped) {
+        const room = headroom();
+        try {
+          tar = zlib.gunzipSync(buffer, { maxOutputLength: Math.max(1, Math.min(remaining, room)) });
+        } catch (err) {
+          if (err instanceof RangeError && room < remaining) {
+            stopForMemory();
+            return;
+          }
+          throw new Error(err instanceof RangeError ? 'expands past the archive size limit' : (err as Error).message);
+        }
+      }
+      raw = tarEntries(tar);
+    }
+
+    for (const entry of raw) {
+      const entryPath = name + ARCHIVE_SEPARATOR + entry.name;
+      if (entry.size > maxEntrySize) {
+        contents.largeEntries.push({ path: entryPath, size: entry.size });
+        continue;
+      }
+      if (entry.size > remaining) {
+        contents.truncated = true;
+        return;
+      }
+      if (entry.size > headroom()) {
+        stopForMemory();
+        return;
+      }
+      let entryData: Buffer;
+      try {
+        entryData = entry.read();
+      } catch (err) {
+        archiveLogger.debug('Could not read %s: %s', entryPath, (err as Error).message);
+        continue;
+      }
+      remaining -= entryData.length;
+      if (isArchive(entry.name) && depth < maxDepth) {
+        try {
+          expand(entryPath, entryData, depth + 1);
+        } catch (err) {
+          archiveLogger.debug('Could not read nested archive %s: %s', entryPath, (err as Error).message);
+        }
+        if (contents.truncated) {
+          return;
+        }
+      } else {
+        contents.entries.push({ path: entryPath, data: entryData });
+      }
+    }
+  };
+
+  expand(archivePath, data, 1);
+  if (contents.outOfMemory) {
+    archiveLogger.warn('Stopped reading %s at the memory budget', archivePath);
+  } else if (contents.truncated) {
+    archiveLogger.warn('Stopped reading %s after %d MB', archivePath, Math.round(archiveSizeLimit(limits) / 1048576));
+  }
+  return contents;
+}
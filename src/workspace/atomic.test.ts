@@ -0,0 +1,39 @@
+/**
+ * Tests for atomic file writes
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { writeFileAtomic } from './atomic';
+
+describe('Atomic file writes', () => {
+  let dir: string;
+
+  beforeEach(() => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'atomic-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  it('should replace a file, creating its directory, with the given mode', async () => {
+    const file = path.join(dir, 'nested', 'data.bin');
+    await writeFileAtomic(file, 'first');
+    await writeFileAtomic(file, Buffer.from('second'), 0o600);
+    expect(fs.readFileSync(file, 'utf8')).toBe('second');
+    expect(fs.readdirSync(path.dirname(file))).toEqual(['data.bin']);
+    if (process.platform !== 'win32') {
+      expect(fs.statSync(file).mode & 0o777).toBe(0o600);
+    }
+  });
+
+  it('should remove the temporary file when the write fails', async () => {
+    // A directory cannot be renamed over
+    const target = path.join(dir, 'taken');
+    fs.mkdirSync(path.join(target, 'inside'), { recursive: true });
+    await expect(writeFileAtomic(target, 'data')).rejects.toThrow();
+    expect(fs.readdirSync(dir)).toEqual(['taken']);
+  });
+});
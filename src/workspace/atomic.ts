@@ -0,0 +1,24 @@
+/**
+ * Atomic file writes
+ * Data goes to a temporary file beside the target, which is then renamed
+ * over it, so a reader or a crash never sees half a file
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+
+/**
+ * Write a file through a temporary file beside it, creating its directory
+ * The temporary file is removed when the write fails
+ */
+export async function writeFileAtomic(filePath: string, data: Buffer | string, mode?: number): Promise<void> {
+  await fs.promises.mkdir(path.dirname(filePath), { recursive: true });
+  const tempPath = `${filePath}.${process.pid}.tmp`;
+  try {
+    await fs.promises.writeFile(tempPath, data, mode === undefined ? undefined : { mode });
+    await fs.promises.rename(tempPath, filePath);
+  } catch (err) {
+    await fs.promises.rm(tempPath, { force: true });
+    throw err;
+  }
+}
@@ -0,0 +1,130 @@
+/**
+ * Tests for Bazel target parsing and source resolution
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { bazelTargetFiles, loadBazelWorkspace, parseBuildFile, parseLabel } from './bazel';
+import { ToolError } from './errors';
+
+describe('Bazel targets', () => {
+  let workspace: string;
+
+  const write = (relativePath: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(workspace, relativePath)), { recursive: true });
+    fs.writeFileSync(path.join(workspace, relativePath), content);
+  };
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'bazel-')));
+    write('services/auth/BUILD.bazel', [
+      'load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")',
+      '',
+      'go_library(',
+      '    name = "auth",',
+      '    srcs = glob(',
+      '        ["*.go"],',
+      '        exclude = ["*_test.go"],  # tests are in auth_test',
+      '    ) + select({',
+      '        "//conditions:linux": ["linux.go"],',
+      '        "//conditions:default": [],',
+      '    }),',
+      ')',
+      '',
+      'go_test(',
+      '    name = "auth_test",',
+      '    srcs = ["auth_test.go"],',
+      '    embed = [":auth"],',
+      ')',
+      '',
+      'genrule(name = "gen", outs = ["gen.go"], cmd = "...")',
+      'filegroup(name = "docs", srcs = [":gen", "//docs:auth.md"])',
+    ].join('\n'));
+    write('services/auth/auth.go', 'package auth\n');
+    write('services/auth/auth_test.go', 'package auth\n');
+    write('services/auth/linux.go', 'package auth\n');
+    write('services/auth/internal/BUILD', 'go_library(name = "internal", srcs = glob(["**/*.go"]))\n');
+    write('services/auth/internal/token.go', 'package internal\n');
+    write('services/auth/internal/jwt/jwt.go', 'package jwt\n');
+    write('services/BUILD', 'filegroup(name = "all_go", srcs = glob(["**/*.go"]))\n');
+    write('docs/BUILD', 'exports_files(["auth.md"])\n');
+    write('docs/auth.md', '# Auth\n');
+    write('tools/lint.go', 'package tools\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should read rules with their sources and lines', () => {
+    const targets = parseBuildFile(fs.readFileSync(path.join(workspace, 'services/auth/BUILD.bazel'), 'utf8'),
+      'services/auth/BUILD.bazel', 'services/auth');
+    expect(targets.map((target) => [target.label, target.kind, target.line])).toEqual([
+      ['//services/auth:auth', 'go_library', 3],
+      ['//services/auth:auth_test', 'go_test', 14],
+      ['//services/auth:gen', 'genrule', 20],
+      ['//services/auth:docs', 'filegroup', 21],
+    ]);
+    expect(targets[0].globs).toEqual([{ include: ['*.go'], exclude: ['*_test.go'] }]);
+    expect(targets[0].sources).toEqual(['linux.go']);
+    expect(targets[3].sources).toEqual([':gen', '//docs:auth.md']);
+  });
+
+  it('should parse labels and patterns', () => {
+    expect(parseLabel('//services/auth:auth')).toEqual({ package: 'services/auth', name: 'auth', recursive: false });
+    expect(parseLabel('//services/auth')).toEqual({ package: 'services/auth', name: 'auth', recursive: false });
+    expect(parseLabel('services/auth:all')).toEqual({ package: 'services/auth', name: undefined, recursive: false });
+    expect(parseLabel('//services/...')).toEqual({ package: 'services', recursive: true });
+    expect(parseLabel('//...')).toEqual({ package: '', recursive: true });
+    expect(() => parseLabel('@other//pkg:x')).toThrow('is in an external repository');
+  });
+
+  it('should resolve sources within the package and find owners', async () => {
+    const bazel = await loadBazelWorkspace(workspace);
+    const [auth] = bazel.resolve('//services/auth');
+    expect(bazel.filesOf(auth)).toEqual(['services/auth/auth.go', 'services/auth/linux.go']);
+    // A glob descends into directories, but not into another package
+    expect(bazel.filesOf(bazel.resolve('//services/auth/internal')[0]))
+      .toEqual(['services/auth/internal/jwt/jwt.go', 'services/auth/internal/token.go']);
+    expect(bazel.filesOf(bazel.resolve('//services:all_go')[0])).toEqual([]);
+    expect(bazel.filesOf(bazel.resolve('//services/auth:docs')[0])).toEqual(['docs/auth.md']);
+
+    expect(bazel.owners('services/auth/linux.go').map((target) => target.label)).toEqual(['//services/auth:auth']);
+    expect(bazel.owners('services/auth/auth_test.go').map((target) => target.label)).toEqual(['//services/auth:auth_test']);
+    expect(bazel.owners('docs/auth.md').map((target) => target.label)).toEqual(['//services/auth:docs']);
+    expect(bazel.packageOf('services/auth/internal/jwt/jwt.go')).toBe('services/auth/internal');
+    expect(bazel.packageOf('tools/lint.go')).toBeUndefined();
+
+    expect(bazel.resolve('//services/...')).toHaveLength(6);
+    expect(() => bazel.resolve('//services/auth:server')).toThrow(
+      'No Bazel target named //services/auth:server; //services/auth has //services/auth:auth, //services/auth:auth_test');
+    expect(() => bazel.resolve('//nowhere/...')).toThrow('No Bazel package named //nowhere or below it');
+    expect(() => bazel.resolve('//nowhere:x')).toThrow(ToolError);
+  });
+
+  it('should reuse a loaded workspace until a file is added or a BUILD file changes', async () => {
+    const first = await loadBazelWorkspace(workspace);
+    expect(await loadBazelWorkspace(workspace)).toBe(first);
+
+    write('services/auth/session.go', 'package auth\n');
+    const added = await loadBazelWorkspace(workspace);
+    expect(added).not.toBe(first);
+    expect(added.filesOf(added.resolve('//services/auth')[0])).toContain('services/auth/session.go');
+
+    write('tools/BUILD', 'go_binary(name = "lint", srcs = ["lint.go"])\n');
+    expect((await loadBazelWorkspace(workspace)).owners('tools/lint.go').map((target) => target.label)).toEqual(['//tools:lint']);
+  });
+
+  it('should list the files of a target for searching', async () => {
+    expect(await bazelTargetFiles(workspace, '//services/auth:all')).toEqual([
+      path.join('docs', 'auth.md'),
+      path.join('services', 'auth', 'auth.go'),
+      path.join('services', 'auth', 'auth_test.go'),
+      path.join('services', 'auth', 'linux.go'),
+    ]);
+    const err = await bazelTargetFiles(workspace, '//services/auth:gen').catch((e) => e);
+    expect(err.message).toBe('No source files named by //services/auth:gen are in the workspace');
+    expect(err.code).toBe('not-found');
+  });
+});
@@ -0,0 +1,452 @@
+/**
+ * Bazel targets - which BUILD targets own which source files
+ * Reads the rules of BUILD and BUILD.bazel files with their name, srcs, hdrs,
+ * and textual_hdrs (lists, globs with excludes, and select branches), and
+ * resolves them to workspace files the way Bazel does: a glob stays inside
+ * its package, never descending into a directory with a BUILD file of its own.
+ * Labels are relative to the workspace, which is taken to be the Bazel workspace root
+ */
+
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { globToRegExp } from './glob.js';
+import { readFileText, sharedOverlay } from './overlay.js';
+import { currentSnapshot } from './treesnapshot.js';
+import { splitArguments } from './routes.js';
+import { ToolError } from './errors.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from './walker.js';
+
+const bazelLogger = createLogger(Component.TOOLS);
+
+/**
+ * Attributes listing the files a target is built from; data is left out, as
+ * a target reads its data files rather than owning them
+ */
+const SOURCE_ATTRIBUTES = ['srcs', 'hdrs', 'textual_hdrs'];
+
+/**
+ * Top-level calls that are not targets
+ */
+const NOT_TARGETS = new Set(['load', 'package', 'licenses', 'exports_files', 'package_group', 'workspace']);
+
+/**
+ * Workspaces (and paths within them) whose loaded BUILD files are kept
+ */
+const MAX_CACHED_WORKSPACES = 8;
+
+/**
+ * A glob() of a sources attribute, with package-relative patterns
+ */
+export interface BazelGlob {
+  include: string[];
+  exclude: string[];
+}
+
+/**
+ * A rule declared in a BUILD file
+ */
+export interface BazelTarget {
+  label: string; // e.g. //services/auth:server
+  kind: string; // Rule or macro, e.g. go_library
+  package: string; // / separated, '' for the root package
+  filePath: string; // BUILD file, relative to the workspace
+  line: number; // 1-indexed
+  // Sources listed by label or file name, as written
+  sources: string[];
+  globs: BazelGlob[];
+}
+
+/**
+ * Check whether a file is a BUILD file
+ */
+export function isBuildFile(filePath: string): boolean {
+  const name = path.basename(filePath);
+  return name === 'BUILD' || name === 'BUILD.bazel';
+}
+
+/**
+ * Blank out # comments, keeping strings and line breaks
+ */
+function stripComments(content: string): string {
+  let result = '';
+  let quote: string | null = null;
+  for (let i = 0; i < content.length; i++) {
+    const c = content[i];
+    if (quote) {
+      if (c === '\\') {
+        result += c + (content[i + 1] ?? '');
+        i++;
+        continue;
+      }
+      if (c === quote) {
+        quote = null;
+      }
+    } else if (c === '"' || c === "'") {
+      quote = c;
+    } else if (c === '#') {
+      while (i < content.length && content[i] !== '\n') {
+        result += ' ';
+        i++;
+      }
+      result += content[i] ?? '';
+      continue;
+    }
+    result += c;
+  }
+  return result;
+}
+
+/**
+ * Contents of the string literals of an expression
+ */
+function stringsOf(text: string): string[] {
+  return Array.from(text.matchAll(/"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'/g), (match) => match[1] ?? match[2]);
+}
+
+/**
+ * Index just past the parenthesis closing the one before start
+ */
+function closingParen(text: string, start: number): number {
+  let depth = 0;
+  let quote: string | null = null;
+  for (let i = start; i < text.length; i++) {
+    const c = text[i];
+    if (quote) {
+      if (c === '\\') {
+        i++;
+      } else if (c === quote) {
+        quote = null;
+      }
+    } else if (c === '"' || c === "'") {
+      quote = c;
+    } else if (c === '(' || c === '[' || c === '{') {
+      depth++;
+    } else if (c === ')' || c === ']' || c === '}') {
+      if (depth === 0) {
+        return i + 1;
+      }
+      depth--;
+    }
+  }
+  return text.length;
+}
+
+/**
+ * Files and globs of a sources attribute value, e.g.
+ * `["a.go"] + glob(["*.go"], exclude = ["*_test.go"]) + select({":linux": ["l.go"]})`
+ */
+function parseSources(value: string): { sources: string[]; globs: BazelGlob[] } {
+  const globs: BazelGlob[] = [];
+  let rest = value;
+  let at: number;
+  while ((at = rest.search(/\bglob\s*\(/)) >= 0) {
+    const open = rest.indexOf('(', at) + 1;
+    const end = closingParen(rest, open);
+    const args = splitArguments(rest.substring(open, end));
+    const keyword = (key: string) => args.find((arg) => new RegExp(`^${key}\\s*=`).test(arg));
+    const include = keyword('include') ?? args.find((arg) => !/^\w+\s*=/.test(arg));
+    globs.push({ include: include ? stringsOf(include) : [], exclude: stringsOf(keyword('exclude') ?? '') });
+    rest = rest.substring(0, at) + rest.substring(end);
+  }
+  // select() keys are conditions, not sources
+  return { sources: stringsOf(rest.replace(/("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')\s*:/g, '')), globs };
+}
+
+/**
+ * Read the targets of a BUILD file
+ * Only calls starting a line are read, as rules and macros are top-level statements
+ */
+export function parseBuildFile(content: string, filePath = 'BUILD.bazel', packageName = ''): BazelTarget[] {
+  const text = stripComments(content);
+  const targets: BazelTarget[] = [];
+  for (const call of text.matchAll(/^([A-Za-z_][\w.]*)\s*\(/gm)) {
+    if (NOT_TARGETS.has(call[1])) {
+      continue;
+    }
+    const args = splitArguments(text.substring(call.index! + call[0].length));
+    const attribute = (key: string) => args.find((arg) => new RegExp(`^${key}\\s*=`).test(arg))?.replace(/^\w+\s*=\s*/, '');
+    const name = stringsOf(attribute('name') ?? '')[0];
+    if (!name) {
+      continue;
+    }
+    const sources: string[] = [];
+    const globs: BazelGlob[] = [];
+    for (const key of SOURCE_ATTRIBUTES) {
+      const value = attribute(key);
+      if (value) {
+        const parsed = parseSources(value);
+        sources.push(...parsed.sources);
+        globs.push(...parsed.globs);
+      }
+    }
+    targets.push({
+      label: `//${packageName}:${name}`,
+      kind: call[1],
+      package: packageName,
+      filePath,
+      line: text.substring(0, call.index).split('\n').length,
+      sources,
+      globs,
+    });
+  }
+  return targets;
+}
+
+/**
+ * A label split into package and name; a pattern ends in /... (every package
+ * below) or names :all or :* (every target of the package)
+ */
+export interface ParsedLabel {
+  package: string;
+  name?: string; // Undefined for every target of the package
+  recursive: boolean;
+}
+
+/**
+ * Parse a label as written outside a BUILD file: //pkg:name, //pkg (the
+ * target named after the package), //pkg:all, //pkg/...; the leading // may be left out
+ */
+export function parseLabel(label: string): ParsedLabel {
+  if (label.startsWith('@')) {
+    throw new ToolError('unsupported', `${label} is in an external repository; only labels of this workspace can be resolved`);
+  }
+  const trimmed = label.replace(/^\/\//, '');
+  const recursive = /(^|\/)\.\.\.$/.test(trimmed);
+  if (recursive) {
+    return { package: trimmed.replace(/\/?\.\.\.$/, ''), recursive };
+  }
+  const colon = trimmed.indexOf(':');
+  const packageName = colon >= 0 ? trimmed.substring(0, colon) : trimmed;
+  const name = colon >= 0 ? trimmed.substring(colon + 1) : path.posix.basename(packageName);
+  if (!name) {
+    throw new ToolError('invalid-argument', `${label} is not a label; use //package:name`);
+  }
+  return { package: packageName, name: name === 'all' || name === '*' ? undefined : name, recursive: false };
+}
+
+/**
+ * The BUILD files of a workspace with their targets, and the files they can own
+ */
+export class BazelWorkspace {
+  private byLabel = new Map<string, BazelTarget>();
+  private existing: Set<string>;
+  // Files of each package, / separated, from the nearest BUILD file above them
+  private byPackage = new Map<string, string[]>();
+  private resolved = new Map<string, string[]>();
+
+  constructor(
+    readonly targets: BazelTarget[],
+    // Workspace files, / separated
+    readonly files: string[],
+    // Packages, / separated, '' for the root
+    readonly packages: Set<string>
+  ) {
+    for (const target of targets) {
+      this.byLabel.set(target.label, target);
+    }
+    this.existing = new Set(files);
+    const packageOfDir = new Map<string, string | undefined>();
+    for (const file of files) {
+      const dir = path.posix.dirname(file);
+      if (!packageOfDir.has(dir)) {
+        packageOfDir.set(dir, this.packageOf(file));
+      }
+      const packageName = packageOfDir.get(dir);
+      if (packageName !== undefined) {
+        this.byPackage.set(packageName, [...(this.byPackage.get(packageName) ?? []), file]);
+      }
+    }
+  }
+
+  /**
+   * Package a file belongs to: the nearest directory holding a BUILD file
+   */
+  packageOf(relativePath: string): string | undefined {
+    let dir = path.posix.dirname(relativePath.split(path.sep).join('/'));
+    for (;;) {
+      const name = dir === '.' ? '' : dir;
+      if (this.packages.has(name)) {
+        return name;
+      }
+      if (name === '') {
+        return undefined;
+      }
+      dir = path.posix.dirname(dir);
+    }
+  }
+
+  /**
+   * Targets a label or label pattern names
+   */
+  resolve(label: string): BazelTarget[] {
+    const parsed = parseLabel(label);
+    if (parsed.recursive) {
+      const matched = this.targets.filter((target) =>
+        parsed.package === '' || target.package === parsed.package || target.package.startsWith(`${parsed.package}/`));
+      if (matched.length === 0) {
+        throw new ToolError('not-found', `No Bazel package named //${parsed.package} or below it`);
+      }
+      return matched;
+    }
+    if (parsed.name === undefined) {
+      const matched = this.targets.filter((target) => target.package === parsed.package);
+      if (matched.length === 0) {
+        throw new ToolError('not-found', `No Bazel package named //${parsed.package}`);
+      }
+      return matched;
+    }
+    const target = this.byLabel.get(`//${parsed.package}:${parsed.name}`);
+    if (!target) {
+      const siblings = this.targets.filter((candidate) => candidate.package === parsed.package).map((candidate) => candidate.label);
+      throw new ToolError('not-found', `No Bazel target named //${parsed.package}:${parsed.name}` +
+        (siblings.length > 0 ? `; //${parsed.package} has ${siblings.join(', ')}` : ''));
+    }
+    return [target];
+  }
+
+  /**
+   * Workspace files a target is built from, / separated
+   * Sources naming another target (a genrule's output) are left out
+   */
+  filesOf(target: BazelTarget): string[] {
+    const cached = this.resolved.get(target.label);
+    if (cached) {
+      return cached;
+    }
+    const files = new Set<string>();
+    for (const source of target.sources) {
+      if (source.startsWith('@')) {
+        continue;
+      }
+      let file: string;
+      if (source.startsWith('//')) {
+        const parsed = parseLabel(source);
+        file = path.posix.join(parsed.package, parsed.name ?? '');
+      } else {
+        const name = source.replace(/^:/, '');
+        if (this.byLabel.has(`//${target.package}:${name}`)) {
+          continue;
+        }
+        file = path.posix.join(target.package, name);
+      }
+      if (this.existing.has(file)) {
+        files.add(file);
+      }
+    }
+
+    if (target.globs.length > 0) {
+      const prefix = target.package === '' ? '' : `${target.package}/`;
+      for (const file of this.byPackage.get(target.package) ?? []) {
+        const relative = file.substring(prefix.length);
+        for (const glob of target.globs) {
+          if (glob.include.some((pattern) => globToRegExp(pattern).test(relative)) &&
+            !glob.exclude.some((pattern) => globToRegExp(pattern).test(relative))) {
+            files.add(file);
+          }
+        }
+      }
+    }
+    const sorted = Array.from(files).sort();
+    this.resolved.set(target.label, sorted);
+    return sorted;
+  }
+
+  /**
+   * Targets built from a file
+   */
+  owners(relativePath: string): BazelTarget[] {
+    const file = relativePath.split(path.sep).join('/');
+    const packageName = this.packageOf(file);
+    // A target lists files of its own package, or of another by full label
+    return this.targets.filter((target) =>
+      (target.package === packageName || target.sources.some((source) => source.startsWith('//'))) &&
+      this.filesOf(target).includes(file));
+  }
+}
+
+const loaded = new Map<string, { stamp: string; workspace: BazelWorkspace }>();
+
+/**
+ * Stamp of the workspace's file list and its BUILD files' modification times,
+ * or undefined when reads are pinned to a snapshot and disk may differ
+ */
+async function workspaceStamp(files: string[], buildFiles: Array<{ absolutePath: string }>): Promise<string | undefined> {
+  if (currentSnapshot()) {
+    return undefined;
+  }
+  const hash = crypto.createHash('sha256').update(files.join('\n'));
+  for (const file of buildFiles) {
+    const overlay = sharedOverlay().entry(file.absolutePath);
+    try {
+      const stat = overlay ? undefined : await fs.promises.stat(file.absolutePath);
+      hash.update(overlay ? `\0overlay:${overlay.updatedAt}` : `\0${stat!.mtimeMs}:${stat!.size}`);
+    } catch {
+      return undefined;
+    }
+  }
+  return hash.digest('hex');
+}
+
+/**
+ * Read the BUILD files under a path, with the workspace files their globs can match
+ * The files are those of the whole workspace, as packages are decided by BUILD files
+ * above and below the path alike. The result, with its package index and the
+ * files resolved for each target, is reused while no file is added or removed
+ * and no BUILD file changes
+ */
+export async function loadBazelWorkspace(workspaceDir: string, pathPrefix?: string): Promise<BazelWorkspace> {
+  const walked = await walkWorkspaceFiles(workspaceDir);
+  const files = walked.map((file) => file.relativePath.split(path.sep).join('/'));
+  const buildFiles = walked.filter((file) => isBuildFile(file.relativePath));
+  const key = `${path.resolve(workspaceDir)}\0${pathPrefix ?? ''}`;
+  const stamp = await workspaceStamp(files, buildFiles);
+  const cached = loaded.get(key);
+  if (stamp && cached?.stamp === stamp) {
+    loaded.delete(key);
+    loaded.set(key, cached);
+    return cached.workspace;
+  }
+  const packages = new Set(buildFiles.map((file) => {
+    const dir = path.posix.dirname(file.relativePath.split(path.sep).join('/'));
+    return dir === '.' ? '' : dir;
+  }));
+
+  const prefix = pathPrefix ? path.relative(workspaceDir, resolveWorkspacePath(workspaceDir, pathPrefix)).split(path.sep).join('/') : '';
+  const targets: BazelTarget[] = [];
+  for (const file of buildFiles) {
+    const relative = file.relativePath.split(path.sep).join('/');
+    if (prefix && relative !== prefix && !relative.startsWith(`${prefix}/`)) {
+      continue;
+    }
+    const dir = path.posix.dirname(relative);
+    try {
+      targets.push(...parseBuildFile(await readFileText(file.absolutePath), file.relativePath, dir === '.' ? '' : dir));
+    } catch (err) {
+      bazelLogger.debug('Could not read %s: %s', file.relativePath, (err as Error).message);
+    }
+  }
+  const workspace = new BazelWorkspace(targets, files, packages);
+  if (stamp) {
+    loaded.delete(key);
+    loaded.set(key, { stamp, workspace });
+    if (loaded.size > MAX_CACHED_WORKSPACES) {
+      loaded.delete(loaded.keys().next().value!);
+    }
+  }
+  return workspace;
+}
+
+/**
+ * Workspace files of the targets a label or pattern names, relative and
+ * spelled for the platform, for searching them only
+ */
+export async function bazelTargetFiles(workspaceDir: string, label: string): Promise<string[]> {
+  const workspace = await loadBazelWorkspace(workspaceDir);
+  const files = new Set(workspace.resolve(label).flatMap((target) => workspace.filesOf(target)));
+  if (files.size === 0) {
+    throw new ToolError('not-found', `No source files named by ${label} are in the workspace`);
+  }
+  return Array.from(files).sort().map((file) => file.split('/').join(path.sep));
+}
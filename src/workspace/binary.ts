@@ -0,0 +1,63 @@
+/**
+ * Binary file detection
+ * Known binary extensions are rejected by name; everything else is checked
+ * for NUL bytes near the start of the file, as git does
+ */
+
+import * as path from 'path';
+import { hasUtf16Bom } from './encoding.js';
+
+/**
+ * Number of leading bytes inspected for NUL bytes
+ */
+const SNIFF_LENGTH = 8000;
+
+/**
+ * Extensions of files that are binary regardless of content
+ */
+const BINARY_EXTENSIONS = new Set([
+  // Images and media
+  '.png', '.jpg', '.jpeg', '.gif', '.bmp', '.ico', '.webp', '.tiff', '.psd',
+  '.mp3', '.mp4', '.wav', '.ogg', '.flac', '.avi', '.mov', '.mkv', '.webm',
+  // Documents and fonts
+  '.pdf', '.doc', '.docx', '.xls', '.xlsx', '.ppt', '.pptx',
+  '.ttf', '.otf', '.woff', '.woff2', '.eot',
+  // Archives
+  '.zip', '.tar', '.gz', '.tgz', '.bz2', '.xz', '.zst', '.7z', '.rar', '.jar', '.war',
+  // Compiled artifacts
+  '.o', '.obj', '.a', '.lib', '.so', '.dylib', '.dll', '.exe', '.bin', '.class',
+  '.pyc', '.pyo', '.wasm', '.node',
+  // Databases
+  '.db', '.sqlite', '.sqlite3',
+]);
+
+/**
+ * Check if a file name has a known binary extension
+ */
+export function hasBinaryExtension(filePath: string): boolean {
+  return BINARY_EXTENSIONS.has(path.extname(filePath).toLowerCase());
+}
+
+/**
+ * Check if content looks binary: a NUL byte within the first SNIFF_LENGTH bytes
+ * UTF-16 text with a byte order mark is not binary
+ */
+export function isBinaryContent(content: Buffer): boolean {
+  if (hasUtf16Bom(content)) {
+    return false;
+  }
+  const end = Math.min(content.length, SNIFF_LENGTH);
+  for (let i = 0; i < end; i++) {
+    if (content[i] === 0) {
+      return true;
+    }
+  }
+  return false;
+}
+
+/**
+ * Check if a file is binary by name or content
+ */
+export function isBinaryFile(filePath: string, content: Buffer): boolean {
+  return hasBinaryExtension(filePath) || isBinaryContent(content);
+}
@@ -0,0 +1,112 @@
+/**
+ * Tests for query cost budgets
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { BudgetExceededError, BudgetTracker, budgetLimitsFromEnv, currentBudget, unbudgeted, withBudget } from './budget';
+import { readFileText } from './overlay';
+import { searchLexical } from '../search/lexical';
+import { searchCode } from '../tools/search';
+
+const UNLIMITED = { files: 0, bytes: 0, lspCalls: 0 };
+
+describe('BudgetTracker', () => {
+  it('should cap files and language server requests per call', () => {
+    const tracker = new BudgetTracker(() => ({ query: { files: 2, bytes: 0, lspCalls: 1 }, session: UNLIMITED }));
+    const budget = tracker.start('a');
+    budget.beforeRead();
+    budget.beforeRead();
+    expect(() => budget.beforeRead()).toThrow('budget exceeded: query limit of 2 files read reached');
+    budget.beforeLspCall();
+    expect(() => budget.beforeLspCall()).toThrow(BudgetExceededError);
+    expect(budget.exceeded()?.resource).toBe('files');
+    // The next call starts afresh
+    expect(() => tracker.start('a').beforeRead()).not.toThrow();
+  });
+
+  it('should let the read that passes the byte limit finish, then refuse more', () => {
+    const tracker = new BudgetTracker(() => ({ query: { files: 0, bytes: 100, lspCalls: 0 }, session: UNLIMITED }));
+    const budget = tracker.start('a');
+    budget.beforeRead();
+    budget.afterRead(150);
+    expect(budget.exceeded()?.toJSON()).toEqual({
+      error: 'budget-exceeded', scope: 'query', resource: 'bytes', limit: 100, message: 'budget exceeded: query limit of 100 bytes read reached',
+    });
+    expect(() => budget.beforeRead()).toThrow('bytes read');
+    expect(budget.spent).toEqual({ files: 1, bytes: 150, lspCalls: 0 });
+  });
+
+  it('should total a session across calls and stop charging closed calls', () => {
+    const tracker = new BudgetTracker(() => ({ query: UNLIMITED, session: { files: 3, bytes: 0, lspCalls: 0 } }));
+    const first = tracker.start('a');
+    first.beforeRead();
+    first.beforeRead();
+    first.close();
+    first.beforeRead();
+    const second = tracker.start('a');
+    second.beforeRead();
+    expect(() => second.beforeRead()).toThrow('session limit of 3 files read');
+    expect(tracker.spent('a')).toEqual({ files: 3, bytes: 0, lspCalls: 0 });
+    // Other sessions have their own budgets
+    expect(() => tracker.start('b').beforeRead()).not.toThrow();
+  });
+
+  it('should forget a session idle past the limit, or one that ended', () => {
+    const tracker = new BudgetTracker(() => ({ query: UNLIMITED, session: { files: 1, bytes: 0, lspCalls: 0 } }), 1000);
+    tracker.start('a', 0).beforeRead();
+    expect(() => tracker.start('a', 500).beforeRead()).toThrow('session limit');
+    // The refused call counts as activity; a second later the session starts over
+    expect(() => tracker.start('a', 1500).beforeRead()).not.toThrow();
+    tracker.forget('a');
+    expect(tracker.spent('a')).toEqual(UNLIMITED);
+  });
+
+  it('should read limits from the environment, unlimited by default', () => {
+    expect(budgetLimitsFromEnv({})).toEqual({ query: UNLIMITED, session: UNLIMITED });
+    expect(budgetLimitsFromEnv({ QUERY_MAX_FILES: '10', QUERY_MAX_READ_MB: '0.5', SESSION_MAX_LSP_CALLS: '-1' }))
+      .toEqual({ query: { files: 10, bytes: 512 * 1024, lspCalls: 0 }, session: UNLIMITED });
+  });
+});
+
+describe('budgeted work', () => {
+  let workspace: string;
+
+  beforeAll(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'budget-')));
+    for (let i = 0; i < 6; i++) {
+      fs.writeFileSync(path.join(workspace, `f${i}.ts`), `const needle${i} = ${i};\n`);
+    }
+  });
+
+  afterAll(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should charge file reads to the budget of the call they run in', async () => {
+    const budget = new BudgetTracker(() => ({ query: { files: 1, bytes: 0, lspCalls: 0 }, session: UNLIMITED })).start('a');
+    await withBudget(budget, async () => {
+      expect(currentBudget()).toBe(budget);
+      await readFileText(path.join(workspace, 'f0.ts'));
+      await unbudgeted(() => readFileText(path.join(workspace, 'f1.ts')));
+      await expect(readFileText(path.join(workspace, 'f2.ts'))).rejects.toThrow('budget exceeded');
+    });
+    expect(budget.spent.files).toBe(1);
+    expect(budget.spent.bytes).toBe(fs.statSync(path.join(workspace, 'f0.ts')).size);
+    expect(currentBudget()).toBeUndefined();
+  });
+
+  it('should return the matches of a search found before its budget ran out', async () => {
+    const budget = new BudgetTracker(() => ({ query: { files: 2, bytes: 0, lspCalls: 0 }, session: UNLIMITED })).start('a');
+    const result = await withBudget(budget, () => searchLexical(workspace, 'needle', { concurrency: 1 }));
+    expect(result.matches).toHaveLength(2);
+    expect(result.truncatedByBudget).toBe('budget exceeded: query limit of 2 files read reached');
+    expect(budget.isReported()).toBe(false);
+    // search_code says so in its output, and the server need not add a note
+    const formatted = new BudgetTracker(() => ({ query: { files: 2, bytes: 0, lspCalls: 0 }, session: UNLIMITED })).start('a');
+    expect(await withBudget(formatted, () => searchCode(workspace, 'needle', { concurrency: 1 }))).toContain('results are partial');
+    expect(formatted.isReported()).toBe(true);
+    expect((await searchLexical(workspace, 'needle')).truncatedByBudget).toBeUndefined();
+  });
+});
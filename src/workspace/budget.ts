@@ -0,0 +1,265 @@
+/**
+ * Query cost budgets per tool call and per client session
+ * Caps the files read, bytes read, and language server requests one call and
+ * one session may spend, so a pathological agent loop cannot monopolize a
+ * shared server. Work is charged to the budget of the call it runs in,
+ * followed through async context; a search over budget stops with partial
+ * results flagged as such, and other calls end with what they have
+ */
+
+import { AsyncLocalStorage } from 'async_hooks';
+import { ToolError } from './errors.js';
+
+/**
+ * What a call or session spent
+ */
+export interface Cost {
+  files: number;
+  bytes: number;
+  lspCalls: number;
+}
+
+/**
+ * Most of each resource a call or session may spend; 0 means unlimited
+ */
+export type CostLimits = Cost;
+
+/**
+ * Budgets for each call and for each session's calls together
+ */
+export interface BudgetLimits {
+  query: CostLimits;
+  session: CostLimits;
+}
+
+/**
+ * Marks output cut short by a budget
+ */
+export const BUDGET_NOTE = 'budget exceeded';
+
+const RESOURCE_NAMES: Record<keyof Cost, string> = {
+  files: 'files read',
+  bytes: 'bytes read',
+  lspCalls: 'language server requests',
+};
+
+/**
+ * Limits from the QUERY_* and SESSION_* variables (default: unlimited)
+ * QUERY_MAX_FILES, QUERY_MAX_READ_MB, QUERY_MAX_LSP_CALLS, and the same for SESSION_
+ */
+export function budgetLimitsFromEnv(env: NodeJS.ProcessEnv = process.env): BudgetLimits {
+  const read = (name: string) => {
+    const value = env[name] ? parseFloat(env[name]!) : 0;
+    return Number.isFinite(value) && value > 0 ? value : 0;
+  };
+  const limits = (prefix: string): CostLimits => ({
+    files: Math.floor(read(`${prefix}_MAX_FILES`)),
+    bytes: Math.floor(read(`${prefix}_MAX_READ_MB`) * 1024 * 1024),
+    lspCalls: Math.floor(read(`${prefix}_MAX_LSP_CALLS`)),
+  });
+  return { query: limits('QUERY'), session: limits('SESSION') };
+}
+
+/**
+ * A call refused or cut short because a budget ran out
+ */
+export class BudgetExceededError extends ToolError {
+  constructor(
+    public readonly scope: 'query' | 'session',
+    public readonly resource: keyof Cost,
+    public readonly limit: number,
+  ) {
+    super('budget-exceeded', `${BUDGET_NOTE}: ${scope} limit of ${limit} ${RESOURCE_NAMES[resource]} reached`);
+    this.name = 'BudgetExceededError';
+  }
+
+  toJSON(): Record<string, unknown> {
+    return { error: this.code, scope: this.scope, resource: this.resource, limit: this.limit, message: this.message };
+  }
+}
+
+function emptyCost(): Cost {
+  return { files: 0, bytes: 0, lspCalls: 0 };
+}
+
+/**
+ * The budget of one call, charging its session's too
+ */
+export class QueryBudget {
+  readonly spent = emptyCost();
+  // The first limit reached, kept so the result can say which
+  private exceededBy?: BudgetExceededError;
+  // Work the call left running, such as background indexing, is not charged
+  private closed = false;
+  // Set once the call's output says which limit it reached
+  private reported = false;
+
+  constructor(private limits: BudgetLimits, private session: Cost) {}
+
+  /**
+   * The limit that would be passed by spending more of a resource, if any
+   */
+  private over(resource: keyof Cost, amount: number): BudgetExceededError | undefined {
+    const { query, session } = this.limits;
+    if (query[resource] > 0 && this.spent[resource] + amount > query[resource]) {
+      return new BudgetExceededError('query', resource, query[resource]);
+    }
+    if (session[resource] > 0 && this.session[resource] + amount > session[resource]) {
+      return new BudgetExceededError('session', resource, session[resource]);
+    }
+    return undefined;
+  }
+
+  private spend(resource: keyof Cost, amount: number): void {
+    this.spent[resource] += amount;
+    this.session[resource] += amount;
+  }
+
+  /**
+   * Charge a file about to be read; throws once the file or byte budget is spent
+   */
+  beforeRead(): void {
+    if (this.closed) {
+      return;
+    }
+    const exceeded = this.exceededBy?.resource === 'bytes' ? this.exceededBy : this.over('files', 1);
+    if (exceeded) {
+      this.exceededBy ??= exceeded;
+      throw exceeded;
+    }
+    this.spend('files', 1);
+  }
+
+  /**
+   * Charge the bytes of a file read; the read that passes the limit still completes
+   */
+  afterRead(bytes: number): void {
+    if (this.closed) {
+      return;
+    }
+    const exceeded = this.over('bytes', bytes);
+    this.spend('bytes', bytes);
+    if (exceeded) {
+      this.exceededBy ??= exceeded;
+    }
+  }
+
+  /**
+   * Charge a language server request about to be sent; throws when the budget is spent
+   */
+  beforeLspCall(): void {
+    if (this.closed) {
+      return;
+    }
+    const exceeded = this.over('lspCalls', 1);
+    if (exceeded) {
+      this.exceededBy ??= exceeded;
+      throw exceeded;
+    }
+    this.spend('lspCalls', 1);
+  }
+
+  /**
+   * The limit reached so far, if any; work should stop
+   */
+  exceeded(): BudgetExceededError | undefined {
+    return this.exceededBy;
+  }
+
+  /**
+   * Record that the call's output already says it was cut short by the budget
+   */
+  markReported(): void {
+    this.reported = true;
+  }
+
+  /**
+   * Whether the call's output says it was cut short; otherwise the server adds a note
+   */
+  isReported(): boolean {
+    return this.reported;
+  }
+
+  /**
+   * End the call; later work is free
+   */
+  close(): void {
+    this.closed = true;
+  }
+}
+
+/**
+ * Time after its last call that a session's spending is forgotten
+ */
+const SESSION_IDLE_MS = 60 * 60 * 1000;
+
+/**
+ * Spending per session against the limits
+ * Limits are read on every call, so a configuration reload applies at once.
+ * A session idle for idleMs starts over, as does one that ends
+ */
+export class BudgetTracker {
+  private sessions = new Map<string, { spent: Cost; lastCall: number }>();
+
+  constructor(
+    private limits: () => BudgetLimits = () => budgetLimitsFromEnv(),
+    private idleMs = SESSION_IDLE_MS,
+  ) {}
+
+  /**
+   * A budget for a call of a session
+   * Once the session has spent a limit, its calls stop at the first read or
+   * request that needs more; calls that need none still run
+   */
+  start(session: string, now = Date.now()): QueryBudget {
+    for (const [id, entry] of this.sessions) {
+      if (entry.lastCall <= now - this.idleMs) {
+        this.sessions.delete(id);
+      }
+    }
+    let entry = this.sessions.get(session);
+    if (!entry) {
+      entry = { spent: emptyCost(), lastCall: now };
+      this.sessions.set(session, entry);
+    }
+    entry.lastCall = now;
+    return new QueryBudget(this.limits(), entry.spent);
+  }
+
+  /**
+   * What a session has spent
+   */
+  spent(session: string): Cost {
+    return { ...(this.sessions.get(session)?.spent ?? emptyCost()) };
+  }
+
+  /**
+   * Drop the spending of a session that ended
+   */
+  forget(session: string): void {
+    this.sessions.delete(session);
+  }
+}
+
+const budgetContext = new AsyncLocalStorage<QueryBudget>();
+
+/**
+ * Run a function with its reads and requests, including from async work it starts, charged to a budget
+ */
+export function withBudget<T>(budget: QueryBudget, fn: () => T): T {
+  return budgetContext.run(budget, fn);
+}
+
+/**
+ * Run shared work, such as index maintenance, that no call's budget pays for
+ */
+export function unbudgeted<T>(fn: () => T): T {
+  return budgetContext.exit(fn);
+}
+
+/**
+ * Budget of the call running, or undefined outside tool calls (indexing, the watcher)
+ */
+export function currentBudget(): QueryBudget | undefined {
+  return budgetContext.getStore();
+}
@@ -0,0 +1,67 @@
+/**
+ * Tests for Go build constraints
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { buildContext, evalBuildExpr, matchesBuildContext, matchesFileName } from './buildtags';
+import { searchLexical } from '../search/lexical';
+
+describe('Go build constraints', () => {
+  const linux = buildContext(['linux', 'amd64']);
+
+  it('should split known GOOS and GOARCH from custom tags', () => {
+    expect(buildContext(['integration', 'windows', 'arm64'], {})).toEqual({
+      goos: 'windows',
+      goarch: 'arm64',
+      tags: ['integration'],
+    });
+    expect(buildContext([], { GOOS: 'darwin', GOARCH: 'arm64' })).toEqual({ goos: 'darwin', goarch: 'arm64', tags: [] });
+  });
+
+  it('should apply file name suffixes like go/build', () => {
+    expect(matchesFileName('net_linux.go', linux)).toBe(true);
+    expect(matchesFileName('net_windows.go', linux)).toBe(false);
+    expect(matchesFileName('net_linux_arm64.go', linux)).toBe(false);
+    expect(matchesFileName('net_windows_test.go', linux)).toBe(false);
+    expect(matchesFileName('linux.go', linux)).toBe(true);
+    expect(matchesFileName('zsys_android.go', buildContext(['android', 'arm64']))).toBe(true);
+    expect(matchesFileName('sys_linux.go', buildContext(['android', 'arm64']))).toBe(true);
+  });
+
+  it('should evaluate //go:build and // +build lines', () => {
+    expect(evalBuildExpr('linux && (amd64 || arm64) && !integration', linux)).toBe(true);
+    expect(evalBuildExpr('unix && go1.21', linux)).toBe(true);
+    expect(evalBuildExpr('windows || integration', linux)).toBe(false);
+    expect(() => evalBuildExpr('linux &&', linux)).toThrow();
+
+    const header = (line: string) => `// Copyright\n\n${line}\n\npackage net\n`;
+    expect(matchesBuildContext('a.go', header('//go:build windows'), linux)).toBe(false);
+    expect(matchesBuildContext('a.go', header('// +build linux,amd64 darwin'), linux)).toBe(true);
+    expect(matchesBuildContext('a.go', header('// +build !linux'), linux)).toBe(false);
+    // A constraint after the package clause is an ordinary comment
+    expect(matchesBuildContext('a.go', 'package net\n//go:build windows\n', linux)).toBe(true);
+    // Malformed lines do not hide files
+    expect(matchesBuildContext('a.go', header('//go:build linux &&'), linux)).toBe(true);
+  });
+
+  it('should leave files outside the build out of searches', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'buildtags-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'open_linux.go'), 'package fs\n\nfunc openFile() {}\n');
+      fs.writeFileSync(path.join(workspace, 'open_windows.go'), 'package fs\n\nfunc openFile() {}\n');
+      fs.writeFileSync(path.join(workspace, 'open_fake.go'), '//go:build fake\n\npackage fs\n\nfunc openFile() {}\n');
+      fs.writeFileSync(path.join(workspace, 'notes.txt'), 'openFile\n');
+
+      const result = await searchLexical(workspace, 'openFile', { goBuild: linux });
+      expect(result.matches.map((m) => m.filePath).sort()).toEqual(['notes.txt', 'open_linux.go']);
+      expect(result.buildExcluded).toBe(2);
+
+      const tagged = await searchLexical(workspace, 'openFile', { goBuild: buildContext(['linux', 'amd64', 'fake']) });
+      expect(tagged.matches.map((m) => m.filePath).sort()).toEqual(['notes.txt', 'open_fake.go', 'open_linux.go']);
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,236 @@
+/**
+ * Go build constraints - //go:build lines, legacy // +build lines, and
+ * _GOOS/_GOARCH file name suffixes
+ * Decides whether a Go file is part of the build for a GOOS, GOARCH, and set
+ * of tags, following the rules of go/build
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { ToolError } from './errors.js';
+
+const buildLogger = createLogger(Component.TOOLS);
+
+/**
+ * Operating systems known to the Go toolchain
+ */
+const KNOWN_OS = new Set([
+  'aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'js', 'linux',
+  'nacl', 'netbsd', 'openbsd', 'plan9', 'solaris', 'wasip1', 'windows', 'zos',
+]);
+
+/**
+ * Architectures known to the Go toolchain
+ */
+const KNOWN_ARCH = new Set([
+  '386', 'amd64', 'amd64p32', 'arm', 'armbe', 'arm64', 'arm64be', 'loong64', 'mips', 'mipsle',
+  'mips64', 'mips64le', 'mips64p32', 'mips64p32le', 'ppc', 'ppc64', 'ppc64le', 'riscv', 'riscv64',
+  's390', 's390x', 'sparc', 'sparc64', 'wasm',
+]);
+
+/**
+ * Operating systems satisfying the "unix" constraint
+ */
+const UNIX_OS = new Set([
+  'aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'linux',
+  'netbsd', 'openbsd', 'solaris',
+]);
+
+/**
+ * Operating systems that also match another one's tag and file suffix
+ */
+const IMPLIED_OS: Record<string, string> = { android: 'linux', ios: 'darwin', illumos: 'solaris' };
+
+/**
+ * Target a Go file is checked against
+ */
+export interface BuildContext {
+  goos: string;
+  goarch: string;
+  // Custom tags as passed to go build -tags (e.g. "integration", "cgo")
+  tags: string[];
+}
+
+/**
+ * GOOS and GOARCH of the machine the server runs on
+ */
+function hostTarget(): { goos: string; goarch: string } {
+  const goos = process.platform === 'win32' ? 'windows' : process.platform === 'sunos' ? 'solaris' : process.platform;
+  const arches: Record<string, string> = { x64: 'amd64', ia32: '386', arm: 'arm', arm64: 'arm64', ppc64: 'ppc64le', s390x: 's390x' };
+  return { goos, goarch: arches[process.arch] ?? process.arch };
+}
+
+/**
+ * Build context from a list of tags such as ["linux", "arm64", "integration"]
+ * Known GOOS and GOARCH names select the target; the rest are custom tags.
+ * The target defaults to GOOS and GOARCH from the environment, then the host,
+ * as for go build
+ */
+export function buildContext(tags: string[] = [], env: NodeJS.ProcessEnv = process.env): BuildContext {
+  const host = hostTarget();
+  const goos = tags.find((tag) => KNOWN_OS.has(tag)) ?? env.GOOS ?? host.goos;
+  const goarch = tags.find((tag) => KNOWN_ARCH.has(tag)) ?? env.GOARCH ?? host.goarch;
+  return { goos, goarch, tags: tags.filter((tag) => !KNOWN_OS.has(tag) && !KNOWN_ARCH.has(tag)) };
+}
+
+/**
+ * Check if a single build tag is satisfied
+ * Release tags (go1.N) are always satisfied, as for the newest toolchain
+ */
+function matchTag(tag: string, context: BuildContext): boolean {
+  if (tag === context.goos || tag === context.goarch || context.tags.includes(tag)) {
+    return true;
+  }
+  if (IMPLIED_OS[context.goos] === tag) {
+    return true;
+  }
+  if (tag === 'unix') {
+    return UNIX_OS.has(context.goos);
+  }
+  return tag === 'gc' || /^go1\.\d+$/.test(tag);
+}
+
+/**
+ * Check the _GOOS, _GOARCH, and _GOOS_GOARCH suffixes of a file name
+ * (before _test); a name without an underscore has no constraint
+ */
+export function matchesFileName(filePath: string, context: BuildContext): boolean {
+  let name = path.basename(filePath).replace(/\.go$/, '');
+  const i = name.indexOf('_');
+  if (i < 0) {
+    return true;
+  }
+  name = name.substring(i);
+  const parts = name.split('_');
+  if (parts[parts.length - 1] === 'test') {
+    parts.pop();
+  }
+  const n = parts.length;
+  if (n >= 2 && KNOWN_OS.has(parts[n - 2]) && KNOWN_ARCH.has(parts[n - 1])) {
+    return matchTag(parts[n - 2], context) && matchTag(parts[n - 1], context);
+  }
+  if (n >= 1 && (KNOWN_OS.has(parts[n - 1]) || KNOWN_ARCH.has(parts[n - 1]))) {
+    return matchTag(parts[n - 1], context);
+  }
+  return true;
+}
+
+/**
+ * Evaluate a //go:build expression (&&, ||, !, and parentheses)
+ */
+export function evalBuildExpr(expr: string, context: BuildContext): boolean {
+  const tokens = expr.match(/&&|\|\||[!()]|[A-Za-z0-9_.]+|\S/g) ?? [];
+  let pos = 0;
+
+  const parseOr = (): boolean => {
+    let value = parseAnd();
+    while (tokens[pos] === '||') {
+      pos++;
+      // Both sides are parsed so the whole expression is checked for syntax
+      const right = parseAnd();
+      value = value || right;
+    }
+    return value;
+  };
+  const parseAnd = (): boolean => {
+    let value = parseNot();
+    while (tokens[pos] === '&&') {
+      pos++;
+      const right = parseNot();
+      value = value && right;
+    }
+    return value;
+  };
+  const parseNot = (): boolean => {
+    const token = tokens[pos++];
+    if (token === '!') {
+      return !parseNot();
+    }
+    if (token === '(') {
+      const value = parseOr();
+      if (tokens[pos++] !== ')') {
+        throw new ToolError('pattern-invalid', 'missing )');
+      }
+      return value;
+    }
+    if (token === undefined || !/^[A-Za-z0-9_.]+$/.test(token)) {
+      throw new ToolError('pattern-invalid', `unexpected ${token ?? 'end of expression'}`);
+    }
+    return matchTag(token, context);
+  };
+
+  const value = parseOr();
+  if (pos < tokens.length) {
+    throw new ToolError('pattern-invalid', `unexpected ${tokens[pos]}`);
+  }
+  return value;
+}
+
+/**
+ * Evaluate one legacy "// +build" line: space-separated options are ORed,
+ * comma-separated terms ANDed, and "!" negates a term
+ */
+function evalPlusBuild(line: string, context: BuildContext): boolean {
+  return line.trim().split(/\s+/).some((option) =>
+    option.split(',').every((term) => (term.startsWith('!') ? !matchTag(term.substring(1), context) : matchTag(term, context))));
+}
+
+/**
+ * Read the build constraint lines from the comments before the package clause
+ */
+export function buildConstraints(content: string): { goBuild?: string; plusBuild: string[] } {
+  let goBuild: string | undefined;
+  const plusBuild: string[] = [];
+  let inBlock = false;
+  for (const rawLine of content.split('\n')) {
+    const line = rawLine.trim();
+    if (inBlock) {
+      inBlock = !line.includes('*/');
+      continue;
+    }
+    if (line.startsWith('/*')) {
+      inBlock = !line.includes('*/');
+      continue;
+    }
+    if (line !== '' && !line.startsWith('//')) {
+      break;
+    }
+    const goMatch = line.match(/^\/\/go:build\s+(.*)$/);
+    if (goMatch && goBuild === undefined) {
+      goBuild = goMatch[1].trim();
+    }
+    const plusMatch = line.match(/^\/\/\s*\+build\s+(.*)$/);
+    if (plusMatch) {
+      plusBuild.push(plusMatch[1]);
+    }
+  }
+  return { goBuild, plusBuild };
+}
+
+/**
+ * Check if a Go file is part of the build for a context, from its name and
+ * its //go:build line (or // +build lines when there is none)
+ * Files with a malformed constraint are kept
+ */
+export function matchesBuildContext(filePath: string, content: string, context: BuildContext): boolean {
+  if (!matchesFileName(filePath, context)) {
+    return false;
+  }
+  const { goBuild, plusBuild } = buildConstraints(content);
+  try {
+    if (goBuild !== undefined) {
+      return evalBuildExpr(goBuild, context);
+    }
+  } catch (err) {
+    buildLogger.debug('Malformed //go:build line in %s: %s', filePath, (err as Error).message);
+    return true;
+  }
+  return plusBuild.every((line) => evalPlusBuild(line, context));
+}
+
+/**
+ * Describe a context for tool output (e.g. "linux/arm64, tags: integration")
+ */
+export function formatBuildContext(context: BuildContext): string {
+  return `${context.goos}/${context.goarch}` + (context.tags.length > 0 ? `, tags: ${context.tags.join(',')}` : '');
+}
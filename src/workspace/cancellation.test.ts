@@ -0,0 +1,82 @@
+/**
+ * Tests for call cancellation and task groups
+ */
+
+import { CancelledError, TaskGroup, currentSignal, runGroup, throwIfCancelled, uncancellable, unlessCancelled, withCancellation } from './cancellation';
+import { runPool } from './pool';
+
+describe('Cancellation', () => {
+  it('should follow the signal through async calls, except into shared work', async () => {
+    const controller = new AbortController();
+    await withCancellation(controller.signal, async () => {
+      await Promise.resolve();
+      expect(currentSignal()).toBe(controller.signal);
+      expect(uncancellable(() => currentSignal())).toBeUndefined();
+      controller.abort();
+      expect(() => throwIfCancelled()).toThrow(CancelledError);
+    });
+    expect(currentSignal()).toBeUndefined();
+    expect(() => throwIfCancelled()).not.toThrow();
+  });
+
+  it('should stop waiting when cancelled and run the cleanup', async () => {
+    const controller = new AbortController();
+    let cleanedUp = false;
+    const waiting = unlessCancelled(new Promise(() => undefined), controller.signal, () => { cleanedUp = true; });
+    controller.abort();
+    await expect(waiting).rejects.toThrow('Cancelled: the client cancelled the request');
+    expect(cleanedUp).toBe(true);
+  });
+
+  it('should stop a pool from taking items once its call is cancelled', async () => {
+    const controller = new AbortController();
+    const seen: number[] = [];
+    const run = withCancellation(controller.signal, () => runPool([1, 2, 3, 4, 5], async (item) => {
+      seen.push(item);
+      if (item === 2) {
+        controller.abort();
+      }
+    }, { concurrency: 1 }));
+    await expect(run).rejects.toThrow(CancelledError);
+    expect(seen).toEqual([1, 2]);
+  });
+});
+
+describe('TaskGroup', () => {
+  it('should cancel the other tasks when one fails and wait for all of them', async () => {
+    const group = new TaskGroup();
+    let sawCancel = false;
+    let settled = false;
+    group.spawn(async () => {
+      await new Promise<void>((resolve) => currentSignal()!.addEventListener('abort', () => resolve()));
+      sawCancel = true;
+      await new Promise((resolve) => setTimeout(resolve, 5));
+      settled = true;
+    });
+    group.spawn(async () => {
+      throw new Error('boom');
+    }).catch(() => undefined);
+    await expect(group.wait()).rejects.toThrow('boom');
+    expect(sawCancel).toBe(true);
+    // The sibling finished before wait returned
+    expect(settled).toBe(true);
+    expect(group.signal.reason.message).toBe('Cancelled: another task of the call failed: boom');
+  });
+
+  it('should end with the parent signal and cancel work left running once it returns', async () => {
+    const parent = new AbortController();
+    const pending = runGroup(parent.signal, () => new Promise((resolve, reject) => {
+      currentSignal()!.addEventListener('abort', () => reject(new CancelledError()));
+    }));
+    parent.abort();
+    await expect(pending).rejects.toThrow(CancelledError);
+
+    let leftBehind: AbortSignal | undefined;
+    expect(await runGroup(undefined, async () => {
+      leftBehind = currentSignal();
+      return 'done';
+    })).toBe('done');
+    expect(leftBehind!.aborted).toBe(true);
+    expect(leftBehind!.reason.message).toBe('Cancelled: the call already returned');
+  });
+});
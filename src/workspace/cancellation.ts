@@ -0,0 +1,195 @@
+/**
+ * Cancellation of tool calls and the work they start
+ * Each call runs in a task group whose signal is followed through async
+ * context, like its budget. The group is cancelled when the client cancels
+ * the request, when one of its tasks fails, and when the call returns, so
+ * scans, pooled file reads, and language server requests left running stop
+ * instead of piling up. Work shared between calls, such as an index
+ * refresh, runs outside any call's signal
+ */
+
+import { AsyncLocalStorage } from 'async_hooks';
+import { ToolError } from './errors.js';
+
+/**
+ * Why a group's signal was aborted, when it was not a failing task
+ */
+const CANCEL_REASONS = {
+  client: 'the client cancelled the request',
+  finished: 'the call already returned',
+} as const;
+
+/**
+ * Work stopped because the call it ran for was cancelled
+ */
+export class CancelledError extends ToolError {
+  constructor(reason = CANCEL_REASONS.client as string) {
+    super('cancelled', `Cancelled: ${reason}`);
+    this.name = 'CancelledError';
+  }
+}
+
+const cancellationContext = new AsyncLocalStorage<AbortSignal>();
+
+/**
+ * Run a function with a signal that work it starts checks, directly or through async calls
+ */
+export function withCancellation<T>(signal: AbortSignal | undefined, fn: () => T): T {
+  return signal ? cancellationContext.run(signal, fn) : fn();
+}
+
+/**
+ * Run shared work, such as an index refresh other calls wait on, outside the current call's signal
+ */
+export function uncancellable<T>(fn: () => T): T {
+  return cancellationContext.exit(fn);
+}
+
+/**
+ * Signal of the call the current work runs for, if any
+ */
+export function currentSignal(): AbortSignal | undefined {
+  return cancellationContext.getStore();
+}
+
+/**
+ * The error a cancelled signal stands for
+ * A signal aborted without a reason of ours, as the SDK aborts them, is the client's
+ */
+function cancelledBy(signal: AbortSignal): Error {
+  if (signal.reason instanceof ToolError) {
+    return signal.reason;
+  }
+  return new CancelledError(typeof signal.reason === 'string' ? signal.reason : CANCEL_REASONS.client);
+}
+
+/**
+ * Throw if the signal, by default the current call's, is cancelled
+ */
+export function throwIfCancelled(signal: AbortSignal | undefined = currentSignal()): void {
+  if (signal?.aborted) {
+    throw cancelledBy(signal);
+  }
+}
+
+/**
+ * Wait for a promise, or reject as soon as the signal is cancelled
+ * The work behind the promise is not stopped; onCancel can stop it
+ */
+export function unlessCancelled<T>(
+  promise: Promise<T>,
+  signal: AbortSignal | undefined = currentSignal(),
+  onCancel?: () => void
+): Promise<T> {
+  if (!signal) {
+    return promise;
+  }
+  if (signal.aborted) {
+    onCancel?.();
+    return Promise.reject(cancelledBy(signal));
+  }
+  return new Promise<T>((resolve, reject) => {
+    const abort = () => {
+      onCancel?.();
+      reject(cancelledBy(signal));
+    };
+    signal.addEventListener('abort', abort, { once: true });
+    promise.then(
+      (value) => {
+        signal.removeEventListener('abort', abort);
+        resolve(value);
+      },
+      (err) => {
+        signal.removeEventListener('abort', abort);
+        reject(err);
+      }
+    );
+  });
+}
+
+/**
+ * Tasks that share a signal and end together
+ * The first task to fail cancels the others, and wait returns only once
+ * every task settled, so none outlives the group. A parent signal, such as
+ * the client's cancellation of the request, cancels the group too
+ */
+export class TaskGroup {
+  private controller = new AbortController();
+  private tasks: Array<Promise<unknown>> = [];
+  private failure?: unknown;
+  private detach?: () => void;
+
+  constructor(parent: AbortSignal | undefined = currentSignal()) {
+    if (parent?.aborted) {
+      this.controller.abort(cancelledBy(parent));
+    } else if (parent) {
+      const abort = () => this.controller.abort(cancelledBy(parent));
+      parent.addEventListener('abort', abort, { once: true });
+      this.detach = () => parent.removeEventListener('abort', abort);
+    }
+  }
+
+  /**
+   * Signal the group's tasks run under
+   */
+  get signal(): AbortSignal {
+    return this.controller.signal;
+  }
+
+  /**
+   * Start a task under the group's signal
+   * Its result is its own; a failure also cancels the group and is thrown by wait
+   */
+  spawn<T>(task: () => Promise<T>): Promise<T> {
+    const running = withCancellation(this.signal, async () => {
+      throwIfCancelled(this.signal);
+      return task();
+    });
+    this.tasks.push(running.catch((err) => {
+      if (this.failure === undefined) {
+        this.failure = err;
+      }
+      this.cancel(new CancelledError(`another task of the call failed: ${err instanceof Error ? err.message : String(err)}`));
+    }));
+    return running;
+  }
+
+  /**
+   * Cancel every task of the group
+   */
+  cancel(reason: Error = new CancelledError()): void {
+    if (!this.signal.aborted) {
+      this.controller.abort(reason);
+    }
+  }
+
+  /**
+   * Wait for every task started so far, then throw the first failure, if any
+   * The group is cancelled once they settle, stopping work they left behind
+   */
+  async wait(): Promise<void> {
+    // Tasks may start others while the group waits
+    let settled = 0;
+    while (settled < this.tasks.length) {
+      const waiting = this.tasks.slice(settled);
+      settled = this.tasks.length;
+      await Promise.all(waiting);
+    }
+    this.cancel(new CancelledError(CANCEL_REASONS.finished));
+    this.detach?.();
+    if (this.failure !== undefined) {
+      throw this.failure;
+    }
+  }
+}
+
+/**
+ * Run a call's work in its own task group, cancelled by the parent signal,
+ * on failure, and when the work returns
+ */
+export async function runGroup<T>(parent: AbortSignal | undefined, task: () => Promise<T>): Promise<T> {
+  const group = new TaskGroup(parent);
+  const result = group.spawn(task);
+  await group.wait();
+  return result;
+}
@@ -0,0 +1,109 @@
+/**
+ * Tests for Dockerfile and compose file analysis
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { analyzeDockerFiles } from '../tools/docker';
+import { dockerFileKind, parseCompose, parseDockerfile } from './docker';
+
+const DOCKERFILE = [
+  'ARG GO_VERSION=1.22',
+  'FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build',
+  'WORKDIR /src',
+  'COPY go.mod go.sum ./',
+  'RUN go mod download && \\',
+  '    # cached between builds',
+  '    go build -o /out/app ./cmd/app',
+  'RUN <<EOF',
+  'echo "no FROM here"',
+  'EOF',
+  '',
+  'FROM gcr.io/distroless/base AS runtime',
+  'COPY --from=build /out/app /app',
+  'COPY ["config/app.yaml", "/etc/app/"]',
+  'EXPOSE 8080 9090/udp',
+  'ENTRYPOINT ["/app"]',
+  '',
+  'FROM runtime',
+  'ENV DEBUG=1',
+].join('\n');
+
+const COMPOSE = `services:
+  api:
+    build:
+      context: .
+      target: runtime
+    ports:
+      - "8080:8080"
+      - target: 9090
+        published: 19090
+    volumes:
+      - ./data:/data
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: postgres:16
+    expose: ["5432"]
+`;
+
+describe('Docker files', () => {
+  it('should recognise Dockerfiles and compose files', () => {
+    expect(dockerFileKind('build/Dockerfile.prod')).toBe('dockerfile');
+    expect(dockerFileKind('api.dockerfile')).toBe('dockerfile');
+    expect(dockerFileKind('docker-compose.override.yml')).toBe('compose');
+    expect(dockerFileKind('compose.yaml')).toBe('compose');
+    expect(dockerFileKind('config.yaml')).toBeUndefined();
+  });
+
+  it('should parse stages, base images, ports, and copies', () => {
+    const stages = parseDockerfile(DOCKERFILE);
+    expect(stages.map((stage) => [stage.index, stage.name, stage.line])).toEqual([[0, 'build', 2], [1, 'runtime', 12], [2, undefined, 18]]);
+    expect(stages[0]).toMatchObject({ baseImage: 'golang:${GO_VERSION}', resolvedImage: 'golang:1.22', platform: '$BUILDPLATFORM' });
+    expect(stages[0].instructions.map((instruction) => instruction.keyword)).toEqual(['FROM', 'WORKDIR', 'COPY', 'RUN', 'RUN']);
+    expect(stages[0].instructions[3].args).toBe('go mod download && go build -o /out/app ./cmd/app');
+    expect(stages[1].ports).toEqual(['8080', '9090/udp']);
+    expect(stages[1].copies).toEqual([
+      { line: 13, keyword: 'COPY', sources: ['/out/app'], destination: '/app', from: 'build' },
+      { line: 14, keyword: 'COPY', sources: ['config/app.yaml'], destination: '/etc/app/', from: undefined },
+    ]);
+    expect(stages[2].fromStage).toBe('runtime');
+  });
+
+  it('should parse compose services', () => {
+    const services = parseCompose(COMPOSE);
+    expect(services[0]).toEqual({
+      name: 'api',
+      line: 2,
+      image: undefined,
+      build: { context: '.', dockerfile: undefined, target: 'runtime' },
+      ports: ['8080:8080', '19090:9090'],
+      volumes: ['./data:/data'],
+      dependsOn: ['db'],
+    });
+    expect(services[1]).toMatchObject({ name: 'db', image: 'postgres:16', ports: ['5432 (internal)'] });
+  });
+
+  it('should summarize files and search within a stage', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'docker-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'Dockerfile'), DOCKERFILE);
+      fs.writeFileSync(path.join(workspace, 'docker-compose.yml'), COMPOSE);
+
+      const summary = await analyzeDockerFiles(workspace);
+      expect(summary).toContain('Stage 0 "build" L2: FROM golang:${GO_VERSION} (golang:1.22) [$BUILDPLATFORM]');
+      expect(summary).toContain('  L13: COPY /out/app → /app (from build)');
+      expect(summary).toContain('Stage 2 L18: FROM stage runtime');
+      expect(summary).toContain('api L2: build . (target runtime)\n  Ports: 8080:8080, 19090:9090');
+
+      const search = await analyzeDockerFiles(workspace, { stage: 'build', pattern: 'go build' });
+      expect(search).toContain('  L5: RUN go mod download && go build -o /out/app ./cmd/app');
+      expect(search).not.toContain('runtime');
+      expect(await analyzeDockerFiles(workspace, { stage: 'test' })).toBe('No build stage test in 2 file(s)');
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
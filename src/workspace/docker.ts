@@ -0,0 +1,300 @@
+/**
+ * Dockerfile and compose file analysis
+ * Reads the build stages of Dockerfiles (base image, exposed ports, copied
+ * paths, and every instruction) and the services of compose files (image or
+ * build, ports, volumes, dependencies), with line numbers
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { StructuredNode, parseYamlNodes } from '../search/structured.js';
+import { readFileText } from './overlay.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from './walker.js';
+
+const dockerLogger = createLogger(Component.TOOLS);
+
+/**
+ * A Dockerfile instruction
+ */
+export interface DockerInstruction {
+  line: number; // 1-indexed, where the instruction starts
+  keyword: string; // Upper case, e.g. RUN
+  args: string;
+}
+
+/**
+ * A COPY or ADD instruction
+ */
+export interface DockerCopy {
+  line: number;
+  keyword: 'COPY' | 'ADD';
+  sources: string[];
+  destination: string;
+  // Stage or image copied from (--from)
+  from?: string;
+}
+
+/**
+ * A build stage: one FROM and the instructions after it
+ */
+export interface DockerStage {
+  index: number; // 0-indexed, as docker build --target counts
+  name?: string;
+  line: number;
+  // Image as written, and with global ARG defaults substituted
+  baseImage: string;
+  resolvedImage: string;
+  // Earlier stage this one builds on
+  fromStage?: string;
+  platform?: string;
+  ports: string[];
+  copies: DockerCopy[];
+  instructions: DockerInstruction[];
+}
+
+/**
+ * A service in a compose file
+ */
+export interface ComposeService {
+  name: string;
+  line: number;
+  image?: string;
+  build?: { context: string; dockerfile?: string; target?: string };
+  ports: string[];
+  volumes: string[];
+  dependsOn: string[];
+}
+
+/**
+ * An analyzed Dockerfile or compose file
+ */
+export type DockerFileInfo =
+  | { kind: 'dockerfile'; filePath: string; stages: DockerStage[] }
+  | { kind: 'compose'; filePath: string; services: ComposeService[] };
+
+/**
+ * Kind of a Docker-related file, from its name
+ */
+export function dockerFileKind(filePath: string): 'dockerfile' | 'compose' | undefined {
+  const name = path.basename(filePath).toLowerCase();
+  if (/^(dockerfile|containerfile)(\..+)?$/.test(name) || name.endsWith('.dockerfile')) {
+    return 'dockerfile';
+  }
+  if (/^(docker-)?compose(\.[\w-]+)?\.ya?ml$/.test(name)) {
+    return 'compose';
+  }
+  return undefined;
+}
+
+/**
+ * Split a Dockerfile into instructions, joining continuation lines, dropping
+ * comments, and keeping heredoc bodies with the instruction that opens them
+ */
+export function dockerInstructions(content: string): DockerInstruction[] {
+  const lines = content.split('\n').map((line) => line.replace(/\r$/, ''));
+  // A "# escape=`" parser directive changes the continuation character
+  const directive = lines[0]?.match(/^#\s*escape\s*=\s*(\S)/i);
+  const escape = directive ? directive[1] : '\\';
+  const instructions: DockerInstruction[] = [];
+
+  for (let i = 0; i < lines.length; i++) {
+    const first = lines[i].trim();
+    if (first === '' || first.startsWith('#')) {
+      continue;
+    }
+    const start = i;
+    let text = first;
+    while (text.endsWith(escape) && i + 1 < lines.length) {
+      text = text.slice(0, -1).trimEnd();
+      const next = lines[++i].trim();
+      // Comment lines inside a continuation are removed
+      if (!next.startsWith('#') && next !== '') {
+        text += ' ' + next;
+      } else {
+        text += ' ' + escape;
+      }
+    }
+    text = text.replace(new RegExp(`\\s*${escape.replace(/[\\^$.*+?()[\]{}|]/g, '\\$&')}$`), '');
+    // RUN <<EOF ... EOF
+    for (const heredoc of text.matchAll(/<<-?(["']?)(\w+)\1/g)) {
+      const terminator = heredoc[2];
+      const body: string[] = [];
+      while (i + 1 < lines.length && lines[i + 1].trim() !== terminator) {
+        body.push(lines[++i]);
+      }
+      i++;
+      text += '\n' + body.join('\n');
+    }
+    const match = text.match(/^(\w+)\s*([\s\S]*)$/);
+    if (match) {
+      instructions.push({ line: start + 1, keyword: match[1].toUpperCase(), args: match[2].trim() });
+    }
+  }
+  return instructions;
+}
+
+/**
+ * Split instruction arguments into words, or read the JSON array form
+ */
+function instructionWords(args: string): string[] {
+  if (args.startsWith('[')) {
+    try {
+      const parsed = JSON.parse(args);
+      if (Array.isArray(parsed)) {
+        return parsed.map(String);
+      }
+    } catch (err) {
+      // Not JSON: shell form
+    }
+  }
+  return args.match(/"[^"]*"|'[^']*'|\S+/g)?.map((word) => word.replace(/^["']|["']$/g, '')) ?? [];
+}
+
+/**
+ * Substitute ${NAME}, ${NAME:-default}, and $NAME from ARG defaults
+ */
+function substituteArgs(text: string, args: Map<string, string>): string {
+  return text.replace(/\$\{(\w+)(?::?[-+]([^}]*))?\}|\$(\w+)/g, (whole, braced: string, fallback: string | undefined, bare: string) => {
+    const value = args.get(braced ?? bare);
+    return value ?? fallback ?? whole;
+  });
+}
+
+/**
+ * Parse the stages of a Dockerfile
+ */
+export function parseDockerfile(content: string): DockerStage[] {
+  const stages: DockerStage[] = [];
+  const globalArgs = new Map<string, string>();
+
+  for (const instruction of dockerInstructions(content)) {
+    const { keyword, args, line } = instruction;
+    if (keyword === 'FROM') {
+      const words = args.split(/\s+/);
+      const platform = words.find((word) => word.startsWith('--platform='))?.substring('--platform='.length);
+      const rest = words.filter((word) => !word.startsWith('--'));
+      const baseImage = rest[0] ?? '';
+      const name = rest[1]?.toUpperCase() === 'AS' ? rest[2] : undefined;
+      const resolvedImage = substituteArgs(baseImage, globalArgs);
+      stages.push({
+        index: stages.length,
+        name,
+        line,
+        baseImage,
+        resolvedImage,
+        fromStage: stages.find((stage) => stage.name !== undefined && stage.name.toLowerCase() === resolvedImage.toLowerCase())?.name,
+        platform,
+        ports: [],
+        copies: [],
+        instructions: [instruction],
+      });
+      continue;
+    }
+    const stage = stages[stages.length - 1];
+    if (!stage) {
+      // ARGs before the first FROM are in scope for FROM lines
+      if (keyword === 'ARG') {
+        const arg = args.match(/^(\w+)(?:=(.*))?$/);
+        if (arg && arg[2] !== undefined) {
+          globalArgs.set(arg[1], arg[2].replace(/^["']|["']$/g, ''));
+        }
+      }
+      continue;
+    }
+    stage.instructions.push(instruction);
+    if (keyword === 'EXPOSE') {
+      stage.ports.push(...instructionWords(args));
+    } else if (keyword === 'COPY' || keyword === 'ADD') {
+      // Heredoc sources (COPY <<EOF /dest) are content, not paths
+      const words = instructionWords(args.split('\n')[0]);
+      const from = words.find((word) => word.startsWith('--from='))?.substring('--from='.length);
+      const paths = words.filter((word) => !word.startsWith('--'));
+      if (paths.length >= 2) {
+        stage.copies.push({ line, keyword, sources: paths.slice(0, -1), destination: paths[paths.length - 1], from });
+      }
+    }
+  }
+  return stages;
+}
+
+/**
+ * Scalar text of a node, or undefined for collections
+ */
+function scalar(node: StructuredNode | undefined): string | undefined {
+  return node?.kind === 'scalar' ? node.value : undefined;
+}
+
+/**
+ * Value of a mapping key
+ */
+function entry(node: StructuredNode | undefined, key: string): StructuredNode | undefined {
+  return node?.kind === 'map' ? node.entries.find((e) => e.key === key)?.value : undefined;
+}
+
+/**
+ * Short form of a compose port or volume: strings as written, long forms joined
+ */
+function shortForm(node: StructuredNode, keys: string[]): string {
+  if (node.kind === 'scalar') {
+    return node.value;
+  }
+  return keys.map((key) => scalar(entry(node, key))).filter(Boolean).join(':');
+}
+
+/**
+ * Parse the services of a compose file
+ */
+export function parseCompose(content: string): ComposeService[] {
+  const root = parseYamlNodes(content)[0];
+  const services = entry(root, 'services');
+  if (!services || services.kind !== 'map') {
+    return [];
+  }
+  return services.entries.map((service) => {
+    const body = service.value;
+    const build = entry(body, 'build');
+    const ports = entry(body, 'ports');
+    const expose = entry(body, 'expose');
+    const volumes = entry(body, 'volumes');
+    const dependsOn = entry(body, 'depends_on');
+    return {
+      name: service.key,
+      line: service.keyPosition.line,
+      image: scalar(entry(body, 'image')),
+      build: build && (build.kind === 'scalar'
+        ? { context: build.value }
+        : { context: scalar(entry(build, 'context')) ?? '.', dockerfile: scalar(entry(build, 'dockerfile')), target: scalar(entry(build, 'target')) }),
+      ports: [
+        ...(ports?.kind === 'seq' ? ports.items.map((item) => shortForm(item, ['published', 'target'])) : []),
+        ...(expose?.kind === 'seq' ? expose.items.map((item) => `${shortForm(item, [])} (internal)`) : []),
+      ],
+      volumes: volumes?.kind === 'seq' ? volumes.items.map((item) => shortForm(item, ['source', 'target'])) : [],
+      // A list of names, or a mapping of name to condition
+      dependsOn: dependsOn?.kind === 'seq'
+        ? dependsOn.items.map((item) => scalar(item) ?? '').filter(Boolean)
+        : dependsOn?.kind === 'map' ? dependsOn.entries.map((e) => e.key) : [],
+    };
+  });
+}
+
+/**
+ * Find and analyze every Dockerfile and compose file in the workspace
+ */
+export async function findDockerFiles(workspaceDir: string, pathPrefix?: string): Promise<DockerFileInfo[]> {
+  const prefix = pathPrefix ? resolveWorkspacePath(workspaceDir, pathPrefix) : undefined;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: prefix }))
+    .filter((file) => dockerFileKind(file.relativePath) !== undefined);
+
+  const results: DockerFileInfo[] = [];
+  for (const file of files) {
+    try {
+      const content = await readFileText(file.absolutePath);
+      results.push(dockerFileKind(file.relativePath) === 'dockerfile'
+        ? { kind: 'dockerfile', filePath: file.relativePath, stages: parseDockerfile(content) }
+        : { kind: 'compose', filePath: file.relativePath, services: parseCompose(content) });
+    } catch (err) {
+      dockerLogger.debug('Could not analyze %s: %s', file.relativePath, (err as Error).message);
+    }
+  }
+  return results;
+}
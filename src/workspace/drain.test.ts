@@ -0,0 +1,60 @@
+/**
+ * Tests for call draining
+ */
+
+import { CallDrain, shutdownTimeoutFromEnv } from './drain';
+
+describe('Call draining', () => {
+  it('should read the timeout, defaulting to 10 seconds', () => {
+    expect(shutdownTimeoutFromEnv({})).toBe(10_000);
+    expect(shutdownTimeoutFromEnv({ SHUTDOWN_TIMEOUT_MS: '2500' })).toBe(2500);
+    expect(shutdownTimeoutFromEnv({ SHUTDOWN_TIMEOUT_MS: 'later' })).toBe(10_000);
+  });
+
+  it('should wait for running calls to finish', async () => {
+    const drain = new CallDrain();
+    let finished = false;
+    const call = drain.track(() => new Promise<string>((resolve) => setTimeout(() => {
+      finished = true;
+      resolve('done');
+    }, 20)));
+    expect(drain.size()).toBe(1);
+
+    expect(await drain.close(1000)).toBe(0);
+    expect(finished).toBe(true);
+    expect(drain.isClosed()).toBe(true);
+    expect(await call).toBe('done');
+  });
+
+  it('should cancel the calls still running at the deadline', async () => {
+    const drain = new CallDrain();
+    let callSignal!: AbortSignal;
+    const call = drain.track((signal) => new Promise<void>((resolve, reject) => {
+      callSignal = signal;
+      signal.addEventListener('abort', () => reject(signal.reason));
+    }));
+    drain.track(() => Promise.reject(new Error('failed'))).catch(() => undefined);
+
+    expect(await drain.close(10)).toBe(1);
+    expect(callSignal.aborted).toBe(true);
+    const err = await call.catch((e) => e);
+    expect(err.code).toBe('cancelled');
+    expect(err.message).toBe('Cancelled: the server is shutting down');
+  });
+
+  it('should pass on the parent signal', async () => {
+    const drain = new CallDrain();
+    const parent = new AbortController();
+    const call = drain.track((signal) => new Promise((resolve) => {
+      signal.addEventListener('abort', () => resolve(signal.reason));
+    }), parent.signal);
+    parent.abort('client');
+    expect(await call).toBe('client');
+
+    const early = new AbortController();
+    early.abort('client');
+    await drain.track(async (signal) => {
+      expect(signal.reason).toBe('client');
+    }, early.signal);
+  });
+});
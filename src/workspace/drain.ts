@@ -0,0 +1,85 @@
+/**
+ * Call draining - let running tool calls finish before the server stops
+ * On SIGTERM the server refuses new calls, waits up to SHUTDOWN_TIMEOUT_MS
+ * for the running ones, and only then stops the language server and saves
+ * the indexes; calls still running after that are cancelled
+ */
+
+import { CancelledError } from './cancellation.js';
+
+/**
+ * Time running calls get to finish, from SHUTDOWN_TIMEOUT_MS (default: 10000)
+ */
+export function shutdownTimeoutFromEnv(env: NodeJS.ProcessEnv = process.env): number {
+  const value = parseInt(env.SHUTDOWN_TIMEOUT_MS || '', 10);
+  return Number.isFinite(value) && value >= 0 ? value : 10_000;
+}
+
+/**
+ * Tracks running calls and waits for them on shutdown
+ */
+export class CallDrain {
+  private running = new Map<Promise<unknown>, AbortController>();
+  private closed = false;
+
+  /**
+   * Check if new calls are refused
+   */
+  isClosed(): boolean {
+    return this.closed;
+  }
+
+  /**
+   * Calls running now
+   */
+  size(): number {
+    return this.running.size;
+  }
+
+  /**
+   * Run a call, tracking it until it settles
+   * Its signal is aborted by the parent signal, such as the client's, and
+   * when the drain stops waiting for it
+   */
+  track<T>(call: (signal: AbortSignal) => Promise<T>, parent?: AbortSignal): Promise<T> {
+    const controller = new AbortController();
+    const abort = () => controller.abort(parent!.reason);
+    if (parent?.aborted) {
+      abort();
+    } else {
+      parent?.addEventListener('abort', abort, { once: true });
+    }
+    const promise = call(controller.signal);
+    this.running.set(promise, controller);
+    const settle = () => {
+      parent?.removeEventListener('abort', abort);
+      this.running.delete(promise);
+    };
+    promise.then(settle, settle);
+    return promise;
+  }
+
+  /**
+   * Refuse new calls and wait for the running ones, at most timeoutMs, then
+   * cancel those still running
+   * Returns the number of calls that were cancelled
+   */
+  async close(timeoutMs: number): Promise<number> {
+    this.closed = true;
+    if (this.running.size > 0) {
+      let timer: NodeJS.Timeout | undefined;
+      await Promise.race([
+        Promise.allSettled(Array.from(this.running.keys())),
+        new Promise<void>((resolve) => {
+          timer = setTimeout(resolve, timeoutMs);
+        }),
+      ]);
+      clearTimeout(timer);
+    }
+    const cancelled = this.running.size;
+    for (const controller of this.running.values()) {
+      controller.abort(new CancelledError('the server is shutting down'));
+    }
+    return cancelled;
+  }
+}
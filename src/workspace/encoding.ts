@@ -0,0 +1,125 @@
+/**
+ * Text encoding detection
+ * Files are decoded as UTF-16 when they start with a UTF-16 byte order mark,
+ * as Latin-1 when they are not valid UTF-8, and as UTF-8 otherwise. Line
+ * endings are normalized to \n so CRLF and CR-only files report the same
+ * line and column numbers as LF files
+ */
+
+import { isUtf8 } from 'buffer';
+import { ToolError } from './errors.js';
+
+/**
+ * Encodings recognized when reading workspace files
+ */
+export type TextEncoding = 'utf-8' | 'utf-16le' | 'utf-16be' | 'latin1';
+
+/**
+ * Detect the encoding of file content and the length of its byte order mark
+ */
+export function detectEncoding(data: Buffer): { encoding: TextEncoding; bomLength: number } {
+  if (data.length >= 2 && data[0] === 0xff && data[1] === 0xfe) {
+    return { encoding: 'utf-16le', bomLength: 2 };
+  }
+  if (data.length >= 2 && data[0] === 0xfe && data[1] === 0xff) {
+    return { encoding: 'utf-16be', bomLength: 2 };
+  }
+  if (data.length >= 3 && data[0] === 0xef && data[1] === 0xbb && data[2] === 0xbf) {
+    return { encoding: 'utf-8', bomLength: 3 };
+  }
+  return { encoding: isUtf8(data) ? 'utf-8' : 'latin1', bomLength: 0 };
+}
+
+/**
+ * Check if content starts with a UTF-16 byte order mark
+ * UTF-16 text is full of NUL bytes, so binary detection must rule it out first
+ */
+export function hasUtf16Bom(data: Buffer): boolean {
+  const { encoding } = detectEncoding(data.subarray(0, 3));
+  return encoding === 'utf-16le' || encoding === 'utf-16be';
+}
+
+/**
+ * Convert CRLF and lone CR line endings to LF
+ */
+export function normalizeLineEndings(text: string): string {
+  return text.includes('\r') ? text.replace(/\r\n?/g, '\n') : text;
+}
+
+/**
+ * Decode file content without touching its line endings
+ */
+function decodeBody(data: Buffer): string {
+  const { encoding, bomLength } = detectEncoding(data);
+  const body = data.subarray(bomLength);
+  switch (encoding) {
+    case 'utf-16le':
+      return body.toString('utf16le');
+    case 'utf-16be':
+      // Node has no big-endian decoder; swap to little-endian first
+      return Buffer.from(body.subarray(0, body.length - (body.length % 2))).swap16().toString('utf16le');
+    case 'latin1':
+      return body.toString('latin1');
+    default:
+      return body.toString('utf8');
+  }
+}
+
+/**
+ * Decode file content to a string with LF line endings
+ */
+export function decodeText(data: Buffer): string {
+  return normalizeLineEndings(decodeBody(data));
+}
+
+/**
+ * How a file's text is stored, so text decoded from it can be written back the same way
+ */
+export interface TextFormat {
+  encoding: TextEncoding;
+  bom: boolean;
+  // The file's first line ending; LF for files of one line
+  lineEnding: '\n' | '\r\n' | '\r';
+}
+
+/**
+ * Detect how file content is stored
+ */
+export function detectTextFormat(data: Buffer): TextFormat {
+  const { encoding, bomLength } = detectEncoding(data);
+  const lineEnding = /\r\n|\r|\n/.exec(decodeBody(data))?.[0] ?? '\n';
+  return { encoding, bom: bomLength > 0, lineEnding: lineEnding as TextFormat['lineEnding'] };
+}
+
+const BOMS: Record<TextEncoding, number[]> = {
+  'utf-8': [0xef, 0xbb, 0xbf],
+  'utf-16le': [0xff, 0xfe],
+  'utf-16be': [0xfe, 0xff],
+  latin1: [],
+};
+
+/**
+ * Encode text with LF line endings as a file stored in the given format
+ * Throws when the text has characters Latin-1 cannot hold
+ */
+export function encodeText(text: string, format: TextFormat): Buffer {
+  const body = format.lineEnding === '\n' ? text : text.replace(/\n/g, format.lineEnding);
+  let data: Buffer;
+  switch (format.encoding) {
+    case 'utf-16le':
+      data = Buffer.from(body, 'utf16le');
+      break;
+    case 'utf-16be':
+      data = Buffer.from(body, 'utf16le').swap16();
+      break;
+    case 'latin1':
+      if (/[^\u0000-\u00ff]/.test(body)) {
+        throw new ToolError('invalid-argument', 'the text has characters Latin-1 cannot encode');
+      }
+      data = Buffer.from(body, 'latin1');
+      break;
+    default:
+      data = Buffer.from(body, 'utf8');
+  }
+  return format.bom ? Buffer.concat([Buffer.from(BOMS[format.encoding]), data]) : data;
+}
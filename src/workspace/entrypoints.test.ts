@@ -0,0 +1,77 @@
+/**
+ * Tests for entry point discovery
+ */
+
+import { scanEntryPoints } from './entrypoints';
+
+function names(relativePath: string, content: string): string[] {
+  return scanEntryPoints(relativePath, content).map((entry) =>
+    `${entry.kind} ${entry.name}${entry.detail ? ` (${entry.detail})` : ''} :${entry.line}`);
+}
+
+describe('scanEntryPoints', () => {
+  it('should find Go mains, routes, and cobra commands', () => {
+    const source = [
+      'package main',
+      '',
+      'var serveCmd = &cobra.Command{',
+      '\tUse:   "serve [flags]",',
+      '}',
+      '',
+      'func main() {',
+      '\thttp.HandleFunc("GET /users/{id}", getUser)',
+      '\tr.POST("/login", login)',
+      '}',
+    ].join('\n');
+    expect(names('cmd/app/main.go', source)).toEqual([
+      'command serve (cobra) :4',
+      'main main (package main) :7',
+      'route /users/{id} (GET) :8',
+      'route /login (POST) :9',
+    ]);
+    expect(names('lib/lib.go', 'package lib\n\nfunc main() {}\n')).toEqual([]);
+  });
+
+  it('should find Python mains, decorated routes, and commands', () => {
+    const source = [
+      'import click',
+      '',
+      '@app.get("/items/{id}")',
+      'async def read_item(id):',
+      '    pass',
+      '',
+      '@cli.command()',
+      'def sync_all():',
+      '    pass',
+      '',
+      'if __name__ == "__main__":',
+      '    cli()',
+    ].join('\n');
+    expect(names('app.py', source)).toEqual([
+      'route /items/{id} (GET read_item) :3',
+      'command sync-all (click/typer) :7',
+      'main __main__ :11',
+    ]);
+    expect(names('shop/urls.py', 'urlpatterns = [\n    path("cart/", views.cart),\n]\n')).toEqual(['route /cart/ (views.cart) :2']);
+  });
+
+  it('should find Express routes and tests only in test files', () => {
+    expect(names('src/server.js', "app.get('/health', (req, res) => res.send('ok'));\nit('is not a test here', () => {});\n"))
+      .toEqual(['route /health (GET) :1']);
+    expect(names('src/server.test.ts', "describe('server', () => {\n  it('responds', () => {});\n  test.skip('later', () => {});\n});\n"))
+      .toEqual(['test responds :2', 'test later :3']);
+    expect(names('pkg/x_test.go', 'package x\n\nfunc TestParse(t *testing.T) {}\nfunc BenchmarkParse(b *testing.B) {}\nfunc helper() {}\n'))
+      .toEqual(['test TestParse :3', 'test BenchmarkParse :4']);
+  });
+
+  it('should find Rust tests and routes from their attributes', () => {
+    const source = '#[get("/")]\nasync fn index() -> String {}\n\n#[test]\nfn parses() {}\n\nfn main() {}\n';
+    expect(names('src/main.rs', source)).toEqual(['route / (GET index) :1', 'test parses :4', 'main main :7']);
+  });
+
+  it('should read bin and main from package.json', () => {
+    expect(names('package.json', JSON.stringify({ name: 'tool', main: 'dist/index.js', bin: { tool: 'dist/cli.js' } })))
+      .toEqual(['main tool (bin → dist/cli.js) :0', 'main tool (main → dist/index.js) :0']);
+    expect(names('package.json', '{ not json')).toEqual([]);
+  });
+});
@@ -0,0 +1,212 @@
+/**
+ * Entry point discovery
+ * Finds where programs start and where requests come in: main functions and
+ * packages, HTTP route registrations, CLI command definitions, and tests.
+ * Recognized by the conventions of common languages and frameworks (net/http,
+ * gin, echo, chi, Express, Flask, FastAPI, Django, Spring, actix, axum,
+ * cobra, urfave/cli, click, typer, argparse, commander, yargs), line by line
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { detectLanguageId, isTestFile } from './language.js';
+import { readFileText } from './overlay.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from './walker.js';
+
+const entryLogger = createLogger(Component.TOOLS);
+
+/**
+ * Kinds of entry point
+ */
+export type EntryPointKind = 'main' | 'route' | 'command' | 'test';
+
+/**
+ * An entry point
+ */
+export interface EntryPoint {
+  kind: EntryPointKind;
+  filePath: string;
+  line: number; // 1-indexed; 0 for manifest entries without a line
+  name: string;
+  // HTTP method, framework, or what the entry point runs
+  detail?: string;
+}
+
+/**
+ * A rule recognizing entry points on a line
+ */
+interface EntryRule {
+  kind: EntryPointKind;
+  languages: string[];
+  pattern: RegExp;
+  // Name and detail from the match and the lines after it; undefined skips the match
+  read: (match: RegExpMatchArray, lines: string[], index: number) => { name: string; detail?: string } | undefined;
+  // Only files whose content passes this check
+  when?: (content: string, relativePath: string) => boolean;
+}
+
+const JS = ['javascript', 'javascriptreact', 'typescript', 'typescriptreact'];
+
+/**
+ * Name of the first function declared within a few lines, for decorators and attributes
+ */
+function nextFunction(lines: string[], index: number, pattern: RegExp): string | undefined {
+  for (let i = index + 1; i < Math.min(lines.length, index + 4); i++) {
+    const match = lines[i].match(pattern);
+    if (match) {
+      return match[1];
+    }
+  }
+  return undefined;
+}
+
+const PY_DEF = /^\s*(?:async\s+)?def\s+(\w+)/;
+const RUST_FN = /^\s*(?:pub\s+)?(?:async\s+)?fn\s+(\w+)/;
+const JAVA_METHOD = /\bvoid\s+(\w+)\s*\(|\bfun\s+`?([^`(]+)`?\s*\(/;
+
+const RULES: EntryRule[] = [
+  // Main functions
+  { kind: 'main', languages: ['go'], pattern: /^func main\(\)/, when: (content) => /^package main\b/m.test(content),
+    read: () => ({ name: 'main', detail: 'package main' }) },
+  { kind: 'main', languages: ['python'], pattern: /^if\s+__name__\s*==\s*['"]__main__['"]\s*:/,
+    read: () => ({ name: '__main__' }) },
+  { kind: 'main', languages: ['rust'], pattern: /^\s*(?:pub\s+)?(?:async\s+)?fn\s+main\s*\(/,
+    read: () => ({ name: 'main' }) },
+  { kind: 'main', languages: ['java', 'csharp'], pattern: /\bstatic\s+(?:async\s+\w+\s+|void\s+|int\s+)(?:main|Main)\s*\(/,
+    read: () => ({ name: 'main' }) },
+  { kind: 'main', languages: ['kotlin'], pattern: /^fun\s+main\s*\(/, read: () => ({ name: 'main' }) },
+  { kind: 'main', languages: ['c', 'cpp'], pattern: /^\s*int\s+main\s*\(/, read: () => ({ name: 'main' }) },
+  { kind: 'main', languages: JS, pattern: /\brequire\.main\s*===\s*module\b/, read: () => ({ name: 'require.main' }) },
+
+  // HTTP routes
+  { kind: 'route', languages: ['go'], pattern: /\b\w+\.(HandleFunc|Handle)\(\s*"([^"]+)"/,
+    read: (m) => {
+      // Go 1.22 patterns may start with a method: "GET /users/{id}"
+      const method = m[2].match(/^([A-Z]+)\s+(.*)$/);
+      return method ? { name: method[2], detail: method[1] } : { name: m[2] };
+    } },
+  { kind: 'route', languages: ['go'], pattern: /\b\w+\.(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|Any|Get|Post|Put|Patch|Delete|Head|Options)\(\s*"(\/[^"]*)"/,
+    read: (m) => ({ name: m[2], detail: m[1].toUpperCase() }) },
+  { kind: 'route', languages: JS, pattern: /\b\w+\.(get|post|put|patch|delete|head|options|all)\(\s*['"`](\/[^'"`]*)['"`]\s*,/,
+    read: (m) => ({ name: m[2], detail: m[1].toUpperCase() }) },
+  { kind: 'route', languages: ['python'], pattern: /^\s*@\w+\.(route|get|post|put|patch|delete|api_route|websocket)\(\s*['"]([^'"]+)['"]/,
+    read: (m, lines, i) => ({ name: m[2], detail: [m[1] === 'route' || m[1] === 'api_route' ? undefined : m[1].toUpperCase(), nextFunction(lines, i, PY_DEF)].filter(Boolean).join(' ') || undefined }) },
+  { kind: 'route', languages: ['python'], pattern: /\b(?:re_)?path\(\s*r?['"]([^'"]*)['"]\s*,\s*([\w.]+)/,
+    when: (_content, relativePath) => path.basename(relativePath) === 'urls.py',
+    read: (m) => ({ name: `/${m[1]}`, detail: m[2] }) },
+  { kind: 'route', languages: ['java', 'kotlin'], pattern: /@(Get|Post|Put|Patch|Delete|Request)Mapping\(\s*(?:(?:value|path)\s*=\s*)?[[{]?\s*"([^"]*)"/,
+    read: (m) => ({ name: m[2], detail: m[1] === 'Request' ? undefined : m[1].toUpperCase() }) },
+  { kind: 'route', languages: ['rust'], pattern: /#\[(get|post|put|patch|delete)\(\s*"([^"]+)"/,
+    read: (m, lines, i) => ({ name: m[2], detail: [m[1].toUpperCase(), nextFunction(lines, i, RUST_FN)].filter(Boolean).join(' ') }) },
+  { kind: 'route', languages: ['rust'], pattern: /\.route\(\s*"(\/[^"]*)"\s*,\s*(get|post|put|patch|delete)\(/,
+    read: (m) => ({ name: m[1], detail: m[2].toUpperCase() }) },
+
+  // CLI commands
+  { kind: 'command', languages: ['go'], pattern: /^\s*Use:\s*"([^"\s]+)/, when: (content) => content.includes('cobra.Command'),
+    read: (m) => ({ name: m[1], detail: 'cobra' }) },
+  { kind: 'command', languages: ['go'], pattern: /^\s*Name:\s*"([^"]+)"/, when: (content) => /\bcli\.Command\b/.test(content),
+    read: (m) => ({ name: m[1], detail: 'urfave/cli' }) },
+  { kind: 'command', languages: ['python'], pattern: /^\s*@(?:\w+\.)*command\((?:\s*(?:name\s*=\s*)?['"]([^'"]+)['"])?/,
+    read: (m, lines, i) => {
+      const name = m[1] ?? nextFunction(lines, i, PY_DEF)?.replace(/_/g, '-');
+      return name ? { name, detail: 'click/typer' } : undefined;
+    } },
+  { kind: 'command', languages: ['python'], pattern: /\.add_parser\(\s*['"]([^'"]+)['"]/,
+    read: (m) => ({ name: m[1], detail: 'argparse' }) },
+  { kind: 'command', languages: JS, pattern: /\.command\(\s*['"`]([^'"`\s]+)/,
+    when: (content) => /\b(commander|yargs)\b/.test(content),
+    read: (m) => ({ name: m[1], detail: /\byargs\b/.test(m.input ?? '') ? 'yargs' : 'commander' }) },
+
+  // Tests
+  { kind: 'test', languages: ['go'], pattern: /^func ((?:Test|Benchmark|Fuzz|Example)\w*)\(/,
+    when: (_content, relativePath) => relativePath.endsWith('_test.go'), read: (m) => ({ name: m[1] }) },
+  { kind: 'test', languages: ['python'], pattern: /^\s*(?:async\s+)?def (test_?\w*)\s*\(/,
+    when: (_content, relativePath) => isTestFile(relativePath), read: (m) => ({ name: m[1] }) },
+  { kind: 'test', languages: JS, pattern: /^\s*(?:it|test)(?:\.(?:only|skip|each\(.*?\)))?\(\s*(['"`])(.*?)\1/,
+    when: (_content, relativePath) => isTestFile(relativePath), read: (m) => ({ name: m[2] }) },
+  { kind: 'test', languages: ['rust'], pattern: /^\s*#\[(?:tokio::)?test\]/,
+    read: (_m, lines, i) => {
+      const name = nextFunction(lines, i, RUST_FN);
+      return name ? { name } : undefined;
+    } },
+  { kind: 'test', languages: ['java', 'kotlin'], pattern: /^\s*@(?:Test|ParameterizedTest)\b/,
+    read: (_m, lines, i) => {
+      for (let j = i + 1; j < Math.min(lines.length, i + 4); j++) {
+        const match = lines[j].match(JAVA_METHOD);
+        if (match) {
+          return { name: (match[1] ?? match[2]).trim() };
+        }
+      }
+      return undefined;
+    } },
+];
+
+/**
+ * Entry points declared in a package.json: bin commands and the main module
+ */
+export function packageEntryPoints(relativePath: string, content: string): EntryPoint[] {
+  let manifest: { bin?: string | Record<string, string>; main?: string; name?: string };
+  try {
+    manifest = JSON.parse(content);
+  } catch (err) {
+    return [];
+  }
+  const entries: EntryPoint[] = [];
+  if (typeof manifest.bin === 'string') {
+    entries.push({ kind: 'main', filePath: relativePath, line: 0, name: manifest.name ?? 'bin', detail: `bin → ${manifest.bin}` });
+  } else if (manifest.bin && typeof manifest.bin === 'object') {
+    for (const [name, target] of Object.entries(manifest.bin)) {
+      entries.push({ kind: 'main', filePath: relativePath, line: 0, name, detail: `bin → ${target}` });
+    }
+  }
+  if (typeof manifest.main === 'string') {
+    entries.push({ kind: 'main', filePath: relativePath, line: 0, name: manifest.name ?? 'main', detail: `main → ${manifest.main}` });
+  }
+  return entries;
+}
+
+/**
+ * Find the entry points of one file
+ */
+export function scanEntryPoints(relativePath: string, content: string): EntryPoint[] {
+  if (path.basename(relativePath) === 'package.json') {
+    return packageEntryPoints(relativePath, content);
+  }
+  const languageId = detectLanguageId(relativePath);
+  const rules = RULES.filter((rule) => rule.languages.includes(languageId) && (!rule.when || rule.when(content, relativePath)));
+  if (rules.length === 0) {
+    return [];
+  }
+  const lines = content.split('\n');
+  const entries: EntryPoint[] = [];
+  for (let i = 0; i < lines.length; i++) {
+    for (const rule of rules) {
+      const match = lines[i].match(rule.pattern);
+      const found = match && rule.read(match, lines, i);
+      if (found) {
+        entries.push({ kind: rule.kind, filePath: relativePath, line: i + 1, ...found });
+        break;
+      }
+    }
+  }
+  return entries;
+}
+
+/**
+ * Find the entry points of every source file and package.json in the workspace
+ */
+export async function findEntryPoints(workspaceDir: string, pathPrefix?: string): Promise<EntryPoint[]> {
+  const prefix = pathPrefix ? resolveWorkspacePath(workspaceDir, pathPrefix) : undefined;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: prefix }))
+    .filter((file) => path.basename(file.relativePath) === 'package.json' || detectLanguageId(file.relativePath) !== 'plaintext');
+
+  const entries: EntryPoint[] = [];
+  for (const file of files) {
+    try {
+      entries.push(...scanEntryPoints(file.relativePath, await readFileText(file.absolutePath)));
+    } catch (err) {
+      entryLogger.debug('Could not scan %s: %s', file.relativePath, (err as Error).message);
+    }
+  }
+  return entries;
+}
@@ -0,0 +1,41 @@
+/**
+ * Tests for tool error codes
+ */
+
+import { buildMatcher } from '../search/lexical';
+import { parseQuery } from '../search/query';
+import { replaceText } from '../tools/replace';
+import { ToolError, toToolError, toolErrorCode } from './errors';
+import { resolveWorkspacePath } from './walker';
+
+describe('Tool errors', () => {
+  const thrown = (fn: () => unknown): unknown => {
+    try {
+      fn();
+    } catch (err) {
+      return err;
+    }
+    throw new Error('expected a throw');
+  };
+
+  it('should serialize as an error code and message', () => {
+    expect(JSON.stringify(new ToolError('timeout', 'embedding request timed out'))).toBe(
+      '{"error":"timeout","message":"embedding request timed out"}');
+  });
+
+  it('should classify errors thrown by the search and path helpers', () => {
+    expect(toolErrorCode(thrown(() => buildMatcher('(unclosed', { regex: true })))).toBe('pattern-invalid');
+    expect(toolErrorCode(thrown(() => resolveWorkspacePath('/srv/app', '../../etc/passwd')))).toBe('path-outside-root');
+  });
+
+  it('should carry the code given where the failure is detected', async () => {
+    expect(toolErrorCode(thrown(() => parseQuery('lang:cobolish load')))).toBe('pattern-invalid');
+    expect(toolErrorCode(await replaceText('/nowhere', '', 'x').catch((e) => e))).toBe('invalid-argument');
+  });
+
+  it('should take any other error for an internal one, whatever its message', () => {
+    expect(toolErrorCode(new Error('filePath is required'))).toBe('internal');
+    expect(toToolError(new Error('https://api.example.com timed out after 30000ms')).code).toBe('internal');
+    expect(toToolError('boom').toJSON()).toEqual({ error: 'internal', message: 'boom' });
+  });
+});
@@ -0,0 +1,71 @@
+/**
+ * Tool errors - machine-readable codes for failed tool calls
+ * A failed call returns {"error": code, "message"} as its result, so a client
+ * agent can branch on the kind of failure (fix the pattern, retry later, fall
+ * back to text search) instead of parsing the message
+ */
+
+import { PathOutsideWorkspaceError } from './paths.js';
+
+/**
+ * Kinds of tool failure
+ */
+export const TOOL_ERROR_CODES = [
+  'invalid-argument', // A parameter is missing or has an unusable value
+  'pattern-invalid', // The search pattern, query, or expression does not parse
+  'path-outside-root', // A path leads out of the workspace
+  'not-found', // A file, symbol, revision, workspace, or snapshot the call names does not exist
+  'lsp-unavailable', // The language server is not running
+  'workspace-not-indexed', // The index the tool needs is not built or not enabled
+  'timeout', // The call or a request it made ran out of time
+  'budget-exceeded', // The call or its session spent its file, byte, or language server request budget
+  'disabled', // The tool is turned off by the configuration or read-only mode
+  'shutting-down', // The server is stopping and takes no new calls
+  'cancelled', // The client cancelled the call before it finished
+  'unsupported', // The workspace, file type, or Node version does not support the call
+  'internal', // Anything else
+] as const;
+
+export type ToolErrorCode = typeof TOOL_ERROR_CODES[number];
+
+/**
+ * A failure of known kind
+ */
+export class ToolError extends Error {
+  constructor(public readonly code: ToolErrorCode, message: string) {
+    super(message);
+    this.name = 'ToolError';
+  }
+
+  /**
+   * Machine-readable form, for the tool result
+   */
+  toJSON(): Record<string, unknown> {
+    return { error: this.code, message: this.message };
+  }
+}
+
+/**
+ * The kind of failure an error stands for
+ * Failures of known kind are thrown as ToolErrors where they are detected;
+ * any other error is internal
+ */
+export function toolErrorCode(err: unknown): ToolErrorCode {
+  if (err instanceof ToolError) {
+    return err.code;
+  }
+  if (err instanceof PathOutsideWorkspaceError) {
+    return 'path-outside-root';
+  }
+  return 'internal';
+}
+
+/**
+ * Error of known kind for any thrown value
+ */
+export function toToolError(err: unknown): ToolError {
+  if (err instanceof ToolError) {
+    return err;
+  }
+  return new ToolError(toolErrorCode(err), err instanceof Error ? err.message : String(err));
+}
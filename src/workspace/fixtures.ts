@@ -0,0 +1,73 @@
+/**
+ * Test fixture classification
+ * Fixture inputs, snapshots, and golden files hold copies of code and
+ * identifiers that drown out real definitions in searches. search_code leaves
+ * them out unless asked for them with scope: fixtures or scope: all
+ */
+
+import { matchesGlob } from './glob.js';
+import { ToolError } from './errors.js';
+
+/**
+ * Which files a search covers: code without fixtures, only fixtures, or both;
+ * comments covers the same files as code, matching only in comments and docstrings
+ */
+export type SearchScope = 'code' | 'fixtures' | 'all' | 'comments';
+
+export const SEARCH_SCOPES: SearchScope[] = ['code', 'fixtures', 'all', 'comments'];
+
+/**
+ * Fixture locations by the conventions of each ecosystem
+ */
+const DEFAULT_FIXTURE_PATTERNS = [
+  '**/testdata/**', // Go: ignored by the go tool
+  '*.golden', // Go and others: expected output files
+  '**/__snapshots__/**', // Jest and Vitest
+  '**/__fixtures__/**', // JavaScript
+  '*.snap', // Jest, insta (Rust), syrupy (Python)
+  '**/test/fixtures/**', // Rails, Mocha
+  '**/tests/fixtures/**', // Python, Rust
+  '**/spec/fixtures/**', // RSpec
+  '**/src/test/resources/**', // Maven and Gradle
+];
+
+/**
+ * Fixture globs: the defaults, plus WORKSPACE_FIXTURE_PATTERNS entries, less
+ * the defaults it negates ("!*.snap")
+ */
+export function fixturePatterns(env: NodeJS.ProcessEnv = process.env): string[] {
+  const entries = (env.WORKSPACE_FIXTURE_PATTERNS || '').split(',').map((entry) => entry.trim()).filter(Boolean);
+  const negated = new Set(entries.filter((entry) => entry.startsWith('!')).map((entry) => entry.substring(1)));
+  const added = entries.filter((entry) => !entry.startsWith('!'));
+  return [...new Set([...DEFAULT_FIXTURE_PATTERNS, ...added])].filter((pattern) => !negated.has(pattern));
+}
+
+/**
+ * Check if a workspace-relative path is a test fixture
+ */
+export function isFixturePath(relativePath: string, patterns: string[] = fixturePatterns()): boolean {
+  return patterns.length > 0 && matchesGlob(relativePath, patterns);
+}
+
+/**
+ * Check if a file belongs to a search scope
+ */
+export function inSearchScope(relativePath: string, scope: SearchScope, patterns: string[] = fixturePatterns()): boolean {
+  if (scope === 'all') {
+    return true;
+  }
+  return isFixturePath(relativePath, patterns) === (scope === 'fixtures');
+}
+
+/**
+ * Validate a scope argument
+ */
+export function parseSearchScope(value: unknown): SearchScope | undefined {
+  if (value === undefined) {
+    return undefined;
+  }
+  if (!SEARCH_SCOPES.includes(value as SearchScope)) {
+    throw new ToolError('invalid-argument', `Unknown scope "${value}"; use ${SEARCH_SCOPES.join(', ')}`);
+  }
+  return value as SearchScope;
+}
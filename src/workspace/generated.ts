@@ -0,0 +1,121 @@
+/**
+ * Generated file detection
+ * Honors linguist-generated in .gitattributes and recognizes the headers
+ * and file names that code generators leave behind
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { matchesGlob } from './glob.js';
+
+/**
+ * Bytes at the start of a file searched for a generated-code header
+ */
+const HEADER_LENGTH = 1024;
+
+/**
+ * Headers written by code generators
+ */
+const GENERATED_HEADERS = [
+  /Code generated .* DO NOT EDIT\./, // Go convention (protoc-gen-go, mockgen, stringer)
+  /@generated\b/, // Facebook/Meta tooling, Relay, Buck
+  /Generated by the protocol buffer compiler/i,
+  /<auto-generated[\s>]/i, // .NET
+  /\b(?:This|The) (?:file|code) (?:was|is) (?:auto(?:matically|-)?)?generated\b/i,
+  /\bAUTO-GENERATED FILE\b/i,
+  /\bDO NOT EDIT\b.*\bgenerated\b|\bgenerated\b.*\bDO NOT EDIT\b/i,
+];
+
+/**
+ * File names that are generated regardless of content
+ */
+const GENERATED_FILE_GLOBS = [
+  '*.pb.go', '*.pb.gw.go', '*_pb2.py', '*_pb2_grpc.py', '*.pb.cc', '*.pb.h', '*_pb.js', '*_pb.d.ts',
+  '*.g.dart', '*.freezed.dart', '*.designer.cs', '*.g.cs',
+  '*.min.js', '*.min.css', '*.bundle.js',
+  'package-lock.json', 'yarn.lock', 'pnpm-lock.yaml', 'Cargo.lock', 'go.sum', 'poetry.lock',
+];
+
+/**
+ * A linguist-generated rule from .gitattributes
+ */
+interface GeneratedRule {
+  pattern: string;
+  generated: boolean;
+}
+
+/**
+ * Parse linguist-generated rules from .gitattributes content
+ */
+export function parseGeneratedAttributes(content: string): GeneratedRule[] {
+  const rules: GeneratedRule[] = [];
+  for (const rawLine of content.split(/\r?\n/)) {
+    const line = rawLine.trim();
+    if (!line || line.startsWith('#')) {
+      continue;
+    }
+    const [pattern, ...attributes] = line.split(/\s+/);
+    for (const attribute of attributes) {
+      if (attribute === 'linguist-generated' || attribute === 'linguist-generated=true') {
+        rules.push({ pattern, generated: true });
+      } else if (attribute === '-linguist-generated' || attribute === 'linguist-generated=false') {
+        rules.push({ pattern, generated: false });
+      }
+    }
+  }
+  return rules;
+}
+
+/**
+ * Check if content starts with a generated-code header
+ */
+export function hasGeneratedHeader(content: string): boolean {
+  const header = content.substring(0, HEADER_LENGTH);
+  return GENERATED_HEADERS.some((re) => re.test(header));
+}
+
+/**
+ * Decides whether workspace files are generated
+ */
+export class GeneratedFileDetector {
+  private rules: GeneratedRule[];
+
+  constructor(readonly workspaceDir: string) {
+    let attributes = '';
+    try {
+      attributes = fs.readFileSync(path.join(workspaceDir, '.gitattributes'), 'utf8');
+    } catch {
+      // No .gitattributes
+    }
+    this.rules = parseGeneratedAttributes(attributes);
+  }
+
+  /**
+   * Generated status from .gitattributes, or undefined when no rule matches
+   * The last matching rule wins, as in git
+   */
+  attributeFor(relativePath: string): boolean | undefined {
+    const normalized = relativePath.replace(/\\/g, '/');
+    let generated: boolean | undefined;
+    for (const rule of this.rules) {
+      // A leading slash anchors the pattern to the workspace root
+      const pattern = rule.pattern.replace(/^\//, '');
+      if (matchesGlob(normalized, pattern) || matchesGlob(normalized, `${pattern.replace(/\/$/, '')}/**`)) {
+        generated = rule.generated;
+      }
+    }
+    return generated;
+  }
+
+  /**
+   * Check if a file is generated, by attribute, name, or header
+   * An explicit -linguist-generated overrides the heuristics
+   */
+  isGenerated(relativePath: string, content: string): boolean {
+    const attribute = this.attributeFor(relativePath);
+    if (attribute !== undefined) {
+      return attribute;
+    }
+    return matchesGlob(relativePath, GENERATED_FILE_GLOBS) || hasGeneratedHeader(content);
+  }
+}
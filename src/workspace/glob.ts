@@ -0,0 +1,106 @@
+/**
+ * Glob matching for workspace-relative paths
+ * Supports **, *, ?, {a,b} alternatives and [...] character classes
+ */
+
+/**
+ * Positions of the braces that open or close a {a,b} group; unmatched ones are literal
+ */
+function groupBraces(glob: string): Set<number> {
+  const matched = new Set<number>();
+  const open: number[] = [];
+  for (let i = 0; i < glob.length; i++) {
+    if (glob[i] === '[' && glob.indexOf(']', i + 1) !== -1) {
+      i = glob.indexOf(']', i + 1);
+    } else if (glob[i] === '{') {
+      open.push(i);
+    } else if (glob[i] === '}' && open.length > 0) {
+      matched.add(open.pop()!).add(i);
+    }
+  }
+  return matched;
+}
+
+/**
+ * Convert a glob pattern to a regular expression matching a whole path
+ * Braces without a partner match themselves
+ */
+export function globToRegExp(glob: string): RegExp {
+  let source = '';
+  // Alternatives may nest, as in {a,{b,c}}
+  let depth = 0;
+  const braces = groupBraces(glob);
+
+  for (let i = 0; i < glob.length; i++) {
+    const ch = glob[i];
+    switch (ch) {
+      case '*':
+        if (glob[i + 1] === '*') {
+          // "**/" matches zero or more directories; a trailing "**" matches everything
+          if (glob[i + 2] === '/') {
+            source += '(?:.*/)?';
+            i += 2;
+          } else {
+            source += '.*';
+            i++;
+          }
+        } else {
+          source += '[^/]*';
+        }
+        break;
+      case '?':
+        source += '[^/]';
+        break;
+      case '{':
+        if (braces.has(i)) {
+          depth++;
+          source += '(?:';
+        } else {
+          source += '\\{';
+        }
+        break;
+      case '}':
+        if (braces.has(i)) {
+          depth--;
+          source += ')';
+        } else {
+          source += '\\}';
+        }
+        break;
+      case ',':
+        source += depth > 0 ? '|' : ',';
+        break;
+      case '[': {
+        const end = glob.indexOf(']', i + 1);
+        if (end === -1) {
+          source += '\\[';
+        } else {
+          source += '[' + glob.substring(i + 1, end).replace(/^!/, '^').replace(/\\/g, '\\\\') + ']';
+          i = end;
+        }
+        break;
+      }
+      default:
+        source += ch.replace(/[.+^$()|\\]/g, '\\$&');
+    }
+  }
+
+  return new RegExp(`^${source}$`);
+}
+
+/**
+ * Check if a workspace-relative path matches any of the globs
+ * Globs without a slash match against the file name alone (e.g. "*.go")
+ */
+export function matchesGlob(relativePath: string, globs: string | string[]): boolean {
+  const normalized = relativePath.replace(/\\/g, '/');
+  const baseName = normalized.substring(normalized.lastIndexOf('/') + 1);
+
+  for (const glob of Array.isArray(globs) ? globs : [globs]) {
+    const target = glob.includes('/') ? normalized : baseName;
+    if (globToRegExp(glob).test(target)) {
+      return true;
+    }
+  }
+  return false;
+}
@@ -0,0 +1,37 @@
+/**
+ * Tests for language detection
+ */
+
+import { detectLanguageId, isSourceFile, languageGlobs, languageOverrides } from './language';
+import { resolveLanguage } from '../search/query';
+
+describe('language detection', () => {
+  const saved = process.env.LANGUAGE_OVERRIDES;
+
+  afterEach(() => {
+    if (saved === undefined) {
+      delete process.env.LANGUAGE_OVERRIDES;
+    } else {
+      process.env.LANGUAGE_OVERRIDES = saved;
+    }
+  });
+
+  it('should detect languages by extension', () => {
+    delete process.env.LANGUAGE_OVERRIDES;
+    expect(detectLanguageId('src/main.go')).toBe('go');
+    expect(detectLanguageId('file:///app/Jenkinsfile')).toBe('plaintext');
+    expect(languageGlobs('go')).toEqual(['*.go']);
+  });
+
+  it('should map overridden file names ahead of extensions', () => {
+    process.env.LANGUAGE_OVERRIDES = '*.gotmpl:go-template, Jenkinsfile:groovy,**/scripts/*.{sh,txt}:perl,broken';
+    expect(languageOverrides().map((override) => override.glob)).toEqual(['*.gotmpl', 'Jenkinsfile', '**/scripts/*.{sh,txt}']);
+    expect(detectLanguageId('deploy/page.gotmpl')).toBe('go-template');
+    expect(detectLanguageId('file:///app/ci/Jenkinsfile')).toBe('groovy');
+    expect(detectLanguageId('tools/scripts/run.sh')).toBe('perl');
+    expect(detectLanguageId('tools/run.sh')).toBe('shell');
+    expect(isSourceFile('Jenkinsfile')).toBe(true);
+    expect(languageGlobs('groovy')).toEqual(['Jenkinsfile']);
+    expect(resolveLanguage('groovy')).toEqual(['groovy']);
+  });
+});
@@ -0,0 +1,131 @@
+/**
+ * Language detection for workspace files
+ * Extensions map to LSP language identifiers; LANGUAGE_OVERRIDES maps
+ * further file name globs (e.g. *.gotmpl, Jenkinsfile) ahead of them
+ */
+
+import * as path from 'path';
+import { globToRegExp } from './glob.js';
+
+/**
+ * Map from file extension to LSP language identifier
+ */
+const languageMap: Record<string, string> = {
+  '.ts': 'typescript',
+  '.tsx': 'typescriptreact',
+  '.js': 'javascript',
+  '.jsx': 'javascriptreact',
+  '.py': 'python',
+  '.go': 'go',
+  '.rs': 'rust',
+  '.c': 'c',
+  '.cpp': 'cpp',
+  '.cc': 'cpp',
+  '.cxx': 'cpp',
+  '.h': 'c',
+  '.hpp': 'cpp',
+  '.java': 'java',
+  '.cs': 'csharp',
+  '.rb': 'ruby',
+  '.php': 'php',
+  '.swift': 'swift',
+  '.kt': 'kotlin',
+  '.scala': 'scala',
+  '.r': 'r',
+  '.R': 'r',
+  '.sh': 'shell',
+  '.bash': 'shell',
+  '.zsh': 'shell',
+  '.fish': 'shell',
+};
+
+/**
+ * A file name glob mapped to a language
+ */
+export interface LanguageOverride {
+  glob: string;
+  languageId: string;
+}
+
+// Parsed overrides, kept until the variable changes
+let parsedOverrides: { source: string; overrides: Array<LanguageOverride & { pattern: RegExp; byPath: boolean }> } | undefined;
+
+/**
+ * Overrides from LANGUAGE_OVERRIDES, "glob:language" pairs separated by commas
+ * Globs without a slash match the file name, others the path
+ */
+export function languageOverrides(env: NodeJS.ProcessEnv = process.env): LanguageOverride[] {
+  return compiledOverrides(env).map(({ glob, languageId }) => ({ glob, languageId }));
+}
+
+function compiledOverrides(env: NodeJS.ProcessEnv): Array<LanguageOverride & { pattern: RegExp; byPath: boolean }> {
+  const source = env.LANGUAGE_OVERRIDES ?? '';
+  if (parsedOverrides?.source !== source) {
+    // Commas inside {a,b} belong to the glob
+    const overrides = source.split(/,(?![^{]*\})/).flatMap((pair) => {
+      // The language follows the last colon
+      const colon = pair.lastIndexOf(':');
+      const glob = pair.substring(0, colon).trim();
+      const languageId = pair.substring(colon + 1).trim();
+      if (colon < 0 || !glob || !languageId) {
+        return [];
+      }
+      return [{ glob, languageId, pattern: globToRegExp(glob), byPath: glob.includes('/') }];
+    });
+    parsedOverrides = { source, overrides };
+  }
+  return parsedOverrides.overrides;
+}
+
+/**
+ * Detect the LSP language identifier for a file path or URI
+ * The first matching override wins over the extension
+ */
+export function detectLanguageId(filePath: string): string {
+  const overrides = compiledOverrides(process.env);
+  if (overrides.length > 0) {
+    const normalized = filePath.replace(/\\/g, '/');
+    const baseName = normalized.substring(normalized.lastIndexOf('/') + 1);
+    const override = overrides.find(({ pattern, byPath }) => pattern.test(byPath ? normalized : baseName));
+    if (override) {
+      return override.languageId;
+    }
+  }
+  const ext = path.extname(filePath).toLowerCase();
+  return languageMap[ext] || 'plaintext';
+}
+
+/**
+ * File extensions of an LSP language identifier, e.g. go -> .go
+ */
+export function languageExtensions(languageId: string): string[] {
+  return Object.keys(languageMap).filter((ext) => languageMap[ext] === languageId);
+}
+
+/**
+ * File name globs of an LSP language identifier: its extensions and overrides
+ */
+export function languageGlobs(languageId: string): string[] {
+  return [
+    ...languageExtensions(languageId).map((ext) => `*${ext}`),
+    ...languageOverrides().filter((override) => override.languageId === languageId).map((override) => override.glob),
+  ];
+}
+
+/**
+ * Check if a file is a recognized source file
+ */
+export function isSourceFile(filePath: string): boolean {
+  return detectLanguageId(filePath) !== 'plaintext';
+}
+
+/**
+ * Check if a file is a test file by naming convention
+ */
+export function isTestFile(filePath: string): boolean {
+  const base = path.basename(filePath);
+  if (/(_test\.go|\.test\.[jt]sx?|\.spec\.[jt]sx?|^test_.*\.py|_test\.py|Tests?\.(java|kt|cs))$/.test(base)) {
+    return true;
+  }
+  return /(^|[\\/])(__tests__|tests?)[\\/]/.test(filePath);
+}
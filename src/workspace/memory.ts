@@ -0,0 +1,91 @@
+/**
+ * Memory budget for searches and indexes
+ * Scans slow down as usage approaches the budget and stop with partial
+ * results when it is reached, instead of running the process out of memory
+ */
+
+import * as v8 from 'v8';
+
+/**
+ * Fraction of the budget at which scanning drops to a single worker
+ */
+const SOFT_LIMIT_RATIO = 0.8;
+
+/**
+ * Minimum time between memory samples
+ */
+const SAMPLE_INTERVAL_MS = 50;
+
+/**
+ * Memory budget measured against heap plus external (Buffer) memory
+ */
+export class MemoryBudget {
+  private sampledAt = 0;
+  private sampled = 0;
+
+  constructor(readonly limitBytes: number) {}
+
+  /**
+   * Budget from SEARCH_MEMORY_LIMIT_MB, defaulting to 75% of the V8 heap limit
+   */
+  static fromEnv(env: NodeJS.ProcessEnv = process.env): MemoryBudget {
+    const configured = env.SEARCH_MEMORY_LIMIT_MB ? parseInt(env.SEARCH_MEMORY_LIMIT_MB, 10) : NaN;
+    const limit = Number.isFinite(configured) && configured > 0
+      ? configured * 1024 * 1024
+      : Math.floor(v8.getHeapStatistics().heap_size_limit * 0.75);
+    return new MemoryBudget(limit);
+  }
+
+  /**
+   * Current usage in bytes, sampled at most every SAMPLE_INTERVAL_MS
+   */
+  usage(): number {
+    const now = Date.now();
+    if (now - this.sampledAt >= SAMPLE_INTERVAL_MS) {
+      const { heapUsed, external } = process.memoryUsage();
+      this.sampled = heapUsed + external;
+      this.sampledAt = now;
+    }
+    return this.sampled;
+  }
+
+  /**
+   * Usage as a fraction of the budget
+   */
+  pressure(): number {
+    return this.usage() / this.limitBytes;
+  }
+
+  /**
+   * Usage is close to the budget; work should be serialized
+   */
+  isNearLimit(): boolean {
+    return this.pressure() >= SOFT_LIMIT_RATIO;
+  }
+
+  /**
+   * Usage has reached the budget; work should stop
+   */
+  isExceeded(): boolean {
+    return this.pressure() >= 1;
+  }
+
+  /**
+   * Bytes that can still be allocated before the budget is reached
+   */
+  headroom(): number {
+    return Math.max(0, this.limitBytes - this.usage());
+  }
+}
+
+let sharedBudget: MemoryBudget | undefined;
+
+/**
+ * Process-wide budget shared by searches and indexes
+ */
+export function sharedMemoryBudget(): MemoryBudget {
+  if (!sharedBudget) {
+    sharedBudget = MemoryBudget.fromEnv();
+  }
+  return sharedBudget;
+}
@@ -0,0 +1,72 @@
+/**
+ * Tests for the file overlay
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { Overlay, assertNoOverlay, readFileText, sharedOverlay } from './overlay';
+import { searchLexical } from '../search/lexical';
+import { TrigramIndex } from '../search/trigram';
+
+describe('File overlay', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'overlay-'));
+    fs.writeFileSync(path.join(workspace, 'main.go'), 'package main\n\nfunc main() {}\n');
+  });
+
+  afterEach(() => {
+    sharedOverlay().clear();
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should track versions and fall back to disk when cleared', async () => {
+    const overlay = new Overlay();
+    const file = path.join(workspace, 'main.go');
+    expect(overlay.set(file, 'a').version).toBe(1);
+    expect(overlay.set(path.join(workspace, '.', 'main.go'), 'b').version).toBe(2);
+    expect(overlay.get(file)).toBe('b');
+    expect(overlay.delete(file)).toBe(true);
+    expect(overlay.delete(file)).toBe(false);
+    expect(overlay.paths()).toEqual([]);
+
+    sharedOverlay().set(file, 'package main\r\n// unsaved\r\n');
+    expect(await readFileText(file)).toBe('package main\n// unsaved\n');
+    sharedOverlay().delete(file);
+    expect(await readFileText(file)).toBe('package main\n\nfunc main() {}\n');
+  });
+
+  it('should decode files on disk and normalize their line endings', async () => {
+    const latin1 = path.join(workspace, 'notes.txt');
+    fs.writeFileSync(latin1, Buffer.from('caf\xe9\r\nna\xefve\r\n', 'latin1'));
+    expect(await readFileText(latin1)).toBe('café\nnaïve\n');
+    const utf16 = path.join(workspace, 'wide.txt');
+    fs.writeFileSync(utf16, Buffer.concat([Buffer.from([0xff, 0xfe]), Buffer.from('wide\r\ntext\r\n', 'utf16le')]));
+    expect(await readFileText(utf16)).toBe('wide\ntext\n');
+  });
+
+  it('should search overlaid content, including files not on disk', async () => {
+    const index = new TrigramIndex(workspace);
+    await index.refresh();
+    sharedOverlay().set(path.join(workspace, 'main.go'), 'package main\n\nfunc main() { retryUnsaved() }\n');
+    sharedOverlay().set(path.join(workspace, 'retry.go'), 'package main\n\nfunc retryUnsaved() {}\n');
+    // Outside the workspace, so never searched
+    sharedOverlay().set(path.join(os.tmpdir(), 'elsewhere.go'), 'retryUnsaved\n');
+
+    for (const searchIndex of [undefined, index]) {
+      const result = await searchLexical(workspace, 'retryUnsaved', {}, searchIndex);
+      expect(result.matches.map((match) => `${match.filePath}:${match.line}`)).toEqual(['main.go:3', 'retry.go:3']);
+    }
+    const scoped = await searchLexical(workspace, 'retryUnsaved', { glob: 'retry.go' });
+    expect(scoped.matches.map((match) => match.filePath)).toEqual(['retry.go']);
+  });
+
+  it('should keep tools that write to disk away from overlaid files', () => {
+    const file = path.join(workspace, 'main.go');
+    expect(() => assertNoOverlay(file, 'edit')).not.toThrow();
+    sharedOverlay().set(file, 'package main\n');
+    expect(() => assertNoOverlay(file, 'edit')).toThrow(`cannot edit ${file}: it has unsaved overlay content`);
+  });
+});
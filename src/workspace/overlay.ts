@@ -0,0 +1,166 @@
+/**
+ * File overlay - in-memory contents that replace files on disk
+ * Clients push unsaved editor buffers or proposed edits, and searches and
+ * language server requests read the overlay instead of the file. Overlaid
+ * files need not exist on disk
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { currentBudget } from './budget.js';
+import { decodeText } from './encoding.js';
+import { currentSnapshot } from './treesnapshot.js';
+import { ToolError } from './errors.js';
+
+const overlayLogger = createLogger(Component.TOOLS);
+
+/**
+ * Overlaid content of one file
+ */
+export interface OverlayEntry {
+  content: string;
+  // Incremented on each update of the file's overlay
+  version: number;
+  updatedAt: number;
+}
+
+/**
+ * In-memory file contents keyed by absolute path
+ */
+export class Overlay {
+  private files = new Map<string, OverlayEntry>();
+
+  /**
+   * Replace a file's content with the given text
+   */
+  set(filePath: string, content: string): OverlayEntry {
+    const key = path.resolve(filePath);
+    const entry = { content, version: (this.files.get(key)?.version ?? 0) + 1, updatedAt: Date.now() };
+    this.files.set(key, entry);
+    overlayLogger.debug('Overlay set for %s (%d bytes, version %d)', key, content.length, entry.version);
+    return entry;
+  }
+
+  /**
+   * Drop a file's overlay so it is read from disk again; returns whether there was one
+   */
+  delete(filePath: string): boolean {
+    return this.files.delete(path.resolve(filePath));
+  }
+
+  /**
+   * Drop every overlay, returning the paths that had one
+   */
+  clear(): string[] {
+    const paths = this.paths();
+    this.files.clear();
+    return paths;
+  }
+
+  /**
+   * Overlaid content of a file, or undefined when it is read from disk
+   */
+  get(filePath: string): string | undefined {
+    return this.files.get(path.resolve(filePath))?.content;
+  }
+
+  /**
+   * Overlay of a file with its version and update time
+   */
+  entry(filePath: string): OverlayEntry | undefined {
+    return this.files.get(path.resolve(filePath));
+  }
+
+  has(filePath: string): boolean {
+    return this.files.has(path.resolve(filePath));
+  }
+
+  /**
+   * Absolute paths of the overlaid files, sorted
+   */
+  paths(): string[] {
+    return Array.from(this.files.keys()).sort();
+  }
+
+  entries(): Array<[string, OverlayEntry]> {
+    return Array.from(this.files.entries()).sort(([a], [b]) => a.localeCompare(b));
+  }
+}
+
+let sharedInstance: Overlay | undefined;
+
+/**
+ * Process-wide overlay consulted by every file read
+ */
+export function sharedOverlay(): Overlay {
+  if (!sharedInstance) {
+    sharedInstance = new Overlay();
+  }
+  return sharedInstance;
+}
+
+/**
+ * Read a file's bytes, from the overlay when it has the file
+ * Calls pinned to a snapshot read the file as it was then
+ */
+export async function readFileBytes(filePath: string): Promise<Buffer> {
+  const budget = currentBudget();
+  budget?.beforeRead();
+  const snapshot = currentSnapshot();
+  const content = sharedOverlay().get(filePath);
+  const data = snapshot?.covers(filePath) ? await snapshot.read(filePath)
+    : content !== undefined ? Buffer.from(content, 'utf8') : await fs.promises.readFile(filePath);
+  budget?.afterRead(data.length);
+  return data;
+}
+
+/**
+ * Read at most the first maxBytes of a file, with its full size
+ * Files on disk are read only that far; overlay and snapshot content is
+ * already in memory
+ */
+export async function readFileHead(filePath: string, maxBytes: number): Promise<{ head: Buffer; size: number }> {
+  const budget = currentBudget();
+  budget?.beforeRead();
+  const snapshot = currentSnapshot();
+  const content = sharedOverlay().get(filePath);
+  let head: Buffer;
+  let size: number;
+  if (snapshot?.covers(filePath) || content !== undefined) {
+    const data = snapshot?.covers(filePath) ? await snapshot.read(filePath) : Buffer.from(content!, 'utf8');
+    head = data.subarray(0, maxBytes);
+    size = data.length;
+  } else {
+    const handle = await fs.promises.open(filePath, 'r');
+    try {
+      size = (await handle.stat()).size;
+      const buffer = Buffer.alloc(Math.min(size, maxBytes));
+      const { bytesRead } = await handle.read(buffer, 0, buffer.length, 0);
+      head = buffer.subarray(0, bytesRead);
+    } finally {
+      await handle.close();
+    }
+  }
+  budget?.afterRead(head.length);
+  return { head, size };
+}
+
+/**
+ * Read a file as text with LF line endings, from the overlay when it has the file
+ * The encoding is detected as by decodeText. Calls pinned to a snapshot read
+ * the file as it was then
+ */
+export async function readFileText(filePath: string): Promise<string> {
+  return decodeText(await readFileBytes(filePath));
+}
+
+/**
+ * Fail when a file has overlay content, for tools that write to disk
+ * Their positions would refer to the overlay while the write replaces the file
+ */
+export function assertNoOverlay(filePath: string, action: string): void {
+  if (sharedOverlay().has(filePath)) {
+    throw new ToolError('invalid-argument', `cannot ${action} ${filePath}: it has unsaved overlay content (save it or clear the overlay first)`);
+  }
+}
@@ -0,0 +1,85 @@
+/**
+ * Tests for path canonicalization
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { PathOutsideWorkspaceError, assertInsideRoots, canonicalizePath, isCaseInsensitiveDir, pathKey } from './paths';
+import { resolveWorkspacePath } from './walker';
+
+describe('Path canonicalization', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'paths-')));
+    fs.mkdirSync(path.join(workspace, 'Src'));
+    fs.writeFileSync(path.join(workspace, 'Src', 'caf\u00e9.ts'), 'x\n'); // NFC on disk
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should map an NFD spelling to the on-disk NFC name', () => {
+    const nfd = path.join(workspace, 'Src', 'cafe\u0301.ts');
+    expect(canonicalizePath(nfd)).toBe(path.join(workspace, 'Src', 'caf\u00e9.ts'));
+    expect(resolveWorkspacePath(workspace, path.join('Src', 'cafe\u0301.ts'))).toBe(path.join(workspace, 'Src', 'caf\u00e9.ts'));
+  });
+
+  it('should match letter case only on case-insensitive volumes', () => {
+    const lower = path.join(workspace, 'src', 'caf\u00e9.ts');
+    const expected = isCaseInsensitiveDir(workspace) ? path.join(workspace, 'Src', 'caf\u00e9.ts') : lower;
+    expect(canonicalizePath(lower)).toBe(expected);
+  });
+
+  it('should keep missing segments, normalized', () => {
+    expect(canonicalizePath(path.join(workspace, 'Src', 'new', 'cafe\u0301.ts'), workspace))
+      .toBe(path.join(workspace, 'Src', 'new', 'caf\u00e9.ts'));
+  });
+
+  it('should compare paths by normalized key', () => {
+    expect(pathKey('/a/cafe\u0301/../b')).toBe(pathKey('/a/b'));
+    expect(pathKey('/x/cafe\u0301')).toBe(pathKey('/x/caf\u00e9'));
+  });
+});
+
+describe('Workspace containment', () => {
+  let root: string;
+  let workspace: string;
+
+  beforeEach(() => {
+    root = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'sandbox-')));
+    workspace = path.join(root, 'workspace');
+    fs.mkdirSync(path.join(workspace, 'src'), { recursive: true });
+    fs.writeFileSync(path.join(root, 'secret.txt'), 'secret\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('should accept paths inside the workspace, existing or not', () => {
+    expect(resolveWorkspacePath(workspace, 'src')).toBe(path.join(workspace, 'src'));
+    expect(resolveWorkspacePath(workspace, 'src/../src/new.ts')).toBe(path.join(workspace, 'src', 'new.ts'));
+    expect(resolveWorkspacePath(workspace, path.join(workspace, 'src'))).toBe(path.join(workspace, 'src'));
+  });
+
+  it('should reject .. escapes and absolute paths outside the workspace', () => {
+    expect(() => resolveWorkspacePath(workspace, '../secret.txt')).toThrow(PathOutsideWorkspaceError);
+    expect(() => resolveWorkspacePath(workspace, 'src/../../secret.txt')).toThrow(/outside the workspace/);
+    expect(() => resolveWorkspacePath(workspace, path.join(root, 'secret.txt'))).toThrow(PathOutsideWorkspaceError);
+    expect(() => resolveWorkspacePath(workspace, '/etc/passwd')).toThrow(PathOutsideWorkspaceError);
+  });
+
+  it('should reject symlinks leading out of the workspace', () => {
+    fs.symlinkSync(root, path.join(workspace, 'escape'));
+    expect(() => resolveWorkspacePath(workspace, 'escape/secret.txt')).toThrow(PathOutsideWorkspaceError);
+    expect(() => resolveWorkspacePath(workspace, 'escape/missing/file.ts')).toThrow(PathOutsideWorkspaceError);
+  });
+
+  it('should accept any of several roots', () => {
+    expect(() => assertInsideRoots(path.join(root, 'secret.txt'), [workspace, root])).not.toThrow();
+    expect(() => assertInsideRoots(path.join(root, 'secret.txt'), [workspace])).toThrow(PathOutsideWorkspaceError);
+  });
+});
@@ -0,0 +1,156 @@
+/**
+ * Path canonicalization
+ * Clients may spell a path differently from the filesystem: NFD instead of
+ * NFC (macOS), or with different letter case on case-insensitive volumes.
+ * Paths are mapped to their on-disk spelling so lookups and comparisons agree
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+
+/**
+ * Case sensitivity per device, detected once
+ */
+const caseInsensitiveDevices = new Map<number, boolean>();
+
+/**
+ * Swap the case of every letter
+ */
+function swapCase(name: string): string {
+  return name.replace(/[a-zA-Z]/g, (c) => (c === c.toLowerCase() ? c.toUpperCase() : c.toLowerCase()));
+}
+
+/**
+ * Check if the volume holding a directory ignores letter case
+ * Probes an entry with letters under its case-swapped name
+ */
+export function isCaseInsensitiveDir(dir: string): boolean {
+  let dev: number;
+  let entries: string[];
+  try {
+    dev = fs.statSync(dir).dev;
+    entries = fs.readdirSync(dir);
+  } catch {
+    return false;
+  }
+  const cached = caseInsensitiveDevices.get(dev);
+  if (cached !== undefined) {
+    return cached;
+  }
+  const probe = entries.find((entry) => /[a-zA-Z]/.test(entry) && !entries.includes(swapCase(entry)));
+  if (probe === undefined) {
+    return false; // Undecided; try again with another directory
+  }
+  const insensitive = fs.existsSync(path.join(dir, swapCase(probe)));
+  caseInsensitiveDevices.set(dev, insensitive);
+  return insensitive;
+}
+
+/**
+ * On-disk spelling of a directory entry, or undefined when there is none
+ */
+function matchEntry(dir: string, name: string): string | undefined {
+  let entries: string[];
+  try {
+    entries = fs.readdirSync(dir);
+  } catch {
+    return undefined;
+  }
+  if (entries.includes(name)) {
+    return name;
+  }
+  const normalized = name.normalize('NFC');
+  const sameText = entries.find((entry) => entry.normalize('NFC') === normalized);
+  if (sameText !== undefined || !isCaseInsensitiveDir(dir)) {
+    return sameText;
+  }
+  const lower = normalized.toLowerCase();
+  return entries.find((entry) => entry.normalize('NFC').toLowerCase() === lower);
+}
+
+/**
+ * Resolve a path to its on-disk spelling
+ * Segments that do not exist are kept as given (NFC-normalized), so the
+ * result is also usable for files about to be created
+ */
+export function canonicalizePath(filePath: string, base?: string): string {
+  const resolved = path.resolve(filePath);
+  // Exact spellings are the only valid ones on case-sensitive volumes
+  if (fs.existsSync(resolved) && !isCaseInsensitiveDir(path.dirname(resolved))) {
+    return resolved;
+  }
+
+  // Segments under base are assumed to be canonical already
+  const start = base && isUnder(resolved, base) ? base : path.parse(resolved).root;
+  const segments = path.relative(start, resolved).split(path.sep).filter(Boolean);
+  let current = start;
+  for (let i = 0; i < segments.length; i++) {
+    const entry = matchEntry(current, segments[i]);
+    if (entry === undefined) {
+      return path.join(current, ...segments.slice(i).map((segment) => segment.normalize('NFC')));
+    }
+    current = path.join(current, entry);
+  }
+  return current;
+}
+
+/**
+ * Check if a path is inside (or equal to) a directory
+ */
+export function isUnder(filePath: string, dir: string): boolean {
+  const relative = path.relative(dir, filePath);
+  return relative === '' || (!relative.startsWith('..') && !path.isAbsolute(relative));
+}
+
+/**
+ * A path argument that leaves the workspace
+ */
+export class PathOutsideWorkspaceError extends Error {
+  constructor(public readonly filePath: string, public readonly roots: string[]) {
+    super(`Path ${filePath} is outside the workspace (${roots.join(', ')})`);
+    this.name = 'PathOutsideWorkspaceError';
+  }
+}
+
+/**
+ * Real path of a path that may not exist yet: symlinks in the nearest
+ * existing ancestor are resolved and the missing segments appended
+ */
+export function realPathOf(filePath: string): string {
+  const missing: string[] = [];
+  let current = path.resolve(filePath);
+  for (;;) {
+    try {
+      return path.join(fs.realpathSync(current), ...missing.reverse());
+    } catch {
+      const parent = path.dirname(current);
+      if (parent === current) {
+        return path.join(current, ...missing.reverse());
+      }
+      missing.push(path.basename(current));
+      current = parent;
+    }
+  }
+}
+
+/**
+ * Check that a path stays inside one of the workspace roots once `..`
+ * segments and symlinks are resolved
+ * Throws PathOutsideWorkspaceError otherwise
+ */
+export function assertInsideRoots(filePath: string, roots: string[]): void {
+  const resolved = path.resolve(filePath);
+  const real = realPathOf(resolved);
+  const inside = roots.some((root) => isUnder(resolved, root) && isUnder(real, realPathOf(root)));
+  if (!inside) {
+    throw new PathOutsideWorkspaceError(filePath, roots);
+  }
+}
+
+/**
+ * Comparison key of a path: NFC, and lowercase on case-insensitive platforms
+ */
+export function pathKey(filePath: string): string {
+  const normalized = path.normalize(filePath).normalize('NFC');
+  return process.platform === 'darwin' || process.platform === 'win32' ? normalized.toLowerCase() : normalized;
+}
@@ -0,0 +1,105 @@
+/**
+ * Bounded worker pool for file scanning
+ * Work items are pulled from a shared queue by a fixed number of workers,
+ * which keeps many file reads in flight without opening every file at once
+ */
+
+import * as os from 'os';
+import { currentSignal, throwIfCancelled } from './cancellation.js';
+import type { MemoryBudget } from './memory.js';
+
+/**
+ * Delay before a paused worker checks memory again
+ */
+const MEMORY_PAUSE_MS = 20;
+
+/**
+ * Pool options
+ */
+export interface PoolOptions {
+  // Number of concurrent workers (default: workerCount())
+  concurrency?: number;
+  // Checked before each item is taken; returning true stops dispatching
+  shouldStop?: () => boolean;
+  // Near the budget, all but one worker pause until usage drops
+  memory?: MemoryBudget;
+}
+
+/**
+ * Number of workers to use: one per available CPU, capped by SEARCH_MAX_WORKERS
+ */
+export function workerCount(env: NodeJS.ProcessEnv = process.env): number {
+  const cpus = typeof os.availableParallelism === 'function' ? os.availableParallelism() : os.cpus().length;
+  const cap = env.SEARCH_MAX_WORKERS ? parseInt(env.SEARCH_MAX_WORKERS, 10) : NaN;
+  const count = Number.isFinite(cap) && cap > 0 ? Math.min(cpus, cap) : cpus;
+  return Math.max(1, count);
+}
+
+/**
+ * Run worker over every item with bounded concurrency
+ * Results keep the input order. Items are dispatched in order, so when
+ * shouldStop ends the run early every item before the first undispatched
+ * one has completed; later slots are left undefined. Once the current
+ * call is cancelled no further item is taken, and the run throws after the
+ * items in progress complete
+ */
+export async function runPool<T, R>(
+  items: T[],
+  worker: (item: T, index: number) => Promise<R>,
+  options: PoolOptions = {}
+): Promise<Array<R | undefined>> {
+  const results: Array<R | undefined> = new Array(items.length);
+  const concurrency = Math.max(1, Math.min(options.concurrency ?? workerCount(), items.length));
+  const signal = currentSignal();
+  let next = 0;
+
+  const runWorker = async (_: unknown, workerId: number): Promise<void> => {
+    while (next < items.length) {
+      if (signal?.aborted || (options.shouldStop && options.shouldStop())) {
+        return;
+      }
+      if (workerId > 0 && options.memory && options.memory.isNearLimit()) {
+        await new Promise((resolve) => setTimeout(resolve, MEMORY_PAUSE_MS));
+        continue;
+      }
+      const index = next++;
+      results[index] = await worker(items[index], index);
+    }
+  };
+
+  await Promise.all(Array.from({ length: concurrency }, runWorker));
+  throwIfCancelled(signal);
+  return results;
+}
+
+/**
+ * Create a limiter that runs at most `concurrency` tasks at a time
+ * Unlike runPool it suits recursive work such as directory walks, where
+ * tasks are discovered while others are running
+ */
+export function createLimiter(concurrency: number): <R>(task: () => Promise<R>) => Promise<R> {
+  let active = 0;
+  const waiting: Array<() => void> = [];
+
+  return async <R>(task: () => Promise<R>): Promise<R> => {
+    throwIfCancelled();
+    if (active >= concurrency) {
+      // The finishing task hands its slot over directly
+      await new Promise<void>((resolve) => waiting.push(resolve));
+    } else {
+      active++;
+    }
+    try {
+      // Tasks queued before the call was cancelled do not start
+      throwIfCancelled();
+      return await task();
+    } finally {
+      const wake = waiting.shift();
+      if (wake) {
+        wake();
+      } else {
+        active--;
+      }
+    }
+  };
+}
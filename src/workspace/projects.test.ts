@@ -0,0 +1,109 @@
+/**
+ * Tests for monorepo project detection
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { PROJECT_SCOPED_TOOLS, detectProjects, findProject } from './projects';
+
+describe('Monorepo projects', () => {
+  let workspace: string;
+
+  const write = (file: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(workspace, file)), { recursive: true });
+    fs.writeFileSync(path.join(workspace, file), content);
+  };
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'projects-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should read go.work members and name them by module path', () => {
+    write('go.work', 'go 1.22\n\nuse (\n\t./services/api // the API\n\t./tools\n)\nuse ./lib\n');
+    write('services/api/go.mod', 'module github.com/acme/api\n');
+    write('tools/go.mod', 'module github.com/acme/tools\n');
+    expect(detectProjects(workspace)).toEqual([
+      { name: 'lib', path: 'lib', source: 'go.work' },
+      { name: 'github.com/acme/api', path: path.join('services', 'api'), source: 'go.work' },
+      { name: 'github.com/acme/tools', path: 'tools', source: 'go.work' },
+    ]);
+  });
+
+  it('should expand npm, pnpm, and Cargo workspace globs', () => {
+    write('package.json', JSON.stringify({ workspaces: ['packages/*', '!packages/ignored'] }));
+    write('packages/web/package.json', JSON.stringify({ name: '@acme/web' }));
+    write('packages/ignored/package.json', JSON.stringify({ name: '@acme/ignored' }));
+    write('packages/not-a-package/README.md', 'no manifest\n');
+    write('pnpm-workspace.yaml', "packages:\n  - 'apps/**'\n");
+    write('apps/admin/package.json', JSON.stringify({ name: 'admin' }));
+    write('apps/admin/node_modules/dep/package.json', JSON.stringify({ name: 'dep' }));
+    write('Cargo.toml', '[workspace]\nmembers = [\n  "crates/*",\n]\nexclude = ["crates/old"]\n\n[workspace.dependencies]\nserde = "1"\n');
+    write('crates/parser/Cargo.toml', '[package]\nname = "acme-parser"\nversion = "0.1.0"\n');
+    write('crates/old/Cargo.toml', '[package]\nname = "old"\n');
+
+    expect(detectProjects(workspace)).toEqual([
+      { name: 'admin', path: path.join('apps', 'admin'), source: 'pnpm-workspace.yaml' },
+      { name: 'acme-parser', path: path.join('crates', 'parser'), source: 'Cargo.toml' },
+      { name: '@acme/web', path: path.join('packages', 'web'), source: 'package.json' },
+    ]);
+  });
+
+  it('should follow CMake subdirectories, Gradle includes, and Maven modules', () => {
+    write('CMakeLists.txt', 'project(acme)\nadd_subdirectory(libs/core)\n# add_subdirectory(old)\nadd_subdirectory(${CMAKE_SOURCE_DIR}/gen)\n');
+    write('libs/core/CMakeLists.txt', 'project(acme_core CXX)\nadd_subdirectory("tests")\n');
+    write('libs/core/tests/CMakeLists.txt', 'add_executable(core_tests main.cpp)\n');
+    write('old/CMakeLists.txt', 'project(old)\n');
+    write('settings.gradle.kts', 'rootProject.name = "shop"\ninclude(\n    ":app",\n    ":lib:payments",\n)\ninclude(":legacy")\nproject(":legacy").projectDir = file("old-app")\n');
+    fs.mkdirSync(path.join(workspace, 'app'));
+    fs.mkdirSync(path.join(workspace, 'lib', 'payments'), { recursive: true });
+    fs.mkdirSync(path.join(workspace, 'old-app'));
+    write('pom.xml', '<project><artifactId>parent</artifactId><modules><module>server</module><!-- <module>gone</module> --></modules></project>');
+    write('server/pom.xml', '<project><parent><artifactId>parent</artifactId></parent><artifactId>shop-server</artifactId>' +
+      '<modules><module>api/pom.xml</module></modules></project>');
+    write('server/api/pom.xml', '<project><artifactId>shop-api</artifactId></project>');
+
+    expect(detectProjects(workspace)).toEqual([
+      { name: ':app', path: 'app', source: 'settings.gradle.kts' },
+      { name: ':lib:payments', path: path.join('lib', 'payments'), source: 'settings.gradle.kts' },
+      { name: 'acme_core', path: path.join('libs', 'core'), source: 'CMakeLists.txt' },
+      { name: 'tests', path: path.join('libs', 'core', 'tests'), source: 'CMakeLists.txt' },
+      { name: ':legacy', path: 'old-app', source: 'settings.gradle.kts' },
+      { name: 'shop-server', path: 'server', source: 'pom.xml' },
+      { name: 'shop-api', path: path.join('server', 'api'), source: 'pom.xml' },
+    ]);
+    expect(findProject(detectProjects(workspace), 'payments').path).toBe(path.join('lib', 'payments'));
+  });
+
+  it('should find projects by name, directory, or last component', () => {
+    const projects = [
+      { name: '@acme/api', path: path.join('services', 'api'), source: 'package.json' as const },
+      { name: 'github.com/acme/api', path: path.join('go', 'api'), source: 'go.work' as const },
+      { name: 'web', path: 'web', source: 'package.json' as const },
+    ];
+    expect(findProject(projects, '@acme/api').path).toBe(path.join('services', 'api'));
+    expect(findProject(projects, 'go/api/').name).toBe('github.com/acme/api');
+    expect(findProject(projects, 'web').name).toBe('web');
+    expect(() => findProject(projects, 'api')).toThrow('project "api" is ambiguous');
+    expect(() => findProject(projects, 'mobile')).toThrow('unknown project "mobile" (projects: @acme/api (services/api)');
+    expect(() => findProject([], 'web')).toThrow('no go.work, npm/pnpm, Cargo, CMake, Gradle, or Maven members were found');
+  });
+
+  it('should scope every tool that takes a workspace path or file', () => {
+    const source = fs.readFileSync(path.join(__dirname, '..', 'index.ts'), 'utf8');
+    const tools = source.split(/\n {8}name: '/).slice(1).map((body) => [body.slice(0, body.indexOf("'")), body] as const);
+    // Tools whose path is not a place in the workspace: a new root, or a code index dump
+    const unscoped = new Set(['add_workspace', 'load_code_index']);
+    const takingPaths = tools
+      .filter(([name, body]) => /\n {12}(path|filePath): \{/.test(body) && !unscoped.has(name))
+      .map(([name]) => name);
+    expect(takingPaths.length).toBeGreaterThan(40);
+    expect(takingPaths.filter((name) => !PROJECT_SCOPED_TOOLS[name])).toEqual([]);
+    const names = new Set(tools.map(([name]) => name));
+    expect(Object.keys(PROJECT_SCOPED_TOOLS).filter((name) => !names.has(name))).toEqual([]);
+  });
+});
@@ -0,0 +1,405 @@
+/**
+ * Monorepo project detection
+ * Finds the member projects of a workspace from go.work, npm and pnpm
+ * workspaces, Cargo workspaces, CMake subdirectories, Gradle builds, and Maven
+ * multi-module builds, so tools can be scoped to a project by name instead of by path
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { parseYaml } from '../config/parse.js';
+import { globToRegExp } from './glob.js';
+import { ToolError } from './errors.js';
+
+const projectsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Directories never searched for workspace members
+ */
+const SKIPPED_DIRS = new Set(['node_modules', 'target', 'vendor', 'dist', 'build']);
+
+/**
+ * Depth searched for members matched by "**"
+ */
+const MAX_MEMBER_DEPTH = 6;
+
+/**
+ * Depth of nested CMake subdirectories and Maven modules followed
+ */
+const MAX_NESTING = 8;
+
+/**
+ * Manifest that declared a project
+ */
+export type ProjectSource = 'go.work' | 'package.json' | 'pnpm-workspace.yaml' | 'Cargo.toml'
+  | 'CMakeLists.txt' | 'settings.gradle' | 'settings.gradle.kts' | 'pom.xml';
+
+/**
+ * How a project scope applies to a tool
+ */
+export type ProjectScopeMode = 'path' | 'filePath' | 'results';
+
+/**
+ * Tools that accept a project scope, and how it applies
+ * - path: the project directory is the default path, and relative paths are under it
+ * - filePath: relative file paths are under the project directory
+ * - results: results outside the project directory are dropped
+ * Every tool taking a workspace path or filePath belongs here
+ */
+export const PROJECT_SCOPED_TOOLS: Record<string, ProjectScopeMode> = {
+  definition: 'results',
+  references: 'results',
+  diagnostics: 'filePath',
+  hover: 'filePath',
+  rename_symbol: 'filePath',
+  edit_file: 'filePath',
+  replace_text: 'path',
+  plan_refactor: 'path',
+  impact_report: 'filePath',
+  overlay: 'filePath',
+  pin_file: 'filePath',
+  bookmark_symbol: 'filePath',
+  todo_comments: 'path',
+  api_surface: 'path',
+  symbol_usage: 'path',
+  explore_symbol: 'results',
+  vocabulary: 'path',
+  search_code: 'path',
+  search_jsx: 'path',
+  query_config: 'path',
+  proto_references: 'path',
+  build_targets: 'path',
+  bazel_targets: 'path',
+  sql_search: 'path',
+  docker_files: 'path',
+  outline: 'filePath',
+  read_range: 'filePath',
+  tree: 'path',
+  file_info: 'filePath',
+  batch_search: 'path',
+  entry_points: 'path',
+  routes: 'path',
+  env_vars: 'path',
+  i18n_messages: 'path',
+  feature_flags: 'path',
+  recent_files: 'path',
+  test_pairs: 'filePath',
+  symbol_history: 'filePath',
+  importers: 'path',
+  stats: 'path',
+  package_usages: 'path',
+  find_typed: 'path',
+  go_error_checks: 'path',
+  find_message_origin: 'path',
+  churn: 'path',
+  symbol_diff: 'path',
+  search_diff: 'path',
+  watch_query: 'path',
+  explain: 'path',
+  find_duplicates: 'path',
+  find_similar: 'path',
+  semantic_search: 'path',
+};
+
+/**
+ * A project inside the workspace
+ */
+export interface Project {
+  name: string;
+  // Directory relative to the workspace ("." for the root)
+  path: string;
+  source: ProjectSource;
+}
+
+function readFile(filePath: string): string | undefined {
+  try {
+    return fs.readFileSync(filePath, 'utf8');
+  } catch (err) {
+    return undefined;
+  }
+}
+
+function readJson(filePath: string): any {
+  const content = readFile(filePath);
+  if (content === undefined) {
+    return undefined;
+  }
+  try {
+    return JSON.parse(content);
+  } catch (err) {
+    projectsLogger.debug('Cannot parse %s: %s', filePath, (err as Error).message);
+    return undefined;
+  }
+}
+
+/**
+ * Expand member patterns such as "packages/*" into directories containing the manifest
+ * Patterns starting with "!" exclude directories
+ */
+function expandMembers(workspaceDir: string, patterns: string[], manifest: string): string[] {
+  const include = patterns.filter((p) => !p.startsWith('!')).map(normalizePattern);
+  const exclude = patterns.filter((p) => p.startsWith('!')).map((p) => globToRegExp(normalizePattern(p.substring(1))));
+  const members = new Set<string>();
+
+  for (const pattern of include) {
+    if (!/[*?[{]/.test(pattern)) {
+      members.add(pattern);
+      continue;
+    }
+    const regex = globToRegExp(pattern);
+    const depth = pattern.includes('**') ? MAX_MEMBER_DEPTH : pattern.split('/').length;
+    const visit = (relativeDir: string, level: number) => {
+      if (level > depth) {
+        return;
+      }
+      let entries: fs.Dirent[];
+      try {
+        entries = fs.readdirSync(path.join(workspaceDir, relativeDir), { withFileTypes: true });
+      } catch (err) {
+        return;
+      }
+      for (const entry of entries) {
+        if (!entry.isDirectory() || entry.name.startsWith('.') || SKIPPED_DIRS.has(entry.name)) {
+          continue;
+        }
+        const child = relativeDir ? `${relativeDir}/${entry.name}` : entry.name;
+        if (regex.test(child)) {
+          members.add(child);
+        }
+        visit(child, level + 1);
+      }
+    };
+    visit('', 1);
+  }
+
+  return Array.from(members)
+    .filter((member) => !exclude.some((regex) => regex.test(member)))
+    .filter((member) => fs.existsSync(path.join(workspaceDir, member, manifest)))
+    .map((member) => member.split('/').join(path.sep));
+}
+
+function normalizePattern(pattern: string): string {
+  return pattern.trim().replace(/\\/g, '/').replace(/^\.\//, '').replace(/\/+$/, '') || '.';
+}
+
+/**
+ * Members listed by go.work "use" directives, named by their module path
+ */
+function goWorkProjects(workspaceDir: string): Project[] {
+  const content = readFile(path.join(workspaceDir, 'go.work'));
+  if (content === undefined) {
+    return [];
+  }
+  const dirs: string[] = [];
+  const text = content.replace(/\/\/.*$/gm, '');
+  for (const block of text.matchAll(/^\s*use\s*\(([^)]*)\)/gm)) {
+    dirs.push(...block[1].split('\n').map((line) => line.trim()).filter(Boolean));
+  }
+  for (const single of text.matchAll(/^\s*use\s+([^(\s][^\s]*)/gm)) {
+    dirs.push(single[1]);
+  }
+  return dirs.map((dir) => {
+    const relative = normalizePattern(dir.replace(/^"|"$/g, '')).split('/').join(path.sep);
+    const mod = readFile(path.join(workspaceDir, relative, 'go.mod'))?.match(/^module\s+(\S+)/m);
+    const name = mod ? mod[1].replace(/^"|"$/g, '') : path.basename(path.resolve(workspaceDir, relative));
+    return { name, path: relative, source: 'go.work' };
+  });
+}
+
+/**
+ * Members of npm, yarn, and pnpm workspaces, named by their package.json name
+ */
+function nodeProjects(workspaceDir: string): Project[] {
+  const projects: Project[] = [];
+  const add = (patterns: unknown, source: ProjectSource) => {
+    if (!Array.isArray(patterns)) {
+      return;
+    }
+    const members = expandMembers(workspaceDir, patterns.filter((p): p is string => typeof p === 'string'), 'package.json');
+    for (const member of members) {
+      const name = readJson(path.join(workspaceDir, member, 'package.json'))?.name;
+      projects.push({ name: typeof name === 'string' ? name : path.basename(member), path: member, source });
+    }
+  };
+
+  const workspaces = readJson(path.join(workspaceDir, 'package.json'))?.workspaces;
+  add(Array.isArray(workspaces) ? workspaces : workspaces?.packages, 'package.json');
+
+  const pnpm = readFile(path.join(workspaceDir, 'pnpm-workspace.yaml'));
+  if (pnpm !== undefined) {
+    try {
+      add(parseYaml(pnpm).packages, 'pnpm-workspace.yaml');
+    } catch (err) {
+      projectsLogger.debug('Cannot parse pnpm-workspace.yaml: %s', (err as Error).message);
+    }
+  }
+  return projects;
+}
+
+/**
+ * Members of a Cargo workspace, named by their package name
+ */
+function cargoProjects(workspaceDir: string): Project[] {
+  const content = readFile(path.join(workspaceDir, 'Cargo.toml'));
+  const workspace = content?.match(/^\[workspace\]([\s\S]*?)(?=^\[|(?![\s\S]))/m);
+  if (!workspace) {
+    return [];
+  }
+  const list = (key: string) => {
+    const value = workspace[1].match(new RegExp(`^\\s*${key}\\s*=\\s*\\[([\\s\\S]*?)\\]`, 'm'));
+    return value ? Array.from(value[1].matchAll(/"([^"]*)"|'([^']*)'/g), (m) => m[1] ?? m[2]) : [];
+  };
+  const patterns = [...list('members'), ...list('exclude').map((p) => '!' + p)];
+  return expandMembers(workspaceDir, patterns, 'Cargo.toml').map((member) => {
+    const manifest = readFile(path.join(workspaceDir, member, 'Cargo.toml')) ?? '';
+    const name = manifest.match(/^\[package\][\s\S]*?^\s*name\s*=\s*["']([^"']+)["']/m);
+    return { name: name ? name[1] : path.basename(member), path: member, source: 'Cargo.toml' };
+  });
+}
+
+/**
+ * Directories added with add_subdirectory, followed into their own
+ * CMakeLists.txt, named by their project() when they declare one
+ */
+function cmakeProjects(workspaceDir: string): Project[] {
+  const projects: Project[] = [];
+  const seen = new Set<string>();
+  const visit = (relativeDir: string, level: number) => {
+    const content = readFile(path.join(workspaceDir, relativeDir, 'CMakeLists.txt'));
+    if (content === undefined || level > MAX_NESTING) {
+      return;
+    }
+    const text = content.replace(/#.*$/gm, '');
+    for (const call of text.matchAll(/\badd_subdirectory\s*\(\s*("[^"]+"|[^\s)]+)/gi)) {
+      const dir = call[1].replace(/^"|"$/g, '');
+      // Directories named through variables (${CMAKE_SOURCE_DIR}/x) cannot be resolved without configuring
+      if (dir.includes('${')) {
+        continue;
+      }
+      const child = path.normalize(path.join(relativeDir, dir));
+      if (child.startsWith('..') || path.isAbsolute(child) || seen.has(child)) {
+        continue;
+      }
+      seen.add(child);
+      const manifest = readFile(path.join(workspaceDir, child, 'CMakeLists.txt'));
+      if (manifest === undefined) {
+        continue;
+      }
+      const name = manifest.replace(/#.*$/gm, '').match(/\bproject\s*\(\s*"?([\w.+-]+)/i);
+      projects.push({ name: name ? name[1] : path.basename(child), path: child, source: 'CMakeLists.txt' });
+      visit(child, level + 1);
+    }
+  };
+  visit('', 1);
+  return projects;
+}
+
+/**
+ * Projects included by settings.gradle(.kts), named by their Gradle path (":lib:core")
+ * A project's directory is its path with colons as slashes unless projectDir moves it
+ */
+function gradleProjects(workspaceDir: string): Project[] {
+  const source = (['settings.gradle.kts', 'settings.gradle'] as const)
+    .find((name) => fs.existsSync(path.join(workspaceDir, name)));
+  if (!source) {
+    return [];
+  }
+  const text = (readFile(path.join(workspaceDir, source)) ?? '').replace(/\/\/.*$/gm, '').replace(/\/\*[\s\S]*?\*\//g, '');
+  const moved = new Map<string, string>();
+  for (const dir of text.matchAll(/project\(\s*["']([^"']+)["']\s*\)\.projectDir\s*=\s*(?:file\(\s*)?["']([^"']+)["']/g)) {
+    moved.set(dir[1].startsWith(':') ? dir[1] : `:${dir[1]}`, normalizePattern(dir[2]));
+  }
+  const projects: Project[] = [];
+  for (const include of text.matchAll(/^\s*include\s*(?:\(([^)]*)\)|([^\n]*))/gm)) {
+    for (const literal of (include[1] ?? include[2]).matchAll(/["']([^"']+)["']/g)) {
+      const gradlePath = literal[1].startsWith(':') ? literal[1] : `:${literal[1]}`;
+      const dir = (moved.get(gradlePath) ?? gradlePath.substring(1).split(':').join('/')).split('/').join(path.sep);
+      if (fs.existsSync(path.join(workspaceDir, dir))) {
+        projects.push({ name: gradlePath, path: dir, source });
+      }
+    }
+  }
+  return projects;
+}
+
+/**
+ * Modules of a Maven multi-module build, followed into their own pom.xml,
+ * named by their artifactId
+ */
+function mavenProjects(workspaceDir: string): Project[] {
+  const projects: Project[] = [];
+  const visit = (relativeDir: string, level: number) => {
+    const pom = readFile(path.join(workspaceDir, relativeDir, 'pom.xml'));
+    if (pom === undefined || level > MAX_NESTING) {
+      return;
+    }
+    const text = pom.replace(/<!--[\s\S]*?-->/g, '');
+    // Modules of every profile count, as each is built by some invocation
+    for (const modules of text.matchAll(/<modules>([\s\S]*?)<\/modules>/g)) {
+      for (const module of modules[1].matchAll(/<module>\s*([^<]+?)\s*<\/module>/g)) {
+        const child = path.normalize(path.join(relativeDir, module[1].replace(/\/pom\.xml$/, '')));
+        if (child.startsWith('..') || path.isAbsolute(child) || projects.some((project) => project.path === child)) {
+          continue;
+        }
+        const manifest = readFile(path.join(workspaceDir, child, 'pom.xml'));
+        if (manifest === undefined) {
+          continue;
+        }
+        const artifact = manifest.replace(/<!--[\s\S]*?-->/g, '').replace(/<parent>[\s\S]*?<\/parent>/, '').match(/<artifactId>\s*([^<]+?)\s*<\/artifactId>/);
+        projects.push({ name: artifact ? artifact[1] : path.basename(child), path: child, source: 'pom.xml' });
+        visit(child, level + 1);
+      }
+    }
+  };
+  visit('', 1);
+  return projects;
+}
+
+/**
+ * Find the projects of a workspace, sorted by path
+ * A directory listed by several manifests is reported once, by the first
+ * of go.work, package.json, pnpm-workspace.yaml, Cargo.toml, CMakeLists.txt,
+ * settings.gradle, and pom.xml
+ */
+export function detectProjects(workspaceDir: string): Project[] {
+  const byPath = new Map<string, Project>();
+  for (const project of [
+    ...goWorkProjects(workspaceDir),
+    ...nodeProjects(workspaceDir),
+    ...cargoProjects(workspaceDir),
+    ...cmakeProjects(workspaceDir),
+    ...gradleProjects(workspaceDir),
+    ...mavenProjects(workspaceDir),
+  ]) {
+    if (!byPath.has(project.path)) {
+      byPath.set(project.path, project);
+    }
+  }
+  const projects = Array.from(byPath.values()).sort((a, b) => a.path.localeCompare(b.path));
+  projectsLogger.debug('Detected %d project(s) in %s', projects.length, workspaceDir);
+  return projects;
+}
+
+/**
+ * Find a project by name, path, or the last component of either
+ * ("@acme/api", "github.com/acme/api", "services/api", and "api" all work)
+ */
+export function findProject(projects: Project[], name: string): Project {
+  const wanted = name.replace(/\\/g, '/').replace(/^\.\//, '').replace(/\/+$/, '');
+  const slashed = (p: Project) => p.path.split(path.sep).join('/');
+  const exact = projects.filter((p) => p.name === wanted || slashed(p) === wanted);
+  const matches = exact.length > 0
+    ? exact
+    : projects.filter((p) => p.name.split('/').pop() === wanted || path.basename(p.path) === wanted);
+  if (matches.length === 1) {
+    return matches[0];
+  }
+  const describe = (list: Project[]) => list.map((p) => `${p.name} (${slashed(p)})`).join(', ');
+  if (matches.length > 1) {
+    throw new ToolError('invalid-argument', `project "${name}" is ambiguous: ${describe(matches)}`);
+  }
+  throw new ToolError('not-found', projects.length > 0
+    ? `unknown project "${name}" (projects: ${describe(projects)})`
+    : `unknown project "${name}": no go.work, npm/pnpm, Cargo, CMake, Gradle, or Maven members were found`);
+}
@@ -0,0 +1,72 @@
+/**
+ * Tests for workspaces added at runtime
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { WorkspaceRoots, allowedRootDirs, formatRoot, rootName } from './roots';
+import { searchCode } from '../tools/search';
+
+describe('Workspace roots', () => {
+  let dir: string;
+  let workspace: string;
+
+  beforeEach(() => {
+    dir = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'roots-')));
+    workspace = path.join(dir, 'main');
+    fs.mkdirSync(workspace);
+    fs.mkdirSync(path.join(dir, 'dep lib'));
+    fs.writeFileSync(path.join(dir, 'dep lib', 'retry.go'), 'func Retry(attempts int) error { return nil }\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  it('should add, index, search, and remove a directory', async () => {
+    const roots = new WorkspaceRoots(workspace);
+    const root = roots.add('../dep lib');
+    expect(root).toMatchObject({ name: 'dep-lib', dir: path.join(dir, 'dep lib') });
+    expect(rootName('/src/my lib')).toBe('my-lib');
+    expect((await root.indexing)?.files).toBe(1);
+    expect(formatRoot(root)).toBe(`dep-lib (${root.dir}): 1 file(s) indexed`);
+
+    const result = await searchCode(root.dir, 'Retry', {}, roots.get('dep-lib').index);
+    expect(result).toContain('retry.go');
+
+    expect(roots.add(root.dir).index).toBe(root.index);
+    expect(roots.names()).toEqual(['dep-lib']);
+    roots.remove('dep-lib');
+    expect(() => roots.get('dep-lib')).toThrow('unknown workspace "dep-lib"');
+  });
+
+  it('should refuse missing directories, the server workspace, and taken names', () => {
+    const roots = new WorkspaceRoots(workspace);
+    expect(() => roots.add('missing')).toThrow('no such directory: missing');
+    expect(() => roots.add('../dep lib/retry.go')).toThrow('not a directory');
+    expect(() => roots.add('.')).toThrow('is the server\'s workspace');
+    expect(() => roots.add('../dep lib', 'bad name')).toThrow('invalid workspace name');
+    roots.add('../dep lib', 'lib');
+    fs.mkdirSync(path.join(dir, 'other'));
+    expect(() => roots.add('../other', 'lib')).toThrow('workspace "lib" is already added for');
+  });
+
+  it('should only add directories under the allowed ones', () => {
+    expect(allowedRootDirs(workspace, {})).toEqual([dir]);
+    expect(allowedRootDirs(workspace, { WORKSPACE_ROOTS_ALLOWED: 'vendor, /opt/src' })).toEqual([path.join(workspace, 'vendor'), '/opt/src']);
+    expect(() => new WorkspaceRoots(workspace).add('/')).toThrow('is outside the directories workspaces can be added from');
+    const roots = new WorkspaceRoots(workspace, [path.join(dir, 'other')]);
+    expect(() => roots.add('../dep lib')).toThrow('is outside the directories workspaces can be added from');
+  });
+
+  it('should stop indexing a removed directory', async () => {
+    for (let i = 0; i < 50; i++) {
+      fs.writeFileSync(path.join(dir, 'dep lib', `f${i}.go`), `func F${i}() {}\n`);
+    }
+    const roots = new WorkspaceRoots(workspace);
+    const root = roots.add('../dep lib');
+    roots.remove('dep-lib');
+    expect((await root.indexing)?.files).toBe(0);
+  });
+});
@@ -0,0 +1,155 @@
+/**
+ * Workspace roots - local directories added while the server runs
+ * Each added root gets its own trigram index, built in the background as
+ * soon as it is added, and is searched with search_code's workspace argument.
+ * The server's own workspace is not a root and cannot be removed. Roots must
+ * lie under an allowed directory (WORKSPACE_ROOTS_ALLOWED, by default the
+ * workspace's parent), so adding / cannot step around the path checks that
+ * keep tools inside the workspace
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { TrigramIndex, TrigramIndexStats } from '../search/trigram.js';
+import { isUnder } from './paths.js';
+import { ToolError } from './errors.js';
+
+const rootsLogger = createLogger(Component.TOOLS);
+
+/**
+ * A directory added as a workspace
+ */
+export interface WorkspaceRoot {
+  name: string;
+  dir: string;
+  index: TrigramIndex;
+  addedAt: number;
+  // Settles when the first indexing pass finishes
+  indexing: Promise<TrigramIndexStats | undefined>;
+}
+
+/**
+ * Default name of a root: its directory name, with unsafe characters replaced
+ */
+export function rootName(dir: string): string {
+  return path.basename(dir).replace(/[^A-Za-z0-9._-]/g, '-') || 'workspace';
+}
+
+/**
+ * Directories roots may be added under, from WORKSPACE_ROOTS_ALLOWED (comma-separated, relative to the workspace)
+ * Defaults to the workspace's parent, for sibling checkouts
+ */
+export function allowedRootDirs(workspaceDir: string, env: NodeJS.ProcessEnv = process.env): string[] {
+  const dirs = (env.WORKSPACE_ROOTS_ALLOWED ?? '').split(',').map((dir) => dir.trim()).filter(Boolean);
+  return dirs.length > 0 ? dirs.map((dir) => path.resolve(workspaceDir, dir)) : [path.dirname(workspaceDir)];
+}
+
+/**
+ * Roots added with add_workspace
+ */
+export class WorkspaceRoots {
+  private roots = new Map<string, WorkspaceRoot>();
+  private allowed: string[];
+
+  /**
+   * @param workspaceDir The server's own workspace; relative directories are resolved against it
+   * @param allowedDirs Directories roots may be added under (default: allowedRootDirs())
+   */
+  constructor(private workspaceDir: string, allowedDirs: string[] = allowedRootDirs(workspaceDir)) {
+    this.allowed = allowedDirs.map((dir) => {
+      try {
+        return fs.realpathSync(dir);
+      } catch (err) {
+        return path.resolve(dir);
+      }
+    });
+  }
+
+  /**
+   * Add a directory and start indexing it
+   * Adding the same directory again refreshes its index
+   */
+  add(dir: string, name?: string): WorkspaceRoot {
+    const resolved = path.resolve(this.workspaceDir, dir);
+    let real: string;
+    try {
+      real = fs.realpathSync(resolved);
+    } catch (err) {
+      throw new ToolError('not-found', `no such directory: ${dir}`);
+    }
+    if (!fs.statSync(real).isDirectory()) {
+      throw new ToolError('invalid-argument', `not a directory: ${dir}`);
+    }
+    if (real === fs.realpathSync(this.workspaceDir)) {
+      throw new ToolError('invalid-argument', `${dir} is the server's workspace`);
+    }
+    if (!this.allowed.some((allowed) => isUnder(real, allowed))) {
+      throw new ToolError('invalid-argument', `${dir} is outside the directories workspaces can be added from (${this.allowed.join(', ')}); see WORKSPACE_ROOTS_ALLOWED`);
+    }
+    const key = name ?? rootName(real);
+    if (!/^[A-Za-z0-9._-]+$/.test(key)) {
+      throw new ToolError('invalid-argument', `invalid workspace name: ${key}`);
+    }
+    const existing = this.roots.get(key);
+    if (existing && existing.dir !== real) {
+      throw new ToolError('invalid-argument', `workspace "${key}" is already added for ${existing.dir}; pass a different name`);
+    }
+
+    const index = existing?.index ?? new TrigramIndex(real);
+    const indexing = index.refresh().then((stats) => {
+      rootsLogger.info('Indexed workspace %s: %d file(s) in %dms', key, stats.files, stats.durationMs);
+      return stats;
+    }, (err) => {
+      rootsLogger.error('Failed to index workspace %s: %s', key, (err as Error).message);
+      return undefined;
+    });
+    const root = { name: key, dir: real, index, addedAt: existing?.addedAt ?? Date.now(), indexing };
+    this.roots.set(key, root);
+    return root;
+  }
+
+  /**
+   * Remove a root, stopping its indexing; searches already running in it finish
+   */
+  remove(name: string): WorkspaceRoot {
+    const root = this.get(name);
+    this.roots.delete(name);
+    root.index.close();
+    return root;
+  }
+
+  /**
+   * An added root
+   */
+  get(name: string): WorkspaceRoot {
+    const root = this.roots.get(name);
+    if (!root) {
+      const names = this.names();
+      throw new ToolError('not-found', `unknown workspace "${name}"` + (names.length > 0 ? ` (added: ${names.join(', ')})` : ''));
+    }
+    return root;
+  }
+
+  /**
+   * Names of the added roots
+   */
+  names(): string[] {
+    return Array.from(this.roots.keys()).sort();
+  }
+
+  /**
+   * Added roots, by name
+   */
+  list(): WorkspaceRoot[] {
+    return this.names().map((name) => this.roots.get(name)!);
+  }
+}
+
+/**
+ * One line describing a root, e.g. "deps (/src/deps): 1200 files indexed"
+ */
+export function formatRoot(root: WorkspaceRoot): string {
+  const state = root.index.isLoaded() ? `${root.index.getStats().files} file(s) indexed` : 'indexing';
+  return `${root.name} (${root.dir}): ${state}`;
+}
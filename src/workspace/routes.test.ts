@@ -0,0 +1,120 @@
+/**
+ * Tests for HTTP route discovery
+ */
+
+import { routeMatchScore, scanDeclarations, scanRoutes, splitArguments, parseRouteQuery } from './routes';
+
+function routes(relativePath: string, content: string): string[] {
+  return scanRoutes(relativePath, content).map((route) =>
+    `${route.method} ${route.path} ${route.handler ?? '-'} (${route.framework}) :${route.line}`);
+}
+
+describe('scanRoutes', () => {
+  it('should split arguments at top-level commas', () => {
+    expect(splitArguments(' auth(), h.Get) // done')).toEqual(['auth()', 'h.Get']);
+    expect(splitArguments(' func(c *gin.Context) { c.JSON(200, x) })')).toEqual(['func(c *gin.Context) { c.JSON(200, x) }']);
+  });
+
+  it('should find net/http and gorilla/mux routes', () => {
+    const source = [
+      'package main',
+      '',
+      'func main() {',
+      '\thttp.HandleFunc("GET /users/{id}", getUser)',
+      '\tmux.Handle("/static/", http.HandlerFunc(serveStatic))',
+      '\tr.HandleFunc("/orders", createOrder).Methods("POST", "PUT")',
+      '}',
+    ].join('\n');
+    expect(routes('main.go', source)).toEqual([
+      'GET /users/{id} getUser (net/http) :4',
+      'ANY /static/ serveStatic (net/http) :5',
+      'POST /orders createOrder (net/http) :6',
+      'PUT /orders createOrder (net/http) :6',
+    ]);
+  });
+
+  it('should prefix gin groups and chi Route blocks', () => {
+    const gin = [
+      'import "github.com/gin-gonic/gin"',
+      '',
+      'api := r.Group("/api")',
+      'v1 := api.Group("/v1")',
+      'v1.GET("/users/:id", auth(), h.GetUser)',
+      'r.POST("/login", func(c *gin.Context) {',
+      '})',
+    ].join('\n');
+    expect(routes('server.go', gin)).toEqual([
+      'GET /api/v1/users/:id h.GetUser (gin) :5',
+      'POST /login (inline) (gin) :6',
+    ]);
+
+    const chi = [
+      'import "github.com/go-chi/chi/v5"',
+      '',
+      'r.Route("/articles", func(r chi.Router) {',
+      '\tr.Get("/{id}", getArticle)',
+      '})',
+      'r.Get("/health", health)',
+    ].join('\n');
+    expect(routes('router.go', chi)).toEqual([
+      'GET /articles/{id} getArticle (chi) :4',
+      'GET /health health (chi) :6',
+    ]);
+  });
+
+  it('should find Express and Fastify routes', () => {
+    const express = [
+      "const router = express.Router();",
+      "app.use('/api', router);",
+      "router.get('/users/:id', requireUser, getUser);",
+      "router.route('/posts')",
+      "  .get(listPosts)",
+      "  .post(createPost);",
+    ].join('\n');
+    expect(routes('app.js', express)).toEqual([
+      'GET /api/users/:id getUser (express) :3',
+      'GET /api/posts listPosts (express) :5',
+      'POST /api/posts createPost (express) :6',
+    ]);
+
+    const fastify = [
+      "const fastify = require('fastify')();",
+      'fastify.route({',
+      "  method: ['GET', 'HEAD'],",
+      "  url: '/items/:id',",
+      '  handler: getItem,',
+      '});',
+    ].join('\n');
+    expect(routes('server.ts', fastify)).toEqual([
+      'GET /items/:id getItem (fastify) :2',
+      'HEAD /items/:id getItem (fastify) :2',
+    ]);
+  });
+
+  it('should find handler declarations', () => {
+    expect(scanDeclarations('h.go', 'func (h *Handler) GetUser(c *gin.Context) {}\nfunc main() {}\n').map((d) => `${d.name}:${d.line}`))
+      .toEqual(['GetUser:1', 'main:2']);
+    expect(scanDeclarations('h.ts', 'export async function getUser(req) {}\nconst listPosts = async (req, res) => {};\n').map((d) => d.name))
+      .toEqual(['getUser', 'listPosts']);
+  });
+});
+
+describe('routeMatchScore', () => {
+  const route = (path: string, framework = 'gin') =>
+    ({ method: 'GET', path, framework, filePath: 'a.go', line: 1 });
+
+  it('should match parameters, catch-alls, and net/http subtrees', () => {
+    expect(routeMatchScore(route('/users/:id'), '/users/42')).toBe(1);
+    expect(routeMatchScore(route('/users/{id}'), '/users/:id')).toBe(1);
+    expect(routeMatchScore(route('/users/me'), '/users/42')).toBeUndefined();
+    expect(routeMatchScore(route('/users/me'), '/users/:id')).toBeUndefined();
+    expect(routeMatchScore(route('/files/*path'), '/files/a/b')).toBe(10);
+    expect(routeMatchScore(route('/static/', 'net/http'), '/static/css/site.css')).toBe(10);
+    expect(routeMatchScore(route('/static/'), '/static/css')).toBeUndefined();
+  });
+
+  it('should parse a method from the query', () => {
+    expect(parseRouteQuery('get /users/42')).toEqual({ method: 'GET', path: '/users/42' });
+    expect(parseRouteQuery('/users')).toEqual({ path: '/users' });
+  });
+});
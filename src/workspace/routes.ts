@@ -0,0 +1,407 @@
+/**
+ * HTTP route discovery
+ * Finds route registrations with their method, full path, and handler in Go
+ * (net/http, gorilla/mux, gin, echo, chi, fiber) and JavaScript/TypeScript
+ * (Express, Fastify), line by line. Group prefixes (gin and echo Group, chi
+ * Route blocks, Express routers mounted with use in the same file) are
+ * prepended to the paths of the routes under them
+ */
+
+import { createLogger, Component } from '../logging/logger.js';
+import { detectLanguageId } from './language.js';
+import { readFileText } from './overlay.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from './walker.js';
+
+const routesLogger = createLogger(Component.TOOLS);
+
+/**
+ * A route registration
+ */
+export interface Route {
+  method: string; // Upper case; ANY when the registration takes every method
+  path: string; // With group prefixes, parameters as written (:id, {id})
+  handler?: string; // As written, e.g. h.GetUser; "(inline)" for a function literal
+  framework: string;
+  filePath: string;
+  line: number; // 1-indexed
+}
+
+/**
+ * A function declaration a handler may name
+ */
+export interface HandlerDeclaration {
+  name: string;
+  filePath: string;
+  line: number;
+}
+
+const JS = ['javascript', 'javascriptreact', 'typescript', 'typescriptreact'];
+
+const METHODS = ['GET', 'POST', 'PUT', 'PATCH', 'DELETE', 'HEAD', 'OPTIONS'];
+
+/**
+ * Lines read ahead for arguments and route objects spanning several lines
+ */
+const LOOKAHEAD_LINES = 8;
+
+/**
+ * Split the arguments following an opening position, up to the closing parenthesis
+ * Commas inside brackets, braces, and strings do not split
+ */
+export function splitArguments(text: string): string[] {
+  const args: string[] = [];
+  let depth = 0;
+  let quote: string | null = null;
+  let start = 0;
+  for (let i = 0; i < text.length; i++) {
+    const c = text[i];
+    if (quote) {
+      if (c === '\\') {
+        i++;
+      } else if (c === quote) {
+        quote = null;
+      }
+    } else if (c === '"' || c === "'" || c === '`') {
+      quote = c;
+    } else if (c === '(' || c === '[' || c === '{') {
+      depth++;
+    } else if (c === ')' || c === ']' || c === '}') {
+      if (depth === 0) {
+        args.push(text.substring(start, i));
+        return args.map((arg) => arg.trim()).filter(Boolean);
+      }
+      depth--;
+    } else if (c === ',' && depth === 0) {
+      args.push(text.substring(start, i));
+      start = i + 1;
+    }
+  }
+  args.push(text.substring(start));
+  return args.map((arg) => arg.trim()).filter(Boolean);
+}
+
+/**
+ * Handler named by an argument expression
+ * Wrappers such as http.HandlerFunc(h) and a method value are unwrapped to h
+ */
+function handlerName(arg: string | undefined): string | undefined {
+  if (!arg) {
+    return undefined;
+  }
+  if (/^(?:func\b|async\b|function\b|\(|\w+\s*=>)/.test(arg)) {
+    return '(inline)';
+  }
+  const wrapped = /^[\w.]*(?:HandlerFunc|Handler|wrap\w*|Wrap\w*)\(\s*([\w.]+)\s*\)$/.exec(arg);
+  if (wrapped) {
+    return wrapped[1];
+  }
+  return /^[\w.]+/.exec(arg)?.[0];
+}
+
+/**
+ * Join a path prefix and a route path with one slash between them
+ */
+function joinPath(prefix: string, routePath: string): string {
+  if (!prefix) {
+    return routePath;
+  }
+  return (prefix.replace(/\/+$/, '') + '/' + routePath.replace(/^\/+/, '')).replace(/\/$/, '') || '/';
+}
+
+/**
+ * Framework of a Go file, from its imports
+ */
+function goFramework(content: string): string {
+  if (content.includes('github.com/gin-gonic/gin')) {
+    return 'gin';
+  }
+  if (content.includes('github.com/labstack/echo')) {
+    return 'echo';
+  }
+  if (content.includes('github.com/go-chi/chi')) {
+    return 'chi';
+  }
+  if (content.includes('github.com/gofiber/fiber')) {
+    return 'fiber';
+  }
+  if (content.includes('github.com/gorilla/mux')) {
+    return 'gorilla/mux';
+  }
+  return 'net/http';
+}
+
+/**
+ * The rest of a statement from a position, joined with the lines after it
+ */
+function statementFrom(lines: string[], index: number, offset: number): string {
+  return [lines[index].substring(offset), ...lines.slice(index + 1, index + LOOKAHEAD_LINES)].join('\n');
+}
+
+/**
+ * Find the routes of a Go file
+ */
+function scanGoRoutes(relativePath: string, lines: string[], framework: string): Route[] {
+  const routes: Route[] = [];
+  // Group prefixes by variable, and chi Route blocks by the brace depth they close at
+  const groups = new Map<string, string>();
+  const blocks: Array<{ prefix: string; depth: number }> = [];
+  let depth = 0;
+  const prefixOf = (receiver: string) =>
+    (blocks.length > 0 ? blocks[blocks.length - 1].prefix : '') + (groups.get(receiver) ?? '');
+
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i];
+    const group = /\b(\w+)\s*:?=\s*(\w+)\.Group\(\s*"([^"]*)"/.exec(line);
+    if (group) {
+      groups.set(group[1], joinPath(prefixOf(group[2]), group[3]));
+    }
+
+    const handle = /\b(\w+)\.(HandleFunc|Handle)\(\s*"([^"]+)"\s*,/.exec(line);
+    const verb = /\b(\w+)\.(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|Any|Get|Post|Put|Patch|Delete|Head|Options|All)\(\s*"(\/[^"]*)"\s*,/.exec(line);
+    const block = /\b(\w+)\.Route\(\s*"(\/[^"]*)"\s*,\s*func\b/.exec(line);
+    if (handle) {
+      const args = splitArguments(statementFrom(lines, i, handle.index! + handle[0].length));
+      // Go 1.22 patterns may start with a method: "GET /users/{id}"
+      const pattern = /^([A-Z]+)\s+(.*)$/.exec(handle[3]);
+      const methods = /\.Methods\(([^)]*)\)/.exec(line)?.[1].match(/[A-Z]+/g);
+      for (const method of pattern ? [pattern[1]] : methods ?? ['ANY']) {
+        routes.push({
+          method,
+          path: joinPath(prefixOf(handle[1]), pattern ? pattern[2] : handle[3]),
+          handler: handlerName(args[0]),
+          framework,
+          filePath: relativePath,
+          line: i + 1,
+        });
+      }
+    } else if (verb) {
+      const args = splitArguments(statementFrom(lines, i, verb.index! + verb[0].length));
+      const method = verb[2].toUpperCase();
+      routes.push({
+        method: method === 'ANY' || method === 'ALL' ? 'ANY' : method,
+        path: joinPath(prefixOf(verb[1]), verb[3]),
+        // Middlewares come first; the handler is the last argument
+        handler: handlerName(args[args.length - 1]),
+        framework,
+        filePath: relativePath,
+        line: i + 1,
+      });
+    }
+
+    const opened = (line.match(/{/g) ?? []).length;
+    const closed = (line.match(/}/g) ?? []).length;
+    if (block) {
+      blocks.push({ prefix: joinPath(prefixOf(block[1]), block[2]), depth });
+    }
+    depth += opened - closed;
+    while (blocks.length > 0 && depth <= blocks[blocks.length - 1].depth) {
+      blocks.pop();
+    }
+  }
+  return routes;
+}
+
+/**
+ * Find the routes of a JavaScript or TypeScript file
+ */
+function scanJsRoutes(relativePath: string, lines: string[], content: string): Route[] {
+  const framework = /\bfastify\b/.test(content) ? 'fastify' : 'express';
+  const routes: Route[] = [];
+  // Routers mounted in this file: app.use('/api', router)
+  const mounts = new Map<string, string>();
+  for (const line of lines) {
+    const use = /\b\w+\.use\(\s*['"`](\/[^'"`]*)['"`]\s*,\s*(\w+)\s*\)/.exec(line);
+    if (use) {
+      mounts.set(use[2], use[1]);
+    }
+  }
+
+  let chained: { receiver: string; path: string } | undefined;
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i];
+    const verb = /\b(\w+)\.(get|post|put|patch|delete|head|options|all)\(\s*['"`](\/[^'"`]*)['"`]\s*,/.exec(line);
+    const route = /\b(\w+)\.route\(\s*['"`](\/[^'"`]*)['"`]\s*\)/.exec(line);
+    const object = /\b(\w+)\.route\(\s*{/.exec(line);
+    if (verb) {
+      const args = splitArguments(statementFrom(lines, i, verb.index! + verb[0].length));
+      routes.push({
+        method: verb[2] === 'all' ? 'ANY' : verb[2].toUpperCase(),
+        path: joinPath(mounts.get(verb[1]) ?? '', verb[3]),
+        handler: handlerName(args[args.length - 1]),
+        framework,
+        filePath: relativePath,
+        line: i + 1,
+      });
+      chained = undefined;
+    } else if (route) {
+      // router.route('/users').get(list).post(create), on one line or several
+      chained = { receiver: route[1], path: route[2] };
+    } else if (object) {
+      // fastify.route({ method: 'GET', url: '/users', handler: list })
+      const text = statementFrom(lines, i, object.index! + object[0].length);
+      const body = text.substring(0, text.indexOf('})') >= 0 ? text.indexOf('})') : text.length);
+      const url = /\b(?:url|path)\s*:\s*['"`]([^'"`]+)['"`]/.exec(body)?.[1];
+      if (url) {
+        const methods = /\bmethod\s*:\s*(\[[^\]]*\]|['"`]\w+['"`])/.exec(body)?.[1].match(/\w+/g) ?? ['ANY'];
+        const handler = /\bhandler\s*:\s*([^,\n}]+)/.exec(body)?.[1] ?? (/\bhandler\s*\(/.test(body) ? '(inline)' : undefined);
+        for (const method of methods) {
+          routes.push({
+            method: method.toUpperCase(),
+            path: joinPath(mounts.get(object[1]) ?? '', url),
+            handler: handlerName(handler?.trim()),
+            framework,
+            filePath: relativePath,
+            line: i + 1,
+          });
+        }
+      }
+    }
+    if (chained) {
+      for (const call of line.matchAll(/\.(get|post|put|patch|delete|head|options|all)\(/g)) {
+        const args = splitArguments(line.substring(call.index! + call[0].length));
+        routes.push({
+          method: call[1] === 'all' ? 'ANY' : call[1].toUpperCase(),
+          path: joinPath(mounts.get(chained.receiver) ?? '', chained.path),
+          handler: handlerName(args[args.length - 1]),
+          framework,
+          filePath: relativePath,
+          line: i + 1,
+        });
+      }
+      if (!route && !/^\s*\./.test(lines[i + 1] ?? '')) {
+        chained = undefined;
+      }
+    }
+  }
+  return routes;
+}
+
+/**
+ * Find the routes registered in one file
+ */
+export function scanRoutes(relativePath: string, content: string): Route[] {
+  const languageId = detectLanguageId(relativePath);
+  const lines = content.split('\n');
+  if (languageId === 'go') {
+    return scanGoRoutes(relativePath, lines, goFramework(content));
+  }
+  if (JS.includes(languageId)) {
+    return scanJsRoutes(relativePath, lines, content);
+  }
+  return [];
+}
+
+/**
+ * Functions declared in a file, by name, for locating handlers
+ */
+export function scanDeclarations(relativePath: string, content: string): HandlerDeclaration[] {
+  const languageId = detectLanguageId(relativePath);
+  const pattern = languageId === 'go'
+    ? /^func\s+(?:\([^)]*\)\s*)?(\w+)\s*\(/
+    : JS.includes(languageId)
+      ? /^\s*(?:export\s+)?(?:(?:async\s+)?function\s*\*?\s*(\w+)|(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|\w+\s*=>))/
+      : undefined;
+  if (!pattern) {
+    return [];
+  }
+  const declarations: HandlerDeclaration[] = [];
+  content.split('\n').forEach((line, i) => {
+    const match = pattern.exec(line);
+    const name = match && (match[1] ?? match[2]);
+    if (name) {
+      declarations.push({ name, filePath: relativePath, line: i + 1 });
+    }
+  });
+  return declarations;
+}
+
+/**
+ * Segments of a path, without the query and surrounding slashes
+ */
+function segments(routePath: string): string[] {
+  return routePath.split('?')[0].split('/').filter(Boolean);
+}
+
+/**
+ * Check if a path segment is a parameter: :id, {id}, {id:[0-9]+}, <id>, or <int:id>
+ */
+function isParameter(segment: string): boolean {
+  return /^:\w+\??$|^\{[^}]+\}$|^<[^>]+>$/.test(segment);
+}
+
+/**
+ * Check if a path segment matches the rest of the path: *, *rest, or {rest...}
+ */
+function isCatchAll(segment: string): boolean {
+  return /^\*\w*$|^\{\w+\.\.\.\}$/.test(segment);
+}
+
+/**
+ * How well a route's path matches a request path, or undefined when it does not
+ * Lower is more specific: literal segments score 0, parameters 1, and a
+ * catch-all 10. The request path may use parameters itself (/users/:id),
+ * which match the route's parameters only. Go net/http patterns ending in a
+ * slash match everything under them
+ */
+export function routeMatchScore(route: Route, requestPath: string): number | undefined {
+  const routeSegments = segments(route.path);
+  const requestSegments = segments(requestPath);
+  const subtree = (route.framework === 'net/http' || route.framework === 'chi') && route.path.endsWith('/');
+  let score = 0;
+  for (let i = 0; i < routeSegments.length; i++) {
+    const routeSegment = routeSegments[i];
+    if (isCatchAll(routeSegment)) {
+      return score + 10;
+    }
+    const requestSegment = requestSegments[i];
+    if (requestSegment === undefined) {
+      return undefined;
+    }
+    if (isParameter(routeSegment)) {
+      score += 1;
+    } else if (isParameter(requestSegment) || routeSegment !== requestSegment) {
+      return undefined;
+    }
+  }
+  if (requestSegments.length > routeSegments.length) {
+    return subtree ? score + 10 : undefined;
+  }
+  return score;
+}
+
+/**
+ * Parse a route query such as "GET /users/42" or "/users/:id"
+ */
+export function parseRouteQuery(query: string): { method?: string; path: string } {
+  const match = /^\s*([A-Za-z]+)\s+(\S+)\s*$/.exec(query);
+  if (match && METHODS.includes(match[1].toUpperCase())) {
+    return { method: match[1].toUpperCase(), path: match[2] };
+  }
+  return { path: query.trim() };
+}
+
+/**
+ * Find the routes and function declarations of every Go, JavaScript, and TypeScript file
+ */
+export async function findRoutes(workspaceDir: string, pathPrefix?: string): Promise<{ routes: Route[]; declarations: HandlerDeclaration[] }> {
+  const prefix = pathPrefix ? resolveWorkspacePath(workspaceDir, pathPrefix) : undefined;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: prefix }))
+    .filter((file) => {
+      const languageId = detectLanguageId(file.relativePath);
+      return languageId === 'go' || JS.includes(languageId);
+    });
+
+  const routes: Route[] = [];
+  const declarations: HandlerDeclaration[] = [];
+  for (const file of files) {
+    try {
+      const content = await readFileText(file.absolutePath);
+      routes.push(...scanRoutes(file.relativePath, content));
+      declarations.push(...scanDeclarations(file.relativePath, content));
+    } catch (err) {
+      routesLogger.debug('Could not scan %s: %s', file.relativePath, (err as Error).message);
+    }
+  }
+  routesLogger.debug('Found %d route(s) in %d file(s)', routes.length, files.length);
+  return { routes, declarations };
+}
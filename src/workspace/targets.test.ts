@@ -0,0 +1,95 @@
+/**
+ * Tests for Makefile, Taskfile, and justfile target discovery
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { listBuildTargets } from '../tools/targets';
+import { parseJustfile, parseMakefile, parseTaskfile } from './targets';
+
+const MAKEFILE = [
+  'GO ?= go',
+  'PKGS := $(shell $(GO) list ./...)',
+  '.PHONY: build test',
+  '',
+  'build: ## Build the binary',
+  '\t$(GO) build -o bin/app \\',
+  '\t  ./cmd/app',
+  '',
+  '# Run the unit tests',
+  'test: build | tools',
+  '\t$(GO) test -race $(PKGS)',
+  'test: GOFLAGS += -count=1',
+  '',
+  'lint fmt: ; golangci-lint run',
+].join('\n');
+
+const TASKFILE = `version: '3'
+tasks:
+  test:
+    desc: Run the tests
+    deps: [generate]
+    cmds:
+      - go test ./...
+      - task: lint
+  generate: go generate ./...
+`;
+
+const JUSTFILE = `set dotenv-load
+default_env := "dev"
+
+# Run the server
+[no-cd]
+serve port="8080": build
+    cargo run -- --port {{port}}
+
+@build:
+    cargo build
+`;
+
+describe('Build targets', () => {
+  it('should parse Makefile rules, help text, and recipes', () => {
+    const targets = parseMakefile(MAKEFILE);
+    expect(targets.map((target) => target.name)).toEqual(['build', 'test', 'lint', 'fmt']);
+    expect(targets[0]).toMatchObject({ line: 5, description: 'Build the binary', recipe: [{ line: 6, text: '$(GO) build -o bin/app ./cmd/app' }] });
+    expect(targets[1]).toMatchObject({ description: 'Run the unit tests', dependencies: ['build', 'tools'] });
+    expect(targets[1].recipe).toEqual([{ line: 11, text: '$(GO) test -race $(PKGS)' }]);
+    expect(targets[3].recipe).toEqual([{ line: 14, text: 'golangci-lint run' }]);
+  });
+
+  it('should parse Taskfile tasks and justfile recipes', () => {
+    const tasks = parseTaskfile(TASKFILE);
+    expect(tasks.map((task) => [task.name, task.line, task.description])).toEqual([['test', 3, 'Run the tests'], ['generate', 9, undefined]]);
+    expect(tasks[0].dependencies).toEqual(['generate']);
+    expect(tasks[0].recipe.map((line) => line.text)).toEqual(['go test ./...', 'task: lint']);
+    expect(tasks[1].recipe).toEqual([{ line: 9, text: 'go generate ./...' }]);
+
+    const recipes = parseJustfile(JUSTFILE);
+    expect(recipes.map((recipe) => [recipe.name, recipe.line, recipe.description])).toEqual([['serve', 6, 'Run the server'], ['build', 9, undefined]]);
+    expect(recipes[0].dependencies).toEqual(['build']);
+    expect(recipes[0].recipe).toEqual([{ line: 7, text: 'cargo run -- --port {{port}}' }]);
+  });
+
+  it('should list targets and search recipes', async () => {
+    const workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'targets-'));
+    try {
+      fs.writeFileSync(path.join(workspace, 'Makefile'), MAKEFILE);
+      fs.mkdirSync(path.join(workspace, 'web'));
+      fs.writeFileSync(path.join(workspace, 'web', 'Taskfile.yml'), TASKFILE);
+
+      const listing = await listBuildTargets(workspace);
+      expect(listing).toContain('Found 6 target(s) in 2 file(s)');
+      expect(listing).toContain('L10: test (depends on build, tools) - Run the unit tests\n  L11: $(GO) test -race $(PKGS)');
+
+      const search = await listBuildTargets(workspace, { pattern: 'go test' });
+      expect(search).toContain('web/Taskfile.yml (task)');
+      expect(search).toContain('L7: go test ./...');
+      expect(search).not.toContain('Makefile (make)');
+
+      expect(await listBuildTargets(workspace, { target: 'tes' })).toBe('No target named tes; similar: test');
+    } finally {
+      fs.rmSync(workspace, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,278 @@
+/**
+ * Build target discovery - Makefiles, Taskfiles, and justfiles
+ * Reads the targets each file defines with their description, dependencies,
+ * and recipe, so "how do I run the tests here" is answered from the files
+ * rather than by guessing a command
+ */
+
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { StructuredNode, parseYamlNodes } from '../search/structured.js';
+import { readFileText } from './overlay.js';
+import { walkWorkspaceFiles, resolveWorkspacePath } from './walker.js';
+
+const targetsLogger = createLogger(Component.TOOLS);
+
+/**
+ * Build tool a file belongs to
+ */
+export type BuildTool = 'make' | 'task' | 'just';
+
+/**
+ * A recipe line with its 1-indexed line number
+ */
+export interface RecipeLine {
+  line: number;
+  text: string;
+}
+
+/**
+ * A target and how it is built
+ */
+export interface BuildTarget {
+  filePath: string; // Relative to the workspace
+  tool: BuildTool;
+  name: string;
+  line: number; // 1-indexed
+  description?: string;
+  dependencies: string[];
+  recipe: RecipeLine[];
+}
+
+/**
+ * Build tool of a file, from its name
+ */
+export function buildToolFor(filePath: string): BuildTool | undefined {
+  const name = path.basename(filePath);
+  if (/^(GNUmakefile|[Mm]akefile)$/.test(name) || name.endsWith('.mk')) {
+    return 'make';
+  }
+  if (/^[Tt]askfile(\.dist)?\.ya?ml$/.test(name)) {
+    return 'task';
+  }
+  if (/^\.?[Jj]ustfile$/.test(name) || name.endsWith('.just')) {
+    return 'just';
+  }
+  return undefined;
+}
+
+/**
+ * Join backslash-continued lines, keeping each logical line's first line number
+ */
+function logicalLines(content: string): RecipeLine[] {
+  const lines = content.split('\n').map((line) => line.replace(/\r$/, ''));
+  const result: RecipeLine[] = [];
+  for (let i = 0; i < lines.length; i++) {
+    const start = i;
+    let text = lines[i];
+    while (text.endsWith('\\') && i + 1 < lines.length) {
+      text = text.slice(0, -1).trimEnd() + ' ' + lines[++i].trim();
+    }
+    result.push({ line: start + 1, text });
+  }
+  return result;
+}
+
+/**
+ * Comment lines directly above a line, as its description
+ */
+function commentAbove(lines: RecipeLine[], index: number): string | undefined {
+  const comments: string[] = [];
+  for (let i = index - 1; i >= 0 && /^\s*#/.test(lines[i].text); i--) {
+    comments.unshift(lines[i].text.replace(/^\s*#+\s?/, '').trim());
+  }
+  const text = comments.filter(Boolean).join(' ');
+  return text || undefined;
+}
+
+/**
+ * Parse the targets of a Makefile
+ * Rules are "targets: prerequisites" with tab-indented recipes; special
+ * targets (.PHONY and the like) and variable assignments are not targets.
+ * A "## text" comment after the rule, as the self-documenting help
+ * convention writes it, or the comments above the rule describe it
+ */
+export function parseMakefile(content: string, filePath = 'Makefile'): BuildTarget[] {
+  const lines = logicalLines(content);
+  const targets: BuildTarget[] = [];
+  let current: BuildTarget[] = [];
+
+  for (let i = 0; i < lines.length; i++) {
+    const { text, line } = lines[i];
+    if (text.startsWith('\t')) {
+      const command = text.trim();
+      if (command && !command.startsWith('#')) {
+        current.forEach((target) => target.recipe.push({ line, text: command }));
+      }
+      continue;
+    }
+    if (text.trim() === '' || /^\s*#/.test(text)) {
+      continue;
+    }
+    // "a b: deps ## help", but not "x := y", "x ?= y", or "x = a:b"
+    const rule = text.match(/^([^\s:#=][^:=#]*?)\s*::?(?![:=])(.*)$/);
+    if (!rule || /^\s*(export|override|define|include|-include|sinclude|ifn?eq|ifn?def|else|endif)\b/.test(text)) {
+      current = [];
+      continue;
+    }
+    const [, names, rest] = rule;
+    // Target-specific variables ("test: GOFLAGS += -race") belong to an earlier rule
+    if (/^\s*[\w.]+\s*[:+?!]?=/.test(rest)) {
+      continue;
+    }
+    const help = rest.match(/##\s*(.*)$/)?.[1]?.trim();
+    // A ";" starts a recipe on the rule line
+    const [prerequisites, inline] = rest.replace(/#.*$/, '').split(';', 2);
+    current = names.split(/\s+/).filter((name) => name && !name.startsWith('.') && !name.includes('$(')).map((name) => ({
+      filePath,
+      tool: 'make' as const,
+      name,
+      line,
+      description: help || commentAbove(lines, i),
+      // Order-only prerequisites (after "|") are still dependencies
+      dependencies: prerequisites.split(/\s+/).filter((dep) => dep && dep !== '|'),
+      recipe: inline?.trim() ? [{ line, text: inline.trim() }] : [],
+    }));
+    targets.push(...current);
+  }
+  return targets;
+}
+
+/**
+ * Parse the recipes of a justfile
+ * Recipes are "name params: dependencies" at the start of a line with an
+ * indented body; settings, aliases, imports, and assignments are skipped
+ */
+export function parseJustfile(content: string, filePath = 'justfile'): BuildTarget[] {
+  const lines = logicalLines(content);
+  const targets: BuildTarget[] = [];
+  let current: BuildTarget | undefined;
+
+  for (let i = 0; i < lines.length; i++) {
+    const { text, line } = lines[i];
+    if (/^\s/.test(text)) {
+      const command = text.trim();
+      if (current && command && !command.startsWith('#')) {
+        current.recipe.push({ line, text: command });
+      }
+      continue;
+    }
+    if (text.trim() === '' || text.startsWith('#') || text.startsWith('[')) {
+      continue;
+    }
+    current = undefined;
+    if (/^(set|alias|export|import|mod)\s/.test(text) || /^[\w-]+\s*:=/.test(text)) {
+      continue;
+    }
+    const recipe = text.match(/^@?([A-Za-z_][\w-]*)((?:\s+[^:]+?)?)\s*:(?!=)\s*(.*)$/);
+    if (!recipe) {
+      continue;
+    }
+    const [, name, , dependencies] = recipe;
+    // Comments over [attributes] still describe the recipe
+    let docIndex = i;
+    while (docIndex > 0 && lines[docIndex - 1].text.startsWith('[')) {
+      docIndex--;
+    }
+    current = {
+      filePath,
+      tool: 'just',
+      name,
+      line,
+      description: commentAbove(lines, docIndex),
+      dependencies: dependencies.replace(/#.*$/, '').trim().split(/\s+/).filter((dep) => dep && dep !== '&&').map((dep) => dep.replace(/^\(|\)$/g, '')),
+      recipe: [],
+    };
+    targets.push(current);
+  }
+  return targets;
+}
+
+/**
+ * Scalar text of a node, or undefined for collections
+ */
+function scalar(node: StructuredNode | undefined): string | undefined {
+  return node?.kind === 'scalar' ? node.value : undefined;
+}
+
+/**
+ * Value of a mapping key
+ */
+function entry(node: StructuredNode, key: string): StructuredNode | undefined {
+  return node.kind === 'map' ? node.entries.find((e) => e.key === key)?.value : undefined;
+}
+
+/**
+ * Parse the tasks of a Taskfile
+ * A task is a command string, a list of commands, or a mapping with desc,
+ * deps, and cmds; commands that call another task are shown as "task: name"
+ */
+export function parseTaskfile(content: string, filePath = 'Taskfile.yml'): BuildTarget[] {
+  const root = parseYamlNodes(content)[0];
+  const tasks = root ? entry(root, 'tasks') : undefined;
+  if (!tasks || tasks.kind !== 'map') {
+    return [];
+  }
+
+  const commands = (node: StructuredNode | undefined): RecipeLine[] => {
+    if (!node) {
+      return [];
+    }
+    const items = node.kind === 'seq' ? node.items : [node];
+    return items.flatMap((item) => {
+      const text = scalar(item) ?? scalar(entry(item, 'cmd')) ??
+        (entry(item, 'task') ? `task: ${scalar(entry(item, 'task'))}` : undefined);
+      return text !== undefined ? text.split('\n').filter((part) => part.trim()).map((part) => ({ line: item.line, text: part.trim() })) : [];
+    });
+  };
+
+  return tasks.entries.map((task) => {
+    const body = task.value;
+    const isMap = body.kind === 'map';
+    const deps = isMap ? entry(body, 'deps') : undefined;
+    return {
+      filePath,
+      tool: 'task' as const,
+      name: task.key,
+      line: task.keyPosition.line,
+      description: isMap ? scalar(entry(body, 'desc')) ?? scalar(entry(body, 'summary')) : undefined,
+      dependencies: deps?.kind === 'seq' ? deps.items.map((dep) => scalar(dep) ?? scalar(entry(dep, 'task')) ?? '').filter(Boolean) : [],
+      recipe: commands(isMap ? entry(body, 'cmds') : body),
+    };
+  });
+}
+
+/**
+ * Parse the targets of a build file
+ */
+export function parseBuildTargets(content: string, filePath: string): BuildTarget[] {
+  switch (buildToolFor(filePath)) {
+    case 'make':
+      return parseMakefile(content, filePath);
+    case 'task':
+      return parseTaskfile(content, filePath);
+    case 'just':
+      return parseJustfile(content, filePath);
+    default:
+      return [];
+  }
+}
+
+/**
+ * Find the targets of every Makefile, Taskfile, and justfile in the workspace
+ */
+export async function findBuildTargets(workspaceDir: string, pathPrefix?: string): Promise<BuildTarget[]> {
+  const prefix = pathPrefix ? resolveWorkspacePath(workspaceDir, pathPrefix) : undefined;
+  const files = (await walkWorkspaceFiles(workspaceDir, { pathPrefix: prefix }))
+    .filter((file) => buildToolFor(file.relativePath) !== undefined);
+
+  const targets: BuildTarget[] = [];
+  for (const file of files) {
+    try {
+      targets.push(...parseBuildTargets(await readFileText(file.absolutePath), file.relativePath));
+    } catch (err) {
+      targetsLogger.debug('Could not read build targets from %s: %s', file.relativePath, (err as Error).message);
+    }
+  }
+  return targets;
+}
@@ -0,0 +1,47 @@
+/**
+ * Tests for tool call limits
+ */
+
+import { CallLimiter, ThrottledError, callLimitsFromEnv } from './throttle';
+
+describe('CallLimiter', () => {
+  it('should cap concurrent calls per session', () => {
+    const limiter = new CallLimiter(() => ({ maxConcurrent: 2, perMinute: 0 }));
+    const first = limiter.acquire('a');
+    limiter.acquire('a');
+    expect(() => limiter.acquire('a')).toThrow(ThrottledError);
+    // Other sessions have their own limits
+    expect(() => limiter.acquire('b')).not.toThrow();
+    first();
+    first(); // Releasing twice frees one slot
+    expect(() => limiter.acquire('a')).not.toThrow();
+    expect(() => limiter.acquire('a')).toThrow('Too many concurrent tool calls (limit 2)');
+  });
+
+  it('should cap calls per minute and say when to retry', () => {
+    const limiter = new CallLimiter(() => ({ maxConcurrent: 0, perMinute: 2 }));
+    limiter.acquire('a', 0)();
+    limiter.acquire('a', 10_000)();
+    let error: ThrottledError | undefined;
+    try {
+      limiter.acquire('a', 20_000);
+    } catch (err) {
+      error = err as ThrottledError;
+    }
+    expect(error?.toJSON()).toMatchObject({ error: 'throttled', reason: 'rate', limit: 2, retryAfterMs: 40_000 });
+    expect(() => limiter.acquire('a', 60_000)).not.toThrow();
+  });
+
+  it('should start a session again once it is forgotten', () => {
+    const limiter = new CallLimiter(() => ({ maxConcurrent: 1, perMinute: 0 }));
+    limiter.acquire('a');
+    expect(() => limiter.acquire('a')).toThrow(ThrottledError);
+    limiter.forget('a');
+    expect(() => limiter.acquire('a')).not.toThrow();
+  });
+
+  it('should read limits from the environment, unlimited by default', () => {
+    expect(callLimitsFromEnv({})).toEqual({ maxConcurrent: 0, perMinute: 0 });
+    expect(callLimitsFromEnv({ TOOL_MAX_CONCURRENT_CALLS: '4', TOOL_CALLS_PER_MINUTE: 'x' })).toEqual({ maxConcurrent: 4, perMinute: 0 });
+  });
+});
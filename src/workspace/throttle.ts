@@ -0,0 +1,110 @@
+/**
+ * Tool call limits per client session
+ * Caps how many tool calls a session runs at once and how many it starts per
+ * minute, so one runaway agent loop cannot starve other clients or the host.
+ * Calls over a limit are refused with a ThrottledError saying when to retry
+ */
+
+/**
+ * Window the per-minute limit counts calls in
+ */
+const RATE_WINDOW_MS = 60_000;
+
+/**
+ * Limits on tool calls per session; 0 means unlimited
+ */
+export interface CallLimits {
+  maxConcurrent: number;
+  perMinute: number;
+}
+
+/**
+ * Limits from TOOL_MAX_CONCURRENT_CALLS and TOOL_CALLS_PER_MINUTE (default: unlimited)
+ */
+export function callLimitsFromEnv(env: NodeJS.ProcessEnv = process.env): CallLimits {
+  const read = (name: string) => {
+    const value = env[name] ? parseInt(env[name]!, 10) : 0;
+    return Number.isFinite(value) && value > 0 ? value : 0;
+  };
+  return { maxConcurrent: read('TOOL_MAX_CONCURRENT_CALLS'), perMinute: read('TOOL_CALLS_PER_MINUTE') };
+}
+
+/**
+ * A tool call refused because its session is over a limit
+ */
+export class ThrottledError extends Error {
+  constructor(
+    public readonly reason: 'concurrency' | 'rate',
+    public readonly limit: number,
+    public readonly retryAfterMs: number,
+  ) {
+    super(reason === 'concurrency'
+      ? `Too many concurrent tool calls (limit ${limit}); retry when one finishes`
+      : `Too many tool calls (limit ${limit} per minute); retry in ${Math.ceil(retryAfterMs / 1000)}s`);
+    this.name = 'ThrottledError';
+  }
+
+  /**
+   * Machine-readable form, for the tool result
+   */
+  toJSON(): Record<string, unknown> {
+    return { error: 'throttled', reason: this.reason, limit: this.limit, retryAfterMs: this.retryAfterMs, message: this.message };
+  }
+}
+
+/**
+ * Running and recent calls of one session
+ */
+interface SessionCalls {
+  running: number;
+  started: number[]; // Start times within the rate window, oldest first
+}
+
+/**
+ * Tracks calls per session against the limits
+ * Limits are read on every call, so a configuration reload applies at once
+ */
+export class CallLimiter {
+  private sessions = new Map<string, SessionCalls>();
+
+  constructor(private limits: () => CallLimits = () => callLimitsFromEnv()) {}
+
+  /**
+   * Start a call for a session, or throw ThrottledError when it is over a limit
+   * Returns the function that ends the call
+   */
+  acquire(session: string, now = Date.now()): () => void {
+    const { maxConcurrent, perMinute } = this.limits();
+    let calls = this.sessions.get(session);
+    if (!calls) {
+      calls = { running: 0, started: [] };
+      this.sessions.set(session, calls);
+    }
+    while (calls.started.length > 0 && calls.started[0] <= now - RATE_WINDOW_MS) {
+      calls.started.shift();
+    }
+    if (maxConcurrent > 0 && calls.running >= maxConcurrent) {
+      throw new ThrottledError('concurrency', maxConcurrent, 0);
+    }
+    if (perMinute > 0 && calls.started.length >= perMinute) {
+      throw new ThrottledError('rate', perMinute, calls.started[calls.started.length - perMinute] + RATE_WINDOW_MS - now);
+    }
+    calls.running++;
+    calls.started.push(now);
+
+    let released = false;
+    return () => {
+      if (!released) {
+        released = true;
+        calls!.running--;
+      }
+    };
+  }
+
+  /**
+   * Drop the calls of a session that ended
+   */
+  forget(session: string): void {
+    this.sessions.delete(session);
+  }
+}
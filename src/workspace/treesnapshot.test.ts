@@ -0,0 +1,103 @@
+/**
+ * Tests for workspace snapshots
+ */
+
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { SnapshotStore, currentSnapshot, takeSnapshot, unpinned, withSnapshot } from './treesnapshot';
+import { readFileBytes, readFileText, sharedOverlay } from './overlay';
+import { searchLexical } from '../search/lexical';
+
+describe('workspace snapshots', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.realpathSync(fs.mkdtempSync(path.join(os.tmpdir(), 'treesnapshot-')));
+    const git = (...args: string[]) => execFileSync('git', args, { cwd: workspace, stdio: 'ignore' });
+    git('init', '-q');
+    fs.writeFileSync(path.join(workspace, 'a.ts'), 'export const a = 1;\n');
+    fs.writeFileSync(path.join(workspace, 'b.ts'), 'export const b = 1;\n');
+    fs.writeFileSync(path.join(workspace, '.gitignore'), 'local.env\n');
+    git('add', '.');
+    git('-c', 'user.name=test', '-c', 'user.email=test@example.com', 'commit', '-qm', 'init');
+    fs.writeFileSync(path.join(workspace, 'b.ts'), 'export const b = 2;\n');
+    fs.writeFileSync(path.join(workspace, 'local.env'), 'A=1\n');
+  });
+
+  afterEach(() => {
+    sharedOverlay().clear();
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should serve reads as of the snapshot while the files keep changing', async () => {
+    sharedOverlay().set(path.join(workspace, 'c.ts'), 'export const c = 1;\n');
+    const snapshot = await takeSnapshot(workspace);
+    expect(snapshot.changedFiles()).toEqual(['b.ts', 'c.ts']);
+    expect((await takeSnapshot(workspace)).id).toBe(snapshot.id);
+
+    // Edits make mtimes later than the snapshot
+    const later = new Date(Date.now() + 60_000);
+    for (const [file, content] of [['a.ts', 'export const a = 9;\n'], ['b.ts', 'export const b = 3;\n'], ['local.env', 'A=2\n'], ['new.ts', 'x\n']]) {
+      fs.writeFileSync(path.join(workspace, file), content);
+      fs.utimesSync(path.join(workspace, file), later, later);
+    }
+    sharedOverlay().clear();
+
+    await withSnapshot(snapshot, async () => {
+      expect(currentSnapshot()).toBe(snapshot);
+      expect(await readFileText(path.join(workspace, 'a.ts'))).toBe('export const a = 1;\n');
+      expect(await readFileText(path.join(workspace, 'b.ts'))).toBe('export const b = 2;\n');
+      expect(await readFileText(path.join(workspace, 'c.ts'))).toBe('export const c = 1;\n');
+      expect((await readFileBytes(path.join(workspace, 'local.env'))).toString()).toBe('A=2\n');
+      await expect(readFileText(path.join(workspace, 'new.ts'))).rejects.toThrow('does not exist in snapshot');
+      expect(await unpinned(() => readFileText(path.join(workspace, 'a.ts')))).toBe('export const a = 9;\n');
+
+      const result = await searchLexical(workspace, 'const a = 1');
+      expect(result.matches.map((match) => match.filePath)).toEqual(['a.ts']);
+      // Files created since are not searched, and ones deleted since still are
+      fs.rmSync(path.join(workspace, 'a.ts'));
+      const files = (await searchLexical(workspace, 'export const')).matches.map((match) => match.filePath);
+      expect(files.sort()).toEqual(['a.ts', 'b.ts', 'c.ts']);
+    });
+    expect(await readFileText(path.join(workspace, 'b.ts'))).toBe('export const b = 3;\n');
+  });
+
+  it('should keep a snapshot while a session is pinned to it', async () => {
+    const store = new SnapshotStore();
+    const snapshot = await takeSnapshot(workspace);
+    store.pin('a', snapshot);
+    store.pin('b', await takeSnapshot(workspace));
+    expect(store.pinned('b')).toBe(snapshot);
+    expect(store.list()).toEqual([{ snapshot, sessions: 2 }]);
+    expect(store.release('a')).toBe(snapshot);
+    expect(store.get(snapshot.id)).toBe(snapshot);
+    store.release('b');
+    expect(store.get(snapshot.id)).toBeUndefined();
+    expect(store.release('b')).toBeUndefined();
+  });
+
+  it('should refuse to record or keep more content than the limit', async () => {
+    fs.writeFileSync(path.join(workspace, 'big.ts'), 'x'.repeat(100));
+    expect((await takeSnapshot(workspace, 50).catch((e) => e)).message).toContain('more than a snapshot may record');
+    const snapshot = await takeSnapshot(workspace);
+    const store = new SnapshotStore(snapshot.size() + 10);
+    store.pin('a', snapshot);
+    fs.writeFileSync(path.join(workspace, 'b.ts'), 'export const b = 4;\n');
+    const other = await takeSnapshot(workspace);
+    expect(() => store.pin('b', other)).toThrow('over the');
+    // Replacing the session's own snapshot frees its room
+    store.pin('a', other);
+    expect(store.pinned('a')).toBe(other);
+  });
+
+  it('should need a git repository', async () => {
+    const plain = fs.mkdtempSync(path.join(os.tmpdir(), 'treesnapshot-plain-'));
+    try {
+      await expect(takeSnapshot(plain)).rejects.toThrow('is not in a git repository');
+    } finally {
+      fs.rmSync(plain, { recursive: true, force: true });
+    }
+  });
+});
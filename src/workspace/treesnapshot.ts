@@ -0,0 +1,298 @@
+/**
+ * Workspace snapshots for reproducible agent runs
+ * A snapshot records the git commit and the content of every file that
+ * differs from it (uncommitted changes, untracked files, and overlays).
+ * Reads of a session pinned to a snapshot see the files as they were when
+ * it was taken: files changed since come from the recorded content or from
+ * git, so a long run sees one view of the code while the user keeps editing
+ */
+
+import { AsyncLocalStorage } from 'async_hooks';
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
+import { fileBytesAtRevision, filesAtRevision, runGit, workingChanges } from '../git/git.js';
+import { ToolError } from './errors.js';
+import { sharedOverlay } from './overlay.js';
+import { isUnder } from './paths.js';
+
+/**
+ * Most content snapshots may hold together: what one records, and what the kept ones hold
+ */
+const MAX_SNAPSHOT_BYTES = 256 * 1024 * 1024;
+
+/**
+ * Error for a file the snapshot does not have, shaped like fs errors
+ */
+function missingFile(filePath: string, id: string): Error {
+  return Object.assign(new Error(`ENOENT: ${filePath} does not exist in snapshot ${id}`), { code: 'ENOENT' });
+}
+
+/**
+ * The files of a workspace as they were at one moment
+ */
+export class WorkspaceSnapshot {
+  // Files committed content was fetched for, because they changed since; at most MAX_SNAPSHOT_BYTES
+  private fetched = new Map<string, Buffer>();
+  private fetchedBytes = 0;
+
+  constructor(
+    readonly id: string,
+    readonly workspaceDir: string,
+    readonly commit: string,
+    readonly createdAt: number,
+    // Content of the files differing from the commit, by relative path; null when deleted
+    private changed: Map<string, Buffer | null>,
+    // Files of the commit, by relative path
+    private tracked: Set<string>,
+  ) {}
+
+  /**
+   * Relative paths of the files that differed from the commit
+   */
+  changedFiles(): string[] {
+    return Array.from(this.changed.keys()).sort();
+  }
+
+  /**
+   * Workspace-relative files as they were: the commit's files and the changed
+   * ones, less those deleted then. Files on disk now that the snapshot lacks
+   * were created since and are left out; ones it has that are gone now are
+   * added, within the prefix and unless excluded
+   */
+  files(current: string[], prefix: string, excluded: (relativePath: string) => boolean): string[] {
+    const exists = (relativePath: string) => {
+      const recorded = this.changed.get(relativePath);
+      return recorded !== undefined ? recorded !== null : this.tracked.has(relativePath);
+    };
+    const files = current.filter(exists);
+    const listed = new Set(files);
+    const inPrefix = (relativePath: string) => !prefix || relativePath === prefix || relativePath.startsWith(prefix + path.sep);
+    const gone = [...this.tracked, ...this.changed.keys()]
+      .filter((relativePath) => !listed.has(relativePath) && exists(relativePath) && inPrefix(relativePath) && !excluded(relativePath));
+    return [...files, ...new Set(gone)];
+  }
+
+  /**
+   * Bytes of recorded content
+   */
+  size(): number {
+    let total = 0;
+    for (const content of this.changed.values()) {
+      total += content?.length ?? 0;
+    }
+    return total;
+  }
+
+  /**
+   * Whether reads of a file go to the snapshot; files outside the workspace do not
+   */
+  covers(filePath: string): boolean {
+    return isUnder(path.resolve(filePath), this.workspaceDir);
+  }
+
+  /**
+   * Content of a file as it was when the snapshot was taken
+   * Files created since do not exist; ignored files are read as they are now
+   */
+  async read(filePath: string): Promise<Buffer> {
+    const relativePath = path.relative(this.workspaceDir, path.resolve(filePath));
+    const recorded = this.changed.get(relativePath);
+    if (recorded !== undefined) {
+      if (recorded === null) {
+        throw missingFile(filePath, this.id);
+      }
+      return recorded;
+    }
+    const fetched = this.fetched.get(relativePath);
+    if (fetched) {
+      return fetched;
+    }
+    const stat = await fs.promises.stat(filePath).catch(() => undefined);
+    if (stat && stat.mtimeMs <= this.createdAt) {
+      return fs.promises.readFile(filePath);
+    }
+    if (this.tracked.has(relativePath)) {
+      const content = await fileBytesAtRevision(this.workspaceDir, this.commit, relativePath);
+      // Past the limit, content is fetched again on each read
+      if (this.fetchedBytes + content.length <= MAX_SNAPSHOT_BYTES) {
+        this.fetched.set(relativePath, content);
+        this.fetchedBytes += content.length;
+      }
+      return content;
+    }
+    if (stat && await this.isIgnored(relativePath)) {
+      return fs.promises.readFile(filePath);
+    }
+    throw missingFile(filePath, this.id);
+  }
+
+  private async isIgnored(relativePath: string): Promise<boolean> {
+    try {
+      await runGit(this.workspaceDir, ['check-ignore', '-q', '--', relativePath.split(path.sep).join('/')]);
+      return true;
+    } catch {
+      return false;
+    }
+  }
+}
+
+/**
+ * Error for changed content past what a snapshot may record
+ */
+function tooLarge(bytes: number): ToolError {
+  return new ToolError('invalid-argument',
+    `The changed files hold more than ${bytes / 1048576} MB, more than a snapshot may record; commit or stash some changes first`);
+}
+
+/**
+ * Record the workspace as it is now
+ * The ID is a hash of the commit and the changed content, so the same state
+ * gives the same ID. Recording stops with an error once the changed content
+ * passes maxBytes
+ */
+export async function takeSnapshot(workspaceDir: string, maxBytes = MAX_SNAPSHOT_BYTES): Promise<WorkspaceSnapshot> {
+  const createdAt = Date.now();
+  const changes = await workingChanges(workspaceDir);
+  if (!changes) {
+    throw new ToolError('unsupported', `${workspaceDir} is not in a git repository; snapshots record changes from a commit`);
+  }
+  let commit: string;
+  try {
+    commit = (await runGit(workspaceDir, ['rev-parse', 'HEAD'])).trim();
+  } catch {
+    throw new ToolError('unsupported', 'The repository has no commits yet; snapshots record changes from a commit');
+  }
+  const changed = new Map<string, Buffer | null>();
+  let recorded = 0;
+  const record = (relativePath: string, content: Buffer | null) => {
+    recorded += (content?.length ?? 0) - (changed.get(relativePath)?.length ?? 0);
+    if (recorded > maxBytes) {
+      throw tooLarge(maxBytes);
+    }
+    changed.set(relativePath, content);
+  };
+  for (const relativePath of changes.keys()) {
+    const filePath = path.join(workspaceDir, relativePath);
+    const stat = await fs.promises.stat(filePath).catch(() => undefined);
+    if (stat && recorded + stat.size > maxBytes) {
+      throw tooLarge(maxBytes);
+    }
+    record(relativePath, stat ? await fs.promises.readFile(filePath).catch(() => null) : null);
+  }
+  // Overlays are part of what queries see
+  for (const [filePath, entry] of sharedOverlay().entries()) {
+    if (isUnder(filePath, workspaceDir)) {
+      record(path.relative(workspaceDir, filePath), Buffer.from(entry.content, 'utf8'));
+    }
+  }
+  const hash = crypto.createHash('sha256').update(commit);
+  for (const relativePath of Array.from(changed.keys()).sort()) {
+    const content = changed.get(relativePath);
+    hash.update(`\0${relativePath}\0`).update(content ?? '<missing>');
+  }
+  const tracked = new Set(await filesAtRevision(workspaceDir, commit));
+  return new WorkspaceSnapshot(hash.digest('hex').substring(0, 12), workspaceDir, commit, createdAt, changed, tracked);
+}
+
+/**
+ * Snapshots and the sessions pinned to them
+ * A snapshot is dropped when the last session pinned to it releases it, and
+ * the snapshots kept record at most maxBytes together
+ */
+export class SnapshotStore {
+  private snapshots = new Map<string, WorkspaceSnapshot>();
+  private pins = new Map<string, string>();
+
+  constructor(private maxBytes = MAX_SNAPSHOT_BYTES) {}
+
+  /**
+   * Pin a session to a snapshot, replacing the one it was pinned to
+   * A new snapshot that would take the kept ones past the limit is refused
+   */
+  pin(session: string, snapshot: WorkspaceSnapshot): void {
+    if (!this.snapshots.has(snapshot.id)) {
+      const replaced = this.pinned(session);
+      const others = Array.from(this.snapshots.values())
+        .filter((kept) => kept !== replaced || this.sessionsOf(kept.id) > 1)
+        .reduce((total, kept) => total + kept.size(), 0);
+      if (others + snapshot.size() > this.maxBytes) {
+        throw new ToolError('invalid-argument',
+          `Snapshot ${snapshot.id} records ${Math.ceil(snapshot.size() / 1048576)} MB; with the snapshots kept that is over the ${this.maxBytes / 1048576} MB limit. Release a snapshot first`);
+      }
+    }
+    this.release(session);
+    // An equal snapshot taken earlier keeps the content it fetched
+    const kept = this.snapshots.get(snapshot.id) ?? snapshot;
+    this.snapshots.set(kept.id, kept);
+    this.pins.set(session, kept.id);
+  }
+
+  /**
+   * A snapshot by ID
+   */
+  get(id: string): WorkspaceSnapshot | undefined {
+    return this.snapshots.get(id);
+  }
+
+  /**
+   * Unpin a session; returns the snapshot it was pinned to
+   */
+  release(session: string): WorkspaceSnapshot | undefined {
+    const id = this.pins.get(session);
+    if (!id) {
+      return undefined;
+    }
+    this.pins.delete(session);
+    const snapshot = this.snapshots.get(id);
+    if (this.sessionsOf(id) === 0) {
+      this.snapshots.delete(id);
+    }
+    return snapshot;
+  }
+
+  private sessionsOf(id: string): number {
+    return Array.from(this.pins.values()).filter((pinned) => pinned === id).length;
+  }
+
+  /**
+   * The snapshot a session is pinned to
+   */
+  pinned(session: string): WorkspaceSnapshot | undefined {
+    const id = this.pins.get(session);
+    return id ? this.snapshots.get(id) : undefined;
+  }
+
+  /**
+   * Snapshots with the number of sessions pinned to each
+   */
+  list(): Array<{ snapshot: WorkspaceSnapshot; sessions: number }> {
+    return Array.from(this.snapshots.values()).map((snapshot) => ({
+      snapshot,
+      sessions: this.sessionsOf(snapshot.id),
+    }));
+  }
+}
+
+const snapshotContext = new AsyncLocalStorage<WorkspaceSnapshot>();
+
+/**
+ * Run a function with its file reads, including from async work it starts, served from a snapshot
+ */
+export function withSnapshot<T>(snapshot: WorkspaceSnapshot | undefined, fn: () => T): T {
+  return snapshot ? snapshotContext.run(snapshot, fn) : fn();
+}
+
+/**
+ * Run shared work, such as index maintenance or syncing the language server, on the files as they are now
+ */
+export function unpinned<T>(fn: () => T): T {
+  return snapshotContext.exit(fn);
+}
+
+/**
+ * Snapshot reads are served from, or undefined for the files as they are now
+ */
+export function currentSnapshot(): WorkspaceSnapshot | undefined {
+  return snapshotContext.getStore();
+}
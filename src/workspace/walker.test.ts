@@ -0,0 +1,103 @@
+/**
+ * Tests for the workspace walker
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { walkWorkspaceFiles } from './walker';
+import { searchLexical } from '../search/lexical';
+
+describe('Workspace walker', () => {
+  let workspace: string;
+
+  beforeEach(() => {
+    workspace = fs.mkdtempSync(path.join(os.tmpdir(), 'walker-'));
+    fs.mkdirSync(path.join(workspace, 'src', 'nested'), { recursive: true });
+    fs.writeFileSync(path.join(workspace, 'src', 'a.ts'), 'a\n');
+    fs.writeFileSync(path.join(workspace, 'src', 'nested', 'b.ts'), 'b\n');
+    // A link to a file, a link back to an ancestor, and a second path to src
+    fs.symlinkSync(path.join(workspace, 'src', 'a.ts'), path.join(workspace, 'alias.ts'));
+    fs.symlinkSync(path.join(workspace, 'src'), path.join(workspace, 'src', 'nested', 'loop'));
+    fs.symlinkSync(path.join(workspace, 'src'), path.join(workspace, 'linked'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(workspace, { recursive: true, force: true });
+  });
+
+  it('should skip symlinks by default', async () => {
+    const files = await walkWorkspaceFiles(workspace, { followSymlinks: false });
+    expect(files.map((f) => f.relativePath)).toEqual([
+      path.join('src', 'a.ts'),
+      path.join('src', 'nested', 'b.ts'),
+    ]);
+  });
+
+  it('should follow symlinks without looping or reporting a file twice', async () => {
+    const files = await walkWorkspaceFiles(workspace, { followSymlinks: true });
+    // Real paths win over the alias and the linked directory
+    expect(files.map((f) => f.relativePath)).toEqual([
+      path.join('src', 'a.ts'),
+      path.join('src', 'nested', 'b.ts'),
+    ]);
+  });
+
+  it('should leave out files a link reaches outside the workspace', async () => {
+    const outside = fs.mkdtempSync(path.join(os.tmpdir(), 'walker-outside-'));
+    try {
+      fs.writeFileSync(path.join(outside, 'shared.ts'), 'shared\n');
+      fs.symlinkSync(outside, path.join(workspace, 'shared'));
+      fs.symlinkSync(path.join(outside, 'shared.ts'), path.join(workspace, 'shared-file.ts'));
+      const excluded: string[] = [];
+      const files = await walkWorkspaceFiles(workspace, {
+        followSymlinks: true,
+        onExcluded: (relativePath, rule) => excluded.push(`${relativePath}: ${rule}`),
+      });
+      expect(files.map((f) => f.relativePath)).toEqual([path.join('src', 'a.ts'), path.join('src', 'nested', 'b.ts')]);
+      expect(excluded).toContain('shared: symlink');
+      expect(excluded).toContain('shared-file.ts: symlink');
+    } finally {
+      fs.rmSync(outside, { recursive: true, force: true });
+    }
+  });
+
+  it('should stop entering directories at the deadline', async () => {
+    expect(await walkWorkspaceFiles(workspace, { deadline: Date.now() - 1 })).toEqual([]);
+    expect((await walkWorkspaceFiles(workspace, { deadline: Date.now() + 60_000 })).length).toBe(2);
+  });
+
+  it('should keep the first files by path when capped', async () => {
+    for (const name of ['z.ts', 'm.ts', 'b.ts']) {
+      fs.writeFileSync(path.join(workspace, name), `${name}\n`);
+    }
+    for (const concurrency of [1, 8]) {
+      const files = await walkWorkspaceFiles(workspace, { maxFiles: 3, concurrency });
+      expect(files.map((file) => file.relativePath)).toEqual(['b.ts', 'm.ts', path.join('src', 'a.ts')]);
+    }
+  });
+
+  it('should skip lock files and minified bundles unless configured otherwise', async () => {
+    for (const name of ['package-lock.json', 'go.sum', 'app.min.js', 'app.js.map', 'data.snap']) {
+      fs.writeFileSync(path.join(workspace, 'src', name), 'needle\n');
+    }
+    const excluded: string[] = [];
+    const files = await walkWorkspaceFiles(workspace, {
+      onExcluded: (relativePath, rule) => rule === 'excludedFile' && excluded.push(path.basename(relativePath)),
+    });
+    expect(files.map((f) => path.basename(f.relativePath))).toEqual(['a.ts', 'data.snap', 'b.ts']);
+    expect(excluded.sort()).toEqual(['app.js.map', 'app.min.js', 'go.sum', 'package-lock.json']);
+
+    process.env.WORKSPACE_EXCLUDE_FILES = '*.snap, !go.sum';
+    try {
+      const configured = await walkWorkspaceFiles(workspace);
+      expect(configured.map((f) => path.basename(f.relativePath))).toEqual(['a.ts', 'go.sum', 'b.ts']);
+    } finally {
+      delete process.env.WORKSPACE_EXCLUDE_FILES;
+    }
+
+    const all = await searchLexical(workspace, 'needle', { includeGenerated: true });
+    expect(all.matches.map((m) => path.basename(m.filePath)).sort())
+      .toEqual(['app.js.map', 'app.min.js', 'data.snap', 'go.sum', 'package-lock.json']);
+  });
+});
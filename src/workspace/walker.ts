@@ -0,0 +1,303 @@
+/**
+ * Workspace walker - enumerate source files in the workspace
+ * Applies the same exclusion rules as the file watcher. Symbolic links are
+ * skipped unless followSymlinks is set; then links leading outside the
+ * workspace and links back into a directory being walked are cut off, and a
+ * physical file reached by several paths is reported once
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { createLogger, Component } from '../logging/logger.js';
+import { GitignoreMatcher } from '../watcher/gitignore.js';
+import { WatcherConfig, defaultWatcherConfig } from '../watcher/watcher.js';
+import { matchesGlob } from './glob.js';
+import { createLimiter, workerCount } from './pool.js';
+import { assertInsideRoots, canonicalizePath, PathOutsideWorkspaceError } from './paths.js';
+import { ToolError } from './errors.js';
+
+const walkerLogger = createLogger(Component.TOOLS);
+
+/**
+ * Rule that kept a path out of the walk
+ */
+export type ExclusionRule = 'hidden' | 'excludedDir' | 'excludedExtension' | 'excludedFile' | 'gitignore' | 'symlink';
+
+/**
+ * Options for walking the workspace
+ */
+export interface WalkOptions {
+  // Only include files under this path (absolute or relative to the workspace)
+  pathPrefix?: string;
+  // Return at most this many files, the first by path
+  maxFiles?: number;
+  // Stop entering directories at this time (Date.now() milliseconds); the files found before it are returned
+  deadline?: number;
+  // Override exclusion rules
+  config?: Partial<WatcherConfig>;
+  // Maximum concurrent directory reads and stats (default: workerCount())
+  concurrency?: number;
+  // Size limit of a file instead of config.maxFileSize, e.g. a larger one for archives
+  maxFileSizeOf?: (relativePath: string) => number;
+  // Called for files skipped for exceeding the size limit
+  onLargeFile?: (relativePath: string, size: number) => void;
+  // Called for each file or directory left out by an exclusion rule (a directory's contents are not visited)
+  onExcluded?: (relativePath: string, rule: ExclusionRule, isDirectory: boolean) => void;
+  // Follow symbolic links (default: config.followSymlinks)
+  followSymlinks?: boolean;
+}
+
+/**
+ * Workspace file entry
+ */
+export interface WorkspaceFile {
+  absolutePath: string;
+  relativePath: string;
+  size: number;
+}
+
+/**
+ * A walked file with the identity of the physical file behind it
+ */
+interface WalkedFile extends WorkspaceFile {
+  inode: string;
+  viaSymlink: boolean;
+}
+
+/**
+ * Resolve a user-supplied path against the workspace directory
+ * The result uses the on-disk spelling, whatever Unicode form or case the client sent.
+ * Paths that escape the workspace, by `..`, an absolute path, or a symlink,
+ * throw PathOutsideWorkspaceError
+ */
+export function resolveWorkspacePath(workspaceDir: string, filePath: string): string {
+  const resolved = path.isAbsolute(filePath) ? path.normalize(filePath) : path.resolve(workspaceDir, filePath);
+  const canonical = canonicalizePath(resolved, workspaceDir);
+  assertInsideRoots(canonical, [path.resolve(workspaceDir)]);
+  return canonical;
+}
+
+/**
+ * The rule leaving a file or directory out of a walk, with the exclusions of a config
+ */
+function exclusionRules(workspaceDir: string, config: WatcherConfig): (fullPath: string, isDirectory: boolean) => ExclusionRule | undefined {
+  let gitignore: GitignoreMatcher | undefined;
+  try {
+    gitignore = new GitignoreMatcher(workspaceDir);
+  } catch (err) {
+    walkerLogger.debug('Could not load gitignore for %s: %s', workspaceDir, err);
+  }
+
+  return (fullPath, isDirectory) => {
+    const name = path.basename(fullPath);
+    if (name.startsWith('.')) {
+      return 'hidden';
+    }
+    if (isDirectory && config.excludedDirs.has(name)) {
+      return 'excludedDir';
+    }
+    if (!isDirectory) {
+      const ext = path.extname(name).toLowerCase();
+      if (config.excludedFileExtensions.has(ext) || config.largeBinaryExtensions.has(ext)) {
+        return 'excludedExtension';
+      }
+      if (matchesGlob(name, config.excludedFileNames)) {
+        return 'excludedFile';
+      }
+    }
+    const relativePath = path.relative(workspaceDir, fullPath);
+    return gitignore && gitignore.shouldIgnore(relativePath, isDirectory) ? 'gitignore' : undefined;
+  };
+}
+
+/**
+ * Whether a walk would leave out a workspace-relative file, by it or a directory above it
+ * For files that may not exist on disk, such as ones deleted since a snapshot
+ */
+export function excludedFromWalk(workspaceDir: string, options: Pick<WalkOptions, 'config'> = {}): (relativePath: string) => boolean {
+  const exclusionRule = exclusionRules(workspaceDir, { ...defaultWatcherConfig(), ...options.config });
+  return (relativePath) => {
+    const parts = relativePath.split(path.sep);
+    return parts.some((_, i) => exclusionRule(path.join(workspaceDir, ...parts.slice(0, i + 1)), i < parts.length - 1) !== undefined);
+  };
+}
+
+/**
+ * Walk the workspace and return all candidate source files
+ */
+export async function walkWorkspaceFiles(
+  workspaceDir: string,
+  options: WalkOptions = {}
+): Promise<WorkspaceFile[]> {
+  const config = { ...defaultWatcherConfig(), ...options.config };
+  const followSymlinks = options.followSymlinks ?? config.followSymlinks;
+  const deadline = options.deadline ?? Infinity;
+
+  const startDir = options.pathPrefix
+    ? resolveWorkspacePath(workspaceDir, options.pathPrefix)
+    : workspaceDir;
+
+  const exclusionRule = exclusionRules(workspaceDir, config);
+
+  const isExcluded = (fullPath: string, isDirectory: boolean): boolean => {
+    const rule = exclusionRule(fullPath, isDirectory);
+    if (rule) {
+      options.onExcluded?.(path.relative(workspaceDir, fullPath), rule, isDirectory);
+    }
+    return rule !== undefined;
+  };
+
+  const limit = createLimiter(options.concurrency ?? workerCount());
+
+  const fileEntry = (fullPath: string, stats: fs.Stats, viaSymlink: boolean): WalkedFile[] => {
+    const relativePath = path.relative(workspaceDir, fullPath);
+    if (stats.size > (options.maxFileSizeOf?.(relativePath) ?? config.maxFileSize)) {
+      walkerLogger.debug('Skipping large file: %s', fullPath);
+      options.onLargeFile?.(relativePath, stats.size);
+      return [];
+    }
+    return [{
+      absolutePath: fullPath,
+      relativePath,
+      size: stats.size,
+      inode: `${stats.dev}:${stats.ino}`,
+      viaSymlink,
+    }];
+  };
+
+  const statFile = async (fullPath: string, viaSymlink: boolean): Promise<WalkedFile[]> => {
+    try {
+      return fileEntry(fullPath, await limit(() => fs.promises.stat(fullPath)), viaSymlink);
+    } catch (err) {
+      walkerLogger.debug('Could not stat %s: %s', fullPath, err);
+      return [];
+    }
+  };
+
+  // A link is followed to its target, unless the target is outside the
+  // workspace or a directory already being walked above it, which would loop forever
+  const followLink = async (fullPath: string, ancestors: Set<string>): Promise<WalkedFile[]> => {
+    try {
+      assertInsideRoots(await fs.promises.realpath(fullPath), [path.resolve(workspaceDir)]);
+    } catch (err) {
+      if (!(err instanceof PathOutsideWorkspaceError)) {
+        walkerLogger.debug('Skipping broken symlink %s: %s', fullPath, err);
+        return [];
+      }
+      walkerLogger.debug('Skipping symlink %s leading outside the workspace', fullPath);
+      options.onExcluded?.(path.relative(workspaceDir, fullPath), 'symlink', false);
+      return [];
+    }
+    let stats: fs.Stats;
+    try {
+      stats = await limit(() => fs.promises.stat(fullPath));
+    } catch (err) {
+      walkerLogger.debug('Skipping broken symlink %s: %s', fullPath, err);
+      return [];
+    }
+    if (stats.isDirectory()) {
+      if (isExcluded(fullPath, true)) {
+        return [];
+      }
+      if (ancestors.has(`${stats.dev}:${stats.ino}`)) {
+        walkerLogger.debug('Skipping symlink cycle at %s', fullPath);
+        return [];
+      }
+      return walkDir(fullPath, ancestors, stats, true);
+    }
+    if (stats.isFile() && !isExcluded(fullPath, false)) {
+      return fileEntry(fullPath, stats, true);
+    }
+    return [];
+  };
+
+  // Subdirectories and files are visited concurrently; results are joined in
+  // sorted entry order so the output stays deterministic
+  const walkDir = async (
+    dir: string,
+    parentAncestors: Set<string>,
+    dirStats: fs.Stats | undefined,
+    viaSymlink: boolean
+  ): Promise<WalkedFile[]> => {
+    if (Date.now() >= deadline) {
+      return [];
+    }
+
+    // Directory identities on the path from the start, for cycle detection
+    let ancestors = parentAncestors;
+    if (followSymlinks) {
+      try {
+        const stats = dirStats ?? await limit(() => fs.promises.stat(dir));
+        ancestors = new Set(parentAncestors).add(`${stats.dev}:${stats.ino}`);
+      } catch (err) {
+        walkerLogger.debug('Could not stat directory %s: %s', dir, err);
+        return [];
+      }
+    }
+
+    let entries: fs.Dirent[];
+    try {
+      entries = await limit(() => fs.promises.readdir(dir, { withFileTypes: true }));
+    } catch (err) {
+      walkerLogger.debug('Could not read directory %s: %s', dir, err);
+      return [];
+    }
+
+    // Sort for deterministic output
+    entries.sort((a, b) => a.name.localeCompare(b.name));
+
+    const results = await Promise.all(entries.map((entry) => {
+      const fullPath = path.join(dir, entry.name);
+      if (entry.isDirectory()) {
+        return isExcluded(fullPath, true) ? [] : walkDir(fullPath, ancestors, undefined, viaSymlink);
+      }
+      if (entry.isFile()) {
+        return isExcluded(fullPath, false) ? [] : statFile(fullPath, viaSymlink);
+      }
+      if (entry.isSymbolicLink()) {
+        if (!followSymlinks) {
+          walkerLogger.debug('Skipping symlink %s (followSymlinks is off)', fullPath);
+          options.onExcluded?.(path.relative(workspaceDir, fullPath), 'symlink', false);
+          return [];
+        }
+        return followLink(fullPath, ancestors);
+      }
+      return [];
+    }));
+    return results.flat();
+  };
+
+  let startStats: fs.Stats;
+  try {
+    startStats = await fs.promises.stat(startDir);
+  } catch (err) {
+    throw new ToolError('not-found', `Path does not exist: ${startDir}`);
+  }
+
+  const files = startStats.isFile()
+    ? fileEntry(startDir, startStats, false)
+    : await walkDir(startDir, new Set(), startStats, false);
+  const walked = followSymlinks ? dedupePhysicalFiles(files) : files;
+  // The whole tree is walked before capping, so the same files are kept however the reads interleave
+  const kept = options.maxFiles === undefined
+    ? walked
+    : walked
+      .sort((a, b) => (a.relativePath < b.relativePath ? -1 : a.relativePath > b.relativePath ? 1 : 0))
+      .slice(0, options.maxFiles);
+  return kept.map(({ absolutePath, relativePath, size }) => ({ absolutePath, relativePath, size }));
+}
+
+/**
+ * Keep one path per physical file, preferring a path without symlinks,
+ * then the first in walk order; the walk order is kept otherwise
+ */
+function dedupePhysicalFiles(files: WalkedFile[]): WalkedFile[] {
+  const chosen = new Map<string, WalkedFile>();
+  for (const file of files) {
+    const current = chosen.get(file.inode);
+    if (!current || (current.viaSymlink && !file.viaSymlink)) {
+      chosen.set(file.inode, file);
+    }
+  }
+  return files.filter((file) => chosen.get(file.inode) === file);
+}